@@ -0,0 +1,34 @@
+use anyhow::{Context, Result};
+use urlencoding::encode;
+
+use super::ApiClient;
+use crate::types::ActivityEvent;
+
+impl ApiClient {
+    /// Account activity, newest first. `since` (ms) is inclusive.
+    pub async fn list_activity(
+        &self,
+        limit: Option<u32>,
+        since: Option<i64>,
+        event_type: Option<&str>,
+    ) -> Result<Vec<ActivityEvent>> {
+        self.require_auth()?;
+        let mut params = vec![];
+        if let Some(l) = limit {
+            params.push(format!("limit={l}"));
+        }
+        if let Some(s) = since {
+            params.push(format!("since={s}"));
+        }
+        if let Some(t) = event_type {
+            params.push(format!("type={}", encode(t)));
+        }
+        let qs = if params.is_empty() {
+            String::new()
+        } else {
+            format!("?{}", params.join("&"))
+        };
+        let resp = self.get(&format!("/api/activity{qs}")).await?;
+        serde_json::from_str(&resp.body).context("failed to parse activity")
+    }
+}
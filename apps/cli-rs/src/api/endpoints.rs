@@ -1,7 +1,10 @@
 use anyhow::{Context, Result};
 
 use super::ApiClient;
-use crate::types::{CreateEndpointRequest, Endpoint, EndpointList, UpdateEndpointRequest};
+use crate::types::{
+    CreateEndpointRequest, DryRunStats, Endpoint, EndpointList, EndpointVersion, MockCanaryStatus,
+    NetworkMatch, PausedResponse, RejectedRequest, SamplingStats, SinkLatency, SlugAvailability, UpdateEndpointRequest, UsageReport,
+};
 
 impl ApiClient {
     pub async fn create_endpoint(&self, req: &CreateEndpointRequest) -> Result<Endpoint> {
@@ -10,6 +13,12 @@ impl ApiClient {
         serde_json::from_str(&resp.body).context("failed to parse endpoint")
     }
 
+    pub async fn check_slug(&self, slug: &str) -> Result<SlugAvailability> {
+        self.require_auth()?;
+        let resp = self.get(&format!("/api/slugs/{}", urlencoding::encode(slug))).await?;
+        serde_json::from_str(&resp.body).context("failed to parse slug availability")
+    }
+
     pub async fn list_endpoints(&self) -> Result<EndpointList> {
         self.require_auth()?;
         let resp = self.get("/api/endpoints").await?;
@@ -28,6 +37,155 @@ impl ApiClient {
         serde_json::from_str(&resp.body).context("failed to parse endpoint")
     }
 
+    /// Saved configuration versions, newest first.
+    pub async fn list_endpoint_versions(&self, slug: &str) -> Result<Vec<EndpointVersion>> {
+        self.require_auth()?;
+        let resp = self
+            .get(&format!("/api/endpoints/{}/versions", urlencoding::encode(slug)))
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse endpoint versions")
+    }
+
+    /// Restore a saved configuration version.
+    pub async fn rollback_endpoint(&self, slug: &str, version: u32) -> Result<Endpoint> {
+        self.require_auth()?;
+        let body = serde_json::json!({ "version": version });
+        let resp = self
+            .post(&format!("/api/endpoints/{}/rollback", urlencoding::encode(slug)), &body)
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse endpoint")
+    }
+
+    /// The running mock canary and its counts per version.
+    pub async fn mock_canary(&self, slug: &str) -> Result<MockCanaryStatus> {
+        self.require_auth()?;
+        let resp = self
+            .get(&format!("/api/endpoints/{}/mock-canary", urlencoding::encode(slug)))
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse mock canary")
+    }
+
+    /// Make the canary's response the endpoint's mock response.
+    pub async fn promote_mock_canary(&self, slug: &str) -> Result<Endpoint> {
+        self.require_auth()?;
+        let resp = self
+            .post(
+                &format!("/api/endpoints/{}/mock-canary/promote", urlencoding::encode(slug)),
+                &serde_json::json!({}),
+            )
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse endpoint")
+    }
+
+    /// Stop the canary; every sender gets the stable mock again.
+    pub async fn rollback_mock_canary(&self, slug: &str) -> Result<Endpoint> {
+        self.require_auth()?;
+        let resp = self
+            .delete(&format!("/api/endpoints/{}/mock-canary", urlencoding::encode(slug)))
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse endpoint")
+    }
+
+    pub async fn network_stats(&self, slug: &str) -> Result<Vec<NetworkMatch>> {
+        self.require_auth()?;
+        let resp = self
+            .get(&format!("/api/endpoints/{}/network-stats", urlencoding::encode(slug)))
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse network stats")
+    }
+
+    pub async fn rejected_requests(
+        &self,
+        slug: &str,
+        limit: u32,
+        since: Option<i64>,
+    ) -> Result<Vec<RejectedRequest>> {
+        self.require_auth()?;
+        let mut path = format!("/api/endpoints/{}/rejected?limit={limit}", urlencoding::encode(slug));
+        if let Some(since) = since {
+            path.push_str(&format!("&since={since}"));
+        }
+        let resp = self.get(&path).await?;
+        serde_json::from_str(&resp.body).context("failed to parse rejected requests")
+    }
+
+    pub async fn dry_run_stats(&self, slug: &str) -> Result<DryRunStats> {
+        self.require_auth()?;
+        let resp = self
+            .get(&format!("/api/endpoints/{}/dry-run-stats", urlencoding::encode(slug)))
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse dry run stats")
+    }
+
+    pub async fn sampling_stats(&self, slug: &str) -> Result<SamplingStats> {
+        self.require_auth()?;
+        let resp = self
+            .get(&format!("/api/endpoints/{}/sampling-stats", urlencoding::encode(slug)))
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse sampling stats")
+    }
+
+    pub async fn sink_latency(&self, slug: &str, window: &str) -> Result<SinkLatency> {
+        let body = self.sink_latency_raw(slug, window, "json").await?;
+        serde_json::from_str(&body).context("failed to parse sink latency")
+    }
+
+    /// Sink latency in the Prometheus text format.
+    pub async fn sink_latency_metrics(&self, slug: &str, window: &str) -> Result<String> {
+        self.sink_latency_raw(slug, window, "prometheus").await
+    }
+
+    async fn sink_latency_raw(&self, slug: &str, window: &str, format: &str) -> Result<String> {
+        self.require_auth()?;
+        let resp = self
+            .get(&format!(
+                "/api/endpoints/{}/sink-latency?window={}&format={format}",
+                urlencoding::encode(slug),
+                urlencoding::encode(window)
+            ))
+            .await?;
+        Ok(resp.body)
+    }
+
+    /// Usage report for one calendar month (`YYYY-MM`).
+    pub async fn usage_report(&self, slug: &str, month: &str) -> Result<UsageReport> {
+        self.require_auth()?;
+        let resp = self
+            .get(&format!(
+                "/api/endpoints/{}/report?month={}",
+                urlencoding::encode(slug),
+                urlencoding::encode(month)
+            ))
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse usage report")
+    }
+
+    /// Stop capturing; the receiver answers with `response` (or its default
+    /// 503) until the endpoint is resumed.
+    pub async fn pause_endpoint(
+        &self,
+        slug: &str,
+        response: Option<&PausedResponse>,
+    ) -> Result<Endpoint> {
+        self.require_auth()?;
+        let body = serde_json::json!({ "response": response });
+        let resp = self
+            .post(&format!("/api/endpoints/{}/pause", urlencoding::encode(slug)), &body)
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse endpoint")
+    }
+
+    pub async fn resume_endpoint(&self, slug: &str) -> Result<Endpoint> {
+        self.require_auth()?;
+        let resp = self
+            .post(
+                &format!("/api/endpoints/{}/resume", urlencoding::encode(slug)),
+                &serde_json::json!({}),
+            )
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse endpoint")
+    }
+
     pub async fn delete_endpoint(&self, slug: &str) -> Result<()> {
         self.require_auth()?;
         self.delete(&format!("/api/endpoints/{}", urlencoding::encode(slug))).await?;
@@ -1,9 +1,12 @@
+pub mod activity;
 pub mod client;
 pub mod device_auth;
 pub mod endpoints;
 pub mod requests;
 pub mod send;
+pub mod shares;
 pub mod stream;
+pub mod teams;
 pub mod usage;
 pub mod update;
 
@@ -25,6 +28,9 @@ pub struct ApiClient {
     pub base_url: String,
     pub webhook_url: String,
     token: Option<String>,
+    team: Option<String>,
+    operation: Option<&'static str>,
+    reveal: bool,
 }
 
 impl std::fmt::Debug for ApiClient {
@@ -33,6 +39,9 @@ impl std::fmt::Debug for ApiClient {
             .field("base_url", &self.base_url)
             .field("webhook_url", &self.webhook_url)
             .field("token", &self.token.as_ref().map(|_| "[REDACTED]"))
+            .field("team", &self.team)
+            .field("operation", &self.operation)
+            .field("reveal", &self.reveal)
             .finish()
     }
 }
@@ -66,6 +75,9 @@ impl ApiClient {
             base_url,
             webhook_url,
             token,
+            team: None,
+            operation: None,
+            reveal: false,
         })
     }
 
@@ -74,6 +86,34 @@ impl ApiClient {
         self.token = Some(token);
     }
 
+    /// Scope commands to a team (ID or name). Endpoint listing is filtered to
+    /// the team's shared endpoints and new endpoints are shared with it.
+    pub fn set_team(&mut self, team: Option<String>) {
+        self.team = team.filter(|t| !t.trim().is_empty());
+    }
+
+    /// The team context, if one was selected with `--team`.
+    pub fn team(&self) -> Option<&str> {
+        self.team.as_deref()
+    }
+
+    /// Ask for encrypted header values opened instead of masked. The server
+    /// only opens them for users with access to the endpoint's key.
+    pub fn set_reveal(&mut self, reveal: bool) {
+        self.reveal = reveal;
+    }
+
+    /// Whether captures come back with encrypted values opened (`--reveal`).
+    pub fn reveals(&self) -> bool {
+        self.reveal
+    }
+
+    /// A copy of the client whose calls are tagged with `X-Whk-Operation`, so
+    /// replays and exports are metered against the account's API usage.
+    pub fn with_operation(&self, operation: &'static str) -> Self {
+        Self { operation: Some(operation), ..self.clone() }
+    }
+
     /// Build default headers with auth.
     pub fn auth_headers(&self) -> Result<HeaderMap> {
         let mut headers = HeaderMap::new();
@@ -91,6 +131,9 @@ impl ApiClient {
                 HeaderValue::from_str(&format!("Bearer {token}"))?,
             );
         }
+        if let Some(operation) = self.operation {
+            headers.insert("x-whk-operation", HeaderValue::from_static(operation));
+        }
         Ok(headers)
     }
 
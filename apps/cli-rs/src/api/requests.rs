@@ -2,7 +2,44 @@ use anyhow::{Context, Result};
 use urlencoding::encode;
 
 use super::ApiClient;
-use crate::types::{CapturedRequest, CountResult, PaginatedRequestList, RequestList, SearchResult};
+use crate::types::{
+    AnnotateRequest, CapturedRequest, CountResult, PaginatedRequestList, RequestBodyUrl, RequestList,
+    SearchResult,
+};
+
+/// Filters for request listings; unset fields don't filter.
+#[derive(Debug, Default, Clone, Copy)]
+pub struct ListRequestsFilter<'a> {
+    /// Only requests carrying this tag
+    pub tag: Option<&'a str>,
+    /// Only CloudEvents of this type
+    pub event_type: Option<&'a str>,
+    /// Only requests from this country (two-letter code)
+    pub country: Option<&'a str>,
+    /// Only requests from this autonomous system
+    pub asn: Option<u32>,
+}
+
+impl ListRequestsFilter<'_> {
+    pub fn is_empty(&self) -> bool {
+        self.tag.is_none() && self.event_type.is_none() && self.country.is_none() && self.asn.is_none()
+    }
+
+    fn push_params(&self, params: &mut Vec<String>) {
+        if let Some(t) = self.tag {
+            params.push(format!("tag={}", encode(t)));
+        }
+        if let Some(t) = self.event_type {
+            params.push(format!("eventType={}", encode(t)));
+        }
+        if let Some(c) = self.country {
+            params.push(format!("country={}", encode(c)));
+        }
+        if let Some(asn) = self.asn {
+            params.push(format!("asn={asn}"));
+        }
+    }
+}
 
 impl ApiClient {
     pub async fn list_requests(
@@ -10,6 +47,17 @@ impl ApiClient {
         slug: &str,
         limit: Option<u32>,
         since: Option<i64>,
+    ) -> Result<RequestList> {
+        self.list_requests_filtered(slug, limit, since, &ListRequestsFilter::default())
+            .await
+    }
+
+    pub async fn list_requests_filtered(
+        &self,
+        slug: &str,
+        limit: Option<u32>,
+        since: Option<i64>,
+        filter: &ListRequestsFilter<'_>,
     ) -> Result<RequestList> {
         self.require_auth()?;
         let mut params = vec![];
@@ -19,6 +67,10 @@ impl ApiClient {
         if let Some(s) = since {
             params.push(format!("since={s}"));
         }
+        filter.push_params(&mut params);
+        if !self.reveals() {
+            params.push("reveal=false".to_string());
+        }
         let qs = if params.is_empty() {
             String::new()
         } else {
@@ -41,6 +93,7 @@ impl ApiClient {
         slug: &str,
         limit: Option<u32>,
         cursor: Option<&str>,
+        filter: &ListRequestsFilter<'_>,
     ) -> Result<PaginatedRequestList> {
         self.require_auth()?;
         let mut params = vec![];
@@ -50,6 +103,10 @@ impl ApiClient {
         if let Some(c) = cursor {
             params.push(format!("cursor={}", encode(c)));
         }
+        filter.push_params(&mut params);
+        if !self.reveals() {
+            params.push("reveal=false".to_string());
+        }
         let qs = if params.is_empty() {
             String::new()
         } else {
@@ -63,7 +120,23 @@ impl ApiClient {
 
     pub async fn get_request(&self, request_id: &str) -> Result<CapturedRequest> {
         self.require_auth()?;
-        let resp = self.get(&format!("/api/requests/{}", encode(request_id))).await?;
+        let qs = if self.reveals() { "" } else { "?reveal=false" };
+        let resp = self.get(&format!("/api/requests/{}{qs}", encode(request_id))).await?;
+        serde_json::from_str(&resp.body).context("failed to parse request")
+    }
+
+    /// Signed URL for a body the receiver kept in object storage.
+    pub async fn get_request_body_url(&self, request_id: &str) -> Result<RequestBodyUrl> {
+        self.require_auth()?;
+        let resp = self.get(&format!("/api/requests/{}/body", encode(request_id))).await?;
+        serde_json::from_str(&resp.body).context("failed to parse body URL")
+    }
+
+    /// Replace a request's note and/or tags; returns the updated request.
+    pub async fn annotate_request(&self, request_id: &str, req: &AnnotateRequest) -> Result<CapturedRequest> {
+        self.require_auth()?;
+        let qs = if self.reveals() { "" } else { "?reveal=false" };
+        let resp = self.patch(&format!("/api/requests/{}{qs}", encode(request_id)), req).await?;
         serde_json::from_str(&resp.body).context("failed to parse request")
     }
 
@@ -75,6 +148,7 @@ impl ApiClient {
         query: Option<&str>,
         from: Option<&str>,
         to: Option<&str>,
+        content_class: Option<&str>,
         limit: Option<u32>,
         offset: Option<u32>,
         order: Option<&str>,
@@ -96,6 +170,9 @@ impl ApiClient {
         if let Some(t) = to {
             params.push(format!("to={}", encode(t)));
         }
+        if let Some(c) = content_class {
+            params.push(format!("contentClass={}", encode(c)));
+        }
         if let Some(l) = limit {
             params.push(format!("limit={l}"));
         }
@@ -121,6 +198,7 @@ impl ApiClient {
         query: Option<&str>,
         from: Option<&str>,
         to: Option<&str>,
+        content_class: Option<&str>,
     ) -> Result<CountResult> {
         self.require_auth()?;
         let mut params = vec![];
@@ -139,6 +217,9 @@ impl ApiClient {
         if let Some(t) = to {
             params.push(format!("to={}", encode(t)));
         }
+        if let Some(c) = content_class {
+            params.push(format!("contentClass={}", encode(c)));
+        }
         let qs = if params.is_empty() {
             String::new()
         } else {
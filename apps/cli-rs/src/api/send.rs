@@ -1,7 +1,7 @@
 use anyhow::{Context, Result};
 
 use super::ApiClient;
-use crate::types::{SendResponse, SendWebhookRequest};
+use crate::types::{FetchedResponse, SendResponse, SendWebhookRequest};
 
 impl ApiClient {
     /// Send a test webhook to a hosted endpoint.
@@ -43,4 +43,42 @@ impl ApiClient {
             body: Some(body),
         })
     }
+
+    /// Request an arbitrary URL and keep the full response (status, every
+    /// header, raw body). Asks for an unencoded body so it can be reused as-is.
+    pub async fn fetch_response(
+        &self,
+        url: &str,
+        method: &str,
+        headers: &std::collections::HashMap<String, String>,
+        body: Option<&str>,
+    ) -> Result<FetchedResponse> {
+        let method: reqwest::Method = method
+            .to_uppercase()
+            .parse()
+            .with_context(|| format!("invalid method: {method}"))?;
+        let mut req_builder = self
+            .http
+            .request(method, url)
+            .header(reqwest::header::ACCEPT_ENCODING, "identity");
+
+        for (k, v) in headers {
+            req_builder = req_builder.header(k.as_str(), v.as_str());
+        }
+
+        if let Some(b) = body {
+            req_builder = req_builder.body(b.to_string());
+        }
+
+        let resp = req_builder.send().await.context("request failed")?;
+        let status = resp.status().as_u16();
+        let headers = resp
+            .headers()
+            .iter()
+            .filter_map(|(k, v)| Some((k.as_str().to_string(), v.to_str().ok()?.to_string())))
+            .collect();
+        let body = resp.bytes().await.context("failed to read response body")?.to_vec();
+
+        Ok(FetchedResponse { status, headers, body })
+    }
 }
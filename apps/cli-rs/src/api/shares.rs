@@ -0,0 +1,64 @@
+use anyhow::{Context, Result};
+use urlencoding::encode;
+
+use super::ApiClient;
+use crate::types::{CreateShareRequest, ShareToken, SharedRequestList};
+
+impl ApiClient {
+    pub async fn create_share(&self, slug: &str, expires_in_ms: i64) -> Result<ShareToken> {
+        self.require_auth()?;
+        let req = CreateShareRequest {
+            expires_in: expires_in_ms,
+        };
+        let resp = self
+            .post(&format!("/api/endpoints/{}/shares", encode(slug)), &req)
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse share token")
+    }
+
+    pub async fn list_shares(&self, slug: &str) -> Result<Vec<ShareToken>> {
+        self.require_auth()?;
+        let resp = self
+            .get(&format!("/api/endpoints/{}/shares", encode(slug)))
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse share tokens")
+    }
+
+    pub async fn revoke_share(&self, slug: &str, share_id: &str) -> Result<()> {
+        self.require_auth()?;
+        self.delete(&format!(
+            "/api/endpoints/{}/shares/{}",
+            encode(slug),
+            encode(share_id)
+        ))
+        .await?;
+        Ok(())
+    }
+
+    /// List requests visible to a share token. Authenticates with the share
+    /// token instead of the logged-in user's key, so no login is required.
+    pub async fn list_shared_requests(
+        &self,
+        share_token: &str,
+        limit: Option<u32>,
+        since: Option<i64>,
+    ) -> Result<SharedRequestList> {
+        let mut shared = self.clone();
+        shared.set_token(share_token.to_string());
+
+        let mut params = vec![];
+        if let Some(l) = limit {
+            params.push(format!("limit={l}"));
+        }
+        if let Some(s) = since {
+            params.push(format!("since={s}"));
+        }
+        let qs = if params.is_empty() {
+            String::new()
+        } else {
+            format!("?{}", params.join("&"))
+        };
+        let resp = shared.get(&format!("/api/shared/requests{qs}")).await?;
+        serde_json::from_str(&resp.body).context("failed to parse shared requests")
+    }
+}
@@ -4,10 +4,54 @@ use std::time::Duration;
 use tokio::sync::mpsc;
 
 use super::ApiClient;
-use crate::types::{CapturedRequest, SseEvent};
+use crate::types::{CapturedRequest, ConfigChangeEvent, EndpointEvent, SseEvent};
 
 const MAX_BUFFER_SIZE: usize = 1024 * 1024; // 1 MB
 
+/// Keepalive interval (seconds) to request from the server; the server accepts 5-120.
+const KEEPALIVE_ENV: &str = "WHK_STREAM_KEEPALIVE";
+
+/// Seconds without any data, keepalives included, before the stream is treated as dead.
+const READ_TIMEOUT_ENV: &str = "WHK_STREAM_READ_TIMEOUT";
+
+/// Stream liveness settings, tunable through the environment for networks whose
+/// proxies drop idle connections.
+#[derive(Debug, Default, PartialEq)]
+struct StreamTuning {
+    keepalive_secs: Option<u64>,
+    read_timeout: Option<Duration>,
+}
+
+impl StreamTuning {
+    fn from_env() -> Result<Self> {
+        Self::parse(
+            std::env::var(KEEPALIVE_ENV).ok().as_deref(),
+            std::env::var(READ_TIMEOUT_ENV).ok().as_deref(),
+        )
+    }
+
+    fn parse(keepalive: Option<&str>, read_timeout: Option<&str>) -> Result<Self> {
+        let keepalive_secs = keepalive
+            .map(|v| match v.trim().parse::<u64>() {
+                Ok(secs @ 5..=120) => Ok(secs),
+                _ => Err(anyhow::anyhow!("{KEEPALIVE_ENV} must be 5-120 seconds")),
+            })
+            .transpose()?;
+        let read_timeout = read_timeout
+            .map(|v| match v.trim().parse::<u64>() {
+                Ok(secs) if secs > keepalive_secs.unwrap_or(30) => Ok(Duration::from_secs(secs)),
+                _ => Err(anyhow::anyhow!(
+                    "{READ_TIMEOUT_ENV} must be a number of seconds greater than the keepalive interval"
+                )),
+            })
+            .transpose()?;
+        Ok(Self {
+            keepalive_secs,
+            read_timeout,
+        })
+    }
+}
+
 impl ApiClient {
     /// Connect to the SSE stream for an endpoint and send events to the channel.
     /// Blocks until the stream ends or the channel is closed.
@@ -15,9 +59,41 @@ impl ApiClient {
         &self,
         slug: &str,
         tx: mpsc::Sender<SseEvent>,
+    ) -> Result<()> {
+        let mut path = format!("/api/stream/{}", urlencoding::encode(slug));
+        if !self.reveals() {
+            path.push_str("?reveal=false");
+        }
+        self.stream_events(&path, tx, parse_sse_event).await
+    }
+
+    /// Connect to an endpoint's configuration event stream and send events to
+    /// the channel. Blocks until the stream ends or the channel is closed.
+    pub async fn stream_endpoint_events(
+        &self,
+        slug: &str,
+        tx: mpsc::Sender<EndpointEvent>,
+    ) -> Result<()> {
+        let path = format!("/api/endpoints/{}/events", urlencoding::encode(slug));
+        self.stream_events(&path, tx, parse_endpoint_event).await
+    }
+
+    /// Read server-sent events from `path`, parse each with `parse` and send
+    /// the results to the channel.
+    async fn stream_events<T>(
+        &self,
+        path: &str,
+        tx: mpsc::Sender<T>,
+        parse: fn(&str, &str) -> Option<T>,
     ) -> Result<()> {
         self.require_auth()?;
         let headers = self.auth_headers()?;
+        let tuning = StreamTuning::from_env()?;
+
+        let mut path = path.to_string();
+        if let Some(secs) = tuning.keepalive_secs {
+            path.push_str(&format!("?keepalive={secs}"));
+        }
 
         let sse_client = reqwest::Client::builder()
             .connect_timeout(Duration::from_secs(30))
@@ -25,7 +101,7 @@ impl ApiClient {
             .context("failed to create SSE client")?;
 
         let resp = sse_client
-            .get(self.url(&format!("/api/stream/{}", urlencoding::encode(slug))))
+            .get(self.url(&path))
             .headers(headers)
             .header("Accept", "text/event-stream")
             .header("Cache-Control", "no-cache")
@@ -44,7 +120,14 @@ impl ApiClient {
         let mut event_type = String::new();
         let mut data_lines: Vec<String> = Vec::new();
 
-        while let Some(chunk) = stream.next().await {
+        loop {
+            let next = match tuning.read_timeout {
+                Some(limit) => tokio::time::timeout(limit, stream.next())
+                    .await
+                    .map_err(|_| anyhow::anyhow!("SSE stream stalled: no data for {}s", limit.as_secs()))?,
+                None => stream.next().await,
+            };
+            let Some(chunk) = next else { break };
             let chunk = chunk.context("stream read error")?;
             buffer.push_str(&String::from_utf8_lossy(&chunk));
 
@@ -63,7 +146,7 @@ impl ApiClient {
                 if line.is_empty() {
                     if !data_lines.is_empty() {
                         let data = data_lines.join("\n");
-                        let event = parse_sse_event(&event_type, &data);
+                        let event = parse(&event_type, &data);
                         if let Some(ev) = event && tx.send(ev).await.is_err() {
                             return Ok(());
                         }
@@ -104,10 +187,47 @@ fn parse_sse_event(event_type: &str, data: &str) -> Option<SseEvent> {
     }
 }
 
+fn parse_endpoint_event(event_type: &str, data: &str) -> Option<EndpointEvent> {
+    match event_type {
+        "connected" => {
+            let _: serde_json::Value = serde_json::from_str(data).ok()?;
+            Some(EndpointEvent::Connected)
+        }
+        "config_changed" => {
+            let change: ConfigChangeEvent = serde_json::from_str(data).ok()?;
+            Some(EndpointEvent::ConfigChanged(change))
+        }
+        "endpoint_expired" => {
+            let data: serde_json::Value = serde_json::from_str(data).ok()?;
+            Some(EndpointEvent::Expired {
+                expires_at: data.get("expiresAt")?.as_i64()?,
+            })
+        }
+        "endpoint_deleted" => Some(EndpointEvent::Deleted),
+        "timeout" => Some(EndpointEvent::Timeout),
+        _ => None,
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
 
+    #[test]
+    fn test_stream_tuning_parse() {
+        assert_eq!(StreamTuning::parse(None, None).unwrap(), StreamTuning::default());
+
+        let tuning = StreamTuning::parse(Some("10"), Some("25")).unwrap();
+        assert_eq!(tuning.keepalive_secs, Some(10));
+        assert_eq!(tuning.read_timeout, Some(Duration::from_secs(25)));
+
+        assert!(StreamTuning::parse(Some("1"), None).is_err());
+        assert!(StreamTuning::parse(Some("abc"), None).is_err());
+        // Read timeout must outlast the (default 30s) keepalive interval
+        assert!(StreamTuning::parse(None, Some("20")).is_err());
+        assert!(StreamTuning::parse(Some("10"), Some("10")).is_err());
+    }
+
     #[test]
     fn test_parse_connected_event() {
         let event = parse_sse_event("connected", r#"{"slug":"test","endpointId":"ep-1"}"#);
@@ -169,4 +289,25 @@ mod tests {
         let event = parse_sse_event("custom_event", "some random data");
         assert!(event.is_none());
     }
+
+    #[test]
+    fn test_parse_endpoint_events() {
+        let data = r#"{"version":4,"source":"rollback","rolledBackTo":2,"createdAt":1700000000000,"changes":[{"field":"mockResponse","change":"edited"},{"field":"notificationUrl","change":"added"}]}"#;
+        match parse_endpoint_event("config_changed", data) {
+            Some(EndpointEvent::ConfigChanged(change)) => {
+                assert_eq!(change.version, 4);
+                assert_eq!(change.rolled_back_to, Some(2));
+                assert_eq!(change.changes.len(), 2);
+                assert_eq!(change.changes[1].field, "notificationUrl");
+                assert_eq!(change.changes[1].change, "added");
+            }
+            other => panic!("expected ConfigChanged, got {other:?}"),
+        }
+
+        let expired = parse_endpoint_event("endpoint_expired", r#"{"slug":"a","expiresAt":5}"#);
+        assert!(matches!(expired, Some(EndpointEvent::Expired { expires_at: 5 })));
+        assert!(matches!(parse_endpoint_event("endpoint_deleted", ""), Some(EndpointEvent::Deleted)));
+        assert!(parse_endpoint_event("config_changed", "not json").is_none());
+        assert!(parse_endpoint_event("request", "{}").is_none());
+    }
 }
@@ -0,0 +1,98 @@
+use anyhow::{Context, Result};
+
+use super::ApiClient;
+use crate::types::{ShareEndpointRequest, Team, TeamMemberList};
+
+impl ApiClient {
+    pub async fn list_teams(&self) -> Result<Vec<Team>> {
+        self.require_auth()?;
+        let resp = self.get("/api/teams").await?;
+        serde_json::from_str(&resp.body).context("failed to parse team list")
+    }
+
+    pub async fn list_team_members(&self, team_id: &str) -> Result<TeamMemberList> {
+        self.require_auth()?;
+        let resp = self
+            .get(&format!("/api/teams/{}/members", urlencoding::encode(team_id)))
+            .await?;
+        serde_json::from_str(&resp.body).context("failed to parse team members")
+    }
+
+    pub async fn share_endpoint_with_team(&self, team_id: &str, endpoint_id: &str) -> Result<()> {
+        self.require_auth()?;
+        let req = ShareEndpointRequest {
+            endpoint_id: endpoint_id.to_string(),
+        };
+        self.post(&format!("/api/teams/{}/endpoints", urlencoding::encode(team_id)), &req)
+            .await?;
+        Ok(())
+    }
+
+    pub async fn unshare_endpoint_from_team(&self, team_id: &str, endpoint_id: &str) -> Result<()> {
+        self.require_auth()?;
+        self.delete(&format!(
+            "/api/teams/{}/endpoints/{}",
+            urlencoding::encode(team_id),
+            urlencoding::encode(endpoint_id)
+        ))
+        .await?;
+        Ok(())
+    }
+
+    /// Resolve a team by ID or name among the teams the current user belongs to.
+    pub async fn resolve_team(&self, team: &str) -> Result<Team> {
+        let teams = self.list_teams().await?;
+        find_team(&teams, team).cloned()
+    }
+}
+
+/// Match a team by exact ID first, then by case-insensitive name.
+pub fn find_team<'a>(teams: &'a [Team], query: &str) -> Result<&'a Team> {
+    if let Some(team) = teams.iter().find(|t| t.id == query) {
+        return Ok(team);
+    }
+
+    let matches: Vec<_> = teams
+        .iter()
+        .filter(|t| t.name.eq_ignore_ascii_case(query))
+        .collect();
+    match matches.as_slice() {
+        [team] => Ok(team),
+        [] => anyhow::bail!(
+            "You are not a member of a team named \"{query}\". Run `whk teams list` to see your teams."
+        ),
+        _ => anyhow::bail!("Multiple teams are named \"{query}\". Use the team ID instead."),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn team(id: &str, name: &str) -> Team {
+        Team {
+            id: id.into(),
+            name: name.into(),
+            created_by: None,
+            created_at: None,
+            member_count: 1,
+            role: "member".into(),
+            suspended: false,
+        }
+    }
+
+    #[test]
+    fn test_find_team_by_id_or_name() {
+        let teams = vec![team("t1", "Acme"), team("t2", "Payments")];
+        assert_eq!(find_team(&teams, "t2").unwrap().name, "Payments");
+        assert_eq!(find_team(&teams, "acme").unwrap().id, "t1");
+        assert!(find_team(&teams, "nope").is_err());
+    }
+
+    #[test]
+    fn test_find_team_ambiguous_name() {
+        let teams = vec![team("t1", "Acme"), team("t2", "ACME")];
+        let err = find_team(&teams, "acme").unwrap_err().to_string();
+        assert!(err.contains("team ID"), "{err}");
+    }
+}
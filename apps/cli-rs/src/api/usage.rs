@@ -1,7 +1,7 @@
 use anyhow::{Context, Result};
 
 use super::ApiClient;
-use crate::types::UsageInfo;
+use crate::types::{ApiUsage, UsageInfo};
 
 impl ApiClient {
     pub async fn get_usage(&self) -> Result<UsageInfo> {
@@ -9,4 +9,10 @@ impl ApiClient {
         let resp = self.get("/api/usage").await?;
         serde_json::from_str(&resp.body).context("failed to parse usage info")
     }
+
+    pub async fn get_api_usage(&self) -> Result<ApiUsage> {
+        self.require_auth()?;
+        let resp = self.get("/api/usage/api").await?;
+        serde_json::from_str(&resp.body).context("failed to parse API usage")
+    }
 }
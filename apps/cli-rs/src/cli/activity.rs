@@ -0,0 +1,208 @@
+use anyhow::Result;
+use std::collections::HashSet;
+use std::time::Duration;
+
+use crate::api::ApiClient;
+use crate::cli::output::{bold, dim, green, red, sanitize, yellow};
+use crate::types::ActivityEvent;
+use crate::util::format::format_timestamp;
+
+const FOLLOW_POLL_INTERVAL: Duration = Duration::from_secs(10);
+
+/// List recent account events, oldest first, and with `follow` keep polling
+/// for new ones until interrupted.
+pub async fn run(
+    client: &ApiClient,
+    follow: bool,
+    limit: u32,
+    event_type: Option<&str>,
+    json: bool,
+) -> Result<()> {
+    let mut events = client.list_activity(Some(limit), None, event_type).await?;
+    events.reverse();
+
+    if !follow {
+        if json {
+            println!("{}", serde_json::to_string_pretty(&events)?);
+        } else if events.is_empty() {
+            println!("  No activity in the last 30 days.");
+        } else {
+            for event in &events {
+                println!("  {}", describe(event));
+            }
+        }
+        return Ok(());
+    }
+
+    if !json {
+        println!("\n  {} Following account activity", green("●"));
+        println!("  {}\n", dim("Press Ctrl+C to stop."));
+    }
+    let mut cursor = Cursor::default();
+    for event in cursor.take_new(events) {
+        print_event(&event, json);
+    }
+
+    loop {
+        tokio::select! {
+            _ = tokio::time::sleep(FOLLOW_POLL_INTERVAL) => {
+                let mut events = client.list_activity(None, cursor.since, event_type).await?;
+                events.reverse();
+                for event in cursor.take_new(events) {
+                    print_event(&event, json);
+                }
+            }
+            _ = tokio::signal::ctrl_c() => break,
+        }
+    }
+
+    Ok(())
+}
+
+fn print_event(event: &ActivityEvent, json: bool) {
+    if json {
+        println!("{}", serde_json::to_string(event).unwrap_or_default());
+    } else {
+        println!("  {}", describe(event));
+    }
+}
+
+/// One line per event: time, kind and what happened.
+fn describe(event: &ActivityEvent) -> String {
+    let text = |key: &str| {
+        event
+            .data
+            .get(key)
+            .and_then(|v| v.as_str())
+            .map(sanitize)
+            .unwrap_or_default()
+    };
+    let number = |key: &str| event.data.get(key).and_then(|v| v.as_i64()).unwrap_or_default();
+
+    let (kind, message) = match event.event_type.as_str() {
+        "endpoint_created" => {
+            let name = text("name");
+            let message = if name.is_empty() {
+                format!("Created {}", bold(&text("slug")))
+            } else {
+                format!("Created {} ({name})", bold(&text("slug")))
+            };
+            (green("endpoint  "), message)
+        }
+        "quota_warning" => (
+            yellow("quota     "),
+            format!(
+                "Used {} of {} requests this period ({}%)",
+                number("used"),
+                number("limit"),
+                number("percent")
+            ),
+        ),
+        "forwarding_failure" => (
+            red("forwarding"),
+            format!(
+                "{}: function sink gave up after {} attempts: {}",
+                bold(&text("slug")),
+                number("attempts"),
+                text("error")
+            ),
+        ),
+        "team_member_joined" => (
+            green("team      "),
+            format!("{} joined {}", text("email"), bold(&text("teamName"))),
+        ),
+        other => (dim(&format!("{:<10}", sanitize(other))), String::new()),
+    };
+    format!("{}  {}  {}", dim(&format_timestamp(event.created_at)), kind, message)
+}
+
+/// Tracks the newest timestamp seen. The API's `since` filter is inclusive,
+/// so events at exactly that millisecond are deduplicated by ID.
+#[derive(Default)]
+struct Cursor {
+    since: Option<i64>,
+    seen_at_since: HashSet<String>,
+}
+
+impl Cursor {
+    /// Filter already-printed events out of an oldest-first batch.
+    fn take_new(&mut self, events: Vec<ActivityEvent>) -> Vec<ActivityEvent> {
+        let mut fresh = Vec::new();
+        for event in events {
+            match self.since {
+                Some(since) if event.created_at < since => continue,
+                Some(since) if event.created_at == since => {
+                    if !self.seen_at_since.insert(event.id.clone()) {
+                        continue;
+                    }
+                }
+                _ => {
+                    self.since = Some(event.created_at);
+                    self.seen_at_since.clear();
+                    self.seen_at_since.insert(event.id.clone());
+                }
+            }
+            fresh.push(event);
+        }
+        fresh
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn event(id: &str, event_type: &str, data: serde_json::Value, created_at: i64) -> ActivityEvent {
+        ActivityEvent {
+            id: id.into(),
+            event_type: event_type.into(),
+            endpoint_id: None,
+            team_id: None,
+            data,
+            created_at,
+        }
+    }
+
+    #[test]
+    fn test_describe_events() {
+        let failure = event(
+            "1",
+            "forwarding_failure",
+            serde_json::json!({"slug": "stripe", "attempts": 3, "error": "timeout\x1b[2J"}),
+            0,
+        );
+        let line = describe(&failure);
+        assert!(line.contains("gave up after 3 attempts: timeout[2J"), "{line}");
+
+        let joined = event(
+            "2",
+            "team_member_joined",
+            serde_json::json!({"email": "ana@example.com", "teamName": "ops"}),
+            0,
+        );
+        assert!(describe(&joined).contains("ana@example.com joined"));
+
+        let quota = event("3", "quota_warning", serde_json::json!({"used": 80, "limit": 100, "percent": 80}), 0);
+        assert!(describe(&quota).contains("Used 80 of 100 requests this period (80%)"));
+    }
+
+    #[test]
+    fn test_cursor_skips_seen_events() {
+        let mut cursor = Cursor::default();
+        let first = cursor.take_new(vec![
+            event("a", "endpoint_created", serde_json::Value::Null, 100),
+            event("b", "endpoint_created", serde_json::Value::Null, 200),
+        ]);
+        assert_eq!(first.len(), 2);
+        assert_eq!(cursor.since, Some(200));
+
+        // The inclusive `since` returns "b" again alongside a new event at the same millisecond.
+        let next = cursor.take_new(vec![
+            event("b", "endpoint_created", serde_json::Value::Null, 200),
+            event("c", "endpoint_created", serde_json::Value::Null, 200),
+            event("d", "endpoint_created", serde_json::Value::Null, 300),
+        ]);
+        let ids: Vec<&str> = next.iter().map(|e| e.id.as_str()).collect();
+        assert_eq!(ids, ["c", "d"]);
+    }
+}
@@ -0,0 +1,121 @@
+use anyhow::{bail, Result};
+
+use crate::api::ApiClient;
+use crate::cli::output::{bold, dim, green, sanitize, tag_list};
+use crate::types::AnnotateRequest;
+use crate::util::cache::CaptureCache;
+
+/// Most tags a request can carry (enforced by the API as well).
+const MAX_TAGS: usize = 10;
+
+/// Lowercase a tag and check it is 1-32 characters of a-z, 0-9, '_' and '-'.
+pub fn normalize_tag(tag: &str) -> Result<String> {
+    let tag = tag.trim().to_lowercase();
+    let valid = (1..=32).contains(&tag.len())
+        && tag.chars().all(|c| c.is_ascii_lowercase() || c.is_ascii_digit() || c == '_' || c == '-');
+    if !valid {
+        bail!("invalid tag {tag:?}: tags are 1-32 characters of a-z, 0-9, '_' and '-'");
+    }
+    Ok(tag)
+}
+
+/// Apply additions and removals to `current`, keeping its order.
+fn merge_tags(current: &[String], add: &[String], remove: &[String]) -> Result<Vec<String>> {
+    let mut tags: Vec<String> = current.iter().filter(|t| !remove.contains(t)).cloned().collect();
+    for tag in add {
+        if !tags.contains(tag) {
+            tags.push(tag.clone());
+        }
+    }
+    if tags.len() > MAX_TAGS {
+        bail!("a request can have at most {MAX_TAGS} tags");
+    }
+    Ok(tags)
+}
+
+/// Set the note and add or remove tags on a captured request. With no
+/// changes, shows the current annotation.
+pub async fn run(
+    client: &ApiClient,
+    id: &str,
+    message: Option<String>,
+    clear_note: bool,
+    tags: Vec<String>,
+    untags: Vec<String>,
+    json: bool,
+) -> Result<()> {
+    if message.is_some() && clear_note {
+        bail!("--message and --clear-note can't be used together");
+    }
+    let add = tags.iter().map(|t| normalize_tag(t)).collect::<Result<Vec<_>>>()?;
+    let remove = untags.iter().map(|t| normalize_tag(t)).collect::<Result<Vec<_>>>()?;
+
+    let req = if message.is_none() && !clear_note && add.is_empty() && remove.is_empty() {
+        client.get_request(id).await?
+    } else {
+        let mut annotation = AnnotateRequest::default();
+        if clear_note {
+            annotation.note = Some(None);
+        } else if let Some(message) = message {
+            annotation.note = Some(Some(message));
+        }
+        if !add.is_empty() || !remove.is_empty() {
+            // Tags are replaced as a whole, so start from the current set.
+            let current = client.get_request(id).await?;
+            annotation.tags = Some(merge_tags(&current.tags, &add, &remove)?);
+        }
+        let updated = client.annotate_request(id, &annotation).await?;
+        if !client.reveals()
+            && let Ok(store) = CaptureCache::new(&client.url(""))
+        {
+            let _ = store.remember(updated.clone());
+        }
+        if !json {
+            println!("  {} Annotated {}", green("✓"), bold(&sanitize(&updated.id)));
+        }
+        updated
+    };
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&req)?);
+        return Ok(());
+    }
+    match req.note {
+        Some(ref note) => println!("  {} {}", dim("Note:"), sanitize(note)),
+        None => println!("  {} {}", dim("Note:"), dim("none")),
+    }
+    if req.tags.is_empty() {
+        println!("  {} {}", dim("Tags:"), dim("none"));
+    } else {
+        println!("  {} {}", dim("Tags:"), tag_list(&req.tags));
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn tags(names: &[&str]) -> Vec<String> {
+        names.iter().map(|t| t.to_string()).collect()
+    }
+
+    #[test]
+    fn test_normalize_tag() {
+        assert_eq!(normalize_tag(" Broke-Prod ").unwrap(), "broke-prod");
+        assert_eq!(normalize_tag("retry_2").unwrap(), "retry_2");
+        assert!(normalize_tag("").is_err());
+        assert!(normalize_tag("has space").is_err());
+        assert!(normalize_tag(&"a".repeat(33)).is_err());
+    }
+
+    #[test]
+    fn test_merge_tags_adds_removes_and_limits() {
+        let merged = merge_tags(&tags(&["a", "b"]), &tags(&["c", "a"]), &tags(&["b"])).unwrap();
+        assert_eq!(merged, tags(&["a", "c"]));
+
+        let full: Vec<String> = (0..MAX_TAGS).map(|i| format!("t{i}")).collect();
+        assert!(merge_tags(&full, &tags(&["extra"]), &[]).is_err());
+        assert!(merge_tags(&full, &tags(&["extra"]), &tags(&["t0"])).is_ok());
+    }
+}
@@ -0,0 +1,733 @@
+use anyhow::{Context, Result};
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+use std::io::{self, Read, Write};
+
+use crate::api::ApiClient;
+use crate::api::teams::find_team;
+use crate::cli::output::{bold, dim, green, red, sanitize, yellow};
+use crate::types::{
+    CreateEndpointRequest, Endpoint, FunctionSink, MockResponse, Team, UpdateEndpointRequest,
+};
+
+/// Declarative endpoint configuration read by `whk apply`. YAML or JSON.
+///
+/// ```yaml
+/// endpoints:
+///   - name: stripe-dev
+///     mockResponse:
+///       status: 200
+///       body: '{"received": true}'
+///     notificationUrl: https://hooks.slack.com/services/...
+///     functionSink:
+///       provider: aws_lambda
+///       arn: arn:aws:lambda:us-east-1:123456789012:function:stripe-dev
+///     bodyTransforms:
+///       - type: strip_fields
+///         paths: [livemode]
+///     teams: [Payments]
+/// ```
+///
+/// Endpoints are matched to existing ones by name. Fields left out of the file
+/// are cleared on the server, so the file is the complete description of each
+/// endpoint it lists.
+#[derive(Debug, Clone, Default, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct ApplyFile {
+    #[serde(default)]
+    pub endpoints: Vec<EndpointSpec>,
+}
+
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+#[serde(deny_unknown_fields, rename_all = "camelCase")]
+pub struct EndpointSpec {
+    pub name: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub mock_response: Option<MockResponse>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub notification_url: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub function_sink: Option<FunctionSink>,
+    /// Capture-time transforms, passed through to the API as-is.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub body_transforms: Option<Vec<serde_json::Value>>,
+    /// Expose quota and expiry headers on webhook responses.
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub info_headers: bool,
+    /// Teams (ID or name) the endpoint is shared with.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub teams: Vec<String>,
+}
+
+#[derive(Debug, Clone, Serialize)]
+#[serde(tag = "action", rename_all = "camelCase")]
+pub enum Change {
+    Create {
+        spec: EndpointSpec,
+        #[serde(skip)]
+        share: Vec<Team>,
+    },
+    Update {
+        slug: String,
+        id: String,
+        spec: EndpointSpec,
+        fields: Vec<&'static str>,
+        #[serde(skip)]
+        share: Vec<Team>,
+        #[serde(skip)]
+        unshare: Vec<String>,
+    },
+    Delete {
+        slug: String,
+        id: String,
+        name: Option<String>,
+    },
+}
+
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct Plan {
+    pub changes: Vec<Change>,
+    pub unchanged: usize,
+}
+
+impl Plan {
+    fn count(&self, pred: fn(&Change) -> bool) -> usize {
+        self.changes.iter().filter(|c| pred(c)).count()
+    }
+}
+
+/// Parse an apply file. YAML is a superset of JSON, so both are accepted.
+pub fn parse_apply_file(contents: &str) -> Result<ApplyFile> {
+    let mut file: ApplyFile = serde_yaml::from_str(contents).context("invalid apply file")?;
+
+    let mut seen = BTreeSet::new();
+    for spec in &mut file.endpoints {
+        // The API stores an empty pipeline as null.
+        if spec.body_transforms.as_ref().is_some_and(Vec::is_empty) {
+            spec.body_transforms = None;
+        }
+
+        let name = spec.name.trim();
+        if name.is_empty() {
+            anyhow::bail!("every endpoint in the apply file needs a name");
+        }
+        if !seen.insert(name.to_string()) {
+            anyhow::bail!("endpoint \"{name}\" is listed more than once in the apply file");
+        }
+        if let Some(ref mock) = spec.mock_response
+            && !(100..=599).contains(&mock.status)
+        {
+            anyhow::bail!("endpoint \"{name}\": mock status must be between 100 and 599");
+        }
+    }
+
+    Ok(file)
+}
+
+/// Diff the desired endpoints against the ones the user owns. Ephemeral
+/// endpoints are never matched or pruned; they come and go with tunnels.
+pub fn plan(file: &ApplyFile, owned: &[Endpoint], teams: &[Team], prune: bool) -> Result<Plan> {
+    let mut by_name: HashMap<&str, Vec<&Endpoint>> = HashMap::new();
+    for ep in owned.iter().filter(|ep| !ep.is_ephemeral) {
+        if let Some(ref name) = ep.name {
+            by_name.entry(name.as_str()).or_default().push(ep);
+        }
+    }
+
+    let mut out = Plan::default();
+    let mut matched = BTreeSet::new();
+
+    for spec in &file.endpoints {
+        let name = spec.name.trim();
+        let mut desired_teams = Vec::new();
+        for query in &spec.teams {
+            let team = find_team(teams, query)?;
+            if !desired_teams.iter().any(|t: &Team| t.id == team.id) {
+                desired_teams.push(team.clone());
+            }
+        }
+
+        let existing = match by_name.get(name).map(Vec::as_slice) {
+            None | Some([]) => {
+                out.changes.push(Change::Create {
+                    spec: spec.clone(),
+                    share: desired_teams,
+                });
+                continue;
+            }
+            Some([ep]) => *ep,
+            Some(_) => anyhow::bail!(
+                "multiple endpoints are named \"{name}\"; rename or delete the extras before applying"
+            ),
+        };
+        matched.insert(existing.id.clone());
+
+        let mut fields = Vec::new();
+        if existing.mock_response != spec.mock_response {
+            fields.push("mockResponse");
+        }
+        if existing.notification_url != spec.notification_url {
+            fields.push("notificationUrl");
+        }
+        if existing.function_sink != spec.function_sink {
+            fields.push("functionSink");
+        }
+        if existing.body_transforms != spec.body_transforms {
+            fields.push("bodyTransforms");
+        }
+        if existing.info_headers != spec.info_headers {
+            fields.push("infoHeaders");
+        }
+
+        let share: Vec<Team> = desired_teams
+            .iter()
+            .filter(|t| !existing.shared_with.iter().any(|s| s.team_id == t.id))
+            .cloned()
+            .collect();
+        let unshare: Vec<String> = existing
+            .shared_with
+            .iter()
+            .filter(|s| !desired_teams.iter().any(|t| t.id == s.team_id))
+            .map(|s| s.team_id.clone())
+            .collect();
+        if !share.is_empty() || !unshare.is_empty() {
+            fields.push("teams");
+        }
+
+        if fields.is_empty() {
+            out.unchanged += 1;
+        } else {
+            out.changes.push(Change::Update {
+                slug: existing.slug.clone(),
+                id: existing.id.clone(),
+                spec: spec.clone(),
+                fields,
+                share,
+                unshare,
+            });
+        }
+    }
+
+    if prune {
+        for ep in owned.iter().filter(|ep| !ep.is_ephemeral) {
+            if !matched.contains(&ep.id) {
+                out.changes.push(Change::Delete {
+                    slug: ep.slug.clone(),
+                    id: ep.id.clone(),
+                    name: ep.name.clone(),
+                });
+            }
+        }
+    }
+
+    Ok(out)
+}
+
+pub async fn run(
+    client: &ApiClient,
+    file: &str,
+    dry_run: bool,
+    prune: bool,
+    yes: bool,
+    json: bool,
+) -> Result<()> {
+    let contents = if file == "-" {
+        let mut buf = String::new();
+        io::stdin().read_to_string(&mut buf)?;
+        buf
+    } else {
+        std::fs::read_to_string(file).with_context(|| format!("failed to read {file}"))?
+    };
+    let desired = parse_apply_file(&contents)?;
+
+    if !dry_run && !yes && (json || file == "-") {
+        anyhow::bail!(
+            "pass --yes (apply without prompting) or --dry-run when using --json or reading stdin"
+        );
+    }
+
+    let owned = client.list_endpoints().await?.owned;
+    let needs_teams = desired.endpoints.iter().any(|s| !s.teams.is_empty())
+        || owned.iter().any(|ep| !ep.shared_with.is_empty());
+    let teams = if needs_teams {
+        client.list_teams().await?
+    } else {
+        Vec::new()
+    };
+
+    let plan = plan(&desired, &owned, &teams, prune)?;
+
+    if json && dry_run {
+        println!("{}", serde_json::to_string_pretty(&plan)?);
+        return Ok(());
+    }
+    if !json {
+        print_plan(&plan);
+    }
+
+    if plan.changes.is_empty() || dry_run {
+        return Ok(());
+    }
+
+    if !yes {
+        print!("  Apply these changes? [y/N] ");
+        io::stdout().flush()?;
+
+        let mut input = String::new();
+        io::stdin().read_line(&mut input)?;
+        if !input.trim().eq_ignore_ascii_case("y") {
+            println!("  Cancelled.");
+            return Ok(());
+        }
+    }
+
+    let mut applied = Vec::new();
+    for change in &plan.changes {
+        let slug = apply_change(client, change).await.with_context(|| {
+            format!(
+                "applied {} of {} changes",
+                applied.len(),
+                plan.changes.len()
+            )
+        })?;
+        if !json {
+            match change {
+                Change::Create { .. } => println!("  {} Created {}", green("✓"), bold(&slug)),
+                Change::Update { .. } => println!("  {} Updated {}", green("✓"), bold(&slug)),
+                Change::Delete { .. } => println!("  {} Deleted {}", red("✓"), bold(&slug)),
+            }
+        }
+        applied.push(slug);
+    }
+
+    if json {
+        println!(
+            "{}",
+            serde_json::to_string_pretty(&serde_json::json!({ "plan": plan, "applied": applied }))?
+        );
+    } else {
+        println!();
+    }
+
+    Ok(())
+}
+
+/// Apply a single change and return the slug of the endpoint it touched.
+async fn apply_change(client: &ApiClient, change: &Change) -> Result<String> {
+    match change {
+        Change::Create { spec, share } => {
+            let req = CreateEndpointRequest {
+                name: Some(spec.name.trim().to_string()),
+                slug: None,
+                is_ephemeral: None,
+                expires_at: None,
+                mock_response: spec.mock_response.clone(),
+                notification_url: spec.notification_url.clone(),
+                demo: None,
+                auto_extend: None,
+            };
+            let endpoint = client.create_endpoint(&req).await?;
+
+            // Function sinks, transforms and info headers can only be set by PATCH.
+            if spec.function_sink.is_some() || spec.body_transforms.is_some() || spec.info_headers {
+                let req = UpdateEndpointRequest {
+                    name: None,
+                    mock_response: None,
+                    notification_url: None,
+                    function_sink: spec
+                        .function_sink
+                        .as_ref()
+                        .map(serde_json::to_value)
+                        .transpose()?,
+                    body_transforms: spec
+                        .body_transforms
+                        .as_ref()
+                        .map(serde_json::to_value)
+                        .transpose()?,
+                    info_headers: spec.info_headers.then_some(true),
+                    record_responses: None,
+                    canonical_json: None,
+                    dry_run: None,
+                    query_overrides: None,
+                    auto_handshake: None,
+                    forward_url: None,
+                    priority_rule: None,
+                    encrypted_headers: None,
+                    network_policy: None,
+                    client_ca: None,
+                    signature_verification: None,
+                    jwt_verification: None,
+                    capture_auth: None,
+                    cors: None,
+                    capture_filter: None,
+                    sampling: None,
+                    chaos: None,
+                    rate_limit: None,
+                    schema_validation: None,
+                    redaction: None,
+                    custom_domain: None,
+                    max_body_size: None,
+                };
+                client.update_endpoint(&endpoint.slug, &req).await?;
+            }
+            for team in share {
+                client
+                    .share_endpoint_with_team(&team.id, &endpoint.id)
+                    .await?;
+            }
+            Ok(endpoint.slug)
+        }
+        Change::Update {
+            slug,
+            id,
+            spec,
+            fields,
+            share,
+            unshare,
+        } => {
+            let req = UpdateEndpointRequest {
+                name: None,
+                mock_response: fields
+                    .contains(&"mockResponse")
+                    .then(|| serde_json::to_value(&spec.mock_response))
+                    .transpose()?,
+                notification_url: fields
+                    .contains(&"notificationUrl")
+                    .then(|| serde_json::to_value(&spec.notification_url))
+                    .transpose()?,
+                function_sink: fields
+                    .contains(&"functionSink")
+                    .then(|| serde_json::to_value(&spec.function_sink))
+                    .transpose()?,
+                body_transforms: fields
+                    .contains(&"bodyTransforms")
+                    .then(|| serde_json::to_value(&spec.body_transforms))
+                    .transpose()?,
+                info_headers: fields.contains(&"infoHeaders").then_some(spec.info_headers),
+                record_responses: None,
+                canonical_json: None,
+                dry_run: None,
+                query_overrides: None,
+                auto_handshake: None,
+                forward_url: None,
+                priority_rule: None,
+                encrypted_headers: None,
+                network_policy: None,
+                client_ca: None,
+                signature_verification: None,
+                jwt_verification: None,
+                capture_auth: None,
+                cors: None,
+                capture_filter: None,
+                sampling: None,
+                chaos: None,
+                rate_limit: None,
+                schema_validation: None,
+                redaction: None,
+                custom_domain: None,
+                max_body_size: None,
+            };
+            if req.mock_response.is_some()
+                || req.notification_url.is_some()
+                || req.function_sink.is_some()
+                || req.body_transforms.is_some()
+                || req.info_headers.is_some()
+            {
+                client.update_endpoint(slug, &req).await?;
+            }
+            for team in share {
+                client.share_endpoint_with_team(&team.id, id).await?;
+            }
+            for team_id in unshare {
+                client.unshare_endpoint_from_team(team_id, id).await?;
+            }
+            Ok(slug.clone())
+        }
+        Change::Delete { slug, .. } => {
+            client.delete_endpoint(slug).await?;
+            Ok(slug.clone())
+        }
+    }
+}
+
+fn print_plan(plan: &Plan) {
+    let creates = plan.count(|c| matches!(c, Change::Create { .. }));
+    let updates = plan.count(|c| matches!(c, Change::Update { .. }));
+    let deletes = plan.count(|c| matches!(c, Change::Delete { .. }));
+
+    println!();
+    if plan.changes.is_empty() {
+        println!(
+            "  {} No changes. {} endpoint(s) already match.",
+            green("✓"),
+            plan.unchanged
+        );
+        println!();
+        return;
+    }
+
+    for change in &plan.changes {
+        match change {
+            Change::Create { spec, share } => {
+                println!("  {} {}", green("+"), bold(&sanitize(&spec.name)));
+                for team in share {
+                    println!("      {} {}", dim("share with"), sanitize(&team.name));
+                }
+            }
+            Change::Update {
+                slug, spec, fields, ..
+            } => {
+                println!(
+                    "  {} {} {}  {}",
+                    yellow("~"),
+                    bold(&sanitize(&spec.name)),
+                    dim(&format!("({})", sanitize(slug))),
+                    fields.join(", ")
+                );
+            }
+            Change::Delete { slug, name, .. } => {
+                println!(
+                    "  {} {} {}",
+                    red("-"),
+                    bold(&sanitize(name.as_deref().unwrap_or("-"))),
+                    dim(&format!("({})", sanitize(slug)))
+                );
+            }
+        }
+    }
+
+    println!();
+    println!(
+        "  Plan: {creates} to create, {updates} to update, {deletes} to delete ({} unchanged)",
+        plan.unchanged
+    );
+    println!();
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::types::TeamShare;
+
+    fn endpoint(id: &str, name: &str) -> Endpoint {
+        Endpoint {
+            id: id.into(),
+            slug: format!("slug-{id}"),
+            name: Some(name.into()),
+            url: None,
+            is_ephemeral: false,
+            expires_at: None,
+            created_at: None,
+            request_count: None,
+            mock_response: None,
+            notification_url: None,
+            function_sink: None,
+            body_transforms: None,
+            info_headers: false,
+            record_responses: false,
+            canonical_json: false,
+            dry_run: false,
+            query_overrides: false,
+            auto_handshake: false,
+            forward_url: None,
+            priority_rule: None,
+            encrypted_headers: vec![],
+            network_policy: None,
+            client_ca: None,
+            signature_verification: None,
+            jwt_verification: None,
+            capture_auth: None,
+            cors: None,
+            capture_filter: None,
+            sampling: None,
+            chaos: None,
+            rate_limit: None,
+            schema_validation: None,
+            redaction: None,
+            mock_canary: None,
+            custom_domain: None,
+            max_body_size: None,
+            demo: None,
+            auto_extend: None,
+            paused_at: None,
+            paused_response: None,
+            shared_with: vec![],
+            from_team: None,
+        }
+    }
+
+    fn spec(name: &str) -> EndpointSpec {
+        EndpointSpec {
+            name: name.into(),
+            ..Default::default()
+        }
+    }
+
+    fn team(id: &str, name: &str) -> Team {
+        Team {
+            id: id.into(),
+            name: name.into(),
+            created_by: None,
+            created_at: None,
+            member_count: 1,
+            role: "owner".into(),
+            suspended: false,
+        }
+    }
+
+    #[test]
+    fn test_parse_apply_file_yaml() {
+        let file = parse_apply_file(
+            r#"
+endpoints:
+  - name: stripe-dev
+    mockResponse:
+      status: 201
+      body: ok
+    notificationUrl: https://example.com/notify
+  - name: github
+"#,
+        )
+        .unwrap();
+        assert_eq!(file.endpoints.len(), 2);
+        assert_eq!(
+            file.endpoints[0].mock_response.as_ref().unwrap().status,
+            201
+        );
+        assert!(file.endpoints[1].mock_response.is_none());
+    }
+
+    #[test]
+    fn test_parse_apply_file_rejects_duplicates_and_unknown_fields() {
+        let err = parse_apply_file(r#"{"endpoints": [{"name": "a"}, {"name": "a"}]}"#)
+            .unwrap_err()
+            .to_string();
+        assert!(err.contains("more than once"), "{err}");
+
+        assert!(parse_apply_file(r#"{"endpoints": [{"name": "a", "slug": "x"}]}"#).is_err());
+        assert!(parse_apply_file(r#"{"endpoints": [{"name": " "}]}"#).is_err());
+    }
+
+    #[test]
+    fn test_plan_create_update_unchanged() {
+        let mut existing = endpoint("1", "github");
+        existing.notification_url = Some("https://example.com/a".into());
+        let owned = vec![existing, endpoint("2", "same")];
+
+        let mut github = spec("github");
+        github.notification_url = Some("https://example.com/b".into());
+        let file = ApplyFile {
+            endpoints: vec![github, spec("same"), spec("new")],
+        };
+
+        let plan = plan(&file, &owned, &[], false).unwrap();
+        assert_eq!(plan.unchanged, 1);
+        assert_eq!(plan.changes.len(), 2);
+        match &plan.changes[0] {
+            Change::Update { slug, fields, .. } => {
+                assert_eq!(slug, "slug-1");
+                assert_eq!(fields, &vec!["notificationUrl"]);
+            }
+            other => panic!("expected update, got {other:?}"),
+        }
+        assert!(matches!(&plan.changes[1], Change::Create { spec, .. } if spec.name == "new"));
+    }
+
+    #[test]
+    fn test_plan_omitted_fields_are_cleared() {
+        let mut existing = endpoint("1", "stripe");
+        existing.mock_response = Some(MockResponse {
+            status: 200,
+            body: String::new(),
+            body_base64: None,
+            headers: HashMap::new(),
+            delay: None,
+            delay_jitter: None,
+            header_policy: None,
+            by_method: HashMap::new(),
+            correlation: None,
+            variants: Vec::new(),
+            schedule: Vec::new(),
+            rules: Vec::new(),
+            sequence: None,
+            script: None,
+            echo: false,
+            stream: None,
+            representations: BTreeMap::new(),
+            grpc: None,
+        });
+        let file = ApplyFile {
+            endpoints: vec![spec("stripe")],
+        };
+
+        let plan = plan(&file, &[existing], &[], false).unwrap();
+        assert!(
+            matches!(&plan.changes[0], Change::Update { fields, .. } if fields == &vec!["mockResponse"])
+        );
+    }
+
+    #[test]
+    fn test_plan_info_headers_toggle() {
+        let mut on = spec("stripe");
+        on.info_headers = true;
+        let file = ApplyFile { endpoints: vec![on] };
+
+        let plan = plan(&file, &[endpoint("1", "stripe")], &[], false).unwrap();
+        assert!(
+            matches!(&plan.changes[0], Change::Update { fields, .. } if fields == &vec!["infoHeaders"])
+        );
+    }
+
+    #[test]
+    fn test_plan_prune_skips_ephemeral() {
+        let mut ephemeral = endpoint("2", "tunnel");
+        ephemeral.is_ephemeral = true;
+        let owned = vec![endpoint("1", "stale"), ephemeral];
+        let file = ApplyFile::default();
+
+        assert!(plan(&file, &owned, &[], false).unwrap().changes.is_empty());
+
+        let pruned = plan(&file, &owned, &[], true).unwrap();
+        assert_eq!(pruned.changes.len(), 1);
+        assert!(matches!(&pruned.changes[0], Change::Delete { id, .. } if id == "1"));
+    }
+
+    #[test]
+    fn test_plan_ambiguous_existing_name() {
+        let owned = vec![endpoint("1", "dup"), endpoint("2", "dup")];
+        let file = ApplyFile {
+            endpoints: vec![spec("dup")],
+        };
+        assert!(plan(&file, &owned, &[], false).is_err());
+    }
+
+    #[test]
+    fn test_plan_team_shares() {
+        let teams = vec![team("t1", "Payments"), team("t2", "Platform")];
+        let mut existing = endpoint("1", "stripe");
+        existing.shared_with = vec![TeamShare {
+            team_id: "t2".into(),
+            team_name: "Platform".into(),
+        }];
+        let mut desired = spec("stripe");
+        desired.teams = vec!["payments".into()];
+        let file = ApplyFile {
+            endpoints: vec![desired],
+        };
+
+        let plan = plan(&file, &[existing], &teams, false).unwrap();
+        match &plan.changes[0] {
+            Change::Update {
+                fields,
+                share,
+                unshare,
+                ..
+            } => {
+                assert_eq!(fields, &vec!["teams"]);
+                assert_eq!(share[0].id, "t1");
+                assert_eq!(unshare, &vec!["t2".to_string()]);
+            }
+            other => panic!("expected update, got {other:?}"),
+        }
+    }
+}
@@ -114,6 +114,8 @@ pub async fn status(json: bool) -> Result<()> {
 
 pub async fn logout(json: bool) -> Result<()> {
     auth::clear_token()?;
+    // Cached captures belong to the account that fetched them.
+    crate::util::cache::purge()?;
     if json {
         println!("{}", serde_json::json!({ "status": "logged_out" }));
     } else {
@@ -0,0 +1,271 @@
+use anyhow::{Result, bail};
+use futures::StreamExt;
+use serde::Serialize;
+use std::collections::HashMap;
+use std::time::{Duration, Instant};
+use tokio::sync::mpsc;
+
+use crate::api::ApiClient;
+use crate::cli::latency::{ms, percentiles};
+use crate::cli::output::{bold, dim, green, red, yellow};
+use crate::types::{LatencyPercentiles, SseEvent};
+
+/// Header carrying `{run}:{seq}` on every benchmark request, so deliveries can
+/// be matched to sends without relying on the body surviving transforms.
+const BENCH_HEADER: &str = "x-whk-bench";
+
+/// How long to wait for the stream to connect before sending anything.
+const CONNECT_TIMEOUT: Duration = Duration::from_secs(15);
+
+/// One benchmark request as sent.
+struct Sent {
+    seq: usize,
+    at: Instant,
+    /// Until the receiver answered
+    send_ms: f64,
+    ok: bool,
+}
+
+/// One benchmark request as delivered on the stream.
+struct Arrival {
+    at: Instant,
+    wall_ms: i64,
+    /// Receiver clock at capture, unix milliseconds
+    received_at: i64,
+}
+
+#[derive(Debug, Serialize)]
+struct Distribution {
+    #[serde(flatten)]
+    percentiles: LatencyPercentiles,
+    max: f64,
+}
+
+#[derive(Debug, Serialize)]
+struct StreamBenchReport {
+    sent: usize,
+    failed: usize,
+    delivered: usize,
+    lost: usize,
+    /// Send until the receiver answered
+    #[serde(rename = "sendMs", skip_serializing_if = "Option::is_none")]
+    send_ms: Option<Distribution>,
+    /// Send until the request arrived on the stream (local clock only)
+    #[serde(rename = "endToEndMs", skip_serializing_if = "Option::is_none")]
+    end_to_end_ms: Option<Distribution>,
+    /// Capture until stream delivery (receiver clock vs local clock)
+    #[serde(rename = "captureToStreamMs", skip_serializing_if = "Option::is_none")]
+    capture_to_stream_ms: Option<Distribution>,
+}
+
+/// Send a burst of marked requests to an endpoint while listening on its
+/// stream, and report how long each took to come back.
+pub async fn stream(
+    client: &ApiClient,
+    slug: &str,
+    count: usize,
+    concurrency: usize,
+    timeout: u64,
+    json: bool,
+) -> Result<()> {
+    let run = format!("{:08x}", rand::random::<u32>());
+    let (tx, mut rx) = mpsc::channel(256);
+    let stream_client = client.clone();
+    let stream_slug = slug.to_string();
+    let stream_handle = tokio::spawn(async move { stream_client.stream_requests(&stream_slug, tx).await });
+
+    // Send only once the stream is up, so the first requests aren't measured
+    // from before anyone was listening.
+    let connected = tokio::time::timeout(CONNECT_TIMEOUT, async {
+        while let Some(event) = rx.recv().await {
+            if matches!(event, SseEvent::Connected) {
+                return true;
+            }
+        }
+        false
+    })
+    .await
+    .unwrap_or(false);
+    if !connected {
+        return match stream_handle.await {
+            Ok(Err(e)) => Err(e),
+            _ => {
+                bail!("stream did not connect within {}s", CONNECT_TIMEOUT.as_secs())
+            }
+        };
+    }
+
+    if !json {
+        println!(
+            "  {} Sending {} requests to {} ({} at a time)",
+            green("●"),
+            count,
+            bold(slug),
+            concurrency
+        );
+    }
+    let url = client.webhook_url_for(slug);
+    let sender = tokio::spawn(send_burst(client.clone(), url, run.clone(), count, concurrency));
+
+    let mut arrivals: HashMap<usize, Arrival> = HashMap::new();
+    let deadline = tokio::time::sleep(Duration::from_secs(timeout));
+    tokio::pin!(deadline);
+    while arrivals.len() < count {
+        tokio::select! {
+            event = rx.recv() => match event {
+                Some(SseEvent::Request(req)) => {
+                    let seq = req.headers.get(BENCH_HEADER).and_then(|v| bench_seq(v, &run));
+                    if let Some(seq) = seq.filter(|seq| *seq < count) {
+                        arrivals.entry(seq).or_insert_with(|| Arrival {
+                            at: Instant::now(),
+                            wall_ms: chrono::Utc::now().timestamp_millis(),
+                            received_at: req.received_at,
+                        });
+                    }
+                }
+                Some(SseEvent::EndpointDeleted) => bail!("endpoint was deleted during the benchmark"),
+                Some(SseEvent::Timeout) => bail!("stream timed out during the benchmark"),
+                Some(SseEvent::Connected) => {}
+                None => break,
+            },
+            _ = &mut deadline => break,
+            _ = tokio::signal::ctrl_c() => break,
+        }
+    }
+    stream_handle.abort();
+    let sent = sender.await?;
+
+    let report = summarize(&sent, &arrivals);
+    if json {
+        println!("{}", serde_json::to_string_pretty(&report)?);
+    } else {
+        print_report(&report, timeout);
+    }
+    Ok(())
+}
+
+async fn send_burst(client: ApiClient, url: String, run: String, count: usize, concurrency: usize) -> Vec<Sent> {
+    futures::stream::iter(0..count)
+        .map(|seq| {
+            let (client, url, run) = (&client, &url, &run);
+            let headers = HashMap::from([
+                (BENCH_HEADER.to_string(), format!("{run}:{seq}")),
+                ("content-type".to_string(), "application/json".to_string()),
+            ]);
+            async move {
+                let wall_ms = chrono::Utc::now().timestamp_millis();
+                let body = serde_json::json!({ "bench": run, "seq": seq, "sentAt": wall_ms }).to_string();
+                let at = Instant::now();
+                let result = client.send_to(url, "POST", &headers, Some(&body)).await;
+                Sent {
+                    seq,
+                    at,
+                    send_ms: at.elapsed().as_secs_f64() * 1000.0,
+                    ok: result.is_ok_and(|r| (200..300).contains(&r.status)),
+                }
+            }
+        })
+        .buffer_unordered(concurrency.max(1))
+        .collect()
+        .await
+}
+
+/// The sequence number in a `{run}:{seq}` bench header from this run.
+fn bench_seq(value: &str, run: &str) -> Option<usize> {
+    let (id, seq) = value.split_once(':')?;
+    if id != run {
+        return None;
+    }
+    seq.parse().ok()
+}
+
+fn summarize(sent: &[Sent], arrivals: &HashMap<usize, Arrival>) -> StreamBenchReport {
+    let mut send_ms = Vec::new();
+    let mut end_to_end = Vec::new();
+    let mut capture_to_stream = Vec::new();
+    let mut failed = 0;
+    for s in sent {
+        send_ms.push(s.send_ms);
+        if !s.ok {
+            failed += 1;
+        }
+        if let Some(arrival) = arrivals.get(&s.seq) {
+            end_to_end.push(arrival.at.saturating_duration_since(s.at).as_secs_f64() * 1000.0);
+            capture_to_stream.push((arrival.wall_ms - arrival.received_at) as f64);
+        }
+    }
+    StreamBenchReport {
+        sent: sent.len(),
+        failed,
+        delivered: end_to_end.len(),
+        lost: (sent.len() - failed).saturating_sub(end_to_end.len()),
+        send_ms: distribution(send_ms),
+        end_to_end_ms: distribution(end_to_end),
+        capture_to_stream_ms: distribution(capture_to_stream),
+    }
+}
+
+/// Nearest-rank percentiles and the maximum, or `None` without samples.
+fn distribution(mut samples: Vec<f64>) -> Option<Distribution> {
+    if samples.is_empty() {
+        return None;
+    }
+    samples.sort_by(f64::total_cmp);
+    let rank = |p: f64| samples[((p * samples.len() as f64).ceil() as usize).clamp(1, samples.len()) - 1];
+    Some(Distribution {
+        percentiles: LatencyPercentiles {
+            p50: rank(0.50),
+            p90: rank(0.90),
+            p99: rank(0.99),
+        },
+        max: samples[samples.len() - 1],
+    })
+}
+
+fn print_report(report: &StreamBenchReport, timeout: u64) {
+    let mut counts = format!("{} sent, {} delivered", report.sent, report.delivered);
+    if report.failed > 0 {
+        counts.push_str(&format!(", {}", red(&format!("{} rejected", report.failed))));
+    }
+    if report.lost > 0 {
+        counts.push_str(&format!(", {}", yellow(&format!("{} not seen within {timeout}s", report.lost))));
+    }
+    println!("\n  {}", counts);
+    println!("  {}", dim("p50 / p90 / p99 / max in ms"));
+    let rows = [
+        ("Send:", &report.send_ms),
+        ("End to end:", &report.end_to_end_ms),
+        ("Capture→SSE:", &report.capture_to_stream_ms),
+    ];
+    for (label, dist) in rows {
+        let value = dist
+            .as_ref()
+            .map(|d| format!("{} / {}", percentiles(&d.percentiles), ms(d.max)))
+            .unwrap_or_else(|| "-".to_string());
+        println!("  {} {}", dim(&format!("{label:<13}")), value);
+    }
+    if report.capture_to_stream_ms.is_some() {
+        println!("  {}", dim("Capture→SSE compares the receiver's clock with this machine's."));
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_bench_seq_only_matches_this_run() {
+        assert_eq!(bench_seq("0badcafe:17", "0badcafe"), Some(17));
+        assert_eq!(bench_seq("deadbeef:17", "0badcafe"), None);
+        assert_eq!(bench_seq("0badcafe:x", "0badcafe"), None);
+    }
+
+    #[test]
+    fn test_distribution_nearest_rank() {
+        let d = distribution((1..=100).map(f64::from).rev().collect()).unwrap();
+        assert_eq!((d.percentiles.p50, d.percentiles.p90, d.percentiles.p99, d.max), (50.0, 90.0, 99.0, 100.0));
+        let one = distribution(vec![7.0]).unwrap();
+        assert_eq!((one.percentiles.p50, one.percentiles.p99), (7.0, 7.0));
+        assert!(distribution(vec![]).is_none());
+    }
+}
@@ -0,0 +1,272 @@
+//! Dynamic completion: endpoint slugs and recent request IDs fetched from the
+//! API. Shell hooks call the hidden `whk __complete <kind>` command, which
+//! reads a short-lived cache so pressing Tab doesn't hit the network each time.
+
+use anyhow::{bail, Result};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::path::PathBuf;
+
+use crate::api::ApiClient;
+use crate::auth;
+use crate::cli::picker::{self, PickItem};
+use crate::util::format::format_timestamp;
+
+/// How long cached candidates are served to shell completion.
+const CACHE_TTL_SECS: i64 = 60;
+
+/// Recent requests offered for request ID arguments.
+const RECENT_REQUESTS: u32 = 20;
+
+/// What to complete.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, clap::ValueEnum)]
+pub enum CompleteKind {
+    /// Endpoint slugs (owned and shared)
+    Slugs,
+    /// Recent request IDs
+    Requests,
+}
+
+impl CompleteKind {
+    fn name(self) -> &'static str {
+        match self {
+            CompleteKind::Slugs => "slugs",
+            CompleteKind::Requests => "requests",
+        }
+    }
+}
+
+#[derive(Debug, Default, Serialize, Deserialize)]
+struct Cache {
+    #[serde(default)]
+    entries: HashMap<String, CacheEntry>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+struct CacheEntry {
+    #[serde(rename = "fetchedAt")]
+    fetched_at: i64,
+    items: Vec<PickItem>,
+}
+
+fn cache_path() -> Result<PathBuf> {
+    Ok(auth::config_dir()?.join("completion-cache.json"))
+}
+
+/// Entries are scoped to the API and team so switching either never offers
+/// another context's slugs.
+fn cache_key(client: &ApiClient, kind: CompleteKind) -> String {
+    format!("{}|{}|{}", kind.name(), client.url(""), client.team().unwrap_or(""))
+}
+
+fn read_cache() -> Cache {
+    cache_path()
+        .ok()
+        .and_then(|path| std::fs::read_to_string(path).ok())
+        .and_then(|raw| serde_json::from_str(&raw).ok())
+        .unwrap_or_default()
+}
+
+fn write_cache(cache: &Cache) {
+    let Ok(path) = cache_path() else { return };
+    if let Some(dir) = path.parent() {
+        let _ = std::fs::create_dir_all(dir);
+    }
+    if let Ok(json) = serde_json::to_string(cache) {
+        let _ = std::fs::write(path, json);
+    }
+}
+
+/// Candidates for `kind`, served from the cache when it is younger than
+/// `max_age_secs` and fetched (and cached) otherwise.
+pub async fn candidates(client: &ApiClient, kind: CompleteKind, max_age_secs: i64) -> Result<Vec<PickItem>> {
+    let key = cache_key(client, kind);
+    let now = chrono::Utc::now().timestamp();
+    let mut cache = read_cache();
+    if let Some(entry) = cache.entries.get(&key)
+        && now - entry.fetched_at < max_age_secs
+    {
+        return Ok(entry.items.clone());
+    }
+
+    let items = fetch(client, kind).await?;
+    cache.entries.retain(|_, e| now - e.fetched_at < CACHE_TTL_SECS);
+    cache.entries.insert(key, CacheEntry { fetched_at: now, items: items.clone() });
+    write_cache(&cache);
+    Ok(items)
+}
+
+async fn fetch(client: &ApiClient, kind: CompleteKind) -> Result<Vec<PickItem>> {
+    match kind {
+        CompleteKind::Slugs => {
+            let list = client.list_endpoints().await?;
+            let owned = list.owned.into_iter().map(|ep| (ep, false));
+            let shared = list.shared.into_iter().map(|ep| (ep, true));
+            Ok(owned
+                .chain(shared)
+                .map(|(ep, shared)| {
+                    let mut label = ep.name.filter(|n| n != &ep.slug).unwrap_or_default();
+                    if shared {
+                        label = format!("{label} (shared)").trim_start().to_string();
+                    }
+                    PickItem { value: ep.slug, label }
+                })
+                .collect())
+        }
+        CompleteKind::Requests => {
+            let result = client
+                .search_requests(None, None, None, None, None, None, Some(RECENT_REQUESTS), None, Some("desc"))
+                .await?;
+            Ok(result
+                .requests
+                .into_iter()
+                .map(|req| PickItem {
+                    label: format!("{} {} {}", req.method, req.path, format_timestamp(req.received_at)),
+                    value: req.id,
+                })
+                .collect())
+        }
+    }
+}
+
+/// `whk __complete <kind>`: print one `value<TAB>description` line per
+/// candidate. Failures (offline, logged out) print nothing so the shell
+/// falls back quietly.
+pub async fn run(client: &ApiClient, kind: CompleteKind) {
+    if client.require_auth().is_err() {
+        return;
+    }
+    if let Ok(items) = candidates(client, kind, CACHE_TTL_SECS).await {
+        for item in items {
+            let label = item.label.replace(['\t', '\n', '\r'], " ");
+            println!("{}\t{}", item.value, label);
+        }
+    }
+}
+
+/// Fill in an omitted slug or request ID by showing the picker. Without a
+/// terminal (or with `--json`) the argument is required.
+pub async fn resolve(
+    client: &ApiClient,
+    value: Option<String>,
+    kind: CompleteKind,
+    json_output: bool,
+) -> Result<String> {
+    if let Some(value) = value {
+        return Ok(value);
+    }
+    let (arg, prompt) = match kind {
+        CompleteKind::Slugs => ("<SLUG>", "Endpoint:"),
+        CompleteKind::Requests => ("<ID>", "Request:"),
+    };
+    if json_output || !picker::is_interactive() {
+        bail!("missing {arg} argument");
+    }
+    // Always fetch fresh here: a stale list could offer a deleted endpoint.
+    let items = candidates(client, kind, 0).await?;
+    if items.is_empty() {
+        bail!("missing {arg} argument (no {} found)", kind.name());
+    }
+    picker::pick(prompt, &items)
+}
+
+/// Shell glue appended to the clap-generated script so slug and request ID
+/// positionals complete from `whk __complete`.
+pub fn shell_hook(shell: clap_complete::Shell) -> Option<&'static str> {
+    match shell {
+        clap_complete::Shell::Bash => Some(BASH_HOOK),
+        clap_complete::Shell::Zsh => Some(ZSH_HOOK),
+        clap_complete::Shell::Fish => Some(FISH_HOOK),
+        _ => None,
+    }
+}
+
+const BASH_HOOK: &str = r#"
+_whk_dynamic() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" kind=""
+    if [[ "$cur" != -* ]]; then
+        case "${COMP_WORDS[1]} ${COMP_WORDS[COMP_CWORD-1]}" in
+            "get get"|"update-endpoint update-endpoint"|"delete delete"|"listen listen"|"watch watch"|"send send"|\
+            "requests list"|"requests clear"|"requests export"|"mock import"|"mock history"|\
+            "share create"|"share list"|"share revoke"|*" --endpoint"|*" --slug")
+                kind=slugs ;;
+            "replay replay"|"annotate annotate"|"requests get")
+                kind=requests ;;
+        esac
+    fi
+    if [[ -n "$kind" ]]; then
+        local IFS=$'\n'
+        COMPREPLY=($(compgen -W "$(whk __complete "$kind" 2>/dev/null | cut -f1)" -- "$cur"))
+        return 0
+    fi
+    _whk "$@"
+}
+complete -F _whk_dynamic -o bashdefault -o default whk
+"#;
+
+const ZSH_HOOK: &str = r#"
+_whk_dynamic() {
+    local kind=""
+    if [[ ${words[CURRENT]} != -* ]]; then
+        case "${words[2]} ${words[CURRENT-1]}" in
+            "get get"|"update-endpoint update-endpoint"|"delete delete"|"listen listen"|"watch watch"|"send send"|\
+            "requests list"|"requests clear"|"requests export"|"mock import"|"mock history"|\
+            "share create"|"share list"|"share revoke"|*" --endpoint"|*" --slug")
+                kind=slugs ;;
+            "replay replay"|"annotate annotate"|"requests get")
+                kind=requests ;;
+        esac
+    fi
+    if [[ -n $kind ]]; then
+        local -a items
+        local line
+        for line in "${(@f)$(whk __complete $kind 2>/dev/null)}"; do
+            [[ -n $line ]] && items+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+        done
+        (( ${#items} )) && _describe -t $kind $kind items && return 0
+    fi
+    _whk "$@"
+}
+compdef _whk_dynamic whk
+"#;
+
+const FISH_HOOK: &str = r#"
+complete -c whk -n '__fish_seen_subcommand_from get update-endpoint delete listen watch send; and not __fish_seen_subcommand_from requests' -f -a '(whk __complete slugs 2>/dev/null)'
+complete -c whk -n '__fish_seen_subcommand_from requests; and __fish_seen_subcommand_from list clear export' -f -a '(whk __complete slugs 2>/dev/null)'
+complete -c whk -n '__fish_seen_subcommand_from share; and __fish_seen_subcommand_from create list revoke' -f -a '(whk __complete slugs 2>/dev/null)'
+complete -c whk -n '__fish_seen_subcommand_from mock; and __fish_seen_subcommand_from import history' -f -a '(whk __complete slugs 2>/dev/null)'
+complete -c whk -n '__fish_seen_subcommand_from tunnel' -l endpoint -f -a '(whk __complete slugs 2>/dev/null)'
+complete -c whk -n '__fish_seen_subcommand_from search count' -l slug -f -a '(whk __complete slugs 2>/dev/null)'
+complete -c whk -n '__fish_seen_subcommand_from replay annotate' -f -a '(whk __complete requests 2>/dev/null)'
+complete -c whk -n '__fish_seen_subcommand_from requests; and __fish_seen_subcommand_from get' -f -a '(whk __complete requests 2>/dev/null)'
+"#;
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_shell_hooks_call_complete() {
+        for shell in [clap_complete::Shell::Bash, clap_complete::Shell::Zsh, clap_complete::Shell::Fish] {
+            let hook = shell_hook(shell).unwrap();
+            assert!(hook.contains("whk __complete"), "{shell:?}");
+        }
+        assert!(shell_hook(clap_complete::Shell::PowerShell).is_none());
+    }
+
+    #[test]
+    fn test_cache_roundtrip() {
+        let mut cache = Cache::default();
+        cache.entries.insert(
+            "slugs|x|".into(),
+            CacheEntry {
+                fetched_at: 42,
+                items: vec![PickItem { value: "abc".into(), label: "Stripe".into() }],
+            },
+        );
+        let json = serde_json::to_string(&cache).unwrap();
+        let back: Cache = serde_json::from_str(&json).unwrap();
+        assert_eq!(back.entries["slugs|x|"].fetched_at, 42);
+        assert_eq!(back.entries["slugs|x|"].items[0].value, "abc");
+    }
+}
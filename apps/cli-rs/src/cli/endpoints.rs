@@ -1,24 +1,32 @@
 use anyhow::Result;
-use std::collections::HashMap;
+use std::collections::{BTreeMap, HashMap};
 use std::io::{self, Write};
 
 use crate::api::ApiClient;
-use crate::cli::output::{bold, dim, green, print_endpoint_table, red};
-use crate::types::{CreateEndpointRequest, MockResponse, UpdateEndpointRequest};
-use crate::util::format::parse_duration;
+use crate::cli::output::{bold, dim, green, print_endpoint_table, red, sanitize, yellow};
+use crate::types::{
+    AutoExtendRequest, CaptureFilterRule, CreateEndpointRequest, DemoConfig, MockResponse, MockRuleMatch, NetworkRule, NetworkTagRule, PausedResponse, TeamShare,
+    UpdateEndpointRequest,
+};
+use crate::util::cache::CaptureCache;
+use crate::util::format::{format_bytes, format_gap, format_timestamp, parse_duration};
 
 #[allow(clippy::too_many_arguments)]
 pub async fn create(
     client: &ApiClient,
     name: Option<String>,
+    slug: Option<String>,
     ephemeral: bool,
     expires_in: Option<String>,
     mock_status: Option<u16>,
     mock_body: Option<String>,
     mock_headers: Vec<String>,
+    mock_echo: bool,
+    demo: Option<DemoConfig>,
+    auto_extend: Option<AutoExtendRequest>,
     json: bool,
 ) -> Result<()> {
-    let mock_response = build_mock_response(mock_status, mock_body, mock_headers)?;
+    let mock_response = build_mock_response(mock_status, mock_body, mock_headers, mock_echo)?;
 
     let expires_at = match expires_in {
         Some(dur) => {
@@ -30,26 +38,81 @@ pub async fn create(
 
     let req = CreateEndpointRequest {
         name: name.clone(),
-        is_ephemeral: if ephemeral { Some(true) } else { None },
+        slug,
+        // Demo mode and auto-extension are only available on ephemeral endpoints.
+        is_ephemeral: if ephemeral || demo.is_some() || auto_extend.is_some() { Some(true) } else { None },
         expires_at,
         mock_response,
+        notification_url: None,
+        demo,
+        auto_extend,
     };
 
-    let endpoint = client.create_endpoint(&req).await?;
+    // Resolve the team before creating so a typo doesn't leave an unshared endpoint behind.
+    let team = match client.team() {
+        Some(query) => Some(client.resolve_team(query).await?),
+        None => None,
+    };
+
+    let mut endpoint = client.create_endpoint(&req).await?;
+
+    if let Some(ref team) = team {
+        client
+            .share_endpoint_with_team(&team.id, &endpoint.id)
+            .await
+            .map_err(|e| {
+                anyhow::anyhow!(
+                    "created endpoint {} but could not share it with team {}: {e}",
+                    endpoint.slug,
+                    team.name
+                )
+            })?;
+        endpoint.shared_with.push(TeamShare {
+            team_id: team.id.clone(),
+            team_name: team.name.clone(),
+        });
+    }
 
     if json {
         println!("{}", serde_json::to_string_pretty(&endpoint)?);
     } else {
         let url = client.webhook_url_for(&endpoint.slug);
         println!("\n  {} Created endpoint {}", green("✓"), bold(&endpoint.slug));
-        println!("  {} {}\n", dim("URL:"), url);
+        println!("  {} {}", dim("URL:"), url);
+        if let Some(ref team) = team {
+            println!("  {} {}", dim("Shared with:"), team.name);
+        }
+        println!();
+    }
+
+    Ok(())
+}
+
+/// Report whether a custom slug can be used, and why not if it can't.
+pub async fn check_slug(client: &ApiClient, slug: &str, json: bool) -> Result<()> {
+    let availability = client.check_slug(slug).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&availability)?);
+    } else if availability.available {
+        println!("\n  {} {} is available", green("✓"), bold(&availability.slug));
+        println!("  {} {}\n", dim("URL:"), client.webhook_url_for(&availability.slug));
+    } else {
+        let message = availability.message.as_deref().unwrap_or("not available");
+        println!("\n  {} {} is not available: {}\n", red("✗"), bold(&availability.slug), message);
     }
 
     Ok(())
 }
 
 pub async fn list(client: &ApiClient, json: bool) -> Result<()> {
-    let list = client.list_endpoints().await?;
+    let mut list = client.list_endpoints().await?;
+
+    if let Some(query) = client.team() {
+        let team = client.resolve_team(query).await?;
+        list.owned.retain(|ep| ep.shared_with.iter().any(|t| t.team_id == team.id));
+        list.shared.retain(|ep| ep.from_team.as_ref().is_some_and(|t| t.team_id == team.id));
+    }
 
     if json {
         println!("{}", serde_json::to_string_pretty(&list)?);
@@ -88,8 +151,361 @@ pub async fn get(client: &ApiClient, slug: &str, json: bool) -> Result<()> {
     if endpoint.is_ephemeral {
         println!("  {} true", dim("Ephemeral:"));
     }
+    if let Some(ref demo) = endpoint.demo {
+        println!(
+            "  {} {} {} per minute",
+            dim("Demo:"),
+            demo.template.as_deref().unwrap_or(&demo.provider),
+            demo.per_minute.unwrap_or(6)
+        );
+    }
+    if let Some(ref extend) = endpoint.auto_extend {
+        println!(
+            "  {} {} after the last request, until {}",
+            dim("Auto-extend:"),
+            format_gap(extend.idle_ms),
+            format_timestamp(extend.until)
+        );
+    }
+    if let Some(paused_at) = endpoint.paused_at {
+        let reply = match endpoint.paused_response {
+            Some(ref r) => format!("{} ({})", r.status, r.body.chars().take(50).collect::<String>()),
+            None => "503 paused".to_string(),
+        };
+        println!("  {} since {}, replying {}", yellow("Paused:"), format_timestamp(paused_at), reply);
+    }
     if let Some(ref mock) = endpoint.mock_response {
-        println!("  {} {} ({})", dim("Mock:"), mock.status, mock.body.chars().take(50).collect::<String>());
+        let body = match mock.body_base64 {
+            Some(_) => format!("binary, {} bytes", mock.body_size()),
+            None => mock.body.chars().take(50).collect(),
+        };
+        println!("  {} {} ({})", dim("Mock:"), mock.status, body);
+        if !mock.by_method.is_empty() {
+            let mut methods: Vec<_> = mock.by_method.iter().collect();
+            methods.sort_by(|a, b| a.0.cmp(b.0));
+            let replies: Vec<String> = methods.iter().map(|(method, reply)| format!("{} → {}", method, reply.status)).collect();
+            println!("  {} {}", dim("By method:"), sanitize(&replies.join(", ")));
+        }
+        if !mock.representations.is_empty() {
+            let types: Vec<&str> = mock.representations.keys().map(String::as_str).collect();
+            println!("  {} {}", dim("Representations:"), sanitize(&types.join(", ")));
+        }
+        if let Some(ref correlation) = mock.correlation {
+            println!(
+                "  {} {} ({} responses)",
+                dim("Correlated:"),
+                correlation.key,
+                correlation.responses.len()
+            );
+        }
+        for variant in &mock.variants {
+            println!(
+                "  {} {} {}% → {}",
+                dim("Variant:"),
+                variant.name,
+                variant.weight,
+                variant.status
+            );
+        }
+        for window in &mock.schedule {
+            let days = if window.days.is_empty() {
+                "daily".to_string()
+            } else {
+                window.days.join(",")
+            };
+            println!(
+                "  {} {} {}-{} {} {} → {}",
+                dim("Window:"),
+                window.name,
+                window.start,
+                window.end,
+                window.timezone.as_deref().unwrap_or("UTC"),
+                days,
+                window.status
+            );
+        }
+        for rule in &mock.rules {
+            println!(
+                "  {} {} → {}",
+                dim("Rule:"),
+                mock_rule_label(&rule.conditions),
+                rule.status
+            );
+        }
+        if let Some(ref sequence) = mock.sequence {
+            let steps: Vec<_> = sequence
+                .steps
+                .iter()
+                .map(|step| match step.count {
+                    Some(count) if count > 1 => format!("{}×{count}", step.status),
+                    _ => step.status.to_string(),
+                })
+                .collect();
+            let mut label = steps.join(" → ");
+            if sequence.repeat {
+                label.push_str(", repeating");
+            }
+            if let Some(ref key) = sequence.key {
+                label.push_str(&format!(", per {}", sanitize(key)));
+            }
+            println!("  {} {}", dim("Sequence:"), label);
+        }
+        if mock.echo {
+            println!("  {} request reflected as JSON", dim("Echo:"));
+        }
+        if let Some(ref stream) = mock.stream {
+            let format = match stream.format.as_deref() {
+                Some("chunked") => "chunks",
+                _ => "SSE events",
+            };
+            let millis: u64 = stream.events.iter().map(|e| u64::from(e.delay.unwrap_or(0))).sum();
+            println!(
+                "  {} {} {} over {:.1}s",
+                dim("Stream:"),
+                stream.events.len(),
+                format,
+                millis as f64 / 1000.0
+            );
+        }
+        if let Some(ref script) = mock.script {
+            println!("  {} {} lines", dim("Script:"), script.lines().count());
+        }
+        if let Some(ref grpc) = mock.grpc {
+            let message = grpc.message.as_deref().map(|m| format!(" ({m})")).unwrap_or_default();
+            println!("  {} status {}{}", dim("gRPC reply:"), grpc.code, message);
+        }
+    }
+    if let Some(ref canary) = endpoint.mock_canary {
+        println!(
+            "  {} {} for {}% of senders since {}",
+            yellow("Mock canary:"),
+            canary.response.status,
+            canary.percent,
+            format_timestamp(canary.started_at)
+        );
+    }
+    if endpoint.info_headers {
+        println!("  {} on", dim("Info headers:"));
+    }
+    if endpoint.record_responses {
+        println!("  {} on", dim("Records responses:"));
+    }
+    if endpoint.canonical_json {
+        println!("  {} on", dim("Canonical JSON:"));
+    }
+    if endpoint.query_overrides {
+        println!("  {} on, __status and __delay apply", dim("Query overrides:"));
+    }
+    if endpoint.auto_handshake {
+        println!("  {} on, provider verification requests are answered", dim("Auto-handshake:"));
+    }
+    if let Some(ref url) = endpoint.forward_url {
+        println!("  {} {url}, its replies are sent and recorded", dim("Forwards to:"));
+    }
+    if let Some(max) = endpoint.max_body_size {
+        println!("  {} {}, larger bodies are cut short", dim("Max body size:"), format_bytes(max as usize));
+    }
+    if endpoint.dry_run {
+        let stats = client.dry_run_stats(&endpoint.slug).await?;
+        println!(
+            "  {} on, nothing is stored ({} requests, {}, {} mocked)",
+            dim("Dry run:"),
+            stats.requests,
+            format_bytes(stats.bytes as usize),
+            stats.mocked
+        );
+    }
+    if let Some(ref sampling) = endpoint.sampling {
+        let mut limits = Vec::new();
+        if let Some(rate) = sampling.rate {
+            limits.push(format!("1 in {rate}"));
+        }
+        if let Some(per_minute) = sampling.per_minute {
+            limits.push(format!("at most {per_minute}/min"));
+        }
+        let stats = client.sampling_stats(&endpoint.slug).await?;
+        println!(
+            "  {} {} ({} requests, {} sampled out, {})",
+            dim("Sampling:"),
+            limits.join(", "),
+            stats.requests,
+            stats.sampled_out,
+            format_bytes(stats.bytes as usize)
+        );
+    }
+    if let Some(ref chaos) = endpoint.chaos {
+        let faults = if chaos.faults.is_empty() {
+            "any fault".to_string()
+        } else {
+            chaos.faults.join(", ")
+        };
+        println!("  {} {}% ({})", dim("Chaos:"), chaos.percent, sanitize(&faults));
+    }
+    if let Some(ref rate_limit) = endpoint.rate_limit {
+        println!("  {} {} requests per {}s", dim("Rate limit:"), rate_limit.limit, rate_limit.window);
+    }
+    if let Some(ref rule) = endpoint.priority_rule {
+        let values = if rule.values.is_empty() {
+            "any value".to_string()
+        } else {
+            rule.values.join(", ")
+        };
+        println!("  {} {} ({})", dim("Priority header:"), rule.header, values);
+    }
+    if !endpoint.encrypted_headers.is_empty() {
+        println!("  {} {}", dim("Encrypted headers:"), endpoint.encrypted_headers.join(", "));
+    }
+    if let Some(ref ca) = endpoint.client_ca {
+        let count = ca.matches("-----BEGIN CERTIFICATE-----").count();
+        println!(
+            "  {} required ({} CA certificate{})",
+            dim("Client cert:"),
+            count,
+            if count == 1 { "" } else { "s" }
+        );
+    }
+    if let Some(ref sig) = endpoint.signature_verification {
+        println!(
+            "  {} {} ({} {}){}",
+            dim("Signatures:"),
+            sig.provider,
+            sig.header,
+            sig.algorithm,
+            if sig.reject { ", rejecting invalid" } else { "" }
+        );
+    }
+    if let Some(ref jwt) = endpoint.jwt_verification {
+        let source = match jwt.jwks_url {
+            Some(ref url) => url.as_str(),
+            None => "shared secret",
+        };
+        println!(
+            "  {} {} in {}{}",
+            dim("JWT:"),
+            source,
+            jwt.header,
+            if jwt.reject { ", rejecting invalid" } else { "" }
+        );
+    }
+    if let Some(ref auth) = endpoint.capture_auth {
+        match (auth.username.as_deref(), auth.header.as_deref()) {
+            (Some(username), _) => println!("  {} basic, user {}", dim("Capture auth:"), username),
+            (_, Some(header)) => println!("  {} API key in {}", dim("Capture auth:"), header),
+            _ => println!("  {} {}", dim("Capture auth:"), auth.kind),
+        }
+    }
+    if let Some(ref cors) = endpoint.cors {
+        let mut details = Vec::new();
+        if !cors.methods.is_empty() {
+            details.push(cors.methods.join(", "));
+        }
+        if !cors.headers.is_empty() {
+            details.push(format!("headers {}", cors.headers.join(", ")));
+        }
+        if cors.credentials {
+            details.push("with credentials".to_string());
+        }
+        if let Some(max_age) = cors.max_age {
+            details.push(format!("preflight cached {max_age}s"));
+        }
+        let details = if details.is_empty() {
+            String::new()
+        } else {
+            format!(" ({})", details.join("; "))
+        };
+        println!("  {} {}{}", dim("CORS:"), cors.origins.join(", "), details);
+    }
+    if let Some(ref filter) = endpoint.capture_filter {
+        let filtered = client
+            .network_stats(&endpoint.slug)
+            .await?
+            .iter()
+            .find(|s| s.rule == "filtered")
+            .map_or(0, |s| s.matched);
+        let labels = |rules: &[CaptureFilterRule]| {
+            rules.iter().map(capture_filter_rule_label).collect::<Vec<_>>().join(" or ")
+        };
+        let mut parts = Vec::new();
+        if !filter.include.is_empty() {
+            parts.push(format!("only {}", labels(&filter.include)));
+        }
+        if !filter.exclude.is_empty() {
+            parts.push(format!("skipping {}", labels(&filter.exclude)));
+        }
+        println!("  {} {} ({} filtered)", dim("Capture filter:"), parts.join("; "), filtered);
+    }
+    if let Some(ref validation) = endpoint.schema_validation {
+        let failure = validation
+            .get("failureResponse")
+            .and_then(|failure| failure.get("status"))
+            .and_then(|status| status.as_u64())
+            .map(|status| format!(", answering failures with {status}"))
+            .unwrap_or_default();
+        println!("  {} checking bodies{}", dim("JSON Schema:"), failure);
+    }
+    if let Some(ref redaction) = endpoint.redaction {
+        let list = |value: Option<&serde_json::Value>| -> Vec<String> {
+            value
+                .and_then(|list| list.as_array())
+                .into_iter()
+                .flatten()
+                .filter_map(|rule| rule.as_str())
+                .map(sanitize)
+                .collect()
+        };
+        let rules: Vec<String> = ["detectors", "paths", "patterns"]
+            .into_iter()
+            .flat_map(|field| list(redaction.get(field)))
+            .collect();
+        if !rules.is_empty() {
+            println!("  {} {}", dim("Redacting:"), rules.join(", "));
+        }
+        let headers = redaction.get("headers");
+        let deny = list(headers.and_then(|headers| headers.get("deny")));
+        if !deny.is_empty() {
+            println!("  {} {}", dim("Hidden headers:"), deny.join(", "));
+        }
+        let allow = list(headers.and_then(|headers| headers.get("allow")));
+        if !allow.is_empty() {
+            println!("  {} {}", dim("Kept headers:"), allow.join(", "));
+        }
+    }
+    if let Some(ref domain) = endpoint.custom_domain {
+        println!("  {} https://{}", dim("Custom domain:"), domain);
+    }
+    if let Some(ref policy) = endpoint.network_policy {
+        let stats = client.network_stats(&endpoint.slug).await?;
+        let matched = |rule: &str| {
+            stats.iter().find(|s| s.rule == rule).map_or(0, |s| s.matched)
+        };
+        if let Some(ref allow) = policy.allow {
+            println!(
+                "  {} {} ({} blocked)",
+                dim("Allow:"),
+                rule_entries(allow),
+                matched("blocked")
+            );
+        }
+        if let Some(ref deny) = policy.deny {
+            println!(
+                "  {} {} ({} denied)",
+                dim("Deny:"),
+                rule_entries(deny),
+                matched("denied")
+            );
+        }
+        if policy.capture_rejected {
+            println!("  {} kept (whk requests rejected)", dim("Rejected requests:"));
+        }
+        for tag in &policy.tags {
+            println!(
+                "  {} {} ← {} ({} matched)",
+                dim("Network tag:"),
+                tag.tag,
+                rule_entries(&tag.rule),
+                matched(&format!("tag:{}", tag.tag))
+            );
+        }
     }
     if !endpoint.shared_with.is_empty() {
         let teams: Vec<_> = endpoint.shared_with.iter().map(|t| t.team_name.as_str()).collect();
@@ -99,6 +515,175 @@ pub async fn get(client: &ApiClient, slug: &str, json: bool) -> Result<()> {
     Ok(())
 }
 
+/// Changes to an endpoint's network policy requested by `update-endpoint` flags.
+pub enum NetworkPolicyEdit {
+    Clear,
+    /// Replace the given parts of the policy, keeping the rest
+    Replace {
+        allow: Option<NetworkRule>,
+        deny: Option<NetworkRule>,
+        tags: Option<Vec<NetworkTagRule>>,
+        capture_rejected: Option<bool>,
+    },
+}
+
+/// Build the network policy edit for `--allow`, `--deny`, `--network-tag`,
+/// `--capture-rejected` and `--clear-network-policy`. The server validates
+/// codes and ranges.
+pub fn network_policy_edit(
+    allow: &[String],
+    deny: &[String],
+    network_tags: &[String],
+    capture_rejected: Option<bool>,
+    clear: bool,
+) -> Result<Option<NetworkPolicyEdit>> {
+    if clear {
+        return Ok(Some(NetworkPolicyEdit::Clear));
+    }
+    if allow.is_empty() && deny.is_empty() && network_tags.is_empty() && capture_rejected.is_none() {
+        return Ok(None);
+    }
+    let tags = network_tags
+        .iter()
+        .map(|spec| {
+            let (tag, entries) = spec
+                .split_once('=')
+                .ok_or_else(|| anyhow::anyhow!("--network-tag must be TAG=COUNTRY|CIDR,..."))?;
+            let entries: Vec<&str> = entries.split(',').collect();
+            Ok(NetworkTagRule {
+                tag: tag.trim().to_string(),
+                rule: network_rule(&entries),
+            })
+        })
+        .collect::<Result<Vec<_>>>()?;
+    Ok(Some(NetworkPolicyEdit::Replace {
+        allow: (!allow.is_empty()).then(|| network_rule(allow)),
+        deny: (!deny.is_empty()).then(|| network_rule(deny)),
+        tags: (!tags.is_empty()).then_some(tags),
+        capture_rejected,
+    }))
+}
+
+/// Providers whose published webhook source ranges a rule can match.
+const NETWORK_PROVIDERS: &[&str] = &["github", "stripe"];
+
+/// Sort entries into providers, country codes (two characters) and CIDR
+/// ranges.
+fn network_rule<S: AsRef<str>>(entries: &[S]) -> NetworkRule {
+    let mut rule = NetworkRule::default();
+    for entry in entries {
+        let entry = entry.as_ref().trim();
+        if entry.is_empty() {
+            continue;
+        }
+        if NETWORK_PROVIDERS.contains(&entry.to_ascii_lowercase().as_str()) {
+            rule.providers.push(entry.to_ascii_lowercase());
+        } else if entry.len() == 2 && entry.chars().all(|c| c.is_ascii_alphanumeric()) {
+            rule.countries.push(entry.to_ascii_uppercase());
+        } else {
+            rule.cidrs.push(entry.to_string());
+        }
+    }
+    rule
+}
+
+fn rule_entries(rule: &NetworkRule) -> String {
+    rule.countries
+        .iter()
+        .chain(&rule.cidrs)
+        .chain(&rule.providers)
+        .map(String::as_str)
+        .collect::<Vec<_>>()
+        .join(", ")
+}
+
+/// Build the capture filter for `--capture-only`, `--skip-capture` and
+/// `--clear-capture-filter`. Each flag value is a one-condition rule:
+/// `method:POST[,PUT]`, `path:GLOB`, `header:NAME[=REGEX]` or `body:REGEX`.
+/// The server validates patterns.
+pub fn capture_filter_edit(only: &[String], skip: &[String], clear: bool) -> Result<Option<serde_json::Value>> {
+    if clear {
+        return Ok(Some(serde_json::Value::Null));
+    }
+    if only.is_empty() && skip.is_empty() {
+        return Ok(None);
+    }
+    let rules = |specs: &[String]| specs.iter().map(|spec| capture_filter_rule(spec)).collect::<Result<Vec<_>>>();
+    let mut filter = serde_json::json!({});
+    if !only.is_empty() {
+        filter["include"] = rules(only)?.into();
+    }
+    if !skip.is_empty() {
+        filter["exclude"] = rules(skip)?.into();
+    }
+    Ok(Some(filter))
+}
+
+fn capture_filter_rule(spec: &str) -> Result<serde_json::Value> {
+    let Some((kind, value)) = spec.split_once(':') else {
+        anyhow::bail!("capture filter rule must be method:, path:, header: or body:, got {spec:?}");
+    };
+    Ok(match kind.trim() {
+        "method" => serde_json::json!({
+            "methods": value.split(',').map(|m| m.trim().to_ascii_uppercase()).collect::<Vec<_>>()
+        }),
+        "path" => serde_json::json!({ "path": value }),
+        "header" => match value.split_once('=') {
+            Some((name, pattern)) => serde_json::json!({ "header": { "name": name.trim(), "value": pattern } }),
+            None => serde_json::json!({ "header": { "name": value.trim() } }),
+        },
+        "body" => serde_json::json!({ "body": value }),
+        other => anyhow::bail!("unknown capture filter condition {other:?} (use method, path, header or body)"),
+    })
+}
+
+fn capture_filter_rule_label(rule: &CaptureFilterRule) -> String {
+    let mut conditions = Vec::new();
+    if !rule.methods.is_empty() {
+        conditions.push(rule.methods.join(","));
+    }
+    if let Some(ref path) = rule.path {
+        conditions.push(path.clone());
+    }
+    if let Some(ref header) = rule.header {
+        conditions.push(match header.value {
+            Some(ref value) => format!("{}={}", header.name, value),
+            None => header.name.clone(),
+        });
+    }
+    if let Some(ref body) = rule.body {
+        conditions.push(format!("body /{body}/"));
+    }
+    conditions.join(" + ")
+}
+
+/// Short form of a mock rule's conditions, e.g.
+/// `POST /orders/* header.x-event=invoice.* body.data.status=failed`, or
+/// `any request` when it has none.
+fn mock_rule_label(conditions: &MockRuleMatch) -> String {
+    let mut parts = Vec::new();
+    if !conditions.methods.is_empty() {
+        parts.push(conditions.methods.join(","));
+    }
+    if let Some(ref path) = conditions.path {
+        parts.push(path.clone());
+    }
+    for (prefix, values) in [
+        ("header", &conditions.headers),
+        ("query", &conditions.query),
+        ("body", &conditions.body),
+    ] {
+        let mut values: Vec<_> = values.iter().collect();
+        values.sort();
+        parts.extend(values.into_iter().map(|(name, value)| format!("{prefix}.{name}={value}")));
+    }
+    if parts.is_empty() {
+        "any request".to_string()
+    } else {
+        parts.join(" ")
+    }
+}
+
 #[allow(clippy::too_many_arguments)]
 pub async fn update_endpoint(
     client: &ApiClient,
@@ -107,19 +692,91 @@ pub async fn update_endpoint(
     mock_status: Option<u16>,
     mock_body: Option<String>,
     mock_headers: Vec<String>,
+    mock_echo: bool,
     clear_mock: bool,
+    info_headers: Option<bool>,
+    record_responses: Option<bool>,
+    canonical_json: Option<bool>,
+    dry_run: Option<bool>,
+    query_overrides: Option<bool>,
+    auto_handshake: Option<bool>,
+    forward_url: Option<serde_json::Value>,
+    priority_rule: Option<serde_json::Value>,
+    encrypted_headers: Option<serde_json::Value>,
+    network_policy: Option<NetworkPolicyEdit>,
+    client_ca: Option<serde_json::Value>,
+    signature_verification: Option<serde_json::Value>,
+    jwt_verification: Option<serde_json::Value>,
+    capture_auth: Option<serde_json::Value>,
+    cors: Option<serde_json::Value>,
+    capture_filter: Option<serde_json::Value>,
+    sampling: Option<serde_json::Value>,
+    chaos: Option<serde_json::Value>,
+    rate_limit: Option<serde_json::Value>,
+    schema_validation: Option<serde_json::Value>,
+    redaction: Option<serde_json::Value>,
+    custom_domain: Option<serde_json::Value>,
+    max_body_size: Option<serde_json::Value>,
     json: bool,
 ) -> Result<()> {
     let mock_response = if clear_mock {
         Some(serde_json::Value::Null)
     } else {
-        build_mock_response(mock_status, mock_body, mock_headers)?
+        build_mock_response(mock_status, mock_body, mock_headers, mock_echo)?
             .map(|m| serde_json::to_value(m).expect("MockResponse is always serializable"))
     };
 
+    let network_policy = match network_policy {
+        None => None,
+        Some(NetworkPolicyEdit::Clear) => Some(serde_json::Value::Null),
+        Some(NetworkPolicyEdit::Replace { allow, deny, tags, capture_rejected }) => {
+            // The API replaces the whole policy, so keep the parts not being edited.
+            let mut policy = client.get_endpoint(slug).await?.network_policy.unwrap_or_default();
+            if let Some(allow) = allow {
+                policy.allow = Some(allow);
+            }
+            if let Some(deny) = deny {
+                policy.deny = Some(deny);
+            }
+            if let Some(tags) = tags {
+                policy.tags = tags;
+            }
+            if let Some(capture_rejected) = capture_rejected {
+                policy.capture_rejected = capture_rejected;
+            }
+            Some(serde_json::to_value(policy)?)
+        }
+    };
+
     let req = UpdateEndpointRequest {
         name,
         mock_response,
+        notification_url: None,
+        function_sink: None,
+        body_transforms: None,
+        info_headers,
+        record_responses,
+        canonical_json,
+        dry_run,
+        query_overrides,
+        auto_handshake,
+        forward_url,
+        priority_rule,
+        encrypted_headers,
+        network_policy,
+        client_ca,
+        signature_verification,
+        jwt_verification,
+        capture_auth,
+        cors,
+        capture_filter,
+        sampling,
+        chaos,
+        rate_limit,
+        schema_validation,
+        redaction,
+        custom_domain,
+        max_body_size,
     };
 
     let endpoint = client.update_endpoint(slug, &req).await?;
@@ -133,6 +790,48 @@ pub async fn update_endpoint(
     Ok(())
 }
 
+pub async fn pause(
+    client: &ApiClient,
+    slug: &str,
+    status: Option<u16>,
+    body: Option<String>,
+    json: bool,
+) -> Result<()> {
+    let response = match status {
+        Some(status) if !(100..=599).contains(&status) => {
+            anyhow::bail!("status must be between 100 and 599")
+        }
+        Some(status) => Some(PausedResponse {
+            status,
+            body: body.unwrap_or_default(),
+        }),
+        None => None,
+    };
+
+    let endpoint = client.pause_endpoint(slug, response.as_ref()).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&endpoint)?);
+    } else {
+        println!("  {} Paused {}; requests are answered but not captured", yellow("●"), bold(&endpoint.slug));
+        println!("  {}", dim(&format!("Resume with: whk resume {}", endpoint.slug)));
+    }
+
+    Ok(())
+}
+
+pub async fn resume(client: &ApiClient, slug: &str, json: bool) -> Result<()> {
+    let endpoint = client.resume_endpoint(slug).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&endpoint)?);
+    } else {
+        println!("  {} Resumed capturing on {}", green("✓"), bold(&endpoint.slug));
+    }
+
+    Ok(())
+}
+
 pub async fn delete(client: &ApiClient, slug: &str, force: bool, json: bool) -> Result<()> {
     if !force {
         print!(
@@ -149,7 +848,12 @@ pub async fn delete(client: &ApiClient, slug: &str, force: bool, json: bool) ->
         }
     }
 
-    client.delete_endpoint(slug).await?;
+    if let Err(e) = client.delete_endpoint(slug).await {
+        return Err(explain_delete_error(client, slug, e).await);
+    }
+    if let Ok(store) = CaptureCache::new(&client.url("")) {
+        let _ = store.clear(slug);
+    }
 
     if json {
         println!("{}", serde_json::json!({ "deleted": slug }));
@@ -160,12 +864,29 @@ pub async fn delete(client: &ApiClient, slug: &str, force: bool, json: bool) ->
     Ok(())
 }
 
+/// Turn a failed delete into a role-aware error. Team members can read and edit
+/// a shared endpoint, but only its owner can delete it; the API reports that as
+/// a plain 404, which reads like the endpoint doesn't exist.
+async fn explain_delete_error(client: &ApiClient, slug: &str, err: anyhow::Error) -> anyhow::Error {
+    match client.get_endpoint(slug).await {
+        Ok(endpoint) => match endpoint.from_team {
+            Some(team) => anyhow::anyhow!(
+                "endpoint {slug} is shared with you through team {}; only its owner can delete it",
+                team.team_name
+            ),
+            None => err,
+        },
+        Err(_) => err,
+    }
+}
+
 fn build_mock_response(
     status: Option<u16>,
     body: Option<String>,
     headers: Vec<String>,
+    echo: bool,
 ) -> Result<Option<MockResponse>> {
-    if status.is_none() && body.is_none() && headers.is_empty() {
+    if status.is_none() && body.is_none() && headers.is_empty() && !echo {
         return Ok(None);
     }
 
@@ -185,7 +906,33 @@ fn build_mock_response(
     Ok(Some(MockResponse {
         status,
         body: body.unwrap_or_default(),
+        body_base64: None,
         headers: header_map,
         delay: None,
+        delay_jitter: None,
+        header_policy: None,
+        by_method: HashMap::new(),
+        correlation: None,
+        variants: Vec::new(),
+        schedule: Vec::new(),
+        rules: Vec::new(),
+        sequence: None,
+        script: None,
+        echo,
+        stream: None,
+        representations: BTreeMap::new(),
+        grpc: None,
+    }))
+}
+
+/// Turn `--auto-extend [IDLE]` and `--auto-extend-max` into request windows.
+/// The server checks the ranges.
+pub fn build_auto_extend(idle: Option<String>, max: Option<String>) -> Result<Option<AutoExtendRequest>> {
+    let Some(idle) = idle else {
+        return Ok(None);
+    };
+    Ok(Some(AutoExtendRequest {
+        idle_ms: Some(parse_duration(&idle)?),
+        max_ms: max.as_deref().map(parse_duration).transpose()?,
     }))
 }
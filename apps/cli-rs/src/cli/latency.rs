@@ -0,0 +1,69 @@
+use anyhow::Result;
+
+use crate::api::ApiClient;
+use crate::cli::output::{bold, dim, red, sanitize};
+use crate::types::{DestinationLatency, LatencyPercentiles};
+
+/// Latency percentiles per function sink destination over `window`.
+pub async fn run(client: &ApiClient, slug: &str, window: &str, prometheus: bool, json: bool) -> Result<()> {
+    if prometheus {
+        print!("{}", client.sink_latency_metrics(slug, window).await?);
+        return Ok(());
+    }
+
+    let latency = client.sink_latency(slug, window).await?;
+    if json {
+        println!("{}", serde_json::to_string_pretty(&latency)?);
+        return Ok(());
+    }
+    if latency.destinations.is_empty() {
+        println!("  No function sink deliveries in the last {window}.");
+        return Ok(());
+    }
+
+    println!("{}", bold(&format!("Function sink latency, last {window}")));
+    println!("  {}", dim("p50 / p90 / p99 in ms"));
+    for destination in &latency.destinations {
+        print_destination(destination);
+    }
+    Ok(())
+}
+
+fn print_destination(d: &DestinationLatency) {
+    println!("\n  {}", bold(&sanitize(&d.target)));
+    let mut counts = format!("{} deliveries", d.deliveries);
+    if d.retried > 0 {
+        counts.push_str(&format!(", {} retried", d.retried));
+    }
+    println!("  {} {}", dim(&format!("{:<13}", "Deliveries:")), counts);
+    if d.failed > 0 {
+        println!("  {} {}", dim(&format!("{:<13}", "Failed:")), red(&d.failed.to_string()));
+    }
+    println!("  {} {}", dim(&format!("{:<13}", "Relay:")), percentiles(&d.relay));
+    println!("  {} {}", dim(&format!("{:<13}", "Destination:")), percentiles(&d.destination));
+    println!("  {} {}", dim(&format!("{:<13}", "Total:")), percentiles(&d.total));
+}
+
+pub(super) fn percentiles(p: &LatencyPercentiles) -> String {
+    format!("{} / {} / {}", ms(p.p50), ms(p.p90), ms(p.p99))
+}
+
+/// Whole milliseconds, or one decimal under 10ms.
+pub(super) fn ms(value: f64) -> String {
+    if value < 10.0 {
+        format!("{value:.1}")
+    } else {
+        format!("{value:.0}")
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_percentiles_format() {
+        let p = LatencyPercentiles { p50: 4.21, p90: 61.4, p99: 480.4 };
+        assert_eq!(percentiles(&p), "4.2 / 61 / 480");
+    }
+}
@@ -3,6 +3,7 @@ use tokio::sync::mpsc;
 
 use crate::api::ApiClient;
 use crate::cli::output::{bold, dim, green, method_color, red};
+use crate::tui::Launch;
 use crate::types::SseEvent;
 use crate::util::format::format_bytes;
 
@@ -11,6 +12,7 @@ pub async fn run(client: &ApiClient, slug: &str, json: bool) -> Result<()> {
         let url = client.webhook_url_for(slug);
         println!("\n  {} Listening on {}", green("●"), bold(slug));
         println!("  {} {}", dim("Webhook URL:"), url);
+        println!("  {} {}", dim("Open in TUI:"), Launch::Live(slug.to_string()).command());
         println!("  {}\n", dim("Press Ctrl+C to stop."));
     }
 
@@ -64,6 +66,14 @@ pub async fn run(client: &ApiClient, slug: &str, json: bool) -> Result<()> {
         }
     }
 
-    stream_handle.abort();
+    // A finished stream task means the stream itself ended; surface its error
+    // (e.g. a read-timeout stall).
+    if stream_handle.is_finished() {
+        if let Ok(Err(e)) = stream_handle.await {
+            return Err(e);
+        }
+    } else {
+        stream_handle.abort();
+    }
     Ok(())
 }
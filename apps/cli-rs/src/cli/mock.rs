@@ -0,0 +1,436 @@
+use anyhow::{bail, Result};
+use base64::Engine;
+use std::collections::{BTreeMap, HashMap};
+
+use crate::api::ApiClient;
+use crate::cli::output::{bold, dim, green, sanitize, yellow};
+use crate::types::{EndpointConfig, EndpointVersion, FetchedResponse, MockResponse, UpdateEndpointRequest};
+use crate::util::format::format_timestamp;
+
+/// Largest body accepted as a mock (the API caps request bodies at 64KB,
+/// which also has to fit the rest of the PATCH).
+const MAX_MOCK_BODY: usize = 60 * 1024;
+
+/// Response headers that describe the original connection or transfer rather
+/// than the response itself; the receiver sets its own.
+const SKIPPED_HEADERS: &[&str] = &[
+    "age",
+    "alt-svc",
+    "connection",
+    "content-encoding",
+    "content-length",
+    "date",
+    "keep-alive",
+    "proxy-connection",
+    "trailer",
+    "transfer-encoding",
+    "upgrade",
+];
+
+/// Fetch a real response and save it as the endpoint's mock response.
+#[allow(clippy::too_many_arguments)]
+pub async fn import(
+    client: &ApiClient,
+    slug: &str,
+    url: &str,
+    method: &str,
+    headers: Vec<String>,
+    data: Option<&str>,
+    dry_run: bool,
+    json: bool,
+) -> Result<()> {
+    let header_map = crate::cli::send::parse_headers(&headers)?;
+    let body = crate::cli::send::read_body(data)?;
+    let fetched = client.fetch_response(url, method, &header_map, body.as_deref()).await?;
+
+    // Keep the existing mock's delay, header policy, replies by method,
+    // correlations, variants, schedule, rules, sequence and script.
+    let existing = client.get_endpoint(slug).await?.mock_response;
+    let mock = to_mock(fetched, existing.as_ref())?;
+
+    if dry_run {
+        if json {
+            println!("{}", serde_json::to_string_pretty(&mock)?);
+        } else {
+            print_mock(&mock);
+            println!("\n  {}", dim("Dry run: mock not saved."));
+        }
+        return Ok(());
+    }
+
+    let req = UpdateEndpointRequest {
+        mock_response: Some(serde_json::to_value(&mock)?),
+        ..Default::default()
+    };
+    let endpoint = client.update_endpoint(slug, &req).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&endpoint)?);
+    } else {
+        print_mock(&mock);
+        println!("\n  {} Saved as the mock response for {}", green("✓"), bold(&endpoint.slug));
+    }
+    Ok(())
+}
+
+/// Convert a fetched response into a mock, carrying over the settings of
+/// `existing` that aren't part of a response.
+fn to_mock(fetched: FetchedResponse, existing: Option<&MockResponse>) -> Result<MockResponse> {
+    if fetched.body.len() > MAX_MOCK_BODY {
+        bail!(
+            "response body is {} bytes; mock bodies are limited to {} bytes",
+            fetched.body.len(),
+            MAX_MOCK_BODY
+        );
+    }
+    // Binary bodies are kept base64-encoded, which makes them a third larger
+    let (body, body_base64) = match String::from_utf8(fetched.body) {
+        Ok(body) => (body, None),
+        Err(e) => (
+            String::new(),
+            Some(base64::engine::general_purpose::STANDARD.encode(e.as_bytes())),
+        ),
+    };
+    if let Some(ref encoded) = body_base64 {
+        if encoded.len() > MAX_MOCK_BODY {
+            bail!(
+                "binary response body is {} bytes base64-encoded; mock bodies are limited to {} bytes",
+                encoded.len(),
+                MAX_MOCK_BODY
+            );
+        }
+    }
+
+    let mut headers: HashMap<String, String> = HashMap::new();
+    for (name, value) in fetched.headers {
+        let name = name.to_lowercase();
+        if SKIPPED_HEADERS.contains(&name.as_str()) {
+            continue;
+        }
+        match headers.get_mut(&name) {
+            // Cookies can't be folded into one value; keep the first.
+            Some(_) if name == "set-cookie" => {}
+            Some(joined) => {
+                joined.push_str(", ");
+                joined.push_str(&value);
+            }
+            None => {
+                headers.insert(name, value);
+            }
+        }
+    }
+
+    Ok(MockResponse {
+        status: fetched.status,
+        body,
+        body_base64,
+        headers,
+        delay: existing.and_then(|m| m.delay),
+        delay_jitter: existing.and_then(|m| m.delay_jitter),
+        header_policy: existing.and_then(|m| m.header_policy.clone()),
+        by_method: existing.map(|m| m.by_method.clone()).unwrap_or_default(),
+        correlation: existing.and_then(|m| m.correlation.clone()),
+        variants: existing.map(|m| m.variants.clone()).unwrap_or_default(),
+        schedule: existing.map(|m| m.schedule.clone()).unwrap_or_default(),
+        rules: existing.map(|m| m.rules.clone()).unwrap_or_default(),
+        sequence: existing.and_then(|m| m.sequence.clone()),
+        script: existing.and_then(|m| m.script.clone()),
+        // The imported body is what the mock should send
+        echo: false,
+        stream: None,
+        representations: BTreeMap::new(),
+        grpc: existing.and_then(|m| m.grpc.clone()),
+    })
+}
+
+/// Show the endpoint's configuration history, or restore a version.
+pub async fn history(client: &ApiClient, slug: &str, rollback: Option<u32>, json: bool) -> Result<()> {
+    if let Some(version) = rollback {
+        let endpoint = client.rollback_endpoint(slug, version).await?;
+        if json {
+            println!("{}", serde_json::to_string_pretty(&endpoint)?);
+        } else {
+            println!("  {} Restored version {} of {}", green("✓"), version, bold(&endpoint.slug));
+        }
+        return Ok(());
+    }
+
+    let versions = client.list_endpoint_versions(slug).await?;
+    if json {
+        println!("{}", serde_json::to_string_pretty(&versions)?);
+        return Ok(());
+    }
+    if versions.is_empty() {
+        println!("  No configuration changes recorded yet.");
+        return Ok(());
+    }
+
+    // Newest first; each version is compared with the one before it.
+    for (i, version) in versions.iter().enumerate() {
+        let previous = versions.get(i + 1);
+        let source = match (version.source.as_str(), version.rolled_back_to) {
+            ("rollback", Some(to)) => yellow(&format!("rollback to v{to}")),
+            ("initial", _) => dim("before first change"),
+            (other, _) => other.to_string(),
+        };
+        let changed = previous
+            .map(|p| changed_fields(&p.config, &version.config).join(", "))
+            .unwrap_or_default();
+        println!(
+            "  {} {} {}  {}",
+            bold(&format!("v{:<3}", version.version)),
+            dim(&format_timestamp(version.created_at)),
+            source,
+            dim(&changed)
+        );
+        println!("       {}", summarize(&version.config));
+    }
+    println!("\n  {}", dim(&format!("Restore one with: whk mock history {slug} --rollback <version>")));
+    Ok(())
+}
+
+/// Show the running mock canary, or promote or roll it back.
+pub async fn canary(client: &ApiClient, slug: &str, promote: bool, rollback: bool, json: bool) -> Result<()> {
+    if promote || rollback {
+        let endpoint = if promote {
+            client.promote_mock_canary(slug).await?
+        } else {
+            client.rollback_mock_canary(slug).await?
+        };
+        if json {
+            println!("{}", serde_json::to_string_pretty(&endpoint)?);
+        } else if promote {
+            println!("  {} Promoted the canary to the mock response of {}", green("✓"), bold(&endpoint.slug));
+        } else {
+            println!("  {} Rolled back the canary on {}", green("✓"), bold(&endpoint.slug));
+        }
+        return Ok(());
+    }
+
+    let status = client.mock_canary(slug).await?;
+    if json {
+        println!("{}", serde_json::to_string_pretty(&status)?);
+        return Ok(());
+    }
+
+    let canary = &status.canary;
+    println!(
+        "  {} {}% of senders since {}",
+        bold("Canary"),
+        canary.percent,
+        format_timestamp(canary.started_at)
+    );
+    println!(
+        "  {} mock {} ({} bytes)",
+        dim("Response:"),
+        canary.response.status,
+        canary.response.body_size()
+    );
+    let stats = &status.stats;
+    println!(
+        "  {} {} requests from {} senders",
+        dim("Stable:  "),
+        stats.requests.stable,
+        stats.senders.stable
+    );
+    println!(
+        "  {} {} requests from {} senders",
+        dim("Canary:  "),
+        stats.requests.canary,
+        stats.senders.canary
+    );
+    println!(
+        "\n  {}",
+        dim(&format!("Finish with: whk mock canary {slug} --promote (or --rollback)"))
+    );
+    Ok(())
+}
+
+/// Names of the settings that differ between two versions.
+fn changed_fields(a: &EndpointConfig, b: &EndpointConfig) -> Vec<&'static str> {
+    let mut changed = Vec::new();
+    if a.mock_response != b.mock_response {
+        changed.push("mock response");
+    }
+    if a.notification_url != b.notification_url {
+        changed.push("notification URL");
+    }
+    if a.function_sink != b.function_sink {
+        changed.push("function sink");
+    }
+    if a.body_transforms != b.body_transforms {
+        changed.push("body transforms");
+    }
+    if a.info_headers != b.info_headers {
+        changed.push("info headers");
+    }
+    changed
+}
+
+/// One-line description of a saved configuration.
+fn summarize(config: &EndpointConfig) -> String {
+    let mut parts = vec![match &config.mock_response {
+        Some(mock) => format!("mock {} ({} bytes)", mock.status, mock.body_size()),
+        None => "no mock".to_string(),
+    }];
+    if let Some(ref url) = config.notification_url {
+        parts.push(format!("notify {}", sanitize(url)));
+    }
+    if config.function_sink.is_some() {
+        parts.push("function sink".to_string());
+    }
+    if let Some(ref transforms) = config.body_transforms {
+        parts.push(format!("{} transforms", transforms.len()));
+    }
+    if config.info_headers {
+        parts.push("info headers".to_string());
+    }
+    parts.join(" · ")
+}
+
+fn print_mock(mock: &MockResponse) {
+    println!("\n  {} {}", dim("Status:"), bold(&mock.status.to_string()));
+    let mut names: Vec<&String> = mock.headers.keys().collect();
+    names.sort();
+    for name in names {
+        println!("  {} {}: {}", dim("Header:"), name, mock.headers[name]);
+    }
+    if mock.body_base64.is_some() {
+        println!("\n{}", dim(&format!("(binary body, {} bytes)", mock.body_size())));
+        return;
+    }
+    let preview: String = mock.body.chars().take(500).collect();
+    if !preview.is_empty() {
+        println!("\n{}", dim(&preview));
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn fetched(headers: &[(&str, &str)], body: &[u8]) -> FetchedResponse {
+        FetchedResponse {
+            status: 201,
+            headers: headers.iter().map(|(k, v)| (k.to_string(), v.to_string())).collect(),
+            body: body.to_vec(),
+        }
+    }
+
+    #[test]
+    fn test_to_mock_keeps_response_headers() {
+        let mock = to_mock(
+            fetched(
+                &[
+                    ("Content-Type", "application/json"),
+                    ("Content-Length", "11"),
+                    ("Date", "Mon, 01 Jan 2024 00:00:00 GMT"),
+                    ("Vary", "Accept"),
+                    ("vary", "Origin"),
+                    ("Set-Cookie", "a=1"),
+                    ("Set-Cookie", "b=2"),
+                ],
+                b"{\"ok\":true}",
+            ),
+            None,
+        )
+        .unwrap();
+
+        assert_eq!(mock.status, 201);
+        assert_eq!(mock.body, "{\"ok\":true}");
+        assert_eq!(mock.headers["content-type"], "application/json");
+        assert_eq!(mock.headers["vary"], "Accept, Origin");
+        assert_eq!(mock.headers["set-cookie"], "a=1");
+        assert!(!mock.headers.contains_key("content-length"));
+        assert!(!mock.headers.contains_key("date"));
+    }
+
+    #[test]
+    fn test_to_mock_preserves_existing_settings() {
+        let existing = MockResponse {
+            status: 200,
+            body: "old".into(),
+            body_base64: None,
+            headers: HashMap::from([("x-old".into(), "1".into())]),
+            delay: Some(250),
+            delay_jitter: Some(100),
+            header_policy: None,
+            by_method: HashMap::new(),
+            correlation: None,
+            variants: Vec::new(),
+            schedule: Vec::new(),
+            rules: Vec::new(),
+            sequence: None,
+            script: None,
+            echo: false,
+            stream: None,
+            representations: BTreeMap::new(),
+            grpc: None,
+        };
+        let mock = to_mock(fetched(&[], b"new"), Some(&existing)).unwrap();
+        assert_eq!((mock.delay, mock.delay_jitter), (Some(250), Some(100)));
+        assert_eq!(mock.body, "new");
+        assert!(mock.headers.is_empty());
+    }
+
+    fn config(status: Option<u16>, notification_url: Option<&str>) -> EndpointConfig {
+        EndpointConfig {
+            mock_response: status.map(|status| MockResponse {
+                status,
+                body: "{}".into(),
+                body_base64: None,
+                headers: HashMap::new(),
+                delay: None,
+                delay_jitter: None,
+                header_policy: None,
+                by_method: HashMap::new(),
+                correlation: None,
+                variants: Vec::new(),
+                schedule: Vec::new(),
+                rules: Vec::new(),
+                sequence: None,
+                script: None,
+                echo: false,
+                stream: None,
+                representations: BTreeMap::new(),
+                grpc: None,
+            }),
+            notification_url: notification_url.map(str::to_string),
+            function_sink: None,
+            body_transforms: None,
+            info_headers: false,
+        }
+    }
+
+    #[test]
+    fn test_changed_fields_between_versions() {
+        let a = config(Some(200), None);
+        let b = config(Some(500), Some("https://hooks.example/n"));
+        assert_eq!(changed_fields(&a, &b), vec!["mock response", "notification URL"]);
+        assert!(changed_fields(&a, &a).is_empty());
+    }
+
+    #[test]
+    fn test_summarize_config() {
+        assert_eq!(summarize(&config(None, None)), "no mock");
+        assert_eq!(
+            summarize(&config(Some(503), Some("https://hooks.example/n"))),
+            "mock 503 (2 bytes) · notify https://hooks.example/n"
+        );
+    }
+
+    #[test]
+    fn test_to_mock_keeps_binary_bodies() {
+        let mock = to_mock(fetched(&[("Content-Type", "image/png")], &[0x89, b'P', 0xff]), None).unwrap();
+        assert_eq!(mock.body, "");
+        assert_eq!(mock.body_base64.as_deref(), Some("iVD/"));
+        assert_eq!(mock.body_size(), 3);
+        assert_eq!(mock.headers["content-type"], "image/png");
+    }
+
+    #[test]
+    fn test_to_mock_rejects_oversized_bodies() {
+        assert!(to_mock(fetched(&[], &vec![0xff; MAX_MOCK_BODY]), None).is_err());
+        assert!(to_mock(fetched(&[], &vec![b'a'; MAX_MOCK_BODY + 1]), None).is_err());
+    }
+}
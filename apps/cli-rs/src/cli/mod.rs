@@ -1,13 +1,25 @@
+pub mod activity;
+pub mod annotate;
+pub mod apply;
 pub mod auth;
+pub mod bench;
+pub mod complete;
 pub mod endpoints;
+pub mod latency;
 pub mod listen;
+pub mod mock;
 pub mod output;
+pub mod picker;
 pub mod replay;
+pub mod report;
 pub mod requests;
 pub mod send;
+pub mod share;
+pub mod teams;
 pub mod tunnel;
 pub mod usage;
 pub mod update;
+pub mod watch;
 
 use clap::{Parser, Subcommand};
 
@@ -39,11 +51,20 @@ pub struct Cli {
     #[arg(long, global = true)]
     pub no_color: bool,
 
+    /// Team context (ID or name) for shared endpoints
+    #[arg(long, visible_alias = "org", env = "WHK_TEAM", global = true)]
+    pub team: Option<String>,
+
+    /// Show encrypted header values instead of masking them (needs access to the endpoint)
+    #[arg(long, global = true)]
+    pub reveal: bool,
+
     #[command(subcommand)]
     pub command: Option<Command>,
 }
 
 #[derive(Subcommand, Debug)]
+#[allow(clippy::large_enum_variant)]
 pub enum Command {
     /// Authenticate with webhooks.cc
     Auth {
@@ -56,6 +77,14 @@ pub enum Command {
         /// Endpoint name (auto-generated if omitted)
         name: Option<String>,
 
+        /// Custom slug for the webhook URL (e.g. "my-stripe-dev")
+        #[arg(long)]
+        slug: Option<String>,
+
+        /// Only check whether --slug is available, without creating anything
+        #[arg(long, requires = "slug")]
+        check: bool,
+
         /// Create as ephemeral (auto-expires)
         #[arg(short, long)]
         ephemeral: bool,
@@ -75,6 +104,35 @@ pub enum Command {
         /// Mock response header (repeatable, format: Key:Value)
         #[arg(long = "mock-header", value_name = "KEY:VALUE")]
         mock_headers: Vec<String>,
+
+        /// Reply with the request itself as JSON (method, path, query, headers, body, IP)
+        #[arg(long, conflicts_with = "mock_body")]
+        mock_echo: bool,
+
+        /// Fill the endpoint with synthetic captures from this provider (implies --ephemeral)
+        #[arg(long, value_name = "PROVIDER", value_parser = ["stripe", "github", "shopify"])]
+        demo: Option<String>,
+
+        /// Demo event to generate, e.g. "invoice.paid" (the provider's events take turns)
+        #[arg(long, value_name = "EVENT", requires = "demo")]
+        demo_template: Option<String>,
+
+        /// Demo captures per minute (1-30, default 6)
+        #[arg(
+            long,
+            value_name = "N",
+            requires = "demo",
+            value_parser = clap::value_parser!(u32).range(1..=30)
+        )]
+        demo_rate: Option<u32>,
+
+        /// Keep the endpoint alive while requests arrive, expiring after IDLE without any (implies --ephemeral)
+        #[arg(long, value_name = "IDLE", num_args = 0..=1, default_missing_value = "15m")]
+        auto_extend: Option<String>,
+
+        /// Longest an auto-extending endpoint may live (default 24h, at most 7d)
+        #[arg(long, value_name = "DURATION", requires = "auto_extend")]
+        auto_extend_max: Option<String>,
     },
 
     /// List all endpoints
@@ -82,15 +140,15 @@ pub enum Command {
 
     /// Get endpoint details
     Get {
-        /// Endpoint slug
-        slug: String,
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
     },
 
     /// Update endpoint settings
     #[command(name = "update-endpoint")]
     UpdateEndpoint {
-        /// Endpoint slug
-        slug: String,
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
 
         /// New display name
         #[arg(long)]
@@ -108,15 +166,325 @@ pub enum Command {
         #[arg(long = "mock-header", value_name = "KEY:VALUE")]
         mock_headers: Vec<String>,
 
+        /// Reply with the request itself as JSON (method, path, query, headers, body, IP)
+        #[arg(long, conflicts_with_all = ["mock_body", "clear_mock"])]
+        mock_echo: bool,
+
         /// Remove mock response
         #[arg(long)]
         clear_mock: bool,
+
+        /// Expose quota and expiry headers (X-Webhook-*) on webhook responses
+        #[arg(long, value_name = "BOOL")]
+        info_headers: Option<bool>,
+
+        /// Store the reply sent for each capture with the request
+        #[arg(long, value_name = "BOOL")]
+        record_responses: Option<bool>,
+
+        /// Compare JSON bodies by content (key order, whitespace, 1.0 vs 1) when flagging duplicates
+        #[arg(long, value_name = "BOOL")]
+        canonical_json: Option<bool>,
+
+        /// Answer requests (network policy, mock response) without storing them or counting quota; only counters are kept
+        #[arg(long, value_name = "BOOL")]
+        dry_run_mode: Option<bool>,
+
+        /// Let requests override the reply with `__status` and `__delay` (ms) query parameters
+        #[arg(long, value_name = "BOOL")]
+        query_overrides: Option<bool>,
+
+        /// Answer Slack, Zoom, Dropbox and Microsoft Graph verification requests (Zoom's with the signature secret)
+        #[arg(long, value_name = "BOOL")]
+        auto_handshake: Option<bool>,
+
+        /// Relay each request to this http(s) URL and answer with its reply (recorded with the capture) instead of the mock
+        #[arg(long, value_name = "URL")]
+        forward_url: Option<String>,
+
+        /// Stop relaying requests to the forward URL
+        #[arg(long, conflicts_with = "forward_url")]
+        clear_forward_url: bool,
+
+        /// Mark captures carrying this header as high priority
+        #[arg(long, value_name = "NAME")]
+        priority_header: Option<String>,
+
+        /// Only these values of --priority-header count (repeatable; any value when omitted)
+        #[arg(long = "priority-value", value_name = "VALUE", requires = "priority_header")]
+        priority_values: Vec<String>,
+
+        /// Remove the priority rule
+        #[arg(long, conflicts_with = "priority_header")]
+        clear_priority: bool,
+
+        /// Encrypt this header's value at capture time (repeatable; replaces the list)
+        #[arg(long = "encrypt-header", value_name = "NAME")]
+        encrypt_headers: Vec<String>,
+
+        /// Stop encrypting headers
+        #[arg(long, conflicts_with = "encrypt_headers")]
+        clear_encrypted_headers: bool,
+
+        /// Only accept requests from this country code, CIDR range or provider (github, stripe; repeatable; replaces the allow list)
+        #[arg(long = "allow", value_name = "COUNTRY|CIDR|PROVIDER")]
+        allow: Vec<String>,
+
+        /// Reject requests from this country code or CIDR range (repeatable; replaces the deny list)
+        #[arg(long = "deny", value_name = "COUNTRY|CIDR")]
+        deny: Vec<String>,
+
+        /// Tag requests from these countries or ranges (repeatable; replaces the tag rules)
+        #[arg(long = "network-tag", value_name = "TAG=COUNTRY|CIDR,...")]
+        network_tags: Vec<String>,
+
+        /// Keep requests rejected by the allow or deny list for `whk requests rejected`
+        #[arg(long, value_name = "BOOL")]
+        capture_rejected: Option<bool>,
+
+        /// Remove the network policy
+        #[arg(long, conflicts_with_all = ["allow", "deny", "network_tags", "capture_rejected"])]
+        clear_network_policy: bool,
+
+        /// Only accept requests over mTLS with a client certificate issued by a CA in this PEM file
+        #[arg(long, value_name = "FILE")]
+        client_ca: Option<String>,
+
+        /// Accept requests without a client certificate again
+        #[arg(long, conflicts_with = "client_ca")]
+        clear_client_ca: bool,
+
+        /// Check each request's HMAC signature with a provider preset or your own header
+        #[arg(
+            long,
+            value_name = "PROVIDER",
+            value_parser = ["stripe", "github", "shopify", "custom"],
+            requires = "signature_secret"
+        )]
+        verify_signature: Option<String>,
+
+        /// Signing secret for --verify-signature
+        #[arg(long, value_name = "SECRET", requires = "verify_signature")]
+        signature_secret: Option<String>,
+
+        /// Header carrying the signature (custom only)
+        #[arg(long, value_name = "NAME", requires = "verify_signature")]
+        signature_header: Option<String>,
+
+        /// HMAC hash (custom only, default sha256)
+        #[arg(
+            long,
+            value_name = "ALG",
+            value_parser = ["sha1", "sha256", "sha512"],
+            requires = "verify_signature"
+        )]
+        signature_algorithm: Option<String>,
+
+        /// Answer requests with a bad signature with 401 instead of storing them
+        #[arg(long, requires = "verify_signature")]
+        reject_invalid_signatures: bool,
+
+        /// Stop verifying signatures
+        #[arg(long, conflicts_with = "verify_signature")]
+        clear_signature_verification: bool,
+
+        /// Validate each request's JWT (HS256/384/512) with this shared secret
+        #[arg(long, value_name = "SECRET", group = "jwt_key")]
+        jwt_secret: Option<String>,
+
+        /// Validate each request's JWT with the keys published at this https URL
+        #[arg(long, value_name = "URL", group = "jwt_key")]
+        jwt_jwks_url: Option<String>,
+
+        /// Header carrying the JWT (default authorization; "Bearer " is ignored)
+        #[arg(long, value_name = "NAME", requires = "jwt_key")]
+        jwt_header: Option<String>,
+
+        /// Require this `iss` claim
+        #[arg(long, value_name = "ISS", requires = "jwt_key")]
+        jwt_issuer: Option<String>,
+
+        /// Require this `aud` claim
+        #[arg(long, value_name = "AUD", requires = "jwt_key")]
+        jwt_audience: Option<String>,
+
+        /// Answer requests with a bad JWT with 401 instead of storing them
+        #[arg(long, requires = "jwt_key")]
+        reject_invalid_jwts: bool,
+
+        /// Stop validating JWTs
+        #[arg(long, conflicts_with = "jwt_key")]
+        clear_jwt_verification: bool,
+
+        /// Only capture requests with these Basic credentials; others get 401
+        #[arg(long, value_name = "USER:PASS", group = "capture_credentials")]
+        basic_auth: Option<String>,
+
+        /// Only capture requests carrying this API key; others get 401
+        #[arg(long, value_name = "KEY", group = "capture_credentials")]
+        api_key: Option<String>,
+
+        /// Header carrying the API key (default x-api-key)
+        #[arg(long, value_name = "NAME", requires = "api_key")]
+        api_key_header: Option<String>,
+
+        /// Capture requests without credentials again
+        #[arg(long, conflicts_with = "capture_credentials")]
+        clear_capture_auth: bool,
+
+        /// Allow browsers on this origin, or * for any (repeatable; replaces the CORS config)
+        #[arg(long = "cors-origin", value_name = "ORIGIN")]
+        cors_origins: Vec<String>,
+
+        /// Allow this method in preflights (repeatable; default any)
+        #[arg(long = "cors-method", value_name = "METHOD", requires = "cors_origins")]
+        cors_methods: Vec<String>,
+
+        /// Allow this request header in preflights (repeatable; default any)
+        #[arg(long = "cors-header", value_name = "NAME", requires = "cors_origins")]
+        cors_headers: Vec<String>,
+
+        /// Allow credentialed requests (cookies, Authorization)
+        #[arg(long, requires = "cors_origins")]
+        cors_credentials: bool,
+
+        /// Let browsers cache preflight answers for this many seconds
+        #[arg(long, value_name = "SECONDS", requires = "cors_origins")]
+        cors_max_age: Option<u32>,
+
+        /// Allow any origin without credentials again
+        #[arg(long, conflicts_with = "cors_origins")]
+        clear_cors: bool,
+
+        /// Store only requests matching this rule: method:POST[,PUT], path:GLOB,
+        /// header:NAME[=REGEX] or body:REGEX (repeatable; replaces the capture filter)
+        #[arg(long = "capture-only", value_name = "RULE")]
+        capture_only: Vec<String>,
+
+        /// Don't store requests matching this rule, same forms as --capture-only (repeatable)
+        #[arg(long = "skip-capture", value_name = "RULE")]
+        skip_capture: Vec<String>,
+
+        /// Store every request again
+        #[arg(long, conflicts_with_all = ["capture_only", "skip_capture"])]
+        clear_capture_filter: bool,
+
+        /// Store one request in N (replaces the sampling config)
+        #[arg(long, value_name = "N")]
+        sample_rate: Option<u64>,
+
+        /// Store at most N requests a minute per receiver (replaces the sampling config)
+        #[arg(long, value_name = "N")]
+        sample_per_minute: Option<u64>,
+
+        /// Store all traffic again
+        #[arg(long, conflicts_with_all = ["sample_rate", "sample_per_minute"])]
+        clear_sampling: bool,
+
+        /// Answer this percent of requests with an injected fault (replaces the chaos config)
+        #[arg(long, value_name = "PERCENT")]
+        chaos_percent: Option<f64>,
+
+        /// Fault to inject: error, hang or reset (repeatable; all of them when omitted)
+        #[arg(
+            long = "chaos-fault",
+            value_name = "FAULT",
+            value_parser = ["error", "hang", "reset"],
+            requires = "chaos_percent"
+        )]
+        chaos_faults: Vec<String>,
+
+        /// Turn chaos mode off
+        #[arg(long, conflicts_with_all = ["chaos_percent", "chaos_faults"])]
+        clear_chaos: bool,
+
+        /// Answer this many requests per window, then 429 until it resets
+        #[arg(long, value_name = "N")]
+        rate_limit: Option<u64>,
+
+        /// Rate-limit window length in seconds
+        #[arg(long, value_name = "SECS", default_value_t = 60, requires = "rate_limit")]
+        rate_limit_window: u64,
+
+        /// Stop simulating a rate limit
+        #[arg(long, conflicts_with = "rate_limit")]
+        clear_rate_limit: bool,
+
+        /// Check each request body against the JSON Schema in this file
+        #[arg(long, value_name = "PATH")]
+        schema_file: Option<String>,
+
+        /// Answer bodies that fail the schema with this status (400-599) instead of the mock
+        #[arg(long, value_name = "STATUS", requires = "schema_file")]
+        schema_failure_status: Option<u16>,
+
+        /// Body for the failure reply (default: the validation errors as JSON)
+        #[arg(long, value_name = "BODY", requires = "schema_failure_status")]
+        schema_failure_body: Option<String>,
+
+        /// Stop checking bodies against a schema
+        #[arg(long, conflicts_with = "schema_file")]
+        clear_schema_validation: bool,
+
+        /// Replace matches with [REDACTED] before storing: email, credit_card, bearer_token,
+        /// path:<body.path> or pattern:<regex> (repeatable; replaces the current rules)
+        #[arg(long = "redact", value_name = "RULE")]
+        redact: Vec<String>,
+
+        /// Hide this header's whole value on top of authorization, proxy-authorization,
+        /// cookie and x-api-key (repeatable; replaces the current rules)
+        #[arg(long = "redact-header", value_name = "NAME")]
+        redact_headers: Vec<String>,
+
+        /// Store this header as sent, even if hidden by default (repeatable)
+        #[arg(long = "keep-header", value_name = "NAME")]
+        keep_headers: Vec<String>,
+
+        /// Drop the endpoint's rules (default headers stay hidden)
+        #[arg(long, conflicts_with_all = ["redact", "redact_headers", "keep_headers"])]
+        clear_redaction: bool,
+
+        /// Capture every request to this hostname (Pro; point its DNS at the receiver first)
+        #[arg(long, value_name = "HOST")]
+        custom_domain: Option<String>,
+
+        /// Stop capturing on the custom domain
+        #[arg(long, conflicts_with = "custom_domain")]
+        clear_custom_domain: bool,
+
+        /// Capture bodies over this many bytes cut short instead of in full (1024 up to the plan's limit)
+        #[arg(long, value_name = "BYTES")]
+        max_body_size: Option<u32>,
+
+        /// Use the plan's body size limit again
+        #[arg(long, conflicts_with = "max_body_size")]
+        clear_max_body_size: bool,
+    },
+
+    /// Stop capturing on an endpoint until it is resumed
+    Pause {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// Status code to reply with while paused (default: 503 "paused" error)
+        #[arg(long)]
+        status: Option<u16>,
+
+        /// Response body to reply with while paused
+        #[arg(long, requires = "status")]
+        body: Option<String>,
+    },
+
+    /// Resume capturing on a paused endpoint
+    Resume {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
     },
 
     /// Delete an endpoint
     Delete {
-        /// Endpoint slug
-        slug: String,
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
 
         /// Skip confirmation prompt
         #[arg(short, long)]
@@ -143,24 +511,67 @@ pub enum Command {
 
     /// Stream incoming requests to terminal
     Listen {
-        /// Endpoint slug to listen on
-        slug: String,
+        /// Endpoint slug to listen on (pick interactively if omitted)
+        slug: Option<String>,
+    },
+
+    /// Open the TUI, optionally straight into an endpoint or request
+    Tui {
+        /// Open this endpoint's detail view
+        #[arg(long, value_name = "SLUG", conflicts_with = "request")]
+        endpoint: Option<String>,
+
+        /// Stream the endpoint's requests live instead of showing its detail
+        #[arg(long, requires = "endpoint")]
+        live: bool,
+
+        /// Open this request's detail view
+        #[arg(long, value_name = "ID")]
+        request: Option<String>,
+    },
+
+    /// Watch an endpoint for configuration changes (mock, forwarding, expiry)
+    Watch {
+        /// Endpoint slug to watch (pick interactively if omitted)
+        slug: Option<String>,
     },
 
     /// Replay a captured request
     Replay {
-        /// Request ID to replay
-        id: String,
+        /// Request ID to replay (pick interactively if omitted)
+        id: Option<String>,
 
         /// Target URL (default: http://localhost:8080)
         #[arg(long, default_value = "http://localhost:8080")]
         to: String,
     },
 
+    /// Attach a note and tags to a captured request
+    Annotate {
+        /// Request ID (pick interactively if omitted)
+        id: Option<String>,
+
+        /// Note to attach (replaces the current one)
+        #[arg(short = 'm', long = "message")]
+        message: Option<String>,
+
+        /// Remove the note
+        #[arg(long)]
+        clear_note: bool,
+
+        /// Add a tag (repeatable)
+        #[arg(short = 't', long = "tag", value_name = "TAG")]
+        tags: Vec<String>,
+
+        /// Remove a tag (repeatable)
+        #[arg(long = "untag", value_name = "TAG")]
+        untags: Vec<String>,
+    },
+
     /// Send a test webhook to an endpoint
     Send {
-        /// Endpoint slug
-        slug: String,
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
 
         /// HTTP method (default: POST)
         #[arg(long, default_value = "POST")]
@@ -173,6 +584,9 @@ pub enum Command {
         /// Request body (string or @file)
         #[arg(short = 'd', long = "data")]
         data: Option<String>,
+
+        #[command(flatten)]
+        retry: RetryStormArgs,
     },
 
     /// Send a webhook to an arbitrary URL
@@ -192,6 +606,15 @@ pub enum Command {
         /// Request body (string or @file)
         #[arg(short = 'd', long = "data")]
         data: Option<String>,
+
+        #[command(flatten)]
+        retry: RetryStormArgs,
+    },
+
+    /// Manage an endpoint's mock response
+    Mock {
+        #[command(subcommand)]
+        action: MockAction,
     },
 
     /// Manage captured requests
@@ -200,9 +623,98 @@ pub enum Command {
         action: RequestsAction,
     },
 
+    /// Reconcile endpoints against a declarative YAML or JSON file
+    Apply {
+        /// Path to the endpoints file ("-" reads stdin)
+        #[arg(short, long, value_name = "FILE")]
+        file: String,
+
+        /// Show the plan without changing anything
+        #[arg(long)]
+        dry_run: bool,
+
+        /// Delete endpoints that are not in the file (ephemeral endpoints are kept)
+        #[arg(long)]
+        prune: bool,
+
+        /// Apply without asking for confirmation
+        #[arg(short, long)]
+        yes: bool,
+    },
+
+    /// Share read-only access to an endpoint's requests
+    Share {
+        #[command(subcommand)]
+        action: ShareAction,
+    },
+
+    /// List teams and their members
+    Teams {
+        #[command(subcommand)]
+        action: TeamsAction,
+    },
+
     /// Show usage and quota info
     Usage,
 
+    /// Show today's API calls, stream minutes, replays and exports against account limits
+    ApiUsage,
+
+    /// Show account activity: new endpoints, quota warnings, forwarding failures, team members
+    Activity {
+        /// Keep polling and print new events as they happen
+        #[arg(short, long)]
+        follow: bool,
+
+        /// Number of recent events to show
+        #[arg(long, default_value = "25")]
+        limit: u32,
+
+        /// Only show events of this type
+        #[arg(long = "type", value_name = "TYPE", value_parser = [
+            "endpoint_created", "quota_warning", "forwarding_failure", "team_member_joined"
+        ])]
+        event_type: Option<String>,
+    },
+
+    /// Measure pipeline performance against an endpoint
+    Bench {
+        #[command(subcommand)]
+        action: BenchAction,
+    },
+
+    /// Show function sink delivery latency per destination (relay vs destination)
+    Latency {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// Time window to summarise
+        #[arg(long, default_value = "24h", value_parser = ["1h", "24h", "7d"])]
+        window: String,
+
+        /// Print Prometheus text format instead, e.g. for a textfile collector
+        #[arg(long)]
+        prometheus: bool,
+    },
+
+    /// Monthly usage report for an endpoint: volumes, sizes, senders, quota and failures
+    Report {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// Calendar month in UTC (YYYY-MM); defaults to last month
+        #[arg(long, value_name = "YYYY-MM")]
+        month: Option<String>,
+
+        /// Write the report in this format instead of printing a summary
+        #[arg(long)]
+        format: Option<ReportFormat>,
+
+        /// Output file (stdout if omitted; required for pdf)
+        #[arg(short, long)]
+        output: Option<String>,
+    },
+
     /// Update whk to the latest version
     Update,
 
@@ -211,6 +723,33 @@ pub enum Command {
         /// Shell type
         shell: clap_complete::Shell,
     },
+
+    /// Print completion candidates (used by the shell completion scripts)
+    #[command(name = "__complete", hide = true)]
+    Complete {
+        kind: complete::CompleteKind,
+    },
+}
+
+#[derive(Subcommand, Debug)]
+pub enum BenchAction {
+    /// Send a burst of marked requests and time each one's arrival on the live stream
+    Stream {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// Number of requests to send
+        #[arg(short = 'n', long, default_value_t = 50, value_parser = clap::value_parser!(u32).range(1..=1000))]
+        count: u32,
+
+        /// Requests in flight at once
+        #[arg(short = 'c', long, default_value_t = 5, value_parser = clap::value_parser!(u32).range(1..=50))]
+        concurrency: u32,
+
+        /// Seconds to wait for deliveries before counting the rest as lost
+        #[arg(long, default_value_t = 30)]
+        timeout: u64,
+    },
 }
 
 #[derive(Subcommand, Debug)]
@@ -224,12 +763,110 @@ pub enum AuthAction {
 }
 
 #[derive(Subcommand, Debug)]
-pub enum RequestsAction {
-    /// List captured requests for an endpoint
+pub enum MockAction {
+    /// Fetch a real response and save it (status, headers, body) as the mock
+    Import {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// URL whose response becomes the mock
+        #[arg(long = "from-url", value_name = "URL")]
+        from_url: String,
+
+        /// HTTP method for the fetch (default: GET)
+        #[arg(long, default_value = "GET")]
+        method: String,
+
+        /// Request header for the fetch (repeatable)
+        #[arg(short = 'H', long = "header", value_name = "KEY:VALUE")]
+        headers: Vec<String>,
+
+        /// Request body for the fetch (string or @file)
+        #[arg(short = 'd', long = "data")]
+        data: Option<String>,
+
+        /// Show the resulting mock without saving it
+        #[arg(long)]
+        dry_run: bool,
+    },
+    /// Show the endpoint's configuration history (mock, forwarding, transforms)
+    History {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// Restore this version
+        #[arg(long, value_name = "VERSION")]
+        rollback: Option<u32>,
+    },
+    /// Show the running mock canary with requests and senders per version
+    Canary {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// Make the canary's response the mock for every sender
+        #[arg(long, conflicts_with = "rollback")]
+        promote: bool,
+
+        /// Stop the canary; every sender gets the stable mock again
+        #[arg(long)]
+        rollback: bool,
+    },
+}
+
+#[derive(Subcommand, Debug)]
+pub enum ShareAction {
+    /// Create a read-only share token for an endpoint
+    Create {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// Token lifetime (e.g. "1h", "24h", "7d"; max 30d)
+        #[arg(long, default_value = "24h")]
+        expires: String,
+    },
+    /// List share tokens for an endpoint
     List {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+    },
+    /// Revoke a share token
+    Revoke {
         /// Endpoint slug
         slug: String,
 
+        /// Share token ID (from `whk share list`)
+        id: String,
+    },
+    /// Tail requests of an endpoint shared with you (no login required)
+    Tail {
+        /// Share token (whsh_...)
+        #[arg(env = "WHK_SHARE_TOKEN")]
+        token: String,
+
+        /// Number of recent requests to show before tailing
+        #[arg(long, default_value = "10")]
+        limit: u32,
+    },
+}
+
+#[derive(Subcommand, Debug)]
+pub enum TeamsAction {
+    /// List teams you belong to and your role in each
+    List,
+    /// List members and pending invites of a team
+    Members {
+        /// Team ID or name (defaults to --team)
+        team: Option<String>,
+    },
+}
+
+#[derive(Subcommand, Debug)]
+pub enum RequestsAction {
+    /// List captured requests for an endpoint
+    List {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
         /// Maximum number of requests to return
         #[arg(long, default_value = "25")]
         limit: u32,
@@ -241,12 +878,85 @@ pub enum RequestsAction {
         /// Cursor for pagination
         #[arg(long)]
         cursor: Option<String>,
+
+        /// Ignore the local cache and refetch from the API
+        #[arg(long)]
+        refresh: bool,
+
+        /// Show identical requests (e.g. provider retries) as one line
+        #[arg(long)]
+        collapse: bool,
+
+        /// With --collapse, also fold JSON bodies that differ only in key order, whitespace or number format
+        #[arg(long, requires = "collapse")]
+        canonical: bool,
+
+        /// Show requests of the same kind (method, path shape and body shape) as one line
+        #[arg(long, conflicts_with = "collapse")]
+        group: bool,
+
+        /// Only requests carrying this tag
+        #[arg(long)]
+        tag: Option<String>,
+
+        /// Only CloudEvents of this type (e.g. com.example.order.created)
+        #[arg(long, value_name = "TYPE")]
+        event_type: Option<String>,
+
+        /// Only requests from this country (two-letter code, e.g. DE)
+        #[arg(long, value_name = "CODE")]
+        country: Option<String>,
+
+        /// Only requests from this autonomous system (e.g. 13335 or AS13335)
+        #[arg(long)]
+        asn: Option<String>,
     },
 
     /// Get a single request by ID
     Get {
-        /// Request ID
-        id: String,
+        /// Request ID (pick interactively if omitted)
+        id: Option<String>,
+
+        /// Ignore the local cache and refetch from the API
+        #[arg(long)]
+        refresh: bool,
+
+        /// Show every attempt at the same delivery (shared delivery ID or
+        /// duplicate body), the time between them and what changed
+        #[arg(long)]
+        follow_chain: bool,
+
+        /// Header carrying the delivery ID (default: webhook-id, svix-id,
+        /// x-github-delivery, x-shopify-webhook-id or x-gitlab-event-uuid)
+        #[arg(long, value_name = "NAME", requires = "follow_chain")]
+        delivery_id_header: Option<String>,
+    },
+
+    /// Download a body kept in object storage (bodies over the inline limit)
+    Body {
+        /// Request ID (pick interactively if omitted)
+        id: Option<String>,
+
+        /// Write the body to this file instead of printing its download URL
+        #[arg(short, long)]
+        output: Option<String>,
+    },
+
+    /// Compare two captured requests field by field
+    Diff {
+        /// First request ID
+        a: String,
+
+        /// Second request ID
+        b: String,
+
+        /// Ignore the local cache and refetch from the API
+        #[arg(long)]
+        refresh: bool,
+
+        /// Compare JSON bodies in canonical form (numbers like 1.0 and 1 are equal)
+        #[arg(long)]
+        canonical: bool,
     },
 
     /// Search across all retained requests
@@ -271,6 +981,10 @@ pub enum RequestsAction {
         #[arg(long)]
         to: Option<String>,
 
+        /// Only bodies of this class
+        #[arg(long, value_name = "CLASS", value_parser = ["json", "xml", "form", "text", "image", "protobuf", "binary"])]
+        content_class: Option<String>,
+
         /// Max results
         #[arg(long, default_value = "50")]
         limit: u32,
@@ -296,12 +1010,36 @@ pub enum RequestsAction {
         from: Option<String>,
         #[arg(long)]
         to: Option<String>,
+        #[arg(long, value_name = "CLASS", value_parser = ["json", "xml", "form", "text", "image", "protobuf", "binary"])]
+        content_class: Option<String>,
+    },
+
+    /// Count requests over a time window, optionally grouped, with size percentiles and a sparkline
+    Stats {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// Group by method, path, header:<name>, query:<name>, provider, event-type, content-class, ip, country or asn
+        #[arg(long, value_name = "FIELD")]
+        group_by: Option<String>,
+
+        /// Window to aggregate, counting back from now (e.g. "1h", "7d")
+        #[arg(long, default_value = "24h")]
+        since: String,
+
+        /// Maximum number of requests to fetch, newest first
+        #[arg(long, default_value = "5000")]
+        limit: u32,
+
+        /// Number of groups to show
+        #[arg(long, default_value = "20")]
+        top: usize,
     },
 
     /// Delete captured requests
     Clear {
-        /// Endpoint slug
-        slug: String,
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
 
         /// Only clear requests before this time (timestamp or duration)
         #[arg(long)]
@@ -314,8 +1052,8 @@ pub enum RequestsAction {
 
     /// Export requests as HAR or cURL
     Export {
-        /// Endpoint slug
-        slug: String,
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
 
         /// Export format
         #[arg(long)]
@@ -329,9 +1067,31 @@ pub enum RequestsAction {
         #[arg(long)]
         since: Option<i64>,
 
-        /// Output file (stdout if omitted)
+        /// Output file (stdout if omitted; required for parquet)
         #[arg(short, long)]
         output: Option<String>,
+
+        /// Header to add as a column in csv/parquet output (repeatable)
+        #[arg(long = "header", value_name = "NAME")]
+        headers: Vec<String>,
+
+        /// Export even when fields are masked, keeping the placeholders
+        #[arg(long)]
+        allow_masked: bool,
+    },
+
+    /// List requests the network policy rejected (needs --capture-rejected true)
+    Rejected {
+        /// Endpoint slug (pick interactively if omitted)
+        slug: Option<String>,
+
+        /// Maximum number of requests to show (1-1000)
+        #[arg(long, default_value = "50", value_parser = clap::value_parser!(u32).range(1..=1000))]
+        limit: u32,
+
+        /// Only show requests after this timestamp (ms)
+        #[arg(long)]
+        since: Option<i64>,
     },
 }
 
@@ -339,4 +1099,46 @@ pub enum RequestsAction {
 pub enum ExportFormat {
     Har,
     Curl,
+    /// One row per request: time, method, path and selected headers as columns
+    Csv,
+    /// The csv columns as a Parquet file
+    Parquet,
+}
+
+#[derive(Debug, Clone, clap::ValueEnum)]
+pub enum ReportFormat {
+    /// The report as returned by the API
+    Json,
+    /// One row per total, day, method, sender and failure count
+    Csv,
+    /// The printed summary as a PDF document
+    Pdf,
+}
+
+/// Provider-style redelivery simulation shared by `send` and `send-to`.
+#[derive(clap::Args, Debug, Clone)]
+pub struct RetryStormArgs {
+    /// Redeliver the webhook this many times, like a provider retrying a failed delivery
+    #[arg(long, value_name = "N", default_value = "0", value_parser = clap::value_parser!(u32).range(0..=20))]
+    pub retries: u32,
+
+    /// Delay before the first retry in milliseconds; doubles on each attempt
+    #[arg(long, value_name = "MS", default_value = "1000")]
+    pub retry_base: u64,
+
+    /// Random jitter applied to each retry delay, as a percentage
+    #[arg(long, value_name = "PCT", default_value = "20", value_parser = clap::value_parser!(u8).range(0..=100))]
+    pub retry_jitter: u8,
+
+    /// Extra copies sent within a few hundred milliseconds of each attempt
+    #[arg(long, value_name = "N", default_value = "0", value_parser = clap::value_parser!(u32).range(0..=5))]
+    pub duplicates: u32,
+
+    /// Header carrying the delivery ID shared by every attempt and duplicate
+    #[arg(long, value_name = "NAME", default_value = "webhook-id")]
+    pub delivery_id_header: String,
+
+    /// Stop retrying once a delivery gets a 2xx response
+    #[arg(long)]
+    pub stop_on_success: bool,
 }
@@ -1,13 +1,15 @@
 use std::sync::atomic::{AtomicBool, Ordering};
 
-use crate::types::{CapturedRequest, Endpoint, UsageInfo};
-use crate::util::format::{format_bytes, format_timestamp};
+use crate::types::{ApiUsage, ApiUsageMeter, CapturedRequest, Endpoint, ShareToken, Team, TeamMemberList, UsageInfo};
+use crate::util::format::{format_bytes, format_origin, format_sizes, format_timestamp, format_tls};
+use crate::util::body::display_body;
+use crate::util::provider::provider;
 
 static NO_COLOR: AtomicBool = AtomicBool::new(false);
 
 /// Strip ANSI control characters from untrusted text to prevent terminal injection.
 /// Preserves normal whitespace (space, tab, newline, carriage return).
-fn sanitize(s: &str) -> String {
+pub fn sanitize(s: &str) -> String {
     s.chars()
         .filter(|c| !c.is_control() || *c == '\n' || *c == '\r' || *c == '\t' || *c == ' ')
         .collect()
@@ -37,6 +39,10 @@ pub fn red(s: &str) -> String {
     if no_color() { s.to_string() } else { format!("\x1b[31m{s}\x1b[0m") }
 }
 
+pub fn yellow(s: &str) -> String {
+    if no_color() { s.to_string() } else { format!("\x1b[33m{s}\x1b[0m") }
+}
+
 pub fn method_color(method: &str) -> String {
     if no_color() {
         return method.to_string();
@@ -71,15 +77,133 @@ pub fn print_endpoint_table(endpoints: &[Endpoint], webhook_url: &str) {
         } else {
             String::new()
         };
-        println!("  {:<20} {:<20} {:<16} {}", bold(&slug), dim(&name), dim(&team), dim(&url));
+        let paused = if ep.paused_at.is_some() { format!(" {}", yellow("paused")) } else { String::new() };
+        println!("  {:<20} {:<20} {:<16} {}{}", bold(&slug), dim(&name), dim(&team), dim(&url), paused);
+    }
+}
+
+pub fn print_share_table(shares: &[ShareToken]) {
+    if shares.is_empty() {
+        println!("  No share tokens. Create one with {}", bold("whk share create <slug>"));
+        return;
+    }
+    println!(
+        "  {:<38} {:<16} {:<20} {}",
+        dim("ID"), dim("TOKEN"), dim("EXPIRES"), dim("LAST USED"),
+    );
+    for share in shares {
+        let expires = share.expires_at.map(format_timestamp).unwrap_or_else(|| "never".into());
+        let last_used = share.last_used_at.map(format_timestamp).unwrap_or_else(|| "-".into());
+        println!(
+            "  {:<38} {:<16} {:<20} {}",
+            share.id,
+            format!("{}…", share.token_prefix),
+            expires,
+            dim(&last_used)
+        );
+    }
+}
+
+pub fn print_team_table(teams: &[Team]) {
+    if teams.is_empty() {
+        println!("  You are not a member of any team.");
+        return;
+    }
+    println!(
+        "  {:<24} {:<10} {:<8} {}",
+        dim("NAME"), dim("ROLE"), dim("MEMBERS"), dim("ID"),
+    );
+    for team in teams {
+        let name = sanitize(&team.name);
+        let role = if team.suspended {
+            format!("{} (suspended)", team.role)
+        } else {
+            team.role.clone()
+        };
+        println!(
+            "  {:<24} {:<10} {:<8} {}",
+            bold(&name), role, team.member_count, dim(&team.id)
+        );
+    }
+}
+
+pub fn print_team_members(team: &Team, list: &TeamMemberList) {
+    println!("{} {}", bold(&sanitize(&team.name)), dim(&format!("(you are {})", team.role)));
+    println!("  {:<32} {:<20} {}", dim("EMAIL"), dim("NAME"), dim("ROLE"));
+    for m in &list.members {
+        let name = sanitize(m.name.as_deref().unwrap_or("-"));
+        println!("  {:<32} {:<20} {}", sanitize(&m.email), dim(&name), m.role);
+    }
+    if !list.pending_invites.is_empty() {
+        println!("\n{}", bold("Pending invites"));
+        for invite in &list.pending_invites {
+            println!("  {}", sanitize(&invite.invited_email));
+        }
     }
 }
 
 pub fn print_request_line(req: &CapturedRequest) {
+    println!("{}", request_line(req));
+}
+
+/// A request line standing for `count` identical requests.
+pub fn print_collapsed_request_line(req: &CapturedRequest, count: usize) {
+    if count > 1 {
+        println!("{} {}", request_line(req), yellow(&format!("×{count} identical")));
+    } else {
+        println!("{}", request_line(req));
+    }
+}
+
+/// The newest request of a group of the same kind, with the group's size.
+pub fn print_grouped_request_line(req: &CapturedRequest, count: usize) {
+    if count > 1 {
+        println!("{} {}", request_line(req), yellow(&format!("×{count} similar")));
+    } else {
+        println!("{}", request_line(req));
+    }
+}
+
+fn request_line(req: &CapturedRequest) -> String {
     let time = format_timestamp(req.received_at);
     let method = method_color(&req.method);
     let size = format_bytes(req.size);
-    println!("  {} {} {} {}", dim(&time), method, sanitize(&req.path), dim(&size));
+    let mut line = format!("  {} {} {} {}", dim(&time), method, sanitize(&req.path), dim(&size));
+    if let Some((name, event_type)) = provider(req) {
+        let label = match event_type {
+            Some(event_type) => format!("{name} {event_type}"),
+            None => name,
+        };
+        line.push_str(&format!(" {}", dim(&sanitize(&label))));
+    }
+    if req.priority {
+        line.push_str(&red(" ⚡"));
+    }
+    if !req.tags.is_empty() {
+        line.push(' ');
+        line.push_str(&tag_list(&req.tags));
+    }
+    if req.note.is_some() {
+        line.push_str(&dim(" ✎"));
+    }
+    if !req.masked.is_empty() {
+        line.push_str(&yellow(" [masked]"));
+    }
+    line
+}
+
+/// A header, trailer or query value, marked with the reason when it's masked.
+fn field_value(req: &CapturedRequest, field: &str, value: &str) -> String {
+    match req.mask(field) {
+        Some(reason) => format!("{} {}", yellow(&sanitize(value)), dim(&format!("({})", sanitize(reason)))),
+        None => sanitize(value),
+    }
+}
+
+/// Tags rendered as `#tag #other`.
+pub fn tag_list(tags: &[String]) -> String {
+    let joined: Vec<String> = tags.iter().map(|t| format!("#{}", sanitize(t))).collect();
+    yellow(&joined.join(" "))
 }
 
 pub fn print_request_detail(req: &CapturedRequest) {
@@ -87,8 +211,134 @@ pub fn print_request_detail(req: &CapturedRequest) {
     println!("  {} {}", dim("ID:"), sanitize(&req.id));
     println!("  {} {} {}", dim("Method:"), method_color(&req.method), sanitize(&req.path));
     println!("  {} {}", dim("IP:"), sanitize(&req.ip));
+    if let Some(origin) = format_origin(req) {
+        println!("  {} {}", dim("Origin:"), sanitize(&origin));
+    }
+    if let Some((name, event_type)) = provider(req) {
+        match event_type {
+            Some(event_type) => println!("  {} {} {}", dim("Provider:"), sanitize(&name), bold(&sanitize(&event_type))),
+            None => println!("  {} {}", dim("Provider:"), sanitize(&name)),
+        }
+    }
+    if req.priority {
+        println!("  {} {}", dim("Priority:"), red("high"));
+    }
+    if let Some(ref version) = req.http_version {
+        println!("  {} {}", dim("Protocol:"), sanitize(version));
+    }
+    if let Some(ref tls) = req.tls {
+        println!("  {} {}", dim("TLS:"), sanitize(&format_tls(tls)));
+    }
+    match req.signature_valid {
+        Some(true) => println!("  {} {}", dim("Signature:"), green("valid")),
+        Some(false) => println!("  {} {}", dim("Signature:"), red("invalid")),
+        None => {}
+    }
+    if let Some(valid) = req.jwt_valid {
+        let verdict = if valid { green("valid") } else { red("invalid") };
+        let subject = req
+            .jwt_claims
+            .as_ref()
+            .and_then(|claims| claims.get("sub"))
+            .and_then(|sub| sub.as_str())
+            .map(|sub| format!(" (sub {})", sanitize(sub)))
+            .unwrap_or_default();
+        println!("  {} {}{}", dim("JWT:"), verdict, subject);
+    }
+    if let Some(valid) = req.schema_valid {
+        let verdict = if valid { green("valid") } else { red("invalid") };
+        println!("  {} {}", dim("Schema:"), verdict);
+        for error in req.schema_errors.iter().flatten() {
+            let path = if error.path.is_empty() { "/" } else { &error.path };
+            println!("    {} {}", dim(&sanitize(path)), sanitize(&error.message));
+        }
+    }
+    if let Some(ref redactions) = req.redactions {
+        let rules: Vec<String> = redactions
+            .iter()
+            .map(|(rule, count)| format!("{} ({count})", sanitize(rule)))
+            .collect();
+        println!("  {} {}", dim("Redacted:"), rules.join(", "));
+    }
+    if !req.masked.is_empty() {
+        let fields: Vec<String> = req
+            .masked
+            .iter()
+            .map(|(field, reason)| format!("{} ({})", sanitize(field), sanitize(reason)))
+            .collect();
+        println!("  {} {}", dim("Masked:"), yellow(&fields.join(", ")));
+        if req.has_encrypted() {
+            println!("    {}", dim("Encrypted values open with --reveal for users with access to the endpoint"));
+        }
+    }
+    if let Some(ref cert) = req.client_cert {
+        let verified = if cert.verified { green(" (verified)") } else { String::new() };
+        println!("  {} {}{}", dim("Client cert:"), sanitize(&cert.subject), verified);
+        println!("  {} {}", dim("Issuer:"), sanitize(&cert.issuer));
+        println!("  {} {}", dim("SHA-256:"), sanitize(&cert.fingerprint));
+    }
     println!("  {} {}", dim("Size:"), format_bytes(req.size));
+    if let Some(ref sizes) = req.sizes {
+        println!("  {} {}", dim("Sizes:"), sanitize(&format_sizes(sizes)));
+    }
     println!("  {} {}", dim("Time:"), format_timestamp(req.received_at));
+    if let Some(ref original) = req.duplicate_of {
+        println!("  {} {}", dim("Duplicate of:"), sanitize(original));
+    }
+    if let Some(ref variant) = req.mock_variant {
+        println!("  {} {}", dim("Mock variant:"), sanitize(variant));
+    }
+    if let Some(ref version) = req.mock_version {
+        println!("  {} {}", dim("Mock version:"), sanitize(version));
+    }
+    if let Some(ref fault) = req.chaos_fault {
+        println!("  {} {}", dim("Chaos fault:"), sanitize(fault));
+    }
+    if let Some(ref overrides) = req.response_override {
+        let mut parts = Vec::new();
+        if let Some(status) = overrides.status {
+            parts.push(format!("status {status}"));
+        }
+        if let Some(delay) = overrides.delay {
+            parts.push(format!("delay {delay}ms"));
+        }
+        println!("  {} {}", dim("Overrides:"), parts.join(", "));
+    }
+    if let Some(ref frame) = req.frame {
+        println!(
+            "  {} #{} {} on connection {}",
+            dim("WebSocket:"),
+            frame.seq,
+            sanitize(&frame.opcode),
+            sanitize(&frame.connection)
+        );
+    }
+    if let Some(ref event) = req.cloud_event {
+        let mut line = format!("{} from {} (id {})", sanitize(&event.event_type), sanitize(&event.source), sanitize(&event.id));
+        if let Some(ref subject) = event.subject {
+            line.push_str(&format!(", subject {}", sanitize(subject)));
+        }
+        println!("  {} {}", dim("CloudEvent:"), line);
+    }
+    if let Some(ref response) = req.response {
+        let mut sent = format!("{} ({}, {})", response.status, sanitize(&response.source), format_bytes(response.body_size));
+        if let Some(delay) = response.delay_ms {
+            sent.push_str(&format!(" after {delay}ms"));
+        }
+        if let Some(latency) = response.latency_ms {
+            sent.push_str(&format!(", backend answered in {latency}ms"));
+        }
+        if let Some(ref error) = response.error {
+            sent.push_str(&format!(", backend failed: {}", sanitize(error)));
+        }
+        println!("  {} {}", dim("Response:"), sent);
+    }
+    if !req.tags.is_empty() {
+        println!("  {} {}", dim("Tags:"), tag_list(&req.tags));
+    }
+    if let Some(ref note) = req.note {
+        println!("  {} {}", dim("Note:"), sanitize(note));
+    }
 
     if let Some(ref ct) = req.content_type {
         println!("  {} {}", dim("Content-Type:"), sanitize(ct));
@@ -97,7 +347,7 @@ pub fn print_request_detail(req: &CapturedRequest) {
     if !req.query_params.is_empty() {
         println!("\n{}", bold("Query Parameters"));
         for (k, v) in &req.query_params {
-            println!("  {} = {}", bold(&sanitize(k)), sanitize(v));
+            println!("  {} = {}", bold(&sanitize(k)), field_value(req, &format!("query:{k}"), v));
         }
     }
 
@@ -106,18 +356,72 @@ pub fn print_request_detail(req: &CapturedRequest) {
         let mut headers: Vec<_> = req.headers.iter().collect();
         headers.sort_by_key(|(k, _)| k.to_lowercase());
         for (k, v) in headers {
-            println!("  {}: {}", bold(&sanitize(k)), sanitize(v));
+            println!("  {}: {}", bold(&sanitize(k)), field_value(req, &format!("header:{k}"), v));
         }
     }
 
-    if let Some(ref body) = req.body {
+    if !req.parts.is_empty() {
+        println!("\n{}", bold("Parts"));
+        for part in &req.parts {
+            let name = part.name.as_deref().map(sanitize).unwrap_or_else(|| "-".into());
+            let mut details = vec![format_bytes(part.size)];
+            if let Some(ref filename) = part.filename {
+                details.insert(0, sanitize(filename));
+            }
+            if let Some(ref ct) = part.content_type {
+                details.push(sanitize(ct));
+            }
+            println!("  {} {}", bold(&name), dim(&details.join(", ")));
+            if let Some(ref body) = part.body {
+                println!("    {}", sanitize(body));
+            }
+        }
+    }
+
+    if req.body_ref.is_some() {
         println!("\n{}", bold("Body"));
-        let sanitized_body = sanitize(body);
-        if let Ok(val) = serde_json::from_str::<serde_json::Value>(&sanitized_body) {
-            println!("{}", serde_json::to_string_pretty(&val).unwrap_or(sanitized_body));
+        println!(
+            "  {} {}",
+            dim("Kept in object storage; download with"),
+            bold(&format!("whk requests body {} -o FILE", sanitize(&req.id)))
+        );
+    } else if let Some(body) =
+        display_body(req.content_class.as_deref(), req.body_raw.as_deref(), req.body.as_deref())
+    {
+        match req.content_class {
+            Some(ref class) => println!("\n{} {}", bold("Body"), dim(&sanitize(class))),
+            None => println!("\n{}", bold("Body")),
+        }
+        if let Some(reason) = req.mask("body") {
+            println!("  {}", yellow(&format!("Parts of the body are {}", sanitize(reason))));
+        }
+        println!("{}", sanitize(&body));
+    }
+
+    if !req.trailers.is_empty() {
+        println!("\n{}", bold("Trailers"));
+        let mut trailers: Vec<_> = req.trailers.iter().collect();
+        trailers.sort_by_key(|(k, _)| k.to_lowercase());
+        for (k, v) in trailers {
+            println!("  {}: {}", bold(&sanitize(k)), field_value(req, &format!("trailer:{k}"), v));
+        }
+    }
+
+    // Proxied captures keep the forward URL's reply
+    if let Some(response) = req.response.as_ref().filter(|r| r.body.is_some()) {
+        let mut notes = Vec::new();
+        if response.body_encoding.is_some() {
+            notes.push("base64");
+        }
+        if response.body_truncated {
+            notes.push("first 64KB");
+        }
+        if notes.is_empty() {
+            println!("\n{}", bold("Response Body"));
         } else {
-            println!("{sanitized_body}");
+            println!("\n{} {}", bold("Response Body"), dim(&notes.join(", ")));
         }
+        println!("{}", sanitize(response.body.as_deref().unwrap_or_default()));
     }
 }
 
@@ -132,3 +436,26 @@ pub fn print_usage(usage: &UsageInfo) {
         println!("  {} {}", dim("Period ends:"), format_timestamp(pe));
     }
 }
+
+pub fn print_api_usage(usage: &ApiUsage) {
+    println!("{} {}", bold("API usage"), dim(&format!("({} UTC)", usage.day)));
+    println!("  {} {}", dim("Plan:"), usage.plan);
+    print_api_meter("API calls:", &usage.api_calls);
+    print_api_meter("Stream minutes:", &usage.stream_minutes);
+    print_api_meter("Replays:", &usage.replays);
+    print_api_meter("Exports:", &usage.exports);
+    println!("  {} {}", dim("Resets:"), format_timestamp(usage.resets_at));
+}
+
+/// One allowance, flagged once 80% of it is used.
+fn print_api_meter(label: &str, meter: &ApiUsageMeter) {
+    let line = format!("{}/{} ({} remaining)", meter.used, meter.limit, meter.remaining);
+    let line = if meter.remaining == 0 {
+        red(&line)
+    } else if meter.used * 5 >= meter.limit * 4 {
+        yellow(&line)
+    } else {
+        line
+    };
+    println!("  {} {}", dim(&format!("{label:<15}")), line);
+}
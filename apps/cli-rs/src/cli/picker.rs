@@ -0,0 +1,220 @@
+//! Inline fuzzy picker used when a required slug or request ID is omitted.
+//!
+//! Renders on stderr so stdout stays clean for piping. Type to filter, arrow
+//! keys to move, Enter to choose, Esc or Ctrl+C to cancel.
+
+use anyhow::{bail, Result};
+use crossterm::event::{self, Event, KeyCode, KeyEventKind, KeyModifiers};
+use crossterm::{cursor, execute, queue, terminal};
+use serde::{Deserialize, Serialize};
+use std::io::{IsTerminal, Write};
+
+use crate::cli::output::{bold, dim, sanitize};
+
+/// Rows shown at once.
+const VISIBLE_ROWS: usize = 10;
+
+/// One choice: the value returned and a label shown next to it.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PickItem {
+    pub value: String,
+    #[serde(default)]
+    pub label: String,
+}
+
+/// Whether an interactive picker can be shown (both stdin and stderr are terminals).
+pub fn is_interactive() -> bool {
+    std::io::stdin().is_terminal() && std::io::stderr().is_terminal()
+}
+
+/// Score `text` against `query` as a case-insensitive subsequence match.
+/// Higher is better; consecutive and early matches score more. `None` when
+/// `query` isn't a subsequence of `text`.
+pub fn fuzzy_score(query: &str, text: &str) -> Option<i64> {
+    if query.is_empty() {
+        return Some(0);
+    }
+    let text: Vec<char> = text.to_lowercase().chars().collect();
+    let mut score = 0i64;
+    let mut pos = 0usize;
+    let mut prev: Option<usize> = None;
+
+    for q in query.to_lowercase().chars() {
+        let found = text[pos..].iter().position(|&c| c == q)? + pos;
+        score += 10;
+        if prev.is_some_and(|p| p + 1 == found) {
+            score += 15;
+        }
+        if found == 0 || matches!(text[found - 1], '-' | '_' | '/' | ' ' | '.') {
+            score += 8;
+        }
+        score -= (found - pos) as i64;
+        prev = Some(found);
+        pos = found + 1;
+    }
+    Some(score)
+}
+
+/// Indexes of `items` matching `query`, best first (ties keep input order).
+pub fn filter(items: &[PickItem], query: &str) -> Vec<usize> {
+    let mut scored: Vec<(i64, usize)> = items
+        .iter()
+        .enumerate()
+        .filter_map(|(i, item)| {
+            let value = fuzzy_score(query, &item.value);
+            let label = fuzzy_score(query, &item.label).map(|s| s - 5);
+            value.max(label).map(|s| (s, i))
+        })
+        .collect();
+    scored.sort_by(|a, b| b.0.cmp(&a.0).then(a.1.cmp(&b.1)));
+    scored.into_iter().map(|(_, i)| i).collect()
+}
+
+/// Show the picker and return the chosen value. Errors when cancelled.
+pub fn pick(prompt: &str, items: &[PickItem]) -> Result<String> {
+    if items.is_empty() {
+        bail!("nothing to choose from");
+    }
+
+    terminal::enable_raw_mode()?;
+    let result = run(prompt, items);
+    let _ = terminal::disable_raw_mode();
+    match result? {
+        Some(i) => Ok(items[i].value.clone()),
+        None => bail!("cancelled"),
+    }
+}
+
+fn run(prompt: &str, items: &[PickItem]) -> Result<Option<usize>> {
+    let mut out = std::io::stderr();
+    let mut query = String::new();
+    let mut selected = 0usize;
+    let mut drawn = 0u16;
+
+    loop {
+        let matches = filter(items, &query);
+        selected = selected.min(matches.len().saturating_sub(1));
+        drawn = draw(&mut out, prompt, &query, items, &matches, selected, drawn)?;
+
+        let Event::Key(key) = event::read()? else { continue };
+        if key.kind != KeyEventKind::Press {
+            continue;
+        }
+        match key.code {
+            KeyCode::Enter => {
+                clear(&mut out, drawn)?;
+                return Ok(matches.get(selected).copied());
+            }
+            KeyCode::Esc => {
+                clear(&mut out, drawn)?;
+                return Ok(None);
+            }
+            KeyCode::Char('c') if key.modifiers.contains(KeyModifiers::CONTROL) => {
+                clear(&mut out, drawn)?;
+                return Ok(None);
+            }
+            KeyCode::Up => selected = selected.saturating_sub(1),
+            KeyCode::Down => selected = (selected + 1).min(matches.len().saturating_sub(1)),
+            KeyCode::Backspace => {
+                query.pop();
+                selected = 0;
+            }
+            KeyCode::Char(c) => {
+                query.push(c);
+                selected = 0;
+            }
+            _ => {}
+        }
+    }
+}
+
+/// Redraw the prompt and visible rows below the cursor; returns rows drawn.
+fn draw(
+    out: &mut std::io::Stderr,
+    prompt: &str,
+    query: &str,
+    items: &[PickItem],
+    matches: &[usize],
+    selected: usize,
+    previous: u16,
+) -> Result<u16> {
+    clear(out, previous)?;
+    let width = terminal::size().map(|(w, _)| w as usize).unwrap_or(80);
+
+    write!(out, "{} {}\r\n", bold(prompt), sanitize(query))?;
+    let start = selected.saturating_sub(VISIBLE_ROWS - 1);
+    let mut rows = 1u16;
+    for (row, &i) in matches.iter().enumerate().skip(start).take(VISIBLE_ROWS) {
+        let item = &items[i];
+        let line: String = format!("{}  {}", item.value, item.label)
+            .chars()
+            .take(width.saturating_sub(4))
+            .collect();
+        let line = sanitize(&line);
+        if row == selected {
+            write!(out, "{} {}\r\n", bold(">"), bold(&line))?;
+        } else {
+            write!(out, "  {}\r\n", dim(&line))?;
+        }
+        rows += 1;
+    }
+    if matches.is_empty() {
+        write!(out, "  {}\r\n", dim("no matches"))?;
+        rows += 1;
+    }
+    out.flush()?;
+    Ok(rows)
+}
+
+/// Move back over the last `rows` drawn lines and clear them.
+fn clear(out: &mut std::io::Stderr, rows: u16) -> Result<()> {
+    if rows > 0 {
+        queue!(out, cursor::MoveUp(rows))?;
+    }
+    execute!(
+        out,
+        cursor::MoveToColumn(0),
+        terminal::Clear(terminal::ClearType::FromCursorDown)
+    )?;
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn item(value: &str, label: &str) -> PickItem {
+        PickItem {
+            value: value.into(),
+            label: label.into(),
+        }
+    }
+
+    #[test]
+    fn test_fuzzy_score_subsequence() {
+        assert!(fuzzy_score("stp", "stripe-prod").is_some());
+        assert!(fuzzy_score("STR", "stripe").is_some());
+        assert!(fuzzy_score("xyz", "stripe").is_none());
+        assert_eq!(fuzzy_score("", "anything"), Some(0));
+    }
+
+    #[test]
+    fn test_fuzzy_score_prefers_consecutive_matches() {
+        let tight = fuzzy_score("stri", "stripe").unwrap();
+        let loose = fuzzy_score("stri", "s-t-r-i-p-e").unwrap();
+        assert!(tight > loose, "{tight} <= {loose}");
+    }
+
+    #[test]
+    fn test_filter_orders_best_first_and_matches_labels() {
+        let items = vec![
+            item("abc123", "Shopify orders"),
+            item("stripe-prod", "Stripe production"),
+            item("stripe-dev", "Stripe dev"),
+        ];
+        assert_eq!(filter(&items, "strdev"), vec![2]);
+        assert_eq!(filter(&items, "shop"), vec![0]);
+        assert_eq!(filter(&items, ""), vec![0, 1, 2]);
+        assert!(filter(&items, "zzz").is_empty());
+    }
+}
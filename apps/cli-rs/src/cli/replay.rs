@@ -10,6 +10,7 @@ const STRIP_HEADERS: &[&str] = &[
     "host",
     "connection",
     "content-length",
+    "expect",
     "transfer-encoding",
     "keep-alive",
     "te",
@@ -35,7 +36,10 @@ const STRIP_HEADERS: &[&str] = &[
 ];
 
 pub async fn run(client: &ApiClient, request_id: &str, target_url: &str, json: bool) -> Result<()> {
-    let req = client.get_request(request_id).await?;
+    let req = client.with_operation("replay").get_request(request_id).await?;
+    if req.frame.is_some() {
+        anyhow::bail!("{request_id} is a WebSocket message and can't be replayed over HTTP");
+    }
 
     let method: reqwest::Method = req.method.parse().unwrap_or(reqwest::Method::POST);
     let url = format!("{}{}", target_url.trim_end_matches('/'), req.path);
@@ -0,0 +1,254 @@
+use anyhow::{Context, Result};
+use chrono::{Datelike, Months, NaiveDate, Utc};
+use std::io::{self, IsTerminal, Write};
+
+use crate::api::ApiClient;
+use crate::cli::output::{bold, dim, green, sanitize};
+use crate::cli::ReportFormat;
+use crate::types::UsageReport;
+use crate::util::format::format_bytes;
+use crate::util::pdf;
+use crate::util::table::{self, Column, Values};
+
+/// Usage report for `month` (last month when omitted), printed as a summary
+/// or written in `format`.
+pub async fn run(
+    client: &ApiClient,
+    slug: &str,
+    month: Option<&str>,
+    format: Option<&ReportFormat>,
+    output: Option<&str>,
+    json: bool,
+) -> Result<()> {
+    let month = match month {
+        Some(month) => parse_month(month)?,
+        None => last_month(Utc::now().date_naive()),
+    };
+    if matches!(format, Some(ReportFormat::Pdf)) && output.is_none() && io::stdout().is_terminal() {
+        anyhow::bail!("pdf output is binary; pass --output <file> or redirect stdout");
+    }
+
+    let report = client.usage_report(slug, &month.format("%Y-%m").to_string()).await?;
+
+    let content = match format {
+        None if json => format!("{}\n", serde_json::to_string_pretty(&report)?).into_bytes(),
+        None => {
+            print_report(&report, month);
+            return Ok(());
+        }
+        Some(ReportFormat::Json) => format!("{}\n", serde_json::to_string_pretty(&report)?).into_bytes(),
+        Some(ReportFormat::Csv) => table::to_csv(&report_columns(&report)).into_bytes(),
+        Some(ReportFormat::Pdf) => pdf::write(
+            &title(&report, month),
+            &summary_lines(&report),
+            &format!("whk version {}", env!("WHK_VERSION")),
+        ),
+    };
+
+    match output {
+        Some(path) => {
+            std::fs::write(path, &content).with_context(|| format!("failed to write {path}"))?;
+            println!("  {} Wrote the {} report to {}", green("✓"), month.format("%B %Y"), bold(path));
+        }
+        None => io::stdout().write_all(&content)?,
+    }
+    Ok(())
+}
+
+/// The first day of a `YYYY-MM` month.
+fn parse_month(month: &str) -> Result<NaiveDate> {
+    let date = (month.len() == 7)
+        .then(|| NaiveDate::parse_from_str(&format!("{month}-01"), "%Y-%m-%d").ok())
+        .flatten();
+    date.with_context(|| format!("invalid month '{month}'; expected YYYY-MM, e.g. 2024-06"))
+}
+
+fn last_month(today: NaiveDate) -> NaiveDate {
+    let first = today.with_day(1).expect("every month has a first day");
+    first - Months::new(1)
+}
+
+fn title(report: &UsageReport, month: NaiveDate) -> String {
+    format!("Usage report: {}, {}", report.slug, month.format("%B %Y"))
+}
+
+fn print_report(report: &UsageReport, month: NaiveDate) {
+    println!("{}", bold(&sanitize(&title(report, month))));
+    for line in summary_lines(report) {
+        if line.ends_with(':') {
+            println!("  {}", bold(&line));
+        } else if let Some((label, value)) = line.split_at_checked(17).filter(|_| !line.starts_with(' ')) {
+            println!("  {}{}", dim(label), value);
+        } else {
+            println!("  {line}");
+        }
+    }
+}
+
+/// The report as plain text lines, shared by the terminal summary and the PDF.
+fn summary_lines(report: &UsageReport) -> Vec<String> {
+    let mut lines = vec![
+        String::new(),
+        format!("{:<17} {}", "Requests:", report.requests),
+        format!(
+            "{:<17} {} total, {} largest",
+            "Body size:",
+            format_bytes(report.bytes as usize),
+            format_bytes(report.max_size as usize)
+        ),
+    ];
+    if report.requests > 0 {
+        lines.push(format!(
+            "{:<17} {}",
+            "Average size:",
+            format_bytes((report.bytes / report.requests) as usize)
+        ));
+    }
+    lines.push(format!(
+        "{:<17} {:.1}% of the {} plan's {} requests",
+        "Quota:",
+        report.quota.share * 100.0,
+        report.quota.plan,
+        report.quota.limit
+    ));
+    let failures = &report.capture_failures;
+    lines.push(if failures.count == 0 {
+        format!("{:<17} none", "Capture failures:")
+    } else {
+        format!(
+            "{:<17} {} requests lost in {} outage{}",
+            "Capture failures:",
+            failures.count,
+            failures.windows,
+            if failures.windows == 1 { "" } else { "s" }
+        )
+    });
+
+    if !report.days.is_empty() {
+        lines.push(String::new());
+        lines.push("Requests by day:".into());
+        for day in &report.days {
+            lines.push(format!("  {}  {:>10}  {:>10}", day.day, day.requests, format_bytes(day.bytes as usize)));
+        }
+    }
+    if !report.methods.is_empty() {
+        lines.push(String::new());
+        lines.push("Methods:".into());
+        let mut methods: Vec<_> = report.methods.iter().collect();
+        methods.sort_by(|a, b| b.1.cmp(a.1).then(a.0.cmp(b.0)));
+        for (method, count) in methods {
+            lines.push(format!("  {:<10}  {:>10}", sanitize(method), count));
+        }
+    }
+    if !report.top_senders.is_empty() {
+        lines.push(String::new());
+        lines.push("Top senders:".into());
+        for sender in &report.top_senders {
+            lines.push(format!("  {:<39}  {:>10}", sanitize(&sender.ip), sender.requests));
+        }
+    }
+    lines
+}
+
+/// CSV rows: `total` for the month, then one per `day`, `method` and `sender`,
+/// the `quota` limit and `capture_failures`. `bytes` is empty where it
+/// doesn't apply.
+fn report_columns(report: &UsageReport) -> Vec<Column> {
+    let mut rows: Vec<(&str, String, i64, Option<u64>)> =
+        vec![("total", report.month.clone(), report.requests as i64, Some(report.bytes))];
+    for day in &report.days {
+        rows.push(("day", day.day.clone(), day.requests as i64, Some(day.bytes)));
+    }
+    for (method, count) in &report.methods {
+        rows.push(("method", method.clone(), *count as i64, None));
+    }
+    for sender in &report.top_senders {
+        rows.push(("sender", sender.ip.clone(), sender.requests as i64, None));
+    }
+    rows.push(("quota", report.quota.plan.clone(), report.quota.limit as i64, None));
+    rows.push((
+        "capture_failures",
+        format!("{} windows", report.capture_failures.windows),
+        report.capture_failures.count as i64,
+        None,
+    ));
+
+    vec![
+        Column {
+            name: "section".into(),
+            values: Values::Utf8(rows.iter().map(|r| Some(r.0.to_string())).collect()),
+        },
+        Column {
+            name: "name".into(),
+            values: Values::Utf8(rows.iter().map(|r| Some(r.1.clone())).collect()),
+        },
+        Column {
+            name: "requests".into(),
+            values: Values::Int64(rows.iter().map(|r| r.2).collect()),
+        },
+        Column {
+            name: "bytes".into(),
+            values: Values::Utf8(rows.iter().map(|r| r.3.map(|b| b.to_string())).collect()),
+        },
+    ]
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::types::{DailyUsage, ReportFailures, ReportQuota, SenderUsage};
+
+    fn report() -> UsageReport {
+        UsageReport {
+            slug: "abc123".into(),
+            month: "2024-06".into(),
+            requests: 250,
+            bytes: 51_200,
+            max_size: 4_096,
+            days: vec![DailyUsage { day: "2024-06-03".into(), requests: 250, bytes: 51_200 }],
+            methods: [("POST".to_string(), 249), ("GET".to_string(), 1)].into(),
+            top_senders: vec![SenderUsage { ip: "203.0.113.7".into(), requests: 200 }],
+            quota: ReportQuota { plan: "pro".into(), limit: 1_000, share: 0.25 },
+            capture_failures: ReportFailures { count: 3, windows: 1 },
+        }
+    }
+
+    #[test]
+    fn test_parse_month() {
+        assert_eq!(parse_month("2024-06").unwrap(), NaiveDate::from_ymd_opt(2024, 6, 1).unwrap());
+        assert!(parse_month("2024-6").is_err());
+        assert!(parse_month("2024-13").is_err());
+        assert!(parse_month("2024-06-01").is_err());
+    }
+
+    #[test]
+    fn test_last_month_crosses_years() {
+        let jan = NaiveDate::from_ymd_opt(2025, 1, 31).unwrap();
+        assert_eq!(last_month(jan), NaiveDate::from_ymd_opt(2024, 12, 1).unwrap());
+    }
+
+    #[test]
+    fn test_summary_lines() {
+        let lines = summary_lines(&report());
+        assert!(lines.contains(&"Quota:            25.0% of the pro plan's 1000 requests".to_string()));
+        assert!(lines.contains(&"Capture failures: 3 requests lost in 1 outage".to_string()));
+        let methods = lines.iter().position(|l| l == "Methods:").unwrap();
+        assert!(lines[methods + 1].starts_with("  POST"));
+    }
+
+    #[test]
+    fn test_report_csv() {
+        let csv = table::to_csv(&report_columns(&report()));
+        assert_eq!(
+            csv,
+            "section,name,requests,bytes\r\n\
+             total,2024-06,250,51200\r\n\
+             day,2024-06-03,250,51200\r\n\
+             method,GET,1,\r\n\
+             method,POST,249,\r\n\
+             sender,203.0.113.7,200,\r\n\
+             quota,pro,1000,\r\n\
+             capture_failures,1 windows,3,\r\n"
+        );
+    }
+}
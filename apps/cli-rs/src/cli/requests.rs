@@ -1,20 +1,53 @@
 use anyhow::Result;
-use std::io::{self, Write};
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::io::{self, IsTerminal, Write};
 
+use crate::api::requests::ListRequestsFilter;
 use crate::api::ApiClient;
-use crate::cli::output::{bold, dim, green, print_request_detail, print_request_line};
+use crate::cli::annotate::normalize_tag;
+use crate::cli::output::{
+    bold, dim, green, method_color, print_collapsed_request_line, print_grouped_request_line, print_request_detail,
+    print_request_line, red, sanitize, yellow,
+};
 use crate::cli::ExportFormat;
+use crate::types::{CapturedRequest, RequestList};
+use crate::util::cache::{CaptureCache, EndpointCache};
+use crate::util::canonical;
+use crate::util::duplicates;
+use crate::util::parquet;
+use crate::util::stats::{self, GroupBy};
+use crate::util::format::{format_bytes, format_gap, format_timestamp, parse_duration};
+use crate::util::table::{self, Column, Values};
 
+#[allow(clippy::too_many_arguments)]
 pub async fn list(
     client: &ApiClient,
     slug: &str,
     limit: u32,
     since: Option<i64>,
     cursor: Option<String>,
+    refresh: bool,
+    collapse: bool,
+    canonical: bool,
+    group: bool,
+    tag: Option<&str>,
+    event_type: Option<&str>,
+    country: Option<&str>,
+    asn: Option<&str>,
     json: bool,
 ) -> Result<()> {
+    let tag = tag.map(normalize_tag).transpose()?;
+    let country = country.map(normalize_country).transpose()?;
+    let filter = ListRequestsFilter {
+        tag: tag.as_deref(),
+        event_type,
+        country: country.as_deref(),
+        asn: asn.map(parse_asn).transpose()?,
+    };
     if let Some(ref c) = cursor {
-        let result = client.list_requests_paginated(slug, Some(limit), Some(c)).await?;
+        let result = client
+            .list_requests_paginated(slug, Some(limit), Some(c), &filter)
+            .await?;
         if json {
             println!("{}", serde_json::to_string_pretty(&result)?);
             return Ok(());
@@ -23,14 +56,21 @@ pub async fn list(
             println!("  No requests found.");
             return Ok(());
         }
-        for req in &result.requests {
-            print_request_line(req);
-        }
+        print_request_lines(&result.requests, collapse, canonical, group);
         if let Some(ref next) = result.next_cursor {
             println!("\n  {} --cursor {}", dim("Next page:"), next);
         }
     } else {
-        let result = client.list_requests(slug, Some(limit), since).await?;
+        // The cache holds whole, masked listings, so filtered and revealed
+        // listings always go to the API.
+        let (result, offline) = if !filter.is_empty() || client.reveals() {
+            (client.list_requests_filtered(slug, Some(limit), since, &filter).await?, false)
+        } else {
+            list_cached(client, slug, limit, since, refresh).await?
+        };
+        if offline {
+            eprintln!("  {}", yellow("API unreachable, showing cached requests"));
+        }
         if json {
             println!("{}", serde_json::to_string_pretty(&result)?);
             return Ok(());
@@ -39,9 +79,7 @@ pub async fn list(
             println!("  No requests found.");
             return Ok(());
         }
-        for req in &result.requests {
-            print_request_line(req);
-        }
+        print_request_lines(&result.requests, collapse, canonical, group);
         if let Some(count) = result.count {
             println!("\n  {} {count} total", dim(&format!("Showing up to {limit} of")));
         }
@@ -49,16 +87,476 @@ pub async fn list(
     Ok(())
 }
 
-pub async fn get(client: &ApiClient, id: &str, json: bool) -> Result<()> {
+fn print_request_lines(requests: &[CapturedRequest], collapse: bool, canonical: bool, group: bool) {
+    if group {
+        let rows = duplicates::group_similar(requests);
+        for row in &rows {
+            print_grouped_request_line(&requests[row.index], row.count);
+        }
+        let hidden = duplicates::hidden_count(&rows);
+        if hidden > 0 {
+            let noun = if hidden == 1 { "request" } else { "requests" };
+            println!("\n  {}", dim(&format!("{hidden} similar {noun} grouped")));
+        }
+        return;
+    }
+    if !collapse {
+        for req in requests {
+            print_request_line(req);
+        }
+        return;
+    }
+    let rows = if canonical {
+        duplicates::collapse_canonical(requests)
+    } else {
+        duplicates::collapse(requests)
+    };
+    for row in &rows {
+        print_collapsed_request_line(&requests[row.index], row.count);
+    }
+    let hidden = duplicates::hidden_count(&rows);
+    if hidden > 0 {
+        let noun = if hidden == 1 { "request" } else { "requests" };
+        println!("\n  {}", dim(&format!("{hidden} identical {noun} collapsed")));
+    }
+}
+
+/// A country filter as stored on requests: two letters (or Cloudflare's
+/// `T1`), uppercase.
+fn normalize_country(country: &str) -> Result<String> {
+    let country = country.trim().to_uppercase();
+    let valid = country.len() == 2
+        && country.starts_with(|c: char| c.is_ascii_uppercase())
+        && country.chars().all(|c| c.is_ascii_alphanumeric());
+    if !valid {
+        anyhow::bail!("invalid country {country:?}: use a two-letter code such as DE");
+    }
+    Ok(country)
+}
+
+/// An autonomous system number, with or without the `AS` prefix.
+fn parse_asn(asn: &str) -> Result<u32> {
+    let trimmed = asn.trim();
+    let digits = trimmed
+        .strip_prefix("AS")
+        .or_else(|| trimmed.strip_prefix("as"))
+        .unwrap_or(trimmed);
+    digits
+        .parse()
+        .map_err(|_| anyhow::anyhow!("invalid ASN {asn:?}: use a number such as 13335 or AS13335"))
+}
+
+/// List through the local capture cache. When the cache already covers the
+/// listing only requests newer than the newest cached one are fetched; when
+/// the API can't be reached the cache is served instead (second value `true`).
+async fn list_cached(
+    client: &ApiClient,
+    slug: &str,
+    limit: u32,
+    since: Option<i64>,
+    refresh: bool,
+) -> Result<(RequestList, bool)> {
+    let Ok(store) = CaptureCache::new(&client.url("")) else {
+        return Ok((client.list_requests(slug, Some(limit), since).await?, false));
+    };
+    let mut cache = if refresh { EndpointCache::default() } else { store.load(slug) };
+    let limit_n = limit as usize;
+    let covered = cache.complete
+        || cache.requests.len() >= limit_n
+        || since.is_some_and(|s| cache.requests.last().is_some_and(|r| s >= r.received_at));
+
+    let fetched = if covered && let Some(newest) = cache.newest() {
+        client.list_requests(slug, Some(limit), Some(newest.max(since.unwrap_or(0)))).await
+    } else {
+        client.list_requests(slug, Some(limit), since).await
+    };
+
+    let fresh = match fetched {
+        Ok(list) => list.requests,
+        Err(e) if is_transport_error(&e) && !cache.requests.is_empty() => {
+            return Ok((from_cache(&cache, limit_n, since), true));
+        }
+        Err(e) => return Err(e),
+    };
+
+    let truncated = fresh.len() >= limit_n;
+    if covered && cache.newest().is_some() && !truncated {
+        cache.merge(fresh);
+    } else if since.is_none() {
+        // A full listing (or a gap after the cached window): start over from it.
+        cache = EndpointCache { complete: !truncated, ..EndpointCache::default() };
+        cache.merge(fresh);
+    } else {
+        // A `since` window the cache doesn't cover; serve it without caching.
+        return Ok((RequestList { requests: fresh, count: None }, false));
+    }
+    cache.updated_at = chrono::Utc::now().timestamp_millis();
+    let _ = store.save(slug, &cache);
+    Ok((from_cache(&cache, limit_n, since), false))
+}
+
+fn from_cache(cache: &EndpointCache, limit: usize, since: Option<i64>) -> RequestList {
+    let requests = cache
+        .requests
+        .iter()
+        .filter(|r| since.is_none_or(|s| r.received_at >= s))
+        .take(limit)
+        .cloned()
+        .collect();
+    RequestList { requests, count: None }
+}
+
+/// Whether the API call failed before getting a response (offline, DNS, timeout).
+fn is_transport_error(err: &anyhow::Error) -> bool {
+    err.chain().any(|cause| cause.downcast_ref::<reqwest::Error>().is_some())
+}
+
+/// A single request, from the capture cache when it was seen before.
+/// Revealed requests skip the cache so opened values never reach the disk.
+async fn fetch_request(client: &ApiClient, id: &str, refresh: bool) -> Result<CapturedRequest> {
+    if client.reveals() {
+        return client.get_request(id).await;
+    }
+    let store = CaptureCache::new(&client.url("")).ok();
+    if !refresh && let Some(req) = store.as_ref().and_then(|s| s.find(id)) {
+        return Ok(req);
+    }
     let req = client.get_request(id).await?;
+    if let Some(store) = store {
+        let _ = store.remember(req.clone());
+    }
+    Ok(req)
+}
+
+pub async fn get(
+    client: &ApiClient,
+    id: &str,
+    refresh: bool,
+    follow_chain: bool,
+    delivery_id_header: Option<&str>,
+    json: bool,
+) -> Result<()> {
+    let req = fetch_request(client, id, refresh).await?;
+    if follow_chain {
+        return show_chain(client, req, delivery_id_header, json).await;
+    }
     if json {
         println!("{}", serde_json::to_string_pretty(&req)?);
     } else {
         print_request_detail(&req);
+        if let Some(ref original) = req.duplicate_of {
+            // Same method, path and body; headers (signatures, delivery IDs) may still differ.
+            println!(
+                "\n  {} {}",
+                dim("Compare with the original:"),
+                bold(&format!("whk requests diff {} {}", sanitize(original), sanitize(&req.id)))
+            );
+        }
     }
     Ok(())
 }
 
+/// Headers providers use for an ID shared by every attempt at one delivery.
+const DELIVERY_ID_HEADERS: &[&str] =
+    &["webhook-id", "svix-id", "x-github-delivery", "x-shopify-webhook-id", "x-gitlab-event-uuid"];
+
+/// How far before the request `--follow-chain` looks for earlier attempts.
+const CHAIN_LOOKBACK_MS: i64 = 3 * 24 * 60 * 60 * 1000;
+
+/// Requests fetched to search for the rest of a chain (the API maximum).
+const CHAIN_FETCH_LIMIT: u32 = 1000;
+
+/// The delivery ID header a request carries: `header` when given, otherwise
+/// the first of [`DELIVERY_ID_HEADERS`] present. Returns the lowercase name and value.
+fn delivery_id<'a>(req: &'a CapturedRequest, header: Option<&str>) -> Option<(String, &'a str)> {
+    let names: Vec<&str> = match header {
+        Some(name) => vec![name],
+        None => DELIVERY_ID_HEADERS.to_vec(),
+    };
+    names.into_iter().find_map(|name| {
+        req.headers
+            .iter()
+            .find(|(k, _)| k.eq_ignore_ascii_case(name))
+            .map(|(_, v)| (name.to_ascii_lowercase(), v.as_str()))
+    })
+}
+
+/// Attempts at the same delivery as `req`, oldest first: requests carrying its
+/// delivery ID, and requests the receiver linked to it as duplicates.
+fn chain_of<'a>(
+    req: &CapturedRequest,
+    candidates: &'a [CapturedRequest],
+    header: Option<&str>,
+) -> Vec<&'a CapturedRequest> {
+    let delivery = delivery_id(req, header);
+    let same_delivery = |c: &CapturedRequest| {
+        delivery
+            .as_ref()
+            .is_some_and(|(name, value)| delivery_id(c, Some(name)).is_some_and(|(_, v)| v == *value))
+    };
+    // Duplicates of any attempt belong to the chain too, even without the header.
+    let groups: HashSet<&str> = std::iter::once(req)
+        .chain(candidates.iter().filter(|c| same_delivery(c)))
+        .map(duplicates::group_key)
+        .collect();
+    let mut chain: Vec<&CapturedRequest> = candidates
+        .iter()
+        .filter(|c| groups.contains(duplicates::group_key(c)) || same_delivery(c))
+        .collect();
+    chain.sort_by(|a, b| a.received_at.cmp(&b.received_at).then_with(|| a.id.cmp(&b.id)));
+    chain
+}
+
+/// Fields that changed between consecutive attempts, in first-seen order.
+fn chain_differences(chain: &[&CapturedRequest]) -> Vec<String> {
+    let mut fields: Vec<String> = Vec::new();
+    for pair in chain.windows(2) {
+        for change in diff_requests(pair[0], pair[1], false) {
+            if !fields.contains(&change.field) {
+                fields.push(change.field);
+            }
+        }
+    }
+    fields
+}
+
+/// Show every attempt at the delivery `req` belongs to, with the time between
+/// attempts and what changed across them.
+async fn show_chain(
+    client: &ApiClient,
+    req: CapturedRequest,
+    header: Option<&str>,
+    json: bool,
+) -> Result<()> {
+    let endpoints = client.list_endpoints().await?;
+    let Some(endpoint) = endpoints.owned.iter().chain(&endpoints.shared).find(|e| e.id == req.endpoint_id)
+    else {
+        anyhow::bail!("endpoint of request {} not found", req.id);
+    };
+    let since = req.received_at.saturating_sub(CHAIN_LOOKBACK_MS);
+    let mut candidates = client
+        .list_requests(&endpoint.slug, Some(CHAIN_FETCH_LIMIT), Some(since))
+        .await?
+        .requests;
+    if !candidates.iter().any(|c| c.id == req.id) {
+        candidates.push(req.clone());
+    }
+
+    let chain = chain_of(&req, &candidates, header);
+    let differences = chain_differences(&chain);
+    let delivery = delivery_id(&req, header);
+    let first = chain.first().map_or(req.received_at, |c| c.received_at);
+
+    if json {
+        let attempts: Vec<serde_json::Value> = chain
+            .iter()
+            .enumerate()
+            .map(|(i, c)| {
+                serde_json::json!({
+                    "attempt": i + 1,
+                    "id": c.id,
+                    "receivedAt": c.received_at,
+                    "sinceFirstMs": c.received_at - first,
+                    "sincePreviousMs": i.checked_sub(1).map(|p| c.received_at - chain[p].received_at),
+                    "status": c.response.as_ref().map(|r| r.status),
+                })
+            })
+            .collect();
+        println!(
+            "{}",
+            serde_json::to_string_pretty(&serde_json::json!({
+                "id": req.id,
+                "deliveryIdHeader": delivery.as_ref().map(|(name, _)| name),
+                "deliveryId": delivery.as_ref().map(|(_, value)| value),
+                "attempts": attempts,
+                "differences": differences,
+            }))?
+        );
+        return Ok(());
+    }
+
+    let span = chain.last().map_or(0, |c| c.received_at - first);
+    let label = match &delivery {
+        Some((name, value)) => format!("{name}: {}", sanitize(value)),
+        None => "no delivery ID; duplicates only".to_string(),
+    };
+    println!(
+        "  {} {} attempts over {} ({})",
+        bold("Retry chain:"),
+        chain.len(),
+        format_gap(span),
+        dim(&label)
+    );
+    for (i, c) in chain.iter().enumerate() {
+        let gap = match i.checked_sub(1) {
+            Some(p) => format!("+{}", format_gap(c.received_at - chain[p].received_at)),
+            None => String::new(),
+        };
+        let status = c.response.as_ref().map(|r| r.status.to_string()).unwrap_or_else(|| "-".into());
+        let marker = if c.id == req.id { bold("←") } else { String::new() };
+        println!(
+            "  #{:<3} {}  {:>8}  {} {}  {:>3}  {} {}",
+            i + 1,
+            format_timestamp(c.received_at),
+            dim(&gap),
+            bold(&sanitize(&c.method)),
+            sanitize(&c.path),
+            status,
+            dim(&sanitize(&c.id)),
+            marker
+        );
+    }
+    if chain.len() < 2 {
+        println!("
+  No other attempts found in the {} before it or since.", format_gap(CHAIN_LOOKBACK_MS));
+    } else if differences.is_empty() {
+        println!("
+  Every attempt was identical.");
+    } else {
+        println!("
+  {} {}", dim("Differed across attempts:"), sanitize(&differences.join(", ")));
+    }
+    Ok(())
+}
+
+/// Print the signed URL for an offloaded body, or download it to `output`.
+pub async fn body(client: &ApiClient, id: &str, output: Option<&str>, json: bool) -> Result<()> {
+    let body = client.get_request_body_url(id).await?;
+    let Some(path) = output else {
+        if json {
+            println!("{}", serde_json::to_string_pretty(&body)?);
+        } else {
+            println!("{}", body.url);
+        }
+        return Ok(());
+    };
+
+    let resp = client.http.get(&body.url).send().await?;
+    if !resp.status().is_success() {
+        anyhow::bail!("object storage returned HTTP {}", resp.status().as_u16());
+    }
+    let bytes = resp.bytes().await?;
+    std::fs::write(path, &bytes)?;
+    if json {
+        println!("{}", serde_json::json!({ "output": path, "size": bytes.len() }));
+    } else {
+        println!("  {} Wrote {} to {}", green("✓"), format_bytes(bytes.len()), path);
+    }
+    Ok(())
+}
+
+/// One field that differs between two requests. `None` means absent.
+#[derive(Debug, PartialEq, serde::Serialize)]
+struct Change {
+    field: String,
+    a: Option<String>,
+    b: Option<String>,
+}
+
+pub async fn diff(client: &ApiClient, a: &str, b: &str, refresh: bool, canonical: bool, json: bool) -> Result<()> {
+    let left = fetch_request(client, a, refresh).await?;
+    let right = fetch_request(client, b, refresh).await?;
+    let changes = diff_requests(&left, &right, canonical);
+
+    if json {
+        println!(
+            "{}",
+            serde_json::to_string_pretty(&serde_json::json!({ "a": a, "b": b, "changes": changes }))?
+        );
+        return Ok(());
+    }
+
+    println!("  {} {} {}", bold(a), dim("vs"), bold(b));
+    if changes.is_empty() {
+        println!("  No differences.");
+        return Ok(());
+    }
+    for c in &changes {
+        let field = sanitize(&c.field);
+        match (&c.a, &c.b) {
+            (Some(x), Some(y)) => println!(
+                "  {} {field}: {} {} {}",
+                yellow("~"),
+                red(&sanitize(x)),
+                dim("->"),
+                green(&sanitize(y))
+            ),
+            (Some(x), None) => println!("  {} {field}: {}", red("-"), sanitize(x)),
+            (None, Some(y)) => println!("  {} {field}: {}", green("+"), sanitize(y)),
+            (None, None) => {}
+        }
+    }
+    Ok(())
+}
+
+/// Field-level differences: method, path, content type, each header and
+/// query parameter, and the body (per JSON path when both bodies are JSON,
+/// after canonicalizing them when `canonical` is set).
+fn diff_requests(a: &CapturedRequest, b: &CapturedRequest, canonical: bool) -> Vec<Change> {
+    let mut changes = Vec::new();
+    let mut field = |name: String, x: Option<&str>, y: Option<&str>| {
+        if x != y {
+            changes.push(Change { field: name, a: x.map(str::to_string), b: y.map(str::to_string) });
+        }
+    };
+
+    field("method".into(), Some(&a.method), Some(&b.method));
+    field("path".into(), Some(&a.path), Some(&b.path));
+    field("contentType".into(), a.content_type.as_deref(), b.content_type.as_deref());
+
+    for (prefix, x, y) in [("headers", &a.headers, &b.headers), ("query", &a.query_params, &b.query_params)] {
+        let x: BTreeMap<String, &String> = x.iter().map(|(k, v)| (k.to_lowercase(), v)).collect();
+        let y: BTreeMap<String, &String> = y.iter().map(|(k, v)| (k.to_lowercase(), v)).collect();
+        let mut keys: Vec<&String> = x.keys().chain(y.keys()).collect();
+        keys.sort();
+        keys.dedup();
+        for k in keys {
+            field(format!("{prefix}.{k}"), x.get(k).map(|v| v.as_str()), y.get(k).map(|v| v.as_str()));
+        }
+    }
+
+    let parse = |body: &Option<String>| {
+        body.as_deref()
+            .and_then(|s| serde_json::from_str(s).ok())
+            .map(|v| if canonical { canonical::canonicalize(v) } else { v })
+    };
+    match (parse(&a.body), parse(&b.body)) {
+        (Some(x), Some(y)) => {
+            let (mut x_leaves, mut y_leaves) = (HashMap::new(), HashMap::new());
+            flatten("body", &x, &mut x_leaves);
+            flatten("body", &y, &mut y_leaves);
+            let mut keys: Vec<&String> = x_leaves.keys().chain(y_leaves.keys()).collect();
+            keys.sort();
+            keys.dedup();
+            for k in keys {
+                field(k.clone(), x_leaves.get(k).map(String::as_str), y_leaves.get(k).map(String::as_str));
+            }
+        }
+        _ => field("body".into(), a.body.as_deref(), b.body.as_deref()),
+    }
+
+    changes
+}
+
+/// Collect `path -> compact JSON` for every leaf of `value`.
+fn flatten(path: &str, value: &serde_json::Value, out: &mut HashMap<String, String>) {
+    match value {
+        serde_json::Value::Object(map) if !map.is_empty() => {
+            for (k, v) in map {
+                flatten(&format!("{path}.{k}"), v, out);
+            }
+        }
+        serde_json::Value::Array(items) if !items.is_empty() => {
+            for (i, v) in items.iter().enumerate() {
+                flatten(&format!("{path}[{i}]"), v, out);
+            }
+        }
+        leaf => {
+            out.insert(path.to_string(), leaf.to_string());
+        }
+    }
+}
+
 #[allow(clippy::too_many_arguments)]
 pub async fn search(
     client: &ApiClient,
@@ -67,13 +565,14 @@ pub async fn search(
     q: Option<&str>,
     from: Option<&str>,
     to: Option<&str>,
+    content_class: Option<&str>,
     limit: u32,
     offset: u32,
     order: &str,
     json: bool,
 ) -> Result<()> {
     let result = client
-        .search_requests(slug, method, q, from, to, Some(limit), Some(offset), Some(order))
+        .search_requests(slug, method, q, from, to, content_class, Some(limit), Some(offset), Some(order))
         .await?;
 
     if json {
@@ -94,6 +593,7 @@ pub async fn search(
     Ok(())
 }
 
+#[allow(clippy::too_many_arguments)]
 pub async fn count(
     client: &ApiClient,
     slug: Option<&str>,
@@ -101,9 +601,10 @@ pub async fn count(
     q: Option<&str>,
     from: Option<&str>,
     to: Option<&str>,
+    content_class: Option<&str>,
     json: bool,
 ) -> Result<()> {
-    let result = client.count_requests(slug, method, q, from, to).await?;
+    let result = client.count_requests(slug, method, q, from, to, content_class).await?;
 
     if json {
         println!("{}", serde_json::json!({ "count": result.count }));
@@ -113,6 +614,136 @@ pub async fn count(
     Ok(())
 }
 
+/// Requests fetched per page for `stats`.
+const STATS_PAGE_SIZE: u32 = 1000;
+
+/// Slices of the window in each sparkline.
+const STATS_BUCKETS: usize = 24;
+
+/// Aggregate the requests of the last `since` (a duration), grouped by
+/// `group_by` when given. Requests are fetched newest first until the window
+/// or `limit` is exhausted, and counted locally.
+pub async fn stats(
+    client: &ApiClient,
+    slug: &str,
+    group_by: Option<&str>,
+    since: &str,
+    limit: u32,
+    top: usize,
+    json: bool,
+) -> Result<()> {
+    let group_by = group_by.map(str::parse::<GroupBy>).transpose()?;
+    let to = chrono::Utc::now().timestamp_millis();
+    let from = to - parse_duration(since)?;
+
+    let mut requests: Vec<CapturedRequest> = Vec::new();
+    let mut cursor: Option<String> = None;
+    let mut truncated = false;
+    loop {
+        let page = client
+            .list_requests_paginated(slug, Some(STATS_PAGE_SIZE.min(limit)), cursor.as_deref(), &ListRequestsFilter::default())
+            .await?;
+        let reached_start = page.requests.last().is_none_or(|r| r.received_at < from);
+        requests.extend(page.requests.into_iter().filter(|r| r.received_at >= from));
+        if requests.len() >= limit as usize {
+            truncated = requests.len() > limit as usize || (!reached_start && page.next_cursor.is_some());
+            requests.truncate(limit as usize);
+            break;
+        }
+        match page.next_cursor {
+            Some(next) if !reached_start => cursor = Some(next),
+            _ => break,
+        }
+    }
+    // With --limit cutting the window short, the counts cover only its newest part.
+    let from = if truncated { requests.last().map_or(from, |r| r.received_at) } else { from };
+
+    let groups = stats::aggregate(&requests, group_by.as_ref(), from, to + 1, STATS_BUCKETS);
+    if json {
+        println!(
+            "{}",
+            serde_json::to_string_pretty(&serde_json::json!({
+                "from": from,
+                "to": to,
+                "total": requests.len(),
+                "truncated": truncated,
+                "groups": groups.iter().take(top).collect::<Vec<_>>(),
+            }))?
+        );
+        return Ok(());
+    }
+    if requests.is_empty() {
+        println!("  No requests in the last {since}.");
+        return Ok(());
+    }
+
+    println!(
+        "  {} requests since {}{}",
+        bold(&requests.len().to_string()),
+        format_timestamp(from),
+        if truncated { yellow(&format!(" (newest {limit} only)")) } else { String::new() }
+    );
+    println!(
+        "\n  {}",
+        dim(&format!(
+            "{:<40} {:>7} {:>6}  {:>8} {:>8} {:>8}  ACTIVITY",
+            "GROUP", "COUNT", "SHARE", "SIZE P50", "P90", "P99"
+        ))
+    );
+    for group in groups.iter().take(top) {
+        let key: String = sanitize(&group.key).chars().take(40).collect();
+        println!(
+            "  {:<40} {:>7} {:>5.1}%  {:>8} {:>8} {:>8}  {}",
+            key,
+            group.count,
+            group.share * 100.0,
+            format_bytes(group.size.p50),
+            format_bytes(group.size.p90),
+            format_bytes(group.size.p99),
+            green(&stats::sparkline(&group.buckets))
+        );
+    }
+    if groups.len() > top {
+        println!("\n  {}", dim(&format!("{} more groups; show them with --top", groups.len() - top)));
+    }
+    Ok(())
+}
+
+/// Requests the endpoint's network policy rejected, newest first.
+pub async fn rejected(client: &ApiClient, slug: &str, limit: u32, since: Option<i64>, json: bool) -> Result<()> {
+    let rejected = client.rejected_requests(slug, limit, since).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&rejected)?);
+        return Ok(());
+    }
+    if rejected.is_empty() {
+        println!("  No rejected requests. They are only kept with --capture-rejected true.");
+        return Ok(());
+    }
+
+    for req in &rejected {
+        let reason = if req.reason == "denied" { red("denied ") } else { yellow("blocked") };
+        let sender = match req.country {
+            Some(ref country) => format!("{} {}", req.ip, country),
+            None => req.ip.clone(),
+        };
+        let mut line = format!(
+            "  {} {} {} {} {}",
+            dim(&format_timestamp(req.received_at)),
+            reason,
+            sanitize(&sender),
+            method_color(&req.method),
+            sanitize(&req.path)
+        );
+        if let Some(ref agent) = req.user_agent {
+            line.push_str(&format!(" {}", dim(&sanitize(agent))));
+        }
+        println!("{line}");
+    }
+    Ok(())
+}
+
 pub async fn clear(
     client: &ApiClient,
     slug: &str,
@@ -137,6 +768,9 @@ pub async fn clear(
     }
 
     client.clear_requests(slug, before).await?;
+    if let Ok(store) = CaptureCache::new(&client.url("")) {
+        let _ = store.clear(slug);
+    }
 
     if json {
         println!("{}", serde_json::json!({ "cleared": true, "slug": slug }));
@@ -146,6 +780,7 @@ pub async fn clear(
     Ok(())
 }
 
+#[allow(clippy::too_many_arguments)]
 pub async fn export(
     client: &ApiClient,
     slug: &str,
@@ -153,19 +788,48 @@ pub async fn export(
     limit: u32,
     since: Option<i64>,
     output: Option<&str>,
+    headers: &[String],
+    allow_masked: bool,
     _json: bool,
 ) -> Result<()> {
-    let result = client.list_requests(slug, Some(limit), since).await?;
+    if matches!(format, ExportFormat::Parquet) && output.is_none() && io::stdout().is_terminal() {
+        anyhow::bail!("parquet output is binary; pass --output <file> or redirect stdout");
+    }
+
+    let result = client
+        .with_operation("export")
+        .list_requests(slug, Some(limit), since)
+        .await?;
 
     if result.requests.is_empty() {
         println!("  No requests to export.");
         return Ok(());
     }
 
+    let masked = masked_in_export(&result.requests, format, headers);
+    if !masked.is_empty() && !allow_masked {
+        let (id, field, reason) = masked[0];
+        let mut message = format!(
+            "{} exported field(s) are masked, e.g. {field} of {id} ({reason}); \
+             exports carry placeholders, not what was sent",
+            masked.len()
+        );
+        if masked.iter().any(|(_, _, reason)| *reason == "encrypted") && !client.reveals() {
+            message.push_str("\n  Pass --reveal to open encrypted values");
+        }
+        message.push_str("\n  Pass --allow-masked to export the placeholders anyway");
+        anyhow::bail!(message);
+    }
+
     let webhook_url = client.webhook_url_for(slug);
     let content = match format {
-        ExportFormat::Har => build_har_export(&webhook_url, &result.requests),
-        ExportFormat::Curl => build_curl_export(&webhook_url, &result.requests),
+        ExportFormat::Har => build_har_export(&webhook_url, &result.requests).into_bytes(),
+        ExportFormat::Curl => build_curl_export(&webhook_url, &result.requests).into_bytes(),
+        ExportFormat::Csv => table::to_csv(&export_columns(&result.requests, headers)).into_bytes(),
+        ExportFormat::Parquet => parquet::write(
+            &export_columns(&result.requests, headers),
+            &format!("whk version {}", env!("WHK_VERSION")),
+        ),
     };
 
     match output {
@@ -178,12 +842,116 @@ pub async fn export(
                 bold(path)
             );
         }
-        None => print!("{content}"),
+        None => io::stdout().write_all(&content)?,
     }
 
     Ok(())
 }
 
+/// Masked fields an export would write, as (request ID, field, reason). HAR
+/// carries headers, query and body; curl headers (less credentials) and body;
+/// csv and parquet the query, the selected headers and the body. None carry
+/// trailers.
+fn masked_in_export<'a>(
+    requests: &'a [CapturedRequest],
+    format: &ExportFormat,
+    headers: &[String],
+) -> Vec<(&'a str, &'a str, &'a str)> {
+    let headers: Vec<String> = headers.iter().map(|h| h.to_lowercase()).collect();
+    let exported = |field: &str| {
+        if field == "body" {
+            return true;
+        }
+        if let Some(name) = field.strip_prefix("header:") {
+            let name = name.to_lowercase();
+            return match format {
+                ExportFormat::Har => true,
+                ExportFormat::Curl => !CURL_SKIPPED_HEADERS.contains(&name.as_str()),
+                ExportFormat::Csv | ExportFormat::Parquet => headers.contains(&name),
+            };
+        }
+        field.starts_with("query:") && !matches!(format, ExportFormat::Curl)
+    };
+    requests
+        .iter()
+        .flat_map(|r| {
+            r.masked
+                .iter()
+                .filter(|(field, _)| exported(field))
+                .map(|(field, reason)| (r.id.as_str(), field.as_str(), reason.as_str()))
+        })
+        .collect()
+}
+
+/// Flattened columns for the csv and parquet formats: one row per request,
+/// plus a `header.<name>` column for each header in `headers`.
+fn export_columns(requests: &[CapturedRequest], headers: &[String]) -> Vec<Column> {
+    let text = |f: &dyn Fn(&CapturedRequest) -> Option<String>| Values::Utf8(requests.iter().map(f).collect());
+    let mut columns = vec![
+        Column {
+            name: "id".into(),
+            values: text(&|r| Some(r.id.clone())),
+        },
+        Column {
+            name: "received_at".into(),
+            values: Values::TimestampMillis(requests.iter().map(|r| r.received_at).collect()),
+        },
+        Column {
+            name: "method".into(),
+            values: text(&|r| Some(r.method.clone())),
+        },
+        Column {
+            name: "path".into(),
+            values: text(&|r| Some(r.path.clone())),
+        },
+        Column {
+            name: "query".into(),
+            values: text(&|r| {
+                let mut pairs: Vec<_> = r.query_params.iter().collect();
+                pairs.sort();
+                let query: Vec<String> = pairs
+                    .into_iter()
+                    .map(|(k, v)| format!("{}={}", urlencoding::encode(k), urlencoding::encode(v)))
+                    .collect();
+                (!query.is_empty()).then(|| query.join("&"))
+            }),
+        },
+        Column {
+            name: "content_type".into(),
+            values: text(&|r| r.content_type.clone()),
+        },
+        Column {
+            name: "size".into(),
+            values: Values::Int64(requests.iter().map(|r| r.size as i64).collect()),
+        },
+        Column {
+            name: "ip".into(),
+            values: text(&|r| Some(r.ip.clone())),
+        },
+    ];
+    for header in headers {
+        let name = header.to_lowercase();
+        columns.push(Column {
+            name: format!("header.{name}"),
+            values: text(&|r| {
+                r.headers
+                    .iter()
+                    .find(|(k, _)| k.to_lowercase() == name)
+                    .map(|(_, v)| v.clone())
+            }),
+        });
+    }
+    columns.push(Column {
+        name: "body".into(),
+        values: text(&|r| r.body.clone()),
+    });
+    columns.push(Column {
+        name: "body_base64".into(),
+        values: text(&|r| r.body_raw.clone()),
+    });
+    columns
+}
+
 fn build_har_export(base_url: &str, requests: &[crate::types::CapturedRequest]) -> String {
     let entries: Vec<serde_json::Value> = requests
         .iter()
@@ -240,9 +1008,10 @@ fn build_har_export(base_url: &str, requests: &[crate::types::CapturedRequest])
     serde_json::to_string_pretty(&har).unwrap_or_else(|_| "{}".to_string())
 }
 
-fn build_curl_export(base_url: &str, requests: &[crate::types::CapturedRequest]) -> String {
-    let sensitive = ["authorization", "cookie", "proxy-authorization", "set-cookie"];
+/// Credentials left out of curl exports.
+const CURL_SKIPPED_HEADERS: [&str; 4] = ["authorization", "cookie", "proxy-authorization", "set-cookie"];
 
+fn build_curl_export(base_url: &str, requests: &[crate::types::CapturedRequest]) -> String {
     requests
         .iter()
         .map(|r| {
@@ -250,7 +1019,7 @@ fn build_curl_export(base_url: &str, requests: &[crate::types::CapturedRequest])
             let mut parts = vec![format!("curl -X {}", shell_escape(&r.method))];
 
             for (k, v) in &r.headers {
-                if sensitive.contains(&k.to_lowercase().as_str()) {
+                if CURL_SKIPPED_HEADERS.contains(&k.to_lowercase().as_str()) {
                     continue;
                 }
                 parts.push(format!("-H '{}: {}'", shell_escape(k), shell_escape(v)));
@@ -270,3 +1039,157 @@ fn build_curl_export(base_url: &str, requests: &[crate::types::CapturedRequest])
 fn shell_escape(s: &str) -> String {
     s.replace('\'', "'\\''")
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn req(method: &str, headers: &[(&str, &str)], body: Option<&str>) -> CapturedRequest {
+        CapturedRequest {
+            id: "r".into(),
+            method: method.into(),
+            path: "/hook".into(),
+            headers: headers
+                .iter()
+                .map(|(k, v)| (k.to_string(), v.to_string()))
+                .collect(),
+            body: body.map(str::to_string),
+            ..CapturedRequest::test_default()
+        }
+    }
+
+    #[test]
+    fn test_diff_requests_identical() {
+        let a = req("POST", &[("X-Id", "1")], Some("{\"a\":1}"));
+        assert!(diff_requests(&a, &a, false).is_empty());
+    }
+
+    #[test]
+    fn test_diff_requests_fields_headers_and_json_body() {
+        let a = req("POST", &[("X-Id", "1"), ("X-Old", "y")], Some("{\"amount\":100,\"items\":[1,2]}"));
+        let b = req("PUT", &[("x-id", "2"), ("X-New", "z")], Some("{\"amount\":200,\"items\":[1,2]}"));
+        let changes = diff_requests(&a, &b, false);
+        let fields: Vec<(&str, Option<&str>, Option<&str>)> = changes
+            .iter()
+            .map(|c| (c.field.as_str(), c.a.as_deref(), c.b.as_deref()))
+            .collect();
+        assert_eq!(
+            fields,
+            vec![
+                ("method", Some("POST"), Some("PUT")),
+                ("headers.x-id", Some("1"), Some("2")),
+                ("headers.x-new", None, Some("z")),
+                ("headers.x-old", Some("y"), None),
+                ("body.amount", Some("100"), Some("200")),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_chain_follows_delivery_id_and_duplicates() {
+        let attempt = |id: &str, at: i64, headers: &[(&str, &str)], duplicate_of: Option<&str>| {
+            let mut r = req("POST", headers, Some("{\"attempt\":1}"));
+            r.id = id.into();
+            r.received_at = at;
+            r.duplicate_of = duplicate_of.map(str::to_string);
+            r
+        };
+        let requests = vec![
+            attempt("c", 3000, &[("Webhook-Id", "msg_1"), ("Webhook-Signature", "s3")], None),
+            attempt("other", 2500, &[("webhook-id", "msg_2")], None),
+            attempt("b", 2000, &[("webhook-id", "msg_1"), ("webhook-signature", "s2")], Some("a")),
+            attempt("a", 1000, &[("webhook-id", "msg_1"), ("webhook-signature", "s1")], None),
+            attempt("dup", 1100, &[], Some("a")),
+        ];
+
+        let chain = chain_of(&requests[0], &requests, None);
+        let ids: Vec<&str> = chain.iter().map(|r| r.id.as_str()).collect();
+        assert_eq!(ids, ["a", "dup", "b", "c"]);
+        assert_eq!(
+            chain_differences(&chain),
+            ["headers.webhook-id", "headers.webhook-signature"]
+        );
+
+        let by_header = chain_of(&requests[0], &requests, Some("webhook-signature"));
+        let ids: Vec<&str> = by_header.iter().map(|r| r.id.as_str()).collect();
+        assert_eq!(ids, ["c"]);
+    }
+
+    #[test]
+    fn test_diff_requests_canonical_numbers() {
+        let a = req("POST", &[], Some("{\"amount\":100,\"rate\":1.5}"));
+        let b = req("POST", &[], Some("{\"rate\":1.5, \"amount\":100.0}"));
+        assert_eq!(diff_requests(&a, &b, false).len(), 1);
+        assert!(diff_requests(&a, &b, true).is_empty());
+    }
+
+    #[test]
+    fn test_diff_requests_non_json_body() {
+        let a = req("POST", &[], Some("a=1"));
+        let b = req("POST", &[], Some("a=2"));
+        assert_eq!(
+            diff_requests(&a, &b, false),
+            vec![Change { field: "body".into(), a: Some("a=1".into()), b: Some("a=2".into()) }]
+        );
+    }
+
+    #[test]
+    fn test_export_columns_flatten_selected_headers() {
+        let mut signed = req("POST", &[("X-GitHub-Event", "push")], Some("{\"a\":1}"));
+        signed.query_params.insert("b".into(), "2 3".into());
+        let requests = vec![signed, req("GET", &[], None)];
+
+        let columns = export_columns(&requests, &["x-github-event".into()]);
+        let names: Vec<&str> = columns.iter().map(|c| c.name.as_str()).collect();
+        assert_eq!(
+            names,
+            [
+                "id",
+                "received_at",
+                "method",
+                "path",
+                "query",
+                "content_type",
+                "size",
+                "ip",
+                "header.x-github-event",
+                "body",
+                "body_base64"
+            ]
+        );
+
+        let csv = table::to_csv(&columns);
+        let rows: Vec<&str> = csv.split("\r\n").collect();
+        assert_eq!(rows[1], "r,1970-01-01T00:00:00.000Z,POST,/hook,b=2%203,,0,,push,\"{\"\"a\"\":1}\",");
+        assert_eq!(rows[2], "r,1970-01-01T00:00:00.000Z,GET,/hook,,,0,,,,");
+    }
+
+    #[test]
+    fn test_masked_in_export_follows_exported_fields() {
+        let mut masked = req("POST", &[("Authorization", "[encrypted]")], Some("{}"));
+        masked.masked.insert("header:authorization".into(), "encrypted".into());
+        masked.masked.insert("query:token".into(), "redacted".into());
+        masked.masked.insert("trailer:x-checksum".into(), "redacted".into());
+        let requests = vec![masked, req("GET", &[], None)];
+
+        assert_eq!(
+            masked_in_export(&requests, &ExportFormat::Har, &[]),
+            [("r", "header:authorization", "encrypted"), ("r", "query:token", "redacted")]
+        );
+        assert!(masked_in_export(&requests, &ExportFormat::Curl, &[]).is_empty());
+        assert_eq!(masked_in_export(&requests, &ExportFormat::Csv, &[]), [("r", "query:token", "redacted")]);
+        assert_eq!(masked_in_export(&requests, &ExportFormat::Parquet, &["Authorization".into()]).len(), 2);
+    }
+
+    #[test]
+    fn test_region_filters() {
+        assert_eq!(normalize_country(" de ").unwrap(), "DE");
+        assert_eq!(normalize_country("t1").unwrap(), "T1");
+        assert!(normalize_country("DEU").is_err());
+        assert!(normalize_country("1A").is_err());
+        assert_eq!(parse_asn("13335").unwrap(), 13335);
+        assert_eq!(parse_asn("AS13335").unwrap(), 13335);
+        assert!(parse_asn("AS").is_err());
+        assert!(parse_asn("4294967296").is_err());
+    }
+}
@@ -1,10 +1,35 @@
 use anyhow::{bail, Result};
+use serde::Serialize;
 use std::collections::HashMap;
 use std::io::Read;
+use std::time::{Duration, Instant};
 
 use crate::api::ApiClient;
+use crate::cli::RetryStormArgs;
 use crate::cli::output::{bold, dim, green, red};
-use crate::types::SendWebhookRequest;
+use crate::types::{SendResponse, SendWebhookRequest};
+
+/// Longest delay between simulated retries.
+const MAX_RETRY_DELAY: Duration = Duration::from_secs(60);
+
+/// Duplicates of an attempt land within this many milliseconds of it.
+const DUPLICATE_WINDOW_MS: u64 = 250;
+
+/// Where a webhook is delivered: a webhooks.cc endpoint (via the API) or any URL.
+#[derive(Clone, Copy)]
+enum Target<'a> {
+    Endpoint(&'a str),
+    Url(&'a str),
+}
+
+impl Target<'_> {
+    fn label(&self) -> String {
+        match self {
+            Target::Endpoint(slug) => bold(slug),
+            Target::Url(url) => dim(url),
+        }
+    }
+}
 
 pub async fn send_to_endpoint(
     client: &ApiClient,
@@ -12,48 +37,23 @@ pub async fn send_to_endpoint(
     method: &str,
     headers: Vec<String>,
     data: Option<&str>,
+    retry: &RetryStormArgs,
     json_output: bool,
 ) -> Result<()> {
     let header_map = parse_headers(&headers)?;
+    let body = read_body(data)?;
+    let target = Target::Endpoint(slug);
 
-    // If data starts with @, read from file
-    let body = match data {
-        Some(d) if d.starts_with('@') => {
-            let path = &d[1..];
-            let file = std::fs::File::open(path)
-                .map_err(|e| anyhow::anyhow!("failed to read {path}: {e}"))?;
-            let mut limited = file.take(10 * 1024 * 1024 + 1);
-            let mut contents = String::new();
-            limited.read_to_string(&mut contents)
-                .map_err(|e| anyhow::anyhow!("failed to read {path}: {e}"))?;
-            if contents.len() > 10 * 1024 * 1024 {
-                bail!("file too large (max 10MB)");
-            }
-            Some(contents)
-        }
-        Some(d) => Some(d.to_string()),
-        None => None,
-    };
-
-    let req = SendWebhookRequest {
-        method: method.to_uppercase(),
-        slug: slug.to_string(),
-        path: None,
-        headers: if header_map.is_empty() { None } else { Some(header_map) },
-        body,
-    };
+    if retry.retries > 0 || retry.duplicates > 0 {
+        return retry_storm(client, target, method, header_map, body, retry, json_output).await;
+    }
 
-    let resp = client.send_webhook(&req).await?;
+    let resp = deliver(client, target, method, &header_map, body.as_deref()).await?;
 
     if json_output {
         println!("{}", serde_json::to_string_pretty(&resp)?);
     } else {
-        let status_str = if resp.status < 400 {
-            green(&format!("{} {}", resp.status, resp.status_text))
-        } else {
-            red(&format!("{} {}", resp.status, resp.status_text))
-        };
-        println!("  {} Sent {} to {} -> {}", green("✓"), bold(method), bold(slug), status_str);
+        println!("  {} Sent {} to {} -> {}", green("✓"), bold(method), target.label(), status_label(&resp));
         if let Some(ref body) = resp.body && !body.is_empty() {
             println!("\n{}", dim(&body.chars().take(500).collect::<String>()));
         }
@@ -68,11 +68,217 @@ pub async fn send_to_url(
     method: &str,
     headers: Vec<String>,
     data: Option<&str>,
+    retry: &RetryStormArgs,
     json_output: bool,
 ) -> Result<()> {
     let header_map = parse_headers(&headers)?;
+    let body = read_body(data)?;
+    let target = Target::Url(url);
+
+    if retry.retries > 0 || retry.duplicates > 0 {
+        return retry_storm(client, target, method, header_map, body, retry, json_output).await;
+    }
+
+    let resp = deliver(client, target, method, &header_map, body.as_deref()).await?;
+
+    if json_output {
+        println!("{}", serde_json::to_string_pretty(&resp)?);
+    } else {
+        println!("  {} Sent {} to {} -> {}", green("✓"), bold(method), target.label(), status_label(&resp));
+    }
+
+    Ok(())
+}
+
+async fn deliver(
+    client: &ApiClient,
+    target: Target<'_>,
+    method: &str,
+    headers: &HashMap<String, String>,
+    body: Option<&str>,
+) -> Result<SendResponse> {
+    match target {
+        Target::Endpoint(slug) => {
+            let req = SendWebhookRequest {
+                method: method.to_uppercase(),
+                slug: slug.to_string(),
+                path: None,
+                headers: if headers.is_empty() { None } else { Some(headers.clone()) },
+                body: body.map(str::to_string),
+            };
+            client.send_webhook(&req).await
+        }
+        Target::Url(url) => client.send_to(url, method, headers, body).await,
+    }
+}
+
+/// One delivery made during a retry storm.
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct Delivery {
+    attempt: u32,
+    duplicate: bool,
+    offset_ms: u64,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    status: Option<u16>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    error: Option<String>,
+}
+
+/// Redeliver the same webhook the way providers do when a delivery fails:
+/// retries on an exponential, jittered schedule, plus optional near-simultaneous
+/// duplicates, all carrying one delivery ID so idempotency handling can be tested.
+async fn retry_storm(
+    client: &ApiClient,
+    target: Target<'_>,
+    method: &str,
+    mut headers: HashMap<String, String>,
+    body: Option<String>,
+    retry: &RetryStormArgs,
+    json_output: bool,
+) -> Result<()> {
+    let id_header = retry.delivery_id_header.trim();
+    if id_header.is_empty() {
+        bail!("--delivery-id-header cannot be empty");
+    }
+    let delivery_id = match headers.iter().find(|(k, _)| k.eq_ignore_ascii_case(id_header)) {
+        Some((_, v)) => v.clone(),
+        None => {
+            let id = format!("msg_{:016x}{:016x}", rand::random::<u64>(), rand::random::<u64>());
+            headers.insert(id_header.to_string(), id.clone());
+            id
+        }
+    };
+
+    let schedule = retry_schedule(
+        retry.retries,
+        Duration::from_millis(retry.retry_base),
+        f64::from(retry.retry_jitter) / 100.0,
+        rand::random::<f64>,
+    );
+    let attempts = retry.retries + 1;
+    let start = Instant::now();
+    let mut deliveries = Vec::new();
+
+    for attempt in 1..=attempts {
+        if attempt > 1 {
+            tokio::time::sleep(schedule[attempt as usize - 2]).await;
+        }
+
+        let sends = (0..=retry.duplicates).map(|copy| {
+            let headers = &headers;
+            let body = body.as_deref();
+            let lag = if copy == 0 {
+                Duration::ZERO
+            } else {
+                Duration::from_millis(rand::random_range(0..=DUPLICATE_WINDOW_MS))
+            };
+            async move {
+                tokio::time::sleep(lag).await;
+                let offset_ms = start.elapsed().as_millis() as u64;
+                let result = deliver(client, target, method, headers, body).await;
+                (copy > 0, offset_ms, result)
+            }
+        });
+
+        let mut succeeded = false;
+        for (duplicate, offset_ms, result) in futures::future::join_all(sends).await {
+            let (status, error) = match result {
+                Ok(resp) => {
+                    succeeded |= (200..300).contains(&resp.status);
+                    if !json_output {
+                        println!(
+                            "  {} Sent {} to {} (attempt {attempt}/{attempts}{}, +{offset_ms}ms) -> {}",
+                            green("✓"),
+                            bold(method),
+                            target.label(),
+                            if duplicate { ", duplicate" } else { "" },
+                            status_label(&resp)
+                        );
+                    }
+                    (Some(resp.status), None)
+                }
+                Err(e) => {
+                    if !json_output {
+                        println!(
+                            "  {} Attempt {attempt}/{attempts}{} failed: {e}",
+                            red("✗"),
+                            if duplicate { " (duplicate)" } else { "" }
+                        );
+                    }
+                    (None, Some(e.to_string()))
+                }
+            };
+            deliveries.push(Delivery {
+                attempt,
+                duplicate,
+                offset_ms,
+                status,
+                error,
+            });
+        }
+
+        if retry.stop_on_success && succeeded {
+            break;
+        }
+    }
+
+    if json_output {
+        println!(
+            "{}",
+            serde_json::to_string_pretty(&serde_json::json!({
+                "deliveryIdHeader": id_header,
+                "deliveryId": delivery_id,
+                "deliveries": deliveries,
+            }))?
+        );
+    } else {
+        println!("\n  {}", dim(&format!("{} deliveries with {id_header}: {delivery_id}", deliveries.len())));
+    }
 
-    let body = match data {
+    Ok(())
+}
+
+/// Delays before each retry: `base * 2^n`, capped at MAX_RETRY_DELAY, scaled
+/// by a random factor in `1 ± jitter`. `sample` yields values in `[0, 1)`.
+fn retry_schedule(
+    retries: u32,
+    base: Duration,
+    jitter: f64,
+    mut sample: impl FnMut() -> f64,
+) -> Vec<Duration> {
+    (0..retries)
+        .map(|n| {
+            let delay = base.saturating_mul(2u32.saturating_pow(n)).min(MAX_RETRY_DELAY);
+            let factor = 1.0 + jitter * (2.0 * sample() - 1.0);
+            delay.mul_f64(factor.max(0.0))
+        })
+        .collect()
+}
+
+fn status_label(resp: &SendResponse) -> String {
+    let label = format!("{} {}", resp.status, resp.status_text);
+    if resp.status < 400 {
+        return green(&label);
+    }
+    match resp.body.as_deref().and_then(receiver_error) {
+        Some((code, message)) => format!("{} {}", red(&label), dim(&format!("({code}: {message})"))),
+        None => red(&label),
+    }
+}
+
+/// Code and message from a webhooks.cc receiver error body, e.g.
+/// `{"code": "quota_exceeded", "message": "..."}`.
+fn receiver_error(body: &str) -> Option<(String, String)> {
+    let value: serde_json::Value = serde_json::from_str(body).ok()?;
+    let code = value.get("code")?.as_str()?;
+    let message = value.get("message")?.as_str()?;
+    Some((code.to_string(), message.to_string()))
+}
+
+/// Resolve `-d`: a literal body, or `@path` to read a file (max 10MB).
+pub(crate) fn read_body(data: Option<&str>) -> Result<Option<String>> {
+    match data {
         Some(d) if d.starts_with('@') => {
             let path = &d[1..];
             let file = std::fs::File::open(path)
@@ -84,31 +290,14 @@ pub async fn send_to_url(
             if contents.len() > 10 * 1024 * 1024 {
                 bail!("file too large (max 10MB)");
             }
-            Some(contents)
+            Ok(Some(contents))
         }
-        Some(d) => Some(d.to_string()),
-        None => None,
-    };
-
-    let resp = client
-        .send_to(url, method, &header_map, body.as_deref())
-        .await?;
-
-    if json_output {
-        println!("{}", serde_json::to_string_pretty(&resp)?);
-    } else {
-        let status_str = if resp.status < 400 {
-            green(&format!("{} {}", resp.status, resp.status_text))
-        } else {
-            red(&format!("{} {}", resp.status, resp.status_text))
-        };
-        println!("  {} Sent {} to {} -> {}", green("✓"), bold(method), dim(url), status_str);
+        Some(d) => Ok(Some(d.to_string())),
+        None => Ok(None),
     }
-
-    Ok(())
 }
 
-fn parse_headers(headers: &[String]) -> Result<HashMap<String, String>> {
+pub(crate) fn parse_headers(headers: &[String]) -> Result<HashMap<String, String>> {
     let mut map = HashMap::new();
     for h in headers {
         let (k, v) = h
@@ -118,3 +307,40 @@ fn parse_headers(headers: &[String]) -> Result<HashMap<String, String>> {
     }
     Ok(map)
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_receiver_error_parses_envelope() {
+        let body = r#"{"error":"not_found","code":"not_found","message":"No endpoint exists with this slug.","docs":"https://webhooks.cc/docs"}"#;
+        assert_eq!(
+            receiver_error(body),
+            Some(("not_found".into(), "No endpoint exists with this slug.".into()))
+        );
+        assert_eq!(receiver_error(r#"{"error":"not_found"}"#), None);
+        assert_eq!(receiver_error("OK"), None);
+    }
+
+    #[test]
+    fn test_retry_schedule_doubles_and_caps() {
+        let schedule = retry_schedule(8, Duration::from_secs(1), 0.0, || 0.5);
+        let secs: Vec<u64> = schedule.iter().map(Duration::as_secs).collect();
+        assert_eq!(secs, vec![1, 2, 4, 8, 16, 32, 60, 60]);
+    }
+
+    #[test]
+    fn test_retry_schedule_jitter_bounds() {
+        let base = Duration::from_millis(1000);
+        assert_eq!(retry_schedule(1, base, 0.2, || 0.0), vec![Duration::from_millis(800)]);
+        assert_eq!(retry_schedule(1, base, 0.2, || 0.5), vec![Duration::from_millis(1000)]);
+        let high = retry_schedule(1, base, 0.2, || 0.999_999)[0];
+        assert!(high > Duration::from_millis(1199) && high < Duration::from_millis(1200));
+    }
+
+    #[test]
+    fn test_retry_schedule_empty_without_retries() {
+        assert!(retry_schedule(0, Duration::from_secs(1), 0.2, || 0.5).is_empty());
+    }
+}
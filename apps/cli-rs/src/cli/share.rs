@@ -0,0 +1,157 @@
+use anyhow::Result;
+use std::collections::HashSet;
+use std::time::Duration;
+
+use crate::api::ApiClient;
+use crate::cli::output::{bold, dim, green, print_request_line, print_share_table, red};
+use crate::types::CapturedRequest;
+use crate::util::format::{format_timestamp, parse_duration};
+
+const TAIL_POLL_INTERVAL: Duration = Duration::from_secs(2);
+
+pub async fn create(client: &ApiClient, slug: &str, expires: &str, json: bool) -> Result<()> {
+    let ttl_ms = parse_duration(expires)?;
+    let share = client.create_share(slug, ttl_ms).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&share)?);
+        return Ok(());
+    }
+
+    let token = share.token.as_deref().unwrap_or_default();
+    println!("\n  {} Created read-only share for {}", green("✓"), bold(slug));
+    println!("  {} {}", dim("Token:"), token);
+    if let Some(expires_at) = share.expires_at {
+        println!("  {} {}", dim("Expires:"), format_timestamp(expires_at));
+    }
+    println!("\n  {}", dim("The token is shown only once. Recipients can tail the endpoint with:"));
+    println!("  whk share tail {token}\n");
+
+    Ok(())
+}
+
+pub async fn list(client: &ApiClient, slug: &str, json: bool) -> Result<()> {
+    let shares = client.list_shares(slug).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&shares)?);
+    } else {
+        print_share_table(&shares);
+    }
+
+    Ok(())
+}
+
+pub async fn revoke(client: &ApiClient, slug: &str, id: &str, json: bool) -> Result<()> {
+    client.revoke_share(slug, id).await?;
+
+    if json {
+        println!("{}", serde_json::json!({ "revoked": id }));
+    } else {
+        println!("  {} Revoked share {} for {}", red("✓"), bold(id), bold(slug));
+    }
+
+    Ok(())
+}
+
+pub async fn tail(client: &ApiClient, token: &str, limit: u32, json: bool) -> Result<()> {
+    let initial = client.list_shared_requests(token, Some(limit), None).await?;
+
+    if !json {
+        println!("\n  {} Tailing shared endpoint {} {}", green("●"), bold(&initial.endpoint.slug), dim("(read-only)"));
+        if let Some(expires_at) = initial.expires_at {
+            println!("  {} {}", dim("Access expires:"), format_timestamp(expires_at));
+        }
+        println!("  {}\n", dim("Press Ctrl+C to stop."));
+    }
+
+    let mut cursor = Cursor::default();
+    let mut requests = initial.requests;
+    requests.reverse();
+    for req in cursor.take_new(requests) {
+        print_tailed(&req, json);
+    }
+
+    loop {
+        tokio::select! {
+            _ = tokio::time::sleep(TAIL_POLL_INTERVAL) => {
+                let page = client.list_shared_requests(token, None, cursor.since).await?;
+                let mut requests = page.requests;
+                requests.reverse();
+                for req in cursor.take_new(requests) {
+                    print_tailed(&req, json);
+                }
+            }
+            _ = tokio::signal::ctrl_c() => break,
+        }
+    }
+
+    Ok(())
+}
+
+fn print_tailed(req: &CapturedRequest, json: bool) {
+    if json {
+        println!("{}", serde_json::to_string(req).unwrap_or_default());
+    } else {
+        print_request_line(req);
+    }
+}
+
+/// Tracks the newest timestamp seen. The API's `since` filter is inclusive,
+/// so requests at exactly that millisecond are deduplicated by ID.
+#[derive(Default)]
+struct Cursor {
+    since: Option<i64>,
+    seen_at_since: HashSet<String>,
+}
+
+impl Cursor {
+    /// Filter already-printed requests out of an oldest-first batch.
+    fn take_new(&mut self, requests: Vec<CapturedRequest>) -> Vec<CapturedRequest> {
+        let mut fresh = Vec::new();
+        for req in requests {
+            match self.since {
+                Some(since) if req.received_at < since => continue,
+                Some(since) if req.received_at == since => {
+                    if !self.seen_at_since.insert(req.id.clone()) {
+                        continue;
+                    }
+                }
+                _ => {
+                    self.since = Some(req.received_at);
+                    self.seen_at_since.clear();
+                    self.seen_at_since.insert(req.id.clone());
+                }
+            }
+            fresh.push(req);
+        }
+        fresh
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn req(id: &str, received_at: i64) -> CapturedRequest {
+        CapturedRequest {
+            id: id.into(),
+            received_at,
+            ..CapturedRequest::test_default()
+        }
+    }
+
+    #[test]
+    fn test_cursor_skips_duplicates_at_inclusive_since() {
+        let mut cursor = Cursor::default();
+        let first = cursor.take_new(vec![req("a", 100), req("b", 200)]);
+        assert_eq!(first.len(), 2);
+        assert_eq!(cursor.since, Some(200));
+
+        // Next poll uses since=200 and returns "b" again plus a new request at the same ms.
+        let second = cursor.take_new(vec![req("b", 200), req("c", 200), req("d", 300)]);
+        let ids: Vec<_> = second.iter().map(|r| r.id.as_str()).collect();
+        assert_eq!(ids, ["c", "d"]);
+        assert_eq!(cursor.since, Some(300));
+    }
+}
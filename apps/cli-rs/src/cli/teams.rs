@@ -0,0 +1,32 @@
+use anyhow::Result;
+
+use crate::api::ApiClient;
+use crate::cli::output::{print_team_members, print_team_table};
+
+pub async fn list(client: &ApiClient, json: bool) -> Result<()> {
+    let teams = client.list_teams().await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&teams)?);
+    } else {
+        print_team_table(&teams);
+    }
+
+    Ok(())
+}
+
+pub async fn members(client: &ApiClient, team: Option<&str>, json: bool) -> Result<()> {
+    let Some(query) = team.or(client.team()) else {
+        anyhow::bail!("No team given. Pass a team ID or name, or set --team.");
+    };
+    let team = client.resolve_team(query).await?;
+    let list = client.list_team_members(&team.id).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&list)?);
+    } else {
+        print_team_members(&team, &list);
+    }
+
+    Ok(())
+}
@@ -31,9 +31,13 @@ pub async fn run(
         None => {
             let req = CreateEndpointRequest {
                 name: None,
+                slug: None,
                 is_ephemeral: if ephemeral { Some(true) } else { None },
                 expires_at: None,
                 mock_response: None,
+                notification_url: None,
+                demo: None,
+                auto_extend: None,
             };
             let ep = client.create_endpoint(&req).await?;
             (ep.slug, true)
@@ -131,12 +135,22 @@ pub async fn run(
         }
     }
 
-    stream_handle.abort();
+    // A finished stream task means the stream itself ended; surface its error
+    // (e.g. a read-timeout stall) after cleanup.
+    let stream_result = if stream_handle.is_finished() {
+        stream_handle.await.ok()
+    } else {
+        stream_handle.abort();
+        None
+    };
 
     // Cleanup — only delete endpoints we created
     if created {
         let _ = client.delete_endpoint(&slug).await;
     }
 
-    Ok(())
+    match stream_result {
+        Some(Err(e)) => Err(e),
+        _ => Ok(()),
+    }
 }
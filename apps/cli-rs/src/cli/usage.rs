@@ -1,7 +1,7 @@
 use anyhow::Result;
 
 use crate::api::ApiClient;
-use crate::cli::output::print_usage;
+use crate::cli::output::{print_api_usage, print_usage};
 
 pub async fn run(client: &ApiClient, json: bool) -> Result<()> {
     let usage = client.get_usage().await?;
@@ -14,3 +14,15 @@ pub async fn run(client: &ApiClient, json: bool) -> Result<()> {
 
     Ok(())
 }
+
+pub async fn api(client: &ApiClient, json: bool) -> Result<()> {
+    let usage = client.get_api_usage().await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&usage)?);
+    } else {
+        print_api_usage(&usage);
+    }
+
+    Ok(())
+}
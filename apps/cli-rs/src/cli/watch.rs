@@ -0,0 +1,140 @@
+use anyhow::Result;
+use tokio::sync::mpsc;
+
+use crate::api::ApiClient;
+use crate::cli::output::{bold, dim, green, red, sanitize, yellow};
+use crate::types::{ConfigChange, ConfigChangeEvent, EndpointEvent};
+use crate::util::format::format_timestamp;
+
+/// Stream configuration changes on an endpoint until it expires, is deleted
+/// or the user stops watching.
+pub async fn run(client: &ApiClient, slug: &str, json: bool) -> Result<()> {
+    if !json {
+        println!("\n  {} Watching {} for configuration changes", green("●"), bold(slug));
+        println!("  {}\n", dim("Press Ctrl+C to stop."));
+    }
+
+    let (tx, mut rx) = mpsc::channel(64);
+    let stream_client = client.clone();
+    let stream_slug = slug.to_string();
+
+    let stream_handle = tokio::spawn(async move {
+        stream_client.stream_endpoint_events(&stream_slug, tx).await
+    });
+
+    loop {
+        tokio::select! {
+            event = rx.recv() => {
+                let Some(event) = event else { break };
+                match event {
+                    EndpointEvent::ConfigChanged(change) => {
+                        if json {
+                            let mut value = serde_json::to_value(&change)?;
+                            value["event"] = "config_changed".into();
+                            println!("{value}");
+                        } else {
+                            println!("  {}", describe(&change));
+                        }
+                    }
+                    EndpointEvent::Expired { expires_at } => {
+                        if json {
+                            println!("{}", serde_json::json!({ "event": "endpoint_expired", "expiresAt": expires_at }));
+                        } else {
+                            println!("\n  {} Endpoint expired at {}.", red("●"), format_timestamp(expires_at));
+                        }
+                        break;
+                    }
+                    EndpointEvent::Deleted => {
+                        if json {
+                            println!("{}", serde_json::json!({ "event": "endpoint_deleted" }));
+                        } else {
+                            println!("\n  {} Endpoint was deleted.", red("●"));
+                        }
+                        break;
+                    }
+                    EndpointEvent::Timeout => {
+                        if !json {
+                            println!("  {} Stream timed out.", dim("●"));
+                        }
+                    }
+                    EndpointEvent::Connected => {}
+                }
+            }
+            _ = tokio::signal::ctrl_c() => {
+                break;
+            }
+        }
+    }
+
+    if stream_handle.is_finished() {
+        if let Ok(Err(e)) = stream_handle.await {
+            return Err(e);
+        }
+    } else {
+        stream_handle.abort();
+    }
+    Ok(())
+}
+
+/// One line per version: time, version, source and what changed.
+fn describe(event: &ConfigChangeEvent) -> String {
+    let source = match (event.source.as_str(), event.rolled_back_to) {
+        ("rollback", Some(to)) => yellow(&format!("rollback to v{to}")),
+        (other, _) => sanitize(other),
+    };
+    let changes: Vec<String> = event.changes.iter().map(describe_change).collect();
+    let changes = if changes.is_empty() {
+        dim("no visible changes")
+    } else {
+        changes.join(", ")
+    };
+    format!(
+        "{} {} {}  {}",
+        dim(&format_timestamp(event.created_at)),
+        bold(&format!("v{:<3}", event.version)),
+        source,
+        changes
+    )
+}
+
+fn describe_change(change: &ConfigChange) -> String {
+    let field = match change.field.as_str() {
+        "mockResponse" => "mock response".to_string(),
+        "notificationUrl" => "notification URL".to_string(),
+        "functionSink" => "function sink".to_string(),
+        "bodyTransforms" => "body transforms".to_string(),
+        "infoHeaders" => "info headers".to_string(),
+        other => sanitize(other),
+    };
+    let verb = match change.change.as_str() {
+        "added" => green("added"),
+        "removed" => red("removed"),
+        other => yellow(&sanitize(other)),
+    };
+    format!("{field} {verb}")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_describe_change_labels_fields() {
+        let change = |field: &str, change: &str| ConfigChange {
+            field: field.into(),
+            change: change.into(),
+        };
+        assert_eq!(
+            describe_change(&change("notificationUrl", "added")),
+            format!("notification URL {}", green("added"))
+        );
+        assert_eq!(
+            describe_change(&change("mockResponse", "edited")),
+            format!("mock response {}", yellow("edited"))
+        );
+        assert_eq!(
+            describe_change(&change("somethingNew\x1b[2J", "removed")),
+            format!("somethingNew[2J {}", red("removed"))
+        );
+    }
+}
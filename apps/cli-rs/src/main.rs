@@ -1,8 +1,9 @@
-use anyhow::Result;
+use anyhow::{Context, Result};
 use clap::Parser;
 
 use whk::api::ApiClient;
-use whk::cli::{self, AuthAction, Cli, Command, RequestsAction};
+use whk::cli::complete::CompleteKind;
+use whk::cli::{self, AuthAction, BenchAction, Cli, Command, MockAction, RequestsAction, ShareAction, TeamsAction};
 use whk::tui;
 
 #[tokio::main]
@@ -15,6 +16,8 @@ async fn main() -> Result<()> {
         args.api_url.as_deref(),
         args.webhook_url.as_deref(),
     )?;
+    client.set_team(args.team);
+    client.set_reveal(args.reveal);
 
     let nogui = args.nogui || std::env::var("WHK_NOGUI").is_ok();
 
@@ -24,18 +27,28 @@ async fn main() -> Result<()> {
                 use clap::CommandFactory;
                 Cli::command().print_help()?;
             } else {
-                tui::run(client).await?;
+                tui::run(client, tui::Launch::Menu).await?;
             }
         }
 
+        Some(Command::Tui { endpoint, live, request }) => {
+            tui::run(client, tui::Launch::from_args(endpoint, live, request)).await?;
+        }
+
         Some(Command::Auth { action }) => match action {
             AuthAction::Login => cli::auth::login(&mut client, args.json).await?,
             AuthAction::Status => cli::auth::status(args.json).await?,
             AuthAction::Logout => cli::auth::logout(args.json).await?,
         },
 
-        Some(Command::Create { name, ephemeral, expires_in, mock_status, mock_body, mock_headers }) => {
-            cli::endpoints::create(&client, name, ephemeral, expires_in, mock_status, mock_body, mock_headers, args.json).await?;
+        Some(Command::Create { name, slug, check, ephemeral, expires_in, mock_status, mock_body, mock_headers, mock_echo, demo, demo_template, demo_rate, auto_extend, auto_extend_max }) => {
+            if check {
+                cli::endpoints::check_slug(&client, slug.as_deref().unwrap_or_default(), args.json).await?;
+            } else {
+                let demo = demo.map(|provider| whk::types::DemoConfig { provider, template: demo_template, per_minute: demo_rate });
+                let auto_extend = cli::endpoints::build_auto_extend(auto_extend, auto_extend_max)?;
+                cli::endpoints::create(&client, name, slug, ephemeral, expires_in, mock_status, mock_body, mock_headers, mock_echo, demo, auto_extend, args.json).await?;
+            }
         }
 
         Some(Command::List) => {
@@ -43,14 +56,205 @@ async fn main() -> Result<()> {
         }
 
         Some(Command::Get { slug }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
             cli::endpoints::get(&client, &slug, args.json).await?;
         }
 
-        Some(Command::UpdateEndpoint { slug, name, mock_status, mock_body, mock_headers, clear_mock }) => {
-            cli::endpoints::update_endpoint(&client, &slug, name, mock_status, mock_body, mock_headers, clear_mock, args.json).await?;
+        Some(Command::UpdateEndpoint { slug, name, mock_status, mock_body, mock_headers, mock_echo, clear_mock, info_headers, record_responses, canonical_json, dry_run_mode, query_overrides, auto_handshake, forward_url, clear_forward_url, priority_header, priority_values, clear_priority, encrypt_headers, clear_encrypted_headers, allow, deny, network_tags, capture_rejected, clear_network_policy, client_ca, clear_client_ca, verify_signature, signature_secret, signature_header, signature_algorithm, reject_invalid_signatures, clear_signature_verification, jwt_secret, jwt_jwks_url, jwt_header, jwt_issuer, jwt_audience, reject_invalid_jwts, clear_jwt_verification, basic_auth, api_key, api_key_header, clear_capture_auth, cors_origins, cors_methods, cors_headers, cors_credentials, cors_max_age, clear_cors, capture_only, skip_capture, clear_capture_filter, sample_rate, sample_per_minute, clear_sampling, chaos_percent, chaos_faults, clear_chaos, rate_limit, rate_limit_window, clear_rate_limit, schema_file, schema_failure_status, schema_failure_body, clear_schema_validation, redact, redact_headers, keep_headers, clear_redaction, custom_domain, clear_custom_domain, max_body_size, clear_max_body_size }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+            let encrypted_headers = if clear_encrypted_headers {
+                Some(serde_json::Value::Null)
+            } else if encrypt_headers.is_empty() {
+                None
+            } else {
+                Some(serde_json::json!(encrypt_headers))
+            };
+            let priority_rule = if clear_priority {
+                Some(serde_json::Value::Null)
+            } else {
+                priority_header.map(|header| serde_json::json!({"header": header, "values": priority_values}))
+            };
+            let network_policy = cli::endpoints::network_policy_edit(&allow, &deny, &network_tags, capture_rejected, clear_network_policy)?;
+            let capture_filter = cli::endpoints::capture_filter_edit(&capture_only, &skip_capture, clear_capture_filter)?;
+            let sampling = if clear_sampling {
+                Some(serde_json::Value::Null)
+            } else if sample_rate.is_none() && sample_per_minute.is_none() {
+                None
+            } else {
+                let mut config = serde_json::json!({});
+                if let Some(rate) = sample_rate {
+                    config["rate"] = rate.into();
+                }
+                if let Some(per_minute) = sample_per_minute {
+                    config["perMinute"] = per_minute.into();
+                }
+                Some(config)
+            };
+            let chaos = if clear_chaos {
+                Some(serde_json::Value::Null)
+            } else {
+                chaos_percent.map(|percent| {
+                    let mut config = serde_json::json!({"percent": percent});
+                    if !chaos_faults.is_empty() {
+                        config["faults"] = chaos_faults.into();
+                    }
+                    config
+                })
+            };
+            let rate_limit = if clear_rate_limit {
+                Some(serde_json::Value::Null)
+            } else {
+                rate_limit.map(|limit| serde_json::json!({"limit": limit, "window": rate_limit_window}))
+            };
+            let forward_url = if clear_forward_url {
+                Some(serde_json::Value::Null)
+            } else {
+                forward_url.map(serde_json::Value::String)
+            };
+            let client_ca = if clear_client_ca {
+                Some(serde_json::Value::Null)
+            } else if let Some(file) = client_ca {
+                let pem = std::fs::read_to_string(&file).with_context(|| format!("failed to read {file}"))?;
+                Some(serde_json::Value::String(pem))
+            } else {
+                None
+            };
+            let signature_verification = if clear_signature_verification {
+                Some(serde_json::Value::Null)
+            } else {
+                verify_signature.map(|provider| {
+                    let mut config = serde_json::json!({
+                        "provider": provider,
+                        "secret": signature_secret.unwrap_or_default(),
+                        "reject": reject_invalid_signatures,
+                    });
+                    if let Some(header) = signature_header {
+                        config["header"] = header.into();
+                    }
+                    if let Some(algorithm) = signature_algorithm {
+                        config["algorithm"] = algorithm.into();
+                    }
+                    config
+                })
+            };
+            let jwt_verification = if clear_jwt_verification {
+                Some(serde_json::Value::Null)
+            } else if jwt_secret.is_some() || jwt_jwks_url.is_some() {
+                let mut config = serde_json::json!({ "reject": reject_invalid_jwts });
+                for (key, value) in [
+                    ("secret", jwt_secret),
+                    ("jwksUrl", jwt_jwks_url),
+                    ("header", jwt_header),
+                    ("issuer", jwt_issuer),
+                    ("audience", jwt_audience),
+                ] {
+                    if let Some(value) = value {
+                        config[key] = value.into();
+                    }
+                }
+                Some(config)
+            } else {
+                None
+            };
+            let capture_auth = if clear_capture_auth {
+                Some(serde_json::Value::Null)
+            } else if let Some(credentials) = basic_auth {
+                let Some((username, password)) = credentials.split_once(':') else {
+                    anyhow::bail!("--basic-auth takes USER:PASS");
+                };
+                Some(serde_json::json!({
+                    "type": "basic",
+                    "username": username,
+                    "password": password,
+                }))
+            } else if let Some(key) = api_key {
+                let mut config = serde_json::json!({ "type": "api_key", "key": key });
+                if let Some(header) = api_key_header {
+                    config["header"] = header.into();
+                }
+                Some(config)
+            } else {
+                None
+            };
+            let cors = if clear_cors {
+                Some(serde_json::Value::Null)
+            } else if cors_origins.is_empty() {
+                None
+            } else {
+                let mut config = serde_json::json!({
+                    "origins": cors_origins,
+                    "methods": cors_methods,
+                    "headers": cors_headers,
+                    "credentials": cors_credentials,
+                });
+                if let Some(max_age) = cors_max_age {
+                    config["maxAge"] = max_age.into();
+                }
+                Some(config)
+            };
+            let schema_validation = if clear_schema_validation {
+                Some(serde_json::Value::Null)
+            } else if let Some(file) = schema_file {
+                let schema = std::fs::read_to_string(&file).with_context(|| format!("failed to read {file}"))?;
+                let schema: serde_json::Value = serde_json::from_str(&schema).with_context(|| format!("{file} isn't valid JSON"))?;
+                let mut config = serde_json::json!({ "schema": schema });
+                if let Some(status) = schema_failure_status {
+                    config["failureResponse"] = serde_json::json!({ "status": status });
+                    if let Some(body) = schema_failure_body {
+                        config["failureResponse"]["body"] = body.into();
+                    }
+                }
+                Some(config)
+            } else {
+                None
+            };
+            let redaction = if clear_redaction {
+                Some(serde_json::Value::Null)
+            } else if redact.is_empty() && redact_headers.is_empty() && keep_headers.is_empty() {
+                None
+            } else {
+                let (mut detectors, mut paths, mut patterns) = (Vec::new(), Vec::new(), Vec::new());
+                for rule in redact {
+                    if let Some(path) = rule.strip_prefix("path:") {
+                        paths.push(path.to_string());
+                    } else if let Some(pattern) = rule.strip_prefix("pattern:") {
+                        patterns.push(pattern.to_string());
+                    } else {
+                        detectors.push(rule);
+                    }
+                }
+                Some(serde_json::json!({
+                    "detectors": detectors,
+                    "paths": paths,
+                    "patterns": patterns,
+                    "headers": { "allow": keep_headers, "deny": redact_headers },
+                }))
+            };
+            let custom_domain = if clear_custom_domain {
+                Some(serde_json::Value::Null)
+            } else {
+                custom_domain.map(serde_json::Value::String)
+            };
+            let max_body_size = if clear_max_body_size {
+                Some(serde_json::Value::Null)
+            } else {
+                max_body_size.map(serde_json::Value::from)
+            };
+            cli::endpoints::update_endpoint(&client, &slug, name, mock_status, mock_body, mock_headers, mock_echo, clear_mock, info_headers, record_responses, canonical_json, dry_run_mode, query_overrides, auto_handshake, forward_url, priority_rule, encrypted_headers, network_policy, client_ca, signature_verification, jwt_verification, capture_auth, cors, capture_filter, sampling, chaos, rate_limit, schema_validation, redaction, custom_domain, max_body_size, args.json).await?;
+        }
+
+        Some(Command::Pause { slug, status, body }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+            cli::endpoints::pause(&client, &slug, status, body, args.json).await?;
+        }
+
+        Some(Command::Resume { slug }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+            cli::endpoints::resume(&client, &slug, args.json).await?;
         }
 
         Some(Command::Delete { slug, force }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
             cli::endpoints::delete(&client, &slug, force, args.json).await?;
         }
 
@@ -59,39 +263,115 @@ async fn main() -> Result<()> {
         }
 
         Some(Command::Listen { slug }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
             cli::listen::run(&client, &slug, args.json).await?;
         }
 
+        Some(Command::Watch { slug }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+            cli::watch::run(&client, &slug, args.json).await?;
+        }
+
         Some(Command::Replay { id, to }) => {
+            let id = cli::complete::resolve(&client, id, CompleteKind::Requests, args.json).await?;
             cli::replay::run(&client, &id, &to, args.json).await?;
         }
 
-        Some(Command::Send { slug, method, headers, data }) => {
-            cli::send::send_to_endpoint(&client, &slug, &method, headers, data.as_deref(), args.json).await?;
+        Some(Command::Annotate { id, message, clear_note, tags, untags }) => {
+            let id = cli::complete::resolve(&client, id, CompleteKind::Requests, args.json).await?;
+            cli::annotate::run(&client, &id, message, clear_note, tags, untags, args.json).await?;
+        }
+
+        Some(Command::Send { slug, method, headers, data, retry }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+            cli::send::send_to_endpoint(&client, &slug, &method, headers, data.as_deref(), &retry, args.json).await?;
         }
 
-        Some(Command::SendTo { url, method, headers, data }) => {
-            cli::send::send_to_url(&client, &url, &method, headers, data.as_deref(), args.json).await?;
+        Some(Command::SendTo { url, method, headers, data, retry }) => {
+            cli::send::send_to_url(&client, &url, &method, headers, data.as_deref(), &retry, args.json).await?;
         }
 
+        Some(Command::Mock { action }) => match action {
+            MockAction::Import { slug, from_url, method, headers, data, dry_run } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::mock::import(&client, &slug, &from_url, &method, headers, data.as_deref(), dry_run, args.json).await?;
+            }
+            MockAction::History { slug, rollback } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::mock::history(&client, &slug, rollback, args.json).await?;
+            }
+            MockAction::Canary { slug, promote, rollback } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::mock::canary(&client, &slug, promote, rollback, args.json).await?;
+            }
+        },
+
         Some(Command::Requests { action }) => match action {
-            RequestsAction::List { slug, limit, since, cursor } => {
-                cli::requests::list(&client, &slug, limit, since, cursor, args.json).await?;
+            RequestsAction::List { slug, limit, since, cursor, refresh, collapse, canonical, group, tag, event_type, country, asn } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::requests::list(&client, &slug, limit, since, cursor, refresh, collapse, canonical, group, tag.as_deref(), event_type.as_deref(), country.as_deref(), asn.as_deref(), args.json).await?;
+            }
+            RequestsAction::Get { id, refresh, follow_chain, delivery_id_header } => {
+                let id = cli::complete::resolve(&client, id, CompleteKind::Requests, args.json).await?;
+                cli::requests::get(&client, &id, refresh, follow_chain, delivery_id_header.as_deref(), args.json)
+                    .await?;
+            }
+            RequestsAction::Body { id, output } => {
+                let id = cli::complete::resolve(&client, id, CompleteKind::Requests, args.json).await?;
+                cli::requests::body(&client, &id, output.as_deref(), args.json).await?;
+            }
+            RequestsAction::Diff { a, b, refresh, canonical } => {
+                cli::requests::diff(&client, &a, &b, refresh, canonical, args.json).await?;
             }
-            RequestsAction::Get { id } => {
-                cli::requests::get(&client, &id, args.json).await?;
+            RequestsAction::Search { slug, method, q, from, to, content_class, limit, offset, order } => {
+                cli::requests::search(&client, slug.as_deref(), method.as_deref(), q.as_deref(), from.as_deref(), to.as_deref(), content_class.as_deref(), limit, offset, &order, args.json).await?;
             }
-            RequestsAction::Search { slug, method, q, from, to, limit, offset, order } => {
-                cli::requests::search(&client, slug.as_deref(), method.as_deref(), q.as_deref(), from.as_deref(), to.as_deref(), limit, offset, &order, args.json).await?;
+            RequestsAction::Count { slug, method, q, from, to, content_class } => {
+                cli::requests::count(&client, slug.as_deref(), method.as_deref(), q.as_deref(), from.as_deref(), to.as_deref(), content_class.as_deref(), args.json).await?;
             }
-            RequestsAction::Count { slug, method, q, from, to } => {
-                cli::requests::count(&client, slug.as_deref(), method.as_deref(), q.as_deref(), from.as_deref(), to.as_deref(), args.json).await?;
+            RequestsAction::Stats { slug, group_by, since, limit, top } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::requests::stats(&client, &slug, group_by.as_deref(), &since, limit, top, args.json).await?;
             }
             RequestsAction::Clear { slug, before, force } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
                 cli::requests::clear(&client, &slug, before.as_deref(), force, args.json).await?;
             }
-            RequestsAction::Export { slug, format, limit, since, output } => {
-                cli::requests::export(&client, &slug, &format, limit, since, output.as_deref(), args.json).await?;
+            RequestsAction::Export { slug, format, limit, since, output, headers, allow_masked } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::requests::export(&client, &slug, &format, limit, since, output.as_deref(), &headers, allow_masked, args.json).await?;
+            }
+            RequestsAction::Rejected { slug, limit, since } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::requests::rejected(&client, &slug, limit, since, args.json).await?;
+            }
+        },
+
+        Some(Command::Apply { file, dry_run, prune, yes }) => {
+            cli::apply::run(&client, &file, dry_run, prune, yes, args.json).await?;
+        }
+
+        Some(Command::Share { action }) => match action {
+            ShareAction::Create { slug, expires } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::share::create(&client, &slug, &expires, args.json).await?;
+            }
+            ShareAction::List { slug } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::share::list(&client, &slug, args.json).await?;
+            }
+            ShareAction::Revoke { slug, id } => {
+                cli::share::revoke(&client, &slug, &id, args.json).await?;
+            }
+            ShareAction::Tail { token, limit } => {
+                cli::share::tail(&client, &token, limit, args.json).await?;
+            }
+        },
+
+        Some(Command::Teams { action }) => match action {
+            TeamsAction::List => cli::teams::list(&client, args.json).await?,
+            TeamsAction::Members { team } => {
+                cli::teams::members(&client, team.as_deref(), args.json).await?;
             }
         },
 
@@ -99,6 +379,31 @@ async fn main() -> Result<()> {
             cli::usage::run(&client, args.json).await?;
         }
 
+        Some(Command::ApiUsage) => {
+            cli::usage::api(&client, args.json).await?;
+        }
+
+        Some(Command::Activity { follow, limit, event_type }) => {
+            cli::activity::run(&client, follow, limit, event_type.as_deref(), args.json).await?;
+        }
+
+        Some(Command::Bench { action }) => match action {
+            BenchAction::Stream { slug, count, concurrency, timeout } => {
+                let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+                cli::bench::stream(&client, &slug, count as usize, concurrency as usize, timeout, args.json).await?;
+            }
+        },
+
+        Some(Command::Latency { slug, window, prometheus }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+            cli::latency::run(&client, &slug, &window, prometheus, args.json).await?;
+        }
+
+        Some(Command::Report { slug, month, format, output }) => {
+            let slug = cli::complete::resolve(&client, slug, CompleteKind::Slugs, args.json).await?;
+            cli::report::run(&client, &slug, month.as_deref(), format.as_ref(), output.as_deref(), args.json).await?;
+        }
+
         Some(Command::Update) => {
             cli::update::run(args.json).await?;
         }
@@ -106,6 +411,13 @@ async fn main() -> Result<()> {
         Some(Command::Completions { shell }) => {
             use clap::CommandFactory;
             clap_complete::generate(shell, &mut Cli::command(), "whk", &mut std::io::stdout());
+            if let Some(hook) = cli::complete::shell_hook(shell) {
+                print!("{hook}");
+            }
+        }
+
+        Some(Command::Complete { kind }) => {
+            cli::complete::run(&client, kind).await;
         }
     }
 
@@ -0,0 +1,58 @@
+//! Deep links into the TUI: `whk tui --endpoint <slug> [--live]` and
+//! `whk tui --request <id>` open their screen over the menu, so Esc still
+//! leads back to it.
+
+/// Where the TUI opens.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub enum Launch {
+    #[default]
+    Menu,
+    /// Endpoint detail
+    Endpoint(String),
+    /// Live request stream for an endpoint
+    Live(String),
+    /// Request detail
+    Request(String),
+}
+
+impl Launch {
+    pub fn from_args(endpoint: Option<String>, live: bool, request: Option<String>) -> Self {
+        match (endpoint, request) {
+            (_, Some(id)) => Self::Request(id),
+            (Some(slug), None) if live => Self::Live(slug),
+            (Some(slug), None) => Self::Endpoint(slug),
+            (None, None) => Self::Menu,
+        }
+    }
+
+    /// The `whk` command that opens this view, for printing as a deep link.
+    pub fn command(&self) -> String {
+        match self {
+            Self::Menu => "whk tui".to_string(),
+            Self::Endpoint(slug) => format!("whk tui --endpoint {slug}"),
+            Self::Live(slug) => format!("whk tui --endpoint {slug} --live"),
+            Self::Request(id) => format!("whk tui --request {id}"),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn from_args() {
+        assert_eq!(Launch::from_args(None, false, None), Launch::Menu);
+        assert_eq!(Launch::from_args(Some("a".into()), false, None), Launch::Endpoint("a".into()));
+        assert_eq!(Launch::from_args(Some("a".into()), true, None), Launch::Live("a".into()));
+        assert_eq!(Launch::from_args(None, false, Some("r1".into())), Launch::Request("r1".into()));
+    }
+
+    #[test]
+    fn command() {
+        assert_eq!(Launch::Menu.command(), "whk tui");
+        assert_eq!(Launch::Endpoint("a".into()).command(), "whk tui --endpoint a");
+        assert_eq!(Launch::Live("stripe-dev".into()).command(), "whk tui --endpoint stripe-dev --live");
+        assert_eq!(Launch::Request("r1".into()).command(), "whk tui --request r1");
+    }
+}
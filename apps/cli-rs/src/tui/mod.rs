@@ -1,5 +1,6 @@
 pub mod event;
 pub mod keys;
+pub mod launch;
 pub mod screens;
 pub mod theme;
 pub mod widgets;
@@ -22,13 +23,14 @@ use crate::api::ApiClient;
 use crate::auth;
 
 use self::event::{spawn_event_reader, AppEvent};
+pub use self::launch::Launch;
 use self::screens::*;
 use self::widgets::header::Header;
 use self::widgets::status_bar::StatusBar;
 
 const TICK_RATE: Duration = Duration::from_millis(100);
 
-pub async fn run(client: ApiClient) -> Result<()> {
+pub async fn run(client: ApiClient, launch: Launch) -> Result<()> {
     // Install panic hook to restore terminal
     let original_hook = std::panic::take_hook();
     std::panic::set_hook(Box::new(move |info| {
@@ -44,14 +46,14 @@ pub async fn run(client: ApiClient) -> Result<()> {
     }
     let mut terminal = ratatui::init();
 
-    let result = run_app(&mut terminal, client).await;
+    let result = run_app(&mut terminal, client, launch).await;
 
     ratatui::restore();
 
     result
 }
 
-async fn run_app(terminal: &mut DefaultTerminal, client: ApiClient) -> Result<()> {
+async fn run_app(terminal: &mut DefaultTerminal, client: ApiClient, launch: Launch) -> Result<()> {
     let mut event_rx = spawn_event_reader(TICK_RATE);
     let (msg_tx, mut msg_rx) = mpsc::unbounded_channel::<Message>();
 
@@ -59,6 +61,9 @@ async fn run_app(terminal: &mut DefaultTerminal, client: ApiClient) -> Result<()
     let auth_email = auth::load_token().ok().flatten().map(|t| t.email);
 
     let mut app = App::new(client, auth_email, msg_tx.clone());
+    if let Some(screen) = launch_screen(launch) {
+        app.navigate_to(screen);
+    }
 
     loop {
         terminal.draw(|frame| app.render(frame))?;
@@ -93,6 +98,16 @@ async fn run_app(terminal: &mut DefaultTerminal, client: ApiClient) -> Result<()
     Ok(())
 }
 
+/// The screen a deep link opens over the menu.
+fn launch_screen(launch: Launch) -> Option<ScreenId> {
+    match launch {
+        Launch::Menu => None,
+        Launch::Endpoint(slug) => Some(ScreenId::EndpointDetail(slug)),
+        Launch::Live(slug) => Some(ScreenId::Listen(Some(slug))),
+        Launch::Request(id) => Some(ScreenId::RequestDetail(id)),
+    }
+}
+
 struct App {
     client: ApiClient,
     screen_stack: Vec<Box<dyn Screen>>,
@@ -159,7 +174,9 @@ impl App {
                 Box::new(screens::endpoint_detail::EndpointDetailScreen::new(slug, webhook_url))
             }
             ScreenId::Tunnel => Box::new(screens::tunnel::TunnelScreen::new(webhook_url)),
-            ScreenId::Listen => Box::new(screens::listen::ListenScreen::new(webhook_url)),
+            ScreenId::Listen(slug) => {
+                Box::new(screens::listen::ListenScreen::new(webhook_url, slug))
+            }
             ScreenId::RequestDetail(id) => {
                 Box::new(screens::request_detail::RequestDetailScreen::new(id))
             }
@@ -295,3 +312,4 @@ fn render_help_overlay(frame: &mut Frame, area: ratatui::layout::Rect) {
 
     frame.render_widget(Paragraph::new(lines), inner);
 }
+
@@ -81,6 +81,18 @@ impl Screen for EndpointDetailScreen {
             return None;
         }
 
+        // 'd' to collapse identical requests
+        if keys::is_char(key, 'd') {
+            self.requests.toggle_collapse();
+            return None;
+        }
+
+        // 't' to filter by tag
+        if keys::is_char(key, 't') {
+            self.requests.cycle_tag_filter();
+            return None;
+        }
+
         None
     }
 
@@ -198,6 +210,8 @@ impl Screen for EndpointDetailScreen {
             ("↑↓", "navigate"),
             ("enter", "inspect"),
             ("r", "refresh"),
+            ("d", "collapse dupes"),
+            ("t", "filter tag"),
             ("esc", "back"),
         ]
     }
@@ -66,9 +66,13 @@ impl Screen for EndpointsScreen {
                         let handle = tokio::spawn(async move {
                             let req = CreateEndpointRequest {
                                 name,
+                                slug: None,
                                 is_ephemeral: None,
                                 expires_at: None,
                                 mock_response: None,
+                                notification_url: None,
+                                demo: None,
+                                auto_extend: None,
                             };
                             let result = client.create_endpoint(&req).await;
                             let _ = tx.send(Message::EndpointCreated(result));
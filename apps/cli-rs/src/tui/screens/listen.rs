@@ -38,12 +38,13 @@ pub struct ListenScreen {
 }
 
 impl ListenScreen {
-    pub fn new(webhook_url: String) -> Self {
+    /// Listen on `slug` straight away, or pick an endpoint when `None`.
+    pub fn new(webhook_url: String, slug: Option<String>) -> Self {
         Self {
-            state: State::LoadingEndpoints,
+            state: if slug.is_some() { State::Connecting } else { State::LoadingEndpoints },
             endpoints: Vec::new(),
             table_state: TableState::default(),
-            slug: None,
+            slug,
             requests: RequestListState::new(),
             webhook_url,
             tx: None,
@@ -101,6 +102,10 @@ impl Screen for ListenScreen {
                 if keys::is_enter(key) && let Some(req) = self.requests.selected_item() {
                     return Some(Action::Navigate(ScreenId::RequestDetail(req.id.clone())));
                 }
+                if keys::is_char(key, 'd') {
+                    self.requests.toggle_collapse();
+                    return None;
+                }
             }
             State::Error(_) => {
                 if keys::is_back(key) || keys::is_enter(key) {
@@ -258,7 +263,12 @@ impl Screen for ListenScreen {
     fn status_keys(&self) -> Vec<(&str, &str)> {
         match &self.state {
             State::Picking => vec![("↑↓", "navigate"), ("enter", "select"), ("esc", "back")],
-            State::Streaming => vec![("↑↓", "navigate"), ("enter", "inspect"), ("esc", "stop")],
+            State::Streaming => vec![
+                ("↑↓", "navigate"),
+                ("enter", "inspect"),
+                ("d", "collapse dupes"),
+                ("esc", "stop"),
+            ],
             _ => vec![("esc", "back")],
         }
     }
@@ -34,7 +34,7 @@ const ITEMS: &[MenuItem] = &[
         label: "Listen",
         desc: "Stream incoming requests",
         icon: "◉",
-        screen: ScreenId::Listen,
+        screen: ScreenId::Listen(None),
     },
     MenuItem {
         key: "e",
@@ -70,7 +70,7 @@ pub enum ScreenId {
     Endpoints,
     EndpointDetail(String), // slug
     Tunnel,
-    Listen,
+    Listen(Option<String>), // slug, or pick one
     RequestDetail(String), // request ID
     Search,
     Send,
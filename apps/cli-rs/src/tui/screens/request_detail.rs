@@ -12,7 +12,8 @@ use crate::api::ApiClient;
 use crate::tui::{keys, theme};
 use crate::tui::widgets::spinner::Spinner;
 use crate::types::CapturedRequest;
-use crate::util::format::{format_bytes, format_timestamp};
+use crate::util::body::display_body;
+use crate::util::format::{format_bytes, format_origin, format_sizes, format_timestamp, format_tls};
 
 use super::{Action, Message, Screen};
 
@@ -261,6 +262,10 @@ fn render_overview(frame: &mut Frame, area: Rect, req: &CapturedRequest, scroll:
             Span::styled("  IP:           ", theme::style_muted()),
             Span::styled(&req.ip, theme::style()),
         ]),
+        Line::from(vec![
+            Span::styled("  Origin:       ", theme::style_muted()),
+            Span::styled(format_origin(req).unwrap_or_else(|| "unknown".into()), theme::style_muted()),
+        ]),
         Line::from(vec![
             Span::styled("  Size:         ", theme::style_muted()),
             Span::styled(format_bytes(req.size), theme::style()),
@@ -271,6 +276,22 @@ fn render_overview(frame: &mut Frame, area: Rect, req: &CapturedRequest, scroll:
         ]),
     ];
 
+    if let Some(ref sizes) = req.sizes {
+        // Explains a body that looks cut off or smaller than what was sent
+        let style = if sizes.truncated.is_empty() { theme::style_muted() } else { theme::style() };
+        lines.push(Line::from(vec![
+            Span::styled("  Sizes:        ", theme::style_muted()),
+            Span::styled(format_sizes(sizes), style),
+        ]));
+    }
+
+    if let Some(ref tls) = req.tls {
+        lines.push(Line::from(vec![
+            Span::styled("  TLS:          ", theme::style_muted()),
+            Span::styled(format_tls(tls), theme::style()),
+        ]));
+    }
+
     if let Some(ref ct) = req.content_type {
         lines.push(Line::from(vec![
             Span::styled("  Content-Type: ", theme::style_muted()),
@@ -278,6 +299,43 @@ fn render_overview(frame: &mut Frame, area: Rect, req: &CapturedRequest, scroll:
         ]));
     }
 
+    if let Some(ref class) = req.content_class {
+        lines.push(Line::from(vec![
+            Span::styled("  Body class:   ", theme::style_muted()),
+            Span::styled(class.as_str(), theme::style()),
+        ]));
+    }
+
+    if !req.tags.is_empty() {
+        let tags: Vec<String> = req.tags.iter().map(|t| format!("#{t}")).collect();
+        lines.push(Line::from(vec![
+            Span::styled("  Tags:         ", theme::style_muted()),
+            Span::styled(tags.join(" "), Style::default().fg(theme::ACCENT)),
+        ]));
+    }
+
+    if !req.masked.is_empty() {
+        let fields: Vec<String> = req.masked.iter().map(|(field, reason)| format!("{field} ({reason})")).collect();
+        lines.push(Line::from(vec![
+            Span::styled("  Masked:       ", theme::style_muted()),
+            Span::styled(fields.join(", "), Style::default().fg(theme::ACCENT)),
+        ]));
+        if req.has_encrypted() {
+            lines.push(Line::from(Span::styled(
+                "                Encrypted values open with whk --reveal",
+                theme::style_dim(),
+            )));
+        }
+    }
+
+    if let Some(ref note) = req.note {
+        lines.push(Line::from(""));
+        lines.push(Line::from(Span::styled("  Note", theme::style_primary_bold())));
+        for line in note.lines() {
+            lines.push(Line::from(Span::styled(format!("    {line}"), theme::style())));
+        }
+    }
+
     if !req.query_params.is_empty() {
         lines.push(Line::from(""));
         lines.push(Line::from(Span::styled("  Query Parameters", theme::style_primary_bold())));
@@ -285,7 +343,7 @@ fn render_overview(frame: &mut Frame, area: Rect, req: &CapturedRequest, scroll:
             lines.push(Line::from(vec![
                 Span::styled(format!("    {k}"), theme::style_bold()),
                 Span::styled(" = ", theme::style_muted()),
-                Span::styled(v.as_str(), theme::style()),
+                Span::styled(v.as_str(), value_style(req, &format!("query:{k}"))),
             ]));
         }
     }
@@ -301,6 +359,15 @@ fn render_overview(frame: &mut Frame, area: Rect, req: &CapturedRequest, scroll:
     frame.render_widget(p, area);
 }
 
+/// Masked values stand out from what was actually sent.
+fn value_style(req: &CapturedRequest, field: &str) -> Style {
+    if req.mask(field).is_some() {
+        Style::default().fg(theme::ACCENT)
+    } else {
+        theme::style()
+    }
+}
+
 fn render_headers(frame: &mut Frame, area: Rect, req: &CapturedRequest, scroll: u16) {
     let mut headers: Vec<_> = req.headers.iter().collect();
     headers.sort_by_key(|(k, _)| k.to_lowercase());
@@ -310,7 +377,7 @@ fn render_headers(frame: &mut Frame, area: Rect, req: &CapturedRequest, scroll:
         lines.push(Line::from(vec![
             Span::styled(format!("  {k}"), theme::style_bold()),
             Span::styled(": ", theme::style_muted()),
-            Span::styled(v.as_str(), theme::style()),
+            Span::styled(v.as_str(), value_style(req, &format!("header:{k}"))),
         ]));
     }
 
@@ -324,20 +391,12 @@ fn render_headers(frame: &mut Frame, area: Rect, req: &CapturedRequest, scroll:
 }
 
 fn render_body(frame: &mut Frame, area: Rect, req: &CapturedRequest, scroll: u16) {
-    let body = match &req.body {
-        Some(b) if !b.is_empty() => b.clone(),
-        _ => {
-            let p = Paragraph::new(Line::from(Span::styled("  No body.", theme::style_muted())));
-            frame.render_widget(p, area);
-            return;
-        }
-    };
-
-    // Try to pretty-print JSON
-    let formatted = if let Ok(val) = serde_json::from_str::<serde_json::Value>(&body) {
-        serde_json::to_string_pretty(&val).unwrap_or(body)
-    } else {
-        body
+    let Some(formatted) =
+        display_body(req.content_class.as_deref(), req.body_raw.as_deref(), req.body.as_deref())
+    else {
+        let p = Paragraph::new(Line::from(Span::styled("  No body.", theme::style_muted())));
+        frame.render_widget(p, area);
+        return;
     };
 
     let lines: Vec<Line> = std::iter::once(Line::from(""))
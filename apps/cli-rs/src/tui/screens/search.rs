@@ -96,6 +96,7 @@ impl SearchScreen {
                         q.as_deref(),
                         None,
                         None,
+                        None,
                         Some(50),
                         None,
                         Some("desc"),
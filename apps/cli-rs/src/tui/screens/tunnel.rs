@@ -321,9 +321,13 @@ impl TunnelScreen {
             let handle = tokio::spawn(async move {
                 let req = CreateEndpointRequest {
                     name: None,
+                    slug: None,
                     is_ephemeral: Some(true),
                     expires_at: None,
                     mock_response: None,
+                    notification_url: None,
+                    demo: None,
+                    auto_extend: None,
                 };
                 let result = client.create_endpoint(&req).await;
                 let _ = tx.send(Message::EndpointCreated(result));
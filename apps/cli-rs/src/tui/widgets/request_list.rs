@@ -8,6 +8,7 @@ use ratatui::{
 
 use crate::tui::theme;
 use crate::types::CapturedRequest;
+use crate::util::duplicates::{self, CollapsedRow};
 use crate::util::format::format_bytes;
 
 /// State for the scrollable request list.
@@ -15,6 +16,10 @@ pub struct RequestListState {
     pub selected: usize,
     pub offset: usize,
     pub items: Vec<CapturedRequest>,
+    /// Show requests the receiver flagged as identical as one row
+    pub collapse_duplicates: bool,
+    /// Only show requests carrying this tag
+    pub tag_filter: Option<String>,
 }
 
 impl Default for RequestListState {
@@ -29,12 +34,31 @@ impl RequestListState {
             selected: 0,
             offset: 0,
             items: Vec::new(),
+            collapse_duplicates: false,
+            tag_filter: None,
+        }
+    }
+
+    /// Rows as displayed: one per request, or one per group of identical
+    /// requests when collapsing.
+    fn rows(&self) -> Vec<CollapsedRow> {
+        let keep = |req: &CapturedRequest| {
+            self.tag_filter.as_ref().is_none_or(|tag| req.tags.contains(tag))
+        };
+        if self.collapse_duplicates {
+            duplicates::collapse_where(&self.items, keep)
+        } else {
+            (0..self.items.len())
+                .filter(|&index| keep(&self.items[index]))
+                .map(|index| CollapsedRow { index, count: 1 })
+                .collect()
         }
     }
 
     pub fn select_next(&mut self) {
-        if !self.items.is_empty() {
-            self.selected = (self.selected + 1).min(self.items.len() - 1);
+        let len = self.rows().len();
+        if len > 0 {
+            self.selected = (self.selected + 1).min(len - 1);
         }
     }
 
@@ -43,15 +67,73 @@ impl RequestListState {
     }
 
     pub fn selected_item(&self) -> Option<&CapturedRequest> {
-        self.items.get(self.selected)
+        self.rows().get(self.selected).map(|row| &self.items[row.index])
     }
 
     pub fn push(&mut self, req: CapturedRequest) {
+        // Keep selection stable: new requests go to the top (and a duplicate
+        // moves its group there), so follow the selected row rather than
+        // its position.
+        let selected = self.selection_key();
         self.items.insert(0, req);
-        // Keep selection stable — after insert at front, the previously selected
-        // item has shifted down by 1, so increment to keep pointing at it
-        if !self.items.is_empty() && self.items.len() > 1 {
-            self.selected += 1;
+        self.restore_selection(selected);
+    }
+
+    pub fn toggle_collapse(&mut self) {
+        let selected = self.selected_item().map(|req| req.id.clone());
+        self.collapse_duplicates = !self.collapse_duplicates;
+        // The selected request may be hidden inside a group now; select the
+        // group it belongs to.
+        let key = selected.and_then(|id| {
+            let req = self.items.iter().find(|r| r.id == id)?;
+            Some(self.row_key(req))
+        });
+        self.restore_selection(key);
+    }
+
+    /// Step the tag filter through the tags present in the list, then off.
+    pub fn cycle_tag_filter(&mut self) {
+        let mut tags: Vec<&String> = self.items.iter().flat_map(|req| &req.tags).collect();
+        tags.sort();
+        tags.dedup();
+        let next = match self.tag_filter {
+            None => tags.first().map(|t| t.to_string()),
+            Some(ref current) => tags
+                .iter()
+                .position(|t| *t == current)
+                .and_then(|pos| tags.get(pos + 1))
+                .map(|t| t.to_string()),
+        };
+        let selected = self.selection_key();
+        self.tag_filter = next;
+        self.selected = 0;
+        self.offset = 0;
+        self.restore_selection(selected);
+    }
+
+    /// Identity of a row: its request, or its duplicate group when collapsing.
+    fn row_key(&self, req: &CapturedRequest) -> String {
+        if self.collapse_duplicates {
+            req.duplicate_of.clone().unwrap_or_else(|| req.id.clone())
+        } else {
+            req.id.clone()
+        }
+    }
+
+    fn selection_key(&self) -> Option<String> {
+        self.selected_item().map(|req| self.row_key(req))
+    }
+
+    fn restore_selection(&mut self, key: Option<String>) {
+        let Some(key) = key else {
+            return;
+        };
+        if let Some(pos) = self
+            .rows()
+            .iter()
+            .position(|row| self.row_key(&self.items[row.index]) == key)
+        {
+            self.selected = pos;
         }
     }
 }
@@ -89,6 +171,24 @@ impl StatefulWidget for RequestList<'_> {
             .border_style(Style::default().fg(theme::BORDER))
             .padding(Padding::horizontal(1));
 
+        let rows = state.rows();
+        let hidden = duplicates::hidden_count(&rows);
+        let block = if hidden > 0 {
+            block.title_bottom(Span::styled(
+                format!(" {hidden} identical collapsed "),
+                theme::style_muted(),
+            ))
+        } else {
+            block
+        };
+        let block = match state.tag_filter {
+            Some(ref tag) => block.title_bottom(Span::styled(
+                format!(" #{tag} "),
+                Style::default().fg(theme::ACCENT),
+            )),
+            None => block,
+        };
+
         let inner = block.inner(area);
         block.render(area, buf);
 
@@ -112,12 +212,13 @@ impl StatefulWidget for RequestList<'_> {
             state.offset = state.selected - visible_height + 1;
         }
 
-        for (i, idx) in (state.offset..state.items.len())
+        for (i, pos) in (state.offset..rows.len())
             .take(visible_height)
             .enumerate()
         {
-            let req = &state.items[idx];
-            let is_selected = idx == state.selected;
+            let row = rows[pos];
+            let req = &state.items[row.index];
+            let is_selected = pos == state.selected;
             let y = inner.y + i as u16;
 
             // Time
@@ -146,7 +247,7 @@ impl StatefulWidget for RequestList<'_> {
                 Style::default().fg(theme::SURFACE).bg(bg)
             };
 
-            let line = Line::from(vec![
+            let mut spans = vec![
                 Span::styled(indicator, indicator_style),
                 Span::styled(&time, Style::default().fg(theme::TEXT_DIM).bg(bg)),
                 Span::styled("  ", Style::default().bg(bg)),
@@ -154,7 +255,24 @@ impl StatefulWidget for RequestList<'_> {
                 Span::styled(&req.path, Style::default().fg(theme::TEXT).bg(bg)),
                 Span::styled("  ", Style::default().bg(bg)),
                 Span::styled(&size_str, Style::default().fg(theme::MUTED).bg(bg)),
-            ]);
+            ];
+            if row.count > 1 {
+                spans.push(Span::styled(
+                    format!("  ×{}", row.count),
+                    Style::default().fg(theme::ACCENT).bg(bg),
+                ));
+            }
+            if !req.tags.is_empty() {
+                let tags: Vec<String> = req.tags.iter().map(|t| format!("#{t}")).collect();
+                spans.push(Span::styled(
+                    format!("  {}", tags.join(" ")),
+                    Style::default().fg(theme::ACCENT).bg(bg),
+                ));
+            }
+            if req.note.is_some() {
+                spans.push(Span::styled(" ✎", Style::default().fg(theme::MUTED).bg(bg)));
+            }
+            let line = Line::from(spans);
 
             buf.set_line(inner.x, y, &line, inner.width);
 
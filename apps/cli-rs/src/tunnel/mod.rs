@@ -36,6 +36,19 @@ const PROXY_HEADERS: &[&str] = &[
     "x-real-ip",
 ];
 
+/// Hop-by-hop headers describe the sender's connection to the receiver, not the
+/// request itself. Forwarding them (e.g. `expect: 100-continue` or
+/// `transfer-encoding: chunked` alongside a buffered body) confuses local servers.
+const HOP_BY_HOP_HEADERS: &[&str] = &[
+    "connection",
+    "expect",
+    "keep-alive",
+    "te",
+    "trailer",
+    "transfer-encoding",
+    "upgrade",
+];
+
 pub struct Tunnel {
     http: reqwest::Client,
     target_base: String,
@@ -123,7 +136,7 @@ impl Tunnel {
 }
 
 fn should_filter_header(lower: &str) -> bool {
-    if SENSITIVE_HEADERS.contains(&lower) {
+    if SENSITIVE_HEADERS.contains(&lower) || HOP_BY_HOP_HEADERS.contains(&lower) {
         return true;
     }
     for prefix in PROXY_HEADERS {
@@ -248,6 +261,13 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_filter_hop_by_hop_headers() {
+        for h in &["connection", "expect", "keep-alive", "te", "trailer", "transfer-encoding", "upgrade"] {
+            assert!(should_filter_header(h), "should filter: {h}");
+        }
+    }
+
     #[test]
     fn test_filter_all_proxy_headers() {
         for h in &["cdn-loop", "cf-connecting-ip", "cf-ray", "x-forwarded-for", "x-forwarded-proto", "x-real-ip", "via", "true-client-ip"] {
@@ -1,5 +1,5 @@
 use serde::{Deserialize, Serialize};
-use std::collections::HashMap;
+use std::collections::{BTreeMap, HashMap};
 use std::fmt;
 
 // ---------------------------------------------------------------------------
@@ -24,12 +24,467 @@ pub struct Endpoint {
     pub request_count: Option<u64>,
     #[serde(rename = "mockResponse", default)]
     pub mock_response: Option<MockResponse>,
+    #[serde(rename = "notificationUrl", default, skip_serializing_if = "Option::is_none")]
+    pub notification_url: Option<String>,
+    #[serde(rename = "functionSink", default, skip_serializing_if = "Option::is_none")]
+    pub function_sink: Option<FunctionSink>,
+    #[serde(rename = "bodyTransforms", default, skip_serializing_if = "Option::is_none")]
+    pub body_transforms: Option<Vec<serde_json::Value>>,
+    #[serde(rename = "infoHeaders", default)]
+    pub info_headers: bool,
+    #[serde(rename = "recordResponses", default)]
+    pub record_responses: bool,
+    #[serde(rename = "canonicalJson", default)]
+    pub canonical_json: bool,
+    /// Requests are answered and counted without being stored
+    #[serde(rename = "dryRun", default)]
+    pub dry_run: bool,
+    /// `__status` and `__delay` query parameters override the reply
+    #[serde(rename = "queryOverrides", default)]
+    pub query_overrides: bool,
+    /// Provider verification requests (Slack, Zoom, Dropbox, Microsoft Graph) are answered
+    #[serde(rename = "autoHandshake", default)]
+    pub auto_handshake: bool,
+    /// Backend requests are relayed to, answering with its reply
+    #[serde(rename = "forwardUrl", default, skip_serializing_if = "Option::is_none")]
+    pub forward_url: Option<String>,
+    #[serde(rename = "priorityRule", default, skip_serializing_if = "Option::is_none")]
+    pub priority_rule: Option<PriorityRule>,
+    #[serde(rename = "encryptedHeaders", default, skip_serializing_if = "Vec::is_empty")]
+    pub encrypted_headers: Vec<String>,
+    #[serde(rename = "networkPolicy", default, skip_serializing_if = "Option::is_none")]
+    pub network_policy: Option<NetworkPolicy>,
+    /// PEM CA bundle client certificates must chain to
+    #[serde(rename = "clientCa", default, skip_serializing_if = "Option::is_none")]
+    pub client_ca: Option<String>,
+    /// How the receiver checks the sender's signature (the secret isn't returned)
+    #[serde(rename = "signatureVerification", default, skip_serializing_if = "Option::is_none")]
+    pub signature_verification: Option<SignatureVerification>,
+    /// How the receiver validates the sender's JWT (a secret isn't returned)
+    #[serde(rename = "jwtVerification", default, skip_serializing_if = "Option::is_none")]
+    pub jwt_verification: Option<JwtVerification>,
+    /// Credentials senders must present (the password or key isn't returned)
+    #[serde(rename = "captureAuth", default, skip_serializing_if = "Option::is_none")]
+    pub capture_auth: Option<CaptureAuth>,
+    /// Origins, methods and headers browsers may use; any origin when unset
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub cors: Option<CorsConfig>,
+    /// Which requests are stored; every request when unset
+    #[serde(rename = "captureFilter", default, skip_serializing_if = "Option::is_none")]
+    pub capture_filter: Option<CaptureFilter>,
+    /// How much of the traffic is stored; all of it when unset
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub sampling: Option<SamplingConfig>,
+    /// Share of requests answered with an injected fault; off when unset
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub chaos: Option<ChaosConfig>,
+    /// Requests per window answered before the rest get a 429; off when unset
+    #[serde(rename = "rateLimit", default, skip_serializing_if = "Option::is_none")]
+    pub rate_limit: Option<RateLimitConfig>,
+    /// JSON Schema each body is checked against, and the reply for failures
+    #[serde(rename = "schemaValidation", default, skip_serializing_if = "Option::is_none")]
+    pub schema_validation: Option<serde_json::Value>,
+    /// Detectors, body paths and patterns replaced with [REDACTED] before storage
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub redaction: Option<serde_json::Value>,
+    /// Changed mock response served to a share of senders before it replaces `mock_response`
+    #[serde(rename = "mockCanary", default, skip_serializing_if = "Option::is_none")]
+    pub mock_canary: Option<MockCanary>,
+    /// Hostname routed to the endpoint (Pro)
+    #[serde(rename = "customDomain", default, skip_serializing_if = "Option::is_none")]
+    pub custom_domain: Option<String>,
+    /// Bodies over this many bytes are captured cut short; the plan's limit when unset
+    #[serde(rename = "maxBodySize", default, skip_serializing_if = "Option::is_none")]
+    pub max_body_size: Option<u64>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub demo: Option<DemoConfig>,
+    #[serde(rename = "autoExtend", default, skip_serializing_if = "Option::is_none")]
+    pub auto_extend: Option<AutoExtend>,
+    #[serde(rename = "pausedAt", default, skip_serializing_if = "Option::is_none")]
+    pub paused_at: Option<i64>,
+    #[serde(rename = "pausedResponse", default, skip_serializing_if = "Option::is_none")]
+    pub paused_response: Option<PausedResponse>,
     #[serde(rename = "sharedWith", default)]
     pub shared_with: Vec<TeamShare>,
     #[serde(rename = "fromTeam", default)]
     pub from_team: Option<TeamShare>,
 }
 
+/// Synthetic captures an ephemeral endpoint generates (demo mode).
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct DemoConfig {
+    pub provider: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub template: Option<String>,
+    #[serde(rename = "perMinute", skip_serializing_if = "Option::is_none")]
+    pub per_minute: Option<u32>,
+}
+
+/// Sliding expiry: each capture pushes `expiresAt` to `idleMs` after it, up to `until`.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct AutoExtend {
+    #[serde(rename = "idleMs")]
+    pub idle_ms: i64,
+    pub until: i64,
+}
+
+/// Auto-extension windows requested at creation; the server fills in defaults.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct AutoExtendRequest {
+    #[serde(rename = "idleMs", skip_serializing_if = "Option::is_none")]
+    pub idle_ms: Option<i64>,
+    #[serde(rename = "maxMs", skip_serializing_if = "Option::is_none")]
+    pub max_ms: Option<i64>,
+}
+
+/// HMAC signature check applied at capture, as returned by the API.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SignatureVerification {
+    pub provider: String,
+    pub algorithm: String,
+    pub header: String,
+    #[serde(default)]
+    pub reject: bool,
+}
+
+/// JWT validation applied at capture, as returned by the API.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct JwtVerification {
+    pub header: String,
+    /// `secret` or `jwks`
+    pub mode: String,
+    #[serde(rename = "jwksUrl", default)]
+    pub jwks_url: Option<String>,
+    #[serde(default)]
+    pub issuer: Option<String>,
+    #[serde(default)]
+    pub audience: Option<String>,
+    #[serde(default)]
+    pub reject: bool,
+}
+
+/// Credentials an endpoint requires before capturing, as returned by the API.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CaptureAuth {
+    /// `basic` or `api_key`
+    #[serde(rename = "type")]
+    pub kind: String,
+    #[serde(default)]
+    pub username: Option<String>,
+    #[serde(default)]
+    pub header: Option<String>,
+}
+
+/// An endpoint's CORS config. Empty `methods` and `headers` allow any.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CorsConfig {
+    pub origins: Vec<String>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub methods: Vec<String>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub headers: Vec<String>,
+    #[serde(default)]
+    pub credentials: bool,
+    /// Seconds browsers may cache a preflight answer
+    #[serde(rename = "maxAge", default, skip_serializing_if = "Option::is_none")]
+    pub max_age: Option<u32>,
+}
+
+/// An endpoint's capture filter: requests matching an `include` rule (or any
+/// when there are none) and no `exclude` rule are stored.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CaptureFilter {
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub include: Vec<CaptureFilterRule>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub exclude: Vec<CaptureFilterRule>,
+}
+
+/// Conditions that must all hold for a capture filter rule to match.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CaptureFilterRule {
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub methods: Vec<String>,
+    /// Glob over the captured path
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub path: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub header: Option<CaptureFilterHeader>,
+    /// Regular expression found in the body
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub body: Option<String>,
+}
+
+/// A header a capture filter rule requires, optionally matching a regex.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CaptureFilterHeader {
+    pub name: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub value: Option<String>,
+}
+
+/// An endpoint's traffic sampling: a request is stored when it passes both limits.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SamplingConfig {
+    /// Store one request in this many
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub rate: Option<u64>,
+    /// Store at most this many requests a minute (per receiver instance)
+    #[serde(rename = "perMinute", default, skip_serializing_if = "Option::is_none")]
+    pub per_minute: Option<u64>,
+}
+
+/// An endpoint's chaos mode: `percent` of requests get one of `faults`
+/// (`error`, `hang` or `reset`), picked evenly, instead of their reply.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ChaosConfig {
+    pub percent: f64,
+    /// Faults to pick from; all of them when empty
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub faults: Vec<String>,
+}
+
+/// An endpoint's simulated rate limit: past `limit` requests in a fixed
+/// `window` of seconds, requests are answered with a 429.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RateLimitConfig {
+    pub limit: u64,
+    pub window: u64,
+}
+
+/// One way a captured body broke the endpoint's JSON Schema.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SchemaError {
+    /// JSON pointer to the failing value; empty for the whole body
+    pub path: String,
+    pub keyword: String,
+    pub message: String,
+}
+
+/// Header that marks an endpoint's captures high priority. Any value counts
+/// when `values` is empty; otherwise the value must be one of them.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PriorityRule {
+    pub header: String,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub values: Vec<String>,
+}
+
+/// Countries and networks an endpoint accepts or tags requests from.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct NetworkPolicy {
+    /// Requests matching none of these are rejected
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub allow: Option<NetworkRule>,
+    /// Requests matching any of these are rejected
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub deny: Option<NetworkRule>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub tags: Vec<NetworkTagRule>,
+    /// Keep rejected requests in the rejected bucket
+    #[serde(rename = "captureRejected", default, skip_serializing_if = "std::ops::Not::not")]
+    pub capture_rejected: bool,
+}
+
+/// Two-letter country codes (as reported by Cloudflare), CIDR ranges and
+/// providers whose published webhook ranges match (`github`, `stripe`).
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct NetworkRule {
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub countries: Vec<String>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub cidrs: Vec<String>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub providers: Vec<String>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct NetworkTagRule {
+    pub tag: String,
+    #[serde(flatten)]
+    pub rule: NetworkRule,
+}
+
+/// Requests that matched one network policy rule since the policy changed.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct NetworkMatch {
+    /// `blocked` for the allow rule, `denied` for the deny rule, `tag:<name>` for a tag rule
+    pub rule: String,
+    pub matched: u64,
+    #[serde(rename = "lastMatchedAt")]
+    pub last_matched_at: i64,
+}
+
+/// A request the network policy rejected, kept while it sets `captureRejected`.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RejectedRequest {
+    pub id: String,
+    /// `denied` for the deny rule, `blocked` for requests outside the allow rule
+    pub reason: String,
+    pub method: String,
+    pub path: String,
+    pub ip: String,
+    #[serde(default)]
+    pub country: Option<String>,
+    #[serde(rename = "userAgent", default)]
+    pub user_agent: Option<String>,
+    #[serde(rename = "receivedAt")]
+    pub received_at: i64,
+}
+
+/// Counters kept while an endpoint is in dry-run mode, since it was turned on.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct DryRunStats {
+    pub requests: u64,
+    pub bytes: u64,
+    /// Requests answered with the mock response
+    pub mocked: u64,
+    #[serde(rename = "firstRequestAt")]
+    pub first_request_at: Option<i64>,
+    #[serde(rename = "lastRequestAt")]
+    pub last_request_at: Option<i64>,
+}
+
+/// Counters kept while an endpoint samples its traffic, since its sampling
+/// config last changed.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SamplingStats {
+    /// Requests received, stored or not
+    pub requests: u64,
+    pub bytes: u64,
+    /// Requests answered but not stored
+    #[serde(rename = "sampledOut")]
+    pub sampled_out: u64,
+    #[serde(rename = "sampledOutBytes")]
+    pub sampled_out_bytes: u64,
+    #[serde(rename = "firstRequestAt")]
+    pub first_request_at: Option<i64>,
+    #[serde(rename = "lastRequestAt")]
+    pub last_request_at: Option<i64>,
+}
+
+/// A changed mock response served to `percent` of senders, bucketed by IP.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct MockCanary {
+    pub response: MockResponse,
+    pub percent: u8,
+    #[serde(rename = "startedAt")]
+    pub started_at: i64,
+}
+
+/// Requests or distinct senders answered by each mock version.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct MockVersionCounts {
+    pub stable: u64,
+    pub canary: u64,
+}
+
+/// Counts since a mock canary started.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct MockCanaryStats {
+    pub requests: MockVersionCounts,
+    pub senders: MockVersionCounts,
+}
+
+/// A running mock canary and its counts.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct MockCanaryStatus {
+    #[serde(flatten)]
+    pub canary: MockCanary,
+    #[serde(default)]
+    pub stats: MockCanaryStats,
+}
+
+/// Latency percentiles in milliseconds.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct LatencyPercentiles {
+    pub p50: f64,
+    pub p90: f64,
+    pub p99: f64,
+}
+
+/// Delivery latency to one function sink destination. `relay` runs from the
+/// request arriving until the final attempt was sent; `destination` from then
+/// until the destination responded.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct DestinationLatency {
+    pub target: String,
+    pub deliveries: u64,
+    #[serde(default)]
+    pub failed: u64,
+    #[serde(default)]
+    pub retried: u64,
+    pub relay: LatencyPercentiles,
+    pub destination: LatencyPercentiles,
+    pub total: LatencyPercentiles,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SinkLatency {
+    pub window: String,
+    pub since: i64,
+    pub destinations: Vec<DestinationLatency>,
+}
+
+/// An endpoint's usage over one calendar month (`GET /api/endpoints/{slug}/report`).
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct UsageReport {
+    pub slug: String,
+    /// `YYYY-MM`
+    pub month: String,
+    pub requests: u64,
+    pub bytes: u64,
+    #[serde(rename = "maxSize")]
+    pub max_size: u64,
+    #[serde(default)]
+    pub days: Vec<DailyUsage>,
+    /// Requests per HTTP method
+    #[serde(default)]
+    pub methods: std::collections::BTreeMap<String, u64>,
+    #[serde(rename = "topSenders", default)]
+    pub top_senders: Vec<SenderUsage>,
+    pub quota: ReportQuota,
+    #[serde(rename = "captureFailures")]
+    pub capture_failures: ReportFailures,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct DailyUsage {
+    /// `YYYY-MM-DD`
+    pub day: String,
+    pub requests: u64,
+    pub bytes: u64,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SenderUsage {
+    pub ip: String,
+    pub requests: u64,
+}
+
+/// The month's requests against the owner's current request limit.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ReportQuota {
+    pub plan: String,
+    pub limit: u64,
+    pub share: f64,
+}
+
+/// Requests the receiver accepted but could not store.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ReportFailures {
+    pub count: u64,
+    pub windows: u64,
+}
+
+/// Reply a paused endpoint sends instead of capturing.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PausedResponse {
+    pub status: u16,
+    #[serde(default)]
+    pub body: String,
+}
+
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct TeamShare {
     #[serde(rename = "teamId")]
@@ -38,8 +493,226 @@ pub struct TeamShare {
     pub team_name: String,
 }
 
-#[derive(Debug, Clone, Serialize, Deserialize)]
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
 pub struct MockResponse {
+    pub status: u16,
+    #[serde(default)]
+    pub body: String,
+    /// Binary body, base64-encoded, sent in place of `body`
+    #[serde(
+        default,
+        rename = "bodyBase64",
+        skip_serializing_if = "Option::is_none"
+    )]
+    pub body_base64: Option<String>,
+    #[serde(default)]
+    pub headers: HashMap<String, String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub delay: Option<u32>,
+    #[serde(
+        default,
+        rename = "delayJitter",
+        skip_serializing_if = "Option::is_none"
+    )]
+    pub delay_jitter: Option<u32>,
+    #[serde(
+        default,
+        rename = "headerPolicy",
+        skip_serializing_if = "Option::is_none"
+    )]
+    pub header_policy: Option<MockHeaderPolicy>,
+    /// Replies by request method (`GET`, `POST`, ...) in place of the base
+    /// reply, after the script and ahead of correlation
+    #[serde(default, rename = "byMethod", skip_serializing_if = "HashMap::is_empty")]
+    pub by_method: HashMap<String, CorrelatedResponse>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub correlation: Option<MockCorrelation>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub variants: Vec<MockVariant>,
+    /// Replies for recurring time windows; an open window wins over
+    /// correlation and variants
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub schedule: Vec<MockWindow>,
+    /// Ordered conditional replies; the first rule a request matches answers
+    /// it, after an open window and ahead of correlation and variants
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub rules: Vec<MockRule>,
+    /// Replies for successive requests; the current step answers after an
+    /// open window and a matching rule, ahead of correlation
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub sequence: Option<MockSequence>,
+    /// Rhai script the receiver runs per request to build the reply, after
+    /// the sequence and ahead of correlation
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub script: Option<String>,
+    /// Reply with the request as JSON in place of the base body
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub echo: bool,
+    /// Events sent over time in place of the base body
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub stream: Option<MockStream>,
+    /// Bodies by media type, sent in place of the base body when the
+    /// request's `Accept` header prefers them
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub representations: BTreeMap<String, String>,
+    /// Reply to calls captured by the receiver's gRPC listener
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub grpc: Option<GrpcMockReply>,
+}
+
+impl MockResponse {
+    /// Size in bytes of the body the receiver sends, binary or text.
+    pub fn body_size(&self) -> usize {
+        use base64::Engine;
+        match self.body_base64 {
+            Some(ref encoded) => base64::engine::general_purpose::STANDARD
+                .decode(encoded)
+                .map_or(0, |body| body.len()),
+            None => self.body.len(),
+        }
+    }
+}
+
+/// Streamed base body: `sse` (the default) sends each event as a
+/// `text/event-stream` message, `chunked` sends each event's data as is.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockStream {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub format: Option<String>,
+    pub events: Vec<MockStreamEvent>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockStreamEvent {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub data: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub event: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub id: Option<String>,
+    /// Milliseconds to wait after the previous event
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub delay: Option<u32>,
+}
+
+/// gRPC status (0-16), optional message, and base64 response message.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct GrpcMockReply {
+    pub code: u8,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub message: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub body: Option<String>,
+}
+
+/// Weighted alternative reply, sent to `weight` percent of captures.
+/// Captures record the variant they got as `mockVariant`.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockVariant {
+    pub name: String,
+    pub weight: u8,
+    pub status: u16,
+    #[serde(default)]
+    pub body: String,
+    #[serde(default)]
+    pub headers: HashMap<String, String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub delay: Option<u32>,
+    #[serde(
+        default,
+        rename = "delayJitter",
+        skip_serializing_if = "Option::is_none"
+    )]
+    pub delay_jitter: Option<u32>,
+}
+
+/// Reply for a recurring time window, `start`-`end` (`HH:MM`, end exclusive
+/// and before start for windows past midnight) in `timezone` (UTC when unset)
+/// on `days` (every day when empty). Captures record its name as `mockVariant`.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockWindow {
+    pub name: String,
+    pub start: String,
+    pub end: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub timezone: Option<String>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub days: Vec<String>,
+    pub status: u16,
+    #[serde(default)]
+    pub body: String,
+    #[serde(default)]
+    pub headers: HashMap<String, String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub delay: Option<u32>,
+    #[serde(
+        default,
+        rename = "delayJitter",
+        skip_serializing_if = "Option::is_none"
+    )]
+    pub delay_jitter: Option<u32>,
+}
+
+/// Reply for requests that meet every condition in `match`.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockRule {
+    #[serde(rename = "match")]
+    pub conditions: MockRuleMatch,
+    pub status: u16,
+    #[serde(default)]
+    pub body: String,
+    #[serde(default)]
+    pub headers: HashMap<String, String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub delay: Option<u32>,
+    #[serde(
+        default,
+        rename = "delayJitter",
+        skip_serializing_if = "Option::is_none"
+    )]
+    pub delay_jitter: Option<u32>,
+}
+
+/// Conditions of a mock rule. Header, query and body (JSON path) values are
+/// patterns where `*` matches any run of characters.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockRuleMatch {
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub methods: Vec<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub path: Option<String>,
+    #[serde(default, skip_serializing_if = "HashMap::is_empty")]
+    pub headers: HashMap<String, String>,
+    #[serde(default, skip_serializing_if = "HashMap::is_empty")]
+    pub query: HashMap<String, String>,
+    #[serde(default, skip_serializing_if = "HashMap::is_empty")]
+    pub body: HashMap<String, String>,
+}
+
+/// Replies for successive requests: each step answers `count` requests, then
+/// the rest of the mock answers unless `repeat` starts over. With `key`, each
+/// value of that request field has its own position.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockSequence {
+    pub steps: Vec<MockSequenceStep>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub key: Option<String>,
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub repeat: bool,
+    /// Seconds a position may sit idle before it starts over
+    #[serde(
+        default,
+        rename = "resetAfter",
+        skip_serializing_if = "Option::is_none"
+    )]
+    pub reset_after: Option<u32>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockSequenceStep {
+    /// Requests this step answers; 1 when unset
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub count: Option<u32>,
     pub status: u16,
     #[serde(default)]
     pub body: String,
@@ -47,68 +720,745 @@ pub struct MockResponse {
     pub headers: HashMap<String, String>,
     #[serde(default, skip_serializing_if = "Option::is_none")]
     pub delay: Option<u32>,
+    #[serde(
+        default,
+        rename = "delayJitter",
+        skip_serializing_if = "Option::is_none"
+    )]
+    pub delay_jitter: Option<u32>,
+}
+
+/// Replies picked by a value read from the request (e.g. `body.json.order_id`).
+/// Unmatched requests get the base mock response.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockCorrelation {
+    pub key: String,
+    pub responses: HashMap<String, CorrelatedResponse>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CorrelatedResponse {
+    pub status: u16,
+    #[serde(default)]
+    pub body: String,
+    #[serde(default)]
+    pub headers: HashMap<String, String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub delay: Option<u32>,
+    #[serde(
+        default,
+        rename = "delayJitter",
+        skip_serializing_if = "Option::is_none"
+    )]
+    pub delay_jitter: Option<u32>,
+}
+
+/// Overrides for the receiver's default mock-response header blocking.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MockHeaderPolicy {
+    /// Default-blocked headers (e.g. set-cookie) this endpoint may send.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub allow: Vec<String>,
+    /// Additional headers to drop.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub block: Vec<String>,
+}
+
+/// Serverless function invoked by the receiver for every captured request.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FunctionSink {
+    pub provider: String,
+    pub arn: String,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CreateEndpointRequest {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub name: Option<String>,
+    /// Custom slug (generated if omitted)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub slug: Option<String>,
+    #[serde(rename = "isEphemeral", skip_serializing_if = "Option::is_none")]
+    pub is_ephemeral: Option<bool>,
+    #[serde(rename = "expiresAt", skip_serializing_if = "Option::is_none")]
+    pub expires_at: Option<i64>,
+    #[serde(rename = "mockResponse", skip_serializing_if = "Option::is_none")]
+    pub mock_response: Option<MockResponse>,
+    #[serde(rename = "notificationUrl", skip_serializing_if = "Option::is_none")]
+    pub notification_url: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub demo: Option<DemoConfig>,
+    #[serde(rename = "autoExtend", skip_serializing_if = "Option::is_none")]
+    pub auto_extend: Option<AutoExtendRequest>,
+}
+
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct UpdateEndpointRequest {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub name: Option<String>,
+    #[serde(
+        rename = "mockResponse",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub mock_response: Option<serde_json::Value>,
+    #[serde(
+        rename = "notificationUrl",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub notification_url: Option<serde_json::Value>,
+    #[serde(
+        rename = "functionSink",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub function_sink: Option<serde_json::Value>,
+    #[serde(
+        rename = "bodyTransforms",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub body_transforms: Option<serde_json::Value>,
+    #[serde(
+        rename = "infoHeaders",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub info_headers: Option<bool>,
+    #[serde(
+        rename = "recordResponses",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub record_responses: Option<bool>,
+    /// Hash JSON bodies in canonical form for duplicate detection
+    #[serde(
+        rename = "canonicalJson",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub canonical_json: Option<bool>,
+    /// Answer requests without storing them
+    #[serde(
+        rename = "dryRun",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub dry_run: Option<bool>,
+    /// Let `__status` and `__delay` query parameters override the reply
+    #[serde(
+        rename = "queryOverrides",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub query_overrides: Option<bool>,
+    /// Answer provider verification requests in place of the mock
+    #[serde(
+        rename = "autoHandshake",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub auto_handshake: Option<bool>,
+    /// Backend to relay requests to, or null to stop relaying
+    #[serde(
+        rename = "forwardUrl",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub forward_url: Option<serde_json::Value>,
+    /// Priority rule, or null to remove it
+    #[serde(
+        rename = "priorityRule",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub priority_rule: Option<serde_json::Value>,
+    /// Header names to encrypt at capture time, or null to stop encrypting
+    #[serde(
+        rename = "encryptedHeaders",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub encrypted_headers: Option<serde_json::Value>,
+    /// Network policy, or null to remove it
+    #[serde(
+        rename = "networkPolicy",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub network_policy: Option<serde_json::Value>,
+    /// PEM CA bundle for client certificates, or null to stop requiring one
+    #[serde(
+        rename = "clientCa",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub client_ca: Option<serde_json::Value>,
+    /// Signature verification config, or null to stop verifying
+    #[serde(
+        rename = "signatureVerification",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub signature_verification: Option<serde_json::Value>,
+    /// JWT validation config, or null to stop validating
+    #[serde(
+        rename = "jwtVerification",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub jwt_verification: Option<serde_json::Value>,
+    /// Capture credentials, or null to capture requests without them
+    #[serde(
+        rename = "captureAuth",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub capture_auth: Option<serde_json::Value>,
+    /// CORS config, or null for any origin without credentials
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub cors: Option<serde_json::Value>,
+    /// Capture filter, or null to store every request
+    #[serde(
+        rename = "captureFilter",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub capture_filter: Option<serde_json::Value>,
+    /// Sampling config, or null to store all traffic
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub sampling: Option<serde_json::Value>,
+    /// Chaos config, or null to turn chaos mode off
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub chaos: Option<serde_json::Value>,
+    /// Rate-limit config, or null to stop throttling
+    #[serde(rename = "rateLimit", skip_serializing_if = "Option::is_none", default)]
+    pub rate_limit: Option<serde_json::Value>,
+    /// JSON Schema validation config, or null to stop checking bodies
+    #[serde(
+        rename = "schemaValidation",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub schema_validation: Option<serde_json::Value>,
+    /// Redaction rules, or null to store captures unredacted
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub redaction: Option<serde_json::Value>,
+    /// Hostname to route to the endpoint, or null to release it
+    #[serde(
+        rename = "customDomain",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub custom_domain: Option<serde_json::Value>,
+    /// Body size limit in bytes, or null for the plan's limit
+    #[serde(
+        rename = "maxBodySize",
+        skip_serializing_if = "Option::is_none",
+        default
+    )]
+    pub max_body_size: Option<serde_json::Value>,
+}
+
+/// A saved endpoint configuration from the version history.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct EndpointVersion {
+    pub version: u32,
+    /// "initial", "edit" or "rollback"
+    pub source: String,
+    #[serde(rename = "rolledBackTo", default)]
+    pub rolled_back_to: Option<u32>,
+    #[serde(rename = "createdAt")]
+    pub created_at: i64,
+    pub config: EndpointConfig,
+}
+
+/// The versioned part of an endpoint.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct EndpointConfig {
+    #[serde(rename = "mockResponse", default)]
+    pub mock_response: Option<MockResponse>,
+    #[serde(rename = "notificationUrl", default)]
+    pub notification_url: Option<String>,
+    #[serde(rename = "functionSink", default)]
+    pub function_sink: Option<FunctionSink>,
+    #[serde(rename = "bodyTransforms", default)]
+    pub body_transforms: Option<Vec<serde_json::Value>>,
+    #[serde(rename = "infoHeaders", default)]
+    pub info_headers: bool,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct EndpointList {
+    pub owned: Vec<Endpoint>,
+    #[serde(default)]
+    pub shared: Vec<Endpoint>,
+}
+
+/// Whether a custom slug can be used for a new endpoint.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SlugAvailability {
+    pub slug: String,
+    pub available: bool,
+    /// "invalid", "reserved" or "taken" when unavailable
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub reason: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub message: Option<String>,
+}
+
+// ---------------------------------------------------------------------------
+// Teams
+// ---------------------------------------------------------------------------
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct Team {
+    pub id: String,
+    pub name: String,
+    #[serde(rename = "createdBy", default)]
+    pub created_by: Option<String>,
+    #[serde(rename = "createdAt", default)]
+    pub created_at: Option<i64>,
+    #[serde(rename = "memberCount", default)]
+    pub member_count: u64,
+    pub role: String,
+    #[serde(default)]
+    pub suspended: bool,
+}
+
+impl Team {
+    /// Whether the current user owns this team (owners manage members and shares).
+    pub fn is_owner(&self) -> bool {
+        self.role == "owner"
+    }
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct TeamMember {
+    pub id: String,
+    #[serde(rename = "userId")]
+    pub user_id: String,
+    pub email: String,
+    #[serde(default)]
+    pub name: Option<String>,
+    pub role: String,
+    #[serde(default)]
+    pub plan: Option<String>,
+    #[serde(rename = "joinedAt", default)]
+    pub joined_at: Option<i64>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct TeamInvite {
+    pub id: String,
+    #[serde(rename = "invitedEmail")]
+    pub invited_email: String,
+    #[serde(default)]
+    pub status: String,
+    #[serde(rename = "createdAt", default)]
+    pub created_at: Option<i64>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct TeamMemberList {
+    pub members: Vec<TeamMember>,
+    #[serde(rename = "pendingInvites", default)]
+    pub pending_invites: Vec<TeamInvite>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ShareEndpointRequest {
+    #[serde(rename = "endpointId")]
+    pub endpoint_id: String,
+}
+
+// ---------------------------------------------------------------------------
+// Share tokens
+// ---------------------------------------------------------------------------
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ShareToken {
+    pub id: String,
+    #[serde(rename = "tokenPrefix")]
+    pub token_prefix: String,
+    /// Raw token, only returned once at creation
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub token: Option<String>,
+    #[serde(rename = "expiresAt", default)]
+    pub expires_at: Option<i64>,
+    #[serde(rename = "lastUsedAt", default)]
+    pub last_used_at: Option<i64>,
+    #[serde(rename = "createdAt")]
+    pub created_at: i64,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CreateShareRequest {
+    /// Token lifetime in milliseconds
+    #[serde(rename = "expiresIn")]
+    pub expires_in: i64,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SharedEndpoint {
+    pub slug: String,
+    #[serde(default)]
+    pub name: Option<String>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SharedRequestList {
+    pub endpoint: SharedEndpoint,
+    #[serde(rename = "expiresAt", default)]
+    pub expires_at: Option<i64>,
+    pub requests: Vec<CapturedRequest>,
+}
+
+// ---------------------------------------------------------------------------
+// Captured request
+// ---------------------------------------------------------------------------
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CapturedRequest {
+    #[serde(alias = "_id")]
+    pub id: String,
+    #[serde(rename = "endpointId")]
+    pub endpoint_id: String,
+    pub method: String,
+    pub path: String,
+    #[serde(default)]
+    pub headers: HashMap<String, String>,
+    #[serde(default)]
+    pub body: Option<String>,
+    /// Base64-encoded raw bytes, present only for non-UTF-8 payloads
+    #[serde(rename = "bodyRaw", default)]
+    pub body_raw: Option<String>,
+    #[serde(rename = "queryParams", default)]
+    pub query_params: HashMap<String, String>,
+    #[serde(rename = "contentType", default)]
+    pub content_type: Option<String>,
+    #[serde(default)]
+    pub ip: String,
+    #[serde(default)]
+    pub size: usize,
+    #[serde(rename = "receivedAt")]
+    pub received_at: i64,
+    /// SHA-256 of the stored body
+    #[serde(rename = "bodyHash", default, skip_serializing_if = "Option::is_none")]
+    pub body_hash: Option<String>,
+    /// First request of the same delivery (same delivery ID, or method, path and body)
+    #[serde(rename = "duplicateOf", default, skip_serializing_if = "Option::is_none")]
+    pub duplicate_of: Option<String>,
+    /// Weighted mock variant the capture was answered with (`default` for the base response)
+    #[serde(rename = "mockVariant", default, skip_serializing_if = "Option::is_none")]
+    pub mock_variant: Option<String>,
+    /// Mock version (`stable` or `canary`) the capture was answered with while a canary ran
+    #[serde(rename = "mockVersion", default, skip_serializing_if = "Option::is_none")]
+    pub mock_version: Option<String>,
+    /// Fault (`error`, `hang` or `reset`) chaos mode injected in place of the reply
+    #[serde(rename = "chaosFault", default, skip_serializing_if = "Option::is_none")]
+    pub chaos_fault: Option<String>,
+    /// Status and delay the request asked for with `__status` and `__delay`
+    #[serde(rename = "responseOverride", default, skip_serializing_if = "Option::is_none")]
+    pub response_override: Option<ResponseOverride>,
+    /// Parts of a multipart/form-data body, in order
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub parts: Vec<MultipartPart>,
+    /// Object storage key when the body was over the receiver's inline limit; `body` is then empty
+    #[serde(rename = "bodyRef", default, skip_serializing_if = "Option::is_none")]
+    pub body_ref: Option<String>,
+    /// Reply the receiver sent, when the endpoint records responses
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub response: Option<CapturedResponse>,
+    /// Set on messages captured over a WebSocket connection (method `WS`)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub frame: Option<WebSocketFrame>,
+    /// Set when the capture is a CloudEvent (binary or structured mode)
+    #[serde(rename = "cloudEvent", default, skip_serializing_if = "Option::is_none")]
+    pub cloud_event: Option<CloudEvent>,
+    /// Protocol the request arrived over (`HTTP/1.1`, `HTTP/2`); unset on older captures
+    #[serde(rename = "httpVersion", default, skip_serializing_if = "Option::is_none")]
+    pub http_version: Option<String>,
+    /// Marked high priority by the endpoint's priority rule
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub priority: bool,
+    /// Certificate the sender presented over the receiver's mTLS listener
+    #[serde(rename = "clientCert", default, skip_serializing_if = "Option::is_none")]
+    pub client_cert: Option<ClientCertificate>,
+    /// What the sender negotiated when the receiver ended its TLS (mTLS listener, custom domains)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub tls: Option<TlsDetails>,
+    /// HTTP trailers sent after the body (chunked or HTTP/2), kept apart from `headers`
+    #[serde(default, skip_serializing_if = "HashMap::is_empty")]
+    pub trailers: HashMap<String, String>,
+    /// Shared by captures of the same kind (method, path with IDs replaced, body shape)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub fingerprint: Option<String>,
+    /// Sender the receiver recognized (`github`, `stripe`, ...)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub provider: Option<String>,
+    /// The provider's name for the event, or a CloudEvent's type
+    #[serde(rename = "eventType", default, skip_serializing_if = "Option::is_none")]
+    pub event_type: Option<String>,
+    /// What the body is (`json`, `xml`, `form`, `text`, `image`, `protobuf`,
+    /// `binary`), decided by the receiver at capture
+    #[serde(rename = "contentClass", default, skip_serializing_if = "Option::is_none")]
+    pub content_class: Option<String>,
+    /// Whether the sender's signature checked out, when the endpoint verifies signatures
+    #[serde(rename = "signatureValid", default, skip_serializing_if = "Option::is_none")]
+    pub signature_valid: Option<bool>,
+    /// Whether the sender's JWT checked out, when the endpoint validates JWTs
+    #[serde(rename = "jwtValid", default, skip_serializing_if = "Option::is_none")]
+    pub jwt_valid: Option<bool>,
+    /// The JWT's decoded payload, kept whether or not it verified
+    #[serde(rename = "jwtClaims", default, skip_serializing_if = "Option::is_none")]
+    pub jwt_claims: Option<serde_json::Value>,
+    /// Whether the body matched the endpoint's JSON Schema, when it has one
+    #[serde(rename = "schemaValid", default, skip_serializing_if = "Option::is_none")]
+    pub schema_valid: Option<bool>,
+    /// How the body broke the schema
+    #[serde(rename = "schemaErrors", default, skip_serializing_if = "Option::is_none")]
+    pub schema_errors: Option<Vec<SchemaError>>,
+    /// Sizes as received and as stored (HTTP captures only)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub sizes: Option<RequestSizes>,
+    /// How often each redaction rule fired, when anything was redacted
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub redactions: Option<BTreeMap<String, usize>>,
+    /// Fields that don't show what was sent, keyed `header:<name>`,
+    /// `trailer:<name>`, `query:<name>` or `body`, with the reason
+    /// (`redacted` at capture, or `encrypted` and not revealed)
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub masked: BTreeMap<String, String>,
+    /// Sender's country (two-letter code), from Cloudflare or the receiver's GeoIP database
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub country: Option<String>,
+    /// Autonomous system the sender's address belongs to
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub asn: Option<u32>,
+    /// Organization holding that autonomous system
+    #[serde(rename = "asOrg", default, skip_serializing_if = "Option::is_none")]
+    pub as_org: Option<String>,
+    /// Free-text note attached with `whk annotate`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub note: Option<String>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub tags: Vec<String>,
+}
+
+impl CapturedRequest {
+    /// Why `field` (`header:<name>`, `query:<name>`, `body`, ...) doesn't
+    /// show what was sent, if it doesn't.
+    pub fn mask(&self, field: &str) -> Option<&str> {
+        self.masked.get(field).map(String::as_str)
+    }
+
+    /// Whether any field is encrypted and could be shown with `--reveal`.
+    pub fn has_encrypted(&self) -> bool {
+        self.masked.values().any(|reason| reason == "encrypted")
+    }
+
+    /// A bare `POST /` capture on endpoint `ep`, for tests to fill in with
+    /// struct update syntax. Not behind `cfg(test)` because the integration
+    /// tests only see the library as built normally.
+    #[doc(hidden)]
+    pub fn test_default() -> Self {
+        Self {
+            id: String::new(),
+            endpoint_id: "ep".into(),
+            method: "POST".into(),
+            path: "/".into(),
+            headers: HashMap::new(),
+            body: None,
+            body_raw: None,
+            query_params: HashMap::new(),
+            content_type: None,
+            ip: String::new(),
+            size: 0,
+            received_at: 0,
+            body_hash: None,
+            duplicate_of: None,
+            mock_variant: None,
+            mock_version: None,
+            chaos_fault: None,
+            response_override: None,
+            parts: Vec::new(),
+            body_ref: None,
+            response: None,
+            frame: None,
+            cloud_event: None,
+            http_version: None,
+            priority: false,
+            client_cert: None,
+            tls: None,
+            trailers: HashMap::new(),
+            fingerprint: None,
+            provider: None,
+            event_type: None,
+            content_class: None,
+            signature_valid: None,
+            jwt_valid: None,
+            jwt_claims: None,
+            schema_valid: None,
+            schema_errors: None,
+            sizes: None,
+            redactions: None,
+            masked: BTreeMap::new(),
+            country: None,
+            asn: None,
+            as_org: None,
+            note: None,
+            tags: Vec::new(),
+        }
+    }
+}
+
+/// What an HTTP capture weighed as received and what was kept of it.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RequestSizes {
+    /// Header block as received, uncompressed
+    pub headers: usize,
+    /// Body as received
+    pub body: usize,
+    /// Body bytes stored with the request; 0 when it went to object storage
+    pub stored: usize,
+    /// Fields not stored as sent, with the reason (`body` → `transformed`)
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub truncated: BTreeMap<String, String>,
+}
+
+/// A client certificate presented over the receiver's mTLS listener.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ClientCertificate {
+    pub subject: String,
+    pub issuer: String,
+    pub serial: String,
+    /// SHA-256 of the DER certificate, lowercase hex
+    pub fingerprint: String,
+    #[serde(rename = "notBefore")]
+    pub not_before: i64,
+    #[serde(rename = "notAfter")]
+    pub not_after: i64,
+    /// Chains to the endpoint's trusted CA
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub verified: bool,
 }
 
+/// TLS parameters of the connection a request arrived on.
 #[derive(Debug, Clone, Serialize, Deserialize)]
-pub struct CreateEndpointRequest {
-    #[serde(skip_serializing_if = "Option::is_none")]
-    pub name: Option<String>,
-    #[serde(rename = "isEphemeral", skip_serializing_if = "Option::is_none")]
-    pub is_ephemeral: Option<bool>,
-    #[serde(rename = "expiresAt", skip_serializing_if = "Option::is_none")]
-    pub expires_at: Option<i64>,
-    #[serde(rename = "mockResponse", skip_serializing_if = "Option::is_none")]
-    pub mock_response: Option<MockResponse>,
+pub struct TlsDetails {
+    /// `TLSv1.2` or `TLSv1.3`
+    pub version: String,
+    /// IANA cipher suite name
+    pub cipher: String,
+    /// Hostname the client asked for (SNI)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub sni: Option<String>,
+    /// Protocol picked by ALPN (`h2`, `http/1.1`)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub alpn: Option<String>,
 }
 
+/// Where a captured WebSocket message sits in its connection.
 #[derive(Debug, Clone, Serialize, Deserialize)]
-pub struct UpdateEndpointRequest {
-    #[serde(skip_serializing_if = "Option::is_none")]
-    pub name: Option<String>,
-    #[serde(
-        rename = "mockResponse",
-        skip_serializing_if = "Option::is_none",
-        default
-    )]
-    pub mock_response: Option<serde_json::Value>,
+pub struct WebSocketFrame {
+    /// Random ID shared by the messages of one connection
+    pub connection: String,
+    /// Position in the connection, from 1
+    pub seq: u64,
+    /// `text` or `binary`
+    pub opcode: String,
 }
 
+/// CloudEvents context attributes the receiver found on a capture.
 #[derive(Debug, Clone, Serialize, Deserialize)]
-pub struct EndpointList {
-    pub owned: Vec<Endpoint>,
-    #[serde(default)]
-    pub shared: Vec<Endpoint>,
+pub struct CloudEvent {
+    /// `binary` (`ce-*` headers) or `structured` (`application/cloudevents+json` body)
+    pub mode: String,
+    pub specversion: String,
+    #[serde(rename = "type")]
+    pub event_type: String,
+    pub source: String,
+    pub id: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub subject: Option<String>,
 }
 
-// ---------------------------------------------------------------------------
-// Captured request
-// ---------------------------------------------------------------------------
+/// Reply overrides a capture asked for with reserved query parameters.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ResponseOverride {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub status: Option<u16>,
+    /// Milliseconds, in place of the mock's delay
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub delay: Option<u64>,
+}
 
+/// The reply the receiver sent for a capture.
 #[derive(Debug, Clone, Serialize, Deserialize)]
-pub struct CapturedRequest {
-    #[serde(alias = "_id")]
-    pub id: String,
-    #[serde(rename = "endpointId")]
-    pub endpoint_id: String,
-    pub method: String,
-    pub path: String,
+pub struct CapturedResponse {
+    pub status: u16,
+    /// `mock`, `handshake`, `proxy`, `rate_limit` or `default`
+    pub source: String,
     #[serde(default)]
     pub headers: HashMap<String, String>,
-    #[serde(default)]
+    #[serde(rename = "bodySize", default)]
+    pub body_size: usize,
+    #[serde(rename = "delayMs", default, skip_serializing_if = "Option::is_none")]
+    pub delay_ms: Option<u64>,
+    /// Proxied replies: time the forward URL took to answer
+    #[serde(rename = "latencyMs", default, skip_serializing_if = "Option::is_none")]
+    pub latency_ms: Option<u64>,
+    /// Proxied replies: the body received, up to 64KB
+    #[serde(default, skip_serializing_if = "Option::is_none")]
     pub body: Option<String>,
-    /// Base64-encoded raw bytes, present only for non-UTF-8 payloads
-    #[serde(rename = "bodyRaw", default)]
-    pub body_raw: Option<String>,
-    #[serde(rename = "queryParams", default)]
-    pub query_params: HashMap<String, String>,
-    #[serde(rename = "contentType", default)]
+    /// `base64` when the body wasn't UTF-8
+    #[serde(rename = "bodyEncoding", default, skip_serializing_if = "Option::is_none")]
+    pub body_encoding: Option<String>,
+    #[serde(rename = "bodyTruncated", default, skip_serializing_if = "std::ops::Not::not")]
+    pub body_truncated: bool,
+    /// Proxied replies: why the forward URL didn't answer
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+/// One part of a multipart/form-data body, as described by the receiver.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct MultipartPart {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub name: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub filename: Option<String>,
+    #[serde(rename = "contentType", default, skip_serializing_if = "Option::is_none")]
     pub content_type: Option<String>,
     #[serde(default)]
-    pub ip: String,
+    pub size: usize,
+    /// Small UTF-8 part bodies, when the receiver inlines them
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub body: Option<String>,
+}
+
+/// Body of `PATCH /api/requests/:id`. Fields left `None` are unchanged.
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct AnnotateRequest {
+    /// `Some(None)` clears the note.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub note: Option<Option<String>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub tags: Option<Vec<String>>,
+}
+
+/// Signed download URL for a body kept in object storage.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RequestBodyUrl {
+    pub url: String,
+    #[serde(rename = "expiresAt")]
+    pub expires_at: i64,
     #[serde(default)]
     pub size: usize,
-    #[serde(rename = "receivedAt")]
-    pub received_at: i64,
+    #[serde(rename = "bodyHash", default, skip_serializing_if = "Option::is_none")]
+    pub body_hash: Option<String>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -151,6 +1501,48 @@ pub struct UsageInfo {
     pub period_end: Option<i64>,
 }
 
+/// One daily API allowance.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ApiUsageMeter {
+    pub used: u64,
+    pub limit: u64,
+    pub remaining: u64,
+}
+
+/// Today's API use against the plan's daily limits (`GET /api/usage/api`).
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ApiUsage {
+    pub plan: String,
+    /// The UTC day being counted, `YYYY-MM-DD`.
+    pub day: String,
+    #[serde(rename = "resetsAt")]
+    pub resets_at: i64,
+    #[serde(rename = "apiCalls")]
+    pub api_calls: ApiUsageMeter,
+    #[serde(rename = "streamMinutes")]
+    pub stream_minutes: ApiUsageMeter,
+    pub replays: ApiUsageMeter,
+    pub exports: ApiUsageMeter,
+}
+
+/// A notable event on the account or one of its teams (`GET /api/activity`).
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ActivityEvent {
+    pub id: String,
+    /// endpoint_created, quota_warning, forwarding_failure or team_member_joined
+    #[serde(rename = "type")]
+    pub event_type: String,
+    #[serde(rename = "endpointId", default, skip_serializing_if = "Option::is_none")]
+    pub endpoint_id: Option<String>,
+    #[serde(rename = "teamId", default, skip_serializing_if = "Option::is_none")]
+    pub team_id: Option<String>,
+    /// Type-specific details (slug, limit, error, email, ...)
+    #[serde(default)]
+    pub data: serde_json::Value,
+    #[serde(rename = "createdAt")]
+    pub created_at: i64,
+}
+
 // ---------------------------------------------------------------------------
 // Device auth
 // ---------------------------------------------------------------------------
@@ -216,6 +1608,15 @@ pub struct SendResponse {
     pub body: Option<String>,
 }
 
+/// A full response from an arbitrary URL, kept byte-for-byte.
+#[derive(Debug, Clone)]
+pub struct FetchedResponse {
+    pub status: u16,
+    /// Headers in received order; repeated names appear once per value.
+    pub headers: Vec<(String, String)>,
+    pub body: Vec<u8>,
+}
+
 // ---------------------------------------------------------------------------
 // Auth token (stored on disk)
 // ---------------------------------------------------------------------------
@@ -280,6 +1681,39 @@ pub enum SseEvent {
     Timeout,
 }
 
+/// Events from an endpoint's configuration stream (`whk watch`).
+#[derive(Debug, Clone)]
+pub enum EndpointEvent {
+    Connected,
+    ConfigChanged(ConfigChangeEvent),
+    /// An ephemeral endpoint expired (unix milliseconds)
+    Expired { expires_at: i64 },
+    Deleted,
+    Timeout,
+}
+
+/// A new configuration version and the settings it changed.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ConfigChangeEvent {
+    pub version: u32,
+    /// "edit" or "rollback"
+    pub source: String,
+    #[serde(rename = "rolledBackTo", default)]
+    pub rolled_back_to: Option<u32>,
+    #[serde(rename = "createdAt")]
+    pub created_at: i64,
+    #[serde(default)]
+    pub changes: Vec<ConfigChange>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ConfigChange {
+    /// "mockResponse", "notificationUrl", "functionSink", "bodyTransforms" or "infoHeaders"
+    pub field: String,
+    /// "added", "removed" or "edited"
+    pub change: String,
+}
+
 // ---------------------------------------------------------------------------
 // API error response
 // ---------------------------------------------------------------------------
@@ -330,6 +1764,86 @@ mod tests {
         assert_eq!(mock.body, "{\"ok\":true}");
     }
 
+    #[test]
+    fn test_deserialize_endpoint_cors() {
+        let json = r#"{
+            "id": "abc-123",
+            "slug": "test",
+            "cors": {"origins": ["https://app.test"], "credentials": true, "maxAge": 600},
+            "createdAt": 1774987447212,
+            "sharedWith": []
+        }"#;
+        let ep: Endpoint = serde_json::from_str(json).unwrap();
+        let cors = ep.cors.unwrap();
+        assert_eq!(cors.origins, vec!["https://app.test"]);
+        assert!(cors.methods.is_empty());
+        assert!(cors.credentials);
+        assert_eq!(cors.max_age, Some(600));
+    }
+
+    #[test]
+    fn test_deserialize_endpoint_capture_filter() {
+        let json = r#"{
+            "id": "abc-123",
+            "slug": "test",
+            "captureFilter": {"exclude": [{"header": {"name": "x-event", "value": "^ping$"}}]},
+            "createdAt": 1774987447212,
+            "sharedWith": []
+        }"#;
+        let ep: Endpoint = serde_json::from_str(json).unwrap();
+        let filter = ep.capture_filter.unwrap();
+        assert!(filter.include.is_empty());
+        let header = filter.exclude[0].header.as_ref().unwrap();
+        assert_eq!(header.name, "x-event");
+        assert_eq!(header.value.as_deref(), Some("^ping$"));
+    }
+
+    #[test]
+    fn test_deserialize_endpoint_chaos() {
+        let json = r#"{
+            "id": "abc-123",
+            "slug": "test",
+            "chaos": {"percent": 12.5, "faults": ["error", "reset"]},
+            "createdAt": 1774987447212,
+            "sharedWith": []
+        }"#;
+        let ep: Endpoint = serde_json::from_str(json).unwrap();
+        let chaos = ep.chaos.unwrap();
+        assert_eq!(chaos.percent, 12.5);
+        assert_eq!(chaos.faults, vec!["error", "reset"]);
+    }
+
+    #[test]
+    fn test_deserialize_endpoint_rate_limit() {
+        let json = r#"{
+            "id": "abc-123",
+            "slug": "test",
+            "rateLimit": {"limit": 10, "window": 60},
+            "createdAt": 1774987447212,
+            "sharedWith": []
+        }"#;
+        let ep: Endpoint = serde_json::from_str(json).unwrap();
+        let rate_limit = ep.rate_limit.unwrap();
+        assert_eq!(rate_limit.limit, 10);
+        assert_eq!(rate_limit.window, 60);
+    }
+
+    #[test]
+    fn test_deserialize_sampling_stats() {
+        let json = r#"{
+            "requests": 50000,
+            "bytes": 100000000,
+            "sampledOut": 45000,
+            "sampledOutBytes": 90000000,
+            "firstRequestAt": 1774987447212,
+            "lastRequestAt": null
+        }"#;
+        let stats: SamplingStats = serde_json::from_str(json).unwrap();
+        assert_eq!(stats.sampled_out, 45000);
+        assert_eq!(stats.first_request_at, Some(1774987447212));
+        assert!(stats.last_request_at.is_none());
+    }
+
     #[test]
     fn test_deserialize_endpoint_list() {
         let json = r#"{"owned": [{"id":"1","slug":"a","createdAt":123,"sharedWith":[]}], "shared": []}"#;
@@ -338,6 +1852,43 @@ mod tests {
         assert_eq!(list.owned[0].slug, "a");
     }
 
+    #[test]
+    fn test_deserialize_teams() {
+        let json = r#"[
+            {"id":"t1","name":"Acme","createdBy":"u1","createdAt":1774987447212,"memberCount":3,"role":"owner","suspended":false},
+            {"id":"t2","name":"Other","createdBy":"u2","createdAt":1774987447212,"memberCount":2,"role":"member","suspended":true}
+        ]"#;
+        let teams: Vec<Team> = serde_json::from_str(json).unwrap();
+        assert_eq!(teams.len(), 2);
+        assert!(teams[0].is_owner());
+        assert!(!teams[1].is_owner());
+        assert!(teams[1].suspended);
+        assert_eq!(teams[0].member_count, 3);
+    }
+
+    #[test]
+    fn test_deserialize_team_member_list() {
+        let json = r#"{
+            "members": [{"id":"m1","userId":"u1","email":"a@example.com","name":null,"image":null,"role":"owner","plan":"pro","joinedAt":1774987447212}],
+            "pendingInvites": [{"id":"i1","teamId":"t1","teamName":"Acme","invitedBy":"u1","inviterEmail":"a@example.com","invitedEmail":"b@example.com","status":"pending","createdAt":1774987447212}]
+        }"#;
+        let list: TeamMemberList = serde_json::from_str(json).unwrap();
+        assert_eq!(list.members[0].email, "a@example.com");
+        assert!(list.members[0].name.is_none());
+        assert_eq!(list.pending_invites[0].invited_email, "b@example.com");
+    }
+
+    #[test]
+    fn test_deserialize_share_token() {
+        let json = r#"{"id":"s1","tokenPrefix":"whsh_Ab3xK9","expiresAt":1775030647212,"lastUsedAt":null,"createdAt":1774987447212,"token":"whsh_Ab3xK9secret","readOnly":true}"#;
+        let share: ShareToken = serde_json::from_str(json).unwrap();
+        assert_eq!(share.token.as_deref(), Some("whsh_Ab3xK9secret"));
+        assert!(share.last_used_at.is_none());
+
+        let listed = serde_json::to_string(&ShareToken { token: None, ..share }).unwrap();
+        assert!(!listed.contains("\"token\""), "token should be omitted when absent: {listed}");
+    }
+
     #[test]
     fn test_deserialize_request_with_id() {
         let json = r#"{
@@ -377,6 +1928,100 @@ mod tests {
         assert_eq!(req.id, "sse-req-789");
     }
 
+    #[test]
+    fn test_deserialize_request_with_cloud_event() {
+        let json = r#"{
+            "id": "req-ce",
+            "endpointId": "ep-456",
+            "method": "POST",
+            "path": "/",
+            "headers": {},
+            "queryParams": {},
+            "ip": "1.2.3.4",
+            "size": 2,
+            "receivedAt": 1774866106592,
+            "cloudEvent": {"mode": "binary", "specversion": "1.0", "type": "com.example.ping", "source": "/s", "id": "1"}
+        }"#;
+        let req: CapturedRequest = serde_json::from_str(json).unwrap();
+        let event = req.cloud_event.unwrap();
+        assert_eq!(event.event_type, "com.example.ping");
+        assert_eq!(event.subject, None);
+    }
+
+    #[test]
+    fn test_deserialize_request_with_schema_errors() {
+        let json = r#"{
+            "id": "req-schema",
+            "endpointId": "ep-456",
+            "method": "POST",
+            "path": "/",
+            "headers": {},
+            "queryParams": {},
+            "ip": "1.2.3.4",
+            "size": 2,
+            "receivedAt": 1774866106592,
+            "schemaValid": false,
+            "schemaErrors": [{"path": "/id", "keyword": "required", "message": "missing property \"id\""}]
+        }"#;
+        let req: CapturedRequest = serde_json::from_str(json).unwrap();
+        assert_eq!(req.schema_valid, Some(false));
+        let errors = req.schema_errors.unwrap();
+        assert_eq!(errors[0].keyword, "required");
+        assert_eq!(errors[0].path, "/id");
+    }
+
+    #[test]
+    fn test_deserialize_request_sizes() {
+        let json = r#"{"id":"1","endpointId":"ep","method":"POST","path":"/","headers":{},"queryParams":{},"ip":"1.2.3.4","size":0,"receivedAt":123,
+            "sizes":{"headers":412,"body":2048,"stored":0,"truncated":{"body":"offloaded"}}}"#;
+        let req: CapturedRequest = serde_json::from_str(json).unwrap();
+        let sizes = req.sizes.unwrap();
+        assert_eq!((sizes.headers, sizes.body, sizes.stored), (412, 2048, 0));
+        assert_eq!(sizes.truncated["body"], "offloaded");
+    }
+
+    #[test]
+    fn test_deserialize_request_redactions() {
+        let json = r#"{"id":"1","endpointId":"ep","method":"POST","path":"/","headers":{},"queryParams":{},"ip":"1.2.3.4","size":0,"receivedAt":123,
+            "redactions":{"email":2,"path:customer.card":1}}"#;
+        let req: CapturedRequest = serde_json::from_str(json).unwrap();
+        let redactions = req.redactions.unwrap();
+        assert_eq!(redactions["email"], 2);
+        assert_eq!(redactions["path:customer.card"], 1);
+    }
+
+    #[test]
+    fn test_deserialize_request_masked() {
+        let json = r#"{"id":"1","endpointId":"ep","method":"POST","path":"/","headers":{"authorization":"[encrypted]"},"queryParams":{},"ip":"1.2.3.4","size":0,"receivedAt":123,
+            "masked":{"header:authorization":"encrypted","body":"redacted"}}"#;
+        let req: CapturedRequest = serde_json::from_str(json).unwrap();
+        assert_eq!(req.mask("header:authorization"), Some("encrypted"));
+        assert_eq!(req.mask("body"), Some("redacted"));
+        assert_eq!(req.mask("header:content-type"), None);
+        assert!(req.has_encrypted());
+    }
+
+    #[test]
+    fn test_deserialize_request_tls() {
+        let json = r#"{"id":"1","endpointId":"ep","method":"POST","path":"/","headers":{},"queryParams":{},"ip":"1.2.3.4","size":0,"receivedAt":123,
+            "tls":{"version":"TLSv1.3","cipher":"TLS_AES_128_GCM_SHA256","alpn":"h2"}}"#;
+        let req: CapturedRequest = serde_json::from_str(json).unwrap();
+        let tls = req.tls.unwrap();
+        assert_eq!((tls.version.as_str(), tls.cipher.as_str()), ("TLSv1.3", "TLS_AES_128_GCM_SHA256"));
+        assert_eq!(tls.sni, None);
+        assert_eq!(tls.alpn.as_deref(), Some("h2"));
+    }
+
+    #[test]
+    fn test_deserialize_request_origin() {
+        let json = r#"{"id":"1","endpointId":"ep","method":"POST","path":"/","headers":{},"queryParams":{},"ip":"1.2.3.4","size":0,"receivedAt":123,
+            "country":"DE","asn":3320,"asOrg":"Deutsche Telekom AG"}"#;
+        let req: CapturedRequest = serde_json::from_str(json).unwrap();
+        assert_eq!(req.country.as_deref(), Some("DE"));
+        assert_eq!(req.asn, Some(3320));
+        assert_eq!(req.as_org.as_deref(), Some("Deutsche Telekom AG"));
+    }
+
     #[test]
     fn test_deserialize_request_bare_array() {
         let json = r#"[
@@ -418,6 +2063,40 @@ mod tests {
         assert!(u.period_end.is_none());
     }
 
+    #[test]
+    fn test_network_policy_deny_roundtrip() {
+        let json = r#"{"deny":{"countries":["T1"],"cidrs":["198.51.100.0/24"]},"captureRejected":true}"#;
+        let policy: NetworkPolicy = serde_json::from_str(json).unwrap();
+        assert!(policy.allow.is_none());
+        assert_eq!(policy.deny.as_ref().unwrap().countries, vec!["T1"]);
+        assert!(policy.capture_rejected);
+        assert_eq!(serde_json::to_string(&policy).unwrap(), json);
+
+        let open: NetworkPolicy = serde_json::from_str(r#"{"tags":[]}"#).unwrap();
+        assert!(!open.capture_rejected);
+        assert_eq!(serde_json::to_string(&open).unwrap(), "{}");
+    }
+
+    #[test]
+    fn test_network_rule_providers_roundtrip() {
+        let json = r#"{"allow":{"cidrs":["203.0.113.0/24"],"providers":["github"]}}"#;
+        let policy: NetworkPolicy = serde_json::from_str(json).unwrap();
+        assert_eq!(policy.allow.as_ref().unwrap().providers, vec!["github"]);
+        assert_eq!(serde_json::to_string(&policy).unwrap(), json);
+    }
+
+    #[test]
+    fn test_deserialize_api_usage() {
+        let meter = r#"{"used":3,"limit":100,"remaining":97}"#;
+        let json = format!(
+            r#"{{"plan":"free","day":"2026-03-14","resetsAt":1773532800000,"apiCalls":{meter},"streamMinutes":{meter},"replays":{meter},"exports":{meter}}}"#
+        );
+        let u: ApiUsage = serde_json::from_str(&json).unwrap();
+        assert_eq!(u.day, "2026-03-14");
+        assert_eq!(u.resets_at, 1773532800000);
+        assert_eq!(u.replays.remaining, 97);
+    }
+
     #[test]
     fn test_deserialize_send_response() {
         let json = r#"{"status":200,"statusText":"OK","body":"OK"}"#;
@@ -430,13 +2109,173 @@ mod tests {
         let mock = MockResponse {
             status: 200,
             body: "ok".into(),
+            body_base64: None,
             headers: HashMap::new(),
             delay: None,
+            delay_jitter: None,
+            header_policy: None,
+            by_method: HashMap::new(),
+            correlation: None,
+            variants: Vec::new(),
+            schedule: Vec::new(),
+            rules: Vec::new(),
+            sequence: None,
+            script: None,
+            echo: false,
+            stream: None,
+            representations: BTreeMap::new(),
+            grpc: None,
         };
         let json = serde_json::to_string(&mock).unwrap();
         assert!(!json.contains("delay"), "delay should be skipped when None: {json}");
     }
 
+    #[test]
+    fn test_mock_response_header_policy_roundtrip() {
+        let json = r#"{"status":200,"body":"","headers":{},"headerPolicy":{"allow":["set-cookie"]}}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        let policy = mock.header_policy.as_ref().unwrap();
+        assert_eq!(policy.allow, vec!["set-cookie"]);
+        assert!(policy.block.is_empty());
+
+        let out = serde_json::to_string(&mock).unwrap();
+        assert!(out.contains(r#""headerPolicy":{"allow":["set-cookie"]}"#), "{out}");
+    }
+
+    #[test]
+    fn test_mock_response_by_method() {
+        let json = r#"{"status":200,"byMethod":{"GET":{"status":200,"body":"[]"},"DELETE":{"status":204}}}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        assert_eq!(mock.by_method["GET"].body, "[]");
+        assert_eq!(mock.by_method["DELETE"].status, 204);
+        let value = serde_json::to_value(&mock).unwrap();
+        assert_eq!(value["byMethod"]["DELETE"]["status"], 204);
+        let plain: MockResponse = serde_json::from_str(r#"{"status":200}"#).unwrap();
+        assert!(serde_json::to_value(&plain).unwrap().get("byMethod").is_none());
+    }
+
+    #[test]
+    fn test_mock_response_representations() {
+        let json = r#"{"status":200,"body":"{}","representations":{"text/html":"<p>ok</p>"}}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        assert_eq!(mock.representations["text/html"], "<p>ok</p>");
+        let value = serde_json::to_value(&mock).unwrap();
+        assert_eq!(value["representations"]["text/html"], "<p>ok</p>");
+    }
+
+    #[test]
+    fn test_mock_response_correlation_defaults() {
+        let json = r#"{"status":200,"correlation":{"key":"body.json.order_id","responses":{"ord_1":{"status":409}}}}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        let correlation = mock.correlation.unwrap();
+        assert_eq!(correlation.key, "body.json.order_id");
+        let reply = &correlation.responses["ord_1"];
+        assert_eq!(reply.status, 409);
+        assert!(reply.body.is_empty() && reply.headers.is_empty());
+    }
+
+    #[test]
+    fn test_mock_response_variants_roundtrip() {
+        let json = r#"{"status":200,"body":"ok","headers":{},"variants":[{"name":"rate_limited","weight":20,"status":429}]}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        assert_eq!(mock.variants.len(), 1);
+        assert_eq!((mock.variants[0].weight, mock.variants[0].status), (20, 429));
+
+        let out = serde_json::to_string(&mock).unwrap();
+        assert!(out.contains(r#""name":"rate_limited""#), "{out}");
+        let plain: MockResponse = serde_json::from_str(r#"{"status":200}"#).unwrap();
+        assert!(!serde_json::to_string(&plain).unwrap().contains("variants"));
+    }
+
+    #[test]
+    fn test_mock_response_schedule_roundtrip() {
+        let json = r#"{"status":200,"schedule":[{"name":"maintenance","start":"02:00","end":"03:00","timezone":"Europe/Berlin","status":503}]}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        let window = &mock.schedule[0];
+        assert_eq!((window.start.as_str(), window.end.as_str()), ("02:00", "03:00"));
+        assert!(window.days.is_empty());
+
+        let out = serde_json::to_string(&mock).unwrap();
+        assert!(out.contains(r#""timezone":"Europe/Berlin""#), "{out}");
+        assert!(!out.contains("days"), "{out}");
+        let plain: MockResponse = serde_json::from_str(r#"{"status":200}"#).unwrap();
+        assert!(!serde_json::to_string(&plain).unwrap().contains("schedule"));
+    }
+
+    #[test]
+    fn test_mock_response_rules_roundtrip() {
+        let json = r#"{"status":200,"rules":[{"match":{"methods":["POST"],"body":{"data.status":"refund*"}},"status":409,"delay":1000,"delayJitter":500}]}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        let rule = &mock.rules[0];
+        assert_eq!((rule.delay, rule.delay_jitter), (Some(1000), Some(500)));
+        assert_eq!(rule.conditions.methods, vec!["POST"]);
+        assert_eq!(rule.conditions.body["data.status"], "refund*");
+        assert_eq!((rule.status, rule.body.as_str()), (409, ""));
+
+        let out = serde_json::to_string(&mock).unwrap();
+        assert!(
+            out.contains(r#""match":{"methods":["POST"],"body":{"data.status":"refund*"}}"#),
+            "{out}"
+        );
+    }
+
+    #[test]
+    fn test_mock_response_sequence_roundtrip() {
+        let json = r#"{"status":200,"sequence":{"steps":[{"status":500,"count":2},{"status":202,"body":"ok"}],"key":"header.x-delivery","resetAfter":300}}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        let sequence = mock.sequence.as_ref().unwrap();
+        assert_eq!(sequence.steps[0].count, Some(2));
+        assert_eq!(
+            (sequence.steps[1].status, sequence.steps[1].body.as_str()),
+            (202, "ok")
+        );
+        assert_eq!(sequence.key.as_deref(), Some("header.x-delivery"));
+        assert_eq!((sequence.repeat, sequence.reset_after), (false, Some(300)));
+
+        let out = serde_json::to_string(&mock).unwrap();
+        assert!(out.contains(r#""resetAfter":300"#), "{out}");
+        assert!(!out.contains("repeat"), "{out}");
+    }
+
+    #[test]
+    fn test_mock_response_script_roundtrip() {
+        let json = r#"{"status":200,"body":"","headers":{},"script":"if request.method == \"DELETE\" { 405 }"}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        assert_eq!(
+            mock.script.as_deref(),
+            Some(r#"if request.method == "DELETE" { 405 }"#)
+        );
+        let out = serde_json::to_string(&mock).unwrap();
+        assert!(out.contains(r#""script":"#), "{out}");
+
+        let plain: MockResponse = serde_json::from_str(r#"{"status":200}"#).unwrap();
+        assert!(!serde_json::to_string(&plain).unwrap().contains("script"));
+    }
+
+    #[test]
+    fn test_mock_response_echo_roundtrip() {
+        let mock: MockResponse =
+            serde_json::from_str(r#"{"status":200,"body":"","headers":{},"echo":true}"#).unwrap();
+        assert!(mock.echo);
+        let out = serde_json::to_string(&mock).unwrap();
+        assert!(out.contains(r#""echo":true"#), "{out}");
+
+        let plain: MockResponse = serde_json::from_str(r#"{"status":200}"#).unwrap();
+        assert!(!serde_json::to_string(&plain).unwrap().contains("echo"));
+    }
+
+    #[test]
+    fn test_mock_response_stream_roundtrip() {
+        let json = r#"{"status":200,"stream":{"events":[{"event":"progress","data":"50","delay":500}]}}"#;
+        let mock: MockResponse = serde_json::from_str(json).unwrap();
+        let stream = mock.stream.as_ref().unwrap();
+        assert_eq!(stream.format, None);
+        assert_eq!(stream.events[0].event.as_deref(), Some("progress"));
+        assert_eq!(stream.events[0].delay, Some(500));
+        let out = serde_json::to_string(&mock).unwrap();
+        assert!(out.contains(r#""stream":{"events":[{"data":"50","event":"progress","delay":500}]}"#), "{out}");
+    }
+
     #[test]
     fn test_token_debug_redacts() {
         let token = Token {
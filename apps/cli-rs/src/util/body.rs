@@ -15,3 +15,78 @@ pub fn resolve_body(body_raw: Option<&str>, body: Option<&str>) -> Option<Vec<u8
     }
     body.map(|b| b.as_bytes().to_vec())
 }
+
+/// Bytes shown in the hex dump of a binary body.
+const HEX_PREVIEW_BYTES: usize = 256;
+
+/// The body as it should be displayed, chosen by the capture's content
+/// class: JSON pretty-printed, images, protobuf and other binary as a hex
+/// dump of the first bytes, and everything else as received. Captures
+/// without a class (stored before classification) are pretty-printed when
+/// they parse as JSON.
+pub fn display_body(
+    content_class: Option<&str>,
+    body_raw: Option<&str>,
+    body: Option<&str>,
+) -> Option<String> {
+    match content_class {
+        Some("image" | "protobuf" | "binary") => {
+            let bytes = resolve_body(body_raw, body)?;
+            (!bytes.is_empty()).then(|| hex_dump(&bytes))
+        }
+        Some("json") | None => {
+            let body = body.filter(|b| !b.is_empty())?;
+            Some(match serde_json::from_str::<serde_json::Value>(body) {
+                Ok(val) => serde_json::to_string_pretty(&val).unwrap_or_else(|_| body.to_string()),
+                Err(_) => body.to_string(),
+            })
+        }
+        Some(_) => body.filter(|b| !b.is_empty()).map(str::to_string),
+    }
+}
+
+/// Offset, hex and printable ASCII, 16 bytes per line, of the first
+/// [`HEX_PREVIEW_BYTES`] bytes.
+fn hex_dump(bytes: &[u8]) -> String {
+    let shown = &bytes[..bytes.len().min(HEX_PREVIEW_BYTES)];
+    let mut out = Vec::new();
+    for (i, chunk) in shown.chunks(16).enumerate() {
+        let hex: Vec<String> = chunk.iter().map(|b| format!("{b:02x}")).collect();
+        let ascii: String = chunk
+            .iter()
+            .map(|&b| if b.is_ascii_graphic() || b == b' ' { b as char } else { '.' })
+            .collect();
+        out.push(format!("{:08x}  {:<47}  |{ascii}|", i * 16, hex.join(" ")));
+    }
+    if bytes.len() > shown.len() {
+        out.push(format!("… {} more bytes", bytes.len() - shown.len()));
+    }
+    out.join("\n")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn json_is_pretty_printed_with_or_without_a_class() {
+        let pretty = "{\n  \"a\": 1\n}";
+        assert_eq!(display_body(Some("json"), None, Some(r#"{"a":1}"#)).as_deref(), Some(pretty));
+        assert_eq!(display_body(None, None, Some(r#"{"a":1}"#)).as_deref(), Some(pretty));
+        assert_eq!(display_body(None, None, Some("plain")).as_deref(), Some("plain"));
+        // A text class is shown as received, even when it happens to parse
+        assert_eq!(display_body(Some("text"), None, Some("[1]")).as_deref(), Some("[1]"));
+        assert_eq!(display_body(Some("xml"), None, Some("")), None);
+    }
+
+    #[test]
+    fn binary_classes_are_hex_dumped() {
+        // "\x89PNG\r\n\x1a\n" + "ab"
+        let dump = display_body(Some("image"), Some("iVBORw0KGgphYg=="), None).unwrap();
+        assert_eq!(dump, "00000000  89 50 4e 47 0d 0a 1a 0a 61 62                    |.PNG....ab|");
+
+        let long = hex_dump(&[0u8; 300]);
+        assert_eq!(long.lines().count(), 17);
+        assert!(long.ends_with("… 44 more bytes"));
+    }
+}
@@ -0,0 +1,266 @@
+//! Local cache of fetched captures so `requests list`, `requests get` and
+//! `requests diff` work offline and skip the network for already-seen items.
+//!
+//! One JSON file per endpoint under `~/.cache/whk/captures/<api>/<slug>.json`,
+//! newest request first. `<api>` is a short hash of the API base URL so
+//! switching servers never mixes captures.
+
+use anyhow::{Context, Result};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use std::collections::HashSet;
+use std::path::{Path, PathBuf};
+
+use crate::types::CapturedRequest;
+
+/// Requests kept per endpoint; older ones are dropped on write.
+pub const MAX_CACHED_PER_ENDPOINT: usize = 1000;
+
+/// Bucket for requests fetched by ID. Slugs never start with `_`.
+const FETCHED_BUCKET: &str = "_fetched";
+
+#[derive(Debug, Default, Serialize, Deserialize)]
+pub struct EndpointCache {
+    #[serde(rename = "updatedAt", default)]
+    pub updated_at: i64,
+    #[serde(default)]
+    pub requests: Vec<CapturedRequest>,
+    /// Whether `requests` reaches back to the endpoint's oldest retained
+    /// request, so any listing limit can be served from the cache.
+    #[serde(default)]
+    pub complete: bool,
+}
+
+impl EndpointCache {
+    /// Newest cached `received_at`, used as `since` for incremental fetches.
+    pub fn newest(&self) -> Option<i64> {
+        self.requests.first().map(|r| r.received_at)
+    }
+
+    /// Add `fresh` requests, replacing cached copies with the same ID.
+    pub fn merge(&mut self, fresh: Vec<CapturedRequest>) {
+        let ids: HashSet<String> = fresh.iter().map(|r| r.id.clone()).collect();
+        self.requests.retain(|r| !ids.contains(&r.id));
+        self.requests.extend(fresh);
+        self.requests.sort_by(|a, b| b.received_at.cmp(&a.received_at));
+        if self.requests.len() > MAX_CACHED_PER_ENDPOINT {
+            self.requests.truncate(MAX_CACHED_PER_ENDPOINT);
+            self.complete = false;
+        }
+    }
+}
+
+/// Cache root for one API base URL.
+pub struct CaptureCache {
+    dir: PathBuf,
+}
+
+impl CaptureCache {
+    pub fn new(api_base: &str) -> Result<Self> {
+        let base = dirs::cache_dir().context("could not determine cache directory")?;
+        Ok(Self::at(base.join("whk").join("captures"), api_base))
+    }
+
+    fn at(root: PathBuf, api_base: &str) -> Self {
+        let digest = hex::encode(Sha256::digest(api_base.as_bytes()));
+        Self { dir: root.join(&digest[..12]) }
+    }
+
+    fn path(&self, slug: &str) -> PathBuf {
+        // Slugs are URL-safe already; strip anything that could escape the directory.
+        let name: String = slug
+            .chars()
+            .filter(|c| c.is_ascii_alphanumeric() || matches!(c, '-' | '_'))
+            .collect();
+        self.dir.join(format!("{name}.json"))
+    }
+
+    /// Cached requests for `slug`, or an empty cache when none (or unreadable).
+    pub fn load(&self, slug: &str) -> EndpointCache {
+        read(&self.path(slug)).unwrap_or_default()
+    }
+
+    pub fn save(&self, slug: &str, cache: &EndpointCache) -> Result<()> {
+        std::fs::create_dir_all(&self.dir).context("failed to create cache directory")?;
+
+        #[cfg(unix)]
+        {
+            use std::os::unix::fs::PermissionsExt;
+            std::fs::set_permissions(&self.dir, std::fs::Permissions::from_mode(0o700)).ok();
+        }
+
+        let json = serde_json::to_string(cache)?;
+        write_private(&self.path(slug), &json)
+    }
+
+    /// Drop everything cached for `slug`. The next listing refetches it.
+    pub fn clear(&self, slug: &str) -> Result<()> {
+        // Requests fetched by ID are matched to the endpoint through its ID.
+        let endpoint_ids: HashSet<String> =
+            self.load(slug).requests.into_iter().map(|r| r.endpoint_id).collect();
+        if !endpoint_ids.is_empty() {
+            let mut fetched = self.load(FETCHED_BUCKET);
+            let before = fetched.requests.len();
+            fetched.requests.retain(|r| !endpoint_ids.contains(&r.endpoint_id));
+            if fetched.requests.len() != before {
+                self.save(FETCHED_BUCKET, &fetched)?;
+            }
+        }
+
+        match std::fs::remove_file(self.path(slug)) {
+            Err(e) if e.kind() != std::io::ErrorKind::NotFound => Err(e.into()),
+            _ => Ok(()),
+        }
+    }
+
+    /// Look a request up by ID across every cached endpoint.
+    pub fn find(&self, id: &str) -> Option<CapturedRequest> {
+        let entries = std::fs::read_dir(&self.dir).ok()?;
+        entries
+            .filter_map(|e| read(&e.ok()?.path()))
+            .find_map(|cache| cache.requests.into_iter().find(|r| r.id == id))
+    }
+
+    /// Store a request fetched on its own (e.g. by `requests get`): refresh
+    /// any cached copy in place, otherwise keep it in a separate bucket that
+    /// `find` also searches, since its slug isn't known.
+    pub fn remember(&self, req: CapturedRequest) -> Result<()> {
+        if let Ok(entries) = std::fs::read_dir(&self.dir) {
+            for path in entries.filter_map(|e| Some(e.ok()?.path())) {
+                let Some(mut cache) = read(&path) else { continue };
+                if let Some(slot) = cache.requests.iter_mut().find(|r| r.id == req.id) {
+                    *slot = req;
+                    let json = serde_json::to_string(&cache)?;
+                    return write_private(&path, &json);
+                }
+            }
+        }
+        let mut cache = self.load(FETCHED_BUCKET);
+        cache.merge(vec![req]);
+        self.save(FETCHED_BUCKET, &cache)
+    }
+}
+
+/// Remove every cached capture (all API bases), e.g. on logout.
+pub fn purge() -> Result<()> {
+    let Some(base) = dirs::cache_dir() else { return Ok(()) };
+    match std::fs::remove_dir_all(base.join("whk").join("captures")) {
+        Err(e) if e.kind() != std::io::ErrorKind::NotFound => Err(e.into()),
+        _ => Ok(()),
+    }
+}
+
+/// Write a cache file only its owner can read, like the token file: captures
+/// carry whatever headers and bodies senders sent.
+fn write_private(path: &Path, json: &str) -> Result<()> {
+    #[cfg(unix)]
+    {
+        use std::io::Write;
+        use std::os::unix::fs::{OpenOptionsExt, PermissionsExt};
+        let mut file = std::fs::OpenOptions::new()
+            .write(true)
+            .create(true)
+            .truncate(true)
+            .mode(0o600)
+            .open(path)
+            .context("failed to write capture cache")?;
+        // `mode` only applies to new files; older ones were written at the umask
+        file.set_permissions(std::fs::Permissions::from_mode(0o600))
+            .context("failed to write capture cache")?;
+        file.write_all(json.as_bytes())
+            .context("failed to write capture cache")?;
+    }
+
+    #[cfg(not(unix))]
+    {
+        std::fs::write(path, json).context("failed to write capture cache")?;
+    }
+
+    Ok(())
+}
+
+fn read(path: &Path) -> Option<EndpointCache> {
+    let raw = std::fs::read_to_string(path).ok()?;
+    serde_json::from_str(&raw).ok()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn req(id: &str, received_at: i64) -> CapturedRequest {
+        CapturedRequest {
+            id: id.into(),
+            received_at,
+            ..CapturedRequest::test_default()
+        }
+    }
+
+    fn temp_cache(name: &str) -> CaptureCache {
+        let root = std::env::temp_dir().join(format!("whk-cache-test-{name}-{}", std::process::id()));
+        let _ = std::fs::remove_dir_all(&root);
+        CaptureCache::at(root, "https://example.test")
+    }
+
+    #[test]
+    fn test_merge_dedupes_and_orders_newest_first() {
+        let mut cache = EndpointCache::default();
+        cache.merge(vec![req("a", 1), req("b", 3)]);
+        cache.merge(vec![req("c", 2), req("b", 3)]);
+        let ids: Vec<&str> = cache.requests.iter().map(|r| r.id.as_str()).collect();
+        assert_eq!(ids, vec!["b", "c", "a"]);
+        assert_eq!(cache.newest(), Some(3));
+    }
+
+    #[test]
+    fn test_save_load_find_and_clear() {
+        let store = temp_cache("roundtrip");
+        let mut cache = EndpointCache::default();
+        cache.merge(vec![req("old", 10), req("new", 20)]);
+        store.save("my-hook", &cache).unwrap();
+
+        assert_eq!(store.load("my-hook").requests.len(), 2);
+        assert_eq!(store.find("old").unwrap().received_at, 10);
+        assert!(store.find("missing").is_none());
+
+        store.remember(req("fetched", 30)).unwrap();
+        assert!(store.find("fetched").is_some());
+        let mut updated = req("old", 10);
+        updated.method = "PUT".into();
+        store.remember(updated).unwrap();
+        assert_eq!(store.load("my-hook").requests[1].method, "PUT");
+
+        store.clear("my-hook").unwrap();
+        assert!(store.load("my-hook").requests.is_empty());
+        assert!(store.find("fetched").is_none());
+        store.clear("my-hook").unwrap();
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_cache_files_are_private() {
+        use std::os::unix::fs::PermissionsExt;
+        let mode = |path: &Path| std::fs::metadata(path).unwrap().permissions().mode() & 0o777;
+
+        let store = temp_cache("private");
+        let mut cache = EndpointCache::default();
+        cache.merge(vec![req("a", 1)]);
+        store.save("my-hook", &cache).unwrap();
+        assert_eq!(mode(&store.path("my-hook")), 0o600);
+        assert_eq!(mode(&store.dir), 0o700);
+
+        // Files left readable by older versions are tightened on the next write
+        let path = store.path("my-hook");
+        std::fs::set_permissions(&path, std::fs::Permissions::from_mode(0o644)).unwrap();
+        store.remember(req("a", 1)).unwrap();
+        assert_eq!(mode(&path), 0o600);
+    }
+
+    #[test]
+    fn test_path_strips_traversal() {
+        let store = temp_cache("path");
+        let path = store.path("../../etc/passwd");
+        assert_eq!(path.file_name().unwrap(), "etcpasswd.json");
+        assert_eq!(path.parent().unwrap(), store.dir);
+    }
+}
@@ -0,0 +1,58 @@
+//! Canonical JSON, for comparing bodies by content: sorted object keys, no
+//! insignificant whitespace, and integral numbers without a fraction (`1.0`
+//! and `1e2` become `1` and `100`). Array order is kept. Matches the form
+//! the receiver hashes for endpoints with canonical JSON turned on.
+
+use serde_json::{Map, Number, Value};
+
+/// Integral floats at or above this magnitude may not be exact integers.
+const MAX_SAFE_INTEGER: f64 = 9_007_199_254_740_992.0;
+
+/// Rebuild `value` in canonical form.
+pub fn canonicalize(value: Value) -> Value {
+    match value {
+        Value::Object(map) => {
+            let mut entries: Vec<(String, Value)> = map.into_iter().collect();
+            entries.sort_by(|a, b| a.0.cmp(&b.0));
+            Value::Object(
+                entries
+                    .into_iter()
+                    .map(|(k, v)| (k, canonicalize(v)))
+                    .collect::<Map<String, Value>>(),
+            )
+        }
+        Value::Array(items) => Value::Array(items.into_iter().map(canonicalize).collect()),
+        Value::Number(n) => Value::Number(canonical_number(n)),
+        other => other,
+    }
+}
+
+/// Canonical text of a JSON body, or `None` when it isn't JSON.
+pub fn canonical_body(body: &str) -> Option<String> {
+    let value: Value = serde_json::from_str(body).ok()?;
+    serde_json::to_string(&canonicalize(value)).ok()
+}
+
+fn canonical_number(n: Number) -> Number {
+    match n.as_f64() {
+        Some(f) if !n.is_i64() && !n.is_u64() && f.fract() == 0.0 && f.abs() < MAX_SAFE_INTEGER => {
+            Number::from(f as i64)
+        }
+        _ => n,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_canonical_body_ignores_key_order_whitespace_and_number_format() {
+        assert_eq!(
+            canonical_body(r#"{ "b": 1.0, "a": [1e2, {"y": 2, "x": -0.5}] }"#).as_deref(),
+            Some(r#"{"a":[100,{"x":-0.5,"y":2}],"b":1}"#)
+        );
+        assert_ne!(canonical_body("[1,2]"), canonical_body("[2,1]"));
+        assert_eq!(canonical_body("a=1&b=2"), None);
+    }
+}
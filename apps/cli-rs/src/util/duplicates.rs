@@ -0,0 +1,185 @@
+use std::collections::HashMap;
+
+use crate::types::CapturedRequest;
+use crate::util::canonical;
+
+/// One row of a collapsed request list.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct CollapsedRow {
+    /// Index of the request shown for the group (its first occurrence in the list)
+    pub index: usize,
+    /// Requests in the group, including the one shown
+    pub count: usize,
+}
+
+/// The group a request belongs to: the original it duplicates, or itself.
+pub fn group_key(req: &CapturedRequest) -> &str {
+    req.duplicate_of.as_deref().unwrap_or(&req.id)
+}
+
+/// Group requests the receiver flagged as identical (same method, path and
+/// body within a short window). Each group is shown at the position of its
+/// first member in `requests`, so newest-first lists stay newest-first.
+pub fn collapse(requests: &[CapturedRequest]) -> Vec<CollapsedRow> {
+    collapse_where(requests, |_| true)
+}
+
+/// Like [`collapse`], counting only the requests that satisfy `keep`.
+pub fn collapse_where(
+    requests: &[CapturedRequest],
+    keep: impl Fn(&CapturedRequest) -> bool,
+) -> Vec<CollapsedRow> {
+    let mut rows: Vec<CollapsedRow> = Vec::new();
+    let mut by_key: HashMap<&str, usize> = HashMap::new();
+    for (index, req) in requests.iter().enumerate().filter(|(_, req)| keep(req)) {
+        match by_key.get(group_key(req)) {
+            Some(&row) => rows[row].count += 1,
+            None => {
+                by_key.insert(group_key(req), rows.len());
+                rows.push(CollapsedRow { index, count: 1 });
+            }
+        }
+    }
+    rows
+}
+
+/// Like [`collapse`], but groups requests with the same method, path and
+/// body content whether or not the receiver linked them: JSON bodies are
+/// compared in canonical form, anything else byte for byte.
+pub fn collapse_canonical(requests: &[CapturedRequest]) -> Vec<CollapsedRow> {
+    let keys: Vec<String> = requests.iter().map(canonical_key).collect();
+    let mut rows: Vec<CollapsedRow> = Vec::new();
+    let mut by_key: HashMap<&str, usize> = HashMap::new();
+    for (index, key) in keys.iter().enumerate() {
+        match by_key.get(key.as_str()) {
+            Some(&row) => rows[row].count += 1,
+            None => {
+                by_key.insert(key, rows.len());
+                rows.push(CollapsedRow { index, count: 1 });
+            }
+        }
+    }
+    rows
+}
+
+fn canonical_key(req: &CapturedRequest) -> String {
+    // Offloaded bodies aren't in the listing; their hash stands in for them.
+    let body = match (&req.body_ref, &req.body_hash) {
+        (Some(_), Some(hash)) => hash.clone(),
+        _ => {
+            let body = req.body.as_deref().unwrap_or_default();
+            canonical::canonical_body(body).unwrap_or_else(|| body.to_string())
+        }
+    };
+    format!("{} {}\n{body}", req.method, req.path)
+}
+
+/// Group requests of the same kind: same receiver fingerprint (method, path
+/// with IDs replaced, body shape), whatever the values. Requests captured
+/// before fingerprints existed stay on their own.
+pub fn group_similar(requests: &[CapturedRequest]) -> Vec<CollapsedRow> {
+    let mut rows: Vec<CollapsedRow> = Vec::new();
+    let mut by_key: HashMap<&str, usize> = HashMap::new();
+    for (index, req) in requests.iter().enumerate() {
+        let Some(key) = req.fingerprint.as_deref() else {
+            rows.push(CollapsedRow { index, count: 1 });
+            continue;
+        };
+        match by_key.get(key) {
+            Some(&row) => rows[row].count += 1,
+            None => {
+                by_key.insert(key, rows.len());
+                rows.push(CollapsedRow { index, count: 1 });
+            }
+        }
+    }
+    rows
+}
+
+/// Requests hidden by collapsing.
+pub fn hidden_count(rows: &[CollapsedRow]) -> usize {
+    rows.iter().map(|r| r.count - 1).sum()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn req(id: &str, duplicate_of: Option<&str>) -> CapturedRequest {
+        CapturedRequest {
+            id: id.into(),
+            duplicate_of: duplicate_of.map(str::to_string),
+            ..CapturedRequest::test_default()
+        }
+    }
+
+    fn with_body(id: &str, body: &str) -> CapturedRequest {
+        CapturedRequest { body: Some(body.to_string()), ..req(id, None) }
+    }
+
+    #[test]
+    fn test_collapse_groups_duplicates_with_original() {
+        // Newest first: two retries of "a", then "b", then the original "a".
+        let requests = vec![
+            req("a3", Some("a")),
+            req("b", None),
+            req("a2", Some("a")),
+            req("a", None),
+        ];
+        let rows = collapse(&requests);
+        assert_eq!(
+            rows,
+            vec![CollapsedRow { index: 0, count: 3 }, CollapsedRow { index: 1, count: 1 }]
+        );
+        assert_eq!(hidden_count(&rows), 2);
+    }
+
+    #[test]
+    fn test_collapse_keeps_unflagged_requests() {
+        let requests = vec![req("a", None), req("b", None)];
+        assert_eq!(collapse(&requests).len(), 2);
+        assert!(collapse(&[]).is_empty());
+    }
+
+    fn with_fingerprint(id: &str, fingerprint: Option<&str>) -> CapturedRequest {
+        CapturedRequest { fingerprint: fingerprint.map(str::to_string), ..req(id, None) }
+    }
+
+    #[test]
+    fn test_group_similar_uses_fingerprints() {
+        let requests = vec![
+            with_fingerprint("c", Some("f1")),
+            with_fingerprint("old1", None),
+            with_fingerprint("b", Some("f2")),
+            with_fingerprint("a", Some("f1")),
+            with_fingerprint("old2", None),
+        ];
+        let rows = group_similar(&requests);
+        assert_eq!(
+            rows,
+            vec![
+                CollapsedRow { index: 0, count: 2 },
+                CollapsedRow { index: 1, count: 1 },
+                CollapsedRow { index: 2, count: 1 },
+                CollapsedRow { index: 4, count: 1 },
+            ]
+        );
+        assert_eq!(hidden_count(&rows), 1);
+    }
+
+    #[test]
+    fn test_collapse_canonical_groups_reordered_json() {
+        let requests = vec![
+            with_body("c", r#"{"b":2,"a":1.0}"#),
+            with_body("b", "plain"),
+            with_body("a", r#"{ "a": 1, "b": 2 }"#),
+        ];
+        let rows = collapse_canonical(&requests);
+        assert_eq!(
+            rows,
+            vec![CollapsedRow { index: 0, count: 2 }, CollapsedRow { index: 1, count: 1 }]
+        );
+        // The receiver's flags alone don't link them
+        assert_eq!(collapse(&requests).len(), 3);
+    }
+}
@@ -1,5 +1,7 @@
 use chrono::{DateTime, Local, TimeZone, Utc};
 
+use crate::types::{CapturedRequest, RequestSizes, TlsDetails};
+
 /// Format a unix timestamp (ms) as a local time string.
 pub fn format_timestamp(ts_ms: i64) -> String {
     let dt = Utc
@@ -23,6 +25,76 @@ pub fn format_bytes(bytes: usize) -> String {
     }
 }
 
+/// Describe a capture's sizes, e.g. "2.0 KB body, 412 B headers; stored
+/// 1.5 KB (body transformed)".
+pub fn format_sizes(sizes: &RequestSizes) -> String {
+    let mut line = format!(
+        "{} body, {} headers; stored {}",
+        format_bytes(sizes.body),
+        format_bytes(sizes.headers),
+        format_bytes(sizes.stored)
+    );
+    if !sizes.truncated.is_empty() {
+        let reasons: Vec<String> = sizes
+            .truncated
+            .iter()
+            .map(|(field, reason)| {
+                let reason = match reason.as_str() {
+                    "max_length" => "cut to the length limit",
+                    "offloaded" => "in object storage",
+                    "max_size" => "cut to the endpoint's size limit",
+                    other => other,
+                };
+                format!("{field} {reason}")
+            })
+            .collect();
+        line.push_str(&format!(" ({})", reasons.join(", ")));
+    }
+    line
+}
+
+/// Where a request came from, e.g. "DE, AS3320 Deutsche Telekom AG"; None
+/// when the receiver couldn't tell.
+pub fn format_origin(req: &CapturedRequest) -> Option<String> {
+    let network = req.asn.map(|asn| match req.as_org {
+        Some(ref org) => format!("AS{asn} {org}"),
+        None => format!("AS{asn}"),
+    });
+    match (req.country.as_deref(), network) {
+        (Some(country), Some(network)) => Some(format!("{country}, {network}")),
+        (Some(country), None) => Some(country.to_string()),
+        (None, network) => network,
+    }
+}
+
+/// Describe a TLS connection, e.g. "TLSv1.3 TLS_AES_128_GCM_SHA256 (SNI
+/// hooks.example.com, ALPN h2)".
+pub fn format_tls(tls: &TlsDetails) -> String {
+    let mut line = format!("{} {}", tls.version, tls.cipher);
+    let negotiated: Vec<String> = [("SNI", &tls.sni), ("ALPN", &tls.alpn)]
+        .into_iter()
+        .filter_map(|(label, value)| value.as_ref().map(|value| format!("{label} {value}")))
+        .collect();
+    if !negotiated.is_empty() {
+        line.push_str(&format!(" ({})", negotiated.join(", ")));
+    }
+    line
+}
+
+/// Format a gap between two timestamps (ms) as "850ms", "1.2s", "4m 10s" or "2h 5m".
+pub fn format_gap(ms: i64) -> String {
+    let ms = ms.max(0);
+    if ms < 1000 {
+        format!("{ms}ms")
+    } else if ms < 60_000 {
+        format!("{:.1}s", ms as f64 / 1000.0)
+    } else if ms < 3_600_000 {
+        format!("{}m {}s", ms / 60_000, ms % 60_000 / 1000)
+    } else {
+        format!("{}h {}m", ms / 3_600_000, ms % 3_600_000 / 60_000)
+    }
+}
+
 /// Format a DateTime as ISO 8601 string, or parse one.
 pub fn format_iso(ts_ms: i64) -> String {
     Utc.timestamp_millis_opt(ts_ms)
@@ -92,6 +164,67 @@ mod tests {
         assert_eq!(format_bytes(1_572_864), "1.5 MB");
     }
 
+    #[test]
+    fn test_format_sizes() {
+        let mut sizes = RequestSizes {
+            headers: 412,
+            body: 2048,
+            stored: 2048,
+            truncated: Default::default(),
+        };
+        assert_eq!(format_sizes(&sizes), "2.0 KB body, 412 B headers; stored 2.0 KB");
+        sizes.stored = 0;
+        sizes.truncated.insert("body".into(), "offloaded".into());
+        sizes.truncated.insert("path".into(), "max_length".into());
+        assert_eq!(
+            format_sizes(&sizes),
+            "2.0 KB body, 412 B headers; stored 0 B (body in object storage, path cut to the length limit)"
+        );
+        sizes.stored = 1024;
+        sizes.truncated.insert("body".into(), "max_size".into());
+        assert!(format_sizes(&sizes).contains("stored 1.0 KB (body cut to the endpoint's size limit"));
+    }
+
+    #[test]
+    fn test_format_origin() {
+        let mut req: CapturedRequest = serde_json::from_value(serde_json::json!({
+            "id": "1", "endpointId": "ep", "method": "POST", "path": "/",
+            "ip": "203.0.113.7", "size": 0, "receivedAt": 0,
+        }))
+        .unwrap();
+        assert_eq!(format_origin(&req), None);
+        req.asn = Some(3320);
+        assert_eq!(format_origin(&req).as_deref(), Some("AS3320"));
+        req.country = Some("DE".into());
+        req.as_org = Some("Deutsche Telekom AG".into());
+        assert_eq!(format_origin(&req).as_deref(), Some("DE, AS3320 Deutsche Telekom AG"));
+    }
+
+    #[test]
+    fn test_format_tls() {
+        let mut tls = TlsDetails {
+            version: "TLSv1.2".into(),
+            cipher: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".into(),
+            sni: None,
+            alpn: None,
+        };
+        assert_eq!(format_tls(&tls), "TLSv1.2 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256");
+        tls.sni = Some("hooks.example.com".into());
+        tls.alpn = Some("h2".into());
+        assert_eq!(
+            format_tls(&tls),
+            "TLSv1.2 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 (SNI hooks.example.com, ALPN h2)"
+        );
+    }
+
+    #[test]
+    fn test_format_gap() {
+        assert_eq!(format_gap(850), "850ms");
+        assert_eq!(format_gap(1240), "1.2s");
+        assert_eq!(format_gap(250_000), "4m 10s");
+        assert_eq!(format_gap(7_500_000), "2h 5m");
+    }
+
     #[test]
     fn test_parse_duration() {
         assert_eq!(parse_duration("500").unwrap(), 500);
@@ -1,2 +1,10 @@
 pub mod body;
+pub mod cache;
+pub mod canonical;
+pub mod duplicates;
 pub mod format;
+pub mod parquet;
+pub mod pdf;
+pub mod provider;
+pub mod stats;
+pub mod table;
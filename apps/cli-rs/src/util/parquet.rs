@@ -0,0 +1,304 @@
+//! Minimal Parquet writer for request exports.
+//!
+//! Writes one row group with one uncompressed, PLAIN-encoded data page per
+//! column: enough for any Parquet reader (DuckDB, pandas/pyarrow, Spark) to
+//! load an export, without pulling the Arrow stack into the CLI. Text columns
+//! are optional (nullable) UTF8 byte arrays; integer and timestamp columns are
+//! required INT64.
+//!
+//! Metadata is Thrift compact protocol, hand-encoded below for the few
+//! structures a flat file needs (see parquet.thrift for field numbers).
+
+use crate::util::table::{row_count, Column, Values};
+
+const MAGIC: &[u8] = b"PAR1";
+
+// parquet.thrift enum values
+const TYPE_INT64: i32 = 2;
+const TYPE_BYTE_ARRAY: i32 = 6;
+const REPETITION_REQUIRED: i32 = 0;
+const REPETITION_OPTIONAL: i32 = 1;
+const CONVERTED_UTF8: i32 = 0;
+const CONVERTED_TIMESTAMP_MILLIS: i32 = 9;
+const ENCODING_PLAIN: i32 = 0;
+const ENCODING_RLE: i32 = 3;
+const CODEC_UNCOMPRESSED: i32 = 0;
+const PAGE_DATA: i32 = 0;
+
+// Thrift compact protocol field types
+const CT_I32: u8 = 5;
+const CT_I64: u8 = 6;
+const CT_BINARY: u8 = 8;
+const CT_LIST: u8 = 9;
+const CT_STRUCT: u8 = 12;
+
+/// Encode `columns` as a Parquet file.
+pub fn write(columns: &[Column], created_by: &str) -> Vec<u8> {
+    let rows = row_count(columns);
+    let mut out = MAGIC.to_vec();
+    let mut chunks = Vec::with_capacity(columns.len());
+
+    for column in columns {
+        let data = page_data(&column.values);
+        let mut header = Thrift::new();
+        header.i32(1, PAGE_DATA);
+        header.i32(2, data.len() as i32);
+        header.i32(3, data.len() as i32);
+        header.begin_struct(5);
+        header.i32(1, rows as i32);
+        header.i32(2, ENCODING_PLAIN);
+        header.i32(3, ENCODING_RLE);
+        header.i32(4, ENCODING_RLE);
+        header.end_struct();
+        let header = header.finish();
+
+        let offset = out.len() as i64;
+        out.extend_from_slice(&header);
+        out.extend_from_slice(&data);
+        chunks.push((offset, (header.len() + data.len()) as i64));
+    }
+
+    let mut meta = Thrift::new();
+    meta.i32(1, 1);
+    meta.list_begin(2, CT_STRUCT, columns.len() + 1);
+    // Root of the schema tree
+    meta.list_struct_begin();
+    meta.binary(4, b"schema");
+    meta.i32(5, columns.len() as i32);
+    meta.end_struct();
+    for column in columns {
+        meta.list_struct_begin();
+        meta.i32(1, physical_type(&column.values));
+        let repetition = match column.values {
+            Values::Utf8(_) => REPETITION_OPTIONAL,
+            _ => REPETITION_REQUIRED,
+        };
+        meta.i32(3, repetition);
+        meta.binary(4, column.name.as_bytes());
+        match column.values {
+            Values::Utf8(_) => meta.i32(6, CONVERTED_UTF8),
+            Values::TimestampMillis(_) => meta.i32(6, CONVERTED_TIMESTAMP_MILLIS),
+            Values::Int64(_) => {}
+        }
+        meta.end_struct();
+    }
+    meta.i64(3, rows as i64);
+
+    meta.list_begin(4, CT_STRUCT, 1);
+    meta.list_struct_begin();
+    meta.list_begin(1, CT_STRUCT, columns.len());
+    for (column, &(offset, size)) in columns.iter().zip(&chunks) {
+        meta.list_struct_begin();
+        meta.i64(2, offset);
+        meta.begin_struct(3);
+        meta.i32(1, physical_type(&column.values));
+        meta.list_begin(2, CT_I32, 2);
+        meta.list_i32(ENCODING_PLAIN);
+        meta.list_i32(ENCODING_RLE);
+        meta.list_begin(3, CT_BINARY, 1);
+        meta.list_binary(column.name.as_bytes());
+        meta.i32(4, CODEC_UNCOMPRESSED);
+        meta.i64(5, rows as i64);
+        meta.i64(6, size);
+        meta.i64(7, size);
+        meta.i64(9, offset);
+        meta.end_struct();
+        meta.end_struct();
+    }
+    let total: i64 = chunks.iter().map(|&(_, size)| size).sum();
+    meta.i64(2, total);
+    meta.i64(3, rows as i64);
+    meta.end_struct();
+
+    meta.binary(6, created_by.as_bytes());
+    let meta = meta.finish();
+
+    out.extend_from_slice(&meta);
+    out.extend_from_slice(&(meta.len() as u32).to_le_bytes());
+    out.extend_from_slice(MAGIC);
+    out
+}
+
+fn physical_type(values: &Values) -> i32 {
+    match values {
+        Values::Utf8(_) => TYPE_BYTE_ARRAY,
+        Values::TimestampMillis(_) | Values::Int64(_) => TYPE_INT64,
+    }
+}
+
+/// Definition levels (optional columns only) followed by the PLAIN values.
+fn page_data(values: &Values) -> Vec<u8> {
+    let mut data = Vec::new();
+    match values {
+        Values::TimestampMillis(v) | Values::Int64(v) => {
+            for n in v {
+                data.extend_from_slice(&n.to_le_bytes());
+            }
+        }
+        Values::Utf8(v) => {
+            let levels = definition_levels(v.iter().map(Option::is_some));
+            data.extend_from_slice(&(levels.len() as u32).to_le_bytes());
+            data.extend_from_slice(&levels);
+            for s in v.iter().flatten() {
+                data.extend_from_slice(&(s.len() as u32).to_le_bytes());
+                data.extend_from_slice(s.as_bytes());
+            }
+        }
+    }
+    data
+}
+
+/// 1-bit definition levels as a single bit-packed run of the RLE/bit-packing
+/// hybrid encoding, padded to a multiple of 8 values.
+fn definition_levels(present: impl ExactSizeIterator<Item = bool>) -> Vec<u8> {
+    let groups = present.len().div_ceil(8);
+    let mut out = Vec::with_capacity(groups + 5);
+    varint(&mut out, ((groups as u64) << 1) | 1);
+    let mut packed = vec![0u8; groups];
+    for (i, set) in present.enumerate() {
+        if set {
+            packed[i / 8] |= 1 << (i % 8);
+        }
+    }
+    out.extend_from_slice(&packed);
+    out
+}
+
+fn varint(out: &mut Vec<u8>, mut n: u64) {
+    while n >= 0x80 {
+        out.push((n as u8) | 0x80);
+        n >>= 7;
+    }
+    out.push(n as u8);
+}
+
+fn zigzag(n: i64) -> u64 {
+    ((n << 1) ^ (n >> 63)) as u64
+}
+
+/// Thrift compact protocol encoder for the structures used above. Starts
+/// inside the outermost struct.
+struct Thrift {
+    out: Vec<u8>,
+    /// Last field id written in each open struct
+    last: Vec<i16>,
+}
+
+impl Thrift {
+    fn new() -> Self {
+        Self { out: Vec::new(), last: vec![0] }
+    }
+
+    fn field(&mut self, id: i16, kind: u8) {
+        let last = self.last.last_mut().map_or(0, |l| std::mem::replace(l, id));
+        let delta = id - last;
+        if (1..=15).contains(&delta) {
+            self.out.push(((delta as u8) << 4) | kind);
+        } else {
+            self.out.push(kind);
+            varint(&mut self.out, zigzag(id as i64));
+        }
+    }
+
+    fn i32(&mut self, id: i16, value: i32) {
+        self.field(id, CT_I32);
+        varint(&mut self.out, zigzag(value as i64));
+    }
+
+    fn i64(&mut self, id: i16, value: i64) {
+        self.field(id, CT_I64);
+        varint(&mut self.out, zigzag(value));
+    }
+
+    fn binary(&mut self, id: i16, value: &[u8]) {
+        self.field(id, CT_BINARY);
+        self.list_binary(value);
+    }
+
+    fn begin_struct(&mut self, id: i16) {
+        self.field(id, CT_STRUCT);
+        self.last.push(0);
+    }
+
+    fn end_struct(&mut self) {
+        self.out.push(0);
+        self.last.pop();
+    }
+
+    fn list_begin(&mut self, id: i16, elem: u8, len: usize) {
+        self.field(id, CT_LIST);
+        if len < 15 {
+            self.out.push(((len as u8) << 4) | elem);
+        } else {
+            self.out.push(0xf0 | elem);
+            varint(&mut self.out, len as u64);
+        }
+    }
+
+    /// Start a struct element of a list (closed with `end_struct`).
+    fn list_struct_begin(&mut self) {
+        self.last.push(0);
+    }
+
+    fn list_i32(&mut self, value: i32) {
+        varint(&mut self.out, zigzag(value as i64));
+    }
+
+    fn list_binary(&mut self, value: &[u8]) {
+        varint(&mut self.out, value.len() as u64);
+        self.out.extend_from_slice(value);
+    }
+
+    /// Close the outermost struct and return the bytes.
+    fn finish(mut self) -> Vec<u8> {
+        self.out.push(0);
+        self.out
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_thrift_compact_field_headers() {
+        let mut t = Thrift::new();
+        t.i32(1, 3);
+        t.i32(20, -1);
+        t.binary(21, b"ab");
+        assert_eq!(t.finish(), vec![0x15, 0x06, 0x05, 0x28, 0x01, 0x18, 0x02, b'a', b'b', 0x00]);
+    }
+
+    #[test]
+    fn test_definition_levels_bit_packed() {
+        let levels = definition_levels([true, false, true, true, false, false, false, false, true].into_iter());
+        // Two groups of 8: header (2 << 1) | 1, then the packed bits LSB first.
+        assert_eq!(levels, vec![0x05, 0b0000_1101, 0b0000_0001]);
+    }
+
+    #[test]
+    fn test_write_frames_file_and_values() {
+        let columns = vec![
+            Column {
+                name: "received_at".into(),
+                values: Values::TimestampMillis(vec![1_700_000_000_000]),
+            },
+            Column {
+                name: "body".into(),
+                values: Values::Utf8(vec![Some("hello".into())]),
+            },
+        ];
+        let file = write(&columns, "whk test");
+        assert!(file.starts_with(MAGIC) && file.ends_with(MAGIC));
+
+        let footer_len = u32::from_le_bytes(file[file.len() - 8..file.len() - 4].try_into().unwrap()) as usize;
+        let footer = &file[file.len() - 8 - footer_len..file.len() - 8];
+        // Column names, the root schema name and created_by live in the footer.
+        for needle in [&b"received_at"[..], b"body", b"schema", b"whk test"] {
+            assert!(footer.windows(needle.len()).any(|w| w == needle));
+        }
+        let data = &file[..file.len() - 8 - footer_len];
+        assert!(data.windows(8).any(|w| w == 1_700_000_000_000i64.to_le_bytes()));
+        assert!(data.windows(9).any(|w| w == b"\x05\x00\x00\x00hello"));
+    }
+}
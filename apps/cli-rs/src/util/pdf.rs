@@ -0,0 +1,170 @@
+//! Minimal PDF writer for usage reports.
+//!
+//! Lays plain text out in Courier on A4 pages, with a bold title on the
+//! first one. Courier is one of the standard 14 fonts, so nothing is
+//! embedded and every line keeps its column alignment. Text is encoded as
+//! WinAnsi; characters outside Latin-1 are written as `?`.
+
+const PAGE_WIDTH: u32 = 595;
+const PAGE_HEIGHT: u32 = 842;
+const MARGIN: u32 = 56;
+const FONT_SIZE: u32 = 9;
+const TITLE_SIZE: u32 = 14;
+const LEADING: u32 = 12;
+const LINES_PER_PAGE: usize = ((PAGE_HEIGHT - 2 * MARGIN) / LEADING) as usize;
+/// The title takes the place of this many lines on the first page.
+const TITLE_LINES: usize = 3;
+
+// Fixed object numbers; pages and their content streams follow.
+const CATALOG: usize = 1;
+const PAGES: usize = 2;
+const FONT: usize = 3;
+const BOLD_FONT: usize = 4;
+const INFO: usize = 5;
+
+/// Render `lines` under `title` as a PDF file.
+pub fn write(title: &str, lines: &[String], producer: &str) -> Vec<u8> {
+    let mut contents = Vec::new();
+    let mut rest = lines;
+    loop {
+        let first = contents.is_empty();
+        let room = if first { LINES_PER_PAGE - TITLE_LINES } else { LINES_PER_PAGE };
+        let (page, tail) = rest.split_at(room.min(rest.len()));
+        contents.push(page_content(first.then_some(title), page));
+        rest = tail;
+        if rest.is_empty() {
+            break;
+        }
+    }
+
+    let page_object = |i: usize| INFO + 1 + 2 * i;
+    let kids: Vec<String> = (0..contents.len()).map(|i| format!("{} 0 R", page_object(i))).collect();
+
+    let mut objects: Vec<Vec<u8>> = vec![
+        format!("<< /Type /Catalog /Pages {PAGES} 0 R >>").into_bytes(),
+        format!("<< /Type /Pages /Kids [{}] /Count {} >>", kids.join(" "), contents.len()).into_bytes(),
+        font("Courier"),
+        font("Courier-Bold"),
+        [b"<< /Title ".as_slice(), &string(title), b" /Producer ", &string(producer), b" >>"].concat(),
+    ];
+    for (i, content) in contents.into_iter().enumerate() {
+        objects.push(
+            format!(
+                "<< /Type /Page /Parent {PAGES} 0 R /MediaBox [0 0 {PAGE_WIDTH} {PAGE_HEIGHT}] \
+                 /Resources << /Font << /F1 {FONT} 0 R /F2 {BOLD_FONT} 0 R >> >> /Contents {} 0 R >>",
+                page_object(i) + 1
+            )
+            .into_bytes(),
+        );
+        let mut stream = format!("<< /Length {} >>\nstream\n", content.len()).into_bytes();
+        stream.extend(content);
+        stream.extend(b"\nendstream");
+        objects.push(stream);
+    }
+
+    let mut out = b"%PDF-1.4\n%\xe2\xe3\xcf\xd3\n".to_vec();
+    let mut offsets = Vec::with_capacity(objects.len());
+    for (i, body) in objects.iter().enumerate() {
+        offsets.push(out.len());
+        out.extend(format!("{} 0 obj\n", i + 1).as_bytes());
+        out.extend(body);
+        out.extend(b"\nendobj\n");
+    }
+    let xref = out.len();
+    out.extend(format!("xref\n0 {}\n0000000000 65535 f \n", objects.len() + 1).as_bytes());
+    for offset in offsets {
+        out.extend(format!("{offset:010} 00000 n \n").as_bytes());
+    }
+    out.extend(
+        format!(
+            "trailer\n<< /Size {} /Root {CATALOG} 0 R /Info {INFO} 0 R >>\nstartxref\n{xref}\n%%EOF\n",
+            objects.len() + 1
+        )
+        .as_bytes(),
+    );
+    out
+}
+
+fn font(name: &str) -> Vec<u8> {
+    format!("<< /Type /Font /Subtype /Type1 /BaseFont /{name} /Encoding /WinAnsiEncoding >>").into_bytes()
+}
+
+/// Text operators for one page, top to bottom.
+fn page_content(title: Option<&str>, lines: &[String]) -> Vec<u8> {
+    let top = PAGE_HEIGHT - MARGIN;
+    let mut out = Vec::new();
+    let mut first_line = top;
+    if let Some(title) = title {
+        out.extend(format!("BT /F2 {TITLE_SIZE} Tf {MARGIN} {top} Td ").as_bytes());
+        out.extend(string(title));
+        out.extend(b" Tj ET\n");
+        first_line -= TITLE_LINES as u32 * LEADING;
+    }
+    out.extend(format!("BT /F1 {FONT_SIZE} Tf {LEADING} TL {MARGIN} {first_line} Td").as_bytes());
+    for (i, line) in lines.iter().enumerate() {
+        out.extend(if i == 0 { b"\n".as_slice() } else { b"\nT* ".as_slice() });
+        out.extend(string(line));
+        out.extend(b" Tj");
+    }
+    out.extend(b"\nET");
+    out
+}
+
+/// A literal string in WinAnsi, with delimiters and backslashes escaped.
+fn string(text: &str) -> Vec<u8> {
+    let mut out = vec![b'('];
+    for c in text.chars() {
+        match c {
+            '(' | ')' | '\\' => out.extend([b'\\', c as u8]),
+            ' '..='~' | '\u{a0}'..='\u{ff}' => out.push(c as u32 as u8),
+            _ => out.push(b'?'),
+        }
+    }
+    out.push(b')');
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn lines(n: usize) -> Vec<String> {
+        (0..n).map(|i| format!("line {i}")).collect()
+    }
+
+    #[test]
+    fn test_xref_points_at_each_object() {
+        let pdf = write("Report", &lines(3), "whk");
+        let text = String::from_utf8_lossy(&pdf);
+        let xref: usize = text.rsplit("startxref\n").next().unwrap().lines().next().unwrap().parse().unwrap();
+        assert!(pdf[xref..].starts_with(b"xref\n0 8\n"));
+
+        let entries: Vec<usize> = std::str::from_utf8(&pdf[xref..])
+            .unwrap()
+            .lines()
+            .skip(3)
+            .take(7)
+            .map(|l| l[..10].parse().unwrap())
+            .collect();
+        for (i, offset) in entries.into_iter().enumerate() {
+            assert!(pdf[offset..].starts_with(format!("{} 0 obj\n", i + 1).as_bytes()));
+        }
+        assert!(text.ends_with("%%EOF\n"));
+    }
+
+    #[test]
+    fn test_long_reports_span_pages() {
+        let one = String::from_utf8_lossy(&write("Report", &lines(LINES_PER_PAGE - TITLE_LINES), "whk")).into_owned();
+        assert!(one.contains("/Count 1 "));
+        let two = String::from_utf8_lossy(&write("Report", &lines(LINES_PER_PAGE), "whk")).into_owned();
+        assert!(two.contains("/Count 2 "));
+        assert!(two.contains("/Kids [6 0 R 8 0 R]"));
+    }
+
+    #[test]
+    fn test_strings_are_escaped_and_latin1() {
+        assert_eq!(string(r"a (b) \c"), br"(a \(b\) \\c)".to_vec());
+        assert_eq!(string("café – ok"), b"(caf\xe9 ? ok)".to_vec());
+        assert_eq!(string("tab\there"), b"(tab?here)".to_vec());
+    }
+}
@@ -0,0 +1,58 @@
+//! Provider and event type of a capture: as the receiver tagged it, or, for
+//! captures stored before it did, detected here with the same rules (the
+//! `webhooks-providers` crate the receiver uses).
+
+use webhooks_providers::{Provider, detect};
+
+use crate::types::CapturedRequest;
+
+/// Display name of the provider that sent `req`, and the event type when
+/// known.
+pub fn provider(req: &CapturedRequest) -> Option<(String, Option<String>)> {
+    if let Some(ref id) = req.provider {
+        let name = Provider::from_id(id).map_or_else(|| id.clone(), |p| p.name().to_string());
+        return Some((name, req.event_type.clone()));
+    }
+    let header = |name: &str| {
+        req.headers
+            .iter()
+            .find(|(k, _)| k.eq_ignore_ascii_case(name))
+            .map(|(_, v)| v.as_str())
+    };
+    let body = req.body.as_deref().unwrap_or_default();
+    let detected = detect(header, body.as_bytes())?;
+    Some((detected.provider.name().to_string(), detected.event_type))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn req(value: serde_json::Value) -> CapturedRequest {
+        let mut base = serde_json::json!({
+            "id": "r1",
+            "endpointId": "ep",
+            "method": "POST",
+            "path": "/",
+            "receivedAt": 0,
+        });
+        base.as_object_mut().unwrap().extend(value.as_object().unwrap().clone());
+        serde_json::from_value(base).unwrap()
+    }
+
+    #[test]
+    fn test_tagged_captures_use_the_receivers_values() {
+        let tagged = req(serde_json::json!({"provider": "stripe", "eventType": "invoice.paid"}));
+        assert_eq!(provider(&tagged), Some(("Stripe".to_string(), Some("invoice.paid".to_string()))));
+    }
+
+    #[test]
+    fn test_older_captures_are_detected() {
+        let untagged = req(serde_json::json!({
+            "headers": {"X-GitHub-Event": "issues"},
+            "body": "{\"action\": \"opened\"}",
+        }));
+        assert_eq!(provider(&untagged), Some(("GitHub".to_string(), Some("issues.opened".to_string()))));
+        assert_eq!(provider(&req(serde_json::json!({}))), None);
+    }
+}
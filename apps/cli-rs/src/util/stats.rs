@@ -0,0 +1,282 @@
+//! Aggregates over captured requests for `whk requests stats`.
+
+use serde::Serialize;
+use std::collections::HashMap;
+use std::str::FromStr;
+
+use crate::types::CapturedRequest;
+
+/// Label for requests without a value for the grouping field.
+pub const NONE_LABEL: &str = "(none)";
+
+/// Field requests are grouped by.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum GroupBy {
+    Method,
+    Path,
+    /// Header value, by case-insensitive name
+    Header(String),
+    /// Query parameter value
+    Query(String),
+    Provider,
+    EventType,
+    ContentClass,
+    Ip,
+    Country,
+    /// Autonomous system, as `AS<number>`
+    Asn,
+}
+
+impl FromStr for GroupBy {
+    type Err = anyhow::Error;
+
+    fn from_str(s: &str) -> anyhow::Result<Self> {
+        if let Some(name) = s.strip_prefix("header:") {
+            if name.trim().is_empty() {
+                anyhow::bail!("header: needs a header name, e.g. header:X-Event-Type");
+            }
+            return Ok(GroupBy::Header(name.trim().to_lowercase()));
+        }
+        if let Some(name) = s.strip_prefix("query:") {
+            if name.is_empty() {
+                anyhow::bail!("query: needs a parameter name, e.g. query:type");
+            }
+            return Ok(GroupBy::Query(name.to_string()));
+        }
+        match s {
+            "method" => Ok(GroupBy::Method),
+            "path" => Ok(GroupBy::Path),
+            "provider" => Ok(GroupBy::Provider),
+            "event-type" => Ok(GroupBy::EventType),
+            "content-class" => Ok(GroupBy::ContentClass),
+            "ip" => Ok(GroupBy::Ip),
+            "country" => Ok(GroupBy::Country),
+            "asn" => Ok(GroupBy::Asn),
+            other => anyhow::bail!(
+                "unknown group {other:?}; use method, path, header:<name>, query:<name>, provider, event-type, content-class, ip, country or asn"
+            ),
+        }
+    }
+}
+
+impl GroupBy {
+    /// The request's value for this field, if it has one.
+    pub fn value(&self, req: &CapturedRequest) -> Option<String> {
+        match self {
+            GroupBy::Method => Some(req.method.clone()),
+            GroupBy::Path => Some(req.path.clone()),
+            GroupBy::Header(name) => req
+                .headers
+                .iter()
+                .find(|(k, _)| k.eq_ignore_ascii_case(name))
+                .map(|(_, v)| v.clone()),
+            GroupBy::Query(name) => req.query_params.get(name).cloned(),
+            GroupBy::Provider => req.provider.clone(),
+            GroupBy::EventType => req
+                .event_type
+                .clone()
+                .or_else(|| req.cloud_event.as_ref().map(|e| e.event_type.clone())),
+            GroupBy::ContentClass => req.content_class.clone(),
+            GroupBy::Ip => Some(req.ip.clone()).filter(|ip| !ip.is_empty()),
+            GroupBy::Country => req.country.clone(),
+            GroupBy::Asn => req.asn.map(|asn| format!("AS{asn}")),
+        }
+    }
+}
+
+/// Nearest-rank percentiles of body sizes, in bytes.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize)]
+pub struct SizePercentiles {
+    pub p50: usize,
+    pub p90: usize,
+    pub p99: usize,
+}
+
+/// Counts for one group (or for all requests when not grouping).
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct GroupStats {
+    pub key: String,
+    pub count: usize,
+    /// Share of all requests in the window, 0-1
+    pub share: f64,
+    pub size: SizePercentiles,
+    #[serde(rename = "firstAt")]
+    pub first_at: i64,
+    #[serde(rename = "lastAt")]
+    pub last_at: i64,
+    /// Requests per equal slice of the window, oldest first
+    pub buckets: Vec<usize>,
+}
+
+/// Aggregate `requests` received in `[from, to)` into groups, busiest first
+/// (ties by key). Without `group_by` everything is one group keyed "all".
+pub fn aggregate(
+    requests: &[CapturedRequest],
+    group_by: Option<&GroupBy>,
+    from: i64,
+    to: i64,
+    buckets: usize,
+) -> Vec<GroupStats> {
+    let in_window: Vec<&CapturedRequest> = requests
+        .iter()
+        .filter(|r| r.received_at >= from && r.received_at < to)
+        .collect();
+    let total = in_window.len();
+
+    let mut groups: HashMap<String, Vec<&CapturedRequest>> = HashMap::new();
+    for req in in_window {
+        let key = match group_by {
+            Some(g) => g.value(req).unwrap_or_else(|| NONE_LABEL.to_string()),
+            None => "all".to_string(),
+        };
+        groups.entry(key).or_default().push(req);
+    }
+
+    let mut stats: Vec<GroupStats> = groups
+        .into_iter()
+        .map(|(key, reqs)| {
+            let mut sizes: Vec<usize> = reqs.iter().map(|r| r.size).collect();
+            sizes.sort_unstable();
+            GroupStats {
+                count: reqs.len(),
+                share: reqs.len() as f64 / total as f64,
+                size: SizePercentiles {
+                    p50: percentile(&sizes, 50.0),
+                    p90: percentile(&sizes, 90.0),
+                    p99: percentile(&sizes, 99.0),
+                },
+                first_at: reqs.iter().map(|r| r.received_at).min().unwrap_or(from),
+                last_at: reqs.iter().map(|r| r.received_at).max().unwrap_or(from),
+                buckets: histogram(reqs.iter().map(|r| r.received_at), from, to, buckets),
+                key,
+            }
+        })
+        .collect();
+    stats.sort_by(|a, b| b.count.cmp(&a.count).then_with(|| a.key.cmp(&b.key)));
+    stats
+}
+
+/// Nearest-rank percentile of an ascending slice; 0 when empty.
+fn percentile(sorted: &[usize], p: f64) -> usize {
+    if sorted.is_empty() {
+        return 0;
+    }
+    let rank = ((p / 100.0) * sorted.len() as f64).ceil() as usize;
+    sorted[rank.clamp(1, sorted.len()) - 1]
+}
+
+/// Count timestamps into `buckets` equal slices of `[from, to)`.
+fn histogram(times: impl Iterator<Item = i64>, from: i64, to: i64, buckets: usize) -> Vec<usize> {
+    let mut counts = vec![0; buckets];
+    let span = (to - from).max(1) as i128;
+    if buckets == 0 {
+        return counts;
+    }
+    for t in times {
+        let index = ((t - from) as i128 * buckets as i128 / span) as usize;
+        counts[index.min(buckets - 1)] += 1;
+    }
+    counts
+}
+
+/// Unicode block sparkline scaled to the largest count.
+pub fn sparkline(counts: &[usize]) -> String {
+    const BARS: [char; 8] = ['▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'];
+    let max = counts.iter().copied().max().unwrap_or(0);
+    counts
+        .iter()
+        .map(|&c| match c {
+            0 => ' ',
+            _ => BARS[((c * BARS.len()).div_ceil(max) - 1).min(BARS.len() - 1)],
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn req(method: &str, path: &str, received_at: i64, size: usize) -> CapturedRequest {
+        CapturedRequest {
+            id: format!("{method}{path}{received_at}"),
+            method: method.into(),
+            path: path.into(),
+            headers: [("x-event-type".to_string(), format!("{method}.event"))].into(),
+            ip: "203.0.113.7".into(),
+            size,
+            received_at,
+            ..CapturedRequest::test_default()
+        }
+    }
+
+    #[test]
+    fn parses_group_fields() {
+        assert_eq!("path".parse::<GroupBy>().unwrap(), GroupBy::Path);
+        assert_eq!(
+            "header:X-Event-Type".parse::<GroupBy>().unwrap(),
+            GroupBy::Header("x-event-type".into())
+        );
+        assert_eq!(
+            "query:type".parse::<GroupBy>().unwrap(),
+            GroupBy::Query("type".into())
+        );
+        assert_eq!("asn".parse::<GroupBy>().unwrap(), GroupBy::Asn);
+        assert!("header:".parse::<GroupBy>().is_err());
+        assert!("status".parse::<GroupBy>().is_err());
+    }
+
+    #[test]
+    fn groups_by_field_busiest_first() {
+        let requests = vec![
+            req("POST", "/a", 10, 100),
+            req("POST", "/a", 20, 300),
+            req("GET", "/b", 30, 0),
+            req("POST", "/old", -5, 10),
+        ];
+        let stats = aggregate(&requests, Some(&GroupBy::Path), 0, 100, 4);
+        assert_eq!(stats.len(), 2);
+        assert_eq!((stats[0].key.as_str(), stats[0].count), ("/a", 2));
+        assert!((stats[0].share - 2.0 / 3.0).abs() < 1e-9);
+        assert_eq!(
+            stats[0].size,
+            SizePercentiles {
+                p50: 100,
+                p90: 300,
+                p99: 300
+            }
+        );
+        assert_eq!((stats[0].first_at, stats[0].last_at), (10, 20));
+        assert_eq!(stats[0].buckets, vec![2, 0, 0, 0]);
+        assert_eq!(stats[1].buckets, vec![0, 1, 0, 0]);
+    }
+
+    #[test]
+    fn missing_values_and_headers_case_insensitively() {
+        let requests = vec![req("POST", "/a", 10, 1), req("GET", "/a", 11, 1)];
+        let by_header = aggregate(
+            &requests,
+            Some(&"header:X-EVENT-TYPE".parse().unwrap()),
+            0,
+            100,
+            1,
+        );
+        assert_eq!(by_header.len(), 2);
+        assert!(by_header.iter().any(|g| g.key == "POST.event"));
+
+        let by_provider = aggregate(&requests, Some(&GroupBy::Provider), 0, 100, 1);
+        assert_eq!(
+            (by_provider[0].key.as_str(), by_provider[0].count),
+            (NONE_LABEL, 2)
+        );
+
+        let all = aggregate(&requests, None, 0, 100, 1);
+        assert_eq!((all[0].key.as_str(), all[0].count), ("all", 2));
+    }
+
+    #[test]
+    fn sparkline_scales_to_the_largest_count() {
+        assert_eq!(sparkline(&[0, 1, 4, 8]), " ▁▄█");
+        assert_eq!(sparkline(&[]), "");
+        assert_eq!(sparkline(&[3, 3]), "██");
+    }
+}
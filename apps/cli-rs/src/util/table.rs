@@ -0,0 +1,108 @@
+//! Column-oriented tables for the CSV and Parquet export formats.
+
+use chrono::{DateTime, SecondsFormat};
+
+/// A named column. Every column of a table has the same number of values.
+pub struct Column {
+    pub name: String,
+    pub values: Values,
+}
+
+pub enum Values {
+    /// Unix milliseconds, rendered as RFC 3339 UTC in CSV
+    TimestampMillis(Vec<i64>),
+    Int64(Vec<i64>),
+    /// Text; `None` is an empty CSV cell and a null in Parquet
+    Utf8(Vec<Option<String>>),
+}
+
+impl Values {
+    fn len(&self) -> usize {
+        match self {
+            Values::TimestampMillis(v) | Values::Int64(v) => v.len(),
+            Values::Utf8(v) => v.len(),
+        }
+    }
+
+    fn cell(&self, row: usize) -> String {
+        match self {
+            Values::TimestampMillis(v) => DateTime::from_timestamp_millis(v[row])
+                .map(|dt| dt.to_rfc3339_opts(SecondsFormat::Millis, true))
+                .unwrap_or_default(),
+            Values::Int64(v) => v[row].to_string(),
+            Values::Utf8(v) => v[row].as_deref().map(csv_text).unwrap_or_default(),
+        }
+    }
+}
+
+/// Number of rows in `columns`.
+pub fn row_count(columns: &[Column]) -> usize {
+    columns.first().map_or(0, |c| c.values.len())
+}
+
+/// Render `columns` as RFC 4180 CSV with a header row.
+pub fn to_csv(columns: &[Column]) -> String {
+    let mut out = String::new();
+    let header: Vec<String> = columns.iter().map(|c| csv_quote(&c.name)).collect();
+    out.push_str(&header.join(","));
+    out.push_str("\r\n");
+    for row in 0..row_count(columns) {
+        let cells: Vec<String> = columns.iter().map(|c| csv_quote(&c.values.cell(row))).collect();
+        out.push_str(&cells.join(","));
+        out.push_str("\r\n");
+    }
+    out
+}
+
+fn csv_quote(cell: &str) -> String {
+    if cell.contains([',', '"', '\r', '\n']) {
+        format!("\"{}\"", cell.replace('"', "\"\""))
+    } else {
+        cell.to_string()
+    }
+}
+
+/// Text cells starting with a formula character are prefixed with `'` so
+/// spreadsheets show them instead of evaluating them (webhook bodies and
+/// headers come from whoever sent them). Plain numbers are left alone.
+fn csv_text(value: &str) -> String {
+    let formula = value.starts_with(['=', '+', '-', '@', '\t', '\r']);
+    if formula && value.parse::<f64>().is_err() {
+        format!("'{value}")
+    } else {
+        value.to_string()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_to_csv_quotes_and_guards_formulas() {
+        let columns = vec![
+            Column {
+                name: "received_at".into(),
+                values: Values::TimestampMillis(vec![0, 1_000]),
+            },
+            Column {
+                name: "size".into(),
+                values: Values::Int64(vec![3, 42]),
+            },
+            Column {
+                name: "body".into(),
+                values: Values::Utf8(vec![Some("a,\"b\"\nc".into()), None]),
+            },
+            Column {
+                name: "header.x-note".into(),
+                values: Values::Utf8(vec![Some("=HYPERLINK(\"x\")".into()), Some("-12.5".into())]),
+            },
+        ];
+        assert_eq!(
+            to_csv(&columns),
+            "received_at,size,body,header.x-note\r\n\
+             1970-01-01T00:00:00.000Z,3,\"a,\"\"b\"\"\nc\",\"'=HYPERLINK(\"\"x\"\")\"\r\n\
+             1970-01-01T00:00:01.000Z,42,,-12.5\r\n"
+        );
+    }
+}
@@ -50,6 +50,7 @@ async fn test_endpoint_create_list_get_delete() {
     // Create
     let req = whk::types::CreateEndpointRequest {
         name: Some("integration-test".into()),
+        slug: None,
         is_ephemeral: Some(true),
         expires_at: None,
         mock_response: None,
@@ -86,6 +87,7 @@ async fn test_endpoint_update_mock_response() {
     // Create
     let req = whk::types::CreateEndpointRequest {
         name: Some("mock-test".into()),
+        slug: None,
         is_ephemeral: Some(true),
         expires_at: None,
         mock_response: None,
@@ -123,6 +125,7 @@ async fn test_send_webhook_and_list_requests() {
     // Create endpoint
     let req = whk::types::CreateEndpointRequest {
         name: Some("send-test".into()),
+        slug: None,
         is_ephemeral: Some(true),
         expires_at: None,
         mock_response: None,
@@ -175,6 +178,7 @@ async fn test_mock_response_works() {
 
     let req = whk::types::CreateEndpointRequest {
         name: Some("mock-resp-test".into()),
+        slug: None,
         is_ephemeral: Some(true),
         expires_at: None,
         mock_response: Some(whk::types::MockResponse {
@@ -264,6 +268,7 @@ async fn test_clear_requests() {
     // Create endpoint and send a webhook
     let req = whk::types::CreateEndpointRequest {
         name: Some("clear-test".into()),
+        slug: None,
         is_ephemeral: Some(true),
         expires_at: None,
         mock_response: None,
@@ -312,14 +317,11 @@ fn make_captured_request(
         endpoint_id: "test-ep".into(),
         method: method.into(),
         path: path.into(),
-        headers: HashMap::new(),
         body,
         body_raw,
-        query_params: HashMap::new(),
         content_type: Some("application/octet-stream".into()),
         ip: "127.0.0.1".into(),
-        size: 0,
-        received_at: 0,
+        ..whk::types::CapturedRequest::test_default()
     }
 }
 
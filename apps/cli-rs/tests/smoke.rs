@@ -70,6 +70,238 @@ fn test_requests_help() {
     assert!(stdout.contains("search"));
     assert!(stdout.contains("export"));
     assert!(stdout.contains("clear"));
+    assert!(stdout.contains("diff"));
+    assert!(stdout.contains("body"));
+}
+
+#[test]
+fn test_mock_import_help() {
+    let output = whk().args(["mock", "import", "--help"]).output().unwrap();
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("--from-url"));
+    assert!(stdout.contains("--dry-run"));
+}
+
+#[test]
+fn test_mock_history_help() {
+    let output = whk().args(["mock", "history", "--help"]).output().unwrap();
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("--rollback"));
+}
+
+#[test]
+fn test_annotate_help() {
+    let output = whk().args(["annotate", "--help"]).output().unwrap();
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("--message"));
+    assert!(stdout.contains("--tag"));
+    assert!(stdout.contains("--untag"));
+}
+
+#[test]
+fn test_watch_help() {
+    let output = whk().args(["watch", "--help"]).output().unwrap();
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("configuration changes"));
+}
+
+#[test]
+fn test_create_check_requires_slug() {
+    let output = whk().args(["create", "--check"]).output().unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--slug"));
+}
+
+#[test]
+fn test_update_endpoint_encrypt_flags_conflict() {
+    let output = whk()
+        .args(["update-endpoint", "abc", "--encrypt-header", "authorization", "--clear-encrypted-headers"])
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+}
+
+#[test]
+fn test_update_endpoint_record_responses_takes_bool() {
+    let output = whk()
+        .args(["update-endpoint", "abc", "--record-responses", "sometimes"])
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--record-responses"));
+}
+
+#[test]
+fn test_update_endpoint_priority_value_requires_header() {
+    let output = whk()
+        .args(["update-endpoint", "abc", "--priority-value", "high"])
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--priority-header"));
+}
+
+#[test]
+fn test_update_endpoint_client_ca_conflicts_with_clear() {
+    let output = whk()
+        .args(["update-endpoint", "abc", "--client-ca", "ca.pem", "--clear-client-ca"])
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--clear-client-ca"));
+}
+
+#[test]
+fn test_update_endpoint_custom_domain_conflicts_with_clear() {
+    let output = whk()
+        .args([
+            "update-endpoint",
+            "abc",
+            "--custom-domain",
+            "hooks.example.com",
+            "--clear-custom-domain",
+        ])
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--clear-custom-domain"));
+}
+
+#[test]
+fn test_update_endpoint_dry_run_mode_takes_a_bool() {
+    let output = whk()
+        .args(["update-endpoint", "abc", "--dry-run-mode", "maybe"])
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--dry-run-mode"));
+}
+
+#[test]
+fn test_bench_stream_rejects_zero_count() {
+    let output = whk().args(["bench", "stream", "abc", "--count", "0"]).output().unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--count"));
+}
+
+#[test]
+fn test_requests_list_canonical_requires_collapse() {
+    let output = whk().args(["requests", "list", "abc", "--canonical"]).output().unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--collapse"));
+}
+
+#[test]
+fn test_requests_list_group_conflicts_with_collapse() {
+    let output = whk().args(["requests", "list", "abc", "--group", "--collapse"]).output().unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--collapse"));
+}
+
+#[test]
+fn test_requests_get_delivery_id_header_requires_follow_chain() {
+    let output = whk()
+        .args(["requests", "get", "abc", "--delivery-id-header", "x-delivery"])
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--follow-chain"));
+}
+
+#[test]
+fn test_pause_body_requires_status() {
+    let output = whk().args(["pause", "abc", "--body", "down for maintenance"]).output().unwrap();
+    assert!(!output.status.success());
+}
+
+#[test]
+fn test_activity_rejects_unknown_type() {
+    let output = whk().args(["activity", "--type", "endpoint_deleted"]).output().unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("quota_warning"));
+}
+
+#[test]
+fn test_clear_network_policy_conflicts_with_rules() {
+    let output = whk()
+        .args(["update-endpoint", "abc", "--allow", "US", "--clear-network-policy"])
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("cannot be used with"));
+}
+
+#[test]
+fn test_demo_options_require_demo() {
+    let output = whk().args(["create", "--demo-rate", "10"]).output().unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("--demo <PROVIDER>"));
+
+    let output = whk().args(["create", "--demo", "paypal"]).output().unwrap();
+    assert!(!output.status.success());
+}
+
+#[test]
+fn test_teams_help() {
+    let output = whk().args(["teams", "--help"]).output().unwrap();
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("list"));
+    assert!(stdout.contains("members"));
+    assert!(stdout.contains("--team"));
+}
+
+#[test]
+fn test_share_help() {
+    let output = whk().args(["share", "--help"]).output().unwrap();
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("create"));
+    assert!(stdout.contains("revoke"));
+    assert!(stdout.contains("tail"));
+}
+
+#[test]
+fn test_apply_help() {
+    let output = whk().args(["apply", "--help"]).output().unwrap();
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("--file"));
+    assert!(stdout.contains("--dry-run"));
+    assert!(stdout.contains("--prune"));
+}
+
+#[test]
+fn test_send_help_shows_retry_storm_flags() {
+    let output = whk().args(["send", "--help"]).output().unwrap();
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("--retries"));
+    assert!(stdout.contains("--duplicates"));
+    assert!(stdout.contains("--delivery-id-header"));
+}
+
+#[test]
+fn test_send_rejects_excessive_retries() {
+    let output = whk().args(["send", "abc", "--retries", "50"]).output().unwrap();
+    assert!(!output.status.success());
 }
 
 #[test]
@@ -80,6 +312,41 @@ fn test_completions_bash() {
     assert!(stdout.contains("whk"));
 }
 
+#[test]
+fn test_completions_include_dynamic_hook() {
+    for shell in ["bash", "zsh", "fish"] {
+        let output = whk().args(["completions", shell]).output().unwrap();
+        assert!(output.status.success());
+        let stdout = String::from_utf8_lossy(&output.stdout);
+        assert!(stdout.contains("whk __complete"), "{shell} script missing dynamic hook");
+    }
+}
+
+#[test]
+fn test_complete_is_silent_without_auth() {
+    let home = std::env::temp_dir().join(format!("whk-smoke-{}", std::process::id()));
+    let output = whk()
+        .args(["__complete", "slugs"])
+        .env("HOME", &home)
+        .env("XDG_CONFIG_HOME", &home)
+        .output()
+        .unwrap();
+    assert!(output.status.success());
+    assert!(output.stdout.is_empty());
+}
+
+#[test]
+fn test_missing_slug_without_terminal() {
+    let output = whk()
+        .args(["get"])
+        .stdin(std::process::Stdio::null())
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("missing <SLUG>"), "unexpected stderr: {stderr}");
+}
+
 #[test]
 fn test_completions_zsh() {
     let output = whk().args(["completions", "zsh"]).output().unwrap();
@@ -126,6 +393,16 @@ fn test_usage_json() {
     }
 }
 
+#[test]
+fn test_api_usage_json() {
+    let output = whk().args(["api-usage", "--json"]).output().unwrap();
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    if output.status.success() {
+        let parsed: serde_json::Value = serde_json::from_str(&stdout).unwrap();
+        assert!(parsed.get("apiCalls").is_some());
+    }
+}
+
 // ─── Full Lifecycle (create → send → list → delete) ────────────────────
 
 #[test]
@@ -242,3 +519,19 @@ fn test_invalid_subcommand() {
     let output = whk().arg("nonexistent-command").output().unwrap();
     assert!(!output.status.success());
 }
+
+#[test]
+fn test_latency_window_values() {
+    let output = whk().args(["latency", "abc", "--window", "30d"]).output().unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("24h"));
+}
+
+#[test]
+fn test_report_format_values() {
+    let output = whk().args(["report", "abc", "--format", "xlsx"]).output().unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("pdf"));
+}
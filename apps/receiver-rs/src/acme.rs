@@ -0,0 +1,721 @@
+//! ACME (RFC 8555) certificates for custom domains.
+//!
+//! Certificates are ordered from `ACME_DIRECTORY_URL` (Let's Encrypt unless
+//! set) and validated with the tls-alpn-01 challenge (RFC 8737), answered on
+//! the custom domain listener itself: the domain only has to point at the
+//! receiver, with no port 80 or DNS API involved. While an authorization is
+//! pending, handshakes offering `acme-tls/1` for the domain get a
+//! self-signed certificate carrying the key authorization digest
+//! ([`Challenges`]). Challenges live in the memory of the instance that
+//! placed the order, so the listener must not be load balanced across
+//! receivers while certificates are being issued.
+//!
+//! The account key is kept in Postgres (`get_acme_account()` /
+//! `save_acme_account()`) so restarts reuse one account. Keys are ECDSA P-256
+//! throughout, and the certificate request and challenge certificate are
+//! built with a small DER writer rather than an X.509 library.
+
+use base64::Engine;
+use base64::engine::general_purpose::{STANDARD as BASE64, URL_SAFE_NO_PAD};
+use chrono::{DateTime, Utc};
+use ring::rand::{SecureRandom, SystemRandom};
+use ring::signature::{
+    ECDSA_P256_SHA256_ASN1_SIGNING, ECDSA_P256_SHA256_FIXED_SIGNING, EcdsaKeyPair, KeyPair,
+};
+use rustls::ServerConfig;
+use rustls::pki_types::{CertificateDer, PrivateKeyDer, PrivatePkcs8KeyDer};
+use rustls::pki_types::pem::PemObject;
+use serde::Deserialize;
+use serde_json::{Value, json};
+use sha2::{Digest, Sha256};
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+use tokio::sync::OnceCell;
+
+use crate::client_cert::crypto_provider;
+
+/// Let's Encrypt's production directory.
+pub const DEFAULT_DIRECTORY_URL: &str = "https://acme-v02.api.letsencrypt.org/directory";
+
+/// ALPN protocol of tls-alpn-01 validation handshakes.
+pub const ACME_TLS_ALPN: &[u8] = b"acme-tls/1";
+
+/// Timeout for each request to the CA.
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// How long the CA gets to validate a challenge or issue a finalized order.
+const POLL_TIMEOUT: Duration = Duration::from_secs(90);
+
+/// Wait between polls of a pending authorization or order.
+const POLL_INTERVAL: Duration = Duration::from_secs(2);
+
+const BOOLEAN: u8 = 0x01;
+const INTEGER: u8 = 0x02;
+const BIT_STRING: u8 = 0x03;
+const OCTET_STRING: u8 = 0x04;
+const UTF8_STRING: u8 = 0x0c;
+const UTC_TIME: u8 = 0x17;
+const SEQUENCE: u8 = 0x30;
+const SET: u8 = 0x31;
+/// `[0]`: the version of a TBSCertificate, the attributes of a CSR
+const CONTEXT_0: u8 = 0xa0;
+/// `[3] EXPLICIT Extensions` of a TBSCertificate
+const CONTEXT_3: u8 = 0xa3;
+/// `dNSName [2] IA5String` in a GeneralName
+const DNS_NAME: u8 = 0x82;
+
+// Object identifiers, encoded with their tag and length.
+/// 1.2.840.10045.2.1
+const EC_PUBLIC_KEY: &[u8] = &[0x06, 0x07, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x02, 0x01];
+/// 1.2.840.10045.3.1.7
+const PRIME256V1: &[u8] = &[0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07];
+/// 1.2.840.10045.4.3.2
+const ECDSA_WITH_SHA256: &[u8] = &[0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x04, 0x03, 0x02];
+/// 1.2.840.113549.1.9.14
+const EXTENSION_REQUEST: &[u8] = &[0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x09, 0x0e];
+/// 2.5.4.3
+const COMMON_NAME: &[u8] = &[0x06, 0x03, 0x55, 0x04, 0x03];
+/// 2.5.29.17
+const SUBJECT_ALT_NAME: &[u8] = &[0x06, 0x03, 0x55, 0x1d, 0x11];
+/// 1.3.6.1.5.5.7.1.31 (RFC 8737)
+const ACME_IDENTIFIER: &[u8] = &[0x06, 0x08, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x07, 0x01, 0x1f];
+
+/// A certificate chain and its key, both PEM.
+pub struct Issued {
+    pub cert_pem: String,
+    pub key_pem: String,
+}
+
+/// Validation certificates for pending tls-alpn-01 challenges, by domain.
+#[derive(Clone, Default)]
+pub struct Challenges {
+    configs: Arc<Mutex<HashMap<String, Arc<ServerConfig>>>>,
+}
+
+impl Challenges {
+    /// TLS settings answering a validation handshake for `domain`, while its
+    /// challenge is pending.
+    pub fn get(&self, domain: &str) -> Option<Arc<ServerConfig>> {
+        self.configs.lock().unwrap().get(domain).cloned()
+    }
+
+    fn insert(&self, domain: &str, config: Arc<ServerConfig>) {
+        self.configs.lock().unwrap().insert(domain.to_string(), config);
+    }
+
+    fn remove(&self, domain: &str) {
+        self.configs.lock().unwrap().remove(domain);
+    }
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct Directory {
+    new_nonce: String,
+    new_account: String,
+    new_order: String,
+}
+
+#[derive(Deserialize)]
+struct Order {
+    status: String,
+    #[serde(default)]
+    authorizations: Vec<String>,
+    finalize: String,
+    certificate: Option<String>,
+    error: Option<Problem>,
+}
+
+#[derive(Deserialize)]
+struct Authorization {
+    status: String,
+    #[serde(default)]
+    challenges: Vec<Challenge>,
+}
+
+#[derive(Deserialize)]
+struct Challenge {
+    #[serde(rename = "type")]
+    kind: String,
+    url: String,
+    #[serde(default)]
+    token: String,
+    error: Option<Problem>,
+}
+
+/// An RFC 7807 problem document, as the CA reports errors.
+#[derive(Deserialize, Default)]
+struct Problem {
+    #[serde(rename = "type", default)]
+    kind: String,
+    #[serde(default)]
+    detail: String,
+}
+
+impl std::fmt::Display for Problem {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        let kind = self.kind.rsplit(':').next().unwrap_or_default();
+        write!(f, "{kind}: {}", self.detail)
+    }
+}
+
+/// The account as stored by `save_acme_account()`.
+#[derive(Deserialize)]
+struct StoredAccount {
+    key_pem: String,
+    url: String,
+}
+
+/// The directory and the account registered with it.
+struct Session {
+    directory: Directory,
+    key: EcdsaKeyPair,
+    /// Account URL, sent as the `kid` of every request after registration
+    url: String,
+    /// RFC 7638 thumbprint of the account key
+    thumbprint: String,
+}
+
+/// Orders certificates from one ACME directory.
+pub struct AcmeClient {
+    http: reqwest::Client,
+    pool: PgPool,
+    directory_url: String,
+    contact: Option<String>,
+    rng: SystemRandom,
+    session: OnceCell<Session>,
+    /// Replay-Nonce from the last response, used by the next request
+    nonce: Mutex<Option<String>>,
+}
+
+impl AcmeClient {
+    pub fn new(pool: PgPool, directory_url: String, contact: Option<String>) -> Self {
+        let http = reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .expect("failed to build ACME HTTP client");
+        Self {
+            http,
+            pool,
+            directory_url,
+            contact,
+            rng: SystemRandom::new(),
+            session: OnceCell::new(),
+            nonce: Mutex::new(None),
+        }
+    }
+
+    /// Order a certificate for `domain`, answering its challenge through
+    /// `challenges`. Takes as long as the CA does, up to a few minutes.
+    pub async fn issue(&self, domain: &str, challenges: &Challenges) -> Result<Issued, String> {
+        let session = self.session().await?;
+
+        let payload = json!({ "identifiers": [{ "type": "dns", "value": domain }] });
+        let response = self.post(session, &session.directory.new_order, Some(&payload)).await?;
+        let order_url = location(&response).ok_or("order has no Location")?;
+        let order: Order = response.json().await.map_err(|e| format!("invalid order: {e}"))?;
+
+        for authorization in &order.authorizations {
+            self.authorize(session, domain, authorization, challenges).await?;
+        }
+
+        let pkcs8 = EcdsaKeyPair::generate_pkcs8(&ECDSA_P256_SHA256_ASN1_SIGNING, &self.rng)
+            .map_err(|_| "failed to generate a certificate key")?;
+        let key = EcdsaKeyPair::from_pkcs8(&ECDSA_P256_SHA256_ASN1_SIGNING, pkcs8.as_ref(), &self.rng)
+            .map_err(|_| "failed to load the certificate key")?;
+        let csr = certificate_request(domain, &key, &self.rng)?;
+        self.post(session, &order.finalize, Some(&json!({ "csr": URL_SAFE_NO_PAD.encode(csr) })))
+            .await?;
+
+        let certificate = self.await_order(session, &order_url).await?;
+        let cert_pem = self
+            .post(session, &certificate, None)
+            .await?
+            .text()
+            .await
+            .map_err(|e| format!("failed to download certificate: {e}"))?;
+        Ok(Issued {
+            cert_pem,
+            key_pem: pem("PRIVATE KEY", pkcs8.as_ref()),
+        })
+    }
+
+    /// Complete one authorization of an order with tls-alpn-01.
+    async fn authorize(
+        &self,
+        session: &Session,
+        domain: &str,
+        url: &str,
+        challenges: &Challenges,
+    ) -> Result<(), String> {
+        let authorization: Authorization = self.post_json(session, url).await?;
+        if authorization.status == "valid" {
+            return Ok(());
+        }
+        let challenge = authorization
+            .challenges
+            .iter()
+            .find(|c| c.kind == "tls-alpn-01")
+            .ok_or("the CA offered no tls-alpn-01 challenge")?;
+
+        let key_authorization = format!("{}.{}", challenge.token, session.thumbprint);
+        challenges.insert(domain, Arc::new(challenge_config(domain, &key_authorization, &self.rng)?));
+        let result = async {
+            self.post(session, &challenge.url, Some(&json!({}))).await?;
+            let deadline = tokio::time::Instant::now() + POLL_TIMEOUT;
+            loop {
+                tokio::time::sleep(POLL_INTERVAL).await;
+                let authorization: Authorization = self.post_json(session, url).await?;
+                match authorization.status.as_str() {
+                    "valid" => return Ok(()),
+                    "pending" if tokio::time::Instant::now() < deadline => continue,
+                    "pending" => return Err("validation timed out".to_string()),
+                    status => {
+                        let problem = authorization
+                            .challenges
+                            .into_iter()
+                            .find(|c| c.kind == "tls-alpn-01")
+                            .and_then(|c| c.error)
+                            .map(|p| p.to_string())
+                            .unwrap_or_default();
+                        return Err(format!("authorization {status}: {problem}"));
+                    }
+                }
+            }
+        }
+        .await;
+        challenges.remove(domain);
+        result
+    }
+
+    /// Poll a finalized order until its certificate URL is ready.
+    async fn await_order(&self, session: &Session, url: &str) -> Result<String, String> {
+        let deadline = tokio::time::Instant::now() + POLL_TIMEOUT;
+        loop {
+            let order: Order = self.post_json(session, url).await?;
+            match order.status.as_str() {
+                "valid" => return order.certificate.ok_or_else(|| "valid order has no certificate".to_string()),
+                "invalid" => {
+                    let problem = order.error.map(|p| p.to_string()).unwrap_or_default();
+                    return Err(format!("order invalid: {problem}"));
+                }
+                _ if tokio::time::Instant::now() < deadline => tokio::time::sleep(POLL_INTERVAL).await,
+                status => return Err(format!("order still {status} after {}s", POLL_TIMEOUT.as_secs())),
+            }
+        }
+    }
+
+    /// The directory and account, registering an account on first use.
+    async fn session(&self) -> Result<&Session, String> {
+        self.session
+            .get_or_try_init(|| async {
+                let directory: Directory = self
+                    .http
+                    .get(&self.directory_url)
+                    .send()
+                    .await
+                    .and_then(|r| r.error_for_status())
+                    .map_err(|e| format!("{}: {e}", self.directory_url))?
+                    .json()
+                    .await
+                    .map_err(|e| format!("invalid directory: {e}"))?;
+
+                let stored: Option<Value> = sqlx::query_scalar("SELECT get_acme_account($1)")
+                    .bind(&self.directory_url)
+                    .fetch_one(&self.pool)
+                    .await
+                    .map_err(|e| format!("get_acme_account failed: {e}"))?;
+                if let Some(account) = stored.and_then(|v| serde_json::from_value::<StoredAccount>(v).ok()) {
+                    let key = PrivatePkcs8KeyDer::from_pem_slice(account.key_pem.as_bytes())
+                        .map_err(|e| format!("invalid stored account key: {e}"))?;
+                    let key = EcdsaKeyPair::from_pkcs8(
+                        &ECDSA_P256_SHA256_FIXED_SIGNING,
+                        key.secret_pkcs8_der(),
+                        &self.rng,
+                    )
+                    .map_err(|_| "invalid stored account key")?;
+                    return Ok(Session {
+                        thumbprint: thumbprint(&key),
+                        directory,
+                        key,
+                        url: account.url,
+                    });
+                }
+
+                let pkcs8 = EcdsaKeyPair::generate_pkcs8(&ECDSA_P256_SHA256_FIXED_SIGNING, &self.rng)
+                    .map_err(|_| "failed to generate an account key")?;
+                let key = EcdsaKeyPair::from_pkcs8(&ECDSA_P256_SHA256_FIXED_SIGNING, pkcs8.as_ref(), &self.rng)
+                    .map_err(|_| "failed to load the account key")?;
+                let mut payload = json!({ "termsOfServiceAgreed": true });
+                if let Some(ref contact) = self.contact {
+                    payload["contact"] = json!([format!("mailto:{contact}")]);
+                }
+                let response = self
+                    .signed_post(&directory, &key, None, &directory.new_account, Some(&payload))
+                    .await?;
+                let url = location(&response).ok_or("account has no Location")?;
+                sqlx::query("SELECT save_acme_account($1, $2, $3)")
+                    .bind(&self.directory_url)
+                    .bind(pem("PRIVATE KEY", pkcs8.as_ref()))
+                    .bind(&url)
+                    .execute(&self.pool)
+                    .await
+                    .map_err(|e| format!("save_acme_account failed: {e}"))?;
+                tracing::info!(directory = %self.directory_url, "registered ACME account");
+                Ok(Session {
+                    thumbprint: thumbprint(&key),
+                    directory,
+                    key,
+                    url,
+                })
+            })
+            .await
+    }
+
+    /// POST-as-GET a resource and parse it.
+    async fn post_json<T: for<'de> Deserialize<'de>>(&self, session: &Session, url: &str) -> Result<T, String> {
+        self.post(session, url, None)
+            .await?
+            .json()
+            .await
+            .map_err(|e| format!("{url}: {e}"))
+    }
+
+    /// A request signed with the account; `None` is a POST-as-GET.
+    async fn post(&self, session: &Session, url: &str, payload: Option<&Value>) -> Result<reqwest::Response, String> {
+        self.signed_post(&session.directory, &session.key, Some(&session.url), url, payload)
+            .await
+    }
+
+    /// A JWS-signed request, identified by account URL (`kid`) or, to
+    /// register, by the public key itself. A stale nonce is retried once.
+    async fn signed_post(
+        &self,
+        directory: &Directory,
+        key: &EcdsaKeyPair,
+        kid: Option<&str>,
+        url: &str,
+        payload: Option<&Value>,
+    ) -> Result<reqwest::Response, String> {
+        let mut retried = false;
+        loop {
+            let nonce = self.nonce(directory).await?;
+            let mut protected = json!({ "alg": "ES256", "nonce": nonce, "url": url });
+            match kid {
+                Some(kid) => protected["kid"] = json!(kid),
+                None => protected["jwk"] = serde_json::from_str(&jwk(key)).expect("JWK is JSON"),
+            }
+            let protected = URL_SAFE_NO_PAD.encode(protected.to_string());
+            let payload = payload.map(|p| URL_SAFE_NO_PAD.encode(p.to_string())).unwrap_or_default();
+            let signature = key
+                .sign(&self.rng, format!("{protected}.{payload}").as_bytes())
+                .map_err(|_| "failed to sign ACME request")?;
+            let body = json!({
+                "protected": protected,
+                "payload": payload,
+                "signature": URL_SAFE_NO_PAD.encode(signature),
+            });
+
+            let response = self
+                .http
+                .post(url)
+                .header(reqwest::header::CONTENT_TYPE, "application/jose+json")
+                .body(body.to_string())
+                .send()
+                .await
+                .map_err(|e| format!("{url}: {e}"))?;
+            self.keep_nonce(&response);
+            if response.status().is_success() {
+                return Ok(response);
+            }
+            let status = response.status();
+            let problem: Problem = response.json().await.unwrap_or_default();
+            if problem.kind.ends_with(":badNonce") && !retried {
+                retried = true;
+                continue;
+            }
+            return Err(format!("{url}: {status} {problem}"));
+        }
+    }
+
+    async fn nonce(&self, directory: &Directory) -> Result<String, String> {
+        if let Some(nonce) = self.nonce.lock().unwrap().take() {
+            return Ok(nonce);
+        }
+        let response = self
+            .http
+            .head(&directory.new_nonce)
+            .send()
+            .await
+            .map_err(|e| format!("{}: {e}", directory.new_nonce))?;
+        response
+            .headers()
+            .get("replay-nonce")
+            .and_then(|v| v.to_str().ok())
+            .map(str::to_string)
+            .ok_or_else(|| "the CA returned no nonce".to_string())
+    }
+
+    fn keep_nonce(&self, response: &reqwest::Response) {
+        if let Some(nonce) = response.headers().get("replay-nonce").and_then(|v| v.to_str().ok()) {
+            *self.nonce.lock().unwrap() = Some(nonce.to_string());
+        }
+    }
+}
+
+fn location(response: &reqwest::Response) -> Option<String> {
+    response
+        .headers()
+        .get(reqwest::header::LOCATION)
+        .and_then(|v| v.to_str().ok())
+        .map(str::to_string)
+}
+
+/// The account's public key as a JWK, members in the order RFC 7638 hashes.
+fn jwk(key: &EcdsaKeyPair) -> String {
+    // Uncompressed point: 0x04 || x || y
+    let point = key.public_key().as_ref();
+    format!(
+        r#"{{"crv":"P-256","kty":"EC","x":"{}","y":"{}"}}"#,
+        URL_SAFE_NO_PAD.encode(&point[1..33]),
+        URL_SAFE_NO_PAD.encode(&point[33..65]),
+    )
+}
+
+fn thumbprint(key: &EcdsaKeyPair) -> String {
+    URL_SAFE_NO_PAD.encode(Sha256::digest(jwk(key)))
+}
+
+/// TLS settings for a tls-alpn-01 validation handshake: a self-signed
+/// certificate for `domain` whose critical acmeIdentifier extension holds
+/// the SHA-256 of the key authorization, offered only over `acme-tls/1`.
+fn challenge_config(domain: &str, key_authorization: &str, rng: &SystemRandom) -> Result<ServerConfig, String> {
+    let pkcs8 = EcdsaKeyPair::generate_pkcs8(&ECDSA_P256_SHA256_ASN1_SIGNING, rng)
+        .map_err(|_| "failed to generate a challenge key")?;
+    let key = EcdsaKeyPair::from_pkcs8(&ECDSA_P256_SHA256_ASN1_SIGNING, pkcs8.as_ref(), rng)
+        .map_err(|_| "failed to load the challenge key")?;
+    let cert = challenge_certificate(domain, key_authorization, &key, rng, Utc::now())?;
+
+    let mut config = ServerConfig::builder_with_provider(crypto_provider())
+        .with_safe_default_protocol_versions()
+        .map_err(|e| e.to_string())?
+        .with_no_client_auth()
+        .with_single_cert(
+            vec![CertificateDer::from(cert)],
+            PrivateKeyDer::Pkcs8(PrivatePkcs8KeyDer::from(pkcs8.as_ref().to_vec())),
+        )
+        .map_err(|e| e.to_string())?;
+    config.alpn_protocols = vec![ACME_TLS_ALPN.to_vec()];
+    Ok(config)
+}
+
+fn challenge_certificate(
+    domain: &str,
+    key_authorization: &str,
+    key: &EcdsaKeyPair,
+    rng: &SystemRandom,
+    now: DateTime<Utc>,
+) -> Result<Vec<u8>, String> {
+    let mut serial = [0u8; 16];
+    rng.fill(&mut serial).map_err(|_| "failed to generate a serial")?;
+    // Positive, and without a leading zero byte
+    serial[0] = (serial[0] & 0x7f) | 0x01;
+
+    let name = sequence(&[der(
+        SET,
+        &sequence(&[COMMON_NAME.to_vec(), der(UTF8_STRING, b"ACME challenge")]),
+    )]);
+    let validity = sequence(&[
+        utc_time(now - chrono::Duration::days(1)),
+        utc_time(now + chrono::Duration::days(7)),
+    ]);
+    let digest = Sha256::digest(key_authorization.as_bytes());
+    let extensions = der(
+        CONTEXT_3,
+        &sequence(&[
+            sequence(&[SUBJECT_ALT_NAME.to_vec(), der(OCTET_STRING, &subject_alt_name(domain))]),
+            sequence(&[
+                ACME_IDENTIFIER.to_vec(),
+                der(BOOLEAN, &[0xff]),
+                der(OCTET_STRING, &der(OCTET_STRING, &digest)),
+            ]),
+        ]),
+    );
+    let tbs = sequence(&[
+        der(CONTEXT_0, &der(INTEGER, &[2])),
+        der(INTEGER, &serial),
+        sequence(&[ECDSA_WITH_SHA256.to_vec()]),
+        name.clone(),
+        validity,
+        name,
+        public_key_info(key),
+        extensions,
+    ]);
+    signed(tbs, key, rng)
+}
+
+/// A PKCS#10 request for `domain`, named only in subjectAltName.
+fn certificate_request(domain: &str, key: &EcdsaKeyPair, rng: &SystemRandom) -> Result<Vec<u8>, String> {
+    let extensions = sequence(&[sequence(&[
+        SUBJECT_ALT_NAME.to_vec(),
+        der(OCTET_STRING, &subject_alt_name(domain)),
+    ])]);
+    let attributes = der(
+        CONTEXT_0,
+        &sequence(&[EXTENSION_REQUEST.to_vec(), der(SET, &extensions)]),
+    );
+    let info = sequence(&[der(INTEGER, &[0]), sequence(&[]), public_key_info(key), attributes]);
+    signed(info, key, rng)
+}
+
+/// `tbs` followed by its ecdsa-with-SHA256 signature, as certificates and
+/// certificate requests are laid out.
+fn signed(tbs: Vec<u8>, key: &EcdsaKeyPair, rng: &SystemRandom) -> Result<Vec<u8>, String> {
+    let signature = key.sign(rng, &tbs).map_err(|_| "failed to sign")?;
+    Ok(sequence(&[tbs, sequence(&[ECDSA_WITH_SHA256.to_vec()]), bit_string(signature.as_ref())]))
+}
+
+fn subject_alt_name(domain: &str) -> Vec<u8> {
+    sequence(&[der(DNS_NAME, domain.as_bytes())])
+}
+
+fn public_key_info(key: &EcdsaKeyPair) -> Vec<u8> {
+    sequence(&[
+        sequence(&[EC_PUBLIC_KEY.to_vec(), PRIME256V1.to_vec()]),
+        bit_string(key.public_key().as_ref()),
+    ])
+}
+
+fn bit_string(bytes: &[u8]) -> Vec<u8> {
+    // No unused bits in the last byte
+    der(BIT_STRING, &[&[0u8][..], bytes].concat())
+}
+
+fn utc_time(at: DateTime<Utc>) -> Vec<u8> {
+    der(UTC_TIME, at.format("%y%m%d%H%M%SZ").to_string().as_bytes())
+}
+
+fn sequence(items: &[Vec<u8>]) -> Vec<u8> {
+    der(SEQUENCE, &items.concat())
+}
+
+/// One DER element: tag, definite length, contents.
+fn der(tag: u8, contents: &[u8]) -> Vec<u8> {
+    let mut out = vec![tag];
+    let len = contents.len();
+    if len < 0x80 {
+        out.push(len as u8);
+    } else {
+        let bytes = len.to_be_bytes();
+        let skip = bytes.iter().take_while(|&&b| b == 0).count();
+        out.push(0x80 | (bytes.len() - skip) as u8);
+        out.extend_from_slice(&bytes[skip..]);
+    }
+    out.extend_from_slice(contents);
+    out
+}
+
+fn pem(label: &str, der: &[u8]) -> String {
+    let encoded = BASE64.encode(der);
+    let mut out = format!("-----BEGIN {label}-----\n");
+    for line in encoded.as_bytes().chunks(64) {
+        out.push_str(std::str::from_utf8(line).expect("base64 is ASCII"));
+        out.push('\n');
+    }
+    out.push_str(&format!("-----END {label}-----\n"));
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::client_cert::ClientCert;
+    use ring::signature::{ECDSA_P256_SHA256_ASN1, UnparsedPublicKey};
+
+    fn key(alg: &'static ring::signature::EcdsaSigningAlgorithm) -> EcdsaKeyPair {
+        let rng = SystemRandom::new();
+        let pkcs8 = EcdsaKeyPair::generate_pkcs8(alg, &rng).unwrap();
+        EcdsaKeyPair::from_pkcs8(alg, pkcs8.as_ref(), &rng).unwrap()
+    }
+
+    /// Split a signed structure into the signed bytes and the signature.
+    fn split_signed(der: &[u8]) -> (&[u8], &[u8]) {
+        let (_, body) = der.split_at(if der[1] < 0x80 { 2 } else { 2 + (der[1] & 0x7f) as usize });
+        let tbs_len = if body[1] < 0x80 {
+            2 + body[1] as usize
+        } else {
+            let n = (body[1] & 0x7f) as usize;
+            2 + n + body[2..2 + n].iter().fold(0usize, |acc, &b| (acc << 8) | b as usize)
+        };
+        let (tbs, rest) = body.split_at(tbs_len);
+        let (algorithm, signature) = rest.split_at(2 + rest[1] as usize);
+        assert_eq!(&algorithm[2..], ECDSA_WITH_SHA256);
+        // BIT STRING: tag, length, unused-bits byte
+        (tbs, &signature[3..])
+    }
+
+    #[test]
+    fn der_lengths_switch_to_long_form() {
+        assert_eq!(der(OCTET_STRING, &[7; 3]), [0x04, 0x03, 7, 7, 7]);
+        let long = der(OCTET_STRING, &[0; 200]);
+        assert_eq!(&long[..3], &[0x04, 0x81, 200]);
+        let longer = der(OCTET_STRING, &[0; 300]);
+        assert_eq!(&longer[..4], &[0x04, 0x82, 0x01, 0x2c]);
+    }
+
+    #[test]
+    fn jwk_members_are_in_thumbprint_order() {
+        let key = key(&ECDSA_P256_SHA256_FIXED_SIGNING);
+        let jwk = jwk(&key);
+        assert!(jwk.starts_with(r#"{"crv":"P-256","kty":"EC","x":""#), "{jwk}");
+        let parsed: Value = serde_json::from_str(&jwk).unwrap();
+        assert_eq!(URL_SAFE_NO_PAD.decode(parsed["x"].as_str().unwrap()).unwrap().len(), 32);
+        assert_eq!(thumbprint(&key).len(), 43);
+    }
+
+    #[test]
+    fn certificate_requests_are_signed_by_their_key() {
+        let key = key(&ECDSA_P256_SHA256_ASN1_SIGNING);
+        let csr = certificate_request("hooks.example.com", &key, &SystemRandom::new()).unwrap();
+        let (info, signature) = split_signed(&csr);
+        UnparsedPublicKey::new(&ECDSA_P256_SHA256_ASN1, key.public_key().as_ref())
+            .verify(info, signature)
+            .unwrap();
+        assert!(info.windows(17).any(|w| w == b"hooks.example.com"));
+    }
+
+    #[test]
+    fn challenge_certificates_carry_the_key_authorization() {
+        let key = key(&ECDSA_P256_SHA256_ASN1_SIGNING);
+        let now = DateTime::parse_from_rfc3339("2026-10-16T12:00:00Z").unwrap().to_utc();
+        let cert = challenge_certificate("hooks.example.com", "token.thumb", &key, &SystemRandom::new(), now)
+            .unwrap();
+
+        let (tbs, signature) = split_signed(&cert);
+        UnparsedPublicKey::new(&ECDSA_P256_SHA256_ASN1, key.public_key().as_ref())
+            .verify(tbs, signature)
+            .unwrap();
+        let described = ClientCert::from_chain(&[CertificateDer::from(cert.clone())]).unwrap();
+        assert_eq!(described.subject, "CN=ACME challenge");
+        assert_eq!(described.not_after.to_rfc3339(), "2026-10-23T12:00:00+00:00");
+
+        let digest = Sha256::digest(b"token.thumb");
+        let extension = [
+            ACME_IDENTIFIER,
+            &[BOOLEAN, 1, 0xff, OCTET_STRING, 34, OCTET_STRING, 32],
+            digest.as_slice(),
+        ]
+        .concat();
+        assert!(cert.windows(extension.len()).any(|w| w == extension));
+    }
+
+    #[test]
+    fn pem_round_trips() {
+        let encoded = pem("PRIVATE KEY", &[1; 100]);
+        assert!(encoded.lines().all(|line| line.len() <= 64));
+        let decoded = PrivatePkcs8KeyDer::from_pem_slice(encoded.as_bytes()).unwrap();
+        assert_eq!(decoded.secret_pkcs8_der(), &[1; 100]);
+    }
+}
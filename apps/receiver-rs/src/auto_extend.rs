@@ -0,0 +1,162 @@
+//! Sliding expiry for ephemeral endpoints with auto-extension.
+//!
+//! `capture_webhook` marks its result `auto_extend` when the endpoint opted
+//! in. The tracker keeps the latest capture time per slug, and a background
+//! task reports them every [`FLUSH_INTERVAL`] in one call to
+//! `extend_active_endpoints`, which moves each endpoint's expiry to the last
+//! capture plus its idle window, never past its hard limit. An endpoint that
+//! keeps receiving traffic stays up; one that goes quiet expires an idle
+//! window after its last request.
+//!
+//! Reports that can't be written stay in memory for the next flush. The
+//! flush interval is well under the shortest idle window, so an endpoint
+//! with steady traffic is extended before it runs out.
+
+use chrono::{DateTime, Utc};
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+/// How often pending activity is reported.
+const FLUSH_INTERVAL: Duration = Duration::from_secs(15);
+
+/// Slugs tracked at once. Activity on further slugs isn't reported.
+const MAX_TRACKED_SLUGS: usize = 10_000;
+
+/// Shared tracker stored in AppState. Cheap to clone.
+#[derive(Clone, Default)]
+pub struct ActivityTracker {
+    pending: Arc<Mutex<HashMap<String, DateTime<Utc>>>>,
+}
+
+impl ActivityTracker {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Note a capture for `slug` at `at`.
+    pub fn record(&self, slug: &str, at: DateTime<Utc>) {
+        let mut pending = self.pending.lock().unwrap_or_else(|e| e.into_inner());
+        if let Some(last) = pending.get_mut(slug) {
+            *last = (*last).max(at);
+            return;
+        }
+        if pending.len() >= MAX_TRACKED_SLUGS {
+            tracing::warn!(slug, "activity tracker full, not extending");
+            return;
+        }
+        pending.insert(slug.to_string(), at);
+    }
+
+    /// Drop pending activity for an endpoint that expired; it can't be
+    /// extended any more.
+    pub fn forget(&self, slug: &str) {
+        self.pending
+            .lock()
+            .unwrap_or_else(|e| e.into_inner())
+            .remove(slug);
+    }
+
+    fn take(&self) -> HashMap<String, DateTime<Utc>> {
+        std::mem::take(&mut *self.pending.lock().unwrap_or_else(|e| e.into_inner()))
+    }
+
+    /// Put unreported activity back, keeping the later time per slug.
+    fn restore(&self, batch: HashMap<String, DateTime<Utc>>) {
+        let mut pending = self.pending.lock().unwrap_or_else(|e| e.into_inner());
+        for (slug, at) in batch {
+            pending
+                .entry(slug)
+                .and_modify(|last| *last = (*last).max(at))
+                .or_insert(at);
+        }
+    }
+
+    /// Report everything pending in one call.
+    pub async fn flush(&self, pool: &PgPool) {
+        let batch = self.take();
+        if batch.is_empty() {
+            return;
+        }
+
+        let (slugs, times): (Vec<_>, Vec<_>) = batch.iter().map(|(slug, at)| (slug.clone(), *at)).unzip();
+        let result: Result<i32, _> = sqlx::query_scalar("SELECT extend_active_endpoints($1, $2)")
+            .bind(slugs)
+            .bind(times)
+            .fetch_one(pool)
+            .await;
+
+        match result {
+            Ok(extended) => tracing::debug!(reported = batch.len(), extended, "extended active endpoints"),
+            Err(e) => {
+                tracing::error!(count = batch.len(), error = %e, "failed to extend active endpoints, will retry");
+                self.restore(batch);
+            }
+        }
+    }
+}
+
+/// Report activity every [`FLUSH_INTERVAL`] for the life of the process.
+pub fn spawn_flusher(tracker: ActivityTracker, pool: PgPool) {
+    tokio::spawn(async move {
+        let mut interval = tokio::time::interval(FLUSH_INTERVAL);
+        interval.set_missed_tick_behavior(tokio::time::MissedTickBehavior::Delay);
+        loop {
+            interval.tick().await;
+            tracker.flush(&pool).await;
+        }
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use chrono::TimeZone;
+
+    fn at(secs: i64) -> DateTime<Utc> {
+        Utc.timestamp_opt(1_700_000_000 + secs, 0).unwrap()
+    }
+
+    #[test]
+    fn keeps_the_latest_capture_per_slug() {
+        let tracker = ActivityTracker::new();
+        tracker.record("abc", at(5));
+        tracker.record("abc", at(9));
+        tracker.record("abc", at(1));
+        tracker.record("xyz", at(3));
+
+        let pending = tracker.take();
+        assert_eq!(pending["abc"], at(9));
+        assert_eq!(pending["xyz"], at(3));
+        assert!(tracker.take().is_empty());
+    }
+
+    #[test]
+    fn restore_keeps_the_later_time() {
+        let tracker = ActivityTracker::new();
+        tracker.record("abc", at(10));
+        tracker.record("xyz", at(30));
+        let unreported = tracker.take();
+
+        tracker.record("abc", at(20));
+        tracker.record("xyz", at(25));
+        tracker.restore(unreported);
+
+        let pending = tracker.take();
+        assert_eq!(pending["abc"], at(20));
+        assert_eq!(pending["xyz"], at(30));
+    }
+
+    #[test]
+    fn forget_drops_pending_activity() {
+        let tracker = ActivityTracker::new();
+        tracker.record("abc", at(10));
+        tracker.record("xyz", at(20));
+        tracker.forget("abc");
+
+        let pending = tracker.take();
+        assert!(!pending.contains_key("abc"));
+        assert_eq!(pending["xyz"], at(20));
+    }
+}
@@ -0,0 +1,91 @@
+//! Per-endpoint body size limits.
+//!
+//! Each plan has a body size limit and endpoints can lower it with
+//! `endpoints.max_body_size`; `get_endpoint_max_body_size()` returns the
+//! effective one, or null when neither sets a limit. The receiver never goes
+//! above its own limit (the ceiling: the inline limit, or the large-body limit
+//! with object storage), which the body limit layer enforces with a 413.
+//!
+//! Bodies over an endpoint's limit but under the ceiling are read to the end,
+//! keeping only the first `limit` bytes, and captured cut short with
+//! `"truncated": {"body": "max_size"}` in `requests.sizes` rather than
+//! refused. Limits are cached per slug like the other endpoint caches and
+//! dropped on `endpoint_config` notifications; lookup failures fall back to
+//! the ceiling and are not cached.
+
+use sqlx::PgPool;
+use std::time::Duration;
+
+use crate::slug_cache::{EndpointCache, SlugCache};
+
+/// How long a slug's limit is trusted. Plan changes aren't announced, so
+/// this is how long an upgrade takes to apply.
+const CACHE_TTL: Duration = Duration::from_secs(60);
+
+/// Cached effective limits, stored in AppState. Cheap to clone.
+#[derive(Clone)]
+pub struct BodyLimitCache {
+    ceiling: usize,
+    limits: SlugCache<Option<usize>>,
+}
+
+impl BodyLimitCache {
+    /// `ceiling` is the receiver's own limit, which no endpoint exceeds.
+    pub fn new(ceiling: usize) -> Self {
+        Self {
+            ceiling,
+            limits: SlugCache::with_ttl(CACHE_TTL),
+        }
+    }
+
+    /// The most body bytes kept for `slug`, reading through to Postgres on a
+    /// miss.
+    pub async fn limit(&self, pool: &PgPool, slug: &str) -> usize {
+        let limit = self
+            .limits
+            .get_or_load(slug, |_| async move {
+                let result: Result<Option<i32>, sqlx::Error> =
+                    sqlx::query_scalar("SELECT get_endpoint_max_body_size($1)")
+                        .bind(slug)
+                        .fetch_one(pool)
+                        .await;
+                match result {
+                    Ok(limit) => Some(limit.and_then(|n| usize::try_from(n).ok())),
+                    Err(e) => {
+                        tracing::error!(slug, error = %e, "get_endpoint_max_body_size query failed");
+                        None
+                    }
+                }
+            })
+            .await
+            .flatten();
+        effective(limit, self.ceiling)
+    }
+}
+
+impl EndpointCache for BodyLimitCache {
+    fn invalidate(&self, slug: &str) {
+        self.limits.invalidate(slug);
+    }
+
+    fn clear(&self) {
+        self.limits.clear();
+    }
+}
+
+/// An endpoint's limit, capped at the receiver's.
+fn effective(limit: Option<usize>, ceiling: usize) -> usize {
+    limit.map_or(ceiling, |limit| limit.min(ceiling))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn endpoint_limits_never_exceed_the_ceiling() {
+        assert_eq!(effective(None, 1_048_576), 1_048_576);
+        assert_eq!(effective(Some(4_096), 1_048_576), 4_096);
+        assert_eq!(effective(Some(10_485_760), 1_048_576), 1_048_576);
+    }
+}
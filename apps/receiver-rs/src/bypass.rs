@@ -0,0 +1,101 @@
+//! Quota bypass tokens for load testing.
+//!
+//! Admins issue short-lived tokens with `scripts/issue-quota-bypass.ts`; a
+//! sender includes one as `x-webhooks-cc-bypass` and `capture_webhook` then
+//! skips the quota check for that request and writes an audit record.
+//!
+//! Token format: `v1.<expires unix seconds>.<hex HMAC-SHA256>` where the MAC
+//! covers `v1.<lowercase slug>.<expires>` under `QUOTA_BYPASS_SECRET`. Binding
+//! the slug means a token can't be replayed against other endpoints, and
+//! tokens expiring more than [`MAX_TTL_SECS`] ahead are refused so a leaked
+//! secret can't mint long-lived ones unnoticed.
+
+use chrono::{DateTime, Utc};
+use hmac::{Hmac, Mac};
+use sha2::Sha256;
+
+/// Header carrying the bypass token. Stripped from captured headers.
+pub const BYPASS_HEADER: &str = "x-webhooks-cc-bypass";
+
+/// Longest accepted token lifetime.
+const MAX_TTL_SECS: i64 = 24 * 60 * 60;
+
+/// Verify `token` for `slug`. Returns the token's expiry when it is valid.
+pub fn verify(
+    secret: &str,
+    token: &str,
+    slug: &str,
+    now: DateTime<Utc>,
+) -> Result<DateTime<Utc>, &'static str> {
+    let mut parts = token.trim().splitn(3, '.');
+    let (Some("v1"), Some(expires), Some(signature)) = (parts.next(), parts.next(), parts.next())
+    else {
+        return Err("malformed token");
+    };
+    let expires_secs: i64 = expires.parse().map_err(|_| "malformed token")?;
+    let signature = hex::decode(signature).map_err(|_| "malformed token")?;
+
+    let mut mac =
+        Hmac::<Sha256>::new_from_slice(secret.as_bytes()).expect("HMAC accepts any key length");
+    mac.update(format!("v1.{}.{}", slug.to_lowercase(), expires_secs).as_bytes());
+    mac.verify_slice(&signature).map_err(|_| "bad signature")?;
+
+    let now_secs = now.timestamp();
+    if expires_secs <= now_secs {
+        return Err("expired");
+    }
+    if expires_secs - now_secs > MAX_TTL_SECS {
+        return Err("lifetime too long");
+    }
+    DateTime::from_timestamp(expires_secs, 0).ok_or("malformed token")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const SECRET: &str = "test-secret";
+
+    fn sign(slug: &str, expires: i64) -> String {
+        let mut mac = Hmac::<Sha256>::new_from_slice(SECRET.as_bytes()).unwrap();
+        mac.update(format!("v1.{slug}.{expires}").as_bytes());
+        format!("v1.{expires}.{}", hex::encode(mac.finalize().into_bytes()))
+    }
+
+    fn now() -> DateTime<Utc> {
+        DateTime::from_timestamp(1_700_000_000, 0).unwrap()
+    }
+
+    #[test]
+    fn accepts_valid_token() {
+        let expires = now().timestamp() + 3600;
+        let token = sign("load-test", expires);
+        assert_eq!(
+            verify(SECRET, &token, "Load-Test", now()).unwrap().timestamp(),
+            expires
+        );
+    }
+
+    #[test]
+    fn rejects_other_slug_and_secret() {
+        let token = sign("load-test", now().timestamp() + 3600);
+        assert_eq!(verify(SECRET, &token, "other", now()), Err("bad signature"));
+        assert_eq!(verify("wrong", &token, "load-test", now()), Err("bad signature"));
+    }
+
+    #[test]
+    fn rejects_expired_and_long_lived_tokens() {
+        let expired = sign("load-test", now().timestamp() - 1);
+        assert_eq!(verify(SECRET, &expired, "load-test", now()), Err("expired"));
+
+        let long = sign("load-test", now().timestamp() + MAX_TTL_SECS + 60);
+        assert_eq!(verify(SECRET, &long, "load-test", now()), Err("lifetime too long"));
+    }
+
+    #[test]
+    fn rejects_malformed_tokens() {
+        for token in ["", "v1", "v2.123.abcd", "v1.notanumber.abcd", "v1.123.zz"] {
+            assert_eq!(verify(SECRET, token, "load-test", now()), Err("malformed token"), "{token}");
+        }
+    }
+}
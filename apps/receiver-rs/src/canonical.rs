@@ -0,0 +1,81 @@
+//! Canonical JSON, for comparing payloads by meaning rather than bytes.
+//!
+//! Senders that re-serialize a payload on every retry can change key order,
+//! whitespace or number formatting (`1.0` vs `1`, `1e2` vs `100`) without
+//! changing what it says. The canonical form sorts object keys, drops
+//! insignificant whitespace and writes integral numbers without a fraction;
+//! array order is significant and kept. capture_webhook uses its hash for
+//! duplicate detection on endpoints with `canonical_json` set.
+
+use serde_json::{Map, Number, Value};
+
+/// Integral floats at or above this magnitude may not be exact integers.
+const MAX_SAFE_INTEGER: f64 = 9_007_199_254_740_992.0;
+
+/// Hex SHA-256 of the canonical form of a JSON object or array body, or
+/// `None` when the body isn't one or is already canonical (its plain body
+/// hash then says the same).
+pub fn canonical_hash(body: &str) -> Option<String> {
+    use sha2::{Digest, Sha256};
+
+    let trimmed = body.trim_start();
+    if !trimmed.starts_with('{') && !trimmed.starts_with('[') {
+        return None;
+    }
+    let value: Value = serde_json::from_str(body).ok()?;
+    let canonical = serde_json::to_string(&canonicalize(value)).ok()?;
+    (canonical != body).then(|| hex::encode(Sha256::digest(canonical.as_bytes())))
+}
+
+/// Rebuild `value` in canonical form.
+pub fn canonicalize(value: Value) -> Value {
+    match value {
+        Value::Object(map) => {
+            let mut entries: Vec<(String, Value)> = map.into_iter().collect();
+            entries.sort_by(|a, b| a.0.cmp(&b.0));
+            Value::Object(
+                entries
+                    .into_iter()
+                    .map(|(k, v)| (k, canonicalize(v)))
+                    .collect::<Map<String, Value>>(),
+            )
+        }
+        Value::Array(items) => Value::Array(items.into_iter().map(canonicalize).collect()),
+        Value::Number(n) => Value::Number(canonical_number(n)),
+        other => other,
+    }
+}
+
+fn canonical_number(n: Number) -> Number {
+    match n.as_f64() {
+        Some(f) if !n.is_i64() && !n.is_u64() && f.fract() == 0.0 && f.abs() < MAX_SAFE_INTEGER => {
+            Number::from(f as i64)
+        }
+        _ => n,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn key_order_whitespace_and_numbers_compare_equal() {
+        let a = canonical_hash(r#"{"b": 1.0, "a": {"y": [1e2, "x"], "x": null}}"#);
+        let b = canonical_hash(r#"{"a":{"x":null,"y":[100.00,"x"]},"b":1 }"#);
+        assert!(a.is_some());
+        assert_eq!(a, b);
+
+        // Array order and fractional values still matter
+        assert_ne!(canonical_hash(r#"{"a":[1,2] }"#), canonical_hash(r#"{"a":[2,1] }"#));
+        assert_ne!(canonical_hash(r#"{"a":1.5 }"#), canonical_hash(r#"{"a":1 }"#));
+    }
+
+    #[test]
+    fn non_json_and_canonical_bodies_have_no_canonical_hash() {
+        assert_eq!(canonical_hash(r#"{"a":1,"b":[true]}"#), None);
+        assert_eq!(canonical_hash("a=1&b=2"), None);
+        assert_eq!(canonical_hash("{not json"), None);
+        assert_eq!(canonical_hash("42"), None);
+    }
+}
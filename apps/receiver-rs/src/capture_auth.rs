@@ -0,0 +1,265 @@
+//! Credentials senders must present before an endpoint captures anything.
+//!
+//! `endpoints.capture_auth` is either HTTP Basic credentials or an API key
+//! header (`x-api-key` unless configured otherwise). The password and key
+//! are stored as salted PBKDF2-HMAC-SHA256 hashes (bare SHA-256 hex for
+//! configs saved before migration 00082); the receiver checks what the
+//! request carries against them in constant time, off the async workers, and
+//! remembers the last secret that matched so a sender pays for the hash once.
+//! Requests without matching credentials are answered 401 `unauthorized`
+//! before the body is read, and counted under the `unauthorized` rule of the
+//! endpoint's network stats.
+//!
+//! The config is cached per slug like the other endpoint caches and dropped
+//! on `endpoint_config` notifications. Endpoints whose config can't be read
+//! capture nothing until it can.
+
+use axum::http::HeaderMap;
+use base64::Engine;
+use base64::engine::general_purpose::STANDARD as BASE64;
+use ring::pbkdf2;
+use serde::Deserialize;
+use sha2::{Digest, Sha256};
+use sqlx::PgPool;
+use std::num::NonZeroU32;
+use std::sync::{Arc, Mutex};
+use subtle::ConstantTimeEq;
+
+use crate::slug_cache::{SlugCache, load_json};
+
+fn default_key_header() -> String {
+    "x-api-key".to_string()
+}
+
+/// An endpoint's `capture_auth` config.
+#[derive(Debug, Deserialize)]
+#[serde(tag = "type", rename_all = "snake_case")]
+pub enum CaptureAuth {
+    Basic {
+        username: String,
+        #[serde(rename = "passwordHash")]
+        password_hash: SecretHash,
+    },
+    ApiKey {
+        #[serde(default = "default_key_header")]
+        header: String,
+        #[serde(rename = "keyHash")]
+        key_hash: SecretHash,
+    },
+}
+
+/// A stored password or key hash: `pbkdf2-sha256$<iterations>$<salt>$<hash>`
+/// (base64), or the SHA-256 hex of older configs.
+#[derive(Debug, Deserialize)]
+#[serde(from = "String")]
+pub struct SecretHash {
+    stored: String,
+    /// SHA-256 of the last secret that matched, checked before the slow hash.
+    accepted: Mutex<Option<[u8; 32]>>,
+}
+
+impl From<String> for SecretHash {
+    fn from(stored: String) -> Self {
+        Self {
+            stored,
+            accepted: Mutex::new(None),
+        }
+    }
+}
+
+impl SecretHash {
+    /// Whether `secret` is the one this was hashed from.
+    fn matches(&self, secret: &str) -> bool {
+        let digest: [u8; 32] = Sha256::digest(secret.as_bytes()).into();
+        let accepted = *self.accepted.lock().unwrap_or_else(|e| e.into_inner());
+        if accepted.is_some_and(|known| bool::from(known.ct_eq(&digest))) {
+            return true;
+        }
+        let matched = verify_hash(&self.stored, secret, &digest);
+        if matched {
+            *self.accepted.lock().unwrap_or_else(|e| e.into_inner()) = Some(digest);
+        }
+        matched
+    }
+}
+
+/// Check `secret` (whose SHA-256 is `digest`) against a stored hash.
+/// Malformed hashes match nothing.
+fn verify_hash(stored: &str, secret: &str, digest: &[u8; 32]) -> bool {
+    let Some(salted) = stored.strip_prefix("pbkdf2-sha256$") else {
+        return hex::decode(stored).is_ok_and(|hash| bool::from(hash.ct_eq(digest)));
+    };
+    let mut parts = salted.split('$');
+    let (Some(iterations), Some(salt), Some(hash), None) =
+        (parts.next(), parts.next(), parts.next(), parts.next())
+    else {
+        return false;
+    };
+    let (Ok(iterations), Ok(salt), Ok(hash)) = (
+        iterations.parse::<NonZeroU32>(),
+        BASE64.decode(salt),
+        BASE64.decode(hash),
+    ) else {
+        return false;
+    };
+    pbkdf2::verify(
+        pbkdf2::PBKDF2_HMAC_SHA256,
+        iterations,
+        &salt,
+        secret.as_bytes(),
+        &hash,
+    )
+    .is_ok()
+}
+
+impl CaptureAuth {
+    /// Whether the request carries the configured credentials. Checking a new
+    /// secret runs the slow hash, so call this from a blocking thread.
+    pub fn allows(&self, headers: &HeaderMap) -> bool {
+        match self {
+            Self::Basic {
+                username,
+                password_hash,
+            } => basic_credentials(headers).is_some_and(|(user, password)| {
+                let user_matches = bool::from(user.as_bytes().ct_eq(username.as_bytes()));
+                // Hash even for the wrong user, so timing doesn't tell them apart
+                password_hash.matches(&password) && user_matches
+            }),
+            Self::ApiKey { header, key_hash } => headers
+                .get(header.as_str())
+                .and_then(|v| v.to_str().ok())
+                .is_some_and(|key| key_hash.matches(key.trim())),
+        }
+    }
+
+    /// Whether refusals should carry a Basic challenge.
+    pub fn is_basic(&self) -> bool {
+        matches!(self, Self::Basic { .. })
+    }
+}
+
+/// `(user, password)` from an `Authorization: Basic` header.
+fn basic_credentials(headers: &HeaderMap) -> Option<(String, String)> {
+    let value = headers.get("authorization")?.to_str().ok()?.trim();
+    let (scheme, encoded) = value.split_once(' ')?;
+    if !scheme.eq_ignore_ascii_case("basic") {
+        return None;
+    }
+    let decoded = String::from_utf8(BASE64.decode(encoded.trim()).ok()?).ok()?;
+    let (user, password) = decoded.split_once(':')?;
+    Some((user.to_string(), password.to_string()))
+}
+
+/// Per-slug capture credentials, shared across requests via AppState.
+pub type CaptureAuthCache = SlugCache<Option<Arc<CaptureAuth>>>;
+
+impl CaptureAuthCache {
+    /// Look up an endpoint's config, reading through to Postgres on a miss.
+    /// `Some(None)` when the endpoint is open; `None` when the lookup failed
+    /// (not cached), which callers treat as a refusal.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Option<Arc<CaptureAuth>>> {
+        self.get_or_load(slug, |_| async move {
+            let config: Option<CaptureAuth> =
+                load_json(pool, slug, "get_endpoint_capture_auth", "capture_auth").await?;
+            Some(config.map(Arc::new))
+        })
+        .await
+    }
+}
+
+/// Count a refused request in the endpoint's network stats, in the background.
+pub fn count_refusal(pool: &PgPool, slug: &str) {
+    let pool = pool.clone();
+    let slug = slug.to_string();
+    tokio::spawn(async move {
+        let result = sqlx::query("SELECT count_capture_auth_failure($1)")
+            .bind(&slug)
+            .execute(&pool)
+            .await;
+        if let Err(e) = result {
+            tracing::warn!(slug, error = %e, "failed to count capture auth failure");
+        }
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::http::HeaderValue;
+
+    // Hashed by apps/web/lib/capture-auth.ts (with 1000 rounds and a fixed salt)
+    const PASSWORD_HASH: &str =
+        "pbkdf2-sha256$1000$d2ViaG9va3MtY2Mtc2FsdA==$XZY1WHvGcA80P5SsEQMJq/gMhuLeUm5/77XJF1BgYNg=";
+    const KEY_HASH: &str =
+        "pbkdf2-sha256$1000$d2ViaG9va3MtY2Mtc2FsdA==$/5k49zEvPwpYg48cbDn6SrmGX0FQ0NeLeESNT87Mt7Q=";
+
+    fn headers(name: &'static str, value: &str) -> HeaderMap {
+        let mut headers = HeaderMap::new();
+        headers.insert(name, HeaderValue::from_str(value).unwrap());
+        headers
+    }
+
+    #[test]
+    fn basic_matches_user_and_password() {
+        let auth: CaptureAuth = serde_json::from_value(serde_json::json!({
+            "type": "basic",
+            "username": "stripe",
+            "passwordHash": PASSWORD_HASH,
+        }))
+        .unwrap();
+        let good = format!("Basic {}", BASE64.encode("stripe:p:ss"));
+        assert!(auth.allows(&headers("authorization", &good)));
+        // Remembered after the first match
+        assert!(auth.allows(&headers("authorization", &good)));
+        assert!(auth.is_basic());
+
+        let wrong_password = format!("basic {}", BASE64.encode("stripe:nope"));
+        assert!(!auth.allows(&headers("authorization", &wrong_password)));
+        let wrong_user = format!("Basic {}", BASE64.encode("github:p:ss"));
+        assert!(!auth.allows(&headers("authorization", &wrong_user)));
+        assert!(!auth.allows(&headers("authorization", "Bearer abc")));
+        assert!(!auth.allows(&HeaderMap::new()));
+    }
+
+    #[test]
+    fn api_key_reads_the_configured_header() {
+        let auth: CaptureAuth = serde_json::from_value(serde_json::json!({
+            "type": "api_key",
+            "keyHash": KEY_HASH,
+        }))
+        .unwrap();
+        assert!(auth.allows(&headers("x-api-key", "k_123")));
+        assert!(!auth.allows(&headers("x-api-key", "k_124")));
+        assert!(!auth.allows(&headers("x-token", "k_123")));
+
+        let custom: CaptureAuth = serde_json::from_value(serde_json::json!({
+            "type": "api_key",
+            "header": "x-token",
+            "keyHash": KEY_HASH,
+        }))
+        .unwrap();
+        assert!(custom.allows(&headers("x-token", "k_123")));
+        assert!(!custom.is_basic());
+    }
+
+    #[test]
+    fn older_sha256_hashes_still_match_and_malformed_ones_never_do() {
+        let legacy = SecretHash::from(hex::encode(Sha256::digest(b"k_123")));
+        assert!(legacy.matches("k_123"));
+        assert!(!legacy.matches("k_124"));
+
+        let trailing = format!("{PASSWORD_HASH}$extra");
+        for stored in [
+            "",
+            "pbkdf2-sha256$0$d2Vi$XZY1",
+            "pbkdf2-sha256$1000$d2Vi",
+            "pbkdf2-sha256$1000$!!$XZY1",
+            trailing.as_str(),
+        ] {
+            assert!(
+                !SecretHash::from(stored.to_string()).matches("p:ss"),
+                "{stored}"
+            );
+        }
+    }
+}
@@ -0,0 +1,251 @@
+//! Owner-visible reporting of webhooks that were received but not stored.
+//!
+//! When `capture_webhook` fails the handler still answers 200 (fail open) so
+//! senders don't retry into an outage, which means the request is lost. The
+//! tracker counts those losses per slug in memory, and a background task
+//! reports them every [`FLUSH_INTERVAL`] through `record_capture_failures`,
+//! which flags the endpoint on the dashboard. When a report opens a new
+//! failure window and the endpoint has a notification URL, the owner also gets
+//! a `capture_failed` notification.
+//!
+//! Reports that can't be written (the database is usually what failed) stay
+//! in memory and are merged into the next flush. Each loss is also announced
+//! as a `capture_failing` lifecycle event (throttled), for the operator.
+
+use chrono::{DateTime, Utc};
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use crate::handlers::webhook::{deliver_notification, NotificationTarget};
+use crate::lifecycle::{Event, Lifecycle};
+
+/// How often pending failures are reported.
+const FLUSH_INTERVAL: Duration = Duration::from_secs(30);
+
+/// Slugs tracked at once. Failures for further slugs are only logged.
+const MAX_TRACKED_SLUGS: usize = 10_000;
+
+/// Losses for one slug since the last successful report.
+#[derive(Debug, Clone, Copy, PartialEq)]
+struct Window {
+    count: u32,
+    first_at: DateTime<Utc>,
+    last_at: DateTime<Utc>,
+}
+
+impl Window {
+    fn merge(&mut self, other: Window) {
+        self.count = self.count.saturating_add(other.count);
+        self.first_at = self.first_at.min(other.first_at);
+        self.last_at = self.last_at.max(other.last_at);
+    }
+}
+
+/// Shared tracker stored in AppState. Cheap to clone.
+#[derive(Clone, Default)]
+pub struct CaptureFailureTracker {
+    pending: Arc<Mutex<HashMap<String, Window>>>,
+    lifecycle: Lifecycle,
+}
+
+impl CaptureFailureTracker {
+    pub fn new(lifecycle: Lifecycle) -> Self {
+        Self { pending: Arc::default(), lifecycle }
+    }
+
+    /// Count one request for `slug` that could not be stored.
+    pub fn record(&self, slug: &str, at: DateTime<Utc>) {
+        self.lifecycle.emit(Event::CaptureFailing, serde_json::json!({ "slug": slug }));
+        let mut pending = self.pending.lock().unwrap_or_else(|e| e.into_inner());
+        if let Some(window) = pending.get_mut(slug) {
+            window.merge(Window { count: 1, first_at: at, last_at: at });
+            return;
+        }
+        if pending.len() >= MAX_TRACKED_SLUGS {
+            tracing::warn!(slug, "capture failure tracker full, not reporting");
+            return;
+        }
+        pending.insert(slug.to_string(), Window { count: 1, first_at: at, last_at: at });
+    }
+
+    fn take(&self) -> HashMap<String, Window> {
+        std::mem::take(&mut *self.pending.lock().unwrap_or_else(|e| e.into_inner()))
+    }
+
+    /// Put unreported windows back, merging with anything recorded meanwhile.
+    fn restore(&self, windows: HashMap<String, Window>) {
+        let mut pending = self.pending.lock().unwrap_or_else(|e| e.into_inner());
+        for (slug, window) in windows {
+            pending
+                .entry(slug)
+                .and_modify(|w| w.merge(window))
+                .or_insert(window);
+        }
+    }
+
+    /// Report everything pending. Stops at the first database error and keeps
+    /// the rest for the next flush rather than waiting out one timeout per slug.
+    pub async fn flush(&self, pool: &PgPool, notify: &NotifyConfig) {
+        let mut windows = self.take();
+        let slugs: Vec<String> = windows.keys().cloned().collect();
+
+        for slug in slugs {
+            let window = windows[&slug];
+            let result: Result<Option<serde_json::Value>, _> =
+                sqlx::query_scalar("SELECT record_capture_failures($1, $2, $3, $4)")
+                    .bind(&slug)
+                    .bind(window.count as i32)
+                    .bind(window.first_at)
+                    .bind(window.last_at)
+                    .fetch_one(pool)
+                    .await;
+
+            match result {
+                Ok(report) => {
+                    windows.remove(&slug);
+                    tracing::warn!(
+                        slug,
+                        count = window.count,
+                        "reported requests that could not be stored"
+                    );
+                    if let Some(url) = report.as_ref().and_then(new_window_notification_url) {
+                        spawn_notification(notify, url, &slug, window);
+                    }
+                }
+                Err(e) => {
+                    tracing::error!(slug, error = %e, "failed to report capture failures, will retry");
+                    break;
+                }
+            }
+        }
+
+        if !windows.is_empty() {
+            self.restore(windows);
+        }
+    }
+}
+
+/// Notification proxy settings shared with per-request notifications.
+#[derive(Clone, Default)]
+pub struct NotifyConfig {
+    pub proxy_url: Option<String>,
+    pub proxy_secret: Option<String>,
+}
+
+/// The notification URL to alert, when the report opened a new window and
+/// the endpoint has one configured.
+fn new_window_notification_url(report: &serde_json::Value) -> Option<String> {
+    if !report.get("new_window").and_then(|v| v.as_bool()).unwrap_or(false) {
+        return None;
+    }
+    report
+        .get("notification_url")
+        .and_then(|v| v.as_str())
+        .filter(|url| !url.is_empty())
+        .map(str::to_string)
+}
+
+fn notification_payload(slug: &str, window: Window) -> serde_json::Value {
+    serde_json::json!({
+        "event": "capture_failed",
+        "slug": slug,
+        "count": window.count,
+        "from": window.first_at.to_rfc3339(),
+        "to": window.last_at.to_rfc3339(),
+    })
+}
+
+fn spawn_notification(notify: &NotifyConfig, url: String, slug: &str, window: Window) {
+    let target = NotificationTarget {
+        url,
+        proxy_url: notify.proxy_url.clone(),
+        proxy_secret: notify.proxy_secret.clone(),
+    };
+    let payload = notification_payload(slug, window);
+    let slug = slug.to_string();
+    tokio::spawn(async move {
+        deliver_notification(&target, &payload, "", &slug).await;
+    });
+}
+
+/// Report pending failures every [`FLUSH_INTERVAL`] for the life of the process.
+pub fn spawn_flusher(tracker: CaptureFailureTracker, pool: PgPool, notify: NotifyConfig) {
+    tokio::spawn(async move {
+        let mut interval = tokio::time::interval(FLUSH_INTERVAL);
+        interval.set_missed_tick_behavior(tokio::time::MissedTickBehavior::Delay);
+        loop {
+            interval.tick().await;
+            tracker.flush(&pool, &notify).await;
+        }
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use chrono::TimeZone;
+
+    fn at(secs: i64) -> DateTime<Utc> {
+        Utc.timestamp_opt(1_700_000_000 + secs, 0).unwrap()
+    }
+
+    #[test]
+    fn record_accumulates_per_slug() {
+        let tracker = CaptureFailureTracker::default();
+        tracker.record("abc", at(5));
+        tracker.record("abc", at(1));
+        tracker.record("abc", at(9));
+        tracker.record("xyz", at(3));
+
+        let pending = tracker.take();
+        assert_eq!(
+            pending["abc"],
+            Window { count: 3, first_at: at(1), last_at: at(9) }
+        );
+        assert_eq!(pending["xyz"].count, 1);
+        assert!(tracker.take().is_empty());
+    }
+
+    #[test]
+    fn restore_merges_with_new_failures() {
+        let tracker = CaptureFailureTracker::default();
+        tracker.record("abc", at(10));
+        let unreported = tracker.take();
+
+        tracker.record("abc", at(20));
+        tracker.restore(unreported);
+
+        let pending = tracker.take();
+        assert_eq!(
+            pending["abc"],
+            Window { count: 2, first_at: at(10), last_at: at(20) }
+        );
+    }
+
+    #[test]
+    fn notifies_only_for_new_windows_with_url() {
+        let report = serde_json::json!({"notification_url": "https://hooks.example/n", "new_window": true});
+        assert_eq!(
+            new_window_notification_url(&report).as_deref(),
+            Some("https://hooks.example/n")
+        );
+
+        let extended = serde_json::json!({"notification_url": "https://hooks.example/n", "new_window": false});
+        assert!(new_window_notification_url(&extended).is_none());
+
+        let no_url = serde_json::json!({"notification_url": null, "new_window": true});
+        assert!(new_window_notification_url(&no_url).is_none());
+    }
+
+    #[test]
+    fn payload_describes_window() {
+        let payload = notification_payload("abc", Window { count: 7, first_at: at(0), last_at: at(60) });
+        assert_eq!(payload["event"], "capture_failed");
+        assert_eq!(payload["slug"], "abc");
+        assert_eq!(payload["count"], 7);
+        assert_eq!(payload["from"], at(0).to_rfc3339());
+        assert_eq!(payload["to"], at(60).to_rfc3339());
+    }
+}
@@ -0,0 +1,310 @@
+//! Capture filters: which requests an endpoint stores.
+//!
+//! `endpoints.capture_filter` has `include` and `exclude` rule lists. A
+//! request is stored when it matches an `include` rule (or there are none)
+//! and no `exclude` rule. A rule matches when every condition it sets holds:
+//!
+//! - `methods`: one of these methods
+//! - `path`: a glob over the captured path, where `*` matches within a
+//!   segment, `**` across segments and `?` one character
+//! - `header`: the header is present, and with `value` its value matches that
+//!   regular expression
+//! - `body`: a regular expression found in the body
+//!
+//! Filtered-out requests still go through `capture_webhook`, which answers
+//! them with the mock response like dry-run captures and counts them under
+//! the `filtered` rule of the endpoint's network stats, but doesn't store,
+//! charge, notify or forward them. Rules read the request as received.
+//! Configs are cached per slug with their patterns compiled once, and dropped
+//! on `endpoint_config` notifications.
+
+use axum::http::{HeaderMap, Method};
+use regex::Regex;
+use serde::Deserialize;
+use sqlx::PgPool;
+use std::sync::Arc;
+
+use crate::slug_cache::{SlugCache, load_json};
+
+#[derive(Debug, Deserialize)]
+struct StoredConfig {
+    #[serde(default)]
+    include: Vec<StoredRule>,
+    #[serde(default)]
+    exclude: Vec<StoredRule>,
+}
+
+#[derive(Debug, Deserialize)]
+struct StoredRule {
+    #[serde(default)]
+    methods: Vec<String>,
+    #[serde(default)]
+    path: Option<String>,
+    #[serde(default)]
+    header: Option<StoredHeader>,
+    #[serde(default)]
+    body: Option<String>,
+}
+
+#[derive(Debug, Deserialize)]
+struct StoredHeader {
+    name: String,
+    #[serde(default)]
+    value: Option<String>,
+}
+
+#[derive(Debug)]
+struct Rule {
+    methods: Vec<String>,
+    path: Option<Regex>,
+    header: Option<(String, Option<Regex>)>,
+    body: Option<Regex>,
+}
+
+/// The parts of a request rules look at.
+pub struct FilterRequest<'a> {
+    pub method: &'a Method,
+    pub path: &'a str,
+    pub headers: &'a HeaderMap,
+    pub body: &'a str,
+}
+
+/// An endpoint's compiled capture filter.
+#[derive(Debug)]
+pub struct CaptureFilter {
+    include: Vec<Rule>,
+    exclude: Vec<Rule>,
+}
+
+/// Regex source for a path glob: `**` spans segments, `*` stays within one.
+fn glob_source(glob: &str) -> String {
+    let mut source = String::from("^");
+    let mut chars = glob.chars().peekable();
+    while let Some(c) = chars.next() {
+        match c {
+            '*' if chars.peek() == Some(&'*') => {
+                chars.next();
+                source.push_str(".*");
+            }
+            '*' => source.push_str("[^/]*"),
+            '?' => source.push_str("[^/]"),
+            other => source.push_str(&regex::escape(other.encode_utf8(&mut [0; 4]))),
+        }
+    }
+    source.push('$');
+    source
+}
+
+impl Rule {
+    /// Compile a stored rule. A rule with a pattern the regex crate refuses
+    /// is dropped, so it can't match everything by accident.
+    fn compile(stored: StoredRule) -> Option<Self> {
+        let compile = |source: &str| match Regex::new(source) {
+            Ok(regex) => Some(regex),
+            Err(e) => {
+                tracing::warn!(pattern = source, error = %e, "unsupported capture filter pattern, skipping rule");
+                None
+            }
+        };
+        let path = match stored.path {
+            Some(glob) => Some(compile(&glob_source(&glob))?),
+            None => None,
+        };
+        let header = match stored.header {
+            Some(header) => {
+                let value = match header.value {
+                    Some(value) => Some(compile(&value)?),
+                    None => None,
+                };
+                Some((header.name.to_ascii_lowercase(), value))
+            }
+            None => None,
+        };
+        let body = match stored.body {
+            Some(body) => Some(compile(&body)?),
+            None => None,
+        };
+        Some(Self {
+            methods: stored
+                .methods
+                .iter()
+                .map(|m| m.to_ascii_uppercase())
+                .collect(),
+            path,
+            header,
+            body,
+        })
+    }
+
+    fn matches(&self, request: &FilterRequest) -> bool {
+        if !self.methods.is_empty() && !self.methods.iter().any(|m| m == request.method.as_str()) {
+            return false;
+        }
+        if let Some(ref path) = self.path
+            && !path.is_match(request.path)
+        {
+            return false;
+        }
+        if let Some((ref name, ref value)) = self.header {
+            let Some(header) = request.headers.get(name.as_str()) else {
+                return false;
+            };
+            if let Some(value) = value
+                && !value.is_match(&String::from_utf8_lossy(header.as_bytes()))
+            {
+                return false;
+            }
+        }
+        if let Some(ref body) = self.body
+            && !body.is_match(request.body)
+        {
+            return false;
+        }
+        true
+    }
+}
+
+impl CaptureFilter {
+    fn new(stored: StoredConfig) -> Self {
+        let compile = |rules: Vec<StoredRule>| -> Vec<Rule> {
+            rules.into_iter().filter_map(Rule::compile).collect()
+        };
+        Self {
+            include: compile(stored.include),
+            exclude: compile(stored.exclude),
+        }
+    }
+
+    /// Whether the request should be stored.
+    pub fn captures(&self, request: &FilterRequest) -> bool {
+        (self.include.is_empty() || self.include.iter().any(|rule| rule.matches(request)))
+            && !self.exclude.iter().any(|rule| rule.matches(request))
+    }
+}
+
+/// Per-slug capture filters, shared across requests via AppState.
+pub type CaptureFilterCache = SlugCache<Option<Arc<CaptureFilter>>>;
+
+impl CaptureFilterCache {
+    /// Look up an endpoint's filter, reading through to Postgres on a miss.
+    /// `None` when the endpoint stores everything. Lookup failures fail open
+    /// and are not cached.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Arc<CaptureFilter>> {
+        self.get_or_load(slug, |_| async move {
+            let stored: Option<StoredConfig> =
+                load_json(pool, slug, "get_endpoint_capture_filter", "capture_filter").await?;
+            Some(stored.map(|stored| Arc::new(CaptureFilter::new(stored))))
+        })
+        .await
+        .flatten()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::http::HeaderValue;
+    use serde_json::json;
+
+    fn filter(config: serde_json::Value) -> CaptureFilter {
+        CaptureFilter::new(serde_json::from_value(config).unwrap())
+    }
+
+    fn captures(
+        filter: &CaptureFilter,
+        method: Method,
+        path: &str,
+        headers: &[(&'static str, &str)],
+        body: &str,
+    ) -> bool {
+        let mut map = HeaderMap::new();
+        for (name, value) in headers {
+            map.insert(*name, HeaderValue::from_str(value).unwrap());
+        }
+        filter.captures(&FilterRequest {
+            method: &method,
+            path,
+            headers: &map,
+            body,
+        })
+    }
+
+    #[test]
+    fn globs_stay_within_segments() {
+        let single = Regex::new(&glob_source("/events/*")).unwrap();
+        assert!(single.is_match("/events/order"));
+        assert!(!single.is_match("/events/order/created"));
+        let any = Regex::new(&glob_source("/events/**")).unwrap();
+        assert!(any.is_match("/events/order/created"));
+        let one = Regex::new(&glob_source("/v?/hook.json")).unwrap();
+        assert!(one.is_match("/v2/hook.json"));
+        assert!(!one.is_match("/v2/hookxjson"));
+    }
+
+    #[test]
+    fn exclude_drops_heartbeats() {
+        let f = filter(json!({
+            "exclude": [
+                {"header": {"name": "X-Event", "value": "^ping$"}},
+                {"methods": ["post"], "body": "\"type\":\\s*\"heartbeat\""}
+            ]
+        }));
+        assert!(!captures(&f, Method::POST, "/", &[("x-event", "ping")], ""));
+        assert!(captures(
+            &f,
+            Method::POST,
+            "/",
+            &[("x-event", "order.created")],
+            ""
+        ));
+        assert!(!captures(
+            &f,
+            Method::POST,
+            "/",
+            &[],
+            r#"{"type": "heartbeat"}"#
+        ));
+        assert!(captures(
+            &f,
+            Method::PUT,
+            "/",
+            &[],
+            r#"{"type": "heartbeat"}"#
+        ));
+    }
+
+    #[test]
+    fn include_needs_every_condition_of_a_rule() {
+        let f = filter(json!({
+            "include": [{"methods": ["POST"], "path": "/orders/**", "header": {"name": "x-signature"}}]
+        }));
+        assert!(captures(
+            &f,
+            Method::POST,
+            "/orders/1/paid",
+            &[("x-signature", "abc")],
+            ""
+        ));
+        assert!(!captures(&f, Method::POST, "/orders/1/paid", &[], ""));
+        assert!(!captures(
+            &f,
+            Method::GET,
+            "/orders/1",
+            &[("x-signature", "abc")],
+            ""
+        ));
+        assert!(!captures(
+            &f,
+            Method::POST,
+            "/health",
+            &[("x-signature", "abc")],
+            ""
+        ));
+    }
+
+    #[test]
+    fn rules_with_bad_patterns_are_dropped() {
+        let f = filter(json!({"include": [{"body": "(unclosed"}]}));
+        assert!(captures(&f, Method::POST, "/", &[], "anything"));
+    }
+}
@@ -0,0 +1,117 @@
+//! Chaos mode: an endpoint's `chaos` config answers a share of its requests
+//! with an injected fault instead of the reply they'd get, to test how
+//! senders retry. capture_webhook rolls for the fault (and stores it as
+//! `requests.chaos_fault`); the receiver carries it out:
+//!
+//! - `error`: the 5xx status capture_webhook picked
+//! - `hang`: no reply for [`HANG`], then the connection is dropped
+//! - `reset`: the connection is dropped right after the status line
+//!
+//! Faults are injected after the capture is stored, forwarded and notified,
+//! so only the sender sees them.
+
+use std::pin::Pin;
+use std::task::{Context, Poll};
+use std::time::Duration;
+
+use axum::body::Body;
+use axum::http::StatusCode;
+use axum::response::{IntoResponse, Response};
+use bytes::Bytes;
+use hyper::body::Frame;
+use serde::Deserialize;
+
+/// How long a hung reply holds the connection before dropping it. Longer
+/// than the timeouts most senders use, so they give up first.
+pub const HANG: Duration = Duration::from_secs(60);
+
+/// A fault capture_webhook picked for this request.
+#[derive(Debug, Deserialize, PartialEq)]
+#[serde(tag = "fault", rename_all = "lowercase")]
+pub enum Fault {
+    Error { status: u16 },
+    Hang,
+    Reset,
+}
+
+impl Fault {
+    pub fn name(&self) -> &'static str {
+        match self {
+            Fault::Error { .. } => "error",
+            Fault::Hang => "hang",
+            Fault::Reset => "reset",
+        }
+    }
+
+    /// Carry the fault out. A hang waits here before dropping the connection.
+    pub async fn respond(self) -> Response {
+        match self {
+            Fault::Error { status } => (
+                StatusCode::from_u16(status)
+                    .ok()
+                    .filter(StatusCode::is_server_error)
+                    .unwrap_or(StatusCode::INTERNAL_SERVER_ERROR),
+                "Injected fault",
+            )
+                .into_response(),
+            Fault::Hang => {
+                tokio::time::sleep(HANG).await;
+                reset()
+            }
+            Fault::Reset => reset(),
+        }
+    }
+}
+
+/// A reply whose body fails as soon as it's read, so the server aborts the
+/// connection (HTTP/1) or resets the stream (HTTP/2) mid-response.
+fn reset() -> Response {
+    Response::new(Body::new(Aborted))
+}
+
+struct Aborted;
+
+impl hyper::body::Body for Aborted {
+    type Data = Bytes;
+    type Error = std::io::Error;
+
+    fn poll_frame(
+        self: Pin<&mut Self>,
+        _cx: &mut Context<'_>,
+    ) -> Poll<Option<Result<Frame<Bytes>, std::io::Error>>> {
+        Poll::Ready(Some(Err(std::io::ErrorKind::ConnectionReset.into())))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use http_body_util::BodyExt;
+    use serde_json::json;
+
+    #[test]
+    fn parses_faults() {
+        let parse = |v| serde_json::from_value::<Fault>(v).unwrap();
+        assert_eq!(
+            parse(json!({"fault": "error", "status": 503})),
+            Fault::Error { status: 503 }
+        );
+        assert_eq!(parse(json!({"fault": "hang"})), Fault::Hang);
+        assert_eq!(parse(json!({"fault": "reset"})), Fault::Reset);
+        assert!(serde_json::from_value::<Fault>(json!({"fault": "slow"})).is_err());
+    }
+
+    #[tokio::test]
+    async fn error_answers_with_its_status() {
+        let response = Fault::Error { status: 502 }.respond().await;
+        assert_eq!(response.status(), StatusCode::BAD_GATEWAY);
+        let response = Fault::Error { status: 200 }.respond().await;
+        assert_eq!(response.status(), StatusCode::INTERNAL_SERVER_ERROR);
+    }
+
+    #[tokio::test]
+    async fn reset_fails_the_body() {
+        let response = Fault::Reset.respond().await;
+        assert!(response.into_body().collect().await.is_err());
+    }
+}
@@ -0,0 +1,472 @@
+//! Client certificates presented on the mTLS listener.
+//!
+//! A client that connects to the mTLS port (see `mtls`) with a certificate
+//! has its chain attached to every request on the connection as a
+//! [`ClientCert`]. Captures store the leaf's subject, issuer, serial, SHA-256
+//! fingerprint and validity in `requests.client_cert`.
+//!
+//! The listener only checks that the client holds the certificate's key.
+//! Trust is per endpoint: an endpoint with a CA bundle
+//! (`endpoints.client_ca`) refuses captures without a chain that verifies
+//! against it with `client_certificate_required`, which includes everything
+//! arriving on the plain HTTP and gRPC listeners. The bundle is read through
+//! `get_endpoint_client_ca()` and cached per slug like the other endpoint
+//! settings; lookup failures fail open like the header encryption cache.
+//!
+//! Only the fields worth showing are read out of the certificate, with a
+//! small DER walker; chain validation is left to webpki.
+
+use chrono::{DateTime, NaiveDateTime, Utc};
+use rustls::RootCertStore;
+use rustls::pki_types::pem::PemObject;
+use rustls::pki_types::{CertificateDer, UnixTime};
+use rustls::server::WebPkiClientVerifier;
+use rustls::server::danger::ClientCertVerifier;
+use serde::Serialize;
+use sha2::{Digest, Sha256};
+use sqlx::PgPool;
+use std::sync::Arc;
+
+use crate::slug_cache::SlugCache;
+
+const INTEGER: u8 = 0x02;
+const OID: u8 = 0x06;
+const SEQUENCE: u8 = 0x30;
+const SET: u8 = 0x31;
+const UTC_TIME: u8 = 0x17;
+const GENERALIZED_TIME: u8 = 0x18;
+/// `[0] EXPLICIT Version` at the start of a v2/v3 TBSCertificate
+const VERSION: u8 = 0xa0;
+
+/// Crypto used for the listener and for verifying chains, matching the
+/// `ring` backend the rest of the receiver's TLS already uses.
+pub fn crypto_provider() -> Arc<rustls::crypto::CryptoProvider> {
+    Arc::new(rustls::crypto::ring::default_provider())
+}
+
+/// A certificate chain presented by the client, leaf first.
+#[derive(Debug, Clone, Serialize)]
+pub struct ClientCert {
+    /// RFC 4514 distinguished name, e.g. `CN=payouts.acme.example,O=Acme Bank`
+    pub subject: String,
+    pub issuer: String,
+    /// Serial number, lowercase hex
+    pub serial: String,
+    /// SHA-256 of the DER certificate, lowercase hex
+    pub fingerprint: String,
+    pub not_before: DateTime<Utc>,
+    pub not_after: DateTime<Utc>,
+    #[serde(skip)]
+    chain: Vec<CertificateDer<'static>>,
+}
+
+/// The `requests.client_cert` value of a capture.
+#[derive(Serialize)]
+struct Record<'a> {
+    #[serde(flatten)]
+    cert: &'a ClientCert,
+    /// Set when the chain verified against the endpoint's CA bundle
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    verified: bool,
+}
+
+impl ClientCert {
+    /// Describe a presented chain. `None` when it is empty or the leaf isn't
+    /// a certificate we can read.
+    pub fn from_chain(chain: &[CertificateDer<'_>]) -> Option<Self> {
+        let leaf = chain.first()?;
+        let fields = describe(leaf)?;
+        Some(Self {
+            subject: fields.subject,
+            issuer: fields.issuer,
+            serial: fields.serial,
+            fingerprint: hex::encode(Sha256::digest(leaf)),
+            not_before: fields.not_before,
+            not_after: fields.not_after,
+            chain: chain.iter().map(|cert| cert.clone().into_owned()).collect(),
+        })
+    }
+
+    pub fn record(&self, verified: bool) -> Option<serde_json::Value> {
+        serde_json::to_value(Record { cert: self, verified }).ok()
+    }
+}
+
+/// The CAs an endpoint trusts for client certificates.
+pub struct ClientCa {
+    /// `None` when the stored bundle can't be used, so nothing verifies
+    verifier: Option<Arc<dyn ClientCertVerifier>>,
+}
+
+impl ClientCa {
+    /// Parse a PEM bundle of one or more CA certificates.
+    pub fn from_pem(pem: &str) -> Result<Self, String> {
+        let mut roots = RootCertStore::empty();
+        for cert in CertificateDer::pem_slice_iter(pem.as_bytes()) {
+            let cert = cert.map_err(|e| format!("invalid PEM: {e}"))?;
+            roots.add(cert).map_err(|e| format!("invalid CA certificate: {e}"))?;
+        }
+        if roots.is_empty() {
+            return Err("no certificates in bundle".to_string());
+        }
+        let verifier = WebPkiClientVerifier::builder_with_provider(Arc::new(roots), crypto_provider())
+            .build()
+            .map_err(|e| e.to_string())?;
+        Ok(Self {
+            verifier: Some(verifier),
+        })
+    }
+
+    /// Whether `cert` chains to one of the CAs and is valid now.
+    pub fn verifies(&self, cert: &ClientCert) -> bool {
+        self.verifies_at(cert, UnixTime::now())
+    }
+
+    fn verifies_at(&self, cert: &ClientCert, now: UnixTime) -> bool {
+        let (Some(verifier), Some((leaf, intermediates))) = (&self.verifier, cert.chain.split_first())
+        else {
+            return false;
+        };
+        verifier.verify_client_cert(leaf, intermediates, now).is_ok()
+    }
+}
+
+/// Per-slug CA bundles, shared across requests via AppState.
+pub type ClientCaCache = SlugCache<Option<Arc<ClientCa>>>;
+
+impl ClientCaCache {
+    /// Look up an endpoint's CA bundle, reading through to Postgres on a
+    /// miss. `None` when the endpoint doesn't require client certificates.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Arc<ClientCa>> {
+        self.get_or_load(slug, |_| async move {
+            let result: Result<Option<String>, sqlx::Error> =
+                sqlx::query_scalar("SELECT get_endpoint_client_ca($1)")
+                    .bind(slug)
+                    .fetch_one(pool)
+                    .await;
+
+            match result {
+                Ok(Some(pem)) => match ClientCa::from_pem(&pem) {
+                    Ok(ca) => Some(Some(Arc::new(ca))),
+                    Err(e) => {
+                        // Still required, so refuse everything rather than nothing
+                        tracing::warn!(slug, error = %e, "invalid client_ca configuration");
+                        Some(Some(Arc::new(ClientCa { verifier: None })))
+                    }
+                },
+                Ok(None) => Some(None),
+                Err(e) => {
+                    tracing::error!(slug, error = %e, "get_endpoint_client_ca query failed");
+                    None
+                }
+            }
+        })
+        .await
+        .flatten()
+    }
+}
+
+/// When a DER certificate expires. Also used for the server certificates
+/// issued to custom domains.
+pub fn not_after(der: &[u8]) -> Option<DateTime<Utc>> {
+    describe(der).map(|fields| fields.not_after)
+}
+
+struct Fields {
+    subject: String,
+    issuer: String,
+    serial: String,
+    not_before: DateTime<Utc>,
+    not_after: DateTime<Utc>,
+}
+
+/// Read the displayed fields of a DER certificate's TBSCertificate.
+fn describe(der: &[u8]) -> Option<Fields> {
+    let (cert, _) = expect(der, SEQUENCE)?;
+    let (tbs, _) = expect(cert, SEQUENCE)?;
+    let rest = match element(tbs)? {
+        (VERSION, _, rest) => rest,
+        _ => tbs,
+    };
+    let (serial, rest) = expect(rest, INTEGER)?;
+    let (_, _, rest) = element(rest)?; // signature algorithm
+    let (issuer, rest) = expect(rest, SEQUENCE)?;
+    let (validity, rest) = expect(rest, SEQUENCE)?;
+    let (subject, _) = expect(rest, SEQUENCE)?;
+    let (not_before, validity) = time(validity)?;
+    let (not_after, _) = time(validity)?;
+
+    let serial = match serial {
+        [0, rest @ ..] if !rest.is_empty() => rest,
+        serial => serial,
+    };
+    Some(Fields {
+        subject: name(subject)?,
+        issuer: name(issuer)?,
+        serial: hex::encode(serial),
+        not_before,
+        not_after,
+    })
+}
+
+/// One DER element: its tag, contents and whatever follows it.
+fn element(input: &[u8]) -> Option<(u8, &[u8], &[u8])> {
+    let (&tag, rest) = input.split_first()?;
+    let (&first, rest) = rest.split_first()?;
+    let (len, rest) = if first < 0x80 {
+        (first as usize, rest)
+    } else {
+        let octets = (first & 0x7f) as usize;
+        if octets == 0 || octets > 4 {
+            return None;
+        }
+        let (len, rest) = rest.split_at_checked(octets)?;
+        (len.iter().fold(0usize, |acc, &b| (acc << 8) | b as usize), rest)
+    };
+    let (contents, rest) = rest.split_at_checked(len)?;
+    Some((tag, contents, rest))
+}
+
+fn expect(input: &[u8], tag: u8) -> Option<(&[u8], &[u8])> {
+    let (found, contents, rest) = element(input)?;
+    (found == tag).then_some((contents, rest))
+}
+
+/// A validity time. RFC 5280 requires UTC with seconds in both forms.
+fn time(input: &[u8]) -> Option<(DateTime<Utc>, &[u8])> {
+    let (tag, contents, rest) = element(input)?;
+    let text = std::str::from_utf8(contents).ok()?;
+    let full = match tag {
+        UTC_TIME => {
+            let year: u8 = text.get(..2)?.parse().ok()?;
+            format!("{}{text}", if year >= 50 { "19" } else { "20" })
+        }
+        GENERALIZED_TIME => text.to_string(),
+        _ => return None,
+    };
+    let parsed = NaiveDateTime::parse_from_str(&full, "%Y%m%d%H%M%SZ").ok()?;
+    Some((parsed.and_utc(), rest))
+}
+
+/// A Name as an RFC 4514 string: most specific RDN first.
+fn name(mut input: &[u8]) -> Option<String> {
+    let mut rdns = Vec::new();
+    while !input.is_empty() {
+        let (mut set, rest) = expect(input, SET)?;
+        input = rest;
+        let mut attributes = Vec::new();
+        while !set.is_empty() {
+            let (attribute, rest) = expect(set, SEQUENCE)?;
+            set = rest;
+            let (oid, value) = expect(attribute, OID)?;
+            let (tag, contents, _) = element(value)?;
+            let value = match string_value(tag, contents) {
+                Some(text) => escape(&text),
+                None => format!("#{}", hex::encode(value)),
+            };
+            attributes.push(format!("{}={value}", attribute_type(oid)));
+        }
+        rdns.push(attributes.join("+"));
+    }
+    rdns.reverse();
+    Some(rdns.join(","))
+}
+
+fn attribute_type(oid: &[u8]) -> String {
+    let short = match oid {
+        [0x55, 0x04, 0x03] => "CN",
+        [0x55, 0x04, 0x05] => "serialNumber",
+        [0x55, 0x04, 0x06] => "C",
+        [0x55, 0x04, 0x07] => "L",
+        [0x55, 0x04, 0x08] => "ST",
+        [0x55, 0x04, 0x09] => "STREET",
+        [0x55, 0x04, 0x0a] => "O",
+        [0x55, 0x04, 0x0b] => "OU",
+        [0x09, 0x92, 0x26, 0x89, 0x93, 0xf2, 0x2c, 0x64, 0x01, 0x01] => "UID",
+        [0x09, 0x92, 0x26, 0x89, 0x93, 0xf2, 0x2c, 0x64, 0x01, 0x19] => "DC",
+        [0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x09, 0x01] => "emailAddress",
+        _ => return dotted(oid),
+    };
+    short.to_string()
+}
+
+/// An OID in dotted-decimal form.
+fn dotted(oid: &[u8]) -> String {
+    let mut arcs = Vec::new();
+    let mut value: u64 = 0;
+    for &byte in oid {
+        value = (value << 7) | u64::from(byte & 0x7f);
+        if byte & 0x80 == 0 {
+            arcs.push(value);
+            value = 0;
+        }
+    }
+    let Some((&first, rest)) = arcs.split_first() else {
+        return String::new();
+    };
+    let (a, b) = match first {
+        0..40 => (0, first),
+        40..80 => (1, first - 40),
+        _ => (2, first - 80),
+    };
+    std::iter::once(a)
+        .chain(std::iter::once(b))
+        .chain(rest.iter().copied())
+        .map(|arc| arc.to_string())
+        .collect::<Vec<_>>()
+        .join(".")
+}
+
+/// The text of a directory string; `None` for other value types.
+fn string_value(tag: u8, contents: &[u8]) -> Option<String> {
+    match tag {
+        // UTF8String, NumericString, PrintableString, IA5String
+        0x0c | 0x12 | 0x13 | 0x16 => String::from_utf8(contents.to_vec()).ok(),
+        // TeletexString, in practice Latin-1
+        0x14 => Some(contents.iter().map(|&b| char::from(b)).collect()),
+        // BMPString
+        0x1e => {
+            let units: Vec<u16> = contents
+                .chunks_exact(2)
+                .map(|pair| u16::from_be_bytes([pair[0], pair[1]]))
+                .collect();
+            String::from_utf16(&units).ok()
+        }
+        _ => None,
+    }
+}
+
+/// Escape an attribute value for RFC 4514.
+fn escape(value: &str) -> String {
+    let last = value.chars().count().saturating_sub(1);
+    let mut out = String::with_capacity(value.len());
+    for (i, c) in value.chars().enumerate() {
+        match c {
+            '"' | '+' | ',' | ';' | '<' | '>' | '\\' => {
+                out.push('\\');
+                out.push(c);
+            }
+            '#' if i == 0 => out.push_str("\\#"),
+            ' ' if i == 0 || i == last => out.push_str("\\ "),
+            '\0' => out.push_str("\\00"),
+            c => out.push(c),
+        }
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::time::Duration;
+
+    /// `CN=payouts.acme.example,OU=Payments,O=Acme Bank,C=GB`, serial
+    /// 0x1f2e3d, issued by [`CA`], valid 2026-10-16 to 2126-09-22.
+    const CLIENT: &str = "-----BEGIN CERTIFICATE-----
+MIIB8DCCAZagAwIBAgIDHy49MAoGCCqGSM49BAMCMDgxCzAJBgNVBAYTAkdCMRIw
+EAYDVQQKDAlBY21lIEJhbmsxFTATBgNVBAMMDEFjbWUgVGVzdCBDQTAgFw0yNjEw
+MTYxNDU1NDBaGA8yMTI2MDkyMjE0NTU0MFowUzELMAkGA1UEBhMCR0IxEjAQBgNV
+BAoMCUFjbWUgQmFuazERMA8GA1UECwwIUGF5bWVudHMxHTAbBgNVBAMMFHBheW91
+dHMuYWNtZS5leGFtcGxlMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEtAgEyjiA
+K9WDBw0wtnW1sFgMDIvtlXlDWPVS6Mh4Y+dUbQ7FehCwvuHaciYXOVflslGpNFLw
+5So/va6xI1zyd6NyMHAwCQYDVR0TBAIwADAOBgNVHQ8BAf8EBAMCB4AwEwYDVR0l
+BAwwCgYIKwYBBQUHAwIwHQYDVR0OBBYEFB+k1Tkfw7VohApcoBSOUnXqv6/fMB8G
+A1UdIwQYMBaAFAQHOQcrxMvrsccVADY4wUy0hf9aMAoGCCqGSM49BAMCA0gAMEUC
+IQCLbbc63kaKQvTz8hLYkF3Mn4GzfnYzS6vaMMuKKV+OsQIgBpqr/IytZQzOi1OU
+xMju3N99dksRImwvdPn727yoGzo=
+-----END CERTIFICATE-----";
+
+    /// `CN=Acme Test CA,O=Acme Bank,C=GB`
+    const CA: &str = "-----BEGIN CERTIFICATE-----
+MIIB1jCCAX2gAwIBAgIUAJTu6WSndV5Kaw3NqOu9XhfpGWEwCgYIKoZIzj0EAwIw
+ODELMAkGA1UEBhMCR0IxEjAQBgNVBAoMCUFjbWUgQmFuazEVMBMGA1UEAwwMQWNt
+ZSBUZXN0IENBMCAXDTI2MTAxNjE0NTU0MFoYDzIxMjYwOTIyMTQ1NTQwWjA4MQsw
+CQYDVQQGEwJHQjESMBAGA1UECgwJQWNtZSBCYW5rMRUwEwYDVQQDDAxBY21lIFRl
+c3QgQ0EwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAAT0MEoXp17I95yzOaBP9lUx
+9h4vUdPqZddA7Rwj8EI32aF6jB9elRHB8Qh8rn3yENPzJaFcOCDQmq1qvyIc/6Db
+o2MwYTAdBgNVHQ4EFgQUBAc5ByvEy+uxxxUANjjBTLSF/1owHwYDVR0jBBgwFoAU
+BAc5ByvEy+uxxxUANjjBTLSF/1owDwYDVR0TAQH/BAUwAwEB/zAOBgNVHQ8BAf8E
+BAMCAQYwCgYIKoZIzj0EAwIDRwAwRAIgb7kJVa8dO3QbVh6J4lF4bylvR5kWmI8f
+e9H7sAqLSPECIEe2EUsHdBJZZAELvhyZ/kI6DvEFJoaHbjI6P5DN2fXE
+-----END CERTIFICATE-----";
+
+    /// An unrelated self-signed CA, `CN=Other CA`
+    const OTHER_CA: &str = "-----BEGIN CERTIFICATE-----
+MIIBfTCCASOgAwIBAgIUSI9ZG7PwhzTom5mXIAL9v/VXDwkwCgYIKoZIzj0EAwIw
+EzERMA8GA1UEAwwIT3RoZXIgQ0EwIBcNMjYxMDE2MTQ1NTQwWhgPMjEyNjA5MjIx
+NDU1NDBaMBMxETAPBgNVBAMMCE90aGVyIENBMFkwEwYHKoZIzj0CAQYIKoZIzj0D
+AQcDQgAEtH/kDihux1KWcI6njKqyFQwVpI6yV9Jq3SYYj9Wf7BbSOLxA10IdpJRB
+KIzA0VKqtRIQ4NrOohhers9kKLt7qqNTMFEwHQYDVR0OBBYEFEI5XhvbtrGzKEmd
+4qQXYqXMVMAcMB8GA1UdIwQYMBaAFEI5XhvbtrGzKEmd4qQXYqXMVMAcMA8GA1Ud
+EwEB/wQFMAMBAf8wCgYIKoZIzj0EAwIDSAAwRQIhAKVY1tOH2P/rld14D7YksXpj
+89PIWnHeL5jEpBI3ZGhGAiBzXuHM59Ts+ilydoY0t9cpcwBYKA4FOPTyzcLVA1Zs
+/Q==
+-----END CERTIFICATE-----";
+
+    fn client() -> ClientCert {
+        let leaf = CertificateDer::from_pem_slice(CLIENT.as_bytes()).unwrap();
+        ClientCert::from_chain(&[leaf]).unwrap()
+    }
+
+    /// 2027-01-01, inside the test certificates' validity
+    fn during_validity() -> UnixTime {
+        UnixTime::since_unix_epoch(Duration::from_secs(1_798_761_600))
+    }
+
+    #[test]
+    fn describes_the_leaf() {
+        let cert = client();
+        assert_eq!(cert.subject, "CN=payouts.acme.example,OU=Payments,O=Acme Bank,C=GB");
+        assert_eq!(cert.issuer, "CN=Acme Test CA,O=Acme Bank,C=GB");
+        assert_eq!(cert.serial, "1f2e3d");
+        assert_eq!(
+            cert.fingerprint,
+            "570e26212ed190e6d2e3a8d3c00cd9dd833ff9e4e52072e19542043fe2fa080a"
+        );
+        assert_eq!(cert.not_before.to_rfc3339(), "2026-10-16T14:55:40+00:00");
+        // UTCTime stops at 2049; later dates are GeneralizedTime
+        assert_eq!(cert.not_after.to_rfc3339(), "2126-09-22T14:55:40+00:00");
+
+        let record = cert.record(true).unwrap();
+        assert_eq!(record["verified"], true);
+        assert_eq!(record["not_after"], "2126-09-22T14:55:40Z");
+        assert!(cert.record(false).unwrap().get("verified").is_none());
+    }
+
+    #[test]
+    fn unreadable_chains_are_not_described() {
+        assert!(ClientCert::from_chain(&[]).is_none());
+        assert!(ClientCert::from_chain(&[CertificateDer::from(vec![0x30, 0x03, 0x02, 0x01])]).is_none());
+    }
+
+    #[test]
+    fn verifies_against_the_endpoint_bundle() {
+        let cert = client();
+        let now = during_validity();
+        assert!(ClientCa::from_pem(CA).unwrap().verifies_at(&cert, now));
+        assert!(!ClientCa::from_pem(OTHER_CA).unwrap().verifies_at(&cert, now));
+        let bundle = format!("{OTHER_CA}\n{CA}\n");
+        assert!(ClientCa::from_pem(&bundle).unwrap().verifies_at(&cert, now));
+        // Before notBefore
+        let early = UnixTime::since_unix_epoch(Duration::from_secs(1_700_000_000));
+        assert!(!ClientCa::from_pem(CA).unwrap().verifies_at(&cert, early));
+        assert!(!ClientCa { verifier: None }.verifies_at(&cert, now));
+    }
+
+    #[test]
+    fn bundles_must_hold_certificates() {
+        assert!(ClientCa::from_pem("").is_err());
+        assert!(ClientCa::from_pem("-----BEGIN CERTIFICATE-----\nnot base64!\n-----END CERTIFICATE-----").is_err());
+    }
+
+    #[test]
+    fn names_are_escaped_and_oids_dotted() {
+        assert_eq!(escape("Acme, Inc."), "Acme\\, Inc.");
+        assert_eq!(escape("#1 "), "\\#1\\ ");
+        // 1.3.6.1.4.1.311.60.2.1.3 (jurisdiction country)
+        assert_eq!(
+            dotted(&[0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x3c, 0x02, 0x01, 0x03]),
+            "1.3.6.1.4.1.311.60.2.1.3"
+        );
+    }
+}
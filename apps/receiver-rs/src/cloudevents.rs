@@ -0,0 +1,122 @@
+//! CloudEvents context attributes (CloudEvents 1.0 HTTP protocol binding).
+//!
+//! Binary mode carries the attributes in `ce-*` headers with the event data as
+//! the body; structured mode sends the whole event as an
+//! `application/cloudevents+json` body. Either way the receiver stores the
+//! event's `type`, `source`, `id` and `subject` in `requests.cloud_event` so
+//! captures can be listed by event type. Batches
+//! (`application/cloudevents-batch+json`) are not described, and neither is
+//! anything missing a required attribute.
+
+use axum::http::HeaderMap;
+use serde::Serialize;
+
+/// Attributes longer than this are not CloudEvents worth describing.
+const MAX_ATTRIBUTE_LEN: usize = 1024;
+
+/// The `requests.cloud_event` value of a captured event.
+#[derive(Debug, PartialEq, Serialize)]
+pub struct CloudEvent {
+    /// `binary` or `structured`
+    pub mode: &'static str,
+    pub specversion: String,
+    #[serde(rename = "type")]
+    pub event_type: String,
+    pub source: String,
+    pub id: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub subject: Option<String>,
+}
+
+/// Describe the CloudEvent a request carries, if any. Binary mode wins when
+/// both are present, as the binding requires for `ce-*` headers.
+pub fn detect(headers: &HeaderMap, content_type: &str, body: &[u8]) -> Option<CloudEvent> {
+    binary(headers).or_else(|| structured(content_type, body))
+}
+
+fn attribute(value: Option<&str>) -> Option<String> {
+    value
+        .map(str::trim)
+        .filter(|v| !v.is_empty() && v.len() <= MAX_ATTRIBUTE_LEN)
+        .map(str::to_string)
+}
+
+fn binary(headers: &HeaderMap) -> Option<CloudEvent> {
+    let header = |name: &str| attribute(headers.get(name).and_then(|v| v.to_str().ok()));
+    Some(CloudEvent {
+        mode: "binary",
+        specversion: header("ce-specversion")?,
+        event_type: header("ce-type")?,
+        source: header("ce-source")?,
+        id: header("ce-id")?,
+        subject: header("ce-subject"),
+    })
+}
+
+fn structured(content_type: &str, body: &[u8]) -> Option<CloudEvent> {
+    let media_type = content_type.split(';').next().unwrap_or_default().trim();
+    if !media_type.eq_ignore_ascii_case("application/cloudevents+json") {
+        return None;
+    }
+    let event: serde_json::Value = serde_json::from_slice(body).ok()?;
+    let field = |name: &str| attribute(event.get(name).and_then(|v| v.as_str()));
+    Some(CloudEvent {
+        mode: "structured",
+        specversion: field("specversion")?,
+        event_type: field("type")?,
+        source: field("source")?,
+        id: field("id")?,
+        subject: field("subject"),
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn headers(pairs: &[(&'static str, &'static str)]) -> HeaderMap {
+        let mut map = HeaderMap::new();
+        for (k, v) in pairs {
+            map.insert(*k, v.parse().unwrap());
+        }
+        map
+    }
+
+    #[test]
+    fn binary_mode_from_ce_headers() {
+        let h = headers(&[
+            ("ce-specversion", "1.0"),
+            ("ce-type", "com.example.order.created"),
+            ("ce-source", "/orders"),
+            ("ce-id", "A234-1234"),
+            ("ce-subject", "order/42"),
+        ]);
+        let event = detect(&h, "application/json", b"{\"total\":10}").unwrap();
+        assert_eq!(event.mode, "binary");
+        assert_eq!(event.event_type, "com.example.order.created");
+        assert_eq!(event.subject.as_deref(), Some("order/42"));
+        assert_eq!(
+            serde_json::to_value(&event).unwrap(),
+            serde_json::json!({
+                "mode": "binary", "specversion": "1.0", "type": "com.example.order.created",
+                "source": "/orders", "id": "A234-1234", "subject": "order/42"
+            })
+        );
+
+        let missing_id = headers(&[("ce-specversion", "1.0"), ("ce-type", "t"), ("ce-source", "/s")]);
+        assert_eq!(detect(&missing_id, "application/json", b"{}"), None);
+    }
+
+    #[test]
+    fn structured_mode_from_body() {
+        let body = br#"{"specversion":"1.0","type":"com.example.ping","source":"urn:x","id":"1","data":{}}"#;
+        let event = detect(&HeaderMap::new(), "application/cloudevents+json; charset=utf-8", body).unwrap();
+        assert_eq!(event.mode, "structured");
+        assert_eq!(event.event_type, "com.example.ping");
+        assert_eq!(event.subject, None);
+
+        assert_eq!(detect(&HeaderMap::new(), "application/json", body), None);
+        assert_eq!(detect(&HeaderMap::new(), "application/cloudevents-batch+json", body), None);
+        assert_eq!(detect(&HeaderMap::new(), "application/cloudevents+json", b"[1]"), None);
+    }
+}
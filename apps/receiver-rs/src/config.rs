@@ -5,6 +5,10 @@ pub struct Config {
     pub database_url: String,
     pub capture_shared_secret: String,
     pub port: u16,
+    pub grpc_port: Option<u16>,
+    pub mtls: Option<crate::mtls::MtlsConfig>,
+    pub custom_domains: Option<crate::custom_domain::CustomDomainConfig>,
+    pub subdomain_host: Option<String>,
     pub debug: bool,
     pub log_dir: String,
     pub pool_min: u32,
@@ -14,6 +18,22 @@ pub struct Config {
     pub notify_proxy_url: Option<String>,
     pub notify_secret: Option<String>,
     pub redis_url: Option<String>,
+    pub aws_credentials: Option<crate::function_sink::AwsCredentials>,
+    pub function_sink_concurrency: usize,
+    pub max_path_length: usize,
+    pub mirror_url: Option<String>,
+    pub mirror_sample_percent: u8,
+    pub mirror_concurrency: usize,
+    pub multipart_inline_bytes: usize,
+    pub quota_bypass_secret: Option<String>,
+    pub header_encryption_key: Option<String>,
+    pub object_storage: Option<crate::body_store::ObjectStorageConfig>,
+    pub object_storage_max_body_bytes: usize,
+    pub tenant_limits: crate::tenant::TenantLimits,
+    pub lifecycle_events_url: Option<String>,
+    pub lifecycle_events_secret: Option<String>,
+    pub geoip_db_path: Option<String>,
+    pub geoip_asn_db_path: Option<String>,
 }
 
 impl std::fmt::Debug for Config {
@@ -22,6 +42,10 @@ impl std::fmt::Debug for Config {
             .field("database_url", &"[REDACTED]")
             .field("capture_shared_secret", &"[REDACTED]")
             .field("port", &self.port)
+            .field("grpc_port", &self.grpc_port)
+            .field("mtls", &self.mtls)
+            .field("custom_domains", &self.custom_domains)
+            .field("subdomain_host", &self.subdomain_host)
             .field("debug", &self.debug)
             .field("log_dir", &self.log_dir)
             .field("pool_min", &self.pool_min)
@@ -31,6 +55,22 @@ impl std::fmt::Debug for Config {
             .field("notify_proxy_url", &self.notify_proxy_url)
             .field("notify_secret", &self.notify_secret.as_ref().map(|_| "[REDACTED]"))
             .field("redis_url", &self.redis_url.as_ref().map(|_| "[REDACTED]"))
+            .field("aws_credentials", &self.aws_credentials)
+            .field("function_sink_concurrency", &self.function_sink_concurrency)
+            .field("max_path_length", &self.max_path_length)
+            .field("mirror_url", &self.mirror_url)
+            .field("mirror_sample_percent", &self.mirror_sample_percent)
+            .field("mirror_concurrency", &self.mirror_concurrency)
+            .field("multipart_inline_bytes", &self.multipart_inline_bytes)
+            .field("quota_bypass_secret", &self.quota_bypass_secret.as_ref().map(|_| "[REDACTED]"))
+            .field("header_encryption_key", &self.header_encryption_key.as_ref().map(|_| "[REDACTED]"))
+            .field("object_storage", &self.object_storage)
+            .field("object_storage_max_body_bytes", &self.object_storage_max_body_bytes)
+            .field("tenant_limits", &self.tenant_limits)
+            .field("lifecycle_events_url", &self.lifecycle_events_url)
+            .field("lifecycle_events_secret", &self.lifecycle_events_secret.as_ref().map(|_| "[REDACTED]"))
+            .field("geoip_db_path", &self.geoip_db_path)
+            .field("geoip_asn_db_path", &self.geoip_asn_db_path)
             .finish()
     }
 }
@@ -55,6 +95,42 @@ impl Config {
             env::var("CAPTURE_SHARED_SECRET").expect("CAPTURE_SHARED_SECRET is required");
 
         let port: u16 = parse_env_or("PORT", 3001);
+        // The gRPC capture listener only starts when given a port.
+        let grpc_port = env::var("GRPC_PORT")
+            .ok()
+            .and_then(|v| v.parse::<u16>().ok())
+            .filter(|&p| p > 0);
+        // The mTLS listener only starts with a port and a certificate to present.
+        let mtls = match (
+            env::var("MTLS_PORT").ok().and_then(|v| v.parse::<u16>().ok()).filter(|&p| p > 0),
+            env::var("MTLS_CERT_FILE").ok().filter(|v| !v.is_empty()),
+            env::var("MTLS_KEY_FILE").ok().filter(|v| !v.is_empty()),
+        ) {
+            (Some(port), Some(cert_file), Some(key_file)) => Some(crate::mtls::MtlsConfig {
+                port,
+                cert_file,
+                key_file,
+            }),
+            _ => None,
+        };
+        // The custom domain listener only starts when given a port.
+        let custom_domains = env::var("CUSTOM_DOMAIN_PORT")
+            .ok()
+            .and_then(|v| v.parse::<u16>().ok())
+            .filter(|&p| p > 0)
+            .map(|port| crate::custom_domain::CustomDomainConfig {
+                port,
+                acme_directory_url: env::var("ACME_DIRECTORY_URL")
+                    .ok()
+                    .filter(|v| !v.is_empty())
+                    .unwrap_or_else(|| crate::acme::DEFAULT_DIRECTORY_URL.into()),
+                acme_contact: env::var("ACME_CONTACT_EMAIL").ok().filter(|v| !v.is_empty()),
+            });
+        // `{slug}.{SUBDOMAIN_HOST}` is routed like `/w/{slug}` when set.
+        let subdomain_host = env::var("SUBDOMAIN_HOST")
+            .ok()
+            .map(|v| v.trim().trim_matches('.').to_ascii_lowercase())
+            .filter(|v| !v.is_empty());
         let debug = env::var("RECEIVER_DEBUG").is_ok_and(|v| !v.is_empty());
         let log_dir = env::var("RECEIVER_LOG_DIR").unwrap_or_else(|_| "logs".into());
         let pool_min: u32 = parse_env_or("PG_POOL_MIN", 5);
@@ -74,11 +150,89 @@ impl Config {
         let redis_url = env::var("REDIS_URL")
             .ok()
             .filter(|v| !v.is_empty());
+        // Function sinks are disabled unless AWS credentials are configured.
+        let aws_credentials = match (
+            env::var("AWS_ACCESS_KEY_ID").ok().filter(|v| !v.is_empty()),
+            env::var("AWS_SECRET_ACCESS_KEY").ok().filter(|v| !v.is_empty()),
+        ) {
+            (Some(access_key_id), Some(secret_access_key)) => Some(crate::function_sink::AwsCredentials {
+                access_key_id,
+                secret_access_key,
+                session_token: env::var("AWS_SESSION_TOKEN").ok().filter(|v| !v.is_empty()),
+            }),
+            _ => None,
+        };
+        let function_sink_concurrency: usize = parse_env_or("FUNCTION_SINK_CONCURRENCY", 64);
+        let max_path_length: usize =
+            parse_env_or("MAX_PATH_LENGTH", crate::path::DEFAULT_MAX_PATH_LENGTH);
+        // Mirroring is disabled unless a mirror base URL is configured.
+        let mirror_url = env::var("MIRROR_URL")
+            .ok()
+            .filter(|v| !v.is_empty());
+        let mirror_sample_percent: u8 = parse_env_or::<u8>("MIRROR_SAMPLE_PERCENT", 100).min(100);
+        let mirror_concurrency: usize = parse_env_or("MIRROR_CONCURRENCY", 32);
+        // Multipart part bodies are only described, not stored inline, unless enabled.
+        let multipart_inline_bytes: usize = parse_env_or("MULTIPART_INLINE_BYTES", 0);
+        // Quota bypass tokens are rejected unless a signing secret is configured.
+        let quota_bypass_secret = env::var("QUOTA_BYPASS_SECRET")
+            .ok()
+            .filter(|v| !v.is_empty());
+        // Headers an endpoint lists for encryption are redacted unless a key is configured.
+        let header_encryption_key = env::var("HEADER_ENCRYPTION_KEY")
+            .ok()
+            .filter(|v| !v.is_empty());
+        // Bodies over the inline limit are rejected unless a bucket is configured.
+        let object_storage = match (
+            env::var("OBJECT_STORAGE_URL").ok().filter(|v| !v.is_empty()),
+            env::var("OBJECT_STORAGE_BUCKET").ok().filter(|v| !v.is_empty()),
+            env::var("OBJECT_STORAGE_ACCESS_KEY_ID").ok().filter(|v| !v.is_empty()),
+            env::var("OBJECT_STORAGE_SECRET_ACCESS_KEY").ok().filter(|v| !v.is_empty()),
+        ) {
+            (Some(url), Some(bucket), Some(access_key_id), Some(secret_access_key)) => {
+                Some(crate::body_store::ObjectStorageConfig {
+                    url,
+                    bucket,
+                    region: env::var("OBJECT_STORAGE_REGION")
+                        .ok()
+                        .filter(|v| !v.is_empty())
+                        .unwrap_or_else(|| "auto".into()),
+                    credentials: crate::function_sink::AwsCredentials {
+                        access_key_id,
+                        secret_access_key,
+                        session_token: None,
+                    },
+                })
+            }
+            _ => None,
+        };
+        let object_storage_max_body_bytes: usize =
+            parse_env_or("OBJECT_STORAGE_MAX_BODY_BYTES", 20 * 1_024 * 1_024);
+        // Per-account shares of the instance; 0 disables a limit.
+        let tenant_limits = crate::tenant::TenantLimits {
+            max_in_flight: parse_env_or("TENANT_MAX_IN_FLIGHT", 200),
+            max_in_flight_bytes: parse_env_or("TENANT_MAX_IN_FLIGHT_BYTES", 64 * 1_024 * 1_024),
+            max_sink_deliveries: parse_env_or("TENANT_MAX_SINK_DELIVERIES", 16),
+        };
+        // Lifecycle events are only logged unless a URL is configured.
+        let lifecycle_events_url = env::var("LIFECYCLE_EVENTS_URL")
+            .ok()
+            .filter(|v| !v.is_empty());
+        let lifecycle_events_secret = env::var("LIFECYCLE_EVENTS_SECRET")
+            .ok()
+            .filter(|v| !v.is_empty());
+        // Countries come only from cf-ipcountry, and there are no ASNs, unless
+        // MaxMind-format databases are configured.
+        let geoip_db_path = env::var("GEOIP_DB_PATH").ok().filter(|v| !v.is_empty());
+        let geoip_asn_db_path = env::var("GEOIP_ASN_DB_PATH").ok().filter(|v| !v.is_empty());
 
         Self {
             database_url,
             capture_shared_secret,
             port,
+            grpc_port,
+            mtls,
+            custom_domains,
+            subdomain_host,
             debug,
             log_dir,
             pool_min,
@@ -88,6 +242,22 @@ impl Config {
             notify_proxy_url,
             notify_secret,
             redis_url,
+            aws_credentials,
+            function_sink_concurrency,
+            max_path_length,
+            mirror_url,
+            mirror_sample_percent,
+            mirror_concurrency,
+            multipart_inline_bytes,
+            quota_bypass_secret,
+            header_encryption_key,
+            object_storage,
+            object_storage_max_body_bytes,
+            tenant_limits,
+            lifecycle_events_url,
+            lifecycle_events_secret,
+            geoip_db_path,
+            geoip_asn_db_path,
         }
     }
 }
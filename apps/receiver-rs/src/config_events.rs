@@ -0,0 +1,155 @@
+//! Endpoint configuration change notifications.
+//!
+//! The `endpoint_config_versions` trigger announces every configuration
+//! change, including rollbacks, with `pg_notify('endpoint_config', slug)`. The
+//! receiver listens so cached per-endpoint state is dropped as soon as the
+//! change commits instead of after the cache TTL, which is what makes a
+//! rollback during an incident take effect immediately.
+//!
+//! Notifications sent while the listener is disconnected are lost, so the
+//! caches are cleared wholesale whenever the connection drops.
+
+use sqlx::PgPool;
+use sqlx::postgres::PgListener;
+use std::time::Duration;
+
+use crate::body_limit::BodyLimitCache;
+use crate::capture_auth::CaptureAuthCache;
+use crate::capture_filter::CaptureFilterCache;
+use crate::client_cert::ClientCaCache;
+use crate::cors::CorsCache;
+use crate::custom_domain::DomainCache;
+use crate::header_crypt::EncryptionCache;
+use crate::json_schema::SchemaCache;
+use crate::jwt::JwtCache;
+use crate::mock_cache::MockCache;
+use crate::redact::RedactionCache;
+use crate::sampling::SamplingCache;
+use crate::signature::SignatureCache;
+use crate::slug_cache::EndpointCache;
+use crate::transform::TransformCache;
+
+/// Channel used by the endpoint_config_versions trigger.
+const CHANNEL: &str = "endpoint_config";
+
+/// Wait between reconnect attempts.
+const RECONNECT_DELAY: Duration = Duration::from_secs(5);
+
+/// Every cache of per-endpoint state, shared across requests via AppState.
+/// Configuration changes and expiry drop a slug from all of them at once.
+#[derive(Clone)]
+pub struct EndpointCaches {
+    pub transforms: TransformCache,
+    pub mocks: MockCache,
+    pub header_encryption: EncryptionCache,
+    pub client_cas: ClientCaCache,
+    pub custom_domains: DomainCache,
+    pub signatures: SignatureCache,
+    pub jwts: JwtCache,
+    pub capture_auth: CaptureAuthCache,
+    pub schemas: SchemaCache,
+    pub redactions: RedactionCache,
+    pub body_limits: BodyLimitCache,
+    pub cors: CorsCache,
+    pub capture_filters: CaptureFilterCache,
+    pub sampling: SamplingCache,
+}
+
+impl EndpointCaches {
+    /// `max_body_size` is the receiver's own body limit, which no endpoint
+    /// exceeds.
+    pub fn new(max_body_size: usize) -> Self {
+        Self {
+            transforms: TransformCache::new(),
+            mocks: MockCache::new(),
+            header_encryption: EncryptionCache::new(),
+            client_cas: ClientCaCache::new(),
+            custom_domains: DomainCache::new(),
+            signatures: SignatureCache::new(),
+            jwts: JwtCache::new(),
+            capture_auth: CaptureAuthCache::new(),
+            schemas: SchemaCache::new(),
+            redactions: RedactionCache::new(),
+            body_limits: BodyLimitCache::new(max_body_size),
+            cors: CorsCache::new(),
+            capture_filters: CaptureFilterCache::new(),
+            sampling: SamplingCache::new(),
+        }
+    }
+
+    fn all(&self) -> [&dyn EndpointCache; 14] {
+        [
+            &self.transforms,
+            &self.mocks,
+            &self.header_encryption,
+            &self.client_cas,
+            &self.custom_domains,
+            &self.signatures,
+            &self.jwts,
+            &self.capture_auth,
+            &self.schemas,
+            &self.redactions,
+            &self.body_limits,
+            &self.cors,
+            &self.capture_filters,
+            &self.sampling,
+        ]
+    }
+
+    /// Drop a slug's cached state so its next request re-reads it.
+    pub fn invalidate(&self, slug: &str) {
+        for cache in self.all() {
+            cache.invalidate(slug);
+        }
+    }
+
+    /// Drop every slug's cached state (after missing notifications).
+    pub fn clear(&self) {
+        for cache in self.all() {
+            cache.clear();
+        }
+    }
+}
+
+/// Listen for configuration changes for the life of the process.
+pub fn spawn_listener(pool: PgPool, caches: EndpointCaches) {
+    tokio::spawn(async move {
+        let mut connected_before = false;
+        loop {
+            if let Err(e) = listen(&pool, &caches, &mut connected_before).await {
+                tracing::warn!(error = %e, "endpoint config listener disconnected, retrying");
+            }
+            tokio::time::sleep(RECONNECT_DELAY).await;
+        }
+    });
+}
+
+async fn listen(
+    pool: &PgPool,
+    caches: &EndpointCaches,
+    connected_before: &mut bool,
+) -> Result<(), sqlx::Error> {
+    let mut listener = PgListener::connect_with(pool).await?;
+    listener.listen(CHANNEL).await?;
+    if *connected_before {
+        caches.clear();
+    }
+    *connected_before = true;
+    tracing::info!("listening for endpoint config changes");
+
+    loop {
+        match listener.try_recv().await? {
+            Some(notification) => {
+                let slug = notification.payload();
+                tracing::debug!(slug, "endpoint config changed, dropping cached state");
+                caches.invalidate(slug);
+            }
+            None => {
+                // Connection lost; the next call reconnects. Anything announced
+                // in between is gone, so start from empty caches.
+                tracing::warn!("endpoint config listener reconnecting");
+                caches.clear();
+            }
+        }
+    }
+}
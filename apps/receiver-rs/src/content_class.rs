@@ -0,0 +1,168 @@
+//! Body classification, so clients pick a renderer without sniffing the body
+//! themselves and searches can filter by kind of payload.
+//!
+//! Classes are `json`, `xml`, `form`, `text`, `image`, `protobuf` and
+//! `binary`. The body decides where it can: a JSON body labelled
+//! `text/plain` is `json`, and a PNG sent as `application/octet-stream` is
+//! an `image`. `protobuf` is a best guess for bodies that aren't labelled
+//! as protobuf: binary that parses as a well-formed protobuf message.
+
+/// Bodies up to this size are parsed to confirm JSON or protobuf; larger
+/// ones are classified by their first bytes and content type.
+const MAX_PARSE_BYTES: usize = 1024 * 1024;
+
+/// Class of a body, or `None` when it is empty.
+pub fn classify(content_type: &str, body: &[u8]) -> Option<&'static str> {
+    if body.is_empty() {
+        return None;
+    }
+    let media_type = content_type
+        .split(';')
+        .next()
+        .unwrap_or("")
+        .trim()
+        .to_ascii_lowercase();
+
+    if is_image(body) {
+        return Some("image");
+    }
+    if media_type.contains("protobuf") || media_type == "application/grpc" || media_type == "application/grpc+proto" {
+        return Some("protobuf");
+    }
+
+    let Ok(text) = std::str::from_utf8(body) else {
+        return Some(if media_type.starts_with("image/") {
+            "image"
+        } else if media_type == "multipart/form-data" {
+            "form"
+        } else if looks_like_protobuf(body) {
+            "protobuf"
+        } else {
+            "binary"
+        });
+    };
+
+    let trimmed = text.trim_start();
+    if (trimmed.starts_with('{') || trimmed.starts_with('['))
+        && (if body.len() <= MAX_PARSE_BYTES {
+            serde_json::from_str::<serde::de::IgnoredAny>(text).is_ok()
+        } else {
+            media_type.ends_with("json")
+        })
+    {
+        return Some("json");
+    }
+    if media_type == "application/x-www-form-urlencoded" || media_type == "multipart/form-data" {
+        return Some("form");
+    }
+    if trimmed.starts_with("<?xml")
+        || (trimmed.starts_with('<') && media_type.ends_with("xml"))
+    {
+        return Some("xml");
+    }
+    if text.chars().any(|c| c.is_control() && !matches!(c, '\n' | '\r' | '\t')) {
+        return Some(if looks_like_protobuf(body) { "protobuf" } else { "binary" });
+    }
+    Some("text")
+}
+
+/// PNG, JPEG, GIF, WebP and BMP magic numbers.
+fn is_image(body: &[u8]) -> bool {
+    let bmp = body.len() >= 6
+        && body.starts_with(b"BM")
+        && u32::from_le_bytes([body[2], body[3], body[4], body[5]]) as usize == body.len();
+    body.starts_with(b"\x89PNG\r\n\x1a\n")
+        || body.starts_with(b"\xff\xd8\xff")
+        || body.starts_with(b"GIF87a")
+        || body.starts_with(b"GIF89a")
+        || (body.len() >= 12 && body.starts_with(b"RIFF") && &body[8..12] == b"WEBP")
+        || bmp
+}
+
+/// Whether `body` reads as a sequence of protobuf fields that ends exactly
+/// at the last byte. Random binary rarely does for more than a few bytes.
+fn looks_like_protobuf(body: &[u8]) -> bool {
+    if body.len() > MAX_PARSE_BYTES {
+        return false;
+    }
+    let mut rest = body;
+    while !rest.is_empty() {
+        let Some(tag) = varint(&mut rest) else {
+            return false;
+        };
+        if tag >> 3 == 0 || tag >> 3 > (1 << 29) - 1 {
+            return false;
+        }
+        let skip = match tag & 7 {
+            0 => match varint(&mut rest) {
+                Some(_) => 0,
+                None => return false,
+            },
+            1 => 8,
+            2 => match varint(&mut rest) {
+                Some(len) => len,
+                None => return false,
+            },
+            5 => 4,
+            _ => return false,
+        };
+        if skip > rest.len() as u64 {
+            return false;
+        }
+        rest = &rest[skip as usize..];
+    }
+    true
+}
+
+/// Read a base-128 varint, advancing `rest`.
+fn varint(rest: &mut &[u8]) -> Option<u64> {
+    let mut value = 0u64;
+    for (i, byte) in rest.iter().enumerate().take(10) {
+        value |= u64::from(byte & 0x7f) << (7 * i);
+        if byte & 0x80 == 0 {
+            *rest = &rest[i + 1..];
+            return Some(value);
+        }
+    }
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn text_classes() {
+        assert_eq!(classify("application/json", br#"{"a": 1}"#), Some("json"));
+        assert_eq!(classify("text/plain", b"[1, 2]"), Some("json"));
+        assert_eq!(classify("application/json", b"{not json"), Some("text"));
+        assert_eq!(classify("application/x-www-form-urlencoded", b"a=1&b=2"), Some("form"));
+        assert_eq!(classify("text/plain", b"<?xml version=\"1.0\"?><a/>"), Some("xml"));
+        assert_eq!(classify("application/atom+xml", b"<feed/>"), Some("xml"));
+        assert_eq!(classify("text/html", b"<html></html>"), Some("text"));
+        assert_eq!(classify("", b"hello\nworld"), Some("text"));
+        assert_eq!(classify("application/json", b""), None);
+    }
+
+    #[test]
+    fn images_by_magic_or_type() {
+        assert_eq!(classify("application/octet-stream", b"\x89PNG\r\n\x1a\n\0\0"), Some("image"));
+        assert_eq!(classify("", b"\xff\xd8\xff\xe0\0\x10JFIF"), Some("image"));
+        assert_eq!(classify("image/x-custom", b"\xfe\xfe\xfe"), Some("image"));
+    }
+
+    #[test]
+    fn protobuf_by_type_or_shape() {
+        assert_eq!(classify("application/x-protobuf", b"anything"), Some("protobuf"));
+        // field 1 (varint) = 150, field 2 (bytes) = "\xff\xfe"
+        assert_eq!(classify("application/octet-stream", b"\x08\x96\x01\x12\x02\xff\xfe"), Some("protobuf"));
+        // A length running past the end
+        assert_eq!(classify("application/octet-stream", b"\x12\x09\xff\xfe"), Some("binary"));
+        assert_eq!(classify("", b"\xff\xff\xff\xff"), Some("binary"));
+    }
+
+    #[test]
+    fn multipart_with_binary_parts_is_form() {
+        assert_eq!(classify("multipart/form-data; boundary=x", b"--x\r\n\xff\xfe\r\n--x--"), Some("form"));
+    }
+}
@@ -0,0 +1,160 @@
+//! Correlation keys for mock responses: a mock can pick its reply from a
+//! key/value table using a value read from the incoming request, so callbacks
+//! about different entities (orders, customers, ...) each get their own answer.
+//!
+//! Supported keys:
+//! - `method`, `path`
+//! - `header.<name>` (case-insensitive)
+//! - `query.<name>`
+//! - `body.json.<path>` — dot-separated path, numeric segments index arrays
+//! - `body.form.<name>` — `application/x-www-form-urlencoded` bodies
+//!
+//! Only strings, numbers and booleans correlate; objects, arrays and null
+//! never match.
+
+use serde_json::Value;
+use std::collections::HashMap;
+
+/// The parts of a request a correlation key can read, after capture-time
+/// transforms have run (so keys address what is stored).
+pub struct RequestFields<'a> {
+    pub method: &'a str,
+    pub path: &'a str,
+    pub headers: &'a HashMap<String, String>,
+    pub query: &'a HashMap<String, String>,
+    pub body: &'a str,
+}
+
+/// Extract the correlation value for `key`, or `None` if the request doesn't
+/// carry one.
+pub fn extract(key: &str, request: &RequestFields<'_>) -> Option<String> {
+    match key {
+        "method" => return Some(request.method.to_string()),
+        "path" => return Some(request.path.to_string()),
+        _ => {}
+    }
+
+    if let Some(name) = key.strip_prefix("header.") {
+        let name = name.to_ascii_lowercase();
+        return request.headers.get(&name).cloned();
+    }
+    if let Some(name) = key.strip_prefix("query.") {
+        return request.query.get(name).cloned();
+    }
+    if let Some(path) = key.strip_prefix("body.json.") {
+        let body: Value = serde_json::from_str(request.body).ok()?;
+        return crate::transform::lookup(&body, path).and_then(scalar_string);
+    }
+    if let Some(name) = key.strip_prefix("body.form.") {
+        return form_value(request.body, name);
+    }
+    None
+}
+
+/// A JSON scalar as the string it correlates as; `None` for objects, arrays
+/// and null.
+pub fn scalar_string(value: &Value) -> Option<String> {
+    match value {
+        Value::String(s) => Some(s.clone()),
+        Value::Number(n) => Some(n.to_string()),
+        Value::Bool(b) => Some(b.to_string()),
+        _ => None,
+    }
+}
+
+fn form_value(body: &str, name: &str) -> Option<String> {
+    body.split('&').find_map(|pair| {
+        let (k, v) = pair.split_once('=').unwrap_or((pair, ""));
+        (decode_form(k) == name).then(|| decode_form(v))
+    })
+}
+
+/// Decode one `application/x-www-form-urlencoded` component.
+fn decode_form(raw: &str) -> String {
+    let bytes = raw.as_bytes();
+    let mut out = Vec::with_capacity(bytes.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        match bytes[i] {
+            b'+' => out.push(b' '),
+            b'%' if i + 2 < bytes.len()
+                && bytes[i + 1].is_ascii_hexdigit()
+                && bytes[i + 2].is_ascii_hexdigit() =>
+            {
+                out.push(hex_value(bytes[i + 1]) << 4 | hex_value(bytes[i + 2]));
+                i += 2;
+            }
+            b => out.push(b),
+        }
+        i += 1;
+    }
+    String::from_utf8_lossy(&out).into_owned()
+}
+
+fn hex_value(digit: u8) -> u8 {
+    match digit {
+        b'0'..=b'9' => digit - b'0',
+        b'a'..=b'f' => digit - b'a' + 10,
+        _ => digit - b'A' + 10,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn fields<'a>(
+        headers: &'a HashMap<String, String>,
+        query: &'a HashMap<String, String>,
+        body: &'a str,
+    ) -> RequestFields<'a> {
+        RequestFields {
+            method: "POST",
+            path: "/orders",
+            headers,
+            query,
+            body,
+        }
+    }
+
+    #[test]
+    fn extracts_json_body_values() {
+        let (h, q) = (HashMap::new(), HashMap::new());
+        let body = r#"{"order_id":"ord_1","n":42,"ok":true,"items":[{"sku":"a"}],"obj":{}}"#;
+        let req = fields(&h, &q, body);
+        assert_eq!(extract("body.json.order_id", &req).as_deref(), Some("ord_1"));
+        assert_eq!(extract("body.json.n", &req).as_deref(), Some("42"));
+        assert_eq!(extract("body.json.ok", &req).as_deref(), Some("true"));
+        assert_eq!(extract("body.json.items.0.sku", &req).as_deref(), Some("a"));
+        assert_eq!(extract("body.json.obj", &req), None);
+        assert_eq!(extract("body.json.missing", &req), None);
+    }
+
+    #[test]
+    fn non_json_body_has_no_json_values() {
+        let (h, q) = (HashMap::new(), HashMap::new());
+        assert_eq!(extract("body.json.a", &fields(&h, &q, "a=1")), None);
+    }
+
+    #[test]
+    fn extracts_form_values() {
+        let (h, q) = (HashMap::new(), HashMap::new());
+        let req = fields(&h, &q, "CallSid=CA1&From=%2B1555+0100&empty");
+        assert_eq!(extract("body.form.CallSid", &req).as_deref(), Some("CA1"));
+        assert_eq!(extract("body.form.From", &req).as_deref(), Some("+1555 0100"));
+        assert_eq!(extract("body.form.empty", &req).as_deref(), Some(""));
+        assert_eq!(extract("body.form.missing", &req), None);
+    }
+
+    #[test]
+    fn extracts_headers_query_method_and_path() {
+        let h = HashMap::from([("x-event-id".to_string(), "evt_9".to_string())]);
+        let q = HashMap::from([("customer".to_string(), "cus_2".to_string())]);
+        let req = fields(&h, &q, "");
+        assert_eq!(extract("header.X-Event-Id", &req).as_deref(), Some("evt_9"));
+        assert_eq!(extract("query.customer", &req).as_deref(), Some("cus_2"));
+        assert_eq!(extract("method", &req).as_deref(), Some("POST"));
+        assert_eq!(extract("path", &req).as_deref(), Some("/orders"));
+        assert_eq!(extract("cookie.session", &req), None);
+    }
+}
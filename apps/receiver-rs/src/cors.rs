@@ -0,0 +1,324 @@
+//! Per-endpoint CORS.
+//!
+//! Browser clients under test sometimes need more than `*`: credentials,
+//! specific request headers, or a cached preflight. `endpoints.cors` lists
+//! the allowed origins (`*` for any) and optionally the methods, request
+//! headers, whether credentials are allowed and the preflight max-age.
+//! Endpoints without a config keep the permissive default: any origin,
+//! method and header, no credentials.
+//!
+//! The webhook handler answers preflights (`OPTIONS` with `Origin` and
+//! `Access-Control-Request-Method`) itself with a 204, before anything is
+//! captured or counted, and adds the CORS headers to every other response,
+//! mock replies and receiver errors included. An origin the config doesn't
+//! list gets no CORS headers, so the browser refuses the response.
+//!
+//! The config is cached per slug like the other endpoint caches and dropped
+//! on `endpoint_config` notifications; lookup failures fall back to the
+//! default and are not cached.
+
+use axum::body::Body;
+use axum::http::{HeaderMap, HeaderValue, Method, StatusCode, header};
+use axum::response::Response;
+use serde::Deserialize;
+use sqlx::PgPool;
+use std::sync::Arc;
+
+use crate::slug_cache::{EndpointCache, SlugCache, load_json};
+
+/// Request headers a preflight's answer depends on.
+const PREFLIGHT_VARY: &str =
+    "origin, access-control-request-method, access-control-request-headers";
+
+/// An endpoint's `cors` config. Empty `methods` and `headers` allow any.
+#[derive(Debug, Deserialize)]
+pub struct CorsPolicy {
+    /// Exact origins (`https://app.example.com`), or `*` for any
+    pub origins: Vec<String>,
+    #[serde(default)]
+    pub methods: Vec<String>,
+    #[serde(default)]
+    pub headers: Vec<String>,
+    #[serde(default)]
+    pub credentials: bool,
+    /// Seconds browsers may cache a preflight answer
+    #[serde(rename = "maxAge", default)]
+    pub max_age: Option<u32>,
+}
+
+impl Default for CorsPolicy {
+    fn default() -> Self {
+        Self {
+            origins: vec!["*".to_string()],
+            methods: Vec::new(),
+            headers: Vec::new(),
+            credentials: false,
+            max_age: None,
+        }
+    }
+}
+
+/// Whether a request is a CORS preflight rather than a capture.
+pub fn is_preflight(method: &Method, headers: &HeaderMap) -> bool {
+    method == Method::OPTIONS
+        && headers.contains_key(header::ORIGIN)
+        && headers.contains_key(header::ACCESS_CONTROL_REQUEST_METHOD)
+}
+
+impl CorsPolicy {
+    fn any_origin(&self) -> bool {
+        self.origins.iter().any(|origin| origin == "*")
+    }
+
+    /// The `Access-Control-Allow-Origin` value for a request, if its origin
+    /// is allowed. `*` can't be combined with credentials, so the origin is
+    /// echoed instead.
+    fn allow_origin(&self, origin: Option<&HeaderValue>) -> Option<HeaderValue> {
+        let Some(origin) = origin else {
+            // Not a cross-origin browser request; keep the old `*` for
+            // clients that read it anyway.
+            return (self.any_origin() && !self.credentials).then(|| HeaderValue::from_static("*"));
+        };
+        if self.any_origin() {
+            return Some(if self.credentials {
+                origin.clone()
+            } else {
+                HeaderValue::from_static("*")
+            });
+        }
+        let origin_str = origin.to_str().ok()?;
+        self.origins
+            .iter()
+            .any(|allowed| allowed.eq_ignore_ascii_case(origin_str))
+            .then(|| origin.clone())
+    }
+
+    /// A list header: the configured values, or with none configured `*`,
+    /// echoing what was asked for when credentials rule out `*`.
+    fn allow_list(
+        &self,
+        configured: &[String],
+        requested: Option<&HeaderValue>,
+    ) -> Option<HeaderValue> {
+        if !configured.is_empty() {
+            return HeaderValue::from_str(&configured.join(", ")).ok();
+        }
+        if self.credentials {
+            return requested.cloned();
+        }
+        Some(HeaderValue::from_static("*"))
+    }
+
+    /// The answer to a preflight: 204 with the allowed methods and headers,
+    /// or a bare 204 for an origin the config doesn't list.
+    pub fn preflight(&self, request_headers: &HeaderMap) -> Response {
+        let mut response = Response::new(Body::empty());
+        *response.status_mut() = StatusCode::NO_CONTENT;
+        let headers = response.headers_mut();
+        headers.insert(header::VARY, HeaderValue::from_static(PREFLIGHT_VARY));
+        let Some(origin) = self.allow_origin(request_headers.get(header::ORIGIN)) else {
+            return response;
+        };
+        headers.insert(header::ACCESS_CONTROL_ALLOW_ORIGIN, origin);
+        if self.credentials {
+            headers.insert(
+                header::ACCESS_CONTROL_ALLOW_CREDENTIALS,
+                HeaderValue::from_static("true"),
+            );
+        }
+        let requested_method = request_headers.get(header::ACCESS_CONTROL_REQUEST_METHOD);
+        if let Some(methods) = self.allow_list(&self.methods, requested_method) {
+            headers.insert(header::ACCESS_CONTROL_ALLOW_METHODS, methods);
+        }
+        let requested_headers = request_headers.get(header::ACCESS_CONTROL_REQUEST_HEADERS);
+        if let Some(allowed) = self.allow_list(&self.headers, requested_headers) {
+            headers.insert(header::ACCESS_CONTROL_ALLOW_HEADERS, allowed);
+        }
+        if let Some(max_age) = self.max_age {
+            headers.insert(header::ACCESS_CONTROL_MAX_AGE, HeaderValue::from(max_age));
+        }
+        response
+    }
+
+    /// Add the CORS headers for a request from `origin` to its
+    /// (non-preflight) response, replacing any a mock configured.
+    pub fn apply(&self, origin: Option<&HeaderValue>, response: &mut Response) {
+        let headers = response.headers_mut();
+        let Some(origin) = self.allow_origin(origin) else {
+            return;
+        };
+        if origin != "*" {
+            headers.append(header::VARY, HeaderValue::from_static("origin"));
+        }
+        headers.insert(header::ACCESS_CONTROL_ALLOW_ORIGIN, origin);
+        if self.credentials {
+            headers.insert(
+                header::ACCESS_CONTROL_ALLOW_CREDENTIALS,
+                HeaderValue::from_static("true"),
+            );
+        }
+    }
+}
+
+/// Per-slug CORS configs, shared across requests via AppState.
+#[derive(Clone, Default)]
+pub struct CorsCache {
+    default: Arc<CorsPolicy>,
+    policies: SlugCache<Arc<CorsPolicy>>,
+}
+
+impl CorsCache {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// The policy for requests from everywhere else, used before the slug
+    /// is known to be valid.
+    pub fn fallback(&self) -> Arc<CorsPolicy> {
+        self.default.clone()
+    }
+
+    /// Look up an endpoint's policy, reading through to Postgres on a miss.
+    /// Endpoints without a config, and failed lookups, get the default.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Arc<CorsPolicy> {
+        self.policies
+            .get_or_load(slug, |_| async move {
+                let policy: Option<CorsPolicy> =
+                    load_json(pool, slug, "get_endpoint_cors", "cors").await?;
+                Some(policy.map_or_else(|| self.default.clone(), Arc::new))
+            })
+            .await
+            .unwrap_or_else(|| self.default.clone())
+    }
+}
+
+impl EndpointCache for CorsCache {
+    fn invalidate(&self, slug: &str) {
+        self.policies.invalidate(slug);
+    }
+
+    fn clear(&self) {
+        self.policies.clear();
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn request(pairs: &[(&'static str, &str)]) -> HeaderMap {
+        let mut headers = HeaderMap::new();
+        for (name, value) in pairs {
+            headers.insert(*name, HeaderValue::from_str(value).unwrap());
+        }
+        headers
+    }
+
+    fn policy(value: serde_json::Value) -> CorsPolicy {
+        serde_json::from_value(value).unwrap()
+    }
+
+    #[test]
+    fn preflights_need_origin_and_requested_method() {
+        let preflight = request(&[
+            ("origin", "https://app.test"),
+            ("access-control-request-method", "PUT"),
+        ]);
+        assert!(is_preflight(&Method::OPTIONS, &preflight));
+        assert!(!is_preflight(&Method::POST, &preflight));
+        assert!(!is_preflight(
+            &Method::OPTIONS,
+            &request(&[("origin", "https://app.test")])
+        ));
+    }
+
+    #[test]
+    fn default_allows_everything_without_credentials() {
+        let response = CorsPolicy::default().preflight(&request(&[
+            ("origin", "https://app.test"),
+            ("access-control-request-method", "PUT"),
+            ("access-control-request-headers", "x-trace"),
+        ]));
+        assert_eq!(response.status(), StatusCode::NO_CONTENT);
+        let headers = response.headers();
+        assert_eq!(headers["access-control-allow-origin"], "*");
+        assert_eq!(headers["access-control-allow-methods"], "*");
+        assert_eq!(headers["access-control-allow-headers"], "*");
+        assert!(headers.get("access-control-allow-credentials").is_none());
+
+        let mut response = Response::new(Body::empty());
+        CorsPolicy::default().apply(None, &mut response);
+        assert_eq!(response.headers()["access-control-allow-origin"], "*");
+    }
+
+    #[test]
+    fn listed_origins_are_echoed_with_credentials() {
+        let cors = policy(serde_json::json!({
+            "origins": ["https://app.test"],
+            "methods": ["POST", "PUT"],
+            "headers": ["authorization", "x-trace"],
+            "credentials": true,
+            "maxAge": 600,
+        }));
+        let response = cors.preflight(&request(&[
+            ("origin", "https://app.test"),
+            ("access-control-request-method", "PUT"),
+        ]));
+        let headers = response.headers();
+        assert_eq!(headers["access-control-allow-origin"], "https://app.test");
+        assert_eq!(headers["access-control-allow-credentials"], "true");
+        assert_eq!(headers["access-control-allow-methods"], "POST, PUT");
+        assert_eq!(
+            headers["access-control-allow-headers"],
+            "authorization, x-trace"
+        );
+        assert_eq!(headers["access-control-max-age"], "600");
+
+        let mut response = Response::new(Body::empty());
+        cors.apply(
+            Some(&HeaderValue::from_static("https://app.test")),
+            &mut response,
+        );
+        assert_eq!(
+            response.headers()["access-control-allow-origin"],
+            "https://app.test"
+        );
+        assert_eq!(response.headers()["vary"], "origin");
+
+        let mut response = Response::new(Body::empty());
+        cors.apply(
+            Some(&HeaderValue::from_static("https://evil.test")),
+            &mut response,
+        );
+        assert!(
+            response
+                .headers()
+                .get("access-control-allow-origin")
+                .is_none()
+        );
+        let refused = cors.preflight(&request(&[
+            ("origin", "https://evil.test"),
+            ("access-control-request-method", "PUT"),
+        ]));
+        assert!(
+            refused
+                .headers()
+                .get("access-control-allow-origin")
+                .is_none()
+        );
+    }
+
+    #[test]
+    fn any_origin_with_credentials_echoes_the_request() {
+        let cors = policy(serde_json::json!({"origins": ["*"], "credentials": true}));
+        let response = cors.preflight(&request(&[
+            ("origin", "https://app.test"),
+            ("access-control-request-method", "DELETE"),
+            ("access-control-request-headers", "x-trace"),
+        ]));
+        let headers = response.headers();
+        assert_eq!(headers["access-control-allow-origin"], "https://app.test");
+        assert_eq!(headers["access-control-allow-methods"], "DELETE");
+        assert_eq!(headers["access-control-allow-headers"], "x-trace");
+    }
+}
@@ -0,0 +1,371 @@
+//! Custom domains: a Pro account's own hostname (`hooks.example.com`)
+//! pointed at the receiver and mapped to one endpoint
+//! (`endpoints.custom_domain`).
+//!
+//! They are served on `CUSTOM_DOMAIN_PORT`, where the receiver terminates TLS
+//! with a certificate per domain, picked by SNI. A certificate is ordered on
+//! the first handshake for a domain (see `acme`), stored in Postgres so
+//! restarts and other instances reuse it, and renewed in the background once
+//! it is within a month of expiring. Handshakes for hostnames that aren't a
+//! custom domain are refused before anything is ordered, so the receiver
+//! never requests certificates for names it doesn't serve.
+//!
+//! Requests are routed by Host like subdomains (`handlers::subdomain`), with
+//! the hostname resolved to its endpoint's slug through [`DomainCache`]
+//! (`get_custom_domain_slug()`, 30s TTL, dropped on `endpoint_config`
+//! notifications). Unlike the other endpoint caches a failed lookup fails
+//! closed: without the mapping there is no endpoint to capture for.
+
+use chrono::{DateTime, Utc};
+use rustls::ServerConfig;
+use rustls::pki_types::pem::PemObject;
+use rustls::pki_types::{CertificateDer, PrivateKeyDer};
+use serde::Deserialize;
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+use tokio::sync::Mutex;
+
+use crate::acme::{AcmeClient, Challenges};
+use crate::client_cert::{crypto_provider, not_after};
+use crate::slug_cache::{EndpointCache, SlugCache};
+
+/// Certificates this close to expiring are renewed.
+const RENEW_BEFORE: chrono::Duration = chrono::Duration::days(30);
+
+/// How often cached certificates are checked for renewal.
+const RENEW_INTERVAL: Duration = Duration::from_secs(12 * 60 * 60);
+
+/// Wait after a failed order before trying the domain again. Let's Encrypt
+/// allows five failed validations per hostname an hour.
+const RETRY_AFTER: Duration = Duration::from_secs(60 * 60);
+
+/// Where the custom domain listener binds and which CA issues its certificates.
+#[derive(Debug, Clone)]
+pub struct CustomDomainConfig {
+    pub port: u16,
+    pub acme_directory_url: String,
+    pub acme_contact: Option<String>,
+}
+
+/// Lowercase, without a port or trailing dot, as custom domains are stored.
+pub fn normalize_host(host: &str) -> String {
+    let host = host.rsplit_once(':').map_or(host, |(name, port)| {
+        if port.bytes().all(|b| b.is_ascii_digit()) { name } else { host }
+    });
+    host.strip_suffix('.').unwrap_or(host).to_ascii_lowercase()
+}
+
+/// Hostname to endpoint slug, shared across requests via AppState.
+#[derive(Clone, Default)]
+pub struct DomainCache {
+    slugs: SlugCache<Option<String>>,
+}
+
+impl DomainCache {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// The slug of the endpoint `host` (normalized) is mapped to, reading
+    /// through to Postgres on a miss.
+    pub async fn get(&self, pool: &PgPool, host: &str) -> Option<String> {
+        self.slugs
+            .get_or_load(host, |_| async move {
+                let result: Result<Option<String>, sqlx::Error> =
+                    sqlx::query_scalar("SELECT get_custom_domain_slug($1)")
+                        .bind(host)
+                        .fetch_one(pool)
+                        .await;
+                match result {
+                    Ok(slug) => Some(slug),
+                    Err(e) => {
+                        tracing::error!(host, error = %e, "get_custom_domain_slug query failed");
+                        None
+                    }
+                }
+            })
+            .await
+            .flatten()
+    }
+}
+
+impl EndpointCache for DomainCache {
+    /// Re-read the hostname mapped to a slug, and every unmapped one, since
+    /// the change may have given the slug a new domain.
+    fn invalidate(&self, slug: &str) {
+        self.slugs
+            .invalidate_where(|mapped| mapped.as_deref().is_none_or(|s| s == slug));
+    }
+
+    fn clear(&self) {
+        self.slugs.clear();
+    }
+}
+
+/// A domain's certificate, ready to serve.
+#[derive(Clone)]
+struct Managed {
+    config: Arc<ServerConfig>,
+    not_after: DateTime<Utc>,
+}
+
+impl Managed {
+    fn due_for_renewal(&self) -> bool {
+        self.not_after - Utc::now() < RENEW_BEFORE
+    }
+}
+
+/// The row `get_custom_domain_certificate()` returns.
+#[derive(Deserialize)]
+struct StoredCertificate {
+    cert_pem: String,
+    key_pem: String,
+}
+
+/// Certificates for custom domains, obtained on first use.
+#[derive(Clone)]
+pub struct CertManager {
+    pool: PgPool,
+    acme: Arc<AcmeClient>,
+    challenges: Challenges,
+    certs: Arc<Mutex<HashMap<String, Managed>>>,
+    /// One order at a time per domain
+    orders: Arc<Mutex<HashMap<String, Arc<Mutex<()>>>>>,
+    failures: Arc<Mutex<HashMap<String, Instant>>>,
+}
+
+impl CertManager {
+    pub fn new(pool: PgPool, config: &CustomDomainConfig) -> Self {
+        Self {
+            acme: Arc::new(AcmeClient::new(
+                pool.clone(),
+                config.acme_directory_url.clone(),
+                config.acme_contact.clone(),
+            )),
+            pool,
+            challenges: Challenges::default(),
+            certs: Arc::default(),
+            orders: Arc::default(),
+            failures: Arc::default(),
+        }
+    }
+
+    /// TLS settings for a validation handshake, while `host` has a challenge
+    /// pending.
+    pub fn challenge_config(&self, host: &str) -> Option<Arc<ServerConfig>> {
+        self.challenges.get(host)
+    }
+
+    /// TLS settings for `host`, a custom domain. Loads the stored certificate
+    /// or orders one when there is none, so the first handshake for a new
+    /// domain waits for issuance. `None` when no certificate can be had.
+    pub async fn server_config(&self, host: &str) -> Option<Arc<ServerConfig>> {
+        let cached = self.certs.lock().await.get(host).cloned();
+        if let Some(managed) = cached {
+            if managed.due_for_renewal() {
+                self.renew(host);
+            }
+            return Some(managed.config);
+        }
+        // In a task of its own, so an order isn't abandoned halfway when the
+        // handshake waiting for it gives up.
+        let this = self.clone();
+        let host = host.to_string();
+        tokio::spawn(async move { this.obtain(&host, true).await })
+            .await
+            .ok()
+            .flatten()
+    }
+
+    /// Renew a certificate in the background unless it is already underway.
+    fn renew(&self, host: &str) {
+        let this = self.clone();
+        let host = host.to_string();
+        tokio::spawn(async move { this.obtain(&host, false).await });
+    }
+
+    /// Check cached certificates for renewal for the life of the process.
+    pub fn spawn_renewer(&self) {
+        let this = self.clone();
+        tokio::spawn(async move {
+            let mut interval = tokio::time::interval(RENEW_INTERVAL);
+            interval.tick().await;
+            loop {
+                interval.tick().await;
+                let due: Vec<String> = this
+                    .certs
+                    .lock()
+                    .await
+                    .iter()
+                    .filter(|(_, managed)| managed.due_for_renewal())
+                    .map(|(host, _)| host.clone())
+                    .collect();
+                for host in due {
+                    this.obtain(&host, false).await;
+                }
+            }
+        });
+    }
+
+    /// The stored certificate when it isn't due for renewal, or a new one
+    /// from the CA. `wait` queues behind an order already in progress for the
+    /// domain instead of giving up. Falls back to the current certificate
+    /// while it is still valid.
+    async fn obtain(&self, host: &str, wait: bool) -> Option<Arc<ServerConfig>> {
+        let lock = self.orders.lock().await.entry(host.to_string()).or_default().clone();
+        let _guard = if wait {
+            lock.lock().await
+        } else {
+            lock.try_lock().ok()?
+        };
+
+        let mut current = self.certs.lock().await.get(host).cloned();
+        if let Some(ref managed) = current
+            && !managed.due_for_renewal()
+        {
+            return Some(managed.config.clone());
+        }
+
+        // Another instance, or this one before a restart, may have renewed it
+        match self.load(host).await {
+            Ok(Some(stored)) if current.as_ref().is_none_or(|c| stored.not_after > c.not_after) => {
+                self.certs.lock().await.insert(host.to_string(), stored.clone());
+                if !stored.due_for_renewal() {
+                    return Some(stored.config);
+                }
+                current = Some(stored);
+            }
+            Ok(_) => {}
+            Err(e) => {
+                // Ordering now could replace a certificate we just can't see
+                tracing::error!(host, error = %e, "get_custom_domain_certificate query failed");
+                return current.map(|c| c.config);
+            }
+        }
+        let usable = current.filter(|c| c.not_after > Utc::now()).map(|c| c.config);
+
+        if let Some(failed_at) = self.failures.lock().await.get(host)
+            && failed_at.elapsed() < RETRY_AFTER
+        {
+            return usable;
+        }
+
+        tracing::info!(host, "ordering certificate");
+        let issued = match self.acme.issue(host, &self.challenges).await {
+            Ok(issued) => issued,
+            Err(e) => {
+                tracing::warn!(host, error = %e, "certificate order failed");
+                self.failures.lock().await.insert(host.to_string(), Instant::now());
+                return usable;
+            }
+        };
+        let managed = match managed(&issued.cert_pem, &issued.key_pem) {
+            Ok(managed) => managed,
+            Err(e) => {
+                tracing::warn!(host, error = %e, "issued certificate is unusable");
+                self.failures.lock().await.insert(host.to_string(), Instant::now());
+                return usable;
+            }
+        };
+        self.failures.lock().await.remove(host);
+        if let Err(e) = sqlx::query("SELECT save_custom_domain_certificate($1, $2, $3, $4)")
+            .bind(host)
+            .bind(&issued.cert_pem)
+            .bind(&issued.key_pem)
+            .bind(managed.not_after)
+            .execute(&self.pool)
+            .await
+        {
+            // Still served from memory; the next restart orders again
+            tracing::error!(host, error = %e, "save_custom_domain_certificate failed");
+        }
+        tracing::info!(host, not_after = %managed.not_after, "certificate issued");
+        self.certs.lock().await.insert(host.to_string(), managed.clone());
+        Some(managed.config)
+    }
+
+    async fn load(&self, host: &str) -> Result<Option<Managed>, sqlx::Error> {
+        let stored: Option<serde_json::Value> = sqlx::query_scalar("SELECT get_custom_domain_certificate($1)")
+            .bind(host)
+            .fetch_one(&self.pool)
+            .await?;
+        let Some(stored) = stored.and_then(|v| serde_json::from_value::<StoredCertificate>(v).ok()) else {
+            return Ok(None);
+        };
+        match managed(&stored.cert_pem, &stored.key_pem) {
+            Ok(managed) => Ok(Some(managed)),
+            Err(e) => {
+                tracing::warn!(host, error = %e, "stored certificate is unusable");
+                Ok(None)
+            }
+        }
+    }
+}
+
+/// TLS settings serving a PEM chain and key, with ALPN for both HTTP versions.
+fn managed(cert_pem: &str, key_pem: &str) -> Result<Managed, String> {
+    let chain = CertificateDer::pem_slice_iter(cert_pem.as_bytes())
+        .collect::<Result<Vec<_>, _>>()
+        .map_err(|e| format!("invalid certificate PEM: {e}"))?;
+    let not_after = chain
+        .first()
+        .and_then(|leaf| not_after(leaf))
+        .ok_or("no readable certificate")?;
+    let key = PrivateKeyDer::from_pem_slice(key_pem.as_bytes()).map_err(|e| format!("invalid key PEM: {e}"))?;
+    let mut config = ServerConfig::builder_with_provider(crypto_provider())
+        .with_safe_default_protocol_versions()
+        .map_err(|e| e.to_string())?
+        .with_no_client_auth()
+        .with_single_cert(chain, key)
+        .map_err(|e| e.to_string())?;
+    config.alpn_protocols = vec![b"h2".to_vec(), b"http/1.1".to_vec()];
+    Ok(Managed {
+        config: Arc::new(config),
+        not_after,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn hosts_are_normalized_like_stored_domains() {
+        assert_eq!(normalize_host("Hooks.Example.COM"), "hooks.example.com");
+        assert_eq!(normalize_host("hooks.example.com:8443"), "hooks.example.com");
+        assert_eq!(normalize_host("hooks.example.com."), "hooks.example.com");
+    }
+
+    #[tokio::test]
+    async fn invalidation_rereads_the_slug_and_unmapped_hosts() {
+        let cache = DomainCache::new();
+        let lookup = |host: &'static str, slug: Option<&'static str>| {
+            cache
+                .slugs
+                .get_or_load(host, move |_| async move { Some(slug.map(str::to_string)) })
+        };
+        for (host, slug) in [
+            ("a.example.com", Some("abc")),
+            ("b.example.com", Some("xyz")),
+            ("c.example.com", None),
+        ] {
+            lookup(host, slug).await;
+        }
+        cache.invalidate("abc");
+        let reread = Some(Some("new".to_string()));
+        assert_eq!(lookup("a.example.com", Some("new")).await, reread);
+        assert_eq!(
+            lookup("b.example.com", Some("new")).await,
+            Some(Some("xyz".to_string()))
+        );
+        assert_eq!(lookup("c.example.com", Some("new")).await, reread);
+    }
+
+    #[test]
+    fn unreadable_certificates_are_refused() {
+        let err = managed("not a certificate", "not a key").err().unwrap();
+        assert_eq!(err, "no readable certificate");
+    }
+}
@@ -0,0 +1,70 @@
+//! Expired endpoint sweeper.
+//!
+//! `capture_webhook` refuses requests to expired endpoints with 410
+//! `expired`, but the receiver's per-slug state (cached configs, pending
+//! auto-extend activity) would otherwise linger until it ages out. Every
+//! [`SWEEP_INTERVAL`] this task asks `expired_endpoint_slugs` for the
+//! endpoints whose expiry passed since the last sweep and drops their state,
+//! so nothing is served or reported for them afterwards. Windows overlap by
+//! [`CLOCK_MARGIN`] so small clock differences with Postgres don't skip an
+//! endpoint; evicting one twice is harmless.
+
+use chrono::{DateTime, Utc};
+use std::time::Duration;
+
+use crate::AppState;
+
+/// How often expired endpoints are swept.
+const SWEEP_INTERVAL: Duration = Duration::from_secs(30);
+
+/// Overlap between consecutive sweep windows.
+const CLOCK_MARGIN: chrono::Duration = chrono::Duration::seconds(5);
+
+/// Sweep for the life of the process.
+pub fn spawn_sweeper(state: AppState) {
+    tokio::spawn(async move {
+        let mut since = Utc::now() - chrono::Duration::from_std(SWEEP_INTERVAL).unwrap_or_default();
+        let mut interval = tokio::time::interval(SWEEP_INTERVAL);
+        interval.set_missed_tick_behavior(tokio::time::MissedTickBehavior::Delay);
+        loop {
+            interval.tick().await;
+            let until = Utc::now();
+            match sweep(&state, since, until).await {
+                Ok(evicted) => {
+                    if evicted > 0 {
+                        tracing::debug!(evicted, "evicted expired endpoints");
+                    }
+                    since = until - CLOCK_MARGIN;
+                }
+                // Keep the window open so the next sweep covers it
+                Err(e) => tracing::warn!(error = %e, "expired endpoint sweep failed, will retry"),
+            }
+        }
+    });
+}
+
+/// Evict every endpoint that expired in `(since, until]`.
+async fn sweep(
+    state: &AppState,
+    since: DateTime<Utc>,
+    until: DateTime<Utc>,
+) -> Result<usize, sqlx::Error> {
+    let slugs: Vec<String> = sqlx::query_scalar("SELECT expired_endpoint_slugs($1, $2)")
+        .bind(since)
+        .bind(until)
+        .fetch_all(&state.pool)
+        .await?;
+    for slug in &slugs {
+        evict(state, slug);
+    }
+    Ok(slugs.len())
+}
+
+/// Drop the receiver's state for an expired endpoint.
+fn evict(state: &AppState, slug: &str) {
+    state.caches.invalidate(slug);
+    state.sequences.forget(slug);
+    state.scripts.forget(slug);
+    // Activity buffered for the endpoint can't extend it any more
+    state.activity.forget(slug);
+}
@@ -0,0 +1,170 @@
+//! Request fingerprints, for grouping captures of the same kind of webhook.
+//!
+//! Two requests share a fingerprint when they have the same method, the same
+//! path once ID-like segments are replaced with `:id`, and a body of the same
+//! shape: the same JSON keys with the same value types, the same form fields,
+//! or the same media type for anything else. Values don't matter, so a
+//! provider's invoice events land in one group however much the amounts and
+//! IDs vary, while the customer events it sends to the same URL, whose
+//! payloads carry different fields, land in another.
+
+use serde_json::Value;
+use std::collections::BTreeSet;
+
+/// Hex characters kept from the SHA-256; plenty to keep one endpoint's
+/// groups apart.
+const FINGERPRINT_LEN: usize = 16;
+
+/// Nesting deeper than this is summarized as `…`.
+const MAX_DEPTH: usize = 8;
+
+/// Segments this long made of letters and digits (both) are treated as IDs.
+const MIN_TOKEN_LEN: usize = 16;
+
+/// Fingerprint of a request. `body` is `None` when it isn't available as
+/// text (raw bytes or offloaded), in which case only the media type counts.
+pub fn fingerprint(method: &str, path: &str, content_type: &str, body: Option<&str>) -> String {
+    use sha2::{Digest, Sha256};
+
+    let key = format!("{} {}\n{}", method.to_ascii_uppercase(), normalize_path(path), body_shape(content_type, body));
+    let mut hash = hex::encode(Sha256::digest(key.as_bytes()));
+    hash.truncate(FINGERPRINT_LEN);
+    hash
+}
+
+/// The path with ID-like segments replaced by `:id`.
+pub fn normalize_path(path: &str) -> String {
+    path.split('/')
+        .map(|segment| if is_id(segment) { ":id" } else { segment })
+        .collect::<Vec<_>>()
+        .join("/")
+}
+
+/// Numbers, UUIDs, hex strings of 8+ characters with a digit, and long
+/// tokens mixing letters and digits.
+fn is_id(segment: &str) -> bool {
+    if segment.is_empty() {
+        return false;
+    }
+    let has_digit = segment.bytes().any(|b| b.is_ascii_digit());
+    if segment.bytes().all(|b| b.is_ascii_digit()) || is_uuid(segment) {
+        return true;
+    }
+    if segment.len() >= 8 && has_digit && segment.bytes().all(|b| b.is_ascii_hexdigit()) {
+        return true;
+    }
+    segment.len() >= MIN_TOKEN_LEN
+        && has_digit
+        && segment.bytes().any(|b| b.is_ascii_alphabetic())
+        && segment.bytes().all(|b| b.is_ascii_alphanumeric() || b == b'_' || b == b'-')
+}
+
+fn is_uuid(segment: &str) -> bool {
+    segment.len() == 36
+        && segment.bytes().enumerate().all(|(i, b)| match i {
+            8 | 13 | 18 | 23 => b == b'-',
+            _ => b.is_ascii_hexdigit(),
+        })
+}
+
+fn body_shape(content_type: &str, body: Option<&str>) -> String {
+    let media_type = content_type
+        .split(';')
+        .next()
+        .unwrap_or("")
+        .trim()
+        .to_ascii_lowercase();
+    let Some(body) = body else {
+        return media_type;
+    };
+    if body.trim().is_empty() {
+        return "empty".to_string();
+    }
+    if let Ok(value) = serde_json::from_str::<Value>(body) {
+        return json_shape(&value, 0);
+    }
+    if media_type == "application/x-www-form-urlencoded" {
+        let fields: BTreeSet<_> = url::form_urlencoded::parse(body.as_bytes()).map(|(k, _)| k).collect();
+        return format!("form:{}", fields.into_iter().collect::<Vec<_>>().join(","));
+    }
+    media_type
+}
+
+/// Keys (sorted) and value types. Arrays list the distinct shapes of their
+/// elements, so a list of 1 or 100 items of one kind reads the same.
+fn json_shape(value: &Value, depth: usize) -> String {
+    if depth >= MAX_DEPTH {
+        return "…".to_string();
+    }
+    match value {
+        Value::Null => "z".to_string(),
+        Value::Bool(_) => "b".to_string(),
+        Value::Number(_) => "n".to_string(),
+        Value::String(_) => "s".to_string(),
+        Value::Array(items) => {
+            let shapes: BTreeSet<String> = items.iter().map(|item| json_shape(item, depth + 1)).collect();
+            format!("[{}]", shapes.into_iter().collect::<Vec<_>>().join("|"))
+        }
+        Value::Object(map) => {
+            let mut fields: Vec<String> = map
+                .iter()
+                .map(|(key, value)| format!("{key:?}:{}", json_shape(value, depth + 1)))
+                .collect();
+            fields.sort();
+            format!("{{{}}}", fields.join(","))
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn id_segments_are_normalized() {
+        assert_eq!(normalize_path("/orders/1234/items"), "/orders/:id/items");
+        assert_eq!(
+            normalize_path("/hooks/550e8400-e29b-41d4-a716-446655440000"),
+            "/hooks/:id"
+        );
+        assert_eq!(normalize_path("/commits/72d3162e"), "/commits/:id");
+        assert_eq!(normalize_path("/events/evt_1NqZ3eLkdIwHu7ix"), "/events/:id");
+        // Words, versions and short tokens stay
+        assert_eq!(normalize_path("/v2/stripe/webhooks"), "/v2/stripe/webhooks");
+        assert_eq!(normalize_path("/facade/deadbeef"), "/facade/deadbeef");
+        assert_eq!(normalize_path("/"), "/");
+    }
+
+    #[test]
+    fn values_do_not_change_the_fingerprint() {
+        let a = fingerprint("POST", "/orders/1", "application/json", Some(r#"{"id": 1, "tags": ["a"], "ok": true}"#));
+        let b = fingerprint("post", "/orders/2", "application/json; charset=utf-8", Some(r#"{"ok": false, "tags": ["b", "c"], "id": 7}"#));
+        assert_eq!(a, b);
+        assert_eq!(a.len(), FINGERPRINT_LEN);
+    }
+
+    #[test]
+    fn shape_method_and_path_change_it() {
+        let base = fingerprint("POST", "/hook", "application/json", Some(r#"{"type": "a"}"#));
+        assert_ne!(base, fingerprint("POST", "/hook", "application/json", Some(r#"{"type": 1}"#)));
+        assert_ne!(base, fingerprint("POST", "/hook", "application/json", Some(r#"{"kind": "a"}"#)));
+        assert_ne!(base, fingerprint("PUT", "/hook", "application/json", Some(r#"{"type": "a"}"#)));
+        assert_ne!(base, fingerprint("POST", "/other", "application/json", Some(r#"{"type": "a"}"#)));
+    }
+
+    #[test]
+    fn non_json_bodies() {
+        assert_eq!(body_shape("application/x-www-form-urlencoded", Some("b=1&a=2&b=3")), "form:a,b");
+        assert_eq!(body_shape("text/plain; charset=utf-8", Some("hello")), "text/plain");
+        assert_eq!(body_shape("application/octet-stream", None), "application/octet-stream");
+        assert_eq!(body_shape("application/json", Some("")), "empty");
+    }
+
+    #[test]
+    fn array_elements_are_merged() {
+        let one = json_shape(&serde_json::json!([{"a": 1}]), 0);
+        let many = json_shape(&serde_json::json!([{"a": 1}, {"a": 2}, {"a": 3}]), 0);
+        assert_eq!(one, many);
+        assert_eq!(json_shape(&serde_json::json!([1, "x", 2]), 0), "[n|s]");
+    }
+}
@@ -0,0 +1,567 @@
+//! Function sinks: invoke a serverless function with each captured request.
+//!
+//! Currently supports AWS Lambda via the Invoke API with `Event` (async)
+//! invocation, signed with SigV4 using the receiver's own credentials. The
+//! endpoint owner grants those credentials `lambda:InvokeFunction` through a
+//! resource policy on their function.
+//!
+//! Invocations are fire-and-forget from the request path. A global semaphore
+//! bounds in-flight invocations; when it is exhausted the payload is shed to
+//! the dead-letter table instead of queueing unboundedly. Retryable failures
+//! (throttling, 5xx, network) are retried with backoff before dead-lettering.
+//! Deliveries that reach the destination are timed (see `sink_latency`).
+
+use chrono::{DateTime, Utc};
+use hmac::{Hmac, Mac};
+use serde::Deserialize;
+use sha2::{Digest, Sha256};
+use sqlx::PgPool;
+use std::sync::Arc;
+use std::time::Duration;
+use tokio::sync::Semaphore;
+
+use crate::lifecycle::{Event, Lifecycle};
+use crate::sink_latency::{Delivery, DeliveryTimings, millis};
+use crate::tenant::TenantLimiter;
+
+/// Maximum invocation attempts before a payload is dead-lettered.
+const MAX_ATTEMPTS: u32 = 3;
+
+/// Base backoff between attempts (doubled each retry).
+const RETRY_BACKOFF: Duration = Duration::from_millis(200);
+
+/// Per-attempt timeout. Event invocations return as soon as Lambda queues them.
+const INVOKE_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Lambda's limit for asynchronous invocation payloads.
+const MAX_PAYLOAD_BYTES: usize = 256 * 1024;
+
+/// Per-endpoint sink configuration, as stored in `endpoints.function_sink`.
+#[derive(Debug, Clone, Deserialize)]
+#[serde(tag = "provider", rename_all = "snake_case")]
+pub enum FunctionSink {
+    AwsLambda { arn: String },
+}
+
+impl FunctionSink {
+    fn target(&self) -> &str {
+        match self {
+            FunctionSink::AwsLambda { arn } => arn,
+        }
+    }
+}
+
+/// AWS credentials used to sign Lambda invocations.
+#[derive(Clone)]
+pub struct AwsCredentials {
+    pub access_key_id: String,
+    pub secret_access_key: String,
+    pub session_token: Option<String>,
+}
+
+impl std::fmt::Debug for AwsCredentials {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.debug_struct("AwsCredentials")
+            .field("access_key_id", &self.access_key_id)
+            .field("secret_access_key", &"[REDACTED]")
+            .field("session_token", &self.session_token.as_ref().map(|_| "[REDACTED]"))
+            .finish()
+    }
+}
+
+/// Shared dispatcher stored in AppState. Cheap to clone.
+#[derive(Clone)]
+pub struct FunctionSinkDispatcher {
+    http: reqwest::Client,
+    pool: PgPool,
+    credentials: Arc<AwsCredentials>,
+    permits: Arc<Semaphore>,
+    timings: DeliveryTimings,
+    tenants: TenantLimiter,
+    lifecycle: Lifecycle,
+}
+
+impl FunctionSinkDispatcher {
+    pub fn new(
+        pool: PgPool,
+        credentials: AwsCredentials,
+        concurrency: usize,
+        timings: DeliveryTimings,
+        tenants: TenantLimiter,
+        lifecycle: Lifecycle,
+    ) -> Self {
+        let http = reqwest::Client::builder()
+            .timeout(INVOKE_TIMEOUT)
+            .redirect(reqwest::redirect::Policy::none())
+            .build()
+            .expect("failed to build function sink HTTP client");
+        Self {
+            http,
+            pool,
+            credentials: Arc::new(credentials),
+            permits: Arc::new(Semaphore::new(concurrency.max(1))),
+            timings,
+            tenants,
+            lifecycle,
+        }
+    }
+
+    /// Invoke the sink in the background. Never blocks the capture response.
+    /// Deliveries count against `tenant`'s sink limit when it is known.
+    pub fn dispatch(
+        &self,
+        slug: String,
+        tenant: Option<Arc<str>>,
+        sink: FunctionSink,
+        payload: serde_json::Value,
+        received_at: DateTime<Utc>,
+    ) {
+        let dispatcher = self.clone();
+        tokio::spawn(async move {
+            let Ok(_permit) = dispatcher.permits.clone().try_acquire_owned() else {
+                tracing::warn!(slug, "function sink concurrency limit reached, dead-lettering");
+                dispatcher
+                    .dead_letter(&slug, &sink, &payload, "concurrency_limit", 0)
+                    .await;
+                return;
+            };
+            let _tenant_permit = match tenant {
+                Some(tenant) => match dispatcher.tenants.start_sink_delivery(tenant) {
+                    Some(permit) => Some(permit),
+                    None => {
+                        tracing::warn!(slug, "account function sink limit reached, dead-lettering");
+                        dispatcher
+                            .dead_letter(&slug, &sink, &payload, "tenant_limit", 0)
+                            .await;
+                        return;
+                    }
+                },
+                None => None,
+            };
+
+            let body = match serde_json::to_vec(&payload) {
+                Ok(b) if b.len() <= MAX_PAYLOAD_BYTES => b,
+                Ok(_) => {
+                    dispatcher
+                        .dead_letter(&slug, &sink, &payload, "payload_too_large", 0)
+                        .await;
+                    return;
+                }
+                Err(_) => return,
+            };
+
+            let mut attempt = 0;
+            loop {
+                attempt += 1;
+                let sent_at = Utc::now();
+                let result = dispatcher.invoke(&sink, &body).await;
+                let timing = Delivery {
+                    slug: slug.clone(),
+                    target: sink.target().to_string(),
+                    received_at,
+                    relay_ms: millis(sent_at - received_at),
+                    destination_ms: millis(Utc::now() - sent_at),
+                    attempts: attempt as i32,
+                    delivered: result.is_ok(),
+                };
+                match result {
+                    Ok(()) => {
+                        dispatcher.timings.record(timing);
+                        return;
+                    }
+                    Err(InvokeError { reason, retryable }) => {
+                        if !retryable || attempt >= MAX_ATTEMPTS {
+                            dispatcher.timings.record(timing);
+                            tracing::warn!(slug, attempt, reason, "function sink invocation failed");
+                            dispatcher
+                                .dead_letter(&slug, &sink, &payload, &reason, attempt)
+                                .await;
+                            return;
+                        }
+                        tokio::time::sleep(RETRY_BACKOFF * 2u32.pow(attempt - 1)).await;
+                    }
+                }
+            }
+        });
+    }
+
+    async fn invoke(&self, sink: &FunctionSink, body: &[u8]) -> Result<(), InvokeError> {
+        match sink {
+            FunctionSink::AwsLambda { arn } => self.invoke_lambda(arn, body).await,
+        }
+    }
+
+    async fn invoke_lambda(&self, arn: &str, body: &[u8]) -> Result<(), InvokeError> {
+        let region = lambda_region(arn).ok_or(InvokeError::fatal("invalid_lambda_arn"))?;
+        let host = format!("lambda.{region}.amazonaws.com");
+        let path = format!("/2015-03-31/functions/{}/invocations", uri_encode(arn));
+        let amz_date = Utc::now().format("%Y%m%dT%H%M%SZ").to_string();
+
+        let mut headers = vec![
+            ("host".to_string(), host.clone()),
+            ("x-amz-date".to_string(), amz_date.clone()),
+            ("x-amz-invocation-type".to_string(), "Event".to_string()),
+        ];
+        if let Some(ref token) = self.credentials.session_token {
+            headers.push(("x-amz-security-token".to_string(), token.clone()));
+        }
+
+        // Non-S3 services sign the path URI-encoded a second time.
+        let authorization = sign_v4(
+            &self.credentials,
+            &SigningInput {
+                method: "POST",
+                canonical_uri: &uri_encode_path(&path),
+                query: "",
+                headers: &headers,
+                payload: body,
+                region,
+                service: "lambda",
+                amz_date: &amz_date,
+            },
+        );
+
+        let mut req = self
+            .http
+            .post(format!("https://{host}{path}"))
+            .header("authorization", authorization)
+            .header("content-type", "application/json")
+            .body(body.to_vec());
+        for (name, value) in &headers {
+            if name != "host" {
+                req = req.header(name.as_str(), value.as_str());
+            }
+        }
+
+        let resp = req
+            .send()
+            .await
+            .map_err(|_| InvokeError::retryable("request_failed"))?;
+        let status = resp.status();
+        if status.is_success() {
+            return Ok(());
+        }
+
+        let reason = format!("lambda_http_{}", status.as_u16());
+        if status.as_u16() == 429 || status.is_server_error() {
+            Err(InvokeError { reason, retryable: true })
+        } else {
+            Err(InvokeError { reason, retryable: false })
+        }
+    }
+
+    async fn dead_letter(
+        &self,
+        slug: &str,
+        sink: &FunctionSink,
+        payload: &serde_json::Value,
+        error: &str,
+        attempts: u32,
+    ) {
+        let result = sqlx::query("SELECT record_function_sink_failure($1, $2, $3, $4, $5)")
+            .bind(slug)
+            .bind(sink.target())
+            .bind(payload)
+            .bind(error)
+            .bind(attempts as i32)
+            .execute(&self.pool)
+            .await;
+        match result {
+            Ok(_) => self
+                .lifecycle
+                .emit(Event::DeadLettered, serde_json::json!({ "slug": slug, "reason": error })),
+            Err(e) => tracing::error!(slug, error = %e, "failed to record function sink dead letter"),
+        }
+    }
+}
+
+/// Check the credentials with STS GetCallerIdentity, which any valid key may
+/// call. Returns the caller's ARN, or a description of why AWS refused.
+pub async fn verify_credentials(credentials: &AwsCredentials) -> Result<String, String> {
+    const HOST: &str = "sts.amazonaws.com";
+    const BODY: &[u8] = b"Action=GetCallerIdentity&Version=2011-06-15";
+
+    let amz_date = Utc::now().format("%Y%m%dT%H%M%SZ").to_string();
+    let mut headers = vec![
+        (
+            "content-type".to_string(),
+            "application/x-www-form-urlencoded".to_string(),
+        ),
+        ("host".to_string(), HOST.to_string()),
+        ("x-amz-date".to_string(), amz_date.clone()),
+    ];
+    if let Some(ref token) = credentials.session_token {
+        headers.push(("x-amz-security-token".to_string(), token.clone()));
+    }
+    let authorization = sign_v4(
+        credentials,
+        &SigningInput {
+            method: "POST",
+            canonical_uri: "/",
+            query: "",
+            headers: &headers,
+            payload: BODY,
+            region: "us-east-1",
+            service: "sts",
+            amz_date: &amz_date,
+        },
+    );
+
+    let http = reqwest::Client::builder()
+        .timeout(INVOKE_TIMEOUT)
+        .build()
+        .map_err(|e| e.to_string())?;
+    let mut req = http
+        .post(format!("https://{HOST}/"))
+        .header("authorization", authorization)
+        .body(BODY.to_vec());
+    for (name, value) in &headers {
+        if name != "host" {
+            req = req.header(name.as_str(), value.as_str());
+        }
+    }
+
+    let resp = req.send().await.map_err(|e| format!("could not reach AWS STS: {e}"))?;
+    let status = resp.status();
+    let body = resp.text().await.unwrap_or_default();
+    if status.is_success() {
+        return Ok(xml_tag(&body, "Arn").unwrap_or("unknown caller").to_string());
+    }
+    match xml_tag(&body, "Code") {
+        Some(code) => Err(format!("AWS rejected the credentials: {code} (HTTP {status})")),
+        None => Err(format!("AWS rejected the credentials (HTTP {status})")),
+    }
+}
+
+/// Text of the first `<tag>…</tag>` in an STS response.
+fn xml_tag<'a>(body: &'a str, tag: &str) -> Option<&'a str> {
+    let open = format!("<{tag}>");
+    let start = body.find(&open)? + open.len();
+    let len = body[start..].find(&format!("</{tag}>"))?;
+    Some(body[start..start + len].trim())
+}
+
+struct InvokeError {
+    reason: String,
+    retryable: bool,
+}
+
+impl InvokeError {
+    fn fatal(reason: &str) -> Self {
+        Self { reason: reason.to_string(), retryable: false }
+    }
+
+    fn retryable(reason: &str) -> Self {
+        Self { reason: reason.to_string(), retryable: true }
+    }
+}
+
+/// Extract the region from `arn:aws:lambda:<region>:<account>:function:<name>[:<qualifier>]`.
+pub fn lambda_region(arn: &str) -> Option<&str> {
+    let parts: Vec<&str> = arn.split(':').collect();
+    let ["arn", _partition, "lambda", region, account, "function", name, rest @ ..] = parts.as_slice()
+    else {
+        return None;
+    };
+    let valid_region = !region.is_empty()
+        && region.bytes().all(|b| b.is_ascii_lowercase() || b.is_ascii_digit() || b == b'-');
+    let valid_account = account.len() == 12 && account.bytes().all(|b| b.is_ascii_digit());
+    if !valid_region || !valid_account || name.is_empty() || rest.len() > 1 {
+        return None;
+    }
+    Some(region)
+}
+
+/// RFC 3986 percent-encoding of everything except unreserved characters.
+fn uri_encode(s: &str) -> String {
+    let mut out = String::with_capacity(s.len());
+    for b in s.bytes() {
+        if b.is_ascii_alphanumeric() || matches!(b, b'-' | b'_' | b'.' | b'~') {
+            out.push(b as char);
+        } else {
+            out.push_str(&format!("%{b:02X}"));
+        }
+    }
+    out
+}
+
+/// Encode each path segment, keeping the slashes.
+pub(crate) fn uri_encode_path(path: &str) -> String {
+    path.split('/').map(uri_encode).collect::<Vec<_>>().join("/")
+}
+
+pub(crate) struct SigningInput<'a> {
+    pub method: &'a str,
+    pub canonical_uri: &'a str,
+    pub query: &'a str,
+    /// Lowercase header names and trimmed values. Every header listed is signed.
+    pub headers: &'a [(String, String)],
+    pub payload: &'a [u8],
+    pub region: &'a str,
+    pub service: &'a str,
+    pub amz_date: &'a str,
+}
+
+fn hmac_sha256(key: &[u8], data: &[u8]) -> Vec<u8> {
+    let mut mac = Hmac::<Sha256>::new_from_slice(key).expect("HMAC accepts any key length");
+    mac.update(data);
+    mac.finalize().into_bytes().to_vec()
+}
+
+fn signing_key(secret: &str, date: &str, region: &str, service: &str) -> Vec<u8> {
+    let k_date = hmac_sha256(format!("AWS4{secret}").as_bytes(), date.as_bytes());
+    let k_region = hmac_sha256(&k_date, region.as_bytes());
+    let k_service = hmac_sha256(&k_region, service.as_bytes());
+    hmac_sha256(&k_service, b"aws4_request")
+}
+
+/// Compute the SigV4 `Authorization` header value.
+pub(crate) fn sign_v4(credentials: &AwsCredentials, input: &SigningInput<'_>) -> String {
+    let mut headers: Vec<_> = input.headers.iter().collect();
+    headers.sort_by(|a, b| a.0.cmp(&b.0));
+
+    let canonical_headers: String = headers
+        .iter()
+        .map(|(k, v)| format!("{k}:{}\n", v.trim()))
+        .collect();
+    let signed_headers = headers
+        .iter()
+        .map(|(k, _)| k.as_str())
+        .collect::<Vec<_>>()
+        .join(";");
+
+    let canonical_request = format!(
+        "{}\n{}\n{}\n{}\n{}\n{}",
+        input.method,
+        input.canonical_uri,
+        input.query,
+        canonical_headers,
+        signed_headers,
+        hex::encode(Sha256::digest(input.payload)),
+    );
+
+    let date = &input.amz_date[..8];
+    let scope = format!("{date}/{}/{}/aws4_request", input.region, input.service);
+    let string_to_sign = format!(
+        "AWS4-HMAC-SHA256\n{}\n{scope}\n{}",
+        input.amz_date,
+        hex::encode(Sha256::digest(canonical_request.as_bytes())),
+    );
+
+    let key = signing_key(&credentials.secret_access_key, date, input.region, input.service);
+    let signature = hex::encode(hmac_sha256(&key, string_to_sign.as_bytes()));
+
+    format!(
+        "AWS4-HMAC-SHA256 Credential={}/{scope}, SignedHeaders={signed_headers}, Signature={signature}",
+        credentials.access_key_id
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const EXAMPLE_SECRET: &str = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY";
+
+    #[test]
+    fn signing_key_matches_aws_example() {
+        // From the AWS SigV4 documentation ("Examples of how to derive a signing key").
+        let key = signing_key(EXAMPLE_SECRET, "20120215", "us-east-1", "iam");
+        assert_eq!(
+            hex::encode(key),
+            "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+        );
+    }
+
+    #[test]
+    fn sign_v4_matches_aws_test_suite_get_vanilla() {
+        let credentials = AwsCredentials {
+            access_key_id: "AKIDEXAMPLE".into(),
+            secret_access_key: EXAMPLE_SECRET.into(),
+            session_token: None,
+        };
+        let headers = vec![
+            ("host".to_string(), "example.amazonaws.com".to_string()),
+            ("x-amz-date".to_string(), "20150830T123600Z".to_string()),
+        ];
+        let auth = sign_v4(
+            &credentials,
+            &SigningInput {
+                method: "GET",
+                canonical_uri: "/",
+                query: "",
+                headers: &headers,
+                payload: b"",
+                region: "us-east-1",
+                service: "service",
+                amz_date: "20150830T123600Z",
+            },
+        );
+        assert_eq!(
+            auth,
+            "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, \
+             SignedHeaders=host;x-amz-date, \
+             Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+        );
+    }
+
+    #[test]
+    fn lambda_region_parsing() {
+        assert_eq!(
+            lambda_region("arn:aws:lambda:eu-west-1:123456789012:function:my-fn"),
+            Some("eu-west-1")
+        );
+        assert_eq!(
+            lambda_region("arn:aws:lambda:us-east-1:123456789012:function:my-fn:prod"),
+            Some("us-east-1")
+        );
+        assert_eq!(lambda_region("arn:aws:s3:::bucket"), None);
+        assert_eq!(lambda_region("arn:aws:lambda:us-east-1:123:function:my-fn"), None);
+        assert_eq!(lambda_region("arn:aws:lambda:evil.com/x:123456789012:function:f"), None);
+    }
+
+    #[test]
+    fn path_is_double_encoded_for_signing() {
+        let path = format!(
+            "/2015-03-31/functions/{}/invocations",
+            uri_encode("arn:aws:lambda:us-east-1:123456789012:function:f")
+        );
+        assert!(path.contains("arn%3Aaws%3Alambda"));
+        assert!(uri_encode_path(&path).contains("arn%253Aaws%253Alambda"));
+        assert!(uri_encode_path(&path).starts_with("/2015-03-31/functions/"));
+    }
+
+    #[test]
+    fn function_sink_deserializes() {
+        let sink: FunctionSink = serde_json::from_value(serde_json::json!({
+            "provider": "aws_lambda",
+            "arn": "arn:aws:lambda:us-east-1:123456789012:function:f"
+        }))
+        .unwrap();
+        assert_eq!(sink.target(), "arn:aws:lambda:us-east-1:123456789012:function:f");
+
+        let unknown = serde_json::from_value::<FunctionSink>(serde_json::json!({"provider": "gcp"}));
+        assert!(unknown.is_err());
+    }
+
+    #[test]
+    fn xml_tag_reads_sts_responses() {
+        let ok = "<GetCallerIdentityResult>\n  <Arn>arn:aws:iam::123456789012:user/receiver</Arn>\n</GetCallerIdentityResult>";
+        assert_eq!(xml_tag(ok, "Arn"), Some("arn:aws:iam::123456789012:user/receiver"));
+        let err = "<ErrorResponse><Error><Code>InvalidClientTokenId</Code></Error></ErrorResponse>";
+        assert_eq!(xml_tag(err, "Code"), Some("InvalidClientTokenId"));
+        assert_eq!(xml_tag(err, "Arn"), None);
+    }
+
+    #[test]
+    fn credentials_debug_redacts() {
+        let creds = AwsCredentials {
+            access_key_id: "AKID".into(),
+            secret_access_key: "super-secret".into(),
+            session_token: Some("tok".into()),
+        };
+        let debug = format!("{creds:?}");
+        assert!(!debug.contains("super-secret"));
+        assert!(!debug.contains("\"tok\""));
+    }
+}
@@ -0,0 +1,390 @@
+//! Country and network (ASN) of each sender, from MaxMind-format databases.
+//!
+//! `GEOIP_DB_PATH` points at a country or city database (GeoLite2-Country,
+//! GeoIP2-City, DB-IP's free country lite, ...) and `GEOIP_ASN_DB_PATH` at an
+//! ASN database (GeoLite2-ASN); either may be left out. Files are read into
+//! memory once at startup, so a refreshed database needs a restart, and one
+//! that can't be read is logged and skipped.
+//!
+//! Cloudflare's `cf-ipcountry` still wins over the database for the country,
+//! since Cloudflare sees the connecting address. The country feeds network
+//! policies and is stored as `requests.country`; the ASN and its organization
+//! are stored for HTTP captures as `requests.asn` and `requests.as_org`.
+//!
+//! Only the parts of the MaxMind DB format needed for lookups are read: the
+//! metadata, the binary search tree (24, 28 and 32-bit records) and the data
+//! section types these databases use.
+
+use serde_json::Value;
+use std::net::IpAddr;
+
+/// Marks the start of the metadata section, near the end of the file.
+const METADATA_MARKER: &[u8] = b"\xab\xcd\xefMaxMind.com";
+
+/// Zero bytes between the search tree and the data section.
+const DATA_SECTION_SEPARATOR: usize = 16;
+
+/// Nesting allowed while decoding, so a corrupt file can't recurse forever.
+const MAX_DEPTH: usize = 32;
+
+/// What the databases know about one address.
+#[derive(Debug, Default, Clone, PartialEq, Eq)]
+pub struct Geo {
+    /// ISO 3166-1 alpha-2 code
+    pub country: Option<String>,
+    pub asn: Option<u32>,
+    /// Organization announcing the ASN
+    pub as_org: Option<String>,
+}
+
+/// The configured databases, stored in AppState.
+pub struct GeoIp {
+    country: Option<Reader>,
+    asn: Option<Reader>,
+}
+
+impl GeoIp {
+    /// Open the configured databases; `None` when neither is configured or
+    /// readable.
+    pub fn open(country_path: Option<&str>, asn_path: Option<&str>) -> Option<Self> {
+        let load = |path: Option<&str>| {
+            let path = path?;
+            match Reader::open(path) {
+                Ok(reader) => {
+                    tracing::info!(path, database = %reader.database_type, "geoip database loaded");
+                    Some(reader)
+                }
+                Err(e) => {
+                    tracing::warn!(path, error = %e, "failed to load geoip database, skipping");
+                    None
+                }
+            }
+        };
+        let geoip = Self {
+            country: load(country_path),
+            asn: load(asn_path),
+        };
+        (geoip.country.is_some() || geoip.asn.is_some()).then_some(geoip)
+    }
+
+    /// Look up `ip` (as returned by `real_ip`); empty for unparseable or
+    /// unknown addresses.
+    pub fn lookup(&self, ip: &str) -> Geo {
+        let Ok(addr) = ip.parse::<IpAddr>() else {
+            return Geo::default();
+        };
+        let mut geo = Geo::default();
+        if let Some(record) = self.country.as_ref().and_then(|db| db.lookup(addr)) {
+            // Addresses without a located country (anycast, some clouds) still
+            // have the country they are registered in.
+            geo.country = ["country", "registered_country"]
+                .iter()
+                .find_map(|key| record[*key]["iso_code"].as_str())
+                .filter(|code| code.len() == 2)
+                .map(str::to_ascii_uppercase);
+        }
+        if let Some(record) = self.asn.as_ref().and_then(|db| db.lookup(addr)) {
+            geo.asn = record["autonomous_system_number"]
+                .as_u64()
+                .and_then(|n| u32::try_from(n).ok());
+            geo.as_org = record["autonomous_system_organization"]
+                .as_str()
+                .map(str::to_string);
+        }
+        geo
+    }
+}
+
+/// One MaxMind DB file held in memory.
+struct Reader {
+    buf: Vec<u8>,
+    node_count: usize,
+    record_size: usize,
+    ip_version: u64,
+    database_type: String,
+    /// Node where IPv4 addresses start in an IPv6 tree (`::/96`)
+    ipv4_start: usize,
+}
+
+/// The database type of the file at `path` (`GeoLite2-Country`), or why it
+/// can't be used. For `--validate`.
+pub fn database_type(path: &str) -> Result<String, String> {
+    Reader::open(path).map(|reader| reader.database_type)
+}
+
+impl Reader {
+    fn open(path: &str) -> Result<Self, String> {
+        std::fs::read(path)
+            .map_err(|e| e.to_string())
+            .and_then(Reader::from_bytes)
+    }
+
+    fn from_bytes(buf: Vec<u8>) -> Result<Self, String> {
+        let start = buf
+            .windows(METADATA_MARKER.len())
+            .rposition(|w| w == METADATA_MARKER)
+            .ok_or("not a MaxMind DB file (no metadata)")?
+            + METADATA_MARKER.len();
+        let metadata = Decoder { buf: &buf[start..] }.decode(0, 0)?.0;
+        let field = |name: &str| {
+            metadata[name]
+                .as_u64()
+                .ok_or_else(|| format!("metadata is missing {name}"))
+        };
+        let node_count = field("node_count")? as usize;
+        let record_size = field("record_size")? as usize;
+        let ip_version = field("ip_version")?;
+        if ![24, 28, 32].contains(&record_size) {
+            return Err(format!("unsupported record size {record_size}"));
+        }
+        if node_count * record_size / 4 + DATA_SECTION_SEPARATOR > start {
+            return Err("search tree is larger than the file".into());
+        }
+        let mut reader = Self {
+            buf,
+            node_count,
+            record_size,
+            ip_version,
+            database_type: metadata["database_type"].as_str().unwrap_or("unknown").to_string(),
+            ipv4_start: 0,
+        };
+        if ip_version == 6 {
+            let mut node = 0;
+            for _ in 0..96 {
+                if node >= node_count {
+                    break;
+                }
+                node = reader.record(node, 0);
+            }
+            reader.ipv4_start = node;
+        }
+        Ok(reader)
+    }
+
+    /// The data recorded for the network containing `addr`.
+    fn lookup(&self, addr: IpAddr) -> Option<Value> {
+        let (bits, mut node): (Vec<u8>, usize) = match addr {
+            IpAddr::V4(v4) => (v4.octets().to_vec(), self.ipv4_start),
+            IpAddr::V6(v6) if self.ip_version == 6 => (v6.octets().to_vec(), 0),
+            IpAddr::V6(_) => return None,
+        };
+        for i in 0..bits.len() * 8 {
+            if node >= self.node_count {
+                break;
+            }
+            let bit = (bits[i / 8] >> (7 - i % 8)) & 1;
+            node = self.record(node, bit);
+        }
+        if node <= self.node_count {
+            return None;
+        }
+        let offset = node - self.node_count - DATA_SECTION_SEPARATOR;
+        let data = self.node_count * self.record_size / 4 + DATA_SECTION_SEPARATOR;
+        Decoder { buf: self.buf.get(data..)? }.decode(offset, 0).ok().map(|(value, _)| value)
+    }
+
+    /// Left (`bit` 0) or right record of a tree node.
+    fn record(&self, node: usize, bit: u8) -> usize {
+        let size = self.record_size * 2 / 8;
+        let n = &self.buf[node * size..node * size + size];
+        let be = |bytes: &[u8]| bytes.iter().fold(0usize, |acc, &b| acc << 8 | b as usize);
+        match (self.record_size, bit) {
+            (24, 0) => be(&n[0..3]),
+            (24, _) => be(&n[3..6]),
+            (28, 0) => ((n[3] as usize & 0xf0) << 20) | be(&n[0..3]),
+            (28, _) => ((n[3] as usize & 0x0f) << 24) | be(&n[4..7]),
+            (_, 0) => be(&n[0..4]),
+            _ => be(&n[4..8]),
+        }
+    }
+}
+
+/// Decoder for the data section (and the metadata, which uses the same
+/// encoding). Offsets and pointers are relative to `buf`.
+struct Decoder<'a> {
+    buf: &'a [u8],
+}
+
+impl Decoder<'_> {
+    /// Decode the value at `offset`, returning it and the offset after it.
+    fn decode(&self, offset: usize, depth: usize) -> Result<(Value, usize), String> {
+        if depth > MAX_DEPTH {
+            return Err("data nested too deeply".into());
+        }
+        let mut pos = offset;
+        let ctrl = self.byte(pos)?;
+        pos += 1;
+        let mut kind = ctrl >> 5;
+
+        if kind == 1 {
+            // Pointer: decode the value it points to, then carry on after it
+            let ss = (ctrl >> 3) & 0x3;
+            let vvv = (ctrl & 0x7) as usize;
+            let len = ss as usize + 1;
+            let bytes = self.slice(pos, len)?;
+            let n = bytes.iter().fold(0usize, |acc, &b| acc << 8 | b as usize);
+            let target = match ss {
+                0 => (vvv << 8) | n,
+                1 => ((vvv << 16) | n) + 2048,
+                2 => ((vvv << 24) | n) + 526_336,
+                _ => n,
+            };
+            let (value, _) = self.decode(target, depth + 1)?;
+            return Ok((value, pos + len));
+        }
+        if kind == 0 {
+            kind = 7 + self.byte(pos)?;
+            pos += 1;
+        }
+
+        let mut size = (ctrl & 0x1f) as usize;
+        if kind != 14 && size >= 29 {
+            let extra = size - 28;
+            let bytes = self.slice(pos, extra)?;
+            let n = bytes.iter().fold(0usize, |acc, &b| acc << 8 | b as usize);
+            pos += extra;
+            size = match extra {
+                1 => 29 + n,
+                2 => 285 + n,
+                _ => 65_821 + n,
+            };
+        }
+
+        match kind {
+            2 => {
+                let s = std::str::from_utf8(self.slice(pos, size)?).map_err(|e| e.to_string())?;
+                Ok((Value::String(s.to_string()), pos + size))
+            }
+            3 => {
+                let bytes: [u8; 8] = self.slice(pos, 8)?.try_into().map_err(|_| "bad double")?;
+                Ok((serde_json::json!(f64::from_be_bytes(bytes)), pos + 8))
+            }
+            4 => Ok((Value::Null, pos + size)),
+            5 | 6 | 9 | 10 => {
+                let bytes = self.slice(pos, size)?;
+                let n = bytes.iter().fold(0u128, |acc, &b| acc << 8 | b as u128);
+                let value = u64::try_from(n).map_or_else(|_| Value::String(n.to_string()), Value::from);
+                Ok((value, pos + size))
+            }
+            7 => {
+                let mut map = serde_json::Map::new();
+                for _ in 0..size {
+                    let (key, next) = self.decode(pos, depth + 1)?;
+                    let (value, next) = self.decode(next, depth + 1)?;
+                    pos = next;
+                    let Value::String(key) = key else {
+                        return Err("map key is not a string".into());
+                    };
+                    map.insert(key, value);
+                }
+                Ok((Value::Object(map), pos))
+            }
+            8 => {
+                let bytes = self.slice(pos, size)?;
+                let n = bytes.iter().fold(0u32, |acc, &b| acc << 8 | b as u32) as i32;
+                Ok((Value::from(n), pos + size))
+            }
+            11 => {
+                let mut items = Vec::with_capacity(size.min(64));
+                for _ in 0..size {
+                    let (value, next) = self.decode(pos, depth + 1)?;
+                    items.push(value);
+                    pos = next;
+                }
+                Ok((Value::Array(items), pos))
+            }
+            14 => Ok((Value::Bool(size != 0), pos)),
+            15 => {
+                let bytes: [u8; 4] = self.slice(pos, 4)?.try_into().map_err(|_| "bad float")?;
+                Ok((serde_json::json!(f32::from_be_bytes(bytes)), pos + 4))
+            }
+            other => Err(format!("unsupported data type {other}")),
+        }
+    }
+
+    fn byte(&self, pos: usize) -> Result<u8, String> {
+        self.buf.get(pos).copied().ok_or_else(|| "data ends early".into())
+    }
+
+    fn slice(&self, pos: usize, len: usize) -> Result<&[u8], String> {
+        self.buf.get(pos..pos + len).ok_or_else(|| "data ends early".into())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn string(s: &str) -> Vec<u8> {
+        let mut out = match s.len() {
+            len @ 0..29 => vec![(2 << 5) | len as u8],
+            len => vec![(2 << 5) | 29, (len - 29) as u8],
+        };
+        out.extend_from_slice(s.as_bytes());
+        out
+    }
+
+    /// An IPv4 database with one node: 0.0.0.0/1 maps to `record` (a map,
+    /// starting at data offset `at`), 128.0.0.0/1 to nothing.
+    fn database(data: &[u8], at: usize, extra_metadata: &[u8]) -> Vec<u8> {
+        let node_count = 1usize;
+        let left = node_count + DATA_SECTION_SEPARATOR + at;
+        let mut buf = vec![0, 0, left as u8, 0, 0, node_count as u8];
+        buf.extend_from_slice(&[0; DATA_SECTION_SEPARATOR]);
+        buf.extend_from_slice(data);
+        buf.extend_from_slice(METADATA_MARKER);
+        let pairs = if extra_metadata.is_empty() { 3 } else { 4 };
+        buf.push((7 << 5) | pairs);
+        buf.extend(string("node_count"));
+        buf.extend([(6 << 5) | 1, node_count as u8]);
+        buf.extend(string("record_size"));
+        buf.extend([(5 << 5) | 1, 24]);
+        buf.extend(string("ip_version"));
+        buf.extend([(5 << 5) | 1, 4]);
+        buf.extend_from_slice(extra_metadata);
+        buf
+    }
+
+    #[test]
+    fn looks_up_country_through_pointers() {
+        // "US" at offset 0, then {"country": {"iso_code": <pointer to 0>}}
+        let mut data = string("US");
+        let at = data.len();
+        data.push((7 << 5) | 1);
+        data.extend(string("country"));
+        data.push((7 << 5) | 1);
+        data.extend(string("iso_code"));
+        data.extend([1 << 5, 0]);
+        let mut metadata = string("database_type");
+        metadata.extend(string("Test-Country"));
+
+        let reader = Reader::from_bytes(database(&data, at, &metadata)).unwrap();
+        assert_eq!(reader.database_type, "Test-Country");
+        let geoip = GeoIp { country: Some(reader), asn: None };
+        assert_eq!(geoip.lookup("8.8.8.8").country.as_deref(), Some("US"));
+        assert_eq!(geoip.lookup("203.0.113.7"), Geo::default());
+        assert_eq!(geoip.lookup("2001:db8::1"), Geo::default());
+        assert_eq!(geoip.lookup("unknown"), Geo::default());
+    }
+
+    #[test]
+    fn looks_up_asn_and_organization() {
+        let mut data = vec![(7 << 5) | 2];
+        data.extend(string("autonomous_system_number"));
+        data.extend([(6 << 5) | 2, 0x34, 0x17]);
+        data.extend(string("autonomous_system_organization"));
+        data.extend(string("CLOUDFLARENET"));
+
+        let reader = Reader::from_bytes(database(&data, 0, &[])).unwrap();
+        let geoip = GeoIp { country: None, asn: Some(reader) };
+        let geo = geoip.lookup("1.1.1.1");
+        assert_eq!(geo.asn, Some(13335));
+        assert_eq!(geo.as_org.as_deref(), Some("CLOUDFLARENET"));
+        assert_eq!(geo.country, None);
+    }
+
+    #[test]
+    fn rejects_files_without_metadata() {
+        assert!(Reader::from_bytes(vec![0; 64]).is_err());
+    }
+}
@@ -0,0 +1,257 @@
+//! Error responses generated by the receiver itself (as opposed to mock
+//! responses, which are whatever the endpoint owner configured).
+//!
+//! Every error has a stable machine-readable code. The body is a JSON
+//! envelope by default; senders that only accept `text/plain` get a one-line
+//! text body instead. The envelope keeps the code under `error` as well as
+//! `code` because that was the whole body before, and senders already match
+//! on it.
+
+use axum::extract::State;
+use axum::http::{HeaderMap, StatusCode, header};
+use axum::response::{IntoResponse, Response};
+
+/// Where each error code is explained. Codes are listed under this anchor.
+const DOCS_URL: &str = "https://webhooks.cc/docs/core-concepts#receiver-errors";
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ReceiverError {
+    InvalidSlug,
+    InvalidBody,
+    PayloadTooLarge,
+    NotFound,
+    ReservedSlug,
+    Expired,
+    Paused,
+    Blocked,
+    ClientCertRequired,
+    InvalidSignature,
+    InvalidJwt,
+    Unauthorized,
+    ConfigUnavailable,
+    QuotaExceeded,
+    TooManyInFlight,
+    UpstreamFailed,
+    RouteNotFound,
+}
+
+impl ReceiverError {
+    pub fn code(self) -> &'static str {
+        match self {
+            Self::InvalidSlug => "invalid_slug",
+            Self::InvalidBody => "invalid_body",
+            Self::PayloadTooLarge => "payload_too_large",
+            Self::NotFound => "not_found",
+            Self::ReservedSlug => "reserved_slug",
+            Self::Expired => "expired",
+            Self::Paused => "paused",
+            Self::Blocked => "blocked",
+            Self::ClientCertRequired => "client_certificate_required",
+            Self::InvalidSignature => "invalid_signature",
+            Self::InvalidJwt => "invalid_jwt",
+            Self::Unauthorized => "unauthorized",
+            Self::ConfigUnavailable => "config_unavailable",
+            Self::QuotaExceeded => "quota_exceeded",
+            Self::TooManyInFlight => "too_many_in_flight",
+            Self::UpstreamFailed => "upstream_failed",
+            Self::RouteNotFound => "route_not_found",
+        }
+    }
+
+    pub fn status(self) -> StatusCode {
+        match self {
+            Self::InvalidSlug | Self::InvalidBody => StatusCode::BAD_REQUEST,
+            Self::PayloadTooLarge => StatusCode::PAYLOAD_TOO_LARGE,
+            Self::NotFound | Self::ReservedSlug | Self::RouteNotFound => StatusCode::NOT_FOUND,
+            Self::Expired => StatusCode::GONE,
+            Self::Paused | Self::ConfigUnavailable => StatusCode::SERVICE_UNAVAILABLE,
+            Self::Blocked | Self::ClientCertRequired => StatusCode::FORBIDDEN,
+            Self::InvalidSignature | Self::InvalidJwt | Self::Unauthorized => {
+                StatusCode::UNAUTHORIZED
+            }
+            Self::QuotaExceeded | Self::TooManyInFlight => StatusCode::TOO_MANY_REQUESTS,
+            Self::UpstreamFailed => StatusCode::BAD_GATEWAY,
+        }
+    }
+
+    pub(super) fn message(self) -> &'static str {
+        match self {
+            Self::InvalidSlug => {
+                "Endpoint slugs are 1-50 characters of letters, digits, '-' and '_'."
+            }
+            Self::InvalidBody => "The request body could not be read.",
+            Self::PayloadTooLarge => "The request body exceeds the receiver's size limit.",
+            Self::NotFound => "No endpoint exists with this slug.",
+            Self::ReservedSlug => {
+                "This slug is reserved by webhooks.cc and can't be used for an endpoint."
+            }
+            Self::Expired => {
+                "This endpoint has expired and no longer captures requests. Create a new one at https://webhooks.cc or with `whk create`."
+            }
+            Self::Paused => {
+                "This endpoint is paused and is not capturing requests. Retry once it is resumed."
+            }
+            Self::Blocked => {
+                "This endpoint does not accept requests from this country or network."
+            }
+            Self::ClientCertRequired => {
+                "This endpoint only accepts requests over mTLS with a client certificate issued by its trusted CA."
+            }
+            Self::InvalidSignature => {
+                "The request's signature does not match the secret this endpoint verifies with."
+            }
+            Self::InvalidJwt => {
+                "The request's JWT is missing, expired or not signed by a key this endpoint trusts."
+            }
+            Self::Unauthorized => {
+                "This endpoint only captures requests that carry its Basic credentials or API key."
+            }
+            Self::ConfigUnavailable => {
+                "This endpoint's settings couldn't be read, so nothing was captured. Retry shortly."
+            }
+            Self::QuotaExceeded => {
+                "The endpoint owner's request quota is used up. Retry after the Retry-After delay."
+            }
+            Self::TooManyInFlight => {
+                "Too many requests for this endpoint's account are being captured at once. Retry shortly."
+            }
+            Self::UpstreamFailed => {
+                "The request was captured, but the backend this endpoint forwards to couldn't be reached or didn't answer in time."
+            }
+            Self::RouteNotFound => "Webhooks are received at /w/<slug>.",
+        }
+    }
+
+    fn body_json(self) -> serde_json::Value {
+        serde_json::json!({
+            "error": self.code(),
+            "code": self.code(),
+            "message": self.message(),
+            "docs": DOCS_URL,
+        })
+    }
+
+    /// Build the response, negotiating the body format from `request_headers`.
+    pub fn respond(self, request_headers: &HeaderMap) -> Response {
+        if prefers_text(request_headers) {
+            let body = format!("{} ({}). See {}\n", self.message(), self.code(), DOCS_URL);
+            return (
+                self.status(),
+                [(header::CONTENT_TYPE, "text/plain; charset=utf-8")],
+                body,
+            )
+                .into_response();
+        }
+        (self.status(), axum::Json(self.body_json())).into_response()
+    }
+}
+
+/// Whether the sender accepts plain text but not JSON. A missing Accept
+/// header, a wildcard, or any JSON type keeps the JSON envelope.
+fn prefers_text(headers: &HeaderMap) -> bool {
+    let Some(accept) = headers.get(header::ACCEPT).and_then(|v| v.to_str().ok()) else {
+        return false;
+    };
+    let mut text = false;
+    for range in accept.split(',') {
+        let mut parts = range.split(';');
+        let media = parts.next().unwrap_or("").trim().to_ascii_lowercase();
+        // q=0 means "not acceptable"
+        let refused = parts.any(|p| {
+            p.trim()
+                .strip_prefix("q=")
+                .and_then(|q| q.trim().parse::<f32>().ok())
+                .is_some_and(|q| q == 0.0)
+        });
+        if refused {
+            continue;
+        }
+        if media == "*/*" || media == "application/*" || media.ends_with("json") {
+            return false;
+        }
+        if media == "text/plain" || media == "text/*" {
+            text = true;
+        }
+    }
+    text
+}
+
+/// Fallback for paths outside `/w/<slug>` and `/health`.
+pub async fn route_not_found(headers: HeaderMap) -> Response {
+    ReceiverError::RouteNotFound.respond(&headers)
+}
+
+/// Reject requests whose Content-Length is over `max` before the body limit
+/// layer does, since that layer answers with a bare plain-text 413. Bodies
+/// without a declared length are still caught while reading.
+pub async fn reject_oversized(
+    State(max): State<usize>,
+    request: axum::extract::Request,
+    next: axum::middleware::Next,
+) -> Response {
+    let declared = request
+        .headers()
+        .get(header::CONTENT_LENGTH)
+        .and_then(|v| v.to_str().ok())
+        .and_then(|v| v.parse::<u64>().ok());
+    if declared.is_some_and(|len| len > max as u64) {
+        return ReceiverError::PayloadTooLarge.respond(request.headers());
+    }
+    next.run(request).await
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::http::HeaderValue;
+
+    fn accept(value: &str) -> HeaderMap {
+        let mut headers = HeaderMap::new();
+        headers.insert(header::ACCEPT, HeaderValue::from_str(value).unwrap());
+        headers
+    }
+
+    #[test]
+    fn envelope_has_code_message_and_docs() {
+        let body = ReceiverError::QuotaExceeded.body_json();
+        assert_eq!(body["error"], "quota_exceeded");
+        assert_eq!(body["code"], "quota_exceeded");
+        assert!(body["message"].as_str().unwrap().contains("quota"));
+        assert_eq!(body["docs"], DOCS_URL);
+    }
+
+    #[test]
+    fn json_unless_only_text_is_accepted() {
+        assert!(!prefers_text(&HeaderMap::new()));
+        assert!(!prefers_text(&accept("*/*")));
+        assert!(!prefers_text(&accept("application/json")));
+        assert!(!prefers_text(&accept(
+            "text/plain, application/problem+json"
+        )));
+        assert!(prefers_text(&accept("text/plain")));
+        assert!(prefers_text(&accept("text/*;q=0.9, application/json;q=0")));
+        assert!(!prefers_text(&accept("text/html")));
+    }
+
+    #[tokio::test]
+    async fn respond_negotiates_content_type() {
+        let response = ReceiverError::NotFound.respond(&accept("text/plain"));
+        assert_eq!(response.status(), StatusCode::NOT_FOUND);
+        assert!(
+            response.headers()[header::CONTENT_TYPE]
+                .to_str()
+                .unwrap()
+                .starts_with("text/plain")
+        );
+
+        let response = ReceiverError::Expired.respond(&HeaderMap::new());
+        assert_eq!(response.status(), StatusCode::GONE);
+        assert_eq!(response.headers()[header::CONTENT_TYPE], "application/json");
+        let bytes = axum::body::to_bytes(response.into_body(), usize::MAX)
+            .await
+            .unwrap();
+        let body: serde_json::Value = serde_json::from_slice(&bytes).unwrap();
+        assert_eq!(body["code"], "expired");
+        assert!(body["message"].as_str().unwrap().contains("Create a new one"));
+    }
+}
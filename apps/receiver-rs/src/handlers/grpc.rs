@@ -0,0 +1,425 @@
+//! gRPC capture on the `GRPC_PORT` listener.
+//!
+//! Every call to any service is accepted by one fallback handler; there are no
+//! registered services. The endpoint comes from the first path segment
+//! (`/{slug}/{package.Service}/{Method}`, for clients that take a base path) or
+//! from the `webhooks-slug` metadata entry on a plain `/{package.Service}/{Method}`
+//! call. The call is captured through `capture_webhook` with method `GRPC`,
+//! the full method name as the path, the metadata as headers, and the request
+//! message as the body: the serialized message of a unary call, or the body as
+//! sent (length prefixes included) when a client stream carries zero or several
+//! messages. Compressed messages are stored compressed.
+//!
+//! The reply comes from the endpoint's `mock_response.grpc`
+//! (`{code, message?, body?}`, body base64) and is an empty OK message without
+//! one. Refused captures end with the gRPC status closest to the receiver
+//! error. Nothing is mirrored, forwarded to function sinks or announced to
+//! notification URLs, and replies are not recorded.
+
+use axum::body::{Body, Bytes};
+use axum::extract::State;
+use axum::http::{HeaderMap, HeaderValue, StatusCode, Uri, Version};
+use axum::response::{IntoResponse, Response};
+use base64::Engine;
+use chrono::Utc;
+use http_body_util::BodyExt;
+use serde::Deserialize;
+
+use super::error::ReceiverError;
+use super::webhook::{
+    body_hash, check_capture_auth, client_cert_record, client_geo, filter_headers,
+    filter_trailers, http_version, is_length_limit_error, is_reserved_slug, is_valid_slug, real_ip,
+};
+use crate::AppState;
+
+/// Method stored for captured gRPC calls.
+const GRPC_METHOD: &str = "GRPC";
+
+/// Metadata entry naming the endpoint when the path has no slug segment.
+const SLUG_METADATA: &str = "webhooks-slug";
+
+/// gRPC status codes used by the receiver.
+mod code {
+    pub const OK: u32 = 0;
+    pub const INVALID_ARGUMENT: u32 = 3;
+    pub const NOT_FOUND: u32 = 5;
+    pub const PERMISSION_DENIED: u32 = 7;
+    pub const RESOURCE_EXHAUSTED: u32 = 8;
+    pub const INTERNAL: u32 = 13;
+    pub const UNAVAILABLE: u32 = 14;
+    pub const UNAUTHENTICATED: u32 = 16;
+    /// Highest defined code
+    pub const MAX: u32 = UNAUTHENTICATED;
+}
+
+/// The gRPC status a refused capture ends with.
+fn status_code(error: &ReceiverError) -> u32 {
+    match error {
+        ReceiverError::InvalidSlug => code::INVALID_ARGUMENT,
+        ReceiverError::InvalidBody => code::INTERNAL,
+        ReceiverError::PayloadTooLarge | ReceiverError::QuotaExceeded | ReceiverError::TooManyInFlight => {
+            code::RESOURCE_EXHAUSTED
+        }
+        ReceiverError::NotFound
+        | ReceiverError::ReservedSlug
+        | ReceiverError::Expired
+        | ReceiverError::RouteNotFound => code::NOT_FOUND,
+        ReceiverError::Paused
+        | ReceiverError::ConfigUnavailable
+        | ReceiverError::UpstreamFailed => code::UNAVAILABLE,
+        ReceiverError::Blocked => code::PERMISSION_DENIED,
+        ReceiverError::ClientCertRequired
+        | ReceiverError::InvalidSignature
+        | ReceiverError::InvalidJwt
+        | ReceiverError::Unauthorized => code::UNAUTHENTICATED,
+    }
+}
+
+/// `mock_response.grpc`: the reply to send for a captured call.
+#[derive(Debug, Default, Deserialize)]
+struct GrpcReply {
+    #[serde(default)]
+    code: u32,
+    #[serde(default)]
+    message: String,
+    /// Base64 of the serialized response message
+    #[serde(default)]
+    body: Option<String>,
+}
+
+/// Endpoint slug and full method name (`/package.Service/Method`) of a call.
+fn call_target(path: &str, headers: &HeaderMap) -> Option<(String, String)> {
+    let segments: Vec<&str> = path.trim_start_matches('/').split('/').collect();
+    match segments.as_slice() {
+        [slug, service, method] if !service.is_empty() && !method.is_empty() => {
+            Some((slug.to_ascii_lowercase(), format!("/{service}/{method}")))
+        }
+        [service, method] if !service.is_empty() && !method.is_empty() => {
+            let slug = headers.get(SLUG_METADATA)?.to_str().ok()?;
+            Some((slug.to_ascii_lowercase(), format!("/{service}/{method}")))
+        }
+        _ => None,
+    }
+}
+
+/// Split a body into its length-prefixed messages; `None` when it isn't
+/// validly framed.
+fn messages(mut body: &[u8]) -> Option<Vec<&[u8]>> {
+    let mut messages = Vec::new();
+    while !body.is_empty() {
+        let header = body.get(..5)?;
+        let len = u32::from_be_bytes([header[1], header[2], header[3], header[4]]) as usize;
+        messages.push(body.get(5..5 + len)?);
+        body = &body[5 + len..];
+    }
+    Some(messages)
+}
+
+/// Length-prefix an uncompressed message.
+fn frame(message: &[u8]) -> Vec<u8> {
+    let mut framed = Vec::with_capacity(5 + message.len());
+    framed.push(0);
+    framed.extend_from_slice(&(message.len() as u32).to_be_bytes());
+    framed.extend_from_slice(message);
+    framed
+}
+
+/// Percent-encode a status message for `grpc-message`.
+fn encode_message(message: &str) -> String {
+    let mut encoded = String::with_capacity(message.len());
+    for byte in message.bytes() {
+        if (0x20..0x7f).contains(&byte) && byte != b'%' {
+            encoded.push(byte as char);
+        } else {
+            encoded.push_str(&format!("%{byte:02X}"));
+        }
+    }
+    encoded
+}
+
+fn status_headers(code: u32, message: &str) -> HeaderMap {
+    let mut headers = HeaderMap::new();
+    headers.insert("grpc-status", HeaderValue::from(code));
+    if !message.is_empty()
+        && let Ok(value) = HeaderValue::from_str(&encode_message(message))
+    {
+        headers.insert("grpc-message", value);
+    }
+    headers
+}
+
+/// A trailers-only reply carrying just a status.
+fn status_only(code: u32, message: &str) -> Response {
+    let mut response = Response::new(Body::empty());
+    *response.headers_mut() = status_headers(code, message);
+    response
+        .headers_mut()
+        .insert("content-type", HeaderValue::from_static("application/grpc"));
+    response
+}
+
+fn refuse(error: ReceiverError) -> Response {
+    status_only(status_code(&error), error.message())
+}
+
+/// The reply for an accepted call: a status with no message for errors, or
+/// one response message followed by the OK status in trailers.
+fn reply(reply: &GrpcReply) -> Response {
+    let code = reply.code.min(code::MAX);
+    if code != code::OK {
+        return status_only(code, &reply.message);
+    }
+    let message = reply
+        .body
+        .as_deref()
+        .and_then(|b| base64::engine::general_purpose::STANDARD.decode(b).ok())
+        .unwrap_or_default();
+    let body = http_body_util::Full::new(Bytes::from(frame(&message)))
+        .with_trailers(std::future::ready(Some(Ok(status_headers(code::OK, &reply.message)))));
+    let mut response = Response::new(Body::new(body));
+    response
+        .headers_mut()
+        .insert("content-type", HeaderValue::from_static("application/grpc"));
+    response
+}
+
+/// gRPC capture: any call on the gRPC listener.
+pub async fn handle_grpc(
+    State(state): State<AppState>,
+    version: Version,
+    uri: Uri,
+    headers: HeaderMap,
+    body: Body,
+) -> Response {
+    let is_grpc = headers
+        .get("content-type")
+        .and_then(|v| v.to_str().ok())
+        .is_some_and(|ct| ct.starts_with("application/grpc"));
+    if !is_grpc {
+        return (StatusCode::UNSUPPORTED_MEDIA_TYPE, "gRPC requests only").into_response();
+    }
+
+    let Some((slug, method_name)) = call_target(uri.path(), &headers) else {
+        return status_only(
+            code::NOT_FOUND,
+            "Call /{slug}/{package.Service}/{Method}, or send webhooks-slug metadata.",
+        );
+    };
+    if !is_valid_slug(&slug) {
+        return refuse(ReceiverError::InvalidSlug);
+    }
+    // This listener has no TLS, so endpoints that require a client
+    // certificate are refused outright.
+    if let Err(e) = client_cert_record(&state, &slug, None).await {
+        return refuse(e);
+    }
+    // Credentials come from metadata, which arrives as request headers.
+    if let Err(refused) = check_capture_auth(&state, &slug, &headers).await {
+        return refuse(refused.error());
+    }
+
+    // Calls count against the account's in-flight limits, like HTTP requests.
+    let mut permit = match state.tenants.tenant(&state.pool, &slug).await {
+        Some(tenant) => match state.tenants.admit(tenant) {
+            Some(permit) => Some(permit),
+            None => return refuse(ReceiverError::TooManyInFlight),
+        },
+        None => None,
+    };
+
+    let (body, mut trailers) = match body.collect().await {
+        Ok(collected) => {
+            let trailers = filter_trailers(collected.trailers());
+            (collected.to_bytes(), trailers)
+        }
+        Err(e) if is_length_limit_error(&e) => return refuse(ReceiverError::PayloadTooLarge),
+        Err(e) => {
+            tracing::debug!(error = %e, "failed to read grpc request body");
+            return refuse(ReceiverError::InvalidBody);
+        }
+    };
+    if let Some(ref mut permit) = permit
+        && !permit.hold_bytes(body.len())
+    {
+        return refuse(ReceiverError::TooManyInFlight);
+    }
+    let Some(messages) = messages(&body) else {
+        return status_only(code::INTERNAL, "Malformed gRPC message framing.");
+    };
+    let message = match messages.as_slice() {
+        [message] => message.to_vec(),
+        _ => body.to_vec(),
+    };
+
+    let received_at = Utc::now();
+    let ip = real_ip(&headers);
+    let (mut body_str, body_raw): (String, Option<Vec<u8>>) = match String::from_utf8(message) {
+        Ok(s) => (s, None),
+        Err(e) => {
+            let lossy = String::from_utf8_lossy(e.as_bytes()).into_owned();
+            (lossy, Some(e.into_bytes()))
+        }
+    };
+
+    let mut captured_headers = filter_headers(&headers);
+    if let Some(transforms) = state.caches.transforms.get(&state.pool, &slug).await {
+        if body_raw.is_some() {
+            let mut unused = String::new();
+            crate::transform::apply(&transforms, &mut captured_headers, &mut unused);
+        } else {
+            crate::transform::apply(&transforms, &mut captured_headers, &mut body_str);
+        }
+    }
+    // Sensitive metadata is redacted (or sealed, when the endpoint encrypts
+    // it) as for HTTP captures; the per-rule counts aren't recorded.
+    let encryption = state.caches.header_encryption.get(&state.pool, &slug).await;
+    let sealed = encryption.as_ref().map_or(&[][..], |policy| &policy.headers[..]);
+    let redactor = state.caches.redactions.get(&state.pool, &slug).await;
+    let mut unrecorded = crate::redact::Fired::new();
+    crate::redact::redact_headers(redactor.as_deref(), sealed, &mut captured_headers, &mut unrecorded);
+    if let Some(ref mut trailers) = trailers {
+        crate::redact::redact_headers(redactor.as_deref(), sealed, trailers, &mut unrecorded);
+    }
+    if let Some(ref policy) = encryption {
+        captured_headers = crate::header_crypt::seal(state.header_cipher.as_deref(), policy, &captured_headers);
+        trailers = trailers.map(|t| crate::header_crypt::seal(state.header_cipher.as_deref(), policy, &t));
+    }
+    let trailers_json = trailers.and_then(|t| serde_json::to_value(t).ok());
+    let headers_json = serde_json::to_value(&captured_headers)
+        .unwrap_or(serde_json::Value::Object(serde_json::Map::new()));
+    let content_type = headers
+        .get("content-type")
+        .and_then(|v| v.to_str().ok())
+        .unwrap_or("application/grpc");
+    let body_hash = body_hash(&body_str, body_raw.as_deref());
+    let content_class =
+        crate::content_class::classify(content_type, body_raw.as_deref().unwrap_or(body_str.as_bytes()));
+    let fingerprint = crate::fingerprint::fingerprint(
+        GRPC_METHOD,
+        &method_name,
+        content_type,
+        body_raw.is_none().then_some(body_str.as_str()),
+    );
+
+    let result: Result<serde_json::Value, sqlx::Error> = sqlx::query_scalar(
+        "SELECT capture_webhook($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)",
+    )
+    .bind(&slug)
+    .bind(GRPC_METHOD)
+    .bind(&method_name)
+    .bind(&headers_json)
+    .bind(&body_str)
+    .bind(serde_json::Value::Object(serde_json::Map::new()))
+    .bind(content_type)
+    .bind(&ip)
+    .bind(received_at)
+    .bind(body_raw.as_deref())
+    .bind(None::<chrono::DateTime<Utc>>)
+    .bind(&body_hash)
+    .bind(None::<serde_json::Value>)
+    .bind(client_geo(&state, &headers, &ip).country)
+    .bind(None::<String>)
+    .bind(None::<i32>)
+    .bind(None::<serde_json::Value>)
+    .bind(None::<serde_json::Value>)
+    .bind(None::<String>)
+    .bind(http_version(version))
+    .bind(None::<serde_json::Value>)
+    .bind(&trailers_json)
+    .bind(None::<String>)
+    .bind(&fingerprint)
+    .bind(None::<String>)
+    .bind(None::<String>)
+    .bind(content_class)
+    .fetch_one(&state.pool)
+    .await;
+
+    let value = match result {
+        Ok(value) => value,
+        Err(e) => {
+            tracing::error!(slug, error = %e, "capture_webhook query failed for grpc call");
+            state.capture_failures.record(&slug, received_at);
+            return status_only(code::UNAVAILABLE, "The call could not be captured. Retry shortly.");
+        }
+    };
+
+    if value.get("auto_extend").and_then(|v| v.as_bool()).unwrap_or(false) {
+        state.activity.record(&slug, received_at);
+    }
+
+    match value.get("status").and_then(|s| s.as_str()).unwrap_or_default() {
+        "ok" => {
+            let grpc = value
+                .get("mock_response")
+                .and_then(|m| m.get("grpc"))
+                .and_then(|g| serde_json::from_value::<GrpcReply>(g.clone()).ok())
+                .unwrap_or_default();
+            reply(&grpc)
+        }
+        "not_found" if is_reserved_slug(&slug) => refuse(ReceiverError::ReservedSlug),
+        "not_found" => refuse(ReceiverError::NotFound),
+        "expired" => refuse(ReceiverError::Expired),
+        "paused" => refuse(ReceiverError::Paused),
+        "blocked" => refuse(ReceiverError::Blocked),
+        "quota_exceeded" => refuse(ReceiverError::QuotaExceeded),
+        unknown => {
+            tracing::warn!(slug, status = unknown, "unexpected capture_webhook status");
+            reply(&GrpcReply::default())
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn call_target_from_path_or_metadata() {
+        let mut headers = HeaderMap::new();
+        assert_eq!(
+            call_target("/My-Slug/acme.Orders/Create", &headers),
+            Some(("my-slug".to_string(), "/acme.Orders/Create".to_string()))
+        );
+        assert_eq!(call_target("/acme.Orders/Create", &headers), None);
+
+        headers.insert(SLUG_METADATA, HeaderValue::from_static("my-slug"));
+        assert_eq!(
+            call_target("/acme.Orders/Create", &headers),
+            Some(("my-slug".to_string(), "/acme.Orders/Create".to_string()))
+        );
+        assert_eq!(call_target("/acme.Orders", &headers), None);
+        assert_eq!(call_target("/a/b/c/d", &headers), None);
+    }
+
+    #[test]
+    fn messages_round_trip_framing() {
+        let mut body = frame(b"\x08\x96\x01");
+        body.extend(frame(b""));
+        assert_eq!(messages(&body), Some(vec![&b"\x08\x96\x01"[..], &b""[..]]));
+        assert_eq!(messages(b""), Some(vec![]));
+        assert_eq!(messages(&body[..4]), None);
+        assert_eq!(messages(&body[..7]), None);
+    }
+
+    #[test]
+    fn status_message_is_percent_encoded() {
+        assert_eq!(encode_message("50% off ✓"), "50%25 off %E2%9C%93");
+        let response = status_only(code::NOT_FOUND, "gone");
+        assert_eq!(response.headers()["grpc-status"], "5");
+        assert_eq!(response.headers()["grpc-message"], "gone");
+        assert_eq!(refuse(ReceiverError::QuotaExceeded).headers()["grpc-status"], "8");
+    }
+
+    #[tokio::test]
+    async fn ok_reply_sends_message_then_trailers() {
+        let grpc = GrpcReply { code: 0, message: String::new(), body: Some("CJYB".into()) };
+        let collected = reply(&grpc).into_body().collect().await.unwrap();
+        assert_eq!(collected.trailers().unwrap()["grpc-status"], "0");
+        assert_eq!(collected.to_bytes(), Bytes::from(frame(b"\x08\x96\x01")));
+
+        let error = GrpcReply { code: 14, message: "down".into(), body: None };
+        let response = reply(&error);
+        assert_eq!(response.headers()["grpc-status"], "14");
+        assert_eq!(response.headers()["grpc-message"], "down");
+    }
+}
@@ -1,2 +1,6 @@
+pub mod error;
+pub mod grpc;
 pub mod health;
+pub mod subdomain;
 pub mod webhook;
+pub mod websocket;
@@ -0,0 +1,134 @@
+//! Host-based routing: `{slug}.{SUBDOMAIN_HOST}/anything` is captured as
+//! `/w/{slug}/anything`, for providers that only take a bare hostname.
+//!
+//! The request URI is rewritten before routing, so everything downstream
+//! (slug validation, captured path, caches keyed by slug) sees the same
+//! request as the path form. WebSocket upgrades are rewritten to `/ws/{slug}`.
+//! Hosts that aren't exactly one label under the suffix, and every request
+//! when `SUBDOMAIN_HOST` is unset, pass through untouched.
+//!
+//! Requests on the custom domain listener are rewritten the same way, with
+//! the endpoint found by looking the whole hostname up (`custom_domain`).
+
+use axum::extract::{Request, State};
+use axum::http::{header, HeaderMap, Uri};
+use axum::middleware::Next;
+use axum::response::Response;
+use std::sync::Arc;
+
+use crate::AppState;
+use crate::custom_domain::normalize_host;
+
+/// Rewrite requests for `{slug}.{suffix}` to the path routes. Runs outside
+/// the router, since routing has already happened by the time a layer on it
+/// sees the request.
+pub async fn route_by_host(
+    State(suffix): State<Option<Arc<str>>>,
+    mut request: Request,
+    next: Next,
+) -> Response {
+    let slug = suffix.as_deref().and_then(|suffix| {
+        request_host(request.headers(), request.uri())
+            .and_then(|host| slug_from_host(host, suffix))
+            .map(str::to_string)
+    });
+    if let Some(slug) = slug {
+        route_to(&mut request, &slug);
+    }
+    next.run(request).await
+}
+
+/// Rewrite requests for a custom domain to its endpoint's path routes.
+/// Hosts that aren't a custom domain pass through, so `/w/{slug}` still
+/// works on the listener.
+pub async fn route_by_custom_domain(
+    State(state): State<AppState>,
+    mut request: Request,
+    next: Next,
+) -> Response {
+    let host = request_host(request.headers(), request.uri()).map(normalize_host);
+    let slug = match host {
+        Some(host) => state.caches.custom_domains.get(&state.pool, &host).await,
+        None => None,
+    };
+    if let Some(slug) = slug {
+        route_to(&mut request, &slug);
+    }
+    next.run(request).await
+}
+
+/// Point a request at an endpoint's capture route, keeping its path.
+fn route_to(request: &mut Request, slug: &str) {
+    let prefix = if is_websocket_upgrade(request.headers()) { "/ws/" } else { "/w/" };
+    if let Some(uri) = rewrite(request.uri(), prefix, slug) {
+        *request.uri_mut() = uri;
+    }
+}
+
+/// The Host header, or the URI authority for HTTP/2 requests without one.
+fn request_host<'a>(headers: &'a HeaderMap, uri: &'a Uri) -> Option<&'a str> {
+    headers
+        .get(header::HOST)
+        .and_then(|v| v.to_str().ok())
+        .or_else(|| uri.authority().map(|a| a.as_str()))
+}
+
+/// The slug label of `host` when it is exactly one label under `suffix`.
+/// Matching ignores case and any port; the slug itself is validated by the
+/// webhook handler like any other.
+fn slug_from_host<'a>(host: &'a str, suffix: &str) -> Option<&'a str> {
+    let host = host.rsplit_once(':').map_or(host, |(name, port)| {
+        if port.bytes().all(|b| b.is_ascii_digit()) { name } else { host }
+    });
+    let host = host.strip_suffix('.').unwrap_or(host);
+    let (label, rest) = host.split_once('.')?;
+    (!label.is_empty() && rest.eq_ignore_ascii_case(suffix)).then_some(label)
+}
+
+fn is_websocket_upgrade(headers: &HeaderMap) -> bool {
+    headers
+        .get(header::UPGRADE)
+        .and_then(|v| v.to_str().ok())
+        .is_some_and(|v| v.eq_ignore_ascii_case("websocket"))
+}
+
+/// `/anything?q` → `{prefix}{slug}/anything?q`; the root maps to `{prefix}{slug}`.
+fn rewrite(uri: &Uri, prefix: &str, slug: &str) -> Option<Uri> {
+    let path = match uri.path() {
+        "/" | "" => String::new(),
+        path => path.to_string(),
+    };
+    let mut rewritten = format!("{prefix}{slug}{path}");
+    if let Some(query) = uri.query() {
+        rewritten.push('?');
+        rewritten.push_str(query);
+    }
+    rewritten.parse().ok()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn slug_is_the_single_label_under_the_suffix() {
+        let suffix = "in.webhooks.cc";
+        assert_eq!(slug_from_host("abc123.in.webhooks.cc", suffix), Some("abc123"));
+        assert_eq!(slug_from_host("ABC123.In.Webhooks.CC:443", suffix), Some("ABC123"));
+        assert_eq!(slug_from_host("abc123.in.webhooks.cc.", suffix), Some("abc123"));
+        assert_eq!(slug_from_host("in.webhooks.cc", suffix), None);
+        assert_eq!(slug_from_host("a.b.in.webhooks.cc", suffix), None);
+        assert_eq!(slug_from_host("abc123.go.webhooks.cc", suffix), None);
+        assert_eq!(slug_from_host(".in.webhooks.cc", suffix), None);
+    }
+
+    #[test]
+    fn rewrite_keeps_path_and_query() {
+        let uri: Uri = "/stripe/events?live=1".parse().unwrap();
+        assert_eq!(rewrite(&uri, "/w/", "abc").unwrap(), "/w/abc/stripe/events?live=1");
+        let root: Uri = "/".parse().unwrap();
+        assert_eq!(rewrite(&root, "/w/", "abc").unwrap(), "/w/abc");
+        let encoded: Uri = "/a%2Fb/w/x".parse().unwrap();
+        assert_eq!(rewrite(&encoded, "/ws/", "abc").unwrap(), "/ws/abc/a%2Fb/w/x");
+    }
+}
@@ -1,21 +1,32 @@
-use axum::body::Bytes;
+use axum::Extension;
+use axum::body::{Body, Bytes};
 use axum::extract::{Path, State};
-use axum::http::{HeaderMap, Method, StatusCode};
+use axum::http::{HeaderMap, Method, StatusCode, Uri, Version};
 use axum::response::{IntoResponse, Response};
+use base64::Engine;
 use chrono::Utc;
-use serde::Deserialize;
-use std::collections::HashMap;
+use http_body_util::BodyExt;
+use hyper::body::Body as _;
+use serde::{Deserialize, Serialize};
+use std::borrow::Cow;
+use std::collections::{BTreeMap, HashMap};
 use std::sync::Arc;
 use tokio::sync::Mutex;
 
+use super::error::ReceiverError;
 use crate::AppState;
+use crate::client_cert::ClientCert;
+use crate::cors;
+use crate::mock_cache::{MockKey, RenderedMock};
+use crate::rate_limit::RateLimit;
+use crate::tls_info::TlsInfo;
 
 const MAX_HEADER_KEY_LEN: usize = 256;
 const MAX_HEADER_VALUE_LEN: usize = 8192;
 
 /// Proxy/CDN/transport headers added by our infrastructure (Cloudflare + Caddy)
 /// that should not be stored — they are not part of the original sender's request.
-const PROXY_HEADERS: &[&str] = &[
+pub(crate) const PROXY_HEADERS: &[&str] = &[
     "accept-encoding",
     "cdn-loop",
     "cf-connecting-ip",
@@ -29,16 +40,51 @@ const PROXY_HEADERS: &[&str] = &[
     "x-real-ip",
     "true-client-ip",
     "x-webhooks-cc-test-send",
+    crate::mirror::MIRROR_HEADER,
+    crate::proxy::PROXY_HEADER,
+    crate::bypass::BYPASS_HEADER,
 ];
 
-/// Blocked response headers that must not be forwarded from mock responses.
-const BLOCKED_HEADERS: &[&str] = &[
+/// Response headers dropped from mock responses by default. An endpoint's
+/// header policy can allow any of these (e.g. to simulate a cookie-setting
+/// callback) or block additional headers.
+pub(crate) const BLOCKED_HEADERS: &[&str] = &[
     "set-cookie",
     "strict-transport-security",
     "content-security-policy",
     "x-frame-options",
 ];
 
+/// Slugs and slug prefixes users can't choose. Mirrors RESERVED_SLUGS and
+/// RESERVED_SLUG_PREFIXES in apps/web/lib/slugs.ts.
+const RESERVED_SLUGS: &[&str] = &[
+    "admin",
+    "api",
+    "app",
+    "auth",
+    "billing",
+    "claim",
+    "dashboard",
+    "docs",
+    "health",
+    "login",
+    "root",
+    "settings",
+    "status",
+    "support",
+    "webhooks",
+    "whk",
+    "www",
+];
+const RESERVED_SLUG_PREFIXES: &[&str] = &["admin-", "internal-", "system-", "webhooks-cc", "whk-"];
+
+/// Whether `slug` (lowercase) is reserved. Captures for a reserved slug with
+/// no endpoint get `reserved_slug` rather than `not_found`, so a sender
+/// pointed at one learns it will never exist.
+pub(super) fn is_reserved_slug(slug: &str) -> bool {
+    RESERVED_SLUGS.contains(&slug) || RESERVED_SLUG_PREFIXES.iter().any(|p| slug.starts_with(p))
+}
+
 /// Validate slug: alphanumeric + hyphen + underscore, 1-50 chars.
 /// Matches backend SLUG_REGEX = /^[a-zA-Z0-9_-]{1,50}$/.
 pub fn is_valid_slug(slug: &str) -> bool {
@@ -49,11 +95,37 @@ pub fn is_valid_slug(slug: &str) -> bool {
         .all(|b| b.is_ascii_alphanumeric() || b == b'-' || b == b'_')
 }
 
+/// The sender's country as Cloudflare reports it in cf-ipcountry: an ISO
+/// 3166-1 alpha-2 code, or `XX`/`T1` for unknown and Tor. Matched against
+/// endpoint network policies.
+pub(super) fn client_country(headers: &HeaderMap) -> Option<String> {
+    let value = headers.get("cf-ipcountry")?.to_str().ok()?;
+    (value.len() == 2 && value.bytes().all(|b| b.is_ascii_alphanumeric()))
+        .then(|| value.to_ascii_uppercase())
+}
+
+/// The sender's country and network from the GeoIP databases (see
+/// `crate::geoip`), with Cloudflare's country taking precedence.
+pub(super) fn client_geo(state: &AppState, headers: &HeaderMap, ip: &str) -> crate::geoip::Geo {
+    let mut geo = state
+        .geoip
+        .as_ref()
+        .map(|db| db.lookup(ip))
+        .unwrap_or_default();
+    if let Some(country) = client_country(headers) {
+        geo.country = Some(country);
+    }
+    geo
+}
+
 /// Extract the real client IP from proxy headers.
 /// Sanitizes the value to contain only valid IP characters (digits, dots, colons, hex)
 /// to prevent XSS via spoofed headers stored in the database.
-fn real_ip(headers: &HeaderMap) -> String {
-    let raw = if let Some(ip) = headers.get("cf-connecting-ip").and_then(|v| v.to_str().ok()) {
+pub(super) fn real_ip(headers: &HeaderMap) -> String {
+    let raw = if let Some(ip) = headers
+        .get("cf-connecting-ip")
+        .and_then(|v| v.to_str().ok())
+    {
         ip.to_string()
     } else if let Some(ip) = headers.get("x-real-ip").and_then(|v| v.to_str().ok()) {
         ip.to_string()
@@ -78,8 +150,91 @@ fn real_ip(headers: &HeaderMap) -> String {
     }
 }
 
+/// The `requests.client_cert` value of a capture, or the refusal when the
+/// endpoint has a CA bundle and the request has no chain that verifies
+/// against it.
+pub(super) async fn client_cert_record(
+    state: &AppState,
+    slug: &str,
+    cert: Option<&ClientCert>,
+) -> Result<Option<serde_json::Value>, ReceiverError> {
+    let verified = match state.caches.client_cas.get(&state.pool, slug).await {
+        Some(ca) if cert.is_some_and(|cert| ca.verifies(cert)) => true,
+        Some(_) => return Err(ReceiverError::ClientCertRequired),
+        None => false,
+    };
+    Ok(cert.and_then(|cert| cert.record(verified)))
+}
+
+/// A request refused at the endpoint's capture credentials check.
+pub(super) enum CaptureAuthRefused {
+    /// It lacks the credentials; `basic` endpoints send a challenge.
+    Unauthorized { basic: bool },
+    /// The endpoint's config couldn't be read, so nothing is let through.
+    Unavailable,
+}
+
+impl CaptureAuthRefused {
+    pub(super) fn error(&self) -> ReceiverError {
+        match self {
+            Self::Unauthorized { .. } => ReceiverError::Unauthorized,
+            Self::Unavailable => ReceiverError::ConfigUnavailable,
+        }
+    }
+
+    /// The 401 (or 503) response. Basic endpoints add a challenge so clients
+    /// that support it retry with credentials.
+    pub(super) fn respond(&self, headers: &HeaderMap) -> Response {
+        let mut response = self.error().respond(headers);
+        if let Self::Unauthorized { basic: true } = self {
+            response.headers_mut().insert(
+                axum::http::header::WWW_AUTHENTICATE,
+                axum::http::HeaderValue::from_static("Basic realm=\"webhooks.cc\""),
+            );
+        }
+        response
+    }
+}
+
+/// Endpoints with `capture_auth` set only take requests carrying its Basic
+/// credentials or API key. Refusals are counted in the network stats. The
+/// check hashes on a blocking thread; endpoints whose config can't be read
+/// are refused outright.
+pub(super) async fn check_capture_auth(
+    state: &AppState,
+    slug: &str,
+    headers: &HeaderMap,
+) -> Result<(), CaptureAuthRefused> {
+    let auth = match state.caches.capture_auth.get(&state.pool, slug).await {
+        Some(Some(auth)) => auth,
+        Some(None) => return Ok(()),
+        None => return Err(CaptureAuthRefused::Unavailable),
+    };
+    let basic = auth.is_basic();
+    let request_headers = headers.clone();
+    let allowed = tokio::task::spawn_blocking(move || auth.allows(&request_headers))
+        .await
+        .unwrap_or(false);
+    if allowed {
+        return Ok(());
+    }
+    crate::capture_auth::count_refusal(&state.pool, slug);
+    Err(CaptureAuthRefused::Unauthorized { basic })
+}
+
+/// The protocol a request arrived over, as stored in `requests.http_version`.
+pub(super) fn http_version(version: Version) -> Option<&'static str> {
+    match version {
+        Version::HTTP_10 => Some("HTTP/1.0"),
+        Version::HTTP_11 => Some("HTTP/1.1"),
+        Version::HTTP_2 => Some("HTTP/2"),
+        Version::HTTP_3 => Some("HTTP/3"),
+        _ => None,
+    }
+}
+
 /// Filter request headers: remove proxy/CDN headers, collect into a HashMap.
-fn filter_headers(headers: &HeaderMap) -> HashMap<String, String> {
+pub(super) fn filter_headers(headers: &HeaderMap) -> HashMap<String, String> {
     let mut map = HashMap::new();
     for (key, value) in headers.iter() {
         let name = key.as_str();
@@ -93,22 +248,564 @@ fn filter_headers(headers: &HeaderMap) -> HashMap<String, String> {
     map
 }
 
+/// Trailer fields as stored in `requests.trailers`. Trailers arrive after the
+/// body (chunked encoding or HTTP/2) and are kept apart from the headers;
+/// proxy headers are dropped just like in `filter_headers`. `None` when the
+/// request had no trailers.
+pub(super) fn filter_trailers(trailers: Option<&HeaderMap>) -> Option<HashMap<String, String>> {
+    let filtered = filter_headers(trailers?);
+    (!filtered.is_empty()).then_some(filtered)
+}
+
+/// Bytes of the header block as an HTTP/1.1 sender would write it: each
+/// name, `: `, value and CRLF. HTTP/2 senders compress headers on the wire,
+/// so this is what they would weigh uncompressed.
+pub(super) fn header_bytes(headers: &HeaderMap) -> usize {
+    headers
+        .iter()
+        .map(|(name, value)| name.as_str().len() + value.len() + 4)
+        .sum()
+}
+
+/// Whether a body read failed because it exceeded the request body limit.
+pub(super) fn is_length_limit_error(err: &axum::Error) -> bool {
+    let mut source: Option<&(dyn std::error::Error + 'static)> = Some(err);
+    while let Some(e) = source {
+        if e.is::<http_body_util::LengthLimitError>() {
+            return true;
+        }
+        source = e.source();
+    }
+    false
+}
+
+/// Read the request body to the end along with any trailers, keeping at most
+/// `limit` bytes (see `crate::body_limit`). Also returns the size as received.
+///
+/// For `Expect: 100-continue` senders, hyper sends the interim `100 Continue`
+/// the first time the body is polled, so callers must reject what they can
+/// (e.g. a malformed slug) before calling this — the sender then gets the final
+/// status without uploading the body at all.
+async fn read_body(
+    mut body: Body,
+    limit: usize,
+    request_headers: &HeaderMap,
+) -> Result<(Bytes, Option<HeaderMap>, usize), Response> {
+    let mut kept = bytes::BytesMut::new();
+    let mut received = 0;
+    let mut trailers: Option<HeaderMap> = None;
+    while let Some(frame) = body.frame().await {
+        let frame = match frame {
+            Ok(frame) => frame,
+            Err(e) if is_length_limit_error(&e) => {
+                return Err(ReceiverError::PayloadTooLarge.respond(request_headers));
+            }
+            Err(e) => {
+                tracing::debug!(error = %e, "failed to read request body");
+                return Err(ReceiverError::InvalidBody.respond(request_headers));
+            }
+        };
+        match frame.into_data() {
+            Ok(data) => {
+                received += data.len();
+                let room = limit.saturating_sub(kept.len());
+                kept.extend_from_slice(&data[..data.len().min(room)]);
+            }
+            Err(frame) => {
+                if let Ok(frame_trailers) = frame.into_trailers() {
+                    trailers.get_or_insert_default().extend(frame_trailers);
+                }
+            }
+        }
+    }
+    Ok((kept.freeze(), trailers, received))
+}
+
+/// Hex SHA-256 of the body as stored (after transforms), used by
+/// capture_webhook to link exact duplicates. Raw bodies hash their bytes.
+pub(super) fn body_hash(body: &str, body_raw: Option<&[u8]>) -> String {
+    use sha2::{Digest, Sha256};
+    hex::encode(Sha256::digest(body_raw.unwrap_or(body.as_bytes())))
+}
+
 /// Shape returned by the capture_webhook stored procedure.
 #[derive(Debug, Deserialize)]
 struct CaptureResult {
     status: String,
-    mock_response: Option<MockResponse>,
+    /// Parsed only when the reply isn't already cached (see `crate::mock_cache`)
+    mock_response: Option<serde_json::Value>,
+    /// Index into `mock_response.variants` picked for this capture; the base
+    /// response when absent
+    #[serde(default)]
+    mock_variant: Option<usize>,
+    /// Index into `mock_response.schedule` of the window open when the
+    /// request arrived; takes precedence over correlation and variants
+    #[serde(default)]
+    mock_window: Option<usize>,
+    /// Set when a mock canary is running and this sender's bucket got the
+    /// canary's response; `mock_response` is then the canary's
+    #[serde(default)]
+    mock_canary: bool,
+    /// Fault the endpoint's chaos config injects in place of the reply
+    #[serde(default)]
+    chaos: Option<crate::chaos::Fault>,
+    /// Status and delay the request asked for with reserved query
+    /// parameters, on endpoints that allow it
+    #[serde(default, rename = "override")]
+    response_override: Option<ResponseOverride>,
+    /// Set when the endpoint answers provider verification requests (see
+    /// `crate::handshake`)
+    #[serde(default)]
+    handshake: bool,
+    /// Backend the endpoint relays requests to, answering with its reply
+    /// (see `crate::proxy`)
+    #[serde(default)]
+    forward_url: Option<String>,
+    /// The endpoint's rate-limit window as counted for this request (see
+    /// `crate::rate_limit`)
+    #[serde(default)]
+    rate_limit: Option<RateLimit>,
     retry_after: Option<i64>,
     notification_url: Option<String>,
+    #[serde(default)]
+    function_sink: Option<serde_json::Value>,
+    #[serde(default)]
+    info: Option<EndpointInfo>,
+    /// Reply configured for a paused endpoint; the default `paused` error when absent
+    #[serde(default)]
+    paused_response: Option<PausedResponse>,
+    /// ID of the stored request, set when the endpoint records the replies it sends
+    #[serde(default)]
+    record_response_id: Option<String>,
+    /// Set when the endpoint's priority header marked the capture high priority
+    #[serde(default)]
+    priority: bool,
+    /// Set when the endpoint is in dry-run mode and nothing was stored
+    #[serde(default)]
+    dry_run: bool,
+    /// Set when the endpoint's capture filter left the request out
+    #[serde(default)]
+    filtered: bool,
+    /// Set when the endpoint's sampling left the request out
+    #[serde(default)]
+    sampled_out: bool,
+    /// Set when the endpoint's expiry slides with activity (see `crate::auto_extend`)
+    #[serde(default)]
+    auto_extend: bool,
 }
 
+/// What a capture weighed as received and what was kept of it, stored as
+/// `requests.sizes`.
+#[derive(Debug, Serialize)]
+struct CaptureSizes {
+    /// Header block as received (see [`header_bytes`])
+    headers: usize,
+    /// Body as received
+    body: usize,
+    /// Body bytes kept in the row; 0 when the body went to object storage
+    stored: usize,
+    /// Each field that wasn't stored as sent, with the reason
+    #[serde(skip_serializing_if = "BTreeMap::is_empty")]
+    truncated: BTreeMap<&'static str, &'static str>,
+}
+
+/// How a stored request was answered, beyond what the response itself shows.
+struct SentReply {
+    /// "rate_limit", "handshake", "schema", "proxy", "mock" or "default"
+    source: &'static str,
+    /// Delay applied before replying, after the cap
+    delay_ms: u64,
+    body_size: usize,
+}
+
+/// The `requests.response` summary of a reply.
+fn response_summary(response: &Response, sent: &SentReply) -> serde_json::Value {
+    let mut headers = serde_json::Map::new();
+    for (name, value) in response.headers() {
+        let Ok(value) = value.to_str() else { continue };
+        match headers.get_mut(name.as_str()) {
+            Some(serde_json::Value::String(existing)) => {
+                existing.push_str(", ");
+                existing.push_str(value);
+            }
+            _ => {
+                headers.insert(name.as_str().to_string(), value.into());
+            }
+        }
+    }
+    let mut summary = serde_json::json!({
+        "status": response.status().as_u16(),
+        "source": sent.source,
+        "headers": headers,
+        "bodySize": sent.body_size,
+    });
+    if sent.delay_ms > 0 {
+        summary["delayMs"] = sent.delay_ms.into();
+    }
+    summary
+}
+
+/// Store the reply with its request in the background.
+fn record_response(state: &AppState, slug: &str, request_id: String, summary: serde_json::Value) {
+    let pool = state.pool.clone();
+    let slug = slug.to_string();
+    tokio::spawn(async move {
+        let result = sqlx::query("SELECT record_capture_response($1::uuid, $2)")
+            .bind(&request_id)
+            .bind(&summary)
+            .execute(&pool)
+            .await;
+        if let Err(e) = result {
+            tracing::warn!(slug, request_id, error = %e, "failed to record response");
+        }
+    });
+}
+
+/// Owner-configured reply while an endpoint is paused.
 #[derive(Debug, Deserialize)]
+struct PausedResponse {
+    status: i64,
+    #[serde(default)]
+    body: String,
+}
+
+impl PausedResponse {
+    fn response(&self) -> Response {
+        let status = u16::try_from(self.status)
+            .ok()
+            .and_then(|s| StatusCode::from_u16(s).ok())
+            .unwrap_or(StatusCode::SERVICE_UNAVAILABLE);
+        (
+            status,
+            [(
+                axum::http::header::CONTENT_TYPE,
+                "text/plain; charset=utf-8",
+            )],
+            self.body.clone(),
+        )
+            .into_response()
+    }
+}
+
+/// Reply overrides a request asked for with the reserved `__status` and
+/// `__delay` query parameters. capture_webhook reads and checks them.
+#[derive(Debug, Default, Deserialize)]
+struct ResponseOverride {
+    #[serde(default)]
+    status: Option<u16>,
+    /// Milliseconds, in place of the mock's delay and jitter
+    #[serde(default)]
+    delay: Option<u64>,
+}
+
+impl ResponseOverride {
+    fn apply(&self, response: &mut Response, delay_ms: &mut u64) {
+        if let Some(status) = self.status.and_then(|s| StatusCode::from_u16(s).ok()) {
+            *response.status_mut() = status;
+        }
+        if let Some(delay) = self.delay {
+            *delay_ms = delay.min(MAX_DELAY_MS);
+        }
+    }
+}
+
+/// Quota and expiry details, present when the endpoint opted into info
+/// headers. Timestamps are unix milliseconds.
+#[derive(Debug, Default, Deserialize)]
+struct EndpointInfo {
+    quota_remaining: Option<i64>,
+    quota_limit: Option<i64>,
+    quota_reset: Option<i64>,
+    expires_at: Option<i64>,
+}
+
+impl EndpointInfo {
+    /// Add the `X-Webhook-*` info headers, overriding any mock header of the same name.
+    fn apply(&self, headers: &mut HeaderMap) {
+        let rfc3339 = |ms: i64| {
+            chrono::DateTime::from_timestamp_millis(ms)
+                .map(|t| t.to_rfc3339_opts(chrono::SecondsFormat::Secs, true))
+        };
+        let values = [
+            (
+                "x-webhook-remaining-quota",
+                self.quota_remaining.map(|n| n.to_string()),
+            ),
+            (
+                "x-webhook-quota-limit",
+                self.quota_limit.map(|n| n.to_string()),
+            ),
+            ("x-webhook-quota-reset", self.quota_reset.and_then(rfc3339)),
+            (
+                "x-webhook-endpoint-expires",
+                self.expires_at.and_then(rfc3339),
+            ),
+        ];
+        for (name, value) in values {
+            if let Some(value) = value
+                && let Ok(value) = axum::http::HeaderValue::from_str(&value)
+            {
+                headers.insert(name, value);
+            }
+        }
+    }
+}
+
+#[derive(Debug, Clone, Deserialize)]
 struct MockResponse {
     status: i64,
     body: String,
+    /// Binary body, sent in place of `body`. Only the base reply has one.
+    #[serde(default, rename = "bodyBase64")]
+    body_base64: Option<String>,
+    headers: HashMap<String, String>,
+    #[serde(default)]
+    delay: Option<u64>,
+    /// Up to this many milliseconds added to `delay` at random per request
+    #[serde(default, rename = "delayJitter")]
+    delay_jitter: Option<u64>,
+    #[serde(default, rename = "headerPolicy")]
+    header_policy: HeaderPolicy,
+    /// Replies for requests with a given method (`GET`, `POST`, ...), in
+    /// place of the base reply
+    #[serde(default, rename = "byMethod")]
+    by_method: HashMap<String, MockVariant>,
+    #[serde(default)]
+    correlation: Option<MockCorrelation>,
+    #[serde(default)]
+    variants: Vec<MockVariant>,
+    /// Replies for recurring time windows. Their times and timezones are
+    /// evaluated by capture_webhook, which reports the open window's index;
+    /// only the reply fields are read here.
+    #[serde(default)]
+    schedule: Vec<MockVariant>,
+    /// Conditional replies. Their `match` is evaluated by
+    /// `crate::mock_rules`, which reports the first matching rule's index;
+    /// only the reply fields are read here.
+    #[serde(default)]
+    rules: Vec<MockVariant>,
+    #[serde(default)]
+    sequence: Option<MockSequence>,
+    /// Events streamed in place of the body. Only the base reply has them.
+    #[serde(default)]
+    stream: Option<crate::mock_stream::MockStream>,
+    /// Bodies by media type, picked by the request's `Accept` header in place
+    /// of `body` (see `crate::mock_negotiation`). Only the base reply has them.
+    #[serde(default)]
+    representations: BTreeMap<String, String>,
+}
+
+/// Weighted alternative reply. capture_webhook rolls the weights and reports
+/// the chosen index; the receiver only renders it.
+#[derive(Debug, Clone, Deserialize)]
+struct MockVariant {
+    status: i64,
+    #[serde(default)]
+    body: String,
+    #[serde(default)]
+    headers: HashMap<String, String>,
+    #[serde(default)]
+    delay: Option<u64>,
+    /// Up to this many milliseconds added to `delay` at random per request
+    #[serde(default, rename = "delayJitter")]
+    delay_jitter: Option<u64>,
+}
+
+/// Replies for successive requests. Positions are tracked by
+/// `crate::mock_sequence`, which reports the answering step's index; only the
+/// reply fields are read here.
+#[derive(Debug, Clone, Deserialize)]
+struct MockSequence {
+    steps: Vec<MockVariant>,
+}
+
+/// Response table keyed by a value read from the request
+/// (see `crate::correlate`). Requests whose key is missing or has no entry
+/// get the endpoint's base mock response.
+#[derive(Debug, Clone, Deserialize)]
+struct MockCorrelation {
+    key: String,
+    responses: HashMap<String, CorrelatedResponse>,
+}
+
+#[derive(Debug, Clone, Deserialize)]
+struct CorrelatedResponse {
+    status: i64,
+    #[serde(default)]
+    body: String,
+    #[serde(default)]
     headers: HashMap<String, String>,
     #[serde(default)]
     delay: Option<u64>,
+    /// Up to this many milliseconds added to `delay` at random per request
+    #[serde(default, rename = "delayJitter")]
+    delay_jitter: Option<u64>,
+}
+
+impl MockResponse {
+    /// Pick the reply for this request: the scheduled window open when it
+    /// arrived, then the first conditional rule it matches, then the sequence
+    /// step whose turn it is, then the script's reply, then the reply for its
+    /// method, then the correlated entry when the key matches, then the
+    /// weighted variant capture_webhook picked, otherwise the base response.
+    /// The header policy always applies.
+    fn resolve(
+        &self,
+        request: &crate::correlate::RequestFields<'_>,
+        variant: Option<usize>,
+        window: Option<usize>,
+        rule: Option<usize>,
+        step: Option<usize>,
+        scripted: Option<&crate::mock_script::ScriptReply>,
+    ) -> Cow<'_, MockResponse> {
+        if let Some(w) = window.and_then(|i| self.schedule.get(i)) {
+            return Cow::Owned(self.with_reply(
+                w.status,
+                &w.body,
+                &w.headers,
+                w.delay,
+                w.delay_jitter,
+            ));
+        }
+        if let Some(r) = rule.and_then(|i| self.rules.get(i)) {
+            return Cow::Owned(self.with_reply(
+                r.status,
+                &r.body,
+                &r.headers,
+                r.delay,
+                r.delay_jitter,
+            ));
+        }
+        if let Some(s) = step.and_then(|i| self.sequence.as_ref()?.steps.get(i)) {
+            return Cow::Owned(self.with_reply(
+                s.status,
+                &s.body,
+                &s.headers,
+                s.delay,
+                s.delay_jitter,
+            ));
+        }
+        if let Some(s) = scripted {
+            // Unset fields keep the base reply's, delay included
+            let (delay, delay_jitter) = match s.delay {
+                Some(delay) => (Some(delay), None),
+                None => (self.delay, self.delay_jitter),
+            };
+            let mut reply = self.with_reply(
+                s.status.unwrap_or(self.status),
+                s.body.as_deref().unwrap_or(&self.body),
+                s.headers.as_ref().unwrap_or(&self.headers),
+                delay,
+                delay_jitter,
+            );
+            if s.body.is_none() {
+                reply.body_base64 = self.body_base64.clone();
+                reply.stream = self.stream.clone();
+            }
+            return Cow::Owned(reply);
+        }
+        if let Some(m) = self.by_method.get(request.method) {
+            return Cow::Owned(self.with_reply(
+                m.status,
+                &m.body,
+                &m.headers,
+                m.delay,
+                m.delay_jitter,
+            ));
+        }
+        let entry = self.correlation.as_ref().and_then(|c| {
+            crate::correlate::extract(&c.key, request).and_then(|value| c.responses.get(&value))
+        });
+        if let Some(entry) = entry {
+            let reply = self.with_reply(
+                entry.status,
+                &entry.body,
+                &entry.headers,
+                entry.delay,
+                entry.delay_jitter,
+            );
+            return Cow::Owned(reply);
+        }
+        match variant.and_then(|i| self.variants.get(i)) {
+            Some(v) => {
+                Cow::Owned(self.with_reply(v.status, &v.body, &v.headers, v.delay, v.delay_jitter))
+            }
+            None => Cow::Borrowed(self),
+        }
+    }
+
+    /// This reply with the template placeholders in its body and header
+    /// values rendered for the request (see `crate::mock_template`).
+    fn render_templates(&self, request: &crate::correlate::RequestFields<'_>) -> MockResponse {
+        let render = |text: &str| crate::mock_template::render(text, request);
+        MockResponse {
+            body: render(&self.body),
+            headers: self
+                .headers
+                .iter()
+                .map(|(name, value)| (name.clone(), render(value)))
+                .collect(),
+            ..self.clone()
+        }
+    }
+
+    fn with_reply(
+        &self,
+        status: i64,
+        body: &str,
+        headers: &HashMap<String, String>,
+        delay: Option<u64>,
+        delay_jitter: Option<u64>,
+    ) -> MockResponse {
+        MockResponse {
+            status,
+            body: body.to_string(),
+            body_base64: None,
+            headers: headers.clone(),
+            delay,
+            delay_jitter,
+            header_policy: self.header_policy.clone(),
+            by_method: HashMap::new(),
+            correlation: None,
+            variants: Vec::new(),
+            schedule: Vec::new(),
+            rules: Vec::new(),
+            sequence: None,
+            stream: None,
+            representations: BTreeMap::new(),
+        }
+    }
+}
+
+/// Per-endpoint override of BLOCKED_HEADERS, stored as `mockResponse.headerPolicy`.
+#[derive(Debug, Clone, Default, Deserialize)]
+struct HeaderPolicy {
+    #[serde(default)]
+    allow: Vec<String>,
+    #[serde(default)]
+    block: Vec<String>,
+}
+
+impl HeaderPolicy {
+    /// Whether `name` (lowercase) must be dropped from the mock response.
+    fn blocks(&self, name: &str) -> bool {
+        if self.block.iter().any(|h| h.eq_ignore_ascii_case(name)) {
+            return true;
+        }
+        BLOCKED_HEADERS.contains(&name) && !self.allow.iter().any(|h| h.eq_ignore_ascii_case(name))
+    }
+}
+
+/// Whether a Set-Cookie value scopes itself with a Domain attribute. Mock
+/// cookies are kept host-only so an endpoint can't set cookies for the parent
+/// domain that other sites on it would receive.
+fn cookie_has_domain(value: &str) -> bool {
+    value.split(';').skip(1).any(|attr| {
+        attr.trim()
+            .split('=')
+            .next()
+            .is_some_and(|name| name.trim().eq_ignore_ascii_case("domain"))
+    })
 }
 
 /// Maximum allowed mock response delay (30 seconds).
@@ -158,7 +855,7 @@ fn is_blocked_ip(ip: std::net::IpAddr) -> bool {
             || v4.is_link_local()                      // 169.254.0.0/16 (includes metadata 169.254.169.254)
             || v4.is_broadcast()                       // 255.255.255.255
             || v4.is_unspecified()                     // 0.0.0.0
-            || v4.octets()[0] == 100 && (v4.octets()[1] & 0xC0) == 64  // 100.64.0.0/10 (CGNAT)
+            || v4.octets()[0] == 100 && (v4.octets()[1] & 0xC0) == 64 // 100.64.0.0/10 (CGNAT)
         }
         std::net::IpAddr::V6(v6) => {
             let segs = v6.segments();
@@ -173,13 +870,13 @@ fn is_blocked_ip(ip: std::net::IpAddr) -> bool {
 }
 
 /// Resolved notification target: original URL + resolved addresses for DNS pinning.
-struct ResolvedTarget {
+pub(crate) struct ResolvedTarget {
     /// Original URL (unchanged — preserves hostname for TLS verification).
-    url: String,
+    pub(crate) url: String,
     /// Hostname from the URL (used for `resolve()` pinning).
-    host: String,
+    pub(crate) host: String,
     /// Validated socket addresses to pin DNS resolution to.
-    addrs: Vec<std::net::SocketAddr>,
+    pub(crate) addrs: Vec<std::net::SocketAddr>,
 }
 
 /// Resolve the notification URL's host, validate all IPs are safe, and return
@@ -187,7 +884,7 @@ struct ResolvedTarget {
 ///
 /// The URL is NOT rewritten — reqwest uses `ClientBuilder::resolve_to_addrs()`
 /// to connect to the validated IPs while keeping the original hostname for TLS.
-async fn resolve_notification_target(url: &str) -> Result<ResolvedTarget, &'static str> {
+pub(crate) async fn resolve_notification_target(url: &str) -> Result<ResolvedTarget, &'static str> {
     let parsed = url::Url::parse(url).map_err(|_| "invalid URL")?;
     let host = parsed.host_str().ok_or("no host in URL")?.to_string();
     let port = parsed
@@ -240,6 +937,8 @@ struct NotificationInfo {
     ip: String,
     preview: String,
     received_at: String,
+    /// Marks high-priority captures in the payload; the cooldown still applies.
+    priority: bool,
     /// When set, notifications route through this Cloudflare Worker proxy
     /// so the destination sees a Cloudflare IP instead of the origin server.
     proxy_url: Option<String>,
@@ -250,143 +949,240 @@ struct NotificationInfo {
 /// Fire-and-forget POST to the notification URL with a JSON summary.
 fn spawn_notification(info: NotificationInfo) {
     tokio::spawn(async move {
-        // Rate limit: skip if we notified this endpoint within the cooldown period.
-        // Try Redis first (distributed), fall back to in-memory on error or absence.
-        let mut use_in_memory = info.redis.is_none();
-        if let Some(mut conn) = info.redis.clone() {
-            let key = format!("whcc:notify:{}", info.slug);
-            // 100ms timeout — if Redis doesn't respond on localhost, fall back fast
-            let redis_result = tokio::time::timeout(
-                std::time::Duration::from_millis(100),
-                redis::cmd("SET")
-                    .arg(&key)
-                    .arg("1")
-                    .arg("NX")
-                    .arg("EX")
-                    .arg(1_u64)
-                    .query_async::<Option<String>>(&mut conn),
-            )
-            .await;
+        if !notification_allowed(&info).await {
+            return;
+        }
 
-            match redis_result {
-                Ok(Ok(Some(_))) => {
-                    // Redis admitted the notification — also record in the in-memory
-                    // map so a subsequent Redis error within 1s doesn't cause a duplicate.
-                    let now = std::time::Instant::now();
-                    let mut map = info.limiter.lock().await;
-                    map.insert(info.slug.clone(), now);
-                    if map.len() > NOTIFICATION_LIMITER_MAX {
-                        map.retain(|_, last_time| now.duration_since(*last_time) < NOTIFICATION_COOLDOWN);
-                    }
-                }
-                Ok(Ok(None)) => return,    // cooldown active, skip
-                Ok(Err(e)) => {
-                    tracing::warn!(error = %e, slug = %info.slug, "Redis notification rate limit failed, falling back to in-memory");
-                    use_in_memory = true;
-                }
-                Err(_) => {
-                    tracing::warn!(slug = %info.slug, "Redis notification rate limit timed out, falling back to in-memory");
-                    use_in_memory = true;
+        let payload = serde_json::json!({
+            "slug": info.slug,
+            "method": info.method,
+            "path": info.path,
+            "ip": info.ip,
+            "receivedAt": info.received_at,
+            "preview": info.preview,
+            "priority": info.priority,
+        });
+        let target = NotificationTarget {
+            url: info.url,
+            proxy_url: info.proxy_url,
+            proxy_secret: info.proxy_secret,
+        };
+        deliver_notification(&target, &payload, &info.ip, &info.slug).await;
+    });
+}
+
+/// Per-endpoint cooldown: false when this endpoint was notified within
+/// `NOTIFICATION_COOLDOWN`. Tries Redis first (distributed), falling back to
+/// the in-memory map on error or absence.
+async fn notification_allowed(info: &NotificationInfo) -> bool {
+    let mut use_in_memory = info.redis.is_none();
+    if let Some(mut conn) = info.redis.clone() {
+        let key = format!("whcc:notify:{}", info.slug);
+        // 100ms timeout — if Redis doesn't respond on localhost, fall back fast
+        let redis_result = tokio::time::timeout(
+            std::time::Duration::from_millis(100),
+            redis::cmd("SET")
+                .arg(&key)
+                .arg("1")
+                .arg("NX")
+                .arg("EX")
+                .arg(1_u64)
+                .query_async::<Option<String>>(&mut conn),
+        )
+        .await;
+
+        match redis_result {
+            Ok(Ok(Some(_))) => {
+                // Redis admitted the notification — also record in the in-memory
+                // map so a subsequent Redis error within 1s doesn't cause a duplicate.
+                let now = std::time::Instant::now();
+                let mut map = info.limiter.lock().await;
+                map.insert(info.slug.clone(), now);
+                if map.len() > NOTIFICATION_LIMITER_MAX {
+                    map.retain(|_, last_time| {
+                        now.duration_since(*last_time) < NOTIFICATION_COOLDOWN
+                    });
                 }
             }
-        }
-        if use_in_memory {
-            let mut map = info.limiter.lock().await;
-            let now = std::time::Instant::now();
-            if let Some(last) = map.get(&info.slug)
-                && now.duration_since(*last) < NOTIFICATION_COOLDOWN
-            {
-                return;
+            Ok(Ok(None)) => return false, // cooldown active, skip
+            Ok(Err(e)) => {
+                tracing::warn!(error = %e, slug = %info.slug, "Redis notification rate limit failed, falling back to in-memory");
+                use_in_memory = true;
             }
-            map.insert(info.slug.clone(), now);
-
-            // Prune stale entries to prevent unbounded memory growth
-            if map.len() > NOTIFICATION_LIMITER_MAX {
-                map.retain(|_, last_time| now.duration_since(*last_time) < NOTIFICATION_COOLDOWN);
+            Err(_) => {
+                tracing::warn!(slug = %info.slug, "Redis notification rate limit timed out, falling back to in-memory");
+                use_in_memory = true;
             }
         }
+    }
+    if use_in_memory {
+        let mut map = info.limiter.lock().await;
+        let now = std::time::Instant::now();
+        if let Some(last) = map.get(&info.slug)
+            && now.duration_since(*last) < NOTIFICATION_COOLDOWN
+        {
+            return false;
+        }
+        map.insert(info.slug.clone(), now);
 
-        // Wrap DNS resolution + POST in a single 5s timeout so slow DNS
-        // can't keep fire-and-forget tasks alive past the budget.
-        let slug_ref = info.slug.clone();
-        let result = tokio::time::timeout(std::time::Duration::from_secs(5), async {
-            let payload = serde_json::json!({
-                "slug": info.slug,
-                "method": info.method,
-                "path": info.path,
-                "ip": info.ip,
-                "receivedAt": info.received_at,
-                "preview": info.preview,
-            });
-
-            // Route through Cloudflare Worker proxy when configured,
-            // otherwise deliver directly with SSRF-safe DNS pinning.
-            if let Some(ref proxy_url) = info.proxy_url {
-                let client = reqwest::Client::builder()
-                    .timeout(std::time::Duration::from_secs(4))
-                    .redirect(reqwest::redirect::Policy::none())
-                    .build()
-                    .map_err(|_| "failed to build client")?;
-
-                let mut req = client
-                    .post(proxy_url)
-                    .header("X-Target-URL", &info.url)
-                    .json(&payload);
-
-                if let Some(ref secret) = info.proxy_secret {
-                    req = req.header("X-Auth", secret.as_str());
-                }
-                if !info.ip.is_empty() {
-                    req = req.header("X-Sender-IP", &info.ip);
-                }
+        // Prune stale entries to prevent unbounded memory growth
+        if map.len() > NOTIFICATION_LIMITER_MAX {
+            map.retain(|_, last_time| now.duration_since(*last_time) < NOTIFICATION_COOLDOWN);
+        }
+    }
+    true
+}
 
-                req.send().await.map_err(|_| "proxy POST failed")?;
-            } else {
-                // Direct delivery with SSRF protection
-                let target = resolve_notification_target(&info.url).await?;
-
-                let pinned_client = reqwest::Client::builder()
-                    .timeout(std::time::Duration::from_secs(4))
-                    .redirect(reqwest::redirect::Policy::none())
-                    .resolve_to_addrs(&target.host, &target.addrs)
-                    .build()
-                    .map_err(|_| "failed to build client")?;
-
-                let mut req = pinned_client
-                    .post(&target.url)
-                    .json(&payload);
-
-                if !info.ip.is_empty() {
-                    req = req.header("X-Sender-IP", &info.ip);
-                }
+/// Where a notification is delivered.
+pub(crate) struct NotificationTarget {
+    pub url: String,
+    /// When set, notifications route through this Cloudflare Worker proxy
+    /// so the destination sees a Cloudflare IP instead of the origin server.
+    pub proxy_url: Option<String>,
+    /// Shared secret for authenticating with the proxy.
+    pub proxy_secret: Option<String>,
+}
 
-                req.send().await.map_err(|_| "POST failed")?;
+/// POST `payload` to a notification URL. Failures are logged at debug level
+/// and otherwise ignored.
+pub(crate) async fn deliver_notification(
+    target: &NotificationTarget,
+    payload: &serde_json::Value,
+    sender_ip: &str,
+    slug: &str,
+) {
+    // Wrap DNS resolution + POST in a single 5s timeout so slow DNS
+    // can't keep fire-and-forget tasks alive past the budget.
+    let result = tokio::time::timeout(std::time::Duration::from_secs(5), async {
+        // Route through Cloudflare Worker proxy when configured,
+        // otherwise deliver directly with SSRF-safe DNS pinning.
+        if let Some(ref proxy_url) = target.proxy_url {
+            let client = reqwest::Client::builder()
+                .timeout(std::time::Duration::from_secs(4))
+                .redirect(reqwest::redirect::Policy::none())
+                .build()
+                .map_err(|_| "failed to build client")?;
+
+            let mut req = client
+                .post(proxy_url)
+                .header("X-Target-URL", &target.url)
+                .json(payload);
+
+            if let Some(ref secret) = target.proxy_secret {
+                req = req.header("X-Auth", secret.as_str());
+            }
+            if !sender_ip.is_empty() {
+                req = req.header("X-Sender-IP", sender_ip);
             }
 
-            Ok::<(), &'static str>(())
-        })
-        .await;
+            req.send().await.map_err(|_| "proxy POST failed")?;
+        } else {
+            // Direct delivery with SSRF protection
+            let resolved = resolve_notification_target(&target.url).await?;
 
-        match result {
-            Ok(Err(reason)) => {
-                tracing::debug!(slug = slug_ref, reason, "notification delivery failed");
-            }
-            Err(_) => {
-                tracing::debug!(slug = slug_ref, "notification timed out");
+            let pinned_client = reqwest::Client::builder()
+                .timeout(std::time::Duration::from_secs(4))
+                .redirect(reqwest::redirect::Policy::none())
+                .resolve_to_addrs(&resolved.host, &resolved.addrs)
+                .build()
+                .map_err(|_| "failed to build client")?;
+
+            let mut req = pinned_client.post(&resolved.url).json(payload);
+
+            if !sender_ip.is_empty() {
+                req = req.header("X-Sender-IP", sender_ip);
             }
-            Ok(Ok(())) => {}
+
+            req.send().await.map_err(|_| "POST failed")?;
         }
-    });
+
+        Ok::<(), &'static str>(())
+    })
+    .await;
+
+    match result {
+        Ok(Err(reason)) => {
+            tracing::debug!(slug, reason, "notification delivery failed");
+        }
+        Err(_) => {
+            tracing::debug!(slug, "notification timed out");
+        }
+        Ok(Ok(())) => {}
+    }
+}
+
+/// Expiry of a valid quota bypass token on this request, if any. Every
+/// attempt is logged; invalid tokens are ignored and the request is captured
+/// under normal quota rules.
+fn quota_bypass(
+    state: &AppState,
+    headers: &HeaderMap,
+    slug: &str,
+    ip: &str,
+    now: chrono::DateTime<Utc>,
+) -> Option<chrono::DateTime<Utc>> {
+    let token = headers.get(crate::bypass::BYPASS_HEADER)?.to_str().ok()?;
+    let Some(ref secret) = state.config.quota_bypass_secret else {
+        tracing::warn!(
+            slug,
+            ip,
+            "quota bypass token sent but QUOTA_BYPASS_SECRET is not set"
+        );
+        return None;
+    };
+    match crate::bypass::verify(secret, token, slug, now) {
+        Ok(expires) => {
+            tracing::info!(slug, ip, token_expires = %expires, "quota bypass used");
+            Some(expires)
+        }
+        Err(reason) => {
+            tracing::warn!(slug, ip, reason, "quota bypass token rejected");
+            None
+        }
+    }
+}
+
+/// Hand a captured request to the function sink dispatcher.
+/// Unknown providers and disabled dispatchers are logged and skipped.
+fn dispatch_function_sink(
+    state: &AppState,
+    slug: &str,
+    tenant: Option<Arc<str>>,
+    sink_config: serde_json::Value,
+    payload: serde_json::Value,
+    received_at: chrono::DateTime<Utc>,
+) {
+    let Some(ref dispatcher) = state.function_sink else {
+        tracing::debug!(
+            slug,
+            "function sink configured but no AWS credentials, skipping"
+        );
+        return;
+    };
+    match serde_json::from_value::<crate::function_sink::FunctionSink>(sink_config) {
+        Ok(sink) => dispatcher.dispatch(slug.to_string(), tenant, sink, payload, received_at),
+        Err(e) => tracing::warn!(slug, error = %e, "invalid function_sink configuration"),
+    }
 }
 
-/// Build an HTTP response from a mock_response configuration.
-fn build_mock_response(mock: &MockResponse) -> Response {
+/// Refuse a request over its account's in-flight limits.
+fn too_many_in_flight(slug: &str, headers: &HeaderMap) -> Response {
+    tracing::info!(slug, "account in-flight limit reached");
+    let mut response = ReceiverError::TooManyInFlight.respond(headers);
+    response
+        .headers_mut()
+        .insert("retry-after", axum::http::HeaderValue::from_static("1"));
+    response
+}
+
+/// Render a mock_response configuration into the reply to send.
+fn render_mock(mock: &MockResponse) -> RenderedMock {
     let status_code = u16::try_from(mock.status)
         .ok()
         .and_then(|s| StatusCode::from_u16(s).ok())
         .unwrap_or(StatusCode::OK);
 
-    let mut builder = axum::http::Response::builder().status(status_code);
+    let mut headers = HeaderMap::new();
 
     for (key, value) in &mock.headers {
         // Skip oversized headers
@@ -394,9 +1190,12 @@ fn build_mock_response(mock: &MockResponse) -> Response {
             continue;
         }
 
-        // Skip blocked headers
+        // Skip headers blocked by the endpoint's policy
         let key_lower = key.to_lowercase();
-        if BLOCKED_HEADERS.contains(&key_lower.as_str()) {
+        if mock.header_policy.blocks(&key_lower) {
+            continue;
+        }
+        if key_lower == "set-cookie" && cookie_has_domain(value) {
             continue;
         }
 
@@ -406,76 +1205,352 @@ fn build_mock_response(mock: &MockResponse) -> Response {
             continue;
         }
 
-        builder = builder.header(key.as_str(), value.as_str());
+        match (
+            axum::http::HeaderName::from_bytes(key.as_bytes()),
+            axum::http::HeaderValue::from_str(value),
+        ) {
+            (Ok(name), Ok(value)) => {
+                headers.append(name, value);
+            }
+            // An unusable header fails the whole mock
+            _ => return RenderedMock::plain_ok(mock.delay, mock.delay_jitter),
+        }
     }
 
-    builder
-        .body(axum::body::Body::from(mock.body.clone()))
-        .unwrap_or_else(|_| {
-            axum::http::Response::builder()
-                .status(StatusCode::OK)
-                .body(axum::body::Body::from("OK"))
-                .unwrap()
-        })
+    let body = match mock.body_base64 {
+        Some(ref encoded) => {
+            let Ok(decoded) = base64::engine::general_purpose::STANDARD.decode(encoded) else {
+                return RenderedMock::plain_ok(mock.delay, mock.delay_jitter);
+            };
+            // Binary bodies without a declared type are sent as opaque bytes
+            if !headers.contains_key(axum::http::header::CONTENT_TYPE) {
+                headers.insert(
+                    axum::http::header::CONTENT_TYPE,
+                    axum::http::HeaderValue::from_static("application/octet-stream"),
+                );
+            }
+            Bytes::from(decoded)
+        }
+        None => Bytes::from(mock.body.clone()),
+    };
+    let stream = mock.stream.as_ref().map(|stream| {
+        if let Some(content_type) = stream.content_type()
+            && !headers.contains_key(axum::http::header::CONTENT_TYPE)
+        {
+            headers.insert(
+                axum::http::header::CONTENT_TYPE,
+                axum::http::HeaderValue::from_static(content_type),
+            );
+        }
+        stream.frames()
+    });
+
+    RenderedMock {
+        status: status_code,
+        headers,
+        body,
+        stream,
+        delay: mock.delay,
+        delay_jitter: mock.delay_jitter,
+    }
+}
+
+/// The reply for a request to an endpoint with a mock response, from the
+/// cache when the request is a repeatable probe and the mock has no
+/// template placeholders, script or echo. `echo` is set when the mock
+/// echoes requests; it then replaces the base reply's body, as does the
+/// representation the request's `Accept` header picks.
+#[allow(clippy::too_many_arguments)]
+async fn mock_reply(
+    state: &AppState,
+    slug: &str,
+    method: &Method,
+    mock: &serde_json::Value,
+    variant: Option<usize>,
+    window: Option<usize>,
+    canary: bool,
+    request: &crate::correlate::RequestFields<'_>,
+    echo: Option<&crate::mock_echo::Echo<'_>>,
+) -> Arc<RenderedMock> {
+    let rule = crate::mock_rules::first_match(mock.get("rules"), request);
+    // Only requests the sequence answers use up a step
+    let step = if window.is_none() && rule.is_none() {
+        state.sequences.next(slug, mock.get("sequence"), request)
+    } else {
+        None
+    };
+    let script = mock.get("script").and_then(serde_json::Value::as_str);
+    let accept = request.headers.get("accept").map(String::as_str);
+    let representation = mock
+        .get("representations")
+        .and_then(serde_json::Value::as_object)
+        .and_then(|types| crate::mock_negotiation::choose(types.keys().map(String::as_str), accept))
+        .map(str::to_string);
+    let templated = crate::mock_template::in_value(mock);
+    let cacheable = !templated
+        && script.is_none()
+        && echo.is_none()
+        && crate::mock_cache::cacheable(method, request.body.as_bytes());
+    let key = cacheable.then(|| MockKey {
+        slug: slug.to_string(),
+        method: method.clone(),
+        path: request.path.to_string(),
+        correlation: mock
+            .pointer("/correlation/key")
+            .and_then(serde_json::Value::as_str)
+            .and_then(|key| crate::correlate::extract(key, request)),
+        representation: representation.clone(),
+        variant,
+        window,
+        rule,
+        step,
+        canary,
+    });
+    if let Some(ref key) = key
+        && let Some(rendered) = state.caches.mocks.get(key)
+    {
+        return rendered;
+    }
+
+    // The script only runs when nothing ahead of it answers
+    let scripted = match script {
+        Some(source) if window.is_none() && rule.is_none() && step.is_none() => {
+            match state.scripts.run(slug, source, request).await {
+                Ok(reply) => reply,
+                Err(e) => {
+                    tracing::info!(slug, error = %e, "mock script failed");
+                    return Arc::new(RenderedMock::script_failed(&e));
+                }
+            }
+        }
+        _ => None,
+    };
+
+    let rendered = match MockResponse::deserialize(mock) {
+        Ok(mock) => {
+            let reply = mock.resolve(request, variant, window, rule, step, scripted.as_ref());
+            let base = matches!(reply, Cow::Borrowed(_));
+            // Script replies are sent as the script built them
+            let mut rendered = if templated && scripted.is_none() {
+                render_mock(&reply.render_templates(request))
+            } else {
+                render_mock(&reply)
+            };
+            if let Some(echo) = echo.filter(|_| base) {
+                rendered.body = echo.body();
+                rendered.stream = None;
+                rendered.headers.insert(
+                    axum::http::header::CONTENT_TYPE,
+                    axum::http::HeaderValue::from_static("application/json"),
+                );
+            }
+            if base && !reply.representations.is_empty() {
+                // The body depends on Accept, even when the base one answers
+                rendered.headers.append(
+                    axum::http::header::VARY,
+                    axum::http::HeaderValue::from_static("accept"),
+                );
+                if let Some(media_type) = representation.as_deref()
+                    && let Some(body) = reply.representations.get(media_type)
+                    && let Ok(content_type) = axum::http::HeaderValue::from_str(media_type)
+                {
+                    rendered.body = if templated {
+                        Bytes::from(crate::mock_template::render(body, request))
+                    } else {
+                        Bytes::from(body.clone())
+                    };
+                    rendered
+                        .headers
+                        .insert(axum::http::header::CONTENT_TYPE, content_type);
+                }
+            }
+            Arc::new(rendered)
+        }
+        Err(e) => {
+            tracing::warn!(slug, error = %e, "invalid mock_response configuration");
+            return Arc::new(RenderedMock::plain_ok(None, None));
+        }
+    };
+    if let Some(key) = key {
+        state.caches.mocks.insert(key, rendered.clone());
+    }
+    rendered
 }
 
 /// The main webhook handler: any method at /w/{slug}/{*path}
+#[allow(clippy::too_many_arguments)]
 pub async fn handle_webhook(
     State(state): State<AppState>,
     method: Method,
-    Path((slug, path)): Path<(String, String)>,
+    version: Version,
+    uri: Uri,
+    Path((slug, _path)): Path<(String, String)>,
     headers: HeaderMap,
     query: axum::extract::Query<HashMap<String, String>>,
-    body: Bytes,
+    client_cert: Option<Extension<Arc<ClientCert>>>,
+    tls: Option<Extension<Arc<TlsInfo>>>,
+    body: Body,
 ) -> Response {
-    handle_webhook_inner(state, method, slug, path, headers, query, body).await
+    let client_cert = client_cert.map(|Extension(cert)| cert);
+    let tls = tls.map(|Extension(tls)| tls);
+    handle_webhook_inner(
+        state,
+        method,
+        version,
+        slug,
+        uri,
+        headers,
+        query,
+        client_cert,
+        tls,
+        body,
+    )
+    .await
 }
 
 /// Handle the case where no trailing path is provided: /w/{slug}
+#[allow(clippy::too_many_arguments)]
 pub async fn handle_webhook_no_path(
     State(state): State<AppState>,
     method: Method,
+    version: Version,
+    uri: Uri,
     Path(slug): Path<String>,
     headers: HeaderMap,
     query: axum::extract::Query<HashMap<String, String>>,
-    body: Bytes,
+    client_cert: Option<Extension<Arc<ClientCert>>>,
+    tls: Option<Extension<Arc<TlsInfo>>>,
+    body: Body,
+) -> Response {
+    let client_cert = client_cert.map(|Extension(cert)| cert);
+    let tls = tls.map(|Extension(tls)| tls);
+    handle_webhook_inner(
+        state,
+        method,
+        version,
+        slug,
+        uri,
+        headers,
+        query,
+        client_cert,
+        tls,
+        body,
+    )
+    .await
+}
+
+/// Answer CORS preflights from the endpoint's CORS config and add its
+/// headers to whatever the capture responds.
+#[allow(clippy::too_many_arguments)]
+async fn handle_webhook_inner(
+    state: AppState,
+    method: Method,
+    version: Version,
+    slug: String,
+    uri: Uri,
+    headers: HeaderMap,
+    query: axum::extract::Query<HashMap<String, String>>,
+    client_cert: Option<Arc<ClientCert>>,
+    tls: Option<Arc<TlsInfo>>,
+    body: Body,
 ) -> Response {
-    handle_webhook_inner(state, method, slug, String::new(), headers, query, body).await
+    // 1. Validate and normalize slug to lowercase (case-insensitive matching)
+    let slug = slug.to_ascii_lowercase();
+    let origin = headers.get(axum::http::header::ORIGIN).cloned();
+    if !is_valid_slug(&slug) {
+        let mut response = ReceiverError::InvalidSlug.respond(&headers);
+        state
+            .caches
+            .cors
+            .fallback()
+            .apply(origin.as_ref(), &mut response);
+        return response;
+    }
+
+    // Preflights are the browser asking, not the client under test sending:
+    // they are answered here and never captured.
+    let cors = state.caches.cors.get(&state.pool, &slug).await;
+    if cors::is_preflight(&method, &headers) {
+        return cors.preflight(&headers);
+    }
+
+    let mut response = capture(
+        state,
+        method,
+        version,
+        slug,
+        uri,
+        headers,
+        query,
+        client_cert,
+        tls,
+        body,
+    )
+    .await;
+    cors.apply(origin.as_ref(), &mut response);
+    response
 }
 
-async fn handle_webhook_inner(
+#[allow(clippy::too_many_arguments)]
+async fn capture(
     state: AppState,
     method: Method,
+    version: Version,
     slug: String,
-    path: String,
+    uri: Uri,
     headers: HeaderMap,
     query: axum::extract::Query<HashMap<String, String>>,
-    body: Bytes,
+    client_cert: Option<Arc<ClientCert>>,
+    tls: Option<Arc<TlsInfo>>,
+    body: Body,
 ) -> Response {
-    // 1. Validate and normalize slug to lowercase (case-insensitive matching)
-    let slug = slug.to_ascii_lowercase();
-    if !is_valid_slug(&slug) {
-        return (
-            StatusCode::BAD_REQUEST,
-            axum::Json(serde_json::json!({"error": "invalid_slug"})),
-        )
-            .into_response();
+    // Endpoints with a CA bundle only take requests with a certificate it issued
+    let client_cert = match client_cert_record(&state, &slug, client_cert.as_deref()).await {
+        Ok(record) => record,
+        Err(e) => return e.respond(&headers),
+    };
+    if let Err(refused) = check_capture_auth(&state, &slug, &headers).await {
+        return refused.respond(&headers);
     }
 
-    // 2. Normalize path
-    let req_path = if path.is_empty() {
-        "/".to_string()
-    } else if path.starts_with('/') {
-        path.clone()
-    } else {
-        format!("/{path}")
+    // 2. Normalize path from the raw URI (axum's {*path} is already decoded,
+    // which would turn %2F into a real separator)
+    let (req_path, path_truncated) =
+        crate::path::captured_path_checked(uri.path(), state.config.max_path_length);
+
+    // Count the request against its account's share of the instance until the
+    // response is sent. Unknown slugs aren't counted; capture_webhook refuses them.
+    let mut permit = match state.tenants.tenant(&state.pool, &slug).await {
+        Some(tenant) => match state.tenants.admit(tenant) {
+            Some(permit) => Some(permit),
+            None => return too_many_in_flight(&slug, &headers),
+        },
+        None => None,
     };
 
-    // 3. Extract request data
+    // 3. Extract request data. The body is read only after the slug checks
+    // out, so Expect: 100-continue senders aren't told to upload for nothing.
+    let body_limit = state.caches.body_limits.limit(&state.pool, &slug).await;
+    let (body, trailers, received_size) = match read_body(body, body_limit, &headers).await {
+        Ok(read) => read,
+        Err(response) => return response,
+    };
+    let body_cut = received_size > body.len();
+    if let Some(ref mut permit) = permit
+        && !permit.hold_bytes(body.len())
+    {
+        return too_many_in_flight(&slug, &headers);
+    }
+    if let Some(ref mirror) = state.mirror {
+        mirror.tee(&method, &uri, &headers, body.clone());
+    }
     let ip = real_ip(&headers);
-    let filtered_headers = filter_headers(&headers);
+    let geo = client_geo(&state, &headers, &ip);
+    let mut filtered_headers = filter_headers(&headers);
+    let mut trailers = filter_trailers(trailers.as_ref());
     // Try exact UTF-8 first; only store raw bytes when the payload isn't valid UTF-8
-    let (body_str, body_raw): (String, Option<Vec<u8>>) = match String::from_utf8(body.to_vec()) {
+    let (mut body_str, body_raw): (String, Option<Vec<u8>>) = match String::from_utf8(body.to_vec())
+    {
         Ok(s) => (s, None),
         Err(e) => {
             let lossy = String::from_utf8_lossy(e.as_bytes()).into_owned();
@@ -489,28 +1564,278 @@ async fn handle_webhook_inner(
         .to_string();
     let received_at = Utc::now();
 
+    // Check the sender's signature over the body as received. Endpoints that
+    // reject bad signatures refuse them before anything is stored.
+    let signature = state.caches.signatures.get(&state.pool, &slug).await;
+    let signature_valid = match signature {
+        Some(ref config) => {
+            let valid = config.verify(&headers, &body, received_at);
+            if !valid && config.reject {
+                tracing::info!(slug, ip = %ip, "rejected invalid signature");
+                return ReceiverError::InvalidSignature.respond(&headers);
+            }
+            Some(valid)
+        }
+        None => None,
+    };
+    // Validate the sender's JWT the same way; its claims are kept either way.
+    let mut jwt = match state.caches.jwts.get(&state.pool, &slug).await {
+        Some(config) => {
+            let verdict = state
+                .caches
+                .jwts
+                .verify(&config, &headers, received_at)
+                .await;
+            if !verdict.valid && config.reject {
+                tracing::info!(slug, ip = %ip, "rejected invalid JWT");
+                return ReceiverError::InvalidJwt.respond(&headers);
+            }
+            Some(verdict)
+        }
+        None => None,
+    };
+    // Check JSON bodies against the endpoint's schema, as received. Bodies
+    // too large to store inline or cut at the endpoint's limit aren't parsed.
+    let schema = match state.caches.schemas.get(&state.pool, &slug).await {
+        Some(config) if !body_cut && body.len() <= crate::MAX_BODY_SIZE => {
+            let verdict = config.validate(&body);
+            Some((config, verdict))
+        }
+        _ => None,
+    };
+    // Requests the endpoint's capture filter leaves out are answered and
+    // counted by capture_webhook, but not stored.
+    let filtered = match state.caches.capture_filters.get(&state.pool, &slug).await {
+        Some(filter) => !filter.captures(&crate::capture_filter::FilterRequest {
+            method: &method,
+            path: &req_path,
+            headers: &headers,
+            body: &body_str,
+        }),
+        None => false,
+    };
+    // Busy endpoints may store only a sample; the rest are counted by
+    // capture_webhook. Filtered requests don't use up the sample.
+    let sampled_out = !filtered
+        && match state.caches.sampling.get(&state.pool, &slug).await {
+            Some(sampler) => !sampler.keep(received_at.timestamp()),
+            None => false,
+        };
+    let bypass_expires = quota_bypass(&state, &headers, &slug, &ip, received_at);
+
+    // Bodies over the inline limit only get past the body limit when object
+    // storage is configured, and are stored there instead of in the row.
+    let offload = state
+        .body_store
+        .as_ref()
+        .filter(|_| body.len() > crate::MAX_BODY_SIZE);
+
+    // Apply the endpoint's capture-time transforms. Raw (non-UTF-8) and
+    // offloaded bodies are stored byte-for-byte, so only their headers are
+    // rewritten.
+    if let Some(transforms) = state.caches.transforms.get(&state.pool, &slug).await {
+        if body_raw.is_some() || offload.is_some() {
+            let mut unused = String::new();
+            crate::transform::apply(&transforms, &mut filtered_headers, &mut unused);
+        } else {
+            crate::transform::apply(&transforms, &mut filtered_headers, &mut body_str);
+        }
+    }
+
+    // Redact sensitive headers and what the endpoint lists before anything
+    // below (hashes, fingerprints, storage, notifications, sinks) sees it.
+    // Headers the endpoint encrypts are sealed further down instead. Mock
+    // correlation reads the request as received. Raw bodies are stored as
+    // received.
+    let encryption = state.caches.header_encryption.get(&state.pool, &slug).await;
+    let sealed = encryption
+        .as_ref()
+        .map_or(&[][..], |policy| &policy.headers[..]);
+    let redactor = state.caches.redactions.get(&state.pool, &slug).await;
+    let mut redactions = crate::redact::Fired::new();
+    let mut received_headers = None;
+    let mut received_body = None;
+    let mut redacted_query = None;
+    let mut redacted = filtered_headers.clone();
+    if crate::redact::redact_headers(redactor.as_deref(), sealed, &mut redacted, &mut redactions) {
+        received_headers = Some(std::mem::replace(&mut filtered_headers, redacted));
+    }
+    if let Some(ref mut trailers) = trailers {
+        crate::redact::redact_headers(redactor.as_deref(), sealed, trailers, &mut redactions);
+    }
+    if let Some(ref redactor) = redactor {
+        let mut query_params = query.0.clone();
+        if redactor.redact_map(&mut query_params, &mut redactions) {
+            redacted_query = Some(query_params);
+        }
+        if body_raw.is_none() {
+            let mut redacted = body_str.clone();
+            if redactor.redact_body(&mut redacted, offload.is_none(), &mut redactions) {
+                received_body = Some(std::mem::replace(&mut body_str, redacted));
+            }
+        }
+        if let Some(claims) = jwt.as_mut().and_then(|verdict| verdict.claims.as_mut()) {
+            redactor.redact_value(claims, &mut redactions);
+        }
+    }
+
+    let body_hash = body_hash(&body_str, body_raw.as_deref());
+    let body_ref = offload.map(|_| crate::body_store::object_key(&body_hash));
+    // For endpoints that compare JSON bodies canonically (capture_webhook
+    // decides); offloaded bodies aren't parsed.
+    let canonical_hash = match (&body_raw, offload) {
+        (None, None) => crate::canonical::canonical_hash(&body_str),
+        _ => None,
+    };
+    let (stored_body, stored_raw) = match body_ref {
+        Some(_) => ("", None),
+        None => (body_str.as_str(), body_raw.as_deref()),
+    };
+
+    // Sizes as received and as kept, so a body that looks cut off can be
+    // explained. Raw bodies are stored byte-for-byte.
+    let mut sizes = CaptureSizes {
+        headers: header_bytes(&headers),
+        body: received_size,
+        stored: stored_raw.map_or(stored_body.len(), <[u8]>::len),
+        truncated: BTreeMap::new(),
+    };
+    if path_truncated {
+        sizes.truncated.insert("path", "max_length");
+    }
+    if received_headers.is_some() {
+        sizes.truncated.insert("headers", "redacted");
+    }
+    if redacted_query.is_some() {
+        sizes.truncated.insert("query", "redacted");
+    }
+    if body_cut {
+        sizes.truncated.insert("body", "max_size");
+    } else if offload.is_some() {
+        sizes.truncated.insert("body", "offloaded");
+    } else if received_body.is_some() {
+        sizes.truncated.insert("body", "redacted");
+    } else if stored_raw.is_none() && stored_body.as_bytes() != &body[..] {
+        sizes.truncated.insert("body", "transformed");
+    }
+
+    // Describe each part of a multipart body, from the bytes as received.
+    let mut parts = crate::multipart::boundary(&content_type)
+        .and_then(|b| crate::multipart::parse(&body, &b, state.config.multipart_inline_bytes))
+        .and_then(|parts| serde_json::to_value(parts).ok());
+
+    // Provider delivery ID, from the headers and body as received, so
+    // capture_webhook can link retries whose body changed.
+    let delivery_key = crate::idempotency::delivery_key(&headers, &body);
+
+    // Lets clients pick a renderer (and searches filter) without sniffing
+    // the body themselves.
+    let content_class = crate::content_class::classify(&content_type, &body);
+
+    // Groups captures of the same kind, from the body as stored. Raw and
+    // offloaded bodies are fingerprinted by media type.
+    let fingerprint = crate::fingerprint::fingerprint(
+        method.as_str(),
+        &req_path,
+        &content_type,
+        (body_raw.is_none() && offload.is_none()).then_some(body_str.as_str()),
+    );
+
+    // CloudEvents attributes, from the headers and body as received.
+    let mut cloud_event = crate::cloudevents::detect(&headers, &content_type, &body)
+        .and_then(|event| serde_json::to_value(event).ok());
+
+    // Provider and event type, from the headers and body as received. When
+    // no provider names the event, a CloudEvent's `type` does.
+    let detected = webhooks_providers::detect(
+        |name| headers.get(name).and_then(|v| v.to_str().ok()),
+        &body,
+    );
+    let provider = detected.as_ref().map(|d| d.provider.id());
+    let event_type = detected.and_then(|d| d.event_type).or_else(|| {
+        cloud_event
+            .as_ref()
+            .and_then(|event| event["type"].as_str())
+            .map(str::to_string)
+    });
+    // Part descriptions and CloudEvent attributes come from the body as
+    // received, so they're redacted on their own.
+    if let Some(ref redactor) = redactor {
+        for value in [parts.as_mut(), cloud_event.as_mut()].into_iter().flatten() {
+            redactor.redact_value(value, &mut redactions);
+        }
+    }
+
+    // Encrypt the headers the endpoint lists as sensitive. Only the stored (and
+    // sink-bound) copy is sealed; mock correlation below reads the originals.
+    // Trailers are sealed under the same policy.
+    let sealed_headers = encryption.as_ref().map(|policy| {
+        crate::header_crypt::seal(state.header_cipher.as_deref(), policy, &filtered_headers)
+    });
+    let trailers_json = trailers.and_then(|trailers| {
+        let trailers = match &encryption {
+            Some(policy) => {
+                crate::header_crypt::seal(state.header_cipher.as_deref(), policy, &trailers)
+            }
+            None => trailers,
+        };
+        serde_json::to_value(trailers).ok()
+    });
+
     // Serialize headers and query params as JSON values
-    let headers_json = serde_json::to_value(&filtered_headers).unwrap_or(serde_json::Value::Object(
-        serde_json::Map::new(),
-    ));
-    let query_json = serde_json::to_value(&query.0).unwrap_or(serde_json::Value::Object(
-        serde_json::Map::new(),
-    ));
+    let headers_json = serde_json::to_value(sealed_headers.as_ref().unwrap_or(&filtered_headers))
+        .unwrap_or(serde_json::Value::Object(serde_json::Map::new()));
+    let query_json = serde_json::to_value(redacted_query.as_ref().unwrap_or(&query.0))
+        .unwrap_or(serde_json::Value::Object(serde_json::Map::new()));
 
     // 4. Call the stored procedure
     let result: Result<serde_json::Value, sqlx::Error> = sqlx::query_scalar(
-        "SELECT capture_webhook($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+        "SELECT capture_webhook($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38, $39)",
     )
     .bind(&slug)
     .bind(method.as_str())
     .bind(&req_path)
     .bind(&headers_json)
-    .bind(&body_str)
+    .bind(stored_body)
     .bind(&query_json)
     .bind(&content_type)
     .bind(&ip)
     .bind(received_at)
-    .bind(&body_raw)
+    .bind(stored_raw)
+    .bind(bypass_expires)
+    .bind(&body_hash)
+    .bind(&parts)
+    .bind(&geo.country)
+    .bind(&body_ref)
+    .bind(body_ref.as_ref().map(|_| body.len() as i32))
+    .bind(None::<serde_json::Value>)
+    .bind(&cloud_event)
+    .bind(&canonical_hash)
+    .bind(http_version(version))
+    .bind(&client_cert)
+    .bind(&trailers_json)
+    .bind(&delivery_key)
+    .bind(&fingerprint)
+    .bind(provider)
+    .bind(&event_type)
+    .bind(content_class)
+    .bind(signature_valid)
+    .bind(jwt.as_ref().map(|verdict| verdict.valid))
+    .bind(jwt.and_then(|verdict| verdict.claims))
+    .bind(schema.as_ref().map(|(_, verdict)| verdict.valid))
+    .bind(
+        schema
+            .as_ref()
+            .filter(|(_, verdict)| !verdict.valid)
+            .and_then(|(_, verdict)| serde_json::to_value(&verdict.errors).ok()),
+    )
+    .bind(serde_json::to_value(&sizes).ok())
+    .bind((!redactions.is_empty()).then(|| serde_json::to_value(&redactions).ok()).flatten())
+    .bind(geo.asn.map(i64::from))
+    .bind(&geo.as_org)
+    .bind(tls.and_then(|tls| serde_json::to_value(&*tls).ok()))
+    .bind(filtered)
+    .bind(sampled_out)
     .fetch_one(&state.pool)
     .await;
 
@@ -527,6 +1852,30 @@ async fn handle_webhook_inner(
 
             match capture.status.as_str() {
                 "ok" => {
+                    if capture.auto_extend {
+                        state.activity.record(&slug, received_at);
+                    }
+
+                    // Upload an offloaded body before answering, so a 200 means
+                    // it is stored. A failed upload is reported like a lost capture.
+                    // Dry runs, filtered and sampled-out requests store nothing,
+                    // so there is nothing to upload.
+                    if let (Some(store), Some(key)) = (offload, &body_ref)
+                        && !capture.dry_run
+                        && !capture.filtered
+                        && !capture.sampled_out
+                    {
+                        // A redacted body is uploaded as redacted
+                        let bytes = match received_body {
+                            Some(_) => Bytes::from(body_str.clone()),
+                            None => body.clone(),
+                        };
+                        if let Err(e) = store.put(key, bytes, &content_type).await {
+                            tracing::error!(slug, key, error = %e, "failed to upload request body");
+                            state.capture_failures.record(&slug, received_at);
+                        }
+                    }
+
                     // Fire notification webhook if configured
                     if let Some(ref url) = capture.notification_url
                         && !url.is_empty()
@@ -542,40 +1891,215 @@ async fn handle_webhook_inner(
                             ip: ip.clone(),
                             preview,
                             received_at: received_at.to_rfc3339(),
+                            priority: capture.priority,
                             proxy_url: state.config.notify_proxy_url.clone(),
                             proxy_secret: state.config.notify_secret.clone(),
                         });
                     }
 
-                    if let Some(mock) = &capture.mock_response {
-                        if let Some(delay) = mock.delay {
-                            let capped = delay.min(MAX_DELAY_MS);
-                            if capped > 0 {
-                                tokio::time::sleep(std::time::Duration::from_millis(capped)).await;
-                            }
+                    // Invoke the endpoint's function sink if configured
+                    if let Some(sink_config) = capture.function_sink {
+                        dispatch_function_sink(
+                            &state,
+                            &slug,
+                            permit.as_ref().map(|p| p.tenant().clone()),
+                            sink_config,
+                            serde_json::json!({
+                                "source": "webhooks.cc",
+                                "slug": slug,
+                                "method": method.as_str(),
+                                "path": req_path,
+                                "headers": headers_json,
+                                "body": body_str,
+                                "queryParams": query_json,
+                                "contentType": content_type,
+                                "ip": ip,
+                                "receivedAt": received_at.to_rfc3339(),
+                            }),
+                            received_at,
+                        );
+                    }
+
+                    // An injected fault replaces whatever reply the request
+                    // would have got; requests.chaos_fault records it
+                    if let Some(fault) = capture.chaos {
+                        tracing::info!(slug, fault = fault.name(), "injecting chaos fault");
+                        return fault.respond().await;
+                    }
+
+                    // Bodies that fail the endpoint's schema get its failure
+                    // reply instead of the mock
+                    let schema_failure = schema.as_ref().and_then(|(config, verdict)| {
+                        config
+                            .failure_response
+                            .as_ref()
+                            .filter(|_| !verdict.valid)
+                            .map(|failure| failure.response(&verdict.errors))
+                    });
+
+                    // Provider verification requests get the answer the provider
+                    // expects, ahead of the schema failure reply and the mock
+                    let handshake = if capture.handshake {
+                        let zoom_secret = signature.as_deref().map(|config| config.secret());
+                        crate::handshake::detect(&method, &query.0, &body, zoom_secret)
+                    } else {
+                        None
+                    };
+
+                    // Requests over the endpoint's simulated rate limit get its
+                    // 429 ahead of any other reply
+                    let throttled = capture.rate_limit.as_ref().and_then(RateLimit::response);
+
+                    // Endpoints with a backend answer with its reply. Requests
+                    // the receiver relayed itself aren't relayed again
+                    let secret = &state.config.capture_shared_secret;
+                    let relayed = crate::proxy::already_relayed(secret, &headers, received_at);
+                    let forwarded = match capture.forward_url {
+                        Some(ref url)
+                            if throttled.is_none()
+                                && handshake.is_none()
+                                && schema_failure.is_none()
+                                && !relayed =>
+                        {
+                            let request = crate::proxy::Forward {
+                                method: &method,
+                                path: &req_path,
+                                query: uri.query(),
+                                headers: &headers,
+                                body: body.clone(),
+                                ip: &ip,
+                                marker: crate::proxy::marker(secret, &slug, received_at),
+                            };
+                            Some(crate::proxy::forward(url, request).await)
                         }
-                        build_mock_response(mock)
+                        _ => None,
+                    };
+
+                    let mut sent = SentReply {
+                        source: "default",
+                        delay_ms: 0,
+                        body_size: 2,
+                    };
+                    let mut response = if let Some((response, body_size)) = throttled {
+                        tracing::debug!(slug, "simulated rate limit reached");
+                        sent = SentReply {
+                            source: "rate_limit",
+                            delay_ms: 0,
+                            body_size,
+                        };
+                        response
+                    } else if let Some(handshake) = handshake {
+                        tracing::info!(
+                            slug,
+                            provider = handshake.provider,
+                            "answering provider handshake"
+                        );
+                        sent = SentReply {
+                            source: "handshake",
+                            delay_ms: 0,
+                            body_size: handshake.body_size(),
+                        };
+                        handshake.response()
+                    } else if let Some((response, body_size)) = schema_failure {
+                        sent = SentReply {
+                            source: "schema",
+                            delay_ms: 0,
+                            body_size,
+                        };
+                        response
+                    } else if let Some(ref exchange) = forwarded {
+                        let (response, body_size) = exchange.response().unwrap_or_else(|| {
+                            tracing::info!(slug, "forward URL failed to answer");
+                            let response = ReceiverError::UpstreamFailed.respond(&headers);
+                            let size = response.body().size_hint().exact().unwrap_or(0);
+                            (response, size as usize)
+                        });
+                        sent = SentReply {
+                            source: "proxy",
+                            delay_ms: 0,
+                            body_size,
+                        };
+                        response
+                    } else if let Some(mock) = &capture.mock_response {
+                        let request = crate::correlate::RequestFields {
+                            method: method.as_str(),
+                            path: &req_path,
+                            headers: received_headers.as_ref().unwrap_or(&filtered_headers),
+                            query: &query.0,
+                            body: received_body.as_deref().unwrap_or(&body_str),
+                        };
+                        // Echoed requests are reflected as sent, before transforms
+                        let echoes = mock.get("echo") == Some(&serde_json::Value::Bool(true));
+                        let sent_headers = echoes.then(|| filter_headers(&headers));
+                        let echo = sent_headers.as_ref().map(|sent| crate::mock_echo::Echo {
+                            method: method.as_str(),
+                            path: &req_path,
+                            query: &query.0,
+                            headers: sent,
+                            body: &body,
+                            ip: &ip,
+                        });
+                        let mock = mock_reply(
+                            &state,
+                            &slug,
+                            &method,
+                            mock,
+                            capture.mock_variant,
+                            capture.mock_window,
+                            capture.mock_canary,
+                            &request,
+                            echo.as_ref(),
+                        )
+                        .await;
+                        sent = SentReply {
+                            source: "mock",
+                            delay_ms: mock.delay_ms(MAX_DELAY_MS),
+                            body_size: mock.body_size(),
+                        };
+                        mock.response()
                     } else {
                         (StatusCode::OK, "OK").into_response()
+                    };
+                    // `__status` and `__delay` on the request win over the reply
+                    if let Some(ref overrides) = capture.response_override {
+                        overrides.apply(&mut response, &mut sent.delay_ms);
+                    }
+                    // The capture is already stored and handed to forwarding,
+                    // so only this reply waits
+                    if sent.delay_ms > 0 {
+                        tokio::time::sleep(std::time::Duration::from_millis(sent.delay_ms)).await;
+                    }
+                    if let Some(ref info) = capture.info {
+                        info.apply(response.headers_mut());
                     }
+                    if let Some(ref rate_limit) = capture.rate_limit {
+                        rate_limit.apply(response.headers_mut());
+                    }
+                    if let Some(request_id) = capture.record_response_id {
+                        let mut summary = response_summary(&response, &sent);
+                        if let Some(ref exchange) = forwarded {
+                            exchange.record(&mut summary);
+                        }
+                        record_response(&state, &slug, request_id, summary);
+                    }
+                    response
+                }
+                "not_found" if is_reserved_slug(&slug) => {
+                    ReceiverError::ReservedSlug.respond(&headers)
+                }
+                "not_found" => ReceiverError::NotFound.respond(&headers),
+                "expired" => ReceiverError::Expired.respond(&headers),
+                "paused" => match capture.paused_response {
+                    Some(ref paused) => paused.response(),
+                    None => ReceiverError::Paused.respond(&headers),
+                },
+                "blocked" => {
+                    tracing::info!(slug, ip = %ip, "rejected by network policy");
+                    ReceiverError::Blocked.respond(&headers)
                 }
-                "not_found" => (
-                    StatusCode::NOT_FOUND,
-                    axum::Json(serde_json::json!({"error": "not_found"})),
-                )
-                    .into_response(),
-                "expired" => (
-                    StatusCode::GONE,
-                    axum::Json(serde_json::json!({"error": "expired"})),
-                )
-                    .into_response(),
                 "quota_exceeded" => {
                     tracing::info!(slug, ip = %ip, "quota exceeded");
-                    let mut response = (
-                        StatusCode::TOO_MANY_REQUESTS,
-                        axum::Json(serde_json::json!({"error": "quota_exceeded"})),
-                    )
-                        .into_response();
+                    let mut response = ReceiverError::QuotaExceeded.respond(&headers);
 
                     if let Some(retry_after_ms) = capture.retry_after {
                         let retry_after_secs = (retry_after_ms + 999) / 1000; // ceil to seconds
@@ -585,6 +2109,9 @@ async fn handle_webhook_inner(
                             response.headers_mut().insert("retry-after", val);
                         }
                     }
+                    if let Some(ref info) = capture.info {
+                        info.apply(response.headers_mut());
+                    }
 
                     response
                 }
@@ -595,8 +2122,10 @@ async fn handle_webhook_inner(
             }
         }
         Err(e) => {
-            // Fail open: return 200 so the sender doesn't retry
+            // Fail open: return 200 so the sender doesn't retry. The loss is
+            // reported to the endpoint owner by the capture failure tracker.
             tracing::error!(slug, error = %e, "capture_webhook query failed");
+            state.capture_failures.record(&slug, received_at);
             (StatusCode::OK, "OK").into_response()
         }
     }
@@ -624,6 +2153,27 @@ mod tests {
         assert!(!is_valid_slug("has.dot"));
     }
 
+    #[test]
+    fn reserved_slugs() {
+        assert!(is_reserved_slug("api"));
+        assert!(is_reserved_slug("whk-demo"));
+        assert!(is_reserved_slug("internal-billing"));
+        assert!(!is_reserved_slug("apis"));
+        assert!(!is_reserved_slug("my-stripe-dev"));
+    }
+
+    #[test]
+    fn client_country_from_cloudflare() {
+        use axum::http::HeaderValue;
+
+        let mut headers = HeaderMap::new();
+        assert_eq!(client_country(&headers), None);
+        headers.insert("cf-ipcountry", HeaderValue::from_static("de"));
+        assert_eq!(client_country(&headers).as_deref(), Some("DE"));
+        headers.insert("cf-ipcountry", HeaderValue::from_static("DE; drop"));
+        assert_eq!(client_country(&headers), None);
+    }
+
     #[test]
     fn real_ip_extraction() {
         use axum::http::HeaderValue;
@@ -660,6 +2210,13 @@ mod tests {
         assert_eq!(real_ip(&headers), "");
     }
 
+    #[test]
+    fn http_version_labels() {
+        assert_eq!(http_version(Version::HTTP_11), Some("HTTP/1.1"));
+        assert_eq!(http_version(Version::HTTP_2), Some("HTTP/2"));
+        assert_eq!(http_version(Version::HTTP_09), None);
+    }
+
     #[test]
     fn header_filtering() {
         use axum::http::HeaderValue;
@@ -677,11 +2234,82 @@ mod tests {
         assert!(!filtered.contains_key("x-forwarded-for"));
     }
 
+    #[test]
+    fn trailers_are_kept_apart_from_headers() {
+        let mut trailers = HeaderMap::new();
+        trailers.insert("x-checksum", "from-trailer".parse().unwrap());
+        trailers.insert("grpc-status", "0".parse().unwrap());
+        trailers.insert("x-forwarded-for", "10.0.0.1".parse().unwrap());
+
+        let filtered = filter_trailers(Some(&trailers)).unwrap();
+
+        assert_eq!(filtered.get("x-checksum").unwrap(), "from-trailer");
+        assert_eq!(filtered.get("grpc-status").unwrap(), "0");
+        assert!(!filtered.contains_key("x-forwarded-for"));
+
+        assert!(filter_trailers(None).is_none());
+        let mut proxy_only = HeaderMap::new();
+        proxy_only.insert("cf-ray", "abc".parse().unwrap());
+        assert!(filter_trailers(Some(&proxy_only)).is_none());
+    }
+
+    #[tokio::test]
+    async fn read_body_returns_trailers() {
+        let mut trailers = HeaderMap::new();
+        trailers.insert("x-signature", "sig".parse().unwrap());
+        let body = Body::new(
+            http_body_util::Full::new(Bytes::from("hello world"))
+                .with_trailers(std::future::ready(Some(Ok(trailers)))),
+        );
+
+        let (bytes, trailers, received) = read_body(body, usize::MAX, &HeaderMap::new())
+            .await
+            .unwrap();
+        assert_eq!(bytes, Bytes::from("hello world"));
+        assert_eq!(received, 11);
+        assert_eq!(trailers.unwrap().get("x-signature").unwrap(), "sig");
+    }
+
+    #[tokio::test]
+    async fn read_body_without_trailers() {
+        let (bytes, trailers, _) = read_body(Body::from("plain"), usize::MAX, &HeaderMap::new())
+            .await
+            .unwrap();
+        assert_eq!(bytes, Bytes::from("plain"));
+        assert!(trailers.is_none());
+    }
+
+    #[tokio::test]
+    async fn read_body_keeps_up_to_the_limit() {
+        let (bytes, _, received) = read_body(Body::from("hello world"), 8, &HeaderMap::new())
+            .await
+            .unwrap();
+        assert_eq!(bytes, Bytes::from("hello wo"));
+        assert_eq!(received, 11);
+    }
+
+    #[test]
+    fn body_hash_covers_stored_bytes() {
+        assert_eq!(body_hash("{\"a\":1}", None), body_hash("{\"a\":1}", None));
+        assert_ne!(body_hash("{\"a\":1}", None), body_hash("{\"a\":2}", None));
+        assert_eq!(
+            body_hash("", None),
+            "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+        );
+        // Raw bodies hash their bytes, not the lossy text stored alongside
+        let raw = [0xff, 0xfe];
+        assert_ne!(
+            body_hash("\u{fffd}\u{fffd}", Some(&raw)),
+            body_hash("\u{fffd}\u{fffd}", None)
+        );
+    }
+
     #[test]
     fn mock_response_blocks_security_headers() {
         let mock = MockResponse {
             status: 200,
             body: "test".to_string(),
+            body_base64: None,
             headers: HashMap::from([
                 ("content-type".to_string(), "text/plain".to_string()),
                 (
@@ -699,9 +2327,19 @@ mod tests {
                 ("x-custom".to_string(), "allowed".to_string()),
             ]),
             delay: None,
+            delay_jitter: None,
+            header_policy: HeaderPolicy::default(),
+            by_method: HashMap::new(),
+            correlation: None,
+            variants: Vec::new(),
+            schedule: Vec::new(),
+            rules: Vec::new(),
+            sequence: None,
+            stream: None,
+            representations: BTreeMap::new(),
         };
 
-        let response = build_mock_response(&mock);
+        let response = render_mock(&mock).response();
         let headers = response.headers();
         assert!(headers.get("content-type").is_some());
         assert!(headers.get("x-custom").is_some());
@@ -710,11 +2348,598 @@ mod tests {
         assert!(headers.get("content-security-policy").is_none());
     }
 
+    #[test]
+    fn mock_response_header_policy_overrides_defaults() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 302,
+            "body": "",
+            "headers": {
+                "Set-Cookie": "session=abc; Path=/; HttpOnly",
+                "x-frame-options": "DENY",
+                "x-powered-by": "mock",
+                "location": "/done"
+            },
+            "headerPolicy": {"allow": ["set-cookie"], "block": ["X-Powered-By"]}
+        }))
+        .unwrap();
+
+        let response = render_mock(&mock).response();
+        let headers = response.headers();
+        assert_eq!(
+            headers.get("set-cookie").unwrap(),
+            "session=abc; Path=/; HttpOnly"
+        );
+        assert!(headers.get("x-frame-options").is_none());
+        assert!(headers.get("x-powered-by").is_none());
+        assert!(headers.get("location").is_some());
+    }
+
+    #[test]
+    fn allowed_cookies_must_be_host_only() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 200,
+            "body": "",
+            "headers": {"set-cookie": "session=abc; Domain=.webhooks.cc; Path=/"},
+            "headerPolicy": {"allow": ["set-cookie"]}
+        }))
+        .unwrap();
+
+        assert!(
+            render_mock(&mock)
+                .response()
+                .headers()
+                .get("set-cookie")
+                .is_none()
+        );
+        assert!(!cookie_has_domain("domain=abc; Path=/"));
+        assert!(cookie_has_domain("a=b; domain = example.com"));
+    }
+
+    #[test]
+    fn mock_response_correlates_on_request_field() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 200,
+            "body": "default",
+            "headers": {},
+            "headerPolicy": {"block": ["x-debug"]},
+            "correlation": {
+                "key": "body.json.order_id",
+                "responses": {
+                    "ord_1": {"status": 202, "body": "accepted", "headers": {"x-debug": "1"}},
+                    "ord_2": {"status": 409}
+                }
+            }
+        }))
+        .unwrap();
+        let (headers, query) = (HashMap::new(), HashMap::new());
+        let request = |body| crate::correlate::RequestFields {
+            method: "POST",
+            path: "/",
+            headers: &headers,
+            query: &query,
+            body,
+        };
+
+        let resolve = |body| {
+            mock.resolve(&request(body), None, None, None, None, None)
+                .into_owned()
+        };
+
+        let hit = resolve(r#"{"order_id":"ord_1"}"#);
+        assert_eq!(hit.status, 202);
+        assert_eq!(hit.body, "accepted");
+        let response = render_mock(&hit).response();
+        assert!(response.headers().get("x-debug").is_none());
+
+        assert_eq!(resolve(r#"{"order_id":"ord_2"}"#).status, 409);
+
+        let miss = resolve(r#"{"order_id":"ord_3"}"#);
+        assert_eq!((miss.status, miss.body.as_str()), (200, "default"));
+        assert_eq!(resolve("not json").status, 200);
+    }
+
+    #[test]
+    fn mock_response_renders_picked_variant() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 200,
+            "body": "ok",
+            "headers": {},
+            "headerPolicy": {"block": ["x-debug"]},
+            "variants": [
+                {"name": "rate_limited", "weight": 20, "status": 429, "headers": {"x-debug": "1"}},
+                {"name": "slow", "weight": 10, "status": 200, "body": "late", "delay": 500}
+            ]
+        }))
+        .unwrap();
+        let (headers, query) = (HashMap::new(), HashMap::new());
+        let request = crate::correlate::RequestFields {
+            method: "GET",
+            path: "/",
+            headers: &headers,
+            query: &query,
+            body: "",
+        };
+
+        let limited = mock.resolve(&request, Some(0), None, None, None, None);
+        assert_eq!(limited.status, 429);
+        assert!(
+            render_mock(&limited)
+                .response()
+                .headers()
+                .get("x-debug")
+                .is_none()
+        );
+        assert_eq!(
+            mock.resolve(&request, Some(1), None, None, None, None)
+                .delay,
+            Some(500)
+        );
+        assert_eq!(
+            mock.resolve(&request, None, None, None, None, None).body,
+            "ok"
+        );
+        // An index past the end (configuration changed mid-flight) falls back to the base
+        assert_eq!(
+            mock.resolve(&request, Some(5), None, None, None, None)
+                .status,
+            200
+        );
+
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": null,
+            "mock_variant": 1
+        }))
+        .unwrap();
+        assert_eq!(capture.mock_variant, Some(1));
+    }
+
+    #[test]
+    fn mock_response_open_window_wins() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 200,
+            "body": "ok",
+            "headers": {},
+            "variants": [{"name": "rate_limited", "weight": 20, "status": 429}],
+            "schedule": [{
+                "name": "maintenance",
+                "start": "02:00",
+                "end": "03:00",
+                "timezone": "Europe/Berlin",
+                "status": 503,
+                "headers": {"retry-after": "3600"}
+            }]
+        }))
+        .unwrap();
+        let (headers, query) = (HashMap::new(), HashMap::new());
+        let request = crate::correlate::RequestFields {
+            method: "POST",
+            path: "/",
+            headers: &headers,
+            query: &query,
+            body: "",
+        };
+
+        let down = mock.resolve(&request, Some(0), Some(0), None, None, None);
+        assert_eq!((down.status, down.body.as_str()), (503, ""));
+        assert!(
+            render_mock(&down)
+                .response()
+                .headers()
+                .get("retry-after")
+                .is_some()
+        );
+        assert_eq!(
+            mock.resolve(&request, Some(0), None, None, None, None)
+                .status,
+            429
+        );
+        assert_eq!(
+            mock.resolve(&request, None, Some(3), None, None, None)
+                .status,
+            200
+        );
+
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": null,
+            "mock_window": 0
+        }))
+        .unwrap();
+        assert_eq!(capture.mock_window, Some(0));
+        assert!(!capture.mock_canary);
+    }
+
+    #[test]
+    fn capture_result_reports_canary_bucket() {
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": {"status": 202, "body": "v2", "headers": {}},
+            "mock_canary": true
+        }))
+        .unwrap();
+        assert!(capture.mock_canary);
+    }
+
+    #[test]
+    fn endpoint_info_sets_headers() {
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": null,
+            "retry_after": null,
+            "notification_url": null,
+            "info": {"quota_remaining": 42, "quota_limit": 200, "expires_at": 1767225600000i64}
+        }))
+        .unwrap();
+        let mut headers = HeaderMap::new();
+        headers.insert("x-webhook-remaining-quota", "spoofed".parse().unwrap());
+        capture.info.unwrap().apply(&mut headers);
+
+        assert_eq!(headers.get("x-webhook-remaining-quota").unwrap(), "42");
+        assert_eq!(headers.get("x-webhook-quota-limit").unwrap(), "200");
+        assert_eq!(
+            headers.get("x-webhook-endpoint-expires").unwrap(),
+            "2026-01-01T00:00:00Z"
+        );
+        assert!(headers.get("x-webhook-quota-reset").is_none());
+    }
+
+    #[test]
+    fn capture_result_priority_defaults_off() {
+        let capture: CaptureResult =
+            serde_json::from_value(serde_json::json!({"status": "ok", "priority": true})).unwrap();
+        assert!(capture.priority);
+        let capture: CaptureResult =
+            serde_json::from_value(serde_json::json!({"status": "ok"})).unwrap();
+        assert!(!capture.priority);
+        assert!(!capture.dry_run);
+        assert!(!capture.auto_extend);
+    }
+
+    #[test]
+    fn capture_result_dry_run() {
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": {"status": 202},
+            "retry_after": null,
+            "info": null,
+            "dry_run": true
+        }))
+        .unwrap();
+        assert!(capture.dry_run);
+        assert!(capture.notification_url.is_none());
+        assert!(capture.forward_url.is_none());
+        assert!(capture.record_response_id.is_none());
+    }
+
+    #[test]
+    fn capture_result_filtered() {
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": {"status": 200},
+            "retry_after": null,
+            "info": null,
+            "filtered": true
+        }))
+        .unwrap();
+        assert!(capture.filtered);
+        assert!(!capture.dry_run);
+        assert!(capture.notification_url.is_none());
+    }
+
+    #[test]
+    fn capture_result_sampled_out() {
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": null,
+            "retry_after": null,
+            "info": null,
+            "sampled_out": true
+        }))
+        .unwrap();
+        assert!(capture.sampled_out);
+        assert!(!capture.filtered);
+        assert!(capture.record_response_id.is_none());
+    }
+
+    #[test]
+    fn capture_result_chaos() {
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": null,
+            "chaos": {"fault": "error", "status": 503},
+            "retry_after": null,
+            "info": null
+        }))
+        .unwrap();
+        assert_eq!(
+            capture.chaos,
+            Some(crate::chaos::Fault::Error { status: 503 })
+        );
+
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": null,
+            "chaos": null,
+            "retry_after": null
+        }))
+        .unwrap();
+        assert!(capture.chaos.is_none());
+    }
+
+    #[test]
+    fn capture_result_override_replaces_status_and_delay() {
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": null,
+            "override": {"status": 503, "delay": 90000}
+        }))
+        .unwrap();
+        let mut response = (StatusCode::ACCEPTED, "queued").into_response();
+        let mut delay_ms = 250;
+        capture
+            .response_override
+            .unwrap()
+            .apply(&mut response, &mut delay_ms);
+        assert_eq!(response.status(), StatusCode::SERVICE_UNAVAILABLE);
+        assert_eq!(delay_ms, MAX_DELAY_MS);
+
+        let overrides = ResponseOverride {
+            status: Some(418),
+            delay: None,
+        };
+        overrides.apply(&mut response, &mut delay_ms);
+        assert_eq!(response.status(), StatusCode::IM_A_TEAPOT);
+        assert_eq!(delay_ms, MAX_DELAY_MS);
+    }
+
+    #[test]
+    fn capture_result_without_info() {
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "ok",
+            "mock_response": null,
+            "retry_after": null,
+            "notification_url": null,
+            "info": null
+        }))
+        .unwrap();
+        assert!(capture.info.is_none());
+    }
+
+    #[test]
+    fn paused_capture_uses_configured_reply() {
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "paused",
+            "paused_response": {"status": 202, "body": "maintenance"}
+        }))
+        .unwrap();
+        let response = capture.paused_response.unwrap().response();
+        assert_eq!(response.status(), StatusCode::ACCEPTED);
+
+        let capture: CaptureResult = serde_json::from_value(serde_json::json!({
+            "status": "paused",
+            "paused_response": null
+        }))
+        .unwrap();
+        assert!(capture.paused_response.is_none());
+    }
+
+    #[test]
+    fn mock_response_renders_templates() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 201,
+            "body": "{\"id\":\"{{.Body.json \"order.id\"}}\"}",
+            "headers": {"x-request-id": "{{.Request.Header \"X-Id\"}}"}
+        }))
+        .unwrap();
+        let headers = HashMap::from([("x-id".to_string(), "evt_1".to_string())]);
+        let query = HashMap::new();
+        let request = crate::correlate::RequestFields {
+            method: "POST",
+            path: "/",
+            headers: &headers,
+            query: &query,
+            body: r#"{"order":{"id":"ord_1"}}"#,
+        };
+
+        let rendered = render_mock(&mock.render_templates(&request));
+        assert_eq!(rendered.body, r#"{"id":"ord_1"}"#);
+        assert_eq!(rendered.headers.get("x-request-id").unwrap(), "evt_1");
+    }
+
+    #[test]
+    fn mock_response_rule_comes_after_window() {
+        let value = serde_json::json!({
+            "status": 200,
+            "body": "ok",
+            "headers": {},
+            "correlation": {"key": "method", "responses": {"POST": {"status": 202}}},
+            "schedule": [{
+                "name": "maintenance",
+                "start": "02:00",
+                "end": "03:00",
+                "timezone": "UTC",
+                "status": 503
+            }],
+            "rules": [{
+                "match": {"path": "/refunds/*"},
+                "status": 409,
+                "body": "{\"error\":\"duplicate\"}"
+            }]
+        });
+        let mock: MockResponse = serde_json::from_value(value.clone()).unwrap();
+        let (headers, query) = (HashMap::new(), HashMap::new());
+        let request = crate::correlate::RequestFields {
+            method: "POST",
+            path: "/refunds/1",
+            headers: &headers,
+            query: &query,
+            body: "",
+        };
+
+        let rule = crate::mock_rules::first_match(value.get("rules"), &request);
+        assert_eq!(rule, Some(0));
+        let matched = mock.resolve(&request, None, None, rule, None, None);
+        assert_eq!(
+            (matched.status, matched.body.as_str()),
+            (409, "{\"error\":\"duplicate\"}")
+        );
+        assert_eq!(
+            mock.resolve(&request, None, Some(0), rule, None, None)
+                .status,
+            503
+        );
+        // Requests no rule matches fall through to the correlation table
+        assert_eq!(
+            mock.resolve(&request, None, None, None, None, None).status,
+            202
+        );
+    }
+
+    #[test]
+    fn mock_response_sequence_comes_before_correlation() {
+        let value = serde_json::json!({
+            "status": 200,
+            "body": "ok",
+            "headers": {},
+            "correlation": {"key": "method", "responses": {"POST": {"status": 202}}},
+            "sequence": {"steps": [{"status": 500, "count": 2}, {"status": 503}]}
+        });
+        let mock: MockResponse = serde_json::from_value(value.clone()).unwrap();
+        let (headers, query) = (HashMap::new(), HashMap::new());
+        let request = crate::correlate::RequestFields {
+            method: "POST",
+            path: "/",
+            headers: &headers,
+            query: &query,
+            body: "",
+        };
+
+        let tracker = crate::mock_sequence::SequenceTracker::new();
+        let statuses: Vec<_> = (0..4)
+            .map(|_| {
+                let step = tracker.next("s", value.get("sequence"), &request);
+                mock.resolve(&request, None, None, None, step, None).status
+            })
+            .collect();
+        // Once the steps are used up, the correlation table answers
+        assert_eq!(statuses, [500, 500, 503, 202]);
+    }
+
+    #[test]
+    fn mock_response_script_reply_keeps_unset_fields() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 200,
+            "body": "ok",
+            "headers": {"content-type": "text/plain"},
+            "delay": 100,
+            "correlation": {"key": "method", "responses": {"POST": {"status": 202}}}
+        }))
+        .unwrap();
+        let (headers, query) = (HashMap::new(), HashMap::new());
+        let request = crate::correlate::RequestFields {
+            method: "POST",
+            path: "/",
+            headers: &headers,
+            query: &query,
+            body: "",
+        };
+
+        let scripted = crate::mock_script::ScriptReply {
+            status: Some(409),
+            ..Default::default()
+        };
+        let reply = mock.resolve(&request, None, None, None, None, Some(&scripted));
+        assert_eq!((reply.status, reply.body.as_str()), (409, "ok"));
+        assert_eq!(reply.headers["content-type"], "text/plain");
+        assert_eq!(reply.delay, Some(100));
+        // A script that returns () leaves the reply to correlation
+        assert_eq!(
+            mock.resolve(&request, None, None, None, None, None).status,
+            202
+        );
+    }
+
+    #[test]
+    fn mock_response_by_method_comes_before_correlation() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 200,
+            "body": "ok",
+            "headers": {"content-type": "text/plain"},
+            "byMethod": {
+                "GET": {"status": 200, "body": "{\"id\":\"o_1\"}", "headers": {"content-type": "application/json"}},
+                "DELETE": {"status": 204}
+            },
+            "correlation": {"key": "method", "responses": {"DELETE": {"status": 202}, "PUT": {"status": 409}}}
+        }))
+        .unwrap();
+        let (headers, query) = (HashMap::new(), HashMap::new());
+        let resolve = |method| {
+            let request = crate::correlate::RequestFields {
+                method,
+                path: "/",
+                headers: &headers,
+                query: &query,
+                body: "",
+            };
+            let reply = mock.resolve(&request, None, None, None, None, None);
+            (reply.status, reply.body.clone())
+        };
+
+        assert_eq!(resolve("GET"), (200, "{\"id\":\"o_1\"}".to_string()));
+        assert_eq!(resolve("DELETE"), (204, String::new()));
+        // Methods without an entry fall through to correlation and the base reply
+        assert_eq!(resolve("PUT").0, 409);
+        assert_eq!(resolve("POST"), (200, "ok".to_string()));
+    }
+
+    #[test]
+    fn mock_response_binary_body() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 200,
+            "body": "",
+            "bodyBase64": "iVBORw0KGgo=",
+            "headers": {}
+        }))
+        .unwrap();
+        let rendered = render_mock(&mock);
+        assert_eq!(&rendered.body[..], b"\x89PNG\r\n\x1a\n");
+        assert_eq!(rendered.headers["content-type"], "application/octet-stream");
+
+        let typed = MockResponse {
+            headers: HashMap::from([("Content-Type".to_string(), "image/png".to_string())]),
+            ..mock.clone()
+        };
+        assert_eq!(render_mock(&typed).headers["content-type"], "image/png");
+
+        let broken = MockResponse {
+            body_base64: Some("not base64!".to_string()),
+            ..mock
+        };
+        assert_eq!(&render_mock(&broken).body[..], b"OK");
+    }
+
+    #[test]
+    fn mock_response_stream_replaces_body() {
+        let mock: MockResponse = serde_json::from_value(serde_json::json!({
+            "status": 200,
+            "body": "unused",
+            "headers": {"Cache-Control": "no-cache"},
+            "stream": {"events": [{"event": "done", "data": "ok"}]}
+        }))
+        .unwrap();
+        let rendered = render_mock(&mock);
+        assert_eq!(rendered.headers["content-type"], "text/event-stream");
+        assert_eq!(rendered.headers["cache-control"], "no-cache");
+        assert_eq!(rendered.body_size(), "event: done\ndata: ok\n\n".len());
+    }
+
     #[test]
     fn mock_response_blocks_crlf_injection() {
         let mock = MockResponse {
             status: 200,
             body: "test".to_string(),
+            body_base64: None,
             headers: HashMap::from([
                 ("good-header".to_string(), "safe-value".to_string()),
                 (
@@ -724,9 +2949,19 @@ mod tests {
                 ("bad\r\nkey".to_string(), "value".to_string()),
             ]),
             delay: None,
+            delay_jitter: None,
+            header_policy: HeaderPolicy::default(),
+            by_method: HashMap::new(),
+            correlation: None,
+            variants: Vec::new(),
+            schedule: Vec::new(),
+            rules: Vec::new(),
+            sequence: None,
+            stream: None,
+            representations: BTreeMap::new(),
         };
 
-        let response = build_mock_response(&mock);
+        let response = render_mock(&mock).response();
         let headers = response.headers();
         assert!(headers.get("good-header").is_some());
         assert!(headers.get("bad-header").is_none());
@@ -763,6 +2998,78 @@ mod tests {
         assert_eq!(truncate_preview("", 200), "");
     }
 
+    #[test]
+    fn header_bytes_counts_names_values_and_separators() {
+        let mut headers = HeaderMap::new();
+        headers.insert("content-type", "application/json".parse().unwrap());
+        headers.append("x-tag", "a".parse().unwrap());
+        headers.append("x-tag", "bc".parse().unwrap());
+        // "content-type: application/json\r\n" + "x-tag: a\r\n" + "x-tag: bc\r\n"
+        assert_eq!(header_bytes(&headers), 32 + 10 + 11);
+        assert_eq!(header_bytes(&HeaderMap::new()), 0);
+    }
+
+    #[test]
+    fn capture_sizes_omit_empty_truncation() {
+        let mut sizes = CaptureSizes {
+            headers: 40,
+            body: 10,
+            stored: 10,
+            truncated: BTreeMap::new(),
+        };
+        assert_eq!(
+            serde_json::to_value(&sizes).unwrap(),
+            serde_json::json!({"headers": 40, "body": 10, "stored": 10})
+        );
+        sizes.stored = 0;
+        sizes.truncated.insert("body", "offloaded");
+        assert_eq!(
+            serde_json::to_value(&sizes).unwrap()["truncated"],
+            serde_json::json!({"body": "offloaded"})
+        );
+    }
+
+    #[test]
+    fn response_summary_describes_reply() {
+        use axum::http::HeaderValue;
+
+        let mut response = (StatusCode::TOO_MANY_REQUESTS, "slow down").into_response();
+        response
+            .headers_mut()
+            .insert("retry-after", HeaderValue::from_static("5"));
+        response
+            .headers_mut()
+            .append("set-cookie", HeaderValue::from_static("a=1"));
+        response
+            .headers_mut()
+            .append("set-cookie", HeaderValue::from_static("b=2"));
+
+        let summary = response_summary(
+            &response,
+            &SentReply {
+                source: "mock",
+                delay_ms: 200,
+                body_size: 9,
+            },
+        );
+        assert_eq!(summary["status"], 429);
+        assert_eq!(summary["source"], "mock");
+        assert_eq!(summary["delayMs"], 200);
+        assert_eq!(summary["bodySize"], 9);
+        assert_eq!(summary["headers"]["retry-after"], "5");
+        assert_eq!(summary["headers"]["set-cookie"], "a=1, b=2");
+
+        let plain = response_summary(
+            &(StatusCode::OK, "OK").into_response(),
+            &SentReply {
+                source: "default",
+                delay_ms: 0,
+                body_size: 2,
+            },
+        );
+        assert!(plain.get("delayMs").is_none());
+    }
+
     #[test]
     fn blocked_ips() {
         use std::net::IpAddr;
@@ -791,7 +3098,9 @@ mod tests {
         // IPv6 ULA (fc00::/7)
         assert!(is_blocked_ip("fd00::1".parse::<IpAddr>().unwrap()));
         assert!(is_blocked_ip("fc00::1".parse::<IpAddr>().unwrap()));
-        assert!(is_blocked_ip("fdab:cdef:1234::1".parse::<IpAddr>().unwrap()));
+        assert!(is_blocked_ip(
+            "fdab:cdef:1234::1".parse::<IpAddr>().unwrap()
+        ));
 
         // IPv6 link-local (fe80::/10)
         assert!(is_blocked_ip("fe80::1".parse::<IpAddr>().unwrap()));
@@ -800,7 +3109,9 @@ mod tests {
         // IPv4-mapped IPv6
         assert!(is_blocked_ip("::ffff:127.0.0.1".parse::<IpAddr>().unwrap()));
         assert!(is_blocked_ip("::ffff:10.0.0.1".parse::<IpAddr>().unwrap()));
-        assert!(is_blocked_ip("::ffff:169.254.169.254".parse::<IpAddr>().unwrap()));
+        assert!(is_blocked_ip(
+            "::ffff:169.254.169.254".parse::<IpAddr>().unwrap()
+        ));
 
         // Public IPs — should NOT be blocked
         assert!(!is_blocked_ip("8.8.8.8".parse::<IpAddr>().unwrap()));
@@ -811,10 +3122,26 @@ mod tests {
 
     #[tokio::test]
     async fn resolve_blocks_private_ip_literals() {
-        assert!(resolve_notification_target("http://127.0.0.1:9876/hook").await.is_err());
-        assert!(resolve_notification_target("http://10.0.0.1/hook").await.is_err());
-        assert!(resolve_notification_target("http://169.254.169.254/meta").await.is_err());
-        assert!(resolve_notification_target("http://[::1]/hook").await.is_err());
+        assert!(
+            resolve_notification_target("http://127.0.0.1:9876/hook")
+                .await
+                .is_err()
+        );
+        assert!(
+            resolve_notification_target("http://10.0.0.1/hook")
+                .await
+                .is_err()
+        );
+        assert!(
+            resolve_notification_target("http://169.254.169.254/meta")
+                .await
+                .is_err()
+        );
+        assert!(
+            resolve_notification_target("http://[::1]/hook")
+                .await
+                .is_err()
+        );
         assert!(resolve_notification_target("not-a-url").await.is_err());
     }
 
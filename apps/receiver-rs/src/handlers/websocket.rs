@@ -0,0 +1,325 @@
+//! WebSocket capture at `/ws/{slug}`.
+//!
+//! After the slug checks the upgrade is always accepted. Every text or binary
+//! message the client then sends is captured through `capture_webhook` like an
+//! HTTP request: method `WS`, the handshake's path, query and headers, and the
+//! message as the body. `requests.frame` records the connection the message
+//! arrived on, its position in it and its opcode, so a session can be read
+//! back in order.
+//!
+//! Messages are captured one at a time in arrival order, so a slow database
+//! pushes back on the sender instead of queueing in memory. When a capture is
+//! refused (unknown endpoint, paused, blocked, over quota, or the account over
+//! its in-flight limits) the connection is closed with code 1008 and the
+//! receiver error code as the reason. Nothing is sent otherwise; pings are
+//! answered by axum. Frames are not mirrored, forwarded to function sinks or
+//! announced to notification URLs.
+
+use axum::Extension;
+use axum::extract::ws::{CloseFrame, Message, WebSocket, WebSocketUpgrade, close_code};
+use axum::extract::{Path, Query, State};
+use axum::http::{HeaderMap, Uri, Version};
+use axum::response::Response;
+use chrono::Utc;
+use ring::rand::{SecureRandom, SystemRandom};
+use serde::Serialize;
+use std::collections::HashMap;
+use std::sync::Arc;
+
+use super::error::ReceiverError;
+use super::webhook::{
+    body_hash, check_capture_auth, client_cert_record, client_geo, filter_headers,
+    http_version, is_reserved_slug, is_valid_slug, real_ip,
+};
+use crate::AppState;
+use crate::client_cert::ClientCert;
+
+/// Method stored for captured WebSocket messages.
+const WS_METHOD: &str = "WS";
+
+/// What the handshake contributes to every message captured on a connection.
+struct Handshake {
+    slug: String,
+    path: String,
+    headers: HashMap<String, String>,
+    query: serde_json::Value,
+    content_type: String,
+    ip: String,
+    country: Option<String>,
+    http_version: Option<&'static str>,
+    client_cert: Option<serde_json::Value>,
+}
+
+/// The `requests.frame` metadata of a captured message.
+#[derive(Debug, Serialize)]
+struct Frame<'a> {
+    connection: &'a str,
+    /// Position among the connection's captured messages, from 1
+    seq: u64,
+    opcode: &'static str,
+}
+
+/// Random ID tying together the messages of one connection.
+fn connection_id() -> String {
+    let mut bytes = [0u8; 8];
+    // SystemRandom only fails if the OS RNG is unavailable; the ID is just a
+    // grouping key, so fall back to the clock.
+    if SystemRandom::new().fill(&mut bytes).is_err() {
+        bytes = Utc::now().timestamp_nanos_opt().unwrap_or_default().to_be_bytes();
+    }
+    hex::encode(bytes)
+}
+
+/// WebSocket capture: GET /ws/{slug}/{*path}
+#[allow(clippy::too_many_arguments)]
+pub async fn handle_websocket(
+    State(state): State<AppState>,
+    version: Version,
+    uri: Uri,
+    Path((slug, _path)): Path<(String, String)>,
+    headers: HeaderMap,
+    query: Query<HashMap<String, String>>,
+    client_cert: Option<Extension<Arc<ClientCert>>>,
+    ws: WebSocketUpgrade,
+) -> Response {
+    let client_cert = client_cert.map(|Extension(cert)| cert);
+    handle_websocket_inner(state, slug, version, uri, headers, query, client_cert, ws).await
+}
+
+/// WebSocket capture without a trailing path: GET /ws/{slug}
+#[allow(clippy::too_many_arguments)]
+pub async fn handle_websocket_no_path(
+    State(state): State<AppState>,
+    version: Version,
+    uri: Uri,
+    Path(slug): Path<String>,
+    headers: HeaderMap,
+    query: Query<HashMap<String, String>>,
+    client_cert: Option<Extension<Arc<ClientCert>>>,
+    ws: WebSocketUpgrade,
+) -> Response {
+    let client_cert = client_cert.map(|Extension(cert)| cert);
+    handle_websocket_inner(state, slug, version, uri, headers, query, client_cert, ws).await
+}
+
+#[allow(clippy::too_many_arguments)]
+async fn handle_websocket_inner(
+    state: AppState,
+    slug: String,
+    version: Version,
+    uri: Uri,
+    headers: HeaderMap,
+    query: Query<HashMap<String, String>>,
+    client_cert: Option<Arc<ClientCert>>,
+    ws: WebSocketUpgrade,
+) -> Response {
+    let slug = slug.to_ascii_lowercase();
+    if !is_valid_slug(&slug) {
+        return ReceiverError::InvalidSlug.respond(&headers);
+    }
+    // Checked once for the connection, like the rest of the handshake
+    let client_cert = match client_cert_record(&state, &slug, client_cert.as_deref()).await {
+        Ok(record) => record,
+        Err(e) => return e.respond(&headers),
+    };
+    if let Err(refused) = check_capture_auth(&state, &slug, &headers).await {
+        return refused.respond(&headers);
+    }
+
+    let handshake = Handshake {
+        path: crate::path::captured_path(uri.path(), state.config.max_path_length),
+        headers: filter_headers(&headers),
+        query: serde_json::to_value(&query.0)
+            .unwrap_or(serde_json::Value::Object(serde_json::Map::new())),
+        content_type: headers
+            .get("content-type")
+            .and_then(|v| v.to_str().ok())
+            .unwrap_or("")
+            .to_string(),
+        country: client_geo(&state, &headers, &real_ip(&headers)).country,
+        ip: real_ip(&headers),
+        http_version: http_version(version),
+        client_cert,
+        slug,
+    };
+
+    ws.max_message_size(crate::MAX_BODY_SIZE)
+        .on_upgrade(move |socket| capture_messages(state, handshake, socket))
+}
+
+async fn capture_messages(state: AppState, handshake: Handshake, mut socket: WebSocket) {
+    let connection = connection_id();
+    tracing::debug!(slug = handshake.slug, connection, "websocket connected");
+
+    let mut seq = 0;
+    while let Some(Ok(message)) = socket.recv().await {
+        let (opcode, body) = match message {
+            Message::Text(text) => ("text", text.as_str().as_bytes().to_vec()),
+            Message::Binary(bytes) => ("binary", bytes.to_vec()),
+            Message::Close(_) => break,
+            Message::Ping(_) | Message::Pong(_) => continue,
+        };
+        seq += 1;
+
+        let frame = Frame { connection: &connection, seq, opcode };
+        if let Err(error) = capture_message(&state, &handshake, &frame, body).await {
+            tracing::info!(slug = handshake.slug, connection, code = error.code(), "closing websocket");
+            let _ = socket
+                .send(Message::Close(Some(CloseFrame {
+                    code: close_code::POLICY,
+                    reason: error.code().into(),
+                })))
+                .await;
+            break;
+        }
+    }
+}
+
+/// Store one message. Errors are the refusals that should end the connection;
+/// a failed query is reported like a lost HTTP capture and the connection stays open.
+async fn capture_message(
+    state: &AppState,
+    handshake: &Handshake,
+    frame: &Frame<'_>,
+    body: Vec<u8>,
+) -> Result<(), ReceiverError> {
+    let slug = handshake.slug.as_str();
+    let received_at = Utc::now();
+
+    // Each message counts against the account's in-flight limits while it is
+    // stored, like an HTTP request.
+    let _permit = match state.tenants.tenant(&state.pool, slug).await {
+        Some(tenant) => {
+            let mut permit = state.tenants.admit(tenant).ok_or(ReceiverError::TooManyInFlight)?;
+            if !permit.hold_bytes(body.len()) {
+                return Err(ReceiverError::TooManyInFlight);
+            }
+            Some(permit)
+        }
+        None => None,
+    };
+
+    let (mut body_str, body_raw): (String, Option<Vec<u8>>) = match String::from_utf8(body) {
+        Ok(s) => (s, None),
+        Err(e) => {
+            let lossy = String::from_utf8_lossy(e.as_bytes()).into_owned();
+            (lossy, Some(e.into_bytes()))
+        }
+    };
+
+    // Transforms, header redaction and encryption apply per message, as for
+    // HTTP captures; the per-rule redaction counts aren't recorded.
+    let mut headers = handshake.headers.clone();
+    if let Some(transforms) = state.caches.transforms.get(&state.pool, slug).await {
+        if body_raw.is_some() {
+            let mut unused = String::new();
+            crate::transform::apply(&transforms, &mut headers, &mut unused);
+        } else {
+            crate::transform::apply(&transforms, &mut headers, &mut body_str);
+        }
+    }
+    let encryption = state.caches.header_encryption.get(&state.pool, slug).await;
+    let sealed = encryption.as_ref().map_or(&[][..], |policy| &policy.headers[..]);
+    let redactor = state.caches.redactions.get(&state.pool, slug).await;
+    crate::redact::redact_headers(redactor.as_deref(), sealed, &mut headers, &mut crate::redact::Fired::new());
+    if let Some(ref policy) = encryption {
+        headers = crate::header_crypt::seal(state.header_cipher.as_deref(), policy, &headers);
+    }
+    let headers_json = serde_json::to_value(&headers)
+        .unwrap_or(serde_json::Value::Object(serde_json::Map::new()));
+    let frame_json = serde_json::to_value(frame).ok();
+    let body_hash = body_hash(&body_str, body_raw.as_deref());
+    let content_class = crate::content_class::classify(
+        &handshake.content_type,
+        body_raw.as_deref().unwrap_or(body_str.as_bytes()),
+    );
+    let fingerprint = crate::fingerprint::fingerprint(
+        WS_METHOD,
+        &handshake.path,
+        &handshake.content_type,
+        body_raw.is_none().then_some(body_str.as_str()),
+    );
+
+    let result: Result<serde_json::Value, sqlx::Error> = sqlx::query_scalar(
+        "SELECT capture_webhook($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)",
+    )
+    .bind(slug)
+    .bind(WS_METHOD)
+    .bind(&handshake.path)
+    .bind(&headers_json)
+    .bind(&body_str)
+    .bind(&handshake.query)
+    .bind(&handshake.content_type)
+    .bind(&handshake.ip)
+    .bind(received_at)
+    .bind(body_raw.as_deref())
+    .bind(None::<chrono::DateTime<Utc>>)
+    .bind(&body_hash)
+    .bind(None::<serde_json::Value>)
+    .bind(&handshake.country)
+    .bind(None::<String>)
+    .bind(None::<i32>)
+    .bind(&frame_json)
+    .bind(None::<serde_json::Value>)
+    .bind(None::<String>)
+    .bind(handshake.http_version)
+    .bind(&handshake.client_cert)
+    .bind(None::<serde_json::Value>)
+    .bind(None::<String>)
+    .bind(&fingerprint)
+    .bind(None::<String>)
+    .bind(None::<String>)
+    .bind(content_class)
+    .fetch_one(&state.pool)
+    .await;
+
+    let value = match result {
+        Ok(value) => value,
+        Err(e) => {
+            tracing::error!(slug, error = %e, "capture_webhook query failed for websocket message");
+            state.capture_failures.record(slug, received_at);
+            return Ok(());
+        }
+    };
+
+    if value.get("auto_extend").and_then(|v| v.as_bool()).unwrap_or(false) {
+        state.activity.record(slug, received_at);
+    }
+
+    match value.get("status").and_then(|s| s.as_str()).unwrap_or_default() {
+        "ok" => Ok(()),
+        "not_found" if is_reserved_slug(slug) => Err(ReceiverError::ReservedSlug),
+        "not_found" => Err(ReceiverError::NotFound),
+        "expired" => Err(ReceiverError::Expired),
+        "paused" => Err(ReceiverError::Paused),
+        "blocked" => Err(ReceiverError::Blocked),
+        "quota_exceeded" => Err(ReceiverError::QuotaExceeded),
+        unknown => {
+            tracing::warn!(slug, status = unknown, "unexpected capture_webhook status");
+            Ok(())
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn frame_serializes_for_storage() {
+        let frame = Frame { connection: "9f2c4a1be0d37a55", seq: 3, opcode: "text" };
+        assert_eq!(
+            serde_json::to_value(&frame).unwrap(),
+            serde_json::json!({"connection": "9f2c4a1be0d37a55", "seq": 3, "opcode": "text"})
+        );
+    }
+
+    #[test]
+    fn connection_ids_are_distinct_hex() {
+        let a = connection_id();
+        let b = connection_id();
+        assert_eq!(a.len(), 16);
+        assert!(a.bytes().all(|c| c.is_ascii_hexdigit()));
+        assert_ne!(a, b);
+    }
+}
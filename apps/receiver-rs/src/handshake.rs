@@ -0,0 +1,177 @@
+//! Provider handshakes: Slack, Zoom, Dropbox and Microsoft Graph won't
+//! deliver to a URL until it answers a verification request the right way.
+//! Endpoints with `auto_handshake` set answer them here, in place of the
+//! mock; the request is captured like any other.
+//!
+//! - Slack: a JSON `{"type": "url_verification", "challenge": ...}` POST,
+//!   answered with the challenge
+//! - Zoom: a JSON `{"event": "endpoint.url_validation", "payload":
+//!   {"plainToken": ...}}` POST, answered with the token and its HMAC-SHA256
+//!   (hex) under the app's secret token. The endpoint's signature
+//!   verification secret is used; without one the request isn't answered.
+//! - Dropbox: a GET with a `challenge` query parameter, answered with it
+//! - Microsoft Graph: a request with a `validationToken` query parameter,
+//!   answered with it
+//!
+//! Challenges are echoed as `text/plain` with `nosniff`, so a handshake URL
+//! can't be used to serve markup.
+
+use axum::http::{HeaderValue, Method, StatusCode, header};
+use axum::response::{IntoResponse, Response};
+use ring::hmac;
+use serde::Deserialize;
+use std::collections::HashMap;
+
+/// Bodies larger than this aren't handshakes and aren't parsed.
+const MAX_BODY: usize = 16 * 1024;
+
+/// Longest challenge echoed back.
+const MAX_CHALLENGE: usize = 2048;
+
+/// The answer to a verification request.
+#[derive(Debug, PartialEq)]
+pub struct Handshake {
+    /// "slack", "zoom", "dropbox" or "microsoft-graph"
+    pub provider: &'static str,
+    content_type: &'static str,
+    body: String,
+}
+
+#[derive(Deserialize)]
+struct Payload {
+    #[serde(default, rename = "type")]
+    kind: Option<String>,
+    #[serde(default)]
+    challenge: Option<String>,
+    #[serde(default)]
+    event: Option<String>,
+    #[serde(default)]
+    payload: Option<ZoomPayload>,
+}
+
+#[derive(Deserialize)]
+struct ZoomPayload {
+    #[serde(rename = "plainToken")]
+    plain_token: String,
+}
+
+/// The answer when the request is a provider's verification request.
+/// `zoom_secret` signs Zoom's answer.
+pub fn detect(
+    method: &Method,
+    query: &HashMap<String, String>,
+    body: &[u8],
+    zoom_secret: Option<&str>,
+) -> Option<Handshake> {
+    if let Some(token) = query.get("validationToken") {
+        return challenge("microsoft-graph", token);
+    }
+    if method == Method::GET {
+        return query
+            .get("challenge")
+            .and_then(|value| challenge("dropbox", value));
+    }
+    if method != Method::POST || body.len() > MAX_BODY {
+        return None;
+    }
+    let payload: Payload = serde_json::from_slice(body).ok()?;
+    if payload.kind.as_deref() == Some("url_verification") {
+        return challenge("slack", payload.challenge.as_deref()?);
+    }
+    if payload.event.as_deref() == Some("endpoint.url_validation") {
+        let token = payload.payload?.plain_token;
+        let key = hmac::Key::new(hmac::HMAC_SHA256, zoom_secret?.as_bytes());
+        let encrypted = hex::encode(hmac::sign(&key, token.as_bytes()));
+        return Some(Handshake {
+            provider: "zoom",
+            content_type: "application/json",
+            body: serde_json::json!({"plainToken": token, "encryptedToken": encrypted}).to_string(),
+        });
+    }
+    None
+}
+
+fn challenge(provider: &'static str, value: &str) -> Option<Handshake> {
+    (!value.is_empty() && value.len() <= MAX_CHALLENGE).then(|| Handshake {
+        provider,
+        content_type: "text/plain",
+        body: value.to_string(),
+    })
+}
+
+impl Handshake {
+    pub fn body_size(&self) -> usize {
+        self.body.len()
+    }
+
+    pub fn response(self) -> Response {
+        let mut response = (StatusCode::OK, self.body).into_response();
+        let headers = response.headers_mut();
+        headers.insert(
+            header::CONTENT_TYPE,
+            HeaderValue::from_static(self.content_type),
+        );
+        headers.insert(
+            header::X_CONTENT_TYPE_OPTIONS,
+            HeaderValue::from_static("nosniff"),
+        );
+        response
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn post(body: &str, secret: Option<&str>) -> Option<Handshake> {
+        detect(&Method::POST, &HashMap::new(), body.as_bytes(), secret)
+    }
+
+    fn query(method: Method, name: &str, value: &str) -> Option<Handshake> {
+        let query = HashMap::from([(name.to_string(), value.to_string())]);
+        detect(&method, &query, b"", None)
+    }
+
+    #[test]
+    fn slack_challenge_is_echoed() {
+        let handshake = post(
+            r#"{"token":"t","challenge":"3eZbrw1aB","type":"url_verification"}"#,
+            None,
+        )
+        .unwrap();
+        assert_eq!(handshake.provider, "slack");
+        assert_eq!(handshake.body, "3eZbrw1aB");
+        assert_eq!(post(r#"{"type":"event_callback"}"#, None), None);
+        assert_eq!(post("not json", None), None);
+    }
+
+    #[test]
+    fn zoom_token_is_signed() {
+        let body = r#"{"event":"endpoint.url_validation","payload":{"plainToken":"qgg8vlvZRS6UYooatFL8Aw"}}"#;
+        assert_eq!(post(body, None), None);
+        let handshake = post(body, Some("secret")).unwrap();
+        assert_eq!(handshake.provider, "zoom");
+        let reply: serde_json::Value = serde_json::from_str(&handshake.body).unwrap();
+        assert_eq!(reply["plainToken"], "qgg8vlvZRS6UYooatFL8Aw");
+        let key = hmac::Key::new(hmac::HMAC_SHA256, b"secret");
+        let expected = hex::encode(hmac::sign(&key, b"qgg8vlvZRS6UYooatFL8Aw"));
+        assert_eq!(reply["encryptedToken"], expected.as_str());
+    }
+
+    #[test]
+    fn query_challenges_are_echoed() {
+        let dropbox = query(Method::GET, "challenge", "abc").unwrap();
+        assert_eq!(
+            (dropbox.provider, dropbox.body.as_str()),
+            ("dropbox", "abc")
+        );
+        assert_eq!(query(Method::POST, "challenge", "abc"), None);
+
+        let graph = query(Method::POST, "validationToken", "Validation: Token").unwrap();
+        assert_eq!(graph.provider, "microsoft-graph");
+        let response = graph.response();
+        assert_eq!(response.headers()["content-type"], "text/plain");
+        assert_eq!(response.headers()["x-content-type-options"], "nosniff");
+        assert_eq!(query(Method::GET, "challenge", ""), None);
+    }
+}
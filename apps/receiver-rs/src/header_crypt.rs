@@ -0,0 +1,214 @@
+//! Capture-time encryption of selected headers.
+//!
+//! An endpoint can list sensitive headers (`endpoints.encrypted_headers`, e.g.
+//! `authorization` or a provider's API key header) whose values are encrypted
+//! before the request is stored or handed to function sinks. Everything else
+//! stays plaintext and searchable. Listed headers are exempt from the default
+//! header redaction in `redact.rs`. Mock correlation still sees the original
+//! values, which never leave the process.
+//!
+//! Values are sealed with AES-256-GCM under the owner's account key, derived
+//! from `HEADER_ENCRYPTION_KEY` with HKDF-SHA256 (info = user id), and bound
+//! to the header name as associated data so a value can't be moved to another
+//! header. Stored form: `enc:v1:<base64(nonce || ciphertext || tag)>`. The web
+//! API derives the same key to decrypt for users with access to the endpoint
+//! (`apps/web/lib/header-crypt.ts`).
+//!
+//! Without a configured key, listed headers are stored as [`REDACTED`] rather
+//! than in the clear.
+
+use base64::Engine;
+use base64::engine::general_purpose::STANDARD as BASE64;
+use ring::aead::{AES_256_GCM, Aad, LessSafeKey, NONCE_LEN, Nonce, UnboundKey};
+use ring::hkdf::{HKDF_SHA256, Salt};
+use ring::rand::{SecureRandom, SystemRandom};
+use serde::Deserialize;
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::Arc;
+
+use crate::slug_cache::{SlugCache, load_json};
+
+/// Prefix of an encrypted header value.
+pub const PREFIX: &str = "enc:v1:";
+
+/// Stored in place of a listed header when no key is configured.
+pub const REDACTED: &str = "[redacted]";
+
+/// HKDF salt shared with the web app.
+const HKDF_SALT: &[u8] = b"webhooks.cc header encryption v1";
+
+/// Master key for account header keys.
+pub struct HeaderCipher {
+    salt: Salt,
+    master: Vec<u8>,
+    rng: SystemRandom,
+}
+
+impl HeaderCipher {
+    /// Parse `HEADER_ENCRYPTION_KEY` (64 hex characters).
+    pub fn from_hex(key: &str) -> Result<Self, &'static str> {
+        let master = hex::decode(key.trim()).map_err(|_| "key must be hex")?;
+        if master.len() != 32 {
+            return Err("key must be 32 bytes (64 hex characters)");
+        }
+        Ok(Self {
+            salt: Salt::new(HKDF_SHA256, HKDF_SALT),
+            master,
+            rng: SystemRandom::new(),
+        })
+    }
+
+    fn account_key(&self, user_id: &str) -> LessSafeKey {
+        let info = [user_id.as_bytes()];
+        let prk = self.salt.extract(&self.master);
+        let okm = prk
+            .expand(&info, &AES_256_GCM)
+            .expect("AES-256 key length is a valid HKDF output length");
+        LessSafeKey::new(UnboundKey::from(okm))
+    }
+
+    /// Encrypt one header value for `user_id`.
+    pub fn encrypt(&self, user_id: &str, name: &str, value: &str) -> Option<String> {
+        let mut nonce = [0u8; NONCE_LEN];
+        self.rng.fill(&mut nonce).ok()?;
+        let mut sealed = value.as_bytes().to_vec();
+        self.account_key(user_id)
+            .seal_in_place_append_tag(
+                Nonce::assume_unique_for_key(nonce),
+                Aad::from(name.as_bytes()),
+                &mut sealed,
+            )
+            .ok()?;
+        let mut out = nonce.to_vec();
+        out.extend_from_slice(&sealed);
+        Some(format!("{PREFIX}{}", BASE64.encode(out)))
+    }
+
+    #[cfg(test)]
+    fn decrypt(&self, user_id: &str, name: &str, value: &str) -> Option<String> {
+        let data = BASE64.decode(value.strip_prefix(PREFIX)?).ok()?;
+        let (nonce, sealed) = data.split_at_checked(NONCE_LEN)?;
+        let mut sealed = sealed.to_vec();
+        let plain = self
+            .account_key(user_id)
+            .open_in_place(
+                Nonce::try_assume_unique_for_key(nonce).ok()?,
+                Aad::from(name.as_bytes()),
+                &mut sealed,
+            )
+            .ok()?;
+        String::from_utf8(plain.to_vec()).ok()
+    }
+}
+
+/// An endpoint's encrypted header list and the account whose key seals it.
+#[derive(Debug, Clone, Deserialize)]
+pub struct HeaderEncryption {
+    pub user_id: String,
+    /// Lowercase header names
+    pub headers: Vec<String>,
+}
+
+/// Copy of `headers` with the listed ones encrypted (or redacted without a key).
+pub fn seal(
+    cipher: Option<&HeaderCipher>,
+    policy: &HeaderEncryption,
+    headers: &HashMap<String, String>,
+) -> HashMap<String, String> {
+    headers
+        .iter()
+        .map(|(name, value)| {
+            let lower = name.to_ascii_lowercase();
+            if !policy.headers.contains(&lower) {
+                return (name.clone(), value.clone());
+            }
+            let sealed = cipher
+                .and_then(|c| c.encrypt(&policy.user_id, &lower, value))
+                .unwrap_or_else(|| REDACTED.to_string());
+            (name.clone(), sealed)
+        })
+        .collect()
+}
+
+/// Per-slug header lists, shared across requests via AppState.
+pub type EncryptionCache = SlugCache<Option<Arc<HeaderEncryption>>>;
+
+impl EncryptionCache {
+    /// Look up an endpoint's header list, reading through to Postgres on a
+    /// miss. `None` when the endpoint encrypts nothing. Lookup failures fail
+    /// open like the transform cache and are not cached; the capture itself
+    /// goes to the same database, so it rarely survives one anyway.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Arc<HeaderEncryption>> {
+        self.get_or_load(slug, |_| async move {
+            let policy: Option<HeaderEncryption> = load_json(
+                pool,
+                slug,
+                "get_endpoint_header_encryption",
+                "encrypted_headers",
+            )
+            .await?;
+            let policy = policy.filter(|policy| !policy.headers.is_empty());
+            Some(policy.map(Arc::new))
+        })
+        .await
+        .flatten()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const KEY: &str = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f";
+
+    fn policy(headers: &[&str]) -> HeaderEncryption {
+        HeaderEncryption {
+            user_id: "user-1".to_string(),
+            headers: headers.iter().map(|h| h.to_string()).collect(),
+        }
+    }
+
+    #[test]
+    fn key_must_be_32_hex_bytes() {
+        assert!(HeaderCipher::from_hex(KEY).is_ok());
+        assert!(HeaderCipher::from_hex("abcd").is_err());
+        assert!(HeaderCipher::from_hex(&"zz".repeat(32)).is_err());
+    }
+
+    #[test]
+    fn values_round_trip_per_account_and_header() {
+        let cipher = HeaderCipher::from_hex(KEY).unwrap();
+        let sealed = cipher.encrypt("user-1", "authorization", "Bearer sk_live_1").unwrap();
+        assert!(sealed.starts_with(PREFIX));
+        assert!(!sealed.contains("sk_live_1"));
+        assert_eq!(
+            cipher.decrypt("user-1", "authorization", &sealed).as_deref(),
+            Some("Bearer sk_live_1")
+        );
+        // Another account's key, or the same value moved to another header, doesn't open.
+        assert!(cipher.decrypt("user-2", "authorization", &sealed).is_none());
+        assert!(cipher.decrypt("user-1", "x-api-key", &sealed).is_none());
+        // Fresh nonce every time
+        assert_ne!(sealed, cipher.encrypt("user-1", "authorization", "Bearer sk_live_1").unwrap());
+    }
+
+    #[test]
+    fn seal_only_touches_listed_headers() {
+        let cipher = HeaderCipher::from_hex(KEY).unwrap();
+        let headers = HashMap::from([
+            ("Authorization".to_string(), "Bearer secret".to_string()),
+            ("content-type".to_string(), "application/json".to_string()),
+        ]);
+        let sealed = seal(Some(&cipher), &policy(&["authorization"]), &headers);
+        assert_eq!(sealed["content-type"], "application/json");
+        assert_eq!(
+            cipher.decrypt("user-1", "authorization", &sealed["Authorization"]).as_deref(),
+            Some("Bearer secret")
+        );
+
+        let redacted = seal(None, &policy(&["authorization"]), &headers);
+        assert_eq!(redacted["Authorization"], REDACTED);
+        assert_eq!(redacted["content-type"], "application/json");
+    }
+}
@@ -0,0 +1,122 @@
+//! Delivery keys, for linking provider retries by the ID they resend.
+//!
+//! Most providers put an ID on each delivery and send it again with every
+//! retry, so two captures with the same ID are the same event even when the
+//! body or signature changed in between. capture_webhook links a capture to
+//! the first one on the endpoint with the same key (see `duplicateOf`), and
+//! falls back to the body hash when there is none.
+//!
+//! Keys are hashed so an ID in a header the endpoint encrypts isn't stored
+//! in the clear.
+
+use axum::http::HeaderMap;
+
+/// Headers whose value is the delivery ID, in order of preference.
+const DELIVERY_ID_HEADERS: &[&str] = &[
+    "idempotency-key",
+    "x-github-delivery",
+    "webhook-id",
+    "svix-id",
+    "x-shopify-webhook-id",
+    "x-gitlab-event-uuid",
+];
+
+/// Longest header value or event ID used as a key; anything longer is a
+/// payload, not an ID.
+const MAX_ID_LEN: usize = 256;
+
+/// Hex SHA-256 of the request's delivery ID, prefixed with where it came
+/// from, or `None` when it carries none.
+///
+/// Stripe sends no ID header, so its key is the signed timestamp from
+/// `Stripe-Signature` plus the event `id` from the body.
+pub fn delivery_key(headers: &HeaderMap, body: &[u8]) -> Option<String> {
+    let key = DELIVERY_ID_HEADERS
+        .iter()
+        .find_map(|name| {
+            let value = headers.get(*name)?.to_str().ok()?.trim();
+            usable(value).then(|| format!("{name}:{value}"))
+        })
+        .or_else(|| stripe_key(headers, body))?;
+
+    use sha2::{Digest, Sha256};
+    Some(hex::encode(Sha256::digest(key.as_bytes())))
+}
+
+fn stripe_key(headers: &HeaderMap, body: &[u8]) -> Option<String> {
+    let signature = headers.get("stripe-signature")?.to_str().ok()?;
+    let timestamp = signature
+        .split(',')
+        .find_map(|item| item.trim().strip_prefix("t="))
+        .filter(|t| !t.is_empty() && t.bytes().all(|b| b.is_ascii_digit()))?;
+
+    #[derive(serde::Deserialize)]
+    struct Event {
+        id: String,
+    }
+    let event: Event = serde_json::from_slice(body).ok()?;
+    usable(&event.id).then(|| format!("stripe-signature:{timestamp}.{}", event.id))
+}
+
+fn usable(id: &str) -> bool {
+    !id.is_empty() && id.len() <= MAX_ID_LEN
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn headers(pairs: &[(&'static str, &str)]) -> HeaderMap {
+        let mut map = HeaderMap::new();
+        for (name, value) in pairs {
+            map.insert(*name, value.parse().unwrap());
+        }
+        map
+    }
+
+    #[test]
+    fn id_headers_give_stable_keys() {
+        let first = delivery_key(&headers(&[("x-github-delivery", "72d3162e")]), b"{\"a\":1}");
+        let retry = delivery_key(&headers(&[("x-github-delivery", "72d3162e")]), b"{\"a\":2}");
+        assert!(first.is_some());
+        assert_eq!(first, retry);
+        assert_eq!(first.unwrap().len(), 64);
+    }
+
+    #[test]
+    fn header_name_is_part_of_the_key() {
+        assert_ne!(
+            delivery_key(&headers(&[("webhook-id", "msg_1")]), b""),
+            delivery_key(&headers(&[("svix-id", "msg_1")]), b"")
+        );
+    }
+
+    #[test]
+    fn idempotency_key_wins() {
+        let both = headers(&[("idempotency-key", "k1"), ("webhook-id", "msg_1")]);
+        assert_eq!(delivery_key(&both, b""), delivery_key(&headers(&[("idempotency-key", "k1")]), b""));
+    }
+
+    #[test]
+    fn stripe_uses_timestamp_and_event_id() {
+        let body = br#"{"id": "evt_1", "type": "charge.succeeded"}"#;
+        let signed = headers(&[("stripe-signature", "t=1700000000,v1=abc,v0=def")]);
+        let resigned = headers(&[("stripe-signature", "t=1700000000,v1=other")]);
+        let later = headers(&[("stripe-signature", "t=1700000300,v1=abc")]);
+
+        let key = delivery_key(&signed, body);
+        assert!(key.is_some());
+        assert_eq!(key, delivery_key(&resigned, body));
+        assert_ne!(key, delivery_key(&later, body));
+        assert!(delivery_key(&signed, br#"{"type": "charge.succeeded"}"#).is_none());
+        assert!(delivery_key(&headers(&[("stripe-signature", "v1=abc")]), body).is_none());
+    }
+
+    #[test]
+    fn no_id_no_key() {
+        assert!(delivery_key(&HeaderMap::new(), b"{\"id\": \"evt_1\"}").is_none());
+        assert!(delivery_key(&headers(&[("idempotency-key", " ")]), b"").is_none());
+        let long = "x".repeat(MAX_ID_LEN + 1);
+        assert!(delivery_key(&headers(&[("idempotency-key", &long)]), b"").is_none());
+    }
+}
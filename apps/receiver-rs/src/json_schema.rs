@@ -0,0 +1,909 @@
+//! JSON Schema validation of request bodies, for contract-testing webhook
+//! producers.
+//!
+//! `endpoints.schema_validation` holds a JSON Schema and an optional
+//! `failureResponse`. Each HTTP body is parsed as received, before
+//! transforms, and checked against the schema; the verdict is stored as
+//! `requests.schema_valid` and the failures as `requests.schema_errors`
+//! (at most [`MAX_ERRORS`], each with the instance path, the keyword and a
+//! message). A body that isn't JSON fails. With `failureResponse` set, a
+//! request that fails is still stored but answered with that reply (422 by
+//! default) instead of the mock response.
+//!
+//! The validator covers the assertion keywords of draft 2020-12 (and
+//! draft-07's array form of `items`): `type`, `enum`, `const`, the numeric,
+//! string, array and object bounds, `pattern`, `properties`,
+//! `patternProperties`, `additionalProperties`, `required`,
+//! `dependentRequired`, `propertyNames`, `contains`, the combinators,
+//! `if`/`then`/`else`, and `$ref` to `#` or a JSON pointer within the
+//! schema. `format` and other annotations are ignored. Patterns use the
+//! `regex` crate's syntax, so lookaround and backreferences don't compile;
+//! such keywords are skipped.
+//!
+//! Configs are cached per slug like the other endpoint caches, with their
+//! patterns compiled once, and dropped on `endpoint_config` notifications.
+
+use axum::http::{HeaderValue, StatusCode, header};
+use axum::response::{IntoResponse, Response};
+use regex::Regex;
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value};
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::Arc;
+
+use crate::slug_cache::{SlugCache, load_json};
+
+/// Failures kept per request; validation stops after this many.
+pub const MAX_ERRORS: usize = 20;
+
+/// Nesting (schemas and `$ref` hops) past which validation gives up, so a
+/// self-referencing schema can't recurse forever.
+const MAX_DEPTH: usize = 64;
+
+/// Status of the failure reply when the config doesn't name one.
+const DEFAULT_FAILURE_STATUS: u16 = 422;
+
+/// Reply sent for bodies that fail validation.
+#[derive(Debug, Clone, Deserialize)]
+pub struct FailureResponse {
+    #[serde(default = "default_failure_status")]
+    pub status: u16,
+    /// Sent as is; the validation errors as JSON when absent
+    #[serde(default)]
+    pub body: Option<String>,
+}
+
+fn default_failure_status() -> u16 {
+    DEFAULT_FAILURE_STATUS
+}
+
+impl FailureResponse {
+    /// The reply, and the size of its body.
+    pub fn response(&self, errors: &[SchemaError]) -> (Response, usize) {
+        let status = StatusCode::from_u16(self.status).unwrap_or(StatusCode::UNPROCESSABLE_ENTITY);
+        let (body, content_type) = match &self.body {
+            Some(body) if serde_json::from_str::<Value>(body).is_ok() => {
+                (body.clone(), "application/json")
+            }
+            Some(body) => (body.clone(), "text/plain; charset=utf-8"),
+            None => (
+                serde_json::json!({ "error": "schema_validation_failed", "errors": errors })
+                    .to_string(),
+                "application/json",
+            ),
+        };
+        let size = body.len();
+        let mut response = (status, body).into_response();
+        response
+            .headers_mut()
+            .insert(header::CONTENT_TYPE, HeaderValue::from_static(content_type));
+        (response, size)
+    }
+}
+
+/// Raw form of `endpoints.schema_validation`.
+#[derive(Debug, Deserialize)]
+struct StoredConfig {
+    schema: Value,
+    #[serde(default, rename = "failureResponse")]
+    failure_response: Option<FailureResponse>,
+}
+
+/// An endpoint's schema with its patterns compiled.
+#[derive(Debug)]
+pub struct SchemaConfig {
+    schema: Value,
+    patterns: HashMap<String, Regex>,
+    pub failure_response: Option<FailureResponse>,
+}
+
+/// One way the body breaks the schema.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct SchemaError {
+    /// JSON pointer to the failing value; empty for the whole body
+    pub path: String,
+    pub keyword: &'static str,
+    pub message: String,
+}
+
+#[derive(Debug, Default)]
+pub struct SchemaVerdict {
+    pub valid: bool,
+    pub errors: Vec<SchemaError>,
+}
+
+impl SchemaConfig {
+    fn new(stored: StoredConfig) -> Self {
+        let mut patterns = HashMap::new();
+        collect_patterns(&stored.schema, &mut patterns, 0);
+        Self {
+            schema: stored.schema,
+            patterns,
+            failure_response: stored.failure_response,
+        }
+    }
+
+    /// Parse `body` as JSON and check it against the schema.
+    pub fn validate(&self, body: &[u8]) -> SchemaVerdict {
+        let instance: Value = match serde_json::from_slice(body) {
+            Ok(instance) => instance,
+            Err(e) => {
+                return SchemaVerdict {
+                    valid: false,
+                    errors: vec![SchemaError {
+                        path: String::new(),
+                        keyword: "json",
+                        message: format!("body is not valid JSON: {e}"),
+                    }],
+                };
+            }
+        };
+        let mut errors = Vec::new();
+        Validator { config: self }.check(&self.schema, &instance, "", 0, &mut errors, MAX_ERRORS);
+        SchemaVerdict {
+            valid: errors.is_empty(),
+            errors,
+        }
+    }
+}
+
+/// Compile every `pattern` and `patternProperties` key in the schema. The
+/// walk covers the whole document, including `$defs`, so referenced
+/// subschemas are ready too.
+fn collect_patterns(schema: &Value, patterns: &mut HashMap<String, Regex>, depth: usize) {
+    if depth > MAX_DEPTH {
+        return;
+    }
+    match schema {
+        Value::Object(map) => {
+            let mut sources: Vec<&str> = Vec::new();
+            if let Some(Value::String(pattern)) = map.get("pattern") {
+                sources.push(pattern);
+            }
+            if let Some(Value::Object(properties)) = map.get("patternProperties") {
+                sources.extend(properties.keys().map(String::as_str));
+            }
+            for source in sources {
+                if patterns.contains_key(source) {
+                    continue;
+                }
+                match Regex::new(source) {
+                    Ok(regex) => {
+                        patterns.insert(source.to_string(), regex);
+                    }
+                    Err(e) => {
+                        tracing::warn!(pattern = source, error = %e, "unsupported schema pattern, skipping")
+                    }
+                }
+            }
+            for value in map.values() {
+                collect_patterns(value, patterns, depth + 1);
+            }
+        }
+        Value::Array(items) => {
+            for item in items {
+                collect_patterns(item, patterns, depth + 1);
+            }
+        }
+        _ => {}
+    }
+}
+
+struct Validator<'a> {
+    config: &'a SchemaConfig,
+}
+
+impl Validator<'_> {
+    /// Whether `instance` satisfies `schema`, without keeping the errors.
+    fn passes(&self, schema: &Value, instance: &Value, depth: usize) -> bool {
+        let mut errors = Vec::new();
+        self.check(schema, instance, "", depth, &mut errors, 1);
+        errors.is_empty()
+    }
+
+    /// Append the ways `instance` fails `schema` to `errors`, stopping once
+    /// it holds `limit`.
+    fn check(
+        &self,
+        schema: &Value,
+        instance: &Value,
+        path: &str,
+        depth: usize,
+        errors: &mut Vec<SchemaError>,
+        limit: usize,
+    ) {
+        if errors.len() >= limit {
+            return;
+        }
+        let fail = |errors: &mut Vec<SchemaError>, keyword: &'static str, message: String| {
+            if errors.len() < limit {
+                errors.push(SchemaError {
+                    path: path.to_string(),
+                    keyword,
+                    message,
+                });
+            }
+        };
+        if depth > MAX_DEPTH {
+            fail(errors, "$ref", "schema nests too deeply".to_string());
+            return;
+        }
+        let schema = match schema {
+            Value::Bool(true) => return,
+            Value::Bool(false) => {
+                fail(errors, "false", "no value is allowed here".to_string());
+                return;
+            }
+            Value::Object(schema) => schema,
+            // Not a schema; nothing to check
+            _ => return,
+        };
+
+        if let Some(Value::String(reference)) = schema.get("$ref") {
+            match resolve(&self.config.schema, reference) {
+                Some(target) => self.check(target, instance, path, depth + 1, errors, limit),
+                None => fail(errors, "$ref", format!("cannot resolve {reference}")),
+            }
+        }
+
+        if let Some(expected) = schema.get("type")
+            && !type_matches(expected, instance)
+        {
+            fail(
+                errors,
+                "type",
+                format!(
+                    "expected {}, got {}",
+                    type_names(expected),
+                    type_of(instance)
+                ),
+            );
+        }
+        if let Some(Value::Array(allowed)) = schema.get("enum")
+            && !allowed.iter().any(|value| json_eq(value, instance))
+        {
+            fail(
+                errors,
+                "enum",
+                "value is not one of the allowed values".to_string(),
+            );
+        }
+        if let Some(expected) = schema.get("const")
+            && !json_eq(expected, instance)
+        {
+            fail(errors, "const", format!("expected {expected}"));
+        }
+
+        match instance {
+            Value::Number(number) => {
+                let n = number.as_f64().unwrap_or(0.0);
+                let bound = |keyword: &str| schema.get(keyword).and_then(Value::as_f64);
+                if let Some(min) = bound("minimum")
+                    && n < min
+                {
+                    fail(errors, "minimum", format!("must be at least {min}"));
+                }
+                if let Some(max) = bound("maximum")
+                    && n > max
+                {
+                    fail(errors, "maximum", format!("must be at most {max}"));
+                }
+                if let Some(min) = bound("exclusiveMinimum")
+                    && n <= min
+                {
+                    fail(
+                        errors,
+                        "exclusiveMinimum",
+                        format!("must be greater than {min}"),
+                    );
+                }
+                if let Some(max) = bound("exclusiveMaximum")
+                    && n >= max
+                {
+                    fail(
+                        errors,
+                        "exclusiveMaximum",
+                        format!("must be less than {max}"),
+                    );
+                }
+                if let Some(step) = bound("multipleOf").filter(|step| *step > 0.0) {
+                    let quotient = n / step;
+                    if (quotient - quotient.round()).abs() > 1e-9 {
+                        fail(
+                            errors,
+                            "multipleOf",
+                            format!("must be a multiple of {step}"),
+                        );
+                    }
+                }
+            }
+            Value::String(string) => {
+                let length = string.chars().count() as u64;
+                if let Some(min) = schema.get("minLength").and_then(Value::as_u64)
+                    && length < min
+                {
+                    fail(
+                        errors,
+                        "minLength",
+                        format!("must be at least {min} characters"),
+                    );
+                }
+                if let Some(max) = schema.get("maxLength").and_then(Value::as_u64)
+                    && length > max
+                {
+                    fail(
+                        errors,
+                        "maxLength",
+                        format!("must be at most {max} characters"),
+                    );
+                }
+                if let Some(Value::String(pattern)) = schema.get("pattern")
+                    && let Some(regex) = self.config.patterns.get(pattern)
+                    && !regex.is_match(string)
+                {
+                    fail(errors, "pattern", format!("does not match {pattern}"));
+                }
+            }
+            Value::Array(items) => self.check_array(schema, items, path, depth, errors, limit),
+            Value::Object(object) => self.check_object(schema, object, path, depth, errors, limit),
+            _ => {}
+        }
+
+        if let Some(Value::Array(all)) = schema.get("allOf") {
+            for subschema in all {
+                self.check(subschema, instance, path, depth + 1, errors, limit);
+            }
+        }
+        if let Some(Value::Array(any)) = schema.get("anyOf")
+            && !any
+                .iter()
+                .any(|subschema| self.passes(subschema, instance, depth + 1))
+        {
+            fail(
+                errors,
+                "anyOf",
+                "does not match any of the allowed schemas".to_string(),
+            );
+        }
+        if let Some(Value::Array(one)) = schema.get("oneOf") {
+            let matched = one
+                .iter()
+                .filter(|subschema| self.passes(subschema, instance, depth + 1))
+                .count();
+            if matched != 1 {
+                fail(
+                    errors,
+                    "oneOf",
+                    format!("must match exactly one of the allowed schemas, matched {matched}"),
+                );
+            }
+        }
+        if let Some(not) = schema.get("not")
+            && self.passes(not, instance, depth + 1)
+        {
+            fail(
+                errors,
+                "not",
+                "matches a schema it must not match".to_string(),
+            );
+        }
+        if let Some(condition) = schema.get("if") {
+            let branch = if self.passes(condition, instance, depth + 1) {
+                schema.get("then")
+            } else {
+                schema.get("else")
+            };
+            if let Some(branch) = branch {
+                self.check(branch, instance, path, depth + 1, errors, limit);
+            }
+        }
+    }
+
+    fn check_array(
+        &self,
+        schema: &Map<String, Value>,
+        items: &[Value],
+        path: &str,
+        depth: usize,
+        errors: &mut Vec<SchemaError>,
+        limit: usize,
+    ) {
+        let fail = |errors: &mut Vec<SchemaError>, keyword: &'static str, message: String| {
+            if errors.len() < limit {
+                errors.push(SchemaError {
+                    path: path.to_string(),
+                    keyword,
+                    message,
+                });
+            }
+        };
+        let count = items.len() as u64;
+        if let Some(min) = schema.get("minItems").and_then(Value::as_u64)
+            && count < min
+        {
+            fail(
+                errors,
+                "minItems",
+                format!("must have at least {min} items"),
+            );
+        }
+        if let Some(max) = schema.get("maxItems").and_then(Value::as_u64)
+            && count > max
+        {
+            fail(errors, "maxItems", format!("must have at most {max} items"));
+        }
+        if schema.get("uniqueItems") == Some(&Value::Bool(true))
+            && let Some((first, second)) = duplicate(items)
+        {
+            fail(
+                errors,
+                "uniqueItems",
+                format!("items {first} and {second} are equal"),
+            );
+        }
+
+        // 2020-12 `prefixItems` + `items`, or draft-07 array `items` +
+        // `additionalItems`
+        let (prefix, rest) = match (schema.get("prefixItems"), schema.get("items")) {
+            (Some(Value::Array(prefix)), rest) => (prefix.as_slice(), rest),
+            (_, Some(Value::Array(prefix))) => (prefix.as_slice(), schema.get("additionalItems")),
+            (_, rest) => (&[][..], rest),
+        };
+        for (index, item) in items.iter().enumerate() {
+            let subschema = match prefix.get(index) {
+                Some(subschema) => subschema,
+                None => match rest {
+                    Some(rest) => rest,
+                    None => break,
+                },
+            };
+            self.check(
+                subschema,
+                item,
+                &format!("{path}/{index}"),
+                depth + 1,
+                errors,
+                limit,
+            );
+        }
+
+        if let Some(contains) = schema.get("contains") {
+            let matched = items
+                .iter()
+                .filter(|item| self.passes(contains, item, depth + 1))
+                .count() as u64;
+            let min = schema
+                .get("minContains")
+                .and_then(Value::as_u64)
+                .unwrap_or(1);
+            if matched < min {
+                fail(
+                    errors,
+                    "contains",
+                    format!("must contain at least {min} matching items, found {matched}"),
+                );
+            }
+            if let Some(max) = schema.get("maxContains").and_then(Value::as_u64)
+                && matched > max
+            {
+                fail(
+                    errors,
+                    "maxContains",
+                    format!("must contain at most {max} matching items, found {matched}"),
+                );
+            }
+        }
+    }
+
+    fn check_object(
+        &self,
+        schema: &Map<String, Value>,
+        object: &Map<String, Value>,
+        path: &str,
+        depth: usize,
+        errors: &mut Vec<SchemaError>,
+        limit: usize,
+    ) {
+        let fail = |errors: &mut Vec<SchemaError>, keyword: &'static str, message: String| {
+            if errors.len() < limit {
+                errors.push(SchemaError {
+                    path: path.to_string(),
+                    keyword,
+                    message,
+                });
+            }
+        };
+        let count = object.len() as u64;
+        if let Some(min) = schema.get("minProperties").and_then(Value::as_u64)
+            && count < min
+        {
+            fail(
+                errors,
+                "minProperties",
+                format!("must have at least {min} properties"),
+            );
+        }
+        if let Some(max) = schema.get("maxProperties").and_then(Value::as_u64)
+            && count > max
+        {
+            fail(
+                errors,
+                "maxProperties",
+                format!("must have at most {max} properties"),
+            );
+        }
+        if let Some(Value::Array(required)) = schema.get("required") {
+            for name in required.iter().filter_map(Value::as_str) {
+                if !object.contains_key(name) {
+                    fail(
+                        errors,
+                        "required",
+                        format!("missing required property \"{name}\""),
+                    );
+                }
+            }
+        }
+        if let Some(Value::Object(dependent)) = schema.get("dependentRequired") {
+            for (name, needs) in dependent {
+                if !object.contains_key(name) {
+                    continue;
+                }
+                for needed in needs
+                    .as_array()
+                    .into_iter()
+                    .flatten()
+                    .filter_map(Value::as_str)
+                {
+                    if !object.contains_key(needed) {
+                        fail(
+                            errors,
+                            "dependentRequired",
+                            format!("\"{needed}\" is required when \"{name}\" is present"),
+                        );
+                    }
+                }
+            }
+        }
+
+        let properties = schema.get("properties").and_then(Value::as_object);
+        let pattern_properties = schema.get("patternProperties").and_then(Value::as_object);
+        let additional = schema.get("additionalProperties");
+        for (name, value) in object {
+            let child = format!("{path}/{}", escape_pointer(name));
+            let mut matched = false;
+            if let Some(subschema) = properties.and_then(|properties| properties.get(name)) {
+                matched = true;
+                self.check(subschema, value, &child, depth + 1, errors, limit);
+            }
+            for (pattern, subschema) in pattern_properties.into_iter().flatten() {
+                if let Some(regex) = self.config.patterns.get(pattern)
+                    && regex.is_match(name)
+                {
+                    matched = true;
+                    self.check(subschema, value, &child, depth + 1, errors, limit);
+                }
+            }
+            match additional {
+                Some(Value::Bool(false)) if !matched => {
+                    fail(
+                        errors,
+                        "additionalProperties",
+                        format!("unexpected property \"{name}\""),
+                    );
+                }
+                Some(subschema) if !matched => {
+                    self.check(subschema, value, &child, depth + 1, errors, limit);
+                }
+                _ => {}
+            }
+            if let Some(names) = schema.get("propertyNames")
+                && !self.passes(names, &Value::String(name.clone()), depth + 1)
+            {
+                fail(
+                    errors,
+                    "propertyNames",
+                    format!("property name \"{name}\" is not allowed"),
+                );
+            }
+        }
+    }
+}
+
+/// The subschema a `$ref` points at: `#` or a JSON pointer fragment.
+fn resolve<'a>(root: &'a Value, reference: &str) -> Option<&'a Value> {
+    let pointer = reference.strip_prefix('#')?;
+    if pointer.is_empty() {
+        return Some(root);
+    }
+    root.pointer(&percent_decode(pointer))
+}
+
+/// Undo percent-encoding in a URI fragment (`%25` → `%`).
+fn percent_decode(value: &str) -> String {
+    let bytes = value.as_bytes();
+    let mut decoded = Vec::with_capacity(bytes.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        if bytes[i] == b'%'
+            && let Some(byte) = value
+                .get(i + 1..i + 3)
+                .and_then(|hex| u8::from_str_radix(hex, 16).ok())
+        {
+            decoded.push(byte);
+            i += 3;
+        } else {
+            decoded.push(bytes[i]);
+            i += 1;
+        }
+    }
+    String::from_utf8(decoded).unwrap_or_else(|_| value.to_string())
+}
+
+fn escape_pointer(name: &str) -> String {
+    name.replace('~', "~0").replace('/', "~1")
+}
+
+fn type_of(value: &Value) -> &'static str {
+    match value {
+        Value::Null => "null",
+        Value::Bool(_) => "boolean",
+        Value::Number(n) if is_integer(n) => "integer",
+        Value::Number(_) => "number",
+        Value::String(_) => "string",
+        Value::Array(_) => "array",
+        Value::Object(_) => "object",
+    }
+}
+
+fn is_integer(number: &serde_json::Number) -> bool {
+    number.is_i64() || number.is_u64() || number.as_f64().is_some_and(|n| n.fract() == 0.0)
+}
+
+fn type_matches(expected: &Value, instance: &Value) -> bool {
+    let matches = |name: &str| match name {
+        "number" => instance.is_number(),
+        "integer" => instance.as_number().is_some_and(is_integer),
+        name => type_of(instance) == name,
+    };
+    match expected {
+        Value::String(name) => matches(name),
+        Value::Array(names) => names.iter().filter_map(Value::as_str).any(matches),
+        _ => true,
+    }
+}
+
+fn type_names(expected: &Value) -> String {
+    match expected {
+        Value::Array(names) => names
+            .iter()
+            .filter_map(Value::as_str)
+            .collect::<Vec<_>>()
+            .join(" or "),
+        Value::String(name) => name.clone(),
+        other => other.to_string(),
+    }
+}
+
+/// JSON equality, with numbers compared by value (`1` equals `1.0`).
+fn json_eq(a: &Value, b: &Value) -> bool {
+    match (a, b) {
+        (Value::Number(a), Value::Number(b)) => a.as_f64() == b.as_f64(),
+        (Value::Array(a), Value::Array(b)) => {
+            a.len() == b.len() && a.iter().zip(b).all(|(a, b)| json_eq(a, b))
+        }
+        (Value::Object(a), Value::Object(b)) => {
+            a.len() == b.len()
+                && a.iter()
+                    .all(|(key, value)| b.get(key).is_some_and(|other| json_eq(value, other)))
+        }
+        (a, b) => a == b,
+    }
+}
+
+/// Indices of the first pair of equal items.
+fn duplicate(items: &[Value]) -> Option<(usize, usize)> {
+    for (i, a) in items.iter().enumerate() {
+        for (j, b) in items.iter().enumerate().skip(i + 1) {
+            if json_eq(a, b) {
+                return Some((i, j));
+            }
+        }
+    }
+    None
+}
+
+/// Per-slug schemas, shared across requests via AppState.
+pub type SchemaCache = SlugCache<Option<Arc<SchemaConfig>>>;
+
+impl SchemaCache {
+    /// Look up an endpoint's schema, reading through to Postgres on a miss.
+    /// `None` when the endpoint doesn't validate bodies. Lookup failures fail
+    /// open and are not cached.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Arc<SchemaConfig>> {
+        self.get_or_load(slug, |_| async move {
+            let stored: Option<StoredConfig> = load_json(
+                pool,
+                slug,
+                "get_endpoint_schema_validation",
+                "schema_validation",
+            )
+            .await?;
+            Some(stored.map(|stored| Arc::new(SchemaConfig::new(stored))))
+        })
+        .await
+        .flatten()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn config(schema: Value) -> SchemaConfig {
+        SchemaConfig::new(StoredConfig {
+            schema,
+            failure_response: None,
+        })
+    }
+
+    /// (path, keyword) of each failure, sorted: properties are visited in
+    /// map order.
+    fn errors(schema: Value, body: Value) -> Vec<(String, &'static str)> {
+        let mut errors: Vec<_> = config(schema)
+            .validate(body.to_string().as_bytes())
+            .errors
+            .into_iter()
+            .map(|error| (error.path, error.keyword))
+            .collect();
+        errors.sort();
+        errors
+    }
+
+    #[test]
+    fn accepts_matching_bodies() {
+        let schema = json!({
+            "type": "object",
+            "required": ["id", "type"],
+            "properties": {
+                "id": {"type": "string", "pattern": "^evt_"},
+                "type": {"enum": ["payment.succeeded", "payment.failed"]},
+                "amount": {"type": "integer", "minimum": 0},
+                "items": {"type": "array", "items": {"type": "string"}, "maxItems": 3}
+            },
+            "additionalProperties": false
+        });
+        let verdict = config(schema).validate(
+            br#"{"id": "evt_1", "type": "payment.failed", "amount": 100.0, "items": ["a"]}"#,
+        );
+        assert!(verdict.valid);
+        assert!(verdict.errors.is_empty());
+    }
+
+    #[test]
+    fn reports_each_failure_with_its_path() {
+        let schema = json!({
+            "type": "object",
+            "required": ["id", "type"],
+            "properties": {
+                "id": {"type": "string", "pattern": "^evt_"},
+                "amount": {"type": "integer", "minimum": 0},
+                "items": {"type": "array", "items": {"type": "string"}}
+            },
+            "additionalProperties": false
+        });
+        assert_eq!(
+            errors(
+                schema,
+                json!({"id": "ch_1", "amount": -1.5, "items": ["a", 2], "extra/key": 1})
+            ),
+            vec![
+                // Missing and unexpected properties are reported on the object
+                ("".to_string(), "additionalProperties"),
+                ("".to_string(), "required"),
+                ("/amount".to_string(), "minimum"),
+                ("/amount".to_string(), "type"),
+                ("/id".to_string(), "pattern"),
+                ("/items/1".to_string(), "type"),
+            ]
+        );
+    }
+
+    #[test]
+    fn rejects_bodies_that_are_not_json() {
+        let verdict = config(json!({"type": "object"})).validate(b"name=value");
+        assert!(!verdict.valid);
+        assert_eq!(verdict.errors[0].keyword, "json");
+    }
+
+    #[test]
+    fn follows_refs_and_combinators() {
+        let schema = json!({
+            "$defs": {"money": {"type": "object", "required": ["currency"]}},
+            "properties": {
+                "total": {"$ref": "#/$defs/money"},
+                "kind": {"oneOf": [{"const": "a"}, {"const": "b"}]},
+                "tags": {"contains": {"const": "prod"}},
+                "code": {"not": {"type": "null"}}
+            },
+            "if": {"properties": {"kind": {"const": "b"}}},
+            "then": {"required": ["reason"]}
+        });
+        assert!(
+            errors(
+                schema.clone(),
+                json!({"total": {"currency": "usd"}, "kind": "a"})
+            )
+            .is_empty()
+        );
+        assert_eq!(
+            errors(
+                schema,
+                json!({"total": {}, "kind": "b", "tags": ["dev"], "code": null})
+            ),
+            vec![
+                ("".to_string(), "required"),
+                ("/code".to_string(), "not"),
+                ("/tags".to_string(), "contains"),
+                ("/total".to_string(), "required"),
+            ]
+        );
+    }
+
+    #[test]
+    fn recursive_refs_stop() {
+        let verdict = config(json!({"$ref": "#"})).validate(b"{}");
+        assert!(!verdict.valid);
+        assert_eq!(verdict.errors[0].keyword, "$ref");
+    }
+
+    #[test]
+    fn caps_the_errors() {
+        let body = Value::Array((0..50).map(Value::from).collect());
+        let verdict =
+            config(json!({"items": {"type": "string"}})).validate(body.to_string().as_bytes());
+        assert_eq!(verdict.errors.len(), MAX_ERRORS);
+    }
+
+    #[test]
+    fn numbers_compare_by_value() {
+        assert!(errors(json!({"const": 1}), json!(1.0)).is_empty());
+        assert!(errors(json!({"type": "integer"}), json!(2.0)).is_empty());
+        assert!(errors(json!({"multipleOf": 0.1}), json!(0.3)).is_empty());
+        assert_eq!(
+            errors(json!({"uniqueItems": true}), json!([1, 1.0])),
+            vec![("".to_string(), "uniqueItems")]
+        );
+    }
+
+    #[test]
+    fn unsupported_patterns_are_skipped() {
+        assert!(errors(json!({"pattern": "^(?=a)"}), json!("b")).is_empty());
+    }
+
+    #[test]
+    fn failure_response_defaults_to_the_errors() {
+        let failure: FailureResponse = serde_json::from_value(json!({})).unwrap();
+        assert_eq!(failure.status, 422);
+        let error = SchemaError {
+            path: "/id".to_string(),
+            keyword: "type",
+            message: "expected string, got integer".to_string(),
+        };
+        let (response, size) = failure.response(&[error]);
+        assert_eq!(response.status(), StatusCode::UNPROCESSABLE_ENTITY);
+        assert!(size > 0);
+        assert_eq!(response.headers()[header::CONTENT_TYPE], "application/json");
+
+        let custom: FailureResponse =
+            serde_json::from_value(json!({"status": 400, "body": "bad"})).unwrap();
+        let (response, size) = custom.response(&[]);
+        assert_eq!(size, 3);
+        assert_eq!(response.status(), StatusCode::BAD_REQUEST);
+        assert_eq!(
+            response.headers()[header::CONTENT_TYPE],
+            "text/plain; charset=utf-8"
+        );
+    }
+}
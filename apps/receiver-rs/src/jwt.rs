@@ -0,0 +1,529 @@
+//! JWT validation for senders that authenticate deliveries with a token
+//! (Zoom, DocuSign Connect and similar).
+//!
+//! `endpoints.jwt_verification` names the header carrying the token and
+//! either a shared secret (HS256/384/512) or a JWKS URL (RS*, PS*, ES256,
+//! ES384, EdDSA); the algorithm families never mix, so a token can't pick
+//! HMAC against a public key. Optional `issuer` and `audience` must match,
+//! and `exp`/`nbf` are checked against the arrival time with
+//! [`LEEWAY_SECS`] of slack.
+//!
+//! The verdict is stored as `requests.jwt_valid` and the token's claims as
+//! `requests.jwt_claims`, also when the token doesn't verify, so a failure
+//! can be debugged. With `reject` set, a request that fails is answered 401
+//! `invalid_jwt` and not stored.
+//!
+//! Configs are cached per slug like the other endpoint caches and dropped on
+//! `endpoint_config` notifications. Key sets are cached per URL for
+//! [`JWKS_TTL`]; a token with an unknown `kid` refetches them, at most once
+//! per [`JWKS_REFETCH_INTERVAL`]. JWKS hosts go through the same private
+//! address checks as notification URLs.
+
+use axum::http::HeaderMap;
+use base64::Engine;
+use base64::engine::general_purpose::URL_SAFE_NO_PAD as BASE64URL;
+use chrono::{DateTime, Utc};
+use ring::{hmac, signature};
+use serde::Deserialize;
+use serde_json::Value;
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+use tokio::sync::Mutex;
+
+use crate::slug_cache::{EndpointCache, SlugCache, load_json};
+
+/// Slack allowed on `exp` and `nbf` for clock skew.
+const LEEWAY_SECS: i64 = 60;
+
+/// Claims larger than this (as JSON) are not stored.
+const MAX_CLAIMS_BYTES: usize = 8 * 1024;
+
+/// Maximum cached key sets before expired entries are pruned.
+const CACHE_MAX: usize = 10_000;
+
+/// How long a fetched key set is used before it is fetched again.
+const JWKS_TTL: Duration = Duration::from_secs(600);
+
+/// Minimum time between fetches of one key set, so unknown `kid`s and
+/// unreachable hosts can't turn every request into a fetch.
+const JWKS_REFETCH_INTERVAL: Duration = Duration::from_secs(60);
+
+/// Budget for resolving and fetching a key set.
+const JWKS_TIMEOUT: Duration = Duration::from_secs(3);
+
+/// Key sets larger than this are refused.
+const MAX_JWKS_BYTES: usize = 256 * 1024;
+
+fn default_header() -> String {
+    "authorization".to_string()
+}
+
+/// An endpoint's `jwt_verification` config. The web API guarantees exactly
+/// one of `secret` and `jwks_url`.
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JwtConfig {
+    /// Header carrying the token, with or without a `Bearer ` prefix
+    #[serde(default = "default_header")]
+    pub header: String,
+    secret: Option<String>,
+    pub jwks_url: Option<String>,
+    pub issuer: Option<String>,
+    pub audience: Option<String>,
+    /// Refuse requests whose token doesn't verify
+    #[serde(default)]
+    pub reject: bool,
+}
+
+/// The outcome of checking one request.
+#[derive(Debug, Default, PartialEq)]
+pub struct JwtVerdict {
+    pub valid: bool,
+    /// The token's payload, when it decodes (verified or not)
+    pub claims: Option<Value>,
+}
+
+/// A token split into the parts verification needs.
+struct Token<'a> {
+    alg: String,
+    kid: Option<String>,
+    /// `<header>.<payload>` as sent, which the signature covers
+    signing_input: &'a str,
+    signature: Vec<u8>,
+    claims: Value,
+}
+
+#[derive(Deserialize)]
+struct TokenHeader {
+    alg: String,
+    kid: Option<String>,
+}
+
+fn parse_token(value: &str) -> Option<Token<'_>> {
+    let (signing_input, signature) = value.rsplit_once('.')?;
+    let (header, payload) = signing_input.split_once('.')?;
+    let header: TokenHeader = serde_json::from_slice(&BASE64URL.decode(header).ok()?).ok()?;
+    let claims: Value = serde_json::from_slice(&BASE64URL.decode(payload).ok()?).ok()?;
+    if !claims.is_object() {
+        return None;
+    }
+    Some(Token {
+        alg: header.alg,
+        kid: header.kid,
+        signing_input,
+        signature: BASE64URL.decode(signature).ok()?,
+        claims,
+    })
+}
+
+impl JwtConfig {
+    /// The token in the configured header, without a `Bearer ` prefix.
+    fn token<'a>(&self, headers: &'a HeaderMap) -> Option<&'a str> {
+        let value = headers.get(self.header.as_str())?.to_str().ok()?.trim();
+        let token = match value.get(..7) {
+            Some(scheme) if scheme.eq_ignore_ascii_case("bearer ") => value[7..].trim_start(),
+            _ => value,
+        };
+        (!token.is_empty()).then_some(token)
+    }
+
+    /// Registered claims: `exp`, `nbf`, and `iss`/`aud` when configured.
+    fn claims_valid(&self, claims: &Value, received_at: DateTime<Utc>) -> bool {
+        let now = received_at.timestamp();
+        if let Some(exp) = claims.get("exp") {
+            match exp.as_i64() {
+                Some(exp) if now <= exp + LEEWAY_SECS => {}
+                _ => return false,
+            }
+        }
+        if let Some(nbf) = claims.get("nbf") {
+            match nbf.as_i64() {
+                Some(nbf) if now >= nbf - LEEWAY_SECS => {}
+                _ => return false,
+            }
+        }
+        if let Some(ref issuer) = self.issuer
+            && claims.get("iss").and_then(Value::as_str) != Some(issuer.as_str())
+        {
+            return false;
+        }
+        if let Some(ref audience) = self.audience {
+            let matches = match claims.get("aud") {
+                Some(Value::String(aud)) => aud == audience,
+                Some(Value::Array(auds)) => auds.iter().any(|a| a.as_str() == Some(audience)),
+                _ => false,
+            };
+            if !matches {
+                return false;
+            }
+        }
+        true
+    }
+}
+
+fn verify_hmac(secret: &str, alg: &str, message: &[u8], tag: &[u8]) -> bool {
+    let algorithm = match alg {
+        "HS256" => hmac::HMAC_SHA256,
+        "HS384" => hmac::HMAC_SHA384,
+        "HS512" => hmac::HMAC_SHA512,
+        _ => return false,
+    };
+    hmac::verify(&hmac::Key::new(algorithm, secret.as_bytes()), message, tag).is_ok()
+}
+
+/// One key of a JWKS document. Members a key type doesn't use stay `None`.
+#[derive(Debug, Deserialize)]
+pub struct Jwk {
+    kty: String,
+    kid: Option<String>,
+    alg: Option<String>,
+    crv: Option<String>,
+    n: Option<String>,
+    e: Option<String>,
+    x: Option<String>,
+    y: Option<String>,
+}
+
+#[derive(Deserialize)]
+struct JwkSet {
+    keys: Vec<Jwk>,
+}
+
+fn decode_member(value: &Option<String>) -> Option<Vec<u8>> {
+    BASE64URL.decode(value.as_deref()?).ok()
+}
+
+impl Jwk {
+    /// Whether `sig` is this key's signature of `message` under `alg`.
+    fn verify(&self, alg: &str, message: &[u8], sig: &[u8]) -> bool {
+        if self.alg.as_deref().is_some_and(|a| a != alg) {
+            return false;
+        }
+        match (self.kty.as_str(), alg) {
+            ("RSA", "RS256" | "RS384" | "RS512" | "PS256" | "PS384" | "PS512") => {
+                let params: &signature::RsaParameters = match alg {
+                    "RS256" => &signature::RSA_PKCS1_2048_8192_SHA256,
+                    "RS384" => &signature::RSA_PKCS1_2048_8192_SHA384,
+                    "RS512" => &signature::RSA_PKCS1_2048_8192_SHA512,
+                    "PS256" => &signature::RSA_PSS_2048_8192_SHA256,
+                    "PS384" => &signature::RSA_PSS_2048_8192_SHA384,
+                    _ => &signature::RSA_PSS_2048_8192_SHA512,
+                };
+                let (Some(n), Some(e)) = (decode_member(&self.n), decode_member(&self.e)) else {
+                    return false;
+                };
+                signature::RsaPublicKeyComponents { n, e }.verify(params, message, sig).is_ok()
+            }
+            ("EC", "ES256" | "ES384") => {
+                let (params, curve): (&signature::EcdsaVerificationAlgorithm, _) = match alg {
+                    "ES256" => (&signature::ECDSA_P256_SHA256_FIXED, "P-256"),
+                    _ => (&signature::ECDSA_P384_SHA384_FIXED, "P-384"),
+                };
+                if self.crv.as_deref() != Some(curve) {
+                    return false;
+                }
+                let (Some(x), Some(y)) = (decode_member(&self.x), decode_member(&self.y)) else {
+                    return false;
+                };
+                let mut point = Vec::with_capacity(1 + x.len() + y.len());
+                point.push(0x04);
+                point.extend_from_slice(&x);
+                point.extend_from_slice(&y);
+                signature::UnparsedPublicKey::new(params, point).verify(message, sig).is_ok()
+            }
+            ("OKP", "EdDSA") if self.crv.as_deref() == Some("Ed25519") => {
+                let Some(x) = decode_member(&self.x) else {
+                    return false;
+                };
+                signature::UnparsedPublicKey::new(&signature::ED25519, x)
+                    .verify(message, sig)
+                    .is_ok()
+            }
+            _ => false,
+        }
+    }
+}
+
+struct CachedKeys {
+    fetched_at: Instant,
+    /// Empty after a failed fetch; retried after [`JWKS_REFETCH_INTERVAL`].
+    keys: Arc<Vec<Jwk>>,
+}
+
+/// Fetched key sets by URL.
+#[derive(Clone, Default)]
+struct JwksCache {
+    entries: Arc<Mutex<HashMap<String, CachedKeys>>>,
+}
+
+impl JwksCache {
+    /// The key set at `url`. With `refetch`, a set older than
+    /// [`JWKS_REFETCH_INTERVAL`] is fetched again (a token named a `kid` it
+    /// doesn't have).
+    async fn keys(&self, url: &str, refetch: bool) -> Arc<Vec<Jwk>> {
+        {
+            let map = self.entries.lock().await;
+            if let Some(entry) = map.get(url) {
+                let age = entry.fetched_at.elapsed();
+                let fresh = if refetch || entry.keys.is_empty() {
+                    age < JWKS_REFETCH_INTERVAL
+                } else {
+                    age < JWKS_TTL
+                };
+                if fresh {
+                    return entry.keys.clone();
+                }
+            }
+        }
+
+        let keys = match tokio::time::timeout(JWKS_TIMEOUT, fetch_jwks(url)).await {
+            Ok(Ok(keys)) => keys,
+            Ok(Err(e)) => {
+                tracing::warn!(url, error = e, "JWKS fetch failed");
+                Vec::new()
+            }
+            Err(_) => {
+                tracing::warn!(url, "JWKS fetch timed out");
+                Vec::new()
+            }
+        };
+        let keys = Arc::new(keys);
+
+        let now = Instant::now();
+        let mut map = self.entries.lock().await;
+        if map.len() >= CACHE_MAX {
+            map.retain(|_, entry| now.duration_since(entry.fetched_at) < JWKS_TTL);
+        }
+        map.insert(
+            url.to_string(),
+            CachedKeys {
+                fetched_at: now,
+                keys: keys.clone(),
+            },
+        );
+        keys
+    }
+}
+
+/// GET a key set, pinned to addresses that passed the SSRF checks.
+async fn fetch_jwks(url: &str) -> Result<Vec<Jwk>, &'static str> {
+    let resolved = crate::handlers::webhook::resolve_notification_target(url).await?;
+    let client = reqwest::Client::builder()
+        .timeout(JWKS_TIMEOUT)
+        .redirect(reqwest::redirect::Policy::none())
+        .resolve_to_addrs(&resolved.host, &resolved.addrs)
+        .build()
+        .map_err(|_| "failed to build client")?;
+    let response = client
+        .get(&resolved.url)
+        .header("accept", "application/json")
+        .send()
+        .await
+        .map_err(|_| "request failed")?;
+    if !response.status().is_success() {
+        return Err("non-success status");
+    }
+    if response.content_length().is_some_and(|len| len > MAX_JWKS_BYTES as u64) {
+        return Err("key set too large");
+    }
+    let body = response.bytes().await.map_err(|_| "failed to read body")?;
+    if body.len() > MAX_JWKS_BYTES {
+        return Err("key set too large");
+    }
+    let set: JwkSet = serde_json::from_slice(&body).map_err(|_| "invalid JWKS document")?;
+    Ok(set.keys)
+}
+
+/// Whether one of `keys` signed the token: the key named by `kid`, or any
+/// key when the token names none.
+fn verify_with_keys(keys: &[Jwk], token: &Token<'_>) -> bool {
+    keys.iter()
+        .filter(|key| token.kid.is_none() || key.kid == token.kid)
+        .any(|key| key.verify(&token.alg, token.signing_input.as_bytes(), &token.signature))
+}
+
+/// Per-slug JWT configs and the key sets they point at, shared across
+/// requests via AppState.
+#[derive(Clone, Default)]
+pub struct JwtCache {
+    configs: SlugCache<Option<Arc<JwtConfig>>>,
+    jwks: JwksCache,
+}
+
+impl JwtCache {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Look up an endpoint's config, reading through to Postgres on a miss.
+    /// `None` when the endpoint doesn't validate JWTs. Lookup failures fail
+    /// open and are not cached.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Arc<JwtConfig>> {
+        self.configs
+            .get_or_load(slug, |_| async move {
+                let config: Option<JwtConfig> = load_json(
+                    pool,
+                    slug,
+                    "get_endpoint_jwt_verification",
+                    "jwt_verification",
+                )
+                .await?;
+                Some(config.map(Arc::new))
+            })
+            .await
+            .flatten()
+    }
+
+    /// Check the request's token against `config`. A missing or malformed
+    /// token is invalid.
+    pub async fn verify(
+        &self,
+        config: &JwtConfig,
+        headers: &HeaderMap,
+        received_at: DateTime<Utc>,
+    ) -> JwtVerdict {
+        let Some(token) = config.token(headers).and_then(parse_token) else {
+            return JwtVerdict::default();
+        };
+        let claims = serde_json::to_vec(&token.claims)
+            .is_ok_and(|json| json.len() <= MAX_CLAIMS_BYTES)
+            .then(|| token.claims.clone());
+
+        let signed = match (&config.secret, &config.jwks_url) {
+            (Some(secret), _) => verify_hmac(
+                secret,
+                &token.alg,
+                token.signing_input.as_bytes(),
+                &token.signature,
+            ),
+            (None, Some(url)) => {
+                let keys = self.jwks.keys(url, false).await;
+                let known = token.kid.is_none() || keys.iter().any(|key| key.kid == token.kid);
+                if known {
+                    verify_with_keys(&keys, &token)
+                } else {
+                    verify_with_keys(&self.jwks.keys(url, true).await, &token)
+                }
+            }
+            (None, None) => false,
+        };
+
+        JwtVerdict {
+            valid: signed && config.claims_valid(&token.claims, received_at),
+            claims,
+        }
+    }
+
+}
+
+impl EndpointCache for JwtCache {
+    fn invalidate(&self, slug: &str) {
+        self.configs.invalidate(slug);
+    }
+
+    fn clear(&self) {
+        self.configs.clear();
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::http::HeaderValue;
+    use chrono::TimeZone;
+    use ring::rand::SystemRandom;
+    use ring::signature::{ECDSA_P256_SHA256_FIXED_SIGNING, EcdsaKeyPair, KeyPair};
+
+    const SECRET: &str = "jwt-secret";
+    const NOW: i64 = 1_700_000_000;
+
+    fn config(value: Value) -> JwtConfig {
+        serde_json::from_value(value).unwrap()
+    }
+
+    fn encode(value: &Value) -> String {
+        BASE64URL.encode(serde_json::to_vec(value).unwrap())
+    }
+
+    fn hs256(claims: &Value) -> String {
+        let input = format!("{}.{}", encode(&serde_json::json!({"alg": "HS256"})), encode(claims));
+        let key = hmac::Key::new(hmac::HMAC_SHA256, SECRET.as_bytes());
+        let tag = hmac::sign(&key, input.as_bytes());
+        format!("{input}.{}", BASE64URL.encode(tag.as_ref()))
+    }
+
+    fn bearer(token: &str) -> HeaderMap {
+        let mut headers = HeaderMap::new();
+        headers.insert("authorization", HeaderValue::from_str(&format!("Bearer {token}")).unwrap());
+        headers
+    }
+
+    fn at(secs: i64) -> DateTime<Utc> {
+        Utc.timestamp_opt(secs, 0).unwrap()
+    }
+
+    #[tokio::test]
+    async fn shared_secret_checks_signature_and_claims() {
+        let cache = JwtCache::new();
+        let config = config(serde_json::json!({"secret": SECRET, "issuer": "zoom", "audience": "me"}));
+        let claims = serde_json::json!({"iss": "zoom", "aud": ["me"], "exp": NOW + 300, "sub": "u1"});
+        let token = hs256(&claims);
+
+        let verdict = cache.verify(&config, &bearer(&token), at(NOW)).await;
+        assert_eq!(verdict, JwtVerdict { valid: true, claims: Some(claims.clone()) });
+
+        // Expired beyond the leeway, and a tampered payload, keep their claims
+        let expired = cache.verify(&config, &bearer(&token), at(NOW + 300 + LEEWAY_SECS + 1)).await;
+        assert!(!expired.valid && expired.claims.is_some());
+        let (_, rest) = token.split_once('.').unwrap();
+        let forged = format!("{}.{rest}", encode(&serde_json::json!({"alg": "HS384"})));
+        assert!(!cache.verify(&config, &bearer(&forged), at(NOW)).await.valid);
+
+        let other_issuer = hs256(&serde_json::json!({"iss": "docusign", "aud": "me"}));
+        assert!(!cache.verify(&config, &bearer(&other_issuer), at(NOW)).await.valid);
+        assert_eq!(cache.verify(&config, &HeaderMap::new(), at(NOW)).await, JwtVerdict::default());
+    }
+
+    #[test]
+    fn token_is_read_from_the_configured_header() {
+        let config = config(serde_json::json!({"secret": SECRET, "header": "x-zm-token"}));
+        let mut headers = HeaderMap::new();
+        headers.insert("x-zm-token", HeaderValue::from_static("abc.def.ghi"));
+        assert_eq!(config.token(&headers), Some("abc.def.ghi"));
+        assert_eq!(config.token(&bearer("abc.def.ghi")), None);
+        assert!(parse_token("abc.def.ghi").is_none());
+    }
+
+    #[test]
+    fn jwk_verifies_es256_and_refuses_hmac() {
+        let rng = SystemRandom::new();
+        let pkcs8 = EcdsaKeyPair::generate_pkcs8(&ECDSA_P256_SHA256_FIXED_SIGNING, &rng).unwrap();
+        let pair =
+            EcdsaKeyPair::from_pkcs8(&ECDSA_P256_SHA256_FIXED_SIGNING, pkcs8.as_ref(), &rng).unwrap();
+        let point = pair.public_key().as_ref();
+        let jwk: Jwk = serde_json::from_value(serde_json::json!({
+            "kty": "EC",
+            "kid": "k1",
+            "crv": "P-256",
+            "x": BASE64URL.encode(&point[1..33]),
+            "y": BASE64URL.encode(&point[33..65]),
+        }))
+        .unwrap();
+
+        let input = format!(
+            "{}.{}",
+            encode(&serde_json::json!({"alg": "ES256", "kid": "k1"})),
+            encode(&serde_json::json!({"sub": "u1"}))
+        );
+        let sig = pair.sign(&rng, input.as_bytes()).unwrap();
+        let token = format!("{input}.{}", BASE64URL.encode(sig.as_ref()));
+        let parsed = parse_token(&token).unwrap();
+
+        assert!(verify_with_keys(&[jwk], &parsed));
+        let hmac_key: Jwk =
+            serde_json::from_value(serde_json::json!({"kty": "oct", "kid": "k1"})).unwrap();
+        assert!(!hmac_key.verify("HS256", input.as_bytes(), sig.as_ref()));
+    }
+}
@@ -0,0 +1,253 @@
+//! Lifecycle events: machine-readable notices of changes in the receiver's
+//! own state, for platform automation (scaling, paging, deploy gates) that
+//! would otherwise scrape logs.
+//!
+//! Every event is logged ("lifecycle event", with `event` and `details`) and, when
+//! `LIFECYCLE_EVENTS_URL` is set, POSTed there as JSON. With
+//! `LIFECYCLE_EVENTS_SECRET` the body is signed (HMAC-SHA256, hex) in
+//! [`SIGNATURE_HEADER`]. Delivery is best effort: a few attempts in the
+//! background, except for `instance_stopped`, which shutdown waits for.
+//!
+//! Conditions that repeat (dead letters, capture failures) are announced at
+//! most once per [`REPEAT_INTERVAL`]; the next event counts the occurrences
+//! that weren't announced.
+
+use chrono::Utc;
+use hmac::{Hmac, Mac};
+use sha2::Sha256;
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+
+/// Carries `sha256=<hex HMAC of the body>` when a secret is configured.
+pub const SIGNATURE_HEADER: &str = "x-webhooks-cc-signature";
+
+/// Per-attempt timeout.
+const DELIVERY_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Delivery attempts per event.
+const MAX_ATTEMPTS: u32 = 3;
+
+/// Base backoff between attempts (doubled each retry).
+const RETRY_BACKOFF: Duration = Duration::from_millis(500);
+
+/// Shortest time between two events for the same repeating condition.
+const REPEAT_INTERVAL: Duration = Duration::from_secs(300);
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum Event {
+    /// Listeners are bound and the receiver is taking requests.
+    InstanceStarted,
+    /// A shutdown signal arrived; in-flight requests are finishing.
+    DrainStarted,
+    /// Draining finished and pending reports were flushed.
+    InstanceStopped,
+    /// A function sink payload went to the dead-letter table.
+    DeadLettered,
+    /// A request could not be stored and was answered anyway (fail open),
+    /// which usually means the database is unreachable.
+    CaptureFailing,
+}
+
+impl Event {
+    pub fn name(self) -> &'static str {
+        match self {
+            Event::InstanceStarted => "instance_started",
+            Event::DrainStarted => "drain_started",
+            Event::InstanceStopped => "instance_stopped",
+            Event::DeadLettered => "dlq_non_empty",
+            Event::CaptureFailing => "capture_failing",
+        }
+    }
+
+    fn repeats(self) -> bool {
+        matches!(self, Event::DeadLettered | Event::CaptureFailing)
+    }
+}
+
+/// When a repeating event was last announced, and how often it has happened since.
+struct Announced {
+    at: Instant,
+    suppressed: u32,
+}
+
+struct Sink {
+    http: reqwest::Client,
+    url: String,
+    secret: Option<String>,
+}
+
+/// Shared emitter stored in AppState. Cheap to clone.
+#[derive(Clone, Default)]
+pub struct Lifecycle {
+    sink: Option<Arc<Sink>>,
+    announced: Arc<Mutex<HashMap<Event, Announced>>>,
+}
+
+impl Lifecycle {
+    /// An emitter posting to `url`, or only logging without one. An invalid
+    /// URL is logged and ignored.
+    pub fn new(url: Option<&str>, secret: Option<String>) -> Self {
+        let sink = url.and_then(|url| {
+            let parsed = url::Url::parse(url).ok();
+            if !parsed.is_some_and(|u| matches!(u.scheme(), "http" | "https") && u.host_str().is_some()) {
+                tracing::warn!("invalid LIFECYCLE_EVENTS_URL, lifecycle events will only be logged");
+                return None;
+            }
+            let http = reqwest::Client::builder()
+                .timeout(DELIVERY_TIMEOUT)
+                .redirect(reqwest::redirect::Policy::none())
+                .build()
+                .expect("failed to build lifecycle HTTP client");
+            Some(Arc::new(Sink { http, url: url.to_string(), secret }))
+        });
+        Self { sink, announced: Arc::default() }
+    }
+
+    /// Announce `event` in the background.
+    pub fn emit(&self, event: Event, details: serde_json::Value) {
+        if let Some((body, sink)) = self.prepare(event, details, Instant::now()) {
+            tokio::spawn(async move { deliver(&sink, event, body).await });
+        }
+    }
+
+    /// Announce `event` and wait for the delivery to finish or give up.
+    pub async fn send(&self, event: Event, details: serde_json::Value) {
+        if let Some((body, sink)) = self.prepare(event, details, Instant::now()) {
+            deliver(&sink, event, body).await;
+        }
+    }
+
+    /// Log the event and build the body to post, or `None` when it is
+    /// throttled or there is nowhere to post it.
+    fn prepare(
+        &self,
+        event: Event,
+        mut details: serde_json::Value,
+        now: Instant,
+    ) -> Option<(Vec<u8>, Arc<Sink>)> {
+        if event.repeats() {
+            let suppressed = self.admit(event, now)?;
+            details["suppressed"] = suppressed.into();
+        }
+        let payload = payload(event, details);
+        tracing::info!(event = event.name(), details = %payload["details"], "lifecycle event");
+        let sink = self.sink.clone()?;
+        Some((serde_json::to_vec(&payload).ok()?, sink))
+    }
+
+    /// Whether a repeating event may be announced now. Returns how many were
+    /// held back since the last announcement, or `None` to hold this one back.
+    fn admit(&self, event: Event, now: Instant) -> Option<u32> {
+        let mut announced = self.announced.lock().unwrap_or_else(|e| e.into_inner());
+        match announced.get_mut(&event) {
+            Some(last) if now.duration_since(last.at) < REPEAT_INTERVAL => {
+                last.suppressed = last.suppressed.saturating_add(1);
+                None
+            }
+            Some(last) => {
+                let suppressed = last.suppressed;
+                *last = Announced { at: now, suppressed: 0 };
+                Some(suppressed)
+            }
+            None => {
+                announced.insert(event, Announced { at: now, suppressed: 0 });
+                Some(0)
+            }
+        }
+    }
+}
+
+fn payload(event: Event, details: serde_json::Value) -> serde_json::Value {
+    serde_json::json!({
+        "event": event.name(),
+        "instance": gethostname::gethostname().to_string_lossy(),
+        "pid": std::process::id(),
+        "version": env!("CARGO_PKG_VERSION"),
+        "at": Utc::now().to_rfc3339(),
+        "details": details,
+    })
+}
+
+fn sign(secret: &str, body: &[u8]) -> String {
+    let mut mac = Hmac::<Sha256>::new_from_slice(secret.as_bytes()).expect("HMAC accepts any key length");
+    mac.update(body);
+    format!("sha256={}", hex::encode(mac.finalize().into_bytes()))
+}
+
+async fn deliver(sink: &Sink, event: Event, body: Vec<u8>) {
+    let signature = sink.secret.as_deref().map(|secret| sign(secret, &body));
+    for attempt in 1..=MAX_ATTEMPTS {
+        let mut req = sink
+            .http
+            .post(&sink.url)
+            .header("content-type", "application/json")
+            .body(body.clone());
+        if let Some(ref signature) = signature {
+            req = req.header(SIGNATURE_HEADER, signature);
+        }
+        match req.send().await {
+            Ok(resp) if resp.status().is_success() => return,
+            Ok(resp) => {
+                tracing::debug!(event = event.name(), status = resp.status().as_u16(), attempt, "lifecycle event refused");
+            }
+            Err(e) => tracing::debug!(event = event.name(), error = %e, attempt, "lifecycle event delivery failed"),
+        }
+        if attempt < MAX_ATTEMPTS {
+            tokio::time::sleep(RETRY_BACKOFF * 2u32.pow(attempt - 1)).await;
+        }
+    }
+    tracing::warn!(event = event.name(), "could not deliver lifecycle event");
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn payload_names_the_event_and_instance() {
+        let payload = payload(Event::DrainStarted, serde_json::json!({}));
+        assert_eq!(payload["event"], "drain_started");
+        assert_eq!(payload["pid"], std::process::id());
+        assert!(payload["instance"].as_str().is_some_and(|s| !s.is_empty()));
+        assert!(payload["at"].as_str().is_some());
+        assert_eq!(payload["details"], serde_json::json!({}));
+    }
+
+    #[test]
+    fn repeating_events_are_throttled() {
+        let lifecycle = Lifecycle::default();
+        let start = Instant::now();
+        assert_eq!(lifecycle.admit(Event::DeadLettered, start), Some(0));
+        assert_eq!(lifecycle.admit(Event::DeadLettered, start + Duration::from_secs(1)), None);
+        assert_eq!(lifecycle.admit(Event::DeadLettered, start + Duration::from_secs(2)), None);
+        // Other conditions are throttled separately
+        assert_eq!(lifecycle.admit(Event::CaptureFailing, start), Some(0));
+        assert_eq!(lifecycle.admit(Event::DeadLettered, start + REPEAT_INTERVAL), Some(2));
+        assert_eq!(lifecycle.admit(Event::DeadLettered, start + REPEAT_INTERVAL * 2), Some(0));
+    }
+
+    #[test]
+    fn one_off_events_are_never_throttled() {
+        let lifecycle = Lifecycle::default();
+        let now = Instant::now();
+        assert!(lifecycle.prepare(Event::DrainStarted, serde_json::json!({}), now).is_none());
+        assert!(lifecycle.announced.lock().unwrap().is_empty());
+    }
+
+    #[test]
+    fn signature_is_hmac_of_the_body() {
+        assert_eq!(
+            sign("secret", br#"{"event":"drain_started"}"#),
+            "sha256=c8123746a2987b7f5b01d69333b66e46f0ed7674838f2acbead7ba1cab2a887d"
+        );
+    }
+
+    #[test]
+    fn invalid_urls_only_log() {
+        assert!(Lifecycle::new(Some("ftp://hooks.example"), None).sink.is_none());
+        assert!(Lifecycle::new(Some("not a url"), None).sink.is_none());
+        assert!(Lifecycle::new(Some("https://hooks.example/receiver"), None).sink.is_some());
+        assert!(Lifecycle::new(None, None).sink.is_none());
+    }
+}
@@ -1,19 +1,73 @@
+mod acme;
+mod auto_extend;
+mod body_limit;
+mod body_store;
+mod bypass;
+mod canonical;
+mod capture_auth;
+mod capture_filter;
+mod capture_failures;
+mod chaos;
+mod client_cert;
+mod cloudevents;
 mod config;
+mod config_events;
+mod content_class;
+mod correlate;
+mod cors;
+mod custom_domain;
+mod expiry;
+mod fingerprint;
+mod function_sink;
+mod geoip;
 mod handlers;
-
-use axum::Router;
+mod handshake;
+mod header_crypt;
+mod idempotency;
+mod json_schema;
+mod jwt;
+mod lifecycle;
+mod mirror;
+mod mock_cache;
+mod mock_echo;
+mod mock_negotiation;
+mod mock_rules;
+mod mock_script;
+mod mock_sequence;
+mod mock_stream;
+mod mock_template;
+mod mtls;
+mod multipart;
+mod path;
+mod provider_ips;
+mod proxy;
+mod rate_limit;
+mod redact;
+mod sampling;
+mod signature;
+mod sink_latency;
+mod slug_cache;
+mod tenant;
+mod tls_info;
+mod transform;
+mod validate;
+
+use axum::{Router, ServiceExt};
 use axum::routing::{any, get};
 use sqlx::postgres::PgPoolOptions;
 use sqlx::PgPool;
 use tokio::net::TcpListener;
 use tokio::signal;
+use tower::Layer;
 use tower_http::cors::{Any, CorsLayer};
 use tower_http::limit::RequestBodyLimitLayer;
 use tower_http::trace::TraceLayer;
 
 use config::Config;
 
+/// Largest body stored inline; bigger ones need object storage (see `body_store`).
 const MAX_BODY_SIZE: usize = 1_024 * 1_024; // 1MB
+const TLS_HANDSHAKE_TIMEOUT: std::time::Duration = std::time::Duration::from_secs(10);
 
 /// Shared application state passed to all handlers.
 #[derive(Clone)]
@@ -22,6 +76,17 @@ pub struct AppState {
     pub config: Config,
     pub notification_limiter: handlers::webhook::NotificationLimiter,
     pub redis: Option<redis::aio::MultiplexedConnection>,
+    pub function_sink: Option<function_sink::FunctionSinkDispatcher>,
+    pub caches: config_events::EndpointCaches,
+    pub sequences: mock_sequence::SequenceTracker,
+    pub scripts: mock_script::ScriptCache,
+    pub header_cipher: Option<std::sync::Arc<header_crypt::HeaderCipher>>,
+    pub mirror: Option<mirror::Mirror>,
+    pub capture_failures: capture_failures::CaptureFailureTracker,
+    pub activity: auto_extend::ActivityTracker,
+    pub body_store: Option<body_store::BodyStore>,
+    pub tenants: tenant::TenantLimiter,
+    pub geoip: Option<std::sync::Arc<geoip::GeoIp>>,
 }
 
 /// Build an OpenTelemetry tracer provider exporting spans to the given collector URL.
@@ -76,6 +141,18 @@ fn init_otel(
 
 #[tokio::main]
 async fn main() {
+    // `--validate`: check configuration and backends, then exit
+    if std::env::args().skip(1).any(|arg| arg == "--validate") {
+        std::process::exit(validate::run().await);
+    }
+
+    // Refuse to start on configuration that would otherwise only fail once
+    // traffic arrives
+    let env_checks = validate::check_env(|name| std::env::var(name).ok());
+    if !validate::report_boot(&env_checks) {
+        std::process::exit(1);
+    }
+
     // Load config
     let config = Config::from_env();
 
@@ -132,6 +209,13 @@ async fn main() {
         "connected to Postgres"
     );
 
+    // Every capture would fail against a database missing migrations
+    let schema = validate::check_schema(&pool).await;
+    if schema.status == validate::Status::Fail {
+        tracing::error!(detail = %schema.detail, "database schema check failed");
+        std::process::exit(1);
+    }
+
     // Connect to Redis (optional — falls back to in-memory rate limiting).
     // NOTE: If Redis is down at startup, we use in-memory fallback for the
     // lifetime of this process. MultiplexedConnection handles reconnection
@@ -170,23 +254,163 @@ async fn main() {
         None => None,
     };
 
+    let tenants = tenant::TenantLimiter::new(config.tenant_limits);
+
+    // Country and ASN of each sender (optional; cf-ipcountry only without it)
+    let geoip = geoip::GeoIp::open(
+        config.geoip_db_path.as_deref(),
+        config.geoip_asn_db_path.as_deref(),
+    )
+    .map(std::sync::Arc::new);
+
+    // Lifecycle events for platform automation (logged; posted when a URL is set)
+    let lifecycle = lifecycle::Lifecycle::new(
+        config.lifecycle_events_url.as_deref(),
+        config.lifecycle_events_secret.clone(),
+    );
+
+    // Function sinks (optional — endpoints with a sink are skipped without credentials)
+    let sink_timings = sink_latency::DeliveryTimings::new();
+    let function_sink = config.aws_credentials.clone().map(|credentials| {
+        tracing::info!(
+            concurrency = config.function_sink_concurrency,
+            "function sinks enabled"
+        );
+        function_sink::FunctionSinkDispatcher::new(
+            pool.clone(),
+            credentials,
+            config.function_sink_concurrency,
+            sink_timings.clone(),
+            tenants.clone(),
+            lifecycle.clone(),
+        )
+    });
+
+    // Traffic mirroring (optional — tees incoming webhooks to a secondary receiver)
+    let mirror = config.mirror_url.as_deref().and_then(|url| {
+        match mirror::Mirror::new(url, config.mirror_sample_percent, config.mirror_concurrency) {
+            Some(m) => {
+                tracing::info!(
+                    sample_percent = config.mirror_sample_percent,
+                    concurrency = config.mirror_concurrency,
+                    "traffic mirroring enabled"
+                );
+                Some(m)
+            }
+            None => {
+                tracing::warn!("invalid MIRROR_URL, mirroring disabled");
+                None
+            }
+        }
+    });
+
+    // Account keys for endpoints that encrypt selected headers (optional —
+    // listed headers are redacted instead when unset)
+    let header_cipher = config.header_encryption_key.as_deref().and_then(|key| {
+        match header_crypt::HeaderCipher::from_hex(key) {
+            Ok(cipher) => Some(std::sync::Arc::new(cipher)),
+            Err(e) => {
+                tracing::warn!(error = e, "invalid HEADER_ENCRYPTION_KEY, encrypted headers will be redacted");
+                None
+            }
+        }
+    });
+
+    // Object storage for bodies over the inline limit (optional — they are
+    // rejected with payload_too_large when unset)
+    let body_store = config.object_storage.as_ref().and_then(|storage| {
+        match body_store::BodyStore::new(storage) {
+            Some(store) => {
+                tracing::info!(
+                    bucket = %storage.bucket,
+                    max_body_bytes = config.object_storage_max_body_bytes,
+                    "large body offload enabled"
+                );
+                Some(store)
+            }
+            None => {
+                tracing::warn!("invalid OBJECT_STORAGE_URL, large bodies will be rejected");
+                None
+            }
+        }
+    });
+    let max_body_size = match body_store {
+        Some(_) => config.object_storage_max_body_bytes.max(MAX_BODY_SIZE),
+        None => MAX_BODY_SIZE,
+    };
+
+    // Report requests that could not be stored to their owners
+    let capture_failures = capture_failures::CaptureFailureTracker::new(lifecycle.clone());
+    let notify = capture_failures::NotifyConfig {
+        proxy_url: config.notify_proxy_url.clone(),
+        proxy_secret: config.notify_secret.clone(),
+    };
+    capture_failures::spawn_flusher(capture_failures.clone(), pool.clone(), notify.clone());
+    sink_latency::spawn_flusher(sink_timings.clone(), pool.clone());
+
+    // Push back the expiry of auto-extending ephemeral endpoints while they
+    // receive traffic
+    let activity = auto_extend::ActivityTracker::new();
+    auto_extend::spawn_flusher(activity.clone(), pool.clone());
+
+    // Keep the provider source ranges network rules can allow up to date
+    provider_ips::spawn_refresher(pool.clone());
+
+    // Drop cached endpoint state as configuration changes are announced
+    let caches = config_events::EndpointCaches::new(max_body_size);
+    config_events::spawn_listener(pool.clone(), caches.clone());
+
     // Build app state
     let state = AppState {
         pool,
         config: config.clone(),
         notification_limiter: handlers::webhook::new_notification_limiter(),
         redis: redis_conn,
+        function_sink,
+        caches,
+        sequences: mock_sequence::SequenceTracker::new(),
+        scripts: mock_script::ScriptCache::new(),
+        header_cipher,
+        mirror,
+        capture_failures: capture_failures.clone(),
+        activity: activity.clone(),
+        body_store,
+        tenants,
+        geoip,
     };
+    let flush_pool = state.pool.clone();
+
+    // Drop cached state of endpoints as soon as they expire
+    expiry::spawn_sweeper(state.clone());
+
+    // gRPC capture listener: every call goes to one handler, so no services
+    // are registered. HTTP/2 without TLS (h2c); TLS ends at the proxy.
+    if let Some(grpc_port) = config.grpc_port {
+        let grpc_app = Router::new()
+            .fallback(handlers::grpc::handle_grpc)
+            .layer(RequestBodyLimitLayer::new(MAX_BODY_SIZE))
+            .with_state(state.clone());
+        let grpc_listener = TcpListener::bind(format!("0.0.0.0:{grpc_port}"))
+            .await
+            .expect("failed to bind gRPC address");
+        tracing::info!(port = grpc_port, "grpc capture listener starting");
+        tokio::spawn(async move {
+            if let Err(e) = axum::serve(grpc_listener, grpc_app)
+                .with_graceful_shutdown(shutdown_signal())
+                .await
+            {
+                tracing::error!(error = %e, "grpc listener failed");
+            }
+        });
+    }
 
-    // CORS: allow all origins on public webhook capture endpoints
+    // CORS: allow all origins on health, WebSocket and unknown routes.
+    // Webhook captures apply their endpoint's own CORS config (cors.rs).
     let public_cors = CorsLayer::new()
         .allow_origin(Any)
         .allow_methods(Any)
         .allow_headers(Any);
-
-    // Public routes: webhook capture + health
-    let app = Router::new()
-        .route("/health", get(handlers::health::health))
+    let webhook_routes = Router::new()
         .route(
             "/w/{slug}/{*path}",
             any(handlers::webhook::handle_webhook),
@@ -194,9 +418,27 @@ async fn main() {
         .route(
             "/w/{slug}",
             any(handlers::webhook::handle_webhook_no_path),
+        );
+
+    // Public routes: webhook and WebSocket capture + health
+    let routes = Router::new()
+        .route("/health", get(handlers::health::health))
+        .route(
+            "/ws/{slug}/{*path}",
+            get(handlers::websocket::handle_websocket),
+        )
+        .route(
+            "/ws/{slug}",
+            get(handlers::websocket::handle_websocket_no_path),
         )
+        .fallback(handlers::error::route_not_found)
         .layer(public_cors)
-        .layer(RequestBodyLimitLayer::new(MAX_BODY_SIZE))
+        .merge(webhook_routes)
+        .layer(RequestBodyLimitLayer::new(max_body_size))
+        .layer(axum::middleware::from_fn_with_state(
+            max_body_size,
+            handlers::error::reject_oversized,
+        ))
         .layer(
             TraceLayer::new_for_http()
                 .on_response(
@@ -204,7 +446,7 @@ async fn main() {
                         .level(tracing::Level::DEBUG),
                 ),
         )
-        .with_state(state);
+        .with_state(state.clone());
 
     // Start server
     let addr = format!("0.0.0.0:{}", config.port);
@@ -214,12 +456,81 @@ async fn main() {
 
     tracing::info!(port = config.port, "webhook receiver starting");
 
-    // Serve with graceful shutdown
-    axum::serve(listener, app)
-        .with_graceful_shutdown(shutdown_signal())
+    // Host-based routing rewrites the URI, so it wraps the router instead of
+    // being one of its layers.
+    let subdomain_host = config.subdomain_host.as_deref().map(std::sync::Arc::<str>::from);
+    let app = axum::middleware::from_fn_with_state(subdomain_host, handlers::subdomain::route_by_host)
+        .layer(routes.clone());
+
+    // mTLS listener: the same routes, with TLS terminated here so client
+    // certificates reach the receiver.
+    if let Some(ref mtls_config) = config.mtls {
+        let tls = match mtls::server_config(&mtls_config.cert_file, &mtls_config.key_file) {
+            Ok(tls) => std::sync::Arc::new(tls),
+            Err(e) => {
+                tracing::error!(error = %e, "invalid mTLS certificate");
+                std::process::exit(1);
+            }
+        };
+        let mtls_listener = TcpListener::bind(format!("0.0.0.0:{}", mtls_config.port))
+            .await
+            .expect("failed to bind mTLS address");
+        tracing::info!(port = mtls_config.port, "mtls capture listener starting");
+        tokio::spawn(serve_mtls(mtls_listener, tls, app.clone()));
+    }
+
+    // Custom domain listener: TLS terminated here with a certificate per
+    // domain, and each request routed to the domain's endpoint.
+    if let Some(ref domain_config) = config.custom_domains {
+        let manager = custom_domain::CertManager::new(state.pool.clone(), domain_config);
+        manager.spawn_renewer();
+        let domain_app = axum::middleware::from_fn_with_state(
+            state.clone(),
+            handlers::subdomain::route_by_custom_domain,
+        )
+        .layer(routes);
+        let domain_listener = TcpListener::bind(format!("0.0.0.0:{}", domain_config.port))
+            .await
+            .expect("failed to bind custom domain address");
+        tracing::info!(port = domain_config.port, "custom domain listener starting");
+        tokio::spawn(serve_custom_domains(
+            domain_listener,
+            manager,
+            state.caches.custom_domains.clone(),
+            state.pool.clone(),
+            domain_app,
+        ));
+    }
+
+    lifecycle.emit(
+        lifecycle::Event::InstanceStarted,
+        serde_json::json!({
+            "port": config.port,
+            "grpc_port": config.grpc_port,
+            "mtls_port": config.mtls.as_ref().map(|m| m.port),
+            "custom_domain_port": config.custom_domains.as_ref().map(|d| d.port),
+        }),
+    );
+
+    // Serve with graceful shutdown. Each connection speaks HTTP/1.1 or, when it
+    // opens with the HTTP/2 preface, h2c; TLS (and ALPN h2) ends at the proxy.
+    let drain = lifecycle.clone();
+    axum::serve(listener, ServiceExt::<axum::extract::Request>::into_make_service(app))
+        .with_graceful_shutdown(async move {
+            shutdown_signal().await;
+            drain.emit(lifecycle::Event::DrainStarted, serde_json::json!({}));
+        })
         .await
         .expect("server error");
 
+    // Report failures counted since the last flush before exiting
+    capture_failures.flush(&flush_pool, &notify).await;
+    sink_timings.flush(&flush_pool).await;
+    activity.flush(&flush_pool).await;
+    lifecycle
+        .send(lifecycle::Event::InstanceStopped, serde_json::json!({}))
+        .await;
+
     // Flush any remaining OTel spans on shutdown
     if let Some(provider) = otel_provider
         && let Err(e) = provider.shutdown()
@@ -228,6 +539,191 @@ async fn main() {
     }
 }
 
+/// Accept TLS connections until shutdown, serving each with HTTP/1.1 or
+/// HTTP/2 as negotiated. Connections in flight at shutdown are dropped when
+/// the main server finishes draining.
+async fn serve_mtls<S>(listener: TcpListener, tls: std::sync::Arc<rustls::ServerConfig>, app: S)
+where
+    S: tower::Service<
+            axum::extract::Request,
+            Response = axum::response::Response,
+            Error = std::convert::Infallible,
+        > + Clone
+        + Send
+        + 'static,
+    S::Future: Send + 'static,
+{
+    let acceptor = tokio_rustls::TlsAcceptor::from(tls);
+    let shutdown = shutdown_signal();
+    tokio::pin!(shutdown);
+    loop {
+        let (tcp, peer) = tokio::select! {
+            accepted = listener.accept() => match accepted {
+                Ok(accepted) => accepted,
+                Err(e) => {
+                    tracing::debug!(error = %e, "mtls accept failed");
+                    continue;
+                }
+            },
+            _ = &mut shutdown => return,
+        };
+        let acceptor = acceptor.clone();
+        let app = app.clone();
+        tokio::spawn(async move {
+            let stream = match tokio::time::timeout(TLS_HANDSHAKE_TIMEOUT, acceptor.accept(tcp)).await {
+                Ok(Ok(stream)) => stream,
+                Ok(Err(e)) => {
+                    tracing::debug!(%peer, error = %e, "mtls handshake failed");
+                    return;
+                }
+                Err(_) => {
+                    tracing::debug!(%peer, "mtls handshake timed out");
+                    return;
+                }
+            };
+            let cert = stream
+                .get_ref()
+                .1
+                .peer_certificates()
+                .and_then(client_cert::ClientCert::from_chain)
+                .map(std::sync::Arc::new);
+            serve_tls_connection(stream, peer, cert, app).await;
+        });
+    }
+}
+
+/// Accept TLS connections for custom domains until shutdown. The certificate
+/// is picked by SNI once the ClientHello is read; hostnames that aren't a
+/// custom domain are refused, and ACME validation handshakes are answered
+/// with their challenge certificate and closed.
+async fn serve_custom_domains<S>(
+    listener: TcpListener,
+    manager: custom_domain::CertManager,
+    domains: custom_domain::DomainCache,
+    pool: PgPool,
+    app: S,
+) where
+    S: tower::Service<
+            axum::extract::Request,
+            Response = axum::response::Response,
+            Error = std::convert::Infallible,
+        > + Clone
+        + Send
+        + 'static,
+    S::Future: Send + 'static,
+{
+    let shutdown = shutdown_signal();
+    tokio::pin!(shutdown);
+    loop {
+        let (tcp, peer) = tokio::select! {
+            accepted = listener.accept() => match accepted {
+                Ok(accepted) => accepted,
+                Err(e) => {
+                    tracing::debug!(error = %e, "custom domain accept failed");
+                    continue;
+                }
+            },
+            _ = &mut shutdown => return,
+        };
+        let manager = manager.clone();
+        let domains = domains.clone();
+        let pool = pool.clone();
+        let app = app.clone();
+        tokio::spawn(async move {
+            let acceptor = tokio_rustls::LazyConfigAcceptor::new(rustls::server::Acceptor::default(), tcp);
+            let start = match tokio::time::timeout(TLS_HANDSHAKE_TIMEOUT, acceptor).await {
+                Ok(Ok(start)) => start,
+                Ok(Err(e)) => {
+                    tracing::debug!(%peer, error = %e, "custom domain handshake failed");
+                    return;
+                }
+                Err(_) => {
+                    tracing::debug!(%peer, "custom domain handshake timed out");
+                    return;
+                }
+            };
+            let hello = start.client_hello();
+            let Some(host) = hello.server_name().map(custom_domain::normalize_host) else {
+                tracing::debug!(%peer, "custom domain handshake without SNI");
+                return;
+            };
+            let challenge = hello
+                .alpn()
+                .is_some_and(|mut protocols| protocols.any(|p| p == acme::ACME_TLS_ALPN));
+
+            if challenge {
+                // The validation server only needs the handshake to complete.
+                let Some(config) = manager.challenge_config(&host) else {
+                    tracing::debug!(%peer, %host, "acme validation without a pending challenge");
+                    return;
+                };
+                if let Ok(Ok(mut stream)) =
+                    tokio::time::timeout(TLS_HANDSHAKE_TIMEOUT, start.into_stream(config)).await
+                {
+                    let _ = tokio::io::AsyncWriteExt::shutdown(&mut stream).await;
+                }
+                return;
+            }
+
+            if domains.get(&pool, &host).await.is_none() {
+                tracing::debug!(%peer, %host, "handshake for an unknown custom domain");
+                return;
+            }
+            let Some(config) = manager.server_config(&host).await else {
+                tracing::debug!(%peer, %host, "no certificate for custom domain");
+                return;
+            };
+            let stream = match tokio::time::timeout(TLS_HANDSHAKE_TIMEOUT, start.into_stream(config)).await {
+                Ok(Ok(stream)) => stream,
+                Ok(Err(e)) => {
+                    tracing::debug!(%peer, %host, error = %e, "custom domain handshake failed");
+                    return;
+                }
+                Err(_) => {
+                    tracing::debug!(%peer, %host, "custom domain handshake timed out");
+                    return;
+                }
+            };
+            serve_tls_connection(stream, peer, None, app).await;
+        });
+    }
+}
+
+/// Serve one TLS connection with HTTP/1.1 or HTTP/2 as negotiated. Requests
+/// arrive without a proxy in front, so they are prepared like mTLS ones and
+/// carry what the handshake negotiated.
+async fn serve_tls_connection<S>(
+    stream: tokio_rustls::server::TlsStream<tokio::net::TcpStream>,
+    peer: std::net::SocketAddr,
+    cert: Option<std::sync::Arc<client_cert::ClientCert>>,
+    app: S,
+) where
+    S: tower::Service<
+            axum::extract::Request,
+            Response = axum::response::Response,
+            Error = std::convert::Infallible,
+        > + Clone
+        + Send
+        + 'static,
+    S::Future: Send + 'static,
+{
+    use hyper_util::rt::{TokioExecutor, TokioIo};
+    use tower::ServiceExt;
+
+    let tls = tls_info::TlsInfo::from_connection(stream.get_ref().1).map(std::sync::Arc::new);
+    let service = hyper::service::service_fn(move |request: hyper::Request<hyper::body::Incoming>| {
+        let mut request = request.map(axum::body::Body::new);
+        mtls::prepare(&mut request, peer, cert.as_ref(), tls.as_ref());
+        app.clone().oneshot(request)
+    });
+    if let Err(e) = hyper_util::server::conn::auto::Builder::new(TokioExecutor::new())
+        .serve_connection_with_upgrades(TokioIo::new(stream), service)
+        .await
+    {
+        tracing::debug!(%peer, error = %e, "tls connection closed with error");
+    }
+}
+
 async fn shutdown_signal() {
     let ctrl_c = async {
         signal::ctrl_c().await.expect("failed to listen for ctrl+c");
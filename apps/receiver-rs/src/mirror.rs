@@ -0,0 +1,187 @@
+//! Traffic mirroring: tee a copy of each incoming webhook to a secondary
+//! receiver (e.g. a staging deployment) to validate a new receiver version
+//! against production traffic.
+//!
+//! Mirroring is fire-and-forget and never affects the response: the original
+//! method, path, query, headers, and raw body are replayed in the background
+//! and the mirror's response is discarded. A semaphore bounds in-flight
+//! copies; when it is exhausted the copy is dropped rather than queued.
+//! Mirrored requests carry `x-webhooks-cc-mirror` and are never re-mirrored,
+//! so two receivers pointed at each other can't loop.
+
+use axum::http::{HeaderMap, Method, Uri};
+use bytes::Bytes;
+use std::sync::Arc;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::time::Duration;
+use tokio::sync::Semaphore;
+
+/// Marks a request as a mirrored copy.
+pub const MIRROR_HEADER: &str = "x-webhooks-cc-mirror";
+
+/// Per-copy timeout. The mirror's latency never reaches the sender, but slow
+/// mirrors would otherwise hold permits.
+const MIRROR_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Connection-level headers that must not be replayed; reqwest sets its own.
+pub(crate) const HOP_HEADERS: &[&str] = &[
+    "connection",
+    "content-length",
+    "expect",
+    "host",
+    "keep-alive",
+    "proxy-authorization",
+    "proxy-connection",
+    "te",
+    "trailer",
+    "transfer-encoding",
+    "upgrade",
+];
+
+/// Shared mirror stored in AppState. Cheap to clone.
+#[derive(Clone)]
+pub struct Mirror {
+    http: reqwest::Client,
+    base_url: Arc<str>,
+    sample_percent: u64,
+    counter: Arc<AtomicU64>,
+    permits: Arc<Semaphore>,
+}
+
+impl Mirror {
+    /// Build a mirror for `base_url` (scheme and host, optionally a path
+    /// prefix). Returns `None` when the URL isn't http(s).
+    pub fn new(base_url: &str, sample_percent: u8, concurrency: usize) -> Option<Self> {
+        let parsed = url::Url::parse(base_url).ok()?;
+        if !matches!(parsed.scheme(), "http" | "https") || parsed.host_str().is_none() {
+            return None;
+        }
+        let http = reqwest::Client::builder()
+            .timeout(MIRROR_TIMEOUT)
+            .redirect(reqwest::redirect::Policy::none())
+            .build()
+            .expect("failed to build mirror HTTP client");
+        Some(Self {
+            http,
+            base_url: base_url.trim_end_matches('/').into(),
+            sample_percent: u64::from(sample_percent.min(100)),
+            counter: Arc::new(AtomicU64::new(0)),
+            permits: Arc::new(Semaphore::new(concurrency.max(1))),
+        })
+    }
+
+    /// Whether this request is selected by the sample rate. Spreads selected
+    /// requests evenly rather than randomly so low rates stay predictable.
+    fn sampled(&self) -> bool {
+        let n = self.counter.fetch_add(1, Ordering::Relaxed) % 100;
+        n < self.sample_percent
+    }
+
+    /// Replay the request against the mirror in the background.
+    pub fn tee(&self, method: &Method, uri: &Uri, headers: &HeaderMap, body: Bytes) {
+        if headers.contains_key(MIRROR_HEADER) || !self.sampled() {
+            return;
+        }
+        let Ok(permit) = self.permits.clone().try_acquire_owned() else {
+            tracing::debug!("mirror concurrency limit reached, dropping copy");
+            return;
+        };
+
+        let url = mirror_url(&self.base_url, uri);
+        let mut req = self
+            .http
+            .request(method.clone(), url)
+            .headers(replay_headers(headers))
+            .header(MIRROR_HEADER, "1");
+        if !body.is_empty() {
+            req = req.body(body);
+        }
+
+        tokio::spawn(async move {
+            let _permit = permit;
+            match req.send().await {
+                Ok(resp) if resp.status().is_server_error() => {
+                    tracing::debug!(status = resp.status().as_u16(), "mirror returned server error");
+                }
+                Ok(_) => {}
+                Err(e) => tracing::debug!(error = %e, "mirror request failed"),
+            }
+        });
+    }
+}
+
+/// The mirror URL for an incoming request: base URL plus the raw path and query.
+fn mirror_url(base_url: &str, uri: &Uri) -> String {
+    let path_and_query = uri.path_and_query().map_or("/", |pq| pq.as_str());
+    format!("{base_url}{path_and_query}")
+}
+
+/// Incoming headers minus hop-by-hop ones.
+pub(crate) fn replay_headers(headers: &HeaderMap) -> HeaderMap {
+    let mut out = headers.clone();
+    for name in HOP_HEADERS {
+        out.remove(*name);
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn new_rejects_non_http_urls() {
+        assert!(Mirror::new("ftp://staging.example", 100, 8).is_none());
+        assert!(Mirror::new("not a url", 100, 8).is_none());
+        assert!(Mirror::new("https://staging.example/", 100, 8).is_some());
+    }
+
+    #[test]
+    fn mirror_url_keeps_raw_path_and_query() {
+        let uri: Uri = "/w/abc/a%2Fb?x=1&y=2".parse().unwrap();
+        assert_eq!(
+            mirror_url("https://staging.example", &uri),
+            "https://staging.example/w/abc/a%2Fb?x=1&y=2"
+        );
+    }
+
+    #[test]
+    fn replay_headers_drops_hop_by_hop() {
+        let mut headers = HeaderMap::new();
+        headers.insert("host", "receiver.example".parse().unwrap());
+        headers.insert("content-length", "5".parse().unwrap());
+        headers.insert("transfer-encoding", "chunked".parse().unwrap());
+        headers.insert("x-signature", "sig".parse().unwrap());
+        headers.insert("cf-connecting-ip", "203.0.113.7".parse().unwrap());
+
+        let out = replay_headers(&headers);
+        assert!(out.get("host").is_none());
+        assert!(out.get("content-length").is_none());
+        assert!(out.get("transfer-encoding").is_none());
+        assert_eq!(out.get("x-signature").unwrap(), "sig");
+        assert_eq!(out.get("cf-connecting-ip").unwrap(), "203.0.113.7");
+    }
+
+    #[test]
+    fn sampling_selects_requested_share() {
+        let mirror = Mirror::new("https://staging.example", 25, 8).unwrap();
+        let selected = (0..400).filter(|_| mirror.sampled()).count();
+        assert_eq!(selected, 100);
+
+        let all = Mirror::new("https://staging.example", 100, 8).unwrap();
+        assert!((0..50).all(|_| all.sampled()));
+
+        let none = Mirror::new("https://staging.example", 0, 8).unwrap();
+        assert!(!(0..50).any(|_| none.sampled()));
+    }
+
+    #[tokio::test]
+    async fn tee_skips_already_mirrored_requests() {
+        let mirror = Mirror::new("http://127.0.0.1:9", 100, 8).unwrap();
+        let mut headers = HeaderMap::new();
+        headers.insert(MIRROR_HEADER, "1".parse().unwrap());
+        mirror.tee(&Method::POST, &"/w/abc".parse().unwrap(), &headers, Bytes::new());
+        // Skipped before sampling, so the counter never advanced.
+        assert_eq!(mirror.counter.load(Ordering::Relaxed), 0);
+    }
+}
@@ -0,0 +1,308 @@
+//! Rendered mock responses for repeated probes.
+//!
+//! Uptime monitors and health checkers hit endpoints with the same bodiless
+//! GET every few seconds. Their replies are rendered once per [`MockKey`] and
+//! reused, so the endpoint's mock configuration isn't parsed and filtered
+//! again for every probe. The key holds everything that picks the reply: the
+//! slug, method, path and correlation value, the representation negotiated
+//! from `Accept`, the schedule window, matched rule, sequence step and
+//! variant, and whether the canary answered. Only GET and HEAD requests
+//! without a body are cached: anything else can correlate on its body, and is
+//! rarely repeated verbatim anyway. Scripted, templated and echo replies
+//! depend on the request itself and are rendered every time.
+//!
+//! Entries are dropped when the endpoint's configuration changes (see
+//! `config_events`); anything missed expires after CACHE_TTL.
+
+use axum::body::{Body, Bytes};
+use axum::http::{HeaderMap, HeaderValue, Method, StatusCode, header};
+use axum::response::Response;
+use ring::rand::{SecureRandom, SystemRandom};
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex, MutexGuard};
+use std::time::{Duration, Instant};
+
+use crate::slug_cache::EndpointCache;
+
+/// How long a rendered response is reused before it is rendered again.
+const CACHE_TTL: Duration = Duration::from_secs(30);
+
+/// Maximum cached responses before expired entries are pruned.
+const CACHE_MAX: usize = 10_000;
+
+/// A mock response ready to send: headers are already filtered by the
+/// endpoint's header policy.
+#[derive(Debug)]
+pub struct RenderedMock {
+    pub status: StatusCode,
+    pub headers: HeaderMap,
+    pub body: Bytes,
+    /// Events sent in place of `body`, for streaming mocks
+    pub stream: Option<crate::mock_stream::Frames>,
+    /// Delay before replying, in milliseconds (uncapped)
+    pub delay: Option<u64>,
+    /// Up to this many milliseconds added to `delay` at random, rolled for
+    /// every reply so cached replies still vary
+    pub delay_jitter: Option<u64>,
+}
+
+impl RenderedMock {
+    /// Plain `200 OK`, sent when a mock can't be rendered.
+    pub fn plain_ok(delay: Option<u64>, delay_jitter: Option<u64>) -> Self {
+        Self {
+            status: StatusCode::OK,
+            headers: HeaderMap::new(),
+            body: Bytes::from_static(b"OK"),
+            stream: None,
+            delay,
+            delay_jitter,
+        }
+    }
+
+    /// `500` with the error, sent when an endpoint's mock script fails so
+    /// its owner can see why in the capture.
+    pub fn script_failed(error: &str) -> Self {
+        let mut headers = HeaderMap::new();
+        headers.insert(
+            header::CONTENT_TYPE,
+            HeaderValue::from_static("text/plain; charset=utf-8"),
+        );
+        Self {
+            status: StatusCode::INTERNAL_SERVER_ERROR,
+            headers,
+            body: Bytes::from(format!("Mock script error: {error}")),
+            stream: None,
+            delay: None,
+            delay_jitter: None,
+        }
+    }
+
+    /// How long to wait before sending this reply: `delay` plus a random
+    /// share of `delay_jitter`, at most `max` milliseconds.
+    pub fn delay_ms(&self, max: u64) -> u64 {
+        let jitter = match self.delay_jitter {
+            Some(jitter) if jitter > 0 => {
+                let mut bytes = [0u8; 8];
+                // Without the OS RNG the reply just goes out without jitter
+                if SystemRandom::new().fill(&mut bytes).is_err() {
+                    0
+                } else {
+                    u64::from_be_bytes(bytes) % (jitter + 1)
+                }
+            }
+            _ => 0,
+        };
+        self.delay.unwrap_or(0).saturating_add(jitter).min(max)
+    }
+
+    /// Bytes the reply's body sends, streamed or not.
+    pub fn body_size(&self) -> usize {
+        match self.stream {
+            Some(ref frames) => frames.size(),
+            None => self.body.len(),
+        }
+    }
+
+    pub fn response(&self) -> Response {
+        let body = match self.stream {
+            Some(ref frames) => frames.body(),
+            None => Body::from(self.body.clone()),
+        };
+        let mut response = Response::new(body);
+        *response.status_mut() = self.status;
+        *response.headers_mut() = self.headers.clone();
+        response
+    }
+}
+
+/// Everything a rendered reply depends on. `correlation` is the value read
+/// from the request for the mock's correlation key, if it has one;
+/// `representation` the media type its `Accept` header picked;
+/// `variant` is the weighted variant capture_webhook picked and `window` the
+/// scheduled window it found open, if any; `rule` is the conditional rule the
+/// request matched and `step` the sequence step whose turn it was. `canary`
+/// is set when the sender was bucketed into a running mock canary, whose
+/// indexes point into the canary's response rather than the stable one.
+#[derive(Debug, Clone, PartialEq, Eq, Hash)]
+pub struct MockKey {
+    pub slug: String,
+    pub method: Method,
+    pub path: String,
+    pub correlation: Option<String>,
+    pub representation: Option<String>,
+    pub variant: Option<usize>,
+    pub window: Option<usize>,
+    pub rule: Option<usize>,
+    pub step: Option<usize>,
+    pub canary: bool,
+}
+
+struct CachedMock {
+    rendered_at: Instant,
+    mock: Arc<RenderedMock>,
+}
+
+/// Whether a request's reply may be served from the cache.
+pub fn cacheable(method: &Method, body: &[u8]) -> bool {
+    (method == Method::GET || method == Method::HEAD) && body.is_empty()
+}
+
+/// Shared across requests via AppState.
+#[derive(Clone, Default)]
+pub struct MockCache {
+    entries: Arc<Mutex<HashMap<MockKey, CachedMock>>>,
+}
+
+impl MockCache {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    fn entries(&self) -> MutexGuard<'_, HashMap<MockKey, CachedMock>> {
+        self.entries.lock().unwrap_or_else(|e| e.into_inner())
+    }
+
+    pub fn get(&self, key: &MockKey) -> Option<Arc<RenderedMock>> {
+        self.entries()
+            .get(key)
+            .filter(|entry| entry.rendered_at.elapsed() < CACHE_TTL)
+            .map(|entry| entry.mock.clone())
+    }
+
+    pub fn insert(&self, key: MockKey, mock: Arc<RenderedMock>) {
+        let now = Instant::now();
+        let mut map = self.entries();
+        if map.len() >= CACHE_MAX {
+            map.retain(|_, entry| now.duration_since(entry.rendered_at) < CACHE_TTL);
+        }
+        map.insert(
+            key,
+            CachedMock {
+                rendered_at: now,
+                mock,
+            },
+        );
+    }
+}
+
+impl EndpointCache for MockCache {
+    /// Drop a slug's rendered responses so the next request renders again.
+    fn invalidate(&self, slug: &str) {
+        self.entries().retain(|key, _| key.slug != slug);
+    }
+
+    fn clear(&self) {
+        self.entries().clear();
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn key(slug: &str, correlation: Option<&str>) -> MockKey {
+        MockKey {
+            slug: slug.to_string(),
+            method: Method::GET,
+            path: "/health".to_string(),
+            correlation: correlation.map(str::to_string),
+            representation: None,
+            variant: None,
+            window: None,
+            rule: None,
+            step: None,
+            canary: false,
+        }
+    }
+
+    fn rendered(body: &'static str) -> Arc<RenderedMock> {
+        Arc::new(RenderedMock {
+            status: StatusCode::OK,
+            headers: HeaderMap::new(),
+            body: Bytes::from_static(body.as_bytes()),
+            stream: None,
+            delay: None,
+            delay_jitter: None,
+        })
+    }
+
+    #[test]
+    fn delay_adds_jitter_up_to_the_cap() {
+        let mock = RenderedMock {
+            delay: Some(100),
+            delay_jitter: Some(50),
+            ..RenderedMock::plain_ok(None, None)
+        };
+        for _ in 0..20 {
+            assert!((100..=150).contains(&mock.delay_ms(30_000)));
+        }
+        assert_eq!(mock.delay_ms(80), 80);
+        assert_eq!(RenderedMock::plain_ok(None, None).delay_ms(30_000), 0);
+    }
+
+    #[test]
+    fn only_bodiless_gets_and_heads_are_cacheable() {
+        assert!(cacheable(&Method::GET, b""));
+        assert!(cacheable(&Method::HEAD, b""));
+        assert!(!cacheable(&Method::GET, b"{}"));
+        assert!(!cacheable(&Method::POST, b""));
+    }
+
+    #[test]
+    fn entries_are_keyed_by_correlation_and_invalidated_per_slug() {
+        let cache = MockCache::new();
+        cache.insert(key("a", Some("eu")), rendered("eu"));
+        cache.insert(key("a", None), rendered("default"));
+        cache.insert(key("b", None), rendered("b"));
+
+        assert_eq!(cache.get(&key("a", Some("eu"))).unwrap().body, "eu");
+        assert_eq!(cache.get(&key("a", None)).unwrap().body, "default");
+        assert!(cache.get(&key("a", Some("us"))).is_none());
+
+        cache.invalidate("a");
+        assert!(cache.get(&key("a", Some("eu"))).is_none());
+        assert!(cache.get(&key("a", None)).is_none());
+        assert!(cache.get(&key("b", None)).is_some());
+    }
+
+    #[test]
+    fn variants_are_cached_separately() {
+        let cache = MockCache::new();
+        let variant = MockKey {
+            variant: Some(0),
+            ..key("a", None)
+        };
+        cache.insert(variant.clone(), rendered("rate_limited"));
+        cache.insert(key("a", None), rendered("default"));
+
+        assert_eq!(cache.get(&variant).unwrap().body, "rate_limited");
+        assert_eq!(cache.get(&key("a", None)).unwrap().body, "default");
+    }
+
+    #[test]
+    fn canary_replies_are_cached_separately() {
+        let cache = MockCache::new();
+        let canary = MockKey {
+            canary: true,
+            ..key("a", None)
+        };
+        cache.insert(canary.clone(), rendered("canary"));
+        cache.insert(key("a", None), rendered("stable"));
+
+        assert_eq!(cache.get(&canary).unwrap().body, "canary");
+        assert_eq!(cache.get(&key("a", None)).unwrap().body, "stable");
+    }
+
+    #[test]
+    fn expired_entries_are_not_served() {
+        let cache = MockCache::new();
+        cache.entries().insert(
+            key("a", None),
+            CachedMock {
+                rendered_at: Instant::now() - CACHE_TTL,
+                mock: rendered("stale"),
+            },
+        );
+        assert!(cache.get(&key("a", None)).is_none());
+    }
+}
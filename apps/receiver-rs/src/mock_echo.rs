@@ -0,0 +1,93 @@
+//! Echo mocks: with `mockResponse.echo` set, the base reply's body is the
+//! request itself as JSON (like httpbin's `/anything`), so senders can check
+//! what they transmitted without opening the dashboard.
+//!
+//! The request is reflected as received: headers before transforms and
+//! redaction (proxy headers are still dropped), and the body byte-for-byte,
+//! as text when it's UTF-8 and base64 otherwise. The reply's status and other
+//! headers come from the mock; rules, sequence steps, correlated entries and
+//! the other replies keep their own bodies.
+
+use base64::Engine;
+use base64::engine::general_purpose::STANDARD as BASE64;
+use bytes::Bytes;
+use serde_json::{Value, json};
+use std::collections::{BTreeMap, HashMap};
+
+/// The request as the sender transmitted it.
+pub struct Echo<'a> {
+    pub method: &'a str,
+    /// Captured path, below the endpoint
+    pub path: &'a str,
+    pub query: &'a HashMap<String, String>,
+    pub headers: &'a HashMap<String, String>,
+    pub body: &'a [u8],
+    pub ip: &'a str,
+}
+
+impl Echo<'_> {
+    /// The reply body: `method`, `path`, `query`, `headers`, `body`, `json`
+    /// (the parsed body, or null) and `ip`, with `bodyEncoding: "base64"`
+    /// for bodies that aren't UTF-8.
+    pub fn body(&self) -> Bytes {
+        let (body, base64) = match std::str::from_utf8(self.body) {
+            Ok(text) => (text.to_string(), false),
+            Err(_) => (BASE64.encode(self.body), true),
+        };
+        let mut echo = json!({
+            "method": self.method,
+            "path": self.path,
+            "query": self.query.iter().collect::<BTreeMap<_, _>>(),
+            "headers": self.headers.iter().collect::<BTreeMap<_, _>>(),
+            "body": body,
+            "json": serde_json::from_slice::<Value>(self.body).unwrap_or(Value::Null),
+            "ip": self.ip,
+        });
+        if base64 {
+            echo["bodyEncoding"] = "base64".into();
+        }
+        let mut out = serde_json::to_vec_pretty(&echo).unwrap_or_default();
+        out.push(b'\n');
+        Bytes::from(out)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn echo(body: &[u8]) -> Value {
+        let query = HashMap::from([("page".to_string(), "2".to_string())]);
+        let headers = HashMap::from([("x-event".to_string(), "order.paid".to_string())]);
+        let echo = Echo {
+            method: "POST",
+            path: "/orders",
+            query: &query,
+            headers: &headers,
+            body,
+            ip: "203.0.113.7",
+        };
+        serde_json::from_slice(&echo.body()).unwrap()
+    }
+
+    #[test]
+    fn reflects_the_request() {
+        let echo = echo(br#"{"id":"o_1"}"#);
+        assert_eq!(echo["method"], "POST");
+        assert_eq!(echo["path"], "/orders");
+        assert_eq!(echo["query"]["page"], "2");
+        assert_eq!(echo["headers"]["x-event"], "order.paid");
+        assert_eq!(echo["body"], r#"{"id":"o_1"}"#);
+        assert_eq!(echo["json"]["id"], "o_1");
+        assert_eq!(echo["ip"], "203.0.113.7");
+        assert!(echo.get("bodyEncoding").is_none());
+    }
+
+    #[test]
+    fn binary_bodies_are_base64() {
+        let echo = echo(&[0xff, 0x00, 0x01]);
+        assert_eq!(echo["body"], "/wAB");
+        assert_eq!(echo["bodyEncoding"], "base64");
+        assert_eq!(echo["json"], Value::Null);
+    }
+}
@@ -0,0 +1,146 @@
+//! Content negotiation for mock responses: `mockResponse.representations`
+//! maps media types (`application/json`, `application/xml`, `text/html`, ...)
+//! to alternative bodies for the base reply, so one endpoint can answer API
+//! clients and browsers each in their own format.
+//!
+//! The representation the request's `Accept` header ranks highest answers,
+//! sent with its media type as `Content-Type`. Ranges are weighed by their
+//! `q` value, then by how specific they are (`text/html` over `text/*`), then
+//! by their order in the header; ranges with `q=0` rule a type out. A type
+//! only reached through `*/*`, or a request without `Accept`, gets the base
+//! body, so clients that accept anything see the mock as configured.
+
+/// The media type in `available` that `accept` prefers, if any. Ties between
+/// equally ranked types go to the first one in `available`.
+pub fn choose<'a>(
+    available: impl IntoIterator<Item = &'a str>,
+    accept: Option<&str>,
+) -> Option<&'a str> {
+    let ranges = parse(accept?);
+    let mut best: Option<(Rank, &str)> = None;
+    for media_type in available {
+        let Some(rank) = rank(&ranges, media_type) else {
+            continue;
+        };
+        if best.as_ref().is_none_or(|(top, _)| rank > *top) {
+            best = Some((rank, media_type));
+        }
+    }
+    best.map(|(_, media_type)| media_type)
+}
+
+/// One media range from an `Accept` header.
+struct Range<'a> {
+    kind: &'a str,
+    subtype: &'a str,
+    /// Quality in thousandths, 0-1000
+    q: u16,
+}
+
+/// How well a media type is accepted: quality, then specificity (2 for an
+/// exact match, 1 for `type/*`), then earlier ranges first.
+#[derive(Clone, Copy, PartialEq, Eq, PartialOrd, Ord)]
+struct Rank {
+    q: u16,
+    specificity: u8,
+    position: std::cmp::Reverse<usize>,
+}
+
+fn parse(accept: &str) -> Vec<Range<'_>> {
+    accept
+        .split(',')
+        .filter_map(|range| {
+            let mut params = range.split(';');
+            let (kind, subtype) = params.next()?.trim().split_once('/')?;
+            let mut q = 1000;
+            for param in params {
+                if let Some((name, value)) = param.split_once('=')
+                    && name.trim().eq_ignore_ascii_case("q")
+                {
+                    q = quality(value.trim())?;
+                }
+            }
+            Some(Range {
+                kind: kind.trim(),
+                subtype: subtype.trim(),
+                q,
+            })
+        })
+        .collect()
+}
+
+/// A `q` value (`0` to `1`, up to three decimals) in thousandths.
+fn quality(value: &str) -> Option<u16> {
+    let q: f32 = value.parse().ok()?;
+    (0.0..=1.0)
+        .contains(&q)
+        .then(|| (q * 1000.0).round() as u16)
+}
+
+/// The rank of `media_type` under its most specific matching range; `None`
+/// when only `*/*` matches or the range excludes it.
+fn rank(ranges: &[Range<'_>], media_type: &str) -> Option<Rank> {
+    let (kind, subtype) = media_type.split_once('/')?;
+    let mut best: Option<Rank> = None;
+    for (position, range) in ranges.iter().enumerate() {
+        if !range.kind.eq_ignore_ascii_case(kind) {
+            continue;
+        }
+        let specificity = if range.subtype.eq_ignore_ascii_case(subtype) {
+            2
+        } else if range.subtype == "*" {
+            1
+        } else {
+            continue;
+        };
+        if best.is_none_or(|b| specificity > b.specificity) {
+            best = Some(Rank {
+                q: range.q,
+                specificity,
+                position: std::cmp::Reverse(position),
+            });
+        }
+    }
+    best.filter(|rank| rank.q > 0)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const TYPES: [&str; 3] = ["application/json", "application/xml", "text/html"];
+
+    fn pick(accept: &str) -> Option<&'static str> {
+        choose(TYPES, Some(accept))
+    }
+
+    #[test]
+    fn exact_types_win_by_quality_then_order() {
+        assert_eq!(pick("application/xml"), Some("application/xml"));
+        assert_eq!(
+            pick("application/xml;q=0.5, application/json"),
+            Some("application/json")
+        );
+        assert_eq!(
+            pick("application/xml, application/json"),
+            Some("application/xml")
+        );
+        assert_eq!(
+            pick("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"),
+            Some("text/html")
+        );
+        assert_eq!(pick("text/*, text/html;q=0"), None);
+        assert_eq!(
+            pick("application/*;q=0.9, text/html;q=0.5"),
+            Some("application/json")
+        );
+    }
+
+    #[test]
+    fn wildcards_and_missing_headers_keep_the_base_body() {
+        assert_eq!(pick("*/*"), None);
+        assert_eq!(pick("image/png"), None);
+        assert_eq!(pick("application/json;q=2"), None);
+        assert_eq!(choose(TYPES, None), None);
+    }
+}
@@ -0,0 +1,209 @@
+//! Conditional mock rules: `mockResponse.rules` is an ordered list of replies,
+//! each with a `match` the request must satisfy. The first matching rule
+//! answers; requests no rule matches fall through to the rest of the mock
+//! response (replies by method, correlation, variants, then the base reply).
+//!
+//! A rule's `match` can set:
+//!
+//! - `methods`: one of these methods
+//! - `path`: a glob over the captured path, where `*` matches within a
+//!   segment, `**` across segments and `?` one character
+//! - `headers`, `query`: name → value pattern
+//! - `body`: JSON path (as in `body.json.<path>` correlation keys) → value
+//!   pattern
+//!
+//! Value patterns match the whole value, with `*` standing for any run of
+//! characters; a field that's missing never matches. Every condition a rule
+//! sets must hold, and a rule with an empty `match` matches everything.
+
+use serde::Deserialize;
+use serde_json::Value;
+use std::collections::HashMap;
+
+use crate::correlate::RequestFields;
+
+#[derive(Debug, Default, Deserialize)]
+struct RuleMatch {
+    #[serde(default)]
+    methods: Vec<String>,
+    #[serde(default)]
+    path: Option<String>,
+    #[serde(default)]
+    headers: HashMap<String, String>,
+    #[serde(default)]
+    query: HashMap<String, String>,
+    #[serde(default)]
+    body: HashMap<String, String>,
+}
+
+impl RuleMatch {
+    /// Whether the request satisfies every condition. `body` is the request
+    /// body parsed as JSON, if it is JSON.
+    fn matches(&self, request: &RequestFields<'_>, body: Option<&Value>) -> bool {
+        if !self.methods.is_empty()
+            && !self
+                .methods
+                .iter()
+                .any(|m| m.eq_ignore_ascii_case(request.method))
+        {
+            return false;
+        }
+        if let Some(ref glob) = self.path
+            && !wildcard(glob.as_bytes(), request.path.as_bytes(), true)
+        {
+            return false;
+        }
+        let headers = self.headers.iter().all(|(name, pattern)| {
+            request
+                .headers
+                .get(&name.to_ascii_lowercase())
+                .is_some_and(|value| wildcard(pattern.as_bytes(), value.as_bytes(), false))
+        });
+        let query = self.query.iter().all(|(name, pattern)| {
+            request
+                .query
+                .get(name)
+                .is_some_and(|value| wildcard(pattern.as_bytes(), value.as_bytes(), false))
+        });
+        let body_values = self.body.iter().all(|(path, pattern)| {
+            body.and_then(|body| crate::transform::lookup(body, path))
+                .and_then(crate::correlate::scalar_string)
+                .is_some_and(|value| wildcard(pattern.as_bytes(), value.as_bytes(), false))
+        });
+        headers && query && body_values
+    }
+}
+
+#[derive(Deserialize)]
+struct StoredRule {
+    #[serde(default, rename = "match")]
+    conditions: RuleMatch,
+}
+
+/// Index of the first rule in `mockResponse.rules` the request matches.
+/// Rules that can't be read never match.
+pub fn first_match(rules: Option<&Value>, request: &RequestFields<'_>) -> Option<usize> {
+    let rules = rules?.as_array()?;
+    // Parsed once, and only when a rule reads the body
+    let mut body: Option<Option<Value>> = None;
+    rules.iter().position(|rule| {
+        let Ok(rule) = StoredRule::deserialize(rule) else {
+            return false;
+        };
+        let body = if rule.conditions.body.is_empty() {
+            None
+        } else {
+            body.get_or_insert_with(|| serde_json::from_str(request.body).ok())
+                .as_ref()
+        };
+        rule.conditions.matches(request, body)
+    })
+}
+
+/// Match `value` against a pattern where `*` stands for any run of characters
+/// and `?` for one. With `segmented`, `*` and `?` stop at `/` and `**` crosses
+/// it, as in path globs.
+fn wildcard(pattern: &[u8], value: &[u8], segmented: bool) -> bool {
+    match pattern.split_first() {
+        None => value.is_empty(),
+        Some((b'*', rest)) => {
+            let (rest, across) = match rest.split_first() {
+                Some((b'*', rest)) if segmented => (rest, true),
+                _ => (rest, !segmented),
+            };
+            (0..=value.len())
+                .take_while(|&i| across || i == 0 || value[i - 1] != b'/')
+                .any(|i| wildcard(rest, &value[i..], segmented))
+        }
+        Some((b'?', rest)) => value
+            .split_first()
+            .is_some_and(|(c, tail)| !(segmented && *c == b'/') && wildcard(rest, tail, segmented)),
+        Some((c, rest)) => value
+            .split_first()
+            .is_some_and(|(v, tail)| v == c && wildcard(rest, tail, segmented)),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn fields<'a>(
+        method: &'a str,
+        path: &'a str,
+        headers: &'a HashMap<String, String>,
+        query: &'a HashMap<String, String>,
+        body: &'a str,
+    ) -> RequestFields<'a> {
+        RequestFields {
+            method,
+            path,
+            headers,
+            query,
+            body,
+        }
+    }
+
+    #[test]
+    fn first_matching_rule_wins() {
+        let rules = json!([
+            {"match": {"methods": ["GET"]}, "status": 200},
+            {"match": {"path": "/orders/*", "body": {"data.status": "refund*"}}, "status": 409},
+            {"match": {"path": "/orders/**"}, "status": 202},
+        ]);
+        let (h, q) = (HashMap::new(), HashMap::new());
+        let body = r#"{"data":{"status":"refunded"}}"#;
+        let req = fields("POST", "/orders/1", &h, &q, body);
+        assert_eq!(first_match(Some(&rules), &req), Some(1));
+        let req = fields("POST", "/orders/1/items", &h, &q, body);
+        assert_eq!(first_match(Some(&rules), &req), Some(2));
+        let req = fields("POST", "/orders/1", &h, &q, r#"{"data":{"status":"paid"}}"#);
+        assert_eq!(first_match(Some(&rules), &req), Some(2));
+        let req = fields("PUT", "/customers", &h, &q, "");
+        assert_eq!(first_match(Some(&rules), &req), None);
+    }
+
+    #[test]
+    fn headers_and_query_must_all_match() {
+        let rules = json!([
+            {"match": {"headers": {"X-Event": "invoice.*"}, "query": {"mode": "test"}}, "status": 400},
+        ]);
+        let h = HashMap::from([("x-event".to_string(), "invoice.paid".to_string())]);
+        let q = HashMap::from([("mode".to_string(), "test".to_string())]);
+        let empty = HashMap::new();
+        assert_eq!(
+            first_match(Some(&rules), &fields("POST", "/", &h, &q, "")),
+            Some(0)
+        );
+        assert_eq!(
+            first_match(Some(&rules), &fields("POST", "/", &h, &empty, "")),
+            None
+        );
+        assert_eq!(
+            first_match(Some(&rules), &fields("POST", "/", &empty, &q, "")),
+            None
+        );
+    }
+
+    #[test]
+    fn unreadable_rules_never_match() {
+        let rules = json!([{"match": {"methods": "POST"}}, {"match": {}}]);
+        let (h, q) = (HashMap::new(), HashMap::new());
+        let req = fields("POST", "/", &h, &q, "");
+        assert_eq!(first_match(Some(&rules), &req), Some(1));
+        assert_eq!(first_match(Some(&json!({})), &req), None);
+        assert_eq!(first_match(None, &req), None);
+    }
+
+    #[test]
+    fn wildcards() {
+        assert!(wildcard(b"/orders/*", b"/orders/1", true));
+        assert!(!wildcard(b"/orders/*", b"/orders/1/items", true));
+        assert!(wildcard(b"/orders/**", b"/orders/1/items", true));
+        assert!(wildcard(b"/v?/ping", b"/v2/ping", true));
+        assert!(wildcard(b"a/*", b"a/b/c", false));
+        assert!(wildcard(b"*", b"", false));
+        assert!(!wildcard(b"paid", b"unpaid", false));
+    }
+}
@@ -0,0 +1,322 @@
+//! Scripted mock responses: `mockResponse.script` is a [Rhai] script that
+//! receives the request and returns the reply, for mocks the declarative
+//! options (rules, correlation, templates) can't express.
+//!
+//! The script sees a `request` constant with `method`, `path`, `headers`
+//! (lowercase names), `query`, `body` (text) and `json` (the parsed body, or
+//! `()`), and returns one of:
+//!
+//! - a map with any of `status`, `headers`, `body` (non-string bodies are
+//!   sent as JSON) and `delay`; missing fields keep the base reply's
+//! - a number: just the status
+//! - a string: just the body
+//! - `()`: no answer, so the rest of the mock response answers
+//!
+//! Scripts run sandboxed: no imports, no output, and bounded operations,
+//! string/array/map sizes, call depth and wall time. Compiled scripts are
+//! cached per slug and recompiled when the source changes.
+//!
+//! [Rhai]: https://rhai.rs
+
+use rhai::module_resolvers::DummyModuleResolver;
+use rhai::packages::{Package, StandardPackage};
+use rhai::{AST, Dynamic, Engine, Scope};
+use serde_json::{Value, json};
+use std::collections::HashMap;
+use std::hash::{DefaultHasher, Hash, Hasher};
+use std::sync::{Arc, LazyLock, Mutex};
+use std::time::{Duration, Instant};
+
+use crate::correlate::RequestFields;
+
+/// Wall time a script may run for a single request.
+const TIME_LIMIT: Duration = Duration::from_millis(50);
+
+/// Operations (roughly, evaluated expressions) a script may run.
+const MAX_OPERATIONS: u64 = 500_000;
+
+/// Largest string a script may build, in bytes. Bodies above it are passed
+/// to the script empty.
+const MAX_STRING: usize = 1024 * 1024;
+
+/// Largest array or map a script may build.
+const MAX_COLLECTION: usize = 10_000;
+
+/// Compiled scripts kept before idle ones are pruned.
+const CACHE_MAX: usize = 10_000;
+
+/// Compiled scripts unused this long are pruned when the cache fills up.
+const CACHE_TTL: Duration = Duration::from_secs(10 * 60);
+
+static PACKAGE: LazyLock<StandardPackage> = LazyLock::new(StandardPackage::new);
+
+/// The reply a script returned. Unset fields keep the base reply's.
+#[derive(Debug, Default, PartialEq)]
+pub struct ScriptReply {
+    pub status: Option<i64>,
+    pub headers: Option<HashMap<String, String>>,
+    pub body: Option<String>,
+    pub delay: Option<u64>,
+}
+
+struct Compiled {
+    /// Hash of the source the entry was compiled from
+    fingerprint: u64,
+    /// Compile errors are kept too, so a broken script isn't recompiled for
+    /// every request
+    ast: Result<Arc<AST>, String>,
+    used_at: Instant,
+}
+
+/// Shared cache of compiled scripts stored in AppState. Cheap to clone.
+#[derive(Clone, Default)]
+pub struct ScriptCache {
+    compiled: Arc<Mutex<HashMap<String, Compiled>>>,
+}
+
+impl ScriptCache {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Run the endpoint's script against the request, off the async
+    /// runtime. `Ok(None)` when the script returned `()`.
+    pub async fn run(
+        &self,
+        slug: &str,
+        source: &str,
+        request: &RequestFields<'_>,
+    ) -> Result<Option<ScriptReply>, String> {
+        let cache = self.clone();
+        let slug = slug.to_string();
+        let source = source.to_string();
+        let input = request_value(request);
+        tokio::task::spawn_blocking(move || {
+            let ast = cache.compile(&slug, &source)?;
+            evaluate(&ast, input)
+        })
+        .await
+        .map_err(|e| format!("script task failed: {e}"))?
+    }
+
+    fn compile(&self, slug: &str, source: &str) -> Result<Arc<AST>, String> {
+        let fingerprint = fingerprint(source);
+        let now = Instant::now();
+        {
+            let mut compiled = self.compiled.lock().unwrap_or_else(|e| e.into_inner());
+            if let Some(entry) = compiled.get_mut(slug)
+                && entry.fingerprint == fingerprint
+            {
+                entry.used_at = now;
+                return entry.ast.clone();
+            }
+        }
+
+        let ast = engine(None)
+            .compile(source)
+            .map(Arc::new)
+            .map_err(|e| format!("script doesn't compile: {e}"));
+
+        let mut compiled = self.compiled.lock().unwrap_or_else(|e| e.into_inner());
+        if compiled.len() >= CACHE_MAX && !compiled.contains_key(slug) {
+            compiled.retain(|_, entry| now.duration_since(entry.used_at) < CACHE_TTL);
+        }
+        if compiled.len() < CACHE_MAX || compiled.contains_key(slug) {
+            compiled.insert(
+                slug.to_string(),
+                Compiled {
+                    fingerprint,
+                    ast: ast.clone(),
+                    used_at: now,
+                },
+            );
+        }
+        ast
+    }
+
+    /// Drop the compiled script of an endpoint that expired.
+    pub fn forget(&self, slug: &str) {
+        self.compiled
+            .lock()
+            .unwrap_or_else(|e| e.into_inner())
+            .remove(slug);
+    }
+}
+
+/// A sandboxed engine. `deadline` stops evaluation once passed; compiling
+/// doesn't need one.
+fn engine(deadline: Option<Instant>) -> Engine {
+    let mut engine = Engine::new_raw();
+    PACKAGE.register_into_engine(&mut engine);
+    engine.set_module_resolver(DummyModuleResolver::new());
+    engine.on_print(|_| {});
+    engine.on_debug(|_, _, _| {});
+    engine.set_max_operations(MAX_OPERATIONS);
+    engine.set_max_call_levels(32);
+    engine.set_max_expr_depths(64, 32);
+    engine.set_max_string_size(MAX_STRING);
+    engine.set_max_array_size(MAX_COLLECTION);
+    engine.set_max_map_size(MAX_COLLECTION);
+    if let Some(deadline) = deadline {
+        engine.on_progress(move |_| (Instant::now() >= deadline).then_some(Dynamic::UNIT));
+    }
+    engine
+}
+
+/// The `request` constant scripts see.
+fn request_value(request: &RequestFields<'_>) -> Value {
+    let body = if request.body.len() > MAX_STRING {
+        ""
+    } else {
+        request.body
+    };
+    json!({
+        "method": request.method,
+        "path": request.path,
+        "headers": request.headers,
+        "query": request.query,
+        "body": body,
+        "json": serde_json::from_str::<Value>(body).unwrap_or(Value::Null),
+    })
+}
+
+fn evaluate(ast: &AST, input: Value) -> Result<Option<ScriptReply>, String> {
+    let engine = engine(Some(Instant::now() + TIME_LIMIT));
+    let request = rhai::serde::to_dynamic(input).map_err(|e| e.to_string())?;
+    let mut scope = Scope::new();
+    scope.push_constant("request", request);
+    let result = engine
+        .eval_ast_with_scope::<Dynamic>(&mut scope, ast)
+        .map_err(|e| format!("script failed: {e}"))?;
+    let result: Value =
+        rhai::serde::from_dynamic(&result).map_err(|e| format!("unusable script result: {e}"))?;
+    reply(result)
+}
+
+fn reply(result: Value) -> Result<Option<ScriptReply>, String> {
+    match result {
+        Value::Null => Ok(None),
+        Value::Number(status) => Ok(Some(ScriptReply {
+            status: Some(status.as_i64().ok_or("status must be a whole number")?),
+            ..ScriptReply::default()
+        })),
+        Value::String(body) => Ok(Some(ScriptReply {
+            body: Some(body),
+            ..ScriptReply::default()
+        })),
+        Value::Object(mut map) => {
+            let status = match map.remove("status") {
+                None | Some(Value::Null) => None,
+                Some(status) => Some(status.as_i64().ok_or("status must be a whole number")?),
+            };
+            let headers = match map.remove("headers") {
+                None | Some(Value::Null) => None,
+                Some(Value::Object(headers)) => Some(
+                    headers
+                        .into_iter()
+                        .map(|(name, value)| (name, text(value)))
+                        .collect(),
+                ),
+                Some(_) => return Err("headers must be a map".into()),
+            };
+            let body = match map.remove("body") {
+                None | Some(Value::Null) => None,
+                Some(body) => Some(text(body)),
+            };
+            let delay = match map.remove("delay") {
+                None | Some(Value::Null) => None,
+                Some(delay) => Some(delay.as_u64().ok_or("delay must be a whole number")?),
+            };
+            Ok(Some(ScriptReply {
+                status,
+                headers,
+                body,
+                delay,
+            }))
+        }
+        _ => Err("script must return a map, a status, a body or ()".into()),
+    }
+}
+
+/// Strings as they are, anything else as JSON.
+fn text(value: Value) -> String {
+    match value {
+        Value::String(s) => s,
+        other => other.to_string(),
+    }
+}
+
+fn fingerprint(source: &str) -> u64 {
+    let mut hasher = DefaultHasher::new();
+    source.hash(&mut hasher);
+    hasher.finish()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn run(source: &str, body: &str) -> Result<Option<ScriptReply>, String> {
+        let headers = HashMap::from([("x-mode".to_string(), "test".to_string())]);
+        let query = HashMap::new();
+        let request = RequestFields {
+            method: "POST",
+            path: "/orders",
+            headers: &headers,
+            query: &query,
+            body,
+        };
+        let ast = ScriptCache::new().compile("s", source)?;
+        evaluate(&ast, request_value(&request))
+    }
+
+    #[test]
+    fn map_reply_reads_the_request() {
+        let reply = run(
+            r#"
+            if request.json.amount > 100 {
+                #{ status: 402, headers: #{ "x-mode": request.headers["x-mode"] },
+                   body: #{ id: request.json.id } }
+            }
+            "#,
+            r#"{"id":"o_1","amount":250}"#,
+        )
+        .unwrap()
+        .unwrap();
+        assert_eq!(reply.status, Some(402));
+        assert_eq!(reply.headers.unwrap()["x-mode"], "test");
+        assert_eq!(reply.body.as_deref(), Some(r#"{"id":"o_1"}"#));
+        assert_eq!(reply.delay, None);
+    }
+
+    #[test]
+    fn short_forms_and_fall_through() {
+        let status = run("201", "").unwrap().unwrap();
+        assert_eq!(status.status, Some(201));
+        let body = run(r#"`path ${request.path}`"#, "").unwrap().unwrap();
+        assert_eq!(body.body.as_deref(), Some("path /orders"));
+        assert_eq!(
+            run("if request.json == () { () } else { 500 }", "not json").unwrap(),
+            None
+        );
+        assert!(run("[1, 2]", "").is_err());
+    }
+
+    #[test]
+    fn sandbox_limits_stop_scripts() {
+        assert!(run("loop {}", "").is_err());
+        assert!(run(r#"let s = "x"; loop { s += s; }"#, "").is_err());
+        assert!(run(r#"import "std" as m;"#, "").is_err());
+        assert!(run("let x = ", "").unwrap_err().contains("compile"));
+    }
+
+    #[test]
+    fn compiled_scripts_follow_the_source() {
+        let cache = ScriptCache::new();
+        let first = cache.compile("s", "1").unwrap();
+        assert!(Arc::ptr_eq(&first, &cache.compile("s", "1").unwrap()));
+        assert!(!Arc::ptr_eq(&first, &cache.compile("s", "2").unwrap()));
+        cache.forget("s");
+        assert!(cache.compiled.lock().unwrap().is_empty());
+    }
+}
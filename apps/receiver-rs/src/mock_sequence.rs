@@ -0,0 +1,251 @@
+//! Mock response sequences: `mockResponse.sequence` answers successive
+//! requests with successive steps, so "fail twice, then succeed" retry flows
+//! can be reproduced without editing the endpoint between attempts.
+//!
+//! - `steps`: replies, each answering `count` requests (1 by default). Once
+//!   they're used up the rest of the mock response answers (replies by
+//!   method, correlation, then the base reply), unless `repeat` starts them
+//!   over.
+//! - `key`: a request field, as in correlation keys; each value gets its own
+//!   position, so every delivery retries through the sequence separately.
+//!   Requests without the field skip the sequence.
+//! - `resetAfter`: seconds a position may sit idle before it starts over.
+//!
+//! Positions are kept per slug in this receiver instance's memory, so with
+//! several instances each keeps its own. Changing the sequence starts every
+//! position over.
+
+use serde::Deserialize;
+use serde_json::Value;
+use std::collections::HashMap;
+use std::hash::{DefaultHasher, Hash, Hasher};
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+
+use crate::correlate::RequestFields;
+
+/// Positions tracked at once. When full, idle ones are pruned; requests that
+/// would need another position skip the sequence.
+const MAX_TRACKED: usize = 100_000;
+
+/// Positions unused this long are pruned when the tracker fills up.
+const IDLE_TTL: Duration = Duration::from_secs(24 * 60 * 60);
+
+#[derive(Debug, Deserialize)]
+struct SequenceConfig {
+    steps: Vec<StepCount>,
+    #[serde(default)]
+    key: Option<String>,
+    #[serde(default)]
+    repeat: bool,
+    #[serde(default, rename = "resetAfter")]
+    reset_after: Option<u64>,
+}
+
+/// Only the step's count is read here; its reply is rendered by the handler.
+#[derive(Debug, Deserialize)]
+struct StepCount {
+    #[serde(default = "one")]
+    count: u64,
+}
+
+fn one() -> u64 {
+    1
+}
+
+struct Position {
+    /// Hash of the sequence the position counts through
+    fingerprint: u64,
+    /// Requests answered by the sequence since it (re)started
+    served: u64,
+    last_seen: Instant,
+}
+
+/// Shared tracker stored in AppState. Cheap to clone.
+#[derive(Clone, Default)]
+pub struct SequenceTracker {
+    positions: Arc<Mutex<HashMap<(String, String), Position>>>,
+}
+
+impl SequenceTracker {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Index into `sequence.steps` of the step answering this request, and
+    /// advance the position. `None` when the endpoint has no sequence, the
+    /// request lacks its key, or the steps are used up.
+    pub fn next(
+        &self,
+        slug: &str,
+        sequence: Option<&Value>,
+        request: &RequestFields<'_>,
+    ) -> Option<usize> {
+        let sequence = sequence?;
+        let config = SequenceConfig::deserialize(sequence).ok()?;
+        let total = config
+            .steps
+            .iter()
+            .fold(0u64, |total, step| total.saturating_add(step.count.max(1)));
+        if total == 0 {
+            return None;
+        }
+        let value = match config.key {
+            Some(ref key) => crate::correlate::extract(key, request)?,
+            None => String::new(),
+        };
+        let key = (slug.to_string(), value);
+        let fingerprint = fingerprint(sequence);
+        let now = Instant::now();
+
+        let mut positions = self.positions.lock().unwrap_or_else(|e| e.into_inner());
+        if positions.len() >= MAX_TRACKED && !positions.contains_key(&key) {
+            positions.retain(|_, p| now.duration_since(p.last_seen) < IDLE_TTL);
+            if positions.len() >= MAX_TRACKED {
+                tracing::warn!(slug, "mock sequence tracker full, skipping sequence");
+                return None;
+            }
+        }
+        let position = positions.entry(key).or_insert(Position {
+            fingerprint,
+            served: 0,
+            last_seen: now,
+        });
+        let idle = config.reset_after.is_some_and(|secs| {
+            now.duration_since(position.last_seen) >= Duration::from_secs(secs)
+        });
+        if position.fingerprint != fingerprint || idle {
+            position.fingerprint = fingerprint;
+            position.served = 0;
+        }
+        position.last_seen = now;
+        let mut served = position.served;
+        position.served = position.served.saturating_add(1);
+        drop(positions);
+
+        if served >= total {
+            if !config.repeat {
+                return None;
+            }
+            served %= total;
+        }
+        config.steps.iter().position(|step| {
+            let count = step.count.max(1);
+            if served < count {
+                return true;
+            }
+            served -= count;
+            false
+        })
+    }
+
+    /// Drop the positions of an endpoint that expired.
+    pub fn forget(&self, slug: &str) {
+        self.positions
+            .lock()
+            .unwrap_or_else(|e| e.into_inner())
+            .retain(|(s, _), _| s != slug);
+    }
+}
+
+fn fingerprint(sequence: &Value) -> u64 {
+    let mut hasher = DefaultHasher::new();
+    sequence.to_string().hash(&mut hasher);
+    hasher.finish()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn fields<'a>(
+        headers: &'a HashMap<String, String>,
+        query: &'a HashMap<String, String>,
+    ) -> RequestFields<'a> {
+        RequestFields {
+            method: "POST",
+            path: "/",
+            headers,
+            query,
+            body: "",
+        }
+    }
+
+    #[test]
+    fn steps_answer_in_order_then_fall_through() {
+        let tracker = SequenceTracker::new();
+        let sequence = json!({"steps": [{"status": 500, "count": 2}, {"status": 202}]});
+        let (h, q) = (HashMap::new(), HashMap::new());
+        let req = fields(&h, &q);
+        let steps: Vec<_> = (0..5)
+            .map(|_| tracker.next("s", Some(&sequence), &req))
+            .collect();
+        assert_eq!(steps, [Some(0), Some(0), Some(1), None, None]);
+        assert_eq!(tracker.next("s", None, &req), None);
+    }
+
+    #[test]
+    fn repeat_starts_over() {
+        let tracker = SequenceTracker::new();
+        let sequence = json!({"steps": [{"status": 500}, {"status": 200}], "repeat": true});
+        let (h, q) = (HashMap::new(), HashMap::new());
+        let req = fields(&h, &q);
+        let steps: Vec<_> = (0..5)
+            .map(|_| tracker.next("s", Some(&sequence), &req))
+            .collect();
+        assert_eq!(steps, [Some(0), Some(1), Some(0), Some(1), Some(0)]);
+    }
+
+    #[test]
+    fn keys_and_slugs_count_separately() {
+        let tracker = SequenceTracker::new();
+        let sequence = json!({"steps": [{"status": 500}], "key": "header.x-delivery"});
+        let q = HashMap::new();
+        let first = HashMap::from([("x-delivery".to_string(), "d1".to_string())]);
+        let second = HashMap::from([("x-delivery".to_string(), "d2".to_string())]);
+        let none = HashMap::new();
+        assert_eq!(
+            tracker.next("s", Some(&sequence), &fields(&first, &q)),
+            Some(0)
+        );
+        assert_eq!(
+            tracker.next("s", Some(&sequence), &fields(&first, &q)),
+            None
+        );
+        assert_eq!(
+            tracker.next("s", Some(&sequence), &fields(&second, &q)),
+            Some(0)
+        );
+        assert_eq!(
+            tracker.next("t", Some(&sequence), &fields(&first, &q)),
+            Some(0)
+        );
+        assert_eq!(tracker.next("s", Some(&sequence), &fields(&none, &q)), None);
+
+        tracker.forget("s");
+        assert_eq!(
+            tracker.next("s", Some(&sequence), &fields(&first, &q)),
+            Some(0)
+        );
+    }
+
+    #[test]
+    fn changed_or_idle_sequences_start_over() {
+        let tracker = SequenceTracker::new();
+        let (h, q) = (HashMap::new(), HashMap::new());
+        let req = fields(&h, &q);
+        let sequence = json!({"steps": [{"status": 500}], "resetAfter": 60});
+        assert_eq!(tracker.next("s", Some(&sequence), &req), Some(0));
+        assert_eq!(tracker.next("s", Some(&sequence), &req), None);
+
+        let changed = json!({"steps": [{"status": 503}], "resetAfter": 60});
+        assert_eq!(tracker.next("s", Some(&changed), &req), Some(0));
+        assert_eq!(tracker.next("s", Some(&changed), &req), None);
+
+        for position in tracker.positions.lock().unwrap().values_mut() {
+            position.last_seen -= Duration::from_secs(61);
+        }
+        assert_eq!(tracker.next("s", Some(&changed), &req), Some(0));
+    }
+}
@@ -0,0 +1,224 @@
+//! Streaming mock responses: `mockResponse.stream` sends the base reply's
+//! body as a series of events, each after its own delay, for testing clients
+//! that consume streamed callbacks.
+//!
+//! - `format: "sse"` (the default) writes each event as a
+//!   `text/event-stream` message, with its `id` and `event` fields and one
+//!   `data:` line per line of `data`.
+//! - `format: "chunked"` writes each event's `data` as is; the reply goes out
+//!   with chunked transfer encoding (HTTP/1.1) or as separate DATA frames
+//!   (HTTP/2).
+//!
+//! Each event waits `delay` milliseconds (at most [`MAX_EVENT_DELAY`]) after
+//! the one before it, and the whole stream is cut off once the delays add up
+//! to [`MAX_STREAM_DURATION`]. The events are rendered once per mock, so
+//! cached replies stream too.
+
+use std::future::Future;
+use std::pin::Pin;
+use std::sync::Arc;
+use std::task::{Context, Poll, ready};
+use std::time::Duration;
+
+use axum::body::Body;
+use bytes::Bytes;
+use hyper::body::Frame;
+use serde::Deserialize;
+
+/// Longest wait before a single event.
+pub const MAX_EVENT_DELAY: Duration = Duration::from_secs(30);
+
+/// Longest a stream may take, counting only the event delays. Events after
+/// it are dropped.
+pub const MAX_STREAM_DURATION: Duration = Duration::from_secs(60);
+
+#[derive(Debug, Clone, Copy, Default, PartialEq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Format {
+    #[default]
+    Sse,
+    Chunked,
+}
+
+#[derive(Debug, Clone, Deserialize)]
+pub struct MockStream {
+    #[serde(default)]
+    format: Format,
+    events: Vec<Event>,
+}
+
+#[derive(Debug, Clone, Deserialize)]
+struct Event {
+    #[serde(default)]
+    data: String,
+    #[serde(default)]
+    event: Option<String>,
+    #[serde(default)]
+    id: Option<String>,
+    /// Milliseconds to wait before sending the event
+    #[serde(default)]
+    delay: Option<u64>,
+}
+
+impl MockStream {
+    /// The content type the stream implies, if any: mock headers that set
+    /// one win.
+    pub fn content_type(&self) -> Option<&'static str> {
+        match self.format {
+            Format::Sse => Some("text/event-stream"),
+            Format::Chunked => None,
+        }
+    }
+
+    /// Render the events into the frames to send.
+    pub fn frames(&self) -> Frames {
+        let mut total = Duration::ZERO;
+        let mut frames = Vec::with_capacity(self.events.len());
+        for event in &self.events {
+            let delay = Duration::from_millis(event.delay.unwrap_or(0)).min(MAX_EVENT_DELAY);
+            total += delay;
+            if total > MAX_STREAM_DURATION {
+                break;
+            }
+            let data = match self.format {
+                Format::Sse => Bytes::from(sse_message(event)),
+                Format::Chunked => Bytes::from(event.data.clone()),
+            };
+            frames.push((delay, data));
+        }
+        Frames(frames.into())
+    }
+}
+
+/// One event as a `text/event-stream` message. Line breaks would end a field
+/// early, so `id` and `event` values holding one are left out and `data` is
+/// split into one field per line.
+fn sse_message(event: &Event) -> String {
+    let single_line = |value: &&String| !value.contains(['\r', '\n']);
+    let mut message = String::new();
+    if let Some(id) = event.id.as_ref().filter(single_line) {
+        message.push_str(&format!("id: {id}\n"));
+    }
+    if let Some(name) = event.event.as_ref().filter(single_line) {
+        message.push_str(&format!("event: {name}\n"));
+    }
+    for line in event.data.replace("\r\n", "\n").split(['\r', '\n']) {
+        message.push_str(&format!("data: {line}\n"));
+    }
+    message.push('\n');
+    message
+}
+
+/// Rendered events, each with the delay before it. Cheap to clone.
+#[derive(Debug, Clone)]
+pub struct Frames(Arc<[(Duration, Bytes)]>);
+
+impl Frames {
+    /// Bytes sent over the whole stream.
+    pub fn size(&self) -> usize {
+        self.0.iter().map(|(_, data)| data.len()).sum()
+    }
+
+    /// A body that sends the frames as their delays pass.
+    pub fn body(&self) -> Body {
+        Body::new(Streamed {
+            frames: self.0.clone(),
+            next: 0,
+            sleep: None,
+        })
+    }
+}
+
+struct Streamed {
+    frames: Arc<[(Duration, Bytes)]>,
+    /// Index of the frame to send next
+    next: usize,
+    /// Wait before the next frame, once started
+    sleep: Option<Pin<Box<tokio::time::Sleep>>>,
+}
+
+impl hyper::body::Body for Streamed {
+    type Data = Bytes;
+    type Error = std::convert::Infallible;
+
+    fn poll_frame(
+        mut self: Pin<&mut Self>,
+        cx: &mut Context<'_>,
+    ) -> Poll<Option<Result<Frame<Bytes>, Self::Error>>> {
+        let Some((delay, data)) = self.frames.get(self.next).cloned() else {
+            return Poll::Ready(None);
+        };
+        if !delay.is_zero() {
+            let sleep = self
+                .sleep
+                .get_or_insert_with(|| Box::pin(tokio::time::sleep(delay)));
+            ready!(sleep.as_mut().poll(cx));
+            self.sleep = None;
+        }
+        self.next += 1;
+        Poll::Ready(Some(Ok(Frame::data(data))))
+    }
+
+    fn is_end_stream(&self) -> bool {
+        self.next >= self.frames.len()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use http_body_util::BodyExt;
+    use serde_json::json;
+
+    fn stream(value: serde_json::Value) -> MockStream {
+        serde_json::from_value(value).unwrap()
+    }
+
+    async fn collect(frames: &Frames) -> String {
+        let body = frames.body().collect().await.unwrap().to_bytes();
+        String::from_utf8(body.to_vec()).unwrap()
+    }
+
+    #[tokio::test]
+    async fn sse_events_are_formatted() {
+        let stream = stream(json!({"events": [
+            {"id": "1", "event": "progress", "data": "{\"pct\":50}"},
+            {"data": "line one\r\nline two"},
+            {"event": "bad\nname", "data": ""}
+        ]}));
+        assert_eq!(stream.content_type(), Some("text/event-stream"));
+        let frames = stream.frames();
+        let body = collect(&frames).await;
+        assert_eq!(
+            body,
+            "id: 1\nevent: progress\ndata: {\"pct\":50}\n\n\
+             data: line one\ndata: line two\n\n\
+             data: \n\n"
+        );
+        assert_eq!(frames.size(), body.len());
+    }
+
+    #[tokio::test]
+    async fn chunks_wait_for_their_delays() {
+        let stream = stream(json!({"format": "chunked", "events": [
+            {"data": "a"},
+            {"data": "b", "delay": 20}
+        ]}));
+        assert_eq!(stream.content_type(), None);
+        let start = std::time::Instant::now();
+        assert_eq!(collect(&stream.frames()).await, "ab");
+        assert!(start.elapsed() >= Duration::from_millis(20));
+    }
+
+    #[test]
+    fn long_streams_are_cut_off() {
+        let stream = stream(json!({"format": "chunked", "events": [
+            {"data": "a", "delay": 40000},
+            {"data": "b", "delay": 30000},
+            {"data": "c", "delay": 1}
+        ]}));
+        let frames = stream.frames();
+        let delays: Vec<_> = frames.0.iter().map(|(delay, _)| delay.as_secs()).collect();
+        assert_eq!(delays, [30, 30]);
+    }
+}
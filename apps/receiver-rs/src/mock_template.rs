@@ -0,0 +1,172 @@
+//! Template placeholders in mock response bodies and header values, rendered
+//! per request so a reply can echo IDs back the way real APIs do:
+//!
+//! - `{{.Request.Method}}`, `{{.Request.Path}}`
+//! - `{{.Request.Header "X-Id"}}`, `{{.Request.Query "id"}}`
+//! - `{{.Body.json "order.id"}}` — dot-separated JSON path, as in
+//!   `body.json.<path>` correlation keys
+//! - `{{.Body.form "CallSid"}}`
+//! - `{{uuid}}` — a random v4 UUID
+//! - `{{now}}` — the current time in RFC 3339, `{{now "unix"}}` in seconds
+//!
+//! Values are inserted as they are, without escaping; a field the request
+//! doesn't carry renders as an empty string. Anything between `{{` and `}}`
+//! that isn't one of these is left untouched, so literal braces survive.
+
+use chrono::{SecondsFormat, Utc};
+use ring::rand::{SecureRandom, SystemRandom};
+use serde_json::Value;
+
+use crate::correlate::RequestFields;
+
+/// Whether any string in a mock configuration has a placeholder. Templated
+/// replies differ per request, so they're never served from the mock cache.
+pub fn in_value(value: &Value) -> bool {
+    match value {
+        Value::String(s) => s.contains("{{"),
+        Value::Array(items) => items.iter().any(in_value),
+        Value::Object(map) => map.values().any(in_value),
+        _ => false,
+    }
+}
+
+/// Replace the placeholders in `template` with values from the request.
+pub fn render(template: &str, request: &RequestFields<'_>) -> String {
+    let mut out = String::with_capacity(template.len());
+    let mut rest = template;
+    while let Some(start) = rest.find("{{") {
+        out.push_str(&rest[..start]);
+        let after = &rest[start + 2..];
+        let Some(end) = after.find("}}") else {
+            rest = &rest[start..];
+            break;
+        };
+        match action(after[..end].trim(), request) {
+            Some(value) => out.push_str(&value),
+            None => out.push_str(&rest[start..start + end + 4]),
+        }
+        rest = &after[end + 2..];
+    }
+    out.push_str(rest);
+    out
+}
+
+/// The value of one placeholder, `None` when it isn't one.
+fn action(source: &str, request: &RequestFields<'_>) -> Option<String> {
+    let (name, arg) = match source.split_once(char::is_whitespace) {
+        Some((name, arg)) => (name, Some(quoted(arg.trim())?)),
+        None => (source, None),
+    };
+    let key = match (name, arg) {
+        (".Request.Method", None) => return Some(request.method.to_string()),
+        (".Request.Path", None) => return Some(request.path.to_string()),
+        ("uuid", None) => return Some(uuid_v4()),
+        ("now", None) => return Some(Utc::now().to_rfc3339_opts(SecondsFormat::Secs, true)),
+        ("now", Some("unix")) => return Some(Utc::now().timestamp().to_string()),
+        (".Request.Header", Some(arg)) => format!("header.{arg}"),
+        (".Request.Query", Some(arg)) => format!("query.{arg}"),
+        (".Body.json", Some(arg)) => format!("body.json.{arg}"),
+        (".Body.form", Some(arg)) => format!("body.form.{arg}"),
+        _ => return None,
+    };
+    Some(crate::correlate::extract(&key, request).unwrap_or_default())
+}
+
+/// The contents of a double-quoted argument.
+fn quoted(arg: &str) -> Option<&str> {
+    let inner = arg.strip_prefix('"')?.strip_suffix('"')?;
+    (!inner.is_empty() && !inner.contains('"')).then_some(inner)
+}
+
+fn uuid_v4() -> String {
+    let mut bytes = [0u8; 16];
+    // SystemRandom only fails if the OS RNG is unavailable; fall back to the
+    // clock so the reply still renders.
+    if SystemRandom::new().fill(&mut bytes).is_err() {
+        let nanos = Utc::now().timestamp_nanos_opt().unwrap_or_default();
+        bytes[..8].copy_from_slice(&nanos.to_be_bytes());
+    }
+    bytes[6] = (bytes[6] & 0x0f) | 0x40;
+    bytes[8] = (bytes[8] & 0x3f) | 0x80;
+    let hex = hex::encode(bytes);
+    format!(
+        "{}-{}-{}-{}-{}",
+        &hex[..8],
+        &hex[8..12],
+        &hex[12..16],
+        &hex[16..20],
+        &hex[20..]
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::collections::HashMap;
+
+    fn fields<'a>(
+        headers: &'a HashMap<String, String>,
+        query: &'a HashMap<String, String>,
+        body: &'a str,
+    ) -> RequestFields<'a> {
+        RequestFields {
+            method: "POST",
+            path: "/orders",
+            headers,
+            query,
+            body,
+        }
+    }
+
+    #[test]
+    fn renders_request_fields() {
+        let h = HashMap::from([("x-id".to_string(), "evt_1".to_string())]);
+        let q = HashMap::from([("customer".to_string(), "cus_2".to_string())]);
+        let req = fields(&h, &q, r#"{"order":{"id":"ord_3"}}"#);
+        assert_eq!(
+            render(
+                r#"{"id":"{{.Body.json "order.id"}}","event":"{{ .Request.Header "X-Id" }}"}"#,
+                &req
+            ),
+            r#"{"id":"ord_3","event":"evt_1"}"#
+        );
+        assert_eq!(
+            render(
+                r#"{{.Request.Method}} {{.Request.Path}}?c={{.Request.Query "customer"}}"#,
+                &req
+            ),
+            "POST /orders?c=cus_2"
+        );
+        assert_eq!(render(r#"[{{.Body.json "missing"}}]"#, &req), "[]");
+    }
+
+    #[test]
+    fn renders_generated_values() {
+        let (h, q) = (HashMap::new(), HashMap::new());
+        let req = fields(&h, &q, "");
+        let id = render("{{uuid}}", &req);
+        assert_eq!(id.len(), 36);
+        assert_eq!(&id[14..15], "4");
+        assert_ne!(id, render("{{uuid}}", &req));
+        assert!(render("{{now}}", &req).ends_with('Z'));
+        assert!(render(r#"{{now "unix"}}"#, &req).parse::<i64>().is_ok());
+    }
+
+    #[test]
+    fn leaves_other_braces_alone() {
+        let (h, q) = (HashMap::new(), HashMap::new());
+        let req = fields(&h, &q, "");
+        for text in [
+            "{{name}}",
+            "{{.Request.Header}}",
+            "{{.Body.json order}}",
+            "a {{ b",
+        ] {
+            assert_eq!(render(text, &req), text);
+        }
+        assert!(!in_value(&serde_json::json!({"status": 200, "body": "{}"})));
+        assert!(in_value(
+            &serde_json::json!({"headers": {"x-id": "{{uuid}}"}})
+        ));
+    }
+}
@@ -0,0 +1,169 @@
+//! The mTLS listener.
+//!
+//! With `MTLS_PORT`, `MTLS_CERT_FILE` and `MTLS_KEY_FILE` set, the receiver
+//! serves the same routes on a second port where it terminates TLS itself,
+//! for senders that authenticate with a client certificate (some banking and
+//! payment APIs deliver nowhere else). Nothing sits in front of it, so the
+//! proxy can't strip the certificate first. ALPN picks HTTP/2 or HTTP/1.1.
+//!
+//! Every client is asked for a certificate but none is required, and any
+//! issuer is accepted as long as the client proves it holds the key; whether
+//! the certificate is trusted is up to the endpoint (see `client_cert`).
+//! Connections arrive without Cloudflare in front, so proxy headers a client
+//! sends are dropped and the peer address is the client IP.
+
+use axum::extract::Request;
+use axum::http::HeaderValue;
+use rustls::client::danger::HandshakeSignatureValid;
+use rustls::crypto::{CryptoProvider, verify_tls12_signature, verify_tls13_signature};
+use rustls::pki_types::pem::PemObject;
+use rustls::pki_types::{CertificateDer, PrivateKeyDer, UnixTime};
+use rustls::server::danger::{ClientCertVerified, ClientCertVerifier};
+use rustls::{DigitallySignedStruct, DistinguishedName, ServerConfig, SignatureScheme};
+use std::net::SocketAddr;
+use std::sync::Arc;
+
+use crate::client_cert::{ClientCert, crypto_provider};
+use crate::tls_info::TlsInfo;
+
+/// Headers only our proxies may set, which a direct client could forge.
+const FORGEABLE_HEADERS: &[&str] = &[
+    "cf-connecting-ip",
+    "cf-ipcountry",
+    "true-client-ip",
+    "x-forwarded-for",
+    "x-real-ip",
+];
+
+/// Where the mTLS listener binds and the server certificate it presents.
+#[derive(Debug, Clone)]
+pub struct MtlsConfig {
+    pub port: u16,
+    pub cert_file: String,
+    pub key_file: String,
+}
+
+/// TLS settings for the listener: the server chain and key from PEM files,
+/// optional client certificates, and ALPN for both HTTP versions.
+pub fn server_config(cert_file: &str, key_file: &str) -> Result<ServerConfig, String> {
+    let certs = CertificateDer::pem_file_iter(cert_file)
+        .and_then(|certs| certs.collect::<Result<Vec<_>, _>>())
+        .map_err(|e| format!("{cert_file}: {e}"))?;
+    if certs.is_empty() {
+        return Err(format!("{cert_file}: no certificates"));
+    }
+    let key = PrivateKeyDer::from_pem_file(key_file).map_err(|e| format!("{key_file}: {e}"))?;
+
+    let provider = crypto_provider();
+    let mut config = ServerConfig::builder_with_provider(provider.clone())
+        .with_safe_default_protocol_versions()
+        .map_err(|e| e.to_string())?
+        .with_client_cert_verifier(Arc::new(AnyClientCert { provider }))
+        .with_single_cert(certs, key)
+        .map_err(|e| format!("{cert_file}: {e}"))?;
+    config.alpn_protocols = vec![b"h2".to_vec(), b"http/1.1".to_vec()];
+    Ok(config)
+}
+
+/// Asks for a client certificate and accepts whichever one is presented.
+/// The handshake signature is still checked, so the client holds its key.
+#[derive(Debug)]
+struct AnyClientCert {
+    provider: Arc<CryptoProvider>,
+}
+
+impl ClientCertVerifier for AnyClientCert {
+    fn client_auth_mandatory(&self) -> bool {
+        false
+    }
+
+    fn root_hint_subjects(&self) -> &[DistinguishedName] {
+        &[]
+    }
+
+    fn verify_client_cert(
+        &self,
+        _end_entity: &CertificateDer<'_>,
+        _intermediates: &[CertificateDer<'_>],
+        _now: UnixTime,
+    ) -> Result<ClientCertVerified, rustls::Error> {
+        Ok(ClientCertVerified::assertion())
+    }
+
+    fn verify_tls12_signature(
+        &self,
+        message: &[u8],
+        cert: &CertificateDer<'_>,
+        dss: &DigitallySignedStruct,
+    ) -> Result<HandshakeSignatureValid, rustls::Error> {
+        verify_tls12_signature(message, cert, dss, &self.provider.signature_verification_algorithms)
+    }
+
+    fn verify_tls13_signature(
+        &self,
+        message: &[u8],
+        cert: &CertificateDer<'_>,
+        dss: &DigitallySignedStruct,
+    ) -> Result<HandshakeSignatureValid, rustls::Error> {
+        verify_tls13_signature(message, cert, dss, &self.provider.signature_verification_algorithms)
+    }
+
+    fn supported_verify_schemes(&self) -> Vec<SignatureScheme> {
+        self.provider.signature_verification_algorithms.supported_schemes()
+    }
+}
+
+/// Prepare a request from a direct connection for the router: replace
+/// forgeable proxy headers with the peer address and attach the
+/// connection's client certificate and TLS details.
+pub fn prepare(
+    request: &mut Request,
+    peer: SocketAddr,
+    cert: Option<&Arc<ClientCert>>,
+    tls: Option<&Arc<TlsInfo>>,
+) {
+    let headers = request.headers_mut();
+    for name in FORGEABLE_HEADERS {
+        headers.remove(*name);
+    }
+    if let Ok(ip) = HeaderValue::from_str(&peer.ip().to_canonical().to_string()) {
+        headers.insert("x-real-ip", ip);
+    }
+    if let Some(cert) = cert {
+        request.extensions_mut().insert(cert.clone());
+    }
+    if let Some(tls) = tls {
+        request.extensions_mut().insert(tls.clone());
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn direct_requests_use_the_peer_address() {
+        let mut request = Request::builder()
+            .uri("/w/abc")
+            .header("x-forwarded-for", "203.0.113.9")
+            .header("cf-connecting-ip", "203.0.113.9")
+            .header("cf-ipcountry", "US")
+            .header("x-custom", "kept")
+            .body(axum::body::Body::empty())
+            .unwrap();
+        prepare(&mut request, "[::ffff:198.51.100.7]:50000".parse().unwrap(), None, None);
+        let headers = request.headers();
+        assert_eq!(headers["x-real-ip"], "198.51.100.7");
+        assert_eq!(headers["x-custom"], "kept");
+        assert!(headers.get("x-forwarded-for").is_none());
+        assert!(headers.get("cf-connecting-ip").is_none());
+        assert!(headers.get("cf-ipcountry").is_none());
+        assert!(request.extensions().get::<Arc<ClientCert>>().is_none());
+    }
+
+    #[test]
+    fn missing_files_are_reported() {
+        let err = server_config("/nonexistent/cert.pem", "/nonexistent/key.pem").unwrap_err();
+        assert!(err.starts_with("/nonexistent/cert.pem"), "{err}");
+    }
+}
@@ -0,0 +1,232 @@
+//! Per-part metadata for `multipart/form-data` bodies.
+//!
+//! Senders like Mailgun and SendGrid's inbound parse post form fields and
+//! attachments as multipart, which otherwise only shows up as one opaque body. Each part's
+//! name, filename, content type and size are stored alongside the raw body
+//! in `requests.parts`; part bodies of at most MULTIPART_INLINE_BYTES that are
+//! valid UTF-8 are stored inline too (off by default, since the raw body
+//! already holds them).
+//!
+//! The parser follows RFC 7578 (CRLF line breaks, `Content-Disposition:
+//! form-data`) and gives up on anything malformed rather than guessing: the
+//! raw body is always stored either way.
+
+use serde::Serialize;
+
+/// Parts beyond this are not described (the raw body still has them).
+const MAX_PARTS: usize = 100;
+
+/// Longest name, filename or content type kept; longer values are cut.
+const MAX_FIELD_LENGTH: usize = 256;
+
+/// RFC 2046 caps boundaries at 70 characters.
+const MAX_BOUNDARY_LENGTH: usize = 70;
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct Part {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub name: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub filename: Option<String>,
+    #[serde(rename = "contentType", skip_serializing_if = "Option::is_none")]
+    pub content_type: Option<String>,
+    /// Body size in bytes
+    pub size: usize,
+    /// The part body, when inlining is on and it is small UTF-8 text
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub body: Option<String>,
+}
+
+/// The boundary of a `multipart/form-data` content type, if it is one.
+pub fn boundary(content_type: &str) -> Option<String> {
+    let mut params = content_type.split(';');
+    let mime = params.next()?.trim();
+    if !mime.eq_ignore_ascii_case("multipart/form-data") {
+        return None;
+    }
+    params.find_map(|param| {
+        let (key, value) = param.split_once('=')?;
+        if !key.trim().eq_ignore_ascii_case("boundary") {
+            return None;
+        }
+        let value = value.trim();
+        let value = value
+            .strip_prefix('"')
+            .and_then(|v| v.strip_suffix('"'))
+            .unwrap_or(value);
+        (!value.is_empty() && value.len() <= MAX_BOUNDARY_LENGTH).then(|| value.to_string())
+    })
+}
+
+/// Describe the parts of a multipart body, or `None` if it doesn't parse.
+/// Part bodies up to `inline_limit` bytes are kept when they are UTF-8.
+pub fn parse(body: &[u8], boundary: &str, inline_limit: usize) -> Option<Vec<Part>> {
+    let delimiter = format!("--{boundary}");
+    let delimiter = delimiter.as_bytes();
+    // Later delimiters start on a new line, which belongs to the delimiter.
+    let separator = [b"\r\n", delimiter].concat();
+
+    let mut pos = if body.starts_with(delimiter) {
+        0
+    } else {
+        find(body, &separator, 0)? + 2
+    };
+    let mut parts = Vec::new();
+    loop {
+        let rest = pos + delimiter.len();
+        if body[rest..].starts_with(b"--") {
+            return Some(parts);
+        }
+        if parts.len() == MAX_PARTS {
+            return Some(parts);
+        }
+        // Skip transport padding after the delimiter.
+        let start = find(body, b"\r\n", rest)? + 2;
+        let end = find(body, &separator, start)?;
+        parts.push(part(&body[start..end], inline_limit)?);
+        pos = end + 2;
+    }
+}
+
+fn part(section: &[u8], inline_limit: usize) -> Option<Part> {
+    let (head, content) = if section.starts_with(b"\r\n") {
+        (&section[..0], &section[2..])
+    } else {
+        let split = find(section, b"\r\n\r\n", 0)?;
+        (&section[..split], &section[split + 4..])
+    };
+    let head = std::str::from_utf8(head).ok()?;
+
+    let mut part = Part {
+        name: None,
+        filename: None,
+        content_type: None,
+        size: content.len(),
+        body: None,
+    };
+    for line in head.split("\r\n") {
+        let Some((name, value)) = line.split_once(':') else {
+            continue;
+        };
+        let value = value.trim();
+        if name.trim().eq_ignore_ascii_case("content-disposition") {
+            part.name = disposition_param(value, "name");
+            part.filename = disposition_param(value, "filename");
+        } else if name.trim().eq_ignore_ascii_case("content-type") {
+            part.content_type = Some(truncate(value));
+        }
+    }
+    if content.len() <= inline_limit {
+        part.body = std::str::from_utf8(content).ok().map(str::to_string);
+    }
+    Some(part)
+}
+
+/// A parameter of a `form-data; name="..."; filename="..."` disposition.
+/// Quoted values may contain `;` and backslash-escaped quotes.
+fn disposition_param(value: &str, key: &str) -> Option<String> {
+    let mut rest = value.split_once(';')?.1;
+    loop {
+        rest = rest.trim_start();
+        let (param, after) = rest.split_once('=')?;
+        let after = after.trim_start();
+        let (param_value, next) = if let Some(quoted) = after.strip_prefix('"') {
+            let mut out = String::new();
+            let mut chars = quoted.char_indices();
+            let mut end = None;
+            while let Some((i, c)) = chars.next() {
+                match c {
+                    '\\' => {
+                        if let Some((_, escaped)) = chars.next() {
+                            out.push(escaped);
+                        }
+                    }
+                    '"' => {
+                        end = Some(i + 1);
+                        break;
+                    }
+                    _ => out.push(c),
+                }
+            }
+            let next = &quoted[end?..];
+            (out, next.split_once(';').map_or("", |(_, n)| n))
+        } else {
+            let (v, n) = after.split_once(';').unwrap_or((after, ""));
+            (v.trim().to_string(), n)
+        };
+        if param.trim().eq_ignore_ascii_case(key) {
+            return Some(truncate(&param_value));
+        }
+        if next.is_empty() {
+            return None;
+        }
+        rest = next;
+    }
+}
+
+fn truncate(value: &str) -> String {
+    match value.char_indices().nth(MAX_FIELD_LENGTH) {
+        Some((i, _)) => value[..i].to_string(),
+        None => value.to_string(),
+    }
+}
+
+fn find(haystack: &[u8], needle: &[u8], from: usize) -> Option<usize> {
+    haystack
+        .get(from..)?
+        .windows(needle.len())
+        .position(|w| w == needle)
+        .map(|i| i + from)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const BODY: &[u8] = b"--XyZ\r\n\
+Content-Disposition: form-data; name=\"from\"\r\n\
+\r\n\
+ana@example.com\r\n\
+--XyZ\r\n\
+Content-Disposition: form-data; name=\"attachment-1\"; filename=\"inv\\\"oice;1.pdf\"\r\n\
+Content-Type: application/pdf\r\n\
+\r\n\
+%PDF-1.4\xff\xfe\r\n\
+--XyZ--\r\n";
+
+    #[test]
+    fn boundary_from_content_type() {
+        assert_eq!(boundary("multipart/form-data; boundary=XyZ").as_deref(), Some("XyZ"));
+        assert_eq!(
+            boundary("Multipart/Form-Data; charset=utf-8; boundary=\"a b\"").as_deref(),
+            Some("a b")
+        );
+        assert_eq!(boundary("multipart/mixed; boundary=XyZ"), None);
+        assert_eq!(boundary("multipart/form-data"), None);
+    }
+
+    #[test]
+    fn parses_fields_and_files() {
+        let parts = parse(BODY, "XyZ", 0).unwrap();
+        assert_eq!(parts.len(), 2);
+        assert_eq!(parts[0].name.as_deref(), Some("from"));
+        assert_eq!((parts[0].size, parts[0].body.as_deref()), (15, None));
+        assert_eq!(parts[1].filename.as_deref(), Some("inv\"oice;1.pdf"));
+        assert_eq!(parts[1].content_type.as_deref(), Some("application/pdf"));
+        assert_eq!(parts[1].size, 10);
+
+        // Inlining keeps small UTF-8 bodies only
+        let parts = parse(BODY, "XyZ", 64).unwrap();
+        assert_eq!(parts[0].body.as_deref(), Some("ana@example.com"));
+        assert_eq!(parts[1].body, None);
+    }
+
+    #[test]
+    fn malformed_bodies_are_not_described() {
+        assert_eq!(parse(b"no delimiters here", "XyZ", 0), None);
+        // Missing the closing delimiter
+        let unterminated = b"--XyZ\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\nvalue";
+        assert_eq!(parse(unterminated, "XyZ", 0), None);
+        assert_eq!(parse(b"--XyZ--\r\n", "XyZ", 0), Some(vec![]));
+    }
+}
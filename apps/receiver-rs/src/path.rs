@@ -0,0 +1,214 @@
+//! Normalization of the captured request path.
+//!
+//! The path stored with a capture is taken from the raw request URI rather
+//! than axum's decoded `{*path}` parameter, so an encoded slash (`%2F`) stays
+//! distinct from a real one. It is then normalized so hostile input can't
+//! confuse consumers that build URLs from it (CLI tunnel, replay, SDK):
+//!
+//! - dot segments (`.`, `..`, and their `%2e` spellings) are resolved and can't
+//!   climb above the endpoint root
+//! - empty segments (`//`) collapse
+//! - percent-escapes are upper-cased; any byte outside the RFC 3986 path
+//!   character set is percent-encoded
+//! - the result is truncated to a configurable maximum without splitting an
+//!   escape sequence
+
+/// Default for `MAX_PATH_LENGTH`.
+pub const DEFAULT_MAX_PATH_LENGTH: usize = 2048;
+
+/// Derive the captured path from the raw URI path of a `/w/{slug}/...` request
+/// (or a `/ws/{slug}/...` WebSocket handshake). Always returns a path starting
+/// with `/`.
+pub fn captured_path(uri_path: &str, max_len: usize) -> String {
+    captured_path_checked(uri_path, max_len).0
+}
+
+/// [`captured_path`], and whether the path was cut to `max_len`.
+pub fn captured_path_checked(uri_path: &str, max_len: usize) -> (String, bool) {
+    let rest = uri_path
+        .strip_prefix("/w/")
+        .or_else(|| uri_path.strip_prefix("/ws/"))
+        .and_then(|after| after.split_once('/'))
+        .map(|(_, rest)| rest)
+        .unwrap_or("");
+
+    let encoded = encode_path_chars(rest);
+    let mut path = remove_dot_segments(&encoded);
+    if !path.starts_with('/') {
+        path.insert(0, '/');
+    }
+    let truncated = path.len() > max_len;
+    (truncate_path(path, max_len), truncated)
+}
+
+fn is_path_char(b: u8) -> bool {
+    // unreserved / sub-delims / ":" / "@" / "/" (RFC 3986 section 3.3)
+    b.is_ascii_alphanumeric() || b"-._~!$&'()*+,;=:@/".contains(&b)
+}
+
+/// Upper-case valid percent-escapes and encode everything else that isn't a
+/// path character, including a stray `%`.
+fn encode_path_chars(raw: &str) -> String {
+    let bytes = raw.as_bytes();
+    let mut out = String::with_capacity(raw.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        let b = bytes[i];
+        if b == b'%'
+            && i + 2 < bytes.len()
+            && bytes[i + 1].is_ascii_hexdigit()
+            && bytes[i + 2].is_ascii_hexdigit()
+        {
+            out.push('%');
+            out.push(bytes[i + 1].to_ascii_uppercase() as char);
+            out.push(bytes[i + 2].to_ascii_uppercase() as char);
+            i += 3;
+        } else if is_path_char(b) {
+            out.push(b as char);
+            i += 1;
+        } else {
+            out.push_str(&format!("%{b:02X}"));
+            i += 1;
+        }
+    }
+    out
+}
+
+/// `.` or `..`, in either literal or `%2E` form (input is already upper-cased).
+fn dot_segment(segment: &str) -> Option<usize> {
+    match segment.replace("%2E", ".").as_str() {
+        "." => Some(1),
+        ".." => Some(2),
+        _ => None,
+    }
+}
+
+/// RFC 3986 section 5.2.4, operating on `/`-separated segments. Popping past
+/// the root is a no-op, so `..` can never escape the endpoint.
+fn remove_dot_segments(path: &str) -> String {
+    let segments: Vec<&str> = path.split('/').collect();
+    let mut out: Vec<&str> = Vec::with_capacity(segments.len());
+    let last = segments.len().saturating_sub(1);
+
+    for (i, segment) in segments.iter().enumerate() {
+        match dot_segment(segment) {
+            Some(dots) => {
+                if dots == 2 {
+                    out.pop();
+                }
+                // A trailing dot segment still denotes a directory.
+                if i == last {
+                    out.push("");
+                }
+            }
+            None if segment.is_empty() && i != last => {}
+            None => out.push(segment),
+        }
+    }
+
+    format!("/{}", out.join("/"))
+}
+
+fn truncate_path(mut path: String, max_len: usize) -> String {
+    if path.len() <= max_len {
+        return path;
+    }
+    let mut cut = max_len.max(1);
+    // Back off so we don't leave half of a %XX escape at the end.
+    if let Some(pct) = path[..cut].rfind('%')
+        && pct + 3 > cut
+    {
+        cut = pct;
+    }
+    path.truncate(cut);
+    path
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn cp(uri_path: &str) -> String {
+        captured_path(uri_path, DEFAULT_MAX_PATH_LENGTH)
+    }
+
+    #[test]
+    fn root_paths() {
+        assert_eq!(cp("/w/abc"), "/");
+        assert_eq!(cp("/w/abc/"), "/");
+    }
+
+    #[test]
+    fn plain_paths_pass_through() {
+        assert_eq!(cp("/w/abc/stripe/events"), "/stripe/events");
+        assert_eq!(cp("/w/abc/hooks/"), "/hooks/");
+        assert_eq!(cp("/w/abc/a:b@c/~user"), "/a:b@c/~user");
+    }
+
+    #[test]
+    fn websocket_paths() {
+        assert_eq!(cp("/ws/abc"), "/");
+        assert_eq!(cp("/ws/abc/feed/v2"), "/feed/v2");
+    }
+
+    #[test]
+    fn encoded_slash_stays_encoded() {
+        assert_eq!(cp("/w/abc/a%2fb"), "/a%2Fb");
+        assert_eq!(cp("/w/abc/a%2Fb/c"), "/a%2Fb/c");
+    }
+
+    #[test]
+    fn dot_segments_cannot_escape_root() {
+        assert_eq!(cp("/w/abc/../../etc/passwd"), "/etc/passwd");
+        assert_eq!(cp("/w/abc/a/./b/../c"), "/a/c");
+        assert_eq!(cp("/w/abc/%2e%2e/%2E%2e/x"), "/x");
+        assert_eq!(cp("/w/abc/.%2e/x"), "/x");
+        assert_eq!(cp("/w/abc/a/.."), "/");
+        assert_eq!(cp("/w/abc/a/b/."), "/a/b/");
+    }
+
+    #[test]
+    fn dotted_names_are_not_dot_segments() {
+        assert_eq!(cp("/w/abc/.well-known/x"), "/.well-known/x");
+        assert_eq!(cp("/w/abc/.../x"), "/.../x");
+        assert_eq!(cp("/w/abc/a..b"), "/a..b");
+    }
+
+    #[test]
+    fn encoded_separators_in_slug_segment_are_not_path() {
+        // axum decodes the slug to "abc/../other", which fails slug validation;
+        // the captured path must not pick up pieces of it either.
+        assert_eq!(cp("/w/abc%2F..%2Fother/x"), "/x");
+    }
+
+    #[test]
+    fn empty_segments_collapse() {
+        assert_eq!(cp("/w/abc//a///b"), "/a/b");
+    }
+
+    #[test]
+    fn unsafe_bytes_are_encoded() {
+        assert_eq!(cp("/w/abc/a b"), "/a%20b");
+        assert_eq!(cp("/w/abc/a\"<b>"), "/a%22%3Cb%3E");
+        assert_eq!(cp("/w/abc/100%"), "/100%25");
+        assert_eq!(cp("/w/abc/%zz"), "/%25zz");
+        assert_eq!(cp("/w/abc/%0d%0aSet-Cookie"), "/%0D%0ASet-Cookie");
+    }
+
+    #[test]
+    fn long_paths_truncate_on_escape_boundary() {
+        let long = format!("/w/abc/{}", "a".repeat(5000));
+        assert_eq!(captured_path(&long, 100).len(), 100);
+
+        // Cutting at 9 would leave a dangling "%"
+        assert_eq!(captured_path("/w/abc/aaaaaaa%2Fbbb", 9), "/aaaaaaa");
+        assert_eq!(captured_path("/w/abc/aaaaaaa%2Fbbb", 11), "/aaaaaaa%2F");
+    }
+
+    #[test]
+    fn reports_truncation() {
+        assert_eq!(captured_path_checked("/w/abc/a/b", 100), ("/a/b".to_string(), false));
+        assert_eq!(captured_path_checked("/w/abc/aaaa", 3), ("/aa".to_string(), true));
+        assert_eq!(captured_path_checked("/w/abc/aaa", 4), ("/aaa".to_string(), false));
+    }
+}
@@ -0,0 +1,202 @@
+//! Published webhook source ranges, for network rules that name a provider.
+//!
+//! GitHub lists the addresses its webhooks come from in the `hooks` array of
+//! its meta API, and Stripe in the `WEBHOOKS` array of its IP list. A
+//! background task fetches both at startup and every [`REFRESH_INTERVAL`] and
+//! stores them with `set_provider_ip_ranges()`; `capture_webhook` matches a
+//! rule's `providers` against the stored ranges. A fetch that fails, or
+//! yields no usable range, leaves the last stored list in place, so a
+//! provider outage doesn't lock its senders out.
+
+use serde_json::Value;
+use sqlx::PgPool;
+use std::net::IpAddr;
+use std::time::Duration;
+
+/// How often the ranges are refreshed.
+const REFRESH_INTERVAL: Duration = Duration::from_secs(60 * 60);
+
+/// Timeout for each fetch.
+const FETCH_TIMEOUT: Duration = Duration::from_secs(15);
+
+/// Larger documents are refused.
+const MAX_DOCUMENT_BYTES: usize = 1_024 * 1_024;
+
+/// A provider whose webhook ranges are published as JSON.
+struct Provider {
+    name: &'static str,
+    url: &'static str,
+    /// The array of addresses or CIDRs in the document.
+    field: &'static str,
+}
+
+const PROVIDERS: &[Provider] = &[
+    Provider {
+        name: "github",
+        url: "https://api.github.com/meta",
+        field: "hooks",
+    },
+    Provider {
+        name: "stripe",
+        url: "https://stripe.com/files/ips/ips_webhooks.json",
+        field: "WEBHOOKS",
+    },
+];
+
+/// Whether `value` is an address or a CIDR Postgres will accept: a valid
+/// prefix length with no host bits set past it. Bare addresses are single
+/// hosts.
+fn is_cidr(value: &str) -> bool {
+    let (address, prefix) = match value.split_once('/') {
+        Some((address, prefix)) => (address, Some(prefix)),
+        None => (value, None),
+    };
+    let Ok(address) = address.parse::<IpAddr>() else {
+        return false;
+    };
+    let (bits, host) = match address {
+        IpAddr::V4(v4) => (32, u128::from(u32::from(v4))),
+        IpAddr::V6(v6) => (128, u128::from(v6)),
+    };
+    let length = match prefix {
+        None => bits,
+        Some(prefix) => match prefix.parse::<u32>() {
+            Ok(length) if length <= bits => length,
+            _ => return false,
+        },
+    };
+    length == bits || host & ((1u128 << (bits - length)) - 1) == 0
+}
+
+/// The usable ranges listed under `field`, or None when there are none.
+fn ranges(document: &Value, field: &str) -> Option<Vec<String>> {
+    let ranges: Vec<String> = document
+        .get(field)?
+        .as_array()?
+        .iter()
+        .filter_map(Value::as_str)
+        .map(str::trim)
+        .filter(|range| is_cidr(range))
+        .map(String::from)
+        .collect();
+    (!ranges.is_empty()).then_some(ranges)
+}
+
+async fn fetch(http: &reqwest::Client, provider: &Provider) -> Result<Vec<String>, &'static str> {
+    let response = http
+        .get(provider.url)
+        .header("accept", "application/json")
+        .send()
+        .await
+        .map_err(|_| "request failed")?;
+    if !response.status().is_success() {
+        return Err("non-success status");
+    }
+    if response
+        .content_length()
+        .is_some_and(|len| len > MAX_DOCUMENT_BYTES as u64)
+    {
+        return Err("document too large");
+    }
+    let body = response.bytes().await.map_err(|_| "failed to read body")?;
+    if body.len() > MAX_DOCUMENT_BYTES {
+        return Err("document too large");
+    }
+    let document: Value = serde_json::from_slice(&body).map_err(|_| "invalid JSON")?;
+    ranges(&document, provider.field).ok_or("no ranges listed")
+}
+
+/// Fetch and store every provider's ranges.
+async fn refresh(http: &reqwest::Client, pool: &PgPool) {
+    for provider in PROVIDERS {
+        let ranges = match fetch(http, provider).await {
+            Ok(ranges) => ranges,
+            Err(error) => {
+                tracing::warn!(
+                    provider = provider.name,
+                    error,
+                    "failed to fetch provider IP ranges, keeping the last list"
+                );
+                continue;
+            }
+        };
+        let count = ranges.len();
+        let result = sqlx::query("SELECT set_provider_ip_ranges($1, $2)")
+            .bind(provider.name)
+            .bind(ranges)
+            .execute(pool)
+            .await;
+        match result {
+            Ok(_) => tracing::debug!(
+                provider = provider.name,
+                count,
+                "refreshed provider IP ranges"
+            ),
+            Err(e) => {
+                tracing::error!(provider = provider.name, error = %e, "failed to store provider IP ranges")
+            }
+        }
+    }
+}
+
+/// Refresh the ranges now and every [`REFRESH_INTERVAL`] for the life of the
+/// process.
+pub fn spawn_refresher(pool: PgPool) {
+    let http = match reqwest::Client::builder()
+        .timeout(FETCH_TIMEOUT)
+        // GitHub's API refuses requests without a User-Agent
+        .user_agent(concat!("webhooks-receiver/", env!("CARGO_PKG_VERSION")))
+        .build()
+    {
+        Ok(http) => http,
+        Err(e) => {
+            tracing::error!(error = %e, "failed to build provider IP range client");
+            return;
+        }
+    };
+    tokio::spawn(async move {
+        let mut interval = tokio::time::interval(REFRESH_INTERVAL);
+        interval.set_missed_tick_behavior(tokio::time::MissedTickBehavior::Delay);
+        loop {
+            interval.tick().await;
+            refresh(&http, &pool).await;
+        }
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn accepts_addresses_and_aligned_ranges() {
+        assert!(is_cidr("192.30.252.0/22"));
+        assert!(is_cidr("3.18.12.63"));
+        assert!(is_cidr("2a0a:a440::/29"));
+        assert!(is_cidr("0.0.0.0/0"));
+        assert!(!is_cidr("192.30.252.1/22"));
+        assert!(!is_cidr("192.30.252.0/33"));
+        assert!(!is_cidr("example.com"));
+        assert!(!is_cidr("10.0.0.0/"));
+    }
+
+    #[test]
+    fn reads_the_listed_field() {
+        let github = json!({"hooks": ["192.30.252.0/22", "bogus", 7], "web": ["1.2.3.4/32"]});
+        assert_eq!(
+            ranges(&github, "hooks"),
+            Some(vec!["192.30.252.0/22".to_string()])
+        );
+
+        let stripe = json!({"WEBHOOKS": [" 3.18.12.63 ", "3.130.192.231"]});
+        assert_eq!(
+            ranges(&stripe, "WEBHOOKS"),
+            Some(vec!["3.18.12.63".to_string(), "3.130.192.231".to_string()])
+        );
+
+        assert_eq!(ranges(&json!({"hooks": []}), "hooks"), None);
+        assert_eq!(ranges(&json!({"hooks": "192.30.252.0/22"}), "hooks"), None);
+        assert_eq!(ranges(&json!({}), "hooks"), None);
+    }
+}
@@ -0,0 +1,370 @@
+//! Proxy passthrough: an endpoint with a `forward_url` relays each request to
+//! that backend and answers the sender with the backend's reply, in place of
+//! the mock, so webhooks.cc can sit in front of a real receiver. The backend's
+//! reply is recorded with the request (`requests.response`, source `proxy`).
+//!
+//! The request goes out with its method, captured path (appended to the
+//! forward URL's path), query, headers and raw body, minus hop-by-hop and
+//! infrastructure headers; `x-forwarded-for` carries the sender's IP. The
+//! reply comes back as sent, minus hop-by-hop headers and those mock replies
+//! block by default. Connections are pinned to the addresses checked when
+//! the host was resolved, so a forward URL can't reach private networks, and
+//! redirects are passed back rather than followed.
+//!
+//! Relayed requests carry `x-webhooks-cc-proxied`: the origin slug and time,
+//! signed with CAPTURE_SHARED_SECRET. Requests with a valid, recent marker
+//! are never relayed again, so an endpoint forwarding to itself (or two
+//! forwarding to each other) can't loop, while a sender can't forge one to
+//! stop an endpoint from relaying. The header is dropped from what's stored
+//! and relayed like the other infrastructure headers.
+
+use axum::body::Body;
+use axum::http::{HeaderMap, Method, StatusCode};
+use axum::response::Response;
+use base64::Engine;
+use base64::engine::general_purpose::STANDARD as BASE64;
+use bytes::{Bytes, BytesMut};
+use chrono::{DateTime, Utc};
+use hmac::{Hmac, Mac};
+use sha2::Sha256;
+use std::time::{Duration, Instant};
+
+/// Marks a request as relayed by the receiver.
+pub const PROXY_HEADER: &str = "x-webhooks-cc-proxied";
+
+/// How long a relay marker is honored: enough for a backend that sends the
+/// request straight back, not enough for a leaked one to matter for long.
+const MARKER_TTL_SECS: i64 = 5 * 60;
+
+/// Clock skew tolerated between receivers for markers from the future.
+const MARKER_SKEW_SECS: i64 = 60;
+
+/// How long the backend has to answer, body included.
+const TIMEOUT: Duration = Duration::from_secs(30);
+
+/// Largest reply relayed to the sender; larger ones fail like an unreachable
+/// backend.
+const MAX_RESPONSE: usize = 10 * 1024 * 1024;
+
+/// Reply body bytes kept in the recorded summary.
+const MAX_RECORDED_BODY: usize = 64 * 1024;
+
+/// The request to relay.
+pub struct Forward<'a> {
+    pub method: &'a Method,
+    /// Captured path, below the endpoint
+    pub path: &'a str,
+    /// Raw query string, if any
+    pub query: Option<&'a str>,
+    /// Headers as received
+    pub headers: &'a HeaderMap,
+    pub body: Bytes,
+    pub ip: &'a str,
+    /// [`PROXY_HEADER`] value, from [`marker`]
+    pub marker: String,
+}
+
+/// A relayed request and what came back.
+pub struct Exchange {
+    latency: Duration,
+    result: Result<Upstream, &'static str>,
+}
+
+struct Upstream {
+    status: StatusCode,
+    headers: HeaderMap,
+    body: Bytes,
+}
+
+/// Relay `request` to the backend at `forward_url`.
+pub async fn forward(forward_url: &str, request: Forward<'_>) -> Exchange {
+    let start = Instant::now();
+    let result = send(forward_url, request).await;
+    Exchange {
+        latency: start.elapsed(),
+        result,
+    }
+}
+
+async fn send(forward_url: &str, request: Forward<'_>) -> Result<Upstream, &'static str> {
+    let url = target_url(forward_url, request.path, request.query);
+    let resolved = crate::handlers::webhook::resolve_notification_target(&url).await?;
+    let client = reqwest::Client::builder()
+        .timeout(TIMEOUT)
+        .redirect(reqwest::redirect::Policy::none())
+        .resolve_to_addrs(&resolved.host, &resolved.addrs)
+        .build()
+        .map_err(|_| "failed to build client")?;
+
+    let mut headers = crate::mirror::replay_headers(request.headers);
+    for name in crate::handlers::webhook::PROXY_HEADERS {
+        headers.remove(*name);
+    }
+    let mut outgoing = client
+        .request(request.method.clone(), &resolved.url)
+        .headers(headers)
+        .header("x-forwarded-for", request.ip)
+        .header(PROXY_HEADER, request.marker);
+    if !request.body.is_empty() {
+        outgoing = outgoing.body(request.body);
+    }
+
+    let mut response = outgoing.send().await.map_err(|e| {
+        if e.is_timeout() {
+            "backend timed out"
+        } else {
+            "backend unreachable"
+        }
+    })?;
+    if response
+        .content_length()
+        .is_some_and(|len| len > MAX_RESPONSE as u64)
+    {
+        return Err("backend reply too large");
+    }
+    let status = response.status();
+    let headers = response.headers().clone();
+    let mut body = BytesMut::new();
+    while let Some(chunk) = response
+        .chunk()
+        .await
+        .map_err(|_| "backend reply cut short")?
+    {
+        if body.len() + chunk.len() > MAX_RESPONSE {
+            return Err("backend reply too large");
+        }
+        body.extend_from_slice(&chunk);
+    }
+    Ok(Upstream {
+        status,
+        headers,
+        body: body.freeze(),
+    })
+}
+
+fn marker_mac(secret: &str, slug: &str, secs: i64) -> Hmac<Sha256> {
+    let mut mac =
+        Hmac::<Sha256>::new_from_slice(secret.as_bytes()).expect("HMAC accepts any key length");
+    mac.update(format!("v1.{}.{secs}", slug.to_lowercase()).as_bytes());
+    mac
+}
+
+/// The [`PROXY_HEADER`] value for a request relayed from `slug`:
+/// `v1.<slug>.<unix seconds>.<hex HMAC-SHA256>`, the MAC covering
+/// `v1.<lowercase slug>.<seconds>` under `secret`.
+pub fn marker(secret: &str, slug: &str, now: DateTime<Utc>) -> String {
+    let secs = now.timestamp();
+    let mac = marker_mac(secret, slug, secs).finalize().into_bytes();
+    format!("v1.{slug}.{secs}.{}", hex::encode(mac))
+}
+
+/// Whether the request carries a marker signed with `secret` in the last
+/// [`MARKER_TTL_SECS`], i.e. a receiver relayed it.
+pub fn already_relayed(secret: &str, headers: &HeaderMap, now: DateTime<Utc>) -> bool {
+    let Some(value) = headers.get(PROXY_HEADER).and_then(|v| v.to_str().ok()) else {
+        return false;
+    };
+    let mut parts = value.trim().splitn(4, '.');
+    let (Some("v1"), Some(slug), Some(secs), Some(signature)) =
+        (parts.next(), parts.next(), parts.next(), parts.next())
+    else {
+        return false;
+    };
+    let (Ok(secs), Ok(signature)) = (secs.parse::<i64>(), hex::decode(signature)) else {
+        return false;
+    };
+    let age = now.timestamp() - secs;
+    if !(-MARKER_SKEW_SECS..=MARKER_TTL_SECS).contains(&age) {
+        return false;
+    }
+    marker_mac(secret, slug, secs)
+        .verify_slice(&signature)
+        .is_ok()
+}
+
+/// The backend URL for a request: the forward URL with the captured path
+/// appended to its path and the request's query added to its own.
+fn target_url(forward_url: &str, path: &str, query: Option<&str>) -> String {
+    let base = forward_url.split('#').next().unwrap_or_default();
+    let (base, base_query) = match base.split_once('?') {
+        Some((base, query)) => (base, Some(query)),
+        None => (base, None),
+    };
+    let mut url = if path == "/" {
+        base.to_string()
+    } else {
+        format!("{}{path}", base.trim_end_matches('/'))
+    };
+    let queries: Vec<&str> = [base_query, query]
+        .into_iter()
+        .flatten()
+        .filter(|q| !q.is_empty())
+        .collect();
+    if !queries.is_empty() {
+        url.push('?');
+        url.push_str(&queries.join("&"));
+    }
+    url
+}
+
+impl Exchange {
+    /// The backend's reply for the sender, with its body size. `None` when
+    /// the backend couldn't be reached, timed out or sent too much.
+    pub fn response(&self) -> Option<(Response, usize)> {
+        let upstream = self.result.as_ref().ok()?;
+        let mut response = Response::new(Body::from(upstream.body.clone()));
+        *response.status_mut() = upstream.status;
+        let mut headers = crate::mirror::replay_headers(&upstream.headers);
+        for name in crate::handlers::webhook::BLOCKED_HEADERS {
+            headers.remove(*name);
+        }
+        *response.headers_mut() = headers;
+        Some((response, upstream.body.len()))
+    }
+
+    /// Add the exchange to the reply's `requests.response` summary:
+    /// `latencyMs`, then the body as received (`body`, with
+    /// `bodyEncoding: "base64"` when it isn't UTF-8 and `bodyTruncated` past
+    /// [`MAX_RECORDED_BODY`]) or the `error` that stopped it.
+    pub fn record(&self, summary: &mut serde_json::Value) {
+        summary["latencyMs"] = u64::try_from(self.latency.as_millis())
+            .unwrap_or(u64::MAX)
+            .into();
+        let upstream = match &self.result {
+            Ok(upstream) => upstream,
+            Err(error) => {
+                summary["error"] = (*error).into();
+                return;
+            }
+        };
+        let truncated = upstream.body.len() > MAX_RECORDED_BODY;
+        let kept = &upstream.body[..upstream.body.len().min(MAX_RECORDED_BODY)];
+        let text = match std::str::from_utf8(kept) {
+            Ok(text) => Some(text),
+            // The cut may split the last character
+            Err(e) if truncated && e.error_len().is_none() => {
+                std::str::from_utf8(&kept[..e.valid_up_to()]).ok()
+            }
+            Err(_) => None,
+        };
+        match text {
+            Some(text) => summary["body"] = text.into(),
+            None => {
+                summary["body"] = BASE64.encode(kept).into();
+                summary["bodyEncoding"] = "base64".into();
+            }
+        }
+        if truncated {
+            summary["bodyTruncated"] = true.into();
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn exchange(result: Result<Upstream, &'static str>) -> Exchange {
+        Exchange {
+            latency: Duration::from_millis(42),
+            result,
+        }
+    }
+
+    fn upstream(body: &[u8]) -> Upstream {
+        let mut headers = HeaderMap::new();
+        headers.insert("content-type", "application/json".parse().unwrap());
+        headers.insert("transfer-encoding", "chunked".parse().unwrap());
+        headers.insert("set-cookie", "session=1".parse().unwrap());
+        Upstream {
+            status: StatusCode::ACCEPTED,
+            headers,
+            body: Bytes::copy_from_slice(body),
+        }
+    }
+
+    #[test]
+    fn only_recent_markers_signed_with_the_secret_count() {
+        let now = DateTime::from_timestamp(1_700_000_000, 0).unwrap();
+        let relayed = |value: &str, secret: &str, at: i64| {
+            let mut headers = HeaderMap::new();
+            headers.insert(PROXY_HEADER, value.parse().unwrap());
+            already_relayed(secret, &headers, DateTime::from_timestamp(at, 0).unwrap())
+        };
+        let value = marker("secret", "My-Hook", now);
+        assert!(value.starts_with("v1.My-Hook.1700000000."));
+
+        assert!(relayed(&value, "secret", 1_700_000_000));
+        assert!(relayed(&value, "secret", 1_700_000_300));
+        assert!(!relayed(&value, "secret", 1_700_000_301));
+        assert!(!relayed(&value, "secret", 1_699_999_900));
+        assert!(!relayed(&value, "other", 1_700_000_000));
+        // Senders used to be able to stop relaying with any value
+        assert!(!relayed("1", "secret", 1_700_000_000));
+        let forged = value.replace("My-Hook", "other-hook");
+        assert!(!relayed(&forged, "secret", 1_700_000_000));
+        assert!(!already_relayed("secret", &HeaderMap::new(), now));
+    }
+
+    #[test]
+    fn target_url_appends_path_and_query() {
+        let url = |base, path, query| target_url(base, path, query);
+        assert_eq!(
+            url("https://api.example/hooks", "/", None),
+            "https://api.example/hooks"
+        );
+        assert_eq!(
+            url("https://api.example/hooks/", "/orders/1", Some("page=2")),
+            "https://api.example/hooks/orders/1?page=2"
+        );
+        assert_eq!(
+            url("https://api.example/in?token=t#frag", "/", Some("page=2")),
+            "https://api.example/in?token=t&page=2"
+        );
+        assert_eq!(
+            url("https://api.example", "/a", Some("")),
+            "https://api.example/a"
+        );
+    }
+
+    #[test]
+    fn reply_is_relayed_without_hop_headers() {
+        let (response, size) = exchange(Ok(upstream(b"{\"ok\":true}"))).response().unwrap();
+        assert_eq!(response.status(), StatusCode::ACCEPTED);
+        assert_eq!(size, 11);
+        assert_eq!(response.headers()["content-type"], "application/json");
+        assert!(response.headers().get("transfer-encoding").is_none());
+        assert!(response.headers().get("set-cookie").is_none());
+        assert!(exchange(Err("backend unreachable")).response().is_none());
+    }
+
+    #[test]
+    fn record_keeps_body_latency_and_errors() {
+        let mut summary = json!({"status": 202});
+        exchange(Ok(upstream(b"{\"ok\":true}"))).record(&mut summary);
+        assert_eq!(summary["latencyMs"], 42);
+        assert_eq!(summary["body"], "{\"ok\":true}");
+        assert!(summary.get("bodyTruncated").is_none());
+
+        let mut summary = json!({});
+        exchange(Ok(upstream(&[0xff, 0x00]))).record(&mut summary);
+        assert_eq!(summary["body"], "/wA=");
+        assert_eq!(summary["bodyEncoding"], "base64");
+
+        let mut long = vec![b'a'; MAX_RECORDED_BODY - 1];
+        long.extend_from_slice("é".as_bytes());
+        let mut summary = json!({});
+        exchange(Ok(upstream(&long))).record(&mut summary);
+        assert_eq!(
+            summary["body"].as_str().unwrap().len(),
+            MAX_RECORDED_BODY - 1
+        );
+        assert_eq!(summary["bodyTruncated"], true);
+
+        let mut summary = json!({});
+        exchange(Err("backend timed out")).record(&mut summary);
+        assert_eq!(summary["error"], "backend timed out");
+        assert!(summary.get("body").is_none());
+    }
+}
@@ -0,0 +1,112 @@
+//! Rate-limit simulation: an endpoint's `rate_limit` config (`{limit,
+//! window}`) throttles it like a real API, to test how senders back off.
+//! capture_webhook counts each request in the endpoint's current fixed window
+//! and returns the numbers; the receiver reports them on every reply and
+//! answers requests over the limit with a 429:
+//!
+//! - `X-RateLimit-Limit`: requests allowed per window
+//! - `X-RateLimit-Remaining`: requests left in the current window
+//! - `X-RateLimit-Reset`: when the window resets, in Unix seconds
+//! - `Retry-After`: seconds until then, on the 429 only
+//!
+//! Counts are kept in Postgres, so every receiver instance shares them.
+//! Throttled requests are still captured.
+
+use axum::http::{HeaderMap, HeaderName, HeaderValue, StatusCode, header};
+use axum::response::{IntoResponse, Response};
+use serde::Deserialize;
+
+/// The endpoint's window as counted for this request.
+#[derive(Debug, Deserialize, PartialEq)]
+pub struct RateLimit {
+    limit: u64,
+    remaining: u64,
+    /// Unix seconds when the window resets
+    reset: i64,
+    /// Seconds until the window resets, at least 1
+    retry_after: u64,
+    /// Set when this request went over the limit
+    #[serde(default)]
+    limited: bool,
+}
+
+impl RateLimit {
+    /// The 429 for a request over the limit, with its body size.
+    pub fn response(&self) -> Option<(Response, usize)> {
+        if !self.limited {
+            return None;
+        }
+        let message = format!(
+            "Rate limit of {} requests exceeded. Retry after {} seconds.",
+            self.limit, self.retry_after
+        );
+        let body = serde_json::json!({
+            "error": "rate_limited",
+            "message": message,
+            "retryAfter": self.retry_after,
+        })
+        .to_string();
+        let size = body.len();
+        let mut response = (
+            StatusCode::TOO_MANY_REQUESTS,
+            [(header::CONTENT_TYPE, "application/json")],
+            body,
+        )
+            .into_response();
+        response
+            .headers_mut()
+            .insert(header::RETRY_AFTER, HeaderValue::from(self.retry_after));
+        Some((response, size))
+    }
+
+    /// Add the `X-RateLimit-*` headers, replacing any the reply set.
+    pub fn apply(&self, headers: &mut HeaderMap) {
+        let values = [
+            ("x-ratelimit-limit", self.limit.to_string()),
+            ("x-ratelimit-remaining", self.remaining.to_string()),
+            ("x-ratelimit-reset", self.reset.to_string()),
+        ];
+        for (name, value) in values {
+            if let Ok(value) = HeaderValue::from_str(&value) {
+                headers.insert(HeaderName::from_static(name), value);
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn rate_limit(limited: bool) -> RateLimit {
+        serde_json::from_value(serde_json::json!({
+            "limit": 100,
+            "remaining": if limited { 0 } else { 42 },
+            "reset": 1767225660i64,
+            "retry_after": 17,
+            "limited": limited
+        }))
+        .unwrap()
+    }
+
+    #[test]
+    fn requests_over_the_limit_get_429() {
+        let (response, size) = rate_limit(true).response().unwrap();
+        assert_eq!(response.status(), StatusCode::TOO_MANY_REQUESTS);
+        assert_eq!(response.headers()["retry-after"], "17");
+        assert_eq!(response.headers()["content-type"], "application/json");
+        assert!(size > 0);
+        assert!(rate_limit(false).response().is_none());
+    }
+
+    #[test]
+    fn headers_report_the_window() {
+        let mut headers = HeaderMap::new();
+        headers.insert("x-ratelimit-limit", "5".parse().unwrap());
+        rate_limit(false).apply(&mut headers);
+        assert_eq!(headers["x-ratelimit-limit"], "100");
+        assert_eq!(headers["x-ratelimit-remaining"], "42");
+        assert_eq!(headers["x-ratelimit-reset"], "1767225660");
+        assert!(headers.get("retry-after").is_none());
+    }
+}
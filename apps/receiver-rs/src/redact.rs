@@ -0,0 +1,492 @@
+//! Redaction of personal data and secrets before a request is stored.
+//!
+//! `endpoints.redaction` lists what to hide:
+//!
+//! - `detectors`: built-in finders for `email` addresses, `credit_card`
+//!   numbers (13-19 digits, optionally grouped by spaces or dashes, that pass
+//!   the Luhn check) and `bearer_token`s (the token after `Bearer `)
+//! - `paths`: body fields, in the transform path syntax, with `*` matching
+//!   every key or index at that level (`customer.email`, `items.*.card`)
+//! - `patterns`: regular expressions in the `regex` crate's syntax
+//! - `headers`: `deny` lists headers whose whole value is hidden on top of
+//!   [`DEFAULT_HEADERS`], and `allow` those stored as sent
+//!
+//! The default headers are hidden on every endpoint, configured or not,
+//! except those it encrypts (`encrypted_headers`), which are sealed instead.
+//! WebSocket and gRPC captures get the header rules only.
+//!
+//! Matches are replaced with [`REDACTED`] in the stored headers, trailers,
+//! query parameters and body (after transforms, so hashes, fingerprints,
+//! notifications and sinks only see the redacted copy), and in the multipart
+//! part descriptions, CloudEvent attributes and JWT claims. JSON bodies are
+//! redacted value by value and re-serialized when something changed; other
+//! UTF-8 bodies, including offloaded ones, are redacted as text. Path rules
+//! only apply to inline JSON bodies. Non-UTF-8 bodies are stored as
+//! received. Each rule that fired is counted under its id (the detector name,
+//! `path:<path>` or `pattern:<pattern>`) in `requests.redactions`.
+//!
+//! Signature, JWT and schema checks and mock correlation read the request as
+//! received. Configs are cached per slug like the other endpoint caches, with
+//! their patterns compiled once, and dropped on `endpoint_config`
+//! notifications.
+
+use regex::{Captures, Regex};
+use serde::Deserialize;
+use serde_json::Value;
+use sqlx::PgPool;
+use std::borrow::Cow;
+use std::collections::{BTreeMap, HashMap};
+use std::sync::Arc;
+
+use crate::slug_cache::{SlugCache, load_json};
+
+/// What a match is replaced with.
+pub const REDACTED: &str = "[REDACTED]";
+
+/// Headers hidden unless an endpoint allows them: credentials senders put in
+/// requests by mistake or by design.
+pub const DEFAULT_HEADERS: &[&str] = &[
+    "authorization",
+    "proxy-authorization",
+    "cookie",
+    "x-api-key",
+];
+
+/// Nesting past which JSON values are left alone.
+const MAX_DEPTH: usize = 64;
+
+const EMAIL: &str = r"[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}";
+const CREDIT_CARD: &str = r"\b\d(?:[ -]?\d){12,18}\b";
+const BEARER_TOKEN: &str = r"(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)";
+
+/// How many times each rule fired, by rule id.
+pub type Fired = BTreeMap<String, usize>;
+
+#[derive(Debug, Deserialize)]
+struct StoredConfig {
+    #[serde(default)]
+    detectors: Vec<String>,
+    #[serde(default)]
+    paths: Vec<String>,
+    #[serde(default)]
+    patterns: Vec<String>,
+    #[serde(default)]
+    headers: HeaderRules,
+}
+
+#[derive(Debug, Default, Deserialize)]
+struct HeaderRules {
+    /// Lowercase names stored as sent, defaults included
+    #[serde(default)]
+    allow: Vec<String>,
+    /// Lowercase names hidden on top of the defaults
+    #[serde(default)]
+    deny: Vec<String>,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum Check {
+    None,
+    /// Only digit runs that pass the Luhn check
+    Luhn,
+    /// Keep the text before capture group 1
+    KeepPrefix,
+}
+
+#[derive(Debug)]
+struct TextRule {
+    id: String,
+    regex: Regex,
+    check: Check,
+}
+
+/// An endpoint's compiled redaction rules.
+#[derive(Debug)]
+pub struct Redactor {
+    paths: Vec<String>,
+    rules: Vec<TextRule>,
+    headers: HeaderRules,
+}
+
+impl Redactor {
+    fn new(stored: StoredConfig) -> Self {
+        let mut rules = Vec::new();
+        for detector in &stored.detectors {
+            let (source, check) = match detector.as_str() {
+                "email" => (EMAIL, Check::None),
+                "credit_card" => (CREDIT_CARD, Check::Luhn),
+                "bearer_token" => (BEARER_TOKEN, Check::KeepPrefix),
+                other => {
+                    tracing::warn!(detector = other, "unknown redaction detector, skipping");
+                    continue;
+                }
+            };
+            let regex = Regex::new(source).expect("built-in redaction patterns compile");
+            rules.push(TextRule {
+                id: detector.clone(),
+                regex,
+                check,
+            });
+        }
+        for pattern in &stored.patterns {
+            match Regex::new(pattern) {
+                Ok(regex) => rules.push(TextRule {
+                    id: format!("pattern:{pattern}"),
+                    regex,
+                    check: Check::None,
+                }),
+                Err(e) => {
+                    tracing::warn!(pattern, error = %e, "unsupported redaction pattern, skipping")
+                }
+            }
+        }
+        let lowercase = |names: Vec<String>| -> Vec<String> {
+            names.iter().map(|name| name.to_ascii_lowercase()).collect()
+        };
+        Self {
+            paths: stored.paths,
+            rules,
+            headers: HeaderRules {
+                allow: lowercase(stored.headers.allow),
+                deny: lowercase(stored.headers.deny),
+            },
+        }
+    }
+
+    /// Whether a header's whole value is hidden.
+    fn hides_header(&self, name: &str) -> bool {
+        (DEFAULT_HEADERS.contains(&name) || self.headers.deny.iter().any(|deny| deny == name))
+            && !self.headers.allow.iter().any(|allow| allow == name)
+    }
+
+    /// `text` with every text rule applied, or None when nothing matched.
+    pub fn redact_text(&self, text: &str, fired: &mut Fired) -> Option<String> {
+        let mut current = Cow::Borrowed(text);
+        for rule in &self.rules {
+            let mut count = 0;
+            let replaced = rule.regex.replace_all(&current, |caps: &Captures| {
+                let whole = caps.get(0).expect("group 0 always matches");
+                if rule.check == Check::Luhn && !luhn(whole.as_str()) {
+                    return whole.as_str().to_string();
+                }
+                count += 1;
+                match caps.get(1).filter(|_| rule.check == Check::KeepPrefix) {
+                    Some(token) => {
+                        let prefix = &whole.as_str()[..token.start() - whole.start()];
+                        format!("{prefix}{REDACTED}")
+                    }
+                    None => REDACTED.to_string(),
+                }
+            });
+            if count > 0 {
+                let replaced = replaced.into_owned();
+                *fired.entry(rule.id.clone()).or_default() += count;
+                current = Cow::Owned(replaced);
+            }
+        }
+        match current {
+            Cow::Owned(text) => Some(text),
+            Cow::Borrowed(_) => None,
+        }
+    }
+
+    /// Apply the text rules to every string in `value`. Returns whether
+    /// anything changed.
+    pub fn redact_value(&self, value: &mut Value, fired: &mut Fired) -> bool {
+        self.redact_strings(value, fired, 0)
+    }
+
+    fn redact_strings(&self, value: &mut Value, fired: &mut Fired, depth: usize) -> bool {
+        if depth > MAX_DEPTH || self.rules.is_empty() {
+            return false;
+        }
+        match value {
+            Value::String(text) => match self.redact_text(text, fired) {
+                Some(redacted) => {
+                    *text = redacted;
+                    true
+                }
+                None => false,
+            },
+            Value::Array(items) => items.iter_mut().fold(false, |changed, item| {
+                self.redact_strings(item, fired, depth + 1) | changed
+            }),
+            Value::Object(map) => map.values_mut().fold(false, |changed, item| {
+                self.redact_strings(item, fired, depth + 1) | changed
+            }),
+            _ => false,
+        }
+    }
+
+    /// Redact a body in place. JSON bodies (when `parse_json`) get the path
+    /// rules and are re-serialized only when something changed; anything
+    /// else is redacted as text. Returns whether the body changed.
+    pub fn redact_body(&self, body: &mut String, parse_json: bool, fired: &mut Fired) -> bool {
+        if parse_json && let Ok(mut value) = serde_json::from_str::<Value>(body) {
+            let mut changed = false;
+            for path in &self.paths {
+                let count = redact_path(&mut value, &segments(path));
+                if count > 0 {
+                    *fired.entry(format!("path:{path}")).or_default() += count;
+                    changed = true;
+                }
+            }
+            changed |= self.redact_value(&mut value, fired);
+            if changed && let Ok(serialized) = serde_json::to_string(&value) {
+                *body = serialized;
+            }
+            return changed;
+        }
+        match self.redact_text(body, fired) {
+            Some(redacted) => {
+                *body = redacted;
+                true
+            }
+            None => false,
+        }
+    }
+
+    /// Redact each value of a header or query map. Returns whether anything
+    /// changed.
+    pub fn redact_map(&self, map: &mut HashMap<String, String>, fired: &mut Fired) -> bool {
+        let mut changed = false;
+        for value in map.values_mut() {
+            if let Some(redacted) = self.redact_text(value, fired) {
+                *value = redacted;
+                changed = true;
+            }
+        }
+        changed
+    }
+}
+
+/// Redact captured headers or trailers in place: hide the whole value of the
+/// default headers and the endpoint's `deny` list (less its `allow` list and
+/// the headers in `sealed`, which are encrypted instead), then apply the
+/// endpoint's text rules. Returns whether anything changed.
+pub fn redact_headers(
+    redactor: Option<&Redactor>,
+    sealed: &[String],
+    headers: &mut HashMap<String, String>,
+    fired: &mut Fired,
+) -> bool {
+    let mut changed = false;
+    for (name, value) in headers.iter_mut() {
+        let name = name.to_ascii_lowercase();
+        let hidden = match redactor {
+            Some(redactor) => redactor.hides_header(&name),
+            None => DEFAULT_HEADERS.contains(&name.as_str()),
+        };
+        if hidden && value != REDACTED && !sealed.contains(&name) {
+            *value = REDACTED.to_string();
+            *fired.entry(format!("header:{name}")).or_default() += 1;
+            changed = true;
+        }
+    }
+    if let Some(redactor) = redactor {
+        changed |= redactor.redact_map(headers, fired);
+    }
+    changed
+}
+
+fn segments(path: &str) -> Vec<&str> {
+    if path.is_empty() {
+        Vec::new()
+    } else {
+        path.split('.').collect()
+    }
+}
+
+/// Replace every value at `path` (`*` matching any key or index) with
+/// [`REDACTED`]. Returns how many were replaced.
+fn redact_path(value: &mut Value, path: &[&str]) -> usize {
+    let Some((key, rest)) = path.split_first() else {
+        if value.as_str() == Some(REDACTED) {
+            return 0;
+        }
+        *value = Value::String(REDACTED.into());
+        return 1;
+    };
+    match (value, *key) {
+        (Value::Object(map), "*") => map.values_mut().map(|v| redact_path(v, rest)).sum(),
+        (Value::Array(items), "*") => items.iter_mut().map(|v| redact_path(v, rest)).sum(),
+        (Value::Object(map), key) => map.get_mut(key).map_or(0, |v| redact_path(v, rest)),
+        (Value::Array(items), key) => key
+            .parse::<usize>()
+            .ok()
+            .and_then(|i| items.get_mut(i))
+            .map_or(0, |v| redact_path(v, rest)),
+        _ => 0,
+    }
+}
+
+/// Whether the digits in `candidate` pass the Luhn checksum.
+fn luhn(candidate: &str) -> bool {
+    let digits: Vec<u32> = candidate.chars().filter_map(|c| c.to_digit(10)).collect();
+    if !(13..=19).contains(&digits.len()) {
+        return false;
+    }
+    let sum: u32 = digits
+        .iter()
+        .rev()
+        .enumerate()
+        .map(|(i, &d)| match i % 2 {
+            0 => d,
+            _ if d * 2 > 9 => d * 2 - 9,
+            _ => d * 2,
+        })
+        .sum();
+    sum.is_multiple_of(10)
+}
+
+/// Per-slug redaction rules, shared across requests via AppState.
+pub type RedactionCache = SlugCache<Option<Arc<Redactor>>>;
+
+impl RedactionCache {
+    /// Look up an endpoint's rules, reading through to Postgres on a miss.
+    /// `None` when the endpoint doesn't redact. Lookup failures fail open
+    /// and are not cached.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Arc<Redactor>> {
+        self.get_or_load(slug, |_| async move {
+            let stored: Option<StoredConfig> =
+                load_json(pool, slug, "get_endpoint_redaction", "redaction").await?;
+            Some(stored.map(|stored| Arc::new(Redactor::new(stored))))
+        })
+        .await
+        .flatten()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn redactor(config: Value) -> Redactor {
+        Redactor::new(serde_json::from_value(config).unwrap())
+    }
+
+    #[test]
+    fn detectors_replace_matches() {
+        let r = redactor(json!({"detectors": ["email", "credit_card", "bearer_token"]}));
+        let mut fired = Fired::new();
+        let text = "from ada@example.co.uk, card 4242 4242 4242 4242, auth Bearer abc.def-123=";
+        assert_eq!(
+            r.redact_text(text, &mut fired).unwrap(),
+            "from [REDACTED], card [REDACTED], auth Bearer [REDACTED]"
+        );
+        assert_eq!(fired["email"], 1);
+        assert_eq!(fired["credit_card"], 1);
+        assert_eq!(fired["bearer_token"], 1);
+    }
+
+    #[test]
+    fn credit_cards_must_pass_luhn() {
+        let r = redactor(json!({"detectors": ["credit_card"]}));
+        let mut fired = Fired::new();
+        assert_eq!(r.redact_text("order 1234567890123456", &mut fired), None);
+        assert_eq!(
+            r.redact_text("card 5555-5555-5555-4444", &mut fired)
+                .unwrap(),
+            "card [REDACTED]"
+        );
+        assert!(luhn("4242424242424242"));
+        assert!(!luhn("4242424242424241"));
+        assert!(!luhn("424242"));
+    }
+
+    #[test]
+    fn json_bodies_are_redacted_by_value_and_path() {
+        let r = redactor(json!({
+            "detectors": ["email"],
+            "paths": ["customer.ssn", "items.*.card"],
+            "patterns": ["sk_live_[0-9a-zA-Z]+"]
+        }));
+        let mut fired = Fired::new();
+        let mut body = json!({
+            "customer": {"email": "ada@example.com", "ssn": 123456789},
+            "items": [{"card": "x"}, {"card": "y"}, {"sku": "a"}],
+            "key": "sk_live_abc123",
+            "note": "\"quoted\""
+        })
+        .to_string();
+        assert!(r.redact_body(&mut body, true, &mut fired));
+        let value: Value = serde_json::from_str(&body).unwrap();
+        assert_eq!(value["customer"]["email"], "[REDACTED]");
+        assert_eq!(value["customer"]["ssn"], "[REDACTED]");
+        assert_eq!(value["items"][1]["card"], "[REDACTED]");
+        assert_eq!(value["items"][2]["sku"], "a");
+        assert_eq!(value["key"], "[REDACTED]");
+        assert_eq!(value["note"], "\"quoted\"");
+        assert_eq!(fired["path:items.*.card"], 2);
+        assert_eq!(fired["pattern:sk_live_[0-9a-zA-Z]+"], 1);
+    }
+
+    #[test]
+    fn unchanged_bodies_keep_their_formatting() {
+        let r = redactor(json!({"detectors": ["email"], "paths": ["missing"]}));
+        let mut fired = Fired::new();
+        let mut body = "{ \"a\": 1 }".to_string();
+        assert!(!r.redact_body(&mut body, true, &mut fired));
+        assert_eq!(body, "{ \"a\": 1 }");
+        assert!(fired.is_empty());
+
+        // Text bodies ignore paths
+        let mut text = "contact ada@example.com".to_string();
+        assert!(r.redact_body(&mut text, true, &mut fired));
+        assert_eq!(text, "contact [REDACTED]");
+    }
+
+    #[test]
+    fn maps_and_unknown_rules() {
+        let r = redactor(json!({"detectors": ["bearer_token", "ssn"], "patterns": ["("]}));
+        assert_eq!(r.rules.len(), 1);
+        let mut fired = Fired::new();
+        let mut headers = HashMap::from([
+            ("authorization".to_string(), "Bearer t0ken".to_string()),
+            ("accept".to_string(), "*/*".to_string()),
+        ]);
+        assert!(r.redact_map(&mut headers, &mut fired));
+        assert_eq!(headers["authorization"], "Bearer [REDACTED]");
+        assert_eq!(headers["accept"], "*/*");
+    }
+
+    #[test]
+    fn default_headers_are_hidden_unless_allowed() {
+        let headers = || {
+            HashMap::from([
+                ("authorization".to_string(), "Bearer t0ken".to_string()),
+                ("cookie".to_string(), "session=abc".to_string()),
+                ("x-api-key".to_string(), "k".to_string()),
+                ("x-tenant-secret".to_string(), "s".to_string()),
+                ("accept".to_string(), "*/*".to_string()),
+            ])
+        };
+
+        let mut fired = Fired::new();
+        let mut plain = headers();
+        assert!(redact_headers(None, &[], &mut plain, &mut fired));
+        assert_eq!(plain["authorization"], REDACTED);
+        assert_eq!(plain["cookie"], REDACTED);
+        assert_eq!(plain["x-tenant-secret"], "s");
+        assert_eq!(fired["header:authorization"], 1);
+
+        let r =
+            redactor(json!({"headers": {"allow": ["Authorization"], "deny": ["x-tenant-secret"]}}));
+        let mut fired = Fired::new();
+        let mut configured = headers();
+        let sealed = vec!["x-api-key".to_string()];
+        assert!(redact_headers(
+            Some(&r),
+            &sealed,
+            &mut configured,
+            &mut fired
+        ));
+        assert_eq!(configured["authorization"], "Bearer t0ken");
+        assert_eq!(configured["cookie"], REDACTED);
+        assert_eq!(configured["x-api-key"], "k");
+        assert_eq!(configured["x-tenant-secret"], REDACTED);
+        assert_eq!(configured["accept"], "*/*");
+        assert!(!fired.contains_key("header:authorization"));
+    }
+}
@@ -0,0 +1,138 @@
+//! Traffic sampling: how much of a very busy endpoint's traffic is stored.
+//!
+//! `endpoints.sampling` sets `rate` (store one request in N) and/or
+//! `perMinute` (store at most M requests a minute); a request is stored when
+//! it passes both. Sampling happens per slug on each receiver instance, after
+//! the capture filter, so a fleet of N receivers stores up to N times
+//! `perMinute`.
+//!
+//! Sampled-out requests still go through `capture_webhook`, which answers
+//! them with the mock response like dry-run captures and counts them in
+//! `endpoint_sampling_stats` next to the stored ones, but doesn't store,
+//! charge, notify or forward them. Configs are cached per slug; a sampler's
+//! counters survive cache refreshes as long as its config is unchanged.
+
+use serde::Deserialize;
+use sqlx::PgPool;
+use std::sync::Arc;
+use std::sync::atomic::{AtomicU64, Ordering};
+
+use crate::slug_cache::{SlugCache, load_json};
+
+#[derive(Debug, Clone, PartialEq, Eq, Deserialize)]
+struct SamplingConfig {
+    #[serde(default)]
+    rate: Option<u64>,
+    #[serde(default, rename = "perMinute")]
+    per_minute: Option<u64>,
+}
+
+/// An endpoint's sampler and the counts it samples by.
+#[derive(Debug)]
+pub struct Sampler {
+    config: SamplingConfig,
+    /// Requests seen, for `rate`
+    seen: AtomicU64,
+    /// Unix minute and requests kept in it, for `perMinute`
+    window: std::sync::Mutex<(i64, u64)>,
+}
+
+impl Sampler {
+    fn new(config: SamplingConfig) -> Self {
+        Self {
+            config,
+            seen: AtomicU64::new(0),
+            window: std::sync::Mutex::new((0, 0)),
+        }
+    }
+
+    /// Whether a request received at `unix_secs` should be stored. Keeps the
+    /// first request of every `rate`, then caps what's kept per minute.
+    pub fn keep(&self, unix_secs: i64) -> bool {
+        if let Some(rate) = self.config.rate.filter(|rate| *rate > 1)
+            && self.seen.fetch_add(1, Ordering::Relaxed) % rate != 0
+        {
+            return false;
+        }
+        if let Some(per_minute) = self.config.per_minute {
+            let minute = unix_secs.div_euclid(60);
+            let mut window = self.window.lock().unwrap_or_else(|e| e.into_inner());
+            if window.0 != minute {
+                *window = (minute, 0);
+            }
+            if window.1 >= per_minute {
+                return false;
+            }
+            window.1 += 1;
+        }
+        true
+    }
+}
+
+/// Per-slug samplers, shared across requests via AppState. Configuration
+/// changes mark entries stale rather than dropping them, so a re-read config
+/// that is unchanged keeps its sampler and its counts.
+pub type SamplingCache = SlugCache<Option<Arc<Sampler>>>;
+
+impl SamplingCache {
+    /// Look up an endpoint's sampler, reading through to Postgres on a miss.
+    /// `None` when the endpoint stores everything. Lookup failures fail open
+    /// and are not cached.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Arc<Sampler>> {
+        self.get_or_load(slug, |previous| async move {
+            let config: Option<SamplingConfig> =
+                load_json(pool, slug, "get_endpoint_sampling", "sampling").await?;
+            Some(config.map(|config| match previous.flatten() {
+                // Keep counting where the previous sampler left off
+                Some(previous) if previous.config == config => previous,
+                _ => Arc::new(Sampler::new(config)),
+            }))
+        })
+        .await
+        .flatten()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn sampler(rate: Option<u64>, per_minute: Option<u64>) -> Sampler {
+        Sampler::new(SamplingConfig { rate, per_minute })
+    }
+
+    #[test]
+    fn rate_keeps_one_in_n() {
+        let s = sampler(Some(3), None);
+        let kept: Vec<bool> = (0..7).map(|_| s.keep(0)).collect();
+        assert_eq!(kept, [true, false, false, true, false, false, true]);
+    }
+
+    #[test]
+    fn per_minute_caps_each_minute() {
+        let s = sampler(None, Some(2));
+        assert!(s.keep(60));
+        assert!(s.keep(90));
+        assert!(!s.keep(119));
+        assert!(s.keep(120));
+    }
+
+    #[test]
+    fn rate_and_per_minute_combine() {
+        let s = sampler(Some(2), Some(1));
+        assert!(s.keep(0));
+        assert!(!s.keep(0));
+        // Passes the rate but the minute is used up
+        assert!(!s.keep(0));
+        assert!(!s.keep(60));
+        assert!(s.keep(60));
+    }
+
+    #[test]
+    fn config_parses_per_minute() {
+        let config: SamplingConfig =
+            serde_json::from_value(serde_json::json!({"perMinute": 600})).unwrap();
+        assert_eq!(config.rate, None);
+        assert_eq!(config.per_minute, Some(600));
+    }
+}
@@ -0,0 +1,253 @@
+//! HMAC signature verification for endpoints that hold their sender's secret.
+//!
+//! `endpoints.signature_verification` names a provider preset (Stripe,
+//! GitHub, Shopify) or a custom header and algorithm. Each request's
+//! signature is checked against the body as received, before transforms, and
+//! the verdict is stored as `requests.signature_valid`. With `reject` set, a
+//! request that fails is answered 401 `invalid_signature` and not stored.
+//!
+//! - Stripe: `stripe-signature: t=<unix>,v1=<hex>[,v1=...]`, HMAC-SHA256 of
+//!   `<t>.<body>`, timestamp within [`STRIPE_TOLERANCE_SECS`] of arrival
+//! - GitHub: `x-hub-signature-256: sha256=<hex>`
+//! - Shopify: `x-shopify-hmac-sha256: <base64>`
+//! - Custom: the configured header, hex or base64, with an optional
+//!   `<algorithm>=` prefix
+//!
+//! The config is cached per slug like the other endpoint caches and dropped
+//! on `endpoint_config` notifications.
+
+use axum::http::HeaderMap;
+use base64::Engine;
+use base64::engine::general_purpose::STANDARD as BASE64;
+use chrono::{DateTime, Utc};
+use ring::hmac;
+use serde::Deserialize;
+use sqlx::PgPool;
+use std::sync::Arc;
+
+use crate::slug_cache::{SlugCache, load_json};
+
+/// How far a Stripe signature's timestamp may be from the arrival time.
+const STRIPE_TOLERANCE_SECS: i64 = 300;
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Provider {
+    Stripe,
+    Github,
+    Shopify,
+    Custom,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Algorithm {
+    Sha1,
+    Sha256,
+    Sha512,
+}
+
+impl Algorithm {
+    fn hmac(self) -> hmac::Algorithm {
+        match self {
+            Self::Sha1 => hmac::HMAC_SHA1_FOR_LEGACY_USE_ONLY,
+            Self::Sha256 => hmac::HMAC_SHA256,
+            Self::Sha512 => hmac::HMAC_SHA512,
+        }
+    }
+
+    fn prefix(self) -> &'static str {
+        match self {
+            Self::Sha1 => "sha1=",
+            Self::Sha256 => "sha256=",
+            Self::Sha512 => "sha512=",
+        }
+    }
+
+    fn digest_len(self) -> usize {
+        match self {
+            Self::Sha1 => 20,
+            Self::Sha256 => 32,
+            Self::Sha512 => 64,
+        }
+    }
+}
+
+/// An endpoint's `signature_verification` config. The web API fills in the
+/// preset's algorithm and header, so every stored config names both.
+#[derive(Debug, Deserialize)]
+pub struct SignatureConfig {
+    pub provider: Provider,
+    pub algorithm: Algorithm,
+    pub header: String,
+    secret: String,
+    /// Refuse requests whose signature doesn't verify
+    #[serde(default)]
+    pub reject: bool,
+}
+
+impl SignatureConfig {
+    /// The shared secret, which also signs Zoom's handshake answer (see
+    /// `crate::handshake`).
+    pub fn secret(&self) -> &str {
+        &self.secret
+    }
+
+    /// Whether the request carries a valid signature for `body`. A missing
+    /// or unreadable header counts as invalid.
+    pub fn verify(&self, headers: &HeaderMap, body: &[u8], received_at: DateTime<Utc>) -> bool {
+        let Some(value) = headers.get(self.header.as_str()).and_then(|v| v.to_str().ok()) else {
+            return false;
+        };
+        let key = hmac::Key::new(self.algorithm.hmac(), self.secret.as_bytes());
+        match self.provider {
+            Provider::Stripe => verify_stripe(&key, value, body, received_at),
+            _ => decode_signature(value, self.algorithm)
+                .is_some_and(|tag| hmac::verify(&key, body, &tag).is_ok()),
+        }
+    }
+}
+
+/// Check any `v1` signature of a `t=...,v1=...` header over `<t>.<body>`.
+fn verify_stripe(key: &hmac::Key, value: &str, body: &[u8], received_at: DateTime<Utc>) -> bool {
+    let mut timestamp = None;
+    let mut signatures = Vec::new();
+    for part in value.split(',') {
+        match part.trim().split_once('=') {
+            Some(("t", t)) => timestamp = Some(t.trim()),
+            Some(("v1", sig)) => signatures.push(sig.trim()),
+            _ => {}
+        }
+    }
+    let Some(timestamp) = timestamp else {
+        return false;
+    };
+    let Ok(signed_at) = timestamp.parse::<i64>() else {
+        return false;
+    };
+    if (received_at.timestamp() - signed_at).abs() > STRIPE_TOLERANCE_SECS {
+        return false;
+    }
+
+    let mut payload = Vec::with_capacity(timestamp.len() + 1 + body.len());
+    payload.extend_from_slice(timestamp.as_bytes());
+    payload.push(b'.');
+    payload.extend_from_slice(body);
+    signatures
+        .iter()
+        .filter_map(|sig| hex::decode(sig).ok())
+        .any(|tag| hmac::verify(key, &payload, &tag).is_ok())
+}
+
+/// Decode a signature header value: an optional `<algorithm>=` prefix, then
+/// hex when it is the digest's hex length, otherwise base64.
+fn decode_signature(value: &str, algorithm: Algorithm) -> Option<Vec<u8>> {
+    let value = value.trim();
+    let prefix = algorithm.prefix();
+    let value = match value.get(..prefix.len()) {
+        Some(head) if head.eq_ignore_ascii_case(prefix) => &value[prefix.len()..],
+        _ => value,
+    };
+    if value.len() == algorithm.digest_len() * 2
+        && let Ok(tag) = hex::decode(value)
+    {
+        return Some(tag);
+    }
+    BASE64.decode(value).ok()
+}
+
+/// Per-slug signature configs, shared across requests via AppState.
+pub type SignatureCache = SlugCache<Option<Arc<SignatureConfig>>>;
+
+impl SignatureCache {
+    /// Look up an endpoint's config, reading through to Postgres on a miss.
+    /// `None` when the endpoint doesn't verify signatures. Lookup failures
+    /// fail open and are not cached.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Arc<SignatureConfig>> {
+        self.get_or_load(slug, |_| async move {
+            let config: Option<SignatureConfig> = load_json(
+                pool,
+                slug,
+                "get_endpoint_signature_verification",
+                "signature_verification",
+            )
+            .await?;
+            Some(config.map(Arc::new))
+        })
+        .await
+        .flatten()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::http::HeaderValue;
+    use chrono::TimeZone;
+
+    const SECRET: &str = "whsec_test";
+    const BODY: &[u8] = br#"{"id":"evt_1"}"#;
+
+    fn config(provider: &str, algorithm: &str, header: &str) -> SignatureConfig {
+        serde_json::from_value(serde_json::json!({
+            "provider": provider,
+            "algorithm": algorithm,
+            "header": header,
+            "secret": SECRET,
+        }))
+        .unwrap()
+    }
+
+    fn sign(algorithm: Algorithm, data: &[u8]) -> Vec<u8> {
+        let key = hmac::Key::new(algorithm.hmac(), SECRET.as_bytes());
+        hmac::sign(&key, data).as_ref().to_vec()
+    }
+
+    fn headers(name: &'static str, value: &str) -> HeaderMap {
+        let mut headers = HeaderMap::new();
+        headers.insert(name, HeaderValue::from_str(value).unwrap());
+        headers
+    }
+
+    fn at(secs: i64) -> DateTime<Utc> {
+        Utc.timestamp_opt(secs, 0).unwrap()
+    }
+
+    #[test]
+    fn stripe_checks_signature_and_timestamp() {
+        let config = config("stripe", "sha256", "stripe-signature");
+        let mut payload = b"1700000000.".to_vec();
+        payload.extend_from_slice(BODY);
+        let value = format!("t=1700000000,v1=00ff,v1={}", hex::encode(sign(Algorithm::Sha256, &payload)));
+        let headers = headers("stripe-signature", &value);
+
+        assert!(config.verify(&headers, BODY, at(1_700_000_060)));
+        assert!(!config.verify(&headers, b"{}", at(1_700_000_060)));
+        assert!(!config.verify(&headers, BODY, at(1_700_000_000 + STRIPE_TOLERANCE_SECS + 1)));
+        assert!(!config.verify(&HeaderMap::new(), BODY, at(1_700_000_060)));
+    }
+
+    #[test]
+    fn github_and_shopify_sign_the_body() {
+        let github = config("github", "sha256", "x-hub-signature-256");
+        let value = format!("sha256={}", hex::encode(sign(Algorithm::Sha256, BODY)));
+        assert!(github.verify(&headers("x-hub-signature-256", &value), BODY, Utc::now()));
+        assert!(!github.verify(&headers("x-hub-signature-256", "sha256=00"), BODY, Utc::now()));
+
+        let shopify = config("shopify", "sha256", "x-shopify-hmac-sha256");
+        let value = BASE64.encode(sign(Algorithm::Sha256, BODY));
+        assert!(shopify.verify(&headers("x-shopify-hmac-sha256", &value), BODY, Utc::now()));
+        assert!(!shopify.verify(&headers("x-shopify-hmac-sha256", &value), b"[]", Utc::now()));
+    }
+
+    #[test]
+    fn custom_takes_hex_or_base64_with_optional_prefix() {
+        let custom = config("custom", "sha512", "x-signature");
+        let tag = sign(Algorithm::Sha512, BODY);
+        for value in [hex::encode(&tag), format!("SHA512={}", hex::encode(&tag)), BASE64.encode(&tag)] {
+            assert!(custom.verify(&headers("x-signature", &value), BODY, Utc::now()), "{value}");
+        }
+        let sha1 = BASE64.encode(sign(Algorithm::Sha1, BODY));
+        assert!(!custom.verify(&headers("x-signature", &sha1), BODY, Utc::now()));
+    }
+}
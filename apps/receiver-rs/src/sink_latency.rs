@@ -0,0 +1,154 @@
+//! Delivery timings for function sinks.
+//!
+//! Every delivery the dispatcher attempts is timed in two stages: relay (the
+//! request was received until the final invocation attempt was sent, which
+//! covers capture, queueing and retry backoff) and destination (that attempt
+//! until the destination responded). Timings are buffered in memory and a
+//! background task reports them every [`FLUSH_INTERVAL`] in one call to
+//! `record_function_sink_deliveries`; `function_sink_latency()` turns them into
+//! percentiles per destination for the API.
+//!
+//! Timings are best effort: a batch that can't be written is kept for the next
+//! flush, and past [`MAX_PENDING`] the oldest are dropped.
+
+use chrono::{DateTime, Utc};
+use sqlx::PgPool;
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+/// How often pending timings are reported.
+const FLUSH_INTERVAL: Duration = Duration::from_secs(30);
+
+/// Timings held between flushes. Older ones are dropped beyond this.
+const MAX_PENDING: usize = 50_000;
+
+/// One timed delivery to a function sink.
+#[derive(Debug, Clone, PartialEq)]
+pub struct Delivery {
+    pub slug: String,
+    pub target: String,
+    pub received_at: DateTime<Utc>,
+    pub relay_ms: i32,
+    pub destination_ms: i32,
+    pub attempts: i32,
+    pub delivered: bool,
+}
+
+/// Milliseconds in `duration`, saturating at `i32::MAX` and never negative.
+pub fn millis(duration: chrono::Duration) -> i32 {
+    duration.num_milliseconds().clamp(0, i32::MAX as i64) as i32
+}
+
+/// Shared buffer of timings, held by the dispatcher. Cheap to clone.
+#[derive(Clone, Default)]
+pub struct DeliveryTimings {
+    pending: Arc<Mutex<Vec<Delivery>>>,
+}
+
+impl DeliveryTimings {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn record(&self, delivery: Delivery) {
+        let mut pending = self.pending.lock().unwrap_or_else(|e| e.into_inner());
+        if pending.len() >= MAX_PENDING {
+            pending.remove(0);
+        }
+        pending.push(delivery);
+    }
+
+    fn take(&self) -> Vec<Delivery> {
+        std::mem::take(&mut *self.pending.lock().unwrap_or_else(|e| e.into_inner()))
+    }
+
+    /// Put an unreported batch back ahead of anything recorded meanwhile.
+    fn restore(&self, mut batch: Vec<Delivery>) {
+        let mut pending = self.pending.lock().unwrap_or_else(|e| e.into_inner());
+        batch.append(&mut pending);
+        let excess = batch.len().saturating_sub(MAX_PENDING);
+        batch.drain(..excess);
+        *pending = batch;
+    }
+
+    /// Report everything pending in one call.
+    pub async fn flush(&self, pool: &PgPool) {
+        let batch = self.take();
+        if batch.is_empty() {
+            return;
+        }
+
+        let result: Result<i32, _> =
+            sqlx::query_scalar("SELECT record_function_sink_deliveries($1, $2, $3, $4, $5, $6, $7)")
+                .bind(batch.iter().map(|d| d.slug.clone()).collect::<Vec<_>>())
+                .bind(batch.iter().map(|d| d.target.clone()).collect::<Vec<_>>())
+                .bind(batch.iter().map(|d| d.received_at).collect::<Vec<_>>())
+                .bind(batch.iter().map(|d| d.relay_ms).collect::<Vec<_>>())
+                .bind(batch.iter().map(|d| d.destination_ms).collect::<Vec<_>>())
+                .bind(batch.iter().map(|d| d.attempts).collect::<Vec<_>>())
+                .bind(batch.iter().map(|d| d.delivered).collect::<Vec<_>>())
+                .fetch_one(pool)
+                .await;
+
+        if let Err(e) = result {
+            tracing::error!(count = batch.len(), error = %e, "failed to report function sink timings, will retry");
+            self.restore(batch);
+        }
+    }
+}
+
+/// Report pending timings every [`FLUSH_INTERVAL`] for the life of the process.
+pub fn spawn_flusher(timings: DeliveryTimings, pool: PgPool) {
+    tokio::spawn(async move {
+        let mut interval = tokio::time::interval(FLUSH_INTERVAL);
+        interval.set_missed_tick_behavior(tokio::time::MissedTickBehavior::Delay);
+        loop {
+            interval.tick().await;
+            timings.flush(&pool).await;
+        }
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn delivery(slug: &str) -> Delivery {
+        Delivery {
+            slug: slug.into(),
+            target: "arn:aws:lambda:us-east-1:123456789012:function:f".into(),
+            received_at: Utc::now(),
+            relay_ms: 3,
+            destination_ms: 40,
+            attempts: 1,
+            delivered: true,
+        }
+    }
+
+    #[test]
+    fn restore_keeps_order_and_cap() {
+        let timings = DeliveryTimings::new();
+        timings.record(delivery("a"));
+        let unreported = timings.take();
+
+        timings.record(delivery("b"));
+        timings.restore(unreported);
+
+        let slugs: Vec<_> = timings.take().into_iter().map(|d| d.slug).collect();
+        assert_eq!(slugs, ["a", "b"]);
+
+        let full: Vec<_> = (0..MAX_PENDING).map(|_| delivery("old")).collect();
+        timings.record(delivery("new"));
+        timings.restore(full);
+        let pending = timings.take();
+        assert_eq!(pending.len(), MAX_PENDING);
+        assert_eq!(pending.last().unwrap().slug, "new");
+    }
+
+    #[test]
+    fn millis_clamps() {
+        assert_eq!(millis(chrono::Duration::milliseconds(250)), 250);
+        assert_eq!(millis(chrono::Duration::milliseconds(-5)), 0);
+        assert_eq!(millis(chrono::Duration::days(365)), i32::MAX);
+    }
+}
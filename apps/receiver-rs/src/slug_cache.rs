@@ -0,0 +1,224 @@
+//! Per-slug cache of endpoint configuration read from Postgres.
+//!
+//! Every capture-time feature reads its slice of an endpoint's settings
+//! through a [`SlugCache`]: the first request for a slug runs the feature's
+//! `get_endpoint_*` function and later ones reuse the result until the
+//! configuration changes (see `config_events`) or, for anything missed,
+//! CACHE_TTL passes. Failed lookups are passed on without being cached, so
+//! the next request retries.
+//!
+//! Invalidated entries are kept, marked stale, until they are read again:
+//! the loader gets the previous value, so state tied to it (the sampling
+//! counters) can carry over a configuration that turns out unchanged.
+
+use serde::de::DeserializeOwned;
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex, MutexGuard};
+use std::time::{Duration, Instant};
+
+/// How long an entry is trusted before it is re-read.
+const CACHE_TTL: Duration = Duration::from_secs(30);
+
+/// Maximum cached keys before expired and stale entries are pruned.
+const CACHE_MAX: usize = 10_000;
+
+/// Cached per-endpoint state that configuration changes make stale.
+pub trait EndpointCache: Send + Sync {
+    /// Re-read a slug's state on its next use.
+    fn invalidate(&self, slug: &str);
+
+    /// Re-read every slug's state on its next use (after missing
+    /// notifications).
+    fn clear(&self);
+}
+
+struct Entry<T> {
+    fetched_at: Instant,
+    stale: bool,
+    value: T,
+}
+
+/// Values by slug (or, for custom domains, hostname), shared across requests
+/// via AppState. Cheap to clone. The lock is never held across an await.
+pub struct SlugCache<T> {
+    ttl: Duration,
+    entries: Arc<Mutex<HashMap<String, Entry<T>>>>,
+}
+
+impl<T> Clone for SlugCache<T> {
+    fn clone(&self) -> Self {
+        Self {
+            ttl: self.ttl,
+            entries: self.entries.clone(),
+        }
+    }
+}
+
+impl<T> Default for SlugCache<T> {
+    fn default() -> Self {
+        Self::with_ttl(CACHE_TTL)
+    }
+}
+
+impl<T> SlugCache<T> {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// A cache whose entries are trusted for `ttl` instead of CACHE_TTL.
+    pub fn with_ttl(ttl: Duration) -> Self {
+        Self {
+            ttl,
+            entries: Arc::default(),
+        }
+    }
+
+    fn entries(&self) -> MutexGuard<'_, HashMap<String, Entry<T>>> {
+        self.entries.lock().unwrap_or_else(|e| e.into_inner())
+    }
+
+    /// Re-read every entry whose value matches `stale` on its next use.
+    pub fn invalidate_where(&self, stale: impl Fn(&T) -> bool) {
+        for entry in self.entries().values_mut() {
+            if stale(&entry.value) {
+                entry.stale = true;
+            }
+        }
+    }
+}
+
+impl<T: Clone> SlugCache<T> {
+    /// The cached value for `key`, or the one `load` reads on a miss. `load`
+    /// gets the stale value, if any, and returns `None` when the lookup
+    /// failed.
+    pub async fn get_or_load<F, Fut>(&self, key: &str, load: F) -> Option<T>
+    where
+        F: FnOnce(Option<T>) -> Fut,
+        Fut: Future<Output = Option<T>>,
+    {
+        let previous = {
+            let map = self.entries();
+            match map.get(key) {
+                Some(entry) if !entry.stale && entry.fetched_at.elapsed() < self.ttl => {
+                    return Some(entry.value.clone());
+                }
+                Some(entry) => Some(entry.value.clone()),
+                None => None,
+            }
+        };
+
+        let value = load(previous).await?;
+
+        let now = Instant::now();
+        let mut map = self.entries();
+        if map.len() >= CACHE_MAX {
+            map.retain(|_, entry| !entry.stale && now.duration_since(entry.fetched_at) < self.ttl);
+        }
+        map.insert(
+            key.to_string(),
+            Entry {
+                fetched_at: now,
+                stale: false,
+                value: value.clone(),
+            },
+        );
+        Some(value)
+    }
+}
+
+impl<T: Send> EndpointCache for SlugCache<T> {
+    fn invalidate(&self, slug: &str) {
+        if let Some(entry) = self.entries().get_mut(slug) {
+            entry.stale = true;
+        }
+    }
+
+    fn clear(&self) {
+        for entry in self.entries().values_mut() {
+            entry.stale = true;
+        }
+    }
+}
+
+/// Read an endpoint's JSON setting with `SELECT <function>($1)` and parse it.
+/// `Some(None)` when the endpoint has none or `column` holds something that
+/// doesn't parse (logged); `None` when the lookup failed (logged).
+pub async fn load_json<C: DeserializeOwned>(
+    pool: &PgPool,
+    slug: &str,
+    function: &str,
+    column: &str,
+) -> Option<Option<C>> {
+    let query = format!("SELECT {function}($1)");
+    let result: Result<Option<serde_json::Value>, sqlx::Error> =
+        sqlx::query_scalar(&query).bind(slug).fetch_one(pool).await;
+
+    match result {
+        Ok(Some(value)) => match serde_json::from_value(value) {
+            Ok(config) => Some(Some(config)),
+            Err(e) => {
+                tracing::warn!(slug, error = %e, "invalid {column} configuration");
+                Some(None)
+            }
+        },
+        Ok(None) => Some(None),
+        Err(e) => {
+            tracing::error!(slug, error = %e, "{function} query failed");
+            None
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    async fn load(cache: &SlugCache<u32>, key: &str, value: Option<u32>) -> Option<u32> {
+        cache.get_or_load(key, |_| async move { value }).await
+    }
+
+    #[tokio::test]
+    async fn hits_skip_the_loader_and_failures_are_not_cached() {
+        let cache = SlugCache::new();
+        assert_eq!(load(&cache, "a", None).await, None);
+        assert_eq!(load(&cache, "a", Some(1)).await, Some(1));
+        assert_eq!(load(&cache, "a", Some(2)).await, Some(1));
+    }
+
+    #[tokio::test]
+    async fn invalidate_rereads_one_slug_with_its_previous_value() {
+        let cache = SlugCache::new();
+        load(&cache, "a", Some(1)).await;
+        load(&cache, "b", Some(1)).await;
+        cache.invalidate("a");
+
+        let previous = cache
+            .get_or_load("a", |previous| async move { previous.map(|n| n + 1) })
+            .await;
+        assert_eq!(previous, Some(2));
+        assert_eq!(load(&cache, "b", Some(5)).await, Some(1));
+    }
+
+    #[tokio::test]
+    async fn clear_and_expiry_reread_everything() {
+        let cache = SlugCache::new();
+        load(&cache, "a", Some(1)).await;
+        cache.clear();
+        assert_eq!(load(&cache, "a", Some(2)).await, Some(2));
+
+        let expiring = SlugCache::with_ttl(Duration::ZERO);
+        load(&expiring, "a", Some(1)).await;
+        assert_eq!(load(&expiring, "a", Some(2)).await, Some(2));
+    }
+
+    #[tokio::test]
+    async fn invalidate_where_matches_values() {
+        let cache = SlugCache::new();
+        load(&cache, "a.example.com", Some(1)).await;
+        load(&cache, "b.example.com", Some(2)).await;
+        cache.invalidate_where(|value| *value == 1);
+        assert_eq!(load(&cache, "a.example.com", Some(3)).await, Some(3));
+        assert_eq!(load(&cache, "b.example.com", Some(3)).await, Some(2));
+    }
+}
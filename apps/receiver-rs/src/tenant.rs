@@ -0,0 +1,262 @@
+//! Per-account limits on the receiver's shared capacity.
+//!
+//! All endpoints of one account share a budget of requests in flight (read
+//! but not yet answered, including mock delays), request body bytes those
+//! requests hold in memory, and function sink deliveries in progress, so one
+//! account's burst can't take the instance's capacity from everyone else. An
+//! unowned ephemeral endpoint is its own tenant.
+//!
+//! Requests over the first two limits get the 429 `too_many_in_flight`
+//! receiver error; sink deliveries over the third are dead-lettered like
+//! deliveries over the global `FUNCTION_SINK_CONCURRENCY`. A limit of 0
+//! disables it.
+//!
+//! The tenant of a slug comes from `get_endpoint_tenant()` and is cached for
+//! [`CACHE_TTL`]; it only changes when an ephemeral endpoint is claimed.
+//! Lookup failures fail open (no limits) and are not cached.
+
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+
+/// How long a slug's tenant is trusted.
+const CACHE_TTL: Duration = Duration::from_secs(60);
+
+/// Slugs cached before expired entries are swept.
+const CACHE_MAX: usize = 10_000;
+
+/// Per-tenant limits; 0 means unlimited.
+#[derive(Debug, Clone, Copy)]
+pub struct TenantLimits {
+    pub max_in_flight: usize,
+    pub max_in_flight_bytes: usize,
+    pub max_sink_deliveries: usize,
+}
+
+#[derive(Debug, Default, PartialEq)]
+struct Usage {
+    requests: usize,
+    bytes: usize,
+    sink_deliveries: usize,
+}
+
+impl Usage {
+    fn is_idle(&self) -> bool {
+        self.requests == 0 && self.bytes == 0 && self.sink_deliveries == 0
+    }
+}
+
+struct CachedTenant {
+    fetched_at: Instant,
+    tenant: Option<Arc<str>>,
+}
+
+/// Shared usage counters and tenant cache, stored in AppState. Cheap to clone.
+#[derive(Clone)]
+pub struct TenantLimiter {
+    limits: TenantLimits,
+    usage: Arc<std::sync::Mutex<HashMap<Arc<str>, Usage>>>,
+    tenants: Arc<tokio::sync::Mutex<HashMap<String, CachedTenant>>>,
+}
+
+impl TenantLimiter {
+    pub fn new(limits: TenantLimits) -> Self {
+        Self {
+            limits,
+            usage: Arc::default(),
+            tenants: Arc::default(),
+        }
+    }
+
+    /// The tenant a slug belongs to, reading through to Postgres on a miss.
+    /// `None` for unknown slugs and failed lookups.
+    pub async fn tenant(&self, pool: &PgPool, slug: &str) -> Option<Arc<str>> {
+        {
+            let map = self.tenants.lock().await;
+            if let Some(entry) = map.get(slug)
+                && entry.fetched_at.elapsed() < CACHE_TTL
+            {
+                return entry.tenant.clone();
+            }
+        }
+
+        let result: Result<Option<String>, sqlx::Error> =
+            sqlx::query_scalar("SELECT get_endpoint_tenant($1)")
+                .bind(slug)
+                .fetch_one(pool)
+                .await;
+
+        let tenant: Option<Arc<str>> = match result {
+            Ok(tenant) => tenant.map(Arc::from),
+            Err(e) => {
+                tracing::error!(slug, error = %e, "get_endpoint_tenant query failed");
+                return None;
+            }
+        };
+
+        let now = Instant::now();
+        let mut map = self.tenants.lock().await;
+        if map.len() >= CACHE_MAX {
+            map.retain(|_, entry| now.duration_since(entry.fetched_at) < CACHE_TTL);
+        }
+        map.insert(
+            slug.to_string(),
+            CachedTenant {
+                fetched_at: now,
+                tenant: tenant.clone(),
+            },
+        );
+        tenant
+    }
+
+    /// Apply `f` to a tenant's counters, dropping them once idle.
+    fn with_usage<R>(&self, tenant: &Arc<str>, f: impl FnOnce(&mut Usage) -> R) -> R {
+        let mut usage = self.usage.lock().unwrap_or_else(|e| e.into_inner());
+        let entry = usage.entry(tenant.clone()).or_default();
+        let result = f(entry);
+        if entry.is_idle() {
+            usage.remove(tenant);
+        }
+        result
+    }
+
+    /// Admit a request for `tenant`, or `None` when it has too many in flight.
+    pub fn admit(&self, tenant: Arc<str>) -> Option<RequestPermit> {
+        let max = self.limits.max_in_flight;
+        let admitted = self.with_usage(&tenant, |usage| {
+            if max > 0 && usage.requests >= max {
+                return false;
+            }
+            usage.requests += 1;
+            true
+        });
+        admitted.then(|| RequestPermit { limiter: self.clone(), tenant, bytes: 0 })
+    }
+
+    /// Start a function sink delivery for `tenant`, or `None` when it has too
+    /// many in progress.
+    pub fn start_sink_delivery(&self, tenant: Arc<str>) -> Option<SinkPermit> {
+        let max = self.limits.max_sink_deliveries;
+        let started = self.with_usage(&tenant, |usage| {
+            if max > 0 && usage.sink_deliveries >= max {
+                return false;
+            }
+            usage.sink_deliveries += 1;
+            true
+        });
+        started.then(|| SinkPermit { limiter: self.clone(), tenant })
+    }
+}
+
+/// A request in flight for a tenant. Released on drop.
+pub struct RequestPermit {
+    limiter: TenantLimiter,
+    tenant: Arc<str>,
+    bytes: usize,
+}
+
+impl RequestPermit {
+    /// The tenant this request counts against.
+    pub fn tenant(&self) -> &Arc<str> {
+        &self.tenant
+    }
+
+    /// Count `bytes` of body held by this request. Fails when the tenant
+    /// would go over its byte limit, unless nothing else of the tenant's is
+    /// held, so a single body under the receiver's own limit always fits.
+    pub fn hold_bytes(&mut self, bytes: usize) -> bool {
+        let max = self.limiter.limits.max_in_flight_bytes;
+        let held = self.limiter.with_usage(&self.tenant, |usage| {
+            if max > 0 && usage.bytes > 0 && usage.bytes + bytes > max {
+                return false;
+            }
+            usage.bytes += bytes;
+            true
+        });
+        if held {
+            self.bytes += bytes;
+        }
+        held
+    }
+}
+
+impl Drop for RequestPermit {
+    fn drop(&mut self) {
+        let bytes = self.bytes;
+        self.limiter.with_usage(&self.tenant, |usage| {
+            usage.requests = usage.requests.saturating_sub(1);
+            usage.bytes = usage.bytes.saturating_sub(bytes);
+        });
+    }
+}
+
+/// A function sink delivery in progress for a tenant. Released on drop.
+pub struct SinkPermit {
+    limiter: TenantLimiter,
+    tenant: Arc<str>,
+}
+
+impl Drop for SinkPermit {
+    fn drop(&mut self) {
+        self.limiter.with_usage(&self.tenant, |usage| {
+            usage.sink_deliveries = usage.sink_deliveries.saturating_sub(1);
+        });
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn limiter(max_in_flight: usize, max_in_flight_bytes: usize, max_sink_deliveries: usize) -> TenantLimiter {
+        TenantLimiter::new(TenantLimits { max_in_flight, max_in_flight_bytes, max_sink_deliveries })
+    }
+
+    fn usage(limiter: &TenantLimiter, tenant: &str) -> Option<(usize, usize, usize)> {
+        let usage = limiter.usage.lock().unwrap();
+        usage.get(tenant).map(|u| (u.requests, u.bytes, u.sink_deliveries))
+    }
+
+    #[test]
+    fn in_flight_limit_is_per_tenant() {
+        let limiter = limiter(2, 0, 0);
+        let a: Arc<str> = Arc::from("user-a");
+        let first = limiter.admit(a.clone()).unwrap();
+        let _second = limiter.admit(a.clone()).unwrap();
+        assert!(limiter.admit(a.clone()).is_none());
+        assert!(limiter.admit(Arc::from("user-b")).is_some());
+
+        drop(first);
+        assert!(limiter.admit(a).is_some());
+    }
+
+    #[test]
+    fn byte_limit_admits_one_large_body() {
+        let limiter = limiter(0, 100, 0);
+        let tenant: Arc<str> = Arc::from("user-a");
+        let mut big = limiter.admit(tenant.clone()).unwrap();
+        assert!(big.hold_bytes(500));
+
+        let mut next = limiter.admit(tenant.clone()).unwrap();
+        assert!(!next.hold_bytes(1));
+        drop(big);
+        assert!(next.hold_bytes(60));
+        assert!(next.hold_bytes(40));
+        assert_eq!(usage(&limiter, "user-a"), Some((1, 100, 0)));
+    }
+
+    #[test]
+    fn released_permits_leave_no_counters() {
+        let limiter = limiter(1, 10, 1);
+        let tenant: Arc<str> = Arc::from("endpoint:e1");
+        {
+            let mut request = limiter.admit(tenant.clone()).unwrap();
+            request.hold_bytes(5);
+            let _sink = limiter.start_sink_delivery(tenant.clone()).unwrap();
+            assert!(limiter.start_sink_delivery(tenant.clone()).is_none());
+            assert_eq!(usage(&limiter, "endpoint:e1"), Some((1, 5, 1)));
+        }
+        assert_eq!(usage(&limiter, "endpoint:e1"), None);
+    }
+}
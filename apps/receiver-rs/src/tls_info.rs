@@ -0,0 +1,71 @@
+//! What a sender negotiated on the receiver's own TLS listeners.
+//!
+//! The mTLS listener and custom domains end TLS in the receiver, so the
+//! handshake is visible: every request on such a connection carries a
+//! [`TlsInfo`] and captures store it in `requests.tls`. Requests through the
+//! proxy have none, since the proxy ended their TLS.
+
+use rustls::{CipherSuite, ProtocolVersion, ServerConnection};
+use serde::Serialize;
+
+/// The negotiated parameters of one TLS connection.
+#[derive(Debug, Clone, Serialize)]
+pub struct TlsInfo {
+    /// `TLSv1.2` or `TLSv1.3`
+    pub version: String,
+    /// IANA name, e.g. `TLS_AES_128_GCM_SHA256`
+    pub cipher: String,
+    /// Hostname the client asked for (SNI)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub sni: Option<String>,
+    /// Protocol picked by ALPN (`h2`, `http/1.1`)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub alpn: Option<String>,
+}
+
+impl TlsInfo {
+    /// Read the parameters of a completed handshake.
+    pub fn from_connection(conn: &ServerConnection) -> Option<Self> {
+        Some(Self {
+            version: version_name(conn.protocol_version()?),
+            cipher: cipher_name(conn.negotiated_cipher_suite()?.suite()),
+            sni: conn.server_name().map(str::to_string),
+            alpn: conn
+                .alpn_protocol()
+                .map(|protocol| String::from_utf8_lossy(protocol).into_owned()),
+        })
+    }
+}
+
+fn version_name(version: ProtocolVersion) -> String {
+    match version {
+        ProtocolVersion::TLSv1_2 => "TLSv1.2".to_string(),
+        ProtocolVersion::TLSv1_3 => "TLSv1.3".to_string(),
+        other => format!("{other:?}"),
+    }
+}
+
+/// rustls names TLS 1.3 suites `TLS13_*`; the IANA names drop the 13.
+fn cipher_name(suite: CipherSuite) -> String {
+    let name = suite.as_str().map_or_else(|| format!("{suite:?}"), str::to_string);
+    match name.strip_prefix("TLS13_") {
+        Some(rest) => format!("TLS_{rest}"),
+        None => name,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn names_follow_iana() {
+        assert_eq!(version_name(ProtocolVersion::TLSv1_3), "TLSv1.3");
+        assert_eq!(version_name(ProtocolVersion::TLSv1_2), "TLSv1.2");
+        assert_eq!(cipher_name(CipherSuite::TLS13_AES_128_GCM_SHA256), "TLS_AES_128_GCM_SHA256");
+        assert_eq!(
+            cipher_name(CipherSuite::TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384),
+            "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"
+        );
+    }
+}
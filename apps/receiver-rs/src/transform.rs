@@ -0,0 +1,297 @@
+//! Capture-time transforms: an ordered, per-endpoint list of rewrites applied
+//! to a request before it is stored or handed to notifications and sinks, so
+//! noisy provider payloads are normalized once at ingest.
+//!
+//! Body transforms only run on JSON bodies; anything that doesn't parse as
+//! JSON (or isn't valid UTF-8) is stored untouched. Header transforms always
+//! run. Transforms never fail a capture — a path that doesn't exist is a no-op.
+//!
+//! Paths are dot-separated object keys; numeric segments index into arrays
+//! (`data.items.0.id`). An empty path addresses the whole body.
+
+use serde::Deserialize;
+use serde_json::Value;
+use sqlx::PgPool;
+use std::collections::HashMap;
+use std::sync::Arc;
+
+use crate::slug_cache::{SlugCache, load_json};
+
+/// One step of an endpoint's pipeline, as stored in `endpoints.body_transforms`.
+#[derive(Debug, Clone, PartialEq, Deserialize)]
+#[serde(tag = "type", rename_all = "snake_case")]
+pub enum Transform {
+    /// Move the value at `from` to `to`, creating intermediate objects.
+    MapField { from: String, to: String },
+    /// Rename a request header (case-insensitive).
+    RenameHeader { from: String, to: String },
+    /// Remove each listed path from the body.
+    StripFields { paths: Vec<String> },
+    /// Flatten nested arrays at `path` into a single array.
+    FlattenArray {
+        #[serde(default)]
+        path: String,
+    },
+}
+
+/// Apply `transforms` in order. `body` is rewritten (compact JSON) only when a
+/// body transform ran against a JSON body.
+pub fn apply(transforms: &[Transform], headers: &mut HashMap<String, String>, body: &mut String) {
+    let mut json: Option<Value> = None;
+    let mut body_is_json = true;
+
+    for transform in transforms {
+        if let Transform::RenameHeader { from, to } = transform {
+            rename_header(headers, from, to);
+            continue;
+        }
+
+        if !body_is_json {
+            continue;
+        }
+        if json.is_none() {
+            match serde_json::from_str::<Value>(body) {
+                Ok(v) => json = Some(v),
+                Err(_) => {
+                    body_is_json = false;
+                    continue;
+                }
+            }
+        }
+        let Some(value) = json.as_mut() else { continue };
+
+        match transform {
+            Transform::MapField { from, to } => {
+                if let Some(v) = take_path(value, from) {
+                    set_path(value, to, v);
+                }
+            }
+            Transform::StripFields { paths } => {
+                for path in paths {
+                    take_path(value, path);
+                }
+            }
+            Transform::FlattenArray { path } => {
+                if let Some(Value::Array(items)) = get_path_mut(value, path) {
+                    let mut flat = Vec::with_capacity(items.len());
+                    flatten_into(std::mem::take(items), &mut flat);
+                    *items = flat;
+                }
+            }
+            Transform::RenameHeader { .. } => unreachable!(),
+        }
+    }
+
+    if let Some(value) = json
+        && let Ok(s) = serde_json::to_string(&value)
+    {
+        *body = s;
+    }
+}
+
+fn rename_header(headers: &mut HashMap<String, String>, from: &str, to: &str) {
+    let from = from.to_ascii_lowercase();
+    let to = to.to_ascii_lowercase();
+    if from == to || to.is_empty() {
+        return;
+    }
+    if let Some(v) = headers.remove(&from) {
+        headers.insert(to, v);
+    }
+}
+
+fn segments(path: &str) -> Vec<&str> {
+    if path.is_empty() {
+        Vec::new()
+    } else {
+        path.split('.').collect()
+    }
+}
+
+fn child_mut<'a>(value: &'a mut Value, key: &str) -> Option<&'a mut Value> {
+    match value {
+        Value::Object(map) => map.get_mut(key),
+        Value::Array(items) => key.parse::<usize>().ok().and_then(|i| items.get_mut(i)),
+        _ => None,
+    }
+}
+
+/// Read the value at `path` (same syntax as the transform paths).
+pub fn lookup<'a>(value: &'a Value, path: &str) -> Option<&'a Value> {
+    segments(path).into_iter().try_fold(value, |current, key| match current {
+        Value::Object(map) => map.get(key),
+        Value::Array(items) => key.parse::<usize>().ok().and_then(|i| items.get(i)),
+        _ => None,
+    })
+}
+
+fn get_path_mut<'a>(value: &'a mut Value, path: &str) -> Option<&'a mut Value> {
+    segments(path)
+        .into_iter()
+        .try_fold(value, |current, key| child_mut(current, key))
+}
+
+/// Remove and return the value at `path`. The root itself can't be taken.
+fn take_path(value: &mut Value, path: &str) -> Option<Value> {
+    let (parent, last) = match path.rsplit_once('.') {
+        Some((parent, last)) => (get_path_mut(value, parent)?, last),
+        None if path.is_empty() => return None,
+        None => (value, path),
+    };
+    match parent {
+        Value::Object(map) => map.remove(last),
+        Value::Array(items) => {
+            let i = last.parse::<usize>().ok()?;
+            (i < items.len()).then(|| items.remove(i))
+        }
+        _ => None,
+    }
+}
+
+/// Write `new` at `path`, creating objects for missing keys. Writes through a
+/// scalar or past the end of an array are dropped.
+fn set_path(value: &mut Value, path: &str, new: Value) {
+    let segs = segments(path);
+    let Some((last, parents)) = segs.split_last() else {
+        *value = new;
+        return;
+    };
+
+    let mut current = value;
+    for key in parents {
+        if let Value::Object(map) = current {
+            current = map
+                .entry((*key).to_string())
+                .or_insert_with(|| Value::Object(serde_json::Map::new()));
+        } else if let Some(next) = child_mut(current, key) {
+            current = next;
+        } else {
+            return;
+        }
+    }
+
+    match current {
+        Value::Object(map) => {
+            map.insert((*last).to_string(), new);
+        }
+        Value::Array(items) => {
+            if let Some(slot) = last.parse::<usize>().ok().and_then(|i| items.get_mut(i)) {
+                *slot = new;
+            }
+        }
+        _ => {}
+    }
+}
+
+fn flatten_into(items: Vec<Value>, out: &mut Vec<Value>) {
+    for item in items {
+        match item {
+            Value::Array(inner) => flatten_into(inner, out),
+            other => out.push(other),
+        }
+    }
+}
+
+/// Per-slug transform lists, shared across requests via AppState.
+pub type TransformCache = SlugCache<Option<Arc<Vec<Transform>>>>;
+
+impl TransformCache {
+    /// Look up an endpoint's transforms, reading through to Postgres on a miss.
+    /// Lookup failures fail open (no transforms) and are not cached.
+    pub async fn get(&self, pool: &PgPool, slug: &str) -> Option<Arc<Vec<Transform>>> {
+        self.get_or_load(slug, |_| async move {
+            let transforms: Option<Vec<Transform>> =
+                load_json(pool, slug, "get_endpoint_transforms", "body_transforms").await?;
+            Some(transforms.filter(|list| !list.is_empty()).map(Arc::new))
+        })
+        .await
+        .flatten()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn run(transforms: serde_json::Value, body: &str) -> (HashMap<String, String>, String) {
+        let transforms: Vec<Transform> = serde_json::from_value(transforms).unwrap();
+        let mut headers = HashMap::from([("x-stripe-signature".to_string(), "sig".to_string())]);
+        let mut body = body.to_string();
+        apply(&transforms, &mut headers, &mut body);
+        (headers, body)
+    }
+
+    #[test]
+    fn map_field_moves_value_and_creates_parents() {
+        let (_, body) = run(
+            serde_json::json!([{"type": "map_field", "from": "data.object.id", "to": "meta.id"}]),
+            r#"{"data":{"object":{"id":"ch_1","amount":5}}}"#,
+        );
+        let v: Value = serde_json::from_str(&body).unwrap();
+        assert_eq!(v["meta"]["id"], "ch_1");
+        assert!(v["data"]["object"].get("id").is_none());
+        assert_eq!(v["data"]["object"]["amount"], 5);
+    }
+
+    #[test]
+    fn strip_fields_supports_array_indexes() {
+        let (_, body) = run(
+            serde_json::json!([{"type": "strip_fields", "paths": ["livemode", "items.0.secret", "missing.path"]}]),
+            r#"{"livemode":false,"items":[{"id":1,"secret":"x"},{"id":2}]}"#,
+        );
+        assert_eq!(body, r#"{"items":[{"id":1},{"id":2}]}"#);
+    }
+
+    #[test]
+    fn flatten_array_nested_and_root() {
+        let (_, body) = run(
+            serde_json::json!([{"type": "flatten_array", "path": "events"}]),
+            r#"{"events":[[1,2],[3,[4]],5]}"#,
+        );
+        assert_eq!(body, r#"{"events":[1,2,3,4,5]}"#);
+
+        let (_, body) = run(serde_json::json!([{"type": "flatten_array"}]), "[[1],[2,3]]");
+        assert_eq!(body, "[1,2,3]");
+    }
+
+    #[test]
+    fn rename_header_is_case_insensitive() {
+        let (headers, _) = run(
+            serde_json::json!([{"type": "rename_header", "from": "X-Stripe-Signature", "to": "X-Signature"}]),
+            "",
+        );
+        assert_eq!(headers.get("x-signature").map(String::as_str), Some("sig"));
+        assert!(!headers.contains_key("x-stripe-signature"));
+    }
+
+    #[test]
+    fn non_json_body_is_left_untouched() {
+        let (headers, body) = run(
+            serde_json::json!([
+                {"type": "strip_fields", "paths": ["a"]},
+                {"type": "rename_header", "from": "x-stripe-signature", "to": "x-sig"}
+            ]),
+            "a=1&b=2",
+        );
+        assert_eq!(body, "a=1&b=2");
+        assert!(headers.contains_key("x-sig"));
+    }
+
+    #[test]
+    fn transforms_run_in_order() {
+        let (_, body) = run(
+            serde_json::json!([
+                {"type": "map_field", "from": "a", "to": "b"},
+                {"type": "strip_fields", "paths": ["b.drop"]}
+            ]),
+            r#"{"a":{"keep":1,"drop":2}}"#,
+        );
+        assert_eq!(body, r#"{"b":{"keep":1}}"#);
+    }
+
+    #[test]
+    fn unknown_transform_type_is_rejected() {
+        let parsed = serde_json::from_value::<Vec<Transform>>(serde_json::json!([{"type": "eval"}]));
+        assert!(parsed.is_err());
+    }
+}
@@ -0,0 +1,658 @@
+//! Configuration validation and startup self-test.
+//!
+//! `webhooks-receiver --validate` checks the environment, connects to every
+//! configured backend (Postgres and its schema, Redis, AWS for function
+//! sinks) and prints one line per check, exiting non-zero if any failed, so a
+//! misconfigured deployment is caught before it takes traffic rather than at
+//! the first webhook. The environment and schema checks also run at every
+//! boot, and the receiver refuses to start when one of them fails.
+//!
+//! The receiver is configured only through environment variables and
+//! terminates no TLS itself; the TLS checks cover its outbound Postgres
+//! (`sslmode`) and Redis (`rediss://`) connections.
+
+use sqlx::PgPool;
+use sqlx::postgres::PgPoolOptions;
+use std::time::Duration;
+
+/// Functions the receiver calls. A missing one means migrations are behind.
+const REQUIRED_FUNCTIONS: &[&str] = &[
+    "capture_webhook",
+    "get_endpoint_transforms",
+    "get_endpoint_header_encryption",
+    "get_endpoint_client_ca",
+    "get_custom_domain_slug",
+    "get_custom_domain_certificate",
+    "save_custom_domain_certificate",
+    "get_acme_account",
+    "save_acme_account",
+    "record_capture_failures",
+    "record_function_sink_failure",
+    "record_function_sink_deliveries",
+    "record_capture_response",
+    "get_endpoint_tenant",
+    "get_endpoint_max_body_size",
+];
+
+/// How long each backend gets to answer.
+const CONNECT_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Shortest secret that doesn't draw a warning.
+const MIN_SECRET_LENGTH: usize = 32;
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Status {
+    Ok,
+    Warn,
+    Fail,
+}
+
+/// Outcome of one check.
+#[derive(Debug)]
+pub struct Check {
+    pub name: &'static str,
+    pub status: Status,
+    pub detail: String,
+}
+
+impl Check {
+    fn ok(name: &'static str, detail: impl Into<String>) -> Self {
+        Self { name, status: Status::Ok, detail: detail.into() }
+    }
+
+    fn warn(name: &'static str, detail: impl Into<String>) -> Self {
+        Self { name, status: Status::Warn, detail: detail.into() }
+    }
+
+    fn fail(name: &'static str, detail: impl Into<String>) -> Self {
+        Self { name, status: Status::Fail, detail: detail.into() }
+    }
+}
+
+/// Validate the environment without touching the network. `var` looks up a
+/// variable; empty values count as unset, as in `Config::from_env`.
+pub fn check_env(var: impl Fn(&str) -> Option<String>) -> Vec<Check> {
+    let get = |name: &str| var(name).filter(|v| !v.is_empty());
+    let mut checks = Vec::new();
+
+    match get("DATABASE_URL") {
+        None => checks.push(Check::fail("DATABASE_URL", "not set; the receiver cannot start without it")),
+        Some(url) => checks.extend(check_database_url(&url)),
+    }
+
+    match get("CAPTURE_SHARED_SECRET") {
+        None => checks.push(Check::fail(
+            "CAPTURE_SHARED_SECRET",
+            "not set; the receiver cannot start without it",
+        )),
+        Some(secret) if secret.len() < MIN_SECRET_LENGTH => checks.push(Check::warn(
+            "CAPTURE_SHARED_SECRET",
+            format!("only {} characters; use at least {MIN_SECRET_LENGTH}", secret.len()),
+        )),
+        Some(_) => checks.push(Check::ok("CAPTURE_SHARED_SECRET", "set")),
+    }
+
+    check_number::<u16>(&mut checks, get("PORT"), "PORT", |&p| p > 0, "a port number (1-65535)");
+    check_number::<u16>(&mut checks, get("GRPC_PORT"), "GRPC_PORT", |&p| p > 0, "a port number (1-65535)");
+    if let Some(grpc_port) = get("GRPC_PORT").and_then(|v| v.parse::<u16>().ok())
+        && grpc_port == get("PORT").and_then(|v| v.parse::<u16>().ok()).unwrap_or(3001)
+    {
+        checks.push(Check::fail("GRPC_PORT", format!("{grpc_port} is also the HTTP PORT")));
+    }
+    check_number::<u16>(&mut checks, get("MTLS_PORT"), "MTLS_PORT", |&p| p > 0, "a port number (1-65535)");
+    if let Some(mtls_port) = get("MTLS_PORT").and_then(|v| v.parse::<u16>().ok()) {
+        if mtls_port == get("PORT").and_then(|v| v.parse::<u16>().ok()).unwrap_or(3001)
+            || Some(mtls_port) == get("GRPC_PORT").and_then(|v| v.parse::<u16>().ok())
+        {
+            checks.push(Check::fail("MTLS_PORT", format!("{mtls_port} is already used by another listener")));
+        }
+        match (get("MTLS_CERT_FILE"), get("MTLS_KEY_FILE")) {
+            (Some(cert), Some(key)) => match crate::mtls::server_config(&cert, &key) {
+                Ok(_) => checks.push(Check::ok("MTLS_PORT", format!("serving mTLS on {mtls_port}"))),
+                Err(e) => checks.push(Check::fail("MTLS_CERT_FILE", e)),
+            },
+            _ => checks.push(Check::fail(
+                "MTLS_PORT",
+                "set MTLS_CERT_FILE and MTLS_KEY_FILE to start the mTLS listener",
+            )),
+        }
+    }
+    check_number::<u16>(
+        &mut checks,
+        get("CUSTOM_DOMAIN_PORT"),
+        "CUSTOM_DOMAIN_PORT",
+        |&p| p > 0,
+        "a port number (1-65535)",
+    );
+    if let Some(domain_port) = get("CUSTOM_DOMAIN_PORT").and_then(|v| v.parse::<u16>().ok()) {
+        if domain_port == get("PORT").and_then(|v| v.parse::<u16>().ok()).unwrap_or(3001)
+            || [get("GRPC_PORT"), get("MTLS_PORT")]
+                .into_iter()
+                .any(|port| port.and_then(|v| v.parse::<u16>().ok()) == Some(domain_port))
+        {
+            checks.push(Check::fail(
+                "CUSTOM_DOMAIN_PORT",
+                format!("{domain_port} is already used by another listener"),
+            ));
+        }
+        match get("ACME_DIRECTORY_URL").map(|url| check_url(&url, &["https"])) {
+            Some(Err(e)) => checks.push(Check::fail("ACME_DIRECTORY_URL", e)),
+            Some(Ok(_)) => checks.push(Check::ok("ACME_DIRECTORY_URL", "set")),
+            None => checks.push(Check::ok("ACME_DIRECTORY_URL", "using Let's Encrypt")),
+        }
+        if get("ACME_CONTACT_EMAIL").is_none() {
+            checks.push(Check::warn(
+                "ACME_CONTACT_EMAIL",
+                "not set; the CA can't warn about certificates that fail to renew",
+            ));
+        }
+    }
+    if let Some(host) = get("SUBDOMAIN_HOST") {
+        let host = host.trim().trim_matches('.');
+        if host.is_empty() || !host.bytes().all(|b| b.is_ascii_alphanumeric() || b == b'-' || b == b'.') {
+            checks.push(Check::fail("SUBDOMAIN_HOST", "expected a bare hostname such as in.webhooks.cc"));
+        } else {
+            checks.push(Check::ok("SUBDOMAIN_HOST", format!("routing {{slug}}.{host}")));
+        }
+    }
+    check_number::<u32>(&mut checks, get("PG_POOL_MIN"), "PG_POOL_MIN", |_| true, "a whole number");
+    check_number::<u32>(&mut checks, get("PG_POOL_MAX"), "PG_POOL_MAX", |&n| n > 0, "a number above 0");
+    let pool_min = get("PG_POOL_MIN").and_then(|v| v.parse::<u32>().ok()).unwrap_or(5);
+    let pool_max = get("PG_POOL_MAX").and_then(|v| v.parse::<u32>().ok()).unwrap_or(20);
+    if pool_min > pool_max {
+        checks.push(Check::fail(
+            "PG_POOL_MIN",
+            format!("{pool_min} is above PG_POOL_MAX ({pool_max})"),
+        ));
+    }
+    check_number::<usize>(
+        &mut checks,
+        get("FUNCTION_SINK_CONCURRENCY"),
+        "FUNCTION_SINK_CONCURRENCY",
+        |&n| n > 0,
+        "a number above 0",
+    );
+    check_number::<usize>(&mut checks, get("MAX_PATH_LENGTH"), "MAX_PATH_LENGTH", |&n| n > 0, "a number above 0");
+    check_number::<u8>(
+        &mut checks,
+        get("MIRROR_SAMPLE_PERCENT"),
+        "MIRROR_SAMPLE_PERCENT",
+        |&n| n <= 100,
+        "a percentage (0-100)",
+    );
+    check_number::<usize>(&mut checks, get("MIRROR_CONCURRENCY"), "MIRROR_CONCURRENCY", |&n| n > 0, "a number above 0");
+    for name in ["GEOIP_DB_PATH", "GEOIP_ASN_DB_PATH"] {
+        if let Some(path) = get(name) {
+            match crate::geoip::database_type(&path) {
+                Ok(database_type) => checks.push(Check::ok(name, database_type)),
+                Err(e) => checks.push(Check::fail(name, format!("{path}: {e}"))),
+            }
+        }
+    }
+    check_number::<usize>(
+        &mut checks,
+        get("MULTIPART_INLINE_BYTES"),
+        "MULTIPART_INLINE_BYTES",
+        |_| true,
+        "a number of bytes",
+    );
+    check_number::<usize>(
+        &mut checks,
+        get("OBJECT_STORAGE_MAX_BODY_BYTES"),
+        "OBJECT_STORAGE_MAX_BODY_BYTES",
+        |&n| n > crate::MAX_BODY_SIZE,
+        "a number of bytes above the 1MB inline limit",
+    );
+
+    for name in ["TENANT_MAX_IN_FLIGHT", "TENANT_MAX_IN_FLIGHT_BYTES", "TENANT_MAX_SINK_DELIVERIES"] {
+        check_number::<usize>(&mut checks, get(name), name, |_| true, "a whole number (0 disables the limit)");
+    }
+
+    for name in [
+        "APPSIGNAL_COLLECTOR_URL",
+        "NOTIFY_PROXY_URL",
+        "MIRROR_URL",
+        "OBJECT_STORAGE_URL",
+        "LIFECYCLE_EVENTS_URL",
+    ] {
+        if let Some(value) = get(name)
+            && let Err(e) = check_url(&value, &["http", "https"])
+        {
+            checks.push(Check::fail(name, e));
+        }
+    }
+
+    match (get("NOTIFY_PROXY_URL"), get("NOTIFY_SECRET")) {
+        (Some(_), None) => checks.push(Check::warn(
+            "NOTIFY_SECRET",
+            "not set; the notify proxy will reject capture failure notifications",
+        )),
+        (None, Some(_)) => checks.push(Check::warn(
+            "NOTIFY_SECRET",
+            "set without NOTIFY_PROXY_URL; it is unused",
+        )),
+        _ => {}
+    }
+
+    if get("LIFECYCLE_EVENTS_SECRET").is_some() && get("LIFECYCLE_EVENTS_URL").is_none() {
+        checks.push(Check::warn(
+            "LIFECYCLE_EVENTS_SECRET",
+            "set without LIFECYCLE_EVENTS_URL; it is unused",
+        ));
+    }
+
+    if let Some(url) = get("REDIS_URL") {
+        match check_url(&url, &["redis", "rediss"]) {
+            Err(e) => checks.push(Check::fail("REDIS_URL", e)),
+            Ok(parsed) if parsed.scheme() == "redis" && !is_local(parsed.host_str()) => {
+                checks.push(Check::warn(
+                    "REDIS_URL",
+                    "connection to a remote host is not encrypted; use rediss://",
+                ))
+            }
+            Ok(_) => {}
+        }
+    }
+
+    match (get("AWS_ACCESS_KEY_ID"), get("AWS_SECRET_ACCESS_KEY")) {
+        (Some(_), None) | (None, Some(_)) => checks.push(Check::fail(
+            "AWS credentials",
+            "set both AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to enable function sinks",
+        )),
+        (None, None) if get("AWS_SESSION_TOKEN").is_some() => checks.push(Check::warn(
+            "AWS credentials",
+            "AWS_SESSION_TOKEN is set without an access key; function sinks are disabled",
+        )),
+        _ => {}
+    }
+
+    let object_storage = [
+        "OBJECT_STORAGE_URL",
+        "OBJECT_STORAGE_BUCKET",
+        "OBJECT_STORAGE_ACCESS_KEY_ID",
+        "OBJECT_STORAGE_SECRET_ACCESS_KEY",
+    ];
+    let missing: Vec<&str> = object_storage.into_iter().filter(|name| get(name).is_none()).collect();
+    if !missing.is_empty() && missing.len() < object_storage.len() {
+        checks.push(Check::fail(
+            "object storage",
+            format!("{} not set; large bodies will be rejected", missing.join(", ")),
+        ));
+    }
+
+    if let Some(key) = get("HEADER_ENCRYPTION_KEY")
+        && let Err(e) = crate::header_crypt::HeaderCipher::from_hex(&key)
+    {
+        checks.push(Check::fail("HEADER_ENCRYPTION_KEY", e));
+    }
+
+    if let Some(secret) = get("QUOTA_BYPASS_SECRET")
+        && secret.len() < MIN_SECRET_LENGTH
+    {
+        checks.push(Check::warn(
+            "QUOTA_BYPASS_SECRET",
+            format!("only {} characters; use at least {MIN_SECRET_LENGTH}", secret.len()),
+        ));
+    }
+
+    checks
+}
+
+/// Scheme, host and TLS mode of `DATABASE_URL`.
+fn check_database_url(url: &str) -> Vec<Check> {
+    let parsed = match check_url(url, &["postgres", "postgresql"]) {
+        Ok(parsed) => parsed,
+        Err(e) => return vec![Check::fail("DATABASE_URL", e)],
+    };
+    let host = parsed.host_str().unwrap_or("localhost").to_string();
+    let mut checks = vec![Check::ok("DATABASE_URL", format!("postgres on {host}"))];
+
+    let sslmode = parsed
+        .query_pairs()
+        .find(|(k, _)| k == "sslmode")
+        .map(|(_, v)| v.into_owned());
+    checks.push(match sslmode.as_deref() {
+        None => Check::ok("database TLS", "sslmode=prefer (default)"),
+        Some(mode @ ("disable" | "allow")) if !is_local(Some(&host)) => Check::warn(
+            "database TLS",
+            format!("sslmode={mode} may send credentials to {host} unencrypted; use sslmode=require"),
+        ),
+        Some(mode @ ("disable" | "allow" | "prefer" | "require" | "verify-ca" | "verify-full")) => {
+            Check::ok("database TLS", format!("sslmode={mode}"))
+        }
+        Some(mode) => Check::fail(
+            "database TLS",
+            format!("unknown sslmode '{mode}'; use disable, allow, prefer, require, verify-ca or verify-full"),
+        ),
+    });
+    checks
+}
+
+fn check_number<T: std::str::FromStr>(
+    checks: &mut Vec<Check>,
+    value: Option<String>,
+    name: &'static str,
+    valid: impl Fn(&T) -> bool,
+    expected: &str,
+) {
+    if let Some(value) = value
+        && !value.parse::<T>().is_ok_and(|n| valid(&n))
+    {
+        checks.push(Check::fail(name, format!("'{value}' is not {expected}")));
+    }
+}
+
+fn check_url(value: &str, schemes: &[&str]) -> Result<url::Url, String> {
+    let parsed = url::Url::parse(value).map_err(|e| format!("not a valid URL ({e})"))?;
+    if !schemes.contains(&parsed.scheme()) {
+        return Err(format!(
+            "scheme '{}' is not supported; use {}://",
+            parsed.scheme(),
+            schemes.join(":// or ")
+        ));
+    }
+    Ok(parsed)
+}
+
+fn is_local(host: Option<&str>) -> bool {
+    matches!(host, None | Some("localhost" | "127.0.0.1" | "[::1]" | "::1"))
+}
+
+/// Check that the functions the receiver calls exist in the database.
+pub async fn check_schema(pool: &PgPool) -> Check {
+    let wanted: Vec<String> = REQUIRED_FUNCTIONS.iter().map(|f| f.to_string()).collect();
+    let found: Result<Vec<String>, sqlx::Error> = sqlx::query_scalar(
+        "SELECT DISTINCT p.proname::text FROM pg_proc p \
+         JOIN pg_namespace n ON n.oid = p.pronamespace \
+         WHERE n.nspname = 'public' AND p.proname = ANY($1)",
+    )
+    .bind(wanted)
+    .fetch_all(pool)
+    .await;
+
+    match found {
+        Err(e) => Check::fail("database schema", format!("could not read the schema: {e}")),
+        Ok(found) => {
+            let missing: Vec<&str> = REQUIRED_FUNCTIONS
+                .iter()
+                .copied()
+                .filter(|f| !found.iter().any(|name| name == f))
+                .collect();
+            if missing.is_empty() {
+                Check::ok("database schema", "all receiver functions present")
+            } else {
+                Check::fail(
+                    "database schema",
+                    format!("missing {}; apply the Supabase migrations", missing.join(", ")),
+                )
+            }
+        }
+    }
+}
+
+async fn check_database(url: &str) -> Vec<Check> {
+    let pool = match PgPoolOptions::new()
+        .max_connections(1)
+        .acquire_timeout(CONNECT_TIMEOUT)
+        .connect(url)
+        .await
+    {
+        Ok(pool) => pool,
+        Err(e) => return vec![Check::fail("database connection", format!("could not connect: {e}"))],
+    };
+    vec![
+        Check::ok("database connection", "connected"),
+        check_schema(&pool).await,
+    ]
+}
+
+async fn check_redis(url: &str) -> Check {
+    let client = match redis::Client::open(url) {
+        Ok(client) => client,
+        Err(e) => return Check::fail("redis", format!("invalid REDIS_URL: {e}")),
+    };
+    let mut conn = match tokio::time::timeout(CONNECT_TIMEOUT, client.get_multiplexed_tokio_connection()).await {
+        Ok(Ok(conn)) => conn,
+        Ok(Err(e)) => {
+            return Check::warn("redis", format!("could not connect ({e}); rate limiting falls back to memory"));
+        }
+        Err(_) => return Check::warn("redis", "connection timed out; rate limiting falls back to memory"),
+    };
+    match redis::cmd("PING").query_async::<String>(&mut conn).await {
+        Ok(_) => Check::ok("redis", "connected"),
+        Err(e) => Check::warn("redis", format!("PING failed ({e}); rate limiting falls back to memory")),
+    }
+}
+
+async fn check_aws(credentials: &crate::function_sink::AwsCredentials) -> Check {
+    match crate::function_sink::verify_credentials(credentials).await {
+        Ok(arn) => Check::ok("function sink credentials", format!("valid for {arn}")),
+        Err(e) => Check::fail("function sink credentials", e),
+    }
+}
+
+/// Check that the log directory exists (or can be created) and is writable.
+fn check_log_dir(dir: &str) -> Check {
+    let path = std::path::Path::new(dir);
+    if let Err(e) = std::fs::create_dir_all(path) {
+        return Check::fail("RECEIVER_LOG_DIR", format!("cannot create {dir}: {e}"));
+    }
+    let probe = path.join(".receiver-validate");
+    match std::fs::write(&probe, b"") {
+        Ok(()) => {
+            let _ = std::fs::remove_file(&probe);
+            Check::ok("RECEIVER_LOG_DIR", format!("{dir} is writable"))
+        }
+        Err(e) => Check::fail("RECEIVER_LOG_DIR", format!("{dir} is not writable: {e}")),
+    }
+}
+
+/// Run every check against the process environment and print the report.
+/// Returns the process exit code.
+pub async fn run() -> i32 {
+    let var = |name: &str| std::env::var(name).ok().filter(|v| !v.is_empty());
+    let mut checks = check_env(var);
+
+    let env_ok = !checks.iter().any(|c| c.status == Status::Fail);
+    if env_ok && let Some(url) = var("DATABASE_URL") {
+        checks.extend(check_database(&url).await);
+    }
+    if env_ok && let Some(url) = var("REDIS_URL") {
+        checks.push(check_redis(&url).await);
+    }
+    if let (Some(access_key_id), Some(secret_access_key)) =
+        (var("AWS_ACCESS_KEY_ID"), var("AWS_SECRET_ACCESS_KEY"))
+    {
+        let credentials = crate::function_sink::AwsCredentials {
+            access_key_id,
+            secret_access_key,
+            session_token: var("AWS_SESSION_TOKEN"),
+        };
+        checks.push(check_aws(&credentials).await);
+    }
+    checks.push(check_log_dir(&var("RECEIVER_LOG_DIR").unwrap_or_else(|| "logs".into())));
+
+    for check in &checks {
+        println!("{}", format_check(check));
+    }
+    let failed = checks.iter().filter(|c| c.status == Status::Fail).count();
+    let warned = checks.iter().filter(|c| c.status == Status::Warn).count();
+    if failed > 0 {
+        println!("\n{failed} check(s) failed, {warned} warning(s). Fix the errors above before deploying.");
+        1
+    } else {
+        println!("\nConfiguration OK ({warned} warning(s)).");
+        0
+    }
+}
+
+/// Print boot-time environment problems to stderr (tracing is not set up
+/// yet). Returns false when the receiver should refuse to start.
+pub fn report_boot(checks: &[Check]) -> bool {
+    for check in checks.iter().filter(|c| c.status != Status::Ok) {
+        eprintln!("{}", format_check(check));
+    }
+    let ok = !checks.iter().any(|c| c.status == Status::Fail);
+    if !ok {
+        eprintln!("invalid configuration; run with --validate for a full report");
+    }
+    ok
+}
+
+fn format_check(check: &Check) -> String {
+    let mark = match check.status {
+        Status::Ok => "✓",
+        Status::Warn => "!",
+        Status::Fail => "✗",
+    };
+    format!("{mark} {:<28} {}", check.name, check.detail)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::collections::HashMap;
+
+    const SECRET: &str = "0123456789abcdef0123456789abcdef";
+
+    fn run_env(vars: &[(&str, &str)]) -> Vec<Check> {
+        let vars: HashMap<String, String> =
+            vars.iter().map(|(k, v)| (k.to_string(), v.to_string())).collect();
+        check_env(|name| vars.get(name).cloned())
+    }
+
+    fn with_required(extra: &[(&'static str, &'static str)]) -> Vec<Check> {
+        let mut vars = vec![
+            ("DATABASE_URL", "postgres://postgres:pw@db.example.com:5432/postgres?sslmode=require"),
+            ("CAPTURE_SHARED_SECRET", SECRET),
+        ];
+        vars.extend_from_slice(extra);
+        run_env(&vars)
+    }
+
+    fn status_of(checks: &[Check], name: &str) -> Option<Status> {
+        checks.iter().find(|c| c.name == name).map(|c| c.status)
+    }
+
+    #[test]
+    fn minimal_config_passes() {
+        let checks = with_required(&[]);
+        assert!(checks.iter().all(|c| c.status == Status::Ok), "{checks:?}");
+    }
+
+    #[test]
+    fn required_vars_fail_when_missing() {
+        let checks = run_env(&[("CAPTURE_SHARED_SECRET", "")]);
+        assert_eq!(status_of(&checks, "DATABASE_URL"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "CAPTURE_SHARED_SECRET"), Some(Status::Fail));
+    }
+
+    #[test]
+    fn database_url_scheme_and_sslmode() {
+        let checks = run_env(&[("DATABASE_URL", "mysql://db/x"), ("CAPTURE_SHARED_SECRET", SECRET)]);
+        assert_eq!(status_of(&checks, "DATABASE_URL"), Some(Status::Fail));
+
+        let remote = check_database_url("postgres://u:p@db.example.com/x?sslmode=disable");
+        assert_eq!(status_of(&remote, "database TLS"), Some(Status::Warn));
+        let local = check_database_url("postgres://u:p@localhost/x?sslmode=disable");
+        assert_eq!(status_of(&local, "database TLS"), Some(Status::Ok));
+        let unknown = check_database_url("postgres://u:p@db.example.com/x?sslmode=strict");
+        assert_eq!(status_of(&unknown, "database TLS"), Some(Status::Fail));
+    }
+
+    #[test]
+    fn numbers_and_urls_are_validated() {
+        let checks = with_required(&[
+            ("PORT", "http"),
+            ("GRPC_PORT", "3001"),
+            ("PG_POOL_MIN", "30"),
+            ("MIRROR_SAMPLE_PERCENT", "150"),
+            ("MIRROR_URL", "ftp://mirror"),
+            ("LIFECYCLE_EVENTS_URL", "hooks.example.com/receiver"),
+            ("REDIS_URL", "redis://cache.example.com:6379"),
+            ("SUBDOMAIN_HOST", "https://in.webhooks.cc/"),
+        ]);
+        assert_eq!(status_of(&checks, "PORT"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "GRPC_PORT"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "PG_POOL_MIN"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "MIRROR_SAMPLE_PERCENT"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "MIRROR_URL"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "LIFECYCLE_EVENTS_URL"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "REDIS_URL"), Some(Status::Warn));
+        assert_eq!(status_of(&checks, "SUBDOMAIN_HOST"), Some(Status::Fail));
+    }
+
+    #[test]
+    fn secrets_and_credentials() {
+        let checks = with_required(&[
+            ("AWS_ACCESS_KEY_ID", "AKID"),
+            ("HEADER_ENCRYPTION_KEY", "abcd"),
+            ("QUOTA_BYPASS_SECRET", "short"),
+            ("NOTIFY_PROXY_URL", "https://notify.example.com"),
+        ]);
+        assert_eq!(status_of(&checks, "AWS credentials"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "HEADER_ENCRYPTION_KEY"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "QUOTA_BYPASS_SECRET"), Some(Status::Warn));
+        assert_eq!(status_of(&checks, "NOTIFY_SECRET"), Some(Status::Warn));
+        assert!(!report_boot(&checks));
+    }
+
+    #[test]
+    fn mtls_listener_needs_a_certificate() {
+        let checks = with_required(&[("MTLS_PORT", "8443")]);
+        assert_eq!(status_of(&checks, "MTLS_PORT"), Some(Status::Fail));
+
+        let checks = with_required(&[
+            ("MTLS_PORT", "8443"),
+            ("MTLS_CERT_FILE", "/nonexistent/cert.pem"),
+            ("MTLS_KEY_FILE", "/nonexistent/key.pem"),
+        ]);
+        assert_eq!(status_of(&checks, "MTLS_CERT_FILE"), Some(Status::Fail));
+
+        let checks = with_required(&[("PORT", "8443"), ("MTLS_PORT", "8443")]);
+        assert_eq!(status_of(&checks, "MTLS_PORT"), Some(Status::Fail));
+    }
+
+    #[test]
+    fn geoip_databases_must_be_readable() {
+        let checks = with_required(&[("GEOIP_DB_PATH", "/nonexistent/GeoLite2-Country.mmdb")]);
+        assert_eq!(status_of(&checks, "GEOIP_DB_PATH"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "GEOIP_ASN_DB_PATH"), None);
+    }
+
+    #[test]
+    fn custom_domains_need_their_own_port_and_an_https_ca() {
+        let checks = with_required(&[("CUSTOM_DOMAIN_PORT", "443"), ("ACME_CONTACT_EMAIL", "ops@example.com")]);
+        assert_eq!(status_of(&checks, "ACME_DIRECTORY_URL"), Some(Status::Ok));
+        assert_eq!(status_of(&checks, "ACME_CONTACT_EMAIL"), None);
+
+        let checks = with_required(&[("CUSTOM_DOMAIN_PORT", "443"), ("MTLS_PORT", "443")]);
+        assert_eq!(status_of(&checks, "CUSTOM_DOMAIN_PORT"), Some(Status::Fail));
+        assert_eq!(status_of(&checks, "ACME_CONTACT_EMAIL"), Some(Status::Warn));
+
+        let checks = with_required(&[
+            ("CUSTOM_DOMAIN_PORT", "443"),
+            ("ACME_DIRECTORY_URL", "http://localhost:14000/dir"),
+        ]);
+        assert_eq!(status_of(&checks, "ACME_DIRECTORY_URL"), Some(Status::Fail));
+    }
+
+    #[test]
+    fn object_storage_needs_every_setting() {
+        let partial = with_required(&[
+            ("OBJECT_STORAGE_URL", "https://account.r2.cloudflarestorage.com"),
+            ("OBJECT_STORAGE_BUCKET", "bodies"),
+            ("OBJECT_STORAGE_MAX_BODY_BYTES", "1024"),
+        ]);
+        assert_eq!(status_of(&partial, "object storage"), Some(Status::Fail));
+        assert_eq!(status_of(&partial, "OBJECT_STORAGE_MAX_BODY_BYTES"), Some(Status::Fail));
+
+        let complete = with_required(&[
+            ("OBJECT_STORAGE_URL", "https://account.r2.cloudflarestorage.com"),
+            ("OBJECT_STORAGE_BUCKET", "bodies"),
+            ("OBJECT_STORAGE_ACCESS_KEY_ID", "AKID"),
+            ("OBJECT_STORAGE_SECRET_ACCESS_KEY", "secret"),
+        ]);
+        assert!(complete.iter().all(|c| c.status == Status::Ok), "{complete:?}");
+    }
+}
@@ -0,0 +1,46 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import {
+  ACCOUNT_EVENT_TYPES,
+  listAccountEvents,
+  type AccountEventType,
+} from "@/lib/supabase/activity";
+
+/**
+ * Account activity, newest first: endpoints created, quota warnings,
+ * forwarding failures and new team members. `?since=<ms>` returns events at
+ * or after that time, for polling; `?type=` filters to one event type.
+ */
+export async function GET(request: Request) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const url = new URL(request.url);
+  const limit = url.searchParams.get("limit");
+  const since = url.searchParams.get("since");
+  const parsedLimit = limit ? Number(limit) : undefined;
+  const parsedSince = since ? Number(since) : undefined;
+
+  if (parsedLimit !== undefined && (!Number.isFinite(parsedLimit) || parsedLimit < 1)) {
+    return Response.json({ error: "invalid_limit" }, { status: 400 });
+  }
+  if (parsedSince !== undefined && (!Number.isFinite(parsedSince) || parsedSince < 0)) {
+    return Response.json({ error: "invalid_since" }, { status: 400 });
+  }
+  const type = url.searchParams.get("type") ?? undefined;
+  if (type !== undefined && !ACCOUNT_EVENT_TYPES.includes(type as AccountEventType)) {
+    return Response.json({ error: "invalid_type" }, { status: 400 });
+  }
+
+  try {
+    const events = await listAccountEvents({
+      userId: auth.userId,
+      limit: parsedLimit,
+      since: parsedSince,
+      type: type as AccountEventType | undefined,
+    });
+    return Response.json(events);
+  } catch (error) {
+    console.error("Failed to list activity:", error);
+    return Response.json({ error: "Failed to list activity" }, { status: 500 });
+  }
+}
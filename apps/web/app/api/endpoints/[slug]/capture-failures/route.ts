@@ -0,0 +1,47 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import {
+  acknowledgeCaptureFailures,
+  listCaptureFailures,
+} from "@/lib/supabase/capture-failures";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access || !access.isOwner) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const failures = await listCaptureFailures(access.endpointId);
+    return Response.json(failures);
+  } catch (error) {
+    console.error("Failed to list capture failures:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
+
+/** Acknowledge every open failure window, clearing the dashboard flag. */
+export async function DELETE(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access || !access.isOwner) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const acknowledged = await acknowledgeCaptureFailures(access.endpointId);
+    return Response.json({ success: true, acknowledged });
+  } catch (error) {
+    console.error("Failed to acknowledge capture failures:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
@@ -0,0 +1,231 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { describeConfigChanges } from "@/lib/config-changes";
+import {
+  createRealtimeAdminClient,
+  MAX_CONNECTION_DURATION_MS,
+  parseKeepalive,
+  waitForSubscribed,
+} from "@/lib/event-stream";
+import type { Database } from "@/lib/supabase/database";
+import {
+  getEndpointVersion,
+  normalizeVersion,
+  type VersionRow,
+} from "@/lib/supabase/endpoint-versions";
+import { getEndpointBySlugForUser } from "@/lib/supabase/endpoints";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+import { recordApiUsage } from "@/lib/supabase/usage";
+import { sendError } from "@appsignal/nodejs";
+import type { RealtimeChannel } from "@supabase/supabase-js";
+
+export const dynamic = "force-dynamic";
+
+type EndpointRow = Database["public"]["Tables"]["endpoints"]["Row"];
+
+/**
+ * Server-sent events for changes to an endpoint's configuration:
+ * `config_changed` for every new version (edits and rollbacks, with the
+ * settings that changed), `endpoint_expired` when an ephemeral endpoint runs
+ * out and `endpoint_deleted`. The stream closes after expiry or deletion.
+ */
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+  const parsedKeepalive = parseKeepalive(new URL(request.url).searchParams);
+  if ("error" in parsedKeepalive) {
+    return Response.json({ error: parsedKeepalive.error }, { status: 400 });
+  }
+  const { keepaliveMs } = parsedKeepalive;
+
+  const access = await resolveEndpointAccess(auth.userId, slug);
+  const endpoint = access ? await getEndpointBySlugForUser(access.ownerId, slug) : null;
+  if (!access || !endpoint) {
+    return Response.json({ error: "Endpoint not found" }, { status: 404 });
+  }
+
+  const encoder = new TextEncoder();
+  const connectionStart = Date.now();
+
+  const stream = new ReadableStream({
+    async start(controller) {
+      const abortSignal = request.signal;
+      const supabase = createRealtimeAdminClient();
+      let keepaliveTimer: ReturnType<typeof setInterval> | null = null;
+      let durationTimer: ReturnType<typeof setTimeout> | null = null;
+      let expiryTimer: ReturnType<typeof setTimeout> | null = null;
+      let closed = false;
+      let versionsChannel: RealtimeChannel | null = null;
+      let endpointChannel: RealtimeChannel | null = null;
+      // Versions are described in insert order, each against the one before it.
+      let pending = Promise.resolve();
+
+      const send = (event: string, data: unknown) => {
+        if (closed) return;
+        try {
+          controller.enqueue(encoder.encode(`event: ${event}\ndata: ${JSON.stringify(data)}\n\n`));
+        } catch {
+          closeStream();
+        }
+      };
+
+      const cleanup = () => {
+        if (keepaliveTimer) {
+          clearInterval(keepaliveTimer);
+          keepaliveTimer = null;
+        }
+        if (durationTimer) {
+          clearTimeout(durationTimer);
+          durationTimer = null;
+        }
+        if (expiryTimer) {
+          clearTimeout(expiryTimer);
+          expiryTimer = null;
+        }
+        if (versionsChannel) {
+          void supabase.removeChannel(versionsChannel);
+          versionsChannel = null;
+        }
+        if (endpointChannel) {
+          void supabase.removeChannel(endpointChannel);
+          endpointChannel = null;
+        }
+        void supabase.realtime.disconnect();
+      };
+
+      function closeStream() {
+        if (closed) return;
+        closed = true;
+        cleanup();
+        recordApiUsage(auth.userId, {
+          streamSeconds: Math.round((Date.now() - connectionStart) / 1000),
+        });
+        try {
+          controller.close();
+        } catch {
+          // Stream may already be closed.
+        }
+      }
+
+      // Only expiries within this connection's lifetime need a timer.
+      const scheduleExpiry = (expiresAt: number | undefined) => {
+        if (expiryTimer) {
+          clearTimeout(expiryTimer);
+          expiryTimer = null;
+        }
+        if (expiresAt === undefined) return;
+        const delay = expiresAt - Date.now();
+        if (delay > MAX_CONNECTION_DURATION_MS - (Date.now() - connectionStart)) return;
+        expiryTimer = setTimeout(
+          () => {
+            send("endpoint_expired", { slug, expiresAt });
+            closeStream();
+          },
+          Math.max(0, delay)
+        );
+      };
+
+      const describeVersion = async (row: VersionRow) => {
+        const record = normalizeVersion(row);
+        const previous =
+          record.version > 1 ? await getEndpointVersion(endpoint.id, record.version - 1) : null;
+        send("config_changed", {
+          version: record.version,
+          source: record.source,
+          rolledBackTo: record.rolledBackTo,
+          createdAt: record.createdAt,
+          changes: describeConfigChanges(previous?.config ?? null, record.config),
+        });
+      };
+
+      send("connected", { slug, endpointId: endpoint.id, keepaliveMs });
+      abortSignal.addEventListener("abort", closeStream);
+
+      keepaliveTimer = setInterval(() => {
+        if (closed || abortSignal.aborted) return;
+        try {
+          controller.enqueue(encoder.encode(": keepalive\n\n"));
+        } catch {
+          closeStream();
+        }
+      }, keepaliveMs);
+
+      durationTimer = setTimeout(
+        () => {
+          if (closed || abortSignal.aborted) return;
+          send("timeout", { reason: "max_duration" });
+          closeStream();
+        },
+        Math.max(0, MAX_CONNECTION_DURATION_MS - (Date.now() - connectionStart))
+      );
+
+      scheduleExpiry(endpoint.expiresAt);
+
+      versionsChannel = supabase.channel(`events:versions:${endpoint.id}:${connectionStart}`).on(
+        "postgres_changes",
+        {
+          event: "INSERT",
+          schema: "public",
+          table: "endpoint_versions",
+          filter: `endpoint_id=eq.${endpoint.id}`,
+        },
+        (payload) => {
+          const row = payload.new as VersionRow;
+          // The configuration a first edit replaced is saved alongside it.
+          if (row.source === "initial") return;
+          pending = pending
+            .then(() => describeVersion(row))
+            .catch((error) => {
+              sendError(error instanceof Error ? error : new Error(String(error)));
+            });
+        }
+      );
+
+      endpointChannel = supabase
+        .channel(`events:endpoint:${endpoint.id}:${connectionStart}`)
+        .on(
+          "postgres_changes",
+          {
+            event: "UPDATE",
+            schema: "public",
+            table: "endpoints",
+            filter: `id=eq.${endpoint.id}`,
+          },
+          (payload) => {
+            const row = payload.new as EndpointRow;
+            scheduleExpiry(row.expires_at ? Date.parse(row.expires_at) : undefined);
+          }
+        )
+        .on(
+          "postgres_changes",
+          {
+            event: "DELETE",
+            schema: "public",
+            table: "endpoints",
+            filter: `id=eq.${endpoint.id}`,
+          },
+          () => {
+            send("endpoint_deleted", { slug });
+            closeStream();
+          }
+        );
+
+      try {
+        await Promise.all([waitForSubscribed(versionsChannel), waitForSubscribed(endpointChannel)]);
+      } catch (error) {
+        sendError(error instanceof Error ? error : new Error(String(error)));
+        console.error("Failed to initialize endpoint event stream:", error);
+        closeStream();
+      }
+    },
+  });
+
+  return new Response(stream, {
+    headers: {
+      "Content-Type": "text/event-stream",
+      "Cache-Control": "no-cache, no-transform",
+      Connection: "keep-alive",
+    },
+  });
+}
@@ -0,0 +1,33 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { getEndpointBySlugForUser } from "@/lib/supabase/endpoints";
+import { promoteMockCanary } from "@/lib/supabase/mock-canary";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+/** Make the running canary's response the endpoint's mock response for every sender. */
+export async function POST(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const promoted = await promoteMockCanary(access.endpointId);
+    if (!promoted) {
+      return Response.json({ error: "No mock canary is running" }, { status: 409 });
+    }
+
+    const endpoint = await getEndpointBySlugForUser(access.ownerId, slug);
+    if (!endpoint) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+    return Response.json(endpoint);
+  } catch (error) {
+    console.error("Failed to promote mock canary:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
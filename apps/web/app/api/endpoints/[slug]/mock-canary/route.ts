@@ -0,0 +1,105 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { validateMockCanary } from "@/lib/request-validation";
+import { getEndpointBySlugForUser, updateEndpointBySlugForUser } from "@/lib/supabase/endpoints";
+import { getMockCanaryStats } from "@/lib/supabase/mock-canary";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+/** The running canary and how many requests and senders each version answered. */
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const endpoint = await getEndpointBySlugForUser(access.ownerId, slug);
+    if (!endpoint) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+    if (!endpoint.mockCanary) {
+      return Response.json({ error: "No mock canary is running" }, { status: 404 });
+    }
+
+    const stats = await getMockCanaryStats(access.endpointId);
+    return Response.json({ ...endpoint.mockCanary, stats });
+  } catch (error) {
+    console.error("Failed to get mock canary:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
+
+/**
+ * Start a canary: `{ "response": MockResponse, "percent": number }` serves
+ * `response` to that share of senders, each sender consistently, while the
+ * rest keep the current mock. Replacing a running canary restarts it, so
+ * senders are bucketed again and its stats start over.
+ */
+export async function PUT(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  let body: unknown;
+  try {
+    body = await request.json();
+  } catch {
+    return Response.json({ error: "Invalid JSON body" }, { status: 400 });
+  }
+
+  const canaryCheck = validateMockCanary(body);
+  if (!canaryCheck.valid) return canaryCheck.response;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const endpoint = await updateEndpointBySlugForUser({
+      userId: access.ownerId,
+      slug,
+      mockCanary: canaryCheck.canary,
+    });
+    if (!endpoint) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+    return Response.json(endpoint);
+  } catch (error) {
+    console.error("Failed to start mock canary:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
+
+/** Roll back: stop the canary and answer every sender with the current mock again. */
+export async function DELETE(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const endpoint = await updateEndpointBySlugForUser({
+      userId: access.ownerId,
+      slug,
+      mockCanary: null,
+    });
+    if (!endpoint) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+    return Response.json(endpoint);
+  } catch (error) {
+    console.error("Failed to roll back mock canary:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
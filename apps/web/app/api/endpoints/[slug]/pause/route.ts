@@ -0,0 +1,53 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { validatePausedResponse } from "@/lib/request-validation";
+import { updateEndpointBySlugForUser } from "@/lib/supabase/endpoints";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+/**
+ * Pause capture: the receiver answers requests without storing, forwarding
+ * or counting them until the endpoint is resumed. Optional body:
+ * `{ "response": { "status": number, "body"?: string } }` to reply with
+ * instead of the default 503. Pausing a paused endpoint replaces the reply.
+ */
+export async function POST(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  let body: Record<string, unknown> = {};
+  const text = await request.text();
+  if (text.trim()) {
+    try {
+      body = JSON.parse(text) as Record<string, unknown>;
+    } catch {
+      return Response.json({ error: "Invalid JSON body" }, { status: 400 });
+    }
+    if (!body || typeof body !== "object" || Array.isArray(body)) {
+      return Response.json({ error: "Expected JSON object" }, { status: 400 });
+    }
+  }
+
+  const responseCheck = validatePausedResponse(body.response);
+  if (!responseCheck.valid) return responseCheck.response;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const endpoint = await updateEndpointBySlugForUser({
+      userId: access.ownerId,
+      slug,
+      paused: { response: responseCheck.response },
+    });
+    if (!endpoint) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+    return Response.json(endpoint);
+  } catch (error) {
+    console.error("Failed to pause endpoint:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
@@ -0,0 +1,39 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { listRejectedRequests, MAX_REJECTED_LIMIT } from "@/lib/supabase/rejected-requests";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+/**
+ * Requests the endpoint's network policy rejected, newest first, when the
+ * policy captures them. `?since=<ms>` returns requests received after it.
+ */
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+  const url = new URL(request.url);
+  const limit = url.searchParams.get("limit");
+  const since = url.searchParams.get("since");
+  const parsedLimit = limit ? Number(limit) : 100;
+  const parsedSince = since ? Number(since) : undefined;
+
+  if (!Number.isInteger(parsedLimit) || parsedLimit < 1 || parsedLimit > MAX_REJECTED_LIMIT) {
+    return Response.json({ error: "invalid_limit" }, { status: 400 });
+  }
+  if (parsedSince !== undefined && (!Number.isFinite(parsedSince) || parsedSince < 0)) {
+    return Response.json({ error: "invalid_since" }, { status: 400 });
+  }
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const rejected = await listRejectedRequests(access.endpointId, parsedLimit, parsedSince);
+    return Response.json(rejected);
+  } catch (error) {
+    console.error("Failed to list rejected requests:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
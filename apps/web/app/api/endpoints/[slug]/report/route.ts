@@ -0,0 +1,39 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { getEndpointUsageReport } from "@/lib/supabase/usage";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+import { parseReportMonth } from "@/lib/usage-report";
+
+/**
+ * Usage report for one calendar month (`?month=YYYY-MM`, UTC): volumes,
+ * sizes, top senders, quota share and capture failures. Rendering it as CSV
+ * or PDF is left to the client.
+ */
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+  const url = new URL(request.url);
+  const month = parseReportMonth(url.searchParams.get("month") ?? "");
+  if (!month) {
+    return Response.json({ error: "invalid_month" }, { status: 400 });
+  }
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const report = await getEndpointUsageReport(
+      access.endpointId,
+      access.ownerId,
+      slug.toLowerCase(),
+      month
+    );
+    return Response.json(report);
+  } catch (error) {
+    console.error("Failed to build usage report:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
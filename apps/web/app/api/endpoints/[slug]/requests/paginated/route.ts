@@ -1,4 +1,11 @@
 import { authenticateRequest } from "@/lib/api-auth";
+import {
+  isCloudEventType,
+  isCountryCode,
+  isRequestTag,
+  parseAsn,
+  parseReveal,
+} from "@/lib/request-validation";
 import { listPaginatedRequestsForEndpointByUser } from "@/lib/supabase/requests";
 
 export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
@@ -15,6 +22,27 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
   if (parsedLimit !== undefined && (!Number.isFinite(parsedLimit) || parsedLimit < 1)) {
     return Response.json({ error: "invalid_limit" }, { status: 400 });
   }
+  const tag = url.searchParams.get("tag") ?? undefined;
+  if (tag !== undefined && !isRequestTag(tag)) {
+    return Response.json({ error: "invalid_tag" }, { status: 400 });
+  }
+  const eventType = url.searchParams.get("eventType") ?? undefined;
+  if (eventType !== undefined && !isCloudEventType(eventType)) {
+    return Response.json({ error: "invalid_event_type" }, { status: 400 });
+  }
+  const country = url.searchParams.get("country")?.toUpperCase() ?? undefined;
+  if (country !== undefined && !isCountryCode(country)) {
+    return Response.json({ error: "invalid_country" }, { status: 400 });
+  }
+  const asnRaw = url.searchParams.get("asn");
+  const asn = asnRaw === null ? undefined : parseAsn(asnRaw);
+  if (asn === null) {
+    return Response.json({ error: "invalid_asn" }, { status: 400 });
+  }
+  const reveal = parseReveal(url.searchParams.get("reveal"));
+  if (reveal === null) {
+    return Response.json({ error: "invalid_reveal" }, { status: 400 });
+  }
 
   try {
     const page = await listPaginatedRequestsForEndpointByUser({
@@ -22,6 +50,11 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
       slug,
       limit: parsedLimit,
       cursor: cursor ?? undefined,
+      tag,
+      eventType,
+      country,
+      asn,
+      reveal,
     });
 
     if (!page) {
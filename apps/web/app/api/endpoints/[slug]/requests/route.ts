@@ -1,4 +1,11 @@
 import { authenticateRequest } from "@/lib/api-auth";
+import {
+  isCloudEventType,
+  isCountryCode,
+  isRequestTag,
+  parseAsn,
+  parseReveal,
+} from "@/lib/request-validation";
 import {
   clearRequestsForEndpointByUser,
   listRequestsForEndpointByUser,
@@ -22,6 +29,27 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
   if (parsedSince !== undefined && (!Number.isFinite(parsedSince) || parsedSince < 0)) {
     return Response.json({ error: "invalid_since" }, { status: 400 });
   }
+  const tag = url.searchParams.get("tag") ?? undefined;
+  if (tag !== undefined && !isRequestTag(tag)) {
+    return Response.json({ error: "invalid_tag" }, { status: 400 });
+  }
+  const eventType = url.searchParams.get("eventType") ?? undefined;
+  if (eventType !== undefined && !isCloudEventType(eventType)) {
+    return Response.json({ error: "invalid_event_type" }, { status: 400 });
+  }
+  const country = url.searchParams.get("country")?.toUpperCase() ?? undefined;
+  if (country !== undefined && !isCountryCode(country)) {
+    return Response.json({ error: "invalid_country" }, { status: 400 });
+  }
+  const asnRaw = url.searchParams.get("asn");
+  const asn = asnRaw === null ? undefined : parseAsn(asnRaw);
+  if (asn === null) {
+    return Response.json({ error: "invalid_asn" }, { status: 400 });
+  }
+  const reveal = parseReveal(url.searchParams.get("reveal"));
+  if (reveal === null) {
+    return Response.json({ error: "invalid_reveal" }, { status: 400 });
+  }
 
   try {
     const data = await listRequestsForEndpointByUser({
@@ -29,6 +57,11 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
       slug,
       limit: parsedLimit,
       since: parsedSince,
+      tag,
+      eventType,
+      country,
+      asn,
+      reveal,
     });
 
     if (!data) {
@@ -0,0 +1,31 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { updateEndpointBySlugForUser } from "@/lib/supabase/endpoints";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+/** Resume capture on a paused endpoint. Resuming an active endpoint is a no-op. */
+export async function POST(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const endpoint = await updateEndpointBySlugForUser({
+      userId: access.ownerId,
+      slug,
+      paused: false,
+    });
+    if (!endpoint) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+    return Response.json(endpoint);
+  } catch (error) {
+    console.error("Failed to resume endpoint:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
@@ -0,0 +1,62 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { getEndpointVersion, rollbackEndpointConfig } from "@/lib/supabase/endpoint-versions";
+import { getEndpointBySlugForUser } from "@/lib/supabase/endpoints";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+/** Restore a saved configuration version. Body: `{ "version": number }`. */
+export async function POST(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  let body: Record<string, unknown>;
+  try {
+    body = (await request.json()) as Record<string, unknown>;
+  } catch {
+    return Response.json({ error: "Invalid JSON body" }, { status: 400 });
+  }
+
+  const version = body.version;
+  if (typeof version !== "number" || !Number.isInteger(version) || version < 1) {
+    return Response.json({ error: "version must be a positive integer" }, { status: 400 });
+  }
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const target = await getEndpointVersion(access.endpointId, version);
+    if (!target) {
+      return Response.json({ error: `Version ${version} not found` }, { status: 404 });
+    }
+
+    // Same rule as PATCH: only the owner may change the function sink.
+    if (!access.isOwner) {
+      const current = await getEndpointBySlugForUser(access.ownerId, slug);
+      const currentSink = JSON.stringify(current?.functionSink ?? null);
+      if (currentSink !== JSON.stringify(target.config.functionSink)) {
+        return Response.json(
+          { error: "Only the endpoint owner can restore a version with a different function sink" },
+          { status: 403 }
+        );
+      }
+    }
+
+    const restored = await rollbackEndpointConfig(access.endpointId, version);
+    if (!restored) {
+      return Response.json({ error: `Version ${version} not found` }, { status: 404 });
+    }
+
+    const endpoint = await getEndpointBySlugForUser(access.ownerId, slug);
+    if (!endpoint) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+    return Response.json(endpoint);
+  } catch (error) {
+    console.error("Failed to roll back endpoint:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
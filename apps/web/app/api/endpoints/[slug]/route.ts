@@ -1,6 +1,32 @@
-import { authenticateRequest } from "@/lib/api-auth";
-import { validateNotificationUrl, validateMockResponseField } from "@/lib/request-validation";
 import {
+  authenticateRequest,
+  extractBearerToken,
+  validateBearerTokenWithPlan,
+} from "@/lib/api-auth";
+import { parseCaptureAuth } from "@/lib/capture-auth";
+import { parseCaptureFilter } from "@/lib/capture-filter";
+import { parseChaos } from "@/lib/chaos";
+import { parseRateLimit } from "@/lib/endpoint-rate-limit";
+import { parseClientCa } from "@/lib/client-ca";
+import { parseCors } from "@/lib/cors";
+import { parseCustomDomain } from "@/lib/custom-domain";
+import { parseEncryptedHeaders } from "@/lib/header-crypt";
+import { parseNetworkPolicy } from "@/lib/network-policy";
+import { parseJwtVerification } from "@/lib/jwt-verification";
+import { parsePriorityRule } from "@/lib/priority";
+import { parseRedaction } from "@/lib/redaction";
+import { parseSampling } from "@/lib/sampling";
+import { parseSchemaValidation } from "@/lib/schema-validation";
+import { parseSignatureVerification } from "@/lib/signature-verification";
+import {
+  validateFunctionSinkField,
+  validateBodyTransformsField,
+  validateNotificationUrl,
+  validateForwardUrl,
+  validateMockResponseField,
+} from "@/lib/request-validation";
+import {
+  type BodyTransform,
   deleteEndpointBySlugForUser,
   getEndpointBySlugForUser,
   updateEndpointBySlugForUser,
@@ -24,10 +50,11 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
       return Response.json({ error: "Endpoint not found" }, { status: 404 });
     }
 
-    // Strip notification URL for non-owners — it's a bearer secret (Slack/Discord)
+    // Strip notification URL for non-owners — it's a bearer secret (Slack/Discord).
+    // Forward URLs often carry tokens too.
     if (access.ownerId !== auth.userId) {
       // eslint-disable-next-line @typescript-eslint/no-unused-vars
-      const { notificationUrl, ...safe } = endpoint;
+      const { notificationUrl, forwardUrl, ...safe } = endpoint;
       return Response.json(safe);
     }
 
@@ -59,15 +86,254 @@ export async function PATCH(request: Request, { params }: { params: Promise<{ sl
   const notifCheck = validateNotificationUrl(body.notificationUrl);
   if (!notifCheck.valid) return notifCheck.response;
 
+  const forwardCheck = validateForwardUrl(body.forwardUrl);
+  if (!forwardCheck.valid) return forwardCheck.response;
+
   const mockCheck = validateMockResponseField(body.mockResponse, true);
   if (!mockCheck.valid) return mockCheck.response;
 
+  const sinkCheck = validateFunctionSinkField(body.functionSink);
+  if (!sinkCheck.valid) return sinkCheck.response;
+
+  const transformsCheck = validateBodyTransformsField(body.bodyTransforms);
+  if (!transformsCheck.valid) return transformsCheck.response;
+
+  if (body.infoHeaders !== undefined && typeof body.infoHeaders !== "boolean") {
+    return Response.json({ error: "infoHeaders must be a boolean" }, { status: 400 });
+  }
+
+  if (body.recordResponses !== undefined && typeof body.recordResponses !== "boolean") {
+    return Response.json({ error: "recordResponses must be a boolean" }, { status: 400 });
+  }
+
+  if (body.canonicalJson !== undefined && typeof body.canonicalJson !== "boolean") {
+    return Response.json({ error: "canonicalJson must be a boolean" }, { status: 400 });
+  }
+
+  if (body.dryRun !== undefined && typeof body.dryRun !== "boolean") {
+    return Response.json({ error: "dryRun must be a boolean" }, { status: 400 });
+  }
+
+  if (body.queryOverrides !== undefined && typeof body.queryOverrides !== "boolean") {
+    return Response.json({ error: "queryOverrides must be a boolean" }, { status: 400 });
+  }
+
+  if (body.autoHandshake !== undefined && typeof body.autoHandshake !== "boolean") {
+    return Response.json({ error: "autoHandshake must be a boolean" }, { status: 400 });
+  }
+
+  // The plan's limit still applies on top (see get_endpoint_max_body_size)
+  if (
+    body.maxBodySize !== undefined &&
+    body.maxBodySize !== null &&
+    (typeof body.maxBodySize !== "number" ||
+      !Number.isInteger(body.maxBodySize) ||
+      body.maxBodySize < 1024 ||
+      body.maxBodySize > 104857600)
+  ) {
+    return Response.json(
+      { error: "maxBodySize must be an integer between 1024 and 104857600, or null" },
+      { status: 400 }
+    );
+  }
+
+  const encryptedCheck =
+    body.encryptedHeaders === undefined ? null : parseEncryptedHeaders(body.encryptedHeaders);
+  if (encryptedCheck && !encryptedCheck.valid) {
+    return Response.json({ error: encryptedCheck.error }, { status: 400 });
+  }
+
+  const clientCaCheck = body.clientCa === undefined ? null : parseClientCa(body.clientCa);
+  if (clientCaCheck && !clientCaCheck.valid) {
+    return Response.json({ error: clientCaCheck.error }, { status: 400 });
+  }
+
+  const signatureCheck =
+    body.signatureVerification === undefined
+      ? null
+      : parseSignatureVerification(body.signatureVerification);
+  if (signatureCheck && !signatureCheck.valid) {
+    return Response.json({ error: signatureCheck.error }, { status: 400 });
+  }
+
+  const jwtCheck =
+    body.jwtVerification === undefined ? null : parseJwtVerification(body.jwtVerification);
+  if (jwtCheck && !jwtCheck.valid) {
+    return Response.json({ error: jwtCheck.error }, { status: 400 });
+  }
+
+  const captureAuthCheck =
+    body.captureAuth === undefined ? null : parseCaptureAuth(body.captureAuth);
+  if (captureAuthCheck && !captureAuthCheck.valid) {
+    return Response.json({ error: captureAuthCheck.error }, { status: 400 });
+  }
+
+  const corsCheck = body.cors === undefined ? null : parseCors(body.cors);
+  if (corsCheck && !corsCheck.valid) {
+    return Response.json({ error: corsCheck.error }, { status: 400 });
+  }
+
+  const captureFilterCheck =
+    body.captureFilter === undefined ? null : parseCaptureFilter(body.captureFilter);
+  if (captureFilterCheck && !captureFilterCheck.valid) {
+    return Response.json({ error: captureFilterCheck.error }, { status: 400 });
+  }
+
+  const samplingCheck = body.sampling === undefined ? null : parseSampling(body.sampling);
+  if (samplingCheck && !samplingCheck.valid) {
+    return Response.json({ error: samplingCheck.error }, { status: 400 });
+  }
+
+  const chaosCheck = body.chaos === undefined ? null : parseChaos(body.chaos);
+  if (chaosCheck && !chaosCheck.valid) {
+    return Response.json({ error: chaosCheck.error }, { status: 400 });
+  }
+
+  const rateLimitCheck = body.rateLimit === undefined ? null : parseRateLimit(body.rateLimit);
+  if (rateLimitCheck && !rateLimitCheck.valid) {
+    return Response.json({ error: rateLimitCheck.error }, { status: 400 });
+  }
+
+  const schemaCheck =
+    body.schemaValidation === undefined ? null : parseSchemaValidation(body.schemaValidation);
+  if (schemaCheck && !schemaCheck.valid) {
+    return Response.json({ error: schemaCheck.error }, { status: 400 });
+  }
+
+  const redactionCheck = body.redaction === undefined ? null : parseRedaction(body.redaction);
+  if (redactionCheck && !redactionCheck.valid) {
+    return Response.json({ error: redactionCheck.error }, { status: 400 });
+  }
+
+  const domainCheck =
+    body.customDomain === undefined ? null : parseCustomDomain(body.customDomain);
+  if (domainCheck && !domainCheck.valid) {
+    return Response.json({ error: domainCheck.error }, { status: 400 });
+  }
+
+  const networkCheck =
+    body.networkPolicy === undefined ? null : parseNetworkPolicy(body.networkPolicy);
+  if (networkCheck && !networkCheck.valid) {
+    return Response.json({ error: networkCheck.error }, { status: 400 });
+  }
+
+  const priorityCheck =
+    body.priorityRule === undefined ? null : parsePriorityRule(body.priorityRule);
+  if (priorityCheck && !priorityCheck.valid) {
+    return Response.json({ error: priorityCheck.error }, { status: 400 });
+  }
+
   try {
     // Allow team members to edit (they can rename + change mock response)
     const access = await resolveEndpointAccess(auth.userId, slug);
     if (!access) {
       return Response.json({ error: "Endpoint not found" }, { status: 404 });
     }
+    if (body.functionSink !== undefined && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can configure a function sink" },
+        { status: 403 }
+      );
+    }
+    if (encryptedCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can choose encrypted headers" },
+        { status: 403 }
+      );
+    }
+    if (clientCaCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set a client CA" },
+        { status: 403 }
+      );
+    }
+    if (signatureCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set signature verification" },
+        { status: 403 }
+      );
+    }
+    if (jwtCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set JWT verification" },
+        { status: 403 }
+      );
+    }
+    if (captureAuthCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set capture credentials" },
+        { status: 403 }
+      );
+    }
+    if (corsCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set CORS" },
+        { status: 403 }
+      );
+    }
+    if (captureFilterCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set capture filters" },
+        { status: 403 }
+      );
+    }
+    if (samplingCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set sampling" },
+        { status: 403 }
+      );
+    }
+    if (body.forwardUrl !== undefined && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set a forward URL" },
+        { status: 403 }
+      );
+    }
+    if (chaosCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set chaos mode" },
+        { status: 403 }
+      );
+    }
+    if (rateLimitCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set a rate limit" },
+        { status: 403 }
+      );
+    }
+    if (schemaCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set schema validation" },
+        { status: 403 }
+      );
+    }
+    if (redactionCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set redaction rules" },
+        { status: 403 }
+      );
+    }
+    if (domainCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set a custom domain" },
+        { status: 403 }
+      );
+    }
+    // Clearing a domain is allowed on any plan, so a downgraded account can
+    // release it.
+    if (domainCheck?.valid && domainCheck.value !== null) {
+      const token = extractBearerToken(request);
+      const validation = token ? await validateBearerTokenWithPlan(token) : null;
+      if (validation?.plan !== "pro") {
+        return Response.json({ error: "Custom domains require a Pro plan" }, { status: 403 });
+      }
+    }
+    if (networkCheck && !access.isOwner) {
+      return Response.json(
+        { error: "Only the endpoint owner can set a network policy" },
+        { status: 403 }
+      );
+    }
 
     const endpoint = await updateEndpointBySlugForUser({
       userId: access.ownerId,
@@ -83,6 +349,42 @@ export async function PATCH(request: Request, { params }: { params: Promise<{ sl
           : body.notificationUrl === null || body.notificationUrl === ""
             ? null
             : (body.notificationUrl as string),
+      forwardUrl:
+        body.forwardUrl === undefined
+          ? undefined
+          : body.forwardUrl === null || body.forwardUrl === ""
+            ? null
+            : (body.forwardUrl as string),
+      functionSink:
+        body.functionSink === undefined
+          ? undefined
+          : (body.functionSink as { provider: "aws_lambda"; arn: string } | null),
+      bodyTransforms:
+        body.bodyTransforms === undefined
+          ? undefined
+          : (body.bodyTransforms as BodyTransform[] | null),
+      infoHeaders: body.infoHeaders as boolean | undefined,
+      recordResponses: body.recordResponses as boolean | undefined,
+      canonicalJson: body.canonicalJson as boolean | undefined,
+      dryRun: body.dryRun as boolean | undefined,
+      queryOverrides: body.queryOverrides as boolean | undefined,
+      autoHandshake: body.autoHandshake as boolean | undefined,
+      maxBodySize: body.maxBodySize as number | null | undefined,
+      priorityRule: priorityCheck?.value,
+      encryptedHeaders: encryptedCheck?.headers,
+      clientCa: clientCaCheck?.value,
+      signatureVerification: signatureCheck?.value,
+      jwtVerification: jwtCheck?.value,
+      captureAuth: captureAuthCheck?.value,
+      cors: corsCheck?.value,
+      captureFilter: captureFilterCheck?.value,
+      sampling: samplingCheck?.value,
+      chaos: chaosCheck?.value,
+      rateLimit: rateLimitCheck?.value,
+      schemaValidation: schemaCheck?.value,
+      redaction: redactionCheck?.value,
+      customDomain: domainCheck?.value,
+      networkPolicy: networkCheck?.value,
     });
 
     if (!endpoint) {
@@ -91,6 +393,12 @@ export async function PATCH(request: Request, { params }: { params: Promise<{ sl
 
     return Response.json(endpoint);
   } catch (error) {
+    if (error instanceof Error && error.message === "domain_taken") {
+      return Response.json(
+        { error: "That domain is already used by another endpoint" },
+        { status: 409 }
+      );
+    }
     console.error("Failed to update endpoint:", error);
     return Response.json({ error: "Internal server error" }, { status: 500 });
   }
@@ -0,0 +1,24 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { getSamplingStats } from "@/lib/supabase/sampling-stats";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+/** Counters kept while the endpoint samples its traffic. */
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const stats = await getSamplingStats(access.endpointId);
+    return Response.json(stats);
+  } catch (error) {
+    console.error("Failed to get sampling stats:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
@@ -0,0 +1,30 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { revokeShareToken } from "@/lib/supabase/share-tokens";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+export async function DELETE(
+  request: Request,
+  { params }: { params: Promise<{ slug: string; shareId: string }> }
+) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug, shareId } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access || !access.isOwner) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const revoked = await revokeShareToken(access.endpointId, shareId);
+    if (!revoked) {
+      return Response.json({ error: "Share token not found" }, { status: 404 });
+    }
+
+    return Response.json({ success: true });
+  } catch (error) {
+    console.error("Failed to revoke share token:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
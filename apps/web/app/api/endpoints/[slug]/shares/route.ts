@@ -0,0 +1,85 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { checkRateLimitByKeyWithInfo, applyRateLimitHeaders } from "@/lib/rate-limit";
+import {
+  createShareToken,
+  DEFAULT_SHARE_TTL_MS,
+  listShareTokens,
+  MAX_SHARE_TTL_MS,
+} from "@/lib/supabase/share-tokens";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access || !access.isOwner) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const shares = await listShareTokens(access.endpointId);
+    return Response.json(shares);
+  } catch (error) {
+    console.error("Failed to list share tokens:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
+
+export async function POST(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const rateLimit = await checkRateLimitByKeyWithInfo(`share-create:${auth.userId}`, 30, 10 * 60_000);
+  if (rateLimit.response) return rateLimit.response;
+
+  const { slug } = await params;
+
+  let body: Record<string, unknown>;
+  try {
+    body = (await request.json()) as Record<string, unknown>;
+  } catch {
+    return Response.json({ error: "Invalid JSON body" }, { status: 400 });
+  }
+
+  const ttlMs = body.expiresIn === undefined ? DEFAULT_SHARE_TTL_MS : body.expiresIn;
+  if (typeof ttlMs !== "number" || !Number.isInteger(ttlMs) || ttlMs < 60_000 || ttlMs > MAX_SHARE_TTL_MS) {
+    return Response.json(
+      { error: "expiresIn must be between 1 minute and 30 days (in milliseconds)" },
+      { status: 400 }
+    );
+  }
+
+  try {
+    // Only the owner can hand out access; team members get 404 like DELETE.
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access || !access.isOwner) {
+      return applyRateLimitHeaders(
+        Response.json({ error: "Endpoint not found" }, { status: 404 }),
+        rateLimit
+      );
+    }
+
+    const result = await createShareToken({
+      endpointId: access.endpointId,
+      userId: auth.userId,
+      ttlMs,
+    });
+    if ("error" in result) {
+      return applyRateLimitHeaders(Response.json({ error: result.error }, { status: 409 }), rateLimit);
+    }
+
+    return applyRateLimitHeaders(
+      Response.json({ ...result.record, token: result.token, readOnly: true }),
+      rateLimit
+    );
+  } catch (error) {
+    console.error("Failed to create share token:", error);
+    return applyRateLimitHeaders(
+      Response.json({ error: "Internal server error" }, { status: 500 }),
+      rateLimit
+    );
+  }
+}
@@ -0,0 +1,50 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import {
+  DEFAULT_LATENCY_WINDOW,
+  LATENCY_WINDOWS,
+  formatPrometheus,
+  isLatencyWindow,
+} from "@/lib/delivery-latency";
+import { getSinkLatency } from "@/lib/supabase/sink-latency";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+/**
+ * Function sink delivery latency per destination. `?window=1h|24h|7d`
+ * (default 24h); `?format=prometheus` returns the Prometheus text format
+ * for scraping instead of JSON.
+ */
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+  const url = new URL(request.url);
+  const window = url.searchParams.get("window") ?? DEFAULT_LATENCY_WINDOW;
+  if (!isLatencyWindow(window)) {
+    return Response.json({ error: "invalid_window" }, { status: 400 });
+  }
+  const format = url.searchParams.get("format") ?? "json";
+  if (format !== "json" && format !== "prometheus") {
+    return Response.json({ error: "invalid_format" }, { status: 400 });
+  }
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const since = Date.now() - LATENCY_WINDOWS[window];
+    const destinations = await getSinkLatency(access.endpointId, since);
+
+    if (format === "prometheus") {
+      return new Response(formatPrometheus(slug.toLowerCase(), destinations), {
+        headers: { "Content-Type": "text/plain; version=0.0.4; charset=utf-8" },
+      });
+    }
+    return Response.json({ window, since, destinations });
+  } catch (error) {
+    console.error("Failed to get sink latency:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
@@ -0,0 +1,24 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { listEndpointVersions } from "@/lib/supabase/endpoint-versions";
+import { resolveEndpointAccess } from "@/lib/supabase/teams";
+
+/** Configuration history (mock response, forwarding, transforms), newest first. */
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { slug } = await params;
+
+  try {
+    const access = await resolveEndpointAccess(auth.userId, slug);
+    if (!access) {
+      return Response.json({ error: "Endpoint not found" }, { status: 404 });
+    }
+
+    const versions = await listEndpointVersions(access.endpointId);
+    return Response.json(versions);
+  } catch (error) {
+    console.error("Failed to list endpoint versions:", error);
+    return Response.json({ error: "Internal server error" }, { status: 500 });
+  }
+}
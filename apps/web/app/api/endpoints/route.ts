@@ -3,12 +3,16 @@ import {
   extractBearerToken,
   validateBearerTokenWithPlan,
 } from "@/lib/api-auth";
+import { parseAutoExtend, type AutoExtendInput } from "@/lib/auto-extend";
+import { parseDemoConfig, type DemoConfig } from "@/lib/demo-mode";
 import {
   parseJsonBody,
   validateNotificationUrl,
   validateMockResponseField,
 } from "@/lib/request-validation";
 import { checkRateLimitByKeyWithInfo, applyRateLimitHeaders } from "@/lib/rate-limit";
+import { checkCustomSlug } from "@/lib/slugs";
+import { getCaptureFailureSummaries } from "@/lib/supabase/capture-failures";
 import { createEndpointForUser, listEndpointsForUser } from "@/lib/supabase/endpoints";
 import { getShareMetadataForOwnedEndpoints, getSharedEndpointsForUser } from "@/lib/supabase/teams";
 
@@ -31,9 +35,12 @@ export async function GET(request: Request) {
       isPro ? getSharedEndpointsForUser(auth.userId) : Promise.resolve([]),
     ]);
 
+    const captureFailures = await getCaptureFailureSummaries(endpoints.map((ep) => ep.id));
+
     const owned = endpoints.map((ep) => ({
       ...ep,
       sharedWith: shareMetadata.get(ep.id) ?? [],
+      ...(captureFailures.has(ep.id) ? { captureFailures: captureFailures.get(ep.id) } : {}),
     }));
 
     const shared = sharedEndpoints.map((ep) => ({
@@ -76,6 +83,18 @@ export async function POST(request: Request) {
     return Response.json({ error: "Name must be between 1 and 100 characters" }, { status: 400 });
   }
 
+  let slug: string | undefined;
+  if (body.slug !== undefined) {
+    if (typeof body.slug !== "string") {
+      return Response.json({ error: "slug must be a string" }, { status: 400 });
+    }
+    const slugCheck = checkCustomSlug(body.slug);
+    if (!slugCheck.valid) {
+      return Response.json({ error: slugCheck.message }, { status: 400 });
+    }
+    slug = slugCheck.slug;
+  }
+
   if (body.isEphemeral !== undefined && typeof body.isEphemeral !== "boolean") {
     return Response.json({ error: "isEphemeral must be a boolean" }, { status: 400 });
   }
@@ -96,9 +115,34 @@ export async function POST(request: Request) {
 
   const isEphemeral = body.isEphemeral === true || expiresAt !== undefined;
 
+  let demo: DemoConfig | undefined;
+  if (body.demo !== undefined && body.demo !== null) {
+    if (!isEphemeral) {
+      return Response.json({ error: "demo requires an ephemeral endpoint" }, { status: 400 });
+    }
+    const demoCheck = parseDemoConfig(body.demo);
+    if (!demoCheck.valid) {
+      return Response.json({ error: demoCheck.error }, { status: 400 });
+    }
+    demo = demoCheck.value;
+  }
+
+  let autoExtend: AutoExtendInput | undefined;
+  if (body.autoExtend !== undefined && body.autoExtend !== null) {
+    if (!isEphemeral) {
+      return Response.json({ error: "autoExtend requires an ephemeral endpoint" }, { status: 400 });
+    }
+    const autoExtendCheck = parseAutoExtend(body.autoExtend);
+    if (!autoExtendCheck.valid) {
+      return Response.json({ error: autoExtendCheck.error }, { status: 400 });
+    }
+    autoExtend = autoExtendCheck.value;
+  }
+
   try {
     const created = await createEndpointForUser({
       userId: auth.userId,
+      slug,
       name,
       isEphemeral,
       expiresAt,
@@ -110,10 +154,27 @@ export async function POST(request: Request) {
         typeof body.notificationUrl === "string" && body.notificationUrl.length > 0
           ? body.notificationUrl
           : undefined,
+      demo,
+      autoExtend,
     });
 
     return applyRateLimitHeaders(Response.json(created), rateLimit);
   } catch (error) {
+    if (error instanceof Error && error.message === "slug_taken") {
+      return applyRateLimitHeaders(
+        Response.json({ error: `The slug "${slug}" is already taken` }, { status: 409 }),
+        rateLimit
+      );
+    }
+    if (error instanceof Error && error.message === "auto_extend_limit_before_expiry") {
+      return applyRateLimitHeaders(
+        Response.json(
+          { error: "autoExtend.maxMs must reach past the endpoint's expiry" },
+          { status: 400 }
+        ),
+        rateLimit
+      );
+    }
     if (error instanceof Error && error.message.includes("Too many active demo endpoints")) {
       return applyRateLimitHeaders(
         Response.json({ error: error.message }, { status: 429 }),
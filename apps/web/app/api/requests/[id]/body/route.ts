@@ -0,0 +1,35 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { bodyDownloadUrl, objectStorageConfig } from "@/lib/object-storage";
+import { getRequestByIdForUser } from "@/lib/supabase/requests";
+
+/**
+ * A short-lived download URL for a body the receiver kept in object storage
+ * because it was over the inline limit. Requests with inline bodies have none.
+ */
+export async function GET(request: Request, { params }: { params: Promise<{ id: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { id } = await params;
+
+  try {
+    const data = await getRequestByIdForUser(auth.userId, id);
+    if (!data) {
+      return Response.json({ error: "not_found" }, { status: 404 });
+    }
+    if (!data.bodyRef) {
+      return Response.json({ error: "Request body is stored inline" }, { status: 404 });
+    }
+
+    const storage = objectStorageConfig();
+    if (!storage) {
+      return Response.json({ error: "Object storage is not configured" }, { status: 503 });
+    }
+
+    const { url, expiresAt } = bodyDownloadUrl(storage, data.bodyRef);
+    return Response.json({ url, expiresAt, size: data.size, bodyHash: data.bodyHash });
+  } catch (error) {
+    console.error("Failed to sign request body URL:", error);
+    return Response.json({ error: "Failed to get request body" }, { status: 500 });
+  }
+}
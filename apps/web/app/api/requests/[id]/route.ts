@@ -1,14 +1,19 @@
 import { authenticateRequest } from "@/lib/api-auth";
-import { getRequestByIdForUser } from "@/lib/supabase/requests";
+import { parseReveal, validateRequestAnnotation } from "@/lib/request-validation";
+import { annotateRequestForUser, getRequestByIdForUser } from "@/lib/supabase/requests";
 
 export async function GET(request: Request, { params }: { params: Promise<{ id: string }> }) {
   const auth = await authenticateRequest(request);
   if (!auth.success) return auth.response;
 
   const { id } = await params;
+  const reveal = parseReveal(new URL(request.url).searchParams.get("reveal"));
+  if (reveal === null) {
+    return Response.json({ error: "invalid_reveal" }, { status: 400 });
+  }
 
   try {
-    const data = await getRequestByIdForUser(auth.userId, id);
+    const data = await getRequestByIdForUser(auth.userId, id, reveal);
     if (!data) {
       return Response.json({ error: "not_found" }, { status: 404 });
     }
@@ -19,3 +24,46 @@ export async function GET(request: Request, { params }: { params: Promise<{ id:
     return Response.json({ error: "Failed to get request" }, { status: 500 });
   }
 }
+
+/**
+ * Annotate a captured request. Body: `{ "note"?: string | null, "tags"?: string[] }`.
+ * Each field given replaces the stored value; omitted fields are left alone.
+ */
+export async function PATCH(request: Request, { params }: { params: Promise<{ id: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const { id } = await params;
+  const reveal = parseReveal(new URL(request.url).searchParams.get("reveal"));
+  if (reveal === null) {
+    return Response.json({ error: "invalid_reveal" }, { status: 400 });
+  }
+
+  let body: Record<string, unknown>;
+  try {
+    body = (await request.json()) as Record<string, unknown>;
+  } catch {
+    return Response.json({ error: "Invalid JSON body" }, { status: 400 });
+  }
+
+  const check = validateRequestAnnotation(body.note, body.tags);
+  if (!check.valid) return check.response;
+  if (body.note === undefined && body.tags === undefined) {
+    return Response.json({ error: "Provide note or tags" }, { status: 400 });
+  }
+
+  try {
+    const data = await annotateRequestForUser(auth.userId, id, {
+      note: body.note as string | null | undefined,
+      tags: body.tags as string[] | undefined,
+    }, reveal);
+    if (!data) {
+      return Response.json({ error: "not_found" }, { status: 404 });
+    }
+
+    return Response.json(data);
+  } catch (error) {
+    console.error("Failed to annotate request:", error);
+    return Response.json({ error: "Failed to annotate request" }, { status: 500 });
+  }
+}
@@ -5,6 +5,7 @@ import {
   type RateLimitInfo,
 } from "@/lib/rate-limit";
 import { countSearchRequestsForUser } from "@/lib/supabase/search";
+import { isContentClass } from "@/lib/request-validation";
 import { sendError } from "@appsignal/nodejs";
 
 function parseOptionalInteger(
@@ -54,6 +55,14 @@ export async function GET(request: Request) {
     if (parsedTo.error) {
       return applyRateLimitHeaders(parsedTo.error, rateLimit);
     }
+    const contentClass = url.searchParams.get("contentClass") ?? undefined;
+    if (contentClass !== undefined && !isContentClass(contentClass)) {
+      return applyRateLimitHeaders(
+        Response.json({ error: "invalid_contentClass" }, { status: 400 }),
+        rateLimit
+      );
+    }
+
     const count = await countSearchRequestsForUser({
       userId,
       plan,
@@ -62,6 +71,7 @@ export async function GET(request: Request) {
       q: url.searchParams.get("q") ?? undefined,
       from: parsedFrom.value,
       to: parsedTo.value,
+      contentClass,
     });
 
     return applyRateLimitHeaders(Response.json({ count }), rateLimit);
@@ -154,4 +154,26 @@ describe("GET /api/search/requests", () => {
     await expect(response.json()).resolves.toEqual({ error: "invalid_limit" });
     expect(mockFns.searchRequestsForUser).not.toHaveBeenCalled();
   });
+
+  test("filters by content class and rejects unknown classes", async () => {
+    mockFns.extractBearerToken.mockReturnValue("token");
+    mockFns.validateBearerTokenWithPlan.mockResolvedValue({
+      userId: "user_123",
+      plan: "pro",
+    });
+    mockFns.searchRequestsForUser.mockResolvedValue([]);
+
+    const { GET } = await import("./route");
+    const ok = await GET(new Request("https://webhooks.cc/api/search/requests?contentClass=xml"));
+    expect(ok.status).toBe(200);
+    expect(mockFns.searchRequestsForUser).toHaveBeenCalledWith(
+      expect.objectContaining({ contentClass: "xml" })
+    );
+
+    mockFns.searchRequestsForUser.mockClear();
+    const bad = await GET(new Request("https://webhooks.cc/api/search/requests?contentClass=yaml"));
+    expect(bad.status).toBe(400);
+    await expect(bad.json()).resolves.toEqual({ error: "invalid_contentClass" });
+    expect(mockFns.searchRequestsForUser).not.toHaveBeenCalled();
+  });
 });
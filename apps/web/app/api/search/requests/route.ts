@@ -5,6 +5,7 @@ import {
   type RateLimitInfo,
 } from "@/lib/rate-limit";
 import { searchRequestsForUser } from "@/lib/supabase/search";
+import { isContentClass } from "@/lib/request-validation";
 import { sendError } from "@appsignal/nodejs";
 
 function parseOptionalInteger(
@@ -63,6 +64,14 @@ export async function GET(request: Request) {
       return applyRateLimitHeaders(parsedOffset.error, rateLimit);
     }
 
+    const contentClass = url.searchParams.get("contentClass") ?? undefined;
+    if (contentClass !== undefined && !isContentClass(contentClass)) {
+      return applyRateLimitHeaders(
+        Response.json({ error: "invalid_contentClass" }, { status: 400 }),
+        rateLimit
+      );
+    }
+
     const order = url.searchParams.get("order");
     const data = await searchRequestsForUser({
       userId,
@@ -75,6 +84,7 @@ export async function GET(request: Request) {
       limit: parsedLimit.value,
       offset: parsedOffset.value,
       order: order === "asc" ? "asc" : "desc",
+      contentClass,
     });
 
     return applyRateLimitHeaders(Response.json(data), rateLimit);
@@ -0,0 +1,106 @@
+import { beforeEach, describe, expect, test, vi } from "vitest";
+
+import { UNREADABLE_VALUE } from "@/lib/header-crypt";
+
+const KEY = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f";
+// Sealed by the receiver (header_crypt.rs) for user-1's "authorization" header.
+const SEALED = "enc:v1:jOHV+3/J7i6HhxNtIUsVHLoaevMYsMgKSyNXgTIARBvFn3LFtEZtxVAepaU=";
+
+const mockFns = vi.hoisted(() => ({
+  checkRateLimitByKeyWithInfo: vi.fn(),
+  resolveShareToken: vi.fn(),
+  from: vi.fn(),
+}));
+
+vi.mock("@/lib/env", () => ({
+  serverEnv: () => ({ HEADER_ENCRYPTION_KEY: KEY }),
+}));
+
+vi.mock("@/lib/rate-limit", () => ({
+  checkRateLimitByKeyWithInfo: mockFns.checkRateLimitByKeyWithInfo,
+  applyRateLimitHeaders: (res: Response) => res,
+}));
+
+vi.mock("@/lib/supabase/share-tokens", () => ({
+  hashShareToken: (token: string) => `hash:${token}`,
+  resolveShareToken: mockFns.resolveShareToken,
+}));
+
+vi.mock("@/lib/supabase/admin", () => ({
+  createAdminClient: () => ({ from: mockFns.from }),
+}));
+
+/** A query builder whose every filter chains and which resolves to `result`. */
+function query(result: unknown) {
+  const builder = {
+    select: () => builder,
+    eq: () => builder,
+    gte: () => builder,
+    order: () => builder,
+    limit: () => builder,
+    returns: () => builder,
+    maybeSingle: async () => result,
+    then: (resolve: (value: unknown) => void) => resolve(result),
+  };
+  return builder;
+}
+
+describe("GET /api/shared/requests", () => {
+  beforeEach(() => {
+    vi.resetModules();
+    vi.clearAllMocks();
+    mockFns.checkRateLimitByKeyWithInfo.mockResolvedValue({
+      allowed: true,
+      response: null,
+      limit: 120,
+      remaining: 119,
+      reset: 0,
+    });
+    mockFns.resolveShareToken.mockResolvedValue({
+      endpointId: "ep_1",
+      ownerId: "user-1",
+      slug: "demo",
+      name: "Demo",
+      expiresAt: 9_999_999_999_999,
+    });
+    mockFns.from.mockImplementation((table: string) =>
+      table === "users"
+        ? query({ data: { plan: "free" }, error: null })
+        : query({
+            data: [
+              {
+                id: "req_1",
+                endpoint_id: "ep_1",
+                method: "POST",
+                path: "/",
+                headers: { authorization: SEALED, "content-type": "application/json" },
+                body: "{}",
+                query_params: {},
+                ip: "127.0.0.1",
+                size: 2,
+                received_at: new Date().toISOString(),
+                tags: [],
+              },
+            ],
+            error: null,
+          })
+    );
+  });
+
+  test("keeps encrypted headers masked for share token holders", async () => {
+    const { GET } = await import("./route");
+    const response = await GET(
+      new Request("https://webhooks.cc/api/shared/requests", {
+        headers: { authorization: "Bearer whsh_token" },
+      })
+    );
+
+    expect(response.status).toBe(200);
+    const { requests } = await response.json();
+    expect(requests[0].headers).toEqual({
+      authorization: UNREADABLE_VALUE,
+      "content-type": "application/json",
+    });
+    expect(requests[0].masked).toEqual({ "header:authorization": "encrypted" });
+  });
+});
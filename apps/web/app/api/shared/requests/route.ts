@@ -0,0 +1,69 @@
+import { extractBearerToken } from "@/lib/api-auth";
+import { checkRateLimitByKeyWithInfo, applyRateLimitHeaders } from "@/lib/rate-limit";
+import { listRequestsForEndpoint } from "@/lib/supabase/requests";
+import { hashShareToken, resolveShareToken } from "@/lib/supabase/share-tokens";
+
+/**
+ * Read-only view of a single endpoint's requests for holders of a share token
+ * (`Authorization: Bearer whsh_...`). No account is required.
+ */
+export async function GET(request: Request) {
+  const token = extractBearerToken(request);
+  if (!token || !token.startsWith("whsh_")) {
+    return Response.json({ error: "Missing or invalid share token" }, { status: 401 });
+  }
+
+  const rateLimit = await checkRateLimitByKeyWithInfo(
+    `share-read:${hashShareToken(token).slice(0, 32)}`,
+    120,
+    60_000
+  );
+  if (rateLimit.response) return rateLimit.response;
+
+  const url = new URL(request.url);
+  const limit = url.searchParams.get("limit");
+  const since = url.searchParams.get("since");
+  const parsedLimit = limit ? Number(limit) : undefined;
+  const parsedSince = since ? Number(since) : undefined;
+
+  if (parsedLimit !== undefined && (!Number.isFinite(parsedLimit) || parsedLimit < 1)) {
+    return Response.json({ error: "invalid_limit" }, { status: 400 });
+  }
+  if (parsedSince !== undefined && (!Number.isFinite(parsedSince) || parsedSince < 0)) {
+    return Response.json({ error: "invalid_since" }, { status: 400 });
+  }
+
+  try {
+    const access = await resolveShareToken(token);
+    if (!access) {
+      return applyRateLimitHeaders(
+        Response.json({ error: "Share token is invalid, expired, or revoked" }, { status: 401 }),
+        rateLimit
+      );
+    }
+
+    const requests = await listRequestsForEndpoint({
+      endpointId: access.endpointId,
+      ownerId: access.ownerId,
+      limit: parsedLimit,
+      since: parsedSince,
+      // Share links never carry the owner's key to encrypted headers
+      reveal: false,
+    });
+
+    return applyRateLimitHeaders(
+      Response.json({
+        endpoint: { slug: access.slug, name: access.name },
+        expiresAt: access.expiresAt,
+        requests,
+      }),
+      rateLimit
+    );
+  } catch (error) {
+    console.error("Failed to list shared requests:", error);
+    return applyRateLimitHeaders(
+      Response.json({ error: "Failed to list requests" }, { status: 500 }),
+      rateLimit
+    );
+  }
+}
@@ -0,0 +1,54 @@
+import { authenticateRequest } from "@/lib/api-auth";
+import { checkRateLimitByKeyWithInfo, applyRateLimitHeaders } from "@/lib/rate-limit";
+import { checkCustomSlug } from "@/lib/slugs";
+import { isSlugInUse } from "@/lib/supabase/endpoints";
+
+const SLUG_CHECK_RATE_LIMIT_WINDOW_MS = 60_000;
+const SLUG_CHECK_RATE_LIMIT_MAX = 60;
+
+/** Whether a custom slug can be used for a new endpoint, and why not. */
+export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
+  const auth = await authenticateRequest(request);
+  if (!auth.success) return auth.response;
+
+  const rateLimit = await checkRateLimitByKeyWithInfo(
+    `slug-check:${auth.userId}`,
+    SLUG_CHECK_RATE_LIMIT_MAX,
+    SLUG_CHECK_RATE_LIMIT_WINDOW_MS
+  );
+  if (rateLimit.response) {
+    return rateLimit.response;
+  }
+
+  const { slug: input } = await params;
+  const check = checkCustomSlug(input);
+  if (!check.valid) {
+    return applyRateLimitHeaders(
+      Response.json({
+        slug: input.toLowerCase(),
+        available: false,
+        reason: check.reason,
+        message: check.message,
+      }),
+      rateLimit
+    );
+  }
+
+  try {
+    const taken = await isSlugInUse(check.slug);
+    return applyRateLimitHeaders(
+      Response.json({
+        slug: check.slug,
+        available: !taken,
+        ...(taken ? { reason: "taken", message: `The slug "${check.slug}" is already taken` } : {}),
+      }),
+      rateLimit
+    );
+  } catch (error) {
+    console.error("Failed to check slug availability:", error);
+    return applyRateLimitHeaders(
+      Response.json({ error: "Internal server error" }, { status: 500 }),
+      rateLimit
+    );
+  }
+}
@@ -1,32 +1,41 @@
 import { authenticateRequest } from "@/lib/api-auth";
-import { serverEnv } from "@/lib/env";
+import {
+  createRealtimeAdminClient,
+  MAX_CONNECTION_DURATION_MS,
+  parseKeepalive,
+  waitForSubscribed,
+} from "@/lib/event-stream";
+import { decryptHeaders } from "@/lib/header-crypt";
+import { maskedFields } from "@/lib/masked-fields";
+import { parseReveal } from "@/lib/request-validation";
+import { normalizeRedactions } from "@/lib/redaction";
+import { normalizeSchemaErrors } from "@/lib/schema-validation";
+import { compileStreamFilter, parseStreamFilter } from "@/lib/stream-filter";
 import { resolveEndpointAccess } from "@/lib/supabase/teams";
+import { recordApiUsage } from "@/lib/supabase/usage";
 import type { Database, Json } from "@/lib/supabase/database";
 import {
   byteaToBase64,
   listNewRequestsForEndpointByUser,
+  normalizeClientCert,
+  normalizeCloudEvent,
+  normalizeJwtClaims,
+  normalizeParts,
+  normalizeFrame,
+  normalizeResponse,
+  normalizeResponseOverride,
+  normalizeSizes,
+  normalizeTls,
+  normalizeTrailers,
   type RequestRecord,
 } from "@/lib/supabase/requests";
 import { sendError } from "@appsignal/nodejs";
-import { createClient, type RealtimeChannel } from "@supabase/supabase-js";
+import type { RealtimeChannel } from "@supabase/supabase-js";
 
 export const dynamic = "force-dynamic";
 
-const KEEPALIVE_INTERVAL_MS = 30_000;
-const MAX_CONNECTION_DURATION_MS = 30 * 60 * 1000;
-
 type RequestRow = Database["public"]["Tables"]["requests"]["Row"];
 
-function createRealtimeAdminClient() {
-  const env = serverEnv();
-  return createClient<Database>(env.SUPABASE_URL, env.SUPABASE_SERVICE_ROLE_KEY, {
-    auth: {
-      autoRefreshToken: false,
-      persistSession: false,
-    },
-  });
-}
-
 function asStringRecord(value: Json): Record<string, string> {
   if (!value || typeof value !== "object" || Array.isArray(value)) {
     return {};
@@ -41,13 +50,14 @@ function parseMillis(timestamp: string): number {
   return Date.parse(timestamp);
 }
 
-function toRequestRecord(row: RequestRow): RequestRecord {
-  return {
+/** `ownerId` opens encrypted headers; null leaves them masked. */
+function toRequestRecord(row: RequestRow, ownerId: string | null): RequestRecord {
+  const record: RequestRecord = {
     id: row.id,
     endpointId: row.endpoint_id,
     method: row.method,
     path: row.path,
-    headers: asStringRecord(row.headers),
+    headers: decryptHeaders(asStringRecord(row.headers), ownerId),
     body: row.body ?? undefined,
     bodyRaw: row.body_raw ? byteaToBase64(row.body_raw) : undefined,
     queryParams: asStringRecord(row.query_params),
@@ -55,7 +65,40 @@ function toRequestRecord(row: RequestRow): RequestRecord {
     ip: row.ip,
     size: row.size,
     receivedAt: parseMillis(row.received_at),
+    bodyHash: row.body_hash ?? undefined,
+    duplicateOf: row.duplicate_of ?? undefined,
+    mockVariant: row.mock_variant ?? undefined,
+    mockVersion: row.mock_version ?? undefined,
+    chaosFault: row.chaos_fault ?? undefined,
+    responseOverride: normalizeResponseOverride(row.response_override),
+    parts: normalizeParts(row.parts),
+    bodyRef: row.body_ref ?? undefined,
+    response: normalizeResponse(row.response),
+    frame: normalizeFrame(row.frame),
+    cloudEvent: normalizeCloudEvent(row.cloud_event),
+    httpVersion: row.http_version ?? undefined,
+    priority: row.priority || undefined,
+    clientCert: normalizeClientCert(row.client_cert),
+    tls: normalizeTls(row.tls),
+    trailers: normalizeTrailers(row.trailers, ownerId),
+    fingerprint: row.fingerprint ?? undefined,
+    provider: row.provider ?? undefined,
+    eventType: row.event_type ?? undefined,
+    contentClass: row.content_class ?? undefined,
+    signatureValid: row.signature_valid ?? undefined,
+    jwtValid: row.jwt_valid ?? undefined,
+    jwtClaims: normalizeJwtClaims(row.jwt_claims),
+    schemaValid: row.schema_valid ?? undefined,
+    schemaErrors: normalizeSchemaErrors(row.schema_errors),
+    sizes: normalizeSizes(row.sizes),
+    redactions: normalizeRedactions(row.redactions),
+    country: row.country ?? undefined,
+    asn: row.asn ?? undefined,
+    asOrg: row.as_org ?? undefined,
+    note: row.note ?? undefined,
+    tags: row.tags ?? [],
   };
+  return { ...record, masked: maskedFields(record) };
 }
 
 function toStreamRequest(record: RequestRecord) {
@@ -73,29 +116,42 @@ function toStreamRequest(record: RequestRecord) {
     ip: record.ip,
     size: record.size,
     receivedAt: record.receivedAt,
+    bodyHash: record.bodyHash,
+    duplicateOf: record.duplicateOf,
+    mockVariant: record.mockVariant,
+    mockVersion: record.mockVersion,
+    chaosFault: record.chaosFault,
+    responseOverride: record.responseOverride,
+    parts: record.parts,
+    bodyRef: record.bodyRef,
+    response: record.response,
+    frame: record.frame,
+    cloudEvent: record.cloudEvent,
+    httpVersion: record.httpVersion,
+    priority: record.priority,
+    clientCert: record.clientCert,
+    tls: record.tls,
+    trailers: record.trailers,
+    fingerprint: record.fingerprint,
+    provider: record.provider,
+    eventType: record.eventType,
+    contentClass: record.contentClass,
+    signatureValid: record.signatureValid,
+    jwtValid: record.jwtValid,
+    jwtClaims: record.jwtClaims,
+    schemaValid: record.schemaValid,
+    schemaErrors: record.schemaErrors,
+    sizes: record.sizes,
+    redactions: record.redactions,
+    country: record.country,
+    asn: record.asn,
+    asOrg: record.asOrg,
+    note: record.note,
+    tags: record.tags,
+    masked: record.masked,
   };
 }
 
-async function waitForSubscribed(channel: RealtimeChannel): Promise<void> {
-  await new Promise<void>((resolve, reject) => {
-    const timeout = setTimeout(() => {
-      reject(new Error("Timed out waiting for realtime subscription"));
-    }, 10_000);
-
-    channel.subscribe((status) => {
-      if (status === "SUBSCRIBED") {
-        clearTimeout(timeout);
-        resolve();
-      }
-
-      if (status === "CHANNEL_ERROR" || status === "TIMED_OUT") {
-        clearTimeout(timeout);
-        reject(new Error(`Realtime subscription failed with status ${status}`));
-      }
-    });
-  });
-}
-
 export async function GET(request: Request, { params }: { params: Promise<{ slug: string }> }) {
   const auth = await authenticateRequest(request);
   if (!auth.success) return auth.response;
@@ -114,11 +170,33 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
     return Response.json({ error: "Invalid since timestamp" }, { status: 400 });
   }
 
+  // The negotiated keepalive interval is echoed in the connected event.
+  const parsedKeepalive = parseKeepalive(url.searchParams);
+  if ("error" in parsedKeepalive) {
+    return Response.json({ error: parsedKeepalive.error }, { status: 400 });
+  }
+  const { keepaliveMs } = parsedKeepalive;
+
+  // Optional method/path/header filters: only matching requests are pushed.
+  const parsedFilter = parseStreamFilter(url.searchParams);
+  if ("error" in parsedFilter) {
+    return Response.json({ error: parsedFilter.error }, { status: 400 });
+  }
+  const { filter } = parsedFilter;
+  const matchesFilter = compileStreamFilter(filter);
+
+  // Encrypted header values are opened unless the consumer asks to keep them masked.
+  const reveal = parseReveal(url.searchParams.get("reveal"));
+  if (reveal === null) {
+    return Response.json({ error: "invalid_reveal" }, { status: 400 });
+  }
+
   const access = await resolveEndpointAccess(auth.userId, slug);
   if (!access) {
     return Response.json({ error: "Endpoint not found" }, { status: 404 });
   }
   const endpoint = { id: access.endpointId, slug };
+  const keyOwner = reveal ? access.ownerId : null;
 
   const encoder = new TextEncoder();
   const connectionStart = Date.now();
@@ -127,13 +205,19 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
     async start(controller) {
       controller.enqueue(
         encoder.encode(
-          `event: connected\ndata: ${JSON.stringify({ slug, endpointId: endpoint.id })}\n\n`
+          `event: connected\ndata: ${JSON.stringify({
+            slug,
+            endpointId: endpoint.id,
+            keepaliveMs,
+            ...(filter ? { filter } : {}),
+          })}\n\n`
         )
       );
 
       const abortSignal = request.signal;
       const supabase = createRealtimeAdminClient();
       const sentIds = new Set<string>();
+      const respondedIds = new Set<string>();
       let afterTimestamp = since ?? connectionStart;
       let keepaliveTimer: ReturnType<typeof setInterval> | null = null;
       let durationTimer: ReturnType<typeof setTimeout> | null = null;
@@ -165,6 +249,9 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
         if (closed) return;
         closed = true;
         cleanup();
+        recordApiUsage(auth.userId, {
+          streamSeconds: Math.round((Date.now() - connectionStart) / 1000),
+        });
         try {
           controller.close();
         } catch {
@@ -173,17 +260,30 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
       };
 
       const enqueueRequest = (record: RequestRecord) => {
-        if (closed || sentIds.has(record.id)) {
+        if (closed || sentIds.has(record.id) || !matchesFilter(record)) {
           return;
         }
 
         sentIds.add(record.id);
+        if (record.response) respondedIds.add(record.id);
         afterTimestamp = Math.max(afterTimestamp, record.receivedAt);
         controller.enqueue(
           encoder.encode(`event: request\ndata: ${JSON.stringify(toStreamRequest(record))}\n\n`)
         );
       };
 
+      // The receiver records the reply it sent just after the request is
+      // stored, so it usually arrives as an update to a request already sent.
+      const enqueueResponse = (record: RequestRecord) => {
+        if (closed || !record.response || !sentIds.has(record.id) || respondedIds.has(record.id)) {
+          return;
+        }
+
+        respondedIds.add(record.id);
+        const data = JSON.stringify({ _id: record.id, response: record.response });
+        controller.enqueue(encoder.encode(`event: response\ndata: ${data}\n\n`));
+      };
+
       abortSignal.addEventListener("abort", closeStream);
 
       keepaliveTimer = setInterval(() => {
@@ -193,7 +293,7 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
         } catch {
           closeStream();
         }
-      }, KEEPALIVE_INTERVAL_MS);
+      }, keepaliveMs);
 
       durationTimer = setTimeout(
         () => {
@@ -212,22 +312,40 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
         Math.max(0, MAX_CONNECTION_DURATION_MS - (Date.now() - connectionStart))
       );
 
-      requestsChannel = supabase.channel(`stream:requests:${endpoint.id}:${connectionStart}`).on(
-        "postgres_changes",
-        {
-          event: "INSERT",
-          schema: "public",
-          table: "requests",
-          filter: `endpoint_id=eq.${endpoint.id}`,
-        },
-        (payload) => {
-          try {
-            enqueueRequest(toRequestRecord(payload.new as RequestRow));
-          } catch (error) {
-            sendError(error instanceof Error ? error : new Error(String(error)));
+      requestsChannel = supabase
+        .channel(`stream:requests:${endpoint.id}:${connectionStart}`)
+        .on(
+          "postgres_changes",
+          {
+            event: "INSERT",
+            schema: "public",
+            table: "requests",
+            filter: `endpoint_id=eq.${endpoint.id}`,
+          },
+          (payload) => {
+            try {
+              enqueueRequest(toRequestRecord(payload.new as RequestRow, keyOwner));
+            } catch (error) {
+              sendError(error instanceof Error ? error : new Error(String(error)));
+            }
           }
-        }
-      );
+        )
+        .on(
+          "postgres_changes",
+          {
+            event: "UPDATE",
+            schema: "public",
+            table: "requests",
+            filter: `endpoint_id=eq.${endpoint.id}`,
+          },
+          (payload) => {
+            try {
+              enqueueResponse(toRequestRecord(payload.new as RequestRow, keyOwner));
+            } catch (error) {
+              sendError(error instanceof Error ? error : new Error(String(error)));
+            }
+          }
+        );
 
       endpointChannel = supabase.channel(`stream:endpoint:${endpoint.id}:${connectionStart}`).on(
         "postgres_changes",
@@ -260,6 +378,7 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
             slug,
             after: backlogCursor,
             limit: 100,
+            reveal,
           });
 
           if (backlog === null) {
@@ -274,6 +393,10 @@ export async function GET(request: Request, { params }: { params: Promise<{ slug
             break;
           }
 
+          // High-priority captures in each page go out first
+          for (const record of backlog.filter((r) => r.priority)) {
+            enqueueRequest(record);
+          }
           for (const record of backlog) {
             enqueueRequest(record);
           }
@@ -13,6 +13,7 @@ import {
   type Tab,
 } from "@/components/dashboard/request-detail";
 import { GettingStarted } from "@/components/dashboard/getting-started";
+import { CaptureFailureBanner } from "@/components/dashboard/capture-failure-banner";
 import { KeyboardShortcutsDialog } from "@/components/dashboard/keyboard-shortcuts-dialog";
 import { RequestDiff } from "@/components/dashboard/request-diff";
 import { RequestTimeline } from "@/components/dashboard/request-timeline";
@@ -642,6 +643,7 @@ export default function DashboardPage() {
         contentType: request.contentType,
         size: request.size,
         receivedAt: request.receivedAt,
+        priority: request.priority,
       }));
 
     const oldestRecent =
@@ -911,12 +913,20 @@ export default function DashboardPage() {
         slug={currentEndpoint.slug}
         mockResponse={currentEndpoint.mockResponse}
         notificationUrl={currentEndpoint.notificationUrl}
+        infoHeaders={currentEndpoint.infoHeaders}
         extra={
           hasRequests ? (
             <ExportDropdown onExportJson={handleExportJson} onExportCsv={handleExportCsv} />
           ) : undefined
         }
       />
+      {currentEndpoint.captureFailures && accessToken && (
+        <CaptureFailureBanner
+          accessToken={accessToken}
+          slug={currentEndpoint.slug}
+          failures={currentEndpoint.captureFailures}
+        />
+      )}
       <GettingStarted hasReceivedWebhook={hasRequests} />
 
       {/* Split pane or empty state */}
@@ -0,0 +1,66 @@
+"use client";
+
+import { useState } from "react";
+import { AlertTriangle, X } from "lucide-react";
+import {
+  acknowledgeDashboardCaptureFailures,
+  emitDashboardEndpointsChanged,
+} from "@/lib/dashboard-api";
+
+interface CaptureFailureBannerProps {
+  accessToken: string;
+  slug: string;
+  failures: { count: number; since: number; until: number };
+}
+
+/**
+ * Warns the owner that the receiver accepted requests it could not store
+ * (e.g. during a database outage). Dismissing acknowledges the failures.
+ */
+export function CaptureFailureBanner({ accessToken, slug, failures }: CaptureFailureBannerProps) {
+  const [dismissing, setDismissing] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  const handleDismiss = async () => {
+    setDismissing(true);
+    setError(null);
+    try {
+      await acknowledgeDashboardCaptureFailures(accessToken, slug);
+      emitDashboardEndpointsChanged();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to dismiss");
+      setDismissing(false);
+    }
+  };
+
+  const noun = failures.count === 1 ? "request was" : "requests were";
+
+  return (
+    <div className="border-b-2 border-foreground bg-destructive/10 px-4 py-3 shrink-0">
+      <div className="flex items-start justify-between gap-4">
+        <div className="flex items-start gap-2">
+          <AlertTriangle className="h-4 w-4 mt-0.5 shrink-0 text-destructive" />
+          <div className="text-sm">
+            <p className="font-bold">
+              {failures.count} {noun} received but could not be stored.
+            </p>
+            <p className="text-xs text-muted-foreground">
+              Between {new Date(failures.since).toLocaleString()} and{" "}
+              {new Date(failures.until).toLocaleString()}. Senders got a 200 response, so they
+              will not retry these deliveries.
+            </p>
+            {error && <p className="text-xs text-destructive mt-1">{error}</p>}
+          </div>
+        </div>
+        <button
+          onClick={handleDismiss}
+          disabled={dismissing}
+          className="p-1 hover:bg-muted transition-colors cursor-pointer disabled:opacity-50"
+          aria-label="Dismiss capture failure warning"
+        >
+          <X className="h-4 w-4" />
+        </button>
+      </div>
+    </div>
+  );
+}
@@ -63,11 +63,26 @@ interface EndpointSettingsDialogProps {
   mockResponse?: {
     status: number;
     body: string;
+    bodyBase64?: string;
     headers: Record<string, string>;
     delay?: number;
+    delayJitter?: number;
+    headerPolicy?: { allow?: string[]; block?: string[] };
+    byMethod?: Record<string, unknown>;
+    correlation?: { key: string; responses: Record<string, unknown> };
+    variants?: Record<string, unknown>[];
+    schedule?: Record<string, unknown>[];
+    rules?: Record<string, unknown>[];
+    sequence?: Record<string, unknown>;
+    script?: string;
+    echo?: boolean;
+    stream?: Record<string, unknown>;
+    representations?: Record<string, string>;
   };
   /** Current notification webhook URL. null = owned, not set. undefined = shared endpoint (hidden). */
   notificationUrl?: string | null;
+  /** Whether webhook responses expose quota and expiry headers. */
+  infoHeaders?: boolean;
 }
 
 function TeamSharingSection({
@@ -192,6 +207,7 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
     slug,
     mockResponse,
     notificationUrl: initialNotificationUrl,
+    infoHeaders: initialInfoHeaders = false,
   } = props;
   const { session } = useAuth();
   const router = useRouter();
@@ -201,8 +217,13 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
   const [mockStatus, setMockStatus] = useState(mockResponse?.status?.toString() || "200");
   const [mockBody, setMockBody] = useState(mockResponse?.body || "");
   const [mockDelay, setMockDelay] = useState(mockResponse?.delay?.toString() || "");
-  const [delayEnabled, setDelayEnabled] = useState(!!mockResponse?.delay);
+  const [mockJitter, setMockJitter] = useState(mockResponse?.delayJitter?.toString() || "");
+  const [mockEcho, setMockEcho] = useState(!!mockResponse?.echo);
+  const [delayEnabled, setDelayEnabled] = useState(
+    !!mockResponse?.delay || !!mockResponse?.delayJitter
+  );
   const [notificationUrl, setNotificationUrl] = useState(initialNotificationUrl || "");
+  const [infoHeaders, setInfoHeaders] = useState(initialInfoHeaders);
   const [isSaving, setIsSaving] = useState(false);
   const [isDeleting, setIsDeleting] = useState(false);
   const [confirmDelete, setConfirmDelete] = useState(false);
@@ -216,13 +237,16 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
       setMockStatus(mockResponse?.status?.toString() || "200");
       setMockBody(mockResponse?.body || "");
       setMockDelay(mockResponse?.delay?.toString() || "");
-      setDelayEnabled(!!mockResponse?.delay);
+      setMockJitter(mockResponse?.delayJitter?.toString() || "");
+      setMockEcho(!!mockResponse?.echo);
+      setDelayEnabled(!!mockResponse?.delay || !!mockResponse?.delayJitter);
       setNotificationUrl(initialNotificationUrl || "");
+      setInfoHeaders(initialInfoHeaders);
       setError(null);
       setConfirmDelete(false);
     }
     prevOpen.current = open;
-  }, [open, endpointName, mockResponse, initialNotificationUrl]);
+  }, [open, endpointName, mockResponse, initialNotificationUrl, initialInfoHeaders]);
 
   const handleSave = async () => {
     setIsSaving(true);
@@ -235,15 +259,40 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
       }
 
       const delayMs = delayEnabled && mockDelay ? parseInt(mockDelay, 10) : undefined;
-      const hasCustomMock = mockBody || mockStatus !== "200" || (delayMs && delayMs > 0);
+      const jitterMs = delayEnabled && mockJitter ? parseInt(mockJitter, 10) : undefined;
+      // A binary body set through the API is kept until a text body replaces it
+      const bodyBase64 = mockBody ? undefined : mockResponse?.bodyBase64;
+      const hasCustomMock =
+        mockBody ||
+        bodyBase64 ||
+        mockResponse?.stream ||
+        mockEcho ||
+        mockStatus !== "200" ||
+        (delayMs && delayMs > 0) ||
+        (jitterMs && jitterMs > 0);
       const updates: Record<string, unknown> = {
         name: name || undefined,
         mockResponse: hasCustomMock
           ? {
               status: parseStatusCode(mockStatus, 200),
               body: mockBody,
+              ...(bodyBase64 ? { bodyBase64 } : {}),
               headers: mockResponse?.headers || {},
               ...(delayMs && delayMs > 0 ? { delay: delayMs } : {}),
+              ...(jitterMs && jitterMs > 0 ? { delayJitter: jitterMs } : {}),
+              ...(mockResponse?.headerPolicy ? { headerPolicy: mockResponse.headerPolicy } : {}),
+              ...(mockResponse?.byMethod ? { byMethod: mockResponse.byMethod } : {}),
+              ...(mockResponse?.correlation ? { correlation: mockResponse.correlation } : {}),
+              ...(mockResponse?.variants ? { variants: mockResponse.variants } : {}),
+              ...(mockResponse?.schedule ? { schedule: mockResponse.schedule } : {}),
+              ...(mockResponse?.rules ? { rules: mockResponse.rules } : {}),
+              ...(mockResponse?.sequence ? { sequence: mockResponse.sequence } : {}),
+              ...(mockResponse?.script ? { script: mockResponse.script } : {}),
+              ...(mockEcho ? { echo: true } : {}),
+              ...(mockResponse?.stream && !mockEcho ? { stream: mockResponse.stream } : {}),
+              ...(mockResponse?.representations && !mockEcho
+                ? { representations: mockResponse.representations }
+                : {}),
             }
           : null,
       };
@@ -252,6 +301,9 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
       if (initialNotificationUrl !== undefined) {
         updates.notificationUrl = notificationUrl || null;
       }
+      if (infoHeaders !== initialInfoHeaders) {
+        updates.infoHeaders = infoHeaders;
+      }
       await updateDashboardEndpoint(accessToken, slug, updates);
       emitDashboardEndpointsChanged();
       setOpen(false);
@@ -344,7 +396,10 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
                 value={mockStatus}
                 onChange={(code) => {
                   setMockStatus(code);
-                  if (!mockBody || DEFAULT_BODY_VALUES.has(mockBody)) {
+                  if (
+                    !mockResponse?.bodyBase64 &&
+                    (!mockBody || DEFAULT_BODY_VALUES.has(mockBody))
+                  ) {
                     setMockBody(DEFAULT_BODIES[code] ?? "");
                   }
                 }}
@@ -361,8 +416,13 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
                   id="settings-body"
                   value={mockBody}
                   onChange={(e) => setMockBody(e.target.value)}
-                  placeholder='{"status": "ok"}'
+                  placeholder={
+                    mockResponse?.bodyBase64
+                      ? "Binary body set through the API; type here to replace it"
+                      : '{"status": "ok"}'
+                  }
                   rows={3}
+                  disabled={mockEcho}
                   className="border-2 border-foreground rounded-none text-sm font-mono"
                 />
                 <p className="text-xs text-muted-foreground">
@@ -370,6 +430,24 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
                 </p>
               </div>
 
+              <div className="space-y-2">
+                <label className="flex items-center gap-2 cursor-pointer">
+                  <input
+                    type="checkbox"
+                    checked={mockEcho}
+                    onChange={(e) => setMockEcho(e.target.checked)}
+                    className="accent-foreground"
+                  />
+                  <span className="font-bold uppercase tracking-wide text-xs">Echo Request</span>
+                </label>
+                {mockEcho && (
+                  <p className="text-xs text-muted-foreground">
+                    Replies with the request as received (method, path, query, headers, body,
+                    IP) as JSON instead of the body above, so senders can check what they sent.
+                  </p>
+                )}
+              </div>
+
               <div className="space-y-2">
                 <label className="flex items-center gap-2 cursor-pointer">
                   <input
@@ -377,7 +455,10 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
                     checked={delayEnabled}
                     onChange={(e) => {
                       setDelayEnabled(e.target.checked);
-                      if (!e.target.checked) setMockDelay("");
+                      if (!e.target.checked) {
+                        setMockDelay("");
+                        setMockJitter("");
+                      }
                     }}
                     className="accent-foreground"
                   />
@@ -402,8 +483,26 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
                       placeholder="0-30000ms"
                       className="neo-input w-full text-sm"
                     />
+                    <input
+                      id="settings-delay-jitter"
+                      type="number"
+                      min="0"
+                      max="30000"
+                      step="100"
+                      value={mockJitter}
+                      onChange={(e) => setMockJitter(e.target.value)}
+                      onBlur={() => {
+                        if (!mockJitter) return;
+                        const n = parseInt(mockJitter, 10);
+                        if (isNaN(n) || n < 0) setMockJitter("");
+                        else if (n > 30000) setMockJitter("30000");
+                      }}
+                      placeholder="Jitter, 0-30000ms"
+                      className="neo-input w-full text-sm"
+                    />
                     <p className="text-xs text-muted-foreground">
-                      Delay before sending the response (max 30s). Useful for testing timeouts.
+                      Delay before sending the response, plus a random extra of up to the jitter
+                      (max 30s in total). Useful for testing timeouts and retries.
                     </p>
                   </>
                 )}
@@ -438,6 +537,22 @@ export function EndpointSettingsDialog(props: EndpointSettingsDialogProps) {
                 </div>
               )}
 
+              <div className="border-2 border-foreground p-4 space-y-2">
+                <label className="flex items-center gap-2 cursor-pointer">
+                  <input
+                    type="checkbox"
+                    checked={infoHeaders}
+                    onChange={(e) => setInfoHeaders(e.target.checked)}
+                    className="accent-foreground"
+                  />
+                  <span className="font-bold uppercase tracking-wide text-xs">Info Headers</span>
+                </label>
+                <p className="text-xs text-muted-foreground">
+                  Add remaining quota and expiry headers (X-Webhook-Remaining-Quota,
+                  X-Webhook-Endpoint-Expires) to every webhook response.
+                </p>
+              </div>
+
               {/* Team Sharing — only for owned endpoints */}
               {initialNotificationUrl !== undefined && (
                 <TeamSharingSection
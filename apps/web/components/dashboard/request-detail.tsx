@@ -6,16 +6,20 @@ import {
   Copy,
   Check,
   ChevronDown,
+  EyeOff,
   Send,
   Settings,
+  ShieldCheck,
+  ShieldX,
   Link as LinkIcon,
   StickyNote,
   X,
+  Zap,
 } from "lucide-react";
 import { ReplayDialog } from "./replay-dialog";
 import { copyToClipboard } from "@/lib/clipboard";
-import { formatBytes } from "@/types/request";
-import type { Request, ClickHouseRequest } from "@/types/request";
+import { describeSizes, formatBytes } from "@/types/request";
+import type { Request, ClickHouseRequest, ClientCertificate, TlsDetails } from "@/types/request";
 import { WEBHOOK_BASE_URL, SKIP_HEADERS_FOR_CURL } from "@/lib/constants";
 import { detectFormat, formatBody, getFormatLabel } from "@/lib/format";
 import { getHighlightLanguage, highlightBody } from "@/lib/highlight";
@@ -147,7 +151,7 @@ export function RequestDetail({
 
   const curlCommand = useMemo(() => generateCurlCommand(request), [request]);
   const fullTime = new Date(request.receivedAt).toLocaleString();
-  const bodyFormat = detectFormat(request.contentType, request.body);
+  const bodyFormat = detectFormat(request.contentType, request.body, request.contentClass);
   const formattedBody = useMemo(
     () => (request.body ? formatBody(request.body, bodyFormat) : "(empty body)"),
     [request.body, bodyFormat]
@@ -183,8 +187,87 @@ export function RequestDetail({
               {request.method} {request.path}
             </div>
             <div className="text-xs text-muted-foreground font-mono mt-0.5 flex items-center gap-3 flex-wrap">
-              <span>{request.ip}</span>
-              <span>{formatBytes(request.size)}</span>
+              {"priority" in request && request.priority && (
+                <span className="flex items-center gap-1 text-destructive font-bold">
+                  <Zap className="h-3 w-3 fill-destructive" />
+                  PRIORITY
+                </span>
+              )}
+              {"signatureValid" in request && request.signatureValid !== undefined && (
+                <span
+                  className={cn(
+                    "flex items-center gap-1 font-bold",
+                    request.signatureValid ? "text-primary" : "text-destructive"
+                  )}
+                >
+                  {request.signatureValid ? (
+                    <ShieldCheck className="h-3 w-3" />
+                  ) : (
+                    <ShieldX className="h-3 w-3" />
+                  )}
+                  {request.signatureValid ? "SIGNED" : "BAD SIGNATURE"}
+                </span>
+              )}
+              {"jwtValid" in request && request.jwtValid !== undefined && (
+                <span
+                  className={cn(
+                    "flex items-center gap-1 font-bold",
+                    request.jwtValid ? "text-primary" : "text-destructive"
+                  )}
+                  title={request.jwtClaims ? JSON.stringify(request.jwtClaims, null, 2) : undefined}
+                >
+                  {request.jwtValid ? (
+                    <ShieldCheck className="h-3 w-3" />
+                  ) : (
+                    <ShieldX className="h-3 w-3" />
+                  )}
+                  {request.jwtValid ? "JWT" : "BAD JWT"}
+                </span>
+              )}
+              {"schemaValid" in request && request.schemaValid !== undefined && (
+                <span
+                  className={cn(
+                    "flex items-center gap-1 font-bold",
+                    request.schemaValid ? "text-primary" : "text-destructive"
+                  )}
+                  title={request.schemaErrors
+                    ?.map((error) => `${error.path || "/"}: ${error.message}`)
+                    .join("\n")}
+                >
+                  {request.schemaValid ? (
+                    <ShieldCheck className="h-3 w-3" />
+                  ) : (
+                    <ShieldX className="h-3 w-3" />
+                  )}
+                  {request.schemaValid ? "SCHEMA" : "SCHEMA FAIL"}
+                </span>
+              )}
+              {"redactions" in request && request.redactions && (
+                <span
+                  className="flex items-center gap-1 font-bold"
+                  title={Object.entries(request.redactions)
+                    .map(([rule, count]) => `${rule}: ${count}`)
+                    .join("\n")}
+                >
+                  <EyeOff className="h-3 w-3" />
+                  REDACTED
+                </span>
+              )}
+              <span
+                title={
+                  "asn" in request && request.asn
+                    ? `AS${request.asn}${request.asOrg ? ` ${request.asOrg}` : ""}`
+                    : undefined
+                }
+              >
+                {request.ip}
+                {"country" in request && request.country && ` · ${request.country}`}
+              </span>
+              <span
+                title={"sizes" in request && request.sizes ? describeSizes(request.sizes) : undefined}
+              >
+                {formatBytes(request.size)}
+              </span>
               <span>{fullTime}</span>
             </div>
           </div>
@@ -290,6 +373,12 @@ export function RequestDetail({
           </div>
         )}
 
+        {tab === "headers" && "clientCert" in request && request.clientCert && (
+          <ClientCertTable cert={request.clientCert} />
+        )}
+
+        {tab === "headers" && "tls" in request && request.tls && <TlsTable tls={request.tls} />}
+
         {tab === "headers" && (
           <div className="neo-code overflow-x-auto">
             <table className="text-sm font-mono w-full">
@@ -338,6 +427,79 @@ export function RequestDetail({
   );
 }
 
+function ClientCertTable({ cert }: { cert: ClientCertificate }) {
+  const rows: Array<[string, string]> = [
+    ["Subject", cert.subject],
+    ["Issuer", cert.issuer],
+    ["Serial", cert.serial],
+    ["SHA-256", cert.fingerprint],
+    [
+      "Valid",
+      `${new Date(cert.notBefore).toLocaleString()} – ${new Date(cert.notAfter).toLocaleString()}`,
+    ],
+  ];
+  return (
+    <div className="mb-4">
+      <div className="flex items-center gap-2 mb-2">
+        <span className="px-2 py-0.5 text-[10px] font-bold uppercase tracking-wide border-2 border-foreground bg-muted">
+          Client certificate
+        </span>
+        {cert.verified && (
+          <span className="px-2 py-0.5 text-[10px] font-bold uppercase tracking-wide border-2 border-foreground bg-primary text-primary-foreground">
+            Verified
+          </span>
+        )}
+      </div>
+      <div className="neo-code overflow-x-auto">
+        <table className="text-sm font-mono w-full">
+          <tbody>
+            {rows.map(([key, value]) => (
+              <tr key={key} className="border-b border-foreground/20 last:border-0">
+                <td className="pr-4 py-1.5 text-muted-foreground font-semibold whitespace-nowrap align-top">
+                  {key}
+                </td>
+                <td className="py-1.5 break-all">{value}</td>
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      </div>
+    </div>
+  );
+}
+
+function TlsTable({ tls }: { tls: TlsDetails }) {
+  const rows: Array<[string, string]> = [
+    ["Version", tls.version],
+    ["Cipher", tls.cipher],
+    ...(tls.sni ? [["SNI", tls.sni] as [string, string]] : []),
+    ...(tls.alpn ? [["ALPN", tls.alpn] as [string, string]] : []),
+  ];
+  return (
+    <div className="mb-4">
+      <div className="flex items-center gap-2 mb-2">
+        <span className="px-2 py-0.5 text-[10px] font-bold uppercase tracking-wide border-2 border-foreground bg-muted">
+          TLS
+        </span>
+      </div>
+      <div className="neo-code overflow-x-auto">
+        <table className="text-sm font-mono w-full">
+          <tbody>
+            {rows.map(([key, value]) => (
+              <tr key={key} className="border-b border-foreground/20 last:border-0">
+                <td className="pr-4 py-1.5 text-muted-foreground font-semibold whitespace-nowrap align-top">
+                  {key}
+                </td>
+                <td className="py-1.5 break-all">{value}</td>
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      </div>
+    </div>
+  );
+}
+
 function jsonToCsvValue(json: string): string | null {
   let parsed: unknown;
   try {
@@ -14,6 +14,7 @@ import {
   List,
   GitCompareArrows,
   Clipboard,
+  Zap,
 } from "lucide-react";
 import { copyToClipboard } from "@/lib/clipboard";
 import {
@@ -429,6 +430,11 @@ export function RequestList({
             {request.method}
           </span>
           <span className="text-xs font-mono truncate flex-1">{request.path}</span>
+          {"priority" in request && request.priority && (
+            <span title="High priority">
+              <Zap className="h-2.5 w-2.5 text-destructive fill-destructive shrink-0" />
+            </span>
+          )}
           {isPinned && <Star className="h-2.5 w-2.5 text-amber-500 fill-amber-500 shrink-0" />}
           {hasNote && <StickyNote className="h-2.5 w-2.5 text-muted-foreground shrink-0" />}
           <span
@@ -18,6 +18,7 @@ interface UrlBarProps {
     headers: Record<string, string>;
   };
   notificationUrl?: string | null;
+  infoHeaders?: boolean;
   extra?: React.ReactNode;
 }
 
@@ -27,6 +28,7 @@ export function UrlBar({
   slug,
   mockResponse,
   notificationUrl,
+  infoHeaders,
   extra,
 }: UrlBarProps) {
   const [copied, setCopied] = useState(false);
@@ -50,6 +52,7 @@ export function UrlBar({
           slug={slug}
           mockResponse={mockResponse}
           notificationUrl={notificationUrl}
+          infoHeaders={infoHeaders}
         />
         <span className="font-bold text-sm uppercase tracking-wide shrink-0">{endpointName}</span>
 
@@ -3,8 +3,10 @@
  *
  * Validates API keys and Supabase session tokens against Supabase.
  */
+import { callUsage } from "./api-usage";
 import { createAdminClient } from "./supabase/admin";
 import { validateApiKeyWithMetadata } from "./supabase/api-keys";
+import { recordApiUsage } from "./supabase/usage";
 
 export type UserPlan = "free" | "pro";
 
@@ -76,6 +78,7 @@ export async function validateApiKeyWithPlan(apiKey: string): Promise<ApiKeyVali
 
 /**
  * Authenticate a request using a Bearer API key or Supabase session token.
+ * Successful calls are metered against the account's daily API usage.
  * Returns { success: true, userId } on success, or { success: false, response } on failure.
  */
 export type AuthResult = { success: true; userId: string } | { success: false; response: Response };
@@ -103,6 +106,7 @@ export async function authenticateRequest(request: Request): Promise<AuthResult>
     };
   }
 
+  recordApiUsage(userId, callUsage(request));
   return { success: true, userId };
 }
 
@@ -0,0 +1,44 @@
+import { describe, expect, test } from "vitest";
+
+import { API_USAGE_LIMITS, parseApiOperation, toApiUsage } from "./api-usage";
+
+describe("parseApiOperation", () => {
+  test("reads the operation header", () => {
+    const tagged = (value: string) =>
+      new Request("https://webhooks.cc/api/requests/r1", {
+        headers: { "X-Whk-Operation": value },
+      });
+    expect(parseApiOperation(tagged("replay"))).toBe("replay");
+    expect(parseApiOperation(tagged(" Export "))).toBe("export");
+    expect(parseApiOperation(tagged("delete"))).toBeNull();
+    expect(parseApiOperation(new Request("https://webhooks.cc/api/usage"))).toBeNull();
+  });
+});
+
+describe("toApiUsage", () => {
+  const now = Date.parse("2026-03-14T15:30:00Z");
+
+  test("reports today's counters against the plan limits", () => {
+    const usage = toApiUsage(
+      { api_calls: 1200, stream_seconds: 61, replays: 3, exports: 60 },
+      "free",
+      now
+    );
+    expect(usage.day).toBe("2026-03-14");
+    expect(usage.resetsAt).toBe(Date.parse("2026-03-15T00:00:00Z"));
+    expect(usage.apiCalls).toEqual({
+      used: 1200,
+      limit: API_USAGE_LIMITS.free.apiCalls,
+      remaining: API_USAGE_LIMITS.free.apiCalls - 1200,
+    });
+    expect(usage.streamMinutes.used).toBe(2);
+    expect(usage.exports).toEqual({ used: 60, limit: 50, remaining: 0 });
+  });
+
+  test("starts at zero without a row", () => {
+    const usage = toApiUsage(null, "pro", now);
+    expect(usage.plan).toBe("pro");
+    expect(usage.apiCalls.used).toBe(0);
+    expect(usage.replays.remaining).toBe(API_USAGE_LIMITS.pro.replays);
+  });
+});
@@ -0,0 +1,99 @@
+/**
+ * Per-account API metering. Authenticated API calls, stream connection time
+ * and request replays and exports are counted per UTC day in api_usage_daily
+ * and reported against the plan's daily limits, so automation can back off
+ * before it runs into them.
+ */
+import type { UserPlan } from "./api-auth";
+
+/** Header the CLI sets on the calls a replay or export makes. */
+export const API_OPERATION_HEADER = "x-whk-operation";
+
+export type ApiOperation = "replay" | "export";
+
+export interface ApiUsageLimits {
+  apiCalls: number;
+  streamMinutes: number;
+  replays: number;
+  exports: number;
+}
+
+/** Daily allowances. */
+export const API_USAGE_LIMITS: Record<UserPlan, ApiUsageLimits> = {
+  free: { apiCalls: 10_000, streamMinutes: 1_440, replays: 100, exports: 50 },
+  pro: { apiCalls: 200_000, streamMinutes: 28_800, replays: 5_000, exports: 1_000 },
+};
+
+export interface ApiUsageMeter {
+  used: number;
+  limit: number;
+  remaining: number;
+}
+
+export interface ApiUsage {
+  plan: UserPlan;
+  /** The UTC day being counted, `YYYY-MM-DD` */
+  day: string;
+  /** When the counters restart (next UTC midnight), in ms */
+  resetsAt: number;
+  apiCalls: ApiUsageMeter;
+  streamMinutes: ApiUsageMeter;
+  replays: ApiUsageMeter;
+  exports: ApiUsageMeter;
+}
+
+/** A row of api_usage_daily, or null when nothing was counted yet. */
+export interface ApiUsageRow {
+  api_calls: number;
+  stream_seconds: number;
+  replays: number;
+  exports: number;
+}
+
+/** What a call adds to the day's counters. */
+export interface ApiUsageDelta {
+  apiCalls?: number;
+  streamSeconds?: number;
+  replays?: number;
+  exports?: number;
+}
+
+/** The operation a request was tagged with, if any. */
+export function parseApiOperation(request: Request): ApiOperation | null {
+  const value = request.headers.get(API_OPERATION_HEADER)?.trim().toLowerCase();
+  return value === "replay" || value === "export" ? value : null;
+}
+
+/** One authenticated call, plus the replay or export it belongs to. */
+export function callUsage(request: Request): ApiUsageDelta {
+  const operation = parseApiOperation(request);
+  return {
+    apiCalls: 1,
+    replays: operation === "replay" ? 1 : 0,
+    exports: operation === "export" ? 1 : 0,
+  };
+}
+
+/** The UTC day containing `now`, `YYYY-MM-DD`. */
+export function usageDay(now = Date.now()): string {
+  return new Date(now).toISOString().slice(0, 10);
+}
+
+function meter(used: number, limit: number): ApiUsageMeter {
+  return { used, limit, remaining: Math.max(0, limit - used) };
+}
+
+export function toApiUsage(row: ApiUsageRow | null, plan: UserPlan, now = Date.now()): ApiUsage {
+  const limits = API_USAGE_LIMITS[plan];
+  const day = usageDay(now);
+  return {
+    plan,
+    day,
+    resetsAt: Date.parse(`${day}T00:00:00Z`) + 86_400_000,
+    apiCalls: meter(row?.api_calls ?? 0, limits.apiCalls),
+    // Started minutes count, so a short stream still shows up
+    streamMinutes: meter(Math.ceil((row?.stream_seconds ?? 0) / 60), limits.streamMinutes),
+    replays: meter(row?.replays ?? 0, limits.replays),
+    exports: meter(row?.exports ?? 0, limits.exports),
+  };
+}
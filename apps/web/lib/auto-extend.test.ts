@@ -0,0 +1,29 @@
+import { describe, expect, test } from "vitest";
+
+import { parseAutoExtend } from "./auto-extend";
+
+describe("parseAutoExtend", () => {
+  test("fills in the defaults", () => {
+    expect(parseAutoExtend({})).toEqual({
+      valid: true,
+      value: { idleMs: 900_000, maxMs: 86_400_000 },
+    });
+    expect(parseAutoExtend({ idleMs: 60_000, maxMs: 3_600_000 })).toEqual({
+      valid: true,
+      value: { idleMs: 60_000, maxMs: 3_600_000 },
+    });
+  });
+
+  test("rejects windows out of range", () => {
+    expect(parseAutoExtend(true).valid).toBe(false);
+    expect(parseAutoExtend({ idleMs: 59_999 }).valid).toBe(false);
+    expect(parseAutoExtend({ idleMs: 90_000.5 }).valid).toBe(false);
+    expect(parseAutoExtend({ idleMs: 86_400_001 }).valid).toBe(false);
+    expect(parseAutoExtend({ maxMs: 8 * 86_400_000 }).valid).toBe(false);
+    // The limit can't be shorter than one idle window
+    expect(parseAutoExtend({ idleMs: 3_600_000, maxMs: 600_000 })).toEqual({
+      valid: false,
+      error: "autoExtend.maxMs must be an integer between idleMs and 604800000 (7d)",
+    });
+  });
+});
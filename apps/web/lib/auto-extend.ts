@@ -0,0 +1,59 @@
+/**
+ * Auto-extension for ephemeral endpoints: while requests keep arriving the
+ * receiver slides `expiresAt` to the last capture plus `idleMs`, never past
+ * `until`, fixed when the endpoint is created.
+ */
+
+export interface AutoExtendInput {
+  /** Quiet time after the last capture before the endpoint expires */
+  idleMs: number;
+  /** How long after creation the endpoint may live at most */
+  maxMs: number;
+}
+
+export const DEFAULT_AUTO_EXTEND_IDLE_MS = 15 * 60 * 1000;
+export const DEFAULT_AUTO_EXTEND_MAX_MS = 24 * 60 * 60 * 1000;
+/** Bounds enforced by the endpoints_auto_extend_check constraint */
+export const MIN_AUTO_EXTEND_IDLE_MS = 60 * 1000;
+export const MAX_AUTO_EXTEND_IDLE_MS = 24 * 60 * 60 * 1000;
+export const MAX_AUTO_EXTEND_MAX_MS = 7 * 24 * 60 * 60 * 1000;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+function isDuration(value: unknown, min: number, max: number): value is number {
+  return typeof value === "number" && Number.isInteger(value) && value >= min && value <= max;
+}
+
+/** Validate the `autoExtend` field of an endpoint create request. */
+export function parseAutoExtend(value: unknown): ParseResult<AutoExtendInput> {
+  if (!value || typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "autoExtend must be an object" };
+  }
+  const input = value as Record<string, unknown>;
+  const config: AutoExtendInput = {
+    idleMs: DEFAULT_AUTO_EXTEND_IDLE_MS,
+    maxMs: DEFAULT_AUTO_EXTEND_MAX_MS,
+  };
+
+  if (input.idleMs !== undefined) {
+    if (!isDuration(input.idleMs, MIN_AUTO_EXTEND_IDLE_MS, MAX_AUTO_EXTEND_IDLE_MS)) {
+      return {
+        valid: false,
+        error: "autoExtend.idleMs must be an integer between 60000 (1m) and 86400000 (24h)",
+      };
+    }
+    config.idleMs = input.idleMs;
+  }
+
+  if (input.maxMs !== undefined) {
+    if (!isDuration(input.maxMs, config.idleMs, MAX_AUTO_EXTEND_MAX_MS)) {
+      return {
+        valid: false,
+        error: "autoExtend.maxMs must be an integer between idleMs and 604800000 (7d)",
+      };
+    }
+    config.maxMs = input.maxMs;
+  }
+
+  return { valid: true, value: config };
+}
@@ -0,0 +1,65 @@
+import { pbkdf2Sync } from "crypto";
+import { describe, expect, test } from "vitest";
+
+import { CAPTURE_AUTH_ITERATIONS, parseCaptureAuth, summarizeCaptureAuth } from "./capture-auth";
+
+/** Whether `stored` is a salted hash of `secret`, checked the way the receiver does. */
+function matches(stored: string, secret: string): boolean {
+  const [scheme, iterations, salt, hash] = stored.split("$");
+  if (scheme !== "pbkdf2-sha256" || Number(iterations) !== CAPTURE_AUTH_ITERATIONS) return false;
+  const derived = pbkdf2Sync(secret, Buffer.from(salt, "base64"), Number(iterations), 32, "sha256");
+  return derived.toString("base64") === hash;
+}
+
+function parsed(value: unknown): Record<string, string> {
+  const result = parseCaptureAuth(value);
+  if (!result.valid || !result.value) throw new Error("expected a config");
+  return result.value as Record<string, string>;
+}
+
+describe("parseCaptureAuth", () => {
+  test("hashes the password and key with a fresh salt", () => {
+    const basic = parsed({ type: "basic", username: "stripe", password: "p:ss" });
+    expect(basic).toMatchObject({ type: "basic", username: "stripe" });
+    expect(matches(basic.passwordHash, "p:ss")).toBe(true);
+    expect(matches(basic.passwordHash, "p:sS")).toBe(false);
+    const again = parsed({ type: "basic", username: "stripe", password: "p:ss" });
+    expect(again.passwordHash).not.toBe(basic.passwordHash);
+
+    const key = parsed({ type: "api_key", key: " k_123 " });
+    expect(key).toMatchObject({ type: "api_key", header: "x-api-key" });
+    expect(matches(key.keyHash, "k_123")).toBe(true);
+    expect(parsed({ type: "api_key", key: "k", header: " X-Token " })).toMatchObject({
+      header: "x-token",
+    });
+    expect(parseCaptureAuth(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects incomplete or malformed credentials", () => {
+    expect(parseCaptureAuth({}).valid).toBe(false);
+    expect(parseCaptureAuth({ type: "bearer", key: "k" }).valid).toBe(false);
+    expect(parseCaptureAuth({ type: "basic", username: "a:b", password: "p" }).valid).toBe(false);
+    expect(parseCaptureAuth({ type: "basic", username: "a", password: "" }).valid).toBe(false);
+    expect(parseCaptureAuth({ type: "basic", password: "p" }).valid).toBe(false);
+    expect(parseCaptureAuth({ type: "api_key", key: "  " }).valid).toBe(false);
+    expect(parseCaptureAuth({ type: "api_key", key: "k", header: "bad header" }).valid).toBe(
+      false
+    );
+    expect(parseCaptureAuth("secret").valid).toBe(false);
+  });
+});
+
+describe("summarizeCaptureAuth", () => {
+  const HASH =
+    "pbkdf2-sha256$1000$d2ViaG9va3MtY2Mtc2FsdA==$XZY1WHvGcA80P5SsEQMJq/gMhuLeUm5/77XJF1BgYNg=";
+
+  test("drops the hashes", () => {
+    expect(
+      summarizeCaptureAuth({ type: "basic", username: "stripe", passwordHash: HASH })
+    ).toEqual({ type: "basic", username: "stripe", header: null });
+    expect(
+      summarizeCaptureAuth({ type: "api_key", header: "x-api-key", keyHash: HASH })
+    ).toEqual({ type: "api_key", username: null, header: "x-api-key" });
+    expect(summarizeCaptureAuth(null)).toBeNull();
+  });
+});
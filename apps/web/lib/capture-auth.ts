@@ -0,0 +1,123 @@
+import { pbkdf2Sync, randomBytes } from "crypto";
+
+/**
+ * Per-endpoint capture credentials. The receiver answers requests that don't
+ * carry the endpoint's Basic credentials or API key with 401 `unauthorized`
+ * before reading the body, and counts them under the `unauthorized` rule of
+ * the network stats. Only salted PBKDF2-HMAC-SHA256 hashes of the password and
+ * key are stored, as `pbkdf2-sha256$<iterations>$<salt>$<hash>` (base64).
+ */
+export const DEFAULT_API_KEY_HEADER = "x-api-key";
+export const MAX_CAPTURE_USERNAME_LENGTH = 200;
+export const MAX_CAPTURE_SECRET_LENGTH = 512;
+/** PBKDF2 rounds for new hashes. The receiver remembers a secret once it matches. */
+export const CAPTURE_AUTH_ITERATIONS = 100_000;
+
+/** Stored form. */
+export type CaptureAuth =
+  | { type: "basic"; username: string; passwordHash: string }
+  | { type: "api_key"; header: string; keyHash: string };
+
+/** What the API returns: the password and key are write-only. */
+export interface CaptureAuthSummary {
+  type: "basic" | "api_key";
+  username: string | null;
+  header: string | null;
+}
+
+const HEADER_NAME_REGEX = /^[a-z0-9_-]{1,100}$/;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+function hashSecret(value: string): string {
+  const salt = randomBytes(16);
+  const hash = pbkdf2Sync(value, salt, CAPTURE_AUTH_ITERATIONS, 32, "sha256");
+  return [
+    "pbkdf2-sha256",
+    CAPTURE_AUTH_ITERATIONS,
+    salt.toString("base64"),
+    hash.toString("base64"),
+  ].join("$");
+}
+
+function isSecret(value: unknown): value is string {
+  return typeof value === "string" && value.length > 0 && value.length <= MAX_CAPTURE_SECRET_LENGTH;
+}
+
+/**
+ * Validate a `captureAuth` setting and hash its secret:
+ * `{ type: "basic", username, password }` or
+ * `{ type: "api_key", key, header? }` (header defaults to X-Api-Key).
+ * null removes the requirement.
+ */
+export function parseCaptureAuth(value: unknown): ParseResult<CaptureAuth | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "captureAuth must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+
+  if (input.type === "basic") {
+    const username = input.username;
+    if (
+      typeof username !== "string" ||
+      username.length === 0 ||
+      username.length > MAX_CAPTURE_USERNAME_LENGTH ||
+      username.includes(":")
+    ) {
+      return {
+        valid: false,
+        error: `captureAuth.username must be 1-${MAX_CAPTURE_USERNAME_LENGTH} characters without ':'`,
+      };
+    }
+    if (!isSecret(input.password)) {
+      return {
+        valid: false,
+        error: `captureAuth.password must be 1-${MAX_CAPTURE_SECRET_LENGTH} characters`,
+      };
+    }
+    return {
+      valid: true,
+      value: { type: "basic", username, passwordHash: hashSecret(input.password) },
+    };
+  }
+
+  if (input.type === "api_key") {
+    const header =
+      input.header === undefined
+        ? DEFAULT_API_KEY_HEADER
+        : typeof input.header === "string"
+          ? input.header.trim().toLowerCase()
+          : "";
+    if (!HEADER_NAME_REGEX.test(header)) {
+      return {
+        valid: false,
+        error: "captureAuth.header must be a header name (letters, digits, - and _)",
+      };
+    }
+    // The receiver trims the header value, so the stored key is trimmed too
+    const key = typeof input.key === "string" ? input.key.trim() : input.key;
+    if (!isSecret(key)) {
+      return {
+        valid: false,
+        error: `captureAuth.key must be 1-${MAX_CAPTURE_SECRET_LENGTH} characters`,
+      };
+    }
+    return { valid: true, value: { type: "api_key", header, keyHash: hashSecret(key) } };
+  }
+
+  return { valid: false, error: 'captureAuth.type must be "basic" or "api_key"' };
+}
+
+/** The stored config without its hash, for API responses. */
+export function summarizeCaptureAuth(value: unknown): CaptureAuthSummary | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  const config = value as Record<string, unknown>;
+  if (config.type === "basic" && typeof config.username === "string") {
+    return { type: "basic", username: config.username, header: null };
+  }
+  if (config.type === "api_key" && typeof config.header === "string") {
+    return { type: "api_key", username: null, header: config.header };
+  }
+  return null;
+}
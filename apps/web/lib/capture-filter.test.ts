@@ -0,0 +1,36 @@
+import { describe, expect, test } from "vitest";
+
+import { parseCaptureFilter } from "./capture-filter";
+
+describe("parseCaptureFilter", () => {
+  test("normalizes rules", () => {
+    expect(
+      parseCaptureFilter({
+        include: [{ methods: ["post", "POST"], path: "events/*" }],
+        exclude: [
+          { header: { name: " X-Event ", value: "^ping$" } },
+          { body: '"type":\\s*"ping"' },
+        ],
+      })
+    ).toEqual({
+      valid: true,
+      value: {
+        include: [{ methods: ["POST"], path: "/events/*" }],
+        exclude: [{ header: { name: "x-event", value: "^ping$" } }, { body: '"type":\\s*"ping"' }],
+      },
+    });
+    expect(parseCaptureFilter(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects malformed rules", () => {
+    expect(parseCaptureFilter({}).valid).toBe(false);
+    expect(parseCaptureFilter({ include: [] }).valid).toBe(false);
+    expect(parseCaptureFilter({ include: [{}] }).valid).toBe(false);
+    expect(parseCaptureFilter({ include: [{ methods: ["GET POST"] }] }).valid).toBe(false);
+    expect(parseCaptureFilter({ exclude: [{ header: { name: "bad header" } }] }).valid).toBe(false);
+    expect(parseCaptureFilter({ exclude: [{ body: "(unclosed" }] }).valid).toBe(false);
+    expect(parseCaptureFilter({ exclude: [{ body: "(?=ahead)" }] }).valid).toBe(false);
+    expect(parseCaptureFilter({ exclude: [{ path: "" }] }).valid).toBe(false);
+    expect(parseCaptureFilter("ping").valid).toBe(false);
+  });
+});
@@ -0,0 +1,153 @@
+import { UNSUPPORTED_PATTERN } from "./schema-validation";
+
+/**
+ * Per-endpoint capture filter, evaluated by the receiver on each request as
+ * received. A request is stored when it matches an `include` rule (or there
+ * are none) and no `exclude` rule. Requests left out still get the mock
+ * response but aren't stored or charged; they're counted under the
+ * `filtered` rule of the endpoint's network stats.
+ */
+export interface CaptureFilterRule {
+  /** Uppercase methods; any when missing */
+  methods?: string[];
+  /** Glob over the captured path: `*` within a segment, `**` across, `?` one character */
+  path?: string;
+  /** Header that must be present, with a regular expression its value must match */
+  header?: { name: string; value?: string };
+  /** Regular expression found in the body */
+  body?: string;
+}
+
+export interface CaptureFilter {
+  include?: CaptureFilterRule[];
+  exclude?: CaptureFilterRule[];
+}
+
+export const MAX_CAPTURE_FILTER_RULES = 20;
+export const MAX_CAPTURE_FILTER_PATTERN_LENGTH = 500;
+
+const METHOD_REGEX = /^[A-Z]{1,20}$/;
+const HEADER_NAME_REGEX = /^[a-z0-9_-]{1,100}$/;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+/** A pattern the receiver's regex crate can compile, or an error. */
+function checkPattern(pattern: unknown, field: string): ParseResult<string> {
+  if (
+    typeof pattern !== "string" ||
+    !pattern ||
+    pattern.length > MAX_CAPTURE_FILTER_PATTERN_LENGTH
+  ) {
+    return {
+      valid: false,
+      error: `${field} must be 1-${MAX_CAPTURE_FILTER_PATTERN_LENGTH} characters`,
+    };
+  }
+  try {
+    new RegExp(pattern, "u");
+  } catch {
+    return { valid: false, error: `${field} is not a valid regular expression` };
+  }
+  if (UNSUPPORTED_PATTERN.test(pattern)) {
+    return { valid: false, error: `${field} uses lookaround or backreferences (unsupported)` };
+  }
+  return { valid: true, value: pattern };
+}
+
+function parseRule(value: unknown, at: string): ParseResult<CaptureFilterRule> {
+  if (!value || typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: `${at} must be an object` };
+  }
+  const input = value as Record<string, unknown>;
+  const rule: CaptureFilterRule = {};
+
+  if (input.methods !== undefined) {
+    if (!Array.isArray(input.methods) || input.methods.some((m) => typeof m !== "string")) {
+      return { valid: false, error: `${at}.methods must be an array of strings` };
+    }
+    const methods = [...new Set(input.methods.map((m: string) => m.trim().toUpperCase()))];
+    if (methods.some((m) => !METHOD_REGEX.test(m))) {
+      return { valid: false, error: `${at}.methods must be HTTP methods` };
+    }
+    if (methods.length) rule.methods = methods;
+  }
+
+  if (input.path !== undefined) {
+    if (
+      typeof input.path !== "string" ||
+      !input.path.trim() ||
+      input.path.length > MAX_CAPTURE_FILTER_PATTERN_LENGTH
+    ) {
+      return {
+        valid: false,
+        error: `${at}.path must be a glob of 1-${MAX_CAPTURE_FILTER_PATTERN_LENGTH} characters`,
+      };
+    }
+    const path = input.path.trim();
+    rule.path = path.startsWith("/") || path.startsWith("*") ? path : `/${path}`;
+  }
+
+  if (input.header !== undefined) {
+    const header = input.header as Record<string, unknown> | null;
+    if (!header || typeof header !== "object" || Array.isArray(header)) {
+      return { valid: false, error: `${at}.header must be an object` };
+    }
+    const name = typeof header.name === "string" ? header.name.trim().toLowerCase() : "";
+    if (!HEADER_NAME_REGEX.test(name)) {
+      return {
+        valid: false,
+        error: `${at}.header.name must be a header name (letters, digits, - and _)`,
+      };
+    }
+    rule.header = { name };
+    if (header.value !== undefined) {
+      const pattern = checkPattern(header.value, `${at}.header.value`);
+      if (!pattern.valid) return pattern;
+      rule.header.value = pattern.value;
+    }
+  }
+
+  if (input.body !== undefined) {
+    const pattern = checkPattern(input.body, `${at}.body`);
+    if (!pattern.valid) return pattern;
+    rule.body = pattern.value;
+  }
+
+  if (!rule.methods && !rule.path && !rule.header && !rule.body) {
+    return { valid: false, error: `${at} needs methods, path, header or body` };
+  }
+  return { valid: true, value: rule };
+}
+
+/** Validate a `captureFilter` setting. Null stores every request again. */
+export function parseCaptureFilter(value: unknown): ParseResult<CaptureFilter | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "captureFilter must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+  const filter: CaptureFilter = {};
+
+  for (const field of ["include", "exclude"] as const) {
+    const rules = input[field];
+    if (rules === undefined) continue;
+    if (!Array.isArray(rules) || rules.length > MAX_CAPTURE_FILTER_RULES) {
+      return {
+        valid: false,
+        error: `captureFilter.${field} must be an array of up to ${MAX_CAPTURE_FILTER_RULES} rules`,
+      };
+    }
+    const parsed: CaptureFilterRule[] = [];
+    for (const [index, rule] of rules.entries()) {
+      const result = parseRule(rule, `captureFilter.${field}[${index}]`);
+      if (!result.valid) return result;
+      parsed.push(result.value);
+    }
+    if (parsed.length) filter[field] = parsed;
+  }
+
+  if (!filter.include && !filter.exclude) {
+    return { valid: false, error: "captureFilter needs at least one include or exclude rule" };
+  }
+  return { valid: true, value: filter };
+}
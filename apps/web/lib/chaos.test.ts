@@ -0,0 +1,25 @@
+import { describe, expect, test } from "vitest";
+
+import { parseChaos } from "./chaos";
+
+describe("parseChaos", () => {
+  test("accepts a percent with or without faults", () => {
+    expect(parseChaos({ percent: 10 })).toEqual({ valid: true, value: { percent: 10 } });
+    expect(parseChaos({ percent: 0.5, faults: ["reset", "error", "reset"], extra: 1 })).toEqual({
+      valid: true,
+      value: { percent: 0.5, faults: ["reset", "error"] },
+    });
+    expect(parseChaos(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects malformed settings", () => {
+    expect(parseChaos({}).valid).toBe(false);
+    expect(parseChaos({ percent: 0 }).valid).toBe(false);
+    expect(parseChaos({ percent: 101 }).valid).toBe(false);
+    expect(parseChaos({ percent: "10" }).valid).toBe(false);
+    expect(parseChaos({ percent: 10, faults: [] }).valid).toBe(false);
+    expect(parseChaos({ percent: 10, faults: ["slow"] }).valid).toBe(false);
+    expect(parseChaos({ percent: 10, faults: "error" }).valid).toBe(false);
+    expect(parseChaos([10]).valid).toBe(false);
+  });
+});
@@ -0,0 +1,57 @@
+/**
+ * Per-endpoint chaos mode: `percent` of requests get one of `faults`, picked
+ * evenly, in place of their reply, so senders' retries can be tested. The
+ * fault is recorded with each stored request as `chaosFault`. Mirrors
+ * chaos_valid() in migration 00077.
+ */
+export const CHAOS_FAULTS = ["error", "hang", "reset"] as const;
+
+/**
+ * - `error`: a random 500, 502, 503 or 504
+ * - `hang`: no reply until the receiver drops the connection
+ * - `reset`: the connection is dropped before the reply finishes
+ */
+export type ChaosFault = (typeof CHAOS_FAULTS)[number];
+
+export interface ChaosConfig {
+  /** Share of requests that get a fault, above 0 and up to 100 */
+  percent: number;
+  /** Faults to pick from; all of them when absent */
+  faults?: ChaosFault[];
+}
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+function isFault(value: unknown): value is ChaosFault {
+  return typeof value === "string" && (CHAOS_FAULTS as readonly string[]).includes(value);
+}
+
+/** Validate a `chaos` setting. Null turns chaos mode off. */
+export function parseChaos(value: unknown): ParseResult<ChaosConfig | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "chaos must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+
+  const percent = input.percent;
+  if (typeof percent !== "number" || !Number.isFinite(percent) || percent <= 0 || percent > 100) {
+    return { valid: false, error: "chaos.percent must be a number above 0 and up to 100" };
+  }
+  const config: ChaosConfig = { percent };
+
+  if (input.faults !== undefined) {
+    if (
+      !Array.isArray(input.faults) ||
+      input.faults.length === 0 ||
+      !input.faults.every(isFault)
+    ) {
+      return {
+        valid: false,
+        error: `chaos.faults must be a non-empty list of ${CHAOS_FAULTS.join(", ")}`,
+      };
+    }
+    config.faults = [...new Set(input.faults)];
+  }
+  return { valid: true, value: config };
+}
@@ -0,0 +1,50 @@
+import { describe, expect, test } from "vitest";
+
+import { parseClientCa } from "./client-ca";
+
+// CN=Acme Test CA,O=Acme Bank,C=GB
+const CA = `-----BEGIN CERTIFICATE-----
+MIIB1jCCAX2gAwIBAgIUAJTu6WSndV5Kaw3NqOu9XhfpGWEwCgYIKoZIzj0EAwIw
+ODELMAkGA1UEBhMCR0IxEjAQBgNVBAoMCUFjbWUgQmFuazEVMBMGA1UEAwwMQWNt
+ZSBUZXN0IENBMCAXDTI2MTAxNjE0NTU0MFoYDzIxMjYwOTIyMTQ1NTQwWjA4MQsw
+CQYDVQQGEwJHQjESMBAGA1UECgwJQWNtZSBCYW5rMRUwEwYDVQQDDAxBY21lIFRl
+c3QgQ0EwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAAT0MEoXp17I95yzOaBP9lUx
+9h4vUdPqZddA7Rwj8EI32aF6jB9elRHB8Qh8rn3yENPzJaFcOCDQmq1qvyIc/6Db
+o2MwYTAdBgNVHQ4EFgQUBAc5ByvEy+uxxxUANjjBTLSF/1owHwYDVR0jBBgwFoAU
+BAc5ByvEy+uxxxUANjjBTLSF/1owDwYDVR0TAQH/BAUwAwEB/zAOBgNVHQ8BAf8E
+BAMCAQYwCgYIKoZIzj0EAwIDRwAwRAIgb7kJVa8dO3QbVh6J4lF4bylvR5kWmI8f
+e9H7sAqLSPECIEe2EUsHdBJZZAELvhyZ/kI6DvEFJoaHbjI6P5DN2fXE
+-----END CERTIFICATE-----`;
+
+// CN=payouts.acme.example, issued by CA above (not itself a CA)
+const LEAF = `-----BEGIN CERTIFICATE-----
+MIIB8DCCAZagAwIBAgIDHy49MAoGCCqGSM49BAMCMDgxCzAJBgNVBAYTAkdCMRIw
+EAYDVQQKDAlBY21lIEJhbmsxFTATBgNVBAMMDEFjbWUgVGVzdCBDQTAgFw0yNjEw
+MTYxNDU1NDBaGA8yMTI2MDkyMjE0NTU0MFowUzELMAkGA1UEBhMCR0IxEjAQBgNV
+BAoMCUFjbWUgQmFuazERMA8GA1UECwwIUGF5bWVudHMxHTAbBgNVBAMMFHBheW91
+dHMuYWNtZS5leGFtcGxlMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEtAgEyjiA
+K9WDBw0wtnW1sFgMDIvtlXlDWPVS6Mh4Y+dUbQ7FehCwvuHaciYXOVflslGpNFLw
+5So/va6xI1zyd6NyMHAwCQYDVR0TBAIwADAOBgNVHQ8BAf8EBAMCB4AwEwYDVR0l
+BAwwCgYIKwYBBQUHAwIwHQYDVR0OBBYEFB+k1Tkfw7VohApcoBSOUnXqv6/fMB8G
+A1UdIwQYMBaAFAQHOQcrxMvrsccVADY4wUy0hf9aMAoGCCqGSM49BAMCA0gAMEUC
+IQCLbbc63kaKQvTz8hLYkF3Mn4GzfnYzS6vaMMuKKV+OsQIgBpqr/IytZQzOi1OU
+xMju3N99dksRImwvdPn727yoGzo=
+-----END CERTIFICATE-----`;
+
+describe("parseClientCa", () => {
+  test("keeps only the certificate blocks", () => {
+    expect(parseClientCa(`Acme Test CA\n${CA}\n\n`)).toEqual({ valid: true, value: `${CA}\n` });
+    expect(parseClientCa(null)).toEqual({ valid: true, value: null });
+    expect(parseClientCa("")).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects anything but CA certificates", () => {
+    expect(parseClientCa(42).valid).toBe(false);
+    expect(parseClientCa("not a certificate").valid).toBe(false);
+    expect(parseClientCa(LEAF).valid).toBe(false);
+    expect(
+      parseClientCa("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----").valid
+    ).toBe(false);
+    expect(parseClientCa(Array(11).fill(CA).join("\n")).valid).toBe(false);
+  });
+});
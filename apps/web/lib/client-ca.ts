@@ -0,0 +1,52 @@
+import { X509Certificate } from "node:crypto";
+
+/**
+ * Per-endpoint trusted CAs for client certificates, as a PEM bundle. When set,
+ * the receiver refuses captures that don't arrive over its mTLS listener with
+ * a certificate chaining to one of them (`client_certificate_required`).
+ * Requests that do are stored with `clientCert.verified`.
+ */
+export const MAX_CLIENT_CA_CERTIFICATES = 10;
+export const MAX_CLIENT_CA_LENGTH = 64 * 1024;
+
+const PEM_BLOCK_REGEX = /-----BEGIN CERTIFICATE-----[\s\S]+?-----END CERTIFICATE-----/g;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+/**
+ * Validate a `clientCa` setting: one or more PEM CA certificates. The bundle
+ * is stored as the certificate blocks alone; null or "" clears it.
+ */
+export function parseClientCa(value: unknown): ParseResult<string | null> {
+  if (value === null || value === "") return { valid: true, value: null };
+  if (typeof value !== "string") {
+    return { valid: false, error: "clientCa must be a PEM string or null" };
+  }
+  if (value.length > MAX_CLIENT_CA_LENGTH) {
+    return { valid: false, error: "clientCa must be at most 64KB" };
+  }
+
+  const blocks = value.match(PEM_BLOCK_REGEX) ?? [];
+  if (blocks.length === 0) {
+    return { valid: false, error: "clientCa must contain a PEM certificate" };
+  }
+  if (blocks.length > MAX_CLIENT_CA_CERTIFICATES) {
+    return {
+      valid: false,
+      error: `clientCa can hold at most ${MAX_CLIENT_CA_CERTIFICATES} certificates`,
+    };
+  }
+  for (const [index, block] of blocks.entries()) {
+    let cert: X509Certificate;
+    try {
+      cert = new X509Certificate(block);
+    } catch {
+      return { valid: false, error: `clientCa certificate ${index + 1} is not valid` };
+    }
+    if (!cert.ca) {
+      return { valid: false, error: `clientCa certificate ${index + 1} is not a CA certificate` };
+    }
+  }
+
+  return { valid: true, value: blocks.join("\n") + "\n" };
+}
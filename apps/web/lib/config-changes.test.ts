@@ -0,0 +1,39 @@
+import { describe, expect, test } from "vitest";
+
+import { describeConfigChanges } from "./config-changes";
+import type { EndpointConfig } from "./supabase/endpoints";
+
+const base: EndpointConfig = {
+  mockResponse: { status: 200, body: "ok", headers: {} },
+  notificationUrl: null,
+  functionSink: null,
+  bodyTransforms: null,
+  infoHeaders: false,
+};
+
+describe("describeConfigChanges", () => {
+  test("reports nothing for identical configs", () => {
+    expect(describeConfigChanges(base, { ...base })).toEqual([]);
+  });
+
+  test("classifies added, removed and edited settings", () => {
+    const after: EndpointConfig = {
+      ...base,
+      mockResponse: { status: 503, body: "down", headers: {} },
+      notificationUrl: "https://hooks.example.com/notify",
+      infoHeaders: true,
+    };
+    expect(describeConfigChanges(base, after)).toEqual([
+      { field: "mockResponse", change: "edited" },
+      { field: "notificationUrl", change: "added" },
+      { field: "infoHeaders", change: "added" },
+    ]);
+    expect(describeConfigChanges(after, { ...after, mockResponse: undefined })).toEqual([
+      { field: "mockResponse", change: "removed" },
+    ]);
+  });
+
+  test("treats every set field as added without a previous version", () => {
+    expect(describeConfigChanges(null, base)).toEqual([{ field: "mockResponse", change: "added" }]);
+  });
+});
@@ -0,0 +1,41 @@
+import type { EndpointConfig } from "./supabase/endpoints";
+
+export type ConfigField = keyof EndpointConfig;
+
+/** One setting that differs between two endpoint configuration versions. */
+export interface ConfigChange {
+  field: ConfigField;
+  /** `added`/`removed` when the setting was turned on or off, otherwise `edited` */
+  change: "added" | "removed" | "edited";
+}
+
+const CONFIG_FIELDS: ConfigField[] = [
+  "mockResponse",
+  "notificationUrl",
+  "functionSink",
+  "bodyTransforms",
+  "infoHeaders",
+];
+
+function isSet(value: unknown): boolean {
+  return value !== null && value !== undefined && value !== false;
+}
+
+/** The settings changed from `before` to `after`, in a fixed order. */
+export function describeConfigChanges(
+  before: EndpointConfig | null,
+  after: EndpointConfig
+): ConfigChange[] {
+  const changes: ConfigChange[] = [];
+  for (const field of CONFIG_FIELDS) {
+    const previous = before?.[field];
+    const next = after[field];
+    if (JSON.stringify(previous ?? null) === JSON.stringify(next ?? null)) continue;
+
+    changes.push({
+      field,
+      change: !isSet(previous) ? "added" : !isSet(next) ? "removed" : "edited",
+    });
+  }
+  return changes;
+}
@@ -0,0 +1,44 @@
+import { describe, expect, test } from "vitest";
+
+import { parseCors } from "./cors";
+
+describe("parseCors", () => {
+  test("normalizes origins, methods and headers", () => {
+    expect(
+      parseCors({
+        origins: ["https://App.example.com/", "http://localhost:3000", "https://app.example.com"],
+        methods: ["post", "PUT"],
+        headers: ["Authorization", "X-Trace"],
+        credentials: true,
+        maxAge: 600,
+      })
+    ).toEqual({
+      valid: true,
+      value: {
+        origins: ["https://app.example.com", "http://localhost:3000"],
+        methods: ["POST", "PUT"],
+        headers: ["authorization", "x-trace"],
+        credentials: true,
+        maxAge: 600,
+      },
+    });
+    expect(parseCors({ origins: ["*"], methods: [], credentials: false })).toEqual({
+      valid: true,
+      value: { origins: ["*"] },
+    });
+    expect(parseCors(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects malformed settings", () => {
+    expect(parseCors({}).valid).toBe(false);
+    expect(parseCors({ origins: [] }).valid).toBe(false);
+    expect(parseCors({ origins: ["app.example.com"] }).valid).toBe(false);
+    expect(parseCors({ origins: ["https://app.example.com/path"] }).valid).toBe(false);
+    expect(parseCors({ origins: ["*"], methods: ["GET POST"] }).valid).toBe(false);
+    expect(parseCors({ origins: ["*"], headers: ["bad header"] }).valid).toBe(false);
+    expect(parseCors({ origins: ["*"], credentials: "yes" }).valid).toBe(false);
+    expect(parseCors({ origins: ["*"], maxAge: 90000 }).valid).toBe(false);
+    expect(parseCors({ origins: ["*"], maxAge: 1.5 }).valid).toBe(false);
+    expect(parseCors("*").valid).toBe(false);
+  });
+});
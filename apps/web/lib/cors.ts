@@ -0,0 +1,122 @@
+/**
+ * Per-endpoint CORS. The receiver answers preflights for the endpoint itself
+ * (they aren't captured) and adds the CORS headers to its other responses.
+ * Without a config it allows any origin, method and header, without
+ * credentials. Mirrors cors_valid() in migration 00073.
+ */
+export interface CorsConfig {
+  /** Exact origins (`https://app.example.com`), or `*` for any */
+  origins: string[];
+  /** Allowed methods; empty or missing allows any */
+  methods?: string[];
+  /** Allowed request headers; empty or missing allows any */
+  headers?: string[];
+  credentials?: boolean;
+  /** Seconds browsers may cache a preflight answer */
+  maxAge?: number;
+}
+
+export const MAX_CORS_ENTRIES = 50;
+export const MAX_CORS_MAX_AGE = 86400;
+
+const ORIGIN_PATTERN = /^https?:\/\/[^/\s]{1,253}$/;
+const METHOD_PATTERN = /^[A-Z]{1,20}$/;
+const HEADER_PATTERN = /^[a-z0-9!#$%&'*+.^_`|~-]{1,100}$/;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+/** A list of strings, normalized and checked against `pattern`. */
+function parseList(
+  value: unknown,
+  field: string,
+  normalize: (item: string) => string,
+  pattern: (item: string) => boolean,
+  what: string
+): ParseResult<string[]> {
+  if (!Array.isArray(value) || value.length > MAX_CORS_ENTRIES) {
+    return { valid: false, error: `cors.${field} must be an array of up to ${MAX_CORS_ENTRIES}` };
+  }
+  const items: string[] = [];
+  for (const item of value) {
+    const normalized = typeof item === "string" ? normalize(item.trim()) : "";
+    if (!pattern(normalized)) {
+      return { valid: false, error: `cors.${field} entries must be ${what}` };
+    }
+    if (!items.includes(normalized)) items.push(normalized);
+  }
+  return { valid: true, value: items };
+}
+
+/**
+ * Validate a `cors` setting. Origins keep their scheme and port but lose a
+ * trailing slash; methods are uppercased and headers lowercased. null goes
+ * back to the permissive default.
+ */
+export function parseCors(value: unknown): ParseResult<CorsConfig | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "cors must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+
+  const origins = parseList(
+    input.origins,
+    "origins",
+    (origin) => origin.replace(/\/$/, "").toLowerCase(),
+    (origin) => origin === "*" || ORIGIN_PATTERN.test(origin),
+    'origins like "https://app.example.com", or "*"'
+  );
+  if (!origins.valid) return origins;
+  if (origins.value.length === 0) {
+    return { valid: false, error: "cors.origins must list at least one origin" };
+  }
+  const config: CorsConfig = { origins: origins.value };
+
+  if (input.methods !== undefined) {
+    const methods = parseList(
+      input.methods,
+      "methods",
+      (method) => method.toUpperCase(),
+      (method) => METHOD_PATTERN.test(method),
+      "HTTP methods"
+    );
+    if (!methods.valid) return methods;
+    if (methods.value.length > 0) config.methods = methods.value;
+  }
+
+  if (input.headers !== undefined) {
+    const headers = parseList(
+      input.headers,
+      "headers",
+      (header) => header.toLowerCase(),
+      (header) => HEADER_PATTERN.test(header),
+      "header names"
+    );
+    if (!headers.valid) return headers;
+    if (headers.value.length > 0) config.headers = headers.value;
+  }
+
+  if (input.credentials !== undefined) {
+    if (typeof input.credentials !== "boolean") {
+      return { valid: false, error: "cors.credentials must be a boolean" };
+    }
+    if (input.credentials) config.credentials = true;
+  }
+
+  if (input.maxAge !== undefined) {
+    if (
+      typeof input.maxAge !== "number" ||
+      !Number.isInteger(input.maxAge) ||
+      input.maxAge < 0 ||
+      input.maxAge > MAX_CORS_MAX_AGE
+    ) {
+      return {
+        valid: false,
+        error: `cors.maxAge must be an integer between 0 and ${MAX_CORS_MAX_AGE}`,
+      };
+    }
+    config.maxAge = input.maxAge;
+  }
+
+  return { valid: true, value: config };
+}
@@ -0,0 +1,30 @@
+import { describe, expect, test } from "vitest";
+
+import { parseCustomDomain } from "./custom-domain";
+
+describe("parseCustomDomain", () => {
+  test("stores hostnames lowercase without a trailing dot", () => {
+    expect(parseCustomDomain("Hooks.Example.com.")).toEqual({
+      valid: true,
+      value: "hooks.example.com",
+    });
+    expect(parseCustomDomain("a-b.example.co.uk")).toEqual({
+      valid: true,
+      value: "a-b.example.co.uk",
+    });
+    expect(parseCustomDomain(null)).toEqual({ valid: true, value: null });
+    expect(parseCustomDomain("")).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects anything but a hostname we don't own", () => {
+    expect(parseCustomDomain(42).valid).toBe(false);
+    expect(parseCustomDomain("localhost").valid).toBe(false);
+    expect(parseCustomDomain("192.168.0.1").valid).toBe(false);
+    expect(parseCustomDomain("hooks.example.com:8443").valid).toBe(false);
+    expect(parseCustomDomain("https://hooks.example.com").valid).toBe(false);
+    expect(parseCustomDomain("-hooks.example.com").valid).toBe(false);
+    expect(parseCustomDomain(`${"a".repeat(64)}.example.com`).valid).toBe(false);
+    expect(parseCustomDomain("webhooks.cc").valid).toBe(false);
+    expect(parseCustomDomain("acme.in.webhooks.cc").valid).toBe(false);
+  });
+});
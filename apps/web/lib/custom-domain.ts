@@ -0,0 +1,32 @@
+/**
+ * Per-endpoint custom domain (Pro). Once its DNS points at the receiver, every
+ * request to the hostname is captured by the endpoint; the receiver obtains
+ * its TLS certificate on the first request.
+ */
+export const MAX_CUSTOM_DOMAIN_LENGTH = 253;
+
+const HOSTNAME_REGEX = /^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$/;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+/**
+ * Validate a `customDomain` setting: a hostname with at least two labels,
+ * stored lowercase without a trailing dot. Our own domains can't be claimed;
+ * null or "" clears it.
+ */
+export function parseCustomDomain(value: unknown): ParseResult<string | null> {
+  if (value === null || value === "") return { valid: true, value: null };
+  if (typeof value !== "string") {
+    return { valid: false, error: "customDomain must be a hostname or null" };
+  }
+
+  const hostname = value.trim().toLowerCase().replace(/\.$/, "");
+  if (hostname.length > MAX_CUSTOM_DOMAIN_LENGTH || !HOSTNAME_REGEX.test(hostname)) {
+    return { valid: false, error: "customDomain must be a hostname like hooks.example.com" };
+  }
+  if (hostname === "webhooks.cc" || hostname.endsWith(".webhooks.cc")) {
+    return { valid: false, error: "customDomain can't be a webhooks.cc hostname" };
+  }
+
+  return { valid: true, value: hostname };
+}
@@ -1,6 +1,13 @@
 "use client";
 
-import type { ClickHouseRequest, Request } from "@/types/request";
+import type {
+  ClickHouseRequest,
+  ClientCertificate,
+  Request,
+  RequestSizes,
+  SchemaError,
+  TlsDetails,
+} from "@/types/request";
 
 export interface DashboardEndpoint {
   id: string;
@@ -13,6 +20,9 @@ export interface DashboardEndpoint {
     headers: Record<string, string>;
   };
   notificationUrl?: string | null;
+  infoHeaders?: boolean;
+  /** Requests received but not stored since the owner last acknowledged. */
+  captureFailures?: { count: number; since: number; until: number };
   isEphemeral?: boolean;
   expiresAt?: number;
   createdAt: number;
@@ -73,6 +83,20 @@ function toDashboardRequest(record: {
   ip: string;
   size: number;
   receivedAt: number;
+  priority?: boolean;
+  clientCert?: ClientCertificate;
+  tls?: TlsDetails;
+  contentClass?: string;
+  signatureValid?: boolean;
+  jwtValid?: boolean;
+  jwtClaims?: Record<string, unknown>;
+  schemaValid?: boolean;
+  schemaErrors?: SchemaError[];
+  sizes?: RequestSizes;
+  redactions?: Record<string, number>;
+  country?: string;
+  asn?: number;
+  asOrg?: string;
 }): Request {
   return {
     _id: record.id,
@@ -87,6 +111,20 @@ function toDashboardRequest(record: {
     ip: record.ip,
     size: record.size,
     receivedAt: record.receivedAt,
+    priority: record.priority,
+    clientCert: record.clientCert,
+    tls: record.tls,
+    contentClass: record.contentClass,
+    signatureValid: record.signatureValid,
+    jwtValid: record.jwtValid,
+    jwtClaims: record.jwtClaims,
+    schemaValid: record.schemaValid,
+    schemaErrors: record.schemaErrors,
+    sizes: record.sizes,
+    redactions: record.redactions,
+    country: record.country,
+    asn: record.asn,
+    asOrg: record.asOrg,
   };
 }
 
@@ -168,6 +206,19 @@ export async function deleteDashboardEndpoint(accessToken: string, slug: string)
   }
 }
 
+export async function acknowledgeDashboardCaptureFailures(
+  accessToken: string,
+  slug: string
+): Promise<void> {
+  const response = await fetch(
+    `/api/endpoints/${encodeURIComponent(slug)}/capture-failures`,
+    withAuthHeaders(accessToken, {
+      method: "DELETE",
+    })
+  );
+  await readJson(response);
+}
+
 export async function fetchDashboardRequests(
   accessToken: string,
   slug: string,
@@ -190,6 +241,9 @@ export async function fetchDashboardRequests(
       ip: string;
       size: number;
       receivedAt: number;
+      priority?: boolean;
+      clientCert?: ClientCertificate;
+      contentClass?: string;
     }>
   >(response);
 
@@ -0,0 +1,45 @@
+import { describe, expect, test } from "vitest";
+
+import { formatPrometheus, isLatencyWindow, type DestinationLatency } from "./delivery-latency";
+
+const ARN = "arn:aws:lambda:us-east-1:123456789012:function:orders";
+
+const latency: DestinationLatency = {
+  target: ARN,
+  deliveries: 120,
+  failed: 2,
+  retried: 5,
+  relay: { p50: 4, p90: 9, p99: 210 },
+  destination: { p50: 38, p90: 61, p99: 480.5 },
+  total: { p50: 43, p90: 72, p99: 690 },
+};
+
+describe("isLatencyWindow", () => {
+  test("accepts only the listed windows", () => {
+    expect(isLatencyWindow("24h")).toBe(true);
+    expect(isLatencyWindow("7d")).toBe(true);
+    expect(isLatencyWindow("30d")).toBe(false);
+    expect(isLatencyWindow("toString")).toBe(false);
+  });
+});
+
+describe("formatPrometheus", () => {
+  test("writes a summary per stage and the failure counts", () => {
+    const text = formatPrometheus("orders", [latency]);
+    const base = `slug="orders",target="${ARN}"`;
+
+    expect(text).toContain("# TYPE webhooks_cc_sink_latency_ms summary\n");
+    expect(text).toContain(`webhooks_cc_sink_latency_ms{${base},stage="relay",quantile="0.99"} 210\n`);
+    expect(text).toContain(
+      `webhooks_cc_sink_latency_ms{${base},stage="destination",quantile="0.99"} 480.5\n`
+    );
+    expect(text).toContain(`webhooks_cc_sink_latency_ms_count{${base},stage="total"} 120\n`);
+    expect(text).toContain(`webhooks_cc_sink_deliveries_failed{${base}} 2\n`);
+    expect(text).toContain(`webhooks_cc_sink_deliveries_retried{${base}} 5\n`);
+  });
+
+  test("escapes label values", () => {
+    const text = formatPrometheus("orders", [{ ...latency, target: 'a"b\\c' }]);
+    expect(text).toContain('target="a\\"b\\\\c"');
+  });
+});
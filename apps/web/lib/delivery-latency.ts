@@ -0,0 +1,97 @@
+/**
+ * Function sink delivery latency, as reported by the receiver
+ * (apps/receiver-rs/src/sink_latency.rs) and summarised by
+ * function_sink_latency(). Each delivery has two stages: relay (received
+ * until the final attempt was sent) and destination (until the destination
+ * responded); total is their sum.
+ */
+
+/** Windows the latency API accepts, in ms. Timings are kept for 7 days. */
+export const LATENCY_WINDOWS = {
+  "1h": 60 * 60 * 1000,
+  "24h": 24 * 60 * 60 * 1000,
+  "7d": 7 * 24 * 60 * 60 * 1000,
+} as const;
+
+export type LatencyWindow = keyof typeof LATENCY_WINDOWS;
+
+export const DEFAULT_LATENCY_WINDOW: LatencyWindow = "24h";
+
+export function isLatencyWindow(value: string): value is LatencyWindow {
+  return Object.hasOwn(LATENCY_WINDOWS, value);
+}
+
+/** Percentiles in milliseconds */
+export interface LatencyPercentiles {
+  p50: number;
+  p90: number;
+  p99: number;
+}
+
+export interface DestinationLatency {
+  /** Function ARN */
+  target: string;
+  deliveries: number;
+  /** Deliveries still failing after the last attempt */
+  failed: number;
+  /** Deliveries that needed more than one attempt */
+  retried: number;
+  relay: LatencyPercentiles;
+  destination: LatencyPercentiles;
+  total: LatencyPercentiles;
+}
+
+const STAGES = ["relay", "destination", "total"] as const;
+const QUANTILES = [
+  ["0.5", "p50"],
+  ["0.9", "p90"],
+  ["0.99", "p99"],
+] as const;
+
+function label(value: string): string {
+  return value.replace(/\\/g, "\\\\").replace(/"/g, '\\"').replace(/\n/g, "\\n");
+}
+
+/**
+ * Prometheus text exposition of one endpoint's latency: a summary per
+ * destination and stage, plus failed and retried delivery counts.
+ */
+export function formatPrometheus(slug: string, destinations: DestinationLatency[]): string {
+  const lines = [
+    "# HELP webhooks_cc_sink_latency_ms Function sink delivery latency by stage, in milliseconds.",
+    "# TYPE webhooks_cc_sink_latency_ms summary",
+  ];
+  for (const d of destinations) {
+    const base = `slug="${label(slug)}",target="${label(d.target)}"`;
+    for (const stage of STAGES) {
+      for (const [quantile, key] of QUANTILES) {
+        lines.push(
+          `webhooks_cc_sink_latency_ms{${base},stage="${stage}",quantile="${quantile}"} ${d[stage][key]}`
+        );
+      }
+      lines.push(`webhooks_cc_sink_latency_ms_count{${base},stage="${stage}"} ${d.deliveries}`);
+    }
+  }
+
+  lines.push(
+    "# HELP webhooks_cc_sink_deliveries_failed Function sink deliveries that failed after retries.",
+    "# TYPE webhooks_cc_sink_deliveries_failed gauge"
+  );
+  for (const d of destinations) {
+    lines.push(
+      `webhooks_cc_sink_deliveries_failed{slug="${label(slug)}",target="${label(d.target)}"} ${d.failed}`
+    );
+  }
+
+  lines.push(
+    "# HELP webhooks_cc_sink_deliveries_retried Function sink deliveries that needed a retry.",
+    "# TYPE webhooks_cc_sink_deliveries_retried gauge"
+  );
+  for (const d of destinations) {
+    lines.push(
+      `webhooks_cc_sink_deliveries_retried{slug="${label(slug)}",target="${label(d.target)}"} ${d.retried}`
+    );
+  }
+
+  return lines.join("\n") + "\n";
+}
@@ -0,0 +1,31 @@
+import { describe, expect, test } from "vitest";
+
+import { parseDemoConfig } from "./demo-mode";
+
+describe("parseDemoConfig", () => {
+  test("fills in the default rate", () => {
+    expect(parseDemoConfig({ provider: "github" })).toEqual({
+      valid: true,
+      value: { provider: "github", perMinute: 6 },
+    });
+    expect(
+      parseDemoConfig({ provider: "stripe", template: "invoice.paid", perMinute: 30 })
+    ).toEqual({
+      valid: true,
+      value: { provider: "stripe", template: "invoice.paid", perMinute: 30 },
+    });
+  });
+
+  test("rejects unknown providers, templates and rates", () => {
+    expect(parseDemoConfig("stripe").valid).toBe(false);
+    expect(parseDemoConfig({ provider: "paypal" })).toEqual({
+      valid: false,
+      error: "demo.provider must be one of: stripe, github, shopify",
+    });
+    expect(parseDemoConfig({ provider: "toString" }).valid).toBe(false);
+    expect(parseDemoConfig({ provider: "shopify", template: "push" }).valid).toBe(false);
+    expect(parseDemoConfig({ provider: "stripe", perMinute: 0 }).valid).toBe(false);
+    expect(parseDemoConfig({ provider: "stripe", perMinute: 1.5 }).valid).toBe(false);
+    expect(parseDemoConfig({ provider: "stripe", perMinute: 31 }).valid).toBe(false);
+  });
+});
@@ -0,0 +1,73 @@
+/**
+ * Synthetic captures for ephemeral demo endpoints. Each provider's templates
+ * match the ones generate_demo_requests (migration 00038) knows how to build.
+ */
+export const DEMO_TEMPLATES = {
+  stripe: ["payment_intent.succeeded", "checkout.session.completed", "invoice.paid"],
+  github: ["push", "pull_request.opened"],
+  shopify: ["orders/create", "orders/paid"],
+} as const;
+
+export type DemoProvider = keyof typeof DEMO_TEMPLATES;
+
+export interface DemoConfig {
+  provider: DemoProvider;
+  /** One of the provider's templates; they take turns when omitted */
+  template?: string;
+  /** Captures generated per minute */
+  perMinute: number;
+}
+
+export const DEFAULT_DEMO_PER_MINUTE = 6;
+export const MAX_DEMO_PER_MINUTE = 30;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+function isDemoProvider(value: unknown): value is DemoProvider {
+  return typeof value === "string" && Object.hasOwn(DEMO_TEMPLATES, value);
+}
+
+/** Validate the `demo` field of an endpoint create request. */
+export function parseDemoConfig(value: unknown): ParseResult<DemoConfig> {
+  if (!value || typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "demo must be an object" };
+  }
+  const input = value as Record<string, unknown>;
+
+  if (!isDemoProvider(input.provider)) {
+    return {
+      valid: false,
+      error: `demo.provider must be one of: ${Object.keys(DEMO_TEMPLATES).join(", ")}`,
+    };
+  }
+  const provider = input.provider;
+  const config: DemoConfig = { provider, perMinute: DEFAULT_DEMO_PER_MINUTE };
+
+  if (input.template !== undefined) {
+    const templates: readonly string[] = DEMO_TEMPLATES[provider];
+    if (typeof input.template !== "string" || !templates.includes(input.template)) {
+      return {
+        valid: false,
+        error: `demo.template for ${provider} must be one of: ${templates.join(", ")}`,
+      };
+    }
+    config.template = input.template;
+  }
+
+  if (input.perMinute !== undefined) {
+    if (
+      typeof input.perMinute !== "number" ||
+      !Number.isInteger(input.perMinute) ||
+      input.perMinute < 1 ||
+      input.perMinute > MAX_DEMO_PER_MINUTE
+    ) {
+      return {
+        valid: false,
+        error: `demo.perMinute must be an integer between 1 and ${MAX_DEMO_PER_MINUTE}`,
+      };
+    }
+    config.perMinute = input.perMinute;
+  }
+
+  return { valid: true, value: config };
+}
@@ -0,0 +1,28 @@
+import { describe, expect, test } from "vitest";
+
+import { parseRateLimit } from "./endpoint-rate-limit";
+
+describe("parseRateLimit", () => {
+  test("accepts a limit and window", () => {
+    expect(parseRateLimit({ limit: 100, window: 60 })).toEqual({
+      valid: true,
+      value: { limit: 100, window: 60 },
+    });
+    expect(parseRateLimit({ limit: 1, window: 86400, extra: true })).toEqual({
+      valid: true,
+      value: { limit: 1, window: 86400 },
+    });
+    expect(parseRateLimit(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects malformed settings", () => {
+    expect(parseRateLimit({}).valid).toBe(false);
+    expect(parseRateLimit({ limit: 10 }).valid).toBe(false);
+    expect(parseRateLimit({ window: 60 }).valid).toBe(false);
+    expect(parseRateLimit({ limit: 0, window: 60 }).valid).toBe(false);
+    expect(parseRateLimit({ limit: 1.5, window: 60 }).valid).toBe(false);
+    expect(parseRateLimit({ limit: 10, window: 86401 }).valid).toBe(false);
+    expect(parseRateLimit({ limit: "10", window: 60 }).valid).toBe(false);
+    expect(parseRateLimit([10, 60]).valid).toBe(false);
+  });
+});
@@ -0,0 +1,36 @@
+/**
+ * Per-endpoint rate-limit simulation: at most `limit` requests per fixed
+ * `window` of seconds are answered as usual; the rest get a 429 with
+ * `Retry-After`, and every reply reports the window in `X-RateLimit-*`
+ * headers, so senders' backoff can be tested. Throttled requests are still
+ * captured. Mirrors rate_limit_valid() in migration 00081.
+ */
+export interface RateLimitConfig {
+  /** Requests answered per window, 1-1000000 */
+  limit: number;
+  /** Window length in seconds, 1-86400 */
+  window: number;
+}
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+function isWholeBetween(value: unknown, min: number, max: number): value is number {
+  return typeof value === "number" && Number.isInteger(value) && value >= min && value <= max;
+}
+
+/** Validate a `rateLimit` setting. Null turns the simulation off. */
+export function parseRateLimit(value: unknown): ParseResult<RateLimitConfig | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "rateLimit must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+
+  if (!isWholeBetween(input.limit, 1, 1000000)) {
+    return { valid: false, error: "rateLimit.limit must be an integer from 1 to 1000000" };
+  }
+  if (!isWholeBetween(input.window, 1, 86400)) {
+    return { valid: false, error: "rateLimit.window must be a number of seconds from 1 to 86400" };
+  }
+  return { valid: true, value: { limit: input.limit, window: input.window } };
+}
@@ -28,6 +28,15 @@ const serverEnvSchema = z.object({
   SUPABASE_URL: z.string().url(),
   SUPABASE_SERVICE_ROLE_KEY: z.string().min(1),
   RECEIVER_INTERNAL_URL: z.string().url(),
+  HEADER_ENCRYPTION_KEY: z
+    .string()
+    .regex(/^[0-9a-fA-F]{64}$/)
+    .optional(),
+  OBJECT_STORAGE_URL: z.string().url().optional(),
+  OBJECT_STORAGE_BUCKET: z.string().min(1).optional(),
+  OBJECT_STORAGE_REGION: z.string().min(1).default("auto"),
+  OBJECT_STORAGE_ACCESS_KEY_ID: z.string().min(1).optional(),
+  OBJECT_STORAGE_SECRET_ACCESS_KEY: z.string().min(1).optional(),
 });
 
 /** Validated public env vars (available in both server and client). */
@@ -61,6 +70,12 @@ export function serverEnv() {
       SUPABASE_URL: process.env.SUPABASE_URL,
       SUPABASE_SERVICE_ROLE_KEY: process.env.SUPABASE_SERVICE_ROLE_KEY,
       RECEIVER_INTERNAL_URL: process.env.RECEIVER_INTERNAL_URL,
+      HEADER_ENCRYPTION_KEY: process.env.HEADER_ENCRYPTION_KEY || undefined,
+      OBJECT_STORAGE_URL: process.env.OBJECT_STORAGE_URL || undefined,
+      OBJECT_STORAGE_BUCKET: process.env.OBJECT_STORAGE_BUCKET || undefined,
+      OBJECT_STORAGE_REGION: process.env.OBJECT_STORAGE_REGION || undefined,
+      OBJECT_STORAGE_ACCESS_KEY_ID: process.env.OBJECT_STORAGE_ACCESS_KEY_ID || undefined,
+      OBJECT_STORAGE_SECRET_ACCESS_KEY: process.env.OBJECT_STORAGE_SECRET_ACCESS_KEY || undefined,
     });
   }
   return _serverEnv;
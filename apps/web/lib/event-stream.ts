@@ -0,0 +1,64 @@
+import { createClient, type RealtimeChannel } from "@supabase/supabase-js";
+import { serverEnv } from "./env";
+import type { Database } from "./supabase/database";
+
+/** Keepalive settings shared by the server-sent event routes. */
+const KEEPALIVE_INTERVAL_MS = 30_000;
+const MIN_KEEPALIVE_INTERVAL_MS = 5_000;
+const MAX_KEEPALIVE_INTERVAL_MS = 120_000;
+export const MAX_CONNECTION_DURATION_MS = 30 * 60 * 1000;
+
+/**
+ * Clients behind proxies that drop idle connections can ask for more frequent
+ * keepalive comments with `?keepalive=<seconds>`.
+ */
+export function parseKeepalive(
+  params: URLSearchParams
+): { keepaliveMs: number } | { error: string } {
+  const raw = params.get("keepalive");
+  const keepaliveMs = raw === null ? KEEPALIVE_INTERVAL_MS : Number(raw) * 1000;
+  if (
+    !Number.isInteger(keepaliveMs) ||
+    keepaliveMs < MIN_KEEPALIVE_INTERVAL_MS ||
+    keepaliveMs > MAX_KEEPALIVE_INTERVAL_MS
+  ) {
+    return {
+      error: `Invalid keepalive: must be ${MIN_KEEPALIVE_INTERVAL_MS / 1000}-${MAX_KEEPALIVE_INTERVAL_MS / 1000} seconds`,
+    };
+  }
+  return { keepaliveMs };
+}
+
+/**
+ * A service-role client of its own for one stream: it holds the stream's
+ * realtime socket, which is disconnected when the stream closes.
+ */
+export function createRealtimeAdminClient() {
+  const env = serverEnv();
+  return createClient<Database>(env.SUPABASE_URL, env.SUPABASE_SERVICE_ROLE_KEY, {
+    auth: {
+      autoRefreshToken: false,
+      persistSession: false,
+    },
+  });
+}
+
+export async function waitForSubscribed(channel: RealtimeChannel): Promise<void> {
+  await new Promise<void>((resolve, reject) => {
+    const timeout = setTimeout(() => {
+      reject(new Error("Timed out waiting for realtime subscription"));
+    }, 10_000);
+
+    channel.subscribe((status) => {
+      if (status === "SUBSCRIBED") {
+        clearTimeout(timeout);
+        resolve();
+      }
+
+      if (status === "CHANNEL_ERROR" || status === "TIMED_OUT") {
+        clearTimeout(timeout);
+        reject(new Error(`Realtime subscription failed with status ${status}`));
+      }
+    });
+  });
+}
@@ -1,11 +1,32 @@
 export type BodyFormat = "json" | "xml" | "form" | "text" | "binary";
 
-/** Detect the body format from content type and body content. */
-export function detectFormat(contentType?: string, body?: string): BodyFormat {
+/**
+ * Detect the body format. The receiver's `contentClass` decides when the
+ * request has one; older captures are judged by content type and content.
+ */
+export function detectFormat(
+  contentType?: string,
+  body?: string,
+  contentClass?: string
+): BodyFormat {
   if (!body) return "text";
 
   const ct = contentType?.toLowerCase() ?? "";
 
+  switch (contentClass) {
+    case "json":
+    case "xml":
+    case "text":
+      return contentClass;
+    case "form":
+      // Multipart bodies are forms too, but only urlencoded ones reformat
+      return ct.includes("multipart/") ? "text" : "form";
+    case "image":
+    case "protobuf":
+    case "binary":
+      return "binary";
+  }
+
   if (ct.includes("application/json") || ct.includes("+json")) return "json";
   if (ct.includes("xml") || ct.includes("+xml")) return "xml";
   if (ct.includes("application/x-www-form-urlencoded")) return "form";
@@ -0,0 +1,53 @@
+import { describe, expect, test } from "vitest";
+
+import { decryptHeaders, parseEncryptedHeaders, UNREADABLE_VALUE } from "./header-crypt";
+
+const KEY = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f";
+// Sealed by the receiver (header_crypt.rs) for user-1's "authorization" header.
+const SEALED = "enc:v1:jOHV+3/J7i6HhxNtIUsVHLoaevMYsMgKSyNXgTIARBvFn3LFtEZtxVAepaU=";
+
+describe("decryptHeaders", () => {
+  test("decrypts values sealed by the receiver", () => {
+    const headers = { Authorization: SEALED, "content-type": "application/json" };
+    expect(decryptHeaders(headers, "user-1", KEY)).toEqual({
+      Authorization: "Bearer sk_live_1",
+      "content-type": "application/json",
+    });
+  });
+
+  test("masks values for another account, header or without a key", () => {
+    expect(decryptHeaders({ authorization: SEALED }, "user-2", KEY)).toEqual({
+      authorization: UNREADABLE_VALUE,
+    });
+    expect(decryptHeaders({ "x-api-key": SEALED }, "user-1", KEY)).toEqual({
+      "x-api-key": UNREADABLE_VALUE,
+    });
+    expect(decryptHeaders({ authorization: SEALED }, "user-1", "")).toEqual({
+      authorization: UNREADABLE_VALUE,
+    });
+  });
+
+  test("returns plaintext headers untouched", () => {
+    const headers = { authorization: "Bearer x" };
+    expect(decryptHeaders(headers, "user-1", KEY)).toBe(headers);
+  });
+});
+
+describe("parseEncryptedHeaders", () => {
+  test("lowercases, de-duplicates and clears on empty", () => {
+    expect(parseEncryptedHeaders(["Authorization", "authorization", " X-Api-Key "])).toEqual({
+      valid: true,
+      headers: ["authorization", "x-api-key"],
+    });
+    expect(parseEncryptedHeaders([])).toEqual({ valid: true, headers: null });
+    expect(parseEncryptedHeaders(null)).toEqual({ valid: true, headers: null });
+  });
+
+  test("rejects bad names and long lists", () => {
+    expect(parseEncryptedHeaders("authorization")).toMatchObject({ valid: false });
+    expect(parseEncryptedHeaders(["x api key"])).toMatchObject({ valid: false });
+    expect(
+      parseEncryptedHeaders(Array.from({ length: 21 }, (_, i) => `x-h${i}`))
+    ).toMatchObject({ valid: false });
+  });
+});
@@ -0,0 +1,100 @@
+import { createDecipheriv, hkdfSync } from "node:crypto";
+import { serverEnv } from "./env";
+
+/**
+ * Headers an endpoint lists in `encryptedHeaders` are sealed by the receiver
+ * with the owner's account key (AES-256-GCM, key = HKDF-SHA256 of
+ * HEADER_ENCRYPTION_KEY with the owner's user id, header name as associated
+ * data) and stored as `enc:v1:<base64(nonce || ciphertext || tag)>`. Keep in
+ * sync with apps/receiver-rs/src/header_crypt.rs.
+ */
+export const ENCRYPTED_PREFIX = "enc:v1:";
+
+const HKDF_SALT = "webhooks.cc header encryption v1";
+const NONCE_LENGTH = 12;
+const TAG_LENGTH = 16;
+
+export const MAX_ENCRYPTED_HEADERS = 20;
+const HEADER_NAME_REGEX = /^[a-z0-9_-]{1,64}$/;
+
+/** Shown in place of a value that can't be decrypted here. */
+export const UNREADABLE_VALUE = "[encrypted]";
+
+export function accountKey(masterKeyHex: string, ownerId: string): Buffer {
+  return Buffer.from(
+    hkdfSync("sha256", Buffer.from(masterKeyHex, "hex"), HKDF_SALT, ownerId, 32)
+  );
+}
+
+function decryptValue(key: Buffer, name: string, value: string): string | null {
+  const data = Buffer.from(value.slice(ENCRYPTED_PREFIX.length), "base64");
+  if (data.length < NONCE_LENGTH + TAG_LENGTH) return null;
+  try {
+    const decipher = createDecipheriv("aes-256-gcm", key, data.subarray(0, NONCE_LENGTH));
+    decipher.setAAD(Buffer.from(name, "utf8"));
+    decipher.setAuthTag(data.subarray(data.length - TAG_LENGTH));
+    return Buffer.concat([
+      decipher.update(data.subarray(NONCE_LENGTH, data.length - TAG_LENGTH)),
+      decipher.final(),
+    ]).toString("utf8");
+  } catch {
+    return null;
+  }
+}
+
+/**
+ * Decrypt the encrypted values in a captured request's headers for a user
+ * with access to the endpoint owned by `ownerId`. Values that can't be
+ * decrypted (no key configured, or a key that has since been rotated) are
+ * replaced with UNREADABLE_VALUE.
+ */
+export function decryptHeaders(
+  headers: Record<string, string>,
+  ownerId: string | null | undefined,
+  masterKeyHex: string | undefined = serverEnv().HEADER_ENCRYPTION_KEY
+): Record<string, string> {
+  const names = Object.keys(headers).filter((name) => headers[name].startsWith(ENCRYPTED_PREFIX));
+  if (names.length === 0) return headers;
+
+  const key = masterKeyHex && ownerId ? accountKey(masterKeyHex, ownerId) : null;
+  const decrypted = { ...headers };
+  for (const name of names) {
+    const plain = key ? decryptValue(key, name.toLowerCase(), headers[name]) : null;
+    decrypted[name] = plain ?? UNREADABLE_VALUE;
+  }
+  return decrypted;
+}
+
+/**
+ * Validate an `encryptedHeaders` setting. Names are lowercased and
+ * de-duplicated; an empty list clears the setting.
+ */
+export function parseEncryptedHeaders(
+  value: unknown
+): { valid: true; headers: string[] | null } | { valid: false; error: string } {
+  if (value === null) return { valid: true, headers: null };
+  if (!Array.isArray(value)) {
+    return { valid: false, error: "encryptedHeaders must be an array of header names or null" };
+  }
+  const headers = new Set<string>();
+  for (const item of value) {
+    if (typeof item !== "string") {
+      return { valid: false, error: "encryptedHeaders must contain only strings" };
+    }
+    const name = item.trim().toLowerCase();
+    if (!HEADER_NAME_REGEX.test(name)) {
+      return {
+        valid: false,
+        error: `Invalid header name "${item}": use 1-64 letters, digits, "-" or "_"`,
+      };
+    }
+    headers.add(name);
+  }
+  if (headers.size > MAX_ENCRYPTED_HEADERS) {
+    return {
+      valid: false,
+      error: `encryptedHeaders can list at most ${MAX_ENCRYPTED_HEADERS} headers`,
+    };
+  }
+  return { valid: true, headers: headers.size > 0 ? [...headers] : null };
+}
@@ -0,0 +1,60 @@
+import { describe, expect, test } from "vitest";
+
+import { parseJwtVerification, summarizeJwtVerification } from "./jwt-verification";
+
+describe("parseJwtVerification", () => {
+  test("defaults the header and keeps optional claims", () => {
+    expect(parseJwtVerification({ secret: "s" })).toEqual({
+      valid: true,
+      value: { header: "authorization", secret: "s", reject: false },
+    });
+    expect(
+      parseJwtVerification({
+        header: " X-Zm-Token ",
+        jwksUrl: "https://example.com/.well-known/jwks.json",
+        issuer: "https://issuer.example.com",
+        audience: "webhooks",
+        reject: true,
+      })
+    ).toEqual({
+      valid: true,
+      value: {
+        header: "x-zm-token",
+        jwksUrl: "https://example.com/.well-known/jwks.json",
+        issuer: "https://issuer.example.com",
+        audience: "webhooks",
+        reject: true,
+      },
+    });
+    expect(parseJwtVerification(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("needs exactly one key source and an https JWKS URL", () => {
+    expect(parseJwtVerification({}).valid).toBe(false);
+    expect(parseJwtVerification({ secret: "s", jwksUrl: "https://a.test/jwks" }).valid).toBe(false);
+    expect(parseJwtVerification({ secret: "" }).valid).toBe(false);
+    expect(parseJwtVerification({ jwksUrl: "http://a.test/jwks" }).valid).toBe(false);
+    expect(parseJwtVerification({ jwksUrl: "not a url" }).valid).toBe(false);
+    expect(parseJwtVerification({ secret: "s", header: "bad header" }).valid).toBe(false);
+    expect(parseJwtVerification({ secret: "s", issuer: 1 }).valid).toBe(false);
+    expect(parseJwtVerification({ secret: "s", reject: "yes" }).valid).toBe(false);
+  });
+});
+
+describe("summarizeJwtVerification", () => {
+  test("drops the secret", () => {
+    const stored = { header: "authorization", secret: "s", reject: true };
+    expect(summarizeJwtVerification(stored)).toEqual({
+      header: "authorization",
+      mode: "secret",
+      jwksUrl: null,
+      issuer: null,
+      audience: null,
+      reject: true,
+    });
+    expect(
+      summarizeJwtVerification({ header: "authorization", jwksUrl: "https://a.test/jwks" })?.mode
+    ).toBe("jwks");
+    expect(summarizeJwtVerification(null)).toBeNull();
+  });
+});
@@ -0,0 +1,130 @@
+/**
+ * Per-endpoint JWT validation. The receiver checks the token in `header`
+ * against a shared secret (HS256/384/512) or the keys at `jwksUrl`, plus
+ * `exp`/`nbf` and the optional issuer and audience, and stores the verdict
+ * as `jwtValid` and the decoded payload as `jwtClaims`. With `reject` set,
+ * requests that fail get 401 `invalid_jwt` and aren't stored.
+ */
+export const DEFAULT_JWT_HEADER = "authorization";
+export const MAX_JWT_SECRET_LENGTH = 512;
+export const MAX_JWKS_URL_LENGTH = 2048;
+export const MAX_JWT_CLAIM_LENGTH = 512;
+
+/** Stored form; exactly one of `secret` and `jwksUrl` is set. */
+export interface JwtVerification {
+  header: string;
+  secret?: string;
+  jwksUrl?: string;
+  issuer?: string;
+  audience?: string;
+  reject: boolean;
+}
+
+/** What the API returns: the secret is write-only, so only its presence shows. */
+export interface JwtVerificationSummary {
+  header: string;
+  mode: "secret" | "jwks";
+  jwksUrl: string | null;
+  issuer: string | null;
+  audience: string | null;
+  reject: boolean;
+}
+
+const HEADER_NAME_REGEX = /^[a-z0-9_-]{1,100}$/;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+function parseJwksUrl(value: string): string | null {
+  if (value.length > MAX_JWKS_URL_LENGTH) return null;
+  try {
+    const url = new URL(value);
+    return url.protocol === "https:" && url.hostname ? url.toString() : null;
+  } catch {
+    return null;
+  }
+}
+
+/**
+ * Validate a `jwtVerification` setting. Takes a `secret` or an https
+ * `jwksUrl`, not both; the header defaults to Authorization. null removes
+ * validation.
+ */
+export function parseJwtVerification(value: unknown): ParseResult<JwtVerification | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "jwtVerification must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+
+  const header =
+    input.header === undefined
+      ? DEFAULT_JWT_HEADER
+      : typeof input.header === "string"
+        ? input.header.trim().toLowerCase()
+        : "";
+  if (!HEADER_NAME_REGEX.test(header)) {
+    return {
+      valid: false,
+      error: "jwtVerification.header must be a header name (letters, digits, - and _)",
+    };
+  }
+
+  if ((input.secret === undefined) === (input.jwksUrl === undefined)) {
+    return { valid: false, error: "jwtVerification needs exactly one of secret and jwksUrl" };
+  }
+  const result: JwtVerification = { header, reject: false };
+  if (input.secret !== undefined) {
+    if (
+      typeof input.secret !== "string" ||
+      input.secret.length === 0 ||
+      input.secret.length > MAX_JWT_SECRET_LENGTH
+    ) {
+      return {
+        valid: false,
+        error: `jwtVerification.secret must be 1-${MAX_JWT_SECRET_LENGTH} characters`,
+      };
+    }
+    result.secret = input.secret;
+  } else {
+    const jwksUrl = typeof input.jwksUrl === "string" ? parseJwksUrl(input.jwksUrl) : null;
+    if (!jwksUrl) {
+      return { valid: false, error: "jwtVerification.jwksUrl must be an https URL" };
+    }
+    result.jwksUrl = jwksUrl;
+  }
+
+  for (const field of ["issuer", "audience"] as const) {
+    const claim = input[field];
+    if (claim === undefined || claim === null) continue;
+    if (typeof claim !== "string" || claim.length === 0 || claim.length > MAX_JWT_CLAIM_LENGTH) {
+      return {
+        valid: false,
+        error: `jwtVerification.${field} must be 1-${MAX_JWT_CLAIM_LENGTH} characters`,
+      };
+    }
+    result[field] = claim;
+  }
+
+  if (input.reject !== undefined && typeof input.reject !== "boolean") {
+    return { valid: false, error: "jwtVerification.reject must be a boolean" };
+  }
+  result.reject = input.reject === true;
+
+  return { valid: true, value: result };
+}
+
+/** The stored config without its secret, for API responses. */
+export function summarizeJwtVerification(value: unknown): JwtVerificationSummary | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  const config = value as Record<string, unknown>;
+  if (typeof config.header !== "string") return null;
+  const jwksUrl = typeof config.jwksUrl === "string" ? config.jwksUrl : null;
+  return {
+    header: config.header,
+    mode: jwksUrl ? "jwks" : "secret",
+    jwksUrl,
+    issuer: typeof config.issuer === "string" ? config.issuer : null,
+    audience: typeof config.audience === "string" ? config.audience : null,
+    reject: config.reject === true,
+  };
+}
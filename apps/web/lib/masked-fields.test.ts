@@ -0,0 +1,33 @@
+import { describe, expect, test } from "vitest";
+
+import { UNREADABLE_VALUE } from "./header-crypt";
+import { maskedFields } from "./masked-fields";
+
+describe("maskedFields", () => {
+  test("marks redacted and unopened encrypted values", () => {
+    expect(
+      maskedFields({
+        headers: {
+          authorization: "[REDACTED]",
+          "x-api-key": UNREADABLE_VALUE,
+          "content-type": "application/json",
+        },
+        trailers: { "grpc-message": "token Bearer [REDACTED]" },
+        queryParams: { email: "[REDACTED]", page: "2" },
+        body: '{"email":"[REDACTED]","id":1}',
+      })
+    ).toEqual({
+      "header:authorization": "redacted",
+      "header:x-api-key": "encrypted",
+      "trailer:grpc-message": "redacted",
+      "query:email": "redacted",
+      body: "redacted",
+    });
+  });
+
+  test("is undefined when nothing is masked", () => {
+    expect(
+      maskedFields({ headers: { authorization: "Bearer x" }, queryParams: {}, body: "{}" })
+    ).toBeUndefined();
+  });
+});
@@ -0,0 +1,43 @@
+import { UNREADABLE_VALUE } from "./header-crypt";
+
+/** What the receiver puts in place of redacted values (`REDACTED` in redact.rs). */
+export const REDACTED_VALUE = "[REDACTED]";
+
+/**
+ * Why a field of a returned capture doesn't show what was sent: `redacted`
+ * by the receiver before storage (for good), or `encrypted` and not opened
+ * for this response (not asked to reveal, or no key that opens it).
+ */
+export type MaskReason = "redacted" | "encrypted";
+
+interface MaskableRequest {
+  headers: Record<string, string>;
+  trailers?: Record<string, string>;
+  queryParams: Record<string, string>;
+  body?: string;
+}
+
+/**
+ * The masked fields of a capture as it's returned, keyed `header:<name>`,
+ * `trailer:<name>`, `query:<name>` or `body`; undefined when nothing is
+ * masked. Clients use it to mark placeholders and to refuse exporting them.
+ */
+export function maskedFields(request: MaskableRequest): Record<string, MaskReason> | undefined {
+  const masked: Record<string, MaskReason> = {};
+  const scan = (prefix: string, values: Record<string, string> | undefined) => {
+    for (const [name, value] of Object.entries(values ?? {})) {
+      if (value === UNREADABLE_VALUE) {
+        masked[`${prefix}:${name}`] = "encrypted";
+      } else if (value.includes(REDACTED_VALUE)) {
+        masked[`${prefix}:${name}`] = "redacted";
+      }
+    }
+  };
+  scan("header", request.headers);
+  scan("trailer", request.trailers);
+  scan("query", request.queryParams);
+  if (request.body?.includes(REDACTED_VALUE)) {
+    masked.body = "redacted";
+  }
+  return Object.keys(masked).length > 0 ? masked : undefined;
+}
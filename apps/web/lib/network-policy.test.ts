@@ -0,0 +1,111 @@
+import { describe, expect, test } from "vitest";
+
+import { parseCidr, parseNetworkPolicy } from "./network-policy";
+
+describe("parseCidr", () => {
+  test("normalizes ranges and bare addresses", () => {
+    expect(parseCidr("203.0.113.0/24")).toEqual({ valid: true, value: "203.0.113.0/24" });
+    expect(parseCidr(" 198.51.100.7 ")).toEqual({ valid: true, value: "198.51.100.7/32" });
+    expect(parseCidr("2001:DB8::/32")).toEqual({ valid: true, value: "2001:db8::/32" });
+    expect(parseCidr("::ffff:10.0.0.0/104")).toEqual({
+      valid: true,
+      value: "::ffff:10.0.0.0/104",
+    });
+  });
+
+  test("rejects malformed ranges and host bits past the prefix", () => {
+    expect(parseCidr("10.0.0.0/33").valid).toBe(false);
+    expect(parseCidr("10.0.0/8").valid).toBe(false);
+    expect(parseCidr("10.0.0.0/8/8").valid).toBe(false);
+    expect(parseCidr("example.com/8").valid).toBe(false);
+    expect(parseCidr("10.0.0.1/8")).toEqual({
+      valid: false,
+      error: 'CIDR "10.0.0.1/8" has bits set past its /8 prefix',
+    });
+    expect(parseCidr("2001:db8::1/64").valid).toBe(false);
+  });
+});
+
+describe("parseNetworkPolicy", () => {
+  test("normalizes countries, ranges and tags", () => {
+    expect(
+      parseNetworkPolicy({
+        allow: { countries: ["us", "US", "ca"], cidrs: ["192.0.2.0/24"] },
+        tags: [{ tag: "aws", cidrs: ["3.0.0.0/9"] }],
+      })
+    ).toEqual({
+      valid: true,
+      value: {
+        allow: { countries: ["US", "CA"], cidrs: ["192.0.2.0/24"] },
+        tags: [{ tag: "aws", cidrs: ["3.0.0.0/9"] }],
+      },
+    });
+  });
+
+  test("accepts a deny rule and the rejected bucket", () => {
+    expect(
+      parseNetworkPolicy({
+        deny: { countries: ["t1"], cidrs: ["198.51.100.7"] },
+        captureRejected: true,
+      })
+    ).toEqual({
+      valid: true,
+      value: {
+        deny: { countries: ["T1"], cidrs: ["198.51.100.7/32"] },
+        captureRejected: true,
+      },
+    });
+    expect(
+      parseNetworkPolicy({ deny: { cidrs: ["10.0.0.0/8"] }, captureRejected: false })
+    ).toEqual({ valid: true, value: { deny: { cidrs: ["10.0.0.0/8"] } } });
+  });
+
+  test("accepts provider ranges", () => {
+    expect(
+      parseNetworkPolicy({ allow: { providers: ["GitHub", "stripe", "github"] } })
+    ).toEqual({ valid: true, value: { allow: { providers: ["github", "stripe"] } } });
+    expect(parseNetworkPolicy({ allow: { providers: ["gitlab"] } })).toEqual({
+      valid: false,
+      error: 'Unknown provider "gitlab" in networkPolicy.allow: use github, stripe',
+    });
+    expect(parseNetworkPolicy({ allow: { providers: "github" } }).valid).toBe(false);
+  });
+
+  test("null and empty policies clear the setting", () => {
+    expect(parseNetworkPolicy(null)).toEqual({ valid: true, value: null });
+    expect(parseNetworkPolicy({})).toEqual({ valid: true, value: null });
+    expect(parseNetworkPolicy({ tags: [] })).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects invalid rules", () => {
+    expect(parseNetworkPolicy([]).valid).toBe(false);
+    expect(parseNetworkPolicy({ allow: {} })).toEqual({
+      valid: false,
+      error: "networkPolicy.allow must list at least one country, CIDR or provider",
+    });
+    expect(parseNetworkPolicy({ allow: { countries: ["USA"] } }).valid).toBe(false);
+    expect(parseNetworkPolicy({ tags: [{ tag: "AWS", cidrs: ["3.0.0.0/9"] }] }).valid).toBe(
+      false
+    );
+    expect(
+      parseNetworkPolicy({
+        tags: [
+          { tag: "eu", countries: ["DE"] },
+          { tag: "eu", countries: ["FR"] },
+        ],
+      })
+    ).toEqual({ valid: false, error: 'Duplicate network tag "eu"' });
+    expect(parseNetworkPolicy({ deny: { cidrs: ["10.0.0.1/8"] } }).valid).toBe(false);
+    expect(
+      parseNetworkPolicy({ deny: { countries: ["RU"] }, captureRejected: "yes" }).valid
+    ).toBe(false);
+    expect(
+      parseNetworkPolicy({ tags: [{ tag: "aws", cidrs: ["3.0.0.0/9"] }], captureRejected: true })
+    ).toEqual({
+      valid: false,
+      error: "networkPolicy.captureRejected needs an allow or deny rule",
+    });
+    const tooMany = Array.from({ length: 11 }, (_, i) => ({ tag: `t${i}`, countries: ["US"] }));
+    expect(parseNetworkPolicy({ tags: tooMany }).valid).toBe(false);
+  });
+});
@@ -0,0 +1,234 @@
+import { isIP } from "node:net";
+
+import { isRequestTag, MAX_REQUEST_TAGS } from "./request-validation";
+
+/** Webhook providers whose published source ranges the receiver keeps. */
+export const NETWORK_PROVIDERS = ["github", "stripe"] as const;
+
+export type NetworkProvider = (typeof NETWORK_PROVIDERS)[number];
+
+/**
+ * Countries and IP ranges a network rule matches. Countries are the codes
+ * Cloudflare reports for the sender (ISO 3166-1 alpha-2, `XX` unknown,
+ * `T1` Tor); ranges are CIDRs, either family; providers match the ranges
+ * the provider publishes for its webhooks, refreshed hourly.
+ */
+export interface NetworkRule {
+  countries?: string[];
+  cidrs?: string[];
+  providers?: NetworkProvider[];
+}
+
+export interface NetworkTagRule extends NetworkRule {
+  tag: string;
+}
+
+/**
+ * Per-endpoint network policy, evaluated by capture_webhook. Requests that
+ * match `deny`, or nothing in `allow`, are rejected with the `blocked`
+ * receiver error, and kept in the rejected bucket when `captureRejected` is
+ * set; stored requests get the tag of every `tags` rule they match.
+ */
+export interface NetworkPolicy {
+  allow?: NetworkRule;
+  deny?: NetworkRule;
+  tags?: NetworkTagRule[];
+  captureRejected?: boolean;
+}
+
+export const MAX_NETWORK_RULE_ENTRIES = 200;
+const COUNTRY_PATTERN = /^[A-Z][A-Z0-9]$/;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+/** IPv4 or IPv6 address as bytes, or null. */
+function addressBytes(address: string): number[] | null {
+  const family = isIP(address);
+  if (family === 4) return address.split(".").map(Number);
+  if (family !== 6) return null;
+
+  const [head, tail] = address.includes("::") ? address.split("::") : [address, undefined];
+  // An embedded IPv4 suffix counts as two groups
+  const groups = (part: string | undefined) =>
+    !part
+      ? []
+      : part.split(":").flatMap((group) => {
+          if (!group.includes(".")) return [parseInt(group, 16)];
+          const [a, b, c, d] = group.split(".").map(Number);
+          return [(a << 8) | b, (c << 8) | d];
+        });
+  const front = groups(head);
+  const back = groups(tail);
+  const words = [...front, ...Array(8 - front.length - back.length).fill(0), ...back];
+  return words.flatMap((word) => [word >> 8, word & 0xff]);
+}
+
+/**
+ * Validate one CIDR. A bare address becomes a single-host range; host bits
+ * past the prefix must be zero, as Postgres requires.
+ */
+export function parseCidr(value: string): ParseResult<string> {
+  const [address, prefix, ...rest] = value.trim().split("/");
+  const bytes = rest.length === 0 ? addressBytes(address) : null;
+  if (!bytes) return { valid: false, error: `Invalid CIDR "${value}"` };
+
+  const bits = bytes.length * 8;
+  const length = prefix === undefined ? bits : /^\d{1,3}$/.test(prefix) ? Number(prefix) : -1;
+  if (length < 0 || length > bits) {
+    return { valid: false, error: `Invalid CIDR "${value}"` };
+  }
+  for (let bit = length; bit < bits; bit++) {
+    if (bytes[bit >> 3] & (0x80 >> (bit & 7))) {
+      return {
+        valid: false,
+        error: `CIDR "${value}" has bits set past its /${length} prefix`,
+      };
+    }
+  }
+  return { valid: true, value: `${address.toLowerCase()}/${length}` };
+}
+
+function parseRule(value: unknown, label: string): ParseResult<NetworkRule> {
+  if (!value || typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: `${label} must be an object` };
+  }
+  const input = value as Record<string, unknown>;
+  const rule: NetworkRule = {};
+
+  if (input.countries !== undefined) {
+    if (!Array.isArray(input.countries)) {
+      return { valid: false, error: `${label}.countries must be an array` };
+    }
+    const countries = new Set<string>();
+    for (const item of input.countries) {
+      const code = typeof item === "string" ? item.trim().toUpperCase() : "";
+      if (!COUNTRY_PATTERN.test(code)) {
+        return {
+          valid: false,
+          error: `Invalid country "${String(item)}" in ${label}: use two-letter codes`,
+        };
+      }
+      countries.add(code);
+    }
+    if (countries.size > 0) rule.countries = [...countries];
+  }
+
+  if (input.cidrs !== undefined) {
+    if (!Array.isArray(input.cidrs)) {
+      return { valid: false, error: `${label}.cidrs must be an array` };
+    }
+    const cidrs = new Set<string>();
+    for (const item of input.cidrs) {
+      if (typeof item !== "string") {
+        return { valid: false, error: `${label}.cidrs must contain only strings` };
+      }
+      const cidr = parseCidr(item);
+      if (!cidr.valid) return cidr;
+      cidrs.add(cidr.value);
+    }
+    if (cidrs.size > 0) rule.cidrs = [...cidrs];
+  }
+
+  if (input.providers !== undefined) {
+    if (!Array.isArray(input.providers)) {
+      return { valid: false, error: `${label}.providers must be an array` };
+    }
+    const providers = new Set<NetworkProvider>();
+    for (const item of input.providers) {
+      const name = typeof item === "string" ? item.trim().toLowerCase() : "";
+      if (!(NETWORK_PROVIDERS as readonly string[]).includes(name)) {
+        return {
+          valid: false,
+          error: `Unknown provider "${String(item)}" in ${label}: use github, stripe`,
+        };
+      }
+      providers.add(name as NetworkProvider);
+    }
+    if (providers.size > 0) rule.providers = [...providers];
+  }
+
+  const entries =
+    (rule.countries?.length ?? 0) + (rule.cidrs?.length ?? 0) + (rule.providers?.length ?? 0);
+  if (entries === 0) {
+    return {
+      valid: false,
+      error: `${label} must list at least one country, CIDR or provider`,
+    };
+  }
+  if (entries > MAX_NETWORK_RULE_ENTRIES) {
+    return {
+      valid: false,
+      error: `${label} can list at most ${MAX_NETWORK_RULE_ENTRIES} countries, CIDRs and providers`,
+    };
+  }
+  return { valid: true, value: rule };
+}
+
+/**
+ * Validate a `networkPolicy` setting. Countries are uppercased, CIDRs
+ * normalized, providers lowercased and all de-duplicated; null or an empty policy clears it.
+ */
+export function parseNetworkPolicy(value: unknown): ParseResult<NetworkPolicy | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "networkPolicy must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+  const policy: NetworkPolicy = {};
+
+  if (input.allow !== undefined && input.allow !== null) {
+    const allow = parseRule(input.allow, "networkPolicy.allow");
+    if (!allow.valid) return allow;
+    policy.allow = allow.value;
+  }
+
+  if (input.deny !== undefined && input.deny !== null) {
+    const deny = parseRule(input.deny, "networkPolicy.deny");
+    if (!deny.valid) return deny;
+    policy.deny = deny.value;
+  }
+
+  if (input.tags !== undefined && input.tags !== null) {
+    if (!Array.isArray(input.tags)) {
+      return { valid: false, error: "networkPolicy.tags must be an array" };
+    }
+    if (input.tags.length > MAX_REQUEST_TAGS) {
+      return {
+        valid: false,
+        error: `networkPolicy.tags can have at most ${MAX_REQUEST_TAGS} rules`,
+      };
+    }
+    const tags: NetworkTagRule[] = [];
+    for (const [index, item] of input.tags.entries()) {
+      const label = `networkPolicy.tags[${index}]`;
+      const rule = parseRule(item, label);
+      if (!rule.valid) return rule;
+      const tag = (item as Record<string, unknown>).tag;
+      if (!isRequestTag(tag)) {
+        return { valid: false, error: `${label}.tag must be 1-32 of a-z, 0-9, "_" and "-"` };
+      }
+      if (tags.some((existing) => existing.tag === tag)) {
+        return { valid: false, error: `Duplicate network tag "${tag}"` };
+      }
+      tags.push({ tag, ...rule.value });
+    }
+    if (tags.length > 0) policy.tags = tags;
+  }
+
+  if (input.captureRejected !== undefined && typeof input.captureRejected !== "boolean") {
+    return { valid: false, error: "networkPolicy.captureRejected must be a boolean" };
+  }
+  if (!policy.allow && !policy.deny && !policy.tags) {
+    return { valid: true, value: null };
+  }
+  if (input.captureRejected === true) {
+    if (!policy.allow && !policy.deny) {
+      return {
+        valid: false,
+        error: "networkPolicy.captureRejected needs an allow or deny rule",
+      };
+    }
+    policy.captureRejected = true;
+  }
+  return { valid: true, value: policy };
+}
@@ -0,0 +1,124 @@
+import { createHash, createHmac } from "node:crypto";
+import { serverEnv } from "./env";
+
+/**
+ * Signed download URLs for request bodies the receiver kept in object storage
+ * because they were over its inline limit (see
+ * apps/receiver-rs/src/body_store.rs). Any S3-compatible service works; the
+ * same OBJECT_STORAGE_* variables configure both sides.
+ */
+
+/** How long a body download URL stays valid. */
+export const BODY_URL_TTL_SECONDS = 15 * 60;
+
+export interface ObjectStorageConfig {
+  url: string;
+  bucket: string;
+  region: string;
+  accessKeyId: string;
+  secretAccessKey: string;
+}
+
+export interface PresignInput {
+  method: string;
+  /** `https://host[:port]` */
+  origin: string;
+  /** Unencoded object path */
+  path: string;
+  region: string;
+  accessKeyId: string;
+  secretAccessKey: string;
+  expiresIn: number;
+  now: Date;
+}
+
+/** The configured bucket, or null when large-body offload is off. */
+export function objectStorageConfig(): ObjectStorageConfig | null {
+  const env = serverEnv();
+  if (
+    !env.OBJECT_STORAGE_URL ||
+    !env.OBJECT_STORAGE_BUCKET ||
+    !env.OBJECT_STORAGE_ACCESS_KEY_ID ||
+    !env.OBJECT_STORAGE_SECRET_ACCESS_KEY
+  ) {
+    return null;
+  }
+  return {
+    url: env.OBJECT_STORAGE_URL,
+    bucket: env.OBJECT_STORAGE_BUCKET,
+    region: env.OBJECT_STORAGE_REGION,
+    accessKeyId: env.OBJECT_STORAGE_ACCESS_KEY_ID,
+    secretAccessKey: env.OBJECT_STORAGE_SECRET_ACCESS_KEY,
+  };
+}
+
+/** RFC 3986 encoding, as SigV4 requires. */
+function uriEncode(value: string): string {
+  return encodeURIComponent(value).replace(
+    /[!'()*]/g,
+    (c) => `%${c.charCodeAt(0).toString(16).toUpperCase()}`
+  );
+}
+
+function hmac(key: string | Buffer, data: string): Buffer {
+  return createHmac("sha256", key).update(data, "utf8").digest();
+}
+
+/** A SigV4 query-string-signed URL (S3 presigned URL). */
+export function presignUrl(input: PresignInput): string {
+  const amzDate = input.now.toISOString().replace(/[-:]/g, "").replace(/\.\d{3}/, "");
+  const date = amzDate.slice(0, 8);
+  const scope = `${date}/${input.region}/s3/aws4_request`;
+  const host = new URL(input.origin).host;
+  const path = input.path.split("/").map(uriEncode).join("/");
+
+  const query = [
+    ["X-Amz-Algorithm", "AWS4-HMAC-SHA256"],
+    ["X-Amz-Credential", `${input.accessKeyId}/${scope}`],
+    ["X-Amz-Date", amzDate],
+    ["X-Amz-Expires", String(input.expiresIn)],
+    ["X-Amz-SignedHeaders", "host"],
+  ]
+    .map(([key, value]) => `${uriEncode(key)}=${uriEncode(value)}`)
+    .join("&");
+
+  const canonicalRequest = [
+    input.method,
+    path,
+    query,
+    `host:${host}\n`,
+    "host",
+    "UNSIGNED-PAYLOAD",
+  ].join("\n");
+  const stringToSign = [
+    "AWS4-HMAC-SHA256",
+    amzDate,
+    scope,
+    createHash("sha256").update(canonicalRequest, "utf8").digest("hex"),
+  ].join("\n");
+
+  const dateKey = hmac(`AWS4${input.secretAccessKey}`, date);
+  const key = hmac(hmac(hmac(dateKey, input.region), "s3"), "aws4_request");
+  const signature = hmac(key, stringToSign).toString("hex");
+  return `${input.origin}${path}?${query}&X-Amz-Signature=${signature}`;
+}
+
+/** A time-limited GET URL for an offloaded body, path-style like the receiver's uploads. */
+export function bodyDownloadUrl(
+  config: ObjectStorageConfig,
+  key: string,
+  now = new Date()
+): { url: string; expiresAt: number } {
+  const service = new URL(config.url);
+  const url = presignUrl({
+    method: "GET",
+    origin: service.origin,
+    path: `${service.pathname.replace(/\/+$/, "")}/${config.bucket}/${key}`,
+    region: config.region,
+    accessKeyId: config.accessKeyId,
+    secretAccessKey: config.secretAccessKey,
+    expiresIn: BODY_URL_TTL_SECONDS,
+    now,
+  });
+  return { url, expiresAt: now.getTime() + BODY_URL_TTL_SECONDS * 1000 };
+}
@@ -0,0 +1,24 @@
+import { describe, expect, test } from "vitest";
+
+import { parsePriorityRule } from "./priority";
+
+describe("parsePriorityRule", () => {
+  test("normalizes the header and values", () => {
+    expect(
+      parsePriorityRule({ header: " X-Priority ", values: ["High", "high", "URGENT"] })
+    ).toEqual({ valid: true, value: { header: "x-priority", values: ["high", "urgent"] } });
+    expect(parsePriorityRule({ header: "x-payment", values: [] })).toEqual({
+      valid: true,
+      value: { header: "x-payment" },
+    });
+    expect(parsePriorityRule(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects bad header names and values", () => {
+    expect(parsePriorityRule({ header: "x priority" }).valid).toBe(false);
+    expect(parsePriorityRule({}).valid).toBe(false);
+    expect(parsePriorityRule({ header: "x-priority", values: "high" }).valid).toBe(false);
+    expect(parsePriorityRule({ header: "x-priority", values: [" "] }).valid).toBe(false);
+    expect(parsePriorityRule(["x-priority"]).valid).toBe(false);
+  });
+});
@@ -0,0 +1,64 @@
+/**
+ * Per-endpoint priority rule, evaluated by capture_webhook. A capture is
+ * high priority when it carries `header` and, if `values` are listed, its
+ * value is one of them. Both are lowercase; values compare case-insensitively.
+ * High-priority captures are flagged as `priority`, notify without the
+ * per-endpoint cooldown and come first in the stream's backlog.
+ */
+export interface PriorityRule {
+  header: string;
+  values?: string[];
+}
+
+export const MAX_PRIORITY_VALUES = 20;
+const HEADER_NAME_REGEX = /^[a-z0-9_-]{1,64}$/;
+const MAX_VALUE_LENGTH = 64;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+/**
+ * Validate a `priorityRule` setting. The header name and values are trimmed,
+ * lowercased and de-duplicated; null clears the rule.
+ */
+export function parsePriorityRule(value: unknown): ParseResult<PriorityRule | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "priorityRule must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+
+  const header = typeof input.header === "string" ? input.header.trim().toLowerCase() : "";
+  if (!HEADER_NAME_REGEX.test(header)) {
+    return {
+      valid: false,
+      error: 'priorityRule.header must be 1-64 letters, digits, "-" or "_"',
+    };
+  }
+  const rule: PriorityRule = { header };
+
+  if (input.values !== undefined && input.values !== null) {
+    if (!Array.isArray(input.values)) {
+      return { valid: false, error: "priorityRule.values must be an array" };
+    }
+    const values = new Set<string>();
+    for (const item of input.values) {
+      const text = typeof item === "string" ? item.trim().toLowerCase() : "";
+      if (text.length === 0 || text.length > MAX_VALUE_LENGTH) {
+        return {
+          valid: false,
+          error: `priorityRule.values must be non-empty strings of at most ${MAX_VALUE_LENGTH} characters`,
+        };
+      }
+      values.add(text);
+    }
+    if (values.size > MAX_PRIORITY_VALUES) {
+      return {
+        valid: false,
+        error: `priorityRule.values can list at most ${MAX_PRIORITY_VALUES} values`,
+      };
+    }
+    if (values.size > 0) rule.values = [...values];
+  }
+
+  return { valid: true, value: rule };
+}
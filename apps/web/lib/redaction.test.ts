@@ -0,0 +1,69 @@
+import { describe, expect, test } from "vitest";
+
+import { normalizeRedaction, normalizeRedactions, parseRedaction } from "./redaction";
+
+describe("parseRedaction", () => {
+  test("keeps the rules that are set", () => {
+    expect(parseRedaction({ detectors: ["email", "email"], paths: [" customer.email "] })).toEqual({
+      valid: true,
+      value: { detectors: ["email"], paths: ["customer.email"] },
+    });
+    expect(parseRedaction({ patterns: ["sk_live_[A-Za-z0-9]+"], paths: [] })).toEqual({
+      valid: true,
+      value: { patterns: ["sk_live_[A-Za-z0-9]+"] },
+    });
+    expect(parseRedaction(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("takes header overrides on their own", () => {
+    const headers = { allow: ["Authorization"], deny: [" X-Shop-Token "] };
+    expect(parseRedaction({ headers })).toEqual({
+      valid: true,
+      value: { headers: { allow: ["authorization"], deny: ["x-shop-token"] } },
+    });
+    expect(parseRedaction({ headers: { allow: [] } }).valid).toBe(false);
+    expect(parseRedaction({ headers: { deny: ["x token"] } }).valid).toBe(false);
+    expect(parseRedaction({ headers: ["cookie"] }).valid).toBe(false);
+  });
+
+  test("rejects rules the receiver can't apply", () => {
+    expect(parseRedaction({}).valid).toBe(false);
+    expect(parseRedaction([]).valid).toBe(false);
+    expect(parseRedaction({ detectors: ["ssn"] })).toEqual({
+      valid: false,
+      error: 'Unknown redaction detector "ssn" (expected email, credit_card, bearer_token)',
+    });
+    expect(parseRedaction({ paths: ["customer..email"] }).valid).toBe(false);
+    expect(parseRedaction({ paths: [""] }).valid).toBe(false);
+    expect(parseRedaction({ patterns: ["("] }).valid).toBe(false);
+    expect(parseRedaction({ patterns: ["token(?=x)"] }).valid).toBe(false);
+    expect(parseRedaction({ patterns: Array.from({ length: 21 }, (_, i) => `p${i}`) }).valid).toBe(
+      false
+    );
+  });
+});
+
+describe("normalizeRedaction", () => {
+  test("reads the stored config", () => {
+    expect(normalizeRedaction({ detectors: ["bearer_token", "bogus"], paths: ["a.*"] })).toEqual({
+      detectors: ["bearer_token"],
+      paths: ["a.*"],
+    });
+    expect(normalizeRedaction({ headers: { allow: ["cookie"] } })).toEqual({
+      headers: { allow: ["cookie"] },
+    });
+    expect(normalizeRedaction({})).toBeNull();
+    expect(normalizeRedaction(null)).toBeNull();
+  });
+});
+
+describe("normalizeRedactions", () => {
+  test("keeps positive counts", () => {
+    expect(normalizeRedactions({ email: 2, "path:card": 1, bogus: "x" })).toEqual({
+      email: 2,
+      "path:card": 1,
+    });
+    expect(normalizeRedactions({})).toBeUndefined();
+    expect(normalizeRedactions(null)).toBeUndefined();
+  });
+});
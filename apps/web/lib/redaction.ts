@@ -0,0 +1,188 @@
+import { UNSUPPORTED_PATTERN } from "./schema-validation";
+
+/**
+ * Per-endpoint redaction. The receiver replaces matches with `[REDACTED]`
+ * before a capture is stored, and records how often each rule fired as
+ * `redactions`, keyed by rule id: the detector name, `header:<name>`,
+ * `path:<path>` or `pattern:<pattern>`. The default headers are hidden on
+ * every endpoint unless `headers.allow` lists them or the endpoint encrypts
+ * them.
+ */
+export const REDACTION_DETECTORS = ["email", "credit_card", "bearer_token"] as const;
+/** Mirrors `DEFAULT_HEADERS` in the receiver's `redact.rs` */
+export const DEFAULT_REDACTED_HEADERS = [
+  "authorization",
+  "proxy-authorization",
+  "cookie",
+  "x-api-key",
+] as const;
+export const MAX_REDACTION_RULES = 20;
+export const MAX_REDACTION_RULE_LENGTH = 200;
+
+export type RedactionDetector = (typeof REDACTION_DETECTORS)[number];
+
+const HEADER_NAME_REGEX = /^[a-z0-9_-]{1,100}$/;
+
+export interface HeaderRedaction {
+  /** Stored as sent, even when hidden by default */
+  allow?: string[];
+  /** Hidden on top of the defaults */
+  deny?: string[];
+}
+
+export interface Redaction {
+  detectors?: RedactionDetector[];
+  /** Body fields as dot paths; `*` matches every key or index at its level */
+  paths?: string[];
+  /** Regular expressions in the receiver's syntax (no lookaround) */
+  patterns?: string[];
+  headers?: HeaderRedaction;
+}
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+function stringList(value: unknown, field: string): ParseResult<string[]> {
+  if (value === undefined || value === null) return { valid: true, value: [] };
+  if (!Array.isArray(value) || value.some((item) => typeof item !== "string")) {
+    return { valid: false, error: `redaction.${field} must be an array of strings` };
+  }
+  if (value.length > MAX_REDACTION_RULES) {
+    return {
+      valid: false,
+      error: `redaction.${field} can have at most ${MAX_REDACTION_RULES} entries`,
+    };
+  }
+  const items = [...new Set(value.map((item: string) => item.trim()))];
+  const bad = items.find((item) => !item || item.length > MAX_REDACTION_RULE_LENGTH);
+  if (bad !== undefined) {
+    return {
+      valid: false,
+      error: `redaction.${field} entries must be 1-${MAX_REDACTION_RULE_LENGTH} characters`,
+    };
+  }
+  return { valid: true, value: items };
+}
+
+/** Validate a `redaction` setting. Null removes redaction. */
+export function parseRedaction(value: unknown): ParseResult<Redaction | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "redaction must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+
+  const detectors = stringList(input.detectors, "detectors");
+  if (!detectors.valid) return detectors;
+  const unknown = detectors.value.find(
+    (detector) => !(REDACTION_DETECTORS as readonly string[]).includes(detector)
+  );
+  if (unknown !== undefined) {
+    return {
+      valid: false,
+      error: `Unknown redaction detector "${unknown}" (expected ${REDACTION_DETECTORS.join(", ")})`,
+    };
+  }
+
+  const paths = stringList(input.paths, "paths");
+  if (!paths.valid) return paths;
+  const badPath = paths.value.find((path) => path.split(".").some((segment) => !segment));
+  if (badPath !== undefined) {
+    return { valid: false, error: `Invalid redaction path "${badPath}"` };
+  }
+
+  const patterns = stringList(input.patterns, "patterns");
+  if (!patterns.valid) return patterns;
+  for (const pattern of patterns.value) {
+    try {
+      new RegExp(pattern, "u");
+    } catch {
+      return { valid: false, error: `Invalid redaction pattern "${pattern}"` };
+    }
+    if (UNSUPPORTED_PATTERN.test(pattern)) {
+      return {
+        valid: false,
+        error: `Redaction pattern "${pattern}" uses lookaround or backreferences (unsupported)`,
+      };
+    }
+  }
+
+  const headers = parseHeaderRedaction(input.headers);
+  if (!headers.valid) return headers;
+
+  if (!detectors.value.length && !paths.value.length && !patterns.value.length && !headers.value) {
+    return {
+      valid: false,
+      error: "redaction needs at least one detector, path, pattern or header rule",
+    };
+  }
+  return {
+    valid: true,
+    value: {
+      ...(detectors.value.length ? { detectors: detectors.value as RedactionDetector[] } : {}),
+      ...(paths.value.length ? { paths: paths.value } : {}),
+      ...(patterns.value.length ? { patterns: patterns.value } : {}),
+      ...(headers.value ? { headers: headers.value } : {}),
+    },
+  };
+}
+
+function parseHeaderRedaction(value: unknown): ParseResult<HeaderRedaction | null> {
+  if (value === undefined || value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "redaction.headers must be an object" };
+  }
+  const input = value as Record<string, unknown>;
+  const result: HeaderRedaction = {};
+  for (const field of ["allow", "deny"] as const) {
+    const names = stringList(input[field], `headers.${field}`);
+    if (!names.valid) return names;
+    const lowered = [...new Set(names.value.map((name) => name.toLowerCase()))];
+    const bad = lowered.find((name) => !HEADER_NAME_REGEX.test(name));
+    if (bad !== undefined) {
+      return {
+        valid: false,
+        error: `Invalid header name "${bad}": use 1-100 letters, digits, "-" or "_"`,
+      };
+    }
+    if (lowered.length) result[field] = lowered;
+  }
+  return { valid: true, value: result.allow || result.deny ? result : null };
+}
+
+/** The stored config, or null when it isn't one. */
+export function normalizeRedaction(value: unknown): Redaction | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  const config = value as Record<string, unknown>;
+  const strings = (list: unknown) =>
+    Array.isArray(list) ? list.filter((item): item is string => typeof item === "string") : [];
+  const detectors = strings(config.detectors).filter((detector): detector is RedactionDetector =>
+    (REDACTION_DETECTORS as readonly string[]).includes(detector)
+  );
+  const paths = strings(config.paths);
+  const patterns = strings(config.patterns);
+  const headerConfig =
+    config.headers && typeof config.headers === "object"
+      ? (config.headers as Record<string, unknown>)
+      : {};
+  const allow = strings(headerConfig.allow);
+  const deny = strings(headerConfig.deny);
+  const headers = allow.length || deny.length;
+  if (!detectors.length && !paths.length && !patterns.length && !headers) return null;
+  return {
+    ...(detectors.length ? { detectors } : {}),
+    ...(paths.length ? { paths } : {}),
+    ...(patterns.length ? { patterns } : {}),
+    ...(headers
+      ? { headers: { ...(allow.length ? { allow } : {}), ...(deny.length ? { deny } : {}) } }
+      : {}),
+  };
+}
+
+/** Stored per-rule counts, or undefined when nothing was redacted. */
+export function normalizeRedactions(value: unknown): Record<string, number> | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  const counts = Object.entries(value as Record<string, unknown>).filter(
+    (entry): entry is [string, number] => typeof entry[1] === "number" && entry[1] > 0
+  );
+  return counts.length ? Object.fromEntries(counts) : undefined;
+}
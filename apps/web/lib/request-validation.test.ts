@@ -1,5 +1,21 @@
 import { describe, expect, test } from "vitest";
-import { validateMockResponseField, validateNotificationUrl } from "./request-validation";
+import {
+  validateFunctionSinkField,
+  validateBodyTransformsField,
+  MAX_BODY_TRANSFORMS,
+  validateMockResponseField,
+  MAX_MOCK_VARIANTS,
+  MAX_MOCK_WINDOWS,
+  MAX_MOCK_RULES,
+  MAX_MOCK_SEQUENCE_STEPS,
+  MAX_MOCK_SCRIPT_LENGTH,
+  validateNotificationUrl,
+  validateForwardUrl,
+  validatePausedResponse,
+  MAX_PAUSED_BODY_LENGTH,
+  validateMockCanary,
+  parseAsn,
+} from "./request-validation";
 
 describe("validateMockResponseField", () => {
   // -----------------------------------------------------------------------
@@ -129,6 +145,26 @@ describe("validateMockResponseField", () => {
     ).toBe(false);
   });
 
+  test("validates delay jitter", () => {
+    const check = (delayJitter: unknown) =>
+      validateMockResponseField({ status: 200, body: "", headers: {}, delay: 500, delayJitter })
+        .valid;
+
+    expect(check(250)).toBe(true);
+    expect(check(0)).toBe(true);
+    expect(check(-1)).toBe(false);
+    expect(check(30001)).toBe(false);
+    expect(check("250")).toBe(false);
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "",
+        headers: {},
+        rules: [{ match: {}, status: 504, delay: 5000, delayJitter: 40000 }],
+      }).valid
+    ).toBe(false);
+  });
+
   test("accepts delay of 0", () => {
     expect(validateMockResponseField({ status: 200, body: "", headers: {}, delay: 0 }).valid).toBe(
       true
@@ -145,6 +181,421 @@ describe("validateMockResponseField", () => {
     expect(validateMockResponseField({ status: 200, body: "", headers: {} }).valid).toBe(true);
   });
 
+  // Header policy validation
+  test("accepts header policy overriding default-blocked headers", () => {
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "",
+        headers: {},
+        headerPolicy: { allow: ["Set-Cookie", "x-frame-options"], block: ["x-powered-by"] },
+      }).valid
+    ).toBe(true);
+  });
+
+  test("rejects allowing a header that is not blocked by default", () => {
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "",
+        headers: {},
+        headerPolicy: { allow: ["x-custom"] },
+      }).valid
+    ).toBe(false);
+  });
+
+  test("rejects malformed header policy", () => {
+    const base = { status: 200, body: "", headers: {} };
+    expect(validateMockResponseField({ ...base, headerPolicy: [] }).valid).toBe(false);
+    expect(validateMockResponseField({ ...base, headerPolicy: { block: "x-a" } }).valid).toBe(
+      false
+    );
+    expect(
+      validateMockResponseField({ ...base, headerPolicy: { block: ["bad header"] } }).valid
+    ).toBe(false);
+    expect(validateMockResponseField({ ...base, headerPolicy: { deny: [] } }).valid).toBe(false);
+  });
+
+  // Correlation validation
+  test("accepts correlated responses keyed by a request field", () => {
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "",
+        headers: {},
+        correlation: {
+          key: "body.json.order_id",
+          responses: {
+            ord_1: { status: 202, body: "accepted", headers: {} },
+            ord_2: { status: 409 },
+          },
+        },
+      }).valid
+    ).toBe(true);
+  });
+
+  test("rejects malformed correlation", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const withCorrelation = (correlation: unknown) =>
+      validateMockResponseField({ ...base, correlation }).valid;
+    expect(withCorrelation({ key: "cookie.session", responses: {} })).toBe(false);
+    expect(withCorrelation({ key: "body.json.id", responses: [] })).toBe(false);
+    expect(withCorrelation({ key: "body.json.id", responses: { a: { body: "x" } } })).toBe(false);
+    expect(withCorrelation({ key: "body.json.id", responses: { a: { status: 999 } } })).toBe(false);
+    expect(
+      withCorrelation({
+        key: "body.json.id",
+        responses: { a: { status: 200, correlation: { key: "path", responses: {} } } },
+      })
+    ).toBe(false);
+  });
+
+  test("rejects correlation tables over the entry limit", () => {
+    const responses = Object.fromEntries(
+      Array.from({ length: 101 }, (_, i) => [`k${i}`, { status: 200 }])
+    );
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "",
+        headers: {},
+        correlation: { key: "query.id", responses },
+      }).valid
+    ).toBe(false);
+  });
+
+  // Content negotiation
+  test("accepts representations by media type", () => {
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: '{"ok":true}',
+        headers: { "content-type": "application/json" },
+        representations: {
+          "application/xml": "<ok>true</ok>",
+          "text/html": "<p>OK</p>",
+        },
+      }).valid
+    ).toBe(true);
+  });
+
+  test("rejects malformed representations", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const withRepresentations = (representations: unknown, extra: Record<string, unknown> = {}) =>
+      validateMockResponseField({ ...base, ...extra, representations }).valid;
+    expect(withRepresentations(["text/html"])).toBe(false);
+    expect(withRepresentations({ "text/*": "x" })).toBe(false);
+    expect(withRepresentations({ "Text/HTML": "x" })).toBe(false);
+    expect(withRepresentations({ "text/html; charset=utf-8": "x" })).toBe(false);
+    expect(withRepresentations({ "text/html": { body: "x" } })).toBe(false);
+    expect(withRepresentations({ "text/html": "x" }, { echo: true })).toBe(false);
+    expect(
+      validateMockResponseField({
+        ...base,
+        rules: [{ match: {}, status: 200, representations: { "text/html": "x" } }],
+      }).valid
+    ).toBe(false);
+  });
+
+  // Per-method replies
+  test("accepts replies by method", () => {
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "",
+        headers: {},
+        byMethod: {
+          GET: {
+            status: 200,
+            body: '{"id":"o_1"}',
+            headers: { "content-type": "application/json" },
+          },
+          DELETE: { status: 204 },
+        },
+        correlation: { key: "query.id", responses: {} },
+      }).valid
+    ).toBe(true);
+  });
+
+  test("rejects malformed replies by method", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const withByMethod = (byMethod: unknown, extra: Record<string, unknown> = {}) =>
+      validateMockResponseField({ ...base, ...extra, byMethod }).valid;
+    expect(withByMethod([])).toBe(false);
+    expect(withByMethod({ get: { status: 200 } })).toBe(false);
+    expect(withByMethod({ GET: { body: "x" } })).toBe(false);
+    expect(withByMethod({ GET: { status: 999 } })).toBe(false);
+    expect(withByMethod({ GET: { status: 200, bodyBase64: "AA==" } })).toBe(false);
+    expect(withByMethod({ GET: { status: 200, rules: [] } })).toBe(false);
+    const variants = [{ name: "a", weight: 10, status: 500 }];
+    expect(withByMethod({ GET: { status: 200 } }, { variants })).toBe(false);
+  });
+
+  test("accepts weighted variants", () => {
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "ok",
+        headers: {},
+        variants: [
+          { name: "rate_limited", weight: 20, status: 429, headers: { "retry-after": "5" } },
+          { name: "slow", weight: 10, status: 200, delay: 2000 },
+        ],
+      })
+    ).toEqual({ valid: true });
+  });
+
+  test("rejects invalid variants", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const check = (variants: unknown, extra: Record<string, unknown> = {}) =>
+      validateMockResponseField({ ...base, ...extra, variants }).valid;
+
+    expect(check({ name: "a", weight: 10, status: 500 })).toBe(false);
+    expect(check([{ name: "default", weight: 10, status: 500 }])).toBe(false);
+    expect(check([{ name: "Bad Name", weight: 10, status: 500 }])).toBe(false);
+    expect(check([{ name: "a", weight: 0, status: 500 }])).toBe(false);
+    expect(check([{ name: "a", weight: 12.5, status: 500 }])).toBe(false);
+    expect(check([{ name: "a", weight: 10 }])).toBe(false);
+    expect(check([{ name: "a", weight: 10, status: 9999 }])).toBe(false);
+    expect(
+      check([
+        { name: "a", weight: 10, status: 500 },
+        { name: "a", weight: 10, status: 502 },
+      ])
+    ).toBe(false);
+    expect(
+      check([
+        { name: "a", weight: 60, status: 500 },
+        { name: "b", weight: 50, status: 502 },
+      ])
+    ).toBe(false);
+    const tooMany = Array.from({ length: MAX_MOCK_VARIANTS + 1 }, (_, i) => ({
+      name: `v${i}`,
+      weight: 1,
+      status: 500,
+    }));
+    expect(check(tooMany)).toBe(false);
+    expect(
+      check([{ name: "a", weight: 10, status: 500 }], {
+        correlation: { key: "query.id", responses: {} },
+      })
+    ).toBe(false);
+  });
+
+  test("accepts scheduled windows", () => {
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "ok",
+        headers: {},
+        schedule: [
+          { name: "maintenance", start: "02:00", end: "03:00", status: 503 },
+          {
+            name: "weekend-night",
+            start: "23:30",
+            end: "01:00",
+            timezone: "Europe/Berlin",
+            days: ["sat", "sun"],
+            status: 503,
+            headers: { "retry-after": "3600" },
+          },
+        ],
+      })
+    ).toEqual({ valid: true });
+  });
+
+  test("rejects invalid schedules", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const window = { name: "maintenance", start: "02:00", end: "03:00", status: 503 };
+    const check = (schedule: unknown) => validateMockResponseField({ ...base, schedule }).valid;
+
+    expect(check(window)).toBe(false);
+    expect(check([{ ...window, name: "default" }])).toBe(false);
+    expect(check([window, window])).toBe(false);
+    expect(check([{ ...window, start: "2:00" }])).toBe(false);
+    expect(check([{ ...window, end: "24:00" }])).toBe(false);
+    expect(check([{ ...window, end: "02:00" }])).toBe(false);
+    expect(check([{ ...window, timezone: "Mars/Olympus" }])).toBe(false);
+    expect(check([{ ...window, days: [] }])).toBe(false);
+    expect(check([{ ...window, days: ["monday"] }])).toBe(false);
+    expect(check([{ ...window, status: undefined }])).toBe(false);
+    expect(check([{ ...window, delay: 60000 }])).toBe(false);
+    const tooMany = Array.from({ length: MAX_MOCK_WINDOWS + 1 }, (_, i) => ({
+      ...window,
+      name: `w${i}`,
+    }));
+    expect(check(tooMany)).toBe(false);
+  });
+
+  test("accepts conditional rules", () => {
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "ok",
+        headers: {},
+        rules: [
+          { match: { methods: ["POST"], path: "/refunds/*" }, status: 409 },
+          {
+            match: {
+              headers: { "x-event": "invoice.*" },
+              query: { mode: "test" },
+              body: { "data.object.status": "failed" },
+            },
+            status: 500,
+            body: '{"error":"boom"}',
+            headers: { "content-type": "application/json" },
+            delay: 200,
+          },
+        ],
+      })
+    ).toEqual({ valid: true });
+  });
+
+  test("rejects invalid rules", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const rule = { match: { path: "/refunds/*" }, status: 409 };
+    const check = (rules: unknown) => validateMockResponseField({ ...base, rules }).valid;
+
+    expect(check(rule)).toBe(false);
+    expect(check([{ status: 409 }])).toBe(false);
+    expect(check([{ ...rule, match: { methods: ["post"] } }])).toBe(false);
+    expect(check([{ ...rule, match: { path: "refunds" } }])).toBe(false);
+    expect(check([{ ...rule, match: { headers: { "x-event": 1 } } }])).toBe(false);
+    expect(check([{ ...rule, match: { query: {} } }])).toBe(false);
+    expect(check([{ ...rule, match: { cookie: { session: "*" } } }])).toBe(false);
+    expect(check([{ ...rule, rules: [] }])).toBe(false);
+    expect(check([{ ...rule, status: undefined }])).toBe(false);
+    expect(check([{ ...rule, delay: 60000 }])).toBe(false);
+    expect(
+      validateMockResponseField({
+        ...base,
+        rules: [rule],
+        variants: [{ name: "rate_limited", weight: 20, status: 429 }],
+      }).valid
+    ).toBe(false);
+    expect(check(Array.from({ length: MAX_MOCK_RULES + 1 }, () => rule))).toBe(false);
+  });
+
+  test("accepts a response sequence", () => {
+    expect(
+      validateMockResponseField({
+        status: 200,
+        body: "ok",
+        headers: {},
+        sequence: {
+          steps: [{ status: 500, count: 2 }, { status: 503, body: "later", delay: 100 }],
+          key: "header.x-delivery-id",
+          repeat: false,
+          resetAfter: 300,
+        },
+      })
+    ).toEqual({ valid: true });
+  });
+
+  test("rejects invalid sequences", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const step = { status: 500 };
+    const check = (sequence: unknown) => validateMockResponseField({ ...base, sequence }).valid;
+
+    expect(check([step])).toBe(false);
+    expect(check({ steps: [] })).toBe(false);
+    expect(check({ steps: [{ count: 2 }] })).toBe(false);
+    expect(check({ steps: [{ ...step, count: 0 }] })).toBe(false);
+    expect(check({ steps: [{ ...step, count: 1.5 }] })).toBe(false);
+    expect(check({ steps: [{ ...step, rules: [] }] })).toBe(false);
+    expect(check({ steps: [step], key: "cookie.session" })).toBe(false);
+    expect(check({ steps: [step], repeat: "yes" })).toBe(false);
+    expect(check({ steps: [step], resetAfter: 0 })).toBe(false);
+    expect(check({ steps: [step], loop: true })).toBe(false);
+    expect(
+      check({ steps: Array.from({ length: MAX_MOCK_SEQUENCE_STEPS + 1 }, () => step) })
+    ).toBe(false);
+    expect(
+      validateMockResponseField({
+        ...base,
+        sequence: { steps: [step] },
+        variants: [{ name: "rate_limited", weight: 20, status: 429 }],
+      }).valid
+    ).toBe(false);
+  });
+
+  test("validates the response script", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const check = (script: unknown) => validateMockResponseField({ ...base, script }).valid;
+
+    expect(check("if request.json.amount > 100 { #{ status: 402 } }")).toBe(true);
+    expect(check(null)).toBe(true);
+    expect(check("")).toBe(false);
+    expect(check(42)).toBe(false);
+    expect(check("x".repeat(MAX_MOCK_SCRIPT_LENGTH + 1))).toBe(false);
+    expect(
+      validateMockResponseField({
+        ...base,
+        script: "500",
+        variants: [{ name: "rate_limited", weight: 20, status: 429 }],
+      }).valid
+    ).toBe(false);
+    expect(
+      validateMockResponseField({
+        ...base,
+        rules: [{ match: {}, status: 409, script: "500" }],
+      }).valid
+    ).toBe(false);
+  });
+
+  test("validates echo", () => {
+    const base = { status: 200, body: "", headers: {} };
+    expect(validateMockResponseField({ ...base, echo: true })).toEqual({ valid: true });
+    expect(validateMockResponseField({ ...base, echo: "yes" }).valid).toBe(false);
+  });
+
+  test("validates the binary body", () => {
+    const base = { status: 200, body: "", headers: {} };
+    expect(validateMockResponseField({ ...base, bodyBase64: "iVBORw0KGgo=" })).toEqual({
+      valid: true,
+    });
+    expect(validateMockResponseField({ ...base, bodyBase64: "not base64!" }).valid).toBe(false);
+    expect(validateMockResponseField({ ...base, bodyBase64: 42 }).valid).toBe(false);
+    expect(
+      validateMockResponseField({ ...base, body: "text", bodyBase64: "iVBORw0KGgo=" }).valid
+    ).toBe(false);
+    // Only the base reply takes a binary body
+    const rules = [{ match: { methods: ["GET"] }, status: 200, bodyBase64: "iVBORw0KGgo=" }];
+    expect(validateMockResponseField({ ...base, rules }).valid).toBe(false);
+  });
+
+  test("validates the stream", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const check = (stream: unknown, extra: Record<string, unknown> = {}) =>
+      validateMockResponseField({ ...base, ...extra, stream }).valid;
+
+    const sse = { events: [{ event: "progress", id: "1", data: "{}" }, { delay: 500 }] };
+    expect(check(sse)).toBe(true);
+    expect(check({ format: "chunked", events: [{ data: "a" }] })).toBe(true);
+    expect(check({ format: "ndjson", events: [{ data: "a" }] })).toBe(false);
+    expect(check({ events: [] })).toBe(false);
+    expect(check({ events: [{ event: "a\nb" }] })).toBe(false);
+    expect(check({ events: [{ data: "a", delay: 30001 }] })).toBe(false);
+    expect(check({ events: [{ delay: 30000 }, { delay: 30000 }, { delay: 1 }] })).toBe(false);
+    expect(check({ events: [{ data: "a", retry: 1 }] })).toBe(false);
+    expect(check({ events: [{ data: "a" }] }, { echo: true })).toBe(false);
+    // Only the base reply streams
+    const rules = [{ match: { methods: ["GET"] }, status: 200, stream: { events: [] } }];
+    expect(validateMockResponseField({ ...base, rules }).valid).toBe(false);
+  });
+
+  test("validates the gRPC reply", () => {
+    const base = { status: 200, body: "", headers: {} };
+    const check = (grpc: unknown) => validateMockResponseField({ ...base, grpc }).valid;
+
+    expect(check({ code: 0, body: "CJYB" })).toBe(true);
+    expect(check({ code: 14, message: "try later" })).toBe(true);
+    expect(check({ code: 17 })).toBe(false);
+    expect(check({ code: "OK" })).toBe(false);
+    expect(check({ code: 0, body: "not base64!" })).toBe(false);
+    expect(check({ code: 0, status: 200 })).toBe(false);
+  });
+
   // -----------------------------------------------------------------------
   // Partial mode (partial=true) — PATCH semantics, all fields optional
   // -----------------------------------------------------------------------
@@ -232,3 +683,159 @@ describe("validateNotificationUrl", () => {
     expect(validateNotificationUrl(42).valid).toBe(false);
   });
 });
+
+describe("validateForwardUrl", () => {
+  test("undefined, null and empty string pass", () => {
+    expect(validateForwardUrl(undefined)).toEqual({ valid: true });
+    expect(validateForwardUrl(null)).toEqual({ valid: true });
+    expect(validateForwardUrl("")).toEqual({ valid: true });
+  });
+
+  test("http and https URLs pass", () => {
+    expect(validateForwardUrl("https://api.example.com/webhooks?token=t")).toEqual({
+      valid: true,
+    });
+    expect(validateForwardUrl("http://api.example.com")).toEqual({ valid: true });
+  });
+
+  test("rejects other protocols, bad URLs, long strings and non-strings", () => {
+    expect(validateForwardUrl("ftp://example.com").valid).toBe(false);
+    expect(validateForwardUrl("not a url").valid).toBe(false);
+    expect(validateForwardUrl("https://example.com/" + "a".repeat(2030)).valid).toBe(false);
+    expect(validateForwardUrl(42).valid).toBe(false);
+  });
+});
+
+describe("validateFunctionSinkField", () => {
+  const arn = "arn:aws:lambda:us-east-1:123456789012:function:my-handler";
+
+  test("undefined and null pass", () => {
+    expect(validateFunctionSinkField(undefined)).toEqual({ valid: true });
+    expect(validateFunctionSinkField(null)).toEqual({ valid: true });
+  });
+
+  test("valid Lambda ARN passes, with or without qualifier", () => {
+    expect(validateFunctionSinkField({ provider: "aws_lambda", arn })).toEqual({ valid: true });
+    expect(validateFunctionSinkField({ provider: "aws_lambda", arn: `${arn}:prod` })).toEqual({
+      valid: true,
+    });
+  });
+
+  test("rejects unknown provider", () => {
+    expect(validateFunctionSinkField({ provider: "gcp", arn }).valid).toBe(false);
+  });
+
+  test("rejects non-Lambda ARN", () => {
+    expect(
+      validateFunctionSinkField({ provider: "aws_lambda", arn: "arn:aws:s3:::bucket" }).valid
+    ).toBe(false);
+  });
+
+  test("rejects unknown fields", () => {
+    expect(validateFunctionSinkField({ provider: "aws_lambda", arn, region: "x" }).valid).toBe(
+      false
+    );
+  });
+});
+
+describe("validateBodyTransformsField", () => {
+  test("undefined, null and empty array pass", () => {
+    expect(validateBodyTransformsField(undefined)).toEqual({ valid: true });
+    expect(validateBodyTransformsField(null)).toEqual({ valid: true });
+    expect(validateBodyTransformsField([])).toEqual({ valid: true });
+  });
+
+  test("accepts every transform type", () => {
+    expect(
+      validateBodyTransformsField([
+        { type: "map_field", from: "data.object.id", to: "id" },
+        { type: "rename_header", from: "x-stripe-signature", to: "x-signature" },
+        { type: "strip_fields", paths: ["livemode", "data.object.metadata"] },
+        { type: "flatten_array", path: "events" },
+        { type: "flatten_array" },
+      ])
+    ).toEqual({ valid: true });
+  });
+
+  test("rejects non-array and too many transforms", () => {
+    expect(validateBodyTransformsField({ type: "map_field" }).valid).toBe(false);
+    const many = Array.from({ length: MAX_BODY_TRANSFORMS + 1 }, () => ({
+      type: "flatten_array",
+    }));
+    expect(validateBodyTransformsField(many).valid).toBe(false);
+  });
+
+  test("rejects unknown type and unknown fields", () => {
+    expect(validateBodyTransformsField([{ type: "eval", code: "x" }]).valid).toBe(false);
+    expect(
+      validateBodyTransformsField([{ type: "map_field", from: "a", to: "b", keep: true }]).valid
+    ).toBe(false);
+  });
+
+  test("rejects missing or empty paths", () => {
+    expect(validateBodyTransformsField([{ type: "map_field", from: "a" }]).valid).toBe(false);
+    expect(validateBodyTransformsField([{ type: "strip_fields", paths: [] }]).valid).toBe(false);
+    expect(validateBodyTransformsField([{ type: "strip_fields", paths: [""] }]).valid).toBe(false);
+  });
+});
+
+describe("validatePausedResponse", () => {
+  test("accepts no response, or a status with an optional body", () => {
+    expect(validatePausedResponse(undefined)).toEqual({ valid: true, response: null });
+    expect(validatePausedResponse(null)).toEqual({ valid: true, response: null });
+    expect(validatePausedResponse({ status: 503, body: "maintenance" })).toEqual({
+      valid: true,
+      response: { status: 503, body: "maintenance" },
+    });
+    expect(validatePausedResponse({ status: 202 })).toEqual({
+      valid: true,
+      response: { status: 202, body: "" },
+    });
+  });
+
+  test("rejects bad status, body and unknown fields", () => {
+    expect(validatePausedResponse("paused").valid).toBe(false);
+    expect(validatePausedResponse({ status: 700 }).valid).toBe(false);
+    expect(validatePausedResponse({ body: "x" }).valid).toBe(false);
+    expect(validatePausedResponse({ status: 503, body: 1 }).valid).toBe(false);
+    expect(validatePausedResponse({ status: 503, headers: {} }).valid).toBe(false);
+    expect(
+      validatePausedResponse({ status: 503, body: "x".repeat(MAX_PAUSED_BODY_LENGTH + 1) }).valid
+    ).toBe(false);
+  });
+});
+
+describe("validateMockCanary", () => {
+  const response = { status: 202, body: "v2", headers: {} };
+
+  test("accepts a complete mock response and a whole percentage", () => {
+    expect(validateMockCanary({ response, percent: 10 })).toEqual({
+      valid: true,
+      canary: { response, percent: 10 },
+    });
+  });
+
+  test("rejects out-of-range percentages, partial responses and unknown fields", () => {
+    expect(validateMockCanary({ response, percent: 0 }).valid).toBe(false);
+    expect(validateMockCanary({ response, percent: 100 }).valid).toBe(false);
+    expect(validateMockCanary({ response, percent: 12.5 }).valid).toBe(false);
+    expect(validateMockCanary({ response: { status: 202 }, percent: 10 }).valid).toBe(false);
+    expect(validateMockCanary({ percent: 10 }).valid).toBe(false);
+    expect(validateMockCanary({ response, percent: 10, startedAt: "now" }).valid).toBe(false);
+  });
+});
+
+describe("parseAsn", () => {
+  test("accepts bare and AS-prefixed numbers", () => {
+    expect(parseAsn("13335")).toBe(13335);
+    expect(parseAsn("AS13335")).toBe(13335);
+    expect(parseAsn("as64512")).toBe(64512);
+  });
+
+  test("rejects other input and numbers past 32 bits", () => {
+    expect(parseAsn("")).toBeNull();
+    expect(parseAsn("AS")).toBeNull();
+    expect(parseAsn("13335x")).toBeNull();
+    expect(parseAsn("4294967296")).toBeNull();
+  });
+});
@@ -7,6 +7,7 @@ import {
   MOCK_RESPONSE_STATUS_MAX,
   MOCK_RESPONSE_DELAY_MIN,
   MOCK_RESPONSE_DELAY_MAX,
+  MOCK_DEFAULT_BLOCKED_HEADERS,
 } from "@webhooks-cc/sdk";
 
 /**
@@ -47,6 +48,92 @@ export function validateNotificationUrl(
   return { valid: true };
 }
 
+/**
+ * Validate a forwardUrl field from a request body.
+ * Accepts undefined/null (skip/clear), empty string (clear), or an http/https URL (max 2048).
+ * The receiver checks where the host resolves to before each relay.
+ */
+export function validateForwardUrl(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  if (value === undefined || value === null || value === "") {
+    return { valid: true };
+  }
+  if (typeof value !== "string" || value.length > 2048) {
+    return {
+      valid: false,
+      response: Response.json({ error: "Invalid forwardUrl" }, { status: 400 }),
+    };
+  }
+  let parsed: URL;
+  try {
+    parsed = new URL(value);
+  } catch {
+    return {
+      valid: false,
+      response: Response.json({ error: "Invalid forwardUrl format" }, { status: 400 }),
+    };
+  }
+  if (!["http:", "https:"].includes(parsed.protocol)) {
+    return {
+      valid: false,
+      response: Response.json({ error: "forwardUrl must use http or https" }, { status: 400 }),
+    };
+  }
+  return { valid: true };
+}
+
+const LAMBDA_ARN_REGEX =
+  /^arn:aws[a-z-]*:lambda:[a-z0-9-]+:\d{12}:function:[A-Za-z0-9_-]{1,64}(:[A-Za-z0-9_$-]{1,128})?$/;
+
+/**
+ * Validate a functionSink field from a request body.
+ * Accepts undefined/null (skip/clear) or `{ provider: "aws_lambda", arn }` with a Lambda function ARN.
+ */
+export function validateFunctionSinkField(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  if (value === undefined || value === null) {
+    return { valid: true };
+  }
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return {
+      valid: false,
+      response: Response.json({ error: "functionSink must be an object" }, { status: 400 }),
+    };
+  }
+  const sink = value as Record<string, unknown>;
+  if (sink.provider !== "aws_lambda") {
+    return {
+      valid: false,
+      response: Response.json(
+        { error: "functionSink.provider must be \"aws_lambda\"" },
+        { status: 400 }
+      ),
+    };
+  }
+  if (typeof sink.arn !== "string" || !LAMBDA_ARN_REGEX.test(sink.arn)) {
+    return {
+      valid: false,
+      response: Response.json(
+        { error: "functionSink.arn must be a Lambda function ARN" },
+        { status: 400 }
+      ),
+    };
+  }
+  const extraKeys = Object.keys(sink).filter((k) => k !== "provider" && k !== "arn");
+  if (extraKeys.length > 0) {
+    return {
+      valid: false,
+      response: Response.json(
+        { error: `Unknown functionSink field: ${extraKeys[0]}` },
+        { status: 400 }
+      ),
+    };
+  }
+  return { valid: true };
+}
+
 /**
  * Validate a mockResponse field from a request body.
  * For POST (create): all fields required. For PATCH (update): fields are optional.
@@ -101,6 +188,29 @@ export function validateMockResponseField(
     };
   }
 
+  // Binary body, sent in place of body
+  if (mr.bodyBase64 !== undefined && mr.bodyBase64 !== null) {
+    if (
+      typeof mr.bodyBase64 !== "string" ||
+      mr.bodyBase64.length % 4 !== 0 ||
+      !BASE64_REGEX.test(mr.bodyBase64)
+    ) {
+      return {
+        valid: false,
+        response: Response.json({ error: "bodyBase64 must be base64" }, { status: 400 }),
+      };
+    }
+    if (typeof mr.body === "string" && mr.body !== "") {
+      return {
+        valid: false,
+        response: Response.json(
+          { error: "body and bodyBase64 cannot both be set" },
+          { status: 400 }
+        ),
+      };
+    }
+  }
+
   // Headers validation
   if (mr.headers !== undefined) {
     if (typeof mr.headers !== "object" || mr.headers === null || Array.isArray(mr.headers)) {
@@ -141,6 +251,902 @@ export function validateMockResponseField(
     };
   }
 
+  if (
+    mr.delayJitter !== undefined &&
+    (typeof mr.delayJitter !== "number" ||
+      !Number.isInteger(mr.delayJitter) ||
+      mr.delayJitter < MOCK_RESPONSE_DELAY_MIN ||
+      mr.delayJitter > MOCK_RESPONSE_DELAY_MAX)
+  ) {
+    return {
+      valid: false,
+      response: Response.json(
+        {
+          error: `Invalid delayJitter: must be ${MOCK_RESPONSE_DELAY_MIN}-${MOCK_RESPONSE_DELAY_MAX}ms`,
+        },
+        { status: 400 }
+      ),
+    };
+  }
+
+  if (mr.headerPolicy !== undefined && mr.headerPolicy !== null) {
+    const policyCheck = validateHeaderPolicy(mr.headerPolicy);
+    if (!policyCheck.valid) return policyCheck;
+  }
+
+  if (mr.byMethod !== undefined && mr.byMethod !== null) {
+    const byMethodCheck = validateMockByMethod(mr.byMethod);
+    if (!byMethodCheck.valid) return byMethodCheck;
+  }
+
+  if (mr.correlation !== undefined && mr.correlation !== null) {
+    const correlationCheck = validateMockCorrelation(mr.correlation);
+    if (!correlationCheck.valid) return correlationCheck;
+  }
+
+  if (mr.variants !== undefined && mr.variants !== null) {
+    if (mr.correlation !== undefined && mr.correlation !== null) {
+      return {
+        valid: false,
+        response: Response.json(
+          { error: "variants cannot be combined with correlation" },
+          { status: 400 }
+        ),
+      };
+    }
+    if (mr.byMethod !== undefined && mr.byMethod !== null) {
+      return {
+        valid: false,
+        response: Response.json(
+          { error: "variants cannot be combined with byMethod" },
+          { status: 400 }
+        ),
+      };
+    }
+    if (mr.rules !== undefined && mr.rules !== null) {
+      return {
+        valid: false,
+        response: Response.json(
+          { error: "variants cannot be combined with rules" },
+          { status: 400 }
+        ),
+      };
+    }
+    if (mr.sequence !== undefined && mr.sequence !== null) {
+      return {
+        valid: false,
+        response: Response.json(
+          { error: "variants cannot be combined with sequence" },
+          { status: 400 }
+        ),
+      };
+    }
+    if (mr.script !== undefined && mr.script !== null) {
+      return {
+        valid: false,
+        response: Response.json(
+          { error: "variants cannot be combined with script" },
+          { status: 400 }
+        ),
+      };
+    }
+    const variantsCheck = validateMockVariants(mr.variants);
+    if (!variantsCheck.valid) return variantsCheck;
+  }
+
+  if (mr.schedule !== undefined && mr.schedule !== null) {
+    const scheduleCheck = validateMockSchedule(mr.schedule);
+    if (!scheduleCheck.valid) return scheduleCheck;
+  }
+
+  if (mr.rules !== undefined && mr.rules !== null) {
+    const rulesCheck = validateMockRules(mr.rules);
+    if (!rulesCheck.valid) return rulesCheck;
+  }
+
+  if (mr.sequence !== undefined && mr.sequence !== null) {
+    const sequenceCheck = validateMockSequence(mr.sequence);
+    if (!sequenceCheck.valid) return sequenceCheck;
+  }
+
+  if (
+    mr.script !== undefined &&
+    mr.script !== null &&
+    (typeof mr.script !== "string" ||
+      mr.script.trim() === "" ||
+      mr.script.length > MAX_MOCK_SCRIPT_LENGTH)
+  ) {
+    return {
+      valid: false,
+      response: Response.json(
+        {
+          error: `script must be a non-empty string of at most ${MAX_MOCK_SCRIPT_LENGTH} characters`,
+        },
+        { status: 400 }
+      ),
+    };
+  }
+
+  if (mr.echo !== undefined && typeof mr.echo !== "boolean") {
+    return {
+      valid: false,
+      response: Response.json({ error: "echo must be a boolean" }, { status: 400 }),
+    };
+  }
+
+  if (mr.stream !== undefined && mr.stream !== null) {
+    if (mr.echo === true || (mr.bodyBase64 !== undefined && mr.bodyBase64 !== null)) {
+      return {
+        valid: false,
+        response: Response.json(
+          { error: "stream cannot be combined with echo or bodyBase64" },
+          { status: 400 }
+        ),
+      };
+    }
+    const streamCheck = validateMockStream(mr.stream);
+    if (!streamCheck.valid) return streamCheck;
+  }
+
+  if (mr.representations !== undefined && mr.representations !== null) {
+    if (
+      mr.echo === true ||
+      (mr.bodyBase64 !== undefined && mr.bodyBase64 !== null) ||
+      (mr.stream !== undefined && mr.stream !== null)
+    ) {
+      return {
+        valid: false,
+        response: Response.json(
+          { error: "representations cannot be combined with echo, stream or bodyBase64" },
+          { status: 400 }
+        ),
+      };
+    }
+    const representationsCheck = validateMockRepresentations(mr.representations);
+    if (!representationsCheck.valid) return representationsCheck;
+  }
+
+  if (mr.grpc !== undefined && mr.grpc !== null) {
+    const grpcCheck = validateGrpcMockReply(mr.grpc);
+    if (!grpcCheck.valid) return grpcCheck;
+  }
+
+  return { valid: true };
+}
+
+/**
+ * Longest mockResponse.script, a Rhai script the receiver runs per request to
+ * build the reply (after an open schedule window, a matching rule and the
+ * current sequence step, ahead of correlation). It is only compiled there;
+ * scripts that don't compile answer 500 with the error.
+ */
+export const MAX_MOCK_SCRIPT_LENGTH = 16_384;
+
+const BASE64_REGEX = /^[A-Za-z0-9+/]*={0,2}$/;
+
+/** mockResponse fields only the base reply takes; the receiver ignores them elsewhere. */
+const BASE_REPLY_FIELDS = ["bodyBase64", "stream", "representations"];
+
+function baseReplyField(reply: object): string | undefined {
+  return BASE_REPLY_FIELDS.find((field) => field in reply);
+}
+
+/**
+ * Validate mockResponse.grpc: the reply to calls captured on the receiver's
+ * gRPC listener, `{ code, message?, body? }` with a gRPC status code (0-16)
+ * and a base64 serialized response message.
+ */
+function validateGrpcMockReply(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (typeof value !== "object" || value === null || Array.isArray(value)) {
+    return invalid("grpc must be an object");
+  }
+  const reply = value as Record<string, unknown>;
+  if (
+    typeof reply.code !== "number" ||
+    !Number.isInteger(reply.code) ||
+    reply.code < 0 ||
+    reply.code > 16
+  ) {
+    return invalid("grpc.code must be a gRPC status code (0-16)");
+  }
+  if (
+    reply.message !== undefined &&
+    (typeof reply.message !== "string" || reply.message.length > 1024)
+  ) {
+    return invalid("grpc.message must be a string of at most 1024 characters");
+  }
+  if (
+    reply.body !== undefined &&
+    (typeof reply.body !== "string" ||
+      reply.body.length % 4 !== 0 ||
+      !BASE64_REGEX.test(reply.body))
+  ) {
+    return invalid("grpc.body must be base64");
+  }
+  const extraKeys = Object.keys(reply).filter((k) => !["code", "message", "body"].includes(k));
+  if (extraKeys.length > 0) {
+    return invalid(`Unknown grpc field: ${extraKeys[0]}`);
+  }
+  return { valid: true };
+}
+
+export const MAX_MOCK_VARIANTS = 10;
+const VARIANT_NAME_REGEX = /^[a-z0-9_-]{1,32}$/;
+
+/**
+ * Validate mockResponse.variants: weighted alternative replies for A/B
+ * testing senders. Each entry is
+ * `{ name, weight, status, body?, headers?, delay?, delayJitter? }` with a unique
+ * name and a whole-percentage weight; the weights may sum to at most 100, and the
+ * remainder goes to the base response (recorded as `default`).
+ */
+function validateMockVariants(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (!Array.isArray(value)) {
+    return invalid("variants must be an array");
+  }
+  if (value.length > MAX_MOCK_VARIANTS) {
+    return invalid(`variants can have at most ${MAX_MOCK_VARIANTS} entries`);
+  }
+  const names = new Set<string>();
+  let total = 0;
+  for (const entry of value) {
+    if (typeof entry !== "object" || entry === null || Array.isArray(entry)) {
+      return invalid("variants entries must be objects");
+    }
+    const variant = entry as Record<string, unknown>;
+    if (
+      typeof variant.name !== "string" ||
+      !VARIANT_NAME_REGEX.test(variant.name) ||
+      variant.name === "default"
+    ) {
+      return invalid(
+        "variant names must be 1-32 lowercase letters, digits, '-' or '_', and not 'default'"
+      );
+    }
+    if (names.has(variant.name)) {
+      return invalid(`Duplicate variant name: ${variant.name}`);
+    }
+    names.add(variant.name);
+    if (
+      typeof variant.weight !== "number" ||
+      !Number.isInteger(variant.weight) ||
+      variant.weight < 1 ||
+      variant.weight > 100
+    ) {
+      return invalid("variant weight must be a whole percentage from 1 to 100");
+    }
+    total += variant.weight;
+    if ("correlation" in variant || "headerPolicy" in variant || "variants" in variant) {
+      return invalid("variants cannot nest correlation, headerPolicy or variants");
+    }
+    const baseOnly = baseReplyField(variant);
+    if (baseOnly) return invalid(`variants cannot set ${baseOnly}`);
+    if (variant.status === undefined) {
+      return invalid("Invalid status code");
+    }
+    const { name: _name, weight: _weight, ...reply } = variant;
+    const replyCheck = validateMockResponseField(reply, true);
+    if (!replyCheck.valid) return replyCheck;
+  }
+  if (total > 100) {
+    return invalid("variant weights must add up to at most 100");
+  }
+
+  return { valid: true };
+}
+
+export const MAX_MOCK_WINDOWS = 10;
+const WINDOW_TIME_REGEX = /^([01]\d|2[0-3]):[0-5]\d$/;
+const WEEKDAYS = ["sun", "mon", "tue", "wed", "thu", "fri", "sat"];
+
+function isTimeZone(value: string): boolean {
+  try {
+    new Intl.DateTimeFormat("en-US", { timeZone: value });
+    return true;
+  } catch {
+    return false;
+  }
+}
+
+/**
+ * Validate mockResponse.schedule: replies for recurring time windows, such as a
+ * 503 during nightly maintenance. Each entry is
+ * `{ name, start, end, timezone?, days?, status, body?, headers?, delay?, delayJitter? }`
+ * with `HH:MM` wall-clock times in an IANA timezone (default UTC); an end before the
+ * start runs past midnight, and `days` lists the weekdays the window starts on.
+ * capture_webhook answers with the first open window, ahead of correlation and
+ * variants.
+ */
+function validateMockSchedule(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (!Array.isArray(value)) {
+    return invalid("schedule must be an array");
+  }
+  if (value.length > MAX_MOCK_WINDOWS) {
+    return invalid(`schedule can have at most ${MAX_MOCK_WINDOWS} windows`);
+  }
+  const names = new Set<string>();
+  for (const entry of value) {
+    if (typeof entry !== "object" || entry === null || Array.isArray(entry)) {
+      return invalid("schedule entries must be objects");
+    }
+    const window = entry as Record<string, unknown>;
+    if (
+      typeof window.name !== "string" ||
+      !VARIANT_NAME_REGEX.test(window.name) ||
+      window.name === "default"
+    ) {
+      return invalid(
+        "schedule names must be 1-32 lowercase letters, digits, '-' or '_', and not 'default'"
+      );
+    }
+    if (names.has(window.name)) {
+      return invalid(`Duplicate schedule name: ${window.name}`);
+    }
+    names.add(window.name);
+    if (
+      typeof window.start !== "string" ||
+      typeof window.end !== "string" ||
+      !WINDOW_TIME_REGEX.test(window.start) ||
+      !WINDOW_TIME_REGEX.test(window.end) ||
+      window.start === window.end
+    ) {
+      return invalid("schedule start and end must be different HH:MM times");
+    }
+    if (
+      window.timezone !== undefined &&
+      (typeof window.timezone !== "string" || !isTimeZone(window.timezone))
+    ) {
+      return invalid("schedule timezone must be an IANA timezone such as Europe/Berlin");
+    }
+    if (
+      window.days !== undefined &&
+      (!Array.isArray(window.days) ||
+        window.days.length === 0 ||
+        !window.days.every((day) => typeof day === "string" && WEEKDAYS.includes(day)))
+    ) {
+      return invalid("schedule days must be a non-empty list of sun, mon, tue, wed, thu, fri, sat");
+    }
+    if ("correlation" in window || "headerPolicy" in window || "variants" in window) {
+      return invalid("schedule windows cannot nest correlation, headerPolicy or variants");
+    }
+    const baseOnly = baseReplyField(window);
+    if (baseOnly) return invalid(`schedule windows cannot set ${baseOnly}`);
+    if (window.status === undefined) {
+      return invalid("Invalid status code");
+    }
+    const {
+      name: _name,
+      start: _start,
+      end: _end,
+      timezone: _timezone,
+      days: _days,
+      ...reply
+    } = window;
+    const replyCheck = validateMockResponseField(reply, true);
+    if (!replyCheck.valid) return replyCheck;
+  }
+
+  return { valid: true };
+}
+
+export const MAX_MOCK_RULES = 20;
+const MAX_MOCK_RULE_CONDITIONS = 10;
+const MAX_MOCK_RULE_PATTERN_LENGTH = 256;
+const RULE_METHOD_REGEX = /^[A-Z]{1,20}$/;
+
+/**
+ * Check one `match` map (`headers`, `query` or `body`): up to
+ * MAX_MOCK_RULE_CONDITIONS names, each with a string value pattern.
+ */
+function isRulePatternMap(value: unknown): boolean {
+  if (typeof value !== "object" || value === null || Array.isArray(value)) return false;
+  const entries = Object.entries(value as Record<string, unknown>);
+  return (
+    entries.length > 0 &&
+    entries.length <= MAX_MOCK_RULE_CONDITIONS &&
+    entries.every(
+      ([name, pattern]) =>
+        name.length > 0 &&
+        name.length <= MAX_MOCK_RULE_PATTERN_LENGTH &&
+        typeof pattern === "string" &&
+        pattern.length <= MAX_MOCK_RULE_PATTERN_LENGTH
+    )
+  );
+}
+
+/**
+ * Validate mockResponse.rules: ordered conditional replies, each
+ * `{ match, status, body?, headers?, delay?, delayJitter? }`. `match` can set
+ * `methods`, a `path` glob, and `headers`, `query` and `body` (JSON path) maps of
+ * value patterns where `*` matches any run of characters. The receiver answers
+ * with the first matching rule, after an open schedule window and ahead of
+ * correlation and variants.
+ */
+function validateMockRules(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (!Array.isArray(value)) {
+    return invalid("rules must be an array");
+  }
+  if (value.length > MAX_MOCK_RULES) {
+    return invalid(`rules can have at most ${MAX_MOCK_RULES} entries`);
+  }
+  for (const entry of value) {
+    if (typeof entry !== "object" || entry === null || Array.isArray(entry)) {
+      return invalid("rules entries must be objects");
+    }
+    const rule = entry as Record<string, unknown>;
+    const match = rule.match;
+    if (typeof match !== "object" || match === null || Array.isArray(match)) {
+      return invalid("rules entries need a match object");
+    }
+    const conditions = match as Record<string, unknown>;
+    if (
+      conditions.methods !== undefined &&
+      (!Array.isArray(conditions.methods) ||
+        conditions.methods.length === 0 ||
+        !conditions.methods.every((m) => typeof m === "string" && RULE_METHOD_REGEX.test(m)))
+    ) {
+      return invalid("rule match.methods must be a non-empty list of uppercase HTTP methods");
+    }
+    if (
+      conditions.path !== undefined &&
+      (typeof conditions.path !== "string" ||
+        !/^[/*]/.test(conditions.path) ||
+        conditions.path.length > MAX_MOCK_RULE_PATTERN_LENGTH)
+    ) {
+      return invalid(
+        `rule match.path must be a glob starting with / or * of at most ${MAX_MOCK_RULE_PATTERN_LENGTH} characters`
+      );
+    }
+    for (const field of ["headers", "query", "body"] as const) {
+      if (conditions[field] !== undefined && !isRulePatternMap(conditions[field])) {
+        return invalid(
+          `rule match.${field} must map 1-${MAX_MOCK_RULE_CONDITIONS} names to string patterns`
+        );
+      }
+    }
+    const extraKeys = Object.keys(conditions).filter(
+      (k) => !["methods", "path", "headers", "query", "body"].includes(k)
+    );
+    if (extraKeys.length > 0) {
+      return invalid(`Unknown rule match field: ${extraKeys[0]}`);
+    }
+    if (
+      "correlation" in rule ||
+      "headerPolicy" in rule ||
+      "variants" in rule ||
+      "schedule" in rule ||
+      "rules" in rule ||
+      "sequence" in rule ||
+      "script" in rule
+    ) {
+      return invalid(
+        "rules cannot nest correlation, headerPolicy, variants, schedule, rules, sequence or script"
+      );
+    }
+    const baseOnly = baseReplyField(rule);
+    if (baseOnly) return invalid(`rules cannot set ${baseOnly}`);
+    if (rule.status === undefined) {
+      return invalid("Invalid status code");
+    }
+    const { match: _match, ...reply } = rule;
+    const replyCheck = validateMockResponseField(reply, true);
+    if (!replyCheck.valid) return replyCheck;
+  }
+
+  return { valid: true };
+}
+
+export const MAX_MOCK_SEQUENCE_STEPS = 20;
+const MAX_MOCK_SEQUENCE_STEP_COUNT = 1000;
+const MAX_MOCK_STREAM_EVENTS = 100;
+const MAX_MOCK_STREAM_DURATION = 60_000;
+const MAX_MOCK_SEQUENCE_RESET_AFTER = 86_400;
+
+export const MAX_MOCK_REPRESENTATIONS = 10;
+const MEDIA_TYPE_REGEX = /^[a-z0-9][a-z0-9!#$&^_.+-]{0,63}\/[a-z0-9][a-z0-9!#$&^_.+-]{0,63}$/;
+
+/**
+ * Validate mockResponse.representations: `{ [mediaType]: body }`, bodies the
+ * receiver sends in place of the base reply's when the request's Accept header
+ * prefers their media type (lowercase `type/subtype`, no wildcards or
+ * parameters). Requests accepting anything keep the base body.
+ */
+function validateMockRepresentations(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (typeof value !== "object" || value === null || Array.isArray(value)) {
+    return invalid("representations must be an object");
+  }
+  const entries = Object.entries(value as Record<string, unknown>);
+  if (entries.length > MAX_MOCK_REPRESENTATIONS) {
+    return invalid(`representations can have at most ${MAX_MOCK_REPRESENTATIONS} media types`);
+  }
+  for (const [mediaType, body] of entries) {
+    if (!MEDIA_TYPE_REGEX.test(mediaType)) {
+      return invalid(
+        "representations keys must be lowercase media types, e.g. application/json or text/html"
+      );
+    }
+    if (typeof body !== "string") {
+      return invalid("representations values must be strings");
+    }
+  }
+  return { valid: true };
+}
+
+/**
+ * Validate mockResponse.stream: `{ format?, events }`, sent by the receiver in
+ * place of the base reply's body. `format` is `sse` (the default, each event a
+ * `text/event-stream` message) or `chunked` (each event's `data` as is). Up to
+ * 100 events `{ data?, event?, id?, delay? }`; `event` and `id` are single
+ * lines, and the per-event delays (ms) add up to at most 60 seconds.
+ */
+function validateMockStream(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (typeof value !== "object" || value === null || Array.isArray(value)) {
+    return invalid("stream must be an object");
+  }
+  const stream = value as Record<string, unknown>;
+  if (stream.format !== undefined && stream.format !== "sse" && stream.format !== "chunked") {
+    return invalid("stream.format must be sse or chunked");
+  }
+  const events = stream.events;
+  if (!Array.isArray(events) || events.length === 0 || events.length > MAX_MOCK_STREAM_EVENTS) {
+    return invalid(`stream.events must have 1-${MAX_MOCK_STREAM_EVENTS} entries`);
+  }
+  let total = 0;
+  for (const entry of events) {
+    if (typeof entry !== "object" || entry === null || Array.isArray(entry)) {
+      return invalid("stream.events entries must be objects");
+    }
+    const event = entry as Record<string, unknown>;
+    if (event.data !== undefined && typeof event.data !== "string") {
+      return invalid("stream event data must be a string");
+    }
+    for (const field of ["event", "id"] as const) {
+      const fieldValue = event[field];
+      if (
+        fieldValue !== undefined &&
+        (typeof fieldValue !== "string" || /[\r\n]/.test(fieldValue))
+      ) {
+        return invalid(`stream event ${field} must be a single line`);
+      }
+    }
+    if (
+      event.delay !== undefined &&
+      (typeof event.delay !== "number" ||
+        !Number.isInteger(event.delay) ||
+        event.delay < MOCK_RESPONSE_DELAY_MIN ||
+        event.delay > MOCK_RESPONSE_DELAY_MAX)
+    ) {
+      return invalid(
+        `stream event delay must be ${MOCK_RESPONSE_DELAY_MIN}-${MOCK_RESPONSE_DELAY_MAX}ms`
+      );
+    }
+    total += (event.delay as number | undefined) ?? 0;
+    const extraKeys = Object.keys(event).filter(
+      (k) => !["data", "event", "id", "delay"].includes(k)
+    );
+    if (extraKeys.length > 0) {
+      return invalid(`Unknown stream event field: ${extraKeys[0]}`);
+    }
+  }
+  if (total > MAX_MOCK_STREAM_DURATION) {
+    return invalid(`stream event delays can add up to at most ${MAX_MOCK_STREAM_DURATION}ms`);
+  }
+  const extraKeys = Object.keys(stream).filter((k) => !["format", "events"].includes(k));
+  if (extraKeys.length > 0) {
+    return invalid(`Unknown stream field: ${extraKeys[0]}`);
+  }
+  return { valid: true };
+}
+
+/**
+ * Validate mockResponse.sequence: `{ steps, key?, repeat?, resetAfter? }`. Each
+ * step is a reply with an optional `count` (1-1000) of requests it answers, in
+ * order. `key` names a request field as in correlation keys, giving each value
+ * its own position; `repeat` starts over after the last step; `resetAfter`
+ * (seconds) starts an idle position over. The receiver answers with the
+ * current step after an open schedule window and a matching rule, ahead of
+ * correlation.
+ */
+function validateMockSequence(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (typeof value !== "object" || value === null || Array.isArray(value)) {
+    return invalid("sequence must be an object");
+  }
+  const sequence = value as Record<string, unknown>;
+  const steps = sequence.steps;
+  if (!Array.isArray(steps) || steps.length === 0 || steps.length > MAX_MOCK_SEQUENCE_STEPS) {
+    return invalid(`sequence.steps must have 1-${MAX_MOCK_SEQUENCE_STEPS} entries`);
+  }
+  for (const entry of steps) {
+    if (typeof entry !== "object" || entry === null || Array.isArray(entry)) {
+      return invalid("sequence.steps entries must be objects");
+    }
+    const step = entry as Record<string, unknown>;
+    if (
+      step.count !== undefined &&
+      (typeof step.count !== "number" ||
+        !Number.isInteger(step.count) ||
+        step.count < 1 ||
+        step.count > MAX_MOCK_SEQUENCE_STEP_COUNT)
+    ) {
+      return invalid(
+        `sequence step count must be an integer from 1 to ${MAX_MOCK_SEQUENCE_STEP_COUNT}`
+      );
+    }
+    if (
+      "correlation" in step ||
+      "headerPolicy" in step ||
+      "variants" in step ||
+      "schedule" in step ||
+      "rules" in step ||
+      "sequence" in step ||
+      "script" in step
+    ) {
+      return invalid(
+        "sequence steps cannot nest correlation, headerPolicy, variants, schedule, rules, sequence or script"
+      );
+    }
+    const baseOnly = baseReplyField(step);
+    if (baseOnly) return invalid(`sequence steps cannot set ${baseOnly}`);
+    if (step.status === undefined) {
+      return invalid("Invalid status code");
+    }
+    const { count: _count, ...reply } = step;
+    const replyCheck = validateMockResponseField(reply, true);
+    if (!replyCheck.valid) return replyCheck;
+  }
+  if (
+    sequence.key !== undefined &&
+    (typeof sequence.key !== "string" || !CORRELATION_KEY_REGEX.test(sequence.key))
+  ) {
+    return invalid(
+      "sequence.key must be method, path, header.<name>, query.<name>, body.form.<name> or body.json.<path>"
+    );
+  }
+  if (sequence.repeat !== undefined && typeof sequence.repeat !== "boolean") {
+    return invalid("sequence.repeat must be a boolean");
+  }
+  if (
+    sequence.resetAfter !== undefined &&
+    (typeof sequence.resetAfter !== "number" ||
+      !Number.isInteger(sequence.resetAfter) ||
+      sequence.resetAfter < 1 ||
+      sequence.resetAfter > MAX_MOCK_SEQUENCE_RESET_AFTER)
+  ) {
+    return invalid(
+      `sequence.resetAfter must be an integer from 1 to ${MAX_MOCK_SEQUENCE_RESET_AFTER} seconds`
+    );
+  }
+  const extraKeys = Object.keys(sequence).filter(
+    (k) => !["steps", "key", "repeat", "resetAfter"].includes(k)
+  );
+  if (extraKeys.length > 0) {
+    return invalid(`Unknown sequence field: ${extraKeys[0]}`);
+  }
+
+  return { valid: true };
+}
+
+export const MAX_MOCK_CORRELATION_ENTRIES = 100;
+const MAX_CORRELATION_VALUE_LENGTH = 256;
+const CORRELATION_KEY_REGEX = /^(method|path|(header|query|body\.form|body\.json)\.\S{1,256})$/;
+
+/**
+ * Validate mockResponse.correlation: `{ key, responses }`. `key` names a request
+ * field (`body.json.order_id`, `header.x-event-id`, `query.id`, `body.form.CallSid`,
+ * `method`, `path`); each response is a full mock response without its own
+ * correlation or header policy.
+ */
+function validateMockCorrelation(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (typeof value !== "object" || value === null || Array.isArray(value)) {
+    return invalid("correlation must be an object");
+  }
+  const correlation = value as Record<string, unknown>;
+  if (typeof correlation.key !== "string" || !CORRELATION_KEY_REGEX.test(correlation.key)) {
+    return invalid(
+      "correlation.key must be method, path, header.<name>, query.<name>, body.form.<name> or body.json.<path>"
+    );
+  }
+  const responses = correlation.responses;
+  if (typeof responses !== "object" || responses === null || Array.isArray(responses)) {
+    return invalid("correlation.responses must be an object");
+  }
+  const entries = Object.entries(responses as Record<string, unknown>);
+  if (entries.length > MAX_MOCK_CORRELATION_ENTRIES) {
+    return invalid(
+      `correlation.responses can have at most ${MAX_MOCK_CORRELATION_ENTRIES} entries`
+    );
+  }
+  for (const [match, entry] of entries) {
+    if (match.length > MAX_CORRELATION_VALUE_LENGTH) {
+      return invalid(
+        `correlation.responses keys must be at most ${MAX_CORRELATION_VALUE_LENGTH} characters`
+      );
+    }
+    if (typeof entry !== "object" || entry === null || Array.isArray(entry)) {
+      return invalid("correlation.responses entries must be objects");
+    }
+    if ("correlation" in entry || "headerPolicy" in entry) {
+      return invalid("correlation.responses entries cannot nest correlation or headerPolicy");
+    }
+    const baseOnly = baseReplyField(entry);
+    if (baseOnly) return invalid(`correlation.responses entries cannot set ${baseOnly}`);
+    // body and headers default to empty; status is required
+    if ((entry as Record<string, unknown>).status === undefined) {
+      return invalid("Invalid status code");
+    }
+    const entryCheck = validateMockResponseField(entry, true);
+    if (!entryCheck.valid) return entryCheck;
+  }
+
+  const extraKeys = Object.keys(correlation).filter((k) => k !== "key" && k !== "responses");
+  if (extraKeys.length > 0) {
+    return invalid(`Unknown correlation field: ${extraKeys[0]}`);
+  }
+
+  return { valid: true };
+}
+
+export const MAX_MOCK_BY_METHOD_ENTRIES = 20;
+const HTTP_METHOD_REGEX = /^[A-Z][A-Z-]{0,31}$/;
+
+/**
+ * Validate mockResponse.byMethod: `{ [method]: reply }`. Requests with one of the
+ * methods (uppercase, as sent) get its reply in place of the base reply, after rules,
+ * sequence steps and the script and ahead of correlation. Each reply takes `status`,
+ * `body`, `headers`, `delay` and `delayJitter`.
+ */
+function validateMockByMethod(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (typeof value !== "object" || value === null || Array.isArray(value)) {
+    return invalid("byMethod must be an object");
+  }
+  const entries = Object.entries(value as Record<string, unknown>);
+  if (entries.length > MAX_MOCK_BY_METHOD_ENTRIES) {
+    return invalid(`byMethod can have at most ${MAX_MOCK_BY_METHOD_ENTRIES} methods`);
+  }
+  for (const [method, reply] of entries) {
+    if (!HTTP_METHOD_REGEX.test(method)) {
+      return invalid("byMethod keys must be uppercase HTTP methods, e.g. GET or POST");
+    }
+    if (typeof reply !== "object" || reply === null || Array.isArray(reply)) {
+      return invalid("byMethod entries must be objects");
+    }
+    if (
+      "byMethod" in reply ||
+      "correlation" in reply ||
+      "headerPolicy" in reply ||
+      "variants" in reply ||
+      "schedule" in reply ||
+      "rules" in reply ||
+      "sequence" in reply ||
+      "script" in reply
+    ) {
+      return invalid(
+        "byMethod entries cannot nest byMethod, correlation, headerPolicy, variants, schedule, rules, sequence or script"
+      );
+    }
+    const baseOnly = baseReplyField(reply);
+    if (baseOnly) return invalid(`byMethod entries cannot set ${baseOnly}`);
+    // body and headers default to empty; status is required
+    if ((reply as Record<string, unknown>).status === undefined) {
+      return invalid("Invalid status code");
+    }
+    const replyCheck = validateMockResponseField(reply, true);
+    if (!replyCheck.valid) return replyCheck;
+  }
+
+  return { valid: true };
+}
+
+export const MAX_HEADER_POLICY_ENTRIES = 20;
+const HEADER_NAME_REGEX = /^[!#$%&'*+.^_`|~0-9A-Za-z-]{1,256}$/;
+
+/**
+ * Validate mockResponse.headerPolicy: `{ allow?: string[], block?: string[] }`.
+ * `allow` may only name headers the receiver blocks by default; `block` takes any header name.
+ */
+function validateHeaderPolicy(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (typeof value !== "object" || value === null || Array.isArray(value)) {
+    return invalid("headerPolicy must be an object");
+  }
+  const policy = value as Record<string, unknown>;
+  const extraKeys = Object.keys(policy).filter((k) => k !== "allow" && k !== "block");
+  if (extraKeys.length > 0) {
+    return invalid(`Unknown headerPolicy field: ${extraKeys[0]}`);
+  }
+
+  for (const key of ["allow", "block"] as const) {
+    const list = policy[key];
+    if (list === undefined) continue;
+    if (!Array.isArray(list) || list.length > MAX_HEADER_POLICY_ENTRIES) {
+      return invalid(
+        `headerPolicy.${key} must be an array of at most ${MAX_HEADER_POLICY_ENTRIES} header names`
+      );
+    }
+    for (const name of list) {
+      if (typeof name !== "string" || !HEADER_NAME_REGEX.test(name)) {
+        return invalid(`Invalid header name in headerPolicy.${key}`);
+      }
+      if (
+        key === "allow" &&
+        !(MOCK_DEFAULT_BLOCKED_HEADERS as readonly string[]).includes(name.toLowerCase())
+      ) {
+        return invalid(
+          `headerPolicy.allow may only contain ${MOCK_DEFAULT_BLOCKED_HEADERS.join(", ")}`
+        );
+      }
+    }
+  }
+
   return { valid: true };
 }
 
@@ -208,3 +1214,277 @@ export async function parseJsonBody(
 
   return { data };
 }
+
+export const MAX_BODY_TRANSFORMS = 20;
+const MAX_TRANSFORM_PATH_LENGTH = 256;
+const MAX_STRIP_PATHS = 50;
+
+const BODY_TRANSFORM_FIELDS: Record<string, string[]> = {
+  map_field: ["from", "to"],
+  rename_header: ["from", "to"],
+  strip_fields: ["paths"],
+  flatten_array: ["path"],
+};
+
+function isTransformPath(value: unknown, allowEmpty = false): boolean {
+  return (
+    typeof value === "string" &&
+    value.length <= MAX_TRANSFORM_PATH_LENGTH &&
+    (allowEmpty || value.length > 0)
+  );
+}
+
+/**
+ * Validate a bodyTransforms field from a request body.
+ * Accepts undefined/null (skip/clear) or an ordered array of at most
+ * MAX_BODY_TRANSFORMS transforms, each tagged by `type`.
+ */
+export function validateBodyTransformsField(
+  value: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (value === undefined || value === null) {
+    return { valid: true };
+  }
+  if (!Array.isArray(value)) {
+    return invalid("bodyTransforms must be an array");
+  }
+  if (value.length > MAX_BODY_TRANSFORMS) {
+    return invalid(`bodyTransforms allows at most ${MAX_BODY_TRANSFORMS} transforms`);
+  }
+
+  for (const [i, item] of value.entries()) {
+    if (!item || typeof item !== "object" || Array.isArray(item)) {
+      return invalid(`bodyTransforms[${i}] must be an object`);
+    }
+    const transform = item as Record<string, unknown>;
+    const type = transform.type;
+    if (typeof type !== "string" || !(type in BODY_TRANSFORM_FIELDS)) {
+      return invalid(
+        `bodyTransforms[${i}].type must be one of: ${Object.keys(BODY_TRANSFORM_FIELDS).join(", ")}`
+      );
+    }
+
+    const allowed = BODY_TRANSFORM_FIELDS[type];
+    const extraKeys = Object.keys(transform).filter((k) => k !== "type" && !allowed.includes(k));
+    if (extraKeys.length > 0) {
+      return invalid(`Unknown bodyTransforms[${i}] field: ${extraKeys[0]}`);
+    }
+
+    switch (type) {
+      case "map_field":
+      case "rename_header":
+        if (!isTransformPath(transform.from) || !isTransformPath(transform.to)) {
+          return invalid(`bodyTransforms[${i}] needs non-empty "from" and "to" strings`);
+        }
+        break;
+      case "strip_fields":
+        if (
+          !Array.isArray(transform.paths) ||
+          transform.paths.length === 0 ||
+          transform.paths.length > MAX_STRIP_PATHS ||
+          !transform.paths.every((path) => isTransformPath(path))
+        ) {
+          return invalid(
+            `bodyTransforms[${i}].paths must be 1-${MAX_STRIP_PATHS} non-empty strings`
+          );
+        }
+        break;
+      case "flatten_array":
+        if (transform.path !== undefined && !isTransformPath(transform.path, true)) {
+          return invalid(`bodyTransforms[${i}].path must be a string`);
+        }
+        break;
+    }
+  }
+
+  return { valid: true };
+}
+
+export const MAX_REQUEST_NOTE_LENGTH = 2000;
+export const MAX_REQUEST_TAGS = 10;
+const REQUEST_TAG_PATTERN = /^[a-z0-9_-]{1,32}$/;
+
+/** Whether `value` is a valid request tag: 1-32 of a-z, 0-9, '_' and '-'. */
+export function isRequestTag(value: unknown): value is string {
+  return typeof value === "string" && REQUEST_TAG_PATTERN.test(value);
+}
+
+const COUNTRY_CODE_PATTERN = /^[A-Z][A-Z0-9]$/;
+
+/** Whether `value` is a two-letter country code as stored on requests (uppercase). */
+export function isCountryCode(value: unknown): value is string {
+  return typeof value === "string" && COUNTRY_CODE_PATTERN.test(value);
+}
+
+/** An autonomous system number filter ("13335" or "AS13335"), or null when invalid. */
+export function parseAsn(value: string): number | null {
+  const digits = value.replace(/^AS/i, "");
+  if (!/^\d{1,10}$/.test(digits)) return null;
+  const asn = Number(digits);
+  return asn <= 0xffffffff ? asn : null;
+}
+
+/**
+ * The `reveal` query parameter of request listings: whether encrypted header
+ * values come back opened (the default) or masked. Null when invalid.
+ */
+export function parseReveal(value: string | null): boolean | null {
+  if (value === null || value === "true") return true;
+  if (value === "false") return false;
+  return null;
+}
+
+/** Longest CloudEvents attribute the receiver records. */
+export const MAX_CLOUD_EVENT_TYPE_LENGTH = 1024;
+
+/** Whether `value` could be a recorded CloudEvents `type`. */
+export function isCloudEventType(value: unknown): value is string {
+  return (
+    typeof value === "string" &&
+    value.trim() === value &&
+    value.length > 0 &&
+    value.length <= MAX_CLOUD_EVENT_TYPE_LENGTH
+  );
+}
+
+/** Body classes the receiver assigns at capture (`contentClass`). */
+export const CONTENT_CLASSES = [
+  "json",
+  "xml",
+  "form",
+  "text",
+  "image",
+  "protobuf",
+  "binary",
+] as const;
+export type ContentClass = (typeof CONTENT_CLASSES)[number];
+
+export function isContentClass(value: unknown): value is ContentClass {
+  return typeof value === "string" && (CONTENT_CLASSES as readonly string[]).includes(value);
+}
+
+/**
+ * Validate the note and tags fields of a request annotation.
+ * `note` accepts undefined (skip), null or "" (clear), or a string of at most
+ * MAX_REQUEST_NOTE_LENGTH characters. `tags` accepts undefined (skip) or an
+ * array of at most MAX_REQUEST_TAGS distinct tags.
+ */
+export function validateRequestAnnotation(
+  note: unknown,
+  tags: unknown
+): { valid: true } | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (note !== undefined && note !== null) {
+    if (typeof note !== "string" || note.length > MAX_REQUEST_NOTE_LENGTH) {
+      return invalid(`note must be a string of at most ${MAX_REQUEST_NOTE_LENGTH} characters`);
+    }
+  }
+
+  if (tags !== undefined) {
+    if (!Array.isArray(tags)) {
+      return invalid("tags must be an array");
+    }
+    if (tags.length > MAX_REQUEST_TAGS) {
+      return invalid(`A request can have at most ${MAX_REQUEST_TAGS} tags`);
+    }
+    const bad = tags.find((tag) => !isRequestTag(tag));
+    if (bad !== undefined) {
+      return invalid("Tags are 1-32 characters of a-z, 0-9, '_' and '-'");
+    }
+    if (new Set(tags).size !== tags.length) {
+      return invalid("tags must not contain duplicates");
+    }
+  }
+
+  return { valid: true };
+}
+
+export const MAX_PAUSED_BODY_LENGTH = 4096;
+
+/**
+ * Validate the reply a paused endpoint sends instead of capturing. Accepts
+ * undefined or null (the receiver's default `paused` error) or
+ * `{ status, body? }` with a 100-599 status and a body of at most
+ * MAX_PAUSED_BODY_LENGTH bytes.
+ */
+export function validatePausedResponse(
+  value: unknown
+):
+  | { valid: true; response: { status: number; body: string } | null }
+  | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (value === undefined || value === null) return { valid: true, response: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return invalid("response must be an object with status and body");
+  }
+
+  const { status, body, ...rest } = value as Record<string, unknown>;
+  if (Object.keys(rest).length > 0) {
+    return invalid(`Unknown response field: ${Object.keys(rest)[0]}`);
+  }
+  if (
+    typeof status !== "number" ||
+    !Number.isInteger(status) ||
+    status < MOCK_RESPONSE_STATUS_MIN ||
+    status > MOCK_RESPONSE_STATUS_MAX
+  ) {
+    return invalid("Invalid status code");
+  }
+  if (body !== undefined && typeof body !== "string") {
+    return invalid("response body must be a string");
+  }
+  if (body !== undefined && Buffer.byteLength(body) > MAX_PAUSED_BODY_LENGTH) {
+    return invalid(`response body can be at most ${MAX_PAUSED_BODY_LENGTH} bytes`);
+  }
+
+  return { valid: true, response: { status, body: body ?? "" } };
+}
+
+/**
+ * Validate the body starting a mock canary: `{ response, percent }` where
+ * `response` is a complete mock response (schedule, variants and correlation
+ * included) and `percent` the whole-number share of senders, 1-99, that get it
+ * instead of the endpoint's current mock.
+ */
+export function validateMockCanary(
+  value: unknown
+):
+  | { valid: true; canary: { response: Record<string, unknown>; percent: number } }
+  | { valid: false; response: Response } {
+  const invalid = (error: string) => ({
+    valid: false as const,
+    response: Response.json({ error }, { status: 400 }),
+  });
+
+  if (typeof value !== "object" || value === null || Array.isArray(value)) {
+    return invalid("Expected JSON object");
+  }
+
+  const { response, percent, ...rest } = value as Record<string, unknown>;
+  if (Object.keys(rest).length > 0) {
+    return invalid(`Unknown canary field: ${Object.keys(rest)[0]}`);
+  }
+  if (typeof percent !== "number" || !Number.isInteger(percent) || percent < 1 || percent > 99) {
+    return invalid("percent must be an integer from 1 to 99");
+  }
+  if (typeof response !== "object" || response === null || Array.isArray(response)) {
+    return invalid("response must be a mock response object");
+  }
+  const responseCheck = validateMockResponseField(response);
+  if (!responseCheck.valid) return responseCheck;
+
+  return { valid: true, canary: { response: response as Record<string, unknown>, percent } };
+}
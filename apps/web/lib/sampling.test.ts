@@ -0,0 +1,25 @@
+import { describe, expect, test } from "vitest";
+
+import { parseSampling } from "./sampling";
+
+describe("parseSampling", () => {
+  test("accepts a rate, a per-minute cap or both", () => {
+    expect(parseSampling({ rate: 10 })).toEqual({ valid: true, value: { rate: 10 } });
+    expect(parseSampling({ perMinute: 600 })).toEqual({ valid: true, value: { perMinute: 600 } });
+    expect(parseSampling({ rate: 100, perMinute: 60, extra: true })).toEqual({
+      valid: true,
+      value: { rate: 100, perMinute: 60 },
+    });
+    expect(parseSampling(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects malformed settings", () => {
+    expect(parseSampling({}).valid).toBe(false);
+    expect(parseSampling({ rate: 1 }).valid).toBe(false);
+    expect(parseSampling({ rate: 2.5 }).valid).toBe(false);
+    expect(parseSampling({ rate: "10" }).valid).toBe(false);
+    expect(parseSampling({ perMinute: 0 }).valid).toBe(false);
+    expect(parseSampling({ perMinute: 2_000_000 }).valid).toBe(false);
+    expect(parseSampling(10).valid).toBe(false);
+  });
+});
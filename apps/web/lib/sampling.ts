@@ -0,0 +1,60 @@
+/**
+ * Per-endpoint traffic sampling for very busy endpoints. The receiver stores
+ * one request in `rate` and at most `perMinute` requests a minute (per
+ * receiver instance); the rest get the mock response without being stored
+ * or charged. Every request is counted in the endpoint's sampling stats.
+ * Mirrors sampling_valid() in migration 00075.
+ */
+export interface SamplingConfig {
+  /** Store one request in this many (2-1000000) */
+  rate?: number;
+  /** Store at most this many requests a minute (1-1000000) */
+  perMinute?: number;
+}
+
+export const MAX_SAMPLING_VALUE = 1_000_000;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+function isWhole(value: unknown, min: number): value is number {
+  return (
+    typeof value === "number" &&
+    Number.isInteger(value) &&
+    value >= min &&
+    value <= MAX_SAMPLING_VALUE
+  );
+}
+
+/** Validate a `sampling` setting. Null stores every request again. */
+export function parseSampling(value: unknown): ParseResult<SamplingConfig | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "sampling must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+  const config: SamplingConfig = {};
+
+  if (input.rate !== undefined) {
+    if (!isWhole(input.rate, 2)) {
+      return {
+        valid: false,
+        error: `sampling.rate must be an integer from 2 to ${MAX_SAMPLING_VALUE}`,
+      };
+    }
+    config.rate = input.rate;
+  }
+  if (input.perMinute !== undefined) {
+    if (!isWhole(input.perMinute, 1)) {
+      return {
+        valid: false,
+        error: `sampling.perMinute must be an integer from 1 to ${MAX_SAMPLING_VALUE}`,
+      };
+    }
+    config.perMinute = input.perMinute;
+  }
+
+  if (config.rate === undefined && config.perMinute === undefined) {
+    return { valid: false, error: "sampling needs rate or perMinute" };
+  }
+  return { valid: true, value: config };
+}
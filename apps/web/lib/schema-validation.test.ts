@@ -0,0 +1,78 @@
+import { describe, expect, test } from "vitest";
+
+import {
+  normalizeSchemaErrors,
+  normalizeSchemaValidation,
+  parseSchemaValidation,
+} from "./schema-validation";
+
+describe("parseSchemaValidation", () => {
+  const schema = {
+    type: "object",
+    required: ["id"],
+    properties: { id: { type: "string", pattern: "^evt_" } },
+  };
+
+  test("keeps the schema and defaults the failure status", () => {
+    expect(parseSchemaValidation({ schema })).toEqual({ valid: true, value: { schema } });
+    expect(parseSchemaValidation({ schema, failureResponse: {} })).toEqual({
+      valid: true,
+      value: { schema, failureResponse: { status: 422 } },
+    });
+    expect(
+      parseSchemaValidation({ schema: true, failureResponse: { status: 400, body: "rejected" } })
+    ).toEqual({
+      valid: true,
+      value: { schema: true, failureResponse: { status: 400, body: "rejected" } },
+    });
+    expect(parseSchemaValidation(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects schemas the receiver can't evaluate", () => {
+    expect(parseSchemaValidation({}).valid).toBe(false);
+    expect(parseSchemaValidation({ schema: [] }).valid).toBe(false);
+    expect(parseSchemaValidation({ schema: { $ref: "https://example.com/s.json" } })).toEqual({
+      valid: false,
+      error: 'schemaValidation.schema: /$ref must point within the schema ("#/...")',
+    });
+    expect(parseSchemaValidation({ schema: { pattern: "(" } }).valid).toBe(false);
+    expect(
+      parseSchemaValidation({ schema: { properties: { a: { pattern: "^(?!x)" } } } }).valid
+    ).toBe(false);
+    expect(parseSchemaValidation({ schema: { description: "x".repeat(70_000) } }).valid).toBe(
+      false
+    );
+  });
+
+  test("checks the failure response", () => {
+    expect(parseSchemaValidation({ schema, failureResponse: { status: 200 } }).valid).toBe(false);
+    expect(parseSchemaValidation({ schema, failureResponse: { status: 422.5 } }).valid).toBe(false);
+    expect(parseSchemaValidation({ schema, failureResponse: { body: 1 } }).valid).toBe(false);
+    expect(parseSchemaValidation({ schema, failureResponse: "422" }).valid).toBe(false);
+  });
+});
+
+describe("normalizeSchemaValidation", () => {
+  test("reads the stored config", () => {
+    expect(normalizeSchemaValidation({ schema: { type: "object" } })).toEqual({
+      schema: { type: "object" },
+    });
+    expect(normalizeSchemaValidation({ schema: false, failureResponse: { status: 422 } })).toEqual({
+      schema: false,
+      failureResponse: { status: 422 },
+    });
+    expect(normalizeSchemaValidation(null)).toBeNull();
+  });
+});
+
+describe("normalizeSchemaErrors", () => {
+  test("keeps well-formed errors", () => {
+    expect(
+      normalizeSchemaErrors([
+        { path: "/id", keyword: "type", message: "expected string, got integer" },
+        { path: 1 },
+      ])
+    ).toEqual([{ path: "/id", keyword: "type", message: "expected string, got integer" }]);
+    expect(normalizeSchemaErrors(null)).toBeUndefined();
+  });
+});
@@ -0,0 +1,161 @@
+/**
+ * Per-endpoint JSON Schema validation. The receiver checks each HTTP body,
+ * as received, against `schema` and stores the verdict as `schemaValid` and
+ * the failures as `schemaErrors`. Requests that fail are stored either way;
+ * with `failureResponse` set they are answered with it instead of the mock
+ * response, so a producer under test sees the rejection.
+ */
+export const MAX_SCHEMA_LENGTH = 65_536;
+export const MAX_FAILURE_BODY_LENGTH = 10_000;
+export const DEFAULT_FAILURE_STATUS = 422;
+
+export interface SchemaFailureResponse {
+  status: number;
+  /** Sent as is; the receiver sends the validation errors as JSON when absent */
+  body?: string;
+}
+
+export interface SchemaValidation {
+  /** JSON Schema (draft 2020-12 assertions; draft-07 tuple `items` too) */
+  schema: Record<string, unknown> | boolean;
+  failureResponse?: SchemaFailureResponse;
+}
+
+/** One way a body broke the schema, as stored on the request. */
+export interface SchemaError {
+  /** JSON pointer to the failing value; empty for the whole body */
+  path: string;
+  keyword: string;
+  message: string;
+}
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+// The receiver compiles patterns with Rust's regex crate, which has no
+// lookaround or backreferences
+export const UNSUPPORTED_PATTERN = /\(\?<?[=!]|\\[1-9]|\\k</;
+
+/**
+ * Check the parts of a schema the receiver can't evaluate: references
+ * outside the document and patterns it can't compile. Returns the first
+ * problem, or null.
+ */
+function schemaProblem(schema: unknown, path: string): string | null {
+  if (Array.isArray(schema)) {
+    for (const [index, item] of schema.entries()) {
+      const problem = schemaProblem(item, `${path}/${index}`);
+      if (problem) return problem;
+    }
+    return null;
+  }
+  if (!schema || typeof schema !== "object") return null;
+  const node = schema as Record<string, unknown>;
+
+  if (node.$ref !== undefined && (typeof node.$ref !== "string" || !node.$ref.startsWith("#"))) {
+    return `${path}/$ref must point within the schema ("#/...")`;
+  }
+  const patterns = [
+    ...(typeof node.pattern === "string" ? [node.pattern] : []),
+    ...(node.patternProperties && typeof node.patternProperties === "object"
+      ? Object.keys(node.patternProperties)
+      : []),
+  ];
+  for (const pattern of patterns) {
+    const at = path || "/";
+    try {
+      new RegExp(pattern, "u");
+    } catch {
+      return `Invalid pattern "${pattern}" at ${at}`;
+    }
+    if (UNSUPPORTED_PATTERN.test(pattern)) {
+      return `Pattern "${pattern}" at ${at} uses lookaround or backreferences (unsupported)`;
+    }
+  }
+  for (const [key, value] of Object.entries(node)) {
+    const problem = schemaProblem(value, `${path}/${key}`);
+    if (problem) return problem;
+  }
+  return null;
+}
+
+/**
+ * Validate a `schemaValidation` setting. The failure response's status
+ * defaults to 422; null removes validation.
+ */
+export function parseSchemaValidation(value: unknown): ParseResult<SchemaValidation | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "schemaValidation must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+
+  const schema = input.schema;
+  const isObject = !!schema && typeof schema === "object" && !Array.isArray(schema);
+  if (typeof schema !== "boolean" && !isObject) {
+    return { valid: false, error: "schemaValidation.schema must be a JSON Schema object" };
+  }
+  if (JSON.stringify(schema).length > MAX_SCHEMA_LENGTH) {
+    return {
+      valid: false,
+      error: `schemaValidation.schema must be at most ${MAX_SCHEMA_LENGTH} characters as JSON`,
+    };
+  }
+  const problem = schemaProblem(schema, "");
+  if (problem) return { valid: false, error: `schemaValidation.schema: ${problem}` };
+  const result: SchemaValidation = { schema: schema as SchemaValidation["schema"] };
+
+  if (input.failureResponse !== undefined && input.failureResponse !== null) {
+    const failure = input.failureResponse;
+    if (typeof failure !== "object" || Array.isArray(failure)) {
+      return { valid: false, error: "schemaValidation.failureResponse must be an object" };
+    }
+    const { status = DEFAULT_FAILURE_STATUS, body } = failure as Record<string, unknown>;
+    if (typeof status !== "number" || !Number.isInteger(status) || status < 400 || status > 599) {
+      return {
+        valid: false,
+        error: "schemaValidation.failureResponse.status must be an integer from 400 to 599",
+      };
+    }
+    if (body !== undefined && (typeof body !== "string" || body.length > MAX_FAILURE_BODY_LENGTH)) {
+      return {
+        valid: false,
+        error: `schemaValidation.failureResponse.body can be at most ${MAX_FAILURE_BODY_LENGTH} chars`,
+      };
+    }
+    result.failureResponse = body === undefined ? { status } : { status, body };
+  }
+
+  return { valid: true, value: result };
+}
+
+/** The stored config, or null when it isn't one. */
+export function normalizeSchemaValidation(value: unknown): SchemaValidation | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  const config = value as Record<string, unknown>;
+  const schema = config.schema;
+  if (typeof schema !== "boolean" && (!schema || typeof schema !== "object")) return null;
+  const failure = config.failureResponse as Record<string, unknown> | undefined;
+  return {
+    schema: schema as SchemaValidation["schema"],
+    ...(failure && typeof failure.status === "number"
+      ? {
+          failureResponse: {
+            status: failure.status,
+            ...(typeof failure.body === "string" ? { body: failure.body } : {}),
+          },
+        }
+      : {}),
+  };
+}
+
+/** Stored validation errors, or undefined when there are none. */
+export function normalizeSchemaErrors(value: unknown): SchemaError[] | undefined {
+  if (!Array.isArray(value)) return undefined;
+  return value.flatMap((item) => {
+    if (!item || typeof item !== "object") return [];
+    const { path, keyword, message } = item as Record<string, unknown>;
+    return typeof path === "string" && typeof keyword === "string" && typeof message === "string"
+      ? [{ path, keyword, message }]
+      : [];
+  });
+}
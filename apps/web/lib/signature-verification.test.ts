@@ -0,0 +1,72 @@
+import { describe, expect, test } from "vitest";
+
+import {
+  parseSignatureVerification,
+  summarizeSignatureVerification,
+} from "./signature-verification";
+
+describe("parseSignatureVerification", () => {
+  test("expands presets and normalizes custom headers", () => {
+    expect(parseSignatureVerification({ provider: "stripe", secret: "whsec_1" })).toEqual({
+      valid: true,
+      value: {
+        provider: "stripe",
+        algorithm: "sha256",
+        header: "stripe-signature",
+        secret: "whsec_1",
+        reject: false,
+      },
+    });
+    expect(
+      parseSignatureVerification({
+        provider: "custom",
+        header: " X-Signature ",
+        algorithm: "sha512",
+        secret: "s",
+        reject: true,
+      })
+    ).toEqual({
+      valid: true,
+      value: {
+        provider: "custom",
+        algorithm: "sha512",
+        header: "x-signature",
+        secret: "s",
+        reject: true,
+      },
+    });
+    expect(parseSignatureVerification(null)).toEqual({ valid: true, value: null });
+  });
+
+  test("rejects unknown providers, missing secrets and overridden presets", () => {
+    expect(parseSignatureVerification({ provider: "paddle", secret: "s" }).valid).toBe(false);
+    expect(parseSignatureVerification({ provider: "github", secret: "" }).valid).toBe(false);
+    expect(
+      parseSignatureVerification({ provider: "github", secret: "s", header: "x-sig" }).valid
+    ).toBe(false);
+    expect(parseSignatureVerification({ provider: "custom", secret: "s" }).valid).toBe(false);
+    const md5 = { provider: "custom", secret: "s", header: "x", algorithm: "md5" };
+    expect(parseSignatureVerification(md5).valid).toBe(false);
+    expect(parseSignatureVerification("stripe").valid).toBe(false);
+  });
+});
+
+describe("summarizeSignatureVerification", () => {
+  test("drops the secret", () => {
+    expect(
+      summarizeSignatureVerification({
+        provider: "github",
+        algorithm: "sha256",
+        header: "x-hub-signature-256",
+        secret: "s",
+        reject: true,
+      })
+    ).toEqual({
+      provider: "github",
+      algorithm: "sha256",
+      header: "x-hub-signature-256",
+      reject: true,
+    });
+    expect(summarizeSignatureVerification(null)).toBeNull();
+  });
+});
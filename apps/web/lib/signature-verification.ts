@@ -0,0 +1,131 @@
+/**
+ * Per-endpoint HMAC signature verification. The receiver checks each
+ * request's signature against the secret and stores the verdict as
+ * `signatureValid`; with `reject` set, requests that fail get 401
+ * `invalid_signature` and aren't stored.
+ */
+export const SIGNATURE_PROVIDERS = ["stripe", "github", "shopify", "custom"] as const;
+export const SIGNATURE_ALGORITHMS = ["sha1", "sha256", "sha512"] as const;
+export const MAX_SIGNATURE_SECRET_LENGTH = 512;
+
+export type SignatureProvider = (typeof SIGNATURE_PROVIDERS)[number];
+export type SignatureAlgorithm = (typeof SIGNATURE_ALGORITHMS)[number];
+
+/** Stored form; presets are expanded so the receiver always gets a header and algorithm. */
+export interface SignatureVerification {
+  provider: SignatureProvider;
+  algorithm: SignatureAlgorithm;
+  header: string;
+  secret: string;
+  reject: boolean;
+}
+
+/** What the API returns: the secret is write-only. */
+export type SignatureVerificationSummary = Omit<SignatureVerification, "secret">;
+
+/** Header and algorithm each provider signs with. */
+const PRESETS: Record<
+  Exclude<SignatureProvider, "custom">,
+  { header: string; algorithm: SignatureAlgorithm }
+> = {
+  stripe: { header: "stripe-signature", algorithm: "sha256" },
+  github: { header: "x-hub-signature-256", algorithm: "sha256" },
+  shopify: { header: "x-shopify-hmac-sha256", algorithm: "sha256" },
+};
+
+const HEADER_NAME_REGEX = /^[a-z0-9_-]{1,100}$/;
+
+type ParseResult<T> = { valid: true; value: T } | { valid: false; error: string };
+
+function isProvider(value: unknown): value is SignatureProvider {
+  return SIGNATURE_PROVIDERS.includes(value as SignatureProvider);
+}
+
+function isAlgorithm(value: unknown): value is SignatureAlgorithm {
+  return SIGNATURE_ALGORITHMS.includes(value as SignatureAlgorithm);
+}
+
+/**
+ * Validate a `signatureVerification` setting. Presets fix the header and
+ * algorithm; `custom` needs a header and defaults to sha256. null removes
+ * verification.
+ */
+export function parseSignatureVerification(
+  value: unknown
+): ParseResult<SignatureVerification | null> {
+  if (value === null) return { valid: true, value: null };
+  if (typeof value !== "object" || Array.isArray(value)) {
+    return { valid: false, error: "signatureVerification must be an object or null" };
+  }
+  const input = value as Record<string, unknown>;
+
+  if (!isProvider(input.provider)) {
+    return {
+      valid: false,
+      error: `signatureVerification.provider must be one of ${SIGNATURE_PROVIDERS.join(", ")}`,
+    };
+  }
+  if (
+    typeof input.secret !== "string" ||
+    input.secret.length === 0 ||
+    input.secret.length > MAX_SIGNATURE_SECRET_LENGTH
+  ) {
+    return {
+      valid: false,
+      error: `signatureVerification.secret must be 1-${MAX_SIGNATURE_SECRET_LENGTH} characters`,
+    };
+  }
+  if (input.reject !== undefined && typeof input.reject !== "boolean") {
+    return { valid: false, error: "signatureVerification.reject must be a boolean" };
+  }
+  const reject = input.reject === true;
+
+  if (input.provider !== "custom") {
+    if (input.header !== undefined || input.algorithm !== undefined) {
+      return {
+        valid: false,
+        error: `signatureVerification.header and algorithm are fixed for ${input.provider}`,
+      };
+    }
+    return {
+      valid: true,
+      value: { provider: input.provider, ...PRESETS[input.provider], secret: input.secret, reject },
+    };
+  }
+
+  const header = typeof input.header === "string" ? input.header.trim().toLowerCase() : "";
+  if (!HEADER_NAME_REGEX.test(header)) {
+    return {
+      valid: false,
+      error: "signatureVerification.header must be a header name (letters, digits, - and _)",
+    };
+  }
+  const algorithm = input.algorithm ?? "sha256";
+  if (!isAlgorithm(algorithm)) {
+    return {
+      valid: false,
+      error: `signatureVerification.algorithm must be one of ${SIGNATURE_ALGORITHMS.join(", ")}`,
+    };
+  }
+
+  return {
+    valid: true,
+    value: { provider: "custom", algorithm, header, secret: input.secret, reject },
+  };
+}
+
+/** The stored config without its secret, for API responses. */
+export function summarizeSignatureVerification(
+  value: unknown
+): SignatureVerificationSummary | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  const config = value as Record<string, unknown>;
+  if (!isProvider(config.provider) || !isAlgorithm(config.algorithm)) return null;
+  if (typeof config.header !== "string") return null;
+  return {
+    provider: config.provider,
+    algorithm: config.algorithm,
+    header: config.header,
+    reject: config.reject === true,
+  };
+}
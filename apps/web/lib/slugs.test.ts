@@ -0,0 +1,31 @@
+import { describe, expect, test } from "vitest";
+
+import { checkCustomSlug, isReservedSlug } from "./slugs";
+
+describe("checkCustomSlug", () => {
+  test("accepts and lowercases vanity slugs", () => {
+    expect(checkCustomSlug(" My-Stripe-Dev ")).toEqual({ valid: true, slug: "my-stripe-dev" });
+    expect(checkCustomSlug("abc")).toEqual({ valid: true, slug: "abc" });
+  });
+
+  test("rejects malformed slugs", () => {
+    for (const slug of ["ab", "-abc", "abc-", "a--b", "under_score", "a".repeat(51), "sp ace"]) {
+      expect(checkCustomSlug(slug)).toMatchObject({ valid: false, reason: "invalid" });
+    }
+  });
+
+  test("rejects reserved names and prefixes", () => {
+    expect(checkCustomSlug("api")).toMatchObject({ valid: false, reason: "reserved" });
+    expect(checkCustomSlug("WHK-test")).toMatchObject({ valid: false, reason: "reserved" });
+    expect(checkCustomSlug("webhooks-ccdemo")).toMatchObject({ valid: false, reason: "reserved" });
+  });
+});
+
+describe("isReservedSlug", () => {
+  test("matches exact names and prefixes only", () => {
+    expect(isReservedSlug("Dashboard")).toBe(true);
+    expect(isReservedSlug("internal-billing")).toBe(true);
+    expect(isReservedSlug("apis")).toBe(false);
+    expect(isReservedSlug("my-admin")).toBe(false);
+  });
+});
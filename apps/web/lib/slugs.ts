@@ -0,0 +1,71 @@
+/**
+ * Rules for user-chosen endpoint slugs. Generated slugs are 10 random
+ * lowercase letters and digits; custom slugs follow CUSTOM_SLUG_REGEX and
+ * can't use a reserved name or prefix.
+ *
+ * The reserved lists are mirrored in apps/receiver-rs/src/handlers/webhook.rs,
+ * which answers captures for a reserved slug without an endpoint with
+ * `reserved_slug` instead of `not_found`.
+ */
+
+/** 3-50 lowercase letters, digits and hyphens, starting and ending with a letter or digit */
+const CUSTOM_SLUG_REGEX = /^[a-z0-9][a-z0-9-]{1,48}[a-z0-9]$/;
+
+/** Names used by the site and API routes, or easily mistaken for official endpoints */
+export const RESERVED_SLUGS: readonly string[] = [
+  "admin",
+  "api",
+  "app",
+  "auth",
+  "billing",
+  "claim",
+  "dashboard",
+  "docs",
+  "health",
+  "login",
+  "root",
+  "settings",
+  "status",
+  "support",
+  "webhooks",
+  "whk",
+  "www",
+];
+
+/** Namespaces kept for the service itself */
+export const RESERVED_SLUG_PREFIXES: readonly string[] = [
+  "admin-",
+  "internal-",
+  "system-",
+  "webhooks-cc",
+  "whk-",
+];
+
+export function isReservedSlug(slug: string): boolean {
+  const lower = slug.toLowerCase();
+  return (
+    RESERVED_SLUGS.includes(lower) ||
+    RESERVED_SLUG_PREFIXES.some((prefix) => lower.startsWith(prefix))
+  );
+}
+
+export type SlugCheck =
+  | { valid: true; slug: string }
+  | { valid: false; reason: "invalid" | "reserved"; message: string };
+
+/** Validate a user-chosen slug. Slugs are case-insensitive and stored lowercase. */
+export function checkCustomSlug(input: string): SlugCheck {
+  const slug = input.trim().toLowerCase();
+  if (!CUSTOM_SLUG_REGEX.test(slug) || slug.includes("--")) {
+    return {
+      valid: false,
+      reason: "invalid",
+      message:
+        "Slug must be 3-50 lowercase letters, digits and single hyphens, starting and ending with a letter or digit",
+    };
+  }
+  if (isReservedSlug(slug)) {
+    return { valid: false, reason: "reserved", message: `The slug "${slug}" is reserved` };
+  }
+  return { valid: true, slug };
+}
@@ -0,0 +1,68 @@
+import { describe, expect, test } from "vitest";
+
+import { compileStreamFilter, parseStreamFilter, type StreamFilter } from "./stream-filter";
+
+function parse(query: string): StreamFilter | null {
+  const result = parseStreamFilter(new URLSearchParams(query));
+  if ("error" in result) throw new Error(result.error);
+  return result.filter;
+}
+
+const request = {
+  method: "POST",
+  path: "/orders/123/items",
+  headers: { "X-Event-Type": "order.created", "x-signature": "abc" },
+};
+
+describe("parseStreamFilter", () => {
+  test("returns null when no filters are given", () => {
+    expect(parse("since=5&keepalive=10")).toBeNull();
+  });
+
+  test("parses comma-separated and repeated methods", () => {
+    expect(parse("method=post,put&method=Delete")).toEqual({
+      methods: ["POST", "PUT", "DELETE"],
+    });
+  });
+
+  test("parses header presence and value filters", () => {
+    expect(parse("header=X-Signature&header=x-event-type:order.created")).toEqual({
+      headers: [{ name: "x-signature" }, { name: "x-event-type", value: "order.created" }],
+    });
+  });
+
+  test("rejects invalid filters", () => {
+    expect(parseStreamFilter(new URLSearchParams("method=P OST"))).toHaveProperty("error");
+    expect(parseStreamFilter(new URLSearchParams("path=orders"))).toHaveProperty("error");
+    expect(parseStreamFilter(new URLSearchParams("header=bad name:x"))).toHaveProperty("error");
+    const tooMany = Array.from({ length: 11 }, (_, i) => `header=h${i}`).join("&");
+    expect(parseStreamFilter(new URLSearchParams(tooMany))).toHaveProperty("error");
+  });
+});
+
+describe("compileStreamFilter", () => {
+  test("matches everything without a filter", () => {
+    expect(compileStreamFilter(null)(request)).toBe(true);
+  });
+
+  test("requires every filter to match", () => {
+    const matches = compileStreamFilter(
+      parse("method=POST&path=/orders/**&header=x-event-type:order.created")
+    );
+    expect(matches(request)).toBe(true);
+    expect(matches({ ...request, method: "GET" })).toBe(false);
+    expect(matches({ ...request, path: "/refunds/1" })).toBe(false);
+    expect(matches({ ...request, headers: { "x-event-type": "order.updated" } })).toBe(false);
+  });
+
+  test("single-star path globs stay within one segment", () => {
+    const matches = compileStreamFilter(parse("path=/orders/*"));
+    expect(matches({ ...request, path: "/orders/123" })).toBe(true);
+    expect(matches(request)).toBe(false);
+  });
+
+  test("header presence matches any value", () => {
+    expect(compileStreamFilter(parse("header=X-SIGNATURE"))(request)).toBe(true);
+    expect(compileStreamFilter(parse("header=x-missing"))(request)).toBe(false);
+  });
+});
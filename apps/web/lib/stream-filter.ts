@@ -0,0 +1,114 @@
+/**
+ * Server-side filters for the /api/stream SSE endpoint, so a consumer tailing
+ * a busy endpoint only receives the events it cares about.
+ *
+ * Query parameters:
+ * - `method=POST,PUT` — any of the listed methods (case-insensitive; repeatable)
+ * - `path=/orders/**` — glob on the captured path (`*` one segment, `**` any depth)
+ * - `header=x-event-type:order.created` — header equals value; `header=x-signature`
+ *   requires presence only. Names are case-insensitive; repeat for several (all must match).
+ */
+
+export const MAX_STREAM_HEADER_FILTERS = 10;
+const MAX_FILTER_LENGTH = 512;
+const METHOD_PATTERN = /^[A-Z]{1,16}$/;
+const HEADER_NAME_PATTERN = /^[!#$%&'*+.^_`|~0-9a-z-]+$/;
+
+export interface StreamFilter {
+  methods?: string[];
+  path?: string;
+  headers?: { name: string; value?: string }[];
+}
+
+interface FilterableRequest {
+  method: string;
+  path: string;
+  headers: Record<string, string>;
+}
+
+function globToRegExp(pattern: string): RegExp {
+  let source = "^";
+  for (let index = 0; index < pattern.length; index++) {
+    const char = pattern[index];
+    if (char === "*") {
+      if (pattern[index + 1] === "*") {
+        source += ".*";
+        index++;
+      } else {
+        source += "[^/]*";
+      }
+      continue;
+    }
+    source += /[\\^$+?.()|[\]{}]/.test(char) ? `\\${char}` : char;
+  }
+  return new RegExp(`${source}$`);
+}
+
+/** Parse filter query parameters. Returns `null` filter when none were given. */
+export function parseStreamFilter(
+  params: URLSearchParams
+): { filter: StreamFilter | null } | { error: string } {
+  const filter: StreamFilter = {};
+
+  const methods = params
+    .getAll("method")
+    .flatMap((value) => value.split(","))
+    .map((value) => value.trim().toUpperCase())
+    .filter(Boolean);
+  if (methods.length > 0) {
+    if (!methods.every((method) => METHOD_PATTERN.test(method))) {
+      return { error: "Invalid method filter" };
+    }
+    filter.methods = [...new Set(methods)];
+  }
+
+  const path = params.get("path");
+  if (path !== null) {
+    if (!path.startsWith("/") || path.length > MAX_FILTER_LENGTH) {
+      return { error: "Invalid path filter: must start with / and be at most 512 characters" };
+    }
+    filter.path = path;
+  }
+
+  const headers = params.getAll("header");
+  if (headers.length > MAX_STREAM_HEADER_FILTERS) {
+    return { error: `Too many header filters (max ${MAX_STREAM_HEADER_FILTERS})` };
+  }
+  for (const raw of headers) {
+    const separator = raw.indexOf(":");
+    const name = (separator === -1 ? raw : raw.slice(0, separator)).trim().toLowerCase();
+    if (!HEADER_NAME_PATTERN.test(name) || raw.length > MAX_FILTER_LENGTH) {
+      return { error: "Invalid header filter: expected name or name:value" };
+    }
+    const value = separator === -1 ? undefined : raw.slice(separator + 1).trim();
+    (filter.headers ??= []).push(value === undefined ? { name } : { name, value });
+  }
+
+  return { filter: Object.keys(filter).length > 0 ? filter : null };
+}
+
+/** Build a predicate for `filter`. Compiles the path glob once per stream. */
+export function compileStreamFilter(
+  filter: StreamFilter | null
+): (request: FilterableRequest) => boolean {
+  if (!filter) return () => true;
+
+  const pathRegex = filter.path !== undefined ? globToRegExp(filter.path) : null;
+  const methods = filter.methods ? new Set(filter.methods) : null;
+
+  return (request) => {
+    if (methods && !methods.has(request.method.toUpperCase())) return false;
+    if (pathRegex && !pathRegex.test(request.path)) return false;
+    if (filter.headers) {
+      const lowered = new Map(
+        Object.entries(request.headers).map(([key, value]) => [key.toLowerCase(), value])
+      );
+      for (const { name, value } of filter.headers) {
+        const actual = lowered.get(name);
+        if (actual === undefined) return false;
+        if (value !== undefined && actual !== value) return false;
+      }
+    }
+    return true;
+  };
+}
@@ -0,0 +1,83 @@
+import { createAdminClient } from "./admin";
+import type { Database, Json } from "./database";
+
+const MAX_LIST_LIMIT = 200;
+
+type AccountEventRow = Database["public"]["Tables"]["account_events"]["Row"];
+export type AccountEventType = AccountEventRow["type"];
+
+export const ACCOUNT_EVENT_TYPES: readonly AccountEventType[] = [
+  "endpoint_created",
+  "quota_warning",
+  "forwarding_failure",
+  "team_member_joined",
+];
+
+/**
+ * A notable event on the user's account or one of their teams. Written by
+ * database triggers (see migration 00035); `data` depends on the type.
+ */
+export interface AccountEventRecord {
+  id: string;
+  type: AccountEventType;
+  endpointId?: string;
+  teamId?: string;
+  data: Record<string, Json | undefined>;
+  createdAt: number;
+}
+
+function normalizeEvent(row: AccountEventRow): AccountEventRecord {
+  return {
+    id: row.id,
+    type: row.type,
+    endpointId: row.endpoint_id ?? undefined,
+    teamId: row.team_id ?? undefined,
+    data: row.data && typeof row.data === "object" && !Array.isArray(row.data) ? row.data : {},
+    createdAt: Date.parse(row.created_at),
+  };
+}
+
+/**
+ * Newest events first: the user's own, plus those of every team they are a
+ * member of. `since` (unix ms) only returns events created at or after it.
+ */
+export async function listAccountEvents(input: {
+  userId: string;
+  since?: number;
+  limit?: number;
+  type?: AccountEventType;
+}): Promise<AccountEventRecord[]> {
+  const admin = createAdminClient();
+
+  // eslint-disable-next-line @typescript-eslint/no-explicit-any
+  const { data: memberships, error: memberError } = await (admin as any)
+    .from("team_members")
+    .select("team_id")
+    .eq("user_id", input.userId);
+  if (memberError) throw memberError;
+  const teamIds = ((memberships ?? []) as { team_id: string }[]).map((m) => m.team_id);
+
+  const query = admin
+    .from("account_events")
+    .select("id, user_id, team_id, endpoint_id, type, data, created_at")
+    .or(
+      teamIds.length > 0
+        ? `user_id.eq.${input.userId},team_id.in.(${teamIds.join(",")})`
+        : `user_id.eq.${input.userId}`
+    );
+  if (input.since !== undefined) {
+    query.gte("created_at", new Date(input.since).toISOString());
+  }
+  if (input.type !== undefined) {
+    query.eq("type", input.type);
+  }
+
+  const limit = Math.min(Math.max(1, Math.floor(input.limit ?? 50)), MAX_LIST_LIMIT);
+  const { data, error } = await query
+    .order("created_at", { ascending: false })
+    .limit(limit)
+    .returns<AccountEventRow[]>();
+  if (error) throw error;
+
+  return (data ?? []).map(normalizeEvent);
+}
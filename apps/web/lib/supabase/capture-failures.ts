@@ -0,0 +1,91 @@
+import { createAdminClient } from "./admin";
+
+/**
+ * A window of requests the receiver accepted but could not store. Written by
+ * the receiver through record_capture_failures(); owners acknowledge them
+ * from the dashboard or API.
+ */
+export interface CaptureFailureRecord {
+  id: string;
+  failedCount: number;
+  windowStart: number;
+  windowEnd: number;
+  acknowledgedAt: number | null;
+  createdAt: number;
+}
+
+/** Unacknowledged failures for one endpoint, summed across windows. */
+export interface CaptureFailureSummary {
+  count: number;
+  since: number;
+  until: number;
+}
+
+export async function listCaptureFailures(endpointId: string): Promise<CaptureFailureRecord[]> {
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("capture_failures")
+    .select("id, failed_count, window_start, window_end, acknowledged_at, created_at")
+    .eq("endpoint_id", endpointId)
+    .order("window_end", { ascending: false })
+    .limit(100);
+
+  if (error) throw error;
+
+  return (data ?? []).map((row) => ({
+    id: row.id,
+    failedCount: row.failed_count,
+    windowStart: Date.parse(row.window_start),
+    windowEnd: Date.parse(row.window_end),
+    acknowledgedAt: row.acknowledged_at ? Date.parse(row.acknowledged_at) : null,
+    createdAt: Date.parse(row.created_at),
+  }));
+}
+
+/** Mark every open failure window as seen. Returns how many were acknowledged. */
+export async function acknowledgeCaptureFailures(endpointId: string): Promise<number> {
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("capture_failures")
+    .update({ acknowledged_at: new Date().toISOString() })
+    .eq("endpoint_id", endpointId)
+    .is("acknowledged_at", null)
+    .select("id");
+
+  if (error) throw error;
+  return (data ?? []).length;
+}
+
+/** Open failure summaries keyed by endpoint ID, for the owner's endpoint list. */
+export async function getCaptureFailureSummaries(
+  endpointIds: string[]
+): Promise<Map<string, CaptureFailureSummary>> {
+  if (endpointIds.length === 0) return new Map();
+
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("capture_failures")
+    .select("endpoint_id, failed_count, window_start, window_end")
+    .in("endpoint_id", endpointIds)
+    .is("acknowledged_at", null);
+
+  if (error) throw error;
+
+  const summaries = new Map<string, CaptureFailureSummary>();
+  for (const row of data ?? []) {
+    const since = Date.parse(row.window_start);
+    const until = Date.parse(row.window_end);
+    const existing = summaries.get(row.endpoint_id);
+    summaries.set(
+      row.endpoint_id,
+      existing
+        ? {
+            count: existing.count + row.failed_count,
+            since: Math.min(existing.since, since),
+            until: Math.max(existing.until, until),
+          }
+        : { count: row.failed_count, since, until }
+    );
+  }
+  return summaries;
+}
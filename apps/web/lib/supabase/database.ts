@@ -3,6 +3,36 @@ export type Json = string | number | boolean | null | { [key: string]: Json | un
 export interface Database {
   public: {
     Tables: {
+      account_events: {
+        Row: {
+          id: string;
+          user_id: string | null;
+          team_id: string | null;
+          endpoint_id: string | null;
+          type: "endpoint_created" | "quota_warning" | "forwarding_failure" | "team_member_joined";
+          data: Json;
+          created_at: string;
+        };
+        Insert: {
+          id?: string;
+          user_id?: string | null;
+          team_id?: string | null;
+          endpoint_id?: string | null;
+          type: "endpoint_created" | "quota_warning" | "forwarding_failure" | "team_member_joined";
+          data?: Json;
+          created_at?: string;
+        };
+        Update: {
+          id?: string;
+          user_id?: string | null;
+          team_id?: string | null;
+          endpoint_id?: string | null;
+          type?: "endpoint_created" | "quota_warning" | "forwarding_failure" | "team_member_joined";
+          data?: Json;
+          created_at?: string;
+        };
+        Relationships: [];
+      };
       api_keys: {
         Row: {
           id: string;
@@ -36,6 +66,33 @@ export interface Database {
         };
         Relationships: [];
       };
+      api_usage_daily: {
+        Row: {
+          user_id: string;
+          day: string;
+          api_calls: number;
+          stream_seconds: number;
+          replays: number;
+          exports: number;
+        };
+        Insert: {
+          user_id: string;
+          day: string;
+          api_calls?: number;
+          stream_seconds?: number;
+          replays?: number;
+          exports?: number;
+        };
+        Update: {
+          user_id?: string;
+          day?: string;
+          api_calls?: number;
+          stream_seconds?: number;
+          replays?: number;
+          exports?: number;
+        };
+        Relationships: [];
+      };
       blog_posts: {
         Row: {
           id: string;
@@ -105,6 +162,36 @@ export interface Database {
         };
         Relationships: [];
       };
+      capture_failures: {
+        Row: {
+          id: string;
+          endpoint_id: string;
+          failed_count: number;
+          window_start: string;
+          window_end: string;
+          acknowledged_at: string | null;
+          created_at: string;
+        };
+        Insert: {
+          id?: string;
+          endpoint_id: string;
+          failed_count: number;
+          window_start: string;
+          window_end: string;
+          acknowledged_at?: string | null;
+          created_at?: string;
+        };
+        Update: {
+          id?: string;
+          endpoint_id?: string;
+          failed_count?: number;
+          window_start?: string;
+          window_end?: string;
+          acknowledged_at?: string | null;
+          created_at?: string;
+        };
+        Relationships: [];
+      };
       device_codes: {
         Row: {
           id: string;
@@ -135,6 +222,147 @@ export interface Database {
         };
         Relationships: [];
       };
+      endpoint_dry_run_stats: {
+        Row: {
+          endpoint_id: string;
+          requests: number;
+          bytes: number;
+          mocked: number;
+          first_request_at: string;
+          last_request_at: string;
+        };
+        Insert: {
+          endpoint_id: string;
+          requests?: number;
+          bytes?: number;
+          mocked?: number;
+          first_request_at?: string;
+          last_request_at?: string;
+        };
+        Update: {
+          endpoint_id?: string;
+          requests?: number;
+          bytes?: number;
+          mocked?: number;
+          first_request_at?: string;
+          last_request_at?: string;
+        };
+        Relationships: [];
+      };
+      endpoint_network_stats: {
+        Row: {
+          endpoint_id: string;
+          rule: string;
+          matched: number;
+          last_matched_at: string;
+        };
+        Insert: {
+          endpoint_id: string;
+          rule: string;
+          matched?: number;
+          last_matched_at?: string;
+        };
+        Update: {
+          endpoint_id?: string;
+          rule?: string;
+          matched?: number;
+          last_matched_at?: string;
+        };
+        Relationships: [];
+      };
+      endpoint_sampling_stats: {
+        Row: {
+          endpoint_id: string;
+          requests: number;
+          bytes: number;
+          sampled_out: number;
+          sampled_out_bytes: number;
+          first_request_at: string;
+          last_request_at: string;
+        };
+        Insert: {
+          endpoint_id: string;
+          requests?: number;
+          bytes?: number;
+          sampled_out?: number;
+          sampled_out_bytes?: number;
+          first_request_at?: string;
+          last_request_at?: string;
+        };
+        Update: {
+          endpoint_id?: string;
+          requests?: number;
+          bytes?: number;
+          sampled_out?: number;
+          sampled_out_bytes?: number;
+          first_request_at?: string;
+          last_request_at?: string;
+        };
+        Relationships: [];
+      };
+      endpoint_share_tokens: {
+        Row: {
+          id: string;
+          endpoint_id: string;
+          created_by: string;
+          token_hash: string;
+          token_prefix: string;
+          expires_at: string | null;
+          last_used_at: string | null;
+          created_at: string;
+        };
+        Insert: {
+          id?: string;
+          endpoint_id: string;
+          created_by: string;
+          token_hash: string;
+          token_prefix: string;
+          expires_at?: string | null;
+          last_used_at?: string | null;
+          created_at?: string;
+        };
+        Update: {
+          id?: string;
+          endpoint_id?: string;
+          created_by?: string;
+          token_hash?: string;
+          token_prefix?: string;
+          expires_at?: string | null;
+          last_used_at?: string | null;
+          created_at?: string;
+        };
+        Relationships: [];
+      };
+      endpoint_versions: {
+        Row: {
+          id: string;
+          endpoint_id: string;
+          version: number;
+          config: Json;
+          source: "initial" | "edit" | "rollback";
+          rolled_back_to: number | null;
+          created_at: string;
+        };
+        Insert: {
+          id?: string;
+          endpoint_id: string;
+          version: number;
+          config: Json;
+          source: "initial" | "edit" | "rollback";
+          rolled_back_to?: number | null;
+          created_at?: string;
+        };
+        Update: {
+          id?: string;
+          endpoint_id?: string;
+          version?: number;
+          config?: Json;
+          source?: "initial" | "edit" | "rollback";
+          rolled_back_to?: number | null;
+          created_at?: string;
+        };
+        Relationships: [];
+      };
       endpoints: {
         Row: {
           id: string;
@@ -143,8 +371,40 @@ export interface Database {
           name: string | null;
           mock_response: Json | null;
           notification_url: string | null;
+          function_sink: Json | null;
+          body_transforms: Json | null;
+          info_headers: boolean;
+          record_responses: boolean;
+          canonical_json: boolean;
+          dry_run: boolean;
+          query_overrides: boolean;
+          auto_handshake: boolean;
+          forward_url: string | null;
+          priority_rule: Json | null;
+          encrypted_headers: string[] | null;
+          client_ca: string | null;
+          signature_verification: Json | null;
+          jwt_verification: Json | null;
+          capture_auth: Json | null;
+          cors: Json | null;
+          capture_filter: Json | null;
+          sampling: Json | null;
+          chaos: Json | null;
+          rate_limit: Json | null;
+          schema_validation: Json | null;
+          redaction: Json | null;
+          mock_canary: Json | null;
+          custom_domain: string | null;
+          max_body_size: number | null;
+          network_policy: Json | null;
+          demo: Json | null;
+          demo_sequence: number;
+          paused_at: string | null;
+          paused_response: Json | null;
           is_ephemeral: boolean;
           expires_at: string | null;
+          auto_extend_idle_ms: number | null;
+          auto_extend_until: string | null;
           request_count: number;
           created_at: string;
         };
@@ -155,8 +415,40 @@ export interface Database {
           name?: string | null;
           mock_response?: Json | null;
           notification_url?: string | null;
+          function_sink?: Json | null;
+          body_transforms?: Json | null;
+          info_headers?: boolean;
+          record_responses?: boolean;
+          canonical_json?: boolean;
+          dry_run?: boolean;
+          query_overrides?: boolean;
+          auto_handshake?: boolean;
+          forward_url?: string | null;
+          priority_rule?: Json | null;
+          encrypted_headers?: string[] | null;
+          client_ca?: string | null;
+          signature_verification?: Json | null;
+          jwt_verification?: Json | null;
+          capture_auth?: Json | null;
+          cors?: Json | null;
+          capture_filter?: Json | null;
+          sampling?: Json | null;
+          chaos?: Json | null;
+          rate_limit?: Json | null;
+          schema_validation?: Json | null;
+          redaction?: Json | null;
+          mock_canary?: Json | null;
+          custom_domain?: string | null;
+          max_body_size?: number | null;
+          network_policy?: Json | null;
+          demo?: Json | null;
+          demo_sequence?: number;
+          paused_at?: string | null;
+          paused_response?: Json | null;
           is_ephemeral?: boolean;
           expires_at?: string | null;
+          auto_extend_idle_ms?: number | null;
+          auto_extend_until?: string | null;
           request_count?: number;
           created_at?: string;
         };
@@ -167,13 +459,81 @@ export interface Database {
           name?: string | null;
           mock_response?: Json | null;
           notification_url?: string | null;
+          function_sink?: Json | null;
+          body_transforms?: Json | null;
+          info_headers?: boolean;
+          record_responses?: boolean;
+          canonical_json?: boolean;
+          dry_run?: boolean;
+          query_overrides?: boolean;
+          auto_handshake?: boolean;
+          forward_url?: string | null;
+          priority_rule?: Json | null;
+          encrypted_headers?: string[] | null;
+          client_ca?: string | null;
+          signature_verification?: Json | null;
+          jwt_verification?: Json | null;
+          capture_auth?: Json | null;
+          cors?: Json | null;
+          capture_filter?: Json | null;
+          sampling?: Json | null;
+          chaos?: Json | null;
+          rate_limit?: Json | null;
+          schema_validation?: Json | null;
+          redaction?: Json | null;
+          mock_canary?: Json | null;
+          custom_domain?: string | null;
+          max_body_size?: number | null;
+          network_policy?: Json | null;
+          demo?: Json | null;
+          demo_sequence?: number;
+          paused_at?: string | null;
+          paused_response?: Json | null;
           is_ephemeral?: boolean;
           expires_at?: string | null;
+          auto_extend_idle_ms?: number | null;
+          auto_extend_until?: string | null;
           request_count?: number;
           created_at?: string;
         };
         Relationships: [];
       };
+      rejected_requests: {
+        Row: {
+          id: string;
+          endpoint_id: string;
+          reason: "denied" | "blocked";
+          method: string;
+          path: string;
+          ip: string;
+          country: string | null;
+          user_agent: string | null;
+          received_at: string;
+        };
+        Insert: {
+          id?: string;
+          endpoint_id: string;
+          reason: "denied" | "blocked";
+          method: string;
+          path: string;
+          ip: string;
+          country?: string | null;
+          user_agent?: string | null;
+          received_at: string;
+        };
+        Update: {
+          id?: string;
+          endpoint_id?: string;
+          reason?: "denied" | "blocked";
+          method?: string;
+          path?: string;
+          ip?: string;
+          country?: string | null;
+          user_agent?: string | null;
+          received_at?: string;
+        };
+        Relationships: [];
+      };
       requests: {
         Row: {
           id: string;
@@ -189,6 +549,38 @@ export interface Database {
           ip: string;
           size: number;
           received_at: string;
+          body_hash: string | null;
+          duplicate_of: string | null;
+          mock_variant: string | null;
+          mock_version: "stable" | "canary" | null;
+          chaos_fault: "error" | "hang" | "reset" | null;
+          response_override: Json | null;
+          country: string | null;
+          asn: number | null;
+          as_org: string | null;
+          tls: Json | null;
+          parts: Json | null;
+          body_ref: string | null;
+          response: Json | null;
+          frame: Json | null;
+          cloud_event: Json | null;
+          http_version: string | null;
+          priority: boolean;
+          client_cert: Json | null;
+          trailers: Json | null;
+          fingerprint: string | null;
+          provider: string | null;
+          event_type: string | null;
+          content_class: string | null;
+          signature_valid: boolean | null;
+          jwt_valid: boolean | null;
+          jwt_claims: Json | null;
+          schema_valid: boolean | null;
+          schema_errors: Json | null;
+          sizes: Json | null;
+          redactions: Json | null;
+          note: string | null;
+          tags: string[];
         };
         Insert: {
           id?: string;
@@ -204,6 +596,38 @@ export interface Database {
           ip: string;
           size?: number;
           received_at?: string;
+          body_hash?: string | null;
+          duplicate_of?: string | null;
+          mock_variant?: string | null;
+          mock_version?: "stable" | "canary" | null;
+          chaos_fault?: "error" | "hang" | "reset" | null;
+          response_override?: Json | null;
+          country?: string | null;
+          asn?: number | null;
+          as_org?: string | null;
+          tls?: Json | null;
+          parts?: Json | null;
+          body_ref?: string | null;
+          response?: Json | null;
+          frame?: Json | null;
+          cloud_event?: Json | null;
+          http_version?: string | null;
+          priority?: boolean;
+          client_cert?: Json | null;
+          trailers?: Json | null;
+          fingerprint?: string | null;
+          provider?: string | null;
+          event_type?: string | null;
+          content_class?: string | null;
+          signature_valid?: boolean | null;
+          jwt_valid?: boolean | null;
+          jwt_claims?: Json | null;
+          schema_valid?: boolean | null;
+          schema_errors?: Json | null;
+          sizes?: Json | null;
+          redactions?: Json | null;
+          note?: string | null;
+          tags?: string[];
         };
         Update: {
           id?: string;
@@ -219,6 +643,38 @@ export interface Database {
           ip?: string;
           size?: number;
           received_at?: string;
+          body_hash?: string | null;
+          duplicate_of?: string | null;
+          mock_variant?: string | null;
+          mock_version?: "stable" | "canary" | null;
+          chaos_fault?: "error" | "hang" | "reset" | null;
+          response_override?: Json | null;
+          country?: string | null;
+          asn?: number | null;
+          as_org?: string | null;
+          tls?: Json | null;
+          parts?: Json | null;
+          body_ref?: string | null;
+          response?: Json | null;
+          frame?: Json | null;
+          cloud_event?: Json | null;
+          http_version?: string | null;
+          priority?: boolean;
+          client_cert?: Json | null;
+          trailers?: Json | null;
+          fingerprint?: string | null;
+          provider?: string | null;
+          event_type?: string | null;
+          content_class?: string | null;
+          signature_valid?: boolean | null;
+          jwt_valid?: boolean | null;
+          jwt_claims?: Json | null;
+          schema_valid?: boolean | null;
+          schema_errors?: Json | null;
+          sizes?: Json | null;
+          redactions?: Json | null;
+          note?: string | null;
+          tags?: string[];
         };
         Relationships: [];
       };
@@ -288,6 +744,7 @@ export interface Database {
           p_limit?: number | null;
           p_offset?: number | null;
           p_order?: string | null;
+          p_content_class?: string | null;
         };
         Returns: Array<{
           id: string;
@@ -301,6 +758,7 @@ export interface Database {
           ip: string;
           size: number;
           received_at: number;
+          content_class: string | null;
         }>;
       };
       search_requests_count: {
@@ -312,9 +770,68 @@ export interface Database {
           p_q?: string | null;
           p_from_ms?: number | null;
           p_to_ms?: number | null;
+          p_content_class?: string | null;
         };
         Returns: number;
       };
+      rollback_endpoint_config: {
+        Args: {
+          p_endpoint_id: string;
+          p_version: number;
+        };
+        Returns: boolean;
+      };
+      promote_mock_canary: {
+        Args: {
+          p_endpoint_id: string;
+        };
+        Returns: boolean;
+      };
+      mock_canary_stats: {
+        Args: {
+          p_endpoint_id: string;
+        };
+        Returns: Json | null;
+      };
+      function_sink_latency: {
+        Args: {
+          p_endpoint_id: string;
+          p_since: string;
+        };
+        Returns: Array<{
+          target: string;
+          deliveries: number;
+          failed: number;
+          retried: number;
+          relay_p50: number;
+          relay_p90: number;
+          relay_p99: number;
+          destination_p50: number;
+          destination_p90: number;
+          destination_p99: number;
+          total_p50: number;
+          total_p90: number;
+          total_p99: number;
+        }>;
+      };
+      endpoint_usage_report: {
+        Args: {
+          p_endpoint_id: string;
+          p_from: string;
+          p_to: string;
+        };
+        Returns: Json;
+      };
+      record_api_usage: {
+        Args: {
+          p_user_id: string;
+          p_api_calls?: number;
+          p_stream_seconds?: number;
+          p_replays?: number;
+          p_exports?: number;
+        };
+        Returns: undefined;
+      };
     };
     Enums: Record<string, never>;
     CompositeTypes: Record<string, never>;
@@ -0,0 +1,37 @@
+import { createAdminClient } from "./admin";
+
+/**
+ * Aggregate counters for an endpoint in dry-run mode, since dry run was last
+ * turned on. Counted by capture_webhook() in place of storing each request.
+ */
+export interface DryRunStatsRecord {
+  requests: number;
+  bytes: number;
+  /** Requests answered with the endpoint's mock response */
+  mocked: number;
+  /** `null` until a request arrives */
+  firstRequestAt: number | null;
+  lastRequestAt: number | null;
+}
+
+export async function getDryRunStats(endpointId: string): Promise<DryRunStatsRecord> {
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("endpoint_dry_run_stats")
+    .select("requests, bytes, mocked, first_request_at, last_request_at")
+    .eq("endpoint_id", endpointId)
+    .maybeSingle();
+
+  if (error) throw error;
+  if (!data) {
+    return { requests: 0, bytes: 0, mocked: 0, firstRequestAt: null, lastRequestAt: null };
+  }
+
+  return {
+    requests: Number(data.requests),
+    bytes: Number(data.bytes),
+    mocked: Number(data.mocked),
+    firstRequestAt: Date.parse(data.first_request_at),
+    lastRequestAt: Date.parse(data.last_request_at),
+  };
+}
@@ -0,0 +1,85 @@
+import { createAdminClient } from "./admin";
+import { normalizeEndpointConfig, type EndpointConfig, type EndpointConfigRow } from "./endpoints";
+
+/**
+ * A saved endpoint configuration. Versions are written by the
+ * endpoint_config_versions trigger whenever the mock response, notification
+ * URL, function sink, body transforms or info headers change.
+ */
+export interface EndpointVersionRecord {
+  version: number;
+  /** `initial` is the configuration before the first recorded change */
+  source: "initial" | "edit" | "rollback";
+  /** For rollbacks, the version that was restored */
+  rolledBackTo: number | null;
+  createdAt: number;
+  config: EndpointConfig;
+}
+
+export type VersionRow = {
+  version: number;
+  source: EndpointVersionRecord["source"];
+  rolled_back_to: number | null;
+  created_at: string;
+  config: unknown;
+};
+
+const VERSION_COLUMNS = "version, source, rolled_back_to, created_at, config";
+
+export function normalizeVersion(row: VersionRow): EndpointVersionRecord {
+  return {
+    version: row.version,
+    source: row.source,
+    rolledBackTo: row.rolled_back_to,
+    createdAt: Date.parse(row.created_at),
+    config: normalizeEndpointConfig(row.config as EndpointConfigRow),
+  };
+}
+
+/** Saved versions for an endpoint, newest first (at most the last 50). */
+export async function listEndpointVersions(endpointId: string): Promise<EndpointVersionRecord[]> {
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("endpoint_versions")
+    .select(VERSION_COLUMNS)
+    .eq("endpoint_id", endpointId)
+    .order("version", { ascending: false });
+
+  if (error) throw error;
+  return (data ?? []).map(normalizeVersion);
+}
+
+export async function getEndpointVersion(
+  endpointId: string,
+  version: number
+): Promise<EndpointVersionRecord | null> {
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("endpoint_versions")
+    .select(VERSION_COLUMNS)
+    .eq("endpoint_id", endpointId)
+    .eq("version", version)
+    .maybeSingle();
+
+  if (error) throw error;
+  return data ? normalizeVersion(data) : null;
+}
+
+/**
+ * Restore a saved version. The restore is recorded as a new version, and
+ * receivers are told to drop cached endpoint state. Returns false when the
+ * version doesn't exist.
+ */
+export async function rollbackEndpointConfig(
+  endpointId: string,
+  version: number
+): Promise<boolean> {
+  const admin = createAdminClient();
+  const { data, error } = await admin.rpc("rollback_endpoint_config", {
+    p_endpoint_id: endpointId,
+    p_version: version,
+  });
+
+  if (error) throw error;
+  return data === true;
+}
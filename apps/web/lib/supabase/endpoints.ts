@@ -1,5 +1,32 @@
 import { customAlphabet } from "nanoid";
+import { isReservedSlug } from "@/lib/slugs";
 import { createAdminClient } from "./admin";
+import type { AutoExtendInput } from "@/lib/auto-extend";
+import {
+  summarizeCaptureAuth,
+  type CaptureAuth,
+  type CaptureAuthSummary,
+} from "@/lib/capture-auth";
+import type { CaptureFilter } from "@/lib/capture-filter";
+import type { ChaosConfig } from "@/lib/chaos";
+import type { RateLimitConfig } from "@/lib/endpoint-rate-limit";
+import type { CorsConfig } from "@/lib/cors";
+import type { SamplingConfig } from "@/lib/sampling";
+import type { DemoConfig } from "@/lib/demo-mode";
+import {
+  summarizeJwtVerification,
+  type JwtVerification,
+  type JwtVerificationSummary,
+} from "@/lib/jwt-verification";
+import type { NetworkPolicy } from "@/lib/network-policy";
+import type { PriorityRule } from "@/lib/priority";
+import { normalizeRedaction, type Redaction } from "@/lib/redaction";
+import { normalizeSchemaValidation, type SchemaValidation } from "@/lib/schema-validation";
+import {
+  summarizeSignatureVerification,
+  type SignatureVerification,
+  type SignatureVerificationSummary,
+} from "@/lib/signature-verification";
 import type { Database, Json } from "./database";
 
 const DEFAULT_EPHEMERAL_TTL_MS = 12 * 60 * 60 * 1000;
@@ -18,8 +45,39 @@ type SelectedEndpointRow = Pick<
   | "name"
   | "mock_response"
   | "notification_url"
+  | "function_sink"
+  | "body_transforms"
+  | "info_headers"
+  | "record_responses"
+  | "canonical_json"
+  | "dry_run"
+  | "query_overrides"
+  | "auto_handshake"
+  | "forward_url"
+  | "priority_rule"
+  | "encrypted_headers"
+  | "client_ca"
+  | "signature_verification"
+  | "jwt_verification"
+  | "capture_auth"
+  | "cors"
+  | "capture_filter"
+  | "sampling"
+  | "chaos"
+  | "rate_limit"
+  | "schema_validation"
+  | "redaction"
+  | "mock_canary"
+  | "custom_domain"
+  | "max_body_size"
+  | "network_policy"
+  | "demo"
+  | "paused_at"
+  | "paused_response"
   | "is_ephemeral"
   | "expires_at"
+  | "auto_extend_idle_ms"
+  | "auto_extend_until"
   | "created_at"
 >;
 type OwnedEndpointRow = Pick<EndpointRow, "id" | "slug" | "user_id">;
@@ -32,22 +90,238 @@ export interface EndpointRecord {
   mockResponse?: {
     status: number;
     body: string;
+    /** Binary body sent in place of `body` */
+    bodyBase64?: string;
     headers: Record<string, string>;
     delay?: number;
+    delayJitter?: number;
+    headerPolicy?: MockHeaderPolicy;
+    correlation?: MockCorrelation;
+    variants?: MockVariant[];
+    schedule?: MockWindow[];
+    rules?: MockRule[];
+    sequence?: MockSequence;
+    /** Rhai script the receiver runs to build the reply */
+    script?: string;
+    /** Reply with the request itself as JSON in place of the base body */
+    echo?: boolean;
+    stream?: MockStream;
+    grpc?: GrpcMockReply;
   };
   notificationUrl: string | null;
+  functionSink: FunctionSink | null;
+  bodyTransforms: BodyTransform[] | null;
+  infoHeaders: boolean;
+  /** Whether the reply sent for each capture is stored with it */
+  recordResponses: boolean;
+  /** Whether duplicate detection compares JSON bodies in canonical form */
+  canonicalJson: boolean;
+  /** Whether requests are answered and counted without being stored */
+  dryRun: boolean;
+  /** Whether `__status` and `__delay` query parameters override the reply */
+  queryOverrides: boolean;
+  /** Whether Slack, Zoom, Dropbox and Microsoft Graph verification requests are answered */
+  autoHandshake: boolean;
+  /** Backend requests are relayed to, answering with its reply */
+  forwardUrl: string | null;
+  /** Header (and optional values) marking captures high priority */
+  priorityRule: PriorityRule | null;
+  /** Lowercase names of headers the receiver encrypts before storing */
+  encryptedHeaders: string[];
+  /** PEM bundle of CAs a client certificate must chain to; captures without one are refused */
+  clientCa: string | null;
+  /** How the receiver checks the sender's HMAC signature; the secret is never returned */
+  signatureVerification: SignatureVerificationSummary | null;
+  /** How the receiver validates the sender's JWT; a shared secret is never returned */
+  jwtVerification: JwtVerificationSummary | null;
+  /** Credentials senders must present before anything is captured; secrets are never returned */
+  captureAuth: CaptureAuthSummary | null;
+  /** Origins, methods and headers browsers may use; any origin without credentials when null */
+  cors: CorsConfig | null;
+  /** Which requests are stored; the rest only get the mock response. Everything when null */
+  captureFilter: CaptureFilter | null;
+  /** How much of the traffic is stored; everything when null */
+  sampling: SamplingConfig | null;
+  /** Share of requests answered with an injected fault; off when null */
+  chaos: ChaosConfig | null;
+  /** Requests per window answered before the rest get a 429; off when null */
+  rateLimit: RateLimitConfig | null;
+  /** JSON Schema the receiver checks each body against, and the reply for failures */
+  schemaValidation: SchemaValidation | null;
+  /** What the receiver replaces with [REDACTED] before a capture is stored */
+  redaction: Redaction | null;
+  /** Changed mock response served to a share of senders before it replaces `mockResponse` */
+  mockCanary: MockCanary | null;
+  /** Pro: the endpoint's own hostname, served by the receiver with an ACME certificate */
+  customDomain: string | null;
+  /** Bodies over this many bytes are captured cut short; the plan's limit when null */
+  maxBodySize: number | null;
+  /** Countries and networks the endpoint accepts or tags requests from */
+  networkPolicy: NetworkPolicy | null;
+  /** Synthetic captures this ephemeral endpoint generates, if any */
+  demo: DemoConfig | null;
+  /** When capture was paused; requests are answered but not stored meanwhile */
+  pausedAt: number | null;
+  /** Reply sent while paused; the receiver's 503 `paused` error when null */
+  pausedResponse: PausedResponse | null;
   isEphemeral?: boolean;
   expiresAt?: number;
+  /** Sliding expiry: `expiresAt` follows the last capture by `idleMs`, up to `until` */
+  autoExtend?: { idleMs: number; until: number };
   createdAt: number;
 }
 
+/**
+ * Mock canary: `response` answers the senders whose bucket (a hash of their IP
+ * and `startedAt`) falls under `percent`, everyone else gets the stable mock.
+ */
+export interface MockCanary {
+  response: NonNullable<EndpointRecord["mockResponse"]>;
+  /** Share of senders answered with the canary, 1-99 */
+  percent: number;
+  startedAt: number;
+}
+
+export interface FunctionSink {
+  provider: "aws_lambda";
+  arn: string;
+}
+
+export interface PausedResponse {
+  status: number;
+  body: string;
+}
+
+export interface MockHeaderPolicy {
+  allow?: string[];
+  block?: string[];
+}
+
+export interface MockCorrelation {
+  key: string;
+  responses: Record<
+    string,
+    {
+      status: number;
+      body?: string;
+      headers?: Record<string, string>;
+      delay?: number;
+      delayJitter?: number;
+    }
+  >;
+}
+
+/** Weighted alternative reply; `weight` is the percentage of captures that get it. */
+export interface MockVariant {
+  name: string;
+  weight: number;
+  status: number;
+  body?: string;
+  headers?: Record<string, string>;
+  delay?: number;
+  delayJitter?: number;
+}
+
+/**
+ * Reply for a recurring time window: `start`/`end` are `HH:MM` in `timezone`
+ * (default UTC), and `days` the weekdays the window starts on.
+ */
+export interface MockWindow {
+  name: string;
+  start: string;
+  end: string;
+  timezone?: string;
+  days?: string[];
+  status: number;
+  body?: string;
+  headers?: Record<string, string>;
+  delay?: number;
+  delayJitter?: number;
+}
+
+/**
+ * Conditional reply: the first rule whose `match` conditions all hold answers
+ * the request. Header, query and body values are patterns where `*` matches
+ * any run of characters.
+ */
+export interface MockRule {
+  match: {
+    methods?: string[];
+    path?: string;
+    headers?: Record<string, string>;
+    query?: Record<string, string>;
+    body?: Record<string, string>;
+  };
+  status: number;
+  body?: string;
+  headers?: Record<string, string>;
+  delay?: number;
+  delayJitter?: number;
+}
+
+/**
+ * Replies for successive requests: each step answers `count` requests (1 by
+ * default), then the rest of the mock response answers unless `repeat` starts
+ * over. With `key`, each value of that request field counts separately.
+ */
+export interface MockSequence {
+  steps: Array<{
+    count?: number;
+    status: number;
+    body?: string;
+    headers?: Record<string, string>;
+    delay?: number;
+    delayJitter?: number;
+  }>;
+  key?: string;
+  repeat?: boolean;
+  /** Seconds a position may sit idle before it starts over */
+  resetAfter?: number;
+}
+
+/**
+ * Events sent in place of the base reply's body, each after its delay: as
+ * `text/event-stream` messages (`sse`) or as raw chunks (`chunked`).
+ */
+export interface MockStream {
+  format?: "sse" | "chunked";
+  events: Array<{
+    data?: string;
+    event?: string;
+    id?: string;
+    /** Milliseconds to wait before the event */
+    delay?: number;
+  }>;
+}
+
+/** Reply to calls captured by the receiver's gRPC listener. */
+export interface GrpcMockReply {
+  /** gRPC status code (0-16) */
+  code: number;
+  message?: string;
+  /** Base64 of the serialized response message, sent when `code` is 0 */
+  body?: string;
+}
+
+export type BodyTransform =
+  | { type: "map_field"; from: string; to: string }
+  | { type: "rename_header"; from: string; to: string }
+  | { type: "strip_fields"; paths: string[] }
+  | { type: "flatten_array"; path?: string };
+
 interface CreateEndpointInput {
   userId?: string;
+  /** A validated custom slug (see lib/slugs.ts); generated when omitted */
+  slug?: string;
   name?: string;
   isEphemeral?: boolean;
   expiresAt?: number;
   mockResponse?: Record<string, unknown>;
   notificationUrl?: string;
+  /** Only for ephemeral endpoints */
+  demo?: DemoConfig;
+  /** Only for ephemeral endpoints */
+  autoExtend?: AutoExtendInput;
 }
 
 interface UpdateEndpointInput {
@@ -56,6 +330,35 @@ interface UpdateEndpointInput {
   name?: string;
   mockResponse?: Record<string, unknown> | null;
   notificationUrl?: string | null;
+  functionSink?: FunctionSink | null;
+  bodyTransforms?: BodyTransform[] | null;
+  infoHeaders?: boolean;
+  recordResponses?: boolean;
+  canonicalJson?: boolean;
+  dryRun?: boolean;
+  queryOverrides?: boolean;
+  autoHandshake?: boolean;
+  forwardUrl?: string | null;
+  priorityRule?: PriorityRule | null;
+  encryptedHeaders?: string[] | null;
+  clientCa?: string | null;
+  signatureVerification?: SignatureVerification | null;
+  jwtVerification?: JwtVerification | null;
+  captureAuth?: CaptureAuth | null;
+  cors?: CorsConfig | null;
+  captureFilter?: CaptureFilter | null;
+  sampling?: SamplingConfig | null;
+  chaos?: ChaosConfig | null;
+  rateLimit?: RateLimitConfig | null;
+  schemaValidation?: SchemaValidation | null;
+  redaction?: Redaction | null;
+  /** Start or replace a canary (`startedAt` is set to now), or `null` to roll it back */
+  mockCanary?: { response: Record<string, unknown>; percent: number } | null;
+  customDomain?: string | null;
+  maxBodySize?: number | null;
+  networkPolicy?: NetworkPolicy | null;
+  /** Pause with an optional reply, or `false` to resume */
+  paused?: { response: PausedResponse | null } | false;
 }
 
 function webhookUrl(slug: string): string | undefined {
@@ -80,54 +383,320 @@ function normalizeMockHeaders(value: unknown): Record<string, string> {
   ) as Record<string, string>;
 }
 
-function normalizeEndpoint(row: SelectedEndpointRow): EndpointRecord {
-  const mockResponse =
-    row.mock_response && typeof row.mock_response === "object" && !Array.isArray(row.mock_response)
-      ? row.mock_response
-      : null;
+function normalizeHeaderNames(value: unknown): string[] | undefined {
+  if (!Array.isArray(value)) return undefined;
+  const names = value.filter((item): item is string => typeof item === "string");
+  return names.length > 0 ? names : undefined;
+}
+
+function normalizeHeaderPolicy(value: unknown): MockHeaderPolicy | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  const policy = value as Record<string, unknown>;
+  const allow = normalizeHeaderNames(policy.allow);
+  const block = normalizeHeaderNames(policy.block);
+  if (!allow && !block) return undefined;
+  return { ...(allow ? { allow } : {}), ...(block ? { block } : {}) };
+}
+
+function normalizeCorrelation(value: unknown): MockCorrelation | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  const correlation = value as Record<string, unknown>;
+  const responses = correlation.responses;
+  if (
+    typeof correlation.key !== "string" ||
+    !responses ||
+    typeof responses !== "object" ||
+    Array.isArray(responses)
+  ) {
+    return undefined;
+  }
+  return { key: correlation.key, responses: responses as MockCorrelation["responses"] };
+}
+
+function normalizeVariants(value: unknown): MockVariant[] | undefined {
+  if (!Array.isArray(value)) return undefined;
+  const variants = value.filter(
+    (item): item is MockVariant =>
+      !!item &&
+      typeof item === "object" &&
+      typeof item.name === "string" &&
+      typeof item.weight === "number" &&
+      typeof item.status === "number"
+  );
+  return variants.length > 0 ? variants : undefined;
+}
+
+function normalizeSchedule(value: unknown): MockWindow[] | undefined {
+  if (!Array.isArray(value)) return undefined;
+  const schedule = value.filter(
+    (item): item is MockWindow =>
+      !!item &&
+      typeof item === "object" &&
+      typeof item.name === "string" &&
+      typeof item.start === "string" &&
+      typeof item.end === "string" &&
+      typeof item.status === "number"
+  );
+  return schedule.length > 0 ? schedule : undefined;
+}
+
+function normalizeRules(value: unknown): MockRule[] | undefined {
+  if (!Array.isArray(value)) return undefined;
+  const rules = value.filter(
+    (item): item is MockRule =>
+      !!item &&
+      typeof item === "object" &&
+      !!item.match &&
+      typeof item.match === "object" &&
+      !Array.isArray(item.match) &&
+      typeof item.status === "number"
+  );
+  return rules.length > 0 ? rules : undefined;
+}
+
+function normalizeSequence(value: unknown): MockSequence | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  const sequence = value as Record<string, unknown>;
+  if (!Array.isArray(sequence.steps)) return undefined;
+  const steps = sequence.steps.filter(
+    (item): item is MockSequence["steps"][number] =>
+      !!item && typeof item === "object" && typeof item.status === "number"
+  );
+  if (steps.length === 0) return undefined;
+  return {
+    steps,
+    ...(typeof sequence.key === "string" ? { key: sequence.key } : {}),
+    ...(typeof sequence.repeat === "boolean" ? { repeat: sequence.repeat } : {}),
+    ...(typeof sequence.resetAfter === "number" ? { resetAfter: sequence.resetAfter } : {}),
+  };
+}
+
+function normalizeStream(value: unknown): MockStream | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  const stream = value as Record<string, unknown>;
+  if (!Array.isArray(stream.events)) return undefined;
+  const events = stream.events.filter(
+    (item): item is MockStream["events"][number] => !!item && typeof item === "object"
+  );
+  if (events.length === 0) return undefined;
+  return {
+    ...(stream.format === "sse" || stream.format === "chunked" ? { format: stream.format } : {}),
+    events,
+  };
+}
+
+function normalizeGrpcReply(value: unknown): GrpcMockReply | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  const reply = value as Record<string, unknown>;
+  if (typeof reply.code !== "number") return undefined;
+  return {
+    code: reply.code,
+    ...(typeof reply.message === "string" ? { message: reply.message } : {}),
+    ...(typeof reply.body === "string" ? { body: reply.body } : {}),
+  };
+}
+
+function normalizeFunctionSink(value: Json | null): FunctionSink | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  if (value.provider !== "aws_lambda" || typeof value.arn !== "string") return null;
+  return { provider: "aws_lambda", arn: value.arn };
+}
+
+function normalizePausedResponse(value: Json | null): PausedResponse | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  if (typeof value.status !== "number") return null;
+  return { status: value.status, body: typeof value.body === "string" ? value.body : "" };
+}
+
+function normalizeBodyTransforms(value: Json | null): BodyTransform[] | null {
+  if (!Array.isArray(value) || value.length === 0) return null;
+  return value as unknown as BodyTransform[];
+}
+
+export type EndpointConfigRow = Pick<
+  EndpointRow,
+  "mock_response" | "notification_url" | "function_sink" | "body_transforms" | "info_headers"
+>;
+
+/** The versioned part of an endpoint (see endpoint-versions.ts). */
+export type EndpointConfig = Pick<
+  EndpointRecord,
+  "mockResponse" | "notificationUrl" | "functionSink" | "bodyTransforms" | "infoHeaders"
+>;
+
+function normalizeMockResponse(value: Json | null): EndpointRecord["mockResponse"] {
+  const mockResponse = value && typeof value === "object" && !Array.isArray(value) ? value : null;
+  const headerPolicy = normalizeHeaderPolicy(mockResponse?.headerPolicy);
+  const correlation = normalizeCorrelation(mockResponse?.correlation);
+  const variants = normalizeVariants(mockResponse?.variants);
+  const schedule = normalizeSchedule(mockResponse?.schedule);
+  const rules = normalizeRules(mockResponse?.rules);
+  const sequence = normalizeSequence(mockResponse?.sequence);
+  const stream = normalizeStream(mockResponse?.stream);
+  const grpc = normalizeGrpcReply(mockResponse?.grpc);
 
+  return mockResponse && typeof mockResponse.status === "number"
+    ? {
+        status: mockResponse.status,
+        body: typeof mockResponse.body === "string" ? mockResponse.body : "",
+        ...(typeof mockResponse.bodyBase64 === "string" && mockResponse.bodyBase64 !== ""
+          ? { bodyBase64: mockResponse.bodyBase64 }
+          : {}),
+        headers: normalizeMockHeaders(mockResponse.headers),
+        ...(typeof mockResponse.delay === "number" &&
+        Number.isInteger(mockResponse.delay) &&
+        mockResponse.delay > 0 &&
+        mockResponse.delay <= 30000
+          ? { delay: mockResponse.delay }
+          : {}),
+        ...(typeof mockResponse.delayJitter === "number" &&
+        Number.isInteger(mockResponse.delayJitter) &&
+        mockResponse.delayJitter > 0 &&
+        mockResponse.delayJitter <= 30000
+          ? { delayJitter: mockResponse.delayJitter }
+          : {}),
+        ...(headerPolicy ? { headerPolicy } : {}),
+        ...(correlation ? { correlation } : {}),
+        ...(variants ? { variants } : {}),
+        ...(schedule ? { schedule } : {}),
+        ...(rules ? { rules } : {}),
+        ...(sequence ? { sequence } : {}),
+        ...(typeof mockResponse.script === "string" && mockResponse.script !== ""
+          ? { script: mockResponse.script }
+          : {}),
+        ...(mockResponse.echo === true ? { echo: true } : {}),
+        ...(stream ? { stream } : {}),
+        ...(grpc ? { grpc } : {}),
+      }
+    : undefined;
+}
+
+export function normalizeEndpointConfig(row: EndpointConfigRow): EndpointConfig {
+  return {
+    mockResponse: normalizeMockResponse(row.mock_response),
+    notificationUrl: row.notification_url ?? null,
+    functionSink: normalizeFunctionSink(row.function_sink),
+    bodyTransforms: normalizeBodyTransforms(row.body_transforms),
+    infoHeaders: row.info_headers ?? false,
+  };
+}
+
+function normalizeMockCanary(value: Json | null): MockCanary | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  const response = normalizeMockResponse(value.response ?? null);
+  const startedAt = typeof value.startedAt === "string" ? Date.parse(value.startedAt) : NaN;
+  if (!response || typeof value.percent !== "number" || Number.isNaN(startedAt)) return null;
+  return { response, percent: value.percent, startedAt };
+}
+
+function normalizeNetworkPolicy(value: Json | null): NetworkPolicy | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  return value as unknown as NetworkPolicy;
+}
+
+function normalizeCors(value: Json | null): CorsConfig | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  if (!Array.isArray(value.origins)) return null;
+  return value as unknown as CorsConfig;
+}
+
+function normalizeCaptureFilter(value: Json | null): CaptureFilter | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  if (!Array.isArray(value.include) && !Array.isArray(value.exclude)) return null;
+  return value as unknown as CaptureFilter;
+}
+
+function normalizeSampling(value: Json | null): SamplingConfig | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  return value as unknown as SamplingConfig;
+}
+
+function normalizeChaos(value: Json | null): ChaosConfig | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  if (typeof value.percent !== "number") return null;
+  return value as unknown as ChaosConfig;
+}
+
+function normalizeRateLimit(value: Json | null): RateLimitConfig | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  if (typeof value.limit !== "number" || typeof value.window !== "number") return null;
+  return value as unknown as RateLimitConfig;
+}
+
+function normalizePriorityRule(value: Json | null): PriorityRule | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  if (typeof value.header !== "string") return null;
+  return value as unknown as PriorityRule;
+}
+
+function normalizeDemo(value: Json | null): DemoConfig | null {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return null;
+  return value as unknown as DemoConfig;
+}
+
+function normalizeEndpoint(row: SelectedEndpointRow): EndpointRecord {
   return {
     id: row.id,
     slug: row.slug,
     name: row.name ?? undefined,
     url: webhookUrl(row.slug),
-    mockResponse:
-      mockResponse && typeof mockResponse.status === "number"
-        ? {
-            status: mockResponse.status,
-            body: typeof mockResponse.body === "string" ? mockResponse.body : "",
-            headers: normalizeMockHeaders(mockResponse.headers),
-            ...(typeof mockResponse.delay === "number" &&
-            Number.isInteger(mockResponse.delay) &&
-            mockResponse.delay > 0 &&
-            mockResponse.delay <= 30000
-              ? { delay: mockResponse.delay }
-              : {}),
-          }
-        : undefined,
-    notificationUrl: row.notification_url ?? null,
+    ...normalizeEndpointConfig(row),
+    recordResponses: row.record_responses ?? false,
+    canonicalJson: row.canonical_json ?? false,
+    dryRun: row.dry_run ?? false,
+    queryOverrides: row.query_overrides ?? false,
+    autoHandshake: row.auto_handshake ?? false,
+    forwardUrl: row.forward_url ?? null,
+    priorityRule: normalizePriorityRule(row.priority_rule),
+    encryptedHeaders: row.encrypted_headers ?? [],
+    clientCa: row.client_ca ?? null,
+    signatureVerification: summarizeSignatureVerification(row.signature_verification),
+    jwtVerification: summarizeJwtVerification(row.jwt_verification),
+    captureAuth: summarizeCaptureAuth(row.capture_auth),
+    cors: normalizeCors(row.cors),
+    captureFilter: normalizeCaptureFilter(row.capture_filter),
+    sampling: normalizeSampling(row.sampling),
+    chaos: normalizeChaos(row.chaos),
+    rateLimit: normalizeRateLimit(row.rate_limit),
+    schemaValidation: normalizeSchemaValidation(row.schema_validation),
+    redaction: normalizeRedaction(row.redaction),
+    mockCanary: normalizeMockCanary(row.mock_canary),
+    customDomain: row.custom_domain ?? null,
+    maxBodySize: row.max_body_size ?? null,
+    networkPolicy: normalizeNetworkPolicy(row.network_policy),
+    demo: normalizeDemo(row.demo),
+    pausedAt: parseMillis(row.paused_at) ?? null,
+    pausedResponse: row.paused_at ? normalizePausedResponse(row.paused_response) : null,
     isEphemeral: row.is_ephemeral || undefined,
     expiresAt: parseMillis(row.expires_at),
+    autoExtend:
+      row.auto_extend_idle_ms !== null && row.auto_extend_until !== null
+        ? { idleMs: row.auto_extend_idle_ms, until: parseMillis(row.auto_extend_until) ?? 0 }
+        : undefined,
     createdAt: parseMillis(row.created_at) ?? Date.now(),
   };
 }
 
-async function generateUniqueSlug(): Promise<string> {
+/** Whether any endpoint, owned by anyone, uses `slug`. */
+export async function isSlugInUse(slug: string): Promise<boolean> {
   const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("endpoints")
+    .select("id")
+    .eq("slug", slug.toLowerCase())
+    .maybeSingle();
+
+  if (error) {
+    throw error;
+  }
+
+  return data !== null;
+}
 
+async function generateUniqueSlug(): Promise<string> {
   for (let attempt = 0; attempt < MAX_SLUG_ATTEMPTS; attempt += 1) {
     const slug = nanoidSlug();
-    const { data, error } = await admin
-      .from("endpoints")
-      .select("id")
-      .eq("slug", slug.toLowerCase())
-      .maybeSingle();
-
-    if (error) {
-      throw error;
-    }
-
-    if (!data) {
+    if (!isReservedSlug(slug) && !(await isSlugInUse(slug))) {
       return slug;
     }
   }
@@ -176,7 +745,7 @@ export async function listEndpointsForUser(userId: string): Promise<EndpointReco
   const { data, error } = await admin
     .from("endpoints")
     .select(
-      "id, user_id, slug, name, mock_response, notification_url, is_ephemeral, expires_at, created_at"
+      "id, user_id, slug, name, mock_response, notification_url, function_sink, body_transforms, info_headers, record_responses, canonical_json, dry_run, query_overrides, auto_handshake, forward_url, priority_rule, encrypted_headers, client_ca, signature_verification, jwt_verification, capture_auth, cors, capture_filter, sampling, chaos, rate_limit, schema_validation, redaction, mock_canary, custom_domain, max_body_size, network_policy, demo, paused_at, paused_response, is_ephemeral, expires_at, auto_extend_idle_ms, auto_extend_until, created_at"
     )
     .eq("user_id", userId)
     .order("created_at", { ascending: false })
@@ -197,7 +766,7 @@ export async function getEndpointBySlugForUser(
   const { data, error } = await admin
     .from("endpoints")
     .select(
-      "id, user_id, slug, name, mock_response, notification_url, is_ephemeral, expires_at, created_at"
+      "id, user_id, slug, name, mock_response, notification_url, function_sink, body_transforms, info_headers, record_responses, canonical_json, dry_run, query_overrides, auto_handshake, forward_url, priority_rule, encrypted_headers, client_ca, signature_verification, jwt_verification, capture_auth, cors, capture_filter, sampling, chaos, rate_limit, schema_validation, redaction, mock_canary, custom_domain, max_body_size, network_policy, demo, paused_at, paused_response, is_ephemeral, expires_at, auto_extend_idle_ms, auto_extend_until, created_at"
     )
     .eq("user_id", userId)
     .eq("slug", slug.toLowerCase())
@@ -213,14 +782,17 @@ export async function getEndpointBySlugForUser(
 
 export async function createEndpointForUser({
   userId,
+  slug: customSlug,
   name,
   isEphemeral = false,
   expiresAt,
   mockResponse,
   notificationUrl,
+  demo,
+  autoExtend,
 }: CreateEndpointInput): Promise<EndpointRecord> {
   const admin = createAdminClient();
-  const slug = await generateUniqueSlug();
+  const slug = customSlug ?? (await generateUniqueSlug());
   const ephemeral = isEphemeral || expiresAt !== undefined;
 
   if (ephemeral) {
@@ -234,6 +806,13 @@ export async function createEndpointForUser({
         ? new Date(Date.now() + DEFAULT_EPHEMERAL_TTL_MS).toISOString()
         : null;
 
+  // The hard limit has to leave room past the initial expiry
+  const extend = ephemeral ? autoExtend : undefined;
+  const autoExtendUntil = extend ? new Date(Date.now() + extend.maxMs).toISOString() : null;
+  if (autoExtendUntil !== null && expiresAtIso !== null && expiresAtIso > autoExtendUntil) {
+    throw new Error("auto_extend_limit_before_expiry");
+  }
+
   const insert: EndpointInsert = {
     user_id: userId ?? null,
     slug,
@@ -242,18 +821,25 @@ export async function createEndpointForUser({
     notification_url: notificationUrl ?? null,
     is_ephemeral: ephemeral,
     expires_at: expiresAtIso,
+    demo: ephemeral && demo ? (demo as unknown as Json) : null,
+    auto_extend_idle_ms: extend?.idleMs ?? null,
+    auto_extend_until: autoExtendUntil,
   };
 
   const { data, error } = await admin
     .from("endpoints")
     .insert(insert)
     .select(
-      "id, user_id, slug, name, mock_response, notification_url, is_ephemeral, expires_at, created_at"
+      "id, user_id, slug, name, mock_response, notification_url, function_sink, body_transforms, info_headers, record_responses, canonical_json, dry_run, query_overrides, auto_handshake, forward_url, priority_rule, encrypted_headers, client_ca, signature_verification, jwt_verification, capture_auth, cors, capture_filter, sampling, chaos, rate_limit, schema_validation, redaction, mock_canary, custom_domain, max_body_size, network_policy, demo, paused_at, paused_response, is_ephemeral, expires_at, auto_extend_idle_ms, auto_extend_until, created_at"
     )
     .returns<SelectedEndpointRow>()
     .single();
 
   if (error) {
+    // Generated slugs are checked first; a custom one may already be in use.
+    if (customSlug !== undefined && error.code === "23505") {
+      throw new Error("slug_taken");
+    }
     throw error;
   }
 
@@ -301,13 +887,15 @@ export async function claimGuestEndpoint(
       user_id: userId,
       is_ephemeral: false,
       expires_at: null,
+      auto_extend_idle_ms: null,
+      auto_extend_until: null,
     })
     .eq("slug", slug.toLowerCase())
     .is("user_id", null)
     .eq("is_ephemeral", true)
     .gt("expires_at", nowIso)
     .select(
-      "id, user_id, slug, name, mock_response, notification_url, is_ephemeral, expires_at, created_at"
+      "id, user_id, slug, name, mock_response, notification_url, function_sink, body_transforms, info_headers, record_responses, canonical_json, dry_run, query_overrides, auto_handshake, forward_url, priority_rule, encrypted_headers, client_ca, signature_verification, jwt_verification, capture_auth, cors, capture_filter, sampling, chaos, rate_limit, schema_validation, redaction, mock_canary, custom_domain, max_body_size, network_policy, demo, paused_at, paused_response, is_ephemeral, expires_at, auto_extend_idle_ms, auto_extend_until, created_at"
     )
     .returns<SelectedEndpointRow>()
     .maybeSingle();
@@ -322,6 +910,33 @@ export async function updateEndpointBySlugForUser({
   name,
   mockResponse,
   notificationUrl,
+  functionSink,
+  bodyTransforms,
+  infoHeaders,
+  recordResponses,
+  canonicalJson,
+  dryRun,
+  queryOverrides,
+  autoHandshake,
+  forwardUrl,
+  priorityRule,
+  encryptedHeaders,
+  clientCa,
+  signatureVerification,
+  jwtVerification,
+  captureAuth,
+  cors,
+  captureFilter,
+  sampling,
+  chaos,
+  rateLimit,
+  schemaValidation,
+  redaction,
+  mockCanary,
+  customDomain,
+  maxBodySize,
+  networkPolicy,
+  paused,
 }: UpdateEndpointInput): Promise<EndpointRecord | null> {
   const admin = createAdminClient();
 
@@ -335,6 +950,91 @@ export async function updateEndpointBySlugForUser({
   if (notificationUrl !== undefined) {
     updates.notification_url = notificationUrl;
   }
+  if (functionSink !== undefined) {
+    updates.function_sink = functionSink as Json | null;
+  }
+  if (bodyTransforms !== undefined) {
+    updates.body_transforms =
+      bodyTransforms && bodyTransforms.length > 0 ? (bodyTransforms as unknown as Json) : null;
+  }
+  if (infoHeaders !== undefined) {
+    updates.info_headers = infoHeaders;
+  }
+  if (recordResponses !== undefined) {
+    updates.record_responses = recordResponses;
+  }
+  if (canonicalJson !== undefined) {
+    updates.canonical_json = canonicalJson;
+  }
+  if (dryRun !== undefined) {
+    updates.dry_run = dryRun;
+  }
+  if (queryOverrides !== undefined) {
+    updates.query_overrides = queryOverrides;
+  }
+  if (autoHandshake !== undefined) {
+    updates.auto_handshake = autoHandshake;
+  }
+  if (forwardUrl !== undefined) {
+    updates.forward_url = forwardUrl;
+  }
+  if (priorityRule !== undefined) {
+    updates.priority_rule = priorityRule as unknown as Json | null;
+  }
+  if (encryptedHeaders !== undefined) {
+    updates.encrypted_headers = encryptedHeaders;
+  }
+  if (clientCa !== undefined) {
+    updates.client_ca = clientCa;
+  }
+  if (signatureVerification !== undefined) {
+    updates.signature_verification = signatureVerification as unknown as Json | null;
+  }
+  if (jwtVerification !== undefined) {
+    updates.jwt_verification = jwtVerification as unknown as Json | null;
+  }
+  if (captureAuth !== undefined) {
+    updates.capture_auth = captureAuth as unknown as Json | null;
+  }
+  if (cors !== undefined) {
+    updates.cors = cors as unknown as Json | null;
+  }
+  if (captureFilter !== undefined) {
+    updates.capture_filter = captureFilter as unknown as Json | null;
+  }
+  if (sampling !== undefined) {
+    updates.sampling = sampling as unknown as Json | null;
+  }
+  if (chaos !== undefined) {
+    updates.chaos = chaos as unknown as Json | null;
+  }
+  if (rateLimit !== undefined) {
+    updates.rate_limit = rateLimit as unknown as Json | null;
+  }
+  if (schemaValidation !== undefined) {
+    updates.schema_validation = schemaValidation as unknown as Json | null;
+  }
+  if (redaction !== undefined) {
+    updates.redaction = redaction as unknown as Json | null;
+  }
+  if (mockCanary !== undefined) {
+    updates.mock_canary = mockCanary
+      ? ({ ...mockCanary, startedAt: new Date().toISOString() } as unknown as Json)
+      : null;
+  }
+  if (customDomain !== undefined) {
+    updates.custom_domain = customDomain;
+  }
+  if (maxBodySize !== undefined) {
+    updates.max_body_size = maxBodySize;
+  }
+  if (networkPolicy !== undefined) {
+    updates.network_policy = networkPolicy as unknown as Json | null;
+  }
+  if (paused !== undefined) {
+    updates.paused_at = paused ? new Date().toISOString() : null;
+    updates.paused_response = paused ? (paused.response as unknown as Json | null) : null;
+  }
 
   const { data, error } = await admin
     .from("endpoints")
@@ -342,12 +1042,15 @@ export async function updateEndpointBySlugForUser({
     .eq("user_id", userId)
     .eq("slug", slug.toLowerCase())
     .select(
-      "id, user_id, slug, name, mock_response, notification_url, is_ephemeral, expires_at, created_at"
+      "id, user_id, slug, name, mock_response, notification_url, function_sink, body_transforms, info_headers, record_responses, canonical_json, dry_run, query_overrides, auto_handshake, forward_url, priority_rule, encrypted_headers, client_ca, signature_verification, jwt_verification, capture_auth, cors, capture_filter, sampling, chaos, rate_limit, schema_validation, redaction, mock_canary, custom_domain, max_body_size, network_policy, demo, paused_at, paused_response, is_ephemeral, expires_at, auto_extend_idle_ms, auto_extend_until, created_at"
     )
     .returns<SelectedEndpointRow>()
     .maybeSingle();
 
   if (error) {
+    if (customDomain && error.code === "23505") {
+      throw new Error("domain_taken");
+    }
     throw error;
   }
 
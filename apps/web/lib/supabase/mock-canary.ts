@@ -0,0 +1,38 @@
+import { createAdminClient } from "./admin";
+
+/** Requests and distinct senders per mock version since the canary started. */
+export interface MockCanaryStats {
+  requests: { stable: number; canary: number };
+  senders: { stable: number; canary: number };
+}
+
+/**
+ * Counts for the endpoint's running canary, from requests.mock_version.
+ * `null` when no canary is running.
+ */
+export async function getMockCanaryStats(endpointId: string): Promise<MockCanaryStats | null> {
+  const admin = createAdminClient();
+  const { data, error } = await admin.rpc("mock_canary_stats", { p_endpoint_id: endpointId });
+
+  if (error) throw error;
+  if (!data || typeof data !== "object" || Array.isArray(data)) return null;
+  const stats = data as Record<string, Record<string, unknown> | undefined>;
+  const count = (group: string, version: string) => Number(stats[group]?.[version] ?? 0);
+  return {
+    requests: { stable: count("requests", "stable"), canary: count("requests", "canary") },
+    senders: { stable: count("senders", "stable"), canary: count("senders", "canary") },
+  };
+}
+
+/**
+ * Make the canary's response the endpoint's mock response and end the canary.
+ * The change is recorded in the endpoint's version history like any edit.
+ * Returns false when no canary is running.
+ */
+export async function promoteMockCanary(endpointId: string): Promise<boolean> {
+  const admin = createAdminClient();
+  const { data, error } = await admin.rpc("promote_mock_canary", { p_endpoint_id: endpointId });
+
+  if (error) throw error;
+  return data === true;
+}
@@ -0,0 +1,34 @@
+import { createAdminClient } from "./admin";
+
+/**
+ * How many requests matched one rule of an endpoint's network policy since
+ * the policy last changed. `rule` is `blocked` for requests rejected by the
+ * allow rule, `denied` for the deny rule, or `tag:<name>` for a tag rule.
+ * Counted by capture_webhook().
+ * Requests refused for missing capture credentials count under
+ * `unauthorized` (since the credentials last changed).
+ * Requests the capture filter left out count under `filtered` (since the
+ * filter last changed).
+ */
+export interface NetworkMatchRecord {
+  rule: string;
+  matched: number;
+  lastMatchedAt: number;
+}
+
+export async function listNetworkStats(endpointId: string): Promise<NetworkMatchRecord[]> {
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("endpoint_network_stats")
+    .select("rule, matched, last_matched_at")
+    .eq("endpoint_id", endpointId)
+    .order("rule");
+
+  if (error) throw error;
+
+  return (data ?? []).map((row) => ({
+    rule: row.rule,
+    matched: Number(row.matched),
+    lastMatchedAt: Date.parse(row.last_matched_at),
+  }));
+}
@@ -0,0 +1,50 @@
+import { createAdminClient } from "./admin";
+
+/**
+ * A request the endpoint's network policy rejected, kept when the policy sets
+ * `captureRejected`. `reason` is `denied` for the deny rule and `blocked` for
+ * requests outside the allow rule. Only the request line and sender are kept,
+ * for 7 days and at most 1000 per hour.
+ */
+export interface RejectedRequestRecord {
+  id: string;
+  reason: "denied" | "blocked";
+  method: string;
+  path: string;
+  ip: string;
+  country: string | null;
+  userAgent: string | null;
+  receivedAt: number;
+}
+
+export const MAX_REJECTED_LIMIT = 1000;
+
+/** Newest first; `since` (ms) keeps only requests received after it. */
+export async function listRejectedRequests(
+  endpointId: string,
+  limit: number,
+  since?: number
+): Promise<RejectedRequestRecord[]> {
+  const admin = createAdminClient();
+  let query = admin
+    .from("rejected_requests")
+    .select("id, reason, method, path, ip, country, user_agent, received_at")
+    .eq("endpoint_id", endpointId);
+  if (since !== undefined) {
+    query = query.gt("received_at", new Date(since).toISOString());
+  }
+  const { data, error } = await query.order("received_at", { ascending: false }).limit(limit);
+
+  if (error) throw error;
+
+  return (data ?? []).map((row) => ({
+    id: row.id,
+    reason: row.reason,
+    method: row.method,
+    path: row.path,
+    ip: row.ip,
+    country: row.country,
+    userAgent: row.user_agent,
+    receivedAt: Date.parse(row.received_at),
+  }));
+}
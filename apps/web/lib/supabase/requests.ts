@@ -1,3 +1,7 @@
+import { decryptHeaders } from "@/lib/header-crypt";
+import { maskedFields, type MaskReason } from "@/lib/masked-fields";
+import { normalizeRedactions } from "@/lib/redaction";
+import { normalizeSchemaErrors, type SchemaError } from "@/lib/schema-validation";
 import { createAdminClient } from "./admin";
 import type { Database, Json } from "./database";
 import { resolveEndpointAccess } from "./teams";
@@ -5,6 +9,8 @@ import { resolveEndpointAccess } from "./teams";
 const FREE_RETENTION_MS = 7 * 24 * 60 * 60 * 1000;
 const PRO_RETENTION_MS = 30 * 24 * 60 * 60 * 1000;
 const MAX_LIST_LIMIT = 1000;
+const REQUEST_COLUMNS =
+  "id, endpoint_id, method, path, headers, body, body_raw, query_params, content_type, ip, size, received_at, body_hash, duplicate_of, mock_variant, mock_version, chaos_fault, response_override, parts, body_ref, response, frame, cloud_event, http_version, priority, client_cert, trailers, fingerprint, provider, event_type, content_class, signature_valid, jwt_valid, jwt_claims, schema_valid, schema_errors, sizes, redactions, country, asn, as_org, tls, note, tags";
 
 type RequestRow = Database["public"]["Tables"]["requests"]["Row"];
 type SelectedRequestRow = Pick<
@@ -21,10 +27,145 @@ type SelectedRequestRow = Pick<
   | "ip"
   | "size"
   | "received_at"
+  | "body_hash"
+  | "duplicate_of"
+  | "mock_variant"
+  | "mock_version"
+  | "chaos_fault"
+  | "response_override"
+  | "parts"
+  | "body_ref"
+  | "response"
+  | "frame"
+  | "cloud_event"
+  | "http_version"
+  | "priority"
+  | "client_cert"
+  | "trailers"
+  | "fingerprint"
+  | "provider"
+  | "event_type"
+  | "content_class"
+  | "signature_valid"
+  | "jwt_valid"
+  | "jwt_claims"
+  | "schema_valid"
+  | "schema_errors"
+  | "sizes"
+  | "redactions"
+  | "country"
+  | "asn"
+  | "as_org"
+  | "tls"
+  | "note"
+  | "tags"
 >;
 type OwnedEndpointRow = Pick<Database["public"]["Tables"]["endpoints"]["Row"], "id" | "slug">;
 type UserPlan = Database["public"]["Tables"]["users"]["Row"]["plan"];
 
+/** One part of a multipart/form-data body, described by the receiver. */
+export interface MultipartPart {
+  name?: string;
+  filename?: string;
+  contentType?: string;
+  /** Part body size in bytes */
+  size: number;
+  /** Small UTF-8 part bodies, when the receiver inlines them */
+  body?: string;
+}
+
+/** The reply the receiver sent, recorded when the endpoint opts in or forwards. */
+export interface CapturedResponse {
+  status: number;
+  /**
+   * `mock` for the endpoint's mock response (see `mockVariant`), `handshake` for an
+   * auto-handshake answer, `proxy` for the forward URL's reply, `rate_limit` for the
+   * simulated rate limit's 429, `default` for the plain 200 OK
+   */
+  source: "mock" | "handshake" | "proxy" | "rate_limit" | "default";
+  headers: Record<string, string>;
+  /** Response body size in bytes */
+  bodySize: number;
+  /** Delay applied before replying, after the receiver's cap */
+  delayMs?: number;
+  /** Proxied replies: time the forward URL took to answer */
+  latencyMs?: number;
+  /** Proxied replies: the body received, up to 64KB */
+  body?: string;
+  /** Set when `body` is base64 because the reply wasn't UTF-8 */
+  bodyEncoding?: "base64";
+  /** Set when `body` holds only the first 64KB */
+  bodyTruncated?: boolean;
+  /** Proxied replies: why the forward URL didn't answer */
+  error?: string;
+}
+
+/** Where a message captured over a WebSocket connection sits in it. */
+export interface WebSocketFrame {
+  /** Random ID shared by the messages of one connection */
+  connection: string;
+  /** Position among the connection's captured messages, from 1 */
+  seq: number;
+  opcode: "text" | "binary";
+}
+
+/** CloudEvents context attributes the receiver found on a capture. */
+export interface CloudEvent {
+  /** `binary` for `ce-*` headers, `structured` for an `application/cloudevents+json` body */
+  mode: "binary" | "structured";
+  specversion: string;
+  type: string;
+  source: string;
+  id: string;
+  subject?: string;
+}
+
+/** Reply overrides a capture asked for with `__status` and `__delay`. */
+export interface ResponseOverride {
+  status?: number;
+  /** Milliseconds, in place of the mock's delay */
+  delay?: number;
+}
+
+/** What an HTTP capture weighed as received and what was kept of it. */
+export interface RequestSizes {
+  /** Header block as received, uncompressed */
+  headers: number;
+  /** Body as received */
+  body: number;
+  /** Body bytes stored with the request; 0 when it went to object storage */
+  stored: number;
+  /** Fields not stored as sent, with the reason (`body: "transformed"`, `path: "max_length"`) */
+  truncated?: Record<string, string>;
+}
+
+/** Client certificate presented on the receiver's mTLS listener. */
+export interface ClientCertificate {
+  /** RFC 4514 distinguished name, most specific attribute first */
+  subject: string;
+  issuer: string;
+  /** Serial number, lowercase hex */
+  serial: string;
+  /** SHA-256 of the DER certificate, lowercase hex */
+  fingerprint: string;
+  notBefore: number;
+  notAfter: number;
+  /** Set when the chain verified against the endpoint's client CA */
+  verified?: boolean;
+}
+
+/** What the sender negotiated when the receiver ended TLS itself. */
+export interface TlsDetails {
+  /** `TLSv1.2` or `TLSv1.3` */
+  version: string;
+  /** IANA cipher suite name */
+  cipher: string;
+  /** Hostname the client asked for */
+  sni?: string;
+  /** Protocol picked by ALPN (`h2`, `http/1.1`) */
+  alpn?: string;
+}
+
 export interface RequestRecord {
   id: string;
   endpointId: string;
@@ -39,6 +180,72 @@ export interface RequestRecord {
   ip: string;
   size: number;
   receivedAt: number;
+  /** SHA-256 of the stored body */
+  bodyHash?: string;
+  /** First request with the same delivery ID (3 days) or method, path and body (10 minutes) */
+  duplicateOf?: string;
+  /** Weighted mock variant this capture was answered with (`default` for the base response) */
+  mockVariant?: string;
+  /** Mock version this capture was answered with while a mock canary ran */
+  mockVersion?: "stable" | "canary";
+  /** Fault the endpoint's chaos mode injected in place of the reply */
+  chaosFault?: "error" | "hang" | "reset";
+  /** Status and delay the request's query parameters asked for, on endpoints that allow it */
+  responseOverride?: ResponseOverride;
+  /** Parts of a multipart/form-data body */
+  parts?: MultipartPart[];
+  /** Object key of a body too large to store inline; `body` is empty and
+   * GET /api/requests/:id/body returns a download URL */
+  bodyRef?: string;
+  /** Reply sent for this capture, when the endpoint records responses */
+  response?: CapturedResponse;
+  /** Set on messages captured at /ws/:slug (method `WS`) */
+  frame?: WebSocketFrame;
+  /** Set when the capture is a CloudEvent */
+  cloudEvent?: CloudEvent;
+  /** Protocol the request arrived over (`HTTP/1.1`, `HTTP/2`); unset on older captures */
+  httpVersion?: string;
+  /** Set when the endpoint's priority rule marked the capture high priority */
+  priority?: boolean;
+  /** Set when the request arrived over mTLS with a client certificate */
+  clientCert?: ClientCertificate;
+  /** Set when the receiver ended the sender's TLS (mTLS listener, custom domains) */
+  tls?: TlsDetails;
+  /** HTTP trailers sent after the body, kept apart from the headers */
+  trailers?: Record<string, string>;
+  /** Same for captures of the same kind: method, path with IDs replaced, and body shape */
+  fingerprint?: string;
+  /** Sender recognized by its signature headers or user agent (`github`, `stripe`, ...) */
+  provider?: string;
+  /** The provider's name for the event (`invoice.paid`), or a CloudEvent's type */
+  eventType?: string;
+  /** Body class set at capture: json, xml, form, text, image, protobuf or binary */
+  contentClass?: string;
+  /** Whether the sender's signature checked out, when the endpoint verifies signatures */
+  signatureValid?: boolean;
+  /** Whether the sender's JWT checked out, when the endpoint validates JWTs */
+  jwtValid?: boolean;
+  /** The JWT's decoded payload, kept whether or not it verified */
+  jwtClaims?: Record<string, unknown>;
+  /** Whether the body matched the endpoint's JSON Schema, when it has one */
+  schemaValid?: boolean;
+  /** How the body broke the schema, when it didn't */
+  schemaErrors?: SchemaError[];
+  /** Sizes as received and as stored; unset for WebSocket, gRPC and older captures */
+  sizes?: RequestSizes;
+  /** How often each redaction rule fired, by rule id, when anything was redacted */
+  redactions?: Record<string, number>;
+  /** Sender's country (ISO 3166 alpha-2), from Cloudflare or the receiver's GeoIP database */
+  country?: string;
+  /** Autonomous system the sender's address belongs to */
+  asn?: number;
+  /** Organization holding that autonomous system */
+  asOrg?: string;
+  /** Free-text note attached while debugging */
+  note?: string;
+  tags: string[];
+  /** Fields that hide what was sent (`header:<name>`, `body`, ...), when any do */
+  masked?: Record<string, MaskReason>;
 }
 
 export interface PaginatedRequestPage {
@@ -52,10 +259,104 @@ export interface ClearRequestsResult {
   complete: true;
 }
 
+export function normalizeParts(value: Json | null): MultipartPart[] | undefined {
+  if (!Array.isArray(value)) return undefined;
+  return value.filter(
+    (part): part is Json & MultipartPart =>
+      !!part && typeof part === "object" && !Array.isArray(part) && typeof part.size === "number"
+  );
+}
+
+export function normalizeResponse(value: Json | null): CapturedResponse | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  if (typeof value.status !== "number") return undefined;
+  return value as unknown as CapturedResponse;
+}
+
+export function normalizeResponseOverride(value: Json | null): ResponseOverride | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  return value as unknown as ResponseOverride;
+}
+
+export function normalizeFrame(value: Json | null): WebSocketFrame | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  if (typeof value.connection !== "string" || typeof value.seq !== "number") return undefined;
+  return value as unknown as WebSocketFrame;
+}
+
+export function normalizeCloudEvent(value: Json | null): CloudEvent | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  if (typeof value.type !== "string" || typeof value.source !== "string") return undefined;
+  return value as unknown as CloudEvent;
+}
+
+export function normalizeClientCert(value: Json | null): ClientCertificate | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  const { subject, issuer, serial, fingerprint, not_before, not_after, verified } = value;
+  if (typeof subject !== "string" || typeof fingerprint !== "string") return undefined;
+  return {
+    subject,
+    issuer: typeof issuer === "string" ? issuer : "",
+    serial: typeof serial === "string" ? serial : "",
+    fingerprint,
+    notBefore: typeof not_before === "string" ? Date.parse(not_before) : 0,
+    notAfter: typeof not_after === "string" ? Date.parse(not_after) : 0,
+    ...(verified === true ? { verified } : {}),
+  };
+}
+
+export function normalizeTls(value: Json | null): TlsDetails | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  const { version, cipher, sni, alpn } = value;
+  if (typeof version !== "string" || typeof cipher !== "string") return undefined;
+  return {
+    version,
+    cipher,
+    ...(typeof sni === "string" ? { sni } : {}),
+    ...(typeof alpn === "string" ? { alpn } : {}),
+  };
+}
+
+export function normalizeSizes(value: Json | null): RequestSizes | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  const { headers, body, stored, truncated } = value;
+  if (typeof headers !== "number" || typeof body !== "number" || typeof stored !== "number") {
+    return undefined;
+  }
+  const reasons =
+    truncated && typeof truncated === "object" && !Array.isArray(truncated)
+      ? Object.fromEntries(
+          Object.entries(truncated).filter(
+            (entry): entry is [string, string] => typeof entry[1] === "string"
+          )
+        )
+      : {};
+  return {
+    headers,
+    body,
+    stored,
+    ...(Object.keys(reasons).length > 0 ? { truncated: reasons } : {}),
+  };
+}
+
+export function normalizeJwtClaims(value: Json | null): Record<string, unknown> | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return undefined;
+  return value as Record<string, unknown>;
+}
+
 function parseMillis(timestamp: string): number {
   return Date.parse(timestamp);
 }
 
+export function normalizeTrailers(
+  value: Json | null,
+  ownerId: string | null
+): Record<string, string> | undefined {
+  const trailers = asStringRecord(value);
+  if (Object.keys(trailers).length === 0) return undefined;
+  return decryptHeaders(trailers, ownerId);
+}
+
 function asStringRecord(value: Json): Record<string, string> {
   if (!value || typeof value !== "object" || Array.isArray(value)) {
     return {};
@@ -79,13 +380,17 @@ export function byteaToBase64(value: string): string {
   return Buffer.from(hex, "hex").toString("base64");
 }
 
-function normalizeRequest(row: SelectedRequestRow): RequestRecord {
-  return {
+/**
+ * `ownerId` is the endpoint owner, whose account key opens encrypted headers;
+ * null leaves them masked (callers that weren't asked to reveal them).
+ */
+function normalizeRequest(row: SelectedRequestRow, ownerId: string | null): RequestRecord {
+  const record: RequestRecord = {
     id: row.id,
     endpointId: row.endpoint_id,
     method: row.method,
     path: row.path,
-    headers: asStringRecord(row.headers),
+    headers: decryptHeaders(asStringRecord(row.headers), ownerId),
     body: row.body ?? undefined,
     bodyRaw: row.body_raw ? byteaToBase64(row.body_raw) : undefined,
     queryParams: asStringRecord(row.query_params),
@@ -93,7 +398,40 @@ function normalizeRequest(row: SelectedRequestRow): RequestRecord {
     ip: row.ip,
     size: row.size,
     receivedAt: parseMillis(row.received_at),
+    bodyHash: row.body_hash ?? undefined,
+    duplicateOf: row.duplicate_of ?? undefined,
+    mockVariant: row.mock_variant ?? undefined,
+    mockVersion: row.mock_version ?? undefined,
+    chaosFault: row.chaos_fault ?? undefined,
+    responseOverride: normalizeResponseOverride(row.response_override),
+    parts: normalizeParts(row.parts),
+    bodyRef: row.body_ref ?? undefined,
+    response: normalizeResponse(row.response),
+    frame: normalizeFrame(row.frame),
+    cloudEvent: normalizeCloudEvent(row.cloud_event),
+    httpVersion: row.http_version ?? undefined,
+    priority: row.priority || undefined,
+    clientCert: normalizeClientCert(row.client_cert),
+    tls: normalizeTls(row.tls),
+    trailers: normalizeTrailers(row.trailers, ownerId),
+    fingerprint: row.fingerprint ?? undefined,
+    provider: row.provider ?? undefined,
+    eventType: row.event_type ?? undefined,
+    contentClass: row.content_class ?? undefined,
+    signatureValid: row.signature_valid ?? undefined,
+    jwtValid: row.jwt_valid ?? undefined,
+    jwtClaims: normalizeJwtClaims(row.jwt_claims),
+    schemaValid: row.schema_valid ?? undefined,
+    schemaErrors: normalizeSchemaErrors(row.schema_errors),
+    sizes: normalizeSizes(row.sizes),
+    redactions: normalizeRedactions(row.redactions),
+    country: row.country ?? undefined,
+    asn: row.asn ?? undefined,
+    asOrg: row.as_org ?? undefined,
+    note: row.note ?? undefined,
+    tags: row.tags ?? [],
   };
+  return { ...record, masked: maskedFields(record) };
 }
 
 function clampLimit(limit: number | undefined, fallback: number): number {
@@ -180,18 +518,18 @@ async function getUserCutoff(userId: string): Promise<number> {
   return Date.now() - retentionMs;
 }
 
+/** Encrypted headers stay masked unless `reveal` is true. */
 export async function getRequestByIdForUser(
   userId: string,
-  requestId: string
+  requestId: string,
+  reveal = false
 ): Promise<RequestRecord | null> {
   const admin = createAdminClient();
 
   // Fetch request without user_id filter — we check access via endpoint ownership or team membership
   const { data, error } = await admin
     .from("requests")
-    .select(
-      "id, endpoint_id, method, path, headers, body, body_raw, query_params, content_type, ip, size, received_at"
-    )
+    .select(REQUEST_COLUMNS)
     .eq("id", requestId)
     .returns<SelectedRequestRow>()
     .maybeSingle();
@@ -220,7 +558,46 @@ export async function getRequestByIdForUser(
     return null;
   }
 
-  return normalizeRequest(row);
+  return normalizeRequest(row, reveal ? access.ownerId : null);
+}
+
+/**
+ * Replace the note and/or tags of a request the user can access. An empty
+ * note clears it. Returns null when the request isn't visible to the user.
+ */
+export async function annotateRequestForUser(
+  userId: string,
+  requestId: string,
+  annotation: { note?: string | null; tags?: string[] },
+  reveal = false
+): Promise<RequestRecord | null> {
+  const existing = await getRequestByIdForUser(userId, requestId, reveal);
+  if (!existing) return null;
+
+  const update: Database["public"]["Tables"]["requests"]["Update"] = {};
+  if (annotation.note !== undefined) {
+    update.note = annotation.note || null;
+  }
+  if (annotation.tags !== undefined) {
+    update.tags = annotation.tags;
+  }
+
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("requests")
+    .update(update)
+    .eq("id", requestId)
+    .select(REQUEST_COLUMNS)
+    .returns<SelectedRequestRow>()
+    .maybeSingle();
+
+  if (error) {
+    throw error;
+  }
+
+  const row = data as SelectedRequestRow | null;
+  // Everything but the annotation is unchanged (and already decrypted or masked).
+  return row ? { ...existing, note: row.note ?? undefined, tags: row.tags ?? [] } : null;
 }
 
 export async function listRequestsForEndpointByUser(input: {
@@ -228,23 +605,73 @@ export async function listRequestsForEndpointByUser(input: {
   slug: string;
   limit?: number;
   since?: number;
+  tag?: string;
+  eventType?: string;
+  country?: string;
+  asn?: number;
+  reveal?: boolean;
 }): Promise<RequestRecord[] | null> {
-  const admin = createAdminClient();
   const endpoint = await getAccessibleEndpoint(input.userId, input.slug);
   if (!endpoint) {
     return null;
   }
 
-  const cutoff = await getUserCutoff(endpoint.ownerId);
+  return listRequestsForEndpoint({
+    endpointId: endpoint.id,
+    ownerId: endpoint.ownerId,
+    limit: input.limit,
+    since: input.since,
+    tag: input.tag,
+    eventType: input.eventType,
+    country: input.country,
+    asn: input.asn,
+    reveal: input.reveal,
+  });
+}
+
+/**
+ * List recent requests for an endpoint whose access has already been checked
+ * (e.g. via a share token). Applies the owner's retention cutoff.
+ */
+export async function listRequestsForEndpoint(input: {
+  endpointId: string;
+  ownerId: string;
+  limit?: number;
+  since?: number;
+  /** Only requests carrying this tag */
+  tag?: string;
+  /** Only CloudEvents of this type */
+  eventType?: string;
+  /** Only requests from this country */
+  country?: string;
+  /** Only requests from this autonomous system */
+  asn?: number;
+  /** true opens encrypted headers; they stay masked otherwise */
+  reveal?: boolean;
+}): Promise<RequestRecord[]> {
+  const admin = createAdminClient();
+  const cutoff = await getUserCutoff(input.ownerId);
   const floor = input.since === undefined ? cutoff : Math.max(input.since, cutoff);
 
-  const { data, error } = await admin
+  const query = admin
     .from("requests")
-    .select(
-      "id, endpoint_id, method, path, headers, body, body_raw, query_params, content_type, ip, size, received_at"
-    )
-    .eq("endpoint_id", endpoint.id)
-    .gte("received_at", new Date(floor).toISOString())
+    .select(REQUEST_COLUMNS)
+    .eq("endpoint_id", input.endpointId)
+    .gte("received_at", new Date(floor).toISOString());
+  if (input.tag !== undefined) {
+    query.contains("tags", [input.tag]);
+  }
+  if (input.eventType !== undefined) {
+    query.eq("cloud_event->>type", input.eventType);
+  }
+  if (input.country !== undefined) {
+    query.eq("country", input.country);
+  }
+  if (input.asn !== undefined) {
+    query.eq("asn", input.asn);
+  }
+
+  const { data, error } = await query
     .order("received_at", { ascending: false })
     .limit(clampLimit(input.limit, 50))
     .returns<SelectedRequestRow[]>();
@@ -253,7 +680,8 @@ export async function listRequestsForEndpointByUser(input: {
     throw error;
   }
 
-  return (data ?? []).map(normalizeRequest);
+  const keyOwner = input.reveal ? input.ownerId : null;
+  return (data ?? []).map((row) => normalizeRequest(row, keyOwner));
 }
 
 export async function listNewRequestsForEndpointByUser(input: {
@@ -261,6 +689,7 @@ export async function listNewRequestsForEndpointByUser(input: {
   slug: string;
   after: number;
   limit?: number;
+  reveal?: boolean;
 }): Promise<RequestRecord[] | null> {
   const admin = createAdminClient();
   const endpoint = await getAccessibleEndpoint(input.userId, input.slug);
@@ -273,9 +702,7 @@ export async function listNewRequestsForEndpointByUser(input: {
 
   const { data, error } = await admin
     .from("requests")
-    .select(
-      "id, endpoint_id, method, path, headers, body, body_raw, query_params, content_type, ip, size, received_at"
-    )
+    .select(REQUEST_COLUMNS)
     .eq("endpoint_id", endpoint.id)
     .gt("received_at", new Date(floor).toISOString())
     .order("received_at", { ascending: true })
@@ -286,7 +713,8 @@ export async function listNewRequestsForEndpointByUser(input: {
     throw error;
   }
 
-  return (data ?? []).map(normalizeRequest);
+  const keyOwner = input.reveal ? endpoint.ownerId : null;
+  return (data ?? []).map((row) => normalizeRequest(row, keyOwner));
 }
 
 export async function listPaginatedRequestsForEndpointByUser(input: {
@@ -294,6 +722,11 @@ export async function listPaginatedRequestsForEndpointByUser(input: {
   slug: string;
   limit?: number;
   cursor?: string;
+  tag?: string;
+  eventType?: string;
+  country?: string;
+  asn?: number;
+  reveal?: boolean;
 }): Promise<PaginatedRequestPage | null> {
   const admin = createAdminClient();
   const endpoint = await getAccessibleEndpoint(input.userId, input.slug);
@@ -310,13 +743,25 @@ export async function listPaginatedRequestsForEndpointByUser(input: {
   const cutoff = decoded?.cutoff ?? (await getUserCutoff(endpoint.ownerId));
   const offset = decoded?.offset ?? 0;
 
-  const { data, error } = await admin
+  const query = admin
     .from("requests")
-    .select(
-      "id, endpoint_id, method, path, headers, body, body_raw, query_params, content_type, ip, size, received_at"
-    )
+    .select(REQUEST_COLUMNS)
     .eq("endpoint_id", endpoint.id)
-    .gte("received_at", new Date(cutoff).toISOString())
+    .gte("received_at", new Date(cutoff).toISOString());
+  if (input.tag !== undefined) {
+    query.contains("tags", [input.tag]);
+  }
+  if (input.eventType !== undefined) {
+    query.eq("cloud_event->>type", input.eventType);
+  }
+  if (input.country !== undefined) {
+    query.eq("country", input.country);
+  }
+  if (input.asn !== undefined) {
+    query.eq("asn", input.asn);
+  }
+
+  const { data, error } = await query
     .order("received_at", { ascending: false })
     .range(offset, offset + limit)
     .returns<SelectedRequestRow[]>();
@@ -326,7 +771,8 @@ export async function listPaginatedRequestsForEndpointByUser(input: {
   }
 
   const rows = data ?? [];
-  const items = rows.slice(0, limit).map(normalizeRequest);
+  const keyOwner = input.reveal ? endpoint.ownerId : null;
+  const items = rows.slice(0, limit).map((row) => normalizeRequest(row, keyOwner));
   const hasMore = rows.length > limit;
 
   return {
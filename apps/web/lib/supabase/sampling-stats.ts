@@ -0,0 +1,48 @@
+import { createAdminClient } from "./admin";
+
+/**
+ * Counters for an endpoint with traffic sampling, since its sampling config
+ * last changed. Counted by capture_webhook() for every request, stored or
+ * sampled out, so volumes can be scaled up from the stored sample.
+ */
+export interface SamplingStatsRecord {
+  /** Requests received, stored or not */
+  requests: number;
+  bytes: number;
+  /** Requests answered but not stored */
+  sampledOut: number;
+  sampledOutBytes: number;
+  /** `null` until a request arrives */
+  firstRequestAt: number | null;
+  lastRequestAt: number | null;
+}
+
+export async function getSamplingStats(endpointId: string): Promise<SamplingStatsRecord> {
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("endpoint_sampling_stats")
+    .select("requests, bytes, sampled_out, sampled_out_bytes, first_request_at, last_request_at")
+    .eq("endpoint_id", endpointId)
+    .maybeSingle();
+
+  if (error) throw error;
+  if (!data) {
+    return {
+      requests: 0,
+      bytes: 0,
+      sampledOut: 0,
+      sampledOutBytes: 0,
+      firstRequestAt: null,
+      lastRequestAt: null,
+    };
+  }
+
+  return {
+    requests: Number(data.requests),
+    bytes: Number(data.bytes),
+    sampledOut: Number(data.sampled_out),
+    sampledOutBytes: Number(data.sampled_out_bytes),
+    firstRequestAt: Date.parse(data.first_request_at),
+    lastRequestAt: Date.parse(data.last_request_at),
+  };
+}
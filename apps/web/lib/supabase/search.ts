@@ -1,3 +1,4 @@
+import { decryptHeaders } from "@/lib/header-crypt";
 import { createAdminClient } from "./admin";
 import type { Database, Json } from "./database";
 
@@ -20,6 +21,7 @@ export interface SearchRequestRecord {
   ip: string;
   size: number;
   receivedAt: number;
+  contentClass?: string;
 }
 
 export interface SearchRequestsInput {
@@ -33,6 +35,7 @@ export interface SearchRequestsInput {
   limit?: number;
   offset?: number;
   order?: "asc" | "desc";
+  contentClass?: string;
 }
 
 export interface CountSearchRequestsInput {
@@ -43,6 +46,7 @@ export interface CountSearchRequestsInput {
   q?: string;
   from?: number;
   to?: number;
+  contentClass?: string;
 }
 
 function asStringRecord(value: Json): Record<string, string> {
@@ -80,19 +84,21 @@ function normalizeTimestamp(value: number | undefined): number | null {
   return Number.isFinite(value) ? Math.floor(value) : null;
 }
 
-function normalizeSearchRow(row: SearchRpcRow): SearchRequestRecord {
+/** Search only covers the user's own endpoints, so their key opens encrypted headers. */
+function normalizeSearchRow(row: SearchRpcRow, userId: string): SearchRequestRecord {
   return {
     id: row.id,
     slug: row.slug,
     method: row.method,
     path: row.path,
-    headers: asStringRecord(row.headers),
+    headers: decryptHeaders(asStringRecord(row.headers), userId),
     body: row.body ?? undefined,
     queryParams: asStringRecord(row.query_params),
     contentType: row.content_type ?? undefined,
     ip: row.ip,
     size: row.size,
     receivedAt: row.received_at,
+    contentClass: row.content_class ?? undefined,
   };
 }
 
@@ -132,13 +138,14 @@ export async function searchRequestsForUser(
     p_limit: clampLimit(input.limit),
     p_offset: clampOffset(input.offset),
     p_order: normalizeOrder(input.order),
+    p_content_class: normalizeOptionalString(input.contentClass),
   });
 
   if (error) {
     throw error;
   }
 
-  return (data ?? []).map(normalizeSearchRow);
+  return (data ?? []).map((row) => normalizeSearchRow(row, input.userId));
 }
 
 export async function countSearchRequestsForUser(input: CountSearchRequestsInput): Promise<number> {
@@ -153,6 +160,7 @@ export async function countSearchRequestsForUser(input: CountSearchRequestsInput
     p_q: normalizeOptionalString(input.q),
     p_from_ms: normalizeTimestamp(input.from),
     p_to_ms: normalizeTimestamp(input.to),
+    p_content_class: normalizeOptionalString(input.contentClass),
   });
 
   if (error) {
@@ -0,0 +1,165 @@
+import { createHash } from "node:crypto";
+import { customAlphabet } from "nanoid";
+import { createAdminClient } from "./admin";
+
+export const MAX_SHARE_TOKENS_PER_ENDPOINT = 20;
+export const DEFAULT_SHARE_TTL_MS = 24 * 60 * 60 * 1000;
+export const MAX_SHARE_TTL_MS = 30 * 24 * 60 * 60 * 1000;
+
+const generateShareTokenBody = customAlphabet(
+  "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+  32
+);
+
+export interface ShareTokenRecord {
+  id: string;
+  tokenPrefix: string;
+  expiresAt: number | null;
+  lastUsedAt: number | null;
+  createdAt: number;
+}
+
+export interface ShareTokenAccess {
+  endpointId: string;
+  slug: string;
+  name: string | null;
+  ownerId: string;
+  expiresAt: number | null;
+}
+
+export function generateShareToken(): string {
+  return `whsh_${generateShareTokenBody()}`;
+}
+
+export function hashShareToken(token: string): string {
+  return createHash("sha256").update(token).digest("hex");
+}
+
+function parseMillis(timestamp: string | null): number | null {
+  return timestamp ? Date.parse(timestamp) : null;
+}
+
+export async function listShareTokens(endpointId: string): Promise<ShareTokenRecord[]> {
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("endpoint_share_tokens")
+    .select("id, token_prefix, expires_at, last_used_at, created_at")
+    .eq("endpoint_id", endpointId)
+    .order("created_at", { ascending: false });
+
+  if (error) throw error;
+
+  return (data ?? []).map((row) => ({
+    id: row.id,
+    tokenPrefix: row.token_prefix,
+    expiresAt: parseMillis(row.expires_at),
+    lastUsedAt: parseMillis(row.last_used_at),
+    createdAt: Date.parse(row.created_at),
+  }));
+}
+
+export async function createShareToken(input: {
+  endpointId: string;
+  userId: string;
+  ttlMs: number;
+}): Promise<{ token: string; record: ShareTokenRecord } | { error: string }> {
+  const admin = createAdminClient();
+
+  const { count, error: countError } = await admin
+    .from("endpoint_share_tokens")
+    .select("id", { count: "exact", head: true })
+    .eq("endpoint_id", input.endpointId);
+
+  if (countError) throw countError;
+
+  if ((count ?? 0) >= MAX_SHARE_TOKENS_PER_ENDPOINT) {
+    return {
+      error: `Maximum of ${MAX_SHARE_TOKENS_PER_ENDPOINT} share tokens per endpoint. Revoke one first.`,
+    };
+  }
+
+  const token = generateShareToken();
+  const expiresAt = new Date(Date.now() + input.ttlMs).toISOString();
+
+  const { data, error } = await admin
+    .from("endpoint_share_tokens")
+    .insert({
+      endpoint_id: input.endpointId,
+      created_by: input.userId,
+      token_hash: hashShareToken(token),
+      token_prefix: token.slice(0, 12),
+      expires_at: expiresAt,
+    })
+    .select("id, token_prefix, expires_at, last_used_at, created_at")
+    .single();
+
+  if (error) throw error;
+
+  return {
+    token,
+    record: {
+      id: data.id,
+      tokenPrefix: data.token_prefix,
+      expiresAt: parseMillis(data.expires_at),
+      lastUsedAt: null,
+      createdAt: Date.parse(data.created_at),
+    },
+  };
+}
+
+export async function revokeShareToken(endpointId: string, tokenId: string): Promise<boolean> {
+  const admin = createAdminClient();
+  const { data, error } = await admin
+    .from("endpoint_share_tokens")
+    .delete()
+    .eq("id", tokenId)
+    .eq("endpoint_id", endpointId)
+    .select("id");
+
+  if (error) throw error;
+  return (data ?? []).length > 0;
+}
+
+/** Resolve a raw share token to the endpoint it grants read access to. */
+export async function resolveShareToken(token: string): Promise<ShareTokenAccess | null> {
+  if (!token.startsWith("whsh_")) return null;
+
+  const admin = createAdminClient();
+  const { data: row, error } = await admin
+    .from("endpoint_share_tokens")
+    .select("id, endpoint_id, expires_at")
+    .eq("token_hash", hashShareToken(token))
+    .maybeSingle();
+
+  if (error) throw error;
+  if (!row) return null;
+
+  const expiresAt = parseMillis(row.expires_at);
+  if (expiresAt !== null && expiresAt < Date.now()) return null;
+
+  const { data: endpoint, error: endpointError } = await admin
+    .from("endpoints")
+    .select("id, slug, name, user_id")
+    .eq("id", row.endpoint_id)
+    .maybeSingle();
+
+  if (endpointError) throw endpointError;
+  if (!endpoint || !endpoint.user_id) return null;
+
+  const { error: updateError } = await admin
+    .from("endpoint_share_tokens")
+    .update({ last_used_at: new Date().toISOString() })
+    .eq("id", row.id);
+
+  if (updateError) {
+    console.error("Failed to update endpoint_share_tokens.last_used_at:", updateError);
+  }
+
+  return {
+    endpointId: endpoint.id,
+    slug: endpoint.slug,
+    name: endpoint.name,
+    ownerId: endpoint.user_id,
+    expiresAt,
+  };
+}
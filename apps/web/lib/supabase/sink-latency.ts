@@ -0,0 +1,30 @@
+import type { DestinationLatency } from "../delivery-latency";
+import { createAdminClient } from "./admin";
+
+/** Latency percentiles per function sink destination for deliveries received since `since` (ms). */
+export async function getSinkLatency(
+  endpointId: string,
+  since: number
+): Promise<DestinationLatency[]> {
+  const admin = createAdminClient();
+  const { data, error } = await admin.rpc("function_sink_latency", {
+    p_endpoint_id: endpointId,
+    p_since: new Date(since).toISOString(),
+  });
+
+  if (error) throw error;
+
+  return (data ?? []).map((row) => ({
+    target: row.target,
+    deliveries: Number(row.deliveries),
+    failed: Number(row.failed),
+    retried: Number(row.retried),
+    relay: { p50: row.relay_p50, p90: row.relay_p90, p99: row.relay_p99 },
+    destination: {
+      p50: row.destination_p50,
+      p90: row.destination_p90,
+      p99: row.destination_p99,
+    },
+    total: { p50: row.total_p50, p90: row.total_p90, p99: row.total_p99 },
+  }));
+}
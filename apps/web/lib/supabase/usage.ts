@@ -1,3 +1,11 @@
+import {
+  type ApiUsage,
+  type ApiUsageDelta,
+  type ApiUsageRow,
+  toApiUsage,
+  usageDay,
+} from "../api-usage";
+import { type ReportMonth, type UsageReport, toUsageReport } from "../usage-report";
 import { createAdminClient } from "./admin";
 import type { UserPlan } from "./api-keys";
 
@@ -38,3 +46,70 @@ export async function getUsageForUser(userId: string): Promise<UsageInfo | null>
     periodEnd: periodActive ? periodEndMs : null,
   };
 }
+
+/** Add to the account's API counters for today. Metering never fails a request. */
+export function recordApiUsage(userId: string, delta: ApiUsageDelta): void {
+  const admin = createAdminClient();
+  void admin
+    .rpc("record_api_usage", {
+      p_user_id: userId,
+      p_api_calls: delta.apiCalls ?? 0,
+      p_stream_seconds: delta.streamSeconds ?? 0,
+      p_replays: delta.replays ?? 0,
+      p_exports: delta.exports ?? 0,
+    })
+    .then(({ error }) => {
+      if (error) console.error("Failed to record API usage:", error);
+    });
+}
+
+/** Today's API usage against the account's daily limits. */
+export async function getApiUsageForUser(userId: string): Promise<ApiUsage | null> {
+  const admin = createAdminClient();
+  const now = Date.now();
+  const [user, row] = await Promise.all([
+    admin.from("users").select("plan").eq("id", userId).maybeSingle(),
+    admin
+      .from("api_usage_daily")
+      .select("api_calls, stream_seconds, replays, exports")
+      .eq("user_id", userId)
+      .eq("day", usageDay(now))
+      .maybeSingle<ApiUsageRow>(),
+  ]);
+
+  if (user.error) throw user.error;
+  if (row.error) throw row.error;
+
+  const plan = user.data?.plan;
+  if (plan !== "free" && plan !== "pro") {
+    return null;
+  }
+
+  return toApiUsage(row.data, plan, now);
+}
+
+/** An endpoint's usage over `month`, with its share of the owner's request limit. */
+export async function getEndpointUsageReport(
+  endpointId: string,
+  ownerId: string,
+  slug: string,
+  month: ReportMonth
+): Promise<UsageReport> {
+  const admin = createAdminClient();
+  const [report, owner] = await Promise.all([
+    admin.rpc("endpoint_usage_report", {
+      p_endpoint_id: endpointId,
+      p_from: month.from,
+      p_to: month.to,
+    }),
+    admin.from("users").select("plan, request_limit").eq("id", ownerId).maybeSingle(),
+  ]);
+
+  if (report.error) throw report.error;
+  if (owner.error) throw owner.error;
+
+  return toUsageReport(slug, month.month, report.data, {
+    plan: owner.data?.plan ?? "free",
+    limit: owner.data?.request_limit ?? 0,
+  });
+}
@@ -0,0 +1,57 @@
+import { describe, expect, test } from "vitest";
+
+import { parseReportMonth, toUsageReport } from "./usage-report";
+
+const NOW = Date.UTC(2026, 9, 16, 12);
+
+describe("parseReportMonth", () => {
+  test("covers the calendar month in UTC", () => {
+    expect(parseReportMonth("2024-06", NOW)).toEqual({
+      month: "2024-06",
+      from: "2024-06-01",
+      to: "2024-07-01",
+    });
+    expect(parseReportMonth("2025-12", NOW)?.to).toBe("2026-01-01");
+    expect(parseReportMonth("2026-10", NOW)?.from).toBe("2026-10-01");
+  });
+
+  test("rejects other formats and future months", () => {
+    expect(parseReportMonth("2024-6", NOW)).toBeNull();
+    expect(parseReportMonth("2024-13", NOW)).toBeNull();
+    expect(parseReportMonth("June 2024", NOW)).toBeNull();
+    expect(parseReportMonth("2026-11", NOW)).toBeNull();
+  });
+});
+
+describe("toUsageReport", () => {
+  test("converts counts and computes the quota share", () => {
+    const report = toUsageReport(
+      "abc123",
+      "2024-06",
+      {
+        requests: 250,
+        bytes: "51200",
+        max_size: 4096,
+        days: [{ day: "2024-06-03", requests: 250, bytes: 51200 }],
+        methods: { POST: "249", GET: 1 },
+        top_senders: [{ ip: "203.0.113.7", requests: "200" }],
+        capture_failures: 3,
+        capture_failure_windows: 1,
+      },
+      { plan: "pro", limit: 1000 }
+    );
+
+    expect(report.bytes).toBe(51200);
+    expect(report.methods).toEqual({ POST: 249, GET: 1 });
+    expect(report.topSenders).toEqual([{ ip: "203.0.113.7", requests: 200 }]);
+    expect(report.quota).toEqual({ plan: "pro", limit: 1000, share: 0.25 });
+    expect(report.captureFailures).toEqual({ count: 3, windows: 1 });
+  });
+
+  test("treats a missing report as empty", () => {
+    const report = toUsageReport("abc123", "2024-06", null, { plan: "free", limit: 0 });
+    expect(report.requests).toBe(0);
+    expect(report.days).toEqual([]);
+    expect(report.quota.share).toBe(0);
+  });
+});
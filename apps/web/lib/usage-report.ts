@@ -0,0 +1,109 @@
+/**
+ * Monthly per-endpoint usage reports, summarised by endpoint_usage_report()
+ * from daily rollups (endpoint_usage_daily) so months older than request
+ * retention can still be reported. Months are calendar months in UTC.
+ */
+
+const MONTH_REGEX = /^(\d{4})-(0[1-9]|1[0-2])$/;
+
+/** The first day of `month` and of the month after, as `YYYY-MM-DD`. */
+export interface ReportMonth {
+  month: string;
+  from: string;
+  to: string;
+}
+
+/** Parse `YYYY-MM`. Returns null for anything else, or a month that hasn't started. */
+export function parseReportMonth(value: string, now = Date.now()): ReportMonth | null {
+  const match = MONTH_REGEX.exec(value);
+  if (!match) return null;
+  const year = Number(match[1]);
+  const month = Number(match[2]);
+  const start = Date.UTC(year, month - 1, 1);
+  if (start > now) return null;
+  const next = new Date(Date.UTC(year, month, 1));
+  return {
+    month: value,
+    from: `${value}-01`,
+    to: next.toISOString().slice(0, 10),
+  };
+}
+
+export interface DailyUsage {
+  /** `YYYY-MM-DD` */
+  day: string;
+  requests: number;
+  bytes: number;
+}
+
+export interface SenderUsage {
+  ip: string;
+  requests: number;
+}
+
+export interface UsageReport {
+  slug: string;
+  month: string;
+  requests: number;
+  /** Total body bytes */
+  bytes: number;
+  /** Largest body in bytes */
+  maxSize: number;
+  /** Requests per day, for days with any */
+  days: DailyUsage[];
+  /** Requests per HTTP method */
+  methods: Record<string, number>;
+  /** Busiest sender IPs, at most 10 */
+  topSenders: SenderUsage[];
+  /** The month's requests against the owner's current request limit */
+  quota: { plan: string; limit: number; share: number };
+  /** Requests the receiver accepted but could not store */
+  captureFailures: { count: number; windows: number };
+}
+
+type ReportRow = {
+  requests?: number;
+  bytes?: number;
+  max_size?: number;
+  days?: DailyUsage[];
+  methods?: Record<string, number>;
+  top_senders?: SenderUsage[];
+  capture_failures?: number;
+  capture_failure_windows?: number;
+};
+
+/** Shape endpoint_usage_report()'s jsonb for the API. */
+export function toUsageReport(
+  slug: string,
+  month: string,
+  row: unknown,
+  quota: { plan: string; limit: number }
+): UsageReport {
+  const data = (row && typeof row === "object" ? row : {}) as ReportRow;
+  const requests = Number(data.requests ?? 0);
+  return {
+    slug,
+    month,
+    requests,
+    bytes: Number(data.bytes ?? 0),
+    maxSize: Number(data.max_size ?? 0),
+    days: (data.days ?? []).map((d) => ({
+      day: d.day,
+      requests: Number(d.requests),
+      bytes: Number(d.bytes),
+    })),
+    methods: Object.fromEntries(
+      Object.entries(data.methods ?? {}).map(([method, count]) => [method, Number(count)])
+    ),
+    topSenders: (data.top_senders ?? []).map((s) => ({ ip: s.ip, requests: Number(s.requests) })),
+    quota: {
+      plan: quota.plan,
+      limit: quota.limit,
+      share: quota.limit > 0 ? requests / quota.limit : 0,
+    },
+    captureFailures: {
+      count: Number(data.capture_failures ?? 0),
+      windows: Number(data.capture_failure_windows ?? 0),
+    },
+  };
+}
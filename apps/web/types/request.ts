@@ -11,6 +11,74 @@ export interface Request {
   ip: string;
   size: number;
   receivedAt: number;
+  /** Marked high priority by the endpoint's priority rule */
+  priority?: boolean;
+  /** Certificate the sender presented over mTLS */
+  clientCert?: ClientCertificate;
+  /** What the sender negotiated when the receiver ended its TLS */
+  tls?: TlsDetails;
+  /** Body class the receiver assigned at capture */
+  contentClass?: string;
+  /** Whether the sender's signature checked out, when the endpoint verifies signatures */
+  signatureValid?: boolean;
+  /** Whether the sender's JWT checked out, when the endpoint validates JWTs */
+  jwtValid?: boolean;
+  /** The JWT's decoded payload */
+  jwtClaims?: Record<string, unknown>;
+  /** Whether the body matched the endpoint's JSON Schema, when it has one */
+  schemaValid?: boolean;
+  /** How the body broke the schema */
+  schemaErrors?: SchemaError[];
+  /** Sizes as received and as stored */
+  sizes?: RequestSizes;
+  /** How often each redaction rule fired, when anything was redacted */
+  redactions?: Record<string, number>;
+  /** Sender's country code, from Cloudflare or the receiver's GeoIP database */
+  country?: string;
+  /** Autonomous system the sender's address belongs to */
+  asn?: number;
+  asOrg?: string;
+  /** Fields that don't show what was sent, and why */
+  masked?: Record<string, "redacted" | "encrypted">;
+}
+
+export interface RequestSizes {
+  /** Header block as received, uncompressed */
+  headers: number;
+  /** Body as received */
+  body: number;
+  /** Body bytes stored with the request; 0 when it went to object storage */
+  stored: number;
+  /** Fields not stored as sent, with the reason */
+  truncated?: Record<string, string>;
+}
+
+export interface SchemaError {
+  /** JSON pointer to the failing value; empty for the whole body */
+  path: string;
+  keyword: string;
+  message: string;
+}
+
+export interface ClientCertificate {
+  subject: string;
+  issuer: string;
+  serial: string;
+  /** SHA-256 of the DER certificate, lowercase hex */
+  fingerprint: string;
+  notBefore: number;
+  notAfter: number;
+  /** Chains to the endpoint's trusted CA */
+  verified?: boolean;
+}
+
+export interface TlsDetails {
+  /** `TLSv1.2` or `TLSv1.3` */
+  version: string;
+  /** IANA cipher suite name */
+  cipher: string;
+  sni?: string;
+  alpn?: string;
 }
 
 export interface RequestSummary {
@@ -21,6 +89,7 @@ export interface RequestSummary {
   contentType?: string;
   size: number;
   receivedAt: number;
+  priority?: boolean;
 }
 
 /** A request from search/pagination (uses string id instead of _id). */
@@ -36,6 +105,7 @@ export interface ClickHouseRequest {
   ip: string;
   size: number;
   receivedAt: number;
+  contentClass?: string;
 }
 
 /** Summary shape for ClickHouse results displayed in the sidebar list. */
@@ -112,6 +182,26 @@ export function formatBytes(bytes: number): string {
   return `${(bytes / (1024 * 1024 * 1024)).toFixed(2)} GB`;
 }
 
+const TRUNCATION_REASONS: Record<string, string> = {
+  max_length: "cut to the length limit",
+  offloaded: "in object storage",
+  max_size: "cut to the endpoint's size limit",
+};
+
+/**
+ * Describes what a capture weighed as received and what was stored, e.g.
+ * "Received 2.0 KB body, 412 B headers; stored 1.5 KB (body transformed)".
+ */
+export function describeSizes(sizes: RequestSizes): string {
+  const body = formatBytes(sizes.body);
+  const headers = formatBytes(sizes.headers);
+  const reasons = Object.entries(sizes.truncated ?? {}).map(
+    ([field, reason]) => `${field} ${TRUNCATION_REASONS[reason] ?? reason}`
+  );
+  const note = reasons.length > 0 ? ` (${reasons.join(", ")})` : "";
+  return `Received ${body} body, ${headers} headers; stored ${formatBytes(sizes.stored)}${note}`;
+}
+
 /**
  * Formats a timestamp as relative time (e.g. "2m ago", "1h ago").
  */
@@ -0,0 +1,290 @@
+//! Which provider sent a webhook, and which event it is.
+//!
+//! Shared by the receiver, which tags captures with `provider` and
+//! `eventType` as they are stored, and the `whk` CLI, which detects the same
+//! for captures stored before tagging existed.
+//!
+//! Providers are recognized by their signature headers first and by their
+//! user agents otherwise. The event type comes from wherever the provider
+//! puts it: a header (GitHub, Shopify, GitLab, Bitbucket) or a field of the
+//! JSON body.
+
+use serde_json::Value;
+
+/// Longest event type kept; anything longer isn't an event name.
+const MAX_EVENT_TYPE_LEN: usize = 200;
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum Provider {
+    GitHub,
+    Stripe,
+    Shopify,
+    Twilio,
+    Slack,
+    Paddle,
+    Linear,
+    SendGrid,
+    /// Svix-delivered webhooks (Clerk, Resend and others)
+    Svix,
+    Discord,
+    Vercel,
+    GitLab,
+    Bitbucket,
+    /// Any sender following the Standard Webhooks spec
+    StandardWebhooks,
+}
+
+impl Provider {
+    /// Identifier stored on captures, e.g. `github`.
+    pub fn id(self) -> &'static str {
+        match self {
+            Provider::GitHub => "github",
+            Provider::Stripe => "stripe",
+            Provider::Shopify => "shopify",
+            Provider::Twilio => "twilio",
+            Provider::Slack => "slack",
+            Provider::Paddle => "paddle",
+            Provider::Linear => "linear",
+            Provider::SendGrid => "sendgrid",
+            Provider::Svix => "svix",
+            Provider::Discord => "discord",
+            Provider::Vercel => "vercel",
+            Provider::GitLab => "gitlab",
+            Provider::Bitbucket => "bitbucket",
+            Provider::StandardWebhooks => "standard-webhooks",
+        }
+    }
+
+    /// Display name, e.g. `GitHub`.
+    pub fn name(self) -> &'static str {
+        match self {
+            Provider::GitHub => "GitHub",
+            Provider::Stripe => "Stripe",
+            Provider::Shopify => "Shopify",
+            Provider::Twilio => "Twilio",
+            Provider::Slack => "Slack",
+            Provider::Paddle => "Paddle",
+            Provider::Linear => "Linear",
+            Provider::SendGrid => "SendGrid",
+            Provider::Svix => "Svix",
+            Provider::Discord => "Discord",
+            Provider::Vercel => "Vercel",
+            Provider::GitLab => "GitLab",
+            Provider::Bitbucket => "Bitbucket",
+            Provider::StandardWebhooks => "Standard Webhooks",
+        }
+    }
+
+    /// The provider with this [`id`](Self::id).
+    pub fn from_id(id: &str) -> Option<Provider> {
+        ALL.iter().copied().find(|p| p.id() == id)
+    }
+}
+
+const ALL: &[Provider] = &[
+    Provider::GitHub,
+    Provider::Stripe,
+    Provider::Shopify,
+    Provider::Twilio,
+    Provider::Slack,
+    Provider::Paddle,
+    Provider::Linear,
+    Provider::SendGrid,
+    Provider::Svix,
+    Provider::Discord,
+    Provider::Vercel,
+    Provider::GitLab,
+    Provider::Bitbucket,
+    Provider::StandardWebhooks,
+];
+
+/// Headers that identify a provider on their own, in order of precedence.
+/// Svix comes before Standard Webhooks, which it can also send.
+const SIGNATURE_HEADERS: &[(&str, Provider)] = &[
+    ("x-github-event", Provider::GitHub),
+    ("stripe-signature", Provider::Stripe),
+    ("x-shopify-hmac-sha256", Provider::Shopify),
+    ("x-twilio-signature", Provider::Twilio),
+    ("x-slack-signature", Provider::Slack),
+    ("paddle-signature", Provider::Paddle),
+    ("linear-signature", Provider::Linear),
+    ("x-signature-ed25519", Provider::Discord),
+    ("x-vercel-signature", Provider::Vercel),
+    ("x-gitlab-event", Provider::GitLab),
+    ("x-gitlab-token", Provider::GitLab),
+    ("x-hook-uuid", Provider::Bitbucket),
+    ("svix-id", Provider::Svix),
+    ("webhook-signature", Provider::StandardWebhooks),
+    ("x-twilio-email-event-webhook-signature", Provider::SendGrid),
+];
+
+/// User-agent prefixes, for senders whose signature headers are missing
+/// (unsigned test deliveries, or stripped by a proxy).
+const USER_AGENTS: &[(&str, Provider)] = &[
+    ("GitHub-Hookshot/", Provider::GitHub),
+    ("Stripe/", Provider::Stripe),
+    ("Shopify-Captain-Hook", Provider::Shopify),
+    ("TwilioProxy/", Provider::Twilio),
+    ("Slackbot", Provider::Slack),
+    ("GitLab/", Provider::GitLab),
+    ("Bitbucket-Webhooks/", Provider::Bitbucket),
+    ("Svix-Webhooks/", Provider::Svix),
+    ("SendGrid Event API", Provider::SendGrid),
+];
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Detection {
+    pub provider: Provider,
+    /// The provider's name for the event, e.g. `invoice.paid` or
+    /// `pull_request.opened`, when the request carries one
+    pub event_type: Option<String>,
+}
+
+/// Detect the provider of a request. `header` looks up a header by its
+/// lowercase name; `body` is the body as received.
+pub fn detect<'a>(header: impl Fn(&str) -> Option<&'a str>, body: &[u8]) -> Option<Detection> {
+    let json = || serde_json::from_slice::<Value>(body).ok();
+    let provider = SIGNATURE_HEADERS
+        .iter()
+        .find(|(name, _)| header(name).is_some())
+        .map(|(_, provider)| *provider)
+        .or_else(|| {
+            let agent = header("user-agent")?;
+            USER_AGENTS
+                .iter()
+                .find(|(prefix, _)| agent.starts_with(prefix))
+                .map(|(_, provider)| *provider)
+        })
+        .or_else(|| is_sendgrid(&json()?).then_some(Provider::SendGrid))?;
+
+    let event_type = match provider {
+        Provider::GitHub => header("x-github-event").map(|event| {
+            match json().as_ref().and_then(|body| body["action"].as_str()) {
+                Some(action) => format!("{event}.{action}"),
+                None => event.to_string(),
+            }
+        }),
+        Provider::Shopify => header("x-shopify-topic").map(str::to_string),
+        Provider::GitLab => json()
+            .and_then(|body| body["object_kind"].as_str().map(str::to_string))
+            .or_else(|| header("x-gitlab-event").map(str::to_string)),
+        Provider::Bitbucket => header("x-event-key").map(str::to_string),
+        Provider::Linear => json().and_then(|body| {
+            let kind = header("linear-event").or(body["type"].as_str())?;
+            Some(match body["action"].as_str() {
+                Some(action) => format!("{kind}.{action}"),
+                None => kind.to_string(),
+            })
+        }),
+        Provider::Slack => json().and_then(|body| {
+            body["event"]["type"].as_str().or(body["type"].as_str()).map(str::to_string)
+        }),
+        Provider::Paddle => json().and_then(|body| body["event_type"].as_str().map(str::to_string)),
+        Provider::SendGrid => json().and_then(|body| body[0]["event"].as_str().map(str::to_string)),
+        Provider::Stripe | Provider::Svix | Provider::Vercel | Provider::StandardWebhooks => {
+            json().and_then(|body| body["type"].as_str().map(str::to_string))
+        }
+        Provider::Twilio | Provider::Discord => None,
+    };
+
+    Some(Detection {
+        provider,
+        event_type: event_type.filter(|t| !t.is_empty() && t.len() <= MAX_EVENT_TYPE_LEN),
+    })
+}
+
+/// SendGrid's event webhook is unsigned by default: a JSON array of events
+/// carrying `sg_event_id`.
+fn is_sendgrid(body: &Value) -> bool {
+    body.as_array()
+        .and_then(|events| events.first())
+        .is_some_and(|event| event.get("sg_event_id").is_some())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn detect_with(headers: &[(&str, &str)], body: &str) -> Option<(&'static str, Option<String>)> {
+        let found = detect(
+            |name| headers.iter().find(|(k, _)| *k == name).map(|(_, v)| *v),
+            body.as_bytes(),
+        )?;
+        Some((found.provider.id(), found.event_type))
+    }
+
+    #[test]
+    fn github_event_includes_the_action() {
+        assert_eq!(
+            detect_with(&[("x-github-event", "pull_request")], r#"{"action": "opened"}"#),
+            Some(("github", Some("pull_request.opened".to_string())))
+        );
+        assert_eq!(
+            detect_with(&[("x-github-event", "push")], r#"{"ref": "refs/heads/main"}"#),
+            Some(("github", Some("push".to_string())))
+        );
+    }
+
+    #[test]
+    fn event_type_from_the_body() {
+        let stripe = detect_with(&[("stripe-signature", "t=1,v1=abc")], r#"{"type": "invoice.paid"}"#);
+        assert_eq!(stripe, Some(("stripe", Some("invoice.paid".to_string()))));
+        let slack = detect_with(
+            &[("x-slack-signature", "v0=abc")],
+            r#"{"type": "event_callback", "event": {"type": "app_mention"}}"#,
+        );
+        assert_eq!(slack, Some(("slack", Some("app_mention".to_string()))));
+        let gitlab = detect_with(&[("x-gitlab-event", "Push Hook")], r#"{"object_kind": "push"}"#);
+        assert_eq!(gitlab, Some(("gitlab", Some("push".to_string()))));
+    }
+
+    #[test]
+    fn event_type_from_headers() {
+        let shopify = detect_with(
+            &[("x-shopify-hmac-sha256", "abc"), ("x-shopify-topic", "orders/create")],
+            "{}",
+        );
+        assert_eq!(shopify, Some(("shopify", Some("orders/create".to_string()))));
+        let gitlab = detect_with(&[("x-gitlab-event", "Push Hook")], "not json");
+        assert_eq!(gitlab, Some(("gitlab", Some("Push Hook".to_string()))));
+    }
+
+    #[test]
+    fn svix_wins_over_standard_webhooks() {
+        let headers = [("svix-id", "msg_1"), ("webhook-id", "msg_1"), ("webhook-signature", "v1,abc")];
+        assert_eq!(
+            detect_with(&headers, r#"{"type": "user.created"}"#),
+            Some(("svix", Some("user.created".to_string())))
+        );
+        assert_eq!(
+            detect_with(&headers[1..], r#"{"type": "user.created"}"#).map(|d| d.0),
+            Some("standard-webhooks")
+        );
+    }
+
+    #[test]
+    fn user_agent_and_body_fallbacks() {
+        assert_eq!(
+            detect_with(&[("user-agent", "GitHub-Hookshot/abc123")], "{}").map(|d| d.0),
+            Some("github")
+        );
+        assert_eq!(
+            detect_with(&[], r#"[{"sg_event_id": "x", "event": "delivered"}]"#),
+            Some(("sendgrid", Some("delivered".to_string())))
+        );
+    }
+
+    #[test]
+    fn unknown_senders() {
+        assert_eq!(detect_with(&[("user-agent", "curl/8.0")], r#"{"type": "x"}"#), None);
+        assert_eq!(detect_with(&[], ""), None);
+    }
+
+    #[test]
+    fn ids_round_trip() {
+        for provider in ALL {
+            assert_eq!(Provider::from_id(provider.id()), Some(*provider));
+        }
+        assert_eq!(Provider::from_id("nope"), None);
+    }
+}
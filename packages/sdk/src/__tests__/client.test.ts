@@ -1,6 +1,11 @@
 import { describe, it, expect, vi, beforeEach, afterEach } from "vitest";
 import { WebhooksCC } from "../client";
-import { WebhooksCCError, UnauthorizedError, NotFoundError } from "../errors";
+import {
+  WebhooksCCError,
+  UnauthorizedError,
+  NotFoundError,
+  StreamStalledError,
+} from "../errors";
 import { TEMPLATE_METADATA } from "../index";
 
 const API_KEY = "whcc_testkey123";
@@ -207,6 +212,55 @@ describe("WebhooksCC", () => {
     });
   });
 
+  describe("endpoints.create with demo", () => {
+    it("sends the demo config for ephemeral endpoints", async () => {
+      const fetchMock = mockFetch({ body: { id: "ep1", slug: "demo", createdAt: Date.now() } });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      await client.endpoints.create({
+        expiresIn: "1h",
+        demo: { provider: "github", perMinute: 12 },
+      });
+
+      const [, opts] = fetchMock.mock.calls[0];
+      const body = JSON.parse(opts.body);
+      expect(body.isEphemeral).toBe(true);
+      expect(body.demo).toEqual({ provider: "github", perMinute: 12 });
+    });
+
+    it("rejects demo mode on persistent endpoints", async () => {
+      const client = createClient();
+      await expect(client.endpoints.create({ demo: { provider: "stripe" } })).rejects.toThrow(
+        "demo requires ephemeral or expiresIn"
+      );
+    });
+  });
+
+  describe("endpoints.create with autoExtend", () => {
+    it("sends the windows in milliseconds", async () => {
+      const fetchMock = mockFetch({ body: { id: "ep1", slug: "live", createdAt: Date.now() } });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      await client.endpoints.create({ expiresIn: "1h", autoExtend: { idle: "10m", max: "2d" } });
+      await client.endpoints.create({ ephemeral: true, autoExtend: true });
+
+      expect(JSON.parse(fetchMock.mock.calls[0][1].body).autoExtend).toEqual({
+        idleMs: 600_000,
+        maxMs: 172_800_000,
+      });
+      expect(JSON.parse(fetchMock.mock.calls[1][1].body).autoExtend).toEqual({});
+    });
+
+    it("rejects autoExtend on persistent endpoints", async () => {
+      const client = createClient();
+      await expect(client.endpoints.create({ autoExtend: true })).rejects.toThrow(
+        "autoExtend requires ephemeral or expiresIn"
+      );
+    });
+  });
+
   describe("endpoints.update", () => {
     it("sends PATCH /api/endpoints/:slug with notificationUrl", async () => {
       const endpoint = {
@@ -262,6 +316,303 @@ describe("WebhooksCC", () => {
       expect(body.mockResponse).toBeDefined();
       expect(body).not.toHaveProperty("notificationUrl");
     });
+
+    it("sends recordResponses", async () => {
+      const endpoint = { id: "ep1", slug: "abc123", recordResponses: true, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { recordResponses: true });
+
+      expect(result.recordResponses).toBe(true);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ recordResponses: true });
+    });
+
+    it("sends canonicalJson", async () => {
+      const endpoint = { id: "ep1", slug: "abc123", canonicalJson: true, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { canonicalJson: true });
+
+      expect(result.canonicalJson).toBe(true);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ canonicalJson: true });
+    });
+
+    it("sends queryOverrides", async () => {
+      const endpoint = { id: "ep1", slug: "abc123", queryOverrides: true, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { queryOverrides: true });
+
+      expect(result.queryOverrides).toBe(true);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ queryOverrides: true });
+    });
+
+    it("sends autoHandshake", async () => {
+      const endpoint = { id: "ep1", slug: "abc123", autoHandshake: true, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { autoHandshake: true });
+
+      expect(result.autoHandshake).toBe(true);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ autoHandshake: true });
+    });
+
+    it("sends forwardUrl", async () => {
+      const forwardUrl = "https://api.example.com/webhooks";
+      const endpoint = { id: "ep1", slug: "abc123", forwardUrl, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { forwardUrl });
+
+      expect(result.forwardUrl).toBe(forwardUrl);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ forwardUrl });
+    });
+
+    it("sends rateLimit", async () => {
+      const rateLimit = { limit: 10, window: 60 };
+      const endpoint = { id: "ep1", slug: "abc123", rateLimit, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { rateLimit });
+
+      expect(result.rateLimit).toEqual(rateLimit);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ rateLimit });
+    });
+
+    it("sends priorityRule", async () => {
+      const priorityRule = { header: "x-priority", values: ["high"] };
+      const endpoint = { id: "ep1", slug: "abc123", priorityRule, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { priorityRule });
+
+      expect(result.priorityRule).toEqual(priorityRule);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ priorityRule });
+    });
+
+    it("sends clientCa", async () => {
+      const clientCa = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n";
+      const endpoint = { id: "ep1", slug: "abc123", clientCa, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { clientCa });
+
+      expect(result.clientCa).toBe(clientCa);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ clientCa });
+    });
+
+    it("sends signatureVerification", async () => {
+      const signatureVerification = { provider: "github" as const, secret: "s3cret", reject: true };
+      const endpoint = {
+        id: "ep1",
+        slug: "abc123",
+        signatureVerification: {
+          provider: "github",
+          algorithm: "sha256",
+          header: "x-hub-signature-256",
+          reject: true,
+        },
+        createdAt: Date.now(),
+      };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { signatureVerification });
+
+      expect(result.signatureVerification?.header).toBe("x-hub-signature-256");
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ signatureVerification });
+    });
+
+    it("sends jwtVerification", async () => {
+      const jwtVerification = { jwksUrl: "https://example.com/jwks.json", audience: "hooks" };
+      const endpoint = {
+        id: "ep1",
+        slug: "abc123",
+        jwtVerification: {
+          header: "authorization",
+          mode: "jwks",
+          jwksUrl: "https://example.com/jwks.json",
+          issuer: null,
+          audience: "hooks",
+          reject: false,
+        },
+        createdAt: Date.now(),
+      };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { jwtVerification });
+
+      expect(result.jwtVerification?.mode).toBe("jwks");
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ jwtVerification });
+    });
+
+    it("sends captureAuth", async () => {
+      const captureAuth = { type: "api_key" as const, key: "k_123" };
+      const endpoint = {
+        id: "ep1",
+        slug: "abc123",
+        captureAuth: { type: "api_key", username: null, header: "x-api-key" },
+        createdAt: Date.now(),
+      };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { captureAuth });
+
+      expect(result.captureAuth?.header).toBe("x-api-key");
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ captureAuth });
+    });
+
+    it("sends cors", async () => {
+      const cors = { origins: ["https://app.example.com"], credentials: true, maxAge: 600 };
+      const endpoint = { id: "ep1", slug: "abc123", cors, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { cors });
+
+      expect(result.cors?.credentials).toBe(true);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ cors });
+    });
+
+    it("sends captureFilter", async () => {
+      const captureFilter = { exclude: [{ header: { name: "x-event", value: "^ping$" } }] };
+      const endpoint = { id: "ep1", slug: "abc123", captureFilter, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { captureFilter });
+
+      expect(result.captureFilter?.exclude).toHaveLength(1);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ captureFilter });
+    });
+
+    it("sends sampling", async () => {
+      const sampling = { rate: 10, perMinute: 600 };
+      const endpoint = { id: "ep1", slug: "abc123", sampling, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { sampling });
+
+      expect(result.sampling?.rate).toBe(10);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ sampling });
+    });
+
+    it("sends chaos", async () => {
+      const chaos = { percent: 5, faults: ["error" as const, "reset" as const] };
+      const endpoint = { id: "ep1", slug: "abc123", chaos, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { chaos });
+
+      expect(result.chaos?.percent).toBe(5);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ chaos });
+    });
+
+    it("sends schemaValidation", async () => {
+      const schemaValidation = {
+        schema: { type: "object", required: ["id"] },
+        failureResponse: { status: 422 },
+      };
+      const endpoint = { id: "ep1", slug: "abc123", schemaValidation, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { schemaValidation });
+
+      expect(result.schemaValidation?.failureResponse?.status).toBe(422);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ schemaValidation });
+    });
+
+    it("sends redaction", async () => {
+      const redaction = { detectors: ["email" as const], paths: ["customer.card"] };
+      const endpoint = { id: "ep1", slug: "abc123", redaction, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", { redaction });
+
+      expect(result.redaction?.detectors).toEqual(["email"]);
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ redaction });
+    });
+
+    it("sends customDomain", async () => {
+      const endpoint = {
+        id: "ep1",
+        slug: "abc123",
+        customDomain: "hooks.example.com",
+        createdAt: Date.now(),
+      };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.update("abc123", {
+        customDomain: "hooks.example.com",
+      });
+
+      expect(result.customDomain).toBe("hooks.example.com");
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ customDomain: "hooks.example.com" });
+    });
+
+    it("sends maxBodySize, null included", async () => {
+      const fetchMock = mockFetch({
+        body: { id: "ep1", slug: "abc123", maxBodySize: null, createdAt: Date.now() },
+      });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      await client.endpoints.update("abc123", { maxBodySize: null });
+
+      const [, opts] = fetchMock.mock.calls[0];
+      expect(JSON.parse(opts.body)).toEqual({ maxBodySize: null });
+    });
   });
 
   describe("endpoints.list", () => {
@@ -304,6 +655,49 @@ describe("WebhooksCC", () => {
     });
   });
 
+  describe("teams", () => {
+    it("sends GET /api/teams and returns teams with roles", async () => {
+      const teams = [
+        {
+          id: "t1",
+          name: "Team A",
+          createdBy: "u1",
+          createdAt: Date.now(),
+          memberCount: 2,
+          role: "member",
+          suspended: false,
+        },
+      ];
+      const fetchMock = mockFetch({ body: teams });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.teams.list();
+
+      expect(result[0].role).toBe("member");
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/teams`);
+      expect(opts.method).toBe("GET");
+    });
+
+    it("sends GET /api/teams/:teamId/members", async () => {
+      const fetchMock = mockFetch({ body: { members: [], pendingInvites: [] } });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.teams.members("t1");
+
+      expect(result).toEqual({ members: [], pendingInvites: [] });
+      const [url] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/teams/t1/members`);
+    });
+
+    it("rejects team IDs with slashes", async () => {
+      const client = createClient();
+      await expect(client.teams.members("t1/../x")).rejects.toThrow("Invalid teamId");
+    });
+  });
+
   describe("templates", () => {
     it("lists supported template providers in a stable order", () => {
       const providers = createClient().templates.listProviders();
@@ -777,6 +1171,216 @@ describe("WebhooksCC", () => {
     });
   });
 
+  describe("endpoints.networkStats", () => {
+    it("sends GET /api/endpoints/{slug}/network-stats", async () => {
+      const stats = [
+        { rule: "blocked", matched: 12, lastMatchedAt: 1700000000000 },
+        { rule: "tag:aws", matched: 3, lastMatchedAt: 1700000000000 },
+      ];
+      const fetchMock = mockFetch({ body: stats });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.networkStats("abc123");
+
+      expect(result).toEqual(stats);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/network-stats`);
+      expect(opts.method).toBe("GET");
+    });
+  });
+
+  describe("endpoints.rejected", () => {
+    it("sends GET /api/endpoints/{slug}/rejected with limit and since", async () => {
+      const rejected = [
+        {
+          id: "r1",
+          reason: "denied",
+          method: "POST",
+          path: "/",
+          ip: "198.51.100.7",
+          country: "NL",
+          userAgent: "curl/8.5.0",
+          receivedAt: 1700000000000,
+        },
+      ];
+      const fetchMock = mockFetch({ body: rejected });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.rejected("abc123", {
+        limit: 20,
+        since: 1690000000000,
+      });
+
+      expect(result).toEqual(rejected);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/rejected?limit=20&since=1690000000000`);
+      expect(opts.method).toBe("GET");
+    });
+  });
+
+  describe("endpoints.dryRunStats", () => {
+    it("sends GET /api/endpoints/{slug}/dry-run-stats", async () => {
+      const stats = {
+        requests: 1200,
+        bytes: 2400000,
+        mocked: 1200,
+        firstRequestAt: 1700000000000,
+        lastRequestAt: 1700000600000,
+      };
+      const fetchMock = mockFetch({ body: stats });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.dryRunStats("abc123");
+
+      expect(result).toEqual(stats);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/dry-run-stats`);
+      expect(opts.method).toBe("GET");
+    });
+  });
+
+  describe("endpoints.samplingStats", () => {
+    it("sends GET /api/endpoints/{slug}/sampling-stats", async () => {
+      const stats = {
+        requests: 50000,
+        bytes: 100000000,
+        sampledOut: 45000,
+        sampledOutBytes: 90000000,
+        firstRequestAt: 1700000000000,
+        lastRequestAt: 1700000600000,
+      };
+      const fetchMock = mockFetch({ body: stats });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.samplingStats("abc123");
+
+      expect(result).toEqual(stats);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/sampling-stats`);
+      expect(opts.method).toBe("GET");
+    });
+  });
+
+  describe("endpoints.sinkLatency", () => {
+    it("sends GET /api/endpoints/{slug}/sink-latency with the window", async () => {
+      const latency = { window: "1h", since: 1700000000000, destinations: [] };
+      const fetchMock = mockFetch({ body: latency });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.sinkLatency("abc123", { window: "1h" });
+
+      expect(result).toEqual(latency);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/sink-latency?window=1h`);
+      expect(opts.method).toBe("GET");
+    });
+  });
+
+  describe("endpoints.report", () => {
+    it("sends GET /api/endpoints/{slug}/report with the month", async () => {
+      const report = { slug: "abc123", month: "2024-06", requests: 12, days: [] };
+      const fetchMock = mockFetch({ body: report });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.report("abc123", "2024-06");
+
+      expect(result).toEqual(report);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/report?month=2024-06`);
+      expect(opts.method).toBe("GET");
+    });
+
+    it("rejects months not in YYYY-MM form", async () => {
+      const client = createClient();
+      await expect(client.endpoints.report("abc123", "June")).rejects.toThrow("Invalid month");
+    });
+  });
+
+  describe("endpoints mock canary", () => {
+    it("sends PUT /api/endpoints/{slug}/mock-canary with the response and percent", async () => {
+      const response = { status: 202, body: "v2", headers: {} };
+      const endpoint = {
+        id: "ep1",
+        slug: "abc123",
+        mockCanary: { response, percent: 10, startedAt: 1700000000000 },
+        createdAt: Date.now(),
+      };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.startMockCanary("abc123", { response, percent: 10 });
+
+      expect(result.mockCanary?.percent).toBe(10);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/mock-canary`);
+      expect(opts.method).toBe("PUT");
+      expect(JSON.parse(opts.body)).toEqual({ response, percent: 10 });
+    });
+
+    it("promotes with POST and rolls back with DELETE", async () => {
+      const endpoint = { id: "ep1", slug: "abc123", mockCanary: null, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      await client.endpoints.promoteMockCanary("abc123");
+      await client.endpoints.rollBackMockCanary("abc123");
+
+      const [promoteUrl, promoteOpts] = fetchMock.mock.calls[0];
+      expect(promoteUrl).toBe(`${BASE_URL}/api/endpoints/abc123/mock-canary/promote`);
+      expect(promoteOpts.method).toBe("POST");
+      const [rollbackUrl, rollbackOpts] = fetchMock.mock.calls[1];
+      expect(rollbackUrl).toBe(`${BASE_URL}/api/endpoints/abc123/mock-canary`);
+      expect(rollbackOpts.method).toBe("DELETE");
+    });
+  });
+
+  describe("endpoints.pause / resume", () => {
+    it("sends POST /api/endpoints/{slug}/pause with the reply", async () => {
+      const endpoint = {
+        id: "ep1",
+        slug: "abc123",
+        pausedAt: 1700000000000,
+        pausedResponse: { status: 202, body: "maintenance" },
+        createdAt: Date.now(),
+      };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.pause("abc123", {
+        response: { status: 202, body: "maintenance" },
+      });
+
+      expect(result.pausedAt).toBe(1700000000000);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/pause`);
+      expect(opts.method).toBe("POST");
+      expect(JSON.parse(opts.body)).toEqual({ response: { status: 202, body: "maintenance" } });
+    });
+
+    it("sends POST /api/endpoints/{slug}/resume", async () => {
+      const endpoint = { id: "ep1", slug: "abc123", pausedAt: null, createdAt: Date.now() };
+      const fetchMock = mockFetch({ body: endpoint });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.endpoints.resume("abc123");
+
+      expect(result.pausedAt).toBeNull();
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/resume`);
+      expect(opts.method).toBe("POST");
+    });
+  });
+
   describe("endpoints.delete", () => {
     it("sends DELETE /api/endpoints/{slug}", async () => {
       const fetchMock = mockFetch({
@@ -942,6 +1546,42 @@ describe("WebhooksCC", () => {
       expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/requests?limit=10&since=1000`);
     });
 
+    it("filters by tag and CloudEvents type", async () => {
+      const fetchMock = mockFetch({ body: [] });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      await client.requests.list("abc123", { tag: "incident", eventType: "com.example.ping" });
+
+      const [url] = fetchMock.mock.calls[0];
+      expect(url).toBe(
+        `${BASE_URL}/api/endpoints/abc123/requests?tag=incident&eventType=com.example.ping`
+      );
+    });
+
+    it("filters by country and autonomous system", async () => {
+      const fetchMock = mockFetch({ body: [] });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      await client.requests.list("abc123", { country: "DE", asn: 13335 });
+
+      const [url] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/endpoints/abc123/requests?country=DE&asn=13335`);
+    });
+
+    it("asks for encrypted headers masked only when reveal is false", async () => {
+      const fetchMock = mockFetch({ body: [] });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      await client.requests.list("abc123", { reveal: false });
+      await client.requests.list("abc123", { reveal: true });
+
+      expect(fetchMock.mock.calls[0][0]).toBe(`${BASE_URL}/api/endpoints/abc123/requests?reveal=false`);
+      expect(fetchMock.mock.calls[1][0]).toBe(`${BASE_URL}/api/endpoints/abc123/requests`);
+    });
+
     it("sends GET without query params when none provided", async () => {
       const fetchMock = mockFetch({ body: [] });
       globalThis.fetch = fetchMock;
@@ -976,6 +1616,31 @@ describe("WebhooksCC", () => {
     });
   });
 
+  describe("apiUsage", () => {
+    it("sends GET /api/usage/api", async () => {
+      const meter = { used: 3, limit: 100, remaining: 97 };
+      const usage = {
+        plan: "free" as const,
+        day: "2026-03-14",
+        resetsAt: 1773532800000,
+        apiCalls: meter,
+        streamMinutes: meter,
+        replays: meter,
+        exports: meter,
+      };
+      const fetchMock = mockFetch({ body: usage });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.apiUsage();
+
+      expect(result).toEqual(usage);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/usage/api`);
+      expect(opts.method).toBe("GET");
+    });
+  });
+
   describe("requests.listPaginated", () => {
     it("sends GET /api/endpoints/{slug}/requests/paginated with cursor params", async () => {
       const page = {
@@ -1015,6 +1680,63 @@ describe("WebhooksCC", () => {
     });
   });
 
+  describe("requests.annotate", () => {
+    it("sends PATCH /api/requests/{id} with the note and tags", async () => {
+      const request = { id: "r1", method: "POST", note: "broke prod", tags: ["incident"] };
+      const fetchMock = mockFetch({ body: request });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.requests.annotate("r1", {
+        note: "broke prod",
+        tags: ["incident"],
+      });
+
+      expect(result).toEqual(request);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/requests/r1`);
+      expect(opts.method).toBe("PATCH");
+      expect(JSON.parse(opts.body)).toEqual({ note: "broke prod", tags: ["incident"] });
+    });
+  });
+
+  describe("requests.bodyUrl", () => {
+    it("sends GET /api/requests/{id}/body", async () => {
+      const body = { url: "https://bucket.example/bodies/ab", expiresAt: 1000, size: 2_000_000 };
+      const fetchMock = mockFetch({ body });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      const result = await client.requests.bodyUrl("r1");
+
+      expect(result).toEqual(body);
+      const [url, opts] = fetchMock.mock.calls[0];
+      expect(url).toBe(`${BASE_URL}/api/requests/r1/body`);
+      expect(opts.method).toBe("GET");
+    });
+  });
+
+  describe("requests.replay", () => {
+    it("refuses WebSocket messages", async () => {
+      const captured = {
+        id: "r1",
+        method: "WS",
+        path: "/",
+        headers: {},
+        body: "ping",
+        frame: { connection: "9f2c4a1be0d37a55", seq: 1, opcode: "text" },
+      };
+      const fetchMock = mockFetch({ body: captured });
+      globalThis.fetch = fetchMock;
+
+      const client = createClient();
+      await expect(client.requests.replay("r1", "http://localhost:3000")).rejects.toThrow(
+        "WebSocket message"
+      );
+      expect(fetchMock).toHaveBeenCalledTimes(1);
+    });
+  });
+
   describe("requests.waitForAll", () => {
     it("collects multiple matching requests in chronological order", async () => {
       const req1 = { id: "r1", method: "POST", receivedAt: 1000 };
@@ -1077,6 +1799,7 @@ describe("WebhooksCC", () => {
         limit: 25,
         offset: 5,
         order: "asc",
+        contentClass: "json",
       });
 
       expect(result).toEqual(results);
@@ -1092,6 +1815,7 @@ describe("WebhooksCC", () => {
       expect(url.searchParams.get("limit")).toBe("25");
       expect(url.searchParams.get("offset")).toBe("5");
       expect(url.searchParams.get("order")).toBe("asc");
+      expect(url.searchParams.get("contentClass")).toBe("json");
       expect(opts.method).toBe("GET");
     });
 
@@ -1220,6 +1944,68 @@ describe("WebhooksCC", () => {
       expect(result[0]).toContain("https://go.test.webhooks.cc/w/abc123/webhook?foo=bar");
     });
 
+    it("exports captured requests as CSV with selected header columns", async () => {
+      globalThis.fetch = vi
+        .fn()
+        .mockResolvedValueOnce({
+          ok: true,
+          status: 200,
+          headers: new Headers({ "content-type": "application/json" }),
+          json: () =>
+            Promise.resolve({
+              id: "ep1",
+              slug: "abc123",
+              url: "https://go.test.webhooks.cc/w/abc123",
+              createdAt: Date.now(),
+            }),
+          text: () =>
+            Promise.resolve(
+              '{"id":"ep1","slug":"abc123","url":"https://go.test.webhooks.cc/w/abc123","createdAt":0}'
+            ),
+        })
+        .mockResolvedValueOnce({
+          ok: true,
+          status: 200,
+          headers: new Headers({ "content-type": "application/json" }),
+          json: () =>
+            Promise.resolve({
+              items: [
+                {
+                  id: "r1",
+                  endpointId: "ep1",
+                  method: "POST",
+                  path: "/webhook",
+                  headers: {
+                    host: "localhost",
+                    "content-type": "application/json",
+                    authorization: "Bearer secret",
+                    "x-test": "yes",
+                  },
+                  body: '{"ok":true}',
+                  queryParams: { foo: "bar" },
+                  contentType: "application/json",
+                  ip: "127.0.0.1",
+                  size: 11,
+                  receivedAt: 1000,
+                },
+              ],
+              hasMore: false,
+            }),
+          text: () => Promise.resolve("[]"),
+        });
+
+      const result = await createClient().requests.export("abc123", {
+        format: "csv",
+        headers: ["X-Test"],
+      });
+
+      expect(result.split("\r\n")).toEqual([
+        "id,received_at,method,path,query,content_type,size,ip,header.x-test,body,body_base64",
+        'r1,1970-01-01T00:00:01.000Z,POST,/webhook,foo=bar,application/json,11,127.0.0.1,yes,"{""ok"":true}",',
+        "",
+      ]);
+    });
+
     it("exports captured requests as HAR", async () => {
       globalThis.fetch = vi
         .fn()
@@ -1284,6 +2070,8 @@ describe("WebhooksCC", () => {
 
       expect(description.version).toBe("1.2.1");
       expect(description.usage).toBeDefined();
+      expect(description.teams.list).toBeDefined();
+      expect(description.teams.members.params.teamId).toBe("string");
       expect(description.flow).toBeDefined();
       expect(description.templates.listProviders).toBeDefined();
       expect(description.templates.get).toBeDefined();
@@ -1295,7 +2083,7 @@ describe("WebhooksCC", () => {
       expect(description.requests.count).toBeDefined();
       expect(description.requests.clear).toBeDefined();
       expect(description.requests.subscribe.params.reconnect).toBe("boolean?");
-      expect(Object.keys(description.requests)).toHaveLength(11);
+      expect(Object.keys(description.requests)).toHaveLength(12);
     });
   });
 
@@ -1360,6 +2148,95 @@ describe("WebhooksCC", () => {
         })
       );
     });
+
+    it("requests the keepalive interval from the server", async () => {
+      globalThis.fetch = vi
+        .fn()
+        .mockResolvedValueOnce(mockSSEStream("event: endpoint_deleted\ndata: {}\n\n"));
+
+      const iterator = createClient()
+        .requests.subscribe("abc123", { keepaliveInterval: "10s" })
+        [Symbol.asyncIterator]();
+      await iterator.next();
+
+      expect(globalThis.fetch).toHaveBeenCalledWith(
+        `${BASE_URL}/api/stream/abc123?keepalive=10`,
+        expect.anything()
+      );
+    });
+
+    it("starts from the since timestamp", async () => {
+      globalThis.fetch = vi
+        .fn()
+        .mockResolvedValueOnce(mockSSEStream("event: endpoint_deleted\ndata: {}\n\n"));
+
+      const iterator = createClient()
+        .requests.subscribe("abc123", { since: 1_700_000_000_000 })
+        [Symbol.asyncIterator]();
+      await iterator.next();
+
+      expect(globalThis.fetch).toHaveBeenCalledWith(
+        `${BASE_URL}/api/stream/abc123?since=1700000000000`,
+        expect.anything()
+      );
+    });
+
+    it("sends stream filters as query parameters", async () => {
+      const fetchMock = vi
+        .fn()
+        .mockResolvedValueOnce(mockSSEStream("event: endpoint_deleted\ndata: {}\n\n"));
+      globalThis.fetch = fetchMock;
+
+      const iterator = createClient()
+        .requests.subscribe("abc123", {
+          filter: {
+            method: ["POST", "PUT"],
+            path: "/orders/**",
+            headers: { "x-event-type": "order.created", "x-signature": true },
+          },
+        })
+        [Symbol.asyncIterator]();
+      await iterator.next();
+
+      const url = new URL(fetchMock.mock.calls[0][0] as string);
+      expect(url.pathname).toBe("/api/stream/abc123");
+      expect(url.searchParams.get("method")).toBe("POST,PUT");
+      expect(url.searchParams.get("path")).toBe("/orders/**");
+      expect(url.searchParams.getAll("header")).toEqual([
+        "x-event-type:order.created",
+        "x-signature",
+      ]);
+    });
+
+    it("rejects a read timeout that does not exceed the keepalive interval", () => {
+      const client = createClient();
+      expect(() =>
+        client.requests.subscribe("abc123", { keepaliveInterval: "10s", readTimeout: "5s" })
+      ).toThrow("Invalid readTimeout");
+      expect(() => client.requests.subscribe("abc123", { keepaliveInterval: "1s" })).toThrow(
+        "Invalid keepaliveInterval"
+      );
+    });
+
+    it("throws StreamStalledError when nothing arrives before the read timeout", async () => {
+      vi.useFakeTimers();
+      const silent = new ReadableStream<Uint8Array>({ start() {} });
+      globalThis.fetch = vi.fn().mockResolvedValueOnce({
+        ok: true,
+        status: 200,
+        headers: new Headers({ "content-type": "text/event-stream" }),
+        body: silent,
+        text: () => Promise.resolve(""),
+      });
+
+      const iterator = createClient()
+        .requests.subscribe("abc123", { keepaliveInterval: "5s", readTimeout: "15s" })
+        [Symbol.asyncIterator]();
+      const next = iterator.next();
+      const assertion = expect(next).rejects.toBeInstanceOf(StreamStalledError);
+      await vi.advanceTimersByTimeAsync(15_000);
+      await assertion;
+    });
   });
 
   describe("requests.waitFor", () => {
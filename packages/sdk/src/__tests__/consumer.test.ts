@@ -0,0 +1,182 @@
+import { mkdtemp, readFile, rm } from "fs/promises";
+import { tmpdir } from "os";
+import { join } from "path";
+import { afterEach, beforeEach, describe, expect, it, vi } from "vitest";
+import { consume } from "../consumer";
+import { NotFoundError } from "../errors";
+import type { Request, SubscribeOptions } from "../types";
+
+function request(id: string, receivedAt: number): Request {
+  return {
+    id,
+    endpointId: "ep1",
+    method: "POST",
+    path: "/hook",
+    headers: { "content-type": "application/json" },
+    body: `{"id":"${id}"}`,
+    queryParams: {},
+    contentType: "application/json",
+    ip: "127.0.0.1",
+    size: 12,
+    receivedAt,
+  };
+}
+
+// A subscribe() that yields `requests`, then stays open until aborted
+function streamOf(requests: Request[]) {
+  return vi.fn((_slug: string, options: SubscribeOptions = {}) => ({
+    async *[Symbol.asyncIterator]() {
+      yield* requests;
+      const signal = options.signal;
+      if (signal && !signal.aborted) {
+        await new Promise((resolve) => signal.addEventListener("abort", resolve, { once: true }));
+      }
+    },
+  }));
+}
+
+let dir: string;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "whk-consumer-"));
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("consume", () => {
+  it("hands requests over in order and resumes from the checkpoint file", async () => {
+    const checkpointFile = join(dir, "checkpoint.json");
+    const handled: string[] = [];
+
+    const first = new AbortController();
+    const subscribe = streamOf([request("r1", 100_000), request("r2", 100_001)]);
+    await consume(
+      { requests: { subscribe } } as never,
+      "abc123",
+      (req) => {
+        handled.push(req.id);
+        if (req.id === "r2") first.abort();
+      },
+      { checkpointFile, since: 10_000, signal: first.signal, handleSignals: false }
+    ).done;
+
+    expect(handled).toEqual(["r1", "r2"]);
+    expect(subscribe).toHaveBeenCalledWith("abc123", expect.objectContaining({ since: 9_999 }));
+    const saved = JSON.parse(await readFile(checkpointFile, "utf8"));
+    expect(saved).toMatchObject({ slug: "abc123", receivedAt: 100_001 });
+    expect(Object.keys(saved.handled)).toEqual(["r1", "r2"]);
+
+    const second = new AbortController();
+    const resubscribe = streamOf([
+      request("r1", 100_000),
+      request("r2", 100_001),
+      request("r3", 100_002),
+    ]);
+    await consume(
+      { requests: { subscribe: resubscribe } } as never,
+      "abc123",
+      (req) => {
+        handled.push(req.id);
+        second.abort();
+      },
+      { checkpointFile, signal: second.signal, handleSignals: false }
+    ).done;
+
+    expect(handled).toEqual(["r1", "r2", "r3"]);
+    expect(resubscribe).toHaveBeenCalledWith(
+      "abc123",
+      expect.objectContaining({ since: 100_001 - 60_000 - 1 })
+    );
+  });
+
+  it("retries a failing handler before moving on", async () => {
+    const controller = new AbortController();
+    const onError = vi.fn();
+    const failure = new Error("database unavailable");
+    const attempts: string[] = [];
+
+    await consume(
+      { requests: { subscribe: streamOf([request("r1", 1000), request("r2", 1001)]) } } as never,
+      "abc123",
+      (req) => {
+        attempts.push(req.id);
+        if (attempts.length === 1) throw failure;
+        if (req.id === "r2") controller.abort();
+      },
+      { since: 0, retryBackoff: 0, signal: controller.signal, handleSignals: false, onError }
+    ).done;
+
+    expect(attempts).toEqual(["r1", "r1", "r2"]);
+    expect(onError).toHaveBeenCalledWith(failure, expect.objectContaining({ id: "r1" }));
+  });
+
+  it("pages back to the checkpoint when polling", async () => {
+    const controller = new AbortController();
+    const listPaginated = vi
+      .fn()
+      .mockResolvedValueOnce({
+        items: [request("r3", 3000), request("r2", 2000)],
+        cursor: "c1",
+        hasMore: true,
+      })
+      .mockResolvedValueOnce({ items: [request("r1", 1000)], hasMore: false });
+    const handled: string[] = [];
+
+    await consume(
+      { requests: { listPaginated } } as never,
+      "abc123",
+      (req) => {
+        handled.push(req.id);
+        if (req.id === "r3") controller.abort();
+      },
+      { mode: "poll", since: 1500, signal: controller.signal, handleSignals: false }
+    ).done;
+
+    expect(handled).toEqual(["r2", "r3"]);
+    expect(listPaginated).toHaveBeenNthCalledWith(1, "abc123", { limit: 100, cursor: undefined });
+    expect(listPaginated).toHaveBeenNthCalledWith(2, "abc123", { limit: 100, cursor: "c1" });
+  });
+
+  it("lets the request being handled finish on stop", async () => {
+    let started!: () => void;
+    const handlerStarted = new Promise<void>((resolve) => (started = resolve));
+    let release!: () => void;
+    const handled: string[] = [];
+    const consumer = consume(
+      { requests: { subscribe: streamOf([request("r1", 1000), request("r2", 1001)]) } } as never,
+      "abc123",
+      async (req) => {
+        started();
+        await new Promise<void>((resolve) => (release = resolve));
+        handled.push(req.id);
+      },
+      { since: 0, handleSignals: false }
+    );
+
+    await handlerStarted;
+    const stopped = consumer.stop();
+    release();
+    await stopped;
+
+    expect(handled).toEqual(["r1"]);
+  });
+
+  it("stops with the error when the endpoint is gone", async () => {
+    const subscribe = vi.fn(() => ({
+      async *[Symbol.asyncIterator]() {
+        throw new NotFoundError("Endpoint not found");
+      },
+    }));
+    const onError = vi.fn();
+
+    await expect(
+      consume({ requests: { subscribe } } as never, "abc123", () => undefined, {
+        handleSignals: false,
+        onError,
+      }).done
+    ).rejects.toBeInstanceOf(NotFoundError);
+    expect(onError).not.toHaveBeenCalled();
+  });
+});
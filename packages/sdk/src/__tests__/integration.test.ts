@@ -545,9 +545,9 @@ describe.skipIf(!API_KEY)("SDK integration tests", () => {
     expect(description.usage).toBeDefined();
     expect(description.flow).toBeDefined();
     expect(description.requests).toBeDefined();
-    expect(Object.keys(description.endpoints).length).toBe(7);
+    expect(Object.keys(description.endpoints).length).toBe(9);
     expect(Object.keys(description.templates).length).toBe(2);
-    expect(Object.keys(description.requests).length).toBe(11);
+    expect(Object.keys(description.requests).length).toBe(12);
 
     // Verify structure
     const createOp = description.endpoints.create;
@@ -704,10 +704,12 @@ describe.skipIf(!API_KEY)("Live SDK tests", () => {
       expect(desc.endpoints.list).toBeDefined();
       expect(desc.endpoints.get).toBeDefined();
       expect(desc.endpoints.update).toBeDefined();
+      expect(desc.endpoints.pause).toBeDefined();
+      expect(desc.endpoints.resume).toBeDefined();
       expect(desc.endpoints.delete).toBeDefined();
       expect(desc.endpoints.send).toBeDefined();
       expect(desc.endpoints.sendTemplate).toBeDefined();
-      expect(Object.keys(desc.endpoints)).toHaveLength(7);
+      expect(Object.keys(desc.endpoints)).toHaveLength(9);
 
       // Template metadata operations
       expect(desc.templates.listProviders).toBeDefined();
@@ -730,7 +732,7 @@ describe.skipIf(!API_KEY)("Live SDK tests", () => {
       expect(desc.requests.search).toBeDefined();
       expect(desc.requests.count).toBeDefined();
       expect(desc.requests.clear).toBeDefined();
-      expect(Object.keys(desc.requests)).toHaveLength(11);
+      expect(Object.keys(desc.requests)).toHaveLength(12);
 
       // Each operation has description and params
       for (const op of Object.values(desc.endpoints)) {
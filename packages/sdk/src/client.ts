@@ -19,8 +19,21 @@ import type {
   Endpoint,
   Request,
   UsageInfo,
+  ApiUsage,
+  Team,
+  TeamMembers,
   CreateEndpointOptions,
   UpdateEndpointOptions,
+  PauseEndpointOptions,
+  NetworkMatch,
+  RejectedRequest,
+  ListRejectedOptions,
+  DryRunStats,
+  SamplingStats,
+  MockCanaryStatus,
+  StartMockCanaryOptions,
+  SinkLatency,
+  UsageReport,
   SendOptions,
   SendTemplateOptions,
   SendToOptions,
@@ -28,15 +41,20 @@ import type {
   TemplateProviderInfo,
   ListRequestsOptions,
   ListPaginatedRequestsOptions,
+  AnnotateRequestOptions,
+  RequestBodyUrl,
+  ResponseOverride,
   PaginatedResult,
   ClearRequestsOptions,
   ExportRequestsOptions,
-  RequestsExport,
+  ExportFormat,
+  RequestsExportFor,
   SearchFilters,
   SearchResult,
   WaitForOptions,
   WaitForAllOptions,
   SubscribeOptions,
+  StreamFilter,
   RetryOptions,
   SDKDescription,
 } from "./types";
@@ -46,12 +64,13 @@ import {
   NotFoundError,
   RateLimitError,
   TimeoutError,
+  StreamStalledError,
 } from "./errors";
 import type { RateLimitMeta } from "./errors";
 import { parseDuration } from "./utils";
 import { parseSSE } from "./sse";
 import { buildTemplateSendOptions, TEMPLATE_METADATA, TEMPLATE_PROVIDERS } from "./templates";
-import { buildCurlExport, buildHarExport } from "./request-export";
+import { buildCsvExport, buildCurlExport, buildHarExport } from "./request-export";
 import { WebhookFlowBuilder } from "./flow";
 import { validateMockResponse } from "./validation";
 
@@ -65,6 +84,9 @@ declare const PKG_VERSION: string | undefined;
 const SDK_VERSION = typeof PKG_VERSION !== "undefined" ? PKG_VERSION : "0.0.0-dev";
 const WAIT_FOR_LOOKBACK_MS = 5 * 60 * 1000;
 const DEFAULT_EXPORT_PAGE_SIZE = 100;
+const STREAM_KEEPALIVE_DEFAULT_MS = 30_000;
+const STREAM_KEEPALIVE_MIN_MS = 5_000;
+const STREAM_KEEPALIVE_MAX_MS = 120_000;
 const PROVIDER_PARAM_DESCRIPTION = TEMPLATE_PROVIDERS.map((provider) => `"${provider}"`).join("|");
 
 // Poll interval bounds: 10ms minimum prevents busy loops, 60s maximum prevents stale connections
@@ -161,6 +183,7 @@ function mapStatusToError(status: number, message: string, response: Response):
 
 // Validates path segments to prevent traversal attacks (e.g., "../admin")
 const SAFE_PATH_SEGMENT_REGEX = /^[a-zA-Z0-9_-]+$/;
+const MONTH_REGEX = /^\d{4}-(0[1-9]|1[0-2])$/;
 
 /**
  * Validates that a URL path segment contains only safe characters.
@@ -212,6 +235,9 @@ function buildSearchQuery(filters: SearchFilters, includePagination: boolean): s
   if (filters.to !== undefined) {
     params.set("to", String(resolveTimestampFilter(filters.to, now)));
   }
+  if (filters.contentClass !== undefined) {
+    params.set("contentClass", filters.contentClass);
+  }
   if (includePagination && filters.limit !== undefined) {
     params.set("limit", String(filters.limit));
   }
@@ -243,6 +269,21 @@ function buildPaginatedListQuery(options: ListPaginatedRequestsOptions = {}): st
   if (options.cursor !== undefined) {
     params.set("cursor", options.cursor);
   }
+  if (options.tag !== undefined) {
+    params.set("tag", options.tag);
+  }
+  if (options.eventType !== undefined) {
+    params.set("eventType", options.eventType);
+  }
+  if (options.country !== undefined) {
+    params.set("country", options.country);
+  }
+  if (options.asn !== undefined) {
+    params.set("asn", String(options.asn));
+  }
+  if (options.reveal === false) {
+    params.set("reveal", "false");
+  }
   const query = params.toString();
   return query ? `?${query}` : "";
 }
@@ -282,11 +323,31 @@ function normalizeExportPageSize(limit: number | undefined): number {
   return Math.max(1, Math.min(DEFAULT_EXPORT_PAGE_SIZE, Math.floor(limit)));
 }
 
-function buildStreamPath(slug: string, since?: number): string {
+function buildStreamPath(
+  slug: string,
+  since?: number,
+  keepaliveMs?: number,
+  filter?: StreamFilter
+): string {
   const params = new URLSearchParams();
   if (since !== undefined) {
     params.set("since", String(Math.max(0, Math.floor(since))));
   }
+  if (keepaliveMs !== undefined) {
+    params.set("keepalive", String(keepaliveMs / 1000));
+  }
+  if (filter?.method !== undefined) {
+    const methods = Array.isArray(filter.method) ? filter.method : [filter.method];
+    if (methods.length > 0) {
+      params.set("method", methods.join(","));
+    }
+  }
+  if (filter?.path !== undefined) {
+    params.set("path", filter.path);
+  }
+  for (const [name, value] of Object.entries(filter?.headers ?? {})) {
+    params.append("header", value === true ? name : `${name}:${value}`);
+  }
   const query = params.toString();
   return `/api/stream/${slug}${query ? `?${query}` : ""}`;
 }
@@ -303,6 +364,10 @@ function shouldReconnectStreamError(error: unknown): boolean {
     return false;
   }
 
+  if (error instanceof StreamStalledError) {
+    return true;
+  }
+
   if (error instanceof WebhooksCCError) {
     return error.statusCode === 429 || error.statusCode >= 500;
   }
@@ -343,6 +408,24 @@ function parseStreamRequest(data: string): Request | null {
       ip: typeof parsed.ip === "string" ? parsed.ip : "unknown",
       size: typeof parsed.size === "number" ? parsed.size : 0,
       receivedAt: parsed.receivedAt,
+      bodyHash: typeof parsed.bodyHash === "string" ? parsed.bodyHash : undefined,
+      duplicateOf: typeof parsed.duplicateOf === "string" ? parsed.duplicateOf : undefined,
+      mockVariant: typeof parsed.mockVariant === "string" ? parsed.mockVariant : undefined,
+      chaosFault:
+        parsed.chaosFault === "error" ||
+        parsed.chaosFault === "hang" ||
+        parsed.chaosFault === "reset"
+          ? parsed.chaosFault
+          : undefined,
+      responseOverride:
+        typeof parsed.responseOverride === "object" && parsed.responseOverride !== null
+          ? (parsed.responseOverride as ResponseOverride)
+          : undefined,
+      parts: Array.isArray(parsed.parts) ? parsed.parts : undefined,
+      note: typeof parsed.note === "string" ? parsed.note : undefined,
+      tags: Array.isArray(parsed.tags)
+        ? parsed.tags.filter((tag): tag is string => typeof tag === "string")
+        : undefined,
     };
   } catch {
     return null;
@@ -567,10 +650,13 @@ export class WebhooksCC {
           description: "Create a webhook endpoint",
           params: {
             name: "string?",
+            slug: "string?",
             ephemeral: "boolean?",
             expiresIn: "number|string?",
             mockResponse: "object?",
             notificationUrl: "string?",
+            demo: "object?",
+            autoExtend: "boolean|object?",
           },
         },
         list: {
@@ -588,8 +674,81 @@ export class WebhooksCC {
             name: "string?",
             mockResponse: "object?",
             notificationUrl: "string?",
+            functionSink: "object?",
+            bodyTransforms: "array?",
+            infoHeaders: "boolean?",
+            recordResponses: "boolean?",
+            canonicalJson: "boolean?",
+            dryRun: "boolean?",
+            queryOverrides: "boolean?",
+            autoHandshake: "boolean?",
+            forwardUrl: "string?",
+            priorityRule: "object?",
+            encryptedHeaders: "array?",
+            networkPolicy: "object?",
+            clientCa: "string?",
+            signatureVerification: "object?",
+            jwtVerification: "object?",
+            captureAuth: "object?",
+            cors: "object?",
+            captureFilter: "object?",
+            sampling: "object?",
+            chaos: "object?",
+            rateLimit: "object?",
+            schemaValidation: "object?",
+            redaction: "object?",
+            customDomain: "string?",
+            maxBodySize: "number?",
           },
         },
+        networkStats: {
+          description: "Match counts for the endpoint's network policy rules",
+          params: { slug: "string" },
+        },
+        rejected: {
+          description: "Requests the network policy rejected, when it captures them",
+          params: { slug: "string", limit: "number?", since: "number?" },
+        },
+        dryRunStats: {
+          description: "Counters kept while the endpoint is in dry-run mode",
+          params: { slug: "string" },
+        },
+        samplingStats: {
+          description: "Requests received and sampled out while the endpoint samples its traffic",
+          params: { slug: "string" },
+        },
+        mockCanary: {
+          description: "Running mock canary with requests and senders per version",
+          params: { slug: "string" },
+        },
+        startMockCanary: {
+          description: "Serve a changed mock response to a share of senders first",
+          params: { slug: "string", response: "object", percent: "number" },
+        },
+        promoteMockCanary: {
+          description: "Make the canary's response the mock for every sender",
+          params: { slug: "string" },
+        },
+        rollBackMockCanary: {
+          description: "Stop the canary; every sender gets the current mock again",
+          params: { slug: "string" },
+        },
+        sinkLatency: {
+          description: "Function sink delivery latency percentiles per destination",
+          params: { slug: "string", window: '"1h"|"24h"|"7d"?' },
+        },
+        report: {
+          description: "Usage report for one calendar month (YYYY-MM, UTC)",
+          params: { slug: "string", month: "string" },
+        },
+        pause: {
+          description: "Stop capturing until resumed; requests get the paused reply",
+          params: { slug: "string", response: "object?" },
+        },
+        resume: {
+          description: "Resume capturing on a paused endpoint",
+          params: { slug: "string" },
+        },
         delete: {
           description: "Delete endpoint and its requests",
           params: { slug: "string" },
@@ -621,10 +780,24 @@ export class WebhooksCC {
           },
         },
       },
+      teams: {
+        list: {
+          description: "List teams you belong to, with your role in each",
+          params: {},
+        },
+        members: {
+          description: "List members and pending invites of a team",
+          params: { teamId: "string" },
+        },
+      },
       usage: {
         description: "Get current request usage and remaining quota",
         params: {},
       },
+      apiUsage: {
+        description: "Get today's API calls, stream minutes, replays and exports against limits",
+        params: {},
+      },
       flow: {
         description: "Create a fluent webhook flow builder for common capture/verify/replay flows",
         params: {},
@@ -653,16 +826,38 @@ export class WebhooksCC {
       requests: {
         list: {
           description: "List captured requests",
-          params: { endpointSlug: "string", limit: "number?", since: "number?" },
+          params: {
+            endpointSlug: "string",
+            limit: "number?",
+            since: "number?",
+            tag: "string?",
+            country: "string?",
+            asn: "number?",
+          },
         },
         listPaginated: {
           description: "List captured requests with cursor-based pagination",
-          params: { endpointSlug: "string", limit: "number?", cursor: "string?" },
+          params: {
+            endpointSlug: "string",
+            limit: "number?",
+            cursor: "string?",
+            tag: "string?",
+            country: "string?",
+            asn: "number?",
+          },
         },
         get: {
           description: "Get request by ID",
           params: { requestId: "string" },
         },
+        annotate: {
+          description: "Attach a note and tags to a captured request",
+          params: { requestId: "string", note: "string|null?", tags: "string[]?" },
+        },
+        bodyUrl: {
+          description: "Get a download URL for a body kept in object storage",
+          params: { requestId: "string" },
+        },
         waitForAll: {
           description: "Poll until multiple matching requests arrive",
           params: {
@@ -691,6 +886,10 @@ export class WebhooksCC {
             maxReconnectAttempts: "number?",
             reconnectBackoffMs: "number|string?",
             onReconnect: "function?",
+            keepaliveInterval: "number|string?",
+            readTimeout: "number|string?",
+            filter: "object?",
+            since: "number?",
           },
         },
         replay: {
@@ -698,12 +897,13 @@ export class WebhooksCC {
           params: { requestId: "string", targetUrl: "string" },
         },
         export: {
-          description: "Export captured requests as HAR or cURL commands",
+          description: "Export captured requests as HAR, cURL commands, or CSV",
           params: {
             endpointSlug: "string",
-            format: '"har"|"curl"',
+            format: '"har"|"curl"|"csv"',
             limit: "number?",
             since: "number?",
+            headers: "string[]?",
           },
         },
         search: {
@@ -717,6 +917,7 @@ export class WebhooksCC {
             limit: "number?",
             offset: "number?",
             order: '"asc"|"desc"?',
+            contentClass: "string?",
           },
         },
         count: {
@@ -727,6 +928,7 @@ export class WebhooksCC {
             q: "string?",
             from: "number|string?",
             to: "number|string?",
+            contentClass: "string?",
           },
         },
         clear: {
@@ -750,6 +952,9 @@ export class WebhooksCC {
       if (options.name !== undefined) {
         body.name = options.name;
       }
+      if (options.slug !== undefined) {
+        body.slug = options.slug;
+      }
       if (options.mockResponse !== undefined) {
         body.mockResponse = options.mockResponse;
       }
@@ -768,6 +973,22 @@ export class WebhooksCC {
         }
         body.expiresAt = Date.now() + durationMs;
       }
+      if (options.demo !== undefined) {
+        if (!isEphemeral) {
+          throw new Error("demo requires ephemeral or expiresIn");
+        }
+        body.demo = options.demo;
+      }
+      if (options.autoExtend !== undefined && options.autoExtend !== false) {
+        if (!isEphemeral) {
+          throw new Error("autoExtend requires ephemeral or expiresIn");
+        }
+        const { idle, max } = options.autoExtend === true ? {} : options.autoExtend;
+        body.autoExtend = {
+          ...(idle !== undefined ? { idleMs: parseDuration(idle) } : {}),
+          ...(max !== undefined ? { maxMs: parseDuration(max) } : {}),
+        };
+      }
 
       return this.request<Endpoint>("POST", "/endpoints", body);
     },
@@ -793,6 +1014,89 @@ export class WebhooksCC {
       return this.request<Endpoint>("PATCH", `/endpoints/${slug}`, options);
     },
 
+    networkStats: async (slug: string): Promise<NetworkMatch[]> => {
+      validatePathSegment(slug, "slug");
+      return this.request<NetworkMatch[]>("GET", `/endpoints/${slug}/network-stats`);
+    },
+
+    rejected: async (
+      slug: string,
+      options: ListRejectedOptions = {}
+    ): Promise<RejectedRequest[]> => {
+      validatePathSegment(slug, "slug");
+      const params = new URLSearchParams();
+      if (options.limit !== undefined) params.set("limit", String(options.limit));
+      if (options.since !== undefined) params.set("since", String(options.since));
+
+      const query = params.toString();
+      return this.request<RejectedRequest[]>(
+        "GET",
+        `/endpoints/${slug}/rejected${query ? `?${query}` : ""}`
+      );
+    },
+
+    dryRunStats: async (slug: string): Promise<DryRunStats> => {
+      validatePathSegment(slug, "slug");
+      return this.request<DryRunStats>("GET", `/endpoints/${slug}/dry-run-stats`);
+    },
+
+    samplingStats: async (slug: string): Promise<SamplingStats> => {
+      validatePathSegment(slug, "slug");
+      return this.request<SamplingStats>("GET", `/endpoints/${slug}/sampling-stats`);
+    },
+
+    mockCanary: async (slug: string): Promise<MockCanaryStatus> => {
+      validatePathSegment(slug, "slug");
+      return this.request<MockCanaryStatus>("GET", `/endpoints/${slug}/mock-canary`);
+    },
+
+    startMockCanary: async (slug: string, options: StartMockCanaryOptions): Promise<Endpoint> => {
+      validatePathSegment(slug, "slug");
+      return this.request<Endpoint>("PUT", `/endpoints/${slug}/mock-canary`, {
+        response: options.response,
+        percent: options.percent,
+      });
+    },
+
+    promoteMockCanary: async (slug: string): Promise<Endpoint> => {
+      validatePathSegment(slug, "slug");
+      return this.request<Endpoint>("POST", `/endpoints/${slug}/mock-canary/promote`);
+    },
+
+    rollBackMockCanary: async (slug: string): Promise<Endpoint> => {
+      validatePathSegment(slug, "slug");
+      return this.request<Endpoint>("DELETE", `/endpoints/${slug}/mock-canary`);
+    },
+
+    sinkLatency: async (
+      slug: string,
+      options: { window?: SinkLatency["window"] } = {}
+    ): Promise<SinkLatency> => {
+      validatePathSegment(slug, "slug");
+      const query = options.window ? `?window=${options.window}` : "";
+      return this.request<SinkLatency>("GET", `/endpoints/${slug}/sink-latency${query}`);
+    },
+
+    report: async (slug: string, month: string): Promise<UsageReport> => {
+      validatePathSegment(slug, "slug");
+      if (!MONTH_REGEX.test(month)) {
+        throw new Error("Invalid month: must be YYYY-MM");
+      }
+      return this.request<UsageReport>("GET", `/endpoints/${slug}/report?month=${month}`);
+    },
+
+    pause: async (slug: string, options: PauseEndpointOptions = {}): Promise<Endpoint> => {
+      validatePathSegment(slug, "slug");
+      return this.request<Endpoint>("POST", `/endpoints/${slug}/pause`, {
+        response: options.response ?? null,
+      });
+    },
+
+    resume: async (slug: string): Promise<Endpoint> => {
+      validatePathSegment(slug, "slug");
+      return this.request<Endpoint>("POST", `/endpoints/${slug}/resume`);
+    },
+
     delete: async (slug: string): Promise<void> => {
       validatePathSegment(slug, "slug");
       await this.request("DELETE", `/endpoints/${slug}`);
@@ -849,10 +1153,25 @@ export class WebhooksCC {
     },
   };
 
+  teams = {
+    list: async (): Promise<Team[]> => {
+      return this.request<Team[]>("GET", "/teams");
+    },
+
+    members: async (teamId: string): Promise<TeamMembers> => {
+      validatePathSegment(teamId, "teamId");
+      return this.request<TeamMembers>("GET", `/teams/${teamId}/members`);
+    },
+  };
+
   usage = async (): Promise<UsageInfo> => {
     return this.request<UsageInfo>("GET", "/usage");
   };
 
+  apiUsage = async (): Promise<ApiUsage> => {
+    return this.request<ApiUsage>("GET", "/usage/api");
+  };
+
   flow = (): WebhookFlowBuilder => {
     return new WebhookFlowBuilder(this);
   };
@@ -1015,6 +1334,11 @@ export class WebhooksCC {
       const params = new URLSearchParams();
       if (options.limit !== undefined) params.set("limit", String(options.limit));
       if (options.since !== undefined) params.set("since", String(options.since));
+      if (options.tag !== undefined) params.set("tag", options.tag);
+      if (options.eventType !== undefined) params.set("eventType", options.eventType);
+      if (options.country !== undefined) params.set("country", options.country);
+      if (options.asn !== undefined) params.set("asn", String(options.asn));
+      if (options.reveal === false) params.set("reveal", "false");
 
       const query = params.toString();
       return this.request<Request[]>(
@@ -1039,6 +1363,16 @@ export class WebhooksCC {
       return this.request<Request>("GET", `/requests/${requestId}`);
     },
 
+    annotate: async (requestId: string, options: AnnotateRequestOptions): Promise<Request> => {
+      validatePathSegment(requestId, "requestId");
+      return this.request<Request>("PATCH", `/requests/${requestId}`, options);
+    },
+
+    bodyUrl: async (requestId: string): Promise<RequestBodyUrl> => {
+      validatePathSegment(requestId, "requestId");
+      return this.request<RequestBodyUrl>("GET", `/requests/${requestId}/body`);
+    },
+
     waitForAll: async (endpointSlug: string, options: WaitForAllOptions): Promise<Request[]> => {
       validatePathSegment(endpointSlug, "endpointSlug");
       const listLimit = Math.min(1000, Math.max(100, Math.floor(options.count) * 2));
@@ -1075,10 +1409,10 @@ export class WebhooksCC {
       );
     },
 
-    export: async (
+    export: async <F extends ExportFormat>(
       endpointSlug: string,
-      options: ExportRequestsOptions
-    ): Promise<RequestsExport> => {
+      options: ExportRequestsOptions<F>
+    ): Promise<RequestsExportFor<F>> => {
       validatePathSegment(endpointSlug, "endpointSlug");
       const endpoint = await this.endpoints.get(endpointSlug);
       const endpointUrl = endpoint.url ?? `${this.webhookUrl}/w/${endpoint.slug}`;
@@ -1117,10 +1451,14 @@ export class WebhooksCC {
         cursor = page.cursor;
       }
 
-      if (options.format === "curl") {
-        return buildCurlExport(endpointUrl, requests);
+      const format: ExportFormat = options.format;
+      if (format === "curl") {
+        return buildCurlExport(endpointUrl, requests) as RequestsExportFor<F>;
       }
-      return buildHarExport(endpointUrl, requests, SDK_VERSION);
+      if (format === "csv") {
+        return buildCsvExport(requests, options.headers) as RequestsExportFor<F>;
+      }
+      return buildHarExport(endpointUrl, requests, SDK_VERSION) as RequestsExportFor<F>;
     },
 
     /**
@@ -1164,6 +1502,11 @@ export class WebhooksCC {
       }
 
       const captured = await this.requests.get(requestId);
+      if (captured.frame) {
+        throw new Error(
+          `Request ${requestId} is a WebSocket message and can't be replayed over HTTP`
+        );
+      }
 
       // Strip hop-by-hop and sensitive headers
       const headers: Record<string, string> = {};
@@ -1209,7 +1552,8 @@ export class WebhooksCC {
      * The connection is closed when the iterator is broken, the signal is aborted,
      * or the timeout expires.
      *
-     * Reconnection is opt-in and resumes from the last yielded request timestamp.
+     * Reconnection is opt-in and resumes from the last yielded request timestamp,
+     * or from `since` when nothing has been yielded yet.
      */
     subscribe: (slug: string, options: SubscribeOptions = {}): AsyncIterable<Request> => {
       validatePathSegment(slug, "slug");
@@ -1219,6 +1563,28 @@ export class WebhooksCC {
       const timeoutMs = timeout !== undefined ? parseDuration(timeout) : undefined;
       const maxReconnectAttempts = Math.max(0, Math.floor(options.maxReconnectAttempts ?? 5));
       const reconnectBackoffMs = normalizeReconnectBackoff(options.reconnectBackoffMs);
+      const keepaliveMs =
+        options.keepaliveInterval !== undefined
+          ? parseDuration(options.keepaliveInterval)
+          : undefined;
+      const readTimeoutMs =
+        options.readTimeout !== undefined ? parseDuration(options.readTimeout) : undefined;
+      if (
+        keepaliveMs !== undefined &&
+        (keepaliveMs < STREAM_KEEPALIVE_MIN_MS || keepaliveMs > STREAM_KEEPALIVE_MAX_MS)
+      ) {
+        throw new Error(
+          `Invalid keepaliveInterval: ${keepaliveMs}ms. Must be ${STREAM_KEEPALIVE_MIN_MS}-${STREAM_KEEPALIVE_MAX_MS}ms.`
+        );
+      }
+      if (
+        readTimeoutMs !== undefined &&
+        readTimeoutMs <= (keepaliveMs ?? STREAM_KEEPALIVE_DEFAULT_MS)
+      ) {
+        throw new Error(
+          `Invalid readTimeout: ${readTimeoutMs}ms. Must exceed the keepalive interval (${keepaliveMs ?? STREAM_KEEPALIVE_DEFAULT_MS}ms).`
+        );
+      }
 
       return {
         [Symbol.asyncIterator](): AsyncIterableIterator<Request> {
@@ -1227,7 +1593,8 @@ export class WebhooksCC {
           let iterator: AsyncGenerator<{ event: string; data: string }> | null = null;
           let started = false;
           let reconnectAttempts = 0;
-          let lastReceivedAt: number | undefined;
+          let resumeAfter = options.since;
+          let abortConnection: (() => void) | undefined;
           const seenRequestIds = new Set<string>();
 
           // Link external signal to our controller
@@ -1252,10 +1619,13 @@ export class WebhooksCC {
           const start = async () => {
             const url = `${baseUrl}${buildStreamPath(
               slug,
-              lastReceivedAt !== undefined ? lastReceivedAt - 1 : undefined
+              resumeAfter,
+              keepaliveMs,
+              options.filter
             )}`;
             // Use a separate signal for connection timeout so it doesn't conflict with stream duration
             const connectController = new AbortController();
+            abortConnection = () => connectController.abort();
             const connectTimeout = setTimeout(() => connectController.abort(), 30000);
             // Abort connection timeout if the main controller aborts
             controller.signal.addEventListener("abort", () => connectController.abort(), {
@@ -1294,6 +1664,30 @@ export class WebhooksCC {
             return parseSSE(response.body);
           };
 
+          // Read the next frame, failing with StreamStalledError if the read
+          // deadline passes first. Any frame, keepalive comments included, counts.
+          const readFrame = async (
+            source: AsyncGenerator<{ event: string; data: string }>
+          ): Promise<IteratorResult<{ event: string; data: string }>> => {
+            if (readTimeoutMs === undefined) {
+              return source.next();
+            }
+            let deadline: ReturnType<typeof setTimeout> | undefined;
+            try {
+              return await Promise.race([
+                source.next(),
+                new Promise<never>((_, reject) => {
+                  deadline = setTimeout(() => {
+                    abortConnection?.();
+                    reject(new StreamStalledError(readTimeoutMs));
+                  }, readTimeoutMs);
+                }),
+              ]);
+            } finally {
+              clearTimeout(deadline);
+            }
+          };
+
           const reconnectStream = async (): Promise<boolean> => {
             if (
               !reconnect ||
@@ -1332,7 +1726,7 @@ export class WebhooksCC {
                 }
 
                 while (iterator) {
-                  const { done, value } = await iterator.next();
+                  const { done, value } = await readFrame(iterator);
                   if (done) {
                     iterator = null;
                     if (await reconnectStream()) {
@@ -1355,7 +1749,7 @@ export class WebhooksCC {
                     if (req.id) {
                       seenRequestIds.add(req.id);
                     }
-                    lastReceivedAt = req.receivedAt;
+                    resumeAfter = req.receivedAt - 1;
                     return { done: false, value: req };
                   }
 
@@ -0,0 +1,329 @@
+import { readFile, rename, writeFile } from "fs/promises";
+import type { WebhooksCC } from "./client";
+import { NotFoundError, UnauthorizedError } from "./errors";
+import type { Request } from "./types";
+import { parseDuration } from "./utils";
+
+const DEFAULT_POLL_INTERVAL = 2000;
+const MIN_POLL_INTERVAL = 100;
+const DEFAULT_RETRY_BACKOFF = 1000;
+const MAX_RETRY_BACKOFF = 60000;
+const POLL_PAGE_SIZE = 100;
+const STREAM_READ_TIMEOUT = 75000;
+/**
+ * How far back a resumed consumer looks past its checkpoint. The stream sends
+ * priority captures ahead of older ones, so a request can be handled before
+ * one received a little earlier; IDs handled within the window are kept in
+ * the checkpoint and skipped.
+ */
+const REORDER_WINDOW_MS = 60000;
+
+type ConsumerClient = Pick<WebhooksCC, "requests">;
+
+/** The part of Node's `process` used for shutdown signals. */
+interface SignalSource {
+  once(event: string, listener: () => void): unknown;
+  removeListener(event: string, listener: () => void): unknown;
+}
+
+export type ConsumerHandler = (request: Request) => Promise<void> | void;
+
+export interface ConsumeOptions {
+  /**
+   * File to keep the checkpoint in. A consumer started with the same file
+   * resumes where the last one stopped; without one, progress is kept in
+   * memory only.
+   */
+  checkpointFile?: string;
+  /** Where to start when there is no checkpoint yet (ms timestamp, default: now) */
+  since?: number;
+  /** "stream" (SSE, default) or "poll" for networks that cut long-lived connections */
+  mode?: "stream" | "poll";
+  /** Time between polls in poll mode (default: 2s) */
+  pollInterval?: number | string;
+  /** First delay before retrying a failed handler or reconnecting; doubles up to 60s (default: 1s) */
+  retryBackoff?: number | string;
+  /** Stop gracefully on SIGINT and SIGTERM (default: true) */
+  handleSignals?: boolean;
+  /** Stops the consumer when aborted, like `stop()` */
+  signal?: AbortSignal;
+  /**
+   * Called when the handler throws (with the request, which is retried) or
+   * the connection fails (without one, and the consumer reconnects).
+   */
+  onError?: (error: unknown, request?: Request) => void;
+}
+
+export interface Consumer {
+  /** Resolves once the consumer has stopped; rejects if the endpoint is gone or the key is rejected */
+  done: Promise<void>;
+  /** Stop taking requests, let the one being handled finish, and save the checkpoint */
+  stop(): Promise<void>;
+}
+
+interface CheckpointState {
+  slug: string;
+  /** Where the first run started; nothing older is handed over */
+  from: number;
+  /** Latest `receivedAt` handled */
+  receivedAt: number;
+  /** IDs handled within the reorder window, with their `receivedAt` */
+  handled: Record<string, number>;
+}
+
+class Checkpoint {
+  private constructor(
+    private state: CheckpointState,
+    private readonly file: string | undefined
+  ) {}
+
+  static async load(slug: string, file: string | undefined, since: number): Promise<Checkpoint> {
+    const fresh: CheckpointState = { slug, from: since, receivedAt: since, handled: {} };
+    if (!file) {
+      return new Checkpoint(fresh, undefined);
+    }
+
+    let text: string;
+    try {
+      text = await readFile(file, "utf8");
+    } catch (error) {
+      if ((error as { code?: string }).code === "ENOENT") {
+        return new Checkpoint(fresh, file);
+      }
+      throw error;
+    }
+
+    const saved = JSON.parse(text) as Partial<CheckpointState>;
+    if (saved.slug !== slug) {
+      throw new Error(
+        `Checkpoint file ${file} belongs to endpoint "${saved.slug}", not "${slug}"`
+      );
+    }
+    return new Checkpoint(
+      {
+        slug,
+        from: typeof saved.from === "number" ? saved.from : since,
+        receivedAt: typeof saved.receivedAt === "number" ? saved.receivedAt : since,
+        handled: saved.handled ?? {},
+      },
+      file
+    );
+  }
+
+  /** Earliest `receivedAt` that may not have been handled yet. */
+  since(): number {
+    return Math.max(this.state.from, this.state.receivedAt - REORDER_WINDOW_MS);
+  }
+
+  has(request: Request): boolean {
+    return request.id in this.state.handled;
+  }
+
+  async record(request: Request): Promise<void> {
+    const receivedAt = Math.max(this.state.receivedAt, request.receivedAt);
+    const handled: Record<string, number> = {};
+    for (const [id, at] of Object.entries(this.state.handled)) {
+      if (at >= receivedAt - REORDER_WINDOW_MS) {
+        handled[id] = at;
+      }
+    }
+    handled[request.id] = request.receivedAt;
+    this.state = { ...this.state, receivedAt, handled };
+
+    if (this.file) {
+      // Write then rename, so a crash mid-write leaves the previous checkpoint
+      const temporary = `${this.file}.tmp`;
+      await writeFile(temporary, JSON.stringify(this.state));
+      await rename(temporary, this.file);
+    }
+  }
+}
+
+function isFatal(error: unknown): boolean {
+  return error instanceof UnauthorizedError || error instanceof NotFoundError;
+}
+
+/** Resolves after `ms`, or early when `signal` aborts. */
+function pause(ms: number, signal: AbortSignal): Promise<void> {
+  return new Promise((resolve) => {
+    if (signal.aborted) {
+      resolve();
+      return;
+    }
+    const done = () => {
+      clearTimeout(timer);
+      signal.removeEventListener("abort", done);
+      resolve();
+    };
+    const timer = setTimeout(done, ms);
+    signal.addEventListener("abort", done, { once: true });
+  });
+}
+
+/**
+ * Consume an endpoint's captures like a queue: each request is passed to
+ * `handler` once it succeeds, in arrival order, and progress is checkpointed
+ * after every one so a restart picks up where the last run stopped.
+ *
+ * Delivery is at least once. A handler that throws is retried with backoff
+ * until it succeeds or the consumer stops, and a crash between the handler
+ * and the checkpoint write hands the request over again.
+ */
+export function consume(
+  client: ConsumerClient,
+  slug: string,
+  handler: ConsumerHandler,
+  options: ConsumeOptions = {}
+): Consumer {
+  const {
+    checkpointFile,
+    mode = "stream",
+    handleSignals = true,
+    signal,
+    onError,
+  } = options;
+  const pollIntervalMs = Math.max(
+    MIN_POLL_INTERVAL,
+    parseDuration(options.pollInterval ?? DEFAULT_POLL_INTERVAL)
+  );
+  const retryBackoffMs = Math.max(0, parseDuration(options.retryBackoff ?? DEFAULT_RETRY_BACKOFF));
+
+  const controller = new AbortController();
+  const stopSignal = controller.signal;
+  const onAbort = () => controller.abort();
+  signal?.addEventListener("abort", onAbort, { once: true });
+  if (signal?.aborted) {
+    controller.abort();
+  }
+
+  const shutdownSignals = ["SIGINT", "SIGTERM"];
+  const processRef = handleSignals ? (globalThis as { process?: SignalSource }).process : undefined;
+  if (typeof processRef?.once === "function") {
+    for (const name of shutdownSignals) {
+      processRef.once(name, onAbort);
+    }
+  }
+
+  const report = (error: unknown, request?: Request) => {
+    try {
+      onError?.(error, request);
+    } catch {
+      // Hooks must not break the consumer
+    }
+  };
+
+  const backoff = (attempt: number) =>
+    Math.min(MAX_RETRY_BACKOFF, retryBackoffMs * 2 ** Math.min(attempt, 16));
+
+  // Hand one request to the handler until it succeeds. Returns false when
+  // the consumer stopped first, leaving the request for the next run.
+  const handle = async (checkpoint: Checkpoint, request: Request): Promise<boolean> => {
+    for (let attempt = 0; ; attempt++) {
+      try {
+        await handler(request);
+        await checkpoint.record(request);
+        return true;
+      } catch (error) {
+        report(error, request);
+      }
+      await pause(backoff(attempt), stopSignal);
+      if (stopSignal.aborted) {
+        return false;
+      }
+    }
+  };
+
+  const runStream = async (checkpoint: Checkpoint): Promise<void> => {
+    for (let failures = 0; !stopSignal.aborted; ) {
+      try {
+        const stream = client.requests.subscribe(slug, {
+          since: checkpoint.since() - 1,
+          signal: stopSignal,
+          readTimeout: STREAM_READ_TIMEOUT,
+        });
+        for await (const request of stream) {
+          failures = 0;
+          if (checkpoint.has(request)) {
+            continue;
+          }
+          if (stopSignal.aborted || !(await handle(checkpoint, request))) {
+            return;
+          }
+        }
+        // The server closes streams after a while; reconnect from the checkpoint
+        await pause(backoff(failures++), stopSignal);
+      } catch (error) {
+        if (isFatal(error)) {
+          throw error;
+        }
+        report(error);
+        await pause(backoff(failures++), stopSignal);
+      }
+    }
+  };
+
+  // Newest first, so page back until the checkpoint is passed
+  const fetchSince = async (since: number): Promise<Request[]> => {
+    const requests: Request[] = [];
+    let cursor: string | undefined;
+    for (;;) {
+      const page = await client.requests.listPaginated(slug, { limit: POLL_PAGE_SIZE, cursor });
+      requests.push(...page.items.filter((request) => request.receivedAt >= since));
+      const passed = page.items.some((request) => request.receivedAt < since);
+      if (passed || !page.hasMore || !page.cursor) {
+        return requests;
+      }
+      cursor = page.cursor;
+    }
+  };
+
+  const runPoll = async (checkpoint: Checkpoint): Promise<void> => {
+    for (let failures = 0; !stopSignal.aborted; ) {
+      try {
+        const requests = (await fetchSince(checkpoint.since()))
+          .filter((request) => !checkpoint.has(request))
+          .sort((left, right) => left.receivedAt - right.receivedAt);
+        failures = 0;
+        for (const request of requests) {
+          // Offset pages shift as requests arrive, so the same one can show up twice
+          if (checkpoint.has(request)) {
+            continue;
+          }
+          if (stopSignal.aborted || !(await handle(checkpoint, request))) {
+            return;
+          }
+        }
+        await pause(pollIntervalMs, stopSignal);
+      } catch (error) {
+        if (isFatal(error)) {
+          throw error;
+        }
+        report(error);
+        await pause(backoff(failures++), stopSignal);
+      }
+    }
+  };
+
+  const run = async () => {
+    try {
+      const checkpoint = await Checkpoint.load(slug, checkpointFile, options.since ?? Date.now());
+      await (mode === "poll" ? runPoll(checkpoint) : runStream(checkpoint));
+    } finally {
+      signal?.removeEventListener("abort", onAbort);
+      if (typeof processRef?.once === "function") {
+        for (const name of shutdownSignals) {
+          processRef.removeListener(name, onAbort);
+        }
+      }
+    }
+  };
+
+  const done = run();
+  return {
+    done,
+    stop: async () => {
+      controller.abort();
+      await done.catch(() => undefined);
+    },
+  };
+}
@@ -37,6 +37,14 @@ export class TimeoutError extends WebhooksCCError {
   }
 }
 
+/** Thrown when a subscribe() stream receives nothing within its read timeout. */
+export class StreamStalledError extends WebhooksCCError {
+  constructor(readTimeoutMs: number) {
+    super(0, `Stream received no data for ${readTimeoutMs}ms`);
+    this.name = "StreamStalledError";
+  }
+}
+
 /** Rate limit metadata from X-RateLimit-* response headers. */
 export interface RateLimitMeta {
   /** Maximum number of requests allowed in the current window. */
@@ -4,6 +4,7 @@ export {
   UnauthorizedError,
   NotFoundError,
   TimeoutError,
+  StreamStalledError,
   RateLimitError,
 } from "./errors";
 export type { RateLimitMeta } from "./errors";
@@ -53,6 +54,7 @@ export {
   MOCK_RESPONSE_STATUS_MAX,
   MOCK_RESPONSE_DELAY_MIN,
   MOCK_RESPONSE_DELAY_MAX,
+  MOCK_DEFAULT_BLOCKED_HEADERS,
 } from "./validation";
 export { WebhookFlowBuilder } from "./flow";
 export {
@@ -89,12 +91,74 @@ export type {
   ErrorHookInfo,
   Endpoint,
   TeamShare,
+  FunctionSink,
+  BodyTransform,
   MockResponse,
+  MockHeaderPolicy,
+  MockCorrelation,
+  MockVariant,
+  MockWindow,
+  MockRule,
+  MockRuleMatch,
+  MockSequence,
+  MockSequenceStep,
+  MockStream,
+  GrpcMockReply,
+  CorrelatedMockResponse,
   Request,
+  MultipartPart,
+  CapturedResponse,
+  ResponseOverride,
+  WebSocketFrame,
+  CloudEvent,
+  ContentClass,
+  ClientCertificate,
+  TlsDetails,
+  RequestSizes,
   SearchResult,
   UsageInfo,
+  ApiUsage,
+  ApiUsageMeter,
+  Team,
+  TeamMember,
+  TeamInvite,
+  TeamMembers,
   CreateEndpointOptions,
+  AutoExtendOptions,
+  DemoConfig,
   UpdateEndpointOptions,
+  PauseEndpointOptions,
+  NetworkRule,
+  NetworkPolicy,
+  SignatureVerification,
+  JwtVerification,
+  JwtVerificationSummary,
+  CaptureAuth,
+  CaptureAuthSummary,
+  CorsConfig,
+  CaptureFilter,
+  CaptureFilterRule,
+  SamplingConfig,
+  ChaosConfig,
+  ChaosFault,
+  RateLimitConfig,
+  SchemaValidation,
+  SchemaError,
+  Redaction,
+  PriorityRule,
+  NetworkMatch,
+  RejectedRequest,
+  ListRejectedOptions,
+  DryRunStats,
+  SamplingStats,
+  MockCanary,
+  MockCanaryStatus,
+  StartMockCanaryOptions,
+  LatencyPercentiles,
+  DestinationLatency,
+  SinkLatency,
+  UsageReport,
+  PausedResponse,
   SendOptions,
   SendTemplateOptions,
   SendToOptions,
@@ -102,12 +166,17 @@ export type {
   TemplateProviderInfo,
   ListRequestsOptions,
   ListPaginatedRequestsOptions,
+  AnnotateRequestOptions,
+  RequestBodyUrl,
   PaginatedResult,
   ClearRequestsOptions,
   ExportRequestsOptions,
   RequestsExport,
+  RequestsExportFor,
+  ExportFormat,
   HarExport,
   CurlExport,
+  CsvExport,
   ParsedBody,
   ParsedFormBody,
   FormBodyValue,
@@ -116,6 +185,7 @@ export type {
   WaitForOptions,
   WaitForAllOptions,
   SubscribeOptions,
+  StreamFilter,
   RetryOptions,
   VerifyProvider,
   VerifySignatureOptions,
@@ -1,4 +1,4 @@
-import type { CurlExport, HarExport, Request } from "./types";
+import type { CsvExport, CurlExport, HarExport, Request } from "./types";
 
 const OMITTED_EXPORT_HEADERS = new Set([
   "host",
@@ -144,3 +144,70 @@ export function buildHarExport(
     },
   };
 }
+
+const CSV_FORMULA_PREFIX = /^[=+\-@\t\r]/;
+
+function csvQuote(cell: string): string {
+  return /[",\r\n]/.test(cell) ? `"${cell.replace(/"/g, '""')}"` : cell;
+}
+
+/**
+ * Text cells starting with a formula character are prefixed with `'` so
+ * spreadsheets show them instead of evaluating them. Plain numbers are left alone.
+ */
+function csvText(value: string | undefined): string {
+  if (value === undefined) return "";
+  if (CSV_FORMULA_PREFIX.test(value) && Number.isNaN(Number(value))) {
+    return `'${value}`;
+  }
+  return value;
+}
+
+function findHeader(request: Request, name: string): string | undefined {
+  const match = Object.entries(request.headers).find(([key]) => key.toLowerCase() === name);
+  return match?.[1];
+}
+
+function encodeQuery(queryParams: Record<string, string>): string {
+  return Object.entries(queryParams)
+    .sort(([a], [b]) => (a < b ? -1 : a > b ? 1 : 0))
+    .map(([key, value]) => `${encodeURIComponent(key)}=${encodeURIComponent(value)}`)
+    .join("&");
+}
+
+/**
+ * Flatten requests into CSV (RFC 4180, CRLF line endings). Columns match
+ * `whk requests export --format csv`.
+ */
+export function buildCsvExport(requests: Request[], headers: string[] = []): CsvExport {
+  const headerNames = headers.map((name) => name.toLowerCase());
+  const columns = [
+    "id",
+    "received_at",
+    "method",
+    "path",
+    "query",
+    "content_type",
+    "size",
+    "ip",
+    ...headerNames.map((name) => `header.${name}`),
+    "body",
+    "body_base64",
+  ];
+
+  const rows = requests.map((request) => [
+    csvText(request.id),
+    new Date(request.receivedAt).toISOString(),
+    csvText(request.method),
+    csvText(request.path),
+    csvText(encodeQuery(request.queryParams)),
+    csvText(request.contentType),
+    String(request.size),
+    csvText(request.ip),
+    ...headerNames.map((name) => csvText(findHeader(request, name))),
+    csvText(request.body),
+    csvText(request.bodyRaw),
+  ]);
+
+  return [columns, ...rows].map((row) => row.map(csvQuote).join(",") + "\r\n").join("");
+}
@@ -21,10 +21,76 @@ export interface Endpoint {
   url?: string;
   /** URL to POST a JSON summary to after each captured request (e.g. Slack/Discord webhook) */
   notificationUrl?: string;
+  /** Serverless function invoked with each captured request */
+  functionSink?: FunctionSink | null;
+  /** Ordered transforms applied to each request before it is stored */
+  bodyTransforms?: BodyTransform[] | null;
+  /**
+   * Whether webhook responses include X-Webhook-Remaining-Quota, X-Webhook-Quota-Limit,
+   * X-Webhook-Quota-Reset and X-Webhook-Endpoint-Expires headers
+   */
+  infoHeaders?: boolean;
+  /** Whether the reply sent for each capture is stored as the request's `response` */
+  recordResponses?: boolean;
+  /**
+   * Whether `bodyHash` and duplicate detection use the canonical form of JSON bodies
+   * (sorted keys, no insignificant whitespace, integral numbers without a fraction)
+   */
+  canonicalJson?: boolean;
+  /** Whether requests are answered and counted without being stored (see `endpoints.dryRunStats`) */
+  dryRun?: boolean;
+  /** Whether `__status` and `__delay` query parameters override the reply */
+  queryOverrides?: boolean;
+  /** Whether Slack, Zoom, Dropbox and Microsoft Graph verification requests are answered */
+  autoHandshake?: boolean;
+  /** Backend requests are relayed to, answering with its reply (owner only) */
+  forwardUrl?: string | null;
+  /** Header that marks captures high priority */
+  priorityRule?: PriorityRule | null;
+  /** Lowercase names of headers encrypted at capture time with the owner's account key */
+  encryptedHeaders?: string[];
+  /** Countries and networks the endpoint accepts or tags requests from */
+  networkPolicy?: NetworkPolicy | null;
+  /** PEM CA bundle that client certificates must chain to; null when not required */
+  clientCa?: string | null;
+  /** How the receiver checks the sender's signature (without the secret); null when it doesn't */
+  signatureVerification?: Required<Omit<SignatureVerification, "secret">> | null;
+  /** How the receiver validates the sender's JWT (without a secret); null when it doesn't */
+  jwtVerification?: JwtVerificationSummary | null;
+  /** Credentials senders must present (without the secret); null when the endpoint is open */
+  captureAuth?: CaptureAuthSummary | null;
+  /** Origins, methods and headers browsers may use; null for any origin without credentials */
+  cors?: CorsConfig | null;
+  /** Which requests are stored; null when every request is */
+  captureFilter?: CaptureFilter | null;
+  /** How much of the traffic is stored; null when all of it is */
+  sampling?: SamplingConfig | null;
+  /** Share of requests answered with an injected fault; null when chaos mode is off */
+  chaos?: ChaosConfig | null;
+  /** Requests per window answered before the rest get a 429; null when not rate limited */
+  rateLimit?: RateLimitConfig | null;
+  /** JSON Schema each body is checked against; null when bodies aren't checked */
+  schemaValidation?: SchemaValidation | null;
+  /** What is replaced with `[REDACTED]` before captures are stored; null when nothing is */
+  redaction?: Redaction | null;
+  /** Changed mock response served to a share of senders; null when no canary runs */
+  mockCanary?: MockCanary | null;
+  /** The endpoint's own hostname (Pro); null when not set */
+  customDomain?: string | null;
+  /** Bodies over this many bytes are captured cut short; null for the plan's limit */
+  maxBodySize?: number | null;
+  /** Synthetic captures this ephemeral endpoint generates */
+  demo?: DemoConfig | null;
+  /** Unix timestamp (ms) when capture was paused; null while capturing */
+  pausedAt?: number | null;
+  /** Reply sent while paused; null for the receiver's default 503 `paused` error */
+  pausedResponse?: PausedResponse | null;
   /** Whether the endpoint auto-expires and may be cleaned up automatically */
   isEphemeral?: boolean;
   /** Unix timestamp (ms) when the endpoint expires, if ephemeral */
   expiresAt?: number;
+  /** Set when `expiresAt` slides with activity: `idleMs` after the last capture, up to `until` */
+  autoExtend?: { idleMs: number; until: number };
   /** Unix timestamp (ms) when the endpoint was created */
   createdAt: number;
   /** Teams this endpoint is shared with (present when you own it) */
@@ -33,22 +99,386 @@ export interface Endpoint {
   fromTeam?: TeamShare;
 }
 
+/**
+ * Serverless function the receiver invokes (asynchronously) with each captured request.
+ * Grant the webhooks.cc AWS principal `lambda:InvokeFunction` on the function.
+ */
+export interface FunctionSink {
+  provider: "aws_lambda";
+  /** Lambda function ARN, optionally with a version or alias qualifier */
+  arn: string;
+}
+
+/**
+ * Capture-time transform. Paths are dot-separated keys; numeric segments index arrays.
+ * Body transforms only apply to JSON bodies.
+ */
+export type BodyTransform =
+  /** Move the value at `from` to `to` */
+  | { type: "map_field"; from: string; to: string }
+  /** Rename a request header (case-insensitive) */
+  | { type: "rename_header"; from: string; to: string }
+  /** Remove each path from the body */
+  | { type: "strip_fields"; paths: string[] }
+  /** Flatten nested arrays at `path` (the whole body when omitted) */
+  | { type: "flatten_array"; path?: string };
+
+/**
+ * Overrides for the receiver's mock-response header blocking. By default
+ * Set-Cookie, Strict-Transport-Security, Content-Security-Policy and
+ * X-Frame-Options are dropped from mock responses.
+ */
+export interface MockHeaderPolicy {
+  /** Default-blocked headers this endpoint may send. Allowed cookies are always host-only. */
+  allow?: string[];
+  /** Additional headers to drop from the mock response */
+  block?: string[];
+}
+
+/** One reply in a {@link MockCorrelation} table. */
+export interface CorrelatedMockResponse {
+  /** HTTP status code (100-599) */
+  status: number;
+  /** Raw response body (default empty) */
+  body?: string;
+  /** Response headers (default none) */
+  headers?: Record<string, string>;
+  /** Response delay in milliseconds (0-30000) */
+  delay?: number;
+  /** Random extra delay of up to this many milliseconds per request (0-30000) */
+  delayJitter?: number;
+}
+
+/**
+ * Pick the mock reply from a table using a value read from the request.
+ * Requests whose value is missing or unlisted get the base mock response.
+ */
+export interface MockCorrelation {
+  /**
+   * Request field to match on: `body.json.<path>`, `body.form.<name>`,
+   * `header.<name>`, `query.<name>`, `method` or `path`
+   */
+  key: string;
+  /** Replies keyed by the extracted value (at most 100) */
+  responses: Record<string, CorrelatedMockResponse>;
+}
+
+/**
+ * Weighted alternative reply. Each capture gets it with probability `weight`%;
+ * the name it was answered with is recorded as `Request.mockVariant`.
+ */
+export interface MockVariant extends CorrelatedMockResponse {
+  /** Unique name, 1-32 characters from a-z, 0-9, '_' and '-' ("default" is reserved) */
+  name: string;
+  /** Whole percentage of captures (1-100); all weights sum to at most 100 */
+  weight: number;
+}
+
+/**
+ * Reply for a recurring time window, e.g. a 503 during nightly maintenance. The
+ * name is recorded as `Request.mockVariant` for captures answered inside it.
+ */
+export interface MockWindow extends CorrelatedMockResponse {
+  /** Unique name, 1-32 characters from a-z, 0-9, '_' and '-' ("default" is reserved) */
+  name: string;
+  /** Opening time, `HH:MM` */
+  start: string;
+  /** Closing time, `HH:MM` (exclusive); before `start` for windows past midnight */
+  end: string;
+  /** IANA timezone the times are in, e.g. "Europe/Berlin" (default UTC) */
+  timezone?: string;
+  /** Weekdays the window starts on (default every day) */
+  days?: ("sun" | "mon" | "tue" | "wed" | "thu" | "fri" | "sat")[];
+}
+
+/**
+ * Conditions a request must meet for a conditional mock rule to answer it. Every
+ * condition set must hold; value patterns match the whole value, with `*` standing
+ * for any run of characters.
+ */
+export interface MockRuleMatch {
+  /** Methods the rule answers, any when missing */
+  methods?: string[];
+  /** Glob over the captured path: `*` within a segment, `**` across, `?` one character */
+  path?: string;
+  /** Header name → value pattern */
+  headers?: Record<string, string>;
+  /** Query parameter → value pattern */
+  query?: Record<string, string>;
+  /** JSON body path (e.g. `data.object.status`) → value pattern */
+  body?: Record<string, string>;
+}
+
+/** Reply sent to requests that match `match`. */
+export interface MockRule extends CorrelatedMockResponse {
+  match: MockRuleMatch;
+}
+
+/** One step of a mock response sequence. */
+export interface MockSequenceStep extends CorrelatedMockResponse {
+  /** Requests this step answers before the next one takes over (1-1000, default 1) */
+  count?: number;
+}
+
+/**
+ * Replies for successive requests, e.g. two 500s before the base 200, to test
+ * retry-until-success flows. Positions are kept per receiver instance.
+ */
+export interface MockSequence {
+  /** Steps in order (at most 20) */
+  steps: MockSequenceStep[];
+  /**
+   * Request field giving each value its own position, as in correlation keys (e.g.
+   * `header.x-delivery-id`); requests without it skip the sequence
+   */
+  key?: string;
+  /** Start over after the last step instead of falling through to the rest of the mock */
+  repeat?: boolean;
+  /** Seconds a position may sit idle before it starts over (1-86400) */
+  resetAfter?: number;
+}
+
 /** Mock response returned by the receiver instead of the default 200 OK. */
 export interface MockResponse {
   /** HTTP status code (100-599) */
   status: number;
-  /** Raw response body */
+  /**
+   * Raw response body. Template placeholders such as `{{.Body.json "order.id"}}`,
+   * `{{.Request.Header "X-Id"}}`, `{{uuid}}` and `{{now}}` are rendered per request,
+   * here and in header values.
+   */
   body: string;
+  /**
+   * Binary body as base64, sent in place of `body` (which must then be empty), for
+   * images, protobuf messages and other non-text replies. Without a `Content-Type`
+   * header the receiver sends `application/octet-stream`. Not templated; only the
+   * base reply takes one.
+   */
+  bodyBase64?: string;
   /** Response headers */
   headers: Record<string, string>;
   /** Response delay in milliseconds (0-30000). The receiver caps at 30s. */
   delay?: number;
+  /**
+   * Random extra delay of up to this many milliseconds, rolled per request
+   * (0-30000). The receiver caps `delay` plus jitter at 30s.
+   */
+  delayJitter?: number;
+  /** Per-endpoint override of which response headers the receiver blocks */
+  headerPolicy?: MockHeaderPolicy;
+  /**
+   * Replies by request method (at most 20, uppercase keys such as `GET` or `DELETE`),
+   * in place of this base response for requests with that method. They answer after
+   * `rules`, `sequence` and `script` and ahead of `correlation`; other methods get the
+   * rest of this mock response.
+   */
+  byMethod?: Record<string, CorrelatedMockResponse>;
+  /** Per-request replies keyed by a request field, e.g. `body.json.order_id` */
+  correlation?: MockCorrelation;
+  /**
+   * Weighted alternative replies (at most 10) for testing senders against mixed
+   * responses; the remaining percentage gets this base response. Not combinable
+   * with `byMethod`, `correlation`, `rules`, `sequence` or `script`.
+   */
+  variants?: MockVariant[];
+  /**
+   * Replies for recurring time windows (at most 10). The first window open when a
+   * request arrives answers it, ahead of `correlation` and `variants`.
+   */
+  schedule?: MockWindow[];
+  /**
+   * Ordered conditional replies (at most 20). The first rule a request matches
+   * answers it, after an open `schedule` window and ahead of `correlation` and
+   * `variants`; requests no rule matches get the rest of this mock response.
+   */
+  rules?: MockRule[];
+  /**
+   * Replies for successive requests. The current step answers after an open
+   * `schedule` window and a matching rule, ahead of `correlation`; once the steps
+   * are used up the rest of this mock response answers. Not combinable with
+   * `variants`.
+   */
+  sequence?: MockSequence;
+  /**
+   * Rhai script (at most 16384 characters) run per request to build the reply,
+   * after an open `schedule` window, a matching rule and the current sequence
+   * step. It sees `request` (`method`, `path`, `headers`, `query`, `body`,
+   * `json`) and returns a map of `status`, `headers`, `body` and `delay` (unset
+   * fields keep this response's), a status, a body, or `()` to leave the reply
+   * to `correlation` and the base response. Scripts that fail answer 500.
+   */
+  script?: string;
+  /**
+   * Reply with the request as received (`method`, `path`, `query`, `headers`,
+   * `body`, `json`, `ip`) as JSON in place of `body`, like httpbin's `/anything`.
+   * Rules, sequence steps and other replies keep their own bodies.
+   */
+  echo?: boolean;
+  /**
+   * Send the body as events over time instead of all at once. Only the base reply
+   * streams; it can't be combined with `echo` or `bodyBase64`.
+   */
+  stream?: MockStream;
+  /**
+   * Bodies by media type (at most 10, lowercase keys such as `application/xml` or
+   * `text/html`), sent in place of `body` with that `Content-Type` when the request's
+   * `Accept` header prefers them. Requests without `Accept`, or that accept any type,
+   * get `body`. Only the base reply takes them; not combinable with `echo`, `stream` or
+   * `bodyBase64`.
+   */
+  representations?: Record<string, string>;
+  /** Reply to calls captured by the receiver's gRPC listener; an empty OK message when unset */
+  grpc?: GrpcMockReply;
+}
+
+/**
+ * A streamed mock body: `sse` (the default) sends each event as a `text/event-stream`
+ * message, `chunked` sends each event's `data` as is. Up to 100 events; each waits at
+ * most 30s and the stream is cut off after 60s of delays.
+ */
+export interface MockStream {
+  format?: "sse" | "chunked";
+  events: {
+    data?: string;
+    /** SSE event name */
+    event?: string;
+    /** SSE event id */
+    id?: string;
+    /** Milliseconds to wait after the previous event */
+    delay?: number;
+  }[];
+}
+
+/** Reply to a call captured over gRPC. */
+export interface GrpcMockReply {
+  /** gRPC status code (0-16) */
+  code: number;
+  /** Status message sent as `grpc-message` */
+  message?: string;
+  /** Base64 of the serialized response message, sent when `code` is 0 */
+  body?: string;
 }
 
 /**
  * A captured webhook request with full HTTP details.
  * Stored when a webhook arrives at an endpoint.
  */
+/** One part of a multipart/form-data body, described by the receiver. */
+export interface MultipartPart {
+  /** Form field name */
+  name?: string;
+  filename?: string;
+  contentType?: string;
+  /** Part body size in bytes */
+  size: number;
+  /** Small UTF-8 part bodies, when the receiver is configured to inline them */
+  body?: string;
+}
+
+/** Where a message captured over a WebSocket connection sits in it. */
+export interface WebSocketFrame {
+  /** Random ID shared by the messages of one connection */
+  connection: string;
+  /** Position among the connection's captured messages, from 1 */
+  seq: number;
+  opcode: "text" | "binary";
+}
+
+/** CloudEvents context attributes the receiver found on a capture. */
+export interface CloudEvent {
+  /** `"binary"` for `ce-*` headers, `"structured"` for an `application/cloudevents+json` body */
+  mode: "binary" | "structured";
+  specversion: string;
+  type: string;
+  source: string;
+  id: string;
+  subject?: string;
+}
+
+/** What an HTTP capture weighed as received and what was kept of it. */
+export interface RequestSizes {
+  /** Header block as received, uncompressed */
+  headers: number;
+  /** Body as received */
+  body: number;
+  /** Body bytes stored with the request; 0 when the body went to object storage */
+  stored: number;
+  /**
+   * Fields not stored as sent, with the reason: `body` is `"max_size"` (cut to
+   * the endpoint's `maxBodySize`), `"offloaded"`, `"redacted"` or `"transformed"`, `headers` and `query` are `"redacted"`,
+   * `path` is `"max_length"`
+   */
+  truncated?: Record<string, string>;
+}
+
+/** A client certificate presented over the receiver's mTLS listener. */
+export interface ClientCertificate {
+  /** Subject distinguished name (RFC 4514) */
+  subject: string;
+  /** Issuer distinguished name (RFC 4514) */
+  issuer: string;
+  /** Serial number, lowercase hex */
+  serial: string;
+  /** SHA-256 of the DER certificate, lowercase hex */
+  fingerprint: string;
+  /** Unix timestamp (ms) */
+  notBefore: number;
+  /** Unix timestamp (ms) */
+  notAfter: number;
+  /** Set when the certificate chains to the endpoint's `clientCa` */
+  verified?: boolean;
+}
+
+/** What the sender negotiated, when the receiver ended its TLS itself. */
+export interface TlsDetails {
+  /** `"TLSv1.2"` or `"TLSv1.3"` */
+  version: string;
+  /** IANA cipher suite name, e.g. `"TLS_AES_128_GCM_SHA256"` */
+  cipher: string;
+  /** Hostname the client asked for (SNI) */
+  sni?: string;
+  /** Protocol picked by ALPN, e.g. `"h2"` */
+  alpn?: string;
+}
+
+/** Reply overrides a capture asked for with reserved query parameters. */
+export interface ResponseOverride {
+  /** Status from `__status` */
+  status?: number;
+  /** Milliseconds from `__delay`, in place of the mock's delay */
+  delay?: number;
+}
+
+/**
+ * The reply the receiver sent for a capture, on endpoints that record responses or have a
+ * forward URL.
+ */
+export interface CapturedResponse {
+  /** HTTP status code */
+  status: number;
+  /**
+   * `"mock"` for the endpoint's mock response (the variant is in `mockVariant`),
+   * `"handshake"` for an auto-handshake answer, `"proxy"` for the forward URL's reply,
+   * `"rate_limit"` for the simulated rate limit's 429, `"default"` for the plain 200 OK
+   */
+  source: "mock" | "handshake" | "proxy" | "rate_limit" | "default";
+  headers: Record<string, string>;
+  /** Response body size in bytes */
+  bodySize: number;
+  /** Delay applied before replying, after the receiver's cap */
+  delayMs?: number;
+  /** Proxied replies: milliseconds the forward URL took to answer */
+  latencyMs?: number;
+  /** Proxied replies: the body received, up to 64KB */
+  body?: string;
+  /** Set when `body` is base64 because the reply wasn't UTF-8 */
+  bodyEncoding?: "base64";
+  /** Set when `body` holds only the first 64KB */
+  bodyTruncated?: boolean;
+  /** Proxied replies: why the forward URL didn't answer (the sender got a 502) */
+  error?: string;
+}
+
 export interface Request {
   /** Unique identifier for this request */
   id: string;
@@ -74,8 +504,114 @@ export interface Request {
   size: number;
   /** Unix timestamp (ms) when the request arrived */
   receivedAt: number;
+  /** SHA-256 of the stored body, hex-encoded */
+  bodyHash?: string;
+  /**
+   * ID of the first request of the same delivery: the same provider delivery ID
+   * (`Idempotency-Key`, `X-GitHub-Delivery`, `webhook-id`, ...) in the 3 days before
+   * this one or, without one, the same method, path and body in the 10 minutes before.
+   * Set on provider retries.
+   */
+  duplicateOf?: string;
+  /**
+   * Name of the weighted mock variant or scheduled window this capture was
+   * answered with, or `"default"` for the base response. Unset when the endpoint
+   * has no variants and no window was open.
+   */
+  mockVariant?: string;
+  /** Mock version this capture was answered with; unset when no mock canary was running */
+  mockVersion?: "stable" | "canary";
+  /**
+   * Fault the endpoint's chaos mode injected in place of the reply; unset when the
+   * capture got its normal reply
+   */
+  chaosFault?: ChaosFault;
+  /**
+   * Status and delay the request asked for with `__status` and `__delay` query parameters,
+   * on endpoints with `queryOverrides`
+   */
+  responseOverride?: ResponseOverride;
+  /** Parts of a multipart/form-data body, in order */
+  parts?: MultipartPart[];
+  /**
+   * Set when the body was over the receiver's inline limit and is kept in object
+   * storage; `body` is then empty. Fetch it with `requests.bodyUrl`.
+   */
+  bodyRef?: string;
+  /**
+   * Reply sent for this capture when the endpoint records responses. Filled in
+   * just after the request is stored, so a request seen live may not have it yet.
+   */
+  response?: CapturedResponse;
+  /**
+   * Set on messages captured over a WebSocket connection to `/ws/:slug`. The
+   * method is then `"WS"`, and the headers, path and query are the handshake's.
+   */
+  frame?: WebSocketFrame;
+  /** Set when the capture is a CloudEvent, sent in binary or structured mode */
+  cloudEvent?: CloudEvent;
+  /** Protocol the request arrived over: `"HTTP/1.1"` or `"HTTP/2"` (h2 or h2c) */
+  httpVersion?: string;
+  /** Set when the endpoint's `priorityRule` marked the capture high priority */
+  priority?: boolean;
+  /** Certificate the sender presented over the receiver's mTLS listener */
+  clientCert?: ClientCertificate;
+  /** TLS parameters, for requests over the mTLS listener or a custom domain */
+  tls?: TlsDetails;
+  /** HTTP trailers sent after the body (chunked or HTTP/2), kept apart from `headers` */
+  trailers?: Record<string, string>;
+  /** Shared by captures of the same kind: method, path with IDs replaced by `:id`, and body shape */
+  fingerprint?: string;
+  /** Sender recognized by its signature headers or user agent, e.g. `"github"` or `"stripe"` */
+  provider?: string;
+  /** The provider's name for the event (`"invoice.paid"`, `"pull_request.opened"`), or a CloudEvent's type */
+  eventType?: string;
+  /** What the body is, decided at capture; unset for empty bodies and older captures */
+  contentClass?: ContentClass;
+  /** Whether the sender's signature checked out; unset when the endpoint doesn't verify signatures */
+  signatureValid?: boolean;
+  /** Whether the sender's JWT checked out; unset when the endpoint doesn't validate JWTs */
+  jwtValid?: boolean;
+  /** The JWT's decoded payload, kept whether or not it verified */
+  jwtClaims?: Record<string, unknown>;
+  /** Whether the body matched the endpoint's JSON Schema; unset when the endpoint has none */
+  schemaValid?: boolean;
+  /** How the body broke the schema (at most 20); unset when it matched */
+  schemaErrors?: SchemaError[];
+  /** Sizes as received and as stored; unset for WebSocket, gRPC and older captures */
+  sizes?: RequestSizes;
+  /**
+   * How often each redaction rule fired, keyed by detector name, `header:<name>`,
+   * `path:<path>` or `pattern:<pattern>`; unset when nothing was redacted
+   */
+  redactions?: Record<string, number>;
+  /**
+   * Sender's country (ISO 3166 alpha-2), from Cloudflare or the receiver's
+   * GeoIP database; unset when neither knows the address
+   */
+  country?: string;
+  /** Autonomous system the sender's address belongs to */
+  asn?: number;
+  /** Organization holding that autonomous system */
+  asOrg?: string;
+  /** Free-text note attached with `requests.annotate` */
+  note?: string;
+  /** Tags attached with `requests.annotate` */
+  tags?: string[];
+  /**
+   * Fields that don't show what was sent, keyed `header:<name>`, `trailer:<name>`,
+   * `query:<name>` or `body`: `redacted` before storage, or `encrypted` and not
+   * opened for this response
+   */
+  masked?: Record<string, "redacted" | "encrypted">;
 }
 
+/**
+ * Body class assigned by the receiver. `"protobuf"` is a best guess for
+ * binary bodies that parse as protobuf without being labelled as such.
+ */
+export type ContentClass = "json" | "xml" | "form" | "text" | "image" | "protobuf" | "binary";
+
 /**
  * A retained request returned from ClickHouse-backed search.
  * The id is synthetic and is not compatible with requests.get()/replay().
@@ -103,6 +639,62 @@ export interface SearchResult {
   size: number;
   /** Unix timestamp (ms) when the request arrived */
   receivedAt: number;
+  /** Body class assigned at capture */
+  contentClass?: ContentClass;
+}
+
+/** A team the current user belongs to. */
+export interface Team {
+  /** Team identifier */
+  id: string;
+  /** Team display name */
+  name: string;
+  /** User ID of the team creator */
+  createdBy: string;
+  /** Unix timestamp (ms) when the team was created */
+  createdAt: number;
+  /** Number of members, including the owner */
+  memberCount: number;
+  /** Your role in the team. Only owners can invite, remove members, or delete shared endpoints. */
+  role: "owner" | "member";
+  /** Whether the team is suspended (owner no longer on a plan that includes teams) */
+  suspended: boolean;
+}
+
+/** A member of a team. */
+export interface TeamMember {
+  /** Membership identifier */
+  id: string;
+  /** Member's user ID */
+  userId: string;
+  /** Member's email address */
+  email: string;
+  /** Member's display name, if set */
+  name: string | null;
+  /** Member's role in the team */
+  role: "owner" | "member";
+  /** Member's subscription plan */
+  plan: "free" | "pro";
+  /** Unix timestamp (ms) when the member joined */
+  joinedAt: number;
+}
+
+/** A pending invitation to join a team. */
+export interface TeamInvite {
+  /** Invite identifier */
+  id: string;
+  /** Email address the invite was sent to */
+  invitedEmail: string;
+  /** Invite status */
+  status: "pending" | "accepted" | "declined";
+  /** Unix timestamp (ms) when the invite was sent */
+  createdAt: number;
+}
+
+/** Members and pending invites of a team. */
+export interface TeamMembers {
+  members: TeamMember[];
+  pendingInvites: TeamInvite[];
 }
 
 /** User-level request usage and quota information. */
@@ -119,12 +711,38 @@ export interface UsageInfo {
   periodEnd: number | null;
 }
 
+/** One of the daily API allowances. */
+export interface ApiUsageMeter {
+  used: number;
+  limit: number;
+  remaining: number;
+}
+
+/** Today's use of the platform API (UTC) against the plan's daily limits. */
+export interface ApiUsage {
+  plan: "free" | "pro";
+  /** The UTC day being counted, `YYYY-MM-DD` */
+  day: string;
+  /** Unix timestamp (ms) of the next UTC midnight, when the counters restart */
+  resetsAt: number;
+  /** Authenticated API calls */
+  apiCalls: ApiUsageMeter;
+  /** Time connected to request and event streams, in started minutes */
+  streamMinutes: ApiUsageMeter;
+  /** Request replays */
+  replays: ApiUsageMeter;
+  /** Request exports */
+  exports: ApiUsageMeter;
+}
+
 /**
  * Options for creating a new endpoint.
  */
 export interface CreateEndpointOptions {
   /** Display name for the endpoint */
   name?: string;
+  /** Custom slug for the webhook URL; generated when omitted */
+  slug?: string;
   /** Whether the endpoint should auto-expire */
   ephemeral?: boolean;
   /** Relative expiry duration like "12h" or "7d"; implies ephemeral */
@@ -133,6 +751,40 @@ export interface CreateEndpointOptions {
   mockResponse?: MockResponse;
   /** URL to POST a JSON summary to after each captured request */
   notificationUrl?: string;
+  /** Fill the endpoint with synthetic captures; requires ephemeral or expiresIn */
+  demo?: DemoConfig;
+  /**
+   * Keep the endpoint alive while requests arrive; requires ephemeral or expiresIn.
+   * `true` uses the defaults (15m idle, 24h limit).
+   */
+  autoExtend?: boolean | AutoExtendOptions;
+}
+
+/**
+ * Sliding expiry for an ephemeral endpoint: each capture pushes `expiresAt` to
+ * `idle` after it, but never more than `max` after creation.
+ */
+export interface AutoExtendOptions {
+  /** Quiet time before the endpoint expires, 1m-24h (default "15m") */
+  idle?: number | string;
+  /** Longest the endpoint may live, up to 7d (default "24h") */
+  max?: number | string;
+}
+
+/**
+ * Demo mode for an ephemeral endpoint: a steady trickle of realistic, deterministic
+ * captures tagged `demo`, which do not count towards your quota.
+ */
+export interface DemoConfig {
+  provider: "stripe" | "github" | "shopify";
+  /**
+   * One of the provider's events; they take turns when omitted.
+   * stripe: payment_intent.succeeded, checkout.session.completed, invoice.paid;
+   * github: push, pull_request.opened; shopify: orders/create, orders/paid
+   */
+  template?: string;
+  /** Captures per minute, 1-30 (default 6) */
+  perMinute?: number;
 }
 
 /**
@@ -145,6 +797,503 @@ export interface UpdateEndpointOptions {
   mockResponse?: MockResponse | null;
   /** Notification webhook URL, or null to clear */
   notificationUrl?: string | null;
+  /** Serverless function invoked with each captured request, or null to clear (owner only) */
+  functionSink?: FunctionSink | null;
+  /** Capture-time transforms (max 20), or null to clear */
+  bodyTransforms?: BodyTransform[] | null;
+  /** Expose quota and expiry headers on webhook responses */
+  infoHeaders?: boolean;
+  /** Store the reply sent for each capture with the request */
+  recordResponses?: boolean;
+  /** Compare JSON bodies in canonical form for `bodyHash` and duplicate detection */
+  canonicalJson?: boolean;
+  /**
+   * Answer requests without storing them, notifying or counting them against the quota;
+   * only the counters from `endpoints.dryRunStats` are kept
+   */
+  dryRun?: boolean;
+  /**
+   * Let requests override the reply with `__status` (100-599) and `__delay` (milliseconds,
+   * up to 30000) query parameters; the overrides are recorded on each capture
+   */
+  queryOverrides?: boolean;
+  /**
+   * Answer the verification requests Slack, Zoom, Dropbox and Microsoft Graph send before
+   * delivering, in place of the mock; the requests are still captured. Zoom's answer is
+   * signed with the endpoint's signature verification secret
+   */
+  autoHandshake?: boolean;
+  /**
+   * Relay each request to this http(s) URL and answer with its reply instead of the mock;
+   * the reply is recorded with the capture. Owner only; null or "" clears it
+   */
+  forwardUrl?: string | null;
+  /** Header that marks captures high priority, or null to clear */
+  priorityRule?: PriorityRule | null;
+  /** Headers to encrypt at capture time (max 20, owner only), or null to stop */
+  encryptedHeaders?: string[] | null;
+  /** Countries and networks to accept or tag requests from (owner only), or null to clear */
+  networkPolicy?: NetworkPolicy | null;
+  /**
+   * PEM CA bundle (max 10 certificates, owner only). Once set, only requests over
+   * the receiver's mTLS listener with a certificate that chains to it are captured.
+   * Null or `""` clears it.
+   */
+  clientCa?: string | null;
+  /**
+   * Check each request's HMAC signature with this secret (owner only); captures get
+   * `signatureValid`. Null turns verification off.
+   */
+  signatureVerification?: SignatureVerification | null;
+  /**
+   * Validate the JWT senders put in a header (owner only); captures get `jwtValid` and
+   * `jwtClaims`. Null turns validation off.
+   */
+  jwtVerification?: JwtVerification | null;
+  /**
+   * Require senders to present Basic credentials or an API key (owner only); requests
+   * without them get 401 `unauthorized` and aren't stored. Null removes the requirement.
+   */
+  captureAuth?: CaptureAuth | null;
+  /**
+   * CORS for browser clients (owner only): allowed origins (or `*`), methods,
+   * request headers, credentials and preflight max-age. Preflights are answered
+   * by the receiver and aren't stored. Null allows any origin without credentials.
+   */
+  cors?: CorsConfig | null;
+  /**
+   * Store only some requests (owner only). The rest still get the mock response but
+   * aren't stored or charged; they're counted under the `"filtered"` network stats rule.
+   * Null stores every request again.
+   */
+  captureFilter?: CaptureFilter | null;
+  /**
+   * Store only a sample of a busy endpoint's traffic (owner only). Sampled-out requests
+   * get the mock response but aren't stored or charged; every request is counted in
+   * `endpoints.samplingStats`. Null stores all traffic again.
+   */
+  sampling?: SamplingConfig | null;
+  /**
+   * Answer a share of requests with an injected fault instead of their reply (owner
+   * only), to test how senders retry. Captures record the fault as `chaosFault`. Null
+   * turns chaos mode off.
+   */
+  chaos?: ChaosConfig | null;
+  /**
+   * Simulate rate limiting (owner only): past `limit` requests in a `window`, requests
+   * are answered with a 429 and `Retry-After`, and every reply carries `X-RateLimit-*`
+   * headers. Throttled requests are still captured. Null turns it off.
+   */
+  rateLimit?: RateLimitConfig | null;
+  /**
+   * Check each body against a JSON Schema (owner only); captures get `schemaValid` and
+   * `schemaErrors`. Null turns validation off.
+   */
+  schemaValidation?: SchemaValidation | null;
+  /**
+   * Replace personal data and secrets with `[REDACTED]` before captures are stored
+   * (owner only). Null removes the rules.
+   */
+  redaction?: Redaction | null;
+  /**
+   * Hostname captured by this endpoint once its DNS points at the receiver
+   * (owner only, Pro plan). Null or `""` clears it.
+   */
+  customDomain?: string | null;
+  /**
+   * Capture bodies over this many bytes (1024-104857600) cut short instead of
+   * in full; the plan's limit still applies when lower. Null restores the plan's limit.
+   */
+  maxBodySize?: number | null;
+}
+
+/**
+ * Captures carrying `header` (with one of `values`, when listed; compared
+ * case-insensitively) are high priority: flagged as `priority`, notified
+ * without the cooldown and sent first in the stream's backlog.
+ */
+export interface PriorityRule {
+  header: string;
+  values?: string[];
+}
+
+/**
+ * Countries and IP ranges a network rule matches: a request matches when
+ * either list contains it. At most 200 entries in total.
+ */
+export interface NetworkRule {
+  /** Two-letter country codes as reported by Cloudflare (`XX` unknown, `T1` Tor) */
+  countries?: string[];
+  /** IPv4 or IPv6 ranges, e.g. `"203.0.113.0/24"` */
+  cidrs?: string[];
+  /** Providers whose published webhook source ranges match, refreshed hourly */
+  providers?: ("github" | "stripe")[];
+}
+
+/**
+ * HMAC signature verification at capture. Presets fix the header and algorithm;
+ * `custom` reads `header` (hex or base64, optional `sha256=` style prefix).
+ */
+export interface SignatureVerification {
+  provider: "stripe" | "github" | "shopify" | "custom";
+  /** Signing secret; never returned by the API */
+  secret: string;
+  /** Signature header (custom only) */
+  header?: string;
+  /** HMAC hash (custom only, default "sha256") */
+  algorithm?: "sha1" | "sha256" | "sha512";
+  /** Answer requests that fail with 401 `invalid_signature` instead of storing them */
+  reject?: boolean;
+}
+
+/**
+ * JWT validation at capture. Give exactly one of `secret` (HS256/384/512) or
+ * `jwksUrl` (RS*, PS*, ES256, ES384, EdDSA).
+ */
+export interface JwtVerification {
+  /** Header carrying the token, default "authorization"; a `Bearer ` prefix is ignored */
+  header?: string;
+  /** HMAC secret; never returned by the API */
+  secret?: string;
+  /** https URL of the sender's JSON Web Key Set */
+  jwksUrl?: string;
+  /** Required `iss` claim */
+  issuer?: string;
+  /** Required `aud` claim */
+  audience?: string;
+  /** Answer requests that fail with 401 `invalid_jwt` instead of storing them */
+  reject?: boolean;
+}
+
+/** A JWT validation config as the API returns it: `mode` stands in for the key source. */
+export interface JwtVerificationSummary {
+  header: string;
+  mode: "secret" | "jwks";
+  jwksUrl: string | null;
+  issuer: string | null;
+  audience: string | null;
+  reject: boolean;
+}
+
+/**
+ * Credentials a sender must present before anything is captured: Basic auth, or an API
+ * key in `header` (default "x-api-key"). Refusals count under the `unauthorized` rule of
+ * the endpoint's network stats.
+ */
+export type CaptureAuth =
+  | { type: "basic"; username: string; password: string }
+  | { type: "api_key"; key: string; header?: string };
+
+/** Capture credentials as the API returns them: the password and key are never returned. */
+export interface CaptureAuthSummary {
+  type: "basic" | "api_key";
+  username: string | null;
+  header: string | null;
+}
+
+/**
+ * CORS for an endpoint's browser clients. Empty or missing `methods` and `headers`
+ * allow any; with `credentials` the receiver echoes the origin instead of `*`.
+ */
+export interface CorsConfig {
+  /** Exact origins such as "https://app.example.com", or "*" for any */
+  origins: string[];
+  methods?: string[];
+  headers?: string[];
+  credentials?: boolean;
+  /** Seconds browsers may cache a preflight answer (0-86400) */
+  maxAge?: number;
+}
+
+/**
+ * Which requests an endpoint stores: those matching an `include` rule (or any when there
+ * are none) and no `exclude` rule. A rule matches when every condition it sets holds.
+ */
+export interface CaptureFilter {
+  include?: CaptureFilterRule[];
+  exclude?: CaptureFilterRule[];
+}
+
+/** Conditions on the request as received; at least one is required. */
+export interface CaptureFilterRule {
+  /** Uppercase methods */
+  methods?: string[];
+  /** Glob over the captured path: `*` within a segment, `**` across, `?` one character */
+  path?: string;
+  /** Header that must be present, with a regular expression its value must match */
+  header?: { name: string; value?: string };
+  /** Regular expression found in the body */
+  body?: string;
+}
+
+/**
+ * Traffic sampling, applied by each receiver instance after the capture filter. A request
+ * is stored when it passes both limits.
+ */
+export interface SamplingConfig {
+  /** Store one request in this many (2-1000000) */
+  rate?: number;
+  /** Store at most this many requests a minute (1-1000000) */
+  perMinute?: number;
+}
+
+/**
+ * A fault injected by chaos mode:
+ * - `error`: a random 500, 502, 503 or 504
+ * - `hang`: no reply until the receiver drops the connection, after 60 seconds
+ * - `reset`: the connection is dropped before the reply finishes
+ */
+export type ChaosFault = "error" | "hang" | "reset";
+
+/** Chaos mode: `percent` of requests get one of `faults`, picked evenly. */
+export interface ChaosConfig {
+  /** Share of requests that get a fault, above 0 and up to 100 */
+  percent: number;
+  /** Faults to pick from; all of them when absent */
+  faults?: ChaosFault[];
+}
+
+/** Rate-limit simulation: at most `limit` requests per fixed window of `window` seconds. */
+export interface RateLimitConfig {
+  /** Requests answered per window, 1 to 1000000 */
+  limit: number;
+  /** Window length in seconds, 1 to 86400 */
+  window: number;
+}
+
+/**
+ * JSON Schema validation of captured bodies. Draft 2020-12 assertions are supported;
+ * `$ref` must point within the schema. Bodies that fail are still stored; with
+ * `failureResponse` they're answered with it instead of the mock response.
+ */
+export interface SchemaValidation {
+  schema: Record<string, unknown> | boolean;
+  failureResponse?: {
+    /** 400-599, default 422 */
+    status?: number;
+    /** Sent as is; defaults to `{"error":"schema_validation_failed","errors":[...]}` */
+    body?: string;
+  };
+}
+
+/** One way a captured body broke the endpoint's schema. */
+export interface SchemaError {
+  /** JSON pointer to the failing value; empty for the whole body */
+  path: string;
+  /** The failing keyword (`type`, `required`, ...), or `json` when the body isn't JSON */
+  keyword: string;
+  message: string;
+}
+
+/**
+ * Redaction rules. Matches are replaced in the stored headers, trailers, query, body and
+ * derived fields; signature, JWT and schema checks see the request as received.
+ * `authorization`, `proxy-authorization`, `cookie` and `x-api-key` are hidden on every
+ * endpoint unless `headers.allow` lists them or the endpoint encrypts them.
+ */
+export interface Redaction {
+  /** `credit_card` only matches numbers that pass the Luhn check */
+  detectors?: ("email" | "credit_card" | "bearer_token")[];
+  /** JSON body fields as dot paths, `*` matching every key or index (max 20) */
+  paths?: string[];
+  /** Regular expressions without lookaround or backreferences (max 20) */
+  patterns?: string[];
+  headers?: {
+    /** Stored as sent, including default-hidden ones */
+    allow?: string[];
+    /** Whole value hidden, on top of the defaults */
+    deny?: string[];
+  };
+}
+
+/**
+ * Per-endpoint network policy. There is no ASN lookup; match cloud provider
+ * networks by their published ranges.
+ */
+export interface NetworkPolicy {
+  /** Reject requests matching none of these with the 403 `blocked` receiver error */
+  allow?: NetworkRule;
+  /** Reject requests matching any of these the same way, whatever `allow` says */
+  deny?: NetworkRule;
+  /** Tag stored requests matching each rule (max 10) */
+  tags?: (NetworkRule & { tag: string })[];
+  /** Keep rejected requests for `endpoints.rejected` (needs `allow` or `deny`) */
+  captureRejected?: boolean;
+}
+
+/**
+ * Requests that matched one network policy rule since the policy last changed.
+ */
+export interface NetworkMatch {
+  /**
+   * `"blocked"` for the allow rule, `"denied"` for the deny rule, `"tag:<name>"` for a tag
+   * rule, `"unauthorized"` for requests refused for missing capture credentials,
+   * `"filtered"` for requests the capture filter left out
+   */
+  rule: string;
+  matched: number;
+  /** Unix timestamp (ms) of the latest match */
+  lastMatchedAt: number;
+}
+
+/**
+ * A request the network policy rejected, kept while the policy sets
+ * `captureRejected`. Only the request line and sender are kept, for 7 days.
+ */
+export interface RejectedRequest {
+  id: string;
+  /** `"denied"` for the deny rule, `"blocked"` for requests outside the allow rule */
+  reason: "denied" | "blocked";
+  method: string;
+  path: string;
+  ip: string;
+  /** Country code reported by Cloudflare */
+  country: string | null;
+  userAgent: string | null;
+  /** Unix timestamp (ms) */
+  receivedAt: number;
+}
+
+/** Options for `endpoints.rejected`. */
+export interface ListRejectedOptions {
+  /** Maximum number to return, 1-1000 (default 100) */
+  limit?: number;
+  /** Only requests received after this Unix timestamp (ms) */
+  since?: number;
+}
+
+/**
+ * Counters kept for a dry-run endpoint since dry run was last turned on.
+ */
+export interface DryRunStats {
+  requests: number;
+  /** Total body size in bytes */
+  bytes: number;
+  /** Requests answered with the endpoint's mock response */
+  mocked: number;
+  /** Unix timestamp (ms); null until a request arrives */
+  firstRequestAt: number | null;
+  lastRequestAt: number | null;
+}
+
+/**
+ * Counters kept for a sampled endpoint since its sampling config last changed. Scale
+ * stored volumes by `requests / (requests - sampledOut)` for totals.
+ */
+export interface SamplingStats {
+  /** Requests received, stored or not */
+  requests: number;
+  /** Total body size in bytes */
+  bytes: number;
+  /** Requests answered but not stored */
+  sampledOut: number;
+  sampledOutBytes: number;
+  /** Unix timestamp (ms); null until a request arrives */
+  firstRequestAt: number | null;
+  lastRequestAt: number | null;
+}
+
+/**
+ * A changed mock response served to a share of senders before it replaces the
+ * endpoint's mock. Senders are bucketed by IP, so each keeps its version.
+ */
+export interface MockCanary {
+  response: MockResponse;
+  /** Share of senders answered with `response`, 1-99 */
+  percent: number;
+  /** Unix timestamp (ms) the canary started */
+  startedAt: number;
+}
+
+/** Options for `endpoints.startMockCanary()`. */
+export interface StartMockCanaryOptions {
+  /** Complete mock response to try */
+  response: MockResponse;
+  /** Whole-number share of senders that get it, 1-99 */
+  percent: number;
+}
+
+/** A running canary with requests and distinct senders per version since it started. */
+export interface MockCanaryStatus extends MockCanary {
+  stats: {
+    requests: { stable: number; canary: number };
+    senders: { stable: number; canary: number };
+  };
+}
+
+/** Latency percentiles in milliseconds. */
+export interface LatencyPercentiles {
+  p50: number;
+  p90: number;
+  p99: number;
+}
+
+/**
+ * Delivery latency to one function sink destination. `relay` runs from the
+ * request arriving until the final attempt was sent (including retry backoff);
+ * `destination` from then until the destination responded.
+ */
+export interface DestinationLatency {
+  /** Function ARN */
+  target: string;
+  deliveries: number;
+  /** Deliveries still failing after the last attempt */
+  failed: number;
+  /** Deliveries that needed more than one attempt */
+  retried: number;
+  relay: LatencyPercentiles;
+  destination: LatencyPercentiles;
+  total: LatencyPercentiles;
+}
+
+export interface SinkLatency {
+  window: "1h" | "24h" | "7d";
+  /** Unix timestamp (ms) the window starts at */
+  since: number;
+  destinations: DestinationLatency[];
+}
+
+/**
+ * An endpoint's usage over one calendar month (UTC), for chargeback. Days are
+ * rolled up after midnight UTC, so past months outlive request retention.
+ */
+export interface UsageReport {
+  slug: string;
+  /** `YYYY-MM` */
+  month: string;
+  requests: number;
+  /** Total body bytes */
+  bytes: number;
+  /** Largest body in bytes */
+  maxSize: number;
+  /** Requests and bytes per day (`YYYY-MM-DD`), for days with any */
+  days: Array<{ day: string; requests: number; bytes: number }>;
+  /** Requests per HTTP method */
+  methods: Record<string, number>;
+  /** Up to 10 busiest sender IPs */
+  topSenders: Array<{ ip: string; requests: number }>;
+  /** The month's requests against the owner's current request limit */
+  quota: { plan: string; limit: number; share: number };
+  /** Requests the receiver accepted but could not store */
+  captureFailures: { count: number; windows: number };
+}
+
+/**
+ * Reply a paused endpoint sends instead of capturing.
+ */
+export interface PausedResponse {
+  /** HTTP status code (100-599) */
+  status: number;
+  /** Response body (max 4096 bytes) */
+  body?: string;
+}
+
+/**
+ * Options for pausing capture on an endpoint.
+ */
+export interface PauseEndpointOptions {
+  /** Reply to send while paused; the receiver's 503 `paused` error when omitted */
+  response?: PausedResponse;
 }
 
 /**
@@ -220,6 +1369,16 @@ export interface ListRequestsOptions {
   limit?: number;
   /** Only return requests received after this timestamp (ms) */
   since?: number;
+  /** Only return requests carrying this tag */
+  tag?: string;
+  /** Only return CloudEvents of this `type` */
+  eventType?: string;
+  /** Only return requests from this country (two-letter code) */
+  country?: string;
+  /** Only return requests from this autonomous system */
+  asn?: number;
+  /** Set to false to get encrypted header values masked instead of opened */
+  reveal?: boolean;
 }
 
 /** Cursor-based paginated result. */
@@ -238,6 +1397,39 @@ export interface ListPaginatedRequestsOptions {
   limit?: number;
   /** Opaque cursor from a previous page */
   cursor?: string;
+  /** Only return requests carrying this tag */
+  tag?: string;
+  /** Only return CloudEvents of this `type` */
+  eventType?: string;
+  /** Only return requests from this country (two-letter code) */
+  country?: string;
+  /** Only return requests from this autonomous system */
+  asn?: number;
+  /** Set to false to get encrypted header values masked instead of opened */
+  reveal?: boolean;
+}
+
+/**
+ * Note and tags to attach to a captured request. Each field given replaces the
+ * stored value; an empty or null note clears it.
+ */
+export interface AnnotateRequestOptions {
+  /** Free text, at most 2000 characters */
+  note?: string | null;
+  /** At most 10 tags of 1-32 characters from a-z, 0-9, '_' and '-' */
+  tags?: string[];
+}
+
+/** A signed download URL for a body kept in object storage. */
+export interface RequestBodyUrl {
+  /** Signed GET URL */
+  url: string;
+  /** Unix timestamp (ms) when the URL stops working */
+  expiresAt: number;
+  /** Body size in bytes */
+  size: number;
+  /** SHA-256 of the body, hex-encoded */
+  bodyHash?: string;
 }
 
 /**
@@ -269,6 +1461,8 @@ export interface SearchFilters {
   offset?: number;
   /** Result ordering by receivedAt */
   order?: "asc" | "desc";
+  /** Restrict results to bodies of one class */
+  contentClass?: ContentClass;
 }
 
 /**
@@ -289,6 +1483,22 @@ export interface WaitForAllOptions extends WaitForOptions {
   count: number;
 }
 
+/**
+ * Server-side filter for subscribe(). Only requests matching every given
+ * field are sent over the stream, which saves bandwidth on busy endpoints.
+ */
+export interface StreamFilter {
+  /** HTTP method or methods to include (case-insensitive) */
+  method?: string | string[];
+  /** Glob on the request path: `*` matches one segment, `**` any depth */
+  path?: string;
+  /**
+   * Headers that must match (names case-insensitive). A string value must be
+   * equal; `true` only requires the header to be present. Max 10.
+   */
+  headers?: Record<string, string | true>;
+}
+
 /**
  * Options for subscribe() SSE streaming.
  */
@@ -305,6 +1515,24 @@ export interface SubscribeOptions {
   reconnectBackoffMs?: number | string;
   /** Callback invoked before each reconnect attempt */
   onReconnect?: (attempt: number) => void;
+  /**
+   * Keepalive interval to request from the server (5s-120s, server default 30s).
+   * Lower it when a proxy or load balancer drops idle connections.
+   */
+  keepaliveInterval?: number | string;
+  /**
+   * Treat the stream as dead when nothing, keepalives included, arrives for this long.
+   * Must exceed the keepalive interval. The stream reconnects if `reconnect` is set,
+   * otherwise iteration throws. Disabled by default.
+   */
+  readTimeout?: number | string;
+  /** Only stream requests matching this filter (applied by the server) */
+  filter?: StreamFilter;
+  /**
+   * Start with the requests received after this timestamp (ms) instead of
+   * only those arriving once connected
+   */
+  since?: number;
 }
 
 /** Info passed to the onRequest hook before a request is sent. */
@@ -434,14 +1662,19 @@ export type ParsedFormBody = Record<string, FormBodyValue>;
 /** Result from parseBody() based on content-type detection. */
 export type ParsedBody = unknown | ParsedFormBody | string | undefined;
 
+/** Formats supported by requests.export(). */
+export type ExportFormat = "har" | "curl" | "csv";
+
 /** Options for bulk request export. */
-export interface ExportRequestsOptions {
+export interface ExportRequestsOptions<F extends ExportFormat = ExportFormat> {
   /** Output format */
-  format: "har" | "curl";
+  format: F;
   /** Maximum number of requests to export */
   limit?: number;
   /** Only include requests received after this timestamp (ms) */
   since?: number;
+  /** Header names to add as `header.<name>` columns (CSV only) */
+  headers?: string[];
 }
 
 /** HAR header entry. */
@@ -520,15 +1753,31 @@ export interface HarExport {
 /** cURL export output. */
 export type CurlExport = string[];
 
+/**
+ * CSV export output: a header row, then one row per request with id,
+ * received_at (ISO 8601), method, path, query, content_type, size, ip, the
+ * selected `header.<name>` columns, body and body_base64 (non-UTF-8 bodies).
+ */
+export type CsvExport = string;
+
 /** Union returned by requests.export(). */
-export type RequestsExport = HarExport | CurlExport;
+export type RequestsExport = HarExport | CurlExport | CsvExport;
+
+/** Output of requests.export() for a given format. */
+export type RequestsExportFor<F extends ExportFormat> = F extends "har"
+  ? HarExport
+  : F extends "curl"
+    ? CurlExport
+    : CsvExport;
 
 /** Self-describing schema returned by client.describe(). */
 export interface SDKDescription {
   version: string;
   endpoints: Record<string, OperationDescription>;
   templates: Record<string, OperationDescription>;
+  teams: Record<string, OperationDescription>;
   usage: OperationDescription;
+  apiUsage: OperationDescription;
   sendTo: OperationDescription;
   buildRequest: OperationDescription;
   flow: OperationDescription;
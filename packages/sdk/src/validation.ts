@@ -4,12 +4,20 @@ export const MOCK_RESPONSE_STATUS_MIN = 100;
 export const MOCK_RESPONSE_STATUS_MAX = 599;
 export const MOCK_RESPONSE_DELAY_MIN = 0;
 export const MOCK_RESPONSE_DELAY_MAX = 30000;
+/** Headers the receiver drops from mock responses unless `headerPolicy.allow` lists them. */
+export const MOCK_DEFAULT_BLOCKED_HEADERS = [
+  "set-cookie",
+  "strict-transport-security",
+  "content-security-policy",
+  "x-frame-options",
+] as const;
 
 export function validateMockResponse(
   mockResponse: MockResponse,
   fieldName = "mock response"
 ): void {
-  const { status, delay } = mockResponse;
+  const { status, delay, delayJitter, headerPolicy, byMethod, correlation, rules, sequence } =
+    mockResponse;
   if (
     !Number.isInteger(status) ||
     status < MOCK_RESPONSE_STATUS_MIN ||
@@ -27,4 +35,36 @@ export function validateMockResponse(
       `Invalid ${fieldName} delay: ${delay}. Must be an integer ${MOCK_RESPONSE_DELAY_MIN}-${MOCK_RESPONSE_DELAY_MAX}.`
     );
   }
+  if (
+    delayJitter !== undefined &&
+    (!Number.isInteger(delayJitter) ||
+      delayJitter < MOCK_RESPONSE_DELAY_MIN ||
+      delayJitter > MOCK_RESPONSE_DELAY_MAX)
+  ) {
+    throw new Error(
+      `Invalid ${fieldName} delayJitter: ${delayJitter}. Must be an integer ${MOCK_RESPONSE_DELAY_MIN}-${MOCK_RESPONSE_DELAY_MAX}.`
+    );
+  }
+  for (const name of headerPolicy?.allow ?? []) {
+    if (!(MOCK_DEFAULT_BLOCKED_HEADERS as readonly string[]).includes(name.toLowerCase())) {
+      throw new Error(
+        `Invalid ${fieldName} headerPolicy.allow entry: ${name}. Must be one of ${MOCK_DEFAULT_BLOCKED_HEADERS.join(", ")}.`
+      );
+    }
+  }
+  for (const [method, reply] of Object.entries(byMethod ?? {})) {
+    validateMockResponse({ body: "", headers: {}, ...reply }, `${fieldName} ${method} reply`);
+  }
+  for (const [value, entry] of Object.entries(correlation?.responses ?? {})) {
+    validateMockResponse({ body: "", headers: {}, ...entry }, `${fieldName} correlation "${value}"`);
+  }
+  for (const [index, { match: _match, ...reply }] of (rules ?? []).entries()) {
+    validateMockResponse({ body: "", headers: {}, ...reply }, `${fieldName} rule ${index}`);
+  }
+  for (const [index, { count: _count, ...reply }] of (sequence?.steps ?? []).entries()) {
+    validateMockResponse(
+      { body: "", headers: {}, ...reply },
+      `${fieldName} sequence step ${index}`
+    );
+  }
 }
@@ -7,6 +7,7 @@ export default defineConfig({
   entry: {
     index: "src/index.ts",
     testing: "src/testing.ts",
+    consumer: "src/consumer.ts",
   },
   format: ["cjs", "esm"],
   dts: true,
@@ -0,0 +1,50 @@
+#!/usr/bin/env npx tsx
+/**
+ * Issue a quota bypass token for load testing one endpoint.
+ *
+ * The token is sent as the `X-Webhooks-Cc-Bypass` header. The receiver skips
+ * quota checks for that endpoint until the token expires and records every
+ * use in `quota_bypass_audit`. Tokens are bound to a slug and live at most
+ * 24 hours (the receiver rejects longer ones).
+ *
+ * Usage:
+ *   npx tsx scripts/issue-quota-bypass.ts <slug> [--ttl=3600]
+ *
+ * Required env vars:
+ *   QUOTA_BYPASS_SECRET — same value as the receiver's QUOTA_BYPASS_SECRET
+ */
+
+import { createHmac } from "crypto";
+
+const MAX_TTL_SECONDS = 24 * 60 * 60;
+
+function mustEnv(name: string): string {
+  const value = process.env[name];
+  if (!value) {
+    console.error(`Missing required env var: ${name}`);
+    process.exit(1);
+  }
+  return value;
+}
+
+const slug = process.argv[2];
+if (!slug || slug.startsWith("--") || !/^[A-Za-z0-9_-]{1,50}$/.test(slug)) {
+  console.error("Usage: npx tsx scripts/issue-quota-bypass.ts <slug> [--ttl=3600]");
+  process.exit(1);
+}
+
+const ttlArg = process.argv.find((arg) => arg.startsWith("--ttl="));
+const ttl = ttlArg ? Number(ttlArg.slice("--ttl=".length)) : 3600;
+if (!Number.isInteger(ttl) || ttl < 1 || ttl > MAX_TTL_SECONDS) {
+  console.error(`--ttl must be between 1 and ${MAX_TTL_SECONDS} seconds`);
+  process.exit(1);
+}
+
+const secret = mustEnv("QUOTA_BYPASS_SECRET");
+const expires = Math.floor(Date.now() / 1000) + ttl;
+const signature = createHmac("sha256", secret)
+  .update(`v1.${slug.toLowerCase()}.${expires}`)
+  .digest("hex");
+
+console.log(`v1.${expires}.${signature}`);
+console.error(`Valid for /w/${slug} until ${new Date(expires * 1000).toISOString()}`);
@@ -0,0 +1,54 @@
+-- ============================================================================
+-- Migration 00022: Endpoint share tokens
+--
+-- Scoped, read-only tokens that let someone without an account (or outside
+-- the owner's teams) view and tail the requests of a single endpoint. Only
+-- the SHA-256 hash of the token is stored; the raw token is shown once at
+-- creation. Tokens are revoked by deleting the row and disappear with the
+-- endpoint.
+-- ============================================================================
+
+create table public.endpoint_share_tokens (
+  id               uuid primary key default gen_random_uuid(),
+  endpoint_id      uuid not null references public.endpoints(id) on delete cascade,
+  created_by       uuid not null references public.users(id) on delete cascade,
+  token_hash       text not null,
+  token_prefix     text not null,   -- first 12 chars, e.g. "whsh_Ab3xK.."
+  expires_at       timestamptz,
+  last_used_at     timestamptz,
+  created_at       timestamptz not null default now()
+);
+
+create unique index endpoint_share_tokens_token_hash
+  on public.endpoint_share_tokens(token_hash);
+create index endpoint_share_tokens_endpoint
+  on public.endpoint_share_tokens(endpoint_id);
+create index endpoint_share_tokens_expires
+  on public.endpoint_share_tokens(expires_at)
+  where expires_at is not null;
+
+-- Accessed only through the service role from API routes.
+alter table public.endpoint_share_tokens enable row level security;
+
+-- Expired tokens are rejected at lookup; this just keeps the table small.
+create or replace function public.cleanup_expired_share_tokens()
+returns integer
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  deleted integer;
+begin
+  delete from public.endpoint_share_tokens
+  where expires_at is not null
+    and expires_at <= now();
+  get diagnostics deleted = row_count;
+  return deleted;
+end;
+$$;
+
+select cron.schedule(
+  'cleanup-expired-share-tokens-hourly',
+  '15 * * * *',
+  'select public.cleanup_expired_share_tokens();'
+);
@@ -0,0 +1,226 @@
+-- ============================================================================
+-- Migration 00023: Function sinks
+--
+-- Optional per-endpoint function_sink that makes the receiver invoke a
+-- serverless function (AWS Lambda) with each captured request, so consumers
+-- don't need a public HTTP endpoint. Shape:
+--   {"provider": "aws_lambda", "arn": "arn:aws:lambda:<region>:<account>:function:<name>"}
+--
+-- Invocations that still fail after retries (or are shed by the receiver's
+-- concurrency limit) land in function_sink_dead_letters for inspection and
+-- manual redrive. capture_webhook() now returns function_sink.
+-- ============================================================================
+
+-- 1. Sink configuration on endpoints
+alter table public.endpoints
+  add column if not exists function_sink jsonb;
+
+-- 2. Dead-letter queue for failed invocations
+create table public.function_sink_dead_letters (
+  id               uuid primary key default gen_random_uuid(),
+  endpoint_id      uuid not null references public.endpoints(id) on delete cascade,
+  function_arn     text not null,
+  payload          jsonb not null,
+  error            text not null,
+  attempts         integer not null,
+  created_at       timestamptz not null default now()
+);
+
+create index function_sink_dead_letters_endpoint
+  on public.function_sink_dead_letters(endpoint_id, created_at desc);
+create index function_sink_dead_letters_created
+  on public.function_sink_dead_letters(created_at);
+
+-- Accessed only through the service role and the receiver's procedures.
+alter table public.function_sink_dead_letters enable row level security;
+
+-- 3. Called by the receiver when an invocation is given up on
+create or replace function public.record_function_sink_failure(
+  p_slug          text,
+  p_function_arn  text,
+  p_payload       jsonb,
+  p_error         text,
+  p_attempts      integer
+)
+returns void
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  insert into public.function_sink_dead_letters (
+    endpoint_id, function_arn, payload, error, attempts
+  )
+  select id, p_function_arn, p_payload, left(p_error, 1000), p_attempts
+    from public.endpoints
+   where slug = lower(p_slug);
+end;
+$$;
+
+revoke all on function public.record_function_sink_failure(text, text, jsonb, text, integer) from public;
+revoke all on function public.record_function_sink_failure(text, text, jsonb, text, integer) from anon;
+revoke all on function public.record_function_sink_failure(text, text, jsonb, text, integer) from authenticated;
+grant execute on function public.record_function_sink_failure(text, text, jsonb, text, integer) to service_role;
+
+-- 4. Keep 7 days of dead letters
+create or replace function public.cleanup_function_sink_dead_letters()
+returns integer
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  deleted integer;
+begin
+  delete from public.function_sink_dead_letters
+  where created_at <= now() - interval '7 days';
+  get diagnostics deleted = row_count;
+  return deleted;
+end;
+$$;
+
+select cron.schedule(
+  'cleanup-function-sink-dead-letters-daily',
+  '30 2 * * *',
+  'select public.cleanup_function_sink_dead_letters();'
+);
+
+-- 5. Return function_sink from capture_webhook
+create or replace function public.capture_webhook(
+  p_slug        text,
+  p_method      text,
+  p_path        text,
+  p_headers     jsonb,
+  p_body        text,
+  p_query_params jsonb,
+  p_content_type text,
+  p_ip          text,
+  p_received_at timestamptz,
+  p_body_raw    bytea default null
+)
+returns jsonb
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_endpoint    record;
+  v_user        record;
+  v_quota       record;
+  v_period      record;
+  v_retry_after bigint;
+  v_size        integer;
+  v_mock        jsonb;
+  v_slug        text;
+begin
+  -- Normalize slug to lowercase for case-insensitive lookup
+  v_slug := lower(p_slug);
+
+  -- 1. Look up endpoint by slug
+  select id, user_id, is_ephemeral, expires_at, mock_response, request_count, notification_url,
+         function_sink
+    into v_endpoint
+    from public.endpoints
+   where slug = v_slug;
+
+  if not found then
+    return jsonb_build_object('status', 'not_found');
+  end if;
+
+  -- 2. Check expiry
+  if v_endpoint.expires_at is not null and v_endpoint.expires_at <= now() then
+    return jsonb_build_object('status', 'expired');
+  end if;
+
+  -- 3. Quota check (branching by endpoint type)
+  if v_endpoint.is_ephemeral and v_endpoint.user_id is null then
+    -- Ephemeral endpoint: atomic increment with 25-request cap
+    select request_count into v_quota
+      from public.check_and_increment_ephemeral(v_endpoint.id);
+
+    if not found then
+      return jsonb_build_object('status', 'quota_exceeded');
+    end if;
+
+  elsif v_endpoint.user_id is not null then
+    -- Owned endpoint: check user quota
+    select id, plan, request_limit, requests_used, period_end
+      into v_user
+      from public.users
+     where id = v_endpoint.user_id;
+
+    if not found then
+      return jsonb_build_object('status', 'not_found');
+    end if;
+
+    -- Free user with expired or unstarted period: start a new one
+    if v_user.plan = 'free' and (v_user.period_end is null or v_user.period_end <= now()) then
+      select remaining, quota_limit, period_end_ts into v_period
+        from public.start_free_period(v_endpoint.user_id);
+
+      if not found then
+        -- Period start failed (shouldn't happen, but handle gracefully)
+        return jsonb_build_object('status', 'quota_exceeded');
+      end if;
+
+      -- Refresh user row after period reset
+      select id, plan, request_limit, requests_used, period_end
+        into v_user
+        from public.users
+       where id = v_endpoint.user_id;
+    end if;
+
+    -- Atomic quota check + decrement
+    select remaining, quota_limit, period_end_ts into v_quota
+      from public.check_and_decrement_quota(v_endpoint.user_id, 1);
+
+    if not found then
+      -- Quota exceeded
+      v_retry_after := null;
+      if v_user.period_end is not null and v_user.period_end > now() then
+        v_retry_after := extract(epoch from (v_user.period_end - now()))::bigint * 1000;
+      end if;
+
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'retry_after', v_retry_after
+      );
+    end if;
+
+  end if;
+  -- else: owned endpoint with null user_id but not ephemeral — allow through (no quota)
+
+  -- 4. Insert the request
+  -- Prefer raw byte length when available for accurate size
+  v_size := coalesce(octet_length(p_body_raw), octet_length(p_body), 0);
+
+  insert into public.requests (
+    endpoint_id, user_id, method, path, headers, body, body_raw,
+    query_params, content_type, ip, size, received_at
+  ) values (
+    v_endpoint.id, v_endpoint.user_id, p_method, p_path, p_headers, p_body, p_body_raw,
+    p_query_params, p_content_type, p_ip, v_size, p_received_at
+  );
+
+  -- 5. Increment endpoint request count (ephemeral already incremented above)
+  if not (v_endpoint.is_ephemeral and v_endpoint.user_id is null) then
+    perform public.increment_endpoint_request_count(v_endpoint.id, 1);
+  end if;
+
+  -- User requests_used already incremented by check_and_decrement_quota
+
+  -- 6. Build response
+  v_mock := null;
+  if v_endpoint.mock_response is not null
+     and jsonb_typeof(v_endpoint.mock_response) = 'object'
+     and (v_endpoint.mock_response ? 'status')
+  then
+    v_mock := v_endpoint.mock_response;
+  end if;
+
+  return jsonb_build_object(
+    'status', 'ok',
+    'mock_response', v_mock,
+    'retry_after', null::bigint,
+    'notification_url', v_endpoint.notification_url,
+    'function_sink', v_endpoint.function_sink
+  );
+end;
+$$;
@@ -0,0 +1,40 @@
+-- ============================================================================
+-- Migration 00024: Capture-time body transforms
+--
+-- Optional per-endpoint body_transforms: an ordered list of rewrites the
+-- receiver applies to each request before capture_webhook() stores it (and
+-- before notifications and function sinks see it). Shape:
+--   [{"type": "map_field", "from": "data.object.id", "to": "id"},
+--    {"type": "rename_header", "from": "x-stripe-signature", "to": "x-signature"},
+--    {"type": "strip_fields", "paths": ["livemode"]},
+--    {"type": "flatten_array", "path": "events"}]
+--
+-- The receiver reads the list through get_endpoint_transforms() and caches
+-- it per slug for a short TTL, so the capture path stays a single call.
+-- ============================================================================
+
+-- 1. Transform pipeline on endpoints
+alter table public.endpoints
+  add column if not exists body_transforms jsonb;
+
+-- 2. Lookup used by the receiver's transform cache
+create or replace function public.get_endpoint_transforms(p_slug text)
+returns jsonb
+language plpgsql
+stable
+security definer set search_path = ''
+as $$
+declare
+  v_transforms jsonb;
+begin
+  select body_transforms into v_transforms
+    from public.endpoints
+   where slug = lower(p_slug);
+  return v_transforms;
+end;
+$$;
+
+revoke all on function public.get_endpoint_transforms(text) from public;
+revoke all on function public.get_endpoint_transforms(text) from anon;
+revoke all on function public.get_endpoint_transforms(text) from authenticated;
+grant execute on function public.get_endpoint_transforms(text) to service_role;
@@ -0,0 +1,204 @@
+-- ============================================================================
+-- Migration 00025: Endpoint info headers
+--
+-- Optional per-endpoint info_headers flag. When set, capture_webhook() returns
+-- an `info` object with the owner's remaining quota and the endpoint's expiry,
+-- which the receiver exposes on the webhook response as
+--   X-Webhook-Remaining-Quota, X-Webhook-Quota-Limit, X-Webhook-Quota-Reset,
+--   X-Webhook-Endpoint-Expires
+-- so senders and test scripts can observe limits without an API call.
+-- Quota-exceeded responses carry the same headers (remaining 0).
+-- ============================================================================
+
+-- 1. Opt-in flag on endpoints
+alter table public.endpoints
+  add column if not exists info_headers boolean not null default false;
+
+-- 2. Build the info object (null unless the endpoint opted in)
+create or replace function public.endpoint_info(
+  p_enabled    boolean,
+  p_remaining  integer,
+  p_limit      integer,
+  p_reset      timestamptz,
+  p_expires_at timestamptz
+)
+returns jsonb
+language sql
+immutable
+set search_path = ''
+as $$
+  select case when p_enabled then
+    jsonb_strip_nulls(jsonb_build_object(
+      'quota_remaining', p_remaining,
+      'quota_limit', p_limit,
+      'quota_reset', (extract(epoch from p_reset) * 1000)::bigint,
+      'expires_at', (extract(epoch from p_expires_at) * 1000)::bigint
+    ))
+  end;
+$$;
+
+revoke all on function public.endpoint_info(boolean, integer, integer, timestamptz, timestamptz) from public;
+revoke all on function public.endpoint_info(boolean, integer, integer, timestamptz, timestamptz) from anon;
+revoke all on function public.endpoint_info(boolean, integer, integer, timestamptz, timestamptz) from authenticated;
+grant execute on function public.endpoint_info(boolean, integer, integer, timestamptz, timestamptz) to service_role;
+
+-- 3. Return info from capture_webhook
+create or replace function public.capture_webhook(
+  p_slug        text,
+  p_method      text,
+  p_path        text,
+  p_headers     jsonb,
+  p_body        text,
+  p_query_params jsonb,
+  p_content_type text,
+  p_ip          text,
+  p_received_at timestamptz,
+  p_body_raw    bytea default null
+)
+returns jsonb
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_endpoint    record;
+  v_user        record;
+  v_quota       record;
+  v_period      record;
+  v_retry_after bigint;
+  v_size        integer;
+  v_mock        jsonb;
+  v_slug        text;
+  v_remaining   integer;
+  v_limit       integer;
+  v_reset       timestamptz;
+begin
+  -- Normalize slug to lowercase for case-insensitive lookup
+  v_slug := lower(p_slug);
+
+  -- 1. Look up endpoint by slug
+  select id, user_id, is_ephemeral, expires_at, mock_response, request_count, notification_url,
+         function_sink, info_headers
+    into v_endpoint
+    from public.endpoints
+   where slug = v_slug;
+
+  if not found then
+    return jsonb_build_object('status', 'not_found');
+  end if;
+
+  -- 2. Check expiry
+  if v_endpoint.expires_at is not null and v_endpoint.expires_at <= now() then
+    return jsonb_build_object('status', 'expired');
+  end if;
+
+  -- 3. Quota check (branching by endpoint type)
+  if v_endpoint.is_ephemeral and v_endpoint.user_id is null then
+    -- Ephemeral endpoint: atomic increment with 25-request cap
+    select request_count into v_quota
+      from public.check_and_increment_ephemeral(v_endpoint.id);
+
+    if not found then
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'info', public.endpoint_info(v_endpoint.info_headers, 0, 25, null, v_endpoint.expires_at)
+      );
+    end if;
+
+    v_remaining := greatest(25 - v_quota.request_count, 0);
+    v_limit := 25;
+
+  elsif v_endpoint.user_id is not null then
+    -- Owned endpoint: check user quota
+    select id, plan, request_limit, requests_used, period_end
+      into v_user
+      from public.users
+     where id = v_endpoint.user_id;
+
+    if not found then
+      return jsonb_build_object('status', 'not_found');
+    end if;
+
+    -- Free user with expired or unstarted period: start a new one
+    if v_user.plan = 'free' and (v_user.period_end is null or v_user.period_end <= now()) then
+      select remaining, quota_limit, period_end_ts into v_period
+        from public.start_free_period(v_endpoint.user_id);
+
+      if not found then
+        -- Period start failed (shouldn't happen, but handle gracefully)
+        return jsonb_build_object('status', 'quota_exceeded');
+      end if;
+
+      -- Refresh user row after period reset
+      select id, plan, request_limit, requests_used, period_end
+        into v_user
+        from public.users
+       where id = v_endpoint.user_id;
+    end if;
+
+    -- Atomic quota check + decrement
+    select remaining, quota_limit, period_end_ts into v_quota
+      from public.check_and_decrement_quota(v_endpoint.user_id, 1);
+
+    if not found then
+      -- Quota exceeded
+      v_retry_after := null;
+      if v_user.period_end is not null and v_user.period_end > now() then
+        v_retry_after := extract(epoch from (v_user.period_end - now()))::bigint * 1000;
+      end if;
+
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'retry_after', v_retry_after,
+        'info', public.endpoint_info(
+          v_endpoint.info_headers, 0, v_user.request_limit, v_user.period_end, v_endpoint.expires_at
+        )
+      );
+    end if;
+
+    v_remaining := v_quota.remaining;
+    v_limit := v_quota.quota_limit;
+    v_reset := v_quota.period_end_ts;
+
+  end if;
+  -- else: owned endpoint with null user_id but not ephemeral — allow through (no quota)
+
+  -- 4. Insert the request
+  -- Prefer raw byte length when available for accurate size
+  v_size := coalesce(octet_length(p_body_raw), octet_length(p_body), 0);
+
+  insert into public.requests (
+    endpoint_id, user_id, method, path, headers, body, body_raw,
+    query_params, content_type, ip, size, received_at
+  ) values (
+    v_endpoint.id, v_endpoint.user_id, p_method, p_path, p_headers, p_body, p_body_raw,
+    p_query_params, p_content_type, p_ip, v_size, p_received_at
+  );
+
+  -- 5. Increment endpoint request count (ephemeral already incremented above)
+  if not (v_endpoint.is_ephemeral and v_endpoint.user_id is null) then
+    perform public.increment_endpoint_request_count(v_endpoint.id, 1);
+  end if;
+
+  -- User requests_used already incremented by check_and_decrement_quota
+
+  -- 6. Build response
+  v_mock := null;
+  if v_endpoint.mock_response is not null
+     and jsonb_typeof(v_endpoint.mock_response) = 'object'
+     and (v_endpoint.mock_response ? 'status')
+  then
+    v_mock := v_endpoint.mock_response;
+  end if;
+
+  return jsonb_build_object(
+    'status', 'ok',
+    'mock_response', v_mock,
+    'retry_after', null::bigint,
+    'notification_url', v_endpoint.notification_url,
+    'function_sink', v_endpoint.function_sink,
+    'info', public.endpoint_info(
+      v_endpoint.info_headers, v_remaining, v_limit, v_reset, v_endpoint.expires_at
+    )
+  );
+end;
+$$;
@@ -0,0 +1,114 @@
+-- ============================================================================
+-- Migration 00026: Capture failures
+--
+-- When capture_webhook() fails (database unavailable, statement timeout) the
+-- receiver still answers 200 so senders don't retry into an outage, and the
+-- request is lost. The receiver counts these losses per endpoint and
+-- periodically reports them through record_capture_failures(), so the owner
+-- sees "N requests between X and Y could not be stored" on the dashboard and
+-- through the endpoint's notification URL instead of only in receiver logs.
+--
+-- Reports that arrive within 10 minutes of an open (unacknowledged) window
+-- extend it, so one outage shows up as one record and one notification.
+-- ============================================================================
+
+create table public.capture_failures (
+  id               uuid primary key default gen_random_uuid(),
+  endpoint_id      uuid not null references public.endpoints(id) on delete cascade,
+  failed_count     integer not null,
+  window_start     timestamptz not null,
+  window_end       timestamptz not null,
+  acknowledged_at  timestamptz,
+  created_at       timestamptz not null default now()
+);
+
+create index capture_failures_endpoint
+  on public.capture_failures(endpoint_id, window_end desc);
+create index capture_failures_unacknowledged
+  on public.capture_failures(endpoint_id)
+  where acknowledged_at is null;
+create index capture_failures_created
+  on public.capture_failures(created_at);
+
+-- Accessed only through the service role and the receiver's procedures.
+alter table public.capture_failures enable row level security;
+
+-- 1. Called by the receiver with the losses counted since its last report.
+--    Returns null for unknown slugs, otherwise the endpoint's notification
+--    URL and whether this report opened a new window (only those notify).
+create or replace function public.record_capture_failures(
+  p_slug          text,
+  p_count         integer,
+  p_window_start  timestamptz,
+  p_window_end    timestamptz
+)
+returns jsonb
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_endpoint  record;
+  v_id        uuid;
+begin
+  select id, notification_url
+    into v_endpoint
+    from public.endpoints
+   where slug = lower(p_slug);
+
+  if not found then
+    return null;
+  end if;
+
+  update public.capture_failures
+     set failed_count = failed_count + p_count,
+         window_start = least(window_start, p_window_start),
+         window_end   = greatest(window_end, p_window_end)
+   where id = (
+     select id
+       from public.capture_failures
+      where endpoint_id = v_endpoint.id
+        and acknowledged_at is null
+        and window_end >= p_window_start - interval '10 minutes'
+      order by window_end desc
+      limit 1
+   )
+  returning id into v_id;
+
+  if v_id is null then
+    insert into public.capture_failures (endpoint_id, failed_count, window_start, window_end)
+    values (v_endpoint.id, p_count, p_window_start, p_window_end);
+  end if;
+
+  return jsonb_build_object(
+    'notification_url', v_endpoint.notification_url,
+    'new_window', v_id is null
+  );
+end;
+$$;
+
+revoke all on function public.record_capture_failures(text, integer, timestamptz, timestamptz) from public;
+revoke all on function public.record_capture_failures(text, integer, timestamptz, timestamptz) from anon;
+revoke all on function public.record_capture_failures(text, integer, timestamptz, timestamptz) from authenticated;
+grant execute on function public.record_capture_failures(text, integer, timestamptz, timestamptz) to service_role;
+
+-- 2. Keep 30 days of capture failures
+create or replace function public.cleanup_capture_failures()
+returns integer
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  deleted integer;
+begin
+  delete from public.capture_failures
+  where created_at <= now() - interval '30 days';
+  get diagnostics deleted = row_count;
+  return deleted;
+end;
+$$;
+
+select cron.schedule(
+  'cleanup-capture-failures-daily',
+  '45 2 * * *',
+  'select public.cleanup_capture_failures();'
+);
@@ -0,0 +1,151 @@
+-- ============================================================================
+-- Migration 00029: Endpoint configuration history
+--
+-- Every change to an endpoint's capture configuration (mock response,
+-- notification URL, function sink, body transforms, info headers) is saved
+-- as a numbered version in endpoint_versions by a trigger, so a bad edit can
+-- be rolled back with rollback_endpoint_config(). The first change to an
+-- endpoint also saves the configuration it replaced as version 1.
+--
+-- Config changes are announced on the endpoint_config channel (payload: the
+-- slug) so receivers drop cached per-endpoint state immediately instead of
+-- waiting for their cache TTL.
+-- ============================================================================
+
+-- 1. Version history
+create table public.endpoint_versions (
+  id              uuid primary key default gen_random_uuid(),
+  endpoint_id     uuid not null references public.endpoints(id) on delete cascade,
+  version         integer not null,
+  config          jsonb not null,
+  source          text not null check (source in ('initial', 'edit', 'rollback')),
+  rolled_back_to  integer,
+  created_at      timestamptz not null default now(),
+  unique (endpoint_id, version)
+);
+
+-- Accessed only through the service role.
+alter table public.endpoint_versions enable row level security;
+
+-- 2. The versioned part of an endpoint row
+create or replace function public.endpoint_config(
+  p_mock_response    jsonb,
+  p_notification_url text,
+  p_function_sink    jsonb,
+  p_body_transforms  jsonb,
+  p_info_headers     boolean
+)
+returns jsonb
+language sql
+immutable
+set search_path = ''
+as $$
+  select jsonb_build_object(
+    'mock_response', p_mock_response,
+    'notification_url', p_notification_url,
+    'function_sink', p_function_sink,
+    'body_transforms', p_body_transforms,
+    'info_headers', p_info_headers
+  );
+$$;
+
+-- 3. Record a version whenever the configuration changes. Updates to the same
+--    endpoint are serialized by the row lock, so version numbers don't race.
+create or replace function public.record_endpoint_version()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_old      jsonb;
+  v_new      jsonb;
+  v_latest   integer;
+  v_rollback integer;
+begin
+  v_old := public.endpoint_config(
+    old.mock_response, old.notification_url, old.function_sink, old.body_transforms, old.info_headers
+  );
+  v_new := public.endpoint_config(
+    new.mock_response, new.notification_url, new.function_sink, new.body_transforms, new.info_headers
+  );
+  if v_old = v_new then
+    return new;
+  end if;
+
+  select max(version) into v_latest
+    from public.endpoint_versions
+   where endpoint_id = new.id;
+
+  if v_latest is null then
+    insert into public.endpoint_versions (endpoint_id, version, config, source)
+    values (new.id, 1, v_old, 'initial');
+    v_latest := 1;
+  end if;
+
+  v_rollback := nullif(current_setting('webhooks.rollback_version', true), '')::integer;
+
+  insert into public.endpoint_versions (endpoint_id, version, config, source, rolled_back_to)
+  values (
+    new.id,
+    v_latest + 1,
+    v_new,
+    case when v_rollback is null then 'edit' else 'rollback' end,
+    v_rollback
+  );
+
+  -- Keep the 50 most recent versions
+  delete from public.endpoint_versions
+   where endpoint_id = new.id
+     and version <= v_latest + 1 - 50;
+
+  perform pg_notify('endpoint_config', new.slug);
+  return new;
+end;
+$$;
+
+create trigger endpoint_config_versions
+  after update on public.endpoints
+  for each row execute function public.record_endpoint_version();
+
+-- 4. Restore a saved version. The restore is itself recorded as a new version
+--    (source 'rollback'), so it can be undone the same way. Returns false when
+--    the version doesn't exist.
+create or replace function public.rollback_endpoint_config(
+  p_endpoint_id uuid,
+  p_version     integer
+)
+returns boolean
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_config jsonb;
+begin
+  select config into v_config
+    from public.endpoint_versions
+   where endpoint_id = p_endpoint_id
+     and version = p_version;
+
+  if not found then
+    return false;
+  end if;
+
+  perform set_config('webhooks.rollback_version', p_version::text, true);
+
+  update public.endpoints
+     set mock_response    = nullif(v_config->'mock_response', 'null'::jsonb),
+         notification_url = v_config->>'notification_url',
+         function_sink    = nullif(v_config->'function_sink', 'null'::jsonb),
+         body_transforms  = nullif(v_config->'body_transforms', 'null'::jsonb),
+         info_headers     = coalesce((v_config->>'info_headers')::boolean, false)
+   where id = p_endpoint_id;
+
+  perform set_config('webhooks.rollback_version', '', true);
+  return true;
+end;
+$$;
+
+revoke all on function public.rollback_endpoint_config(uuid, integer) from public;
+revoke all on function public.rollback_endpoint_config(uuid, integer) from anon;
+revoke all on function public.rollback_endpoint_config(uuid, integer) from authenticated;
+grant execute on function public.rollback_endpoint_config(uuid, integer) to service_role;
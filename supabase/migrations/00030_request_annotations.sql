@@ -0,0 +1,16 @@
+-- ============================================================================
+-- Migration 00030: Request annotations
+--
+-- Captured requests can carry a free-text note and a set of tags, so the
+-- context found while debugging stays next to the traffic. Both are edited
+-- through PATCH /api/requests/:id; the receiver never writes them.
+-- ============================================================================
+
+alter table public.requests
+  add column note text check (char_length(note) <= 2000),
+  add column tags text[] not null default '{}'
+    check (cardinality(tags) <= 10);
+
+-- Tag filters (tags @> '{name}') on an endpoint's requests
+create index idx_requests_tags on public.requests using gin (tags)
+  where cardinality(tags) > 0;
@@ -0,0 +1,15 @@
+-- ============================================================================
+-- Migration 00031: Realtime for endpoint configuration history
+--
+-- GET /api/endpoints/:slug/events streams configuration changes to clients
+-- (`whk watch`), so new endpoint_versions rows are published to Realtime.
+-- The table keeps RLS without policies: only the service role reads it.
+-- ============================================================================
+
+do $$
+begin
+  alter publication supabase_realtime add table public.endpoint_versions;
+exception
+  when duplicate_object then null;
+end
+$$;
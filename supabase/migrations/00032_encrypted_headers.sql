@@ -0,0 +1,70 @@
+-- ============================================================================
+-- Migration 00032: Per-endpoint header encryption
+--
+-- Optional per-endpoint encrypted_headers: lowercase names of headers whose
+-- values the receiver encrypts with the owner's account key before
+-- capture_webhook() stores them, e.g. {authorization, x-api-key}. The rest of
+-- the request stays plaintext and searchable. Encrypted values are stored as
+-- "enc:v1:<base64>" and decrypted by the API for users with access.
+--
+-- The receiver reads the list (with the owner's id, which selects the key)
+-- through get_endpoint_header_encryption() and caches it per slug. Changes
+-- are announced on the endpoint_config channel like other config changes.
+-- ============================================================================
+
+-- 1. Header list on endpoints
+alter table public.endpoints
+  add column if not exists encrypted_headers text[];
+
+alter table public.endpoints
+  add constraint endpoints_encrypted_headers_check
+  check (
+    encrypted_headers is null
+    or (
+      cardinality(encrypted_headers) <= 20
+      and array_to_string(encrypted_headers, ',') ~ '^[a-z0-9_-]{1,64}(,[a-z0-9_-]{1,64})*$'
+    )
+  );
+
+-- 2. Lookup used by the receiver's encryption cache
+create or replace function public.get_endpoint_header_encryption(p_slug text)
+returns jsonb
+language plpgsql
+stable
+security definer set search_path = ''
+as $$
+declare
+  v_result jsonb;
+begin
+  select jsonb_build_object('user_id', user_id, 'headers', to_jsonb(encrypted_headers))
+    into v_result
+    from public.endpoints
+   where slug = lower(p_slug)
+     and user_id is not null
+     and cardinality(encrypted_headers) > 0;
+  return v_result;
+end;
+$$;
+
+revoke all on function public.get_endpoint_header_encryption(text) from public;
+revoke all on function public.get_endpoint_header_encryption(text) from anon;
+revoke all on function public.get_endpoint_header_encryption(text) from authenticated;
+grant execute on function public.get_endpoint_header_encryption(text) to service_role;
+
+-- 3. Tell receivers to drop their cached list when it changes
+create or replace function public.notify_encrypted_headers_change()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  perform pg_notify('endpoint_config', new.slug);
+  return new;
+end;
+$$;
+
+create trigger endpoint_encrypted_headers_changed
+  after update of encrypted_headers on public.endpoints
+  for each row
+  when (old.encrypted_headers is distinct from new.encrypted_headers)
+  execute function public.notify_encrypted_headers_change();
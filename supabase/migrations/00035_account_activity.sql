@@ -0,0 +1,168 @@
+-- ============================================================================
+-- Migration 00035: Account activity feed
+--
+-- account_events collects the notable things that happen to an account so
+-- they can be followed from one place (`whk activity`) instead of per
+-- endpoint:
+--   endpoint_created    an owned endpoint was created
+--   quota_warning       the owner's usage crossed 80% of the period's limit
+--   forwarding_failure  a function sink invocation was given up on
+--   team_member_joined  someone joined one of the owner's teams
+--
+-- Events are written by triggers on the tables they describe, so every
+-- writer (web app, receiver procedures, cron) is covered. Team events carry
+-- team_id and are visible to every member; the rest carry user_id and are
+-- visible to that user. Events are kept for 30 days.
+-- ============================================================================
+
+-- 1. Events
+create table public.account_events (
+  id           uuid primary key default gen_random_uuid(),
+  user_id      uuid references public.users(id) on delete cascade,
+  team_id      uuid references public.teams(id) on delete cascade,
+  endpoint_id  uuid references public.endpoints(id) on delete set null,
+  type         text not null check (
+    type in ('endpoint_created', 'quota_warning', 'forwarding_failure', 'team_member_joined')
+  ),
+  data         jsonb not null default '{}'::jsonb,
+  created_at   timestamptz not null default now(),
+  check (user_id is not null or team_id is not null)
+);
+
+create index account_events_user
+  on public.account_events(user_id, created_at desc)
+  where user_id is not null;
+create index account_events_team
+  on public.account_events(team_id, created_at desc)
+  where team_id is not null;
+create index account_events_created
+  on public.account_events(created_at);
+
+-- Accessed only through the service role.
+alter table public.account_events enable row level security;
+
+-- 2. endpoint_created
+create or replace function public.record_endpoint_created_event()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  insert into public.account_events (user_id, endpoint_id, type, data)
+  values (
+    new.user_id, new.id, 'endpoint_created',
+    jsonb_build_object('slug', new.slug, 'name', new.name)
+  );
+  return new;
+end;
+$$;
+
+create trigger endpoints_activity_created
+  after insert on public.endpoints
+  for each row
+  when (new.user_id is not null)
+  execute function public.record_endpoint_created_event();
+
+-- 3. quota_warning, once per period: requests_used only grows until the
+--    period resets it to 0
+create or replace function public.record_quota_warning_event()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  insert into public.account_events (user_id, type, data)
+  values (
+    new.id, 'quota_warning',
+    jsonb_build_object(
+      'used', new.requests_used,
+      'limit', new.request_limit,
+      'percent', 80,
+      'periodEnd', new.period_end
+    )
+  );
+  return new;
+end;
+$$;
+
+create trigger users_activity_quota_warning
+  after update of requests_used on public.users
+  for each row
+  when (
+    new.request_limit > 0
+    and old.requests_used * 5 < new.request_limit * 4
+    and new.requests_used * 5 >= new.request_limit * 4
+  )
+  execute function public.record_quota_warning_event();
+
+-- 4. forwarding_failure
+create or replace function public.record_forwarding_failure_event()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  insert into public.account_events (user_id, endpoint_id, type, data)
+  select e.user_id, e.id, 'forwarding_failure',
+         jsonb_build_object(
+           'slug', e.slug,
+           'target', new.function_arn,
+           'error', left(new.error, 200),
+           'attempts', new.attempts
+         )
+    from public.endpoints e
+   where e.id = new.endpoint_id
+     and e.user_id is not null;
+  return new;
+end;
+$$;
+
+create trigger function_sink_dead_letters_activity
+  after insert on public.function_sink_dead_letters
+  for each row
+  execute function public.record_forwarding_failure_event();
+
+-- 5. team_member_joined (the team's creator is added as owner, not "joining")
+create or replace function public.record_team_member_joined_event()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  insert into public.account_events (team_id, type, data)
+  select new.team_id, 'team_member_joined',
+         jsonb_build_object('teamName', t.name, 'email', u.email)
+    from public.teams t
+    join public.users u on u.id = new.user_id
+   where t.id = new.team_id;
+  return new;
+end;
+$$;
+
+create trigger team_members_activity_joined
+  after insert on public.team_members
+  for each row
+  when (new.role = 'member')
+  execute function public.record_team_member_joined_event();
+
+-- 6. Keep 30 days of events
+create or replace function public.cleanup_account_events()
+returns integer
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  deleted integer;
+begin
+  delete from public.account_events
+  where created_at <= now() - interval '30 days';
+  get diagnostics deleted = row_count;
+  return deleted;
+end;
+$$;
+
+select cron.schedule(
+  'cleanup-account-events-daily',
+  '45 2 * * *',
+  'select public.cleanup_account_events();'
+);
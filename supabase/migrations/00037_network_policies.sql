@@ -0,0 +1,378 @@
+-- ============================================================================
+-- Migration 00037: Network policies
+--
+-- Optional per-endpoint network_policy, evaluated by capture_webhook()
+-- against the sender's IP and country:
+--   {"allow": {"countries": ["US", "CA"], "cidrs": ["203.0.113.0/24"]},
+--    "tags":  [{"tag": "aws", "cidrs": ["3.0.0.0/9", "52.0.0.0/10"]},
+--              {"tag": "eu", "countries": ["DE", "FR", "NL"]}]}
+-- A request that matches none of the allow rule's countries or ranges is
+-- rejected with status 'blocked' before the quota check, so it is neither
+-- stored nor counted. Each tag rule a stored request matches adds its tag to
+-- requests.tags, where the usual tag filters find it.
+--
+-- The country is the two-letter code Cloudflare puts in cf-ipcountry, passed
+-- by the receiver as p_country (null when absent, which matches no country
+-- list). There is no ASN lookup; cloud provider ranges are matched by CIDR.
+--
+-- endpoint_network_stats counts matches per rule ('blocked', 'tag:<name>')
+-- since the policy was last changed.
+-- ============================================================================
+
+-- 1. Validation: every listed range must parse as a CIDR, so matching can
+--    cast without checking
+create or replace function public.network_policy_valid(p_policy jsonb)
+returns boolean
+language plpgsql
+immutable
+set search_path = ''
+as $$
+declare
+  v_rule  jsonb;
+  v_cidr  text;
+begin
+  if jsonb_typeof(p_policy) <> 'object' then
+    return false;
+  end if;
+  for v_rule in
+    select p_policy -> 'allow' where p_policy ? 'allow'
+    union all
+    select value from jsonb_array_elements(coalesce(p_policy -> 'tags', '[]'))
+  loop
+    if jsonb_typeof(v_rule) <> 'object' then
+      return false;
+    end if;
+    for v_cidr in select jsonb_array_elements_text(coalesce(v_rule -> 'cidrs', '[]')) loop
+      perform v_cidr::cidr;
+    end loop;
+  end loop;
+  return true;
+exception when others then
+  return false;
+end;
+$$;
+
+alter table public.endpoints
+  add column if not exists network_policy jsonb;
+
+alter table public.endpoints
+  add constraint endpoints_network_policy_check
+  check (network_policy is null or public.network_policy_valid(network_policy));
+
+-- 2. Match counters
+create table public.endpoint_network_stats (
+  endpoint_id      uuid not null references public.endpoints(id) on delete cascade,
+  rule             text not null,
+  matched          bigint not null default 0,
+  last_matched_at  timestamptz not null default now(),
+  primary key (endpoint_id, rule)
+);
+
+-- Accessed only through the service role and the receiver's procedures.
+alter table public.endpoint_network_stats enable row level security;
+
+create or replace function public.count_network_match(p_endpoint_id uuid, p_rule text)
+returns void
+language sql
+security definer set search_path = ''
+as $$
+  insert into public.endpoint_network_stats (endpoint_id, rule, matched)
+  values (p_endpoint_id, p_rule, 1)
+  on conflict (endpoint_id, rule) do update
+    set matched = public.endpoint_network_stats.matched + 1,
+        last_matched_at = now();
+$$;
+
+-- Counts describe the current policy only
+create or replace function public.reset_network_stats()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  delete from public.endpoint_network_stats where endpoint_id = new.id;
+  return new;
+end;
+$$;
+
+create trigger endpoint_network_policy_changed
+  after update of network_policy on public.endpoints
+  for each row
+  when (old.network_policy is distinct from new.network_policy)
+  execute function public.reset_network_stats();
+
+-- 3. Whether a request's IP or country matches a rule's lists
+create or replace function public.network_rule_matches(p_rule jsonb, p_ip inet, p_country text)
+returns boolean
+language sql
+immutable
+set search_path = ''
+as $$
+  select (p_country is not null and coalesce(p_rule -> 'countries', '[]') ? p_country)
+      or (p_ip is not null and exists (
+            select 1
+              from jsonb_array_elements_text(coalesce(p_rule -> 'cidrs', '[]')) as c(cidr)
+             where p_ip <<= c.cidr::cidr
+          ));
+$$;
+
+-- 4. capture_webhook with optional 14th parameter p_country
+drop function if exists public.capture_webhook(
+  text, text, text, jsonb, text, jsonb, text, text, timestamptz, bytea, timestamptz, text, jsonb
+);
+
+create or replace function public.capture_webhook(
+  p_slug        text,
+  p_method      text,
+  p_path        text,
+  p_headers     jsonb,
+  p_body        text,
+  p_query_params jsonb,
+  p_content_type text,
+  p_ip          text,
+  p_received_at timestamptz,
+  p_body_raw    bytea default null,
+  p_bypass_expires timestamptz default null,
+  p_body_hash   text default null,
+  p_parts       jsonb default null,
+  p_country     text default null
+)
+returns jsonb
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_endpoint    record;
+  v_user        record;
+  v_quota       record;
+  v_period      record;
+  v_retry_after bigint;
+  v_size        integer;
+  v_mock        jsonb;
+  v_slug        text;
+  v_remaining   integer;
+  v_limit       integer;
+  v_reset       timestamptz;
+  v_duplicate_of uuid;
+  v_variant     record;
+  v_variant_index integer;
+  v_variant_name text;
+  v_roll        numeric;
+  v_cumulative  numeric;
+  v_policy      jsonb;
+  v_ip          inet;
+  v_tags        text[] := '{}';
+  v_tag         text;
+  v_rule        jsonb;
+begin
+  -- Normalize slug to lowercase for case-insensitive lookup
+  v_slug := lower(p_slug);
+
+  -- 1. Look up endpoint by slug
+  select id, user_id, is_ephemeral, expires_at, mock_response, request_count, notification_url,
+         function_sink, info_headers, paused_at, paused_response, network_policy
+    into v_endpoint
+    from public.endpoints
+   where slug = v_slug;
+
+  if not found then
+    return jsonb_build_object('status', 'not_found');
+  end if;
+
+  -- 2. Check expiry
+  if v_endpoint.expires_at is not null and v_endpoint.expires_at <= now() then
+    return jsonb_build_object('status', 'expired');
+  end if;
+
+  -- 3. Paused endpoints answer without storing, forwarding or counting the
+  --    request
+  if v_endpoint.paused_at is not null then
+    return jsonb_build_object('status', 'paused', 'paused_response', v_endpoint.paused_response);
+  end if;
+
+  -- 4. Network policy: requests outside the allow rule are rejected before
+  --    the quota check; tag rules label the ones that match
+  v_policy := v_endpoint.network_policy;
+  if v_policy is not null then
+    begin
+      v_ip := nullif(p_ip, '')::inet;
+    exception when others then
+      v_ip := null;
+    end;
+
+    if v_policy ? 'allow'
+       and not public.network_rule_matches(v_policy -> 'allow', v_ip, p_country)
+    then
+      perform public.count_network_match(v_endpoint.id, 'blocked');
+      return jsonb_build_object('status', 'blocked');
+    end if;
+
+    for v_rule in select value from jsonb_array_elements(coalesce(v_policy -> 'tags', '[]'))
+    loop
+      if public.network_rule_matches(v_rule, v_ip, p_country) then
+        v_tags := array_append(v_tags, v_rule ->> 'tag');
+      end if;
+    end loop;
+  end if;
+
+  -- 5. Quota check (branching by endpoint type). A verified bypass token
+  --    skips it entirely and is audited instead; usage isn't counted.
+  if p_bypass_expires is not null then
+    insert into public.quota_bypass_audit (endpoint_id, token_expires_at, ip, method, path)
+    values (v_endpoint.id, p_bypass_expires, p_ip, p_method, left(p_path, 1000));
+
+  elsif v_endpoint.is_ephemeral and v_endpoint.user_id is null then
+    -- Ephemeral endpoint: atomic increment with 25-request cap
+    select request_count into v_quota
+      from public.check_and_increment_ephemeral(v_endpoint.id);
+
+    if not found then
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'info', public.endpoint_info(v_endpoint.info_headers, 0, 25, null, v_endpoint.expires_at)
+      );
+    end if;
+
+    v_remaining := greatest(25 - v_quota.request_count, 0);
+    v_limit := 25;
+
+  elsif v_endpoint.user_id is not null then
+    -- Owned endpoint: check user quota
+    select id, plan, request_limit, requests_used, period_end
+      into v_user
+      from public.users
+     where id = v_endpoint.user_id;
+
+    if not found then
+      return jsonb_build_object('status', 'not_found');
+    end if;
+
+    -- Free user with expired or unstarted period: start a new one
+    if v_user.plan = 'free' and (v_user.period_end is null or v_user.period_end <= now()) then
+      select remaining, quota_limit, period_end_ts into v_period
+        from public.start_free_period(v_endpoint.user_id);
+
+      if not found then
+        -- Period start failed (shouldn't happen, but handle gracefully)
+        return jsonb_build_object('status', 'quota_exceeded');
+      end if;
+
+      -- Refresh user row after period reset
+      select id, plan, request_limit, requests_used, period_end
+        into v_user
+        from public.users
+       where id = v_endpoint.user_id;
+    end if;
+
+    -- Atomic quota check + decrement
+    select remaining, quota_limit, period_end_ts into v_quota
+      from public.check_and_decrement_quota(v_endpoint.user_id, 1);
+
+    if not found then
+      -- Quota exceeded
+      v_retry_after := null;
+      if v_user.period_end is not null and v_user.period_end > now() then
+        v_retry_after := extract(epoch from (v_user.period_end - now()))::bigint * 1000;
+      end if;
+
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'retry_after', v_retry_after,
+        'info', public.endpoint_info(
+          v_endpoint.info_headers, 0, v_user.request_limit, v_user.period_end, v_endpoint.expires_at
+        )
+      );
+    end if;
+
+    v_remaining := v_quota.remaining;
+    v_limit := v_quota.quota_limit;
+    v_reset := v_quota.period_end_ts;
+
+  end if;
+  -- else: owned endpoint with null user_id but not ephemeral — allow through (no quota)
+
+  -- 6. Duplicate detection: the same method, path and body as a capture in
+  --    the last 10 minutes points at the first request of that group
+  if p_body_hash is not null then
+    select coalesce(r.duplicate_of, r.id)
+      into v_duplicate_of
+      from public.requests r
+     where r.endpoint_id = v_endpoint.id
+       and r.body_hash = p_body_hash
+       and r.method = p_method
+       and r.path = p_path
+       and r.received_at > p_received_at - interval '10 minutes'
+     order by r.received_at desc
+     limit 1;
+  end if;
+
+  -- 7. Pick the mock response, rolling for a weighted variant when the
+  --    endpoint defines any
+  v_mock := null;
+  if v_endpoint.mock_response is not null
+     and jsonb_typeof(v_endpoint.mock_response) = 'object'
+     and (v_endpoint.mock_response ? 'status')
+  then
+    v_mock := v_endpoint.mock_response;
+
+    if jsonb_typeof(v_mock -> 'variants') = 'array'
+       and jsonb_array_length(v_mock -> 'variants') > 0
+    then
+      v_roll := random() * 100;
+      v_cumulative := 0;
+      for v_variant in
+        select value, ordinality - 1 as idx
+          from jsonb_array_elements(v_mock -> 'variants') with ordinality
+      loop
+        v_cumulative := v_cumulative + coalesce((v_variant.value ->> 'weight')::numeric, 0);
+        if v_roll < v_cumulative then
+          v_variant_index := v_variant.idx;
+          v_variant_name := v_variant.value ->> 'name';
+          exit;
+        end if;
+      end loop;
+      v_variant_name := coalesce(v_variant_name, 'default');
+    end if;
+  end if;
+
+  -- 8. Insert the request
+  -- Prefer raw byte length when available for accurate size
+  v_size := coalesce(octet_length(p_body_raw), octet_length(p_body), 0);
+
+  insert into public.requests (
+    endpoint_id, user_id, method, path, headers, body, body_raw,
+    query_params, content_type, ip, size, received_at, body_hash, duplicate_of,
+    mock_variant, parts, tags
+  ) values (
+    v_endpoint.id, v_endpoint.user_id, p_method, p_path, p_headers, p_body, p_body_raw,
+    p_query_params, p_content_type, p_ip, v_size, p_received_at, p_body_hash, v_duplicate_of,
+    v_variant_name, p_parts, v_tags
+  );
+
+  -- Count the tag rules the stored request matched
+  foreach v_tag in array v_tags loop
+    perform public.count_network_match(v_endpoint.id, 'tag:' || v_tag);
+  end loop;
+
+  -- 9. Increment endpoint request count (ephemeral already incremented above
+  --    unless the quota check was bypassed)
+  if p_bypass_expires is not null or not (v_endpoint.is_ephemeral and v_endpoint.user_id is null) then
+    perform public.increment_endpoint_request_count(v_endpoint.id, 1);
+  end if;
+
+  -- User requests_used already incremented by check_and_decrement_quota
+
+  -- 10. Build response
+  return jsonb_build_object(
+    'status', 'ok',
+    'mock_response', v_mock,
+    'mock_variant', v_variant_index,
+    'retry_after', null::bigint,
+    'notification_url', v_endpoint.notification_url,
+    'function_sink', v_endpoint.function_sink,
+    'info', public.endpoint_info(
+      v_endpoint.info_headers, v_remaining, v_limit, v_reset, v_endpoint.expires_at
+    )
+  );
+end;
+$$;
@@ -0,0 +1,256 @@
+-- ============================================================================
+-- Migration 00038: Demo mode for ephemeral endpoints
+--
+-- An ephemeral endpoint created with a demo config receives a steady trickle
+-- of synthetic captures, so docs, screenshots and onboarding have live data
+-- without real traffic:
+--   {"provider": "stripe", "template": "invoice.paid", "perMinute": 6}
+-- template is optional; without it the provider's templates take turns.
+--
+-- Captures are deterministic: capture n is received at created_at + n
+-- intervals and its IDs and amounts derive from md5(slug || ':' || n), so the
+-- same slug always produces the same data. They are tagged "demo", come from
+-- 203.0.113.10 (a documentation address) and carry no signatures. They count
+-- towards the endpoint's request count but not the owner's quota, and stop
+-- when the endpoint is paused or expires.
+--
+-- Payloads follow the shape of the SDK's send templates
+-- (packages/sdk/src/templates.ts) for the providers listed here.
+-- ============================================================================
+
+-- 1. Demo config
+alter table public.endpoints
+  add column if not exists demo jsonb,
+  add column if not exists demo_sequence bigint not null default 0;
+
+alter table public.endpoints
+  add constraint endpoints_demo_check
+  check (
+    demo is null
+    or (
+      is_ephemeral
+      and demo ->> 'provider' in ('stripe', 'github', 'shopify')
+      and jsonb_typeof(demo -> 'perMinute') = 'number'
+      and (demo ->> 'perMinute')::numeric between 1 and 30
+    )
+  );
+
+create index endpoints_demo
+  on public.endpoints(id)
+  where demo is not null;
+
+-- 2. One synthetic request: {headers, body}. p_key is 32 hex characters.
+create or replace function public.demo_request(
+  p_provider  text,
+  p_template  text,
+  p_key       text,
+  p_at        timestamptz
+)
+returns jsonb
+language plpgsql
+immutable
+set search_path = ''
+as $$
+declare
+  v_created  bigint := extract(epoch from p_at)::bigint;
+  v_id       text := substr(p_key, 1, 24);
+  v_number   integer := ('x' || substr(p_key, 25, 6))::bit(24)::integer;
+  v_amount   integer := (1 + v_number % 200) * 500;
+  v_body     jsonb;
+  v_headers  jsonb := jsonb_build_object('content-type', 'application/json');
+begin
+  if p_provider = 'stripe' then
+    v_headers := v_headers
+      || jsonb_build_object('user-agent', 'Stripe/1.0 (+https://stripe.com/docs/webhooks)');
+    v_body := jsonb_build_object(
+      'id', 'evt_' || v_id,
+      'object', 'event',
+      'api_version', '2025-01-27.acacia',
+      'created', v_created,
+      'livemode', false,
+      'pending_webhooks', 1,
+      'type', p_template,
+      'data', jsonb_build_object('object', case p_template
+        when 'checkout.session.completed' then jsonb_build_object(
+          'id', 'cs_test_' || v_id,
+          'object', 'checkout.session',
+          'mode', 'payment',
+          'payment_status', 'paid',
+          'status', 'complete',
+          'amount_total', v_amount,
+          'currency', 'usd',
+          'customer', 'cus_' || substr(v_id, 1, 14),
+          'payment_intent', 'pi_' || v_id,
+          'created', v_created
+        )
+        when 'invoice.paid' then jsonb_build_object(
+          'id', 'in_' || substr(v_id, 1, 14),
+          'object', 'invoice',
+          'amount_due', v_amount,
+          'amount_paid', v_amount,
+          'amount_remaining', 0,
+          'billing_reason', 'subscription_cycle',
+          'currency', 'usd',
+          'customer', 'cus_' || substr(v_id, 1, 14),
+          'paid', true,
+          'status', 'paid',
+          'created', v_created
+        )
+        else jsonb_build_object(
+          'id', 'pi_' || v_id,
+          'object', 'payment_intent',
+          'amount', v_amount,
+          'amount_received', v_amount,
+          'currency', 'usd',
+          'status', 'succeeded',
+          'created', v_created,
+          'metadata', jsonb_build_object('order_id', 'order_' || substr(v_id, 1, 12))
+        )
+      end)
+    );
+
+  elsif p_provider = 'github' then
+    v_headers := v_headers || jsonb_build_object(
+      'user-agent', 'GitHub-Hookshot/demo',
+      'x-github-event', split_part(p_template, '.', 1),
+      'x-github-delivery', p_key::uuid::text
+    );
+    if p_template = 'pull_request.opened' then
+      v_body := jsonb_build_object(
+        'action', 'opened',
+        'number', 1 + v_number % 500,
+        'pull_request', jsonb_build_object(
+          'number', 1 + v_number % 500,
+          'state', 'open',
+          'title', 'Handle webhook retries',
+          'html_url', 'https://github.com/webhooks-cc/demo/pull/' || (1 + v_number % 500),
+          'head', jsonb_build_object('ref', 'feature/retries', 'sha', p_key || substr(md5(p_key), 1, 8)),
+          'base', jsonb_build_object('ref', 'main'),
+          'created_at', to_char(p_at at time zone 'utc', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+        ),
+        'repository', jsonb_build_object('full_name', 'webhooks-cc/demo', 'private', false),
+        'sender', jsonb_build_object('login', 'webhooks-cc-bot', 'type', 'Bot')
+      );
+    else
+      v_body := jsonb_build_object(
+        'ref', 'refs/heads/main',
+        'before', md5(p_key) || substr(p_key, 1, 8),
+        'after', p_key || substr(md5(p_key), 1, 8),
+        'repository', jsonb_build_object('full_name', 'webhooks-cc/demo', 'private', false),
+        'pusher', jsonb_build_object('name', 'webhooks-cc-bot', 'email', 'bot@webhooks.cc'),
+        'head_commit', jsonb_build_object(
+          'id', p_key || substr(md5(p_key), 1, 8),
+          'message', 'Update webhook integration tests',
+          'timestamp', to_char(p_at at time zone 'utc', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+        ),
+        'sender', jsonb_build_object('login', 'webhooks-cc-bot', 'type', 'Bot')
+      );
+    end if;
+
+  else
+    v_headers := v_headers || jsonb_build_object(
+      'x-shopify-topic', p_template,
+      'x-shopify-shop-domain', 'webhooks-cc-demo.myshopify.com',
+      'x-shopify-webhook-id', p_key::uuid::text
+    );
+    v_body := jsonb_build_object(
+      'id', 5000000000 + v_number,
+      'order_number', 1000 + v_number % 9000,
+      'email', 'customer' || (v_number % 100) || '@example.com',
+      'currency', 'USD',
+      'total_price', to_char(v_amount / 100.0, 'FM999990.00'),
+      'financial_status', case p_template when 'orders/paid' then 'paid' else 'pending' end,
+      'created_at', to_char(p_at at time zone 'utc', 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+      'line_items', jsonb_build_array(jsonb_build_object(
+        'title', 'Webhook T-shirt',
+        'quantity', 1 + v_number % 3,
+        'price', to_char(v_amount / 100.0, 'FM999990.00')
+      ))
+    );
+  end if;
+
+  return jsonb_build_object('headers', v_headers, 'body', v_body::text);
+end;
+$$;
+
+-- 3. Insert the captures due since the last run. Runs every 10 seconds; a
+--    demo that fell behind (e.g. the job was down) skips to the latest 10.
+create or replace function public.generate_demo_requests()
+returns integer
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_endpoint   record;
+  v_interval   interval;
+  v_due        bigint;
+  v_templates  text[];
+  v_template   text;
+  v_at         timestamptz;
+  v_request    jsonb;
+  v_count      integer;
+  v_total      integer := 0;
+begin
+  for v_endpoint in
+    select id, user_id, slug, demo, demo_sequence, created_at
+      from public.endpoints
+     where demo is not null
+       and paused_at is null
+       and (expires_at is null or expires_at > now())
+       for update skip locked
+  loop
+    v_interval := make_interval(secs => 60 / (v_endpoint.demo ->> 'perMinute')::numeric);
+    v_due := floor(
+      extract(epoch from now() - v_endpoint.created_at) / extract(epoch from v_interval)
+    )::bigint + 1;
+    continue when v_due <= v_endpoint.demo_sequence;
+
+    v_templates := case v_endpoint.demo ->> 'provider'
+      when 'stripe' then array['payment_intent.succeeded', 'checkout.session.completed', 'invoice.paid']
+      when 'github' then array['push', 'pull_request.opened']
+      else array['orders/create', 'orders/paid']
+    end;
+    v_count := 0;
+
+    for v_seq in greatest(v_endpoint.demo_sequence, v_due - 10) .. v_due - 1 loop
+      v_template := coalesce(
+        v_endpoint.demo ->> 'template',
+        v_templates[1 + v_seq % array_length(v_templates, 1)]
+      );
+      v_at := v_endpoint.created_at + v_interval * v_seq;
+      v_request := public.demo_request(
+        v_endpoint.demo ->> 'provider', v_template, md5(v_endpoint.slug || ':' || v_seq), v_at
+      );
+
+      insert into public.requests (
+        endpoint_id, user_id, method, path, headers, body, query_params,
+        content_type, ip, size, received_at, tags
+      ) values (
+        v_endpoint.id, v_endpoint.user_id, 'POST', '/', v_request -> 'headers',
+        v_request ->> 'body', '{}'::jsonb, 'application/json', '203.0.113.10',
+        octet_length(v_request ->> 'body'), v_at, array['demo']
+      );
+      v_count := v_count + 1;
+    end loop;
+
+    update public.endpoints
+       set demo_sequence = v_due
+     where id = v_endpoint.id;
+    perform public.increment_endpoint_request_count(v_endpoint.id, v_count);
+    v_total := v_total + v_count;
+  end loop;
+
+  return v_total;
+end;
+$$;
+
+revoke all on function public.generate_demo_requests() from public;
+revoke all on function public.generate_demo_requests() from anon;
+revoke all on function public.generate_demo_requests() from authenticated;
+grant execute on function public.generate_demo_requests() to service_role;
+
+select cron.schedule(
+  'generate-demo-requests',
+  '10 seconds',
+  'select public.generate_demo_requests();'
+);
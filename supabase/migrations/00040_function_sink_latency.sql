@@ -0,0 +1,143 @@
+-- ============================================================================
+-- Migration 00040: Function sink delivery latency
+--
+-- The receiver times every function sink delivery it attempts and reports the
+-- timings in batches. Each delivery is split into two stages:
+--   relay        request received -> final invocation attempt sent
+--                (capture, queueing and retry backoff inside webhooks.cc)
+--   destination  final attempt sent -> destination responded
+-- so an owner can tell whether slowness is in their function or the relay.
+-- Shed and oversized payloads never reach the destination and are not timed;
+-- they are in function_sink_dead_letters.
+--
+-- function_sink_latency() returns p50/p90/p99 per destination over a window.
+-- Timings are kept for 7 days, like dead letters.
+-- ============================================================================
+
+-- 1. One row per timed delivery
+create table public.function_sink_deliveries (
+  id               bigint generated always as identity primary key,
+  endpoint_id      uuid not null references public.endpoints(id) on delete cascade,
+  target           text not null,
+  received_at      timestamptz not null,
+  relay_ms         integer not null check (relay_ms >= 0),
+  destination_ms   integer not null check (destination_ms >= 0),
+  attempts         integer not null,
+  delivered        boolean not null
+);
+
+create index function_sink_deliveries_endpoint
+  on public.function_sink_deliveries(endpoint_id, received_at desc);
+create index function_sink_deliveries_received
+  on public.function_sink_deliveries(received_at);
+
+-- Accessed only through the service role and the receiver's procedures.
+alter table public.function_sink_deliveries enable row level security;
+
+-- 2. Called by the receiver with the deliveries timed since its last report.
+--    The arrays are parallel; rows for unknown slugs are dropped.
+create or replace function public.record_function_sink_deliveries(
+  p_slugs           text[],
+  p_targets         text[],
+  p_received_at     timestamptz[],
+  p_relay_ms        integer[],
+  p_destination_ms  integer[],
+  p_attempts        integer[],
+  p_delivered       boolean[]
+)
+returns integer
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_count integer;
+begin
+  insert into public.function_sink_deliveries (
+    endpoint_id, target, received_at, relay_ms, destination_ms, attempts, delivered
+  )
+  select e.id, left(d.target, 500), d.received_at, greatest(d.relay_ms, 0),
+         greatest(d.destination_ms, 0), d.attempts, d.delivered
+    from unnest(
+           p_slugs, p_targets, p_received_at, p_relay_ms, p_destination_ms, p_attempts, p_delivered
+         ) as d(slug, target, received_at, relay_ms, destination_ms, attempts, delivered)
+    join public.endpoints e on e.slug = lower(d.slug);
+  get diagnostics v_count = row_count;
+  return v_count;
+end;
+$$;
+
+revoke all on function public.record_function_sink_deliveries(text[], text[], timestamptz[], integer[], integer[], integer[], boolean[]) from public;
+revoke all on function public.record_function_sink_deliveries(text[], text[], timestamptz[], integer[], integer[], integer[], boolean[]) from anon;
+revoke all on function public.record_function_sink_deliveries(text[], text[], timestamptz[], integer[], integer[], integer[], boolean[]) from authenticated;
+grant execute on function public.record_function_sink_deliveries(text[], text[], timestamptz[], integer[], integer[], integer[], boolean[]) to service_role;
+
+-- 3. Percentiles per destination for deliveries received since p_since
+create or replace function public.function_sink_latency(
+  p_endpoint_id  uuid,
+  p_since        timestamptz
+)
+returns table (
+  target           text,
+  deliveries       bigint,
+  failed           bigint,
+  retried          bigint,
+  relay_p50        double precision,
+  relay_p90        double precision,
+  relay_p99        double precision,
+  destination_p50  double precision,
+  destination_p90  double precision,
+  destination_p99  double precision,
+  total_p50        double precision,
+  total_p90        double precision,
+  total_p99        double precision
+)
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select d.target,
+         count(*),
+         count(*) filter (where not d.delivered),
+         count(*) filter (where d.attempts > 1),
+         percentile_cont(0.5) within group (order by d.relay_ms),
+         percentile_cont(0.9) within group (order by d.relay_ms),
+         percentile_cont(0.99) within group (order by d.relay_ms),
+         percentile_cont(0.5) within group (order by d.destination_ms),
+         percentile_cont(0.9) within group (order by d.destination_ms),
+         percentile_cont(0.99) within group (order by d.destination_ms),
+         percentile_cont(0.5) within group (order by d.relay_ms + d.destination_ms),
+         percentile_cont(0.9) within group (order by d.relay_ms + d.destination_ms),
+         percentile_cont(0.99) within group (order by d.relay_ms + d.destination_ms)
+    from public.function_sink_deliveries d
+   where d.endpoint_id = p_endpoint_id
+     and d.received_at >= p_since
+   group by d.target
+   order by d.target;
+$$;
+
+revoke all on function public.function_sink_latency(uuid, timestamptz) from public;
+revoke all on function public.function_sink_latency(uuid, timestamptz) from anon;
+revoke all on function public.function_sink_latency(uuid, timestamptz) from authenticated;
+grant execute on function public.function_sink_latency(uuid, timestamptz) to service_role;
+
+-- 4. Keep 7 days of timings
+create or replace function public.cleanup_function_sink_deliveries()
+returns integer
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  deleted integer;
+begin
+  delete from public.function_sink_deliveries
+  where received_at <= now() - interval '7 days';
+  get diagnostics deleted = row_count;
+  return deleted;
+end;
+$$;
+
+select cron.schedule(
+  'cleanup-function-sink-deliveries-daily',
+  '40 2 * * *',
+  'select public.cleanup_function_sink_deliveries();'
+);
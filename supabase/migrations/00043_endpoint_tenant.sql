@@ -0,0 +1,28 @@
+-- ============================================================================
+-- Migration 00043: Endpoint tenant lookup
+--
+-- The receiver limits requests in flight, the bytes they hold and function
+-- sink deliveries per account, so one account's burst can't slow capture for
+-- everyone else on the instance. It needs the account behind a slug before
+-- capture_webhook() runs, and reads it through get_endpoint_tenant(), cached
+-- per slug like the transform and header encryption lookups.
+--
+-- The tenant is the owner's user id, or 'endpoint:<id>' for an unowned
+-- ephemeral endpoint, which is its own tenant. Unknown slugs return null.
+-- ============================================================================
+
+create or replace function public.get_endpoint_tenant(p_slug text)
+returns text
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select coalesce(user_id::text, 'endpoint:' || id::text)
+    from public.endpoints
+   where slug = lower(p_slug);
+$$;
+
+revoke all on function public.get_endpoint_tenant(text) from public;
+revoke all on function public.get_endpoint_tenant(text) from anon;
+revoke all on function public.get_endpoint_tenant(text) from authenticated;
+grant execute on function public.get_endpoint_tenant(text) to service_role;
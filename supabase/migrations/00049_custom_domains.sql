@@ -0,0 +1,169 @@
+-- ============================================================================
+-- Migration 00049: Custom domains
+--
+-- A Pro endpoint can be given its own hostname (endpoints.custom_domain,
+-- e.g. hooks.example.com). The owner points it at the receiver, which serves
+-- it on a separate TLS listener and routes every request on it to the
+-- endpoint. Lookups go through get_custom_domain_slug(), which only answers
+-- for endpoints whose owner is on the Pro plan.
+--
+-- Certificates are issued by an ACME CA (Let's Encrypt by default) on first
+-- use and kept in custom_domain_certificates so every receiver instance and
+-- restart reuses them; the CA account is kept in acme_accounts, one per
+-- directory URL. Both tables are only reached through the functions below.
+-- Changes to custom_domain are announced on the endpoint_config channel.
+-- ============================================================================
+
+-- 1. Per-endpoint hostname
+alter table public.endpoints
+  add column if not exists custom_domain text;
+
+alter table public.endpoints
+  add constraint endpoints_custom_domain_check
+  check (
+    custom_domain is null
+    or (
+      char_length(custom_domain) <= 253
+      and custom_domain ~ '^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$'
+      and custom_domain <> 'webhooks.cc'
+      and custom_domain not like '%.webhooks.cc'
+    )
+  );
+
+create unique index if not exists idx_endpoints_custom_domain
+  on public.endpoints (custom_domain)
+  where custom_domain is not null;
+
+-- 2. Issued certificates and ACME accounts
+create table if not exists public.custom_domain_certificates (
+  hostname   text primary key,
+  cert_pem   text not null,
+  key_pem    text not null,
+  not_after  timestamptz not null,
+  updated_at timestamptz not null default now()
+);
+
+create table if not exists public.acme_accounts (
+  directory  text primary key,
+  key_pem    text not null,
+  url        text not null,
+  created_at timestamptz not null default now()
+);
+
+-- Accessed only through the service role and the receiver's procedures.
+alter table public.custom_domain_certificates enable row level security;
+alter table public.acme_accounts enable row level security;
+
+-- 3. Lookups and writes used by the receiver
+create or replace function public.get_custom_domain_slug(p_hostname text)
+returns text
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select e.slug
+    from public.endpoints e
+    join public.users u on u.id = e.user_id
+   where e.custom_domain = lower(p_hostname)
+     and u.plan = 'pro';
+$$;
+
+create or replace function public.get_custom_domain_certificate(p_hostname text)
+returns jsonb
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select jsonb_build_object('cert_pem', cert_pem, 'key_pem', key_pem)
+    from public.custom_domain_certificates
+   where hostname = lower(p_hostname);
+$$;
+
+create or replace function public.save_custom_domain_certificate(
+  p_hostname  text,
+  p_cert_pem  text,
+  p_key_pem   text,
+  p_not_after timestamptz
+)
+returns void
+language sql
+security definer set search_path = ''
+as $$
+  insert into public.custom_domain_certificates (hostname, cert_pem, key_pem, not_after)
+  values (lower(p_hostname), p_cert_pem, p_key_pem, p_not_after)
+  on conflict (hostname) do update
+    set cert_pem = excluded.cert_pem,
+        key_pem = excluded.key_pem,
+        not_after = excluded.not_after,
+        updated_at = now();
+$$;
+
+create or replace function public.get_acme_account(p_directory text)
+returns jsonb
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select jsonb_build_object('key_pem', key_pem, 'url', url)
+    from public.acme_accounts
+   where directory = p_directory;
+$$;
+
+-- The first account registered for a directory is kept; an instance that
+-- registered at the same time keeps using its own until it restarts.
+create or replace function public.save_acme_account(p_directory text, p_key_pem text, p_url text)
+returns void
+language sql
+security definer set search_path = ''
+as $$
+  insert into public.acme_accounts (directory, key_pem, url)
+  values (p_directory, p_key_pem, p_url)
+  on conflict (directory) do nothing;
+$$;
+
+revoke all on function public.get_custom_domain_slug(text) from public;
+revoke all on function public.get_custom_domain_slug(text) from anon;
+revoke all on function public.get_custom_domain_slug(text) from authenticated;
+grant execute on function public.get_custom_domain_slug(text) to service_role;
+
+revoke all on function public.get_custom_domain_certificate(text) from public;
+revoke all on function public.get_custom_domain_certificate(text) from anon;
+revoke all on function public.get_custom_domain_certificate(text) from authenticated;
+grant execute on function public.get_custom_domain_certificate(text) to service_role;
+
+revoke all on function public.save_custom_domain_certificate(text, text, text, timestamptz) from public;
+revoke all on function public.save_custom_domain_certificate(text, text, text, timestamptz) from anon;
+revoke all on function public.save_custom_domain_certificate(text, text, text, timestamptz) from authenticated;
+grant execute on function public.save_custom_domain_certificate(text, text, text, timestamptz) to service_role;
+
+revoke all on function public.get_acme_account(text) from public;
+revoke all on function public.get_acme_account(text) from anon;
+revoke all on function public.get_acme_account(text) from authenticated;
+grant execute on function public.get_acme_account(text) to service_role;
+
+revoke all on function public.save_acme_account(text, text, text) from public;
+revoke all on function public.save_acme_account(text, text, text) from anon;
+revoke all on function public.save_acme_account(text, text, text) from authenticated;
+grant execute on function public.save_acme_account(text, text, text) to service_role;
+
+-- 4. Tell receivers to drop their cached mapping when it changes, and forget
+--    the certificate of a hostname that is no longer used
+create or replace function public.notify_custom_domain_change()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  if old.custom_domain is not null then
+    delete from public.custom_domain_certificates where hostname = old.custom_domain;
+  end if;
+  perform pg_notify('endpoint_config', new.slug);
+  return new;
+end;
+$$;
+
+create trigger endpoint_custom_domain_changed
+  after update of custom_domain on public.endpoints
+  for each row
+  when (old.custom_domain is distinct from new.custom_domain)
+  execute function public.notify_custom_domain_change();
@@ -0,0 +1,206 @@
+-- ============================================================================
+-- Migration 00050: Usage reports
+--
+-- Monthly per-endpoint reports (volumes, sizes, top senders, quota share and
+-- capture failures) for chargeback. Requests are only kept for 7 or 31 days,
+-- so each day's captures are rolled up into endpoint_usage_daily shortly
+-- after midnight UTC, before the retention jobs run, and reports are built
+-- from the rollups plus the live rows of days not rolled up yet.
+--
+-- Senders are kept per day as the 50 busiest IPs, so monthly sender counts
+-- are exact for anyone who was in a day's top 50 and a lower bound otherwise.
+-- ============================================================================
+
+-- 1. Daily rollups
+create table public.endpoint_usage_daily (
+  endpoint_id  uuid not null references public.endpoints(id) on delete cascade,
+  day          date not null,
+  requests     integer not null,
+  bytes        bigint not null,
+  max_size     integer not null,
+  methods      jsonb not null default '{}'::jsonb,
+  senders      jsonb not null default '{}'::jsonb,
+  primary key (endpoint_id, day)
+);
+
+-- Accessed only through the service role.
+alter table public.endpoint_usage_daily enable row level security;
+
+-- 2. Aggregate one UTC day of requests per endpoint
+create or replace function public.endpoint_usage_for_day(p_endpoint_id uuid, p_day date)
+returns table (
+  endpoint_id uuid,
+  day         date,
+  requests    integer,
+  bytes       bigint,
+  max_size    integer,
+  methods     jsonb,
+  senders     jsonb
+)
+language sql
+stable
+security definer set search_path = ''
+as $$
+  with day_requests as (
+    select r.endpoint_id, r.method, r.ip, r.size
+      from public.requests r
+     where (p_endpoint_id is null or r.endpoint_id = p_endpoint_id)
+       and r.received_at >= p_day::timestamp at time zone 'utc'
+       and r.received_at < (p_day + 1)::timestamp at time zone 'utc'
+  ),
+  methods as (
+    select m.endpoint_id, jsonb_object_agg(m.method, m.count) as methods
+      from (
+        select d.endpoint_id, d.method, count(*) as count
+          from day_requests d
+         group by d.endpoint_id, d.method
+      ) m
+     group by m.endpoint_id
+  ),
+  senders as (
+    select s.endpoint_id, jsonb_object_agg(s.ip, s.count) as senders
+      from (
+        select d.endpoint_id, d.ip, count(*) as count,
+               row_number() over (partition by d.endpoint_id order by count(*) desc, d.ip) as position
+          from day_requests d
+         group by d.endpoint_id, d.ip
+      ) s
+     where s.position <= 50
+     group by s.endpoint_id
+  )
+  select d.endpoint_id, p_day, count(*)::integer, coalesce(sum(d.size), 0)::bigint,
+         coalesce(max(d.size), 0), m.methods, s.senders
+    from day_requests d
+    join methods m on m.endpoint_id = d.endpoint_id
+    join senders s on s.endpoint_id = d.endpoint_id
+   group by d.endpoint_id, m.methods, s.senders;
+$$;
+
+create or replace function public.rollup_endpoint_usage(p_day date)
+returns integer
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_count integer;
+begin
+  insert into public.endpoint_usage_daily (endpoint_id, day, requests, bytes, max_size, methods, senders)
+  select u.endpoint_id, u.day, u.requests, u.bytes, u.max_size, u.methods, u.senders
+    from public.endpoint_usage_for_day(null, p_day) u
+  on conflict (endpoint_id, day) do update
+    set requests = excluded.requests,
+        bytes = excluded.bytes,
+        max_size = excluded.max_size,
+        methods = excluded.methods,
+        senders = excluded.senders;
+  get diagnostics v_count = row_count;
+  return v_count;
+end;
+$$;
+
+-- Yesterday, before cleanup_old_requests (01:00) and cleanup_free_user_requests (01:30)
+select cron.schedule(
+  'rollup-endpoint-usage-daily',
+  '20 0 * * *',
+  $$select public.rollup_endpoint_usage((now() at time zone 'utc')::date - 1);$$
+);
+
+-- 3. One endpoint's report for [p_from, p_to). Days without a rollup yet
+--    (today, or a missed run) come from the live rows.
+create or replace function public.endpoint_usage_report(
+  p_endpoint_id  uuid,
+  p_from         date,
+  p_to           date
+)
+returns jsonb
+language sql
+stable
+security definer set search_path = ''
+as $$
+  with rolled as (
+    select d.day, d.requests, d.bytes, d.max_size, d.methods, d.senders
+      from public.endpoint_usage_daily d
+     where d.endpoint_id = p_endpoint_id
+       and d.day >= p_from
+       and d.day < p_to
+  ),
+  live as (
+    select u.day, u.requests, u.bytes, u.max_size, u.methods, u.senders
+      from generate_series(
+             p_from::timestamp,
+             least(p_to - 1, (now() at time zone 'utc')::date)::timestamp,
+             interval '1 day'
+           ) as g(day)
+     cross join lateral public.endpoint_usage_for_day(p_endpoint_id, g.day::date) u
+     where g.day::date not in (select day from rolled)
+  ),
+  report_days as (
+    select * from rolled
+    union all
+    select * from live
+  )
+  select jsonb_build_object(
+    'requests', (select coalesce(sum(requests), 0) from report_days),
+    'bytes', (select coalesce(sum(bytes), 0) from report_days),
+    'max_size', (select coalesce(max(max_size), 0) from report_days),
+    'days', (
+      select coalesce(
+               jsonb_agg(jsonb_build_object('day', day, 'requests', requests, 'bytes', bytes)
+                         order by day),
+               '[]'::jsonb)
+        from report_days
+    ),
+    'methods', (
+      select coalesce(jsonb_object_agg(m.key, m.count), '{}'::jsonb)
+        from (
+          select e.key, sum(e.value::bigint) as count
+            from report_days r
+           cross join lateral jsonb_each_text(r.methods) e
+           group by e.key
+        ) m
+    ),
+    'top_senders', (
+      select coalesce(
+               jsonb_agg(jsonb_build_object('ip', s.key, 'requests', s.count)
+                         order by s.count desc, s.key),
+               '[]'::jsonb)
+        from (
+          select e.key, sum(e.value::bigint) as count
+            from report_days r
+           cross join lateral jsonb_each_text(r.senders) e
+           group by e.key
+           order by count desc, e.key
+           limit 10
+        ) s
+    ),
+    'capture_failures', (
+      select coalesce(sum(f.failed_count), 0)
+        from public.capture_failures f
+       where f.endpoint_id = p_endpoint_id
+         and f.window_start < p_to::timestamp at time zone 'utc'
+         and f.window_end >= p_from::timestamp at time zone 'utc'
+    ),
+    'capture_failure_windows', (
+      select count(*)
+        from public.capture_failures f
+       where f.endpoint_id = p_endpoint_id
+         and f.window_start < p_to::timestamp at time zone 'utc'
+         and f.window_end >= p_from::timestamp at time zone 'utc'
+    )
+  );
+$$;
+
+revoke all on function public.endpoint_usage_for_day(uuid, date) from public;
+revoke all on function public.endpoint_usage_for_day(uuid, date) from anon;
+revoke all on function public.endpoint_usage_for_day(uuid, date) from authenticated;
+grant execute on function public.endpoint_usage_for_day(uuid, date) to service_role;
+
+revoke all on function public.rollup_endpoint_usage(date) from public;
+revoke all on function public.rollup_endpoint_usage(date) from anon;
+revoke all on function public.rollup_endpoint_usage(date) from authenticated;
+grant execute on function public.rollup_endpoint_usage(date) to service_role;
+
+revoke all on function public.endpoint_usage_report(uuid, date, date) from public;
+revoke all on function public.endpoint_usage_report(uuid, date, date) from anon;
+revoke all on function public.endpoint_usage_report(uuid, date, date) from authenticated;
+grant execute on function public.endpoint_usage_report(uuid, date, date) to service_role;
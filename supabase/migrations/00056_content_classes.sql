@@ -0,0 +1,521 @@
+-- ============================================================================
+-- Migration 00056: Content classes
+--
+-- The receiver classifies each body as json, xml, form, text, image,
+-- protobuf (a best guess for unlabelled binary) or binary, from its bytes
+-- and content type, and passes it as p_content_class. It is stored in
+-- requests.content_class so clients pick a renderer without sniffing the
+-- body, and search_requests / search_requests_count take p_content_class
+-- to filter by it. Empty bodies and requests captured before this
+-- migration have no class.
+-- ============================================================================
+
+-- 1. Content class on requests
+alter table public.requests add column if not exists content_class text;
+
+create index if not exists requests_user_content_class
+  on public.requests(user_id, content_class, received_at desc)
+  where content_class is not null;
+
+-- 2. capture_webhook with an optional 27th parameter p_content_class
+drop function if exists public.capture_webhook(
+  text, text, text, jsonb, text, jsonb, text, text, timestamptz, bytea, timestamptz, text, jsonb, text,
+  text, integer, jsonb, jsonb, text, text, jsonb, jsonb, text, text, text, text
+);
+
+create or replace function public.capture_webhook(
+  p_slug        text,
+  p_method      text,
+  p_path        text,
+  p_headers     jsonb,
+  p_body        text,
+  p_query_params jsonb,
+  p_content_type text,
+  p_ip          text,
+  p_received_at timestamptz,
+  p_body_raw    bytea default null,
+  p_bypass_expires timestamptz default null,
+  p_body_hash   text default null,
+  p_parts       jsonb default null,
+  p_country     text default null,
+  p_body_ref    text default null,
+  p_body_size   integer default null,
+  p_frame       jsonb default null,
+  p_cloud_event jsonb default null,
+  p_canonical_hash text default null,
+  p_http_version text default null,
+  p_client_cert jsonb default null,
+  p_trailers    jsonb default null,
+  p_delivery_key text default null,
+  p_fingerprint text default null,
+  p_provider    text default null,
+  p_event_type  text default null,
+  p_content_class text default null
+)
+returns jsonb
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_endpoint    record;
+  v_user        record;
+  v_quota       record;
+  v_period      record;
+  v_retry_after bigint;
+  v_size        integer;
+  v_mock        jsonb;
+  v_slug        text;
+  v_remaining   integer;
+  v_limit       integer;
+  v_reset       timestamptz;
+  v_duplicate_of uuid;
+  v_variant     record;
+  v_variant_index integer;
+  v_variant_name text;
+  v_roll        numeric;
+  v_cumulative  numeric;
+  v_policy      jsonb;
+  v_ip          inet;
+  v_tags        text[] := '{}';
+  v_tag         text;
+  v_rule        jsonb;
+  v_request_id  uuid;
+  v_body_hash   text;
+  v_priority    boolean := false;
+begin
+  -- Normalize slug to lowercase for case-insensitive lookup
+  v_slug := lower(p_slug);
+
+  -- 1. Look up endpoint by slug
+  select id, user_id, is_ephemeral, expires_at, mock_response, request_count, notification_url,
+         function_sink, info_headers, paused_at, paused_response, network_policy,
+         record_responses, canonical_json, priority_rule, dry_run
+    into v_endpoint
+    from public.endpoints
+   where slug = v_slug;
+
+  if not found then
+    return jsonb_build_object('status', 'not_found');
+  end if;
+
+  -- 2. Check expiry
+  if v_endpoint.expires_at is not null and v_endpoint.expires_at <= now() then
+    return jsonb_build_object('status', 'expired');
+  end if;
+
+  -- 3. Paused endpoints answer without storing, forwarding or counting the
+  --    request
+  if v_endpoint.paused_at is not null then
+    return jsonb_build_object('status', 'paused', 'paused_response', v_endpoint.paused_response);
+  end if;
+
+  -- 4. Network policy: requests outside the allow rule are rejected before
+  --    the quota check; tag rules label the ones that match
+  v_policy := v_endpoint.network_policy;
+  if v_policy is not null then
+    begin
+      v_ip := nullif(p_ip, '')::inet;
+    exception when others then
+      v_ip := null;
+    end;
+
+    if v_policy ? 'allow'
+       and not public.network_rule_matches(v_policy -> 'allow', v_ip, p_country)
+    then
+      perform public.count_network_match(v_endpoint.id, 'blocked');
+      return jsonb_build_object('status', 'blocked');
+    end if;
+
+    for v_rule in select value from jsonb_array_elements(coalesce(v_policy -> 'tags', '[]'))
+    loop
+      if public.network_rule_matches(v_rule, v_ip, p_country) then
+        v_tags := array_append(v_tags, v_rule ->> 'tag');
+      end if;
+    end loop;
+  end if;
+
+  -- 5. Quota check (branching by endpoint type). A verified bypass token
+  --    skips it entirely and is audited instead; usage isn't counted.
+  --    Dry-run captures are never stored, so they aren't counted either.
+  if v_endpoint.dry_run then
+    null;
+
+  elsif p_bypass_expires is not null then
+    insert into public.quota_bypass_audit (endpoint_id, token_expires_at, ip, method, path)
+    values (v_endpoint.id, p_bypass_expires, p_ip, p_method, left(p_path, 1000));
+
+  elsif v_endpoint.is_ephemeral and v_endpoint.user_id is null then
+    -- Ephemeral endpoint: atomic increment with 25-request cap
+    select request_count into v_quota
+      from public.check_and_increment_ephemeral(v_endpoint.id);
+
+    if not found then
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'info', public.endpoint_info(v_endpoint.info_headers, 0, 25, null, v_endpoint.expires_at)
+      );
+    end if;
+
+    v_remaining := greatest(25 - v_quota.request_count, 0);
+    v_limit := 25;
+
+  elsif v_endpoint.user_id is not null then
+    -- Owned endpoint: check user quota
+    select id, plan, request_limit, requests_used, period_end
+      into v_user
+      from public.users
+     where id = v_endpoint.user_id;
+
+    if not found then
+      return jsonb_build_object('status', 'not_found');
+    end if;
+
+    -- Free user with expired or unstarted period: start a new one
+    if v_user.plan = 'free' and (v_user.period_end is null or v_user.period_end <= now()) then
+      select remaining, quota_limit, period_end_ts into v_period
+        from public.start_free_period(v_endpoint.user_id);
+
+      if not found then
+        -- Period start failed (shouldn't happen, but handle gracefully)
+        return jsonb_build_object('status', 'quota_exceeded');
+      end if;
+
+      -- Refresh user row after period reset
+      select id, plan, request_limit, requests_used, period_end
+        into v_user
+        from public.users
+       where id = v_endpoint.user_id;
+    end if;
+
+    -- Atomic quota check + decrement
+    select remaining, quota_limit, period_end_ts into v_quota
+      from public.check_and_decrement_quota(v_endpoint.user_id, 1);
+
+    if not found then
+      -- Quota exceeded
+      v_retry_after := null;
+      if v_user.period_end is not null and v_user.period_end > now() then
+        v_retry_after := extract(epoch from (v_user.period_end - now()))::bigint * 1000;
+      end if;
+
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'retry_after', v_retry_after,
+        'info', public.endpoint_info(
+          v_endpoint.info_headers, 0, v_user.request_limit, v_user.period_end, v_endpoint.expires_at
+        )
+      );
+    end if;
+
+    v_remaining := v_quota.remaining;
+    v_limit := v_quota.quota_limit;
+    v_reset := v_quota.period_end_ts;
+
+  end if;
+  -- else: owned endpoint with null user_id but not ephemeral — allow through (no quota)
+
+  -- 6. Duplicate detection: a capture carrying a provider delivery key
+  --    points at the first request with the same key in the last 3 days.
+  --    Without a key, the same method, path and body as a capture in the
+  --    last 10 minutes points at the first request of that group.
+  --    Endpoints comparing canonical JSON hash the canonical form instead,
+  --    when the receiver sent one (the body is JSON and not already canonical).
+  v_body_hash := p_body_hash;
+  if v_endpoint.canonical_json and p_canonical_hash is not null then
+    v_body_hash := p_canonical_hash;
+  end if;
+
+  if p_delivery_key is not null then
+    select coalesce(r.duplicate_of, r.id)
+      into v_duplicate_of
+      from public.requests r
+     where r.endpoint_id = v_endpoint.id
+       and r.delivery_key = p_delivery_key
+       and r.received_at > p_received_at - interval '3 days'
+     order by r.received_at desc
+     limit 1;
+  elsif v_body_hash is not null then
+    select coalesce(r.duplicate_of, r.id)
+      into v_duplicate_of
+      from public.requests r
+     where r.endpoint_id = v_endpoint.id
+       and r.body_hash = v_body_hash
+       and r.method = p_method
+       and r.path = p_path
+       and r.received_at > p_received_at - interval '10 minutes'
+     order by r.received_at desc
+     limit 1;
+  end if;
+
+  -- 7. Pick the mock response, rolling for a weighted variant when the
+  --    endpoint defines any
+  v_mock := null;
+  if v_endpoint.mock_response is not null
+     and jsonb_typeof(v_endpoint.mock_response) = 'object'
+     and (v_endpoint.mock_response ? 'status')
+  then
+    v_mock := v_endpoint.mock_response;
+
+    if jsonb_typeof(v_mock -> 'variants') = 'array'
+       and jsonb_array_length(v_mock -> 'variants') > 0
+    then
+      v_roll := random() * 100;
+      v_cumulative := 0;
+      for v_variant in
+        select value, ordinality - 1 as idx
+          from jsonb_array_elements(v_mock -> 'variants') with ordinality
+      loop
+        v_cumulative := v_cumulative + coalesce((v_variant.value ->> 'weight')::numeric, 0);
+        if v_roll < v_cumulative then
+          v_variant_index := v_variant.idx;
+          v_variant_name := v_variant.value ->> 'name';
+          exit;
+        end if;
+      end loop;
+      v_variant_name := coalesce(v_variant_name, 'default');
+    end if;
+  end if;
+
+  -- High priority when the endpoint's priority header is present and, if the
+  -- rule lists values, matches one of them. Header names arrive lowercased.
+  if v_endpoint.priority_rule is not null
+     and p_headers ? (v_endpoint.priority_rule ->> 'header') then
+    v_priority := jsonb_array_length(coalesce(v_endpoint.priority_rule -> 'values', '[]'::jsonb)) = 0
+      or (v_endpoint.priority_rule -> 'values')
+         ? lower(trim(p_headers ->> (v_endpoint.priority_rule ->> 'header')));
+  end if;
+
+  -- Offloaded bodies report their own size; otherwise prefer raw byte length
+  -- when available for accurate size
+  v_size := coalesce(p_body_size, octet_length(p_body_raw), octet_length(p_body), 0);
+
+  -- Dry run: count the request and the tag rules it matched, then answer as
+  -- if it had been stored, without notifications, the function sink or
+  -- response recording
+  if v_endpoint.dry_run then
+    perform public.count_dry_run(v_endpoint.id, v_size, v_mock is not null);
+    foreach v_tag in array v_tags loop
+      perform public.count_network_match(v_endpoint.id, 'tag:' || v_tag);
+    end loop;
+
+    return jsonb_build_object(
+      'status', 'ok',
+      'mock_response', v_mock,
+      'mock_variant', v_variant_index,
+      'retry_after', null::bigint,
+      'info', public.endpoint_info(
+        v_endpoint.info_headers, null, null, null, v_endpoint.expires_at
+      ),
+      'dry_run', true
+    );
+  end if;
+
+  -- 8. Insert the request
+
+  insert into public.requests (
+    endpoint_id, user_id, method, path, headers, body, body_raw,
+    query_params, content_type, ip, size, received_at, body_hash, duplicate_of,
+    mock_variant, parts, tags, body_ref, frame, cloud_event, http_version, priority,
+    client_cert, trailers, delivery_key, fingerprint, provider, event_type, content_class
+  ) values (
+    v_endpoint.id, v_endpoint.user_id, p_method, p_path, p_headers, p_body, p_body_raw,
+    p_query_params, p_content_type, p_ip, v_size, p_received_at, v_body_hash, v_duplicate_of,
+    v_variant_name, p_parts, v_tags, p_body_ref, p_frame, p_cloud_event, p_http_version, v_priority,
+    p_client_cert, p_trailers, p_delivery_key, p_fingerprint, p_provider, p_event_type,
+    p_content_class
+  )
+  returning id into v_request_id;
+
+  -- Count the tag rules the stored request matched
+  foreach v_tag in array v_tags loop
+    perform public.count_network_match(v_endpoint.id, 'tag:' || v_tag);
+  end loop;
+
+  -- 9. Increment endpoint request count (ephemeral already incremented above
+  --    unless the quota check was bypassed)
+  if p_bypass_expires is not null or not (v_endpoint.is_ephemeral and v_endpoint.user_id is null) then
+    perform public.increment_endpoint_request_count(v_endpoint.id, 1);
+  end if;
+
+  -- User requests_used already incremented by check_and_decrement_quota
+
+  -- 10. Build response
+  return jsonb_build_object(
+    'status', 'ok',
+    'mock_response', v_mock,
+    'mock_variant', v_variant_index,
+    'retry_after', null::bigint,
+    'notification_url', v_endpoint.notification_url,
+    'function_sink', v_endpoint.function_sink,
+    'info', public.endpoint_info(
+      v_endpoint.info_headers, v_remaining, v_limit, v_reset, v_endpoint.expires_at
+    ),
+    'record_response_id', case when v_endpoint.record_responses then v_request_id end,
+    'priority', v_priority
+  );
+end;
+$$;
+
+-- 3. Search filters by content class and returns it
+drop function if exists public.search_requests(uuid, text, text, text, text, bigint, bigint, integer, integer, text);
+drop function if exists public.search_requests_count(uuid, text, text, text, text, bigint, bigint);
+
+create or replace function public.search_requests(
+  p_user_id uuid,
+  p_plan text default null,
+  p_slug text default null,
+  p_method text default null,
+  p_q text default null,
+  p_from_ms bigint default null,
+  p_to_ms bigint default null,
+  p_limit integer default 50,
+  p_offset integer default 0,
+  p_order text default 'desc',
+  p_content_class text default null
+)
+returns table(
+  id text,
+  slug text,
+  method text,
+  path text,
+  headers jsonb,
+  body text,
+  query_params jsonb,
+  content_type text,
+  ip text,
+  size integer,
+  received_at bigint,
+  content_class text
+)
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_plan text := coalesce(p_plan, 'pro');
+  v_limit integer := least(greatest(coalesce(p_limit, 50), 1), 200);
+  v_offset integer := least(greatest(coalesce(p_offset, 0), 0), 10000);
+  v_order text := case when lower(coalesce(p_order, 'desc')) = 'asc' then 'asc' else 'desc' end;
+  v_from timestamptz := case
+    when p_from_ms is null then null
+    else to_timestamp(p_from_ms::double precision / 1000.0)
+  end;
+  v_to timestamptz := case
+    when p_to_ms is null then null
+    else to_timestamp(p_to_ms::double precision / 1000.0)
+  end;
+  v_retention_cutoff timestamptz := case
+    when v_plan = 'free' then now() - interval '7 days'
+    else null
+  end;
+  v_q text := nullif(btrim(p_q), '');
+begin
+  if v_plan not in ('free', 'pro') then
+    raise exception 'invalid plan' using errcode = '22023';
+  end if;
+
+  return query execute format(
+    'select
+       r.id::text,
+       e.slug,
+       r.method,
+       r.path,
+       r.headers,
+       nullif(r.body, ''''),
+       r.query_params,
+       nullif(r.content_type, ''''),
+       r.ip,
+       r.size,
+       floor(extract(epoch from r.received_at) * 1000)::bigint,
+       r.content_class
+     from public.requests r
+     join public.endpoints e on e.id = r.endpoint_id
+     where r.user_id = $1
+       and ($2 is null or e.slug = $2)
+       and ($3 is null or $3 = ''ALL'' or r.method = $3)
+       and (
+         $4 is null
+         or r.path ilike ''%%'' || $4 || ''%%''
+         or coalesce(r.body, '''') ilike ''%%'' || $4 || ''%%''
+         or r.headers::text ilike ''%%'' || $4 || ''%%''
+       )
+       and ($5 is null or r.received_at >= $5)
+       and ($6 is null or r.received_at <= $6)
+       and ($7 is null or r.received_at >= $7)
+       and ($8 is null or r.content_class = $8)
+     order by r.received_at %s
+     limit %s offset %s',
+    v_order,
+    v_limit,
+    v_offset
+  )
+  using p_user_id, nullif(btrim(p_slug), ''), nullif(btrim(p_method), ''), v_q, v_from, v_to, v_retention_cutoff,
+    nullif(btrim(p_content_class), '');
+end;
+$$;
+
+create or replace function public.search_requests_count(
+  p_user_id uuid,
+  p_plan text default null,
+  p_slug text default null,
+  p_method text default null,
+  p_q text default null,
+  p_from_ms bigint default null,
+  p_to_ms bigint default null,
+  p_content_class text default null
+)
+returns integer
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_plan text := coalesce(p_plan, 'pro');
+  v_from timestamptz := case
+    when p_from_ms is null then null
+    else to_timestamp(p_from_ms::double precision / 1000.0)
+  end;
+  v_to timestamptz := case
+    when p_to_ms is null then null
+    else to_timestamp(p_to_ms::double precision / 1000.0)
+  end;
+  v_retention_cutoff timestamptz := case
+    when v_plan = 'free' then now() - interval '7 days'
+    else null
+  end;
+  v_q text := nullif(btrim(p_q), '');
+  v_count integer;
+begin
+  if v_plan not in ('free', 'pro') then
+    raise exception 'invalid plan' using errcode = '22023';
+  end if;
+
+  select count(*)::integer
+  into v_count
+  from public.requests r
+  join public.endpoints e on e.id = r.endpoint_id
+  where r.user_id = p_user_id
+    and (nullif(btrim(p_slug), '') is null or e.slug = nullif(btrim(p_slug), ''))
+    and (nullif(btrim(p_method), '') is null or nullif(btrim(p_method), '') = 'ALL' or r.method = nullif(btrim(p_method), ''))
+    and (
+      v_q is null
+      or r.path ilike '%' || v_q || '%'
+      or coalesce(r.body, '') ilike '%' || v_q || '%'
+      or r.headers::text ilike '%' || v_q || '%'
+    )
+    and (v_from is null or r.received_at >= v_from)
+    and (v_to is null or r.received_at <= v_to)
+    and (v_retention_cutoff is null or r.received_at >= v_retention_cutoff)
+    and (nullif(btrim(p_content_class), '') is null or r.content_class = nullif(btrim(p_content_class), ''));
+
+  return coalesce(v_count, 0);
+end;
+$$;
+
+revoke all on function public.search_requests(uuid, text, text, text, text, bigint, bigint, integer, integer, text, text) from public;
+revoke all on function public.search_requests(uuid, text, text, text, text, bigint, bigint, integer, integer, text, text) from anon;
+revoke all on function public.search_requests(uuid, text, text, text, text, bigint, bigint, integer, integer, text, text) from authenticated;
+grant execute on function public.search_requests(uuid, text, text, text, text, bigint, bigint, integer, integer, text, text) to service_role;
+
+revoke all on function public.search_requests_count(uuid, text, text, text, text, bigint, bigint, text) from public;
+revoke all on function public.search_requests_count(uuid, text, text, text, text, bigint, bigint, text) from anon;
+revoke all on function public.search_requests_count(uuid, text, text, text, text, bigint, bigint, text) from authenticated;
+grant execute on function public.search_requests_count(uuid, text, text, text, text, bigint, bigint, text) to service_role;
@@ -0,0 +1,103 @@
+-- ============================================================================
+-- Migration 00060: Capture credentials
+--
+-- Endpoints can require senders to authenticate before anything is captured
+-- (endpoints.capture_auth), with HTTP Basic credentials or an API key header:
+--   {"type": "basic", "username": "...", "passwordHash": "<sha256 hex>"}
+--   {"type": "api_key", "header": "x-api-key", "keyHash": "<sha256 hex>"}
+-- Only SHA-256 hashes of the password and key are stored.
+--
+-- The receiver reads the config through get_endpoint_capture_auth(), caches
+-- it per slug, and answers requests without matching credentials with 401
+-- unauthorized before reading the body. Refusals aren't stored as requests
+-- but are counted under the 'unauthorized' rule in endpoint_network_stats via
+-- count_capture_auth_failure(). Changes are announced on the endpoint_config
+-- channel like other config changes.
+-- ============================================================================
+
+-- 1. Per-endpoint config
+create or replace function public.capture_auth_valid(p_config jsonb)
+returns boolean
+language sql
+immutable
+set search_path = ''
+as $$
+  select p_config is null or (
+    jsonb_typeof(p_config) = 'object'
+    and (
+      (
+        p_config->>'type' = 'basic'
+        and jsonb_typeof(p_config->'username') = 'string'
+        and p_config->>'username' ~ '^[^:]{1,200}$'
+        and jsonb_typeof(p_config->'passwordHash') = 'string'
+        and p_config->>'passwordHash' ~ '^[0-9a-f]{64}$'
+      )
+      or (
+        p_config->>'type' = 'api_key'
+        and jsonb_typeof(p_config->'header') = 'string'
+        and p_config->>'header' ~ '^[a-z0-9_-]{1,100}$'
+        and jsonb_typeof(p_config->'keyHash') = 'string'
+        and p_config->>'keyHash' ~ '^[0-9a-f]{64}$'
+      )
+    )
+  );
+$$;
+
+alter table public.endpoints
+  add column if not exists capture_auth jsonb;
+
+alter table public.endpoints
+  add constraint endpoints_capture_auth_check
+  check (public.capture_auth_valid(capture_auth));
+
+-- 2. Lookup used by the receiver's capture auth cache
+create or replace function public.get_endpoint_capture_auth(p_slug text)
+returns jsonb
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select capture_auth from public.endpoints where slug = lower(p_slug);
+$$;
+
+revoke all on function public.get_endpoint_capture_auth(text) from public;
+revoke all on function public.get_endpoint_capture_auth(text) from anon;
+revoke all on function public.get_endpoint_capture_auth(text) from authenticated;
+grant execute on function public.get_endpoint_capture_auth(text) to service_role;
+
+-- 3. Count refused requests alongside the network policy matches
+create or replace function public.count_capture_auth_failure(p_slug text)
+returns void
+language sql
+security definer set search_path = ''
+as $$
+  select public.count_network_match(id, 'unauthorized')
+  from public.endpoints
+  where slug = lower(p_slug);
+$$;
+
+revoke all on function public.count_capture_auth_failure(text) from public;
+revoke all on function public.count_capture_auth_failure(text) from anon;
+revoke all on function public.count_capture_auth_failure(text) from authenticated;
+grant execute on function public.count_capture_auth_failure(text) to service_role;
+
+-- 4. Tell receivers to drop their cached config when it changes, and restart
+--    the refusal count for the new credentials
+create or replace function public.notify_capture_auth_change()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  delete from public.endpoint_network_stats
+  where endpoint_id = new.id and rule = 'unauthorized';
+  perform pg_notify('endpoint_config', new.slug);
+  return new;
+end;
+$$;
+
+create trigger endpoint_capture_auth_changed
+  after update of capture_auth on public.endpoints
+  for each row
+  when (old.capture_auth is distinct from new.capture_auth)
+  execute function public.notify_capture_auth_change();
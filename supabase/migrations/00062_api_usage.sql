@@ -0,0 +1,59 @@
+-- ============================================================================
+-- Migration 00062: API usage metering
+--
+-- Counts each account's use of the platform API per UTC day: authenticated
+-- API calls, stream connection time, and request replays and exports (the
+-- CLI tags the calls those commands make with X-Whk-Operation). The web app
+-- records usage through record_api_usage() and reports today's totals
+-- against the plan's daily limits at GET /api/usage/api.
+-- ============================================================================
+
+-- 1. Daily counters
+create table public.api_usage_daily (
+  user_id         uuid not null references public.users(id) on delete cascade,
+  day             date not null,
+  api_calls       integer not null default 0,
+  stream_seconds  bigint not null default 0,
+  replays         integer not null default 0,
+  exports         integer not null default 0,
+  primary key (user_id, day)
+);
+
+-- Accessed only through the service role.
+alter table public.api_usage_daily enable row level security;
+
+-- 2. Add to today's counters
+create or replace function public.record_api_usage(
+  p_user_id         uuid,
+  p_api_calls       integer default 0,
+  p_stream_seconds  integer default 0,
+  p_replays         integer default 0,
+  p_exports         integer default 0
+)
+returns void
+language sql
+security definer set search_path = ''
+as $$
+  insert into public.api_usage_daily as u
+         (user_id, day, api_calls, stream_seconds, replays, exports)
+  values (p_user_id, (now() at time zone 'utc')::date,
+          greatest(p_api_calls, 0), greatest(p_stream_seconds, 0),
+          greatest(p_replays, 0), greatest(p_exports, 0))
+  on conflict (user_id, day) do update
+    set api_calls = u.api_calls + excluded.api_calls,
+        stream_seconds = u.stream_seconds + excluded.stream_seconds,
+        replays = u.replays + excluded.replays,
+        exports = u.exports + excluded.exports;
+$$;
+
+revoke all on function public.record_api_usage(uuid, integer, integer, integer, integer) from public;
+revoke all on function public.record_api_usage(uuid, integer, integer, integer, integer) from anon;
+revoke all on function public.record_api_usage(uuid, integer, integer, integer, integer) from authenticated;
+grant execute on function public.record_api_usage(uuid, integer, integer, integer, integer) to service_role;
+
+-- 3. Keep a month of history
+select cron.schedule(
+  'cleanup-api-usage-daily',
+  '40 0 * * *',
+  $$delete from public.api_usage_daily where day < (now() at time zone 'utc')::date - 31;$$
+);
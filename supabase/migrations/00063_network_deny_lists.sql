@@ -0,0 +1,491 @@
+-- ============================================================================
+-- Migration 00063: Network deny lists and rejected requests
+--
+-- network_policy gains a deny rule, checked before the allow rule:
+--   {"deny": {"countries": ["T1"], "cidrs": ["198.51.100.0/24"]},
+--    "allow": {...}, "tags": [...], "captureRejected": true}
+-- Requests matching it are rejected with status 'blocked' like requests
+-- outside the allow rule, and counted under the 'denied' rule in
+-- endpoint_network_stats ('blocked' stays the count for the allow rule).
+--
+-- With captureRejected set, every rejected request is also kept in
+-- rejected_requests (method, path, sender, reason; no headers or body) so
+-- owners can see who is hitting the URL. Rejected requests don't count
+-- against the quota. At most 1000 are kept per endpoint and hour, and none
+-- for longer than 7 days.
+-- ============================================================================
+
+-- 1. Validation: the deny rule's ranges must parse too, and captureRejected
+--    is a boolean
+create or replace function public.network_policy_valid(p_policy jsonb)
+returns boolean
+language plpgsql
+immutable
+set search_path = ''
+as $$
+declare
+  v_rule  jsonb;
+  v_cidr  text;
+begin
+  if jsonb_typeof(p_policy) <> 'object' then
+    return false;
+  end if;
+  if p_policy ? 'captureRejected' and jsonb_typeof(p_policy -> 'captureRejected') <> 'boolean' then
+    return false;
+  end if;
+  for v_rule in
+    select p_policy -> 'allow' where p_policy ? 'allow'
+    union all
+    select p_policy -> 'deny' where p_policy ? 'deny'
+    union all
+    select value from jsonb_array_elements(coalesce(p_policy -> 'tags', '[]'))
+  loop
+    if jsonb_typeof(v_rule) <> 'object' then
+      return false;
+    end if;
+    for v_cidr in select jsonb_array_elements_text(coalesce(v_rule -> 'cidrs', '[]')) loop
+      perform v_cidr::cidr;
+    end loop;
+  end loop;
+  return true;
+exception when others then
+  return false;
+end;
+$$;
+
+-- 2. The rejected bucket
+create table public.rejected_requests (
+  id           uuid primary key default gen_random_uuid(),
+  endpoint_id  uuid not null references public.endpoints(id) on delete cascade,
+  reason       text not null check (reason in ('denied', 'blocked')),
+  method       text not null,
+  path         text not null,
+  ip           text not null,
+  country      text,
+  user_agent   text,
+  received_at  timestamptz not null
+);
+
+create index idx_rejected_requests_endpoint_received
+  on public.rejected_requests (endpoint_id, received_at desc);
+
+-- Accessed only through the service role and capture_webhook().
+alter table public.rejected_requests enable row level security;
+
+create or replace function public.record_rejected_request(
+  p_endpoint_id  uuid,
+  p_reason       text,
+  p_method       text,
+  p_path         text,
+  p_ip           text,
+  p_country      text,
+  p_user_agent   text,
+  p_received_at  timestamptz
+)
+returns void
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  -- A flood from a denied range shouldn't fill the table
+  if (
+    select count(*)
+      from (
+        select 1
+          from public.rejected_requests
+         where endpoint_id = p_endpoint_id
+           and received_at > p_received_at - interval '1 hour'
+         limit 1000
+      ) recent
+  ) >= 1000 then
+    return;
+  end if;
+
+  insert into public.rejected_requests
+    (endpoint_id, reason, method, path, ip, country, user_agent, received_at)
+  values
+    (p_endpoint_id, p_reason, p_method, left(p_path, 1000), p_ip, p_country,
+     left(p_user_agent, 500), p_received_at);
+end;
+$$;
+
+revoke all on function public.record_rejected_request(
+  uuid, text, text, text, text, text, text, timestamptz
+) from public;
+revoke all on function public.record_rejected_request(
+  uuid, text, text, text, text, text, text, timestamptz
+) from anon;
+revoke all on function public.record_rejected_request(
+  uuid, text, text, text, text, text, text, timestamptz
+) from authenticated;
+
+select cron.schedule(
+  'cleanup-rejected-requests',
+  '50 0 * * *',
+  $$delete from public.rejected_requests where received_at < now() - interval '7 days';$$
+);
+
+-- 3. capture_webhook (signature unchanged) checks the deny rule and records
+--    rejected requests
+create or replace function public.capture_webhook(
+  p_slug        text,
+  p_method      text,
+  p_path        text,
+  p_headers     jsonb,
+  p_body        text,
+  p_query_params jsonb,
+  p_content_type text,
+  p_ip          text,
+  p_received_at timestamptz,
+  p_body_raw    bytea default null,
+  p_bypass_expires timestamptz default null,
+  p_body_hash   text default null,
+  p_parts       jsonb default null,
+  p_country     text default null,
+  p_body_ref    text default null,
+  p_body_size   integer default null,
+  p_frame       jsonb default null,
+  p_cloud_event jsonb default null,
+  p_canonical_hash text default null,
+  p_http_version text default null,
+  p_client_cert jsonb default null,
+  p_trailers    jsonb default null,
+  p_delivery_key text default null,
+  p_fingerprint text default null,
+  p_provider    text default null,
+  p_event_type  text default null,
+  p_content_class text default null,
+  p_signature_valid boolean default null,
+  p_jwt_valid   boolean default null,
+  p_jwt_claims  jsonb default null
+)
+returns jsonb
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_endpoint    record;
+  v_user        record;
+  v_quota       record;
+  v_period      record;
+  v_retry_after bigint;
+  v_size        integer;
+  v_mock        jsonb;
+  v_slug        text;
+  v_remaining   integer;
+  v_limit       integer;
+  v_reset       timestamptz;
+  v_duplicate_of uuid;
+  v_variant     record;
+  v_variant_index integer;
+  v_variant_name text;
+  v_window_index integer;
+  v_roll        numeric;
+  v_cumulative  numeric;
+  v_policy      jsonb;
+  v_ip          inet;
+  v_tags        text[] := '{}';
+  v_tag         text;
+  v_rule        jsonb;
+  v_rejected    text;
+  v_request_id  uuid;
+  v_body_hash   text;
+  v_priority    boolean := false;
+begin
+  -- Normalize slug to lowercase for case-insensitive lookup
+  v_slug := lower(p_slug);
+
+  -- 1. Look up endpoint by slug
+  select id, user_id, is_ephemeral, expires_at, mock_response, request_count, notification_url,
+         function_sink, info_headers, paused_at, paused_response, network_policy,
+         record_responses, canonical_json, priority_rule, dry_run, auto_extend_idle_ms
+    into v_endpoint
+    from public.endpoints
+   where slug = v_slug;
+
+  if not found then
+    return jsonb_build_object('status', 'not_found');
+  end if;
+
+  -- 2. Check expiry
+  if v_endpoint.expires_at is not null and v_endpoint.expires_at <= now() then
+    return jsonb_build_object('status', 'expired');
+  end if;
+
+  -- 3. Paused endpoints answer without storing, forwarding or counting the
+  --    request
+  if v_endpoint.paused_at is not null then
+    return jsonb_build_object('status', 'paused', 'paused_response', v_endpoint.paused_response);
+  end if;
+
+  -- 4. Network policy: requests matching the deny rule, or outside the allow
+  --    rule, are rejected before the quota check (and kept in
+  --    rejected_requests when the policy asks for it); tag rules label the
+  --    ones that pass
+  v_policy := v_endpoint.network_policy;
+  if v_policy is not null then
+    begin
+      v_ip := nullif(p_ip, '')::inet;
+    exception when others then
+      v_ip := null;
+    end;
+
+    if v_policy ? 'deny'
+       and public.network_rule_matches(v_policy -> 'deny', v_ip, p_country)
+    then
+      v_rejected := 'denied';
+    elsif v_policy ? 'allow'
+       and not public.network_rule_matches(v_policy -> 'allow', v_ip, p_country)
+    then
+      v_rejected := 'blocked';
+    end if;
+
+    if v_rejected is not null then
+      perform public.count_network_match(v_endpoint.id, v_rejected);
+      if (v_policy ->> 'captureRejected')::boolean and not v_endpoint.dry_run then
+        perform public.record_rejected_request(
+          v_endpoint.id, v_rejected, p_method, p_path, p_ip, p_country,
+          p_headers ->> 'user-agent', p_received_at
+        );
+      end if;
+      return jsonb_build_object('status', 'blocked');
+    end if;
+
+    for v_rule in select value from jsonb_array_elements(coalesce(v_policy -> 'tags', '[]'))
+    loop
+      if public.network_rule_matches(v_rule, v_ip, p_country) then
+        v_tags := array_append(v_tags, v_rule ->> 'tag');
+      end if;
+    end loop;
+  end if;
+
+  -- 5. Quota check (branching by endpoint type). A verified bypass token
+  --    skips it entirely and is audited instead; usage isn't counted.
+  --    Dry-run captures are never stored, so they aren't counted either.
+  if v_endpoint.dry_run then
+    null;
+
+  elsif p_bypass_expires is not null then
+    insert into public.quota_bypass_audit (endpoint_id, token_expires_at, ip, method, path)
+    values (v_endpoint.id, p_bypass_expires, p_ip, p_method, left(p_path, 1000));
+
+  elsif v_endpoint.is_ephemeral and v_endpoint.user_id is null then
+    -- Ephemeral endpoint: atomic increment with 25-request cap
+    select request_count into v_quota
+      from public.check_and_increment_ephemeral(v_endpoint.id);
+
+    if not found then
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'info', public.endpoint_info(v_endpoint.info_headers, 0, 25, null, v_endpoint.expires_at)
+      );
+    end if;
+
+    v_remaining := greatest(25 - v_quota.request_count, 0);
+    v_limit := 25;
+
+  elsif v_endpoint.user_id is not null then
+    -- Owned endpoint: check user quota
+    select id, plan, request_limit, requests_used, period_end
+      into v_user
+      from public.users
+     where id = v_endpoint.user_id;
+
+    if not found then
+      return jsonb_build_object('status', 'not_found');
+    end if;
+
+    -- Free user with expired or unstarted period: start a new one
+    if v_user.plan = 'free' and (v_user.period_end is null or v_user.period_end <= now()) then
+      select remaining, quota_limit, period_end_ts into v_period
+        from public.start_free_period(v_endpoint.user_id);
+
+      if not found then
+        -- Period start failed (shouldn't happen, but handle gracefully)
+        return jsonb_build_object('status', 'quota_exceeded');
+      end if;
+
+      -- Refresh user row after period reset
+      select id, plan, request_limit, requests_used, period_end
+        into v_user
+        from public.users
+       where id = v_endpoint.user_id;
+    end if;
+
+    -- Atomic quota check + decrement
+    select remaining, quota_limit, period_end_ts into v_quota
+      from public.check_and_decrement_quota(v_endpoint.user_id, 1);
+
+    if not found then
+      -- Quota exceeded
+      v_retry_after := null;
+      if v_user.period_end is not null and v_user.period_end > now() then
+        v_retry_after := extract(epoch from (v_user.period_end - now()))::bigint * 1000;
+      end if;
+
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'retry_after', v_retry_after,
+        'info', public.endpoint_info(
+          v_endpoint.info_headers, 0, v_user.request_limit, v_user.period_end, v_endpoint.expires_at
+        )
+      );
+    end if;
+
+    v_remaining := v_quota.remaining;
+    v_limit := v_quota.quota_limit;
+    v_reset := v_quota.period_end_ts;
+
+  end if;
+  -- else: owned endpoint with null user_id but not ephemeral — allow through (no quota)
+
+  -- 6. Duplicate detection: a capture carrying a provider delivery key
+  --    points at the first request with the same key in the last 3 days.
+  --    Without a key, the same method, path and body as a capture in the
+  --    last 10 minutes points at the first request of that group.
+  --    Endpoints comparing canonical JSON hash the canonical form instead,
+  --    when the receiver sent one (the body is JSON and not already canonical).
+  v_body_hash := p_body_hash;
+  if v_endpoint.canonical_json and p_canonical_hash is not null then
+    v_body_hash := p_canonical_hash;
+  end if;
+
+  if p_delivery_key is not null then
+    select coalesce(r.duplicate_of, r.id)
+      into v_duplicate_of
+      from public.requests r
+     where r.endpoint_id = v_endpoint.id
+       and r.delivery_key = p_delivery_key
+       and r.received_at > p_received_at - interval '3 days'
+     order by r.received_at desc
+     limit 1;
+  elsif v_body_hash is not null then
+    select coalesce(r.duplicate_of, r.id)
+      into v_duplicate_of
+      from public.requests r
+     where r.endpoint_id = v_endpoint.id
+       and r.body_hash = v_body_hash
+       and r.method = p_method
+       and r.path = p_path
+       and r.received_at > p_received_at - interval '10 minutes'
+     order by r.received_at desc
+     limit 1;
+  end if;
+
+  -- 7. Pick the mock response: a scheduled window open when the request
+  --    arrived wins, otherwise roll for a weighted variant when the endpoint
+  --    defines any
+  v_mock := null;
+  if v_endpoint.mock_response is not null
+     and jsonb_typeof(v_endpoint.mock_response) = 'object'
+     and (v_endpoint.mock_response ? 'status')
+  then
+    v_mock := v_endpoint.mock_response;
+    v_window_index := public.open_mock_window(v_mock -> 'schedule', p_received_at);
+
+    if v_window_index is not null then
+      v_variant_name := v_mock -> 'schedule' -> v_window_index ->> 'name';
+    elsif jsonb_typeof(v_mock -> 'variants') = 'array'
+       and jsonb_array_length(v_mock -> 'variants') > 0
+    then
+      v_roll := random() * 100;
+      v_cumulative := 0;
+      for v_variant in
+        select value, ordinality - 1 as idx
+          from jsonb_array_elements(v_mock -> 'variants') with ordinality
+      loop
+        v_cumulative := v_cumulative + coalesce((v_variant.value ->> 'weight')::numeric, 0);
+        if v_roll < v_cumulative then
+          v_variant_index := v_variant.idx;
+          v_variant_name := v_variant.value ->> 'name';
+          exit;
+        end if;
+      end loop;
+      v_variant_name := coalesce(v_variant_name, 'default');
+    end if;
+  end if;
+
+  -- High priority when the endpoint's priority header is present and, if the
+  -- rule lists values, matches one of them. Header names arrive lowercased.
+  if v_endpoint.priority_rule is not null
+     and p_headers ? (v_endpoint.priority_rule ->> 'header') then
+    v_priority := jsonb_array_length(coalesce(v_endpoint.priority_rule -> 'values', '[]'::jsonb)) = 0
+      or (v_endpoint.priority_rule -> 'values')
+         ? lower(trim(p_headers ->> (v_endpoint.priority_rule ->> 'header')));
+  end if;
+
+  -- Offloaded bodies report their own size; otherwise prefer raw byte length
+  -- when available for accurate size
+  v_size := coalesce(p_body_size, octet_length(p_body_raw), octet_length(p_body), 0);
+
+  -- Dry run: count the request and the tag rules it matched, then answer as
+  -- if it had been stored, without notifications, the function sink or
+  -- response recording
+  if v_endpoint.dry_run then
+    perform public.count_dry_run(v_endpoint.id, v_size, v_mock is not null);
+    foreach v_tag in array v_tags loop
+      perform public.count_network_match(v_endpoint.id, 'tag:' || v_tag);
+    end loop;
+
+    return jsonb_build_object(
+      'status', 'ok',
+      'mock_response', v_mock,
+      'mock_variant', v_variant_index,
+      'mock_window', v_window_index,
+      'retry_after', null::bigint,
+      'info', public.endpoint_info(
+        v_endpoint.info_headers, null, null, null, v_endpoint.expires_at
+      ),
+      'dry_run', true,
+      'auto_extend', v_endpoint.auto_extend_idle_ms is not null and v_endpoint.expires_at is not null
+    );
+  end if;
+
+  -- 8. Insert the request
+
+  insert into public.requests (
+    endpoint_id, user_id, method, path, headers, body, body_raw,
+    query_params, content_type, ip, size, received_at, body_hash, duplicate_of,
+    mock_variant, parts, tags, body_ref, frame, cloud_event, http_version, priority,
+    client_cert, trailers, delivery_key, fingerprint, provider, event_type, content_class,
+    signature_valid, jwt_valid, jwt_claims
+  ) values (
+    v_endpoint.id, v_endpoint.user_id, p_method, p_path, p_headers, p_body, p_body_raw,
+    p_query_params, p_content_type, p_ip, v_size, p_received_at, v_body_hash, v_duplicate_of,
+    v_variant_name, p_parts, v_tags, p_body_ref, p_frame, p_cloud_event, p_http_version, v_priority,
+    p_client_cert, p_trailers, p_delivery_key, p_fingerprint, p_provider, p_event_type,
+    p_content_class, p_signature_valid, p_jwt_valid, p_jwt_claims
+  )
+  returning id into v_request_id;
+
+  -- Count the tag rules the stored request matched
+  foreach v_tag in array v_tags loop
+    perform public.count_network_match(v_endpoint.id, 'tag:' || v_tag);
+  end loop;
+
+  -- 9. Increment endpoint request count (ephemeral already incremented above
+  --    unless the quota check was bypassed)
+  if p_bypass_expires is not null or not (v_endpoint.is_ephemeral and v_endpoint.user_id is null) then
+    perform public.increment_endpoint_request_count(v_endpoint.id, 1);
+  end if;
+
+  -- User requests_used already incremented by check_and_decrement_quota
+
+  -- 10. Build response
+  return jsonb_build_object(
+    'status', 'ok',
+    'mock_response', v_mock,
+    'mock_variant', v_variant_index,
+    'mock_window', v_window_index,
+    'retry_after', null::bigint,
+    'notification_url', v_endpoint.notification_url,
+    'function_sink', v_endpoint.function_sink,
+    'info', public.endpoint_info(
+      v_endpoint.info_headers, v_remaining, v_limit, v_reset, v_endpoint.expires_at
+    ),
+    'record_response_id', case when v_endpoint.record_responses then v_request_id end,
+    'priority', v_priority,
+    'auto_extend', v_endpoint.auto_extend_idle_ms is not null and v_endpoint.expires_at is not null
+  );
+end;
+$$;
@@ -0,0 +1,106 @@
+-- ============================================================================
+-- Migration 00064: Provider IP allowlists
+--
+-- Network rules can name webhook providers whose published source ranges
+-- they match, alongside countries and CIDRs:
+--   {"allow": {"providers": ["github", "stripe"]}}
+-- The receiver fetches GitHub's and Stripe's lists at startup and hourly and
+-- stores them with set_provider_ip_ranges(), so the rule follows the
+-- providers' changes without anyone editing the policy. A provider with no
+-- stored list yet matches nothing.
+-- ============================================================================
+
+-- 1. Latest published ranges per provider
+create table public.provider_ip_ranges (
+  provider      text primary key check (provider in ('github', 'stripe')),
+  cidrs         cidr[] not null,
+  refreshed_at  timestamptz not null default now()
+);
+
+-- Accessed only through the service role and capture_webhook().
+alter table public.provider_ip_ranges enable row level security;
+
+create or replace function public.set_provider_ip_ranges(p_provider text, p_cidrs text[])
+returns void
+language sql
+security definer set search_path = ''
+as $$
+  -- An empty list would lock the provider out; keep the last one instead
+  insert into public.provider_ip_ranges (provider, cidrs, refreshed_at)
+  select p_provider, p_cidrs::cidr[], now()
+   where cardinality(p_cidrs) > 0
+  on conflict (provider) do update
+    set cidrs = excluded.cidrs,
+        refreshed_at = excluded.refreshed_at;
+$$;
+
+revoke all on function public.set_provider_ip_ranges(text, text[]) from public;
+revoke all on function public.set_provider_ip_ranges(text, text[]) from anon;
+revoke all on function public.set_provider_ip_ranges(text, text[]) from authenticated;
+grant execute on function public.set_provider_ip_ranges(text, text[]) to service_role;
+
+-- 2. Rules match the named providers' ranges. No longer immutable: the
+--    result depends on the stored ranges.
+create or replace function public.network_rule_matches(p_rule jsonb, p_ip inet, p_country text)
+returns boolean
+language sql
+stable
+set search_path = ''
+as $$
+  select (p_country is not null and coalesce(p_rule -> 'countries', '[]') ? p_country)
+      or (p_ip is not null and exists (
+            select 1
+              from jsonb_array_elements_text(coalesce(p_rule -> 'cidrs', '[]')) as c(cidr)
+             where p_ip <<= c.cidr::cidr
+          ))
+      or (p_ip is not null and exists (
+            select 1
+              from public.provider_ip_ranges r
+             cross join unnest(r.cidrs) as c(cidr)
+             where coalesce(p_rule -> 'providers', '[]') ? r.provider
+               and p_ip <<= c.cidr
+          ));
+$$;
+
+-- 3. Validation: providers must be known
+create or replace function public.network_policy_valid(p_policy jsonb)
+returns boolean
+language plpgsql
+immutable
+set search_path = ''
+as $$
+declare
+  v_rule      jsonb;
+  v_cidr      text;
+  v_provider  jsonb;
+begin
+  if jsonb_typeof(p_policy) <> 'object' then
+    return false;
+  end if;
+  if p_policy ? 'captureRejected' and jsonb_typeof(p_policy -> 'captureRejected') <> 'boolean' then
+    return false;
+  end if;
+  for v_rule in
+    select p_policy -> 'allow' where p_policy ? 'allow'
+    union all
+    select p_policy -> 'deny' where p_policy ? 'deny'
+    union all
+    select value from jsonb_array_elements(coalesce(p_policy -> 'tags', '[]'))
+  loop
+    if jsonb_typeof(v_rule) <> 'object' then
+      return false;
+    end if;
+    for v_cidr in select jsonb_array_elements_text(coalesce(v_rule -> 'cidrs', '[]')) loop
+      perform v_cidr::cidr;
+    end loop;
+    for v_provider in select jsonb_array_elements(coalesce(v_rule -> 'providers', '[]')) loop
+      if v_provider not in ('"github"'::jsonb, '"stripe"'::jsonb) then
+        return false;
+      end if;
+    end loop;
+  end loop;
+  return true;
+exception when others then
+  return false;
+end;
+$$;
@@ -0,0 +1,55 @@
+-- ============================================================================
+-- Migration 00068: Header redaction
+--
+-- The receiver now hides the whole value of sensitive headers (authorization,
+-- proxy-authorization, cookie, x-api-key) on every endpoint before storage,
+-- counted in requests.redactions as "header:<name>". endpoints.redaction
+-- gains per-endpoint overrides:
+--   {"headers": {"allow": ["authorization"], "deny": ["x-shopify-access-token"]}}
+-- `allow` stores listed headers as sent, `deny` hides more. Headers listed in
+-- encrypted_headers are sealed instead of hidden. A config may now consist
+-- of header overrides alone.
+-- ============================================================================
+
+create or replace function public.redaction_valid(p_config jsonb)
+returns boolean
+language sql
+immutable
+set search_path = ''
+as $$
+  select p_config is null or (
+    jsonb_typeof(p_config) = 'object'
+    and jsonb_typeof(coalesce(p_config->'detectors', '[]'::jsonb)) = 'array'
+    and jsonb_typeof(coalesce(p_config->'paths', '[]'::jsonb)) = 'array'
+    and jsonb_typeof(coalesce(p_config->'patterns', '[]'::jsonb)) = 'array'
+    and jsonb_typeof(coalesce(p_config->'headers', '{}'::jsonb)) = 'object'
+    and jsonb_typeof(coalesce(p_config->'headers'->'allow', '[]'::jsonb)) = 'array'
+    and jsonb_typeof(coalesce(p_config->'headers'->'deny', '[]'::jsonb)) = 'array'
+    and jsonb_array_length(coalesce(p_config->'paths', '[]'::jsonb)) <= 20
+    and jsonb_array_length(coalesce(p_config->'patterns', '[]'::jsonb)) <= 20
+    and jsonb_array_length(coalesce(p_config->'headers'->'allow', '[]'::jsonb)) <= 20
+    and jsonb_array_length(coalesce(p_config->'headers'->'deny', '[]'::jsonb)) <= 20
+    and jsonb_array_length(coalesce(p_config->'detectors', '[]'::jsonb))
+      + jsonb_array_length(coalesce(p_config->'paths', '[]'::jsonb))
+      + jsonb_array_length(coalesce(p_config->'patterns', '[]'::jsonb))
+      + jsonb_array_length(coalesce(p_config->'headers'->'allow', '[]'::jsonb))
+      + jsonb_array_length(coalesce(p_config->'headers'->'deny', '[]'::jsonb)) > 0
+    and not exists (
+      select 1 from jsonb_array_elements(coalesce(p_config->'detectors', '[]'::jsonb)) d
+      where d not in ('"email"'::jsonb, '"credit_card"'::jsonb, '"bearer_token"'::jsonb)
+    )
+    and not exists (
+      select 1 from jsonb_array_elements(
+        coalesce(p_config->'paths', '[]'::jsonb) || coalesce(p_config->'patterns', '[]'::jsonb)
+      ) r
+      where jsonb_typeof(r) <> 'string' or length(r #>> '{}') not between 1 and 200
+    )
+    and not exists (
+      select 1 from jsonb_array_elements(
+        coalesce(p_config->'headers'->'allow', '[]'::jsonb)
+          || coalesce(p_config->'headers'->'deny', '[]'::jsonb)
+      ) h
+      where jsonb_typeof(h) <> 'string' or (h #>> '{}') !~ '^[a-z0-9_-]{1,100}$'
+    )
+  );
+$$;
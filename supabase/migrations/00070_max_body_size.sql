@@ -0,0 +1,71 @@
+-- ============================================================================
+-- Migration 00070: Per-endpoint body size limits
+--
+-- Each plan has a body size limit (plan_max_body_size(): 1MB on free and for
+-- guests, the receiver's own limit on pro), and endpoints can lower it with
+-- endpoints.max_body_size. The receiver reads the effective limit through
+-- get_endpoint_max_body_size(), caches it per slug, and never goes above its
+-- own limit (1MB, or OBJECT_STORAGE_MAX_BODY_BYTES with object storage).
+-- Bodies over an endpoint's limit are captured cut to the limit instead of
+-- refused: requests.sizes keeps the size as received and marks the body
+-- {"truncated": {"body": "max_size"}}. Only bodies over the receiver's own
+-- limit are still refused with 413 payload_too_large. Changes to the column
+-- are announced on the endpoint_config channel; plan changes reach receivers
+-- when their cache expires.
+-- ============================================================================
+
+-- 1. Per-endpoint override
+alter table public.endpoints
+  add column if not exists max_body_size integer;
+
+alter table public.endpoints
+  add constraint endpoints_max_body_size_check
+  check (max_body_size is null or max_body_size between 1024 and 104857600);
+
+-- 2. Plan limits; null means the receiver's own limit
+create or replace function public.plan_max_body_size(p_plan text)
+returns integer
+language sql
+immutable
+set search_path = ''
+as $$
+  select case p_plan when 'pro' then null else 1048576 end;
+$$;
+
+-- 3. Lookup used by the receiver's body limit cache. Ephemeral endpoints
+--    without an owner get the free limit. least() ignores nulls, so null
+--    only when neither the endpoint nor the plan sets a limit.
+create or replace function public.get_endpoint_max_body_size(p_slug text)
+returns integer
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select least(e.max_body_size, public.plan_max_body_size(coalesce(u.plan, 'free')))
+  from public.endpoints e
+  left join public.users u on u.id = e.user_id
+  where e.slug = lower(p_slug);
+$$;
+
+revoke all on function public.get_endpoint_max_body_size(text) from public;
+revoke all on function public.get_endpoint_max_body_size(text) from anon;
+revoke all on function public.get_endpoint_max_body_size(text) from authenticated;
+grant execute on function public.get_endpoint_max_body_size(text) to service_role;
+
+-- 4. Tell receivers to drop their cached limit when it changes
+create or replace function public.notify_max_body_size_change()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  perform pg_notify('endpoint_config', new.slug);
+  return new;
+end;
+$$;
+
+create trigger endpoint_max_body_size_changed
+  after update of max_body_size on public.endpoints
+  for each row
+  when (old.max_body_size is distinct from new.max_body_size)
+  execute function public.notify_max_body_size_change();
@@ -0,0 +1,97 @@
+-- ============================================================================
+-- Migration 00073: Per-endpoint CORS
+--
+-- The receiver used to answer every capture with Access-Control-Allow-Origin
+-- "*", which browsers refuse for credentialed requests. endpoints.cors lets an
+-- endpoint list what its browser clients need:
+--   {"origins": ["https://app.example.com"], "methods": ["POST"],
+--    "headers": ["authorization"], "credentials": true, "maxAge": 600}
+-- origins are exact origins or "*"; empty or missing methods and headers
+-- allow any. Endpoints without a config keep the permissive default.
+--
+-- The receiver reads the config through get_endpoint_cors(), caches it per
+-- slug, answers preflights itself (they aren't captured) and adds the headers
+-- to every other response of the endpoint. Changes are announced on the
+-- endpoint_config channel like other config changes.
+-- ============================================================================
+
+-- 1. Per-endpoint config
+create or replace function public.cors_list_valid(p_list jsonb, p_pattern text)
+returns boolean
+language sql
+immutable
+set search_path = ''
+as $$
+  select p_list is null or (
+    jsonb_typeof(p_list) = 'array'
+    and jsonb_array_length(p_list) <= 50
+    and not exists (
+      select 1
+      from jsonb_array_elements(p_list) item
+      where jsonb_typeof(item) <> 'string' or not (item #>> '{}' ~ p_pattern)
+    )
+  );
+$$;
+
+create or replace function public.cors_valid(p_config jsonb)
+returns boolean
+language sql
+immutable
+set search_path = ''
+as $$
+  select p_config is null or (
+    jsonb_typeof(p_config) = 'object'
+    and jsonb_typeof(p_config->'origins') = 'array'
+    and jsonb_array_length(p_config->'origins') > 0
+    and public.cors_list_valid(p_config->'origins', '^(\*|https?://[^/\s]{1,253})$')
+    and public.cors_list_valid(p_config->'methods', '^[A-Z]{1,20}$')
+    and public.cors_list_valid(p_config->'headers', '^[a-z0-9!#$%&''*+.^_`|~-]{1,100}$')
+    and coalesce(jsonb_typeof(p_config->'credentials') = 'boolean', true)
+    and coalesce(
+      jsonb_typeof(p_config->'maxAge') = 'number'
+        and (p_config->>'maxAge') ~ '^\d{1,5}$'
+        and (p_config->>'maxAge')::integer <= 86400,
+      true
+    )
+  );
+$$;
+
+alter table public.endpoints
+  add column if not exists cors jsonb;
+
+alter table public.endpoints
+  add constraint endpoints_cors_check
+  check (public.cors_valid(cors));
+
+-- 2. Lookup used by the receiver's CORS cache
+create or replace function public.get_endpoint_cors(p_slug text)
+returns jsonb
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select cors from public.endpoints where slug = lower(p_slug);
+$$;
+
+revoke all on function public.get_endpoint_cors(text) from public;
+revoke all on function public.get_endpoint_cors(text) from anon;
+revoke all on function public.get_endpoint_cors(text) from authenticated;
+grant execute on function public.get_endpoint_cors(text) to service_role;
+
+-- 3. Tell receivers to drop their cached config when it changes
+create or replace function public.notify_cors_change()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  perform pg_notify('endpoint_config', new.slug);
+  return new;
+end;
+$$;
+
+create trigger endpoint_cors_changed
+  after update of cors on public.endpoints
+  for each row
+  when (old.cors is distinct from new.cors)
+  execute function public.notify_cors_change();
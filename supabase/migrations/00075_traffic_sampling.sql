@@ -0,0 +1,572 @@
+-- ============================================================================
+-- Migration 00075: Traffic sampling
+--
+-- Very high-volume endpoints can store a sample of their traffic
+-- (endpoints.sampling):
+--   {"rate": 10}         store one request in 10
+--   {"perMinute": 600}   store at most 600 requests a minute
+-- Both may be set; a request is stored when it passes both. The receiver
+-- reads the config through get_endpoint_sampling() and samples per slug on
+-- each receiver instance, after the capture filter. Requests it leaves out
+-- reach capture_webhook with p_sampled_out: they are answered with the mock
+-- response like dry-run captures but not stored, charged to the quota,
+-- notified or forwarded.
+--
+-- Every request a sampled endpoint receives (except ones the capture filter
+-- left out) is counted in endpoint_sampling_stats, stored or not, so
+-- volumes can be scaled up from the sample. The counters restart when the
+-- sampling config changes.
+-- ============================================================================
+
+-- 1. Per-endpoint config
+create or replace function public.sampling_valid(p_config jsonb)
+returns boolean
+language sql
+immutable
+set search_path = ''
+as $$
+  select p_config is null or (
+    jsonb_typeof(p_config) = 'object'
+    and (p_config ? 'rate' or p_config ? 'perMinute')
+    and not exists (
+      select 1 from jsonb_object_keys(p_config) k where k not in ('rate', 'perMinute')
+    )
+    and (not p_config ? 'rate' or (
+      jsonb_typeof(p_config -> 'rate') = 'number'
+      and (p_config ->> 'rate')::numeric = trunc((p_config ->> 'rate')::numeric)
+      and (p_config ->> 'rate')::numeric between 2 and 1000000
+    ))
+    and (not p_config ? 'perMinute' or (
+      jsonb_typeof(p_config -> 'perMinute') = 'number'
+      and (p_config ->> 'perMinute')::numeric = trunc((p_config ->> 'perMinute')::numeric)
+      and (p_config ->> 'perMinute')::numeric between 1 and 1000000
+    ))
+  );
+$$;
+
+alter table public.endpoints
+  add column if not exists sampling jsonb;
+
+alter table public.endpoints
+  add constraint endpoints_sampling_check
+  check (public.sampling_valid(sampling));
+
+-- 2. Counters
+create table public.endpoint_sampling_stats (
+  endpoint_id      uuid primary key references public.endpoints(id) on delete cascade,
+  requests         bigint not null default 0,
+  bytes            bigint not null default 0,
+  sampled_out      bigint not null default 0,
+  sampled_out_bytes bigint not null default 0,
+  first_request_at timestamptz not null default now(),
+  last_request_at  timestamptz not null default now()
+);
+
+-- Accessed only through the service role and the receiver's procedures.
+alter table public.endpoint_sampling_stats enable row level security;
+
+create or replace function public.count_sampling(p_endpoint_id uuid, p_size integer, p_sampled_out boolean)
+returns void
+language sql
+security definer set search_path = ''
+as $$
+  insert into public.endpoint_sampling_stats (endpoint_id, requests, bytes, sampled_out, sampled_out_bytes)
+  values (
+    p_endpoint_id, 1, p_size,
+    case when p_sampled_out then 1 else 0 end,
+    case when p_sampled_out then p_size else 0 end
+  )
+  on conflict (endpoint_id) do update
+    set requests = public.endpoint_sampling_stats.requests + 1,
+        bytes = public.endpoint_sampling_stats.bytes + excluded.bytes,
+        sampled_out = public.endpoint_sampling_stats.sampled_out + excluded.sampled_out,
+        sampled_out_bytes = public.endpoint_sampling_stats.sampled_out_bytes + excluded.sampled_out_bytes,
+        last_request_at = now();
+$$;
+
+revoke all on function public.count_sampling(uuid, integer, boolean) from public;
+revoke all on function public.count_sampling(uuid, integer, boolean) from anon;
+revoke all on function public.count_sampling(uuid, integer, boolean) from authenticated;
+grant execute on function public.count_sampling(uuid, integer, boolean) to service_role;
+
+-- 3. Lookup used by the receiver's sampling cache
+create or replace function public.get_endpoint_sampling(p_slug text)
+returns jsonb
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select sampling from public.endpoints where slug = lower(p_slug);
+$$;
+
+revoke all on function public.get_endpoint_sampling(text) from public;
+revoke all on function public.get_endpoint_sampling(text) from anon;
+revoke all on function public.get_endpoint_sampling(text) from authenticated;
+grant execute on function public.get_endpoint_sampling(text) to service_role;
+
+-- 4. Tell receivers to drop their cached config when it changes, and restart
+--    the counters for the new config
+create or replace function public.notify_sampling_change()
+returns trigger
+language plpgsql
+security definer set search_path = ''
+as $$
+begin
+  delete from public.endpoint_sampling_stats where endpoint_id = new.id;
+  perform pg_notify('endpoint_config', new.slug);
+  return new;
+end;
+$$;
+
+create trigger endpoint_sampling_changed
+  after update of sampling on public.endpoints
+  for each row
+  when (old.sampling is distinct from new.sampling)
+  execute function public.notify_sampling_change();
+
+-- 5. capture_webhook with an optional 39th parameter p_sampled_out
+drop function if exists public.capture_webhook(
+  text, text, text, jsonb, text, jsonb, text, text, timestamptz, bytea, timestamptz, text, jsonb, text,
+  text, integer, jsonb, jsonb, text, text, jsonb, jsonb, text, text, text, text, text, boolean,
+  boolean, jsonb, boolean, jsonb, jsonb, jsonb, bigint, text, jsonb, boolean
+);
+
+create or replace function public.capture_webhook(
+  p_slug        text,
+  p_method      text,
+  p_path        text,
+  p_headers     jsonb,
+  p_body        text,
+  p_query_params jsonb,
+  p_content_type text,
+  p_ip          text,
+  p_received_at timestamptz,
+  p_body_raw    bytea default null,
+  p_bypass_expires timestamptz default null,
+  p_body_hash   text default null,
+  p_parts       jsonb default null,
+  p_country     text default null,
+  p_body_ref    text default null,
+  p_body_size   integer default null,
+  p_frame       jsonb default null,
+  p_cloud_event jsonb default null,
+  p_canonical_hash text default null,
+  p_http_version text default null,
+  p_client_cert jsonb default null,
+  p_trailers    jsonb default null,
+  p_delivery_key text default null,
+  p_fingerprint text default null,
+  p_provider    text default null,
+  p_event_type  text default null,
+  p_content_class text default null,
+  p_signature_valid boolean default null,
+  p_jwt_valid   boolean default null,
+  p_jwt_claims  jsonb default null,
+  p_schema_valid boolean default null,
+  p_schema_errors jsonb default null,
+  p_sizes       jsonb default null,
+  p_redactions  jsonb default null,
+  p_asn         bigint default null,
+  p_as_org      text default null,
+  p_tls         jsonb default null,
+  p_filtered    boolean default false,
+  p_sampled_out boolean default false
+)
+returns jsonb
+language plpgsql
+security definer set search_path = ''
+as $$
+declare
+  v_endpoint    record;
+  v_user        record;
+  v_quota       record;
+  v_period      record;
+  v_retry_after bigint;
+  v_size        integer;
+  v_mock        jsonb;
+  v_slug        text;
+  v_remaining   integer;
+  v_limit       integer;
+  v_reset       timestamptz;
+  v_duplicate_of uuid;
+  v_variant     record;
+  v_variant_index integer;
+  v_variant_name text;
+  v_window_index integer;
+  v_mock_source jsonb;
+  v_mock_version text;
+  v_roll        numeric;
+  v_cumulative  numeric;
+  v_policy      jsonb;
+  v_ip          inet;
+  v_tags        text[] := '{}';
+  v_tag         text;
+  v_rule        jsonb;
+  v_rejected    text;
+  v_request_id  uuid;
+  v_body_hash   text;
+  v_priority    boolean := false;
+begin
+  -- Normalize slug to lowercase for case-insensitive lookup
+  v_slug := lower(p_slug);
+
+  -- 1. Look up endpoint by slug
+  select id, user_id, is_ephemeral, expires_at, mock_response, request_count, notification_url,
+         function_sink, info_headers, paused_at, paused_response, network_policy,
+         record_responses, canonical_json, priority_rule, dry_run, auto_extend_idle_ms,
+         mock_canary, sampling
+    into v_endpoint
+    from public.endpoints
+   where slug = v_slug;
+
+  if not found then
+    return jsonb_build_object('status', 'not_found');
+  end if;
+
+  -- 2. Check expiry
+  if v_endpoint.expires_at is not null and v_endpoint.expires_at <= now() then
+    return jsonb_build_object('status', 'expired');
+  end if;
+
+  -- 3. Paused endpoints answer without storing, forwarding or counting the
+  --    request
+  if v_endpoint.paused_at is not null then
+    return jsonb_build_object('status', 'paused', 'paused_response', v_endpoint.paused_response);
+  end if;
+
+  -- 4. Network policy: requests matching the deny rule, or outside the allow
+  --    rule, are rejected before the quota check (and kept in
+  --    rejected_requests when the policy asks for it); tag rules label the
+  --    ones that pass
+  v_policy := v_endpoint.network_policy;
+  if v_policy is not null then
+    begin
+      v_ip := nullif(p_ip, '')::inet;
+    exception when others then
+      v_ip := null;
+    end;
+
+    if v_policy ? 'deny'
+       and public.network_rule_matches(v_policy -> 'deny', v_ip, p_country)
+    then
+      v_rejected := 'denied';
+    elsif v_policy ? 'allow'
+       and not public.network_rule_matches(v_policy -> 'allow', v_ip, p_country)
+    then
+      v_rejected := 'blocked';
+    end if;
+
+    if v_rejected is not null then
+      perform public.count_network_match(v_endpoint.id, v_rejected);
+      if (v_policy ->> 'captureRejected')::boolean and not v_endpoint.dry_run then
+        perform public.record_rejected_request(
+          v_endpoint.id, v_rejected, p_method, p_path, p_ip, p_country,
+          p_headers ->> 'user-agent', p_received_at
+        );
+      end if;
+      return jsonb_build_object('status', 'blocked');
+    end if;
+
+    for v_rule in select value from jsonb_array_elements(coalesce(v_policy -> 'tags', '[]'))
+    loop
+      if public.network_rule_matches(v_rule, v_ip, p_country) then
+        v_tags := array_append(v_tags, v_rule ->> 'tag');
+      end if;
+    end loop;
+  end if;
+
+  -- 5. Quota check (branching by endpoint type). A verified bypass token
+  --    skips it entirely and is audited instead; usage isn't counted.
+  --    Dry-run, filtered and sampled-out captures are never stored, so they
+  --    aren't counted either.
+  if v_endpoint.dry_run or p_filtered or p_sampled_out then
+    null;
+
+  elsif p_bypass_expires is not null then
+    insert into public.quota_bypass_audit (endpoint_id, token_expires_at, ip, method, path)
+    values (v_endpoint.id, p_bypass_expires, p_ip, p_method, left(p_path, 1000));
+
+  elsif v_endpoint.is_ephemeral and v_endpoint.user_id is null then
+    -- Ephemeral endpoint: atomic increment with 25-request cap
+    select request_count into v_quota
+      from public.check_and_increment_ephemeral(v_endpoint.id);
+
+    if not found then
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'info', public.endpoint_info(v_endpoint.info_headers, 0, 25, null, v_endpoint.expires_at)
+      );
+    end if;
+
+    v_remaining := greatest(25 - v_quota.request_count, 0);
+    v_limit := 25;
+
+  elsif v_endpoint.user_id is not null then
+    -- Owned endpoint: check user quota
+    select id, plan, request_limit, requests_used, period_end
+      into v_user
+      from public.users
+     where id = v_endpoint.user_id;
+
+    if not found then
+      return jsonb_build_object('status', 'not_found');
+    end if;
+
+    -- Free user with expired or unstarted period: start a new one
+    if v_user.plan = 'free' and (v_user.period_end is null or v_user.period_end <= now()) then
+      select remaining, quota_limit, period_end_ts into v_period
+        from public.start_free_period(v_endpoint.user_id);
+
+      if not found then
+        -- Period start failed (shouldn't happen, but handle gracefully)
+        return jsonb_build_object('status', 'quota_exceeded');
+      end if;
+
+      -- Refresh user row after period reset
+      select id, plan, request_limit, requests_used, period_end
+        into v_user
+        from public.users
+       where id = v_endpoint.user_id;
+    end if;
+
+    -- Atomic quota check + decrement
+    select remaining, quota_limit, period_end_ts into v_quota
+      from public.check_and_decrement_quota(v_endpoint.user_id, 1);
+
+    if not found then
+      -- Quota exceeded
+      v_retry_after := null;
+      if v_user.period_end is not null and v_user.period_end > now() then
+        v_retry_after := extract(epoch from (v_user.period_end - now()))::bigint * 1000;
+      end if;
+
+      return jsonb_build_object(
+        'status', 'quota_exceeded',
+        'retry_after', v_retry_after,
+        'info', public.endpoint_info(
+          v_endpoint.info_headers, 0, v_user.request_limit, v_user.period_end, v_endpoint.expires_at
+        )
+      );
+    end if;
+
+    v_remaining := v_quota.remaining;
+    v_limit := v_quota.quota_limit;
+    v_reset := v_quota.period_end_ts;
+
+  end if;
+  -- else: owned endpoint with null user_id but not ephemeral — allow through (no quota)
+
+  -- 6. Duplicate detection: a capture carrying a provider delivery key
+  --    points at the first request with the same key in the last 3 days.
+  --    Without a key, the same method, path and body as a capture in the
+  --    last 10 minutes points at the first request of that group.
+  --    Endpoints comparing canonical JSON hash the canonical form instead,
+  --    when the receiver sent one (the body is JSON and not already canonical).
+  v_body_hash := p_body_hash;
+  if v_endpoint.canonical_json and p_canonical_hash is not null then
+    v_body_hash := p_canonical_hash;
+  end if;
+
+  if p_delivery_key is not null then
+    select coalesce(r.duplicate_of, r.id)
+      into v_duplicate_of
+      from public.requests r
+     where r.endpoint_id = v_endpoint.id
+       and r.delivery_key = p_delivery_key
+       and r.received_at > p_received_at - interval '3 days'
+     order by r.received_at desc
+     limit 1;
+  elsif v_body_hash is not null then
+    select coalesce(r.duplicate_of, r.id)
+      into v_duplicate_of
+      from public.requests r
+     where r.endpoint_id = v_endpoint.id
+       and r.body_hash = v_body_hash
+       and r.method = p_method
+       and r.path = p_path
+       and r.received_at > p_received_at - interval '10 minutes'
+     order by r.received_at desc
+     limit 1;
+  end if;
+
+  -- 7. Pick the mock response: while a canary runs, the share of senders its
+  --    percent covers get the canary's response instead of the stable one,
+  --    bucketed by a hash of the sender's IP so each sender keeps getting the
+  --    same version. Within the chosen response a scheduled window open when
+  --    the request arrived wins, otherwise roll for a weighted variant when
+  --    it defines any
+  v_mock := null;
+  v_mock_source := v_endpoint.mock_response;
+  if v_endpoint.mock_canary is not null then
+    if public.mock_canary_bucket(p_ip, v_endpoint.mock_canary ->> 'startedAt')
+       < (v_endpoint.mock_canary ->> 'percent')::integer
+    then
+      v_mock_source := v_endpoint.mock_canary -> 'response';
+      v_mock_version := 'canary';
+    else
+      v_mock_version := 'stable';
+    end if;
+  end if;
+  if v_mock_source is not null
+     and jsonb_typeof(v_mock_source) = 'object'
+     and (v_mock_source ? 'status')
+  then
+    v_mock := v_mock_source;
+    v_window_index := public.open_mock_window(v_mock -> 'schedule', p_received_at);
+
+    if v_window_index is not null then
+      v_variant_name := v_mock -> 'schedule' -> v_window_index ->> 'name';
+    elsif jsonb_typeof(v_mock -> 'variants') = 'array'
+       and jsonb_array_length(v_mock -> 'variants') > 0
+    then
+      v_roll := random() * 100;
+      v_cumulative := 0;
+      for v_variant in
+        select value, ordinality - 1 as idx
+          from jsonb_array_elements(v_mock -> 'variants') with ordinality
+      loop
+        v_cumulative := v_cumulative + coalesce((v_variant.value ->> 'weight')::numeric, 0);
+        if v_roll < v_cumulative then
+          v_variant_index := v_variant.idx;
+          v_variant_name := v_variant.value ->> 'name';
+          exit;
+        end if;
+      end loop;
+      v_variant_name := coalesce(v_variant_name, 'default');
+    end if;
+  end if;
+
+  -- High priority when the endpoint's priority header is present and, if the
+  -- rule lists values, matches one of them. Header names arrive lowercased.
+  if v_endpoint.priority_rule is not null
+     and p_headers ? (v_endpoint.priority_rule ->> 'header') then
+    v_priority := jsonb_array_length(coalesce(v_endpoint.priority_rule -> 'values', '[]'::jsonb)) = 0
+      or (v_endpoint.priority_rule -> 'values')
+         ? lower(trim(p_headers ->> (v_endpoint.priority_rule ->> 'header')));
+  end if;
+
+  -- Offloaded bodies report their own size; otherwise prefer raw byte length
+  -- when available for accurate size
+  v_size := coalesce(p_body_size, octet_length(p_body_raw), octet_length(p_body), 0);
+
+  -- Sampling: count every request the sampler saw, so totals can be scaled
+  -- up from the stored sample, and answer the ones it left out as if they
+  -- had been stored, like a dry run
+  if p_sampled_out or (v_endpoint.sampling is not null and not p_filtered) then
+    perform public.count_sampling(v_endpoint.id, v_size, p_sampled_out);
+  end if;
+
+  if p_sampled_out then
+    return jsonb_build_object(
+      'status', 'ok',
+      'mock_response', v_mock,
+      'mock_variant', v_variant_index,
+      'mock_window', v_window_index,
+      'mock_canary', v_mock_version is not distinct from 'canary',
+      'retry_after', null::bigint,
+      'info', public.endpoint_info(
+        v_endpoint.info_headers, null, null, null, v_endpoint.expires_at
+      ),
+      'sampled_out', true,
+      'auto_extend', v_endpoint.auto_extend_idle_ms is not null and v_endpoint.expires_at is not null
+    );
+  end if;
+
+  -- Filtered out by the endpoint's capture filter: count it and answer as if
+  -- it had been stored, like a dry run
+  if p_filtered then
+    perform public.count_network_match(v_endpoint.id, 'filtered');
+
+    return jsonb_build_object(
+      'status', 'ok',
+      'mock_response', v_mock,
+      'mock_variant', v_variant_index,
+      'mock_window', v_window_index,
+      'mock_canary', v_mock_version is not distinct from 'canary',
+      'retry_after', null::bigint,
+      'info', public.endpoint_info(
+        v_endpoint.info_headers, null, null, null, v_endpoint.expires_at
+      ),
+      'filtered', true,
+      'auto_extend', v_endpoint.auto_extend_idle_ms is not null and v_endpoint.expires_at is not null
+    );
+  end if;
+
+  -- Dry run: count the request and the tag rules it matched, then answer as
+  -- if it had been stored, without notifications, the function sink or
+  -- response recording
+  if v_endpoint.dry_run then
+    perform public.count_dry_run(v_endpoint.id, v_size, v_mock is not null);
+    foreach v_tag in array v_tags loop
+      perform public.count_network_match(v_endpoint.id, 'tag:' || v_tag);
+    end loop;
+
+    return jsonb_build_object(
+      'status', 'ok',
+      'mock_response', v_mock,
+      'mock_variant', v_variant_index,
+      'mock_window', v_window_index,
+      'mock_canary', v_mock_version is not distinct from 'canary',
+      'retry_after', null::bigint,
+      'info', public.endpoint_info(
+        v_endpoint.info_headers, null, null, null, v_endpoint.expires_at
+      ),
+      'dry_run', true,
+      'auto_extend', v_endpoint.auto_extend_idle_ms is not null and v_endpoint.expires_at is not null
+    );
+  end if;
+
+  -- 8. Insert the request
+
+  insert into public.requests (
+    endpoint_id, user_id, method, path, headers, body, body_raw,
+    query_params, content_type, ip, size, received_at, body_hash, duplicate_of,
+    mock_variant, parts, tags, body_ref, frame, cloud_event, http_version, priority,
+    client_cert, trailers, delivery_key, fingerprint, provider, event_type, content_class,
+    signature_valid, jwt_valid, jwt_claims, schema_valid, schema_errors, sizes, redactions, mock_version,
+    country, asn, as_org, tls
+  ) values (
+    v_endpoint.id, v_endpoint.user_id, p_method, p_path, p_headers, p_body, p_body_raw,
+    p_query_params, p_content_type, p_ip, v_size, p_received_at, v_body_hash, v_duplicate_of,
+    v_variant_name, p_parts, v_tags, p_body_ref, p_frame, p_cloud_event, p_http_version, v_priority,
+    p_client_cert, p_trailers, p_delivery_key, p_fingerprint, p_provider, p_event_type,
+    p_content_class, p_signature_valid, p_jwt_valid, p_jwt_claims, p_schema_valid, p_schema_errors,
+    p_sizes,
+    p_redactions,
+    v_mock_version,
+    p_country, p_asn, left(p_as_org, 200), p_tls
+  )
+  returning id into v_request_id;
+
+  -- Count the tag rules the stored request matched
+  foreach v_tag in array v_tags loop
+    perform public.count_network_match(v_endpoint.id, 'tag:' || v_tag);
+  end loop;
+
+  -- 9. Increment endpoint request count (ephemeral already incremented above
+  --    unless the quota check was bypassed)
+  if p_bypass_expires is not null or not (v_endpoint.is_ephemeral and v_endpoint.user_id is null) then
+    perform public.increment_endpoint_request_count(v_endpoint.id, 1);
+  end if;
+
+  -- User requests_used already incremented by check_and_decrement_quota
+
+  -- 10. Build response
+  return jsonb_build_object(
+    'status', 'ok',
+    'mock_response', v_mock,
+    'mock_variant', v_variant_index,
+    'mock_window', v_window_index,
+    'mock_canary', v_mock_version is not distinct from 'canary',
+    'retry_after', null::bigint,
+    'notification_url', v_endpoint.notification_url,
+    'function_sink', v_endpoint.function_sink,
+    'info', public.endpoint_info(
+      v_endpoint.info_headers, v_remaining, v_limit, v_reset, v_endpoint.expires_at
+    ),
+    'record_response_id', case when v_endpoint.record_responses then v_request_id end,
+    'priority', v_priority,
+    'auto_extend', v_endpoint.auto_extend_idle_ms is not null and v_endpoint.expires_at is not null
+  );
+end;
+$$;
@@ -0,0 +1,31 @@
+-- ============================================================================
+-- Migration 00076: Expired endpoint sweep
+--
+-- capture_webhook already refuses requests to expired endpoints, but each
+-- receiver keeps per-slug state (cached configs, pending auto-extend
+-- activity) until it ages out. Receivers now sweep: every half minute they
+-- ask for the endpoints that expired since their last sweep and drop that
+-- state right away, before cleanup_expired_ephemeral_endpoints() deletes the
+-- endpoints themselves.
+-- ============================================================================
+
+create or replace function public.expired_endpoint_slugs(
+  p_since timestamptz,
+  p_until timestamptz
+)
+returns setof text
+language sql
+stable
+security definer set search_path = ''
+as $$
+  select slug
+    from public.endpoints
+   where expires_at > p_since
+     and expires_at <= p_until
+   limit 10000;
+$$;
+
+revoke all on function public.expired_endpoint_slugs(timestamptz, timestamptz) from public;
+revoke all on function public.expired_endpoint_slugs(timestamptz, timestamptz) from anon;
+revoke all on function public.expired_endpoint_slugs(timestamptz, timestamptz) from authenticated;
+grant execute on function public.expired_endpoint_slugs(timestamptz, timestamptz) to service_role;
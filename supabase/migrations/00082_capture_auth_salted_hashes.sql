@@ -0,0 +1,38 @@
+-- ============================================================================
+-- Migration 00082: Salted capture credentials
+--
+-- endpoints.capture_auth now stores the password or key as a salted
+-- PBKDF2-HMAC-SHA256 hash instead of bare SHA-256 hex:
+--   "passwordHash": "pbkdf2-sha256$<iterations>$<salt base64>$<hash base64>"
+-- Configs saved before keep their SHA-256 hex, which the receiver still
+-- compares (in constant time) until the owner sets the credentials again.
+-- ============================================================================
+
+create or replace function public.capture_auth_valid(p_config jsonb)
+returns boolean
+language sql
+immutable
+set search_path = ''
+as $$
+  select p_config is null or (
+    jsonb_typeof(p_config) = 'object'
+    and (
+      (
+        p_config->>'type' = 'basic'
+        and jsonb_typeof(p_config->'username') = 'string'
+        and p_config->>'username' ~ '^[^:]{1,200}$'
+        and jsonb_typeof(p_config->'passwordHash') = 'string'
+        and p_config->>'passwordHash'
+          ~ '^(pbkdf2-sha256\$[1-9][0-9]{0,6}\$[A-Za-z0-9+/=]{4,88}\$[A-Za-z0-9+/]{43}=|[0-9a-f]{64})$'
+      )
+      or (
+        p_config->>'type' = 'api_key'
+        and jsonb_typeof(p_config->'header') = 'string'
+        and p_config->>'header' ~ '^[a-z0-9_-]{1,100}$'
+        and jsonb_typeof(p_config->'keyHash') = 'string'
+        and p_config->>'keyHash'
+          ~ '^(pbkdf2-sha256\$[1-9][0-9]{0,6}\$[A-Za-z0-9+/=]{4,88}\$[A-Za-z0-9+/]{43}=|[0-9a-f]{64})$'
+      )
+    )
+  );
+$$;